@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/config/validation"
+	"sing-box-web/pkg/lifecycle"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/server/agent"
+)
+
+// NewAgentCommand creates a new agent command
+func NewAgentCommand(ctx context.Context) *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "sing-box-agent",
+		Short: "Sing-box node agent",
+		Long:  "The sing-box-agent runs sing-box on a node and manages it for the sing-box-api server.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(ctx, configPath)
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+
+	return cmd
+}
+
+// loadConfig loads the configuration file over the defaults
+func loadConfig(configPath string) (*configv1.AgentConfig, error) {
+	config := configv1.DefaultAgentConfig()
+	if configPath != "" {
+		data, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+	return config, nil
+}
+
+func run(ctx context.Context, configPath string) error {
+	// Load configuration
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if err := validation.ValidateAgentConfig(config); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Initialize logger
+	if err := logger.InitLogger(config.Log); err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	log := logger.GetLogger().Named("agent-main")
+	log.Info("Starting sing-box-agent",
+		zap.String("node_id", config.Node.NodeID),
+		zap.String("api_server", config.APIServer.Address),
+		zap.Int("api_port", config.APIServer.Port),
+	)
+
+	// The agent serves its health endpoints, metrics and tracing itself
+	nodeAgent, err := agent.NewAgent(*config)
+	if err != nil {
+		return fmt.Errorf("failed to create agent: %w", err)
+	}
+
+	// Background jobs run until the shutdown stops them
+	jobs, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	if err := nodeAgent.Start(jobs); err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+
+	// Apply the log level and monitor intervals of the file while running
+	if configPath != "" {
+		if err := watchConfig(jobs, configPath, nodeAgent, log); err != nil {
+			return err
+		}
+	}
+
+	// Stopped in reverse: the agent with sing-box, then its background jobs
+	shutdown := lifecycle.NewManager(config.Shutdown, log)
+	shutdown.Add("background jobs", func(context.Context) error {
+		stopJobs()
+		return nil
+	})
+	shutdown.Add("agent", nodeAgent.Stop)
+	shutdown.AddReadiness(nodeAgent)
+	shutdown.SetReady()
+
+	err = shutdown.Run(ctx)
+	log.Info("sing-box-agent stopped")
+	return err
+}
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/config"
+	"sing-box-web/pkg/config/validation"
+	"sing-box-web/pkg/server/agent"
+)
+
+// watchConfig applies the changes of the configuration file to the agent
+// until ctx is done. A file that fails to load or validate is rejected and
+// the current configuration stays in effect.
+func watchConfig(ctx context.Context, configPath string, nodeAgent *agent.Agent, log *zap.Logger) error {
+	return config.Watch(ctx, configPath, log, func() {
+		reloaded, err := loadConfig(configPath)
+		if err == nil {
+			err = validation.ValidateAgentConfig(reloaded)
+		}
+		if err != nil {
+			log.Error("Configuration reload rejected, keeping the current configuration",
+				zap.String("path", configPath), zap.Error(err))
+			return
+		}
+		nodeAgent.Reload(reloaded)
+	})
+}
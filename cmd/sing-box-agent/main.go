@@ -8,8 +8,8 @@ import (
 )
 
 func main() {
-	ctx := context.TODO()
-	rootCmd := app.NewAPICommand(ctx)
+	ctx := context.Background()
+	rootCmd := app.NewAgentCommand(ctx)
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/seed"
+)
+
+// bootstrapAdmin refuses to go on while the placeholder password of earlier
+// releases is in use, and on first run creates the super admin with the
+// password of the environment or a generated one, printed this once
+func bootstrapAdmin(dbService *database.Service, log *zap.Logger) error {
+	if err := seed.CheckPlaceholderPasswords(dbService.GetDB()); err != nil {
+		return fmt.Errorf("%w, set a password with sing-box-api reset-admin-password", err)
+	}
+
+	result, err := seed.Bootstrap(dbService.GetDB(), os.Getenv(seed.AdminPasswordEnv))
+	if err != nil {
+		return fmt.Errorf("failed to create default data: %w", err)
+	}
+	if !result.AdminCreated {
+		return nil
+	}
+	log.Info("Created super admin on first run", zap.String("username", seed.AdminUsername))
+	if result.AdminPassword != "" {
+		// Kept out of the logs, which outlive the first login
+		fmt.Fprintf(os.Stderr, "Generated password of super admin %q: %s\n"+
+			"It is not shown again, change it after the first login.\n", seed.AdminUsername, result.AdminPassword)
+	}
+	return nil
+}
+
+// newResetAdminPasswordCommand creates the command setting the password of
+// an admin locked out, or of one with the placeholder password
+func newResetAdminPasswordCommand() *cobra.Command {
+	var configPath, username, password string
+
+	cmd := &cobra.Command{
+		Use:   "reset-admin-password",
+		Short: "Set the password of an admin, generating one unless given",
+		Long: "Sets the password of an admin and clears its failed logins and lock. The password is " +
+			"taken from --password or the " + seed.AdminPasswordEnv + " environment variable, " +
+			"and generated and printed when neither is set.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if password == "" {
+				password = os.Getenv(seed.AdminPasswordEnv)
+			}
+
+			dbService, err := openMigrateDatabase(configPath)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			if err := dbService.CheckSchema(); err != nil {
+				return fmt.Errorf("%w, run sing-box-api migrate up", err)
+			}
+
+			generated, err := seed.ResetPassword(dbService.GetDB(), username, password)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if generated != "" {
+				fmt.Fprintf(out, "Generated password of %q: %s\n", username, generated)
+				return nil
+			}
+			fmt.Fprintf(out, "Password of %q set\n", username)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	cmd.Flags().StringVar(&username, "username", seed.AdminUsername, "Username of the admin")
+	cmd.Flags().StringVar(&password, "password", "", "New password, generated when empty")
+	return cmd
+}
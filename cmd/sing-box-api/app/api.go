@@ -11,8 +11,11 @@ import (
 
 	configv1 "sing-box-web/pkg/config/v1"
 	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/lifecycle"
 	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/metrics"
 	"sing-box-web/pkg/server/api"
+	"sing-box-web/pkg/tracing"
 )
 
 // NewAPICommand creates a new API command
@@ -29,23 +32,33 @@ func NewAPICommand(ctx context.Context) *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	cmd.AddCommand(newTelemetryCommand(), newMigrateCommand(), newSeedCommand(), newImportCommand(), newResetAdminPasswordCommand())
 
 	return cmd
 }
 
-func run(ctx context.Context, configPath string) error {
-	// Load configuration
+// loadConfig loads the configuration file over the defaults
+func loadConfig(configPath string) (*configv1.APIConfig, error) {
 	config := configv1.DefaultAPIConfig()
 	if configPath != "" {
 		data, err := ioutil.ReadFile(configPath)
 		if err != nil {
-			return fmt.Errorf("failed to read config file: %w", err)
+			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 		
 		if err := yaml.Unmarshal(data, config); err != nil {
-			return fmt.Errorf("failed to parse config file: %w", err)
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
 		}
 	}
+	return config, nil
+}
+
+func run(ctx context.Context, configPath string) error {
+	// Load configuration
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
 
 	// Initialize logger
 	if err := logger.InitLogger(config.Log); err != nil {
@@ -58,15 +71,39 @@ func run(ctx context.Context, configPath string) error {
 		zap.Int("port", config.GRPC.Port),
 	)
 
+	// Initialize Prometheus metrics
+	metrics.InitGlobalMetrics(log.Named("metrics"))
+	metrics.SetSeriesLimits(config.Metrics.MaxUserSeries, config.Metrics.MaxNodeSeries)
+	if err := metrics.GetGlobalMetrics().StartMetricsServer(config.Metrics); err != nil {
+		return fmt.Errorf("failed to start metrics server: %w", err)
+	}
+
+	// Initialize tracing before the components it instruments
+	stopTracing, err := tracing.Setup(ctx, config.SkyWalking, log.Named("tracing"))
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
 	// Initialize database
 	dbService, err := database.New(config.Database, log)
 	if err != nil {
 		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	// Run database migrations
-	if err := dbService.AutoMigrate(); err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
+	// Run database migrations, or refuse to run on a schema of another
+	// version when they are left to the migrate command
+	if err := dbService.PrepareSchema(); err != nil {
+		return fmt.Errorf("failed to prepare database schema: %w", err)
+	}
+	if err := bootstrapAdmin(dbService, log); err != nil {
+		return err
+	}
+
+	// Serve traffic summaries from the analytics storage
+	if config.Analytics.Enabled {
+		if err := dbService.EnableAnalytics(config.Analytics); err != nil {
+			return fmt.Errorf("failed to enable analytics storage: %w", err)
+		}
 	}
 
 	// Create and start API server
@@ -75,13 +112,36 @@ func run(ctx context.Context, configPath string) error {
 		return fmt.Errorf("failed to create API server: %w", err)
 	}
 
-	if err := server.Start(ctx); err != nil {
+	// Background jobs run until the shutdown stops them
+	jobs, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	if err := server.Start(jobs); err != nil {
 		return fmt.Errorf("failed to start API server: %w", err)
 	}
 
-	// Wait for context cancellation
-	<-ctx.Done()
+	// Apply the log level, business and alert settings of the file while running
+	if configPath != "" {
+		if err := watchConfig(jobs, configPath, server, log); err != nil {
+			return err
+		}
+	}
 
-	log.Info("Shutting down sing-box-api")
-	return server.Stop(ctx)
+	// Stopped in reverse: the server, its background jobs, the database, then
+	// tracing, flushing the spans of the shutdown
+	shutdown := lifecycle.NewManager(config.Shutdown, log)
+	shutdown.Add("tracing", stopTracing)
+	shutdown.Add("database", func(context.Context) error {
+		return dbService.Close()
+	})
+	shutdown.Add("background jobs", func(context.Context) error {
+		stopJobs()
+		return nil
+	})
+	shutdown.Add("api server", server.Stop)
+	shutdown.AddReadiness(server)
+	shutdown.SetReady()
+
+	err = shutdown.Run(ctx)
+	log.Info("sing-box-api stopped")
+	return err
 }
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"sing-box-web/pkg/importer"
+)
+
+// newImportCommand creates the command importing the users, plans and nodes
+// of another panel
+func newImportCommand() *cobra.Command {
+	var configPath, reportPath string
+	var load importer.LoadOptions
+	var opts importer.Options
+
+	cmd := &cobra.Command{
+		Use:   "import <" + strings.Join(importer.Sources, "|") + ">",
+		Short: "Import the users, plans and nodes of another panel",
+		Long: "Reads the database of a v2board, x-ui or marzban panel, or the JSON of the marzban user " +
+			"listing API, and creates its node groups, nodes, plans and users. Users get new subscription " +
+			"tokens and keep their UUID, and their password when it is a bcrypt hash. Records already " +
+			"present are kept, so the command can be rerun. With --dry-run nothing is written.",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: importer.Sources,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := importer.Load(args[0], load)
+			if err != nil {
+				return err
+			}
+
+			dbService, err := openMigrateDatabase(configPath)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			if err := dbService.PrepareSchema(); err != nil {
+				return fmt.Errorf("failed to prepare database schema: %w", err)
+			}
+
+			report, err := importer.Run(dbService.GetDB(), data, opts)
+			if err != nil {
+				return err
+			}
+			if err := printImportReport(cmd, report); err != nil {
+				return err
+			}
+			if reportPath == "" {
+				return nil
+			}
+			content, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			// The report holds the subscription tokens of the users
+			if err := os.WriteFile(reportPath, content, 0o600); err != nil {
+				return fmt.Errorf("failed to write import report: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote the report to %s\n", reportPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	cmd.Flags().StringVar(&load.Path, "from", "", "Database of the panel, a SQLite file or a mysql:// DSN, or a marzban users .json export")
+	cmd.Flags().StringVar(&load.NodeHost, "node-host", "", "Public address of the x-ui server, the host of its imported inbounds")
+	cmd.Flags().UintVar(&opts.DefaultPlanID, "default-plan", 0, "Plan of the users without one, the first plan when unset")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Report what would be imported without writing anything")
+	cmd.Flags().StringVar(&reportPath, "report", "", "Write the reconciliation report, with the subscription tokens of the users, to this JSON file")
+	return cmd
+}
+
+// printImportReport prints the records created, found and skipped by kind,
+// and the reasons of the skipped ones
+func printImportReport(cmd *cobra.Command, report *importer.Report) error {
+	out := cmd.OutOrStdout()
+	if report.DryRun {
+		fmt.Fprintln(out, "Dry run, nothing was written")
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tCREATED\tEXISTED\tSKIPPED")
+	for _, kind := range []string{"group", "node", "plan", "user"} {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", kind,
+			report.Count(kind, importer.ActionCreated),
+			report.Count(kind, importer.ActionExisted),
+			report.Count(kind, importer.ActionSkipped))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var resets int
+	for _, user := range report.Users {
+		if user.PasswordReset {
+			resets++
+		}
+	}
+	if resets > 0 {
+		fmt.Fprintf(out, "%d users have no usable password and must reset it\n", resets)
+	}
+	for _, entry := range report.Entries {
+		if entry.Action == importer.ActionCreated || entry.Detail == "" {
+			continue
+		}
+		fmt.Fprintf(out, "%s %s %s %s: %s\n", entry.Action, entry.Kind, entry.Key, entry.Name, entry.Detail)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/logger"
+)
+
+// newMigrateCommand creates the command group of the database schema
+// migrations, for deployments applying them apart from the servers
+func newMigrateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the database schema migrations",
+	}
+	cmd.AddCommand(newMigrateUpCommand(), newMigrateDownCommand(), newMigrateStatusCommand())
+	return cmd
+}
+
+// newMigrateUpCommand creates the command applying the pending migrations
+func newMigrateUpCommand() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply the pending migrations to the shared and tenant databases",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbService, err := openMigrateDatabase(configPath)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			return dbService.Migrate()
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	return cmd
+}
+
+// newMigrateDownCommand creates the command reverting the last migrations
+func newMigrateDownCommand() *cobra.Command {
+	var configPath string
+	var steps int
+
+	cmd := &cobra.Command{
+		Use:   "down",
+		Short: "Revert the last migrations of the shared database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if steps < 1 {
+				return fmt.Errorf("--steps must be at least 1")
+			}
+			dbService, err := openMigrateDatabase(configPath)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			reverted, err := dbService.Rollback(steps)
+			for _, m := range reverted {
+				fmt.Fprintf(cmd.OutOrStdout(), "Reverted %d %s\n", m.Version, m.Description)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	cmd.Flags().IntVar(&steps, "steps", 1, "Number of migrations to revert")
+	return cmd
+}
+
+// newMigrateStatusCommand creates the command listing the migrations of
+// every database with when they were applied
+func newMigrateStatusCommand() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the applied and pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dbService, err := openMigrateDatabase(configPath)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			statuses, err := dbService.SchemaStatus()
+			if err != nil {
+				return err
+			}
+			names := make([]string, 0, len(statuses))
+			for name := range statuses {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "DATABASE\tVERSION\tDESCRIPTION\tAPPLIED")
+			for _, name := range names {
+				for _, status := range statuses[name] {
+					applied := "pending"
+					if status.AppliedAt != nil {
+						applied = status.AppliedAt.Format("2006-01-02 15:04:05")
+					}
+					fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", name, status.Version, status.Description, applied)
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	return cmd
+}
+
+// openMigrateDatabase opens the databases of a configuration for the
+// commands run apart from the server, logging to the console
+func openMigrateDatabase(configPath string) (*database.Service, error) {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := logger.InitLogger(config.Log); err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	dbService, err := database.New(config.Database, logger.GetLogger().Named("migrate"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	return dbService, nil
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"sing-box-web/pkg/seed"
+)
+
+// newSeedCommand creates the command creating the default plan and super
+// admin, and with --demo demo data for load tests and UI development
+func newSeedCommand() *cobra.Command {
+	var configPath string
+	opts := seed.DefaultOptions()
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Create the default data, and demo data with --demo",
+		Long: "Creates the default plan and the admin super admin when missing. With --demo, " +
+			"also creates demo plans, nodes, users (password " + seed.DemoPassword + ") and " +
+			"traffic history. Records already present are kept, so the command can be rerun.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.AdminPassword == "" {
+				opts.AdminPassword = os.Getenv(seed.AdminPasswordEnv)
+			}
+
+			dbService, err := openMigrateDatabase(configPath)
+			if err != nil {
+				return err
+			}
+			defer dbService.Close()
+
+			if err := dbService.PrepareSchema(); err != nil {
+				return fmt.Errorf("failed to prepare database schema: %w", err)
+			}
+
+			result, err := seed.Run(dbService.GetDB(), opts)
+			if result != nil {
+				out := cmd.OutOrStdout()
+				if result.AdminCreated {
+					fmt.Fprintf(out, "Created super admin %q\n", seed.AdminUsername)
+				}
+				if result.AdminPassword != "" {
+					fmt.Fprintf(out, "Generated admin password: %s\n", result.AdminPassword)
+				}
+				if opts.Demo {
+					fmt.Fprintf(out, "Created %d demo plans, %d nodes, %d users and %d traffic records\n",
+						result.Plans, result.Nodes, result.Users, result.TrafficRecords)
+				}
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	cmd.Flags().StringVar(&opts.AdminPassword, "admin-password", "", "Password of the super admin when created, from "+seed.AdminPasswordEnv+" or generated when empty")
+	cmd.Flags().BoolVar(&opts.Demo, "demo", false, "Create demo data, never use on a production database")
+	cmd.Flags().IntVar(&opts.Plans, "plans", opts.Plans, "Number of demo plans")
+	cmd.Flags().IntVar(&opts.Nodes, "nodes", opts.Nodes, "Number of demo nodes")
+	cmd.Flags().IntVar(&opts.Users, "users", opts.Users, "Number of demo users")
+	cmd.Flags().IntVar(&opts.TrafficDays, "traffic-days", opts.TrafficDays, "Days of traffic history of each demo user")
+	cmd.Flags().Uint64Var(&opts.RandSeed, "rand-seed", opts.RandSeed, "Seed of the demo data, the same seed gives the same data")
+	return cmd
+}
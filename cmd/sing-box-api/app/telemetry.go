@@ -0,0 +1,66 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/telemetry"
+)
+
+// newTelemetryCommand creates the command group of the anonymous usage
+// telemetry
+func newTelemetryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "telemetry",
+		Short: "Inspect the anonymous usage telemetry",
+	}
+	cmd.AddCommand(newTelemetryPreviewCommand())
+	return cmd
+}
+
+// newTelemetryPreviewCommand creates the command printing the exact payload
+// the API server reports when telemetry is enabled, without sending it
+func newTelemetryPreviewCommand() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "preview",
+		Short: "Print the telemetry payload without sending it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(configPath)
+			if err != nil {
+				return err
+			}
+
+			dbService, err := database.New(config.Database, zap.NewNop())
+			if err != nil {
+				return fmt.Errorf("failed to initialize database: %w", err)
+			}
+			defer dbService.Close()
+
+			nodes, err := dbService.GetRepository().Node.GetNodeCount()
+			if err != nil {
+				return fmt.Errorf("failed to count nodes: %w", err)
+			}
+
+			data, err := json.MarshalIndent(telemetry.Collect(config.Database.Driver, nodes), "", "  ")
+			if err != nil {
+				return err
+			}
+			state := "disabled, nothing is sent"
+			if config.Telemetry.Enabled {
+				state = "enabled, sent to " + config.Telemetry.Endpoint + " every " + config.Telemetry.Interval.String()
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "# Telemetry %s\n%s\n", state, data)
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	return cmd
+}
@@ -0,0 +1,28 @@
+package app
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/config"
+	"sing-box-web/pkg/config/validation"
+	"sing-box-web/pkg/server/web"
+)
+
+// watchConfig applies the changes of the configuration file to the server
+// until ctx is done. A file that fails to load or validate is rejected and
+// the current configuration stays in effect.
+func watchConfig(ctx context.Context, configPath string, server *web.Server, log *zap.Logger) error {
+	return config.Watch(ctx, configPath, log, func() {
+		reloaded, err := loadConfig(configPath)
+		if err == nil {
+			err = validation.ValidateWebConfig(reloaded)
+		}
+		if err != nil {
+			server.RejectReload(configPath, err)
+			return
+		}
+		server.Reload(configPath, reloaded)
+	})
+}
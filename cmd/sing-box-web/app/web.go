@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/lifecycle"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/metrics"
+	"sing-box-web/pkg/seed"
+	"sing-box-web/pkg/server/web"
+	"sing-box-web/pkg/tracing"
+)
+
+// NewWebCommand creates a new web command
+func NewWebCommand(ctx context.Context) *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "sing-box-web",
+		Short: "Sing-box web server",
+		Long:  "The sing-box-web provides web ui for sing-box management platform.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(ctx, configPath)
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to configuration file")
+	cmd.AddCommand(newOpenAPICommand())
+
+	return cmd
+}
+
+// newOpenAPICommand creates the command printing the OpenAPI document of the
+// management API served under /api/v1/admin/rpc
+func newOpenAPICommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "openapi",
+		Short: "Print the OpenAPI document of the management API",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := web.ManagementOpenAPI()
+			if err != nil {
+				return fmt.Errorf("failed to generate OpenAPI document: %w", err)
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+			return err
+		},
+	}
+}
+
+// loadConfig loads the configuration file over the defaults
+func loadConfig(configPath string) (*configv1.WebConfig, error) {
+	config := configv1.DefaultWebConfig()
+	if configPath != "" {
+		data, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+	}
+	return config, nil
+}
+
+func run(ctx context.Context, configPath string) error {
+	// Load configuration
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	// Initialize logger
+	if err := logger.InitLogger(config.Log); err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	log := logger.GetLogger().Named("web-main")
+	log.Info("Starting sing-box-web",
+		zap.String("address", config.Server.Address),
+		zap.Int("port", config.Server.Port),
+	)
+
+	// Initialize Prometheus metrics, exporting the API key usage
+	metrics.InitGlobalMetrics(log.Named("metrics"))
+	if err := metrics.GetGlobalMetrics().StartMetricsServer(config.Metrics); err != nil {
+		return fmt.Errorf("failed to start metrics server: %w", err)
+	}
+
+	// Initialize tracing before the components it instruments
+	stopTracing, err := tracing.Setup(ctx, config.SkyWalking, log.Named("tracing"))
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
+	// Initialize database
+	dbService, err := database.New(config.Database, log)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	// Run database migrations, or refuse to run on a schema of another
+	// version when they are left to the migrate command
+	if err := dbService.PrepareSchema(); err != nil {
+		return fmt.Errorf("failed to prepare database schema: %w", err)
+	}
+
+	// The placeholder admin password of earlier releases is public
+	if err := seed.CheckPlaceholderPasswords(dbService.GetDB()); err != nil {
+		return fmt.Errorf("%w, set a password with sing-box-api reset-admin-password", err)
+	}
+
+	// Create and start web server
+	server, err := web.NewServer(*config, dbService)
+	if err != nil {
+		return fmt.Errorf("failed to create web server: %w", err)
+	}
+
+	// Background jobs run until the shutdown stops them
+	jobs, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	if err := server.Start(jobs); err != nil {
+		return fmt.Errorf("failed to start web server: %w", err)
+	}
+
+	// Apply the log level of the file while running
+	if configPath != "" {
+		if err := watchConfig(jobs, configPath, server, log); err != nil {
+			return err
+		}
+	}
+
+	// Stopped in reverse: the server, its background jobs, the database, then
+	// tracing, flushing the spans of the shutdown
+	shutdown := lifecycle.NewManager(config.Shutdown, log)
+	shutdown.Add("tracing", stopTracing)
+	shutdown.Add("database", func(context.Context) error {
+		return dbService.Close()
+	})
+	shutdown.Add("background jobs", func(context.Context) error {
+		stopJobs()
+		return nil
+	})
+	shutdown.Add("web server", server.Stop)
+	shutdown.AddReadiness(server)
+	shutdown.SetReady()
+
+	err = shutdown.Run(ctx)
+	log.Info("sing-box-web stopped")
+	return err
+}
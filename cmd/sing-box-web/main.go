@@ -8,7 +8,7 @@ import (
 )
 
 func main() {
-	ctx := context.TODO()
+	ctx := context.Background()
 	rootCmd := app.NewWebCommand(ctx)
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,104 @@
+package alert
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// Alert is an event raised for a user, such as a quota warning
+type Alert struct {
+	UserID   uint
+	Type     models.NotificationType
+	Severity string
+	Title    string
+	Message  string
+	// Key identifies the event so that a channel delivers it to the user at
+	// most once, e.g. once per quota period. Empty keys are never deduplicated.
+	Key string
+}
+
+// Channel delivers alerts to users
+type Channel interface {
+	Name() string
+	// Send delivers the alert, doing nothing for an alert already delivered
+	Send(alert *Alert) error
+}
+
+// Engine fans the alerts raised by the background jobs out to every
+// configured delivery channel
+type Engine struct {
+	mu       sync.RWMutex
+	channels []Channel
+	logger   *zap.Logger
+}
+
+// NewEngine creates an alert engine delivering to channels
+func NewEngine(logger *zap.Logger, channels ...Channel) *Engine {
+	return &Engine{
+		channels: channels,
+		logger:   logger.Named("alert"),
+	}
+}
+
+// Raise sends the alert to every channel. A failing channel is logged and
+// does not keep the alert from the others.
+func (e *Engine) Raise(alert *Alert) {
+	e.mu.RLock()
+	channels := e.channels
+	e.mu.RUnlock()
+
+	for _, channel := range channels {
+		if err := channel.Send(alert); err != nil {
+			e.logger.Error("Failed to deliver alert",
+				zap.String("channel", channel.Name()),
+				zap.Uint("user_id", alert.UserID),
+				zap.String("type", string(alert.Type)),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// SetChannels replaces the delivery channels, e.g. when the configuration is
+// reloaded. Alerts being raised finish on the previous channels.
+func (e *Engine) SetChannels(channels ...Channel) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.channels = channels
+}
+
+// inAppChannel stores alerts in the users' notification center
+type inAppChannel struct {
+	repo repository.NotificationRepository
+}
+
+// NewInAppChannel creates a channel delivering to the in-app notification center
+func NewInAppChannel(repo repository.NotificationRepository) Channel {
+	return &inAppChannel{repo: repo}
+}
+
+// Name returns the channel name
+func (c *inAppChannel) Name() string {
+	return "in_app"
+}
+
+// Send stores the alert as a notification of the user
+func (c *inAppChannel) Send(alert *Alert) error {
+	notification := &models.Notification{
+		UserID:   alert.UserID,
+		Type:     alert.Type,
+		Severity: alert.Severity,
+		Title:    alert.Title,
+		Message:  alert.Message,
+	}
+	if alert.Key != "" {
+		key := alert.Key
+		notification.DedupKey = &key
+	}
+	_, err := c.repo.Create(notification)
+	return err
+}
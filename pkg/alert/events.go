@@ -0,0 +1,51 @@
+package alert
+
+import (
+	"strconv"
+
+	"sing-box-web/pkg/events"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// eventChannelName is the name of the event channel and of its delivery records
+const eventChannelName = "events"
+
+// eventChannel publishes alerts to the dashboards watching the event bus
+type eventChannel struct {
+	bus        *events.Bus
+	deliveries repository.AlertDeliveryRepository
+}
+
+// NewEventChannel creates a channel publishing alerts on the alerts topic of
+// bus, each keyed alert once like the other channels deliver it
+func NewEventChannel(bus *events.Bus, deliveries repository.AlertDeliveryRepository) Channel {
+	return &eventChannel{bus: bus, deliveries: deliveries}
+}
+
+// Name returns the channel name
+func (c *eventChannel) Name() string {
+	return eventChannelName
+}
+
+// Send publishes the alert unless it was published before
+func (c *eventChannel) Send(alert *Alert) error {
+	if alert.Key != "" {
+		recorded, err := c.deliveries.Record(eventChannelName, alert.UserID, alert.Key)
+		if err != nil || !recorded {
+			return err
+		}
+	}
+
+	c.bus.Publish(&pbv1.Event{
+		Type: events.TypeAlertRaised,
+		Alert: &pbv1.AlertEvent{
+			UserId:   strconv.FormatUint(uint64(alert.UserID), 10),
+			Type:     string(alert.Type),
+			Severity: alert.Severity,
+			Title:    alert.Title,
+			Message:  alert.Message,
+		},
+	})
+	return nil
+}
@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// mailChannelName is the name of the mail channel and of its delivery records
+const mailChannelName = "email"
+
+// mailTemplates maps the alert types mailed to users to their templates
+var mailTemplates = map[models.NotificationType]string{
+	models.NotificationTypeQuotaWarning:  mail.TemplateQuotaWarning,
+	models.NotificationTypeQuotaExceeded: mail.TemplateQuotaWarning,
+	models.NotificationTypePlanExpiring:  mail.TemplateExpiryReminder,
+}
+
+// mailChannel mails alerts to the users' email addresses
+type mailChannel struct {
+	mailer     *mail.Mailer
+	users      repository.UserRepository
+	deliveries repository.AlertDeliveryRepository
+}
+
+// NewMailChannel creates a channel mailing quota and expiry alerts. Other
+// alert types and users without an email address are skipped.
+func NewMailChannel(mailer *mail.Mailer, users repository.UserRepository, deliveries repository.AlertDeliveryRepository) Channel {
+	return &mailChannel{mailer: mailer, users: users, deliveries: deliveries}
+}
+
+// Name returns the channel name
+func (c *mailChannel) Name() string {
+	return mailChannelName
+}
+
+// Send queues a mail for the alert unless it was mailed before
+func (c *mailChannel) Send(alert *Alert) error {
+	template, ok := mailTemplates[alert.Type]
+	if !ok || !c.mailer.Enabled(template) {
+		return nil
+	}
+
+	user, err := c.users.GetByID(alert.UserID)
+	if err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return nil
+	}
+
+	if alert.Key != "" {
+		recorded, err := c.deliveries.Record(mailChannelName, alert.UserID, alert.Key)
+		if err != nil || !recorded {
+			return err
+		}
+	}
+
+	err = c.mailer.Send(&mail.Message{
+		Template: template,
+		To:       user.Email,
+		Username: user.Username,
+		TenantID: user.TenantID,
+		Data: map[string]string{
+			"title":   alert.Title,
+			"message": alert.Message,
+		},
+	})
+	if err != nil && alert.Key != "" {
+		// Not queued, let the next check raising the alert try again
+		_ = c.deliveries.Forget(mailChannelName, alert.UserID, alert.Key)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// telegramChannelName is the name of the Telegram channel and of its delivery records
+const telegramChannelName = "telegram"
+
+// telegramChannel sends alerts to the Telegram chats users linked
+type telegramChannel struct {
+	config     configv1.TelegramConfig
+	client     *http.Client
+	users      repository.UserRepository
+	deliveries repository.AlertDeliveryRepository
+}
+
+// NewTelegramChannel creates a channel sending alerts with a Telegram bot.
+// Users without a linked chat are skipped.
+func NewTelegramChannel(config configv1.TelegramConfig, users repository.UserRepository, deliveries repository.AlertDeliveryRepository) Channel {
+	return &telegramChannel{
+		config:     config,
+		client:     &http.Client{Timeout: config.Timeout},
+		users:      users,
+		deliveries: deliveries,
+	}
+}
+
+// Name returns the channel name
+func (c *telegramChannel) Name() string {
+	return telegramChannelName
+}
+
+// Send sends the alert to the user's chat unless it was sent before
+func (c *telegramChannel) Send(alert *Alert) error {
+	user, err := c.users.GetByID(alert.UserID)
+	if err != nil {
+		return err
+	}
+	if user.TelegramChatID == "" {
+		return nil
+	}
+
+	if alert.Key != "" {
+		recorded, err := c.deliveries.Record(telegramChannelName, alert.UserID, alert.Key)
+		if err != nil || !recorded {
+			return err
+		}
+	}
+
+	err = c.sendMessage(user.TelegramChatID, alert.Title+"\n\n"+alert.Message)
+	if err != nil && alert.Key != "" {
+		// Not sent, let the next check raising the alert try again
+		_ = c.deliveries.Forget(telegramChannelName, alert.UserID, alert.Key)
+	}
+	return err
+}
+
+// sendMessage sends a plain text message with the Bot API
+func (c *telegramChannel) sendMessage(chatID, text string) error {
+	body, err := json.Marshal(map[string]string{"chat_id": chatID, "text": text})
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(c.config.APIURL, "/") + "/bot" + c.config.BotToken + "/sendMessage"
+
+	resp, err := c.client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL holds the bot token, keep it out of the logged error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram responded with status %s", resp.Status)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram rejected the message: %s", result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+// Package apierror builds the gRPC errors returned by sing-box-web services.
+//
+// Every failed RPC returns a non-OK gRPC status; the success field of a
+// response is only ever true. Client errors carry structured details so that
+// callers can branch on them without parsing messages:
+//
+//   - an ErrorInfo with a stable Reason in the "sing-box-web" domain
+//   - a BadRequest listing the offending fields for InvalidArgument
+//   - a ResourceInfo naming the missing resource for NotFound
+//   - a PreconditionFailure for FailedPrecondition
+//
+// Internal errors carry no details so that nothing about the failure leaks.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// Domain is the ErrorInfo domain of all errors built by this package
+const Domain = "sing-box-web"
+
+// Error reasons
+const (
+	ReasonInvalidArgument = "INVALID_ARGUMENT"
+	ReasonNotFound        = "NOT_FOUND"
+
+	// Node reasons
+	ReasonNodeNameTaken      = "NODE_NAME_TAKEN"
+	ReasonNodeDeleted        = "NODE_DELETED"
+	ReasonNodeNotRegistered  = "NODE_NOT_REGISTERED"
+	ReasonNodeNotRemoved     = "NODE_NOT_REMOVED"
+	ReasonNodeAlreadyMerged  = "NODE_ALREADY_MERGED"
+	ReasonCommandQueueFull   = "COMMAND_QUEUE_FULL"
+	ReasonNodeTokenMissing   = "NODE_TOKEN_MISSING"
+	ReasonNodeTokenInvalid   = "NODE_TOKEN_INVALID"
+	ReasonNodeTokenMismatch  = "NODE_TOKEN_MISMATCH"
+	ReasonNodeGroupNameTaken = "NODE_GROUP_NAME_TAKEN"
+	ReasonNodeConfigMissing  = "NODE_CONFIG_MISSING"
+	// ReasonNodeRegistrationDisabled rejects unknown nodes while the
+	// node.registration_enabled setting is off
+	ReasonNodeRegistrationDisabled = "NODE_REGISTRATION_DISABLED"
+	// ReasonNodeEnrollmentInvalid rejects enrollment tokens that are
+	// unknown, used, revoked or expired
+	ReasonNodeEnrollmentInvalid = "NODE_ENROLLMENT_INVALID"
+	// ReasonNodeEnrollmentUsed rejects revoking an enrollment a node used
+	ReasonNodeEnrollmentUsed = "NODE_ENROLLMENT_USED"
+	// ReasonNodeLogsUnavailable is returned when a node could not read the
+	// logs asked for, such as logs written to the console
+	ReasonNodeLogsUnavailable = "NODE_LOGS_UNAVAILABLE"
+	// ReasonNodeLogsTimeout is returned when a node did not upload its logs in time
+	ReasonNodeLogsTimeout = "NODE_LOGS_TIMEOUT"
+	// ReasonNodeDiagnosticTimeout is returned when a node did not report a
+	// diagnostic in time
+	ReasonNodeDiagnosticTimeout = "NODE_DIAGNOSTIC_TIMEOUT"
+
+	// Traffic reasons
+	ReasonTrafficBufferFull = "TRAFFIC_BUFFER_FULL"
+
+	// User reasons
+	ReasonUsernameTaken          = "USERNAME_TAKEN"
+	ReasonEmailTaken             = "EMAIL_TAKEN"
+	ReasonTwoFactorEnabled       = "TWO_FACTOR_ENABLED"
+	ReasonTwoFactorNotEnabled    = "TWO_FACTOR_NOT_ENABLED"
+	ReasonTwoFactorSetupRequired = "TWO_FACTOR_SETUP_REQUIRED"
+	ReasonInvalidTwoFactorCode   = "INVALID_TWO_FACTOR_CODE"
+
+	// Plan reasons
+	ReasonPlanNameTaken           = "PLAN_NAME_TAKEN"
+	ReasonPlanInUse               = "PLAN_IN_USE"
+	ReasonPlanProtocolUnsupported = "PLAN_PROTOCOL_UNSUPPORTED"
+
+	// Order reasons
+	ReasonPlanUnavailable   = "PLAN_UNAVAILABLE"
+	ReasonOrderNotPending   = "ORDER_NOT_PENDING"
+	ReasonOrderNotPaid      = "ORDER_NOT_PAID"
+	ReasonLifetimePlanOwned = "LIFETIME_PLAN_OWNED"
+	ReasonTrialUsed         = "TRIAL_USED"
+
+	// Wallet reasons
+	ReasonInsufficientBalance = "INSUFFICIENT_BALANCE"
+	ReasonBalanceCurrency     = "BALANCE_CURRENCY"
+
+	// Coupon reasons
+	ReasonCouponCodeTaken     = "COUPON_CODE_TAKEN"
+	ReasonCouponNotApplicable = "COUPON_NOT_APPLICABLE"
+
+	// Geo data reasons
+	ReasonGeoDataDisabled = "GEO_DATA_DISABLED"
+	ReasonGeoDataChanged  = "GEO_DATA_CHANGED"
+
+	// Mail reasons
+	ReasonMailDisabled = "MAIL_DISABLED"
+
+	// Tenant reasons
+	ReasonTenantNameTaken = "TENANT_NAME_TAKEN"
+
+	// Saved filter reasons
+	ReasonSavedFilterNameTaken = "SAVED_FILTER_NAME_TAKEN"
+	ReasonSavedFilterNotOwned  = "SAVED_FILTER_NOT_OWNED"
+
+	// Blocklist reasons
+	ReasonBlocklisted            = "BLOCKLISTED"
+	ReasonBlocklistEntryExists   = "BLOCKLIST_ENTRY_EXISTS"
+	ReasonBlocklistListsDisabled = "BLOCKLIST_LISTS_DISABLED"
+
+	// Admin reasons
+	ReasonLastSuperAdmin   = "LAST_SUPER_ADMIN"
+	ReasonAdminSelfDisable = "ADMIN_SELF_DISABLE"
+
+	// API key reasons
+	ReasonAPIKeyLimit   = "API_KEY_LIMIT"
+	ReasonAPIKeyRevoked = "API_KEY_REVOKED"
+
+	// User migration reasons
+	ReasonUserMigrationActive   = "USER_MIGRATION_ACTIVE"
+	ReasonUserMigrationFinished = "USER_MIGRATION_FINISHED"
+	ReasonUserMigrationState    = "USER_MIGRATION_STATE"
+
+	// Automation reasons
+	ReasonAutomationRuleNameTaken = "AUTOMATION_RULE_NAME_TAKEN"
+
+	// Service reasons
+	ReasonStandbyInstance        = "STANDBY_INSTANCE"
+	ReasonRateLimited            = "RATE_LIMITED"
+	ReasonManagementTokenMissing = "MANAGEMENT_TOKEN_MISSING"
+	ReasonManagementTokenInvalid = "MANAGEMENT_TOKEN_INVALID"
+
+	// Safety policy reasons
+	ReasonOperationDisabled    = "OPERATION_DISABLED"
+	ReasonConfirmationRequired = "CONFIRMATION_REQUIRED"
+
+	// Global config reasons
+	ReasonGlobalConfigChanged = "GLOBAL_CONFIG_CHANGED"
+)
+
+// Resource types used in NotFound and AlreadyExists errors
+const (
+	ResourceNode        = "node"
+	ResourceUser        = "user"
+	ResourceNodeToken   = "node_token"
+	ResourceNodeGroup   = "node_group"
+	ResourceNodeCost    = "node_cost"
+	ResourcePlan        = "plan"
+	ResourcePlanFeature = "plan_feature"
+	ResourceOrder       = "order"
+	ResourceTenant      = "tenant"
+	ResourceCoupon      = "coupon"
+	ResourceGeoData     = "geo_data"
+
+	ResourceNodeConfigVersion = "node_config_version"
+	ResourceAnnouncement      = "announcement"
+	ResourceSavedFilter       = "saved_filter"
+	ResourceBlocklistEntry    = "blocklist_entry"
+	ResourceAdmin             = "admin"
+	ResourceAPIKey            = "api_key"
+	ResourceUserMigration     = "user_migration"
+	ResourceAutomationRule    = "automation_rule"
+	ResourceNodeEnrollment    = "node_enrollment"
+	ResourceNodeCommand       = "node_command"
+	ResourceConnection        = "connection"
+)
+
+// New returns a status error with an ErrorInfo detail
+func New(code codes.Code, reason, message string, metadata map[string]string) error {
+	return withDetails(status.New(code, message), &errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   Domain,
+		Metadata: metadata,
+	})
+}
+
+// MissingField returns an InvalidArgument error for a required field that is empty
+func MissingField(field string) error {
+	return InvalidField(field, field+" is required")
+}
+
+// InvalidField returns an InvalidArgument error for a single malformed field
+func InvalidField(field, description string) error {
+	return withDetails(status.New(codes.InvalidArgument, description),
+		&errdetails.ErrorInfo{
+			Reason:   ReasonInvalidArgument,
+			Domain:   Domain,
+			Metadata: map[string]string{"field": field},
+		},
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: field, Description: description},
+			},
+		},
+	)
+}
+
+// FieldViolation names a malformed field and why it was rejected
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// InvalidFields returns an InvalidArgument error listing several malformed
+// fields. The message is the description of the first one.
+func InvalidFields(violations []FieldViolation) error {
+	if len(violations) == 1 {
+		return InvalidField(violations[0].Field, violations[0].Description)
+	}
+
+	fields := make([]string, len(violations))
+	badRequest := &errdetails.BadRequest{}
+	for i, v := range violations {
+		fields[i] = v.Field
+		badRequest.FieldViolations = append(badRequest.FieldViolations,
+			&errdetails.BadRequest_FieldViolation{Field: v.Field, Description: v.Description})
+	}
+	return withDetails(status.New(codes.InvalidArgument, violations[0].Description),
+		&errdetails.ErrorInfo{
+			Reason:   ReasonInvalidArgument,
+			Domain:   Domain,
+			Metadata: map[string]string{"field": strings.Join(fields, ",")},
+		},
+		badRequest,
+	)
+}
+
+// NotFound returns a NotFound error for the resource with the given ID
+func NotFound(resource, id string) error {
+	return withDetails(status.New(codes.NotFound, resource+" not found"),
+		&errdetails.ErrorInfo{
+			Reason:   ReasonNotFound,
+			Domain:   Domain,
+			Metadata: map[string]string{"resource": resource, "id": id},
+		},
+		&errdetails.ResourceInfo{
+			ResourceType: resource,
+			ResourceName: id,
+			Description:  resource + " not found",
+		},
+	)
+}
+
+// AlreadyExists returns an AlreadyExists error for a conflicting resource
+func AlreadyExists(resource, reason, message string, metadata map[string]string) error {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	metadata["resource"] = resource
+	return New(codes.AlreadyExists, reason, message, metadata)
+}
+
+// FailedPrecondition returns a FailedPrecondition error. The reason doubles as
+// the PreconditionFailure violation type.
+func FailedPrecondition(reason, subject, message string) error {
+	return withDetails(status.New(codes.FailedPrecondition, message),
+		&errdetails.ErrorInfo{
+			Reason: reason,
+			Domain: Domain,
+		},
+		&errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{
+				{Type: reason, Subject: subject, Description: message},
+			},
+		},
+	)
+}
+
+// Internal returns an Internal error without details
+func Internal(message string) error {
+	return status.Error(codes.Internal, message)
+}
+
+// Reason returns the ErrorInfo reason of an error, or an empty string
+func Reason(err error) string {
+	if info := errorInfo(err); info != nil {
+		return info.Reason
+	}
+	return ""
+}
+
+// Metadata returns the ErrorInfo metadata of an error, or nil
+func Metadata(err error) map[string]string {
+	if info := errorInfo(err); info != nil {
+		return info.Metadata
+	}
+	return nil
+}
+
+// FieldViolations returns the BadRequest field violations of an error as field -> description
+func FieldViolations(err error) map[string]string {
+	st, ok := fromError(err)
+	if !ok {
+		return nil
+	}
+	violations := make(map[string]string)
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				violations[v.Field] = v.Description
+			}
+		}
+	}
+	return violations
+}
+
+// HTTPStatus maps the gRPC code of an error to the matching HTTP status code
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	st, ok := fromError(err)
+	if !ok {
+		return http.StatusInternalServerError
+	}
+
+	switch st.Code() {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Canceled:
+		return 499 // Client closed request
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// withDetails attaches details to a status, falling back to the bare status
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// errorInfo returns the ErrorInfo detail of an error
+func errorInfo(err error) *errdetails.ErrorInfo {
+	st, ok := fromError(err)
+	if !ok {
+		return nil
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+// fromError extracts a status from an error, including wrapped ones
+func fromError(err error) (*status.Status, bool) {
+	if err == nil {
+		return nil, false
+	}
+	if st, ok := status.FromError(err); ok {
+		return st, true
+	}
+	var se interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &se) {
+		return se.GRPCStatus(), true
+	}
+	return nil, false
+}
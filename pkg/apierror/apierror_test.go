@@ -0,0 +1,66 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestErrorDetails(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		code       codes.Code
+		reason     string
+		httpStatus int
+	}{
+		{"missing field", MissingField("node_id"), codes.InvalidArgument, ReasonInvalidArgument, http.StatusBadRequest},
+		{"not found", NotFound(ResourceNode, "42"), codes.NotFound, ReasonNotFound, http.StatusNotFound},
+		{"already exists", AlreadyExists(ResourceUser, ReasonEmailTaken, "email already exists", nil), codes.AlreadyExists, ReasonEmailTaken, http.StatusConflict},
+		{"failed precondition", FailedPrecondition(ReasonNodeDeleted, "node/1", "node was deleted"), codes.FailedPrecondition, ReasonNodeDeleted, http.StatusPreconditionFailed},
+		{"internal", Internal("failed"), codes.Internal, "", http.StatusInternalServerError},
+		{"wrapped", fmt.Errorf("register: %w", NotFound(ResourceNode, "1")), codes.NotFound, ReasonNotFound, http.StatusNotFound},
+		{"plain error", errors.New("boom"), codes.Unknown, "", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.code != codes.Unknown {
+				var se interface{ GRPCStatus() *status.Status }
+				if !errors.As(tt.err, &se) || se.GRPCStatus().Code() != tt.code {
+					t.Errorf("code = %v, want %v", status.Code(tt.err), tt.code)
+				}
+			}
+			if got := Reason(tt.err); got != tt.reason {
+				t.Errorf("Reason() = %q, want %q", got, tt.reason)
+			}
+			if got := HTTPStatus(tt.err); got != tt.httpStatus {
+				t.Errorf("HTTPStatus() = %d, want %d", got, tt.httpStatus)
+			}
+		})
+	}
+}
+
+func TestFieldViolations(t *testing.T) {
+	violations := FieldViolations(MissingField("user_id"))
+	if violations["user_id"] != "user_id is required" {
+		t.Errorf("FieldViolations() = %v", violations)
+	}
+
+	violations = FieldViolations(InvalidFields([]FieldViolation{
+		{Field: "username", Description: "username is required"},
+		{Field: "port", Description: "port must be between 1 and 65535"},
+	}))
+	if len(violations) != 2 || violations["port"] != "port must be between 1 and 65535" {
+		t.Errorf("FieldViolations() of InvalidFields = %v", violations)
+	}
+
+	metadata := Metadata(NotFound(ResourceNode, "7"))
+	if metadata["resource"] != ResourceNode || metadata["id"] != "7" {
+		t.Errorf("Metadata() = %v", metadata)
+	}
+}
@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+)
+
+var (
+	// ErrTooManyRequests is returned when too many reset or verification mails were requested
+	ErrTooManyRequests = errors.New("too many requests, try again later")
+	// ErrPasswordTooShort is returned when a new password is shorter than the configured minimum
+	ErrPasswordTooShort = errors.New("password is too short")
+)
+
+// AccountUserRepository interface for user operations needed by password
+// reset and email verification
+type AccountUserRepository interface {
+	GetByID(id uint) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	ReplacePassword(userID uint, oldHash, newHash string) (bool, error)
+	MarkEmailVerified(userID uint, email string) (bool, error)
+}
+
+// AccountMailer queues mails
+type AccountMailer interface {
+	Send(message *mail.Message) error
+}
+
+// Accounts runs the self-service password reset and email verification
+// flows. Requests for unknown addresses succeed without sending anything so
+// that they do not reveal which addresses have an account.
+type Accounts struct {
+	config  configv1.AuthConfig
+	users   AccountUserRepository
+	tokens  *AccountTokens
+	mailer  AccountMailer
+	limiter *issueLimiter
+	logger  *zap.Logger
+}
+
+// NewAccounts creates the account flows, mailing links signed by tokens
+func NewAccounts(config configv1.AuthConfig, users AccountUserRepository, tokens *AccountTokens, mailer AccountMailer, logger *zap.Logger) *Accounts {
+	return &Accounts{
+		config:  config,
+		users:   users,
+		tokens:  tokens,
+		mailer:  mailer,
+		limiter: newIssueLimiter(config.TokenIssueWindow),
+		logger:  logger,
+	}
+}
+
+// RequestPasswordReset mails a password reset link to the account of email
+func (a *Accounts) RequestPasswordReset(email, clientIP string) error {
+	user, err := a.requestToken(PurposePasswordReset, email, clientIP)
+	if err != nil || user == nil {
+		return err
+	}
+
+	if err := a.mailer.Send(a.tokens.PasswordResetMessage(user, time.Now())); err != nil {
+		a.logger.Error("Failed to queue password reset mail", zap.Uint("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	a.logger.Info("Password reset requested", zap.Uint("user_id", user.ID), zap.String("client_ip", clientIP))
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token. The token stops
+// working once the password changed.
+func (a *Accounts) ResetPassword(token, password string) error {
+	if len(password) < a.config.PasswordMinLength {
+		return ErrPasswordTooShort
+	}
+	user, err := a.tokenUser(PurposePasswordReset, token)
+	if err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	replaced, err := a.users.ReplacePassword(user.ID, user.Password, hash)
+	if err != nil {
+		return err
+	}
+	if !replaced {
+		// The password changed since the token was verified, e.g. by a concurrent reset
+		return ErrInvalidAccountToken
+	}
+
+	a.logger.Info("Password reset", zap.Uint("user_id", user.ID))
+	return nil
+}
+
+// RequestVerification mails a new verification link to the account of
+// email, unless it is verified already
+func (a *Accounts) RequestVerification(email, clientIP string) error {
+	user, err := a.requestToken(PurposeEmailVerification, email, clientIP)
+	if err != nil || user == nil || user.EmailVerified {
+		return err
+	}
+
+	if err := a.mailer.Send(a.tokens.VerificationMessage(user, time.Now())); err != nil {
+		a.logger.Error("Failed to queue verification mail", zap.Uint("user_id", user.ID), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// VerifyEmail marks the email address of a verification token verified
+func (a *Accounts) VerifyEmail(token string) error {
+	user, err := a.tokenUser(PurposeEmailVerification, token)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	verified, err := a.users.MarkEmailVerified(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return ErrInvalidAccountToken
+	}
+
+	a.logger.Info("Email verified", zap.Uint("user_id", user.ID))
+	return nil
+}
+
+// requestToken applies the issuance limits and returns the account of email,
+// nil for an unknown address
+func (a *Accounts) requestToken(purpose, email, clientIP string) (*models.User, error) {
+	email = strings.TrimSpace(email)
+	now := time.Now()
+	if !a.limiter.allow("ip:"+clientIP, a.config.TokenIssueIPLimit, now) ||
+		!a.limiter.allow(purpose+":"+strings.ToLower(email), a.config.TokenIssueLimit, now) {
+		a.logger.Info("Token request rate limited", zap.String("purpose", purpose), zap.String("client_ip", clientIP))
+		return nil, ErrTooManyRequests
+	}
+
+	user, err := a.users.GetByEmail(email)
+	if err != nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// tokenUser verifies a token and returns its user
+func (a *Accounts) tokenUser(purpose, token string) (*models.User, error) {
+	userID, err := a.tokens.UserID(token)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.users.GetByID(userID)
+	if err != nil {
+		return nil, ErrInvalidAccountToken
+	}
+	if err := a.tokens.Verify(purpose, token, user, time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// issueLimiter counts requests per key in fixed windows. It is kept in
+// memory, so every web server instance enforces its own limits.
+type issueLimiter struct {
+	mu        sync.Mutex
+	window    time.Duration
+	counts    map[string]*issueWindow
+	lastSweep time.Time
+}
+
+// issueWindow is the request count of a key since start
+type issueWindow struct {
+	start time.Time
+	count int
+}
+
+func newIssueLimiter(window time.Duration) *issueLimiter {
+	return &issueLimiter{window: window, counts: make(map[string]*issueWindow)}
+}
+
+// allow counts a request for key and reports whether it is within limit
+func (l *issueLimiter) allow(key string, limit int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop the windows that ended, at most once per window
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, w := range l.counts {
+			if now.Sub(w.start) >= l.window {
+				delete(l.counts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.counts[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &issueWindow{start: now}
+		l.counts[key] = w
+	}
+	if w.count >= limit {
+		return false
+	}
+	w.count++
+	return true
+}
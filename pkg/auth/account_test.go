@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+)
+
+func testAccountTokens() *AccountTokens {
+	config := configv1.DefaultMailConfig()
+	config.LinkSecret = "0123456789abcdef0123456789abcdef"
+	config.BaseURL = "https://panel.example.com/"
+	return NewAccountTokens(config)
+}
+
+func TestAccountTokenVerify(t *testing.T) {
+	tokens := testAccountTokens()
+	now := time.Unix(1700000000, 0)
+	user := &models.User{ID: 7, Email: "alice@example.com", Password: "hash-1"}
+	token := tokens.Issue(PurposePasswordReset, user, now)
+
+	if id, err := tokens.UserID(token); err != nil || id != 7 {
+		t.Fatalf("UserID() = %d, %v, want 7", id, err)
+	}
+
+	tests := []struct {
+		name    string
+		purpose string
+		token   string
+		user    models.User
+		at      time.Time
+		wantErr bool
+	}{
+		{name: "valid", purpose: PurposePasswordReset, token: token, user: *user, at: now},
+		{name: "expired", purpose: PurposePasswordReset, token: token, user: *user, at: now.Add(time.Hour + time.Second), wantErr: true},
+		{name: "other purpose", purpose: PurposeEmailVerification, token: token, user: *user, at: now, wantErr: true},
+		{name: "password changed", purpose: PurposePasswordReset, token: token,
+			user: models.User{ID: 7, Email: user.Email, Password: "hash-2"}, at: now, wantErr: true},
+		{name: "other user", purpose: PurposePasswordReset, token: token,
+			user: models.User{ID: 8, Email: user.Email, Password: user.Password}, at: now, wantErr: true},
+		{name: "tampered", purpose: PurposePasswordReset, token: "Ny4xOTk5OTk5OTk5." + strings.Split(token, ".")[1],
+			user: *user, at: now, wantErr: true},
+		{name: "malformed", purpose: PurposePasswordReset, token: "garbage", user: *user, at: now, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tokens.Verify(tt.purpose, tt.token, &tt.user, tt.at)
+			if tt.wantErr != (err != nil) {
+				t.Errorf("Verify() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerificationMessage(t *testing.T) {
+	tokens := testAccountTokens()
+	user := &models.User{ID: 3, Username: "bob", Email: "bob@example.com"}
+	now := time.Now()
+
+	message := tokens.VerificationMessage(user, now)
+	if message.Template != mail.TemplateEmailVerification || message.To != user.Email {
+		t.Fatalf("message = %+v", message)
+	}
+	if message.Data["expires_in"] != "2 days" {
+		t.Errorf("expires_in = %q, want 2 days", message.Data["expires_in"])
+	}
+
+	link, err := url.Parse(message.Data["verify_url"])
+	if err != nil || link.Host != "panel.example.com" || link.Path != "/verify-email" {
+		t.Fatalf("verify_url = %q", message.Data["verify_url"])
+	}
+	if err := tokens.Verify(PurposeEmailVerification, link.Query().Get("token"), user, now); err != nil {
+		t.Errorf("link token does not verify: %v", err)
+	}
+}
+
+// fakeAccountUsers is an in-memory AccountUserRepository
+type fakeAccountUsers struct {
+	users map[uint]*models.User
+}
+
+func (r *fakeAccountUsers) GetByID(id uint) (*models.User, error) {
+	if user, ok := r.users[id]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *fakeAccountUsers) GetByEmail(email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
+
+func (r *fakeAccountUsers) ReplacePassword(userID uint, oldHash, newHash string) (bool, error) {
+	user := r.users[userID]
+	if user == nil || user.Password != oldHash {
+		return false, nil
+	}
+	user.Password = newHash
+	return true, nil
+}
+
+func (r *fakeAccountUsers) MarkEmailVerified(userID uint, email string) (bool, error) {
+	user := r.users[userID]
+	if user == nil || user.Email != email {
+		return false, nil
+	}
+	user.EmailVerified = true
+	return true, nil
+}
+
+// fakeAccountMailer records the queued mails
+type fakeAccountMailer struct {
+	sent []*mail.Message
+}
+
+func (m *fakeAccountMailer) Send(message *mail.Message) error {
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+func testAccounts() (*Accounts, *fakeAccountUsers, *fakeAccountMailer) {
+	users := &fakeAccountUsers{users: map[uint]*models.User{
+		1: {ID: 1, Username: "alice", Email: "alice@example.com", Password: "old-hash"},
+	}}
+	mailer := &fakeAccountMailer{}
+	config := configv1.DefaultWebConfig().Auth
+	return NewAccounts(config, users, testAccountTokens(), mailer, zap.NewNop()), users, mailer
+}
+
+// linkToken extracts the token of the link in a queued mail
+func linkToken(t *testing.T, message *mail.Message, field string) string {
+	t.Helper()
+	link, err := url.Parse(message.Data[field])
+	if err != nil {
+		t.Fatalf("invalid link %q", message.Data[field])
+	}
+	return link.Query().Get("token")
+}
+
+func TestPasswordReset(t *testing.T) {
+	accounts, users, mailer := testAccounts()
+
+	if err := accounts.RequestPasswordReset("nobody@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("unknown address: %v", err)
+	}
+	if len(mailer.sent) != 0 {
+		t.Fatalf("mail sent for unknown address")
+	}
+
+	if err := accounts.RequestPasswordReset("alice@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(mailer.sent))
+	}
+	token := linkToken(t, mailer.sent[0], "reset_url")
+
+	if err := accounts.ResetPassword(token, "short"); !errors.Is(err, ErrPasswordTooShort) {
+		t.Errorf("short password: error = %v, want ErrPasswordTooShort", err)
+	}
+	if err := accounts.ResetPassword(token, "new-password"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(users.users[1].Password), []byte("new-password")) != nil {
+		t.Errorf("password not replaced")
+	}
+	if err := accounts.ResetPassword(token, "another-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Errorf("reused token: error = %v, want ErrInvalidAccountToken", err)
+	}
+}
+
+func TestEmailVerification(t *testing.T) {
+	accounts, users, mailer := testAccounts()
+
+	if err := accounts.RequestVerification("alice@example.com", "10.0.0.1"); err != nil {
+		t.Fatalf("RequestVerification() error = %v", err)
+	}
+	token := linkToken(t, mailer.sent[0], "verify_url")
+
+	if err := accounts.ResetPassword(token, "new-password"); !errors.Is(err, ErrInvalidAccountToken) {
+		t.Errorf("verification token reset the password: error = %v", err)
+	}
+	if err := accounts.VerifyEmail(token); err != nil {
+		t.Fatalf("VerifyEmail() error = %v", err)
+	}
+	if !users.users[1].EmailVerified {
+		t.Errorf("email not verified")
+	}
+
+	// Verified accounts get no further verification mails
+	if err := accounts.RequestVerification("alice@example.com", "10.0.0.1"); err != nil || len(mailer.sent) != 1 {
+		t.Errorf("RequestVerification() for verified account: error = %v, sent = %d", err, len(mailer.sent))
+	}
+}
+
+func TestTokenIssueLimits(t *testing.T) {
+	accounts, _, mailer := testAccounts()
+
+	// tokenIssueLimit is 3 per account
+	for i := 0; i < 3; i++ {
+		if err := accounts.RequestPasswordReset("alice@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := accounts.RequestPasswordReset("ALICE@example.com", "10.0.0.2"); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("over account limit: error = %v, want ErrTooManyRequests", err)
+	}
+	if len(mailer.sent) != 3 {
+		t.Errorf("sent %d mails, want 3", len(mailer.sent))
+	}
+
+	// tokenIssueIpLimit is 10 per client IP, unknown addresses count as well
+	for i := 0; i < 9; i++ {
+		if err := accounts.RequestPasswordReset("nobody@example.com"+strings.Repeat("x", i), "10.0.0.3"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := accounts.RequestVerification("someone@example.com", "10.0.0.3"); err != nil {
+		t.Fatalf("tenth request: %v", err)
+	}
+	if err := accounts.RequestVerification("other@example.com", "10.0.0.3"); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("over IP limit: error = %v, want ErrTooManyRequests", err)
+	}
+}
+
+func TestIssueLimiterWindow(t *testing.T) {
+	limiter := newIssueLimiter(time.Hour)
+	now := time.Unix(1700000000, 0)
+
+	if !limiter.allow("k", 1, now) {
+		t.Fatal("first request denied")
+	}
+	if limiter.allow("k", 1, now.Add(time.Minute)) {
+		t.Error("second request in window allowed")
+	}
+	if !limiter.allow("k", 1, now.Add(time.Hour)) {
+		t.Error("request in next window denied")
+	}
+}
+
+func TestFormatTTL(t *testing.T) {
+	tests := map[time.Duration]string{
+		time.Hour:        "1 hour",
+		48 * time.Hour:   "2 days",
+		90 * time.Minute: "90 minutes",
+		36 * time.Hour:   "36 hours",
+	}
+	for ttl, want := range tests {
+		if got := formatTTL(ttl); got != want {
+			t.Errorf("formatTTL(%v) = %q, want %q", ttl, got, want)
+		}
+	}
+}
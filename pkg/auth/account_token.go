@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+)
+
+// Account token purposes, part of the signature so that a token of one
+// purpose is never accepted for the other
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+)
+
+// ErrInvalidAccountToken is returned for a malformed, forged, expired or already used token
+var ErrInvalidAccountToken = errors.New("invalid or expired token")
+
+var accountTokenEncoding = base64.RawURLEncoding
+
+// AccountTokens signs and verifies the tokens of password reset and email
+// verification links. Tokens are not stored: a token carries the user ID and
+// its expiry, and the signature also covers the account state the token acts
+// on, the password hash or the email address. Changing that state, e.g. by
+// using a reset token, invalidates every token issued before.
+type AccountTokens struct {
+	secret          []byte
+	baseURL         string
+	resetTTL        time.Duration
+	verificationTTL time.Duration
+}
+
+// NewAccountTokens creates the account token signer of the mail links
+func NewAccountTokens(config configv1.MailConfig) *AccountTokens {
+	return &AccountTokens{
+		secret:          []byte(config.LinkSecret),
+		baseURL:         strings.TrimSuffix(config.BaseURL, "/"),
+		resetTTL:        config.PasswordResetTTL,
+		verificationTTL: config.VerificationTTL,
+	}
+}
+
+// Issue returns a token for purpose acting on the current state of user
+func (t *AccountTokens) Issue(purpose string, user *models.User, now time.Time) string {
+	ttl := t.resetTTL
+	if purpose == PurposeEmailVerification {
+		ttl = t.verificationTTL
+	}
+	payload := strconv.FormatUint(uint64(user.ID), 10) + "." + strconv.FormatInt(now.Add(ttl).Unix(), 10)
+	return accountTokenEncoding.EncodeToString([]byte(payload)) + "." +
+		accountTokenEncoding.EncodeToString(t.sign(purpose, payload, user))
+}
+
+// UserID returns the user a token was issued for, without verifying it
+func (t *AccountTokens) UserID(token string) (uint, error) {
+	userID, _, _, err := parseAccountToken(token)
+	return userID, err
+}
+
+// Verify checks that token was issued for purpose and the current state of
+// user and has not expired
+func (t *AccountTokens) Verify(purpose, token string, user *models.User, now time.Time) error {
+	userID, expiresAt, signature, err := parseAccountToken(token)
+	if err != nil {
+		return err
+	}
+	if userID != user.ID || now.Unix() > expiresAt {
+		return ErrInvalidAccountToken
+	}
+
+	payload := strconv.FormatUint(uint64(userID), 10) + "." + strconv.FormatInt(expiresAt, 10)
+	if !hmac.Equal(signature, t.sign(purpose, payload, user)) {
+		return ErrInvalidAccountToken
+	}
+	return nil
+}
+
+// PasswordResetMessage returns the password reset mail of user with a new token
+func (t *AccountTokens) PasswordResetMessage(user *models.User, now time.Time) *mail.Message {
+	return t.message(mail.TemplatePasswordReset, "/reset-password", "reset_url", PurposePasswordReset, t.resetTTL, user, now)
+}
+
+// VerificationMessage returns the email verification mail of user with a new token
+func (t *AccountTokens) VerificationMessage(user *models.User, now time.Time) *mail.Message {
+	return t.message(mail.TemplateEmailVerification, "/verify-email", "verify_url", PurposeEmailVerification, t.verificationTTL, user, now)
+}
+
+func (t *AccountTokens) message(template, path, urlField, purpose string, ttl time.Duration, user *models.User, now time.Time) *mail.Message {
+	link := t.baseURL + path + "?token=" + url.QueryEscape(t.Issue(purpose, user, now))
+	return &mail.Message{
+		Template: template,
+		To:       user.Email,
+		Username: user.Username,
+		TenantID: user.TenantID,
+		Data: map[string]string{
+			urlField:     link,
+			"expires_in": formatTTL(ttl),
+		},
+	}
+}
+
+// sign returns the signature of a token payload for the account state the purpose acts on
+func (t *AccountTokens) sign(purpose, payload string, user *models.User) []byte {
+	state := user.Password
+	if purpose == PurposeEmailVerification {
+		state = user.Email
+	}
+
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(payload))
+	mac.Write([]byte{0})
+	mac.Write([]byte(state))
+	return mac.Sum(nil)
+}
+
+// parseAccountToken splits a token into its user ID, expiry and signature
+func parseAccountToken(token string) (uint, int64, []byte, error) {
+	encodedPayload, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, 0, nil, ErrInvalidAccountToken
+	}
+	payload, err := accountTokenEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidAccountToken
+	}
+	signature, err := accountTokenEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidAccountToken
+	}
+
+	id, expiry, ok := strings.Cut(string(payload), ".")
+	if !ok {
+		return 0, 0, nil, ErrInvalidAccountToken
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidAccountToken
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return 0, 0, nil, ErrInvalidAccountToken
+	}
+	return uint(userID), expiresAt, signature, nil
+}
+
+// formatTTL formats a link lifetime for mails, e.g. "1 hour" or "2 days"
+func formatTTL(ttl time.Duration) string {
+	unit, count := "minute", int(ttl/time.Minute)
+	switch {
+	case ttl >= 24*time.Hour && ttl%(24*time.Hour) == 0:
+		unit, count = "day", int(ttl/(24*time.Hour))
+	case ttl >= time.Hour && ttl%time.Hour == 0:
+		unit, count = "hour", int(ttl/time.Hour)
+	}
+	if count != 1 {
+		unit += "s"
+	}
+	return strconv.Itoa(count) + " " + unit
+}
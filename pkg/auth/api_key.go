@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// apiKeyPrefix tells API keys apart from the JWTs sent as bearer token
+const apiKeyPrefix = "sbk_"
+
+// GenerateAPIKey generates a new random API key
+func GenerateAPIKey() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	return apiKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashAPIKey returns the hash under which an API key is stored
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer token is an API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// APIKeyHint returns the start of an API key, shown to tell keys apart
+func APIKeyHint(key string) string {
+	if len(key) <= len(apiKeyPrefix)+8 {
+		return key
+	}
+	return key[:len(apiKeyPrefix)+8]
+}
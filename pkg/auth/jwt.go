@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	configv1 "sing-box-web/pkg/config/v1"
@@ -17,26 +18,59 @@ type Claims struct {
 	Username string `json:"username"`
 	Role     string `json:"role"`
 	NodeID   string `json:"node_id,omitempty"`
+	// Type tells access, refresh and setup tokens apart, which are signed
+	// with the same secret
+	Type string `json:"typ,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// Token types
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+	// tokenTypeTwoFactorSetup is the type of the tokens allowing admins
+	// refused at login for lack of 2FA to enroll it
+	tokenTypeTwoFactorSetup = "2fa_setup"
+)
+
+// TwoFactorSetupTokenLifetime is how long an admin has to enroll 2FA after
+// a login refused for lack of it
+const TwoFactorSetupTokenLifetime = 10 * time.Minute
+
 // UserRepository interface for user operations
 type UserRepository interface {
 	GetByID(id uint) (*User, error)
 }
 
+// RevocationStore interface for persisting revoked token IDs
+type RevocationStore interface {
+	RevokeToken(jti string, userID uint, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+}
+
+// ErrTokenRevoked is returned when a revoked token is presented
+var ErrTokenRevoked = errors.New("token revoked")
+
+// ErrTokenExpired is returned when an expired token is presented, clients
+// get a new access token with their refresh token
+var ErrTokenExpired = errors.New("token expired")
+
 // User represents basic user info for JWT
 type User struct {
 	ID       uint   `json:"id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// Active is false for disabled, expired, suspended or locked accounts,
+	// which get no new access tokens
+	Active bool `json:"active"`
 }
 
 // JWTManager manages JWT token operations
 type JWTManager struct {
-	config   configv1.AuthConfig
-	logger   *zap.Logger
-	userRepo UserRepository
+	config          configv1.AuthConfig
+	logger          *zap.Logger
+	userRepo        UserRepository
+	revocationStore RevocationStore
 }
 
 // NewJWTManager creates a new JWT manager
@@ -53,16 +87,34 @@ func (j *JWTManager) SetUserRepository(userRepo UserRepository) {
 	j.userRepo = userRepo
 }
 
+// SetRevocationStore sets the storage backing the token revocation list
+func (j *JWTManager) SetRevocationStore(store RevocationStore) {
+	j.revocationStore = store
+}
+
 // GenerateToken generates a JWT token for a user
 func (j *JWTManager) GenerateToken(userID, username, role string) (string, error) {
+	return j.generateToken(userID, username, role, tokenTypeAccess, j.config.JWTExpiration)
+}
+
+// GenerateTwoFactorSetupToken generates a token that only allows a user to
+// enroll 2FA, for TwoFactorSetupTokenLifetime
+func (j *JWTManager) GenerateTwoFactorSetupToken(userID, username, role string) (string, error) {
+	return j.generateToken(userID, username, role, tokenTypeTwoFactorSetup, TwoFactorSetupTokenLifetime)
+}
+
+// generateToken generates a JWT token of a type for a user
+func (j *JWTManager) generateToken(userID, username, role, typ string, lifetime time.Duration) (string, error) {
 	now := time.Now()
-	expiresAt := now.Add(j.config.JWTExpiration)
+	expiresAt := now.Add(lifetime)
 
 	claims := Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
+		Type:     typ,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Issuer:    "sing-box-web",
 			Subject:   userID,
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -78,7 +130,7 @@ func (j *JWTManager) GenerateToken(userID, username, role string) (string, error
 		return "", err
 	}
 
-	j.logger.Debug("Generated JWT token", zap.String("user_id", userID), zap.String("username", username))
+	j.logger.Debug("Generated JWT token", zap.String("user_id", userID), zap.String("username", username), zap.String("type", typ))
 	return tokenString, nil
 }
 
@@ -87,12 +139,16 @@ func (j *JWTManager) GenerateRefreshToken(userID string) (string, error) {
 	now := time.Now()
 	expiresAt := now.Add(j.config.RefreshExpiration)
 
-	claims := jwt.RegisteredClaims{
-		Issuer:    "sing-box-web",
-		Subject:   userID,
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(expiresAt),
-		NotBefore: jwt.NewNumericDate(now),
+	claims := Claims{
+		Type: tokenTypeRefresh,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    "sing-box-web",
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			NotBefore: jwt.NewNumericDate(now),
+		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -107,15 +163,43 @@ func (j *JWTManager) GenerateRefreshToken(userID string) (string, error) {
 
 // ValidateToken validates a JWT token and returns the claims
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
+	return j.validateToken(tokenString, tokenTypeAccess, 0)
+}
+
+// ValidateTwoFactorSetupToken validates a token of GenerateTwoFactorSetupToken
+// and returns the claims. Access tokens are rejected.
+func (j *JWTManager) ValidateTwoFactorSetupToken(tokenString string) (*Claims, error) {
+	return j.validateToken(tokenString, tokenTypeTwoFactorSetup, 0)
+}
+
+// ValidateStaleToken validates a JWT token like ValidateToken, but also
+// accepts it for up to grace after it expired. stale reports whether it did.
+// Revoked tokens are rejected regardless.
+func (j *JWTManager) ValidateStaleToken(tokenString string, grace time.Duration) (claims *Claims, stale bool, err error) {
+	claims, err = j.validateToken(tokenString, tokenTypeAccess, grace)
+	if err != nil {
+		return nil, false, err
+	}
+	stale = claims.ExpiresAt != nil && time.Now().After(claims.ExpiresAt.Time)
+	return claims, stale, nil
+}
+
+// validateToken validates a JWT token of a type, accepting it until leeway
+// after expiry
+func (j *JWTManager) validateToken(tokenString, typ string, leeway time.Duration) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		// Validate signing method
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
 		}
 		return []byte(j.config.JWTSecret), nil
-	})
+	}, jwt.WithLeeway(leeway))
 
 	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			j.logger.Debug("Expired JWT token presented")
+			return nil, ErrTokenExpired
+		}
 		j.logger.Warn("Failed to parse JWT token", zap.Error(err))
 		return nil, err
 	}
@@ -125,19 +209,29 @@ func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
 		j.logger.Warn("Invalid JWT token claims")
 		return nil, errors.New("invalid token claims")
 	}
+	if claims.Type != typ {
+		j.logger.Warn("JWT token of another type presented", zap.String("user_id", claims.UserID), zap.String("type", claims.Type))
+		return nil, errors.New("invalid token type")
+	}
 
 	// Check if token is expired
-	if claims.ExpiresAt != nil && time.Now().After(claims.ExpiresAt.Time) {
-		j.logger.Warn("JWT token expired", zap.String("user_id", claims.UserID))
-		return nil, errors.New("token expired")
+	if claims.ExpiresAt != nil && time.Now().After(claims.ExpiresAt.Time.Add(leeway)) {
+		j.logger.Debug("JWT token expired", zap.String("user_id", claims.UserID))
+		return nil, ErrTokenExpired
+	}
+
+	if j.isRevoked(claims.ID) {
+		j.logger.Warn("Revoked JWT token presented", zap.String("user_id", claims.UserID), zap.String("jti", claims.ID))
+		return nil, ErrTokenRevoked
 	}
 
 	return claims, nil
 }
 
-// RefreshToken validates a refresh token and generates a new access token
-func (j *JWTManager) RefreshToken(refreshToken string) (string, error) {
-	token, err := jwt.ParseWithClaims(refreshToken, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+// parseRefreshToken validates the signature, type and expiry of a refresh
+// token and returns its claims
+func (j *JWTManager) parseRefreshToken(refreshToken string) (*jwt.RegisteredClaims, error) {
+	token, err := jwt.ParseWithClaims(refreshToken, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
 		}
@@ -146,19 +240,38 @@ func (j *JWTManager) RefreshToken(refreshToken string) (string, error) {
 
 	if err != nil {
 		j.logger.Warn("Failed to parse refresh token", zap.Error(err))
-		return "", err
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	claims, ok := token.Claims.(*Claims)
 	if !ok || !token.Valid {
 		j.logger.Warn("Invalid refresh token claims")
-		return "", errors.New("invalid refresh token")
+		return nil, errors.New("invalid refresh token")
+	}
+	if claims.Type != tokenTypeRefresh {
+		j.logger.Warn("JWT token of another type presented for refresh", zap.String("user_id", claims.Subject), zap.String("type", claims.Type))
+		return nil, errors.New("invalid token type")
 	}
 
 	// Check if refresh token is expired
 	if claims.ExpiresAt != nil && time.Now().After(claims.ExpiresAt.Time) {
 		j.logger.Warn("Refresh token expired", zap.String("user_id", claims.Subject))
-		return "", errors.New("refresh token expired")
+		return nil, errors.New("refresh token expired")
+	}
+	return &claims.RegisteredClaims, nil
+}
+
+// RefreshToken validates a refresh token and generates a new access token.
+// Users whose account is no longer active get ErrAccountInactive.
+func (j *JWTManager) RefreshToken(refreshToken string) (string, error) {
+	claims, err := j.parseRefreshToken(refreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	if j.isRevoked(claims.ID) {
+		j.logger.Warn("Revoked refresh token presented", zap.String("user_id", claims.Subject), zap.String("jti", claims.ID))
+		return "", ErrTokenRevoked
 	}
 
 	// Get user details from database to generate new token
@@ -175,6 +288,10 @@ func (j *JWTManager) RefreshToken(refreshToken string) (string, error) {
 			j.logger.Warn("User not found for refresh token", zap.String("user_id", userID))
 			return "", errors.New("user not found")
 		}
+		if !user.Active {
+			j.logger.Info("Refresh rejected: inactive account", zap.String("user_id", userID))
+			return "", ErrAccountInactive
+		}
 		return j.GenerateToken(userID, user.Username, user.Role)
 	}
 
@@ -183,22 +300,96 @@ func (j *JWTManager) RefreshToken(refreshToken string) (string, error) {
 	return j.GenerateToken(userID, "user", "user")
 }
 
-// RevokeToken adds a token to the revocation list
+// RevokeToken adds a token to the revocation list until it expires
 func (j *JWTManager) RevokeToken(tokenString string) error {
-	// TODO: Implement token revocation using Redis or database
-	// For now, we'll just log the revocation
 	claims, err := j.ValidateToken(tokenString)
 	if err != nil {
 		return err
 	}
 
-	j.logger.Info("Token revoked", zap.String("user_id", claims.UserID), zap.String("username", claims.Username))
+	if claims.ID == "" {
+		return errors.New("token has no ID and cannot be revoked")
+	}
+	if j.revocationStore == nil {
+		return errors.New("revocation store not configured")
+	}
+
+	userID, err := strconv.ParseUint(claims.UserID, 10, 32)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	expiresAt := time.Now().Add(j.config.JWTExpiration)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	if err := j.revocationStore.RevokeToken(claims.ID, uint(userID), expiresAt); err != nil {
+		j.logger.Error("Failed to revoke token", zap.String("jti", claims.ID), zap.Error(err))
+		return err
+	}
+
+	j.logger.Info("Token revoked", zap.String("user_id", claims.UserID), zap.String("username", claims.Username), zap.String("jti", claims.ID))
+	return nil
+}
+
+// RevokeRefreshToken adds a refresh token of a user to the revocation list
+// until it expires, so that it mints no access tokens after logout
+func (j *JWTManager) RevokeRefreshToken(refreshToken, userID string) error {
+	claims, err := j.parseRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	if claims.Subject != userID {
+		return errors.New("refresh token of another user")
+	}
+
+	if claims.ID == "" {
+		return errors.New("token has no ID and cannot be revoked")
+	}
+	if j.revocationStore == nil {
+		return errors.New("revocation store not configured")
+	}
+
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return errors.New("invalid user ID")
+	}
+
+	expiresAt := time.Now().Add(j.config.RefreshExpiration)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	if err := j.revocationStore.RevokeToken(claims.ID, uint(id), expiresAt); err != nil {
+		j.logger.Error("Failed to revoke refresh token", zap.String("jti", claims.ID), zap.Error(err))
+		return err
+	}
+
+	j.logger.Info("Refresh token revoked", zap.String("user_id", userID), zap.String("jti", claims.ID))
 	return nil
 }
 
 // IsTokenRevoked checks if a token is revoked
 func (j *JWTManager) IsTokenRevoked(tokenString string) bool {
-	// TODO: Check token revocation list
-	// For now, we'll return false (not revoked)
-	return false
-}
\ No newline at end of file
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return false
+	}
+	return j.isRevoked(claims.ID)
+}
+
+// isRevoked checks the revocation list for a JTI.
+// Lookup failures are treated as revoked so that a storage outage fails closed.
+func (j *JWTManager) isRevoked(jti string) bool {
+	if j.revocationStore == nil || jti == "" {
+		return false
+	}
+
+	revoked, err := j.revocationStore.IsTokenRevoked(jti)
+	if err != nil {
+		j.logger.Error("Failed to check token revocation", zap.String("jti", jti), zap.Error(err))
+		return true
+	}
+	return revoked
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+type testUsers map[uint]*User
+
+func (u testUsers) GetByID(id uint) (*User, error) {
+	user, ok := u[id]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return user, nil
+}
+
+type testRevocations map[string]bool
+
+func (r testRevocations) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	r[jti] = true
+	return nil
+}
+
+func (r testRevocations) IsTokenRevoked(jti string) (bool, error) {
+	return r[jti], nil
+}
+
+func testJWTManager(users testUsers) *JWTManager {
+	manager := NewJWTManager(configv1.AuthConfig{
+		JWTSecret:         "0123456789abcdef0123456789abcdef",
+		JWTExpiration:     time.Hour,
+		RefreshExpiration: 24 * time.Hour,
+	}, zap.NewNop())
+	manager.SetUserRepository(users)
+	manager.SetRevocationStore(testRevocations{})
+	return manager
+}
+
+func TestRefreshTokenInactiveUser(t *testing.T) {
+	users := testUsers{7: {ID: 7, Username: "alice", Role: "user", Active: true}}
+	manager := testJWTManager(users)
+	refresh, err := manager.GenerateRefreshToken("7")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	access, err := manager.RefreshToken(refresh)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if claims, err := manager.ValidateToken(access); err != nil || claims.Username != "alice" {
+		t.Fatalf("refreshed token claims = %+v, %v", claims, err)
+	}
+
+	// Disabled, expired or locked accounts get no new access tokens
+	users[7].Active = false
+	if _, err := manager.RefreshToken(refresh); !errors.Is(err, ErrAccountInactive) {
+		t.Fatalf("RefreshToken of an inactive user = %v, want ErrAccountInactive", err)
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	manager := testJWTManager(testUsers{7: {ID: 7, Username: "alice", Role: "user", Active: true}})
+	refresh, err := manager.GenerateRefreshToken("7")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	// Only the user the token was issued to revokes it
+	if err := manager.RevokeRefreshToken(refresh, "8"); err == nil {
+		t.Fatal("RevokeRefreshToken of another user succeeded")
+	}
+	if _, err := manager.RefreshToken(refresh); err != nil {
+		t.Fatalf("RefreshToken after a rejected revocation: %v", err)
+	}
+
+	if err := manager.RevokeRefreshToken(refresh, "7"); err != nil {
+		t.Fatalf("RevokeRefreshToken: %v", err)
+	}
+	if _, err := manager.RefreshToken(refresh); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("RefreshToken after logout = %v, want ErrTokenRevoked", err)
+	}
+}
+
+func TestTwoFactorSetupToken(t *testing.T) {
+	manager := testJWTManager(testUsers{})
+	setup, err := manager.GenerateTwoFactorSetupToken("7", "admin", "admin")
+	if err != nil {
+		t.Fatalf("GenerateTwoFactorSetupToken: %v", err)
+	}
+	if claims, err := manager.ValidateTwoFactorSetupToken(setup); err != nil || claims.UserID != "7" {
+		t.Fatalf("ValidateTwoFactorSetupToken = %+v, %v", claims, err)
+	}
+	// It grants no access, nor do access tokens allow enrolling in its stead
+	if _, err := manager.ValidateToken(setup); err == nil {
+		t.Error("setup token accepted as access token")
+	}
+	access, err := manager.GenerateToken("7", "admin", "admin")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := manager.ValidateTwoFactorSetupToken(access); err == nil {
+		t.Error("access token accepted as setup token")
+	}
+}
+
+func TestTokenTypes(t *testing.T) {
+	manager := testJWTManager(testUsers{7: {ID: 7, Username: "alice", Role: "user", Active: true}})
+	access, err := manager.GenerateToken("7", "alice", "user")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	refresh, err := manager.GenerateRefreshToken("7")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	// An access token mints no access tokens
+	if _, err := manager.RefreshToken(access); err == nil {
+		t.Error("access token accepted for refresh")
+	}
+	if err := manager.RevokeRefreshToken(access, "7"); err == nil {
+		t.Error("access token revoked as refresh token")
+	}
+	// and a refresh token grants no access
+	if _, err := manager.ValidateToken(refresh); err == nil {
+		t.Error("refresh token accepted as access token")
+	}
+	if _, _, err := manager.ValidateStaleToken(refresh, time.Hour); err == nil {
+		t.Error("refresh token accepted as stale access token")
+	}
+
+	if _, err := manager.ValidateToken(access); err != nil {
+		t.Errorf("ValidateToken: %v", err)
+	}
+	if _, err := manager.RefreshToken(refresh); err != nil {
+		t.Errorf("RefreshToken: %v", err)
+	}
+}
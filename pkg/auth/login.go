@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+var (
+	// ErrInvalidCredentials is returned when the username or password is wrong
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrAccountInactive is returned when the account is suspended, expired or locked
+	ErrAccountInactive = errors.New("account is not active")
+	// ErrTwoFactorRequired is returned when the password is correct but a 2FA code is needed
+	ErrTwoFactorRequired = errors.New("two-factor code required")
+	// ErrTwoFactorSetupRequired is returned when an admin logs in without 2FA while it is mandatory
+	ErrTwoFactorSetupRequired = errors.New("two-factor authentication must be enabled for admin accounts")
+	// ErrEmailNotVerified is returned when a user with an unverified email logs in while verification is mandatory
+	ErrEmailNotVerified = errors.New("email address is not verified")
+)
+
+// TwoFactorSetupError refuses the login of an admin without 2FA while it is
+// mandatory. Token allows the admin to enroll 2FA until ExpiresAt, after
+// which the login is repeated with a code. It matches ErrTwoFactorSetupRequired.
+type TwoFactorSetupError struct {
+	Token     string
+	ExpiresAt time.Time
+}
+
+func (e *TwoFactorSetupError) Error() string { return ErrTwoFactorSetupRequired.Error() }
+
+func (e *TwoFactorSetupError) Unwrap() error { return ErrTwoFactorSetupRequired }
+
+// LoginUserRepository interface for user operations needed during login
+type LoginUserRepository interface {
+	GetByUsername(username string) (*models.User, error)
+	UpdateLastLogin(userID uint, ip string) error
+	IncrementLoginAttempts(userID uint) error
+	ReplaceBackupCodes(userID uint, old, backupCodes []string) (bool, error)
+	UseTwoFactorStep(userID uint, step int64) (bool, error)
+	ReactivateInactive(userID uint) error
+}
+
+// TwoFactorStore records the use of two-factor codes
+type TwoFactorStore interface {
+	// ReplaceBackupCodes stores the backup codes left after one was used
+	// unless the stored ones are no longer old, false then
+	ReplaceBackupCodes(userID uint, old, backupCodes []string) (bool, error)
+	// UseTwoFactorStep stores the time step of an accepted TOTP code unless
+	// a code of this step or a later one was accepted, false then
+	UseTwoFactorStep(userID uint, step int64) (bool, error)
+}
+
+// TwoFactorCheck is the outcome of an accepted two-factor code
+type TwoFactorCheck struct {
+	// Step is the time step of an accepted TOTP code
+	Step int64
+	// BackupCodeUsed is set when a backup code was accepted instead, whose
+	// hash is left out of the Remaining ones
+	BackupCodeUsed bool
+	Remaining      []string
+}
+
+// LoginRequest holds the credentials submitted by a client
+type LoginRequest struct {
+	// Provider names the credential provider, the default provider when empty
+	Provider      string
+	Username      string
+	Password      string
+	Code          string
+	RedirectURI   string
+	TwoFactorCode string
+	ClientIP      string
+}
+
+// LoginResult holds the tokens issued after a successful login
+type LoginResult struct {
+	User         *models.User
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// Authenticator verifies user credentials through the configured providers,
+// enforces the second factor and issues tokens
+type Authenticator struct {
+	config     configv1.AuthConfig
+	logger     *zap.Logger
+	users      LoginUserRepository
+	jwtManager *JWTManager
+
+	providers       map[string]Provider
+	providerRoles   map[string][]string
+	defaultProvider string
+}
+
+// NewAuthenticator creates a new authenticator with the configured providers.
+// Without configured providers only the local database provider is available.
+func NewAuthenticator(config configv1.AuthConfig, users LoginUserRepository, jwtManager *JWTManager, logger *zap.Logger) (*Authenticator, error) {
+	a := &Authenticator{
+		config:          config,
+		logger:          logger,
+		users:           users,
+		jwtManager:      jwtManager,
+		providers:       make(map[string]Provider),
+		providerRoles:   make(map[string][]string),
+		defaultProvider: config.DefaultProvider,
+	}
+
+	providers := config.Providers
+	if len(providers) == 0 {
+		providers = []configv1.AuthProviderConfig{{Name: LocalProviderName, Type: "local"}}
+	}
+	for _, providerConfig := range providers {
+		provider, err := NewProvider(providerConfig, users, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create authentication provider %s: %w", providerConfig.Name, err)
+		}
+		a.providers[providerConfig.Name] = provider
+		a.providerRoles[providerConfig.Name] = providerConfig.Roles
+	}
+	if a.defaultProvider == "" {
+		a.defaultProvider = providers[0].Name
+	}
+
+	return a, nil
+}
+
+// Login verifies the credentials with the requested provider and, when enabled,
+// the second factor before issuing tokens
+func (a *Authenticator) Login(ctx context.Context, req LoginRequest) (*LoginResult, error) {
+	providerName := req.Provider
+	if providerName == "" {
+		providerName = a.defaultProvider
+	}
+	provider, ok := a.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	user, err := provider.Authenticate(ctx, Credentials{
+		Username:    req.Username,
+		Password:    req.Password,
+		Code:        req.Code,
+		RedirectURI: req.RedirectURI,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !a.roleAllowed(providerName, user.Role) {
+		a.logger.Warn("Login rejected: provider not allowed for role",
+			zap.Uint("user_id", user.ID),
+			zap.String("provider", providerName),
+			zap.String("role", string(user.Role)),
+		)
+		return nil, ErrProviderNotAllowed
+	}
+
+	// Logging in reactivates an account suspended for inactivity
+	reactivate := user.SuspendedForInactivity()
+	if reactivate {
+		user.Status = models.UserStatusActive
+	}
+	if !user.IsActive() {
+		a.logger.Info("Login failed: inactive account", zap.Uint("user_id", user.ID))
+		return nil, ErrAccountInactive
+	}
+
+	// Admins are exempt so that enabling verification cannot lock them out
+	if a.config.RequireEmailVerification && !user.EmailVerified && !user.Role.IsAdmin() {
+		a.logger.Info("Login failed: email not verified", zap.Uint("user_id", user.ID))
+		return nil, ErrEmailNotVerified
+	}
+
+	if user.RequiresTwoFactor() {
+		if req.TwoFactorCode == "" {
+			return nil, ErrTwoFactorRequired
+		}
+		if err := a.verifySecondFactor(user, req.TwoFactorCode); err != nil {
+			if incErr := a.users.IncrementLoginAttempts(user.ID); incErr != nil {
+				a.logger.Warn("Failed to increment login attempts", zap.Uint("user_id", user.ID), zap.Error(incErr))
+			}
+			return nil, err
+		}
+	} else if user.Role.IsAdmin() && a.config.RequireAdminTwoFactor {
+		a.logger.Warn("Admin login rejected: two-factor not enabled", zap.Uint("user_id", user.ID))
+		return nil, a.twoFactorSetupError(user)
+	}
+
+	if reactivate {
+		if err := a.users.ReactivateInactive(user.ID); err != nil {
+			return nil, err
+		}
+		a.logger.Info("Account reactivated by login", zap.Uint("user_id", user.ID))
+	}
+	if err := a.users.UpdateLastLogin(user.ID, req.ClientIP); err != nil {
+		a.logger.Warn("Failed to update last login", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	accessToken, err := a.jwtManager.GenerateToken(userID, user.Username, string(user.Role))
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := a.jwtManager.GenerateRefreshToken(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	a.logger.Info("User logged in",
+		zap.Uint("user_id", user.ID),
+		zap.String("provider", providerName),
+		zap.Bool("two_factor", user.RequiresTwoFactor()),
+	)
+	return &LoginResult{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    time.Now().Add(a.config.JWTExpiration),
+	}, nil
+}
+
+// twoFactorSetupError refuses the login of a user who has to enroll 2FA
+// first, with a token to do so
+func (a *Authenticator) twoFactorSetupError(user *models.User) error {
+	expiresAt := time.Now().Add(TwoFactorSetupTokenLifetime)
+	token, err := a.jwtManager.GenerateTwoFactorSetupToken(strconv.FormatUint(uint64(user.ID), 10), user.Username, string(user.Role))
+	if err != nil {
+		return err
+	}
+	return &TwoFactorSetupError{Token: token, ExpiresAt: expiresAt}
+}
+
+// roleAllowed checks whether users of a role may log in with a provider.
+// Allowing admins allows super admins too.
+func (a *Authenticator) roleAllowed(providerName string, role models.UserRole) bool {
+	roles := a.providerRoles[providerName]
+	if len(roles) == 0 {
+		return true
+	}
+	for _, allowed := range roles {
+		if allowed == string(role) || (allowed == string(models.UserRoleAdmin) && role.IsAdmin()) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySecondFactor checks a TOTP or backup code and persists consumed backup codes
+func (a *Authenticator) verifySecondFactor(user *models.User, code string) error {
+	check, err := ConsumeTwoFactorCode(a.users, user, code, time.Now())
+	if err != nil {
+		if errors.Is(err, ErrInvalidTOTPCode) {
+			a.logger.Info("Login failed: invalid two-factor code", zap.Uint("user_id", user.ID))
+		} else {
+			a.logger.Error("Failed to verify two-factor code", zap.Uint("user_id", user.ID), zap.Error(err))
+		}
+		return err
+	}
+
+	if check.BackupCodeUsed {
+		a.logger.Info("Backup code used for login", zap.Uint("user_id", user.ID), zap.Int("remaining", len(check.Remaining)))
+	}
+	return nil
+}
+
+// VerifyTwoFactorCode checks a TOTP code, falling back to the user's backup
+// codes. TOTP codes of the user's last accepted step or an earlier one are
+// rejected. Callers record the use of the code, see ConsumeTwoFactorCode.
+func VerifyTwoFactorCode(user *models.User, code string, now time.Time) (*TwoFactorCheck, error) {
+	if user.TwoFactorSecret == "" {
+		return nil, ErrInvalidTOTPSecret
+	}
+
+	step, valid, err := TOTPStep(user.TwoFactorSecret, code, now)
+	if err != nil {
+		return nil, err
+	}
+	if valid && step > user.TwoFactorLastStep {
+		return &TwoFactorCheck{Step: step}, nil
+	}
+
+	if remaining, ok := ConsumeBackupCode(user.TwoFactorBackupCodes, code); ok {
+		return &TwoFactorCheck{BackupCodeUsed: true, Remaining: remaining}, nil
+	}
+	return nil, ErrInvalidTOTPCode
+}
+
+// ConsumeTwoFactorCode verifies a code with VerifyTwoFactorCode and records
+// its use in store, so that no code is accepted twice. A TOTP or backup code
+// accepted concurrently is rejected with ErrInvalidTOTPCode.
+func ConsumeTwoFactorCode(store TwoFactorStore, user *models.User, code string, now time.Time) (*TwoFactorCheck, error) {
+	check, err := VerifyTwoFactorCode(user, code, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if check.BackupCodeUsed {
+		replaced, err := store.ReplaceBackupCodes(user.ID, user.TwoFactorBackupCodes, check.Remaining)
+		if err != nil {
+			return nil, fmt.Errorf("failed to consume backup code: %w", err)
+		}
+		if !replaced {
+			return nil, ErrInvalidTOTPCode
+		}
+		return check, nil
+	}
+	used, err := store.UseTwoFactorStep(user.ID, check.Step)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record two-factor code: %w", err)
+	}
+	if !used {
+		return nil, ErrInvalidTOTPCode
+	}
+	return check, nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// nodeTokenPrefix makes node tokens recognizable in configs and secret scanners
+const nodeTokenPrefix = "sbn_"
+
+// enrollmentTokenPrefix tells node enrollment tokens from node tokens
+const enrollmentTokenPrefix = "sbe_"
+
+// GenerateNodeToken generates a new random node registration token
+func GenerateNodeToken() (string, error) {
+	return generateToken(nodeTokenPrefix, "node token")
+}
+
+// GenerateEnrollmentToken generates a new random one-time node enrollment
+// token, stored hashed with HashNodeToken
+func GenerateEnrollmentToken() (string, error) {
+	return generateToken(enrollmentTokenPrefix, "enrollment token")
+}
+
+// generateToken generates a random token with prefix
+func generateToken(prefix, kind string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate %s: %w", kind, err)
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// HashNodeToken returns the hash under which a node token is stored
+func HashNodeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
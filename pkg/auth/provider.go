@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+// LocalProviderName is the name of the provider used when none is configured
+const LocalProviderName = "local"
+
+var (
+	// ErrUnknownProvider is returned when a login names a provider that is not configured
+	ErrUnknownProvider = errors.New("unknown authentication provider")
+	// ErrProviderNotAllowed is returned when the user's role may not log in with the provider
+	ErrProviderNotAllowed = errors.New("authentication provider is not allowed for this account")
+)
+
+// Credentials are submitted by a client and verified by a provider. Password
+// based providers use Username and Password, OIDC uses Code and RedirectURI.
+type Credentials struct {
+	Username    string
+	Password    string
+	Code        string
+	RedirectURI string
+}
+
+// Provider verifies credentials and resolves the local user they belong to.
+// Accounts always exist locally; providers only decide whether the
+// credentials prove the identity of one.
+type Provider interface {
+	// Name returns the configured name of the provider
+	Name() string
+	// Authenticate verifies the credentials and returns the matching local user
+	Authenticate(ctx context.Context, creds Credentials) (*models.User, error)
+}
+
+// ProviderFactory creates a provider from its configuration
+type ProviderFactory func(config configv1.AuthProviderConfig, users LoginUserRepository, logger *zap.Logger) (Provider, error)
+
+var (
+	providerFactoriesMu sync.RWMutex
+	providerFactories   = map[string]ProviderFactory{
+		"local": newLocalProvider,
+		"ldap":  newLDAPProvider,
+		"oidc":  newOIDCProvider,
+	}
+)
+
+// RegisterProvider makes a provider type available to the configuration
+func RegisterProvider(providerType string, factory ProviderFactory) {
+	providerFactoriesMu.Lock()
+	defer providerFactoriesMu.Unlock()
+	providerFactories[providerType] = factory
+}
+
+// NewProvider creates a provider of the configured type
+func NewProvider(config configv1.AuthProviderConfig, users LoginUserRepository, logger *zap.Logger) (Provider, error) {
+	providerFactoriesMu.RLock()
+	factory, ok := providerFactories[config.Type]
+	providerFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported authentication provider type: %s", config.Type)
+	}
+	return factory(config, users, logger.With(zap.String("provider", config.Name)))
+}
+
+// resolveUser maps an identity verified by an external provider to its local account
+func resolveUser(users LoginUserRepository, username string) (*models.User, error) {
+	if username == "" {
+		return nil, ErrInvalidCredentials
+	}
+	user, err := users.GetByUsername(username)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+const (
+	// defaultLDAPTimeout bounds a bind when no timeout is configured
+	defaultLDAPTimeout = 10 * time.Second
+
+	// LDAP result codes (RFC 4511)
+	ldapResultSuccess            = 0
+	ldapResultInvalidCredentials = 49
+
+	// maxLDAPMessageSize caps the size of a bind response
+	maxLDAPMessageSize = 64 * 1024
+)
+
+// ldapProvider verifies passwords with an LDAP simple bind as the user
+type ldapProvider struct {
+	name   string
+	config configv1.LDAPProviderConfig
+	users  LoginUserRepository
+	logger *zap.Logger
+}
+
+// newLDAPProvider creates an LDAP provider
+func newLDAPProvider(config configv1.AuthProviderConfig, users LoginUserRepository, logger *zap.Logger) (Provider, error) {
+	if _, err := url.Parse(config.LDAP.URL); err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	if config.LDAP.Timeout <= 0 {
+		config.LDAP.Timeout = defaultLDAPTimeout
+	}
+	return &ldapProvider{name: config.Name, config: config.LDAP, users: users, logger: logger}, nil
+}
+
+// Name returns the configured name of the provider
+func (p *ldapProvider) Name() string {
+	return p.name
+}
+
+// Authenticate binds as the user's DN and resolves the local account of the same username
+func (p *ldapProvider) Authenticate(ctx context.Context, creds Credentials) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which servers accept
+	if creds.Username == "" || creds.Password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	dn := fmt.Sprintf(p.config.BindDNTemplate, escapeDN(creds.Username))
+	if err := p.bind(ctx, dn, creds.Password); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			p.logger.Info("Login failed: LDAP bind rejected", zap.String("username", creds.Username))
+		} else {
+			p.logger.Error("LDAP bind failed", zap.String("username", creds.Username), zap.Error(err))
+		}
+		return nil, err
+	}
+
+	return resolveUser(p.users, creds.Username)
+}
+
+// bind performs a single LDAPv3 simple bind
+func (p *ldapProvider) bind(ctx context.Context, dn, password string) error {
+	u, err := url.Parse(p.config.URL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	var conn net.Conn
+	dialer := &net.Dialer{}
+	switch u.Scheme {
+	case "ldaps":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: p.config.InsecureSkipVerify,
+		}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	case "ldap":
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	default:
+		return fmt.Errorf("unsupported LDAP scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(ldapBindRequest(1, dn, password)); err != nil {
+		return fmt.Errorf("failed to send LDAP bind request: %w", err)
+	}
+
+	message, err := readBERMessage(bufio.NewReader(conn))
+	if err != nil {
+		return fmt.Errorf("failed to read LDAP bind response: %w", err)
+	}
+
+	code, diagnostic, err := parseLDAPBindResponse(message)
+	if err != nil {
+		return err
+	}
+	switch code {
+	case ldapResultSuccess:
+		return nil
+	case ldapResultInvalidCredentials:
+		return ErrInvalidCredentials
+	default:
+		return fmt.Errorf("LDAP bind failed with result code %d: %s", code, diagnostic)
+	}
+}
+
+// escapeDN escapes an attribute value for use in a distinguished name (RFC 4514)
+func escapeDN(value string) string {
+	var b strings.Builder
+	for i, r := range value {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, r),
+			r == '#' && i == 0,
+			r == ' ' && (i == 0 || i == len(value)-1):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == 0:
+			b.WriteString(`\00`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ldapBindRequest encodes a simple BindRequest LDAPMessage
+func ldapBindRequest(messageID int, dn, password string) []byte {
+	bind := berTLV(0x60, // [APPLICATION 0] BindRequest
+		berInteger(0x02, 3),
+		berTLV(0x04, []byte(dn)),
+		berTLV(0x80, []byte(password)), // [0] simple
+	)
+	return berTLV(0x30, berInteger(0x02, messageID), bind)
+}
+
+// parseLDAPBindResponse returns the result code and diagnostic message of a BindResponse
+func parseLDAPBindResponse(message []byte) (int, string, error) {
+	var envelope struct {
+		MessageID  int
+		ProtocolOp asn1.RawValue
+	}
+	if _, err := asn1.Unmarshal(message, &envelope); err != nil {
+		return 0, "", fmt.Errorf("invalid LDAP message: %w", err)
+	}
+	op := envelope.ProtocolOp
+	if op.Class != asn1.ClassApplication || op.Tag != 1 {
+		return 0, "", fmt.Errorf("unexpected LDAP operation %d", op.Tag)
+	}
+
+	var code asn1.Enumerated
+	rest, err := asn1.Unmarshal(op.Bytes, &code)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid LDAP bind response: %w", err)
+	}
+
+	var matchedDN, diagnostic asn1.RawValue
+	if rest, err = asn1.Unmarshal(rest, &matchedDN); err == nil {
+		if _, err := asn1.Unmarshal(rest, &diagnostic); err == nil {
+			return int(code), string(diagnostic.Bytes), nil
+		}
+	}
+	return int(code), "", nil
+}
+
+// readBERMessage reads one BER encoded element
+func readBERMessage(r *bufio.Reader) ([]byte, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte{tag, first}
+	length := int(first)
+	if first&0x80 != 0 {
+		count := int(first & 0x7f)
+		if count == 0 || count > 4 {
+			return nil, errors.New("unsupported BER length")
+		}
+		length = 0
+		for i := 0; i < count; i++ {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			header = append(header, b)
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxLDAPMessageSize {
+		return nil, errors.New("LDAP message too large")
+	}
+
+	message := make([]byte, len(header)+length)
+	copy(message, header)
+	if _, err := io.ReadFull(r, message[len(header):]); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// berTLV encodes a BER element from its tag and the concatenated contents
+func berTLV(tag byte, contents ...[]byte) []byte {
+	var body []byte
+	for _, content := range contents {
+		body = append(body, content...)
+	}
+
+	out := []byte{tag}
+	if len(body) < 0x80 {
+		out = append(out, byte(len(body)))
+	} else {
+		var length []byte
+		for n := len(body); n > 0; n >>= 8 {
+			length = append([]byte{byte(n)}, length...)
+		}
+		out = append(out, 0x80|byte(len(length)))
+		out = append(out, length...)
+	}
+	return append(out, body...)
+}
+
+// berInteger encodes a non-negative integer
+func berInteger(tag byte, value int) []byte {
+	var body []byte
+	for n := value; n > 0; n >>= 8 {
+		body = append([]byte{byte(n)}, body...)
+	}
+	if len(body) == 0 || body[0]&0x80 != 0 {
+		body = append([]byte{0}, body...)
+	}
+	return berTLV(tag, body)
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestEscapeDN(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "plain", value: "alice", want: "alice"},
+		{name: "comma", value: "doe, john", want: `doe\, john`},
+		{name: "injection", value: "x,ou=admins", want: `x\,ou\=admins`},
+		{name: "leading hash", value: "#admin", want: `\#admin`},
+		{name: "surrounding spaces", value: " bob ", want: `\ bob\ `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeDN(tt.value); got != tt.want {
+				t.Errorf("escapeDN(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// serveLDAPBind answers one bind request, accepting only the given password
+func serveLDAPBind(t *testing.T, password string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		request, err := readBERMessage(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		code := byte(ldapResultInvalidCredentials)
+		if bytes.HasSuffix(request, append([]byte{0x80, byte(len(password))}, password...)) {
+			code = ldapResultSuccess
+		}
+		conn.Write(berTLV(0x30, berInteger(0x02, 1),
+			berTLV(0x61, berTLV(0x0a, []byte{code}), berTLV(0x04, nil), berTLV(0x04, nil))))
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestLDAPProviderBind(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		wantErr  error
+	}{
+		{name: "valid password", password: "secret"},
+		{name: "wrong password", password: "wrong", wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := serveLDAPBind(t, "secret")
+			provider, err := newLDAPProvider(configv1.AuthProviderConfig{
+				Name: "ldap",
+				LDAP: configv1.LDAPProviderConfig{
+					URL:            "ldap://" + address,
+					BindDNTemplate: "uid=%s,ou=people,dc=example,dc=org",
+				},
+			}, nil, zap.NewNop())
+			if err != nil {
+				t.Fatalf("newLDAPProvider() error = %v", err)
+			}
+
+			err = provider.(*ldapProvider).bind(context.Background(), "uid=alice,ou=people,dc=example,dc=org", tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("bind() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
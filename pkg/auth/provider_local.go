@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+// ErrPasswordTooLong is returned for passwords longer than bcrypt hashes
+var ErrPasswordTooLong = errors.New("password is longer than 72 bytes")
+
+// HashPassword returns the bcrypt hash of a password, as the local provider
+// verifies it
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", ErrPasswordTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// localProvider verifies passwords against the bcrypt hashes in the database
+type localProvider struct {
+	name   string
+	users  LoginUserRepository
+	logger *zap.Logger
+}
+
+// newLocalProvider creates a local database provider
+func newLocalProvider(config configv1.AuthProviderConfig, users LoginUserRepository, logger *zap.Logger) (Provider, error) {
+	return &localProvider{name: config.Name, users: users, logger: logger}, nil
+}
+
+// Name returns the configured name of the provider
+func (p *localProvider) Name() string {
+	return p.name
+}
+
+// Authenticate checks the password and counts failed attempts
+func (p *localProvider) Authenticate(ctx context.Context, creds Credentials) (*models.User, error) {
+	user, err := p.users.GetByUsername(creds.Username)
+	if err != nil {
+		p.logger.Info("Login failed: unknown user", zap.String("username", creds.Username))
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(creds.Password)); err != nil {
+		if incErr := p.users.IncrementLoginAttempts(user.ID); incErr != nil {
+			p.logger.Warn("Failed to increment login attempts", zap.Uint("user_id", user.ID), zap.Error(incErr))
+		}
+		p.logger.Info("Login failed: wrong password", zap.Uint("user_id", user.ID))
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
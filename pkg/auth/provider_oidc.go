@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+const (
+	// defaultOIDCTimeout bounds each request to the identity provider
+	defaultOIDCTimeout = 10 * time.Second
+	// defaultOIDCUsernameClaim is matched against local usernames
+	defaultOIDCUsernameClaim = "preferred_username"
+	// maxOIDCResponseSize caps token and userinfo responses
+	maxOIDCResponseSize = 1 << 20
+)
+
+// oidcProvider exchanges an authorization code for tokens and identifies the
+// user through the userinfo endpoint. The identity provider is reached
+// directly over TLS, so the userinfo response needs no signature check.
+type oidcProvider struct {
+	name   string
+	config configv1.OIDCProviderConfig
+	users  LoginUserRepository
+	client *http.Client
+	logger *zap.Logger
+}
+
+// oidcTokenResponse is the token endpoint response (RFC 6749 section 5.1)
+type oidcTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// newOIDCProvider creates an OIDC provider
+func newOIDCProvider(config configv1.AuthProviderConfig, users LoginUserRepository, logger *zap.Logger) (Provider, error) {
+	if config.OIDC.Timeout <= 0 {
+		config.OIDC.Timeout = defaultOIDCTimeout
+	}
+	if config.OIDC.UsernameClaim == "" {
+		config.OIDC.UsernameClaim = defaultOIDCUsernameClaim
+	}
+	return &oidcProvider{
+		name:   config.Name,
+		config: config.OIDC,
+		users:  users,
+		client: &http.Client{Timeout: config.OIDC.Timeout},
+		logger: logger,
+	}, nil
+}
+
+// Name returns the configured name of the provider
+func (p *oidcProvider) Name() string {
+	return p.name
+}
+
+// Authenticate exchanges the authorization code and resolves the local account of the username claim
+func (p *oidcProvider) Authenticate(ctx context.Context, creds Credentials) (*models.User, error) {
+	if creds.Code == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	accessToken, err := p.exchangeCode(ctx, creds.Code, creds.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := p.userInfo(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	username, _ := claims[p.config.UsernameClaim].(string)
+	user, err := resolveUser(p.users, username)
+	if err != nil {
+		p.logger.Info("Login failed: no local account for OIDC identity",
+			zap.String("claim", p.config.UsernameClaim),
+			zap.String("username", username),
+		)
+		return nil, err
+	}
+	return user, nil
+}
+
+// exchangeCode redeems the authorization code for an access token
+func (p *oidcProvider) exchangeCode(ctx context.Context, code, redirectURI string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var token oidcTokenResponse
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return "", err
+	}
+	// invalid_grant and friends are reported with 400 and 401
+	if status == http.StatusBadRequest || status == http.StatusUnauthorized {
+		p.logger.Info("Login failed: authorization code rejected", zap.Int("status", status))
+		return "", ErrInvalidCredentials
+	}
+	if status != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("OIDC token endpoint returned status %d", status)
+	}
+	return token.AccessToken, nil
+}
+
+// userInfo fetches the claims of the access token's subject
+func (p *oidcProvider) userInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	claims := make(map[string]interface{})
+	status, err := p.doJSON(req, &claims)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("OIDC userinfo endpoint returned status %d", status)
+	}
+	return claims, nil
+}
+
+// doJSON sends a request and decodes a JSON body on success
+func (p *oidcProvider) doJSON(req *http.Request, out interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("OIDC request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxOIDCResponseSize))
+		return resp.StatusCode, nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOIDCResponseSize)).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid OIDC response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// TOTPDigits is the number of digits in a generated code
+	TOTPDigits = 6
+	// TOTPPeriod is the time step of a code
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is the number of time steps accepted before and after the current one
+	TOTPSkew = 1
+
+	// totpSecretSize is the secret length in bytes (160 bits as recommended by RFC 4226)
+	totpSecretSize = 20
+
+	// BackupCodeCount is the number of backup codes generated per enrollment
+	BackupCodeCount = 10
+	// backupCodeLength is the number of characters in a backup code
+	backupCodeLength = 10
+)
+
+var (
+	// ErrInvalidTOTPCode is returned when a TOTP or backup code does not match
+	ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+	// ErrInvalidTOTPSecret is returned when a stored secret cannot be decoded
+	ErrInvalidTOTPSecret = errors.New("invalid two-factor secret")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// backupCodeAlphabet avoids characters that are easily confused when read aloud
+const backupCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateTOTPSecret generates a new random base32 encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPProvisioningURI builds the otpauth:// URI rendered as a QR code by authenticator apps
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", int(TOTPPeriod.Seconds())))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// GenerateTOTPCode generates the TOTP code for the given secret at time t (RFC 6238)
+func GenerateTOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix())/uint64(TOTPPeriod.Seconds())), nil
+}
+
+// ValidateTOTPCode checks a code against the secret, allowing TOTPSkew steps of clock drift
+func ValidateTOTPCode(secret, code string, t time.Time) (bool, error) {
+	_, valid, err := TOTPStep(secret, code, t)
+	return valid, err
+}
+
+// TOTPStep checks a code like ValidateTOTPCode and returns the time step it
+// was generated for, which callers store to reject the code when replayed
+func TOTPStep(secret, code string, t time.Time) (int64, bool, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false, nil
+	}
+
+	counter := int64(t.Unix()) / int64(TOTPPeriod.Seconds())
+	for i := -TOTPSkew; i <= TOTPSkew; i++ {
+		c := counter + int64(i)
+		if c < 0 {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(c))), []byte(code)) == 1 {
+			return c, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// GenerateBackupCodes generates a set of one-time backup codes.
+// The plain codes are shown to the user once; only the hashes must be stored.
+func GenerateBackupCodes(count int) (codes []string, hashes []string, err error) {
+	codes = make([]string, 0, count)
+	hashes = make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		code, err := randomBackupCode()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to generate backup code: %w", err)
+		}
+
+		hash, err := bcrypt.GenerateFromPassword(code, bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to hash backup code: %w", err)
+		}
+
+		codes = append(codes, string(code))
+		hashes = append(hashes, string(hash))
+	}
+	return codes, hashes, nil
+}
+
+// randomBackupCode draws the characters of a backup code uniformly from the
+// alphabet. Random bytes from the largest multiple of the alphabet size up
+// are rejected, as they would favour the first characters.
+func randomBackupCode() ([]byte, error) {
+	limit := 256 - 256%len(backupCodeAlphabet)
+	code := make([]byte, 0, backupCodeLength)
+	buf := make([]byte, backupCodeLength)
+	for len(code) < backupCodeLength {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		for _, b := range buf {
+			if int(b) < limit && len(code) < backupCodeLength {
+				code = append(code, backupCodeAlphabet[int(b)%len(backupCodeAlphabet)])
+			}
+		}
+	}
+	return code, nil
+}
+
+// ConsumeBackupCode checks a backup code against the stored hashes.
+// On success it returns the remaining hashes with the used one removed.
+func ConsumeBackupCode(hashes []string, code string) ([]string, bool) {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if code == "" {
+		return hashes, false
+	}
+
+	for i, hash := range hashes {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+			remaining := make([]string, 0, len(hashes)-1)
+			remaining = append(remaining, hashes[:i]...)
+			remaining = append(remaining, hashes[i+1:]...)
+			return remaining, true
+		}
+	}
+	return hashes, false
+}
+
+// decodeTOTPSecret decodes a base32 secret, tolerating lowercase, spaces and padding
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	normalized = strings.TrimRight(normalized, "=")
+	key, err := totpEncoding.DecodeString(normalized)
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidTOTPSecret
+	}
+	return key, nil
+}
+
+// hotp computes an HOTP value (RFC 4226) for the given counter
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
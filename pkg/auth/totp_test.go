@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"encoding/base32"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+// rfc6238Secret is the SHA1 test key from RFC 6238 Appendix B
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestGenerateTOTPCode(t *testing.T) {
+	tests := []struct {
+		name string
+		unix int64
+		want string
+	}{
+		{name: "t=59", unix: 59, want: "287082"},
+		{name: "t=1111111109", unix: 1111111109, want: "081804"},
+		{name: "t=1234567890", unix: 1234567890, want: "005924"},
+		{name: "t=2000000000", unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateTOTPCode(rfc6238Secret, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("GenerateTOTPCode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateTOTPCode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		want bool
+	}{
+		{name: "current step", code: "081804", at: now, want: true},
+		{name: "previous step within skew", code: "081804", at: now.Add(TOTPPeriod), want: true},
+		{name: "outside skew", code: "081804", at: now.Add(3 * TOTPPeriod), want: false},
+		{name: "wrong code", code: "000000", at: now, want: false},
+		{name: "wrong length", code: "81804", at: now, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateTOTPCode(rfc6238Secret, tt.code, tt.at)
+			if err != nil {
+				t.Fatalf("ValidateTOTPCode() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateTOTPCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ValidateTOTPCode("not base32!", "000000", now); err != ErrInvalidTOTPSecret {
+		t.Errorf("ValidateTOTPCode() with invalid secret error = %v, want %v", err, ErrInvalidTOTPSecret)
+	}
+}
+
+func TestConsumeBackupCode(t *testing.T) {
+	codes, hashes, err := GenerateBackupCodes(2)
+	if err != nil {
+		t.Fatalf("GenerateBackupCodes() error = %v", err)
+	}
+
+	remaining, ok := ConsumeBackupCode(hashes, codes[0])
+	if !ok || len(remaining) != 1 {
+		t.Fatalf("ConsumeBackupCode() = %d remaining, ok %v; want 1, true", len(remaining), ok)
+	}
+
+	if _, ok := ConsumeBackupCode(remaining, codes[0]); ok {
+		t.Error("ConsumeBackupCode() accepted an already used code")
+	}
+}
+
+func TestRandomBackupCode(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		code, err := randomBackupCode()
+		if err != nil {
+			t.Fatalf("randomBackupCode() error = %v", err)
+		}
+		if len(code) != backupCodeLength {
+			t.Fatalf("randomBackupCode() = %q, want %d characters", code, backupCodeLength)
+		}
+		for _, c := range code {
+			if !strings.ContainsRune(backupCodeAlphabet, rune(c)) {
+				t.Fatalf("randomBackupCode() = %q, %q is not in the alphabet", code, c)
+			}
+		}
+	}
+}
+
+// testTwoFactorStore keeps the last accepted step and the backup codes like
+// the user repository
+type testTwoFactorStore struct {
+	user *models.User
+}
+
+func (s testTwoFactorStore) ReplaceBackupCodes(userID uint, old, backupCodes []string) (bool, error) {
+	if !slices.Equal(s.user.TwoFactorBackupCodes, old) {
+		return false, nil
+	}
+	s.user.TwoFactorBackupCodes = backupCodes
+	return true, nil
+}
+
+func (s testTwoFactorStore) UseTwoFactorStep(userID uint, step int64) (bool, error) {
+	if step <= s.user.TwoFactorLastStep {
+		return false, nil
+	}
+	s.user.TwoFactorLastStep = step
+	return true, nil
+}
+
+func TestConsumeTwoFactorCodeReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	user := &models.User{TwoFactorEnabled: true, TwoFactorSecret: rfc6238Secret}
+	store := testTwoFactorStore{user: user}
+
+	code, err := GenerateTOTPCode(rfc6238Secret, now)
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode() error = %v", err)
+	}
+	check, err := ConsumeTwoFactorCode(store, user, code, now)
+	if err != nil {
+		t.Fatalf("ConsumeTwoFactorCode() error = %v", err)
+	}
+	if check.BackupCodeUsed || user.TwoFactorLastStep != check.Step {
+		t.Fatalf("ConsumeTwoFactorCode() = %+v, stored step %d", check, user.TwoFactorLastStep)
+	}
+
+	// The same code is rejected for the rest of its window
+	if _, err := ConsumeTwoFactorCode(store, user, code, now.Add(TOTPPeriod)); err != ErrInvalidTOTPCode {
+		t.Errorf("ConsumeTwoFactorCode() of a used code error = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+	// and so are codes of earlier steps
+	earlier, err := GenerateTOTPCode(rfc6238Secret, now.Add(-TOTPPeriod))
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode() error = %v", err)
+	}
+	if _, err := ConsumeTwoFactorCode(store, user, earlier, now); err != ErrInvalidTOTPCode {
+		t.Errorf("ConsumeTwoFactorCode() of an earlier code error = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+
+	next, err := GenerateTOTPCode(rfc6238Secret, now.Add(TOTPPeriod))
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode() error = %v", err)
+	}
+	if _, err := ConsumeTwoFactorCode(store, user, next, now.Add(TOTPPeriod)); err != nil {
+		t.Errorf("ConsumeTwoFactorCode() of the next code error = %v", err)
+	}
+}
+
+func TestConsumeTwoFactorCodeConcurrentBackupCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	codes, hashes, err := GenerateBackupCodes(2)
+	if err != nil {
+		t.Fatalf("GenerateBackupCodes() error = %v", err)
+	}
+	stored := &models.User{TwoFactorEnabled: true, TwoFactorSecret: rfc6238Secret, TwoFactorBackupCodes: hashes}
+	store := testTwoFactorStore{user: stored}
+
+	// Two logins load the user before either spends the code
+	first, second := *stored, *stored
+	check, err := ConsumeTwoFactorCode(store, &first, codes[0], now)
+	if err != nil || !check.BackupCodeUsed {
+		t.Fatalf("ConsumeTwoFactorCode() = %+v, %v, want the backup code accepted", check, err)
+	}
+	if _, err := ConsumeTwoFactorCode(store, &second, codes[0], now); err != ErrInvalidTOTPCode {
+		t.Errorf("ConsumeTwoFactorCode() of a concurrently used backup code error = %v, want %v", err, ErrInvalidTOTPCode)
+	}
+	if len(stored.TwoFactorBackupCodes) != 1 {
+		t.Errorf("stored backup codes = %d, want 1", len(stored.TwoFactorBackupCodes))
+	}
+}
@@ -0,0 +1,128 @@
+// Package blocklist normalizes and matches the values of the registration
+// blocklist and imports public disposable email domain lists into it.
+package blocklist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"sing-box-web/pkg/models"
+)
+
+var (
+	// ErrInvalidValue is returned for a value that does not fit its type
+	ErrInvalidValue = errors.New("invalid blocklist value")
+	// ErrListTooLarge is returned for a list with more domains than allowed
+	ErrListTooLarge = errors.New("domain list too large")
+)
+
+// Normalize returns the form a value is stored and matched in: lowercase
+// emails and domains, and canonical CIDRs. A single IP becomes a host CIDR.
+func Normalize(t models.BlocklistType, value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch t {
+	case models.BlocklistTypeEmail:
+		local, domain, ok := strings.Cut(value, "@")
+		if !ok || local == "" || strings.Contains(domain, "@") || !validDomain(domain) {
+			return "", fmt.Errorf("%w: %q is not an email address", ErrInvalidValue, value)
+		}
+		return value, nil
+	case models.BlocklistTypeDomain:
+		domain := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(value, "@"), "*."), ".")
+		if !validDomain(domain) {
+			return "", fmt.Errorf("%w: %q is not a domain", ErrInvalidValue, value)
+		}
+		return domain, nil
+	case models.BlocklistTypeCIDR:
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return "", fmt.Errorf("%w: %q is not an IP address or CIDR", ErrInvalidValue, value)
+			}
+			if ip.To4() != nil {
+				return ip.String() + "/32", nil
+			}
+			return ip.String() + "/128", nil
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q is not an IP address or CIDR", ErrInvalidValue, value)
+		}
+		return network.String(), nil
+	}
+	return "", fmt.Errorf("%w: unknown type %q", ErrInvalidValue, t)
+}
+
+// EmailDomains returns the domain of an email and its parent domains, the
+// values of the domain entries that block the email
+func EmailDomains(email string) []string {
+	_, domain, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok || domain == "" {
+		return nil
+	}
+	domains := []string{domain}
+	for {
+		_, parent, ok := strings.Cut(domain, ".")
+		if !ok || parent == "" {
+			return domains
+		}
+		domains = append(domains, parent)
+		domain = parent
+	}
+}
+
+// ContainsIP reports whether the CIDR of an entry contains ip
+func ContainsIP(cidr string, ip net.IP) bool {
+	_, network, err := net.ParseCIDR(cidr)
+	return err == nil && network.Contains(ip)
+}
+
+// ParseList reads a domain list: one domain per line, with blank lines and
+// lines starting with # or // ignored. Lines that are not a domain are
+// skipped, so a list with a stray entry still imports.
+func ParseList(r io.Reader, maxDomains int) ([]string, error) {
+	var domains []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		domain, err := Normalize(models.BlocklistTypeDomain, line)
+		if err != nil || seen[domain] {
+			continue
+		}
+		if len(domains) == maxDomains {
+			return nil, fmt.Errorf("%w: more than %d domains", ErrListTooLarge, maxDomains)
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// validDomain checks the length and charset of a domain and its labels
+func validDomain(domain string) bool {
+	if domain == "" || len(domain) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package blocklist
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		t       models.BlocklistType
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"email", models.BlocklistTypeEmail, " Spam@Example.COM ", "spam@example.com", false},
+		{"email without local part", models.BlocklistTypeEmail, "@example.com", "", true},
+		{"email without domain", models.BlocklistTypeEmail, "spam", "", true},
+		{"domain", models.BlocklistTypeDomain, "Mailinator.com", "mailinator.com", false},
+		{"domain with at and wildcard", models.BlocklistTypeDomain, "@*.mailinator.com.", "mailinator.com", false},
+		{"domain with bad label", models.BlocklistTypeDomain, "-bad.com", "", true},
+		{"ipv4", models.BlocklistTypeCIDR, "192.0.2.1", "192.0.2.1/32", false},
+		{"ipv6", models.BlocklistTypeCIDR, "2001:DB8::1", "2001:db8::1/128", false},
+		{"cidr is canonicalized", models.BlocklistTypeCIDR, "192.0.2.77/24", "192.0.2.0/24", false},
+		{"invalid cidr", models.BlocklistTypeCIDR, "192.0.2.1/40", "", true},
+		{"unknown type", "phone", "123", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.t, tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidValue) {
+					t.Fatalf("Normalize() error = %v, want ErrInvalidValue", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailDomains(t *testing.T) {
+	got := EmailDomains("User@Mail.Temp.Example.com")
+	want := []string{"mail.temp.example.com", "temp.example.com", "example.com", "com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("EmailDomains() = %v, want %v", got, want)
+	}
+	if got := EmailDomains("not-an-email"); got != nil {
+		t.Errorf("EmailDomains() of a non-email = %v, want nil", got)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	list := `# disposable domains
+mailinator.com
+Guerrillamail.com
+
+// duplicate and invalid lines are skipped
+mailinator.com
+not a domain
+`
+	domains, err := ParseList(strings.NewReader(list), 10)
+	if err != nil {
+		t.Fatalf("ParseList() error = %v", err)
+	}
+	want := []string{"mailinator.com", "guerrillamail.com"}
+	if !slices.Equal(domains, want) {
+		t.Errorf("ParseList() = %v, want %v", domains, want)
+	}
+
+	if _, err := ParseList(strings.NewReader(list), 1); !errors.Is(err, ErrListTooLarge) {
+		t.Errorf("ParseList() over the limit error = %v, want ErrListTooLarge", err)
+	}
+}
@@ -0,0 +1,99 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// ListResult is the outcome of importing one domain list
+type ListResult struct {
+	URL     string
+	Domains int
+	Added   int
+	Removed int
+	Err     error
+}
+
+// Updater imports the configured disposable domain lists into the
+// blocklist. The entries of a list use its URL as source.
+type Updater struct {
+	config configv1.BlocklistConfig
+	repo   repository.BlocklistRepository
+	client *http.Client
+	logger *zap.Logger
+}
+
+// NewUpdater creates a disposable domain list updater
+func NewUpdater(config configv1.BlocklistConfig, repo repository.BlocklistRepository, logger *zap.Logger) *Updater {
+	return &Updater{
+		config: config,
+		repo:   repo,
+		client: &http.Client{Timeout: config.DownloadTimeout},
+		logger: logger.Named("blocklist"),
+	}
+}
+
+// Refresh downloads every list and replaces its entries. A failing list
+// keeps its previous entries.
+func (u *Updater) Refresh(ctx context.Context) ([]ListResult, error) {
+	results := make([]ListResult, 0, len(u.config.DisposableDomainLists))
+	var errs []error
+	for _, url := range u.config.DisposableDomainLists {
+		result := u.refreshList(ctx, url)
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", url, result.Err))
+		} else {
+			u.logger.Info("Disposable domain list imported",
+				zap.String("url", url),
+				zap.Int("domains", result.Domains),
+				zap.Int("added", result.Added),
+				zap.Int("removed", result.Removed),
+			)
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// refreshList downloads one list and replaces its entries
+func (u *Updater) refreshList(ctx context.Context, url string) ListResult {
+	result := ListResult{URL: url}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		result.Err = fmt.Errorf("unexpected status %s", resp.Status)
+		return result
+	}
+
+	domains, err := ParseList(resp.Body, u.config.MaxListSize)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	// An empty list is more likely a broken download than a real change
+	if len(domains) == 0 {
+		result.Err = errors.New("list contains no domains")
+		return result
+	}
+	result.Domains = len(domains)
+
+	result.Added, result.Removed, result.Err = u.repo.ReplaceSource(url, domains)
+	return result
+}
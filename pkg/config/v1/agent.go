@@ -19,6 +19,12 @@ type AgentConfig struct {
 	// Monitoring configuration
 	Monitor MonitorConfig `yaml:"monitor" json:"monitor"`
 
+	// Geo database synchronization
+	GeoData GeoDataSyncConfig `yaml:"geoData" json:"geoData"`
+
+	// Guard of the agent's own disk footprint
+	Disk DiskGuardConfig `yaml:"disk" json:"disk"`
+
 	// Logging configuration
 	Log LogConfig `yaml:"log" json:"log"`
 
@@ -27,6 +33,12 @@ type AgentConfig struct {
 
 	// SkyWalking configuration
 	SkyWalking SkyWalkingConfig `yaml:"skywalking" json:"skywalking"`
+
+	// Health and readiness endpoints
+	HealthEndpoints HealthEndpointConfig `yaml:"healthEndpoints" json:"healthEndpoints"`
+
+	// Graceful shutdown configuration
+	Shutdown ShutdownConfig `yaml:"shutdown" json:"shutdown"`
 }
 
 // NodeInfo defines node information
@@ -38,6 +50,12 @@ type NodeInfo struct {
 	Tags         map[string]string `yaml:"tags" json:"tags"`
 	Capabilities []string          `yaml:"capabilities" json:"capabilities"`
 	MaxUsers     int               `yaml:"maxUsers" json:"maxUsers"`
+	// EnrollmentToken is a one-time token an agent without a node ID
+	// exchanges on its first start for its node ID and node token
+	EnrollmentToken string `yaml:"enrollmentToken" json:"enrollmentToken"`
+	// CredentialsFile keeps the node ID and token obtained by enrolling,
+	// used on later starts
+	CredentialsFile string `yaml:"credentialsFile" json:"credentialsFile"`
 }
 
 // SingBoxConfig defines sing-box related configuration
@@ -49,6 +67,49 @@ type SingBoxConfig struct {
 	RestartDelay   time.Duration  `yaml:"restartDelay" json:"restartDelay"`
 	HealthCheckURL string         `yaml:"healthCheckUrl" json:"healthCheckUrl"`
 	ClashAPI       ClashAPIConfig `yaml:"clashApi" json:"clashApi"`
+	// SwapMode is how configuration changes are applied: "restart" stops
+	// sing-box and starts it again, dropping every connection; "blue-green"
+	// starts a second instance sharing the listen ports and stops the old
+	// one once the new one is healthy
+	SwapMode  string          `yaml:"swapMode" json:"swapMode"`
+	BlueGreen BlueGreenConfig `yaml:"blueGreen" json:"blueGreen"`
+	// Rollback restores the last working configuration when sing-box keeps
+	// crashing after a configuration change
+	Rollback RollbackConfig `yaml:"rollback" json:"rollback"`
+}
+
+// RollbackConfig defines when a configuration is deemed working and when
+// sing-box is deemed crash-looping. Configurations failing "sing-box check"
+// are never applied.
+type RollbackConfig struct {
+	// StableAfter is how long sing-box must run on a configuration for it
+	// to become the one rolled back to
+	StableAfter time.Duration `yaml:"stableAfter" json:"stableAfter"`
+	// MaxCrashes is how many times in a row sing-box may exit before running
+	// for StableAfter until the configuration is rolled back
+	MaxCrashes int `yaml:"maxCrashes" json:"maxCrashes"`
+}
+
+// Swap modes of SingBoxConfig
+const (
+	SwapModeRestart   = "restart"
+	SwapModeBlueGreen = "blue-green"
+)
+
+// BlueGreenConfig defines the zero-downtime configuration swaps. Both
+// instances listen on the same ports with SO_REUSEPORT (reuse_addr on the
+// inbounds), the kernel hands new connections to either until the old
+// instance is stopped.
+type BlueGreenConfig struct {
+	// HealthDelay is how long the new instance must keep running before
+	// traffic is switched to it
+	HealthDelay time.Duration `yaml:"healthDelay" json:"healthDelay"`
+	// DrainTimeout is how long the old instance keeps serving its open
+	// connections before it is stopped
+	DrainTimeout time.Duration `yaml:"drainTimeout" json:"drainTimeout"`
+	// FallbackToRestart restarts sing-box when the new instance fails its
+	// health check, e.g. on a sing-box without reuse_addr support
+	FallbackToRestart bool `yaml:"fallbackToRestart" json:"fallbackToRestart"`
 }
 
 // ClashAPIConfig defines Clash API configuration
@@ -59,6 +120,40 @@ type ClashAPIConfig struct {
 	Secret  string `yaml:"secret" json:"secret"`
 }
 
+// DiskGuardConfig defines how the agent watches its disk footprint. The
+// pressure is reported in heartbeats; at critical pressure the agent refuses
+// operations that write to disk, such as geo data downloads.
+type DiskGuardConfig struct {
+	// Path is a directory on the watched filesystem, defaults to singBox.workingDir
+	Path          string        `yaml:"path" json:"path"`
+	CheckInterval time.Duration `yaml:"checkInterval" json:"checkInterval"`
+	// WarningPercent and CriticalPercent are the disk usage of the pressure levels
+	WarningPercent  float64 `yaml:"warningPercent" json:"warningPercent"`
+	CriticalPercent float64 `yaml:"criticalPercent" json:"criticalPercent"`
+	// LogMaxSize is the size in megabytes at which the sing-box log
+	// (singBox.logPath) is rotated, keeping LogMaxBackups compressed files
+	LogMaxSize    int `yaml:"logMaxSize" json:"logMaxSize"`
+	LogMaxBackups int `yaml:"logMaxBackups" json:"logMaxBackups"`
+}
+
+// Disk pressure levels of DiskGuardConfig, reported in heartbeats
+const (
+	DiskPressureOK       = "ok"
+	DiskPressureWarning  = "warning"
+	DiskPressureCritical = "critical"
+)
+
+// GeoDataSyncConfig defines how the agent fetches the geo databases
+// distributed by the API server. Databases are also fetched on command.
+type GeoDataSyncConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Dir is where the databases are stored, sing-box rules reference them from there
+	Dir          string        `yaml:"dir" json:"dir"`
+	SyncInterval time.Duration `yaml:"syncInterval" json:"syncInterval"`
+	// RestartOnUpdate restarts sing-box after a database changed so that it loads the new one
+	RestartOnUpdate bool `yaml:"restartOnUpdate" json:"restartOnUpdate"`
+}
+
 // MonitorConfig defines monitoring configuration
 type MonitorConfig struct {
 	// Data collection intervals
@@ -75,6 +170,12 @@ type MonitorConfig struct {
 	LocalCacheSize          int           `yaml:"localCacheSize" json:"localCacheSize"`
 	LocalCacheFlushInterval time.Duration `yaml:"localCacheFlushInterval" json:"localCacheFlushInterval"`
 
+	// Reports the API server did not receive are kept in SpoolPath, at most
+	// SpoolMaxReports of them, and replayed every LocalCacheFlushInterval.
+	// An empty path drops them.
+	SpoolPath       string `yaml:"spoolPath" json:"spoolPath"`
+	SpoolMaxReports int    `yaml:"spoolMaxReports" json:"spoolMaxReports"`
+
 	// Retry settings
 	MaxRetries   int           `yaml:"maxRetries" json:"maxRetries"`
 	RetryBackoff time.Duration `yaml:"retryBackoff" json:"retryBackoff"`
@@ -94,6 +195,8 @@ func DefaultAgentConfig() *AgentConfig {
 			Tags:         map[string]string{},
 			Capabilities: []string{"user_management", "traffic_stats"},
 			MaxUsers:     1000,
+
+			CredentialsFile: "/var/lib/sing-box-agent/credentials.json",
 		},
 		APIServer: APIServerConnection{
 			Address:  "localhost",
@@ -114,6 +217,23 @@ func DefaultAgentConfig() *AgentConfig {
 				Port:    9090,
 				Secret:  "",
 			},
+			SwapMode: SwapModeRestart,
+			BlueGreen: BlueGreenConfig{
+				HealthDelay:       3 * time.Second,
+				DrainTimeout:      30 * time.Second,
+				FallbackToRestart: true,
+			},
+			Rollback: RollbackConfig{
+				StableAfter: time.Minute,
+				MaxCrashes:  3,
+			},
+		},
+		Disk: DiskGuardConfig{
+			CheckInterval:   time.Minute,
+			WarningPercent:  85,
+			CriticalPercent: 95,
+			LogMaxSize:      50,
+			LogMaxBackups:   3,
 		},
 		Monitor: MonitorConfig{
 			SystemMetricsInterval:   30 * time.Second,
@@ -124,10 +244,18 @@ func DefaultAgentConfig() *AgentConfig {
 			EnableConnectionStats:   true,
 			LocalCacheSize:          1000,
 			LocalCacheFlushInterval: time.Minute,
+			SpoolPath:               "/var/lib/sing-box-agent/spool.db",
+			SpoolMaxReports:         10000,
 			MaxRetries:              3,
 			RetryBackoff:            5 * time.Second,
 			RetryTimeout:            30 * time.Second,
 		},
+		GeoData: GeoDataSyncConfig{
+			Enabled:         false,
+			Dir:             "/var/lib/sing-box",
+			SyncInterval:    6 * time.Hour,
+			RestartOnUpdate: true,
+		},
 		Log: LogConfig{
 			Level:      "info",
 			Format:     "json",
@@ -143,11 +271,8 @@ func DefaultAgentConfig() *AgentConfig {
 			Port:    9092,
 			Path:    "/metrics",
 		},
-		SkyWalking: SkyWalkingConfig{
-			Enabled:     false,
-			Collector:   "localhost:11800",
-			ServiceName: "sing-box-agent",
-			SampleRate:  1,
-		},
+		SkyWalking: DefaultSkyWalkingConfig("sing-box-agent"),
+		HealthEndpoints: DefaultHealthEndpointConfig(8083),
+		Shutdown:        DefaultShutdownConfig(),
 	}
 }
@@ -13,6 +13,9 @@ type APIConfig struct {
 	// Database configuration
 	Database DatabaseConfig `yaml:"database" json:"database"`
 
+	// Analytics storage for traffic records
+	Analytics AnalyticsConfig `yaml:"analytics" json:"analytics"`
+
 	// Logging configuration
 	Log LogConfig `yaml:"log" json:"log"`
 
@@ -24,6 +27,51 @@ type APIConfig struct {
 
 	// Business configuration
 	Business BusinessConfig `yaml:"business" json:"business"`
+
+	// High availability configuration
+	HA HAConfig `yaml:"ha" json:"ha"`
+
+	// Outgoing mail configuration
+	Mail MailConfig `yaml:"mail" json:"mail"`
+
+	// Event bus the services publish their domain events to
+	Events EventBusConfig `yaml:"events" json:"events"`
+
+	// Graceful shutdown configuration
+	Shutdown ShutdownConfig `yaml:"shutdown" json:"shutdown"`
+
+	// Health and readiness endpoints
+	HealthEndpoints HealthEndpointConfig `yaml:"healthEndpoints" json:"healthEndpoints"`
+
+	// Anonymous usage telemetry, off unless enabled
+	Telemetry TelemetryConfig `yaml:"telemetry" json:"telemetry"`
+}
+
+// TelemetryConfig defines the opt-in reporting of anonymous deployment
+// statistics: the version, the database driver and the bucket of the node
+// count. No user, node or traffic data is ever included; run
+// "sing-box-api telemetry preview" to print the exact payload.
+type TelemetryConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Endpoint receives the payload as a JSON POST
+	Endpoint string        `yaml:"endpoint" json:"endpoint"`
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// HAConfig defines warm standby configuration. Instances sharing a database
+// compete for a lease; only the lease holder serves agents and runs background
+// jobs, the others stay connected and take over when the lease expires.
+type HAConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// InstanceID identifies this instance in the lease, defaults to the hostname
+	InstanceID    string        `yaml:"instanceId" json:"instanceId"`
+	LeaseName     string        `yaml:"leaseName" json:"leaseName"`
+	LeaseDuration time.Duration `yaml:"leaseDuration" json:"leaseDuration"`
+	RenewInterval time.Duration `yaml:"renewInterval" json:"renewInterval"`
+	// WebhookURL receives a JSON POST on every failover event
+	WebhookURL     string        `yaml:"webhookUrl" json:"webhookUrl"`
+	WebhookTimeout time.Duration `yaml:"webhookTimeout" json:"webhookTimeout"`
 }
 
 // GRPCServerConfig defines gRPC server configuration
@@ -39,6 +87,51 @@ type GRPCServerConfig struct {
 	CertFile          string        `yaml:"certFile" json:"certFile"`
 	KeyFile           string        `yaml:"keyFile" json:"keyFile"`
 	ClientCAs         string        `yaml:"clientCAs" json:"clientCAs"`
+	// RequireNodeToken rejects AgentService calls without a valid node registration token
+	RequireNodeToken bool `yaml:"requireNodeToken" json:"requireNodeToken"`
+	// ManagementToken, when set, is required as the bearer token of
+	// ManagementService calls. Web servers send it as apiServer.authToken.
+	ManagementToken string `yaml:"managementToken" json:"managementToken"`
+
+	// Interceptors wrapping every call
+	Interceptors GRPCInterceptorConfig `yaml:"interceptors" json:"interceptors"`
+}
+
+// GRPCInterceptorConfig defines the interceptors of the gRPC server. Panics
+// are always recovered and calls always counted in the gRPC request metrics.
+type GRPCInterceptorConfig struct {
+	// LogRequests logs every call with its request ID at debug level, and
+	// failed calls at warn level
+	LogRequests bool `yaml:"logRequests" json:"logRequests"`
+	// SlowCallThreshold logs calls taking longer at warn level, 0 disables
+	SlowCallThreshold time.Duration `yaml:"slowCallThreshold" json:"slowCallThreshold"`
+	// Rate limiting of the calls per method and caller
+	RateLimit GRPCRateLimitConfig `yaml:"rateLimit" json:"rateLimit"`
+}
+
+// GRPCRateLimitConfig limits the calls of each caller, by peer address, to
+// a method within a window, in bursts of up to the limit. Limits are kept in
+// memory per API instance.
+type GRPCRateLimitConfig struct {
+	Enabled bool          `yaml:"enabled" json:"enabled"`
+	Window  time.Duration `yaml:"window" json:"window"`
+	// Requests is the limit of methods not listed in Methods, 0 for unlimited
+	Requests int `yaml:"requests" json:"requests"`
+	// Methods sets the limit of methods by name ("Heartbeat") or full name
+	// ("/api.v1.AgentService/Heartbeat"), 0 for unlimited
+	Methods map[string]int `yaml:"methods" json:"methods"`
+}
+
+// AnalyticsConfig defines the optional analytics storage for traffic records.
+// When enabled, traffic records are also written to the analytics backend and
+// the summary queries (sums, hourly/daily series and top-N) are served from it.
+type AnalyticsConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Driver is either "clickhouse" or "timescaledb"
+	Driver       string        `yaml:"driver" json:"driver"`
+	DSN          string        `yaml:"dsn" json:"dsn"`
+	MaxOpenConns int           `yaml:"maxOpenConns" json:"maxOpenConns"`
+	QueryTimeout time.Duration `yaml:"queryTimeout" json:"queryTimeout"`
 }
 
 // BusinessConfig defines business logic configuration
@@ -54,6 +147,48 @@ type BusinessConfig struct {
 
 	// Alert configuration
 	Alert AlertConfig `yaml:"alert" json:"alert"`
+
+	// Node metrics history
+	Metrics MetricsHistoryConfig `yaml:"metrics" json:"metrics"`
+
+	// Geo database distribution to nodes
+	GeoData GeoDataConfig `yaml:"geoData" json:"geoData"`
+
+	// Registration blocklist
+	Blocklist BlocklistConfig `yaml:"blocklist" json:"blocklist"`
+
+	// Inactive account policy
+	Inactivity InactivityConfig `yaml:"inactivity" json:"inactivity"`
+
+	// Trials of new users
+	Trial TrialConfig `yaml:"trial" json:"trial"`
+
+	// Expiry of accounts at the end of their plan
+	Expiry ExpiryConfig `yaml:"expiry" json:"expiry"`
+
+	// Cross-checking of node reports
+	Witness WitnessConfig `yaml:"witness" json:"witness"`
+
+	// Node health scoring
+	Health HealthConfig `yaml:"health" json:"health"`
+
+	// Failover of users off offline nodes
+	Failover FailoverConfig `yaml:"failover" json:"failover"`
+
+	// Gradual migrations of users between plans or nodes
+	UserMigration UserMigrationConfig `yaml:"userMigration" json:"userMigration"`
+
+	// Rules admins define to act on domain events
+	Automation AutomationConfig `yaml:"automation" json:"automation"`
+
+	// Verification of invariants spanning several tables
+	Integrity IntegrityConfig `yaml:"integrity" json:"integrity"`
+
+	// Monthly traffic reports mailed to admins
+	TrafficReport TrafficReportConfig `yaml:"trafficReport" json:"trafficReport"`
+
+	// Detection of subscription links shared between many clients
+	SubscriptionSharing SubscriptionSharingConfig `yaml:"subscriptionSharing" json:"subscriptionSharing"`
 }
 
 // TrafficConfig defines traffic management configuration
@@ -62,8 +197,13 @@ type TrafficConfig struct {
 	BatchSize         int           `yaml:"batchSize" json:"batchSize"`
 	RetentionDays     int           `yaml:"retentionDays" json:"retentionDays"`
 	EnableCompression bool          `yaml:"enableCompression" json:"enableCompression"`
+	// EnableAggregation adds new traffic records to the daily and monthly
+	// summaries every AggregationWindow, and checks the previous day's
+	// summaries against the records once a day
 	EnableAggregation bool          `yaml:"enableAggregation" json:"enableAggregation"`
 	AggregationWindow time.Duration `yaml:"aggregationWindow" json:"aggregationWindow"`
+	// MaxBufferedRecords bounds the ingestion buffer; reports are rejected while it is full
+	MaxBufferedRecords int `yaml:"maxBufferedRecords" json:"maxBufferedRecords"`
 }
 
 // NodeConfig defines node management configuration
@@ -74,6 +214,36 @@ type NodeConfig struct {
 	ConfigSyncInterval time.Duration `yaml:"configSyncInterval" json:"configSyncInterval"`
 	MaxRetries         int           `yaml:"maxRetries" json:"maxRetries"`
 	RetryBackoff       time.Duration `yaml:"retryBackoff" json:"retryBackoff"`
+	// AutoRenameOnConflict registers a node under "<name>-N" instead of rejecting
+	// it when the name is taken, including by a deleted node
+	AutoRenameOnConflict bool `yaml:"autoRenameOnConflict" json:"autoRenameOnConflict"`
+	// CommandTimeout is how long a command queued to a node may go without a
+	// result before it is marked timed out
+	CommandTimeout time.Duration `yaml:"commandTimeout" json:"commandTimeout"`
+	// Enrollment configures the one-time tokens agents enroll new nodes with
+	Enrollment NodeEnrollmentConfig `yaml:"enrollment" json:"enrollment"`
+}
+
+// NodeEnrollmentConfig defines node enrollment tokens and the one-line install
+// command returned with them
+type NodeEnrollmentConfig struct {
+	// TokenTTL is how long an enrollment token stays valid when the admin
+	// creating it does not choose
+	TokenTTL time.Duration `yaml:"tokenTTL" json:"tokenTTL"`
+	// AgentAPIAddress is the host:port agents reach the gRPC server at, used
+	// in install commands unless the admin gives another
+	AgentAPIAddress string `yaml:"agentAPIAddress" json:"agentAPIAddress"`
+	// InstallScriptURL is where install commands download the agent install
+	// script from
+	InstallScriptURL string `yaml:"installScriptURL" json:"installScriptURL"`
+}
+
+// DefaultNodeEnrollmentConfig returns the default node enrollment configuration
+func DefaultNodeEnrollmentConfig() NodeEnrollmentConfig {
+	return NodeEnrollmentConfig{
+		TokenTTL:         24 * time.Hour,
+		InstallScriptURL: "https://raw.githubusercontent.com/HappyLadySauce/sing-box-web/main/scripts/install-agent.sh",
+	}
 }
 
 // UserConfig defines user management configuration
@@ -85,15 +255,233 @@ type UserConfig struct {
 	UserLimitCheckInterval time.Duration `yaml:"userLimitCheckInterval" json:"userLimitCheckInterval"`
 }
 
+// MetricsHistoryConfig defines node metrics history storage and downsampling.
+// Minute samples are folded into hourly buckets after MinuteRetention and
+// hourly buckets into daily ones after HourlyRetention.
+type MetricsHistoryConfig struct {
+	Enabled            bool          `yaml:"enabled" json:"enabled"`
+	DownsampleInterval time.Duration `yaml:"downsampleInterval" json:"downsampleInterval"`
+	MinuteRetention    time.Duration `yaml:"minuteRetention" json:"minuteRetention"`
+	HourlyRetention    time.Duration `yaml:"hourlyRetention" json:"hourlyRetention"`
+	DailyRetention     time.Duration `yaml:"dailyRetention" json:"dailyRetention"`
+}
+
+// GeoDataConfig defines the geo databases the API server downloads, caches
+// and distributes to nodes. Nodes still running an older version StaleAfter
+// after a new one was fetched are reported as stale.
+type GeoDataConfig struct {
+	Enabled         bool            `yaml:"enabled" json:"enabled"`
+	CacheDir        string          `yaml:"cacheDir" json:"cacheDir"`
+	RefreshInterval time.Duration   `yaml:"refreshInterval" json:"refreshInterval"`
+	DownloadTimeout time.Duration   `yaml:"downloadTimeout" json:"downloadTimeout"`
+	StaleAfter      time.Duration   `yaml:"staleAfter" json:"staleAfter"`
+	Sources         []GeoDataSource `yaml:"sources" json:"sources"`
+}
+
+// GeoDataSource defines where a geo database is downloaded from
+type GeoDataSource struct {
+	// Name is the file name used on the nodes, e.g. geoip.db
+	Name string `yaml:"name" json:"name"`
+	URL  string `yaml:"url" json:"url"`
+	// ChecksumURL points to a sha256sum file verifying the download, optional
+	ChecksumURL string `yaml:"checksumUrl" json:"checksumUrl"`
+}
+
+// BlocklistConfig defines the public disposable email domain lists merged
+// into the registration blocklist. Domains that drop out of a list are
+// removed on the next refresh; entries added through the API are kept.
+type BlocklistConfig struct {
+	// DisposableDomainLists are URLs of plain text lists, one domain per line
+	DisposableDomainLists []string      `yaml:"disposableDomainLists" json:"disposableDomainLists"`
+	RefreshInterval       time.Duration `yaml:"refreshInterval" json:"refreshInterval"`
+	DownloadTimeout       time.Duration `yaml:"downloadTimeout" json:"downloadTimeout"`
+	// MaxListSize rejects lists with more domains, guarding against a broken download
+	MaxListSize int `yaml:"maxListSize" json:"maxListSize"`
+}
+
+// InactivityConfig defines the policy for accounts without logins or
+// traffic. Users are warned WarningBefore they are suspended, and accounts
+// suspended by the policy are deleted once idle for PurgeAfter. Logging in
+// reactivates an account the policy suspended. Admins and exempt users are
+// never affected.
+type InactivityConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// FreeOnly limits the policy to users of free plans
+	FreeOnly      bool          `yaml:"freeOnly" json:"freeOnly"`
+	SuspendAfter  time.Duration `yaml:"suspendAfter" json:"suspendAfter"`
+	WarningBefore time.Duration `yaml:"warningBefore" json:"warningBefore"`
+	// PurgeAfter is the idle time after which suspended accounts are deleted, 0 keeps them
+	PurgeAfter    time.Duration `yaml:"purgeAfter" json:"purgeAfter"`
+	CheckInterval time.Duration `yaml:"checkInterval" json:"checkInterval"`
+}
+
+// TrialConfig defines the trial of self-registered users. Users signing up
+// start on PlanID, a trial plan, for its trial days. They are warned
+// WarningBefore the trial ends, and at its end move to DowngradePlanID or,
+// when it is 0, expire. Each user gets one trial, whether started on signup
+// or ordered.
+type TrialConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// PlanID is the trial plan of new users, 0 only ends the trials ordered
+	PlanID          uint          `yaml:"planID" json:"planID"`
+	DowngradePlanID uint          `yaml:"downgradePlanID" json:"downgradePlanID"`
+	WarningBefore   time.Duration `yaml:"warningBefore" json:"warningBefore"`
+	CheckInterval   time.Duration `yaml:"checkInterval" json:"checkInterval"`
+}
+
+// ExpiryConfig defines the expiry of accounts. Active users whose plan ran
+// out are expired and removed from their nodes, and restored to them once
+// renewed. Users are warned at each of the WarningOffsets before their
+// account expires, replacing the plan expiry warning of alerts.
+type ExpiryConfig struct {
+	Enabled        bool            `yaml:"enabled" json:"enabled"`
+	WarningOffsets []time.Duration `yaml:"warningOffsets" json:"warningOffsets"`
+	CheckInterval  time.Duration   `yaml:"checkInterval" json:"checkInterval"`
+}
+
+// WitnessConfig defines the cross-checking of what agents report. Every
+// Window, the bytes that went through each node's network interfaces, per
+// its metrics counters, are compared with the user traffic it reported, and
+// that traffic with the probes of the node. Nodes whose reports diverge are
+// flagged and the admins of nodes alerted.
+type WitnessConfig struct {
+	Enabled bool          `yaml:"enabled" json:"enabled"`
+	Window  time.Duration `yaml:"window" json:"window"`
+	// MinBytes is the traffic below which a window is not compared
+	MinBytes int64 `yaml:"minBytes" json:"minBytes"`
+	// MinInterfaceRatio and MaxInterfaceRatio bound the interface traffic per
+	// byte of user traffic; a proxy relaying each byte has about 2
+	MinInterfaceRatio float64 `yaml:"minInterfaceRatio" json:"minInterfaceRatio"`
+	MaxInterfaceRatio float64 `yaml:"maxInterfaceRatio" json:"maxInterfaceRatio"`
+	// MinProbeSamples and MinProbeAvailability flag nodes that served
+	// traffic while most probes failed; probes run on the web server
+	MinProbeSamples      int     `yaml:"minProbeSamples" json:"minProbeSamples"`
+	MinProbeAvailability float64 `yaml:"minProbeAvailability" json:"minProbeAvailability"`
+}
+
+// HealthConfig defines the health checks of nodes. Every CheckInterval each
+// online node is scored from 0 to 100 on heartbeat recency, load, the error
+// rate of its heartbeats since the previous check and the packet loss of its
+// probes over ProbeWindow. Nodes scoring below DegradedBelow are marked degraded, below
+// MaintenanceBelow taken into maintenance, and they recover once scoring
+// RecoverAbove. Maintenance not set by the health checks is kept.
+type HealthConfig struct {
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	CheckInterval time.Duration `yaml:"checkInterval" json:"checkInterval"`
+	// LoadThreshold is the CPU or memory usage percentage above which load lowers the score
+	LoadThreshold float64 `yaml:"loadThreshold" json:"loadThreshold"`
+	// MinProbeSamples probes over ProbeWindow are needed to score packet loss
+	ProbeWindow     time.Duration `yaml:"probeWindow" json:"probeWindow"`
+	MinProbeSamples int           `yaml:"minProbeSamples" json:"minProbeSamples"`
+
+	HeartbeatWeight  float64 `yaml:"heartbeatWeight" json:"heartbeatWeight"`
+	LoadWeight       float64 `yaml:"loadWeight" json:"loadWeight"`
+	ErrorWeight      float64 `yaml:"errorWeight" json:"errorWeight"`
+	PacketLossWeight float64 `yaml:"packetLossWeight" json:"packetLossWeight"`
+
+	DegradedBelow    float64 `yaml:"degradedBelow" json:"degradedBelow"`
+	MaintenanceBelow float64 `yaml:"maintenanceBelow" json:"maintenanceBelow"`
+	RecoverAbove     float64 `yaml:"recoverAbove" json:"recoverAbove"`
+}
+
+// FailoverConfig defines the failover of users off offline nodes. Every
+// CheckInterval the users assigned to a node offline for longer than
+// node.maxOfflineTime are moved to another node of its group, or of its region
+// when it is in no group, that their plan grants and that has room. They are
+// moved back once the node has been connected again for RevertAfter.
+type FailoverConfig struct {
+	Enabled       bool          `yaml:"enabled" json:"enabled"`
+	CheckInterval time.Duration `yaml:"checkInterval" json:"checkInterval"`
+	RevertAfter   time.Duration `yaml:"revertAfter" json:"revertAfter"`
+}
+
+// UserMigrationConfig defines the user migrations admins start to move the
+// users of a plan or node to another. Every CheckInterval the users due at
+// each migration's rate are moved; shorter intervals spread the provisioning
+// commands more evenly. Migrations may move at most MaxUsersPerMinute users a
+// minute. A zero CheckInterval leaves migrations paused where they are.
+type UserMigrationConfig struct {
+	CheckInterval     time.Duration `yaml:"checkInterval" json:"checkInterval"`
+	MaxUsersPerMinute int           `yaml:"maxUsersPerMinute" json:"maxUsersPerMinute"`
+}
+
+// AutomationConfig defines the automation rules admins define to act on
+// domain events, such as suspending a user or notifying admins. When enabled
+// the active API server evaluates the rules as events are published. The
+// executions of matched rules are kept for ExecutionRetention, zero keeps
+// them forever.
+type AutomationConfig struct {
+	Enabled            bool          `yaml:"enabled" json:"enabled"`
+	ExecutionRetention time.Duration `yaml:"executionRetention" json:"executionRetention"`
+}
+
+// IntegrityConfig defines the integrity checker. Every CheckInterval it
+// verifies that plans and nodes count their users and assignments, that users
+// reference existing plans and that the daily traffic summaries of the last
+// TrafficDays days are within TrafficTolerance, a fraction, of the records.
+// Issues are logged, and with Repair the counts are recomputed and the
+// summaries rebuilt; users of missing plans are only reported.
+type IntegrityConfig struct {
+	Enabled          bool          `yaml:"enabled" json:"enabled"`
+	CheckInterval    time.Duration `yaml:"checkInterval" json:"checkInterval"`
+	Repair           bool          `yaml:"repair" json:"repair"`
+	TrafficDays      int           `yaml:"trafficDays" json:"trafficDays"`
+	TrafficTolerance float64       `yaml:"trafficTolerance" json:"trafficTolerance"`
+}
+
+// TrafficReportConfig defines the monthly traffic reports. When enabled the
+// active API server mails the traffic of every user and every node in the
+// previous month, added up from the daily summaries, to Recipients or, when
+// there are none, to the active admins allowed to manage nodes, early on the
+// first day of each month. The reports are attached as Format files, csv or
+// xlsx. Requires mail and the traffic aggregation to be enabled.
+type TrafficReportConfig struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled"`
+	Format     string   `yaml:"format" json:"format"`
+	Recipients []string `yaml:"recipients" json:"recipients"`
+}
+
+// SubscriptionSharingConfig defines the detection of shared subscription
+// links from the fetches the web server records. Every CheckInterval the
+// active API server alerts the active admins allowed to manage users about
+// each user whose subscription was fetched from more than MaxDistinctIPs
+// addresses over the last Window, once per user and window.
+type SubscriptionSharingConfig struct {
+	Enabled        bool          `yaml:"enabled" json:"enabled"`
+	Window         time.Duration `yaml:"window" json:"window"`
+	MaxDistinctIPs int           `yaml:"maxDistinctIps" json:"maxDistinctIps"`
+	CheckInterval  time.Duration `yaml:"checkInterval" json:"checkInterval"`
+}
+
 // AlertConfig defines alert configuration
 type AlertConfig struct {
 	Enabled           bool          `yaml:"enabled" json:"enabled"`
-	SMTPHost          string        `yaml:"smtpHost" json:"smtpHost"`
-	SMTPPort          int           `yaml:"smtpPort" json:"smtpPort"`
-	SMTPUser          string        `yaml:"smtpUser" json:"smtpUser"`
-	SMTPPassword      string        `yaml:"smtpPassword" json:"smtpPassword"`
 	DefaultRecipients []string      `yaml:"defaultRecipients" json:"defaultRecipients"`
 	AlertCooldown     time.Duration `yaml:"alertCooldown" json:"alertCooldown"`
+
+	// InAppNotifications delivers user alerts to the in-app notification center
+	InAppNotifications bool `yaml:"inAppNotifications" json:"inAppNotifications"`
+	// EmailNotifications also mails user alerts, requires mail to be enabled
+	EmailNotifications bool `yaml:"emailNotifications" json:"emailNotifications"`
+	// TelegramNotifications also sends user alerts to linked Telegram chats
+	TelegramNotifications bool           `yaml:"telegramNotifications" json:"telegramNotifications"`
+	Telegram              TelegramConfig `yaml:"telegram" json:"telegram"`
+	// QuotaWarningThresholds are the ascending percentages of the traffic
+	// quota used that raise a quota warning, each once per quota period.
+	// Plans can set their own.
+	QuotaWarningThresholds []int `yaml:"quotaWarningThresholds" json:"quotaWarningThresholds"`
+	// PlanExpiryWarning raises a plan expiring alert that long before an account expires
+	PlanExpiryWarning time.Duration `yaml:"planExpiryWarning" json:"planExpiryWarning"`
+	// CheckInterval is the interval between scans for expiring accounts
+	CheckInterval time.Duration `yaml:"checkInterval" json:"checkInterval"`
+}
+
+// TelegramConfig defines the Telegram bot that sends user alerts
+type TelegramConfig struct {
+	BotToken string `yaml:"botToken" json:"botToken"`
+	// APIURL is the Bot API endpoint, changed for a self-hosted Bot API server
+	APIURL  string        `yaml:"apiUrl" json:"apiUrl"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
 }
 
 // DefaultAPIConfig returns default API configuration
@@ -110,6 +498,15 @@ func DefaultAPIConfig() *APIConfig {
 			KeepaliveTime:     30 * time.Second,
 			KeepaliveTimeout:  5 * time.Second,
 			TLSEnabled:        false,
+			RequireNodeToken:  true,
+			Interceptors: GRPCInterceptorConfig{
+				SlowCallThreshold: 5 * time.Second,
+				RateLimit: GRPCRateLimitConfig{
+					Enabled:  false,
+					Window:   time.Minute,
+					Requests: 600,
+				},
+			},
 		},
 		Database: DatabaseConfig{
 			Driver:       "mysql",
@@ -121,6 +518,32 @@ func DefaultAPIConfig() *APIConfig {
 			MaxIdleConns: 10,
 			MaxOpenConns: 100,
 			MaxLifetime:  time.Hour,
+
+			SlowQueryThreshold: 200 * time.Millisecond,
+			StatsInterval:      15 * time.Second,
+			AutoMigrate:        true,
+		},
+		HA: HAConfig{
+			Enabled:        false,
+			LeaseName:      "sing-box-api",
+			LeaseDuration:  15 * time.Second,
+			RenewInterval:  5 * time.Second,
+			WebhookTimeout: 5 * time.Second,
+		},
+		Mail:            DefaultMailConfig(),
+		Events:          DefaultEventBusConfig(),
+		Shutdown:        DefaultShutdownConfig(),
+		HealthEndpoints: DefaultHealthEndpointConfig(8082),
+		Telemetry: TelemetryConfig{
+			Enabled:  false,
+			Interval: 24 * time.Hour,
+			Timeout:  10 * time.Second,
+		},
+		Analytics: AnalyticsConfig{
+			Enabled:      false,
+			Driver:       "clickhouse",
+			MaxOpenConns: 10,
+			QueryTimeout: 30 * time.Second,
 		},
 		Log: LogConfig{
 			Level:      "info",
@@ -136,21 +559,22 @@ func DefaultAPIConfig() *APIConfig {
 			Address: "0.0.0.0",
 			Port:    9091,
 			Path:    "/metrics",
+
+			MaxUserSeries:      10000,
+			MaxNodeSeries:      1000,
+			SeriesSyncInterval: 5 * time.Minute,
+			RefreshInterval:    time.Minute,
 		},
-		SkyWalking: SkyWalkingConfig{
-			Enabled:     false,
-			Collector:   "localhost:11800",
-			ServiceName: "sing-box-api",
-			SampleRate:  1,
-		},
+		SkyWalking: DefaultSkyWalkingConfig("sing-box-api"),
 		Business: BusinessConfig{
 			Traffic: TrafficConfig{
-				ReportInterval:    5 * time.Minute,
-				BatchSize:         1000,
-				RetentionDays:     90,
-				EnableCompression: true,
-				EnableAggregation: true,
-				AggregationWindow: time.Hour,
+				ReportInterval:     5 * time.Minute,
+				BatchSize:          1000,
+				RetentionDays:      90,
+				EnableCompression:  true,
+				EnableAggregation:  true,
+				AggregationWindow:  time.Minute,
+				MaxBufferedRecords: 20000,
 			},
 			Node: NodeConfig{
 				HeartbeatInterval:  30 * time.Second,
@@ -159,6 +583,8 @@ func DefaultAPIConfig() *APIConfig {
 				ConfigSyncInterval: 10 * time.Minute,
 				MaxRetries:         3,
 				RetryBackoff:       5 * time.Second,
+				CommandTimeout:     10 * time.Minute,
+				Enrollment:         DefaultNodeEnrollmentConfig(),
 			},
 			User: UserConfig{
 				MaxUsersPerNode:        1000,
@@ -169,9 +595,118 @@ func DefaultAPIConfig() *APIConfig {
 			},
 			Alert: AlertConfig{
 				Enabled:       false,
-				SMTPHost:      "localhost",
-				SMTPPort:      587,
 				AlertCooldown: 15 * time.Minute,
+
+				InAppNotifications: true,
+				Telegram: TelegramConfig{
+					APIURL:  "https://api.telegram.org",
+					Timeout: 10 * time.Second,
+				},
+				QuotaWarningThresholds: []int{50, 80, 95},
+				PlanExpiryWarning:      72 * time.Hour,
+				CheckInterval:          time.Hour,
+			},
+			Metrics: MetricsHistoryConfig{
+				Enabled:            true,
+				DownsampleInterval: time.Hour,
+				MinuteRetention:    48 * time.Hour,
+				HourlyRetention:    30 * 24 * time.Hour,
+				DailyRetention:     365 * 24 * time.Hour,
+			},
+			GeoData: GeoDataConfig{
+				Enabled:         false,
+				CacheDir:        "/var/lib/sing-box-api/geodata",
+				RefreshInterval: 24 * time.Hour,
+				DownloadTimeout: 5 * time.Minute,
+				StaleAfter:      48 * time.Hour,
+				Sources: []GeoDataSource{
+					{
+						Name:        "geoip.db",
+						URL:         "https://github.com/SagerNet/sing-geoip/releases/latest/download/geoip.db",
+						ChecksumURL: "https://github.com/SagerNet/sing-geoip/releases/latest/download/geoip.db.sha256sum",
+					},
+					{
+						Name:        "geosite.db",
+						URL:         "https://github.com/SagerNet/sing-geosite/releases/latest/download/geosite.db",
+						ChecksumURL: "https://github.com/SagerNet/sing-geosite/releases/latest/download/geosite.db.sha256sum",
+					},
+				},
+			},
+			Blocklist: BlocklistConfig{
+				RefreshInterval: 24 * time.Hour,
+				DownloadTimeout: 2 * time.Minute,
+				MaxListSize:     200000,
+			},
+			Inactivity: InactivityConfig{
+				Enabled:       false,
+				FreeOnly:      true,
+				SuspendAfter:  90 * 24 * time.Hour,
+				WarningBefore: 7 * 24 * time.Hour,
+				PurgeAfter:    180 * 24 * time.Hour,
+				CheckInterval: time.Hour,
+			},
+			Trial: TrialConfig{
+				Enabled:       false,
+				WarningBefore: 24 * time.Hour,
+				CheckInterval: 10 * time.Minute,
+			},
+			Expiry: ExpiryConfig{
+				Enabled:        false,
+				WarningOffsets: []time.Duration{7 * 24 * time.Hour, 3 * 24 * time.Hour, 24 * time.Hour},
+				CheckInterval:  5 * time.Minute,
+			},
+			Witness: WitnessConfig{
+				Enabled:              false,
+				Window:               time.Hour,
+				MinBytes:             1 << 30,
+				MinInterfaceRatio:    1,
+				MaxInterfaceRatio:    4,
+				MinProbeSamples:      10,
+				MinProbeAvailability: 0.5,
+			},
+			Health: HealthConfig{
+				Enabled:          false,
+				CheckInterval:    time.Minute,
+				LoadThreshold:    80,
+				ProbeWindow:      15 * time.Minute,
+				MinProbeSamples:  5,
+				HeartbeatWeight:  0.4,
+				LoadWeight:       0.2,
+				ErrorWeight:      0.2,
+				PacketLossWeight: 0.2,
+				DegradedBelow:    60,
+				MaintenanceBelow: 30,
+				RecoverAbove:     75,
+			},
+			Failover: FailoverConfig{
+				Enabled:       false,
+				CheckInterval: time.Minute,
+				RevertAfter:   10 * time.Minute,
+			},
+			UserMigration: UserMigrationConfig{
+				CheckInterval:     10 * time.Second,
+				MaxUsersPerMinute: 600,
+			},
+			Automation: AutomationConfig{
+				Enabled:            true,
+				ExecutionRetention: 30 * 24 * time.Hour,
+			},
+			Integrity: IntegrityConfig{
+				Enabled:          false,
+				CheckInterval:    24 * time.Hour,
+				Repair:           false,
+				TrafficDays:      7,
+				TrafficTolerance: 0.01,
+			},
+			TrafficReport: TrafficReportConfig{
+				Enabled: false,
+				Format:  "csv",
+			},
+			SubscriptionSharing: SubscriptionSharingConfig{
+				Enabled:        false,
+				Window:         24 * time.Hour,
+				MaxDistinctIPs: 10,
+				CheckInterval:  time.Hour,
 			},
 		},
 	}
@@ -13,6 +13,30 @@ type DatabaseConfig struct {
 	MaxIdleConns int           `yaml:"maxIdleConns" json:"maxIdleConns"`
 	MaxOpenConns int           `yaml:"maxOpenConns" json:"maxOpenConns"`
 	MaxLifetime  time.Duration `yaml:"maxLifetime" json:"maxLifetime"`
+	// SSLMode is the sslmode of PostgreSQL connections, disable by default
+	SSLMode string `yaml:"sslMode,omitempty" json:"sslMode,omitempty"`
+
+	// SlowQueryThreshold logs the queries taking longer, 0 disables the log.
+	// Applies to the tenant databases as well.
+	SlowQueryThreshold time.Duration `yaml:"slowQueryThreshold,omitempty" json:"slowQueryThreshold,omitempty"`
+	// StatsInterval is the period of the connection pool metrics
+	StatsInterval time.Duration `yaml:"statsInterval,omitempty" json:"statsInterval,omitempty"`
+
+	// AutoMigrate applies the pending schema migrations at startup. When
+	// disabled, servers refuse to start until the migrate command ran.
+	AutoMigrate bool `yaml:"autoMigrate" json:"autoMigrate"`
+
+	// Tenants places the traffic data of large tenants in databases of
+	// their own, each with its own connection pool
+	Tenants []TenantDatabaseConfig `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+}
+
+// TenantDatabaseConfig defines the database holding the traffic records and
+// summaries of a tenant's users. Accounts, nodes and billing stay in the
+// shared database.
+type TenantDatabaseConfig struct {
+	TenantID       uint `yaml:"tenantId" json:"tenantId"`
+	DatabaseConfig `yaml:",inline"`
 }
 
 // LogConfig defines logging configuration
@@ -35,6 +59,188 @@ type APIServerConnection struct {
 	CertFile string        `yaml:"certFile" json:"certFile"`
 	KeyFile  string        `yaml:"keyFile" json:"keyFile"`
 	CAFile   string        `yaml:"caFile" json:"caFile"`
+	// PinnedPublicKeys are "sha256/<base64>" hashes of the SubjectPublicKeyInfo
+	// of the API server certificate or one of its CAs. When set, the server
+	// must present a chain containing one of them in addition to passing CA
+	// verification. List the old and new key while rotating.
+	PinnedPublicKeys []string `yaml:"pinnedPublicKeys" json:"pinnedPublicKeys"`
+	// AuthToken is sent as a bearer token on every call (agent registration
+	// token, or the API server's management token for the web server)
+	AuthToken string `yaml:"authToken" json:"authToken"`
+	// FailoverAddresses are standby API servers ("host:port") tried in order
+	// when the current server is unreachable or is not the active instance
+	FailoverAddresses []string `yaml:"failoverAddresses" json:"failoverAddresses"`
+}
+
+// MailConfig defines outgoing mail. Mails are queued and sent in the
+// background, failed deliveries are retried MaxRetries times.
+type MailConfig struct {
+	Enabled      bool   `yaml:"enabled" json:"enabled"`
+	SMTPHost     string `yaml:"smtpHost" json:"smtpHost"`
+	SMTPPort     int    `yaml:"smtpPort" json:"smtpPort"`
+	SMTPUser     string `yaml:"smtpUser" json:"smtpUser"`
+	SMTPPassword string `yaml:"smtpPassword" json:"smtpPassword"`
+	// Encryption is "starttls", "tls" (implicit TLS, usually port 465) or "none"
+	Encryption string `yaml:"encryption" json:"encryption"`
+	// From is the sender address, shown with the panel name of the recipient's brand
+	From string `yaml:"from" json:"from"`
+	// BaseURL is the public URL of the panel used for links in mails
+	BaseURL string `yaml:"baseUrl" json:"baseUrl"`
+	// LinkSecret signs the password reset and email verification links.
+	// The API and web servers must share it.
+	LinkSecret string `yaml:"linkSecret" json:"linkSecret"`
+	// PasswordResetTTL and VerificationTTL are how long these links stay valid
+	PasswordResetTTL time.Duration `yaml:"passwordResetTtl" json:"passwordResetTtl"`
+	VerificationTTL  time.Duration `yaml:"verificationTtl" json:"verificationTtl"`
+
+	QueueSize    int           `yaml:"queueSize" json:"queueSize"`
+	MaxRetries   int           `yaml:"maxRetries" json:"maxRetries"`
+	RetryBackoff time.Duration `yaml:"retryBackoff" json:"retryBackoff"`
+	SendTimeout  time.Duration `yaml:"sendTimeout" json:"sendTimeout"`
+
+	// Templates turns individual templates (welcome, password_reset,
+	// email_verification, quota_warning, expiry_reminder, traffic_report) on
+	// or off, unlisted ones are enabled
+	Templates map[string]bool `yaml:"templates" json:"templates"`
+}
+
+// DefaultMailConfig returns the default mail configuration, disabled
+func DefaultMailConfig() MailConfig {
+	return MailConfig{
+		Enabled:      false,
+		SMTPHost:     "localhost",
+		SMTPPort:     587,
+		Encryption:   "starttls",
+		QueueSize:    1000,
+		MaxRetries:   3,
+		RetryBackoff: 30 * time.Second,
+		SendTimeout:  30 * time.Second,
+
+		PasswordResetTTL: time.Hour,
+		VerificationTTL:  48 * time.Hour,
+	}
+}
+
+// EventBusConfig selects the transport of the event bus. The in-memory bus
+// only reaches the process that published; Redis Streams carries the events
+// of every API instance to every web server reading the same stream.
+type EventBusConfig struct {
+	// Backend is "memory" or "redis"
+	Backend string            `yaml:"backend" json:"backend"`
+	Redis   RedisStreamConfig `yaml:"redis" json:"redis"`
+}
+
+// RedisStreamConfig defines the Redis stream events are published to
+type RedisStreamConfig struct {
+	Address  string `yaml:"address" json:"address"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	DB       int    `yaml:"db" json:"db"`
+	Stream   string `yaml:"stream" json:"stream"`
+	// MaxLen approximately caps the stream length, 0 keeps every event
+	MaxLen      int64         `yaml:"maxLen" json:"maxLen"`
+	DialTimeout time.Duration `yaml:"dialTimeout" json:"dialTimeout"`
+}
+
+// Connection returns the Redis connection settings of the stream
+func (c RedisStreamConfig) Connection() RedisConfig {
+	return RedisConfig{
+		Address:     c.Address,
+		Username:    c.Username,
+		Password:    c.Password,
+		DB:          c.DB,
+		DialTimeout: c.DialTimeout,
+	}
+}
+
+// RedisConfig defines a Redis connection
+type RedisConfig struct {
+	Address     string        `yaml:"address" json:"address"`
+	Username    string        `yaml:"username" json:"username"`
+	Password    string        `yaml:"password" json:"password"`
+	DB          int           `yaml:"db" json:"db"`
+	DialTimeout time.Duration `yaml:"dialTimeout" json:"dialTimeout"`
+}
+
+// DefaultEventBusConfig returns the default event bus configuration, in memory
+func DefaultEventBusConfig() EventBusConfig {
+	return EventBusConfig{
+		Backend: "memory",
+		Redis: RedisStreamConfig{
+			Address:     "localhost:6379",
+			Stream:      "sing-box-web:events",
+			MaxLen:      10000,
+			DialTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// RateLimitStoreConfig selects where the rate limit buckets are kept. In
+// memory each instance limits on its own; Redis shares the buckets of every
+// web instance, falling back to memory while Redis cannot be reached.
+type RateLimitStoreConfig struct {
+	// Backend is "memory" or "redis"
+	Backend string      `yaml:"backend" json:"backend"`
+	Redis   RedisConfig `yaml:"redis" json:"redis"`
+	// KeyPrefix prefixes the Redis keys of the buckets
+	KeyPrefix string `yaml:"keyPrefix" json:"keyPrefix"`
+}
+
+// DefaultRateLimitStoreConfig returns the default rate limit store, in memory
+func DefaultRateLimitStoreConfig() RateLimitStoreConfig {
+	return RateLimitStoreConfig{
+		Backend: "memory",
+		Redis: RedisConfig{
+			Address:     "localhost:6379",
+			DialTimeout: 5 * time.Second,
+		},
+		KeyPrefix: "sing-box-web:ratelimit:",
+	}
+}
+
+// ShutdownConfig controls how a server stops on SIGINT or SIGTERM. It first
+// reports not ready, then stops accepting requests and waits for the ones in
+// flight, its background jobs and the database to finish.
+type ShutdownConfig struct {
+	// DrainDelay is how long the server reports not ready while still
+	// serving, for load balancers to take it out of rotation
+	DrainDelay time.Duration `yaml:"drainDelay" json:"drainDelay"`
+	// Timeout bounds the shutdown after the drain delay; requests still
+	// running then are cut off
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// DefaultShutdownConfig returns the default shutdown configuration
+func DefaultShutdownConfig() ShutdownConfig {
+	return ShutdownConfig{
+		DrainDelay: 5 * time.Second,
+		Timeout:    30 * time.Second,
+	}
+}
+
+// HealthEndpointConfig defines the /healthz and /readyz endpoints probed by
+// Kubernetes and load balancers
+type HealthEndpointConfig struct {
+	// Address and Port of the HTTP listener serving the endpoints, port 0
+	// disables it. sing-box-web serves them on its own port instead.
+	Address string `yaml:"address" json:"address"`
+	Port    int    `yaml:"port" json:"port"`
+	// CheckTimeout bounds each dependency check
+	CheckTimeout time.Duration `yaml:"checkTimeout" json:"checkTimeout"`
+	// CheckInterval is how often the status of the gRPC health service is
+	// re-evaluated, where the server has one
+	CheckInterval time.Duration `yaml:"checkInterval" json:"checkInterval"`
+}
+
+// DefaultHealthEndpointConfig returns the default health endpoint configuration
+// listening on port
+func DefaultHealthEndpointConfig(port int) HealthEndpointConfig {
+	return HealthEndpointConfig{
+		Address:       "0.0.0.0",
+		Port:          port,
+		CheckTimeout:  2 * time.Second,
+		CheckInterval: 10 * time.Second,
+	}
 }
 
 // MetricsConfig defines metrics configuration
@@ -43,12 +249,75 @@ type MetricsConfig struct {
 	Address string `yaml:"address" json:"address"`
 	Port    int    `yaml:"port" json:"port"`
 	Path    string `yaml:"path" json:"path"`
+
+	// MaxUserSeries and MaxNodeSeries bound how many users and nodes get
+	// per-user and per-node series; updates for others are dropped. 0 means no limit.
+	MaxUserSeries int `yaml:"maxUserSeries" json:"maxUserSeries"`
+	MaxNodeSeries int `yaml:"maxNodeSeries" json:"maxNodeSeries"`
+	// SeriesSyncInterval is the interval between deletions of the series of
+	// removed or inactive users and removed nodes, 0 disables them
+	SeriesSyncInterval time.Duration `yaml:"seriesSyncInterval" json:"seriesSyncInterval"`
+	// RefreshInterval is the interval between refreshes of the node, user,
+	// quota, traffic and process gauges from the database, 0 disables them
+	RefreshInterval time.Duration `yaml:"refreshInterval" json:"refreshInterval"`
+
+	// Auth restricts who may scrape the metrics endpoint, open to anyone
+	// reaching it when neither credentials nor networks are set
+	Auth MetricsAuthConfig `yaml:"auth" json:"auth"`
 }
 
-// SkyWalkingConfig defines SkyWalking agent configuration
+// MetricsAuthConfig defines the scrapers of the metrics endpoint. A scrape
+// must come from one of AllowedCIDRs, when set, and present one of
+// Credentials, when set. The source is the address of the connection,
+// forwarding headers are ignored.
+type MetricsAuthConfig struct {
+	Credentials []ScrapeCredential `yaml:"credentials" json:"credentials"`
+	// AllowedCIDRs are the networks scrapes are accepted from, as CIDRs or
+	// single addresses
+	AllowedCIDRs []string `yaml:"allowedCidrs" json:"allowedCidrs"`
+}
+
+// ScrapeCredential is the credential of one scraper: a bearer token, set as
+// authorization.credentials of a Prometheus scrape config or as the bearer
+// token of a Grafana data source, or a username and password for basic auth
+type ScrapeCredential struct {
+	// Name identifies the scraper in the logs and in the scrape counters
+	Name     string `yaml:"name" json:"name"`
+	Token    string `yaml:"token" json:"token"`
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+}
+
+// SkyWalkingConfig defines distributed tracing. Spans of HTTP handlers, gRPC
+// calls and database queries are exported with OpenTelemetry over OTLP/gRPC
+// to Collector ("host:port"): a SkyWalking OAP with its OTLP trace receiver
+// enabled, or any OTLP collector.
 type SkyWalkingConfig struct {
 	Enabled     bool   `yaml:"enabled" json:"enabled"`
 	Collector   string `yaml:"collector" json:"collector"`
 	ServiceName string `yaml:"serviceName" json:"serviceName"`
-	SampleRate  int    `yaml:"sampleRate" json:"sampleRate"`
+	// SampleRate is the number of traces started per 3 seconds, like the
+	// SkyWalking agent's sample_n_per_3_secs; 0 samples every trace. Calls
+	// continue the traces of their callers regardless.
+	SampleRate int `yaml:"sampleRate" json:"sampleRate"`
+	// Exporter is "skywalking" or "otlp"
+	Exporter string `yaml:"exporter" json:"exporter"`
+	// Insecure exports without TLS
+	Insecure bool `yaml:"insecure" json:"insecure"`
+	// Authentication is the token of a SkyWalking OAP requiring one
+	Authentication string `yaml:"authentication" json:"authentication"`
+	// Headers are sent with every export, e.g. the API key of an OTLP collector
+	Headers map[string]string `yaml:"headers" json:"headers"`
+}
+
+// DefaultSkyWalkingConfig returns the default tracing configuration of a service
+func DefaultSkyWalkingConfig(serviceName string) SkyWalkingConfig {
+	return SkyWalkingConfig{
+		Enabled:     false,
+		Collector:   "localhost:11800",
+		ServiceName: serviceName,
+		SampleRate:  1,
+		Exporter:    "skywalking",
+		Insecure:    true,
+	}
 }
@@ -19,6 +19,37 @@ type WebConfig struct {
 	// Authentication configuration
 	Auth AuthConfig `yaml:"auth" json:"auth"`
 
+	// Subscription delivery configuration
+	Subscription SubscriptionConfig `yaml:"subscription" json:"subscription"`
+
+	// Default branding, overridden per tenant
+	Branding BrandingConfig `yaml:"branding" json:"branding"`
+
+	// Progressive Web App user portal
+	Portal PortalConfig `yaml:"portal" json:"portal"`
+
+	// Node latency probing configuration
+	Probe ProbeConfig `yaml:"probe" json:"probe"`
+
+	// Enrollment tokens and install commands of new nodes, issued by the
+	// admin endpoints served in-process
+	NodeEnrollment NodeEnrollmentConfig `yaml:"nodeEnrollment" json:"nodeEnrollment"`
+
+	// Outgoing mail configuration
+	Mail MailConfig `yaml:"mail" json:"mail"`
+
+	// Real-time dashboard events
+	Events EventsConfig `yaml:"events" json:"events"`
+
+	// Alertmanager webhook receiving infrastructure alerts
+	Alertmanager AlertmanagerConfig `yaml:"alertmanager" json:"alertmanager"`
+
+	// API keys of integrations, metered and limited per key
+	APIKeys APIKeyConfig `yaml:"apiKeys" json:"apiKeys"`
+
+	// Networks and countries requests may come from
+	Access AccessConfig `yaml:"access" json:"access"`
+
 	// Logging configuration
 	Log LogConfig `yaml:"log" json:"log"`
 
@@ -27,6 +58,12 @@ type WebConfig struct {
 
 	// SkyWalking configuration
 	SkyWalking SkyWalkingConfig `yaml:"skywalking" json:"skywalking"`
+
+	// Graceful shutdown configuration
+	Shutdown ShutdownConfig `yaml:"shutdown" json:"shutdown"`
+
+	// Health and readiness endpoints, served on the server port
+	HealthEndpoints HealthEndpointConfig `yaml:"healthEndpoints" json:"healthEndpoints"`
 }
 
 // ServerConfig defines web server configuration
@@ -46,11 +83,211 @@ type AuthConfig struct {
 	JWTSecret             string        `yaml:"jwtSecret" json:"jwtSecret"`
 	JWTExpiration         time.Duration `yaml:"jwtExpiration" json:"jwtExpiration"`
 	RefreshExpiration     time.Duration `yaml:"refreshExpiration" json:"refreshExpiration"`
-	EnableRateLimit       bool          `yaml:"enableRateLimit" json:"enableRateLimit"`
-	RateLimitRequests     int           `yaml:"rateLimitRequests" json:"rateLimitRequests"`
-	RateLimitDuration     time.Duration `yaml:"rateLimitDuration" json:"rateLimitDuration"`
 	SessionTimeout        time.Duration `yaml:"sessionTimeout" json:"sessionTimeout"`
 	MaxConcurrentSessions int           `yaml:"maxConcurrentSessions" json:"maxConcurrentSessions"`
+
+	// Rate limiting of the web API: each client IP and each user may make
+	// RateLimitRequests requests per RateLimitDuration, in bursts of up to
+	// as many. The subscription endpoint is limited on its own, per client
+	// IP and per subscription token, to SubscriptionRateLimitRequests.
+	EnableRateLimit               bool                 `yaml:"enableRateLimit" json:"enableRateLimit"`
+	RateLimitRequests             int                  `yaml:"rateLimitRequests" json:"rateLimitRequests"`
+	RateLimitDuration             time.Duration        `yaml:"rateLimitDuration" json:"rateLimitDuration"`
+	SubscriptionRateLimitRequests int                  `yaml:"subscriptionRateLimitRequests" json:"subscriptionRateLimitRequests"`
+	RateLimitStore                RateLimitStoreConfig `yaml:"rateLimitStore" json:"rateLimitStore"`
+
+	// Two-factor authentication
+	RequireAdminTwoFactor bool   `yaml:"requireAdminTwoFactor" json:"requireAdminTwoFactor"`
+	TwoFactorIssuer       string `yaml:"twoFactorIssuer" json:"twoFactorIssuer"`
+
+	// RequireEmailVerification rejects logins of users, not admins, whose
+	// email address is not verified
+	RequireEmailVerification bool `yaml:"requireEmailVerification" json:"requireEmailVerification"`
+	// PasswordMinLength applies to passwords set through a reset link or on signup
+	PasswordMinLength int `yaml:"passwordMinLength" json:"passwordMinLength"`
+	// AllowSignup serves the public signup endpoint. New users are checked
+	// against the registration blocklist with the client address.
+	AllowSignup bool `yaml:"allowSignup" json:"allowSignup"`
+	// SignupTrialPlanID is the trial plan users signing up start on, 0 for
+	// the default plan. It has to be an active plan with a trial.
+	SignupTrialPlanID uint `yaml:"signupTrialPlanID" json:"signupTrialPlanID"`
+	// TokenIssueLimit bounds the password reset and verification mails sent
+	// per account, TokenIssueIPLimit those requested per client IP, within TokenIssueWindow
+	TokenIssueLimit   int           `yaml:"tokenIssueLimit" json:"tokenIssueLimit"`
+	TokenIssueIPLimit int           `yaml:"tokenIssueIpLimit" json:"tokenIssueIpLimit"`
+	TokenIssueWindow  time.Duration `yaml:"tokenIssueWindow" json:"tokenIssueWindow"`
+
+	// Credential providers, a single local provider is used when empty
+	Providers       []AuthProviderConfig `yaml:"providers" json:"providers"`
+	DefaultProvider string               `yaml:"defaultProvider" json:"defaultProvider"`
+}
+
+// AuthProviderConfig defines a credential provider used at login
+type AuthProviderConfig struct {
+	Name string `yaml:"name" json:"name"`
+	// Type is "local", "ldap" or "oidc"
+	Type string `yaml:"type" json:"type"`
+	// Roles restricts the provider to users of these roles, empty allows all roles
+	Roles []string `yaml:"roles" json:"roles"`
+
+	LDAP LDAPProviderConfig `yaml:"ldap" json:"ldap"`
+	OIDC OIDCProviderConfig `yaml:"oidc" json:"oidc"`
+}
+
+// LDAPProviderConfig defines an LDAP simple bind provider
+type LDAPProviderConfig struct {
+	// URL is ldap://host:389 or ldaps://host:636
+	URL string `yaml:"url" json:"url"`
+	// BindDNTemplate is the user DN with %s standing for the escaped username
+	BindDNTemplate     string        `yaml:"bindDNTemplate" json:"bindDNTemplate"`
+	InsecureSkipVerify bool          `yaml:"insecureSkipVerify" json:"insecureSkipVerify"`
+	Timeout            time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// OIDCProviderConfig defines an OpenID Connect authorization code provider
+type OIDCProviderConfig struct {
+	TokenURL     string `yaml:"tokenUrl" json:"tokenUrl"`
+	UserInfoURL  string `yaml:"userInfoUrl" json:"userInfoUrl"`
+	ClientID     string `yaml:"clientId" json:"clientId"`
+	ClientSecret string `yaml:"clientSecret" json:"clientSecret"`
+	// UsernameClaim is the userinfo claim matched against local usernames
+	UsernameClaim string        `yaml:"usernameClaim" json:"usernameClaim"`
+	Timeout       time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// SubscriptionConfig defines subscription delivery configuration
+type SubscriptionConfig struct {
+	UpdateInterval time.Duration `yaml:"updateInterval" json:"updateInterval"`
+	CacheMaxAge    time.Duration `yaml:"cacheMaxAge" json:"cacheMaxAge"`
+	// ShowNodeQuality appends the probed quality rating to outbound tags
+	ShowNodeQuality bool `yaml:"showNodeQuality" json:"showNodeQuality"`
+	// TokenGracePeriod keeps a replaced token working after a self-service rotation
+	TokenGracePeriod time.Duration `yaml:"tokenGracePeriod" json:"tokenGracePeriod"`
+}
+
+// BrandingConfig defines the panel branding shown to users without a tenant
+// and used for the fields a tenant leaves empty
+type BrandingConfig struct {
+	PanelName    string `yaml:"panelName" json:"panelName"`
+	LogoURL      string `yaml:"logoUrl" json:"logoUrl"`
+	SupportEmail string `yaml:"supportEmail" json:"supportEmail"`
+	SupportURL   string `yaml:"supportUrl" json:"supportUrl"`
+	// SubscriptionHost is the host put in subscription links, empty for relative links
+	SubscriptionHost string `yaml:"subscriptionHost" json:"subscriptionHost"`
+}
+
+// PortalConfig defines the server side of the Progressive Web App user
+// portal: its installable assets and the endpoints mobile clients poll
+type PortalConfig struct {
+	// AssetsDir holds the service worker (sw.js) and icons served at the
+	// root; empty serves the generated manifest only
+	AssetsDir string `yaml:"assetsDir" json:"assetsDir"`
+	// ThemeColor and BackgroundColor are put in the web app manifest
+	ThemeColor      string `yaml:"themeColor" json:"themeColor"`
+	BackgroundColor string `yaml:"backgroundColor" json:"backgroundColor"`
+	// StaleTokenGrace is how long after expiry an access token is still
+	// accepted by the offline-friendly endpoints, which then ask for a refresh
+	StaleTokenGrace time.Duration `yaml:"staleTokenGrace" json:"staleTokenGrace"`
+	// StatusMaxAge is the Cache-Control max-age of the status endpoint
+	StatusMaxAge time.Duration `yaml:"statusMaxAge" json:"statusMaxAge"`
+}
+
+// AlertmanagerConfig defines the webhook Prometheus Alertmanager posts its
+// notifications to, which puts infrastructure alerts into the system overview
+type AlertmanagerConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Token is the bearer token Alertmanager sends, set as
+	// http_config.authorization.credentials of the webhook receiver
+	Token string `yaml:"token" json:"token"`
+	// NodeLabels are the alert labels tried in order to find the node an
+	// alert is about, matched against node names and hosts. Ports, as in the
+	// instance label, are ignored.
+	NodeLabels []string `yaml:"nodeLabels" json:"nodeLabels"`
+}
+
+// APIKeyConfig defines the API keys users create for their integrations.
+// Requests made with a key are metered per key; the limits are the defaults
+// of keys without limits of their own and are enforced by each web instance.
+type APIKeyConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// DailyQuota is the requests a key may make per day, 0 for unlimited
+	DailyQuota int64 `yaml:"dailyQuota" json:"dailyQuota"`
+	// RateLimit is the sustained requests per second of a key, 0 for unlimited
+	RateLimit float64 `yaml:"rateLimit" json:"rateLimit"`
+	// Burst is the requests a key may make at once above the rate
+	Burst int `yaml:"burst" json:"burst"`
+	// FlushInterval is how often the metered usage is written to the database
+	FlushInterval time.Duration `yaml:"flushInterval" json:"flushInterval"`
+}
+
+// AccessConfig restricts where requests may come from. Refused requests get
+// 403 and are recorded in the admin audit log.
+type AccessConfig struct {
+	// TrustedProxies are the reverse proxies, as networks or addresses, whose
+	// X-Forwarded-For and X-Real-IP headers name the client. None when empty,
+	// the client is then the address the request came from.
+	TrustedProxies []string `yaml:"trustedProxies" json:"trustedProxies"`
+
+	// AdminAllowedCIDRs limits the admin endpoints to these networks or
+	// addresses, any when empty
+	AdminAllowedCIDRs []string `yaml:"adminAllowedCidrs" json:"adminAllowedCidrs"`
+	// AdminDeniedCIDRs refuses the admin endpoints to these networks or
+	// addresses, also within the allowed ones
+	AdminDeniedCIDRs []string `yaml:"adminDeniedCidrs" json:"adminDeniedCidrs"`
+
+	// Country blocking of the login and subscription endpoints
+	GeoIP GeoIPConfig `yaml:"geoip" json:"geoip"`
+
+	// AuditInterval is how often a refused client IP is recorded in the
+	// audit log for the same rule, so that scans do not flood it
+	AuditInterval time.Duration `yaml:"auditInterval" json:"auditInterval"`
+}
+
+// GeoIPConfig defines the country blocking of the login and subscription
+// endpoints and the MaxMind DB file countries are looked up in, such as
+// GeoLite2-Country, DB-IP country lite or the geoip.db of sing-box
+type GeoIPConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// DatabasePath is the database file, written by downloads from DownloadURL
+	DatabasePath string `yaml:"databasePath" json:"databasePath"`
+	// DownloadURL is where the database is downloaded from, plain or
+	// gzipped; without it the file is only read again when it changes
+	DownloadURL string `yaml:"downloadUrl" json:"downloadUrl"`
+	// RefreshInterval is how often the database is downloaded or checked
+	RefreshInterval time.Duration `yaml:"refreshInterval" json:"refreshInterval"`
+	DownloadTimeout time.Duration `yaml:"downloadTimeout" json:"downloadTimeout"`
+
+	// BlockedCountries are refused, as ISO 3166-1 alpha-2 codes
+	BlockedCountries []string `yaml:"blockedCountries" json:"blockedCountries"`
+	// AllowedCountries, when set, are the only countries accepted
+	AllowedCountries []string `yaml:"allowedCountries" json:"allowedCountries"`
+	// BlockUnknown refuses addresses without a country, such as private ones
+	BlockUnknown bool `yaml:"blockUnknown" json:"blockUnknown"`
+}
+
+// ProbeConfig defines node latency probing configuration
+type ProbeConfig struct {
+	Enabled  bool          `yaml:"enabled" json:"enabled"`
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout"`
+	Window   time.Duration `yaml:"window" json:"window"`
+}
+
+// EventsConfig defines the WebSocket event stream of dashboards. With the
+// in-memory bus, events are relayed from the API server given by apiServer;
+// with Redis, they are read from the stream the API servers publish to.
+type EventsConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// Bus must use the backend of the API servers
+	Bus EventBusConfig `yaml:"bus" json:"bus"`
+	// ReconnectInterval is the wait before watching the API server again
+	// after the stream broke, moving on to the next failover address
+	ReconnectInterval time.Duration `yaml:"reconnectInterval" json:"reconnectInterval"`
+	// ClientBuffer is the number of events buffered per client, a client
+	// that falls further behind loses events
+	ClientBuffer int `yaml:"clientBuffer" json:"clientBuffer"`
+	// WriteTimeout disconnects clients that do not take an event in time
+	WriteTimeout time.Duration `yaml:"writeTimeout" json:"writeTimeout"`
 }
 
 // DefaultWebConfig returns default web configuration
@@ -76,6 +313,10 @@ func DefaultWebConfig() *WebConfig {
 			MaxIdleConns: 10,
 			MaxOpenConns: 100,
 			MaxLifetime:  time.Hour,
+
+			SlowQueryThreshold: 200 * time.Millisecond,
+			StatsInterval:      15 * time.Second,
+			AutoMigrate:        true,
 		},
 		APIServer: APIServerConnection{
 			Address:  "localhost",
@@ -87,11 +328,69 @@ func DefaultWebConfig() *WebConfig {
 			JWTSecret:             "default-jwt-secret",
 			JWTExpiration:         24 * time.Hour,
 			RefreshExpiration:     7 * 24 * time.Hour,
-			EnableRateLimit:       true,
-			RateLimitRequests:     100,
-			RateLimitDuration:     time.Minute,
 			SessionTimeout:        30 * time.Minute,
 			MaxConcurrentSessions: 5,
+			RequireAdminTwoFactor: false,
+			TwoFactorIssuer:       "sing-box-web",
+
+			EnableRateLimit:               true,
+			RateLimitRequests:             100,
+			RateLimitDuration:             time.Minute,
+			SubscriptionRateLimitRequests: 20,
+			RateLimitStore:                DefaultRateLimitStoreConfig(),
+
+			PasswordMinLength: 8,
+			TokenIssueLimit:   3,
+			TokenIssueIPLimit: 10,
+			TokenIssueWindow:  time.Hour,
+		},
+		Subscription: SubscriptionConfig{
+			UpdateInterval:   12 * time.Hour,
+			CacheMaxAge:      5 * time.Minute,
+			TokenGracePeriod: 24 * time.Hour,
+		},
+		Branding: BrandingConfig{
+			PanelName: "sing-box-web",
+		},
+		Portal: PortalConfig{
+			ThemeColor:      "#1f2937",
+			BackgroundColor: "#ffffff",
+			StaleTokenGrace: 24 * time.Hour,
+			StatusMaxAge:    30 * time.Second,
+		},
+		Probe: ProbeConfig{
+			Enabled:  true,
+			Interval: time.Minute,
+			Timeout:  5 * time.Second,
+			Window:   time.Hour,
+		},
+		NodeEnrollment:  DefaultNodeEnrollmentConfig(),
+		Mail:            DefaultMailConfig(),
+		Shutdown:        DefaultShutdownConfig(),
+		HealthEndpoints: DefaultHealthEndpointConfig(0),
+		Events: EventsConfig{
+			Enabled:           false,
+			Bus:               DefaultEventBusConfig(),
+			ReconnectInterval: 5 * time.Second,
+			ClientBuffer:      64,
+			WriteTimeout:      10 * time.Second,
+		},
+		Alertmanager: AlertmanagerConfig{
+			NodeLabels: []string{"node", "instance", "host"},
+		},
+		APIKeys: APIKeyConfig{
+			Enabled:       true,
+			RateLimit:     10,
+			Burst:         20,
+			FlushInterval: 15 * time.Second,
+		},
+		Access: AccessConfig{
+			GeoIP: GeoIPConfig{
+				DatabasePath:    "./data/geoip/country.mmdb",
+				RefreshInterval: 24 * time.Hour,
+				DownloadTimeout: 5 * time.Minute,
+			},
+			AuditInterval: 10 * time.Minute,
 		},
 		Log: LogConfig{
 			Level:      "info",
@@ -108,11 +407,6 @@ func DefaultWebConfig() *WebConfig {
 			Port:    9090,
 			Path:    "/metrics",
 		},
-		SkyWalking: SkyWalkingConfig{
-			Enabled:     false,
-			Collector:   "localhost:11800",
-			ServiceName: "sing-box-web",
-			SampleRate:  1,
-		},
+		SkyWalking: DefaultSkyWalkingConfig("sing-box-web"),
 	}
 }
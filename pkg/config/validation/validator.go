@@ -1,17 +1,25 @@
 package validation
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"net/mail"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/geodata"
+	mailer "sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/report"
+	"sing-box-web/pkg/util"
 )
 
 // ValidationError represents a configuration validation error
@@ -85,6 +93,55 @@ func ValidateWebConfig(config *configv1.WebConfig) error {
 	// Validate auth configuration
 	validator.validateAuthConfig(config.Auth)
 
+	// Validate subscription configuration
+	validator.validateSubscriptionConfig(config.Subscription)
+
+	// Validate branding configuration
+	validator.validateBrandingConfig(config.Branding)
+
+	// Validate portal configuration
+	validator.validatePortalConfig(config.Portal, config.Auth)
+
+	// Validate probe configuration
+	validator.validateProbeConfig(config.Probe)
+
+	// Validate node enrollment configuration
+	validator.validateNodeEnrollmentConfig(config.NodeEnrollment, "nodeEnrollment")
+
+	// Validate mail configuration
+	validator.validateMailConfig(config.Mail)
+	if config.Auth.RequireEmailVerification && !config.Mail.Enabled {
+		validator.addError("auth.requireEmailVerification", true, "email verification requires mail to be enabled")
+	}
+
+	// Validate events configuration
+	if config.Events.Enabled {
+		validator.validateEventBusConfig(config.Events.Bus, "events.bus")
+		validator.validateDuration(config.Events.ReconnectInterval, "events.reconnectInterval")
+		validator.validateDuration(config.Events.WriteTimeout, "events.writeTimeout")
+		if config.Events.ClientBuffer <= 0 {
+			validator.addError("events.clientBuffer", config.Events.ClientBuffer, "client buffer must be greater than 0")
+		}
+	}
+
+	// Validate Alertmanager webhook configuration
+	if config.Alertmanager.Enabled {
+		if len(config.Alertmanager.Token) < 16 {
+			validator.addError("alertmanager.token", "", "token of at least 16 characters is required for the Alertmanager webhook")
+		}
+		if len(config.Alertmanager.NodeLabels) == 0 {
+			validator.addError("alertmanager.nodeLabels", config.Alertmanager.NodeLabels, "at least one node label is required")
+		}
+	}
+
+	// Validate API key configuration
+	if config.APIKeys.Enabled {
+		validator.validateAPIKeyConfig(config.APIKeys)
+	}
+
+	// Validate access configuration
+	validator.validateAccessConfig(config.Access)
+
 	// Validate log configuration
 	validator.validateLogConfig(config.Log)
 
@@ -94,6 +151,15 @@ func ValidateWebConfig(config *configv1.WebConfig) error {
 	// Validate SkyWalking configuration
 	validator.validateSkyWalkingConfig(config.SkyWalking)
 
+	// Validate shutdown configuration
+	validator.validateShutdownConfig(config.Shutdown)
+
+	// Validate health endpoint configuration
+	validator.validateHealthEndpointConfig(config.HealthEndpoints, false)
+
+	// Validate shutdown configuration
+	validator.validateShutdownConfig(config.Shutdown)
+
 	return validator.Validate()
 }
 
@@ -111,6 +177,9 @@ func ValidateAPIConfig(config *configv1.APIConfig) error {
 	// Validate database configuration
 	validator.validateDatabaseConfig(config.Database)
 
+	// Validate analytics configuration
+	validator.validateAnalyticsConfig(config.Analytics)
+
 	// Validate log configuration
 	validator.validateLogConfig(config.Log)
 
@@ -123,6 +192,30 @@ func ValidateAPIConfig(config *configv1.APIConfig) error {
 	// Validate business configuration
 	validator.validateBusinessConfig(config.Business)
 
+	// Validate high availability configuration
+	validator.validateHAConfig(config.HA)
+
+	// Validate mail configuration
+	validator.validateMailConfig(config.Mail)
+	if config.Business.Alert.EmailNotifications && !config.Mail.Enabled {
+		validator.addError("business.alert.emailNotifications", true, "email notifications require mail to be enabled")
+	}
+	if config.Business.TrafficReport.Enabled && !config.Mail.Enabled {
+		validator.addError("business.trafficReport.enabled", true, "traffic reports require mail to be enabled")
+	}
+
+	// Validate event bus configuration
+	validator.validateEventBusConfig(config.Events, "events")
+
+	// Validate shutdown configuration
+	validator.validateShutdownConfig(config.Shutdown)
+
+	// Validate health endpoint configuration
+	validator.validateHealthEndpointConfig(config.HealthEndpoints, true)
+
+	// Validate telemetry configuration
+	validator.validateTelemetryConfig(config.Telemetry)
+
 	return validator.Validate()
 }
 
@@ -143,9 +236,20 @@ func ValidateAgentConfig(config *configv1.AgentConfig) error {
 	// Validate sing-box configuration
 	validator.validateSingBoxConfig(config.SingBox)
 
+	// Validate disk guard configuration
+	validator.validateDiskGuardConfig(config.Disk)
+
 	// Validate monitor configuration
 	validator.validateMonitorConfig(config.Monitor)
 
+	// Validate geo data synchronization
+	if config.GeoData.Enabled {
+		if config.GeoData.Dir == "" || !filepath.IsAbs(config.GeoData.Dir) {
+			validator.addError("geoData.dir", config.GeoData.Dir, "directory must be an absolute path")
+		}
+		validator.validateDuration(config.GeoData.SyncInterval, "geoData.syncInterval")
+	}
+
 	// Validate log configuration
 	validator.validateLogConfig(config.Log)
 
@@ -155,6 +259,9 @@ func ValidateAgentConfig(config *configv1.AgentConfig) error {
 	// Validate SkyWalking configuration
 	validator.validateSkyWalkingConfig(config.SkyWalking)
 
+	// Validate health endpoint configuration
+	validator.validateHealthEndpointConfig(config.HealthEndpoints, false)
+
 	return validator.Validate()
 }
 
@@ -189,37 +296,237 @@ func (v *Validator) validateServerConfig(config configv1.ServerConfig) {
 	}
 }
 
+// validateAPIKeyConfig validates the default limits and metering of API keys
+func (v *Validator) validateAPIKeyConfig(config configv1.APIKeyConfig) {
+	if config.DailyQuota < 0 {
+		v.addError("apiKeys.dailyQuota", config.DailyQuota, "daily quota cannot be negative")
+	}
+	if config.RateLimit < 0 {
+		v.addError("apiKeys.rateLimit", config.RateLimit, "rate limit cannot be negative")
+	}
+	if config.RateLimit > 0 && config.Burst < 1 {
+		v.addError("apiKeys.burst", config.Burst, "burst must be at least 1 with a rate limit")
+	}
+	v.validateDuration(config.FlushInterval, "apiKeys.flushInterval")
+}
+
+func (v *Validator) validateAccessConfig(config configv1.AccessConfig) {
+	for i, proxy := range config.TrustedProxies {
+		v.validateNetwork(proxy, fmt.Sprintf("access.trustedProxies[%d]", i))
+	}
+	for i, cidr := range config.AdminAllowedCIDRs {
+		v.validateNetwork(cidr, fmt.Sprintf("access.adminAllowedCidrs[%d]", i))
+	}
+	for i, cidr := range config.AdminDeniedCIDRs {
+		v.validateNetwork(cidr, fmt.Sprintf("access.adminDeniedCidrs[%d]", i))
+	}
+	v.validateDuration(config.AuditInterval, "access.auditInterval")
+
+	geoip := config.GeoIP
+	if !geoip.Enabled {
+		return
+	}
+	if geoip.DatabasePath == "" {
+		v.addError("access.geoip.databasePath", geoip.DatabasePath, "database path cannot be empty")
+	}
+	if geoip.DownloadURL != "" {
+		if u, err := url.Parse(geoip.DownloadURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			v.addError("access.geoip.downloadUrl", geoip.DownloadURL, "download URL must be an http or https URL")
+		}
+		v.validateDuration(geoip.DownloadTimeout, "access.geoip.downloadTimeout")
+	}
+	v.validateDuration(geoip.RefreshInterval, "access.geoip.refreshInterval")
+	if len(geoip.BlockedCountries) == 0 && len(geoip.AllowedCountries) == 0 && !geoip.BlockUnknown {
+		v.addError("access.geoip.blockedCountries", geoip.BlockedCountries, "blocked or allowed countries are required with GeoIP enabled")
+	}
+	for i, country := range geoip.BlockedCountries {
+		v.validateCountryCode(country, fmt.Sprintf("access.geoip.blockedCountries[%d]", i))
+	}
+	for i, country := range geoip.AllowedCountries {
+		v.validateCountryCode(country, fmt.Sprintf("access.geoip.allowedCountries[%d]", i))
+	}
+}
+
+// validateCountryCode checks an ISO 3166-1 alpha-2 country code
+func (v *Validator) validateCountryCode(code, field string) {
+	upper := strings.ToUpper(code)
+	if len(upper) != 2 || upper[0] < 'A' || upper[0] > 'Z' || upper[1] < 'A' || upper[1] > 'Z' {
+		v.addError(field, code, "country must be a two-letter ISO 3166-1 code")
+	}
+}
+
 func (v *Validator) validateDatabaseConfig(config configv1.DatabaseConfig) {
+	v.validateDatabaseConnection(config, "database")
+
+	if config.SlowQueryThreshold < 0 {
+		v.addError("database.slowQueryThreshold", config.SlowQueryThreshold, "slow query threshold cannot be negative")
+	}
+	v.validateDuration(config.StatsInterval, "database.statsInterval")
+
+	tenants := make(map[uint]bool, len(config.Tenants))
+	for i, tenant := range config.Tenants {
+		field := fmt.Sprintf("database.tenants[%d]", i)
+		if tenant.TenantID == 0 {
+			v.addError(field+".tenantId", tenant.TenantID, "tenant ID cannot be empty")
+		} else if tenants[tenant.TenantID] {
+			v.addError(field+".tenantId", tenant.TenantID, "tenant has more than one database")
+		}
+		tenants[tenant.TenantID] = true
+
+		if len(tenant.Tenants) > 0 {
+			v.addError(field+".tenants", len(tenant.Tenants), "tenant databases cannot have tenant databases")
+		}
+		v.validateDatabaseConnection(tenant.DatabaseConfig, field)
+	}
+}
+
+// validateDatabaseConnection validates the connection and pool settings of a database
+func (v *Validator) validateDatabaseConnection(config configv1.DatabaseConfig, field string) {
 	if config.Driver == "" {
-		v.addError("database.driver", config.Driver, "database driver cannot be empty")
+		v.addError(field+".driver", config.Driver, "database driver cannot be empty")
 	} else if config.Driver != "mysql" && config.Driver != "postgres" && config.Driver != "sqlite" {
-		v.addError("database.driver", config.Driver, "database driver must be 'mysql', 'postgres', or 'sqlite'")
+		v.addError(field+".driver", config.Driver, "database driver must be 'mysql', 'postgres', or 'sqlite'")
 	}
 
 	// For SQLite, host and port are not required
 	if config.Driver != "sqlite" {
-		v.validateAddress(config.Host, "database.host")
-		v.validatePort(config.Port, "database.port")
+		v.validateAddress(config.Host, field+".host")
+		v.validatePort(config.Port, field+".port")
 
 		if config.Username == "" {
-			v.addError("database.username", config.Username, "database username cannot be empty")
+			v.addError(field+".username", config.Username, "database username cannot be empty")
+		}
+	}
+
+	if config.SSLMode != "" {
+		switch config.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			v.addError(field+".sslMode", config.SSLMode, "sslMode must be 'disable', 'allow', 'prefer', 'require', 'verify-ca', or 'verify-full'")
 		}
 	}
 
 	if config.Database == "" {
-		v.addError("database.database", config.Database, "database name cannot be empty")
+		v.addError(field+".database", config.Database, "database name cannot be empty")
 	}
 
 	if config.MaxIdleConns <= 0 {
-		v.addError("database.maxIdleConns", config.MaxIdleConns, "maxIdleConns must be greater than 0")
+		v.addError(field+".maxIdleConns", config.MaxIdleConns, "maxIdleConns must be greater than 0")
 	}
 
 	if config.MaxOpenConns <= 0 {
-		v.addError("database.maxOpenConns", config.MaxOpenConns, "maxOpenConns must be greater than 0")
+		v.addError(field+".maxOpenConns", config.MaxOpenConns, "maxOpenConns must be greater than 0")
 	}
 
 	if config.MaxIdleConns > config.MaxOpenConns {
-		v.addError("database.maxIdleConns", config.MaxIdleConns, "maxIdleConns cannot be greater than maxOpenConns")
+		v.addError(field+".maxIdleConns", config.MaxIdleConns, "maxIdleConns cannot be greater than maxOpenConns")
+	}
+}
+
+func (v *Validator) validateAnalyticsConfig(config configv1.AnalyticsConfig) {
+	if !config.Enabled {
+		return
+	}
+
+	if config.Driver != "clickhouse" && config.Driver != "timescaledb" {
+		v.addError("analytics.driver", config.Driver, "analytics driver must be 'clickhouse' or 'timescaledb'")
+	}
+
+	if config.DSN == "" {
+		v.addError("analytics.dsn", config.DSN, "analytics DSN cannot be empty")
+	}
+
+	if config.MaxOpenConns <= 0 {
+		v.addError("analytics.maxOpenConns", config.MaxOpenConns, "maxOpenConns must be greater than 0")
+	}
+
+	v.validateDuration(config.QueryTimeout, "analytics.queryTimeout")
+}
+
+func (v *Validator) validateRateLimitStore(config configv1.RateLimitStoreConfig, field string) {
+	switch config.Backend {
+	case "memory":
+	case "redis":
+		if config.Redis.Address == "" {
+			v.addError(field+".redis.address", config.Redis.Address, "redis address cannot be empty")
+		}
+		if config.Redis.DB < 0 {
+			v.addError(field+".redis.db", config.Redis.DB, "redis db cannot be negative")
+		}
+		v.validateDuration(config.Redis.DialTimeout, field+".redis.dialTimeout")
+	default:
+		v.addError(field+".backend", config.Backend, "rate limit store backend must be 'memory' or 'redis'")
+	}
+}
+
+func (v *Validator) validateEventBusConfig(config configv1.EventBusConfig, field string) {
+	switch config.Backend {
+	case "memory":
+	case "redis":
+		if config.Redis.Address == "" {
+			v.addError(field+".redis.address", config.Redis.Address, "redis address cannot be empty")
+		}
+		if config.Redis.Stream == "" {
+			v.addError(field+".redis.stream", config.Redis.Stream, "redis stream cannot be empty")
+		}
+		if config.Redis.DB < 0 {
+			v.addError(field+".redis.db", config.Redis.DB, "redis db cannot be negative")
+		}
+		if config.Redis.MaxLen < 0 {
+			v.addError(field+".redis.maxLen", config.Redis.MaxLen, "maxLen cannot be negative")
+		}
+		v.validateDuration(config.Redis.DialTimeout, field+".redis.dialTimeout")
+	default:
+		v.addError(field+".backend", config.Backend, "event bus backend must be 'memory' or 'redis'")
+	}
+}
+
+func (v *Validator) validateShutdownConfig(config configv1.ShutdownConfig) {
+	if config.DrainDelay < 0 {
+		v.addError("shutdown.drainDelay", config.DrainDelay, "drain delay cannot be negative")
+	}
+	v.validateDuration(config.Timeout, "shutdown.timeout")
+}
+
+// validateHealthEndpointConfig validates the health endpoints, the check
+// interval only for servers with a gRPC health service
+func (v *Validator) validateHealthEndpointConfig(config configv1.HealthEndpointConfig, grpcHealth bool) {
+	if config.Port != 0 {
+		v.validatePort(config.Port, "healthEndpoints.port")
+	}
+	v.validateDuration(config.CheckTimeout, "healthEndpoints.checkTimeout")
+	if grpcHealth {
+		v.validateDuration(config.CheckInterval, "healthEndpoints.checkInterval")
+	}
+}
+
+func (v *Validator) validateTelemetryConfig(config configv1.TelemetryConfig) {
+	if !config.Enabled {
+		return
+	}
+
+	v.validateHTTPURL(config.Endpoint, "telemetry.endpoint")
+	v.validateDuration(config.Interval, "telemetry.interval")
+	v.validateDuration(config.Timeout, "telemetry.timeout")
+}
+
+func (v *Validator) validateHAConfig(config configv1.HAConfig) {
+	if !config.Enabled {
+		return
+	}
+
+	if config.LeaseName == "" {
+		v.addError("ha.leaseName", config.LeaseName, "lease name cannot be empty")
+	}
+
+	v.validateDuration(config.LeaseDuration, "ha.leaseDuration")
+	v.validateDuration(config.RenewInterval, "ha.renewInterval")
+	if config.RenewInterval >= config.LeaseDuration {
+		v.addError("ha.renewInterval", config.RenewInterval, "renew interval must be shorter than the lease duration")
+	}
+
+	if config.WebhookURL != "" {
+		v.validateDuration(config.WebhookTimeout, "ha.webhookTimeout")
 	}
 }
 
@@ -229,9 +536,26 @@ func (v *Validator) validateAPIServerConnection(config configv1.APIServerConnect
 	v.validateDuration(config.Timeout, "apiServer.timeout")
 
 	if !config.Insecure {
-		v.validateFilePath(config.CertFile, "apiServer.certFile")
-		v.validateFilePath(config.KeyFile, "apiServer.keyFile")
-		v.validateFilePath(config.CAFile, "apiServer.caFile")
+		// Client certificate is only needed when the server requires mTLS
+		if config.CertFile != "" || config.KeyFile != "" {
+			v.validateFilePath(config.CertFile, "apiServer.certFile")
+			v.validateFilePath(config.KeyFile, "apiServer.keyFile")
+		}
+		// Falls back to the system roots when no CA file is given
+		if config.CAFile != "" {
+			v.validateFilePath(config.CAFile, "apiServer.caFile")
+		}
+		if _, err := util.ParsePublicKeyPins(config.PinnedPublicKeys); err != nil {
+			v.addError("apiServer.pinnedPublicKeys", config.PinnedPublicKeys, err.Error())
+		}
+	} else if len(config.PinnedPublicKeys) > 0 {
+		v.addError("apiServer.pinnedPublicKeys", config.PinnedPublicKeys, "public keys can only be pinned when TLS is used")
+	}
+
+	for i, address := range config.FailoverAddresses {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			v.addError(fmt.Sprintf("apiServer.failoverAddresses[%d]", i), address, "failover address must be in host:port format")
+		}
 	}
 }
 
@@ -249,10 +573,171 @@ func (v *Validator) validateAuthConfig(config configv1.AuthConfig) {
 	if config.RateLimitRequests <= 0 {
 		v.addError("auth.rateLimitRequests", config.RateLimitRequests, "rateLimitRequests must be greater than 0")
 	}
+	if config.EnableRateLimit {
+		v.validateDuration(config.RateLimitDuration, "auth.rateLimitDuration")
+		if config.SubscriptionRateLimitRequests < 0 {
+			v.addError("auth.subscriptionRateLimitRequests", config.SubscriptionRateLimitRequests, "subscriptionRateLimitRequests cannot be negative")
+		}
+		v.validateRateLimitStore(config.RateLimitStore, "auth.rateLimitStore")
+	}
 
 	if config.MaxConcurrentSessions <= 0 {
 		v.addError("auth.maxConcurrentSessions", config.MaxConcurrentSessions, "maxConcurrentSessions must be greater than 0")
 	}
+
+	if config.RequireAdminTwoFactor && config.TwoFactorIssuer == "" {
+		v.addError("auth.twoFactorIssuer", config.TwoFactorIssuer, "twoFactorIssuer cannot be empty when admin two-factor is required")
+	}
+
+	if config.PasswordMinLength < 6 {
+		v.addError("auth.passwordMinLength", config.PasswordMinLength, "password min length must be at least 6")
+	}
+	if config.TokenIssueLimit <= 0 {
+		v.addError("auth.tokenIssueLimit", config.TokenIssueLimit, "tokenIssueLimit must be greater than 0")
+	}
+	if config.TokenIssueIPLimit <= 0 {
+		v.addError("auth.tokenIssueIpLimit", config.TokenIssueIPLimit, "tokenIssueIpLimit must be greater than 0")
+	}
+	v.validateDuration(config.TokenIssueWindow, "auth.tokenIssueWindow")
+
+	names := make(map[string]bool, len(config.Providers))
+	for i, provider := range config.Providers {
+		field := fmt.Sprintf("auth.providers[%d]", i)
+		if provider.Name == "" {
+			v.addError(field+".name", provider.Name, "provider name cannot be empty")
+		} else if names[provider.Name] {
+			v.addError(field+".name", provider.Name, "provider name must be unique")
+		}
+		names[provider.Name] = true
+
+		switch provider.Type {
+		case "local":
+		case "ldap":
+			if provider.LDAP.URL == "" {
+				v.addError(field+".ldap.url", provider.LDAP.URL, "LDAP URL cannot be empty")
+			} else if u, err := url.Parse(provider.LDAP.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") {
+				v.addError(field+".ldap.url", provider.LDAP.URL, "LDAP URL must use the ldap or ldaps scheme")
+			}
+			if strings.Count(provider.LDAP.BindDNTemplate, "%s") != 1 {
+				v.addError(field+".ldap.bindDNTemplate", provider.LDAP.BindDNTemplate, "bind DN template must contain exactly one %s")
+			}
+		case "oidc":
+			if provider.OIDC.TokenURL == "" {
+				v.addError(field+".oidc.tokenUrl", provider.OIDC.TokenURL, "token URL cannot be empty")
+			}
+			if provider.OIDC.UserInfoURL == "" {
+				v.addError(field+".oidc.userInfoUrl", provider.OIDC.UserInfoURL, "userinfo URL cannot be empty")
+			}
+			if provider.OIDC.ClientID == "" {
+				v.addError(field+".oidc.clientId", provider.OIDC.ClientID, "client ID cannot be empty")
+			}
+		default:
+			v.addError(field+".type", provider.Type, "provider type must be 'local', 'ldap' or 'oidc'")
+		}
+	}
+
+	if config.DefaultProvider != "" && len(config.Providers) > 0 && !names[config.DefaultProvider] {
+		v.addError("auth.defaultProvider", config.DefaultProvider, "default provider must be one of the configured providers")
+	}
+}
+
+func (v *Validator) validateSubscriptionConfig(config configv1.SubscriptionConfig) {
+	if config.UpdateInterval < time.Hour {
+		v.addError("subscription.updateInterval", config.UpdateInterval, "updateInterval must be at least 1h")
+	}
+
+	if config.CacheMaxAge < 0 {
+		v.addError("subscription.cacheMaxAge", config.CacheMaxAge, "cacheMaxAge cannot be negative")
+	}
+
+	if config.TokenGracePeriod < 0 || config.TokenGracePeriod > 30*24*time.Hour {
+		v.addError("subscription.tokenGracePeriod", config.TokenGracePeriod, "tokenGracePeriod must be between 0 and 720h")
+	}
+}
+
+func (v *Validator) validateBrandingConfig(config configv1.BrandingConfig) {
+	var validationErr *models.ValidationError
+	if err := models.DefaultBranding(config).Validate(); errors.As(err, &validationErr) {
+		for _, field := range validationErr.Fields {
+			v.addError("branding."+toCamelCase(field.Field), field.Value, field.Message)
+		}
+	}
+}
+
+func (v *Validator) validatePortalConfig(config configv1.PortalConfig, auth configv1.AuthConfig) {
+	if config.AssetsDir != "" {
+		if info, err := os.Stat(config.AssetsDir); err != nil || !info.IsDir() {
+			v.addError("portal.assetsDir", config.AssetsDir, "assets directory does not exist")
+		}
+	}
+	if config.StaleTokenGrace < 0 {
+		v.addError("portal.staleTokenGrace", config.StaleTokenGrace, "staleTokenGrace cannot be negative")
+	} else if config.StaleTokenGrace > auth.RefreshExpiration {
+		v.addError("portal.staleTokenGrace", config.StaleTokenGrace, "staleTokenGrace cannot exceed auth.refreshExpiration")
+	}
+	if config.StatusMaxAge < 0 {
+		v.addError("portal.statusMaxAge", config.StatusMaxAge, "statusMaxAge cannot be negative")
+	}
+}
+
+func (v *Validator) validateProbeConfig(config configv1.ProbeConfig) {
+	if !config.Enabled {
+		return
+	}
+
+	v.validateDuration(config.Interval, "probe.interval")
+	v.validateDuration(config.Timeout, "probe.timeout")
+	v.validateDuration(config.Window, "probe.window")
+
+	if config.Timeout >= config.Interval {
+		v.addError("probe.timeout", config.Timeout, "timeout must be shorter than interval")
+	}
+}
+
+func (v *Validator) validateMailConfig(config configv1.MailConfig) {
+	if !config.Enabled {
+		return
+	}
+
+	if config.SMTPHost == "" {
+		v.addError("mail.smtpHost", config.SMTPHost, "SMTP host is required")
+	}
+	v.validatePort(config.SMTPPort, "mail.smtpPort")
+	if !contains([]string{"starttls", "tls", "none"}, config.Encryption) {
+		v.addError("mail.encryption", config.Encryption, "encryption must be one of: starttls, tls, none")
+	}
+	if config.SMTPUser == "" && config.SMTPPassword != "" {
+		v.addError("mail.smtpUser", config.SMTPUser, "SMTP user is required with an SMTP password")
+	}
+	if config.SMTPUser != "" && config.Encryption == "none" {
+		v.addError("mail.encryption", config.Encryption, "SMTP authentication requires starttls or tls encryption")
+	}
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		v.addError("mail.from", config.From, "from must be a valid email address")
+	}
+	if config.BaseURL != "" {
+		v.validateHTTPURL(config.BaseURL, "mail.baseUrl")
+	}
+	if len(config.LinkSecret) < 32 {
+		v.addError("mail.linkSecret", "", "link secret must be at least 32 characters long")
+	}
+	v.validateDuration(config.PasswordResetTTL, "mail.passwordResetTtl")
+	v.validateDuration(config.VerificationTTL, "mail.verificationTtl")
+
+	if config.QueueSize <= 0 {
+		v.addError("mail.queueSize", config.QueueSize, "queue size must be greater than 0")
+	}
+	if config.MaxRetries < 0 {
+		v.addError("mail.maxRetries", config.MaxRetries, "max retries cannot be negative")
+	}
+	v.validateDuration(config.RetryBackoff, "mail.retryBackoff")
+	v.validateDuration(config.SendTimeout, "mail.sendTimeout")
+
+	for name := range config.Templates {
+		if !contains(mailer.Templates, name) {
+			v.addError("mail.templates."+name, name, "template must be one of: "+strings.Join(mailer.Templates, ", "))
+		}
+	}
 }
 
 func (v *Validator) validateLogConfig(config configv1.LogConfig) {
@@ -282,14 +767,73 @@ func (v *Validator) validateMetricsConfig(config configv1.MetricsConfig) {
 			v.addError("metrics.path", config.Path, "metrics path must start with '/'")
 		}
 	}
+
+	if config.MaxUserSeries < 0 {
+		v.addError("metrics.maxUserSeries", config.MaxUserSeries, "max user series cannot be negative")
+	}
+	if config.MaxNodeSeries < 0 {
+		v.addError("metrics.maxNodeSeries", config.MaxNodeSeries, "max node series cannot be negative")
+	}
+	if config.SeriesSyncInterval < 0 {
+		v.addError("metrics.seriesSyncInterval", config.SeriesSyncInterval, "series sync interval cannot be negative")
+	}
+	if config.RefreshInterval < 0 {
+		v.addError("metrics.refreshInterval", config.RefreshInterval, "refresh interval cannot be negative")
+	}
+	v.validateMetricsAuth(config.Auth)
+}
+
+// validateMetricsAuth checks the scrape credentials and source networks.
+// Secrets are not echoed in the errors.
+func (v *Validator) validateMetricsAuth(config configv1.MetricsAuthConfig) {
+	names := make(map[string]bool, len(config.Credentials))
+	for i, credential := range config.Credentials {
+		field := fmt.Sprintf("metrics.auth.credentials[%d]", i)
+		if credential.Name == "" {
+			v.addError(field+".name", credential.Name, "credential name cannot be empty")
+		} else if names[credential.Name] {
+			v.addError(field+".name", credential.Name, "credential name must be unique")
+		}
+		names[credential.Name] = true
+
+		basic := credential.Username != "" || credential.Password != ""
+		switch {
+		case credential.Token != "" && basic:
+			v.addError(field, credential.Name, "credential must have either a token or a username and password, not both")
+		case credential.Token != "":
+			if len(credential.Token) < 16 {
+				v.addError(field+".token", "", "token must be at least 16 characters")
+			}
+		case basic:
+			if credential.Username == "" || strings.Contains(credential.Username, ":") {
+				v.addError(field+".username", credential.Username, "username cannot be empty or contain ':'")
+			}
+			if len(credential.Password) < 8 {
+				v.addError(field+".password", "", "password must be at least 8 characters")
+			}
+		default:
+			v.addError(field, credential.Name, "credential must have a token or a username and password")
+		}
+	}
+
+	for i, cidr := range config.AllowedCIDRs {
+		v.validateNetwork(cidr, fmt.Sprintf("metrics.auth.allowedCidrs[%d]", i))
+	}
+}
+
+// validateNetwork checks a CIDR or a single IP address
+func (v *Validator) validateNetwork(cidr, field string) {
+	if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+		v.addError(field, cidr, "invalid CIDR or IP address")
+	}
 }
 
 func (v *Validator) validateSkyWalkingConfig(config configv1.SkyWalkingConfig) {
 	if config.Enabled {
 		if config.Collector == "" {
 			v.addError("skywalking.collector", config.Collector, "SkyWalking collector cannot be empty")
-		} else {
-			v.validateURL(config.Collector, "skywalking.collector")
+		} else if _, _, err := net.SplitHostPort(config.Collector); err != nil {
+			v.addError("skywalking.collector", config.Collector, "collector must be in host:port format")
 		}
 
 		if config.ServiceName == "" {
@@ -299,6 +843,10 @@ func (v *Validator) validateSkyWalkingConfig(config configv1.SkyWalkingConfig) {
 		if config.SampleRate < 0 || config.SampleRate > 10000 {
 			v.addError("skywalking.sampleRate", config.SampleRate, "SkyWalking sample rate must be between 0 and 10000")
 		}
+
+		if config.Exporter != "skywalking" && config.Exporter != "otlp" {
+			v.addError("skywalking.exporter", config.Exporter, "exporter must be skywalking or otlp")
+		}
 	}
 }
 
@@ -324,6 +872,26 @@ func (v *Validator) validateGRPCServerConfig(config configv1.GRPCServerConfig) {
 			v.validateFilePath(config.ClientCAs, "grpc.clientCAs")
 		}
 	}
+
+	if config.ManagementToken != "" && len(config.ManagementToken) < 16 {
+		v.addError("grpc.managementToken", "", "management token must be at least 16 characters long")
+	}
+
+	if config.Interceptors.SlowCallThreshold < 0 {
+		v.addError("grpc.interceptors.slowCallThreshold", config.Interceptors.SlowCallThreshold, "slowCallThreshold cannot be negative")
+	}
+	rateLimit := config.Interceptors.RateLimit
+	if rateLimit.Enabled {
+		v.validateDuration(rateLimit.Window, "grpc.interceptors.rateLimit.window")
+		if rateLimit.Requests < 0 {
+			v.addError("grpc.interceptors.rateLimit.requests", rateLimit.Requests, "requests cannot be negative")
+		}
+		for method, requests := range rateLimit.Methods {
+			if requests < 0 {
+				v.addError("grpc.interceptors.rateLimit.methods."+method, requests, "requests cannot be negative")
+			}
+		}
+	}
 }
 
 func (v *Validator) validateBusinessConfig(config configv1.BusinessConfig) {
@@ -332,15 +900,23 @@ func (v *Validator) validateBusinessConfig(config configv1.BusinessConfig) {
 	if config.Traffic.BatchSize <= 0 {
 		v.addError("business.traffic.batchSize", config.Traffic.BatchSize, "batch size must be greater than 0")
 	}
+	if config.Traffic.MaxBufferedRecords < config.Traffic.BatchSize {
+		v.addError("business.traffic.maxBufferedRecords", config.Traffic.MaxBufferedRecords, "max buffered records must be at least the batch size")
+	}
 	if config.Traffic.RetentionDays <= 0 {
 		v.addError("business.traffic.retentionDays", config.Traffic.RetentionDays, "retention days must be greater than 0")
 	}
+	if config.Traffic.EnableAggregation {
+		v.validateDuration(config.Traffic.AggregationWindow, "business.traffic.aggregationWindow")
+	}
 
 	// Validate node config
 	v.validateDuration(config.Node.HeartbeatInterval, "business.node.heartbeatInterval")
 	v.validateDuration(config.Node.HeartbeatTimeout, "business.node.heartbeatTimeout")
 	v.validateDuration(config.Node.MaxOfflineTime, "business.node.maxOfflineTime")
 	v.validateDuration(config.Node.ConfigSyncInterval, "business.node.configSyncInterval")
+	v.validateDuration(config.Node.CommandTimeout, "business.node.commandTimeout")
+	v.validateNodeEnrollmentConfig(config.Node.Enrollment, "business.node.enrollment")
 
 	// Validate user config
 	if config.User.MaxUsersPerNode <= 0 {
@@ -349,11 +925,221 @@ func (v *Validator) validateBusinessConfig(config configv1.BusinessConfig) {
 	if config.User.PasswordMinLength < 6 {
 		v.addError("business.user.passwordMinLength", config.User.PasswordMinLength, "password min length must be at least 6")
 	}
+
+	// Validate metrics history config
+	if config.Metrics.Enabled {
+		v.validateDuration(config.Metrics.DownsampleInterval, "business.metrics.downsampleInterval")
+		v.validateDuration(config.Metrics.MinuteRetention, "business.metrics.minuteRetention")
+		v.validateDuration(config.Metrics.HourlyRetention, "business.metrics.hourlyRetention")
+		v.validateDuration(config.Metrics.DailyRetention, "business.metrics.dailyRetention")
+		if config.Metrics.HourlyRetention <= config.Metrics.MinuteRetention {
+			v.addError("business.metrics.hourlyRetention", config.Metrics.HourlyRetention, "hourly retention must be longer than minute retention")
+		}
+		if config.Metrics.DailyRetention <= config.Metrics.HourlyRetention {
+			v.addError("business.metrics.dailyRetention", config.Metrics.DailyRetention, "daily retention must be longer than hourly retention")
+		}
+	}
+
+	// Validate user alert config
+	if config.Alert.InAppNotifications || config.Alert.EmailNotifications || config.Alert.TelegramNotifications {
+		thresholds := config.Alert.QuotaWarningThresholds
+		if len(thresholds) == 0 {
+			v.addError("business.alert.quotaWarningThresholds", thresholds, "at least one quota warning threshold is required")
+		}
+		for i, threshold := range thresholds {
+			if threshold < 1 || threshold > 99 || (i > 0 && threshold <= thresholds[i-1]) {
+				v.addError("business.alert.quotaWarningThresholds", thresholds, "thresholds must be ascending percentages between 1 and 99")
+				break
+			}
+		}
+		v.validateDuration(config.Alert.PlanExpiryWarning, "business.alert.planExpiryWarning")
+		v.validateDuration(config.Alert.CheckInterval, "business.alert.checkInterval")
+	}
+	if config.Alert.TelegramNotifications {
+		if config.Alert.Telegram.BotToken == "" {
+			v.addError("business.alert.telegram.botToken", "", "bot token is required for Telegram notifications")
+		}
+		v.validateHTTPURL(config.Alert.Telegram.APIURL, "business.alert.telegram.apiUrl")
+		v.validateDuration(config.Alert.Telegram.Timeout, "business.alert.telegram.timeout")
+	}
+
+	// Validate geo data distribution config
+	v.validateGeoDataConfig(config.GeoData)
+
+	// Validate registration blocklist config
+	if len(config.Blocklist.DisposableDomainLists) > 0 {
+		for i, url := range config.Blocklist.DisposableDomainLists {
+			v.validateHTTPURL(url, fmt.Sprintf("business.blocklist.disposableDomainLists[%d]", i))
+		}
+		v.validateDuration(config.Blocklist.RefreshInterval, "business.blocklist.refreshInterval")
+		v.validateDuration(config.Blocklist.DownloadTimeout, "business.blocklist.downloadTimeout")
+		if config.Blocklist.MaxListSize <= 0 {
+			v.addError("business.blocklist.maxListSize", config.Blocklist.MaxListSize, "max list size must be greater than 0")
+		}
+	}
+
+	// Validate inactive account policy
+	if config.Inactivity.Enabled {
+		inactivity := config.Inactivity
+		v.validateDuration(inactivity.SuspendAfter, "business.inactivity.suspendAfter")
+		v.validateDuration(inactivity.CheckInterval, "business.inactivity.checkInterval")
+		if inactivity.WarningBefore < 0 || inactivity.WarningBefore >= inactivity.SuspendAfter {
+			v.addError("business.inactivity.warningBefore", inactivity.WarningBefore, "warning must not be negative and shorter than suspendAfter")
+		}
+		if inactivity.PurgeAfter != 0 && inactivity.PurgeAfter <= inactivity.SuspendAfter {
+			v.addError("business.inactivity.purgeAfter", inactivity.PurgeAfter, "purge must come after the suspension, or be 0 to keep suspended accounts")
+		}
+	}
+
+	// Validate trials
+	if config.Trial.Enabled {
+		v.validateDuration(config.Trial.CheckInterval, "business.trial.checkInterval")
+		if config.Trial.WarningBefore < 0 {
+			v.addError("business.trial.warningBefore", config.Trial.WarningBefore, "warningBefore must not be negative")
+		}
+		if config.Trial.PlanID != 0 && config.Trial.DowngradePlanID == config.Trial.PlanID {
+			v.addError("business.trial.downgradePlanID", config.Trial.DowngradePlanID, "trials cannot downgrade to the trial plan")
+		}
+	}
+
+	// Validate account expiry
+	if config.Expiry.Enabled {
+		v.validateDuration(config.Expiry.CheckInterval, "business.expiry.checkInterval")
+		for i, offset := range config.Expiry.WarningOffsets {
+			if offset <= 0 {
+				v.addError(fmt.Sprintf("business.expiry.warningOffsets[%d]", i), offset, "warning offsets must be positive")
+			}
+		}
+	}
+
+	// Validate node report cross-checking
+	if config.Witness.Enabled {
+		witness := config.Witness
+		v.validateDuration(witness.Window, "business.witness.window")
+		if witness.MinBytes <= 0 {
+			v.addError("business.witness.minBytes", witness.MinBytes, "minBytes must be greater than 0")
+		}
+		if witness.MinInterfaceRatio < 0 || witness.MaxInterfaceRatio <= witness.MinInterfaceRatio {
+			v.addError("business.witness.maxInterfaceRatio", witness.MaxInterfaceRatio, "ratios must not be negative and maxInterfaceRatio must exceed minInterfaceRatio")
+		}
+		if witness.MinProbeSamples <= 0 {
+			v.addError("business.witness.minProbeSamples", witness.MinProbeSamples, "minProbeSamples must be greater than 0")
+		}
+		if witness.MinProbeAvailability < 0 || witness.MinProbeAvailability > 1 {
+			v.addError("business.witness.minProbeAvailability", witness.MinProbeAvailability, "availability must be between 0 and 1")
+		}
+	}
+
+	// Validate node health scoring
+	if config.Health.Enabled {
+		health := config.Health
+		v.validateDuration(health.CheckInterval, "business.health.checkInterval")
+		v.validateDuration(health.ProbeWindow, "business.health.probeWindow")
+		if health.LoadThreshold < 0 || health.LoadThreshold >= 100 {
+			v.addError("business.health.loadThreshold", health.LoadThreshold, "loadThreshold must be between 0 and 100")
+		}
+		if health.MinProbeSamples < 0 {
+			v.addError("business.health.minProbeSamples", health.MinProbeSamples, "minProbeSamples must not be negative")
+		}
+		if min(health.HeartbeatWeight, health.LoadWeight, health.ErrorWeight, health.PacketLossWeight) < 0 ||
+			health.HeartbeatWeight+health.LoadWeight+health.ErrorWeight+health.PacketLossWeight == 0 {
+			v.addError("business.health.heartbeatWeight", health.HeartbeatWeight, "weights must not be negative and at least one must be set")
+		}
+		if health.MaintenanceBelow < 0 || health.DegradedBelow < health.MaintenanceBelow ||
+			health.RecoverAbove < health.DegradedBelow || health.RecoverAbove > 100 {
+			v.addError("business.health.recoverAbove", health.RecoverAbove, "thresholds must satisfy 0 <= maintenanceBelow <= degradedBelow <= recoverAbove <= 100")
+		}
+	}
+
+	if config.Failover.Enabled {
+		v.validateDuration(config.Failover.CheckInterval, "business.failover.checkInterval")
+		if config.Failover.RevertAfter < 0 {
+			v.addError("business.failover.revertAfter", config.Failover.RevertAfter, "revertAfter must not be negative")
+		}
+	}
+
+	if config.UserMigration.CheckInterval < 0 {
+		v.addError("business.userMigration.checkInterval", config.UserMigration.CheckInterval, "checkInterval must not be negative")
+	}
+	if config.UserMigration.MaxUsersPerMinute <= 0 {
+		v.addError("business.userMigration.maxUsersPerMinute", config.UserMigration.MaxUsersPerMinute, "maxUsersPerMinute must be positive")
+	}
+
+	if config.Automation.ExecutionRetention < 0 {
+		v.addError("business.automation.executionRetention", config.Automation.ExecutionRetention, "executionRetention must not be negative")
+	}
+
+	if config.Integrity.Enabled {
+		v.validateDuration(config.Integrity.CheckInterval, "business.integrity.checkInterval")
+		if config.Integrity.TrafficDays < 0 {
+			v.addError("business.integrity.trafficDays", config.Integrity.TrafficDays, "trafficDays must not be negative")
+		}
+		if config.Integrity.TrafficTolerance < 0 || config.Integrity.TrafficTolerance >= 1 {
+			v.addError("business.integrity.trafficTolerance", config.Integrity.TrafficTolerance, "trafficTolerance must be between 0 and 1")
+		}
+	}
+
+	if config.TrafficReport.Enabled {
+		if !slices.Contains(report.Formats, config.TrafficReport.Format) {
+			v.addError("business.trafficReport.format", config.TrafficReport.Format,
+				"format must be one of "+strings.Join(report.Formats, ", "))
+		}
+		if !config.Traffic.EnableAggregation {
+			v.addError("business.trafficReport.enabled", true, "traffic reports require traffic.enableAggregation")
+		}
+		for i, recipient := range config.TrafficReport.Recipients {
+			if _, err := mail.ParseAddress(recipient); err != nil {
+				v.addError(fmt.Sprintf("business.trafficReport.recipients[%d]", i), recipient, "invalid email address")
+			}
+		}
+	}
+
+	if config.SubscriptionSharing.Enabled {
+		v.validateDuration(config.SubscriptionSharing.Window, "business.subscriptionSharing.window")
+		v.validateDuration(config.SubscriptionSharing.CheckInterval, "business.subscriptionSharing.checkInterval")
+		if config.SubscriptionSharing.MaxDistinctIPs <= 0 {
+			v.addError("business.subscriptionSharing.maxDistinctIps", config.SubscriptionSharing.MaxDistinctIPs, "maxDistinctIps must be greater than 0")
+		}
+	}
+}
+
+func (v *Validator) validateGeoDataConfig(config configv1.GeoDataConfig) {
+	if !config.Enabled {
+		return
+	}
+
+	if config.CacheDir == "" || !filepath.IsAbs(config.CacheDir) {
+		v.addError("business.geoData.cacheDir", config.CacheDir, "cache directory must be an absolute path")
+	}
+	v.validateDuration(config.RefreshInterval, "business.geoData.refreshInterval")
+	v.validateDuration(config.DownloadTimeout, "business.geoData.downloadTimeout")
+	v.validateDuration(config.StaleAfter, "business.geoData.staleAfter")
+
+	if len(config.Sources) == 0 {
+		v.addError("business.geoData.sources", config.Sources, "at least one source is required")
+	}
+	names := make(map[string]bool)
+	for i, source := range config.Sources {
+		field := fmt.Sprintf("business.geoData.sources[%d]", i)
+		if !geodata.ValidName(source.Name) {
+			v.addError(field+".name", source.Name, "name must be a plain file name such as geoip.db")
+		} else if names[source.Name] {
+			v.addError(field+".name", source.Name, "name is used by another source")
+		}
+		names[source.Name] = true
+		v.validateHTTPURL(source.URL, field+".url")
+		if source.ChecksumURL != "" {
+			v.validateHTTPURL(source.ChecksumURL, field+".checksumUrl")
+		}
+	}
 }
 
 func (v *Validator) validateNodeInfo(config configv1.NodeInfo) {
-	if config.NodeID == "" {
-		v.addError("node.nodeId", config.NodeID, "node ID cannot be empty")
+	if config.NodeID == "" && config.EnrollmentToken == "" {
+		v.addError("node.nodeId", config.NodeID, "node ID cannot be empty without an enrollment token")
+	}
+	if config.NodeID == "" && !filepath.IsAbs(config.CredentialsFile) {
+		v.addError("node.credentialsFile", config.CredentialsFile, "file path must be absolute")
 	}
 
 	if config.NodeName == "" {
@@ -365,6 +1151,17 @@ func (v *Validator) validateNodeInfo(config configv1.NodeInfo) {
 	}
 }
 
+// validateNodeEnrollmentConfig validates the enrollment settings at field
+func (v *Validator) validateNodeEnrollmentConfig(config configv1.NodeEnrollmentConfig, field string) {
+	v.validateDuration(config.TokenTTL, field+".tokenTTL")
+	if config.AgentAPIAddress != "" {
+		if _, _, err := net.SplitHostPort(config.AgentAPIAddress); err != nil {
+			v.addError(field+".agentAPIAddress", config.AgentAPIAddress, "address must be host:port")
+		}
+	}
+	v.validateHTTPURL(config.InstallScriptURL, field+".installScriptURL")
+}
+
 func (v *Validator) validateSingBoxConfig(config configv1.SingBoxConfig) {
 	v.validateFilePath(config.BinaryPath, "singBox.binaryPath")
 	v.validateFilePath(config.ConfigPath, "singBox.configPath")
@@ -374,6 +1171,38 @@ func (v *Validator) validateSingBoxConfig(config configv1.SingBoxConfig) {
 		v.validateAddress(config.ClashAPI.Address, "singBox.clashApi.address")
 		v.validatePort(config.ClashAPI.Port, "singBox.clashApi.port")
 	}
+
+	switch config.SwapMode {
+	case configv1.SwapModeRestart:
+	case configv1.SwapModeBlueGreen:
+		v.validateDuration(config.BlueGreen.HealthDelay, "singBox.blueGreen.healthDelay")
+		if config.BlueGreen.DrainTimeout < 0 {
+			v.addError("singBox.blueGreen.drainTimeout", config.BlueGreen.DrainTimeout, "drain timeout cannot be negative")
+		}
+	default:
+		v.addError("singBox.swapMode", config.SwapMode, "swap mode must be 'restart' or 'blue-green'")
+	}
+
+	v.validateDuration(config.Rollback.StableAfter, "singBox.rollback.stableAfter")
+	if config.Rollback.MaxCrashes <= 0 {
+		v.addError("singBox.rollback.maxCrashes", config.Rollback.MaxCrashes, "max crashes must be greater than 0")
+	}
+}
+
+func (v *Validator) validateDiskGuardConfig(config configv1.DiskGuardConfig) {
+	v.validateDuration(config.CheckInterval, "disk.checkInterval")
+	if config.WarningPercent <= 0 || config.WarningPercent > 100 {
+		v.addError("disk.warningPercent", config.WarningPercent, "warning level must be between 0 and 100")
+	}
+	if config.CriticalPercent < config.WarningPercent || config.CriticalPercent > 100 {
+		v.addError("disk.criticalPercent", config.CriticalPercent, "critical level must be between the warning level and 100")
+	}
+	if config.LogMaxSize <= 0 {
+		v.addError("disk.logMaxSize", config.LogMaxSize, "log max size must be greater than 0")
+	}
+	if config.LogMaxBackups < 0 {
+		v.addError("disk.logMaxBackups", config.LogMaxBackups, "log max backups cannot be negative")
+	}
 }
 
 func (v *Validator) validateMonitorConfig(config configv1.MonitorConfig) {
@@ -388,6 +1217,10 @@ func (v *Validator) validateMonitorConfig(config configv1.MonitorConfig) {
 		v.addError("monitor.localCacheSize", config.LocalCacheSize, "local cache size must be greater than 0")
 	}
 
+	if config.SpoolPath != "" && config.SpoolMaxReports <= 0 {
+		v.addError("monitor.spoolMaxReports", config.SpoolMaxReports, "spool max reports must be greater than 0")
+	}
+
 	if config.MaxRetries <= 0 {
 		v.addError("monitor.maxRetries", config.MaxRetries, "max retries must be greater than 0")
 	}
@@ -449,8 +1282,27 @@ func (v *Validator) validateURL(urlStr, field string) {
 	}
 }
 
+// validateHTTPURL checks that urlStr is an absolute http or https URL
+func (v *Validator) validateHTTPURL(urlStr, field string) {
+	u, err := url.Parse(urlStr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.addError(field, urlStr, "must be an http or https URL")
+	}
+}
+
 // Helper functions
 
+// toCamelCase converts a snake_case model field to the camelCase used in configuration files
+func toCamelCase(field string) string {
+	parts := strings.Split(field, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// reloadDelay is how long a config file must stay unchanged before it is
+// reloaded, editors and deployment tools writing it in several steps
+const reloadDelay = 500 * time.Millisecond
+
+// Watch calls reload whenever the config file at path changes, until ctx is
+// done. The directory of the file is watched rather than the file, so that
+// files replaced by a rename, as editors and Kubernetes ConfigMap volumes do,
+// are still seen.
+func Watch(ctx context.Context, path string, logger *zap.Logger, reload func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch config directory: %w", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		// The target of a symlinked file may change while the link does not
+		target, _ := filepath.EvalSymlinks(path)
+		timer := time.NewTimer(reloadDelay)
+		timer.Stop()
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == path || changedTarget(path, &target) {
+					timer.Reset(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("Config watcher error", zap.Error(err))
+			case <-timer.C:
+				reload()
+			}
+		}
+	}()
+	return nil
+}
+
+// changedTarget reports whether the file path links to has changed since
+// target, updating target
+func changedTarget(path string, target *string) bool {
+	current, err := filepath.EvalSymlinks(path)
+	if err != nil || current == *target {
+		return false
+	}
+	*target = current
+	return true
+}
+
+// ChangedSections returns the yaml names of the top-level sections that
+// differ between two configs of the same struct type, leaving out the
+// sections in skip. It tells which changes of a reloaded config only apply
+// after a restart.
+func ChangedSections(old, new any, skip ...string) []string {
+	oldValue := reflect.Indirect(reflect.ValueOf(old))
+	newValue := reflect.Indirect(reflect.ValueOf(new))
+
+	var changed []string
+	for i := 0; i < oldValue.NumField(); i++ {
+		name, _, _ := strings.Cut(oldValue.Type().Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || slices.Contains(skip, name) {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "api.yaml")
+	if err := os.WriteFile(path, []byte("log:\n  level: info\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan struct{}, 4)
+	if err := Watch(ctx, path, zap.NewNop(), func() { reloads <- struct{}{} }); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	// Other files of the directory are ignored
+	if err := os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write other file: %v", err)
+	}
+	select {
+	case <-reloads:
+		t.Fatal("reloaded for another file")
+	case <-time.After(2 * reloadDelay):
+	}
+
+	// Several writes in a row reload once, as does replacing the file by a rename
+	for _, level := range []string{"debug", "warn"} {
+		if err := os.WriteFile(path, []byte("log:\n  level: "+level+"\n"), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+	}
+	waitReload(t, reloads)
+
+	replacement := filepath.Join(dir, "api.yaml.tmp")
+	if err := os.WriteFile(replacement, []byte("log:\n  level: error\n"), 0o644); err != nil {
+		t.Fatalf("write replacement: %v", err)
+	}
+	if err := os.Rename(replacement, path); err != nil {
+		t.Fatalf("rename replacement: %v", err)
+	}
+	waitReload(t, reloads)
+
+	select {
+	case <-reloads:
+		t.Error("reloaded more than once per change")
+	case <-time.After(2 * reloadDelay):
+	}
+}
+
+func waitReload(t *testing.T, reloads <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("config was not reloaded")
+	}
+}
+
+func TestChangedSections(t *testing.T) {
+	old := configv1.DefaultAPIConfig()
+	updated := configv1.DefaultAPIConfig()
+	updated.Log.Level = "debug"
+	updated.Business.Health.CheckInterval = time.Hour
+	updated.GRPC.Port++
+
+	changed := ChangedSections(old, updated, "log", "business")
+	if len(changed) != 1 || changed[0] != "grpc" {
+		t.Errorf("ChangedSections = %v, want [grpc]", changed)
+	}
+	if changed := ChangedSections(old, configv1.DefaultAPIConfig()); len(changed) != 0 {
+		t.Errorf("ChangedSections of equal configs = %v", changed)
+	}
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Retention periods of the data cleanup, in days
+const (
+	trafficRecordRetentionDays  = 30
+	trafficSummaryRetentionDays = 90
+	nodeProbeRetentionDays      = 7
+	shapingRecordRetentionDays  = 30
+	notificationRetentionDays   = 90
+	alertDeliveryRetentionDays  = 90
+	externalAlertRetentionDays  = 7
+	subscriptionAccessRetention = 30
+)
+
+// CleanupTarget reports the rows of one table removed by a cleanup, or that
+// would be removed by a dry run
+type CleanupTarget struct {
+	Name   string
+	Cutoff time.Time
+	Count  int64
+}
+
+// RunCleanup removes data past its retention period. With dryRun set it only
+// counts the rows that would be removed. Counts of a real run are taken right
+// before deleting, so rows expiring in between may be removed uncounted.
+// Failures are logged per table and the first one is returned.
+func (s *Service) RunCleanup(dryRun bool) ([]CleanupTarget, error) {
+	now := time.Now()
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+
+	steps := []struct {
+		name    string
+		cutoff  time.Time
+		count   func() (int64, error)
+		cleanup func() error
+	}{
+		{
+			name:    "traffic_records",
+			cutoff:  daysAgo(trafficRecordRetentionDays),
+			count:   func() (int64, error) { return s.repository.Traffic.CountOldRecords(trafficRecordRetentionDays) },
+			cleanup: func() error { return s.repository.Traffic.CleanupOldRecords(trafficRecordRetentionDays) },
+		},
+		{
+			name:    "traffic_summaries",
+			cutoff:  daysAgo(trafficSummaryRetentionDays),
+			count:   func() (int64, error) { return s.repository.Traffic.CountOldSummaries(trafficSummaryRetentionDays) },
+			cleanup: func() error { return s.repository.Traffic.CleanupOldSummaries(trafficSummaryRetentionDays) },
+		},
+		{
+			name:    "node_probes",
+			cutoff:  daysAgo(nodeProbeRetentionDays),
+			count:   func() (int64, error) { return s.repository.Probe.CountOldProbes(nodeProbeRetentionDays) },
+			cleanup: func() error { return s.repository.Probe.CleanupOldProbes(nodeProbeRetentionDays) },
+		},
+		{
+			name:    "shaping_records",
+			cutoff:  daysAgo(shapingRecordRetentionDays),
+			count:   func() (int64, error) { return s.repository.Shaping.CountOldRecords(shapingRecordRetentionDays) },
+			cleanup: func() error { return s.repository.Shaping.CleanupOldRecords(shapingRecordRetentionDays) },
+		},
+		{
+			name:    "notifications",
+			cutoff:  daysAgo(notificationRetentionDays),
+			count:   func() (int64, error) { return s.repository.Notification.CountOldNotifications(notificationRetentionDays) },
+			cleanup: func() error { return s.repository.Notification.CleanupOldNotifications(notificationRetentionDays) },
+		},
+		{
+			name:    "alert_deliveries",
+			cutoff:  daysAgo(alertDeliveryRetentionDays),
+			count:   func() (int64, error) { return s.repository.AlertDelivery.CountOldDeliveries(alertDeliveryRetentionDays) },
+			cleanup: func() error { return s.repository.AlertDelivery.CleanupOldDeliveries(alertDeliveryRetentionDays) },
+		},
+		{
+			name:    "external_alerts",
+			cutoff:  daysAgo(externalAlertRetentionDays),
+			count:   func() (int64, error) { return s.repository.ExternalAlert.CountOldResolved(externalAlertRetentionDays) },
+			cleanup: func() error { return s.repository.ExternalAlert.CleanupOldResolved(externalAlertRetentionDays) },
+		},
+		{
+			name:    "subscription_access_logs",
+			cutoff:  daysAgo(subscriptionAccessRetention),
+			count:   func() (int64, error) { return s.repository.SubscriptionLog.CountOldLogs(subscriptionAccessRetention) },
+			cleanup: func() error { return s.repository.SubscriptionLog.CleanupOldLogs(subscriptionAccessRetention) },
+		},
+		{
+			name:   "revoked_tokens",
+			cutoff: now,
+			count:  s.repository.Token.CountExpired,
+			cleanup: func() error {
+				_, err := s.repository.Token.DeleteExpired()
+				return err
+			},
+		},
+	}
+
+	targets := make([]CleanupTarget, 0, len(steps))
+	var firstErr error
+	for _, step := range steps {
+		count, err := step.count()
+		if err == nil && !dryRun && count > 0 {
+			err = step.cleanup()
+		}
+		if err != nil {
+			// Tables are cleaned independently, one failure does not stop the others
+			s.logger.Error("Failed to cleanup old data", zap.String("target", step.name), zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		targets = append(targets, CleanupTarget{Name: step.name, Cutoff: step.cutoff, Count: count})
+		if count > 0 {
+			s.logger.Info("Data cleanup",
+				zap.String("target", step.name),
+				zap.Int64("count", count),
+				zap.Bool("dry_run", dryRun),
+			)
+		}
+	}
+	return targets, firstErr
+}
@@ -4,14 +4,14 @@ import (
 	"fmt"
 	"time"
 
-	"gorm.io/driver/mysql"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"go.uber.org/zap"
 
 	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/migration"
 	"sing-box-web/pkg/models"
 	"sing-box-web/pkg/repository"
+	"sing-box-web/pkg/tracing"
 )
 
 // Service represents the database service
@@ -20,10 +20,53 @@ type Service struct {
 	repository *repository.Manager
 	logger     *zap.Logger
 	config     configv1.DatabaseConfig
+
+	// stopStats stops the export of the connection pool metrics
+	stopStats chan struct{}
 }
 
 // New creates a new database service
 func New(config configv1.DatabaseConfig, logger *zap.Logger) (*Service, error) {
+	queries := &metricsPlugin{slowThreshold: config.SlowQueryThreshold, logger: logger.Named("database")}
+	db, err := open(config, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	service := &Service{
+		db:         db,
+		repository: repository.NewManager(db),
+		logger:     logger,
+		config:     config,
+	}
+
+	if len(config.Tenants) > 0 {
+		// Each tenant database has its own pool, a busy tenant cannot starve the others
+		tenants := make(map[uint]*gorm.DB, len(config.Tenants))
+		for _, tenant := range config.Tenants {
+			tenantDB, err := open(tenant.DatabaseConfig, queries)
+			if err != nil {
+				repository.NewTenantDatabases(db, tenants).Close()
+				closeDB(db)
+				return nil, fmt.Errorf("tenant %d: %w", tenant.TenantID, err)
+			}
+			tenants[tenant.TenantID] = tenantDB
+		}
+		service.repository.EnableTenantDatabases(tenants)
+		logger.Info("Tenant databases enabled", zap.Int("tenants", len(tenants)))
+	}
+
+	if config.StatsInterval > 0 {
+		service.stopStats = make(chan struct{})
+		go service.exportPoolStats(config.StatsInterval, service.stopStats)
+	}
+
+	return service, nil
+}
+
+// open connects to a database and configures its connection pool, recording
+// its queries with the metrics plugin
+func open(config configv1.DatabaseConfig, queries *metricsPlugin) (*gorm.DB, error) {
 	// Configure GORM
 	gormConfig := &gorm.Config{
 		NowFunc: func() time.Time {
@@ -32,29 +75,24 @@ func New(config configv1.DatabaseConfig, logger *zap.Logger) (*Service, error) {
 		DisableForeignKeyConstraintWhenMigrating: true,
 	}
 
-	var db *gorm.DB
-	var err error
-
-	switch config.Driver {
-	case "sqlite":
-		db, err = gorm.Open(sqlite.Open(config.Database), gormConfig)
-	case "mysql":
-		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-			config.Username,
-			config.Password,
-			config.Host,
-			config.Port,
-			config.Database,
-		)
-		db, err = gorm.Open(mysql.Open(dsn), gormConfig)
-	default:
-		return nil, fmt.Errorf("unsupported database driver: %s", config.Driver)
+	dialector, err := models.Dialector(config)
+	if err != nil {
+		return nil, err
 	}
 
+	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
+	// Trace the queries run within traced requests
+	if err := db.Use(tracing.GORMPlugin()); err != nil {
+		return nil, fmt.Errorf("failed to register database tracing: %w", err)
+	}
+	if err := db.Use(queries); err != nil {
+		return nil, fmt.Errorf("failed to register database metrics: %w", err)
+	}
+
 	// Configure connection pool
 	sqlDB, err := db.DB()
 	if err != nil {
@@ -67,55 +105,130 @@ func New(config configv1.DatabaseConfig, logger *zap.Logger) (*Service, error) {
 
 	// Test connection
 	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	service := &Service{
-		db:         db,
-		repository: repository.NewManager(db),
-		logger:     logger,
-		config:     config,
-	}
+	return db, nil
+}
 
-	return service, nil
+// closeDB closes a database opened by open
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
 }
 
-// AutoMigrate runs database migrations
-func (s *Service) AutoMigrate() error {
+// Migrate applies the pending migrations to the shared database and the
+// tenant databases
+func (s *Service) Migrate() error {
 	s.logger.Info("Starting database migration")
-	
-	err := s.db.AutoMigrate(
-		&models.Plan{},
-		&models.PlanFeature{},
-		&models.User{},
-		&models.Node{},
-		&models.UserNode{},
-		&models.TrafficRecord{},
-		&models.TrafficSummary{},
-		&models.TrafficQuota{},
-		&models.NodeLog{},
-		&models.PlanNodeAccess{},
-	)
-	
-	if err != nil {
-		s.logger.Error("Database migration failed", zap.Error(err))
-		return fmt.Errorf("failed to migrate database: %w", err)
+
+	for _, schema := range s.schemas() {
+		applied, err := schema.migrator.Up()
+		for _, m := range applied {
+			s.logger.Info("Applied migration",
+				zap.String("database", schema.name),
+				zap.Uint("version", m.Version),
+				zap.String("description", m.Description),
+			)
+		}
+		if err != nil {
+			s.logger.Error("Database migration failed", zap.String("database", schema.name), zap.Error(err))
+			return fmt.Errorf("failed to migrate %s database: %w", schema.name, err)
+		}
 	}
-	
+
 	s.logger.Info("Database migration completed successfully")
 	return nil
 }
 
-// InitializeData creates default data
-func (s *Service) InitializeData() error {
-	s.logger.Info("Initializing default data")
-	
-	if err := s.repository.InitializeDefaultData(); err != nil {
-		s.logger.Error("Failed to initialize default data", zap.Error(err))
+// CheckSchema fails unless every database is at the schema version of this
+// release, for servers that leave migrations to the migrate command
+func (s *Service) CheckSchema() error {
+	for _, schema := range s.schemas() {
+		if err := schema.migrator.Check(); err != nil {
+			return fmt.Errorf("%s database: %w", schema.name, err)
+		}
+	}
+	return nil
+}
+
+// PrepareSchema applies the pending migrations when automatic migrations
+// are enabled, and otherwise refuses a schema of another version
+func (s *Service) PrepareSchema() error {
+	if s.config.AutoMigrate {
+		return s.Migrate()
+	}
+	if err := s.CheckSchema(); err != nil {
+		return fmt.Errorf("%w, run sing-box-api migrate up", err)
+	}
+	return nil
+}
+
+// Rollback reverts the last steps migrations of the shared database. Tenant
+// databases are left as they are.
+func (s *Service) Rollback(steps int) ([]migration.Migration, error) {
+	return s.schemas()[0].migrator.Down(steps)
+}
+
+// SchemaStatus returns the state of the migrations of every database, by database name
+func (s *Service) SchemaStatus() (map[string][]migration.Status, error) {
+	statuses := make(map[string][]migration.Status)
+	for _, schema := range s.schemas() {
+		status, err := schema.migrator.Status()
+		if err != nil {
+			return nil, fmt.Errorf("%s database: %w", schema.name, err)
+		}
+		statuses[schema.name] = status
+	}
+	return statuses, nil
+}
+
+// schema is a database and its migrations
+type schema struct {
+	name     string
+	migrator *migration.Migrator
+}
+
+// schemas returns the shared database, then the tenant databases in order
+func (s *Service) schemas() []schema {
+	schemas := []schema{{name: "shared", migrator: mustMigrator(s.db, migration.Shared)}}
+	if tenants := s.repository.TenantDatabases(); tenants != nil {
+		for _, tenantID := range tenants.TenantIDs() {
+			schemas = append(schemas, schema{
+				name:     fmt.Sprintf("tenant %d", tenantID),
+				migrator: mustMigrator(tenants.DB(&tenantID), migration.Tenant),
+			})
+		}
+	}
+	return schemas
+}
+
+// mustMigrator creates a migrator of migrations known to be well ordered
+func mustMigrator(db *gorm.DB, migrations []migration.Migration) *migration.Migrator {
+	migrator, err := migration.New(db, migrations)
+	if err != nil {
+		panic(err)
+	}
+	return migrator
+}
+
+// EnableAnalytics connects the analytics store and serves the traffic
+// summary queries from it
+func (s *Service) EnableAnalytics(config configv1.AnalyticsConfig) error {
+	store, err := repository.NewAnalyticsStore(config)
+	if err != nil {
 		return err
 	}
-	
-	s.logger.Info("Default data initialized successfully")
+
+	if err := store.Migrate(); err != nil {
+		store.Close()
+		return err
+	}
+
+	s.repository.EnableAnalytics(store)
+	s.logger.Info("Analytics storage enabled", zap.String("driver", config.Driver))
 	return nil
 }
 
@@ -137,6 +250,10 @@ func (s *Service) Health() error {
 // Close closes the database connection
 func (s *Service) Close() error {
 	s.logger.Info("Closing database connection")
+	if s.stopStats != nil {
+		close(s.stopStats)
+		s.stopStats = nil
+	}
 	return s.repository.Close()
 }
 
@@ -170,28 +287,13 @@ func (s *Service) StartMaintenanceTasks() {
 func (s *Service) runMaintenanceTasks() {
 	s.logger.Info("Starting maintenance tasks")
 	
-	// Cleanup old traffic records (keep 30 days)
-	if err := s.repository.Traffic.CleanupOldRecords(30); err != nil {
-		s.logger.Error("Failed to cleanup old traffic records", zap.Error(err))
-	}
-	
-	// Cleanup old summaries (keep 90 days)
-	if err := s.repository.Traffic.CleanupOldSummaries(90); err != nil {
-		s.logger.Error("Failed to cleanup old traffic summaries", zap.Error(err))
-	}
+	// Remove data past its retention period, failures are logged per table
+	s.RunCleanup(false)
 	
-	// Aggregate daily data for yesterday
+	// Summaries are maintained incrementally, check yesterday's for drift
 	yesterday := time.Now().AddDate(0, 0, -1)
-	if err := s.repository.Traffic.AggregateDailyData(yesterday); err != nil {
-		s.logger.Error("Failed to aggregate daily traffic data", zap.Error(err))
-	}
-	
-	// Aggregate monthly data for last month (on the 1st of each month)
-	if time.Now().Day() == 1 {
-		lastMonth := time.Now().AddDate(0, -1, 0)
-		if err := s.repository.Traffic.AggregateMonthlyData(lastMonth); err != nil {
-			s.logger.Error("Failed to aggregate monthly traffic data", zap.Error(err))
-		}
+	if _, err := s.repository.Traffic.CheckSummaries(yesterday); err != nil {
+		s.logger.Error("Failed to check traffic summaries", zap.Error(err))
 	}
 	
 	s.logger.Info("Maintenance tasks completed")
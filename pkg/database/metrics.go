@@ -0,0 +1,103 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/metrics"
+)
+
+// queryStartKey is the instance setting holding the start of a statement
+const queryStartKey = "metrics:start"
+
+// metricsPlugin records the duration and outcome of each database operation
+// as Prometheus metrics and logs the operations slower than slowThreshold
+type metricsPlugin struct {
+	slowThreshold time.Duration
+	logger        *zap.Logger
+}
+
+// Name returns the name of the plugin
+func (p *metricsPlugin) Name() string {
+	return "metrics"
+}
+
+// Initialize registers the callbacks around each kind of operation
+func (p *metricsPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", startQuery),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", p.endQuery("create")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", startQuery),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", p.endQuery("query")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", startQuery),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", p.endQuery("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", startQuery),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", p.endQuery("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", startQuery),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", p.endQuery("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", startQuery),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", p.endQuery("raw")),
+	)
+}
+
+// startQuery notes the start of an operation
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+// endQuery records an operation once it completed
+func (p *metricsPlugin) endQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		duration := time.Since(value.(time.Time))
+
+		status := "success"
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			status = "error"
+		}
+		metrics.RecordDBQuery(operation, status, duration)
+
+		if p.slowThreshold > 0 && duration >= p.slowThreshold {
+			// The statement holds placeholders, not the values bound to them
+			p.logger.Warn("Slow database query",
+				zap.String("operation", operation),
+				zap.String("table", db.Statement.Table),
+				zap.String("sql", db.Statement.SQL.String()),
+				zap.Int64("rows", db.Statement.RowsAffected),
+				zap.Duration("duration", duration),
+				zap.String("status", status),
+			)
+		}
+	}
+}
+
+// exportPoolStats periodically exports the connection counts of the shared
+// database's pool until stop is closed
+func (s *Service) exportPoolStats(interval time.Duration, stop <-chan struct{}) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		s.logger.Error("Failed to get database pool for metrics", zap.Error(err))
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		stats := sqlDB.Stats()
+		metrics.SetDBConnections(stats.OpenConnections, stats.Idle, stats.InUse)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
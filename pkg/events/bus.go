@@ -0,0 +1,339 @@
+// Package events is the bus of the domain events services emit, such as
+// user.created, node.offline or traffic.reported. Alerts, notifications and
+// the dashboards' WebSocket push consume the same stream.
+//
+// The bus is in-memory unless given a Backend: the API server then publishes
+// to Redis Streams and web servers read the stream directly. Otherwise the API
+// server streams its events with ManagementService.WatchEvents and the web
+// server relays that stream to a bus of its own.
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Topics of events, subscriptions receive the events of their topics
+const (
+	// TopicNodes carries node status changes
+	TopicNodes = "nodes"
+	// TopicAlerts carries the alerts raised for users
+	TopicAlerts = "alerts"
+	// TopicTraffic carries the traffic written by each ingestion flush
+	TopicTraffic = "traffic"
+	// TopicUsers carries account changes
+	TopicUsers = "users"
+)
+
+// Types of domain events, each belonging to one topic
+const (
+	TypeUserCreated     = "user.created"
+	TypeNodeOnline      = "node.online"
+	TypeNodeOffline     = "node.offline"
+	TypeNodeDegraded    = "node.degraded"
+	TypeNodeMaintenance = "node.maintenance"
+	TypeNodeFlagged     = "node.flagged"
+	TypeTrafficReported = "traffic.reported"
+	TypeAlertRaised     = "alert.raised"
+)
+
+// Types returns every event type
+func Types() []string {
+	return []string{
+		TypeUserCreated, TypeNodeOnline, TypeNodeOffline, TypeNodeDegraded,
+		TypeNodeMaintenance, TypeNodeFlagged, TypeTrafficReported, TypeAlertRaised,
+	}
+}
+
+// IsValidType checks if the event type is known
+func IsValidType(eventType string) bool {
+	for _, known := range Types() {
+		if eventType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// typeTopics maps the subject of an event type to its topic
+var typeTopics = map[string]string{
+	"user":    TopicUsers,
+	"node":    TopicNodes,
+	"traffic": TopicTraffic,
+	"alert":   TopicAlerts,
+}
+
+// TopicOf returns the topic of an event type, empty for unknown types
+func TopicOf(eventType string) string {
+	subject, _, _ := strings.Cut(eventType, ".")
+	return typeTopics[subject]
+}
+
+// Topics returns every topic
+func Topics() []string {
+	return []string{TopicNodes, TopicAlerts, TopicTraffic, TopicUsers}
+}
+
+// IsValidTopic checks if the topic is known
+func IsValidTopic(topic string) bool {
+	switch topic {
+	case TopicNodes, TopicAlerts, TopicTraffic, TopicUsers:
+		return true
+	}
+	return false
+}
+
+// ParseTopics parses a comma-separated topic list, reporting the first
+// unknown topic. An empty list is every topic.
+func ParseTopics(list string) ([]string, string) {
+	var topics []string
+	for _, topic := range strings.Split(list, ",") {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		if !IsValidTopic(topic) {
+			return nil, topic
+		}
+		topics = append(topics, topic)
+	}
+	if len(topics) == 0 {
+		return Topics(), ""
+	}
+	return topics, ""
+}
+
+// Backend carries encoded events between the processes sharing a bus
+type Backend interface {
+	// Publish sends an event to every process receiving from the backend,
+	// this one included
+	Publish(ctx context.Context, data []byte) error
+	// Receive passes the events published since the previous call to
+	// deliver, until ctx is done or the connection fails
+	Receive(ctx context.Context, deliver func(data []byte)) error
+	// Close releases the connections of the backend
+	Close() error
+}
+
+const (
+	// backendQueueSize is the number of events waiting to be sent to the backend
+	backendQueueSize = 1024
+	// backendRetryInterval is the wait before receiving again after a failure
+	backendRetryInterval = 5 * time.Second
+)
+
+// New creates the event bus selected by the configuration
+func New(config configv1.EventBusConfig, logger *zap.Logger) (*Bus, error) {
+	switch config.Backend {
+	case "", "memory":
+		return NewBus(), nil
+	case "redis":
+		return NewBackendBus(NewRedisBackend(config.Redis), logger), nil
+	default:
+		return nil, fmt.Errorf("unsupported event bus backend: %s", config.Backend)
+	}
+}
+
+// Bus fans published events out to the subscriptions of their topic.
+// Publishing never blocks: a subscriber that does not keep up loses events.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+
+	// backend carries events between processes, nil for an in-memory bus
+	backend Backend
+	queue   chan []byte
+	logger  *zap.Logger
+}
+
+// NewBus creates an in-memory event bus
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// NewBackendBus creates an event bus publishing through backend. Events are
+// only sent and received while Run is running.
+func NewBackendBus(backend Backend, logger *zap.Logger) *Bus {
+	return &Bus{
+		subs:    make(map[*Subscription]struct{}),
+		backend: backend,
+		queue:   make(chan []byte, backendQueueSize),
+		logger:  logger,
+	}
+}
+
+// Publish sends the event to the subscriptions of its topic, setting its
+// time when empty and its topic from its type
+func (b *Bus) Publish(event *pbv1.Event) {
+	if event.Time == nil {
+		event.Time = timestamppb.New(time.Now())
+	}
+	if event.Topic == "" {
+		event.Topic = TopicOf(event.Type)
+	}
+
+	if b.backend == nil {
+		b.dispatch(event)
+		return
+	}
+
+	data, err := proto.Marshal(event)
+	if err != nil {
+		b.logger.Error("Failed to encode event", zap.Error(err), zap.String("type", event.Type))
+		return
+	}
+	select {
+	case b.queue <- data:
+	default:
+		// The backend does not keep up, at least this process gets the event
+		b.dispatch(event)
+	}
+}
+
+// Run sends and receives the events of the backend until ctx is done,
+// reconnecting after failures. It returns at once for an in-memory bus.
+func (b *Bus) Run(ctx context.Context) {
+	if b.backend == nil {
+		return
+	}
+	defer b.backend.Close()
+
+	go b.send(ctx)
+	for {
+		err := b.backend.Receive(ctx, b.deliver)
+		if ctx.Err() != nil {
+			return
+		}
+		b.logger.Warn("Failed to receive events from the event bus backend", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backendRetryInterval):
+		}
+	}
+}
+
+// send publishes the queued events to the backend. Events the backend
+// rejects are still dispatched to this process.
+func (b *Bus) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-b.queue:
+			if err := b.backend.Publish(ctx, data); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				b.logger.Warn("Failed to publish event to the event bus backend", zap.Error(err))
+				b.deliver(data)
+			}
+		}
+	}
+}
+
+// deliver dispatches an event received from the backend
+func (b *Bus) deliver(data []byte) {
+	event := &pbv1.Event{}
+	if err := proto.Unmarshal(data, event); err != nil {
+		b.logger.Warn("Failed to decode event", zap.Error(err))
+		return
+	}
+	b.dispatch(event)
+}
+
+// dispatch sends the event to the subscriptions of its topic
+func (b *Bus) dispatch(event *pbv1.Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subs {
+		if !sub.Has(event.Topic) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe subscribes to topics, buffering up to buffer events
+func (b *Bus) Subscribe(topics []string, buffer int) *Subscription {
+	sub := &Subscription{bus: b, ch: make(chan *pbv1.Event, buffer)}
+	sub.SetTopics(topics)
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Subscribers returns the number of open subscriptions
+func (b *Bus) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
+
+// Subscription receives the events of its topics until closed
+type Subscription struct {
+	bus *Bus
+	ch  chan *pbv1.Event
+
+	mu     sync.RWMutex
+	topics map[string]bool
+
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Events returns the channel of received events, closed by Close
+func (s *Subscription) Events() <-chan *pbv1.Event {
+	return s.ch
+}
+
+// SetTopics replaces the subscribed topics
+func (s *Subscription) SetTopics(topics []string) {
+	set := make(map[string]bool, len(topics))
+	for _, topic := range topics {
+		set[topic] = true
+	}
+	s.mu.Lock()
+	s.topics = set
+	s.mu.Unlock()
+}
+
+// Has reports whether the subscription receives topic
+func (s *Subscription) Has(topic string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.topics[topic]
+}
+
+// Dropped returns the number of events lost because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the event channel
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.ch)
+	})
+}
@@ -0,0 +1,165 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	nodes := bus.Subscribe([]string{TopicNodes}, 4)
+	all := bus.Subscribe(Topics(), 4)
+	if got := bus.Subscribers(); got != 2 {
+		t.Fatalf("Subscribers() = %d, want 2", got)
+	}
+
+	bus.Publish(&pbv1.Event{Topic: TopicNodes})
+	bus.Publish(&pbv1.Event{Topic: TopicTraffic})
+
+	if got := len(nodes.Events()); got != 1 {
+		t.Errorf("nodes subscription received %d events, want 1", got)
+	}
+	if got := len(all.Events()); got != 2 {
+		t.Errorf("all topics subscription received %d events, want 2", got)
+	}
+	if event := <-nodes.Events(); event.Time == nil {
+		t.Error("published event has no time")
+	}
+
+	// Changed topics apply to the next events
+	nodes.SetTopics([]string{TopicAlerts})
+	bus.Publish(&pbv1.Event{Topic: TopicNodes})
+	bus.Publish(&pbv1.Event{Topic: TopicAlerts})
+	if event := <-nodes.Events(); event.Topic != TopicAlerts {
+		t.Errorf("received topic %q after SetTopics, want %q", event.Topic, TopicAlerts)
+	}
+}
+
+func TestBusDropsForSlowSubscribers(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe([]string{TopicTraffic}, 2)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(&pbv1.Event{Topic: TopicTraffic})
+	}
+	if got := len(sub.Events()); got != 2 {
+		t.Errorf("buffered %d events, want 2", got)
+	}
+	if got := sub.Dropped(); got != 3 {
+		t.Errorf("Dropped() = %d, want 3", got)
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(Topics(), 1)
+	sub.Close()
+	sub.Close()
+
+	if got := bus.Subscribers(); got != 0 {
+		t.Errorf("Subscribers() after Close = %d, want 0", got)
+	}
+	if _, ok := <-sub.Events(); ok {
+		t.Error("event channel is open after Close")
+	}
+	// Publishing after Close must not panic on the closed channel
+	bus.Publish(&pbv1.Event{Topic: TopicNodes})
+}
+
+func TestParseTopics(t *testing.T) {
+	if topics, unknown := ParseTopics(""); unknown != "" || len(topics) != len(Topics()) {
+		t.Errorf("ParseTopics(\"\") = %v, %q, want every topic", topics, unknown)
+	}
+	if topics, unknown := ParseTopics(" nodes, alerts "); unknown != "" || len(topics) != 2 {
+		t.Errorf("ParseTopics() = %v, %q, want nodes and alerts", topics, unknown)
+	}
+	if _, unknown := ParseTopics("nodes,logs"); unknown != "logs" {
+		t.Errorf("ParseTopics() unknown = %q, want logs", unknown)
+	}
+}
+
+func TestTopicOf(t *testing.T) {
+	tests := map[string]string{
+		TypeUserCreated:     TopicUsers,
+		TypeNodeOffline:     TopicNodes,
+		TypeTrafficReported: TopicTraffic,
+		TypeAlertRaised:     TopicAlerts,
+		"order.paid":        "",
+	}
+	for eventType, want := range tests {
+		if got := TopicOf(eventType); got != want {
+			t.Errorf("TopicOf(%q) = %q, want %q", eventType, got, want)
+		}
+	}
+
+	// Publishing sets the topic from the type
+	bus := NewBus()
+	sub := bus.Subscribe([]string{TopicNodes}, 1)
+	bus.Publish(&pbv1.Event{Type: TypeNodeOffline})
+	if event := <-sub.Events(); event.Topic != TopicNodes {
+		t.Errorf("published event topic = %q, want %q", event.Topic, TopicNodes)
+	}
+}
+
+// loopBackend delivers published events back to the receiving bus
+type loopBackend struct {
+	published chan []byte
+	fail      bool
+}
+
+func (b *loopBackend) Publish(ctx context.Context, data []byte) error {
+	if b.fail {
+		return errors.New("backend down")
+	}
+	b.published <- data
+	return nil
+}
+
+func (b *loopBackend) Receive(ctx context.Context, deliver func(data []byte)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case data := <-b.published:
+			deliver(data)
+		}
+	}
+}
+
+func (b *loopBackend) Close() error {
+	return nil
+}
+
+func TestBackendBus(t *testing.T) {
+	for _, fail := range []bool{false, true} {
+		backend := &loopBackend{published: make(chan []byte, 1), fail: fail}
+		bus := NewBackendBus(backend, zap.NewNop())
+		sub := bus.Subscribe([]string{TopicUsers}, 2)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go bus.Run(ctx)
+
+		// Events go through the backend, or straight to this process when it fails
+		bus.Publish(&pbv1.Event{Type: TypeUserCreated, User: &pbv1.UserEvent{Username: "alice"}})
+		select {
+		case event := <-sub.Events():
+			if event.GetUser().GetUsername() != "alice" || event.Topic != TopicUsers {
+				t.Errorf("received %v, want the user.created event of alice", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event received (backend failing: %v)", fail)
+		}
+		select {
+		case event := <-sub.Events():
+			t.Errorf("event received twice: %v", event)
+		case <-time.After(50 * time.Millisecond):
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Fields flattens an event into its fields named by their dotted proto
+// paths, such as type, node_status.status or alert.severity. Lists of values
+// are joined with commas and lists of messages are left out.
+func Fields(event *pbv1.Event) (map[string]string, error) {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	// Numbers keep their text, 1000000 does not become 1e+06
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var decoded map[string]any
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	fields := make(map[string]string)
+	flatten(fields, "", decoded)
+	return fields, nil
+}
+
+// flatten adds the values of object to fields, prefixing their names
+func flatten(fields map[string]string, prefix string, object map[string]any) {
+	for name, value := range object {
+		switch value := value.(type) {
+		case map[string]any:
+			flatten(fields, prefix+name+".", value)
+		case []any:
+			values := make([]string, 0, len(value))
+			for _, item := range value {
+				if _, ok := item.(map[string]any); ok {
+					values = nil
+					break
+				}
+				values = append(values, fmt.Sprint(item))
+			}
+			if values != nil {
+				fields[prefix+name] = strings.Join(values, ",")
+			}
+		default:
+			fields[prefix+name] = fmt.Sprint(value)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package events
+
+import (
+	"testing"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestFields(t *testing.T) {
+	fields, err := Fields(&pbv1.Event{
+		Type: TypeNodeDegraded,
+		NodeStatus: &pbv1.NodeStatusEvent{
+			NodeId:      "3",
+			Status:      "degraded",
+			HealthScore: 1000000,
+		},
+		NodeWitness: &pbv1.NodeWitnessEvent{Findings: []string{"traffic", "probe"}, TrafficBytes: 42},
+		Traffic:     &pbv1.TrafficEvent{Nodes: []*pbv1.NodeTrafficCounter{{NodeId: "3"}}},
+	})
+	if err != nil {
+		t.Fatalf("Fields: %v", err)
+	}
+
+	want := map[string]string{
+		"type":                       TypeNodeDegraded,
+		"node_status.node_id":        "3",
+		"node_status.status":         "degraded",
+		"node_status.health_score":   "1000000",
+		"node_witness.findings":      "traffic,probe",
+		"node_witness.traffic_bytes": "42",
+	}
+	for name, value := range want {
+		if fields[name] != value {
+			t.Errorf("%s = %q, want %q", name, fields[name], value)
+		}
+	}
+	if _, ok := fields["traffic.nodes"]; ok {
+		t.Error("lists of messages must be left out")
+	}
+	if _, ok := fields["node_status.reason"]; ok {
+		t.Error("unset fields must be left out")
+	}
+}
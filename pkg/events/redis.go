@@ -0,0 +1,358 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// redisBlockTime is how long a stream read waits for new events
+const redisBlockTime = 5 * time.Second
+
+// redisField is the stream entry field holding the encoded event
+const redisField = "event"
+
+// RedisBackend publishes events to a Redis stream and reads them back,
+// speaking the few commands it needs of the Redis protocol
+type RedisBackend struct {
+	config configv1.RedisStreamConfig
+
+	// pub is the client events are published with
+	pub *RedisClient
+
+	// lastID is the last entry read, reads resume after it on reconnection
+	lastID string
+}
+
+// NewRedisBackend creates a Redis Streams backend, connecting on first use
+func NewRedisBackend(config configv1.RedisStreamConfig) *RedisBackend {
+	return &RedisBackend{config: config, pub: NewRedisClient(config.Connection())}
+}
+
+// Publish appends the event to the stream, trimming it to about MaxLen entries
+func (r *RedisBackend) Publish(ctx context.Context, data []byte) error {
+	args := []string{"XADD", r.config.Stream}
+	if r.config.MaxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.FormatInt(r.config.MaxLen, 10))
+	}
+	args = append(args, "*", redisField, string(data))
+
+	_, err := r.pub.Do(ctx, args...)
+	return err
+}
+
+// Receive reads the stream on a connection of its own until ctx is done or
+// the connection fails
+func (r *RedisBackend) Receive(ctx context.Context, deliver func(data []byte)) error {
+	conn, err := dialRedis(ctx, r.config.Connection())
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+
+	// Unblock the pending read when ctx is done
+	stop := context.AfterFunc(ctx, conn.close)
+	defer stop()
+
+	// Start after the current last entry; "$" would skip the entries added
+	// between two reads
+	if r.lastID == "" {
+		if r.lastID, err = redisLastID(conn, r.config.Stream); err != nil {
+			return err
+		}
+	}
+
+	block := strconv.FormatInt(redisBlockTime.Milliseconds(), 10)
+	for {
+		conn.setDeadline(redisBlockTime + r.config.DialTimeout)
+		reply, err := conn.do("XREAD", "COUNT", "100", "BLOCK", block, "STREAMS", r.config.Stream, r.lastID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		// Timed out without new entries
+		if reply == nil {
+			continue
+		}
+
+		entries, err := redisStreamEntries(reply)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			r.lastID = entry.id
+			if data, ok := entry.fields[redisField]; ok {
+				deliver([]byte(data))
+			}
+		}
+	}
+}
+
+// redisLastID returns the ID of the last entry of the stream, "0-0" when empty
+func redisLastID(conn *redisConn, stream string) (string, error) {
+	reply, err := conn.do("XREVRANGE", stream, "+", "-", "COUNT", "1")
+	if err != nil {
+		return "", err
+	}
+	entries, ok := reply.([]any)
+	if !ok {
+		return "", fmt.Errorf("redis: malformed XREVRANGE reply")
+	}
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	entry, ok := entries[0].([]any)
+	if !ok || len(entry) != 2 {
+		return "", fmt.Errorf("redis: malformed XREVRANGE reply")
+	}
+	id, ok := entry[0].(string)
+	if !ok {
+		return "", fmt.Errorf("redis: malformed XREVRANGE reply")
+	}
+	return id, nil
+}
+
+// Close closes the publishing connection
+func (r *RedisBackend) Close() error {
+	return r.pub.Close()
+}
+
+// RedisClient runs commands on a single Redis connection, dialed on demand
+// and dialed again after it failed. Commands are sent one at a time.
+type RedisClient struct {
+	config configv1.RedisConfig
+
+	mu   sync.Mutex
+	conn *redisConn
+}
+
+// NewRedisClient creates a Redis client, connecting on first use
+func NewRedisClient(config configv1.RedisConfig) *RedisClient {
+	return &RedisClient{config: config}
+}
+
+// Do sends a command and returns its reply: a string, an int64, nil or a
+// []any of those. Error replies of the server are returned as errors.
+func (c *RedisClient) Do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		conn, err := dialRedis(ctx, c.config)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+
+	c.conn.setDeadline(c.config.DialTimeout)
+	reply, err := c.conn.do(args...)
+	if err != nil {
+		// The connection may be out of sync, dial again next time
+		var redisErr redisError
+		if !errors.As(err, &redisErr) {
+			c.conn.close()
+			c.conn = nil
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Close closes the connection
+func (c *RedisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		c.conn.close()
+		c.conn = nil
+	}
+	return nil
+}
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisConn is a connection speaking RESP, the Redis protocol
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// dialRedis connects, authenticates and selects the database
+func dialRedis(ctx context.Context, config configv1.RedisConfig) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: config.DialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	c := newRedisConn(conn)
+	c.setDeadline(config.DialTimeout)
+
+	if config.Password != "" {
+		args := []string{"AUTH", config.Password}
+		if config.Username != "" {
+			args = []string{"AUTH", config.Username, config.Password}
+		}
+		if _, err := c.do(args...); err != nil {
+			c.close()
+			return nil, fmt.Errorf("failed to authenticate to redis: %w", err)
+		}
+	}
+	if config.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(config.DB)); err != nil {
+			c.close()
+			return nil, fmt.Errorf("failed to select redis database: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func newRedisConn(conn net.Conn) *redisConn {
+	return &redisConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+}
+
+func (c *redisConn) setDeadline(timeout time.Duration) {
+	c.conn.SetDeadline(time.Now().Add(timeout))
+}
+
+func (c *redisConn) close() {
+	c.conn.Close()
+}
+
+// do sends a command and reads its reply. Replies are strings, int64s,
+// nil and []any of those; error replies are returned as redisError.
+func (c *redisConn) do(args ...string) (any, error) {
+	fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	reply, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if redisErr, ok := reply.(redisError); ok {
+		return nil, redisErr
+	}
+	return reply, nil
+}
+
+// read reads one reply
+func (c *redisConn) read() (any, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return redisError(payload), nil
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", payload)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", payload)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
+
+// redisEntry is a stream entry
+type redisEntry struct {
+	id     string
+	fields map[string]string
+}
+
+// redisStreamEntries decodes the XREAD reply of a single stream:
+// [[stream, [[id, [field, value, ...]], ...]]]
+func redisStreamEntries(reply any) ([]redisEntry, error) {
+	malformed := fmt.Errorf("redis: malformed XREAD reply")
+
+	streams, ok := reply.([]any)
+	if !ok || len(streams) != 1 {
+		return nil, malformed
+	}
+	stream, ok := streams[0].([]any)
+	if !ok || len(stream) != 2 {
+		return nil, malformed
+	}
+	items, ok := stream[1].([]any)
+	if !ok {
+		return nil, malformed
+	}
+
+	entries := make([]redisEntry, 0, len(items))
+	for _, item := range items {
+		pair, ok := item.([]any)
+		if !ok || len(pair) != 2 {
+			return nil, malformed
+		}
+		id, ok := pair[0].(string)
+		if !ok {
+			return nil, malformed
+		}
+		values, ok := pair[1].([]any)
+		if !ok || len(values)%2 != 0 {
+			return nil, malformed
+		}
+		entry := redisEntry{id: id, fields: make(map[string]string, len(values)/2)}
+		for i := 0; i < len(values); i += 2 {
+			field, _ := values[i].(string)
+			value, _ := values[i+1].(string)
+			entry.fields[field] = value
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
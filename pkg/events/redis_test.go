@@ -0,0 +1,197 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// fakeRedis serves XADD, XREVRANGE and XREAD on a single in-memory stream
+type fakeRedis struct {
+	listener net.Listener
+
+	mu      sync.Mutex
+	entries [][2]string // id, event field
+	lastAdd []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	r := &fakeRedis{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		switch strings.ToUpper(args[0]) {
+		case "XADD":
+			r.mu.Lock()
+			id := fmt.Sprintf("%d-0", len(r.entries)+1)
+			r.entries = append(r.entries, [2]string{id, args[len(args)-1]})
+			r.lastAdd = args
+			r.mu.Unlock()
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(id), id)
+		case "XREVRANGE":
+			r.mu.Lock()
+			if len(r.entries) == 0 {
+				io.WriteString(conn, "*0\r\n")
+			} else {
+				id := r.entries[len(r.entries)-1][0]
+				fmt.Fprintf(conn, "*1\r\n*2\r\n$%d\r\n%s\r\n*0\r\n", len(id), id)
+			}
+			r.mu.Unlock()
+		case "XREAD":
+			// XREAD COUNT n BLOCK ms STREAMS key id
+			after := args[len(args)-1]
+			reply := r.entriesAfter(args[len(args)-2], after)
+			if reply == "" {
+				time.Sleep(10 * time.Millisecond)
+				io.WriteString(conn, "*-1\r\n")
+				continue
+			}
+			io.WriteString(conn, reply)
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+	}
+}
+
+// entriesAfter encodes the XREAD reply of the entries after id
+func (r *fakeRedis) entriesAfter(stream, id string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start, _ := strconv.Atoi(strings.TrimSuffix(id, "-0"))
+	if start >= len(r.entries) {
+		return ""
+	}
+
+	bulk := func(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+	reply := fmt.Sprintf("*1\r\n*2\r\n%s*%d\r\n", bulk(stream), len(r.entries)-start)
+	for _, entry := range r.entries[start:] {
+		reply += "*2\r\n" + bulk(entry[0]) + "*2\r\n" + bulk(redisField) + bulk(entry[1])
+	}
+	return reply
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	conn := &redisConn{reader: reader}
+	reply, err := conn.read()
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) == 0 {
+		return nil, fmt.Errorf("not a command: %v", reply)
+	}
+	args := make([]string, len(items))
+	for i, item := range items {
+		args[i], _ = item.(string)
+	}
+	return args, nil
+}
+
+func TestRedisBackend(t *testing.T) {
+	server := newFakeRedis(t)
+	backend := NewRedisBackend(configv1.RedisStreamConfig{
+		Address:     server.listener.Addr().String(),
+		Stream:      "events",
+		MaxLen:      100,
+		DialTimeout: time.Second,
+	})
+	defer backend.Close()
+
+	// Entries published before reading started are not received
+	if err := backend.Publish(context.Background(), []byte("old")); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan string, 4)
+	done := make(chan error)
+	go func() {
+		done <- backend.Receive(ctx, func(data []byte) { received <- string(data) })
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	for _, data := range []string{"first", "second\r\nline"} {
+		if err := backend.Publish(ctx, []byte(data)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+
+	server.mu.Lock()
+	lastAdd := strings.Join(server.lastAdd[:len(server.lastAdd)-1], " ")
+	server.mu.Unlock()
+	if lastAdd != "XADD events MAXLEN ~ 100 * event" {
+		t.Errorf("XADD arguments = %q", lastAdd)
+	}
+
+	for _, want := range []string{"first", "second\r\nline"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("received %q, want %q", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %q not received", want)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Receive() did not return after cancel")
+	}
+	if backend.lastID != "3-0" {
+		t.Errorf("lastID = %q, want 3-0", backend.lastID)
+	}
+}
+
+func TestRedisConnErrorReply(t *testing.T) {
+	server := newFakeRedis(t)
+	conn, err := dialRedis(context.Background(), configv1.RedisConfig{
+		Address:     server.listener.Addr().String(),
+		DialTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("dialRedis() error = %v", err)
+	}
+	defer conn.close()
+
+	_, err = conn.do("PING")
+	if _, ok := err.(redisError); !ok {
+		t.Errorf("do() error = %v, want a redis error reply", err)
+	}
+}
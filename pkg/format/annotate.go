@@ -0,0 +1,170 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kind is how the value of a JSON field is displayed
+type kind int
+
+const (
+	kindNone kind = iota
+	kindBytes
+	kindRate
+	kindDuration
+	kindPercent
+	kindTime
+	kindDate
+)
+
+// byteFields are the fields holding sizes that their name does not tell
+var byteFields = map[string]bool{
+	"size":                true,
+	"traffic":             true,
+	"traffic_quota":       true,
+	"traffic_bonus":       true,
+	"total_upload":        true,
+	"total_download":      true,
+	"total_traffic":       true,
+	"total_traffic_today": true,
+	"daily_usage":         true,
+	"cached_traffic":      true,
+	"usage_before":        true,
+	"usage_after":         true,
+	"avg_traffic_usage":   true,
+}
+
+// kindOf classifies a field by the naming conventions of the management API
+func kindOf(key string) kind {
+	switch {
+	case strings.HasSuffix(key, "_bytes_per_sec"),
+		strings.HasPrefix(key, "network_") && strings.HasSuffix(key, "_rate"):
+		return kindRate
+	case key == "bytes", strings.HasSuffix(key, "_bytes"), strings.HasPrefix(key, "bytes_"), byteFields[key]:
+		return kindBytes
+	case strings.HasSuffix(key, "_seconds"):
+		return kindDuration
+	case strings.HasSuffix(key, "_percent"), strings.HasSuffix(key, "_percentage"):
+		return kindPercent
+	case key == "time", key == "timestamp", strings.HasSuffix(key, "_at"), strings.HasSuffix(key, "_time"):
+		return kindTime
+	case key == "date":
+		return kindDate
+	}
+	return kindNone
+}
+
+// Annotate adds display values next to the raw values of a JSON document
+// rendered with the proto field names. A size, rate, duration or percentage
+// field gets a "<field>_display" sibling, and a time field also gets a
+// "<field>_relative" one. Raw values are left untouched, and fields whose
+// name is already taken are not added.
+func (f *Formatter) Annotate(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	f.annotate(document)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// annotate adds the display values of the objects of a decoded document
+func (f *Formatter) annotate(value any) {
+	switch value := value.(type) {
+	case []any:
+		for _, item := range value {
+			f.annotate(item)
+		}
+	case map[string]any:
+		// Display values are added afterwards so they are not annotated themselves
+		added := make(map[string]string)
+		for key, field := range value {
+			switch field.(type) {
+			case []any, map[string]any:
+				f.annotate(field)
+				continue
+			}
+			f.display(added, key, field)
+		}
+		for key, display := range added {
+			if _, taken := value[key]; !taken {
+				value[key] = display
+			}
+		}
+	}
+}
+
+// display adds the display values of a field to added
+func (f *Formatter) display(added map[string]string, key string, value any) {
+	switch kindOf(key) {
+	case kindBytes:
+		if n, ok := integer(value); ok {
+			added[key+"_display"] = f.Bytes(n)
+		}
+	case kindRate:
+		if n, ok := integer(value); ok {
+			added[key+"_display"] = f.Rate(n)
+		}
+	case kindDuration:
+		if seconds, ok := number(value); ok {
+			added[key+"_display"] = f.Duration(time.Duration(seconds * float64(time.Second)))
+		}
+	case kindPercent:
+		if percent, ok := number(value); ok {
+			added[key+"_display"] = f.Percent(percent)
+		}
+	case kindTime:
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				added[key+"_display"] = f.Time(t)
+				added[key+"_relative"] = f.Relative(t)
+			}
+		}
+	case kindDate:
+		if s, ok := value.(string); ok {
+			if t, err := time.Parse(time.DateOnly, s); err == nil {
+				added[key+"_display"] = f.Date(t)
+			}
+		}
+	}
+}
+
+// integer reads an integer field, which protojson renders as a string when
+// it has 64 bits
+func integer(value any) (int64, bool) {
+	switch value := value.(type) {
+	case json.Number:
+		n, err := value.Int64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// number reads a numeric field
+func number(value any) (float64, bool) {
+	switch value := value.(type) {
+	case json.Number:
+		n, err := value.Float64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseFloat(value, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
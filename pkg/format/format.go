@@ -0,0 +1,171 @@
+// Package format renders the sizes, durations and times of admin-facing
+// responses for display, in the locale and time zone an admin prefers.
+package format
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	// Time zones must resolve on hosts without a zoneinfo database
+	_ "time/tzdata"
+)
+
+// Formatter renders values for display in a locale and time zone
+type Formatter struct {
+	locale   Locale
+	location *time.Location
+	now      func() time.Time
+}
+
+// New creates a formatter. Unsupported locales fall back to DefaultLocale
+// and a nil location to the local time zone.
+func New(locale Locale, location *time.Location) *Formatter {
+	if !locale.IsValid() {
+		locale = DefaultLocale
+	}
+	if location == nil {
+		location = time.Local
+	}
+	return &Formatter{locale: locale, location: location, now: time.Now}
+}
+
+// Locale returns the locale of the formatter
+func (f *Formatter) Locale() Locale {
+	return f.locale
+}
+
+// LoadLocation loads an IANA time zone, the local one when name is empty
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// byteUnits are the binary units of sizes, named as admins know them
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+
+// Bytes renders a size in binary units, such as "1.50 GB"
+func (f *Formatter) Bytes(n int64) string {
+	value, unit := float64(n), 0
+	for math.Abs(value) >= 1024 && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d B", n)
+	}
+	return fmt.Sprintf("%.2f %s", value, byteUnits[unit])
+}
+
+// Rate renders a transfer rate in bytes per second, such as "1.50 MB/s"
+func (f *Formatter) Rate(bytesPerSec int64) string {
+	return f.Bytes(bytesPerSec) + "/s"
+}
+
+// Percent renders a percentage, such as "12.5%"
+func (f *Formatter) Percent(percent float64) string {
+	return fmt.Sprintf("%.1f%%", percent)
+}
+
+// durationUnits are the units of durations by locale, largest first
+var durationUnits = map[Locale][4]string{
+	LocaleEnglish: {"d", "h", "m", "s"},
+	LocaleChinese: {"天", "小时", "分钟", "秒"},
+}
+
+// Duration renders a duration in its two largest units, such as "2d 3h" or
+// "2天3小时". Durations under a second render as "0s".
+func (f *Formatter) Duration(d time.Duration) string {
+	units := durationUnits[f.locale]
+	separator := " "
+	if f.locale == LocaleChinese {
+		separator = ""
+	}
+
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	seconds := int64(d / time.Second)
+	values := [4]int64{seconds / 86400, seconds / 3600 % 24, seconds / 60 % 60, seconds % 60}
+
+	for i, value := range values {
+		if value == 0 {
+			continue
+		}
+		parts := []string{fmt.Sprintf("%d%s", value, units[i])}
+		if i+1 < len(values) && values[i+1] != 0 {
+			parts = append(parts, fmt.Sprintf("%d%s", values[i+1], units[i+1]))
+		}
+		return sign + strings.Join(parts, separator)
+	}
+	return "0" + units[3]
+}
+
+// timeLayouts are the layouts of times and dates by locale
+var timeLayouts = map[Locale][2]string{
+	LocaleEnglish: {"Jan 2, 2006 15:04 MST", "Jan 2, 2006"},
+	LocaleChinese: {"2006年1月2日 15:04 MST", "2006年1月2日"},
+}
+
+// Time renders a time in the time zone of the formatter
+func (f *Formatter) Time(t time.Time) string {
+	return t.In(f.location).Format(timeLayouts[f.locale][0])
+}
+
+// Date renders a calendar date, which has no time zone
+func (f *Formatter) Date(t time.Time) string {
+	return t.Format(timeLayouts[f.locale][1])
+}
+
+// relativeUnits are the units of relative times, largest first
+var relativeUnits = []struct {
+	size    time.Duration
+	english string
+	chinese string
+}{
+	{365 * 24 * time.Hour, "year", "年"},
+	{30 * 24 * time.Hour, "month", "个月"},
+	{7 * 24 * time.Hour, "week", "周"},
+	{24 * time.Hour, "day", "天"},
+	{time.Hour, "hour", "小时"},
+	{time.Minute, "minute", "分钟"},
+}
+
+// Relative renders a time relative to now in its largest unit, such as
+// "3 hours ago", "in 2 days" or "3小时前"
+func (f *Formatter) Relative(t time.Time) string {
+	d := f.now().Sub(t)
+	past := d >= 0
+	if !past {
+		d = -d
+	}
+
+	for _, unit := range relativeUnits {
+		if d < unit.size {
+			continue
+		}
+		n := int64(d / unit.size)
+		if f.locale == LocaleChinese {
+			if past {
+				return fmt.Sprintf("%d%s前", n, unit.chinese)
+			}
+			return fmt.Sprintf("%d%s后", n, unit.chinese)
+		}
+		name := unit.english
+		if n != 1 {
+			name += "s"
+		}
+		if past {
+			return fmt.Sprintf("%d %s ago", n, name)
+		}
+		return fmt.Sprintf("in %d %s", n, name)
+	}
+
+	if f.locale == LocaleChinese {
+		return "刚刚"
+	}
+	return "just now"
+}
@@ -0,0 +1,175 @@
+package format
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func newTestFormatter(locale Locale) *Formatter {
+	f := New(locale, time.UTC)
+	f.now = func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC) }
+	return f
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Locale
+	}{
+		{"", LocaleEnglish},
+		{"zh-CN,zh;q=0.9,en;q=0.8", LocaleChinese},
+		{"en-US,en;q=0.9", LocaleEnglish},
+		{"fr-FR,zh_Hans;q=0.5,en;q=0.3", LocaleChinese},
+		{"en;q=0.2, zh-TW", LocaleChinese},
+		{"zh;q=0,de", LocaleEnglish},
+	}
+	for _, tt := range tests {
+		if got := NegotiateLocale(tt.header); got != tt.want {
+			t.Errorf("NegotiateLocale(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	f := newTestFormatter(LocaleEnglish)
+	tests := map[int64]string{
+		0:          "0 B",
+		1023:       "1023 B",
+		1536:       "1.50 KB",
+		5 << 30:    "5.00 GB",
+		3 << 40:    "3.00 TB",
+		-(2 << 20): "-2.00 MB",
+	}
+	for n, want := range tests {
+		if got := f.Bytes(n); got != want {
+			t.Errorf("Bytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+	if got := f.Rate(1 << 20); got != "1.00 MB/s" {
+		t.Errorf("Rate = %q", got)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		d       time.Duration
+		english string
+		chinese string
+	}{
+		{0, "0s", "0秒"},
+		{45 * time.Second, "45s", "45秒"},
+		{3*time.Hour + 5*time.Minute + 10*time.Second, "3h 5m", "3小时5分钟"},
+		{2*24*time.Hour + 5*time.Minute, "2d", "2天"},
+		{-90 * time.Second, "-1m 30s", "-1分钟30秒"},
+	}
+	english, chinese := newTestFormatter(LocaleEnglish), newTestFormatter(LocaleChinese)
+	for _, tt := range tests {
+		if got := english.Duration(tt.d); got != tt.english {
+			t.Errorf("Duration(%v) = %q, want %q", tt.d, got, tt.english)
+		}
+		if got := chinese.Duration(tt.d); got != tt.chinese {
+			t.Errorf("Duration(%v) in Chinese = %q, want %q", tt.d, got, tt.chinese)
+		}
+	}
+}
+
+func TestRelative(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t       time.Time
+		english string
+		chinese string
+	}{
+		{now.Add(-30 * time.Second), "just now", "刚刚"},
+		{now.Add(-time.Minute), "1 minute ago", "1分钟前"},
+		{now.Add(-3 * time.Hour), "3 hours ago", "3小时前"},
+		{now.Add(48 * time.Hour), "in 2 days", "2天后"},
+		{now.AddDate(-2, 0, 0), "2 years ago", "2年前"},
+	}
+	english, chinese := newTestFormatter(LocaleEnglish), newTestFormatter(LocaleChinese)
+	for _, tt := range tests {
+		if got := english.Relative(tt.t); got != tt.english {
+			t.Errorf("Relative(%v) = %q, want %q", tt.t, got, tt.english)
+		}
+		if got := chinese.Relative(tt.t); got != tt.chinese {
+			t.Errorf("Relative(%v) in Chinese = %q, want %q", tt.t, got, tt.chinese)
+		}
+	}
+}
+
+func TestTimeZone(t *testing.T) {
+	shanghai, err := LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 3, 10, 20, 30, 0, 0, time.UTC)
+
+	if got := New(LocaleChinese, shanghai).Time(at); got != "2024年3月11日 04:30 CST" {
+		t.Errorf("Time in Shanghai = %q", got)
+	}
+	if got := New(LocaleEnglish, time.UTC).Time(at); got != "Mar 10, 2024 20:30 UTC" {
+		t.Errorf("Time in UTC = %q", got)
+	}
+	if _, err := LoadLocation("Mars/Olympus"); err == nil {
+		t.Error("LoadLocation accepted an unknown time zone")
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	f := newTestFormatter(LocaleEnglish)
+	data, err := f.Annotate([]byte(`{
+		"users": [{"upload_bytes": "1073741824", "last_login_at": "2024-03-10T09:00:00Z", "username": "a<b"}],
+		"total_traffic": "2048",
+		"network_in_rate": "1024",
+		"throttled_seconds": 3700,
+		"usage_percent": 12.345,
+		"date": "2024-03-01",
+		"size": "12",
+		"size_display": "taken",
+		"created_at": null
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	user := got["users"].([]any)[0].(map[string]any)
+	checks := map[string]any{
+		"total_traffic_display":     got["total_traffic_display"],
+		"network_in_rate_display":   got["network_in_rate_display"],
+		"throttled_seconds_display": got["throttled_seconds_display"],
+		"usage_percent_display":     got["usage_percent_display"],
+		"date_display":              got["date_display"],
+		"size_display":              got["size_display"],
+		"upload_bytes":              user["upload_bytes"],
+		"upload_bytes_display":      user["upload_bytes_display"],
+		"last_login_at_display":     user["last_login_at_display"],
+		"last_login_at_relative":    user["last_login_at_relative"],
+		"username":                  user["username"],
+	}
+	want := map[string]any{
+		"total_traffic_display":     "2.00 KB",
+		"network_in_rate_display":   "1.00 KB/s",
+		"throttled_seconds_display": "1h 1m",
+		"usage_percent_display":     "12.3%",
+		"date_display":              "Mar 1, 2024",
+		"size_display":              "taken",
+		"upload_bytes":              "1073741824",
+		"upload_bytes_display":      "1.00 GB",
+		"last_login_at_display":     "Mar 10, 2024 09:00 UTC",
+		"last_login_at_relative":    "3 hours ago",
+		"username":                  "a<b",
+	}
+	for key, value := range want {
+		if checks[key] != value {
+			t.Errorf("%s = %v, want %v", key, checks[key], value)
+		}
+	}
+	if _, ok := got["created_at_display"]; ok {
+		t.Error("null time was annotated")
+	}
+}
@@ -0,0 +1,70 @@
+package format
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Locale is a language the display values are rendered in
+type Locale string
+
+const (
+	LocaleEnglish Locale = "en"
+	LocaleChinese Locale = "zh-CN"
+
+	// DefaultLocale is used when no supported locale is requested
+	DefaultLocale = LocaleEnglish
+)
+
+// Locales are the supported locales
+var Locales = []Locale{LocaleEnglish, LocaleChinese}
+
+// IsValid checks if the locale is supported
+func (l Locale) IsValid() bool {
+	return l == LocaleEnglish || l == LocaleChinese
+}
+
+// ParseLocale matches a language tag such as "en-US", "zh" or "zh_Hans_CN"
+// to a supported locale, ignoring the case and the region
+func ParseLocale(tag string) (Locale, bool) {
+	language, _, _ := strings.Cut(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	switch strings.ToLower(language) {
+	case "en":
+		return LocaleEnglish, true
+	case "zh":
+		return LocaleChinese, true
+	}
+	return "", false
+}
+
+// NegotiateLocale picks the supported locale an Accept-Language header
+// prefers most, DefaultLocale when it names none
+func NegotiateLocale(acceptLanguage string) Locale {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			tags = append(tags, weighted{tag: tag, quality: quality})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].quality > tags[j].quality })
+
+	for _, tag := range tags {
+		if locale, ok := ParseLocale(tag.tag); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
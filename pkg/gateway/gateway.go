@@ -0,0 +1,109 @@
+// Package gateway maps the unary methods of a gRPC service to HTTP/JSON.
+//
+// Every method is served as POST {prefix}/{MethodName} with the request
+// message as the JSON body, in the protojson mapping the gRPC services use,
+// and the response message as the JSON result. The routes are derived from
+// the service descriptor, so methods added to the proto are exposed without
+// further code, and OpenAPI describes the same routes.
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	messageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// method is a unary method of the service and its server implementation
+type method struct {
+	input protoreflect.MessageType
+	call  reflect.Value
+}
+
+// Gateway invokes the methods of a service implementation from JSON requests
+type Gateway struct {
+	service protoreflect.ServiceDescriptor
+	methods map[string]method
+}
+
+// New creates a gateway for the unary methods of service implemented by srv,
+// usually the server registered for the service with gRPC. It fails when srv
+// lacks a method of the service with the generated signature.
+func New(service protoreflect.ServiceDescriptor, srv any) (*Gateway, error) {
+	g := &Gateway{service: service, methods: make(map[string]method)}
+	value := reflect.ValueOf(srv)
+
+	descriptors := service.Methods()
+	for i := 0; i < descriptors.Len(); i++ {
+		desc := descriptors.Get(i)
+		if desc.IsStreamingClient() || desc.IsStreamingServer() {
+			continue
+		}
+		name := string(desc.Name())
+
+		input, err := protoregistry.GlobalTypes.FindMessageByName(desc.Input().FullName())
+		if err != nil {
+			return nil, fmt.Errorf("method %s: %w", name, err)
+		}
+		call := value.MethodByName(name)
+		if !call.IsValid() || !isUnaryHandler(call.Type()) {
+			return nil, fmt.Errorf("method %s is not implemented by %T", name, srv)
+		}
+		g.methods[name] = method{input: input, call: call}
+	}
+	return g, nil
+}
+
+// isUnaryHandler checks for the func(context.Context, *Request) (*Response, error)
+// signature of generated unary server methods
+func isUnaryHandler(t reflect.Type) bool {
+	return t.NumIn() == 2 && t.In(0) == contextType && t.In(1).Implements(messageType) &&
+		t.NumOut() == 2 && t.Out(0).Implements(messageType) && t.Out(1) == errorType
+}
+
+// Service returns the descriptor of the service served by the gateway
+func (g *Gateway) Service() protoreflect.ServiceDescriptor {
+	return g.service
+}
+
+// Has reports whether name is a method served by the gateway
+func (g *Gateway) Has(name string) bool {
+	_, ok := g.methods[name]
+	return ok
+}
+
+// Invoke calls the method name with the request decoded from the JSON body,
+// an empty body being the empty request. Errors are gRPC status errors, the
+// ones of the method returned as they are.
+func (g *Gateway) Invoke(ctx context.Context, name string, body []byte) (proto.Message, error) {
+	m, ok := g.methods[name]
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "unknown method %s", name)
+	}
+
+	req := m.input.New().Interface()
+	if len(body) > 0 {
+		if err := protojson.Unmarshal(body, req); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", m.input.Descriptor().Name(), err)
+		}
+	}
+
+	out := m.call.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	resp, _ := out[0].Interface().(proto.Message)
+	return resp, nil
+}
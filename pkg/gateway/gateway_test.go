@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// testManagementServer implements GetUser, the other methods are unimplemented
+type testManagementServer struct {
+	pbv1.UnimplementedManagementServiceServer
+}
+
+func (testManagementServer) GetUser(ctx context.Context, req *pbv1.GetUserRequest) (*pbv1.GetUserResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	return &pbv1.GetUserResponse{User: &pbv1.UserInfo{UserId: req.UserId, Username: "alice"}}, nil
+}
+
+func newTestGateway(t *testing.T) *Gateway {
+	t.Helper()
+	g, err := New(pbv1.File_v1_management_proto.Services().ByName("ManagementService"), testManagementServer{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return g
+}
+
+func TestInvoke(t *testing.T) {
+	g := newTestGateway(t)
+
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		wantCode codes.Code
+	}{
+		{"proto field names", "GetUser", `{"user_id": "7"}`, codes.OK},
+		{"json field names", "GetUser", `{"userId": "7"}`, codes.OK},
+		{"method error", "GetUser", ``, codes.InvalidArgument},
+		{"invalid body", "GetUser", `{"user_id": 7`, codes.InvalidArgument},
+		{"unknown field", "GetUser", `{"id": "7"}`, codes.InvalidArgument},
+		{"unimplemented method", "ListUsers", `{}`, codes.Unimplemented},
+		{"unknown method", "Nope", `{}`, codes.Unimplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := g.Invoke(context.Background(), tt.method, []byte(tt.body))
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("Invoke() code = %v, want %v (err %v)", code, tt.wantCode, err)
+			}
+			if tt.wantCode != codes.OK {
+				return
+			}
+			user := resp.(*pbv1.GetUserResponse).User
+			if user.GetUserId() != "7" || user.GetUsername() != "alice" {
+				t.Errorf("Invoke() = %v", resp)
+			}
+		})
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	g := newTestGateway(t)
+	data, err := g.OpenAPI("/rpc", "test", "v1")
+	if err != nil {
+		t.Fatalf("OpenAPI() error = %v", err)
+	}
+
+	var doc struct {
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+
+	if _, ok := doc.Paths["/rpc/GetUser"]["post"]; !ok {
+		t.Errorf("missing POST /rpc/GetUser")
+	}
+	user, ok := doc.Components.Schemas["api.v1.UserInfo"]
+	if !ok {
+		t.Fatalf("missing api.v1.UserInfo schema")
+	}
+	tests := []struct {
+		field      string
+		wantType   string
+		wantFormat string
+	}{
+		{"username", "string", ""},
+		{"plan_id", "string", "int64"},
+		{"created_at", "string", "date-time"},
+		{"allowed_nodes", "array", ""},
+		{"metadata", "object", ""},
+		{"email_verified", "boolean", ""},
+	}
+	for _, tt := range tests {
+		property := user.Properties[tt.field]
+		if property["type"] != tt.wantType || (tt.wantFormat != "" && property["format"] != tt.wantFormat) {
+			t.Errorf("%s schema = %v, want %s %s", tt.field, property, tt.wantType, tt.wantFormat)
+		}
+	}
+}
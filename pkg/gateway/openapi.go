@@ -0,0 +1,168 @@
+package gateway
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// errorSchema is the name of the schema of error responses
+const errorSchema = "Error"
+
+// OpenAPI describes the routes of the gateway served under prefix as an
+// OpenAPI 3 document. Field names are the proto names, the ones responses
+// are rendered with; requests also accept the JSON (lowerCamelCase) names.
+func (g *Gateway) OpenAPI(prefix, title, version string) ([]byte, error) {
+	schemas := map[string]any{
+		errorSchema: map[string]any{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]any{
+				"error":  map[string]any{"type": "string"},
+				"reason": map[string]any{"type": "string", "description": "Machine readable reason of the error"},
+				"fields": map[string]any{
+					"type":                 "object",
+					"description":          "Invalid request fields and what is wrong with them",
+					"additionalProperties": map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+	paths := make(map[string]any)
+
+	methods := g.service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		desc := methods.Get(i)
+		name := string(desc.Name())
+		if !g.Has(name) {
+			continue
+		}
+
+		paths[prefix+"/"+name] = map[string]any{
+			"post": map[string]any{
+				"operationId": name,
+				"tags":        []string{string(g.service.Name())},
+				"requestBody": map[string]any{
+					"required": false,
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": messageSchema(desc.Input(), schemas)},
+					},
+				},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "OK",
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": messageSchema(desc.Output(), schemas)},
+						},
+					},
+					"default": map[string]any{
+						"description": "Error, with the HTTP status of its gRPC code",
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": schemaRef(errorSchema)},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": title, "version": version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		"security": []any{map[string]any{"bearer": []string{}}},
+	}, "", "  ")
+}
+
+// schemaRef references a component schema
+func schemaRef(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// messageSchema returns the schema of a message, adding the schemas of it and
+// the messages it refers to to schemas. Well-known types are inlined in their
+// JSON form.
+func messageSchema(desc protoreflect.MessageDescriptor, schemas map[string]any) map[string]any {
+	switch desc.FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string", "example": "3.5s"}
+	case "google.protobuf.Empty":
+		return map[string]any{"type": "object"}
+	case "google.protobuf.FieldMask":
+		return map[string]any{"type": "string", "example": "id,name"}
+	}
+
+	name := string(desc.FullName())
+	if _, ok := schemas[name]; ok {
+		return schemaRef(name)
+	}
+	properties := make(map[string]any)
+	schema := map[string]any{"type": "object", "properties": properties}
+	// Registered before the fields, so that recursive messages end up as references
+	schemas[name] = schema
+
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		properties[string(field.Name())] = fieldSchema(field, schemas)
+	}
+	return schemaRef(name)
+}
+
+// fieldSchema returns the schema of a field, arrays for repeated fields and
+// objects for maps
+func fieldSchema(field protoreflect.FieldDescriptor, schemas map[string]any) map[string]any {
+	switch {
+	case field.IsMap():
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": singularSchema(field.MapValue(), schemas),
+		}
+	case field.IsList():
+		return map[string]any{"type": "array", "items": singularSchema(field, schemas)}
+	default:
+		return singularSchema(field, schemas)
+	}
+}
+
+// singularSchema returns the schema of one value of a field. 64-bit integers
+// are strings in the protojson mapping.
+func singularSchema(field protoreflect.FieldDescriptor, schemas map[string]any) map[string]any {
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "uint64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageSchema(field.Message(), schemas)
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
@@ -0,0 +1,258 @@
+package geodata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// maxChecksumSize bounds the size of a checksum file
+const maxChecksumSize = 64 * 1024
+
+var (
+	// ErrUnknownArtifact is returned for a database that is not cached
+	ErrUnknownArtifact = errors.New("geo data artifact not found")
+	// ErrArtifactChanged is returned when a download asks for content that was replaced
+	ErrArtifactChanged = errors.New("geo data artifact changed")
+)
+
+// Cache downloads the configured sources into the cache directory and serves
+// the cached databases to the agents. Artifact metadata is stored in the
+// database so that the panel can compare it with the versions nodes report.
+type Cache struct {
+	config configv1.GeoDataConfig
+	repo   repository.GeoDataRepository
+	client *http.Client
+	logger *zap.Logger
+
+	// Cached artifacts by name, only those whose file is in the cache directory
+	artifacts map[string]*models.GeoDataArtifact
+	mu        sync.RWMutex
+}
+
+// NewCache creates a geo data cache
+func NewCache(config configv1.GeoDataConfig, repo repository.GeoDataRepository, logger *zap.Logger) *Cache {
+	return &Cache{
+		config:    config,
+		repo:      repo,
+		client:    &http.Client{Timeout: config.DownloadTimeout},
+		logger:    logger.Named("geodata"),
+		artifacts: make(map[string]*models.GeoDataArtifact),
+	}
+}
+
+// Load restores the artifacts recorded in the database whose file is still in
+// the cache directory with the recorded content. It reports whether every
+// source is cached and was checked within the refresh interval.
+func (c *Cache) Load() (bool, error) {
+	stored, err := c.repo.ListArtifacts()
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, artifact := range stored {
+		sum, _, err := FileSHA256(filepath.Join(c.config.CacheDir, artifact.Name))
+		if err != nil || sum != artifact.SHA256 {
+			c.logger.Info("cached geo data missing or changed, will download again", zap.String("name", artifact.Name))
+			continue
+		}
+		c.artifacts[artifact.Name] = artifact
+	}
+
+	for _, source := range c.config.Sources {
+		artifact, ok := c.artifacts[source.Name]
+		if !ok || time.Since(artifact.CheckedAt) >= c.config.RefreshInterval {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Refresh downloads every source and returns the artifacts whose content
+// changed. A failing source keeps its previous artifact.
+func (c *Cache) Refresh(ctx context.Context) ([]*models.GeoDataArtifact, error) {
+	var changed []*models.GeoDataArtifact
+	var errs []error
+	for _, source := range c.config.Sources {
+		artifact, updated, err := c.refreshSource(ctx, source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source.Name, err))
+			continue
+		}
+		if updated {
+			changed = append(changed, artifact)
+		}
+	}
+	return changed, errors.Join(errs...)
+}
+
+// refreshSource downloads one source, verifies it against its checksum file
+// and installs it when the content differs from the cached one
+func (c *Cache) refreshSource(ctx context.Context, source configv1.GeoDataSource) (*models.GeoDataArtifact, bool, error) {
+	var want string
+	if source.ChecksumURL != "" {
+		body, err := c.get(ctx, source.ChecksumURL)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to download checksum: %w", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(body, maxChecksumSize))
+		body.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to download checksum: %w", err)
+		}
+		if want, err = ParseChecksum(data, source.Name); err != nil {
+			return nil, false, err
+		}
+	}
+
+	now := time.Now()
+	c.mu.RLock()
+	current := c.artifacts[source.Name]
+	c.mu.RUnlock()
+
+	// The checksum file tells whether there is anything new to download
+	if current != nil && want != "" && want == current.SHA256 {
+		return c.markChecked(current, now), false, nil
+	}
+
+	body, err := c.get(ctx, source.URL)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to download: %w", err)
+	}
+	defer body.Close()
+
+	// Downloads go to a staging directory so that agents keep reading the old
+	// file until the new one is verified
+	staging := filepath.Join(c.config.CacheDir, ".staging")
+	sum, size, err := Install(staging, source.Name, body, want)
+	if err != nil {
+		return nil, false, err
+	}
+	if current != nil && sum == current.SHA256 {
+		os.Remove(filepath.Join(staging, source.Name))
+		return c.markChecked(current, now), false, nil
+	}
+
+	artifact := &models.GeoDataArtifact{
+		Name:        source.Name,
+		Version:     now.UTC().Format("20060102150405"),
+		SHA256:      sum,
+		Size:        size,
+		SourceURL:   source.URL,
+		PublishedAt: now,
+		CheckedAt:   now,
+	}
+
+	c.mu.Lock()
+	err = os.Rename(filepath.Join(staging, source.Name), filepath.Join(c.config.CacheDir, source.Name))
+	if err == nil {
+		c.artifacts[source.Name] = artifact
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := c.repo.SaveArtifact(artifact); err != nil {
+		c.logger.Error("failed to save geo data artifact", zap.Error(err), zap.String("name", source.Name))
+	}
+	c.logger.Info("geo data updated",
+		zap.String("name", artifact.Name),
+		zap.String("version", artifact.Version),
+		zap.String("sha256", artifact.SHA256),
+		zap.Int64("size", artifact.Size),
+	)
+	return artifact, true, nil
+}
+
+// markChecked records that the source of an unchanged artifact was checked
+func (c *Cache) markChecked(current *models.GeoDataArtifact, now time.Time) *models.GeoDataArtifact {
+	artifact := *current
+	artifact.CheckedAt = now
+
+	c.mu.Lock()
+	c.artifacts[artifact.Name] = &artifact
+	c.mu.Unlock()
+
+	if err := c.repo.SaveArtifact(&artifact); err != nil {
+		c.logger.Error("failed to save geo data artifact", zap.Error(err), zap.String("name", artifact.Name))
+	}
+	return &artifact
+}
+
+// get starts a GET request and returns the body of a 200 response
+func (c *Cache) get(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Artifacts returns the cached artifacts ordered by name
+func (c *Cache) Artifacts() []*models.GeoDataArtifact {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	artifacts := make([]*models.GeoDataArtifact, 0, len(c.artifacts))
+	for _, artifact := range c.artifacts {
+		artifacts = append(artifacts, artifact)
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].Name < artifacts[j].Name })
+	return artifacts
+}
+
+// ReadChunk reads up to ChunkSize bytes of an artifact starting at offset. It
+// fails with ErrArtifactChanged unless the cached content has the SHA-256 sum,
+// so that a download never mixes two versions.
+func (c *Cache) ReadChunk(name, sum string, offset int64) ([]byte, *models.GeoDataArtifact, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	artifact, ok := c.artifacts[name]
+	if !ok {
+		return nil, nil, ErrUnknownArtifact
+	}
+	if sum != artifact.SHA256 {
+		return nil, artifact, ErrArtifactChanged
+	}
+	if offset < 0 || offset > artifact.Size {
+		return nil, artifact, fmt.Errorf("offset %d out of range", offset)
+	}
+
+	f, err := os.Open(filepath.Join(c.config.CacheDir, name))
+	if err != nil {
+		return nil, artifact, err
+	}
+	defer f.Close()
+
+	data := make([]byte, min(int64(ChunkSize), artifact.Size-offset))
+	if _, err := f.ReadAt(data, offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, artifact, err
+	}
+	return data, artifact, nil
+}
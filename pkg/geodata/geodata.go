@@ -0,0 +1,119 @@
+// Package geodata distributes the geo databases used by sing-box routing
+// rules (geoip.db, geosite.db) from the API server to the nodes. The API
+// server downloads and caches release artifacts; agents fetch them in chunks
+// and only install a file whose SHA-256 matches the advertised one.
+package geodata
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ChunkSize is the largest piece of a database sent in one download response
+const ChunkSize = 1 << 20
+
+// namePattern matches plain file names
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ErrChecksumMismatch is returned when downloaded content does not have the expected SHA-256
+var ErrChecksumMismatch = errors.New("geo data checksum mismatch")
+
+// ValidName reports whether name can be used as a database file name
+func ValidName(name string) bool {
+	return len(name) <= 64 && namePattern.MatchString(name)
+}
+
+// FileSHA256 returns the hex SHA-256 and the size of a file
+func FileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// ParseChecksum extracts the SHA-256 of the file name from sha256sum output.
+// A file holding a single bare hash is accepted as well.
+func ParseChecksum(data []byte, name string) (string, error) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// The file name may carry the binary mode marker and a directory
+		matches := len(fields) == 1 && len(lines) == 1
+		if len(fields) == 2 {
+			matches = filepath.Base(strings.TrimPrefix(fields[1], "*")) == name
+		}
+		if matches && isSHA256(fields[0]) {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no SHA-256 for %s in checksum file", name)
+}
+
+// isSHA256 reports whether s is a hex encoded SHA-256
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Install writes r to dir/name, replacing the file atomically once the content
+// is complete. When sum is not empty the content must have that SHA-256,
+// otherwise ErrChecksumMismatch is returned and the existing file is kept.
+func Install(dir, name string, r io.Reader, sum string) (string, int64, error) {
+	if !ValidName(name) {
+		return "", 0, fmt.Errorf("invalid geo data name %q", name)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", 0, err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	got := hex.EncodeToString(h.Sum(nil))
+	if sum != "" && !strings.EqualFold(got, sum) {
+		return "", 0, fmt.Errorf("%w: %s has %s, want %s", ErrChecksumMismatch, name, got, sum)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, name)); err != nil {
+		return "", 0, err
+	}
+	return got, size, nil
+}
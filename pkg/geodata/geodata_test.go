@@ -0,0 +1,90 @@
+package geodata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	worldSHA256 = "486ea46224d1bb4fb680f34f7c9ad96a8f24ec88be73ea8e5a6c65260e9cb8a7"
+)
+
+func TestParseChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		file    string
+		want    string
+		wantErr bool
+	}{
+		{"sha256sum line", helloSHA256 + "  geoip.db\n", "geoip.db", helloSHA256, false},
+		{"binary marker and directory", helloSHA256 + " *dist/geoip.db", "geoip.db", helloSHA256, false},
+		{"bare hash", strings.ToUpper(helloSHA256), "geoip.db", helloSHA256, false},
+		{"several files", worldSHA256 + "  geosite.db\n" + helloSHA256 + "  geoip.db\n", "geoip.db", helloSHA256, false},
+		{"other file", helloSHA256 + "  geosite.db", "geoip.db", "", true},
+		{"not a hash", "abc  geoip.db", "geoip.db", "", true},
+		{"empty", "", "geoip.db", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChecksum([]byte(tt.data), tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseChecksum() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstall(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		sum     string
+		wantErr error
+		want    string
+	}{
+		{"matching checksum", "hello", helloSHA256, nil, "hello"},
+		{"no checksum", "hello", "", nil, "hello"},
+		{"checksum mismatch keeps the old file", "hello", worldSHA256, ErrChecksumMismatch, "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "geoip.db")
+			if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			sum, size, err := Install(dir, "geoip.db", strings.NewReader(tt.content), tt.sum)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Install() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (sum != helloSHA256 || size != int64(len(tt.content))) {
+				t.Errorf("Install() = %q, %d, want %q, %d", sum, size, helloSHA256, len(tt.content))
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("file content = %q, want %q", data, tt.want)
+			}
+
+			// No temporary files are left behind
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != 1 {
+				t.Errorf("directory has %d entries, want 1", len(entries))
+			}
+		})
+	}
+}
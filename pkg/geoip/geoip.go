@@ -0,0 +1,215 @@
+// Package geoip looks up the countries of IP addresses in a MaxMind DB file,
+// such as GeoLite2-Country, DB-IP country lite or the geoip.db of sing-box,
+// and keeps it up to date from a download URL or from the file on disk.
+package geoip
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// maxDatabaseSize bounds the size of a database file or download
+const maxDatabaseSize = 512 << 20
+
+// ErrNotLoaded is returned by lookups before a database was loaded
+var ErrNotLoaded = errors.New("GeoIP database not loaded")
+
+// Database is the country database in use, replaced as it is refreshed
+type Database struct {
+	config configv1.GeoIPConfig
+	client *http.Client
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	reader  *Reader
+	modTime time.Time
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewDatabase creates a database, empty until loaded
+func NewDatabase(config configv1.GeoIPConfig, logger *zap.Logger) *Database {
+	return &Database{
+		config: config,
+		client: &http.Client{Timeout: config.DownloadTimeout},
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+}
+
+// Country returns the ISO country code of an address, empty when unknown
+func (d *Database) Country(ip net.IP) (string, error) {
+	d.mu.RLock()
+	reader := d.reader
+	d.mu.RUnlock()
+
+	if reader == nil {
+		return "", ErrNotLoaded
+	}
+	return reader.Country(ip)
+}
+
+// Start loads the database file in the background, downloading it first
+// when it is missing or older than the refresh interval, and refreshes it
+// every refresh interval. Lookups fail with ErrNotLoaded until it is loaded.
+func (d *Database) Start(ctx context.Context) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if err := d.Refresh(ctx); err != nil {
+			d.logger.Error("Failed to load GeoIP database", zap.Error(err))
+		}
+
+		ticker := time.NewTicker(d.config.RefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.done:
+				return
+			case <-ticker.C:
+				if err := d.Refresh(ctx); err != nil {
+					d.logger.Error("Failed to refresh GeoIP database", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the refreshes
+func (d *Database) Stop() {
+	d.once.Do(func() { close(d.done) })
+	d.wg.Wait()
+}
+
+// Refresh downloads the database when it has a download URL and the file is
+// due, then loads the file when it changed. A failed download keeps the
+// database in use.
+func (d *Database) Refresh(ctx context.Context) error {
+	var downloadErr error
+	if d.config.DownloadURL != "" {
+		info, err := os.Stat(d.config.DatabasePath)
+		if err != nil || time.Since(info.ModTime()) >= d.config.RefreshInterval {
+			downloadErr = d.download(ctx)
+		}
+	}
+	return errors.Join(downloadErr, d.load())
+}
+
+// load reads the database file unless it is the one in use
+func (d *Database) load() error {
+	info, err := os.Stat(d.config.DatabasePath)
+	if err != nil {
+		return err
+	}
+	d.mu.RLock()
+	unchanged := d.reader != nil && info.ModTime().Equal(d.modTime)
+	d.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+	if info.Size() > maxDatabaseSize {
+		return fmt.Errorf("GeoIP database larger than %d bytes", maxDatabaseSize)
+	}
+
+	data, err := os.ReadFile(d.config.DatabasePath)
+	if err != nil {
+		return err
+	}
+	reader, err := NewReader(data)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.reader, d.modTime = reader, info.ModTime()
+	d.mu.Unlock()
+
+	d.logger.Info("GeoIP database loaded",
+		zap.String("path", d.config.DatabasePath),
+		zap.String("type", reader.Type),
+		zap.Time("modified", info.ModTime()),
+	)
+	return nil
+}
+
+// download replaces the database file with the download, once it parsed
+func (d *Database) download(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.config.DownloadURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download GeoIP database: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download GeoIP database: unexpected status %s", resp.Status)
+	}
+
+	// Gzipped databases, such as those of DB-IP, are recognized by content
+	body := bufio.NewReader(resp.Body)
+	var content io.Reader = body
+	if magic, _ := body.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress GeoIP database: %w", err)
+		}
+		defer gz.Close()
+		content = gz
+	}
+
+	data, err := io.ReadAll(io.LimitReader(content, maxDatabaseSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to download GeoIP database: %w", err)
+	}
+	if len(data) > maxDatabaseSize {
+		return fmt.Errorf("GeoIP database larger than %d bytes", maxDatabaseSize)
+	}
+	if _, err := NewReader(data); err != nil {
+		return fmt.Errorf("downloaded GeoIP database: %w", err)
+	}
+
+	// Written next to the file and renamed, so that a crash never leaves a
+	// partial database
+	dir := filepath.Dir(d.config.DatabasePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".geoip-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), d.config.DatabasePath); err != nil {
+		return err
+	}
+	d.logger.Info("GeoIP database downloaded", zap.String("url", d.config.DownloadURL), zap.Int("size", len(data)))
+	return nil
+}
@@ -0,0 +1,68 @@
+package geoip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestDatabaseRefresh(t *testing.T) {
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(buildDatabase(t, 6, 28, encodeString("US"), testNetworks))
+	gz.Close()
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write(gzipped.Bytes())
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "geoip", "country.mmdb")
+	db := NewDatabase(configv1.GeoIPConfig{
+		DatabasePath:    path,
+		DownloadURL:     server.URL,
+		RefreshInterval: time.Hour,
+		DownloadTimeout: time.Second,
+	}, zap.NewNop())
+
+	if _, err := db.Country(net.ParseIP("1.2.3.4")); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("Country() before loading error = %v, want ErrNotLoaded", err)
+	}
+
+	if err := db.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got, err := db.Country(net.ParseIP("1.2.3.4")); err != nil || got != "CN" {
+		t.Errorf("Country(1.2.3.4) = %q, %v; want CN", got, err)
+	}
+
+	// A failed download keeps the file and the database in use
+	status = http.StatusInternalServerError
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Refresh(context.Background()); err == nil {
+		t.Error("Refresh() with a failing download succeeded")
+	}
+	if got, _ := db.Country(net.ParseIP("8.8.8.8")); got != "US" {
+		t.Errorf("Country(8.8.8.8) after a failed download = %q, want US", got)
+	}
+}
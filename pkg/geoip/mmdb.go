@@ -0,0 +1,342 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+)
+
+// metadataMarker precedes the metadata at the end of a MaxMind DB file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// maxMetadataSize bounds the search for the metadata marker
+const maxMetadataSize = 128 * 1024
+
+// dataSectionSeparator is the gap between the search tree and the data
+const dataSectionSeparator = 16
+
+// maxDecodeDepth bounds the nesting and pointers followed by the decoder
+const maxDecodeDepth = 32
+
+// ErrInvalidDatabase is returned for data that is not a MaxMind DB file
+var ErrInvalidDatabase = errors.New("invalid MaxMind DB file")
+
+// Data types of the MaxMind DB data section
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBoolean   = 14
+	typeFloat     = 15
+)
+
+// Reader looks up addresses in a MaxMind DB file held in memory, decoding
+// only the types the country databases use
+type Reader struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node of ::/96, where IPv4 lookups start in an IPv6 tree
+	ipv4Start uint
+	// Type is the database_type of the metadata, e.g. "GeoLite2-Country"
+	Type string
+}
+
+// NewReader parses a MaxMind DB file
+func NewReader(buf []byte) (*Reader, error) {
+	start := max(0, len(buf)-maxMetadataSize)
+	index := bytes.LastIndex(buf[start:], metadataMarker)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", ErrInvalidDatabase)
+	}
+	metadataStart := start + index + len(metadataMarker)
+
+	value, _, err := decoder{buf: buf[metadataStart:]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", ErrInvalidDatabase, err)
+	}
+	metadata, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	r := &Reader{}
+	r.Type, _ = metadata["database_type"].(string)
+	nodeCount, ok1 := metadata["node_count"].(uint64)
+	recordSize, ok2 := metadata["record_size"].(uint64)
+	ipVersion, ok3 := metadata["ip_version"].(uint64)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, fmt.Errorf("%w: node_count, record_size or ip_version missing", ErrInvalidDatabase)
+	}
+	if recordSize != 24 && recordSize != 28 && recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, recordSize)
+	}
+	if ipVersion != 4 && ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, ipVersion)
+	}
+	r.nodeCount, r.recordSize, r.ipVersion = uint(nodeCount), uint(recordSize), uint(ipVersion)
+
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(start+index) {
+		return nil, fmt.Errorf("%w: search tree larger than the file", ErrInvalidDatabase)
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[treeSize+dataSectionSeparator : start+index]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node
+func (r *Reader) record(node, bit uint) uint {
+	switch r.recordSize {
+	case 24:
+		offset := node*6 + bit*3
+		b := r.tree[offset : offset+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := r.tree[node*7 : node*7+7]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		offset := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(r.tree[offset : offset+4]))
+	}
+}
+
+// Lookup returns the data of the network an address is in, nil when the
+// database has none
+func (r *Reader) Lookup(ip net.IP) (any, error) {
+	bits := ip.To4()
+	node := uint(0)
+	switch {
+	case bits != nil && r.ipVersion == 6:
+		node = r.ipv4Start
+	case bits == nil && r.ipVersion == 4:
+		return nil, nil
+	case bits == nil:
+		if bits = ip.To16(); bits == nil {
+			return nil, fmt.Errorf("invalid IP address %v", ip)
+		}
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-i%8)) & 1
+		node = r.record(node, bit)
+	}
+	if node == r.nodeCount {
+		return nil, nil
+	}
+	if node < r.nodeCount {
+		return nil, fmt.Errorf("%w: search tree deeper than the address", ErrInvalidDatabase)
+	}
+
+	offset := node - r.nodeCount - dataSectionSeparator
+	value, _, err := decoder{buf: r.data}.decode(offset, 0)
+	return value, err
+}
+
+// Country returns the ISO country code of an address, empty when unknown.
+// Records are either the code itself, as in the geoip.db of sing-box, or a
+// map with country.iso_code, as in GeoLite2 and DB-IP.
+func (r *Reader) Country(ip net.IP) (string, error) {
+	value, err := r.Lookup(ip)
+	if err != nil {
+		return "", err
+	}
+	switch value := value.(type) {
+	case string:
+		return strings.ToUpper(value), nil
+	case map[string]any:
+		for _, key := range []string{"country", "registered_country"} {
+			if country, ok := value[key].(map[string]any); ok {
+				if code, ok := country["iso_code"].(string); ok && code != "" {
+					return strings.ToUpper(code), nil
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// decoder decodes the values of a data section
+type decoder struct {
+	buf []byte
+}
+
+// bytes returns n bytes at offset
+func (d decoder) bytes(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d.buf)) || offset+n < offset {
+		return nil, fmt.Errorf("value at %d out of bounds", offset)
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+// uintOf decodes a big-endian unsigned integer
+func uintOf(b []byte) uint64 {
+	var value uint64
+	for _, c := range b {
+		value = value<<8 | uint64(c)
+	}
+	return value
+}
+
+// decode decodes the value at offset and returns the offset after it.
+// Strings, maps, arrays, booleans, doubles and floats are returned as such,
+// unsigned and signed integers as uint64 and int64, bytes and uint128 as
+// []byte.
+func (d decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("values nested too deep")
+	}
+	control, err := d.bytes(offset, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset++
+	kind := uint(control[0] >> 5)
+
+	if kind == typePointer {
+		size := uint(control[0]>>3) & 3
+		b, err := d.bytes(offset, size+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += size + 1
+		low := uint(control[0] & 7)
+		var target uint
+		switch size {
+		case 0:
+			target = low<<8 | uint(b[0])
+		case 1:
+			target = (low<<16 | uint(uintOf(b))) + 2048
+		case 2:
+			target = (low<<24 | uint(uintOf(b))) + 526336
+		default:
+			target = uint(uintOf(b))
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, offset, err
+	}
+
+	if kind == typeExtended {
+		b, err := d.bytes(offset, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset++
+		kind = 7 + uint(b[0])
+	}
+
+	size := uint(control[0] & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		b, err := d.bytes(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + uint(b[0])
+		case 2:
+			size = 285 + uint(uintOf(b))
+		default:
+			size = 65821 + uint(uintOf(b))
+		}
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]any, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			value, after, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+			offset = after
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 1024))
+		for i := uint(0); i < size; i++ {
+			value, after, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = after
+		}
+		return a, offset, nil
+	case typeBoolean:
+		return size != 0, offset, nil
+	}
+
+	b, err := d.bytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+	switch kind {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes, typeUint128:
+		return b, offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("double of invalid size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("float of invalid size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("integer of invalid size")
+		}
+		return uintOf(b), offset, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("integer of invalid size")
+		}
+		shift := 32 - 8*size
+		return int64(int32(uint32(uintOf(b))<<shift) >> shift), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+}
@@ -0,0 +1,205 @@
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+// Test databases are built from a trie of networks, numbered breadth first
+
+type trieNode struct {
+	children [2]*trieNode
+	// data is the offset of the record of a network ending here, -1 inside
+	data int
+}
+
+// encodeControl encodes the control byte of a value of a standard type and
+// size below 29
+func encodeControl(kind, size int) []byte {
+	return []byte{byte(kind<<5 | size)}
+}
+
+func encodeString(s string) []byte {
+	return append(encodeControl(typeString, len(s)), s...)
+}
+
+func encodeUint16(v uint16) []byte {
+	return append(encodeControl(typeUint16, 2), byte(v>>8), byte(v))
+}
+
+func encodeUint32(v uint32) []byte {
+	return append(encodeControl(typeUint32, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// encodeMap encodes a map of keys and encoded values, in order
+func encodeMap(pairs ...any) []byte {
+	out := encodeControl(typeMap, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		out = append(out, encodeString(pairs[i].(string))...)
+		out = append(out, pairs[i+1].([]byte)...)
+	}
+	return out
+}
+
+// network is a network of a test database and its encoded record
+type network struct {
+	cidr   string
+	record []byte
+}
+
+// buildDatabase writes a database of networks, its data section starting
+// with shared, the target of the pointers of the records
+func buildDatabase(t *testing.T, ipVersion, recordSize int, shared []byte, networks []network) []byte {
+	t.Helper()
+
+	root := &trieNode{data: -1}
+	data := append([]byte{}, shared...)
+	for _, n := range networks {
+		ip, ipNet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ones, _ := ipNet.Mask.Size()
+		bits := ip.To4()
+		if ipVersion == 6 {
+			// IPv4 networks are under ::/96
+			if bits != nil {
+				bits = append(make([]byte, 12), bits...)
+				ones += 96
+			} else {
+				bits = ip.To16()
+			}
+		} else if bits == nil {
+			continue
+		}
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := bits[i/8] >> (7 - i%8) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &trieNode{data: -1}
+			}
+			node = node.children[bit]
+		}
+		node.data = len(data)
+		data = append(data, n.record...)
+	}
+
+	// Number the inner nodes breadth first
+	var nodes []*trieNode
+	index := map[*trieNode]int{}
+	for queue := []*trieNode{root}; len(queue) > 0; queue = queue[1:] {
+		node := queue[0]
+		if node.data >= 0 {
+			continue
+		}
+		index[node] = len(nodes)
+		nodes = append(nodes, node)
+		for _, child := range node.children {
+			if child != nil {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	nodeCount := len(nodes)
+	var tree []byte
+	for _, node := range nodes {
+		var records [2]uint32
+		for bit, child := range node.children {
+			switch {
+			case child == nil:
+				records[bit] = uint32(nodeCount)
+			case child.data >= 0:
+				records[bit] = uint32(nodeCount + dataSectionSeparator + child.data)
+			default:
+				records[bit] = uint32(index[child])
+			}
+		}
+		left, right := records[0], records[1]
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>20&0xf0|right>>24&0x0f), byte(right>>16), byte(right>>8), byte(right))
+		default:
+			tree = append(tree, byte(left>>24), byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encodeMap(
+		"node_count", encodeUint32(uint32(nodeCount)),
+		"record_size", encodeUint16(uint16(recordSize)),
+		"ip_version", encodeUint16(uint16(ipVersion)),
+		"database_type", encodeString("Test-Country"),
+	))
+	return buf.Bytes()
+}
+
+// testNetworks are the networks of the test databases, the GeoLite2 style
+// record of 8.8.8.0/24 pointing at the shared "US"
+var testNetworks = []network{
+	{"1.0.0.0/8", encodeString("cn")},
+	{"8.8.8.0/24", encodeMap("country", encodeMap("iso_code", []byte{typePointer << 5, 0}))},
+	{"9.9.0.0/16", encodeMap("registered_country", encodeMap("iso_code", encodeString("ch")))},
+	{"10.0.0.0/8", encodeMap("continent", encodeString("none"))},
+	{"2001:db8::/32", encodeString("de")},
+}
+
+func TestReaderCountry(t *testing.T) {
+	for _, tc := range []struct {
+		ipVersion, recordSize int
+	}{{4, 24}, {6, 24}, {6, 28}, {6, 32}} {
+		db := buildDatabase(t, tc.ipVersion, tc.recordSize, encodeString("US"), testNetworks)
+		reader, err := NewReader(db)
+		if err != nil {
+			t.Fatalf("IPv%d/%d: NewReader() error = %v", tc.ipVersion, tc.recordSize, err)
+		}
+		if reader.Type != "Test-Country" {
+			t.Errorf("Type = %q, want Test-Country", reader.Type)
+		}
+
+		want := map[string]string{
+			"1.2.3.4":     "CN",
+			"8.8.8.8":     "US",
+			"9.9.9.9":     "CH",
+			"10.1.1.1":    "",
+			"192.0.2.1":   "",
+			"2001:db8::1": "DE",
+		}
+		if tc.ipVersion == 4 {
+			want["2001:db8::1"] = ""
+		}
+		for address, code := range want {
+			got, err := reader.Country(net.ParseIP(address))
+			if err != nil {
+				t.Errorf("IPv%d/%d: Country(%s) error = %v", tc.ipVersion, tc.recordSize, address, err)
+				continue
+			}
+			if got != code {
+				t.Errorf("IPv%d/%d: Country(%s) = %q, want %q", tc.ipVersion, tc.recordSize, address, got, code)
+			}
+		}
+	}
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	if _, err := NewReader([]byte("not a database")); !errors.Is(err, ErrInvalidDatabase) {
+		t.Errorf("NewReader() error = %v, want ErrInvalidDatabase", err)
+	}
+
+	// A search tree larger than the file
+	db := buildDatabase(t, 4, 24, nil, testNetworks)
+	truncated := db[len(db)-80:]
+	if _, err := NewReader(truncated); !errors.Is(err, ErrInvalidDatabase) {
+		t.Errorf("NewReader(truncated) error = %v, want ErrInvalidDatabase", err)
+	}
+}
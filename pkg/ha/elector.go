@@ -0,0 +1,181 @@
+package ha
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/metrics"
+	"sing-box-web/pkg/repository"
+)
+
+// Event types reported through metrics and the failover webhook
+const (
+	EventPromoted   = "promoted"
+	EventDemoted    = "demoted"
+	EventLeaseError = "lease_error"
+)
+
+// Elector decides which API instance is active. Every instance competes for a
+// lease stored in the shared database; the holder renews it and serves agents,
+// the others stay in warm standby and take over once it expires.
+type Elector struct {
+	config   configv1.HAConfig
+	repo     repository.LeaseRepository
+	notifier *webhookNotifier
+	logger   *zap.Logger
+
+	instanceID string
+	active     atomic.Bool
+	epoch      atomic.Int64
+
+	mu        sync.Mutex
+	listeners []func(active bool)
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewElector creates a new lease elector
+func NewElector(config configv1.HAConfig, repo repository.LeaseRepository, logger *zap.Logger) *Elector {
+	instanceID := config.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+
+	logger = logger.Named("ha")
+	return &Elector{
+		config:     config,
+		repo:       repo,
+		notifier:   newWebhookNotifier(config.WebhookURL, config.WebhookTimeout, logger),
+		logger:     logger,
+		instanceID: instanceID,
+		done:       make(chan struct{}),
+	}
+}
+
+// InstanceID returns the identifier this instance holds the lease with
+func (e *Elector) InstanceID() string {
+	return e.instanceID
+}
+
+// IsActive reports whether this instance currently holds the lease
+func (e *Elector) IsActive() bool {
+	return e.active.Load()
+}
+
+// OnChange registers a listener called whenever the instance is promoted or demoted
+func (e *Elector) OnChange(listener func(active bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.listeners = append(e.listeners, listener)
+}
+
+// Start makes a first attempt at the lease and keeps renewing it in the background
+func (e *Elector) Start(ctx context.Context) {
+	metrics.SetHAState(e.instanceID, false, 0)
+	e.renew()
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+
+		ticker := time.NewTicker(e.config.RenewInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-e.done:
+				return
+			case <-ticker.C:
+				e.renew()
+			}
+		}
+	}()
+
+	e.logger.Info("lease elector started",
+		zap.String("instance_id", e.instanceID),
+		zap.String("lease", e.config.LeaseName),
+		zap.Bool("active", e.IsActive()),
+	)
+}
+
+// Stop stops renewing and releases the lease so that a standby takes over immediately
+func (e *Elector) Stop() {
+	select {
+	case <-e.done:
+		return
+	default:
+		close(e.done)
+	}
+	e.wg.Wait()
+
+	if e.IsActive() {
+		if err := e.repo.Release(e.config.LeaseName, e.instanceID); err != nil {
+			e.logger.Error("Failed to release lease", zap.Error(err))
+		}
+		e.setActive(false, e.epoch.Load(), "")
+	}
+}
+
+// renew acquires or renews the lease and handles role changes
+func (e *Elector) renew() {
+	lease, held, err := e.repo.TryAcquire(e.config.LeaseName, e.instanceID, e.config.LeaseDuration)
+	if err != nil {
+		metrics.RecordHAEvent(EventLeaseError)
+		e.logger.Error("Failed to renew lease", zap.Error(err))
+
+		// Without the database the lease cannot be proven, step down before it could be taken over
+		if e.IsActive() {
+			e.setActive(false, e.epoch.Load(), "")
+		}
+		return
+	}
+
+	e.setActive(held, lease.Epoch, lease.Holder)
+}
+
+// setActive records the role of the instance and reports changes
+func (e *Elector) setActive(active bool, epoch int64, holder string) {
+	e.epoch.Store(epoch)
+	metrics.SetHAState(e.instanceID, active, epoch)
+
+	if e.active.Swap(active) == active {
+		return
+	}
+
+	event := EventDemoted
+	if active {
+		event = EventPromoted
+	}
+	metrics.RecordHAEvent(event)
+	e.logger.Warn("API instance role changed",
+		zap.String("event", event),
+		zap.String("instance_id", e.instanceID),
+		zap.String("lease_holder", holder),
+		zap.Int64("epoch", epoch),
+	)
+
+	e.notifier.notify(Event{
+		Type:        event,
+		InstanceID:  e.instanceID,
+		LeaseName:   e.config.LeaseName,
+		LeaseHolder: holder,
+		Epoch:       epoch,
+		Timestamp:   time.Now(),
+	})
+
+	e.mu.Lock()
+	listeners := append([]func(bool){}, e.listeners...)
+	e.mu.Unlock()
+	for _, listener := range listeners {
+		listener(active)
+	}
+}
@@ -0,0 +1,153 @@
+package ha
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+const testLeaseDuration = 200 * time.Millisecond
+
+func newLeaseRepository(t *testing.T) repository.LeaseRepository {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.ServiceLease{}); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return repository.NewLeaseRepository(db)
+}
+
+// newTestElector returns an elector renewed by the test only, and the role
+// changes it reported
+func newTestElector(repo repository.LeaseRepository, instanceID string) (*Elector, *[]bool) {
+	config := configv1.HAConfig{InstanceID: instanceID, LeaseName: "api", LeaseDuration: testLeaseDuration, RenewInterval: time.Hour}
+	elector := NewElector(config, repo, zap.NewNop())
+	var changes []bool
+	elector.OnChange(func(active bool) {
+		changes = append(changes, active)
+	})
+	return elector, &changes
+}
+
+// failingLeases fails every lease operation while failing is set
+type failingLeases struct {
+	repository.LeaseRepository
+	failing bool
+}
+
+func (f *failingLeases) TryAcquire(name, holder string, ttl time.Duration) (*models.ServiceLease, bool, error) {
+	if f.failing {
+		return nil, false, errors.New("database unavailable")
+	}
+	return f.LeaseRepository.TryAcquire(name, holder, ttl)
+}
+
+func TestElectorAcquireAndRenew(t *testing.T) {
+	elector, changes := newTestElector(newLeaseRepository(t), "a")
+
+	elector.renew()
+	if !elector.IsActive() || elector.epoch.Load() != 1 {
+		t.Fatalf("after acquiring: active %v, epoch %d; want true, 1", elector.IsActive(), elector.epoch.Load())
+	}
+	// Renewals keep the role and the epoch, without reporting a change
+	elector.renew()
+	elector.renew()
+	if !elector.IsActive() || elector.epoch.Load() != 1 {
+		t.Errorf("after renewing: active %v, epoch %d; want true, 1", elector.IsActive(), elector.epoch.Load())
+	}
+	if !slices.Equal(*changes, []bool{true}) {
+		t.Errorf("role changes = %v, want [true]", *changes)
+	}
+}
+
+func TestElectorTakeoverAfterExpiry(t *testing.T) {
+	repo := newLeaseRepository(t)
+	active, activeChanges := newTestElector(repo, "a")
+	standby, standbyChanges := newTestElector(repo, "b")
+
+	active.renew()
+	standby.renew()
+	if !active.IsActive() || standby.IsActive() {
+		t.Fatalf("active %v, standby %v; want only the first instance active", active.IsActive(), standby.IsActive())
+	}
+
+	// The active instance stops renewing, the standby takes over once the
+	// lease expired
+	time.Sleep(testLeaseDuration + 50*time.Millisecond)
+	standby.renew()
+	if !standby.IsActive() || standby.epoch.Load() != 2 {
+		t.Fatalf("standby after expiry: active %v, epoch %d; want true, 2", standby.IsActive(), standby.epoch.Load())
+	}
+
+	// The former holder learns it lost the lease at its next renewal
+	active.renew()
+	if active.IsActive() {
+		t.Fatal("instance still active after losing the lease")
+	}
+	if !slices.Equal(*activeChanges, []bool{true, false}) {
+		t.Errorf("former holder role changes = %v, want [true false]", *activeChanges)
+	}
+	if !slices.Equal(*standbyChanges, []bool{true}) {
+		t.Errorf("standby role changes = %v, want [true]", *standbyChanges)
+	}
+}
+
+func TestElectorLeaseError(t *testing.T) {
+	repo := &failingLeases{LeaseRepository: newLeaseRepository(t)}
+	elector, changes := newTestElector(repo, "a")
+
+	elector.renew()
+	if !elector.IsActive() {
+		t.Fatal("instance not active after acquiring the lease")
+	}
+
+	// Without the database the lease cannot be proven, the instance steps down
+	repo.failing = true
+	elector.renew()
+	if elector.IsActive() {
+		t.Fatal("instance still active while the lease cannot be renewed")
+	}
+
+	// and takes its lease back once the database is reachable again
+	repo.failing = false
+	elector.renew()
+	if !elector.IsActive() {
+		t.Fatal("instance not active after the database recovered")
+	}
+	if !slices.Equal(*changes, []bool{true, false, true}) {
+		t.Errorf("role changes = %v, want [true false true]", *changes)
+	}
+}
+
+func TestElectorStopReleases(t *testing.T) {
+	repo := newLeaseRepository(t)
+	active, _ := newTestElector(repo, "a")
+	standby, _ := newTestElector(repo, "b")
+
+	active.renew()
+	active.Stop()
+	if active.IsActive() {
+		t.Fatal("stopped instance still active")
+	}
+
+	// The standby takes over without waiting for the lease to expire
+	standby.renew()
+	if !standby.IsActive() {
+		t.Error("standby not active after the lease was released")
+	}
+}
@@ -0,0 +1,76 @@
+package ha
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Event is the payload posted to the failover webhook
+type Event struct {
+	Type        string    `json:"type"`
+	InstanceID  string    `json:"instance_id"`
+	LeaseName   string    `json:"lease_name"`
+	LeaseHolder string    `json:"lease_holder,omitempty"`
+	Epoch       int64     `json:"epoch"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// webhookNotifier posts failover events to a webhook
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+	logger *zap.Logger
+}
+
+// newWebhookNotifier creates a webhook notifier, posting nothing when url is empty
+func newWebhookNotifier(url string, timeout time.Duration, logger *zap.Logger) *webhookNotifier {
+	return &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+		logger: logger,
+	}
+}
+
+// notify posts the event in the background so that role changes are never delayed
+func (n *webhookNotifier) notify(event Event) {
+	if n.url == "" {
+		return
+	}
+
+	go func() {
+		if err := n.post(event); err != nil {
+			n.logger.Error("Failed to send failover webhook", zap.Error(err), zap.String("event", event.Type))
+		}
+	}()
+}
+
+// post sends one event to the webhook
+func (n *webhookNotifier) post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+// Package health reports the liveness and readiness of a server process on
+// /healthz and /readyz, as probed by Kubernetes and load balancers, and
+// through the standard gRPC health service. Liveness only tells that the
+// process still answers; readiness also requires the process to be started
+// and not shutting down, and its dependency checks to pass.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// Statuses of a report
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// errNotReady is reported while the process is starting or shutting down
+var errNotReady = errors.New("not ready")
+
+// Check tests a dependency, returning nil while it is usable
+type Check func(ctx context.Context) error
+
+// check is a named dependency check
+type check struct {
+	name string
+	run  Check
+	// optional checks degrade the report instead of failing readiness
+	optional bool
+}
+
+// Report is the result of the readiness checks
+type Report struct {
+	Status string `json:"status"`
+	// Checks holds "ok" or the error of every check by name
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Ready reports whether the process should receive traffic
+func (r Report) Ready() bool {
+	return r.Status != StatusUnavailable
+}
+
+// Checker evaluates the readiness of a process from its dependency checks
+type Checker struct {
+	timeout time.Duration
+	ready   atomic.Bool
+
+	mu     sync.RWMutex
+	checks []check
+}
+
+// NewChecker creates a checker, not ready until SetReady. Each check is
+// given timeout to complete.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a dependency the process cannot serve without
+func (c *Checker) Add(name string, run Check) {
+	c.add(check{name: name, run: run})
+}
+
+// AddOptional registers a dependency the process serves without, in a
+// degraded state. A failing optional check is reported but leaves the
+// process ready.
+func (c *Checker) AddOptional(name string, run Check) {
+	c.add(check{name: name, run: run, optional: true})
+}
+
+func (c *Checker) add(ch check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, ch)
+}
+
+// SetReady sets whether the process started and is not shutting down
+func (c *Checker) SetReady(ready bool) {
+	c.ready.Store(ready)
+}
+
+// Check runs the dependency checks concurrently and reports the readiness
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]check(nil), c.checks...)
+	c.mu.RUnlock()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.run(ctx, ch.run)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]string, len(checks)+1)}
+	if !c.ready.Load() {
+		report.Status = StatusUnavailable
+		report.Checks["lifecycle"] = errNotReady.Error()
+	}
+	for i, ch := range checks {
+		if errs[i] == nil {
+			report.Checks[ch.name] = StatusOK
+			continue
+		}
+		report.Checks[ch.name] = errs[i].Error()
+		switch {
+		case !ch.optional:
+			report.Status = StatusUnavailable
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// run runs a check within the check timeout. Checks that ignore ctx are
+// left to finish in the background.
+func (c *Checker) run(ctx context.Context, run Check) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", c.timeout)
+	}
+}
+
+// ServeLiveness answers /healthz. The process is alive as long as it answers.
+func (c *Checker) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// ServeReadiness answers /readyz with the report of the checks, 200 while
+// ready, including degraded, and 503 otherwise
+func (c *Checker) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// WatchGRPC sets the overall status of a gRPC health server from the
+// readiness every interval until ctx is done. Once the server is shut down,
+// on shutdown of the process, its status is no longer updated.
+func (c *Checker) WatchGRPC(ctx context.Context, server *health.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if c.Check(ctx).Ready() {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		server.SetServingStatus("", status)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Server serves /healthz and /readyz on a listener of its own, for
+// processes without an HTTP server
+type Server struct {
+	config     configv1.HealthEndpointConfig
+	checker    *Checker
+	logger     *zap.Logger
+	httpServer *http.Server
+}
+
+// NewServer creates a health endpoint server for checker
+func NewServer(config configv1.HealthEndpointConfig, checker *Checker, logger *zap.Logger) *Server {
+	return &Server{
+		config:  config,
+		checker: checker,
+		logger:  logger.Named("health"),
+	}
+}
+
+// Start listens and serves the endpoints in the background. It does
+// nothing when the port is 0.
+func (s *Server) Start() error {
+	if s.config.Port == 0 {
+		s.logger.Info("Health endpoints disabled")
+		return nil
+	}
+
+	address := net.JoinHostPort(s.config.Address, strconv.Itoa(s.config.Port))
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.checker.ServeLiveness)
+	mux.HandleFunc("GET /readyz", s.checker.ServeReadiness)
+	s.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Health server failed", zap.Error(err))
+		}
+	}()
+	s.logger.Info("Health endpoints started", zap.String("address", address))
+	return nil
+}
+
+// Stop stops serving the endpoints
+func (s *Server) Stop(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Shutdown(ctx)
+}
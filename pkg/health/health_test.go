@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	c := NewChecker(50 * time.Millisecond)
+	var dbErr, upstreamErr error
+	c.Add("database", func(context.Context) error { return dbErr })
+	c.AddOptional("upstream", func(context.Context) error { return upstreamErr })
+
+	if report := c.Check(context.Background()); report.Ready() || report.Checks["lifecycle"] != "not ready" {
+		t.Fatalf("ready before SetReady: %+v", report)
+	}
+
+	c.SetReady(true)
+	tests := []struct {
+		name        string
+		db          error
+		upstream    error
+		status      string
+		upstreamMsg string
+	}{
+		{"all ok", nil, nil, StatusOK, StatusOK},
+		{"optional failing", nil, errors.New("unreachable"), StatusDegraded, "unreachable"},
+		{"required failing", errors.New("closed"), errors.New("unreachable"), StatusUnavailable, "unreachable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbErr, upstreamErr = tt.db, tt.upstream
+			report := c.Check(context.Background())
+			if report.Status != tt.status || report.Checks["upstream"] != tt.upstreamMsg {
+				t.Errorf("Check() = %+v, want status %s", report, tt.status)
+			}
+		})
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := NewChecker(20 * time.Millisecond)
+	c.SetReady(true)
+	block := make(chan struct{})
+	defer close(block)
+	c.Add("stuck", func(context.Context) error {
+		<-block
+		return nil
+	})
+
+	start := time.Now()
+	report := c.Check(context.Background())
+	if report.Ready() || time.Since(start) > time.Second {
+		t.Fatalf("Check() = %+v after %s, want a timeout", report, time.Since(start))
+	}
+}
+
+func TestServeReadiness(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Add("database", func(context.Context) error { return nil })
+
+	serve := func() (int, Report) {
+		rec := httptest.NewRecorder()
+		c.ServeReadiness(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("invalid body %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, report
+	}
+
+	if code, _ := serve(); code != http.StatusServiceUnavailable {
+		t.Errorf("status %d before ready, want 503", code)
+	}
+	c.SetReady(true)
+	if code, report := serve(); code != http.StatusOK || report.Checks["database"] != StatusOK {
+		t.Errorf("status %d, report %+v once ready", code, report)
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeLiveness(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("liveness status %d, want 200", rec.Code)
+	}
+}
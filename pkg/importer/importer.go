@@ -0,0 +1,548 @@
+// Package importer moves the users, plans and nodes of other proxy panels
+// (v2board, x-ui and marzban) into this schema, so that operators can switch
+// panels without recreating their accounts. A source is first loaded into a
+// panel-neutral Dataset, which Run then writes in one transaction. Records
+// already present are kept and reported, so an import can be rerun after
+// fixing the conflicts it reported.
+package importer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// placeholderEmailDomain names the emails of imported users that had none,
+// the reserved .invalid TLD never delivers
+const placeholderEmailDomain = "import.invalid"
+
+// errDryRun rolls back the transaction of a dry run
+var errDryRun = errors.New("dry run")
+
+// Dataset is the content of a source panel, mapped to this schema. Records
+// refer to each other by the key they had in the source.
+type Dataset struct {
+	Source string
+	Groups []Group
+	Plans  []Plan
+	Nodes  []Node
+	Users  []User
+	// Notes are the source records the loader could not map
+	Notes []Entry
+}
+
+// Group is a node group of the source, granted to plans
+type Group struct {
+	Key  string
+	Name string
+}
+
+// Plan is a plan of the source
+type Plan struct {
+	Key          string
+	Name         string
+	Description  string
+	Period       models.PlanPeriod
+	Price        int64
+	TrafficQuota int64
+	SpeedLimit   int64
+	DeviceLimit  int
+	Enabled      bool
+	// Groups are the keys of the groups whose nodes the plan grants
+	Groups []string
+}
+
+// Node is a proxy server of the source
+type Node struct {
+	Key        string
+	Name       string
+	Type       models.NodeType
+	Host       string
+	Port       int
+	Method     string
+	Network    string
+	Path       string
+	HostHeader string
+	TLS        bool
+	ServerName string
+	Insecure   bool
+	Enabled    bool
+	// Groups are the keys of the groups the node belongs to
+	Groups []string
+}
+
+// User is an account of the source
+type User struct {
+	Key      string
+	Username string
+	Email    string
+	// PasswordHash is a bcrypt hash, empty when the source has none this
+	// panel can verify
+	PasswordHash string
+	UUID         string
+	Status       models.UserStatus
+	// PlanKey is empty for users of panels without plans, who get the
+	// default plan
+	PlanKey      string
+	TrafficQuota int64
+	TrafficUsed  int64
+	SpeedLimit   int64
+	DeviceLimit  int
+	ExpiresAt    *time.Time
+	CreatedAt    time.Time
+	// Nodes are the keys of the nodes the user is assigned to, for panels
+	// without plans
+	Nodes []string
+}
+
+// Options tunes how a dataset is written
+type Options struct {
+	// DefaultPlanID is the plan of users without one, the first plan when 0
+	DefaultPlanID uint
+	// DryRun reports what an import would do without writing anything
+	DryRun bool
+}
+
+// Actions of report entries
+const (
+	ActionCreated = "created"
+	ActionExisted = "existed"
+	ActionSkipped = "skipped"
+)
+
+// Entry reports what became of a source record
+type Entry struct {
+	Kind   string `json:"kind"`
+	Key    string `json:"key,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Action string `json:"action"`
+	// ID is the record of this panel it was mapped to, 0 when skipped
+	ID     uint   `json:"id,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// UserEntry reports an imported user and what the operator must send it
+type UserEntry struct {
+	Key               string `json:"key"`
+	Username          string `json:"username"`
+	Email             string `json:"email"`
+	SubscriptionToken string `json:"subscription_token"`
+	// PasswordReset is set when the source password could not be kept and
+	// the user must reset it
+	PasswordReset bool `json:"password_reset"`
+}
+
+// Report is the reconciliation report of an import
+type Report struct {
+	Source  string      `json:"source"`
+	DryRun  bool        `json:"dry_run"`
+	Entries []Entry     `json:"entries"`
+	Users   []UserEntry `json:"users"`
+}
+
+// Count returns the entries of a kind with an action
+func (r *Report) Count(kind, action string) int {
+	var n int
+	for _, entry := range r.Entries {
+		if entry.Kind == kind && entry.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *Report) add(kind, key, name, action string, id uint, detail string) {
+	r.Entries = append(r.Entries, Entry{Kind: kind, Key: key, Name: name, Action: action, ID: id, Detail: detail})
+}
+
+// Run writes a dataset to a migrated database in one transaction
+func Run(db *gorm.DB, data *Dataset, opts Options) (*Report, error) {
+	report := &Report{Source: data.Source, DryRun: opts.DryRun}
+	report.Entries = append(report.Entries, data.Notes...)
+
+	w := &writer{
+		report: report,
+		opts:   opts,
+		groups: make(map[string]uint),
+		plans:  make(map[string]uint),
+		nodes:  make(map[string]uint),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		w.tx = tx
+		if err := w.write(data); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// writer writes a dataset, mapping the source keys to the created records
+type writer struct {
+	tx     *gorm.DB
+	report *Report
+	opts   Options
+
+	groups map[string]uint
+	plans  map[string]uint
+	nodes  map[string]uint
+}
+
+func (w *writer) write(data *Dataset) error {
+	for _, group := range data.Groups {
+		if err := w.writeGroup(group); err != nil {
+			return err
+		}
+	}
+	for _, node := range data.Nodes {
+		if err := w.writeNode(node); err != nil {
+			return err
+		}
+	}
+	for _, plan := range data.Plans {
+		if err := w.writePlan(plan); err != nil {
+			return err
+		}
+	}
+
+	defaultPlan, err := w.defaultPlan()
+	if err != nil {
+		return err
+	}
+	for _, user := range data.Users {
+		if err := w.writeUser(user, defaultPlan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGroup creates a node group, reusing a group of the same name
+func (w *writer) writeGroup(g Group) error {
+	var group models.NodeGroup
+	err := w.tx.Where("name = ?", g.Name).First(&group).Error
+	switch {
+	case err == nil:
+		w.groups[g.Key] = group.ID
+		w.report.add("group", g.Key, g.Name, ActionExisted, group.ID, "")
+		return nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to look up group %q: %w", g.Name, err)
+	}
+
+	group = models.NodeGroup{Name: g.Name, Description: "Imported from " + w.report.Source}
+	if err := w.tx.Create(&group).Error; err != nil {
+		return fmt.Errorf("failed to create group %q: %w", g.Name, err)
+	}
+	w.groups[g.Key] = group.ID
+	w.report.add("group", g.Key, g.Name, ActionCreated, group.ID, "")
+	return nil
+}
+
+// writeNode creates a node, reusing a node of the same type, host and port.
+// Imported nodes are offline until their agent enrolls.
+func (w *writer) writeNode(n Node) error {
+	if !n.Type.IsValid() {
+		w.report.add("node", n.Key, n.Name, ActionSkipped, 0, fmt.Sprintf("unsupported protocol %q", n.Type))
+		return nil
+	}
+
+	var node models.Node
+	err := w.tx.Where("type = ? AND host = ? AND port = ?", n.Type, n.Host, n.Port).First(&node).Error
+	switch {
+	case err == nil:
+		w.nodes[n.Key] = node.ID
+		w.report.add("node", n.Key, n.Name, ActionExisted, node.ID, "")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		node = models.Node{
+			Name:          n.Name,
+			Type:          n.Type,
+			Status:        models.NodeStatusOffline,
+			Host:          n.Host,
+			Port:          n.Port,
+			Method:        n.Method,
+			Network:       n.Network,
+			Path:          n.Path,
+			Host_header:   n.HostHeader,
+			TLS:           n.TLS,
+			ServerName:    n.ServerName,
+			AllowInsecure: n.Insecure,
+			IsEnabled:     n.Enabled,
+			TrafficRate:   1,
+			Metadata:      map[string]string{"import_source": w.report.Source, "import_key": n.Key},
+		}
+		if node.Network == "" {
+			node.Network = "tcp"
+		}
+		if err := node.Validate(); err != nil {
+			w.report.add("node", n.Key, n.Name, ActionSkipped, 0, err.Error())
+			return nil
+		}
+		if err := w.tx.Create(&node).Error; err != nil {
+			return fmt.Errorf("failed to create node %q: %w", n.Name, err)
+		}
+		w.nodes[n.Key] = node.ID
+		w.report.add("node", n.Key, n.Name, ActionCreated, node.ID, "")
+	default:
+		return fmt.Errorf("failed to look up node %q: %w", n.Name, err)
+	}
+
+	for _, key := range n.Groups {
+		groupID, ok := w.groups[key]
+		if !ok {
+			continue
+		}
+		member := models.NodeGroupMember{GroupID: groupID, NodeID: node.ID}
+		if err := w.tx.Where(member).FirstOrCreate(&member).Error; err != nil {
+			return fmt.Errorf("failed to add node %q to its group: %w", n.Name, err)
+		}
+	}
+	return nil
+}
+
+// writePlan creates a plan granting the nodes of its groups, reusing a plan
+// of the same name
+func (w *writer) writePlan(p Plan) error {
+	var plan models.Plan
+	err := w.tx.Where("name = ?", p.Name).First(&plan).Error
+	switch {
+	case err == nil:
+		w.plans[p.Key] = plan.ID
+		w.report.add("plan", p.Key, p.Name, ActionExisted, plan.ID, "")
+		return nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to look up plan %q: %w", p.Name, err)
+	}
+
+	plan = models.Plan{
+		Name:         p.Name,
+		Description:  p.Description,
+		Status:       models.PlanStatusActive,
+		Period:       p.Period,
+		Price:        p.Price,
+		Currency:     "USD",
+		TrafficQuota: p.TrafficQuota,
+		SpeedLimit:   p.SpeedLimit,
+		DeviceLimit:  p.DeviceLimit,
+		IsPublic:     p.Enabled,
+		IsEnabled:    p.Enabled,
+	}
+	if !plan.Period.IsValid() {
+		plan.Period = models.PlanPeriodMonthly
+	}
+	if plan.DeviceLimit <= 0 {
+		plan.DeviceLimit = 1
+	}
+	if !p.Enabled {
+		plan.Status = models.PlanStatusInactive
+	}
+	if err := plan.Validate(); err != nil {
+		w.report.add("plan", p.Key, p.Name, ActionSkipped, 0, err.Error())
+		return nil
+	}
+	if err := w.tx.Create(&plan).Error; err != nil {
+		return fmt.Errorf("failed to create plan %q: %w", p.Name, err)
+	}
+
+	for _, key := range p.Groups {
+		groupID, ok := w.groups[key]
+		if !ok {
+			continue
+		}
+		access := models.PlanGroupAccess{PlanID: plan.ID, GroupID: groupID, IsEnabled: true}
+		if err := w.tx.Create(&access).Error; err != nil {
+			return fmt.Errorf("failed to grant the groups of plan %q: %w", p.Name, err)
+		}
+	}
+	w.plans[p.Key] = plan.ID
+	w.report.add("plan", p.Key, p.Name, ActionCreated, plan.ID, "")
+	return nil
+}
+
+// defaultPlan returns the plan of users without one
+func (w *writer) defaultPlan() (uint, error) {
+	var plan models.Plan
+	query := w.tx.Order("id")
+	if w.opts.DefaultPlanID != 0 {
+		query = query.Where("id = ?", w.opts.DefaultPlanID)
+	}
+	err := query.First(&plan).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if w.opts.DefaultPlanID != 0 {
+			return 0, fmt.Errorf("default plan %d not found", w.opts.DefaultPlanID)
+		}
+		// Users without a plan are reported skipped
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get default plan: %w", err)
+	}
+	return plan.ID, nil
+}
+
+// writeUser creates a user with a new subscription token, skipping users
+// whose username, email or UUID is taken
+func (w *writer) writeUser(u User, defaultPlan uint) error {
+	planID := defaultPlan
+	if u.PlanKey != "" {
+		id, ok := w.plans[u.PlanKey]
+		if !ok {
+			w.report.add("user", u.Key, u.Username, ActionSkipped, 0, "plan "+u.PlanKey+" was not imported")
+			return nil
+		}
+		planID = id
+	}
+	if planID == 0 {
+		w.report.add("user", u.Key, u.Username, ActionSkipped, 0, "no plan to assign, create one or pass a default plan")
+		return nil
+	}
+
+	username := sanitizeUsername(u.Username)
+	email := strings.ToLower(strings.TrimSpace(u.Email))
+	detail := ""
+	if email == "" || models.ValidateEmail(email) != nil {
+		email = strings.ToLower(username) + "@" + placeholderEmailDomain
+		detail = "placeholder email"
+	}
+
+	var taken int64
+	query := w.tx.Unscoped().Model(&models.User{}).Where("username = ? OR email = ?", username, email)
+	if u.UUID != "" {
+		query = query.Or("uuid = ?", u.UUID)
+	}
+	if err := query.Count(&taken).Error; err != nil {
+		return fmt.Errorf("failed to look up user %q: %w", username, err)
+	}
+	if taken > 0 {
+		w.report.add("user", u.Key, username, ActionExisted, 0, "username, email or UUID already taken")
+		return nil
+	}
+
+	hash := u.PasswordHash
+	reset := false
+	if hash == "" {
+		password, err := randomPassword()
+		if err != nil {
+			return err
+		}
+		generated, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		hash = string(generated)
+		reset = true
+	}
+
+	user := &models.User{
+		Username:     username,
+		Email:        email,
+		Password:     hash,
+		Status:       u.Status,
+		Role:         models.UserRoleUser,
+		PlanID:       planID,
+		TrafficQuota: u.TrafficQuota,
+		TrafficUsed:  u.TrafficUsed,
+		SpeedLimit:   u.SpeedLimit,
+		DeviceLimit:  u.DeviceLimit,
+		ExpiresAt:    u.ExpiresAt,
+		UUID:         u.UUID,
+		Notes:        "Imported from " + w.report.Source,
+		Metadata:     map[string]string{"import_source": w.report.Source, "import_key": u.Key},
+	}
+	if !user.Status.IsValid() {
+		user.Status = models.UserStatusActive
+	}
+	if user.DeviceLimit <= 0 {
+		user.DeviceLimit = 1
+	}
+	if !u.CreatedAt.IsZero() {
+		user.CreatedAt = u.CreatedAt
+	}
+	if user.Status == models.UserStatusExpired && user.ExpiresAt != nil {
+		expired := *user.ExpiresAt
+		user.ExpiredAt = &expired
+	}
+	if err := user.Validate(); err != nil {
+		w.report.add("user", u.Key, u.Username, ActionSkipped, 0, err.Error())
+		return nil
+	}
+	if err := w.tx.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create user %q: %w", username, err)
+	}
+	if err := w.tx.Model(&models.Plan{}).Where("id = ?", planID).
+		UpdateColumn("current_users", gorm.Expr("current_users + 1")).Error; err != nil {
+		return fmt.Errorf("failed to count user %q on its plan: %w", username, err)
+	}
+
+	for _, key := range u.Nodes {
+		nodeID, ok := w.nodes[key]
+		if !ok {
+			continue
+		}
+		if err := w.tx.Create(&models.UserNode{UserID: user.ID, NodeID: nodeID, IsEnabled: true}).Error; err != nil {
+			return fmt.Errorf("failed to assign user %q to its nodes: %w", username, err)
+		}
+		if err := w.tx.Model(&models.Node{}).Where("id = ?", nodeID).
+			UpdateColumn("current_users", gorm.Expr("current_users + 1")).Error; err != nil {
+			return fmt.Errorf("failed to count user %q on its nodes: %w", username, err)
+		}
+	}
+
+	w.report.add("user", u.Key, username, ActionCreated, user.ID, detail)
+	w.report.Users = append(w.report.Users, UserEntry{
+		Key:               u.Key,
+		Username:          username,
+		Email:             email,
+		SubscriptionToken: user.SubscriptionToken,
+		PasswordReset:     reset,
+	})
+	return nil
+}
+
+// sanitizeUsername keeps the characters usernames may have, replacing the
+// others such as the @ of email-like source names, see
+// models.ValidateUsername
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() == 0:
+			// Usernames start with a letter or digit
+		case r == '_', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	username := b.String()
+	if len(username) > models.MaxUsernameLength {
+		username = username[:models.MaxUsernameLength]
+	}
+	return username
+}
+
+// randomPassword generates the password of a user whose source password
+// could not be kept; it is never shown, the user resets it
+func randomPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
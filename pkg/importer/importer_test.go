@@ -0,0 +1,318 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"sing-box-web/pkg/migration"
+	"sing-box-web/pkg/models"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	migrator, err := migration.New(db, migration.Shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newSourceDB creates the SQLite database of a source panel from statements
+func newSourceDB(t *testing.T, statements ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "source.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open source database: %v", err)
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	return path
+}
+
+func TestImportV2Board(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Hour).Unix()
+	path := newSourceDB(t,
+		`CREATE TABLE v2_server_group (id INTEGER PRIMARY KEY, name TEXT)`,
+		`INSERT INTO v2_server_group VALUES (1, 'Premium')`,
+		`CREATE TABLE v2_server_vmess (id INTEGER PRIMARY KEY, group_id TEXT, name TEXT, host TEXT, port TEXT, server_port INTEGER,
+			tls INTEGER, network TEXT, "networkSettings" TEXT, network_settings TEXT, show INTEGER)`,
+		`INSERT INTO v2_server_vmess VALUES (1, '["1"]', 'Tokyo', 'tyo.example.com', '443', 10443, 1, 'ws', NULL,
+			'{"path":"/ws","headers":{"Host":"cdn.example.com"}}', 1)`,
+		`INSERT INTO v2_server_vmess VALUES (2, '["1"]', 'Ranged', 'rng.example.com', '1000-2000', 10443, 0, 'tcp', NULL, NULL, 1)`,
+		`CREATE TABLE v2_plan (id INTEGER PRIMARY KEY, group_id INTEGER, name TEXT, content TEXT, show INTEGER,
+			transfer_enable INTEGER, speed_limit INTEGER, device_limit INTEGER, month_price INTEGER, quarter_price INTEGER,
+			half_year_price INTEGER, year_price INTEGER, onetime_price INTEGER)`,
+		`INSERT INTO v2_plan VALUES (1, 1, 'Premium', 'Fast nodes', 1, 100, 8, 3, NULL, 2700, NULL, NULL, NULL)`,
+		`CREATE TABLE v2_user (id INTEGER PRIMARY KEY, email TEXT, password TEXT, password_algo TEXT, uuid TEXT, token TEXT,
+			plan_id INTEGER, transfer_enable INTEGER, u INTEGER, d INTEGER, expired_at INTEGER, banned INTEGER,
+			is_admin INTEGER, speed_limit INTEGER, device_limit INTEGER, created_at INTEGER)`,
+		`INSERT INTO v2_user VALUES (1, 'alice@example.com', '`+string(hash)+`', NULL, '11111111-1111-1111-1111-111111111111', 'old',
+			1, 1073741824, 100, 200, NULL, 0, 0, NULL, NULL, 1600000000)`,
+		`INSERT INTO v2_user VALUES (2, 'bob@example.com', '5ebe2294ecd0e0f08eab7690d2a6ee69', 'md5', '22222222-2222-2222-2222-222222222222', 'old',
+			1, 0, 0, 0, `+strconv.FormatInt(expired, 10)+`, 0, 0, NULL, NULL, 1600000000)`,
+		`INSERT INTO v2_user VALUES (3, 'carol@example.com', '', NULL, '33333333-3333-3333-3333-333333333333', 'old',
+			9, 0, 0, 0, NULL, 1, 0, NULL, NULL, 1600000000)`,
+	)
+
+	data, err := Load(SourceV2Board, LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	db := newTestDB(t)
+
+	// A dry run reports without writing anything
+	report, err := Run(db, data, Options{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if report.Count("user", ActionCreated) != 2 {
+		t.Errorf("dry run created %d users, want 2", report.Count("user", ActionCreated))
+	}
+	var count int64
+	db.Model(&models.User{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("dry run wrote %d users", count)
+	}
+
+	report, err = Run(db, data, Options{})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if report.Count("node", ActionCreated) != 1 || report.Count("node", ActionSkipped) != 1 {
+		t.Errorf("nodes created %d, skipped %d, want 1 and 1 (the port range)",
+			report.Count("node", ActionCreated), report.Count("node", ActionSkipped))
+	}
+	// Carol's plan does not exist in the source
+	if report.Count("user", ActionCreated) != 2 || report.Count("user", ActionSkipped) != 1 {
+		t.Errorf("users created %d, skipped %d, want 2 and 1",
+			report.Count("user", ActionCreated), report.Count("user", ActionSkipped))
+	}
+
+	var node models.Node
+	if err := db.Where("name = ?", "Tokyo").First(&node).Error; err != nil {
+		t.Fatalf("get node: %v", err)
+	}
+	if node.Port != 443 || !node.TLS || node.Path != "/ws" || node.Host_header != "cdn.example.com" || node.Status != models.NodeStatusOffline {
+		t.Errorf("node = port %d, tls %v, path %q, host %q, status %s", node.Port, node.TLS, node.Path, node.Host_header, node.Status)
+	}
+
+	var plan models.Plan
+	if err := db.Where("name = ?", "Premium").First(&plan).Error; err != nil {
+		t.Fatalf("get plan: %v", err)
+	}
+	if plan.Price != 900 || plan.TrafficQuota != 100<<30 || plan.SpeedLimit != 1_000_000 || plan.CurrentUsers != 2 {
+		t.Errorf("plan = price %d, quota %d, speed %d, users %d", plan.Price, plan.TrafficQuota, plan.SpeedLimit, plan.CurrentUsers)
+	}
+
+	var alice, bob models.User
+	if err := db.Where("email = ?", "alice@example.com").First(&alice).Error; err != nil {
+		t.Fatalf("get alice: %v", err)
+	}
+	if alice.Username != "alice_example.com" || alice.UUID != "11111111-1111-1111-1111-111111111111" || alice.TrafficUsed != 300 {
+		t.Errorf("alice = %s, uuid %s, used %d", alice.Username, alice.UUID, alice.TrafficUsed)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(alice.Password), []byte("secret")) != nil {
+		t.Error("alice lost her password")
+	}
+	if alice.SubscriptionToken == "" || alice.SubscriptionToken == "old" {
+		t.Errorf("alice subscription token = %q, want a new one", alice.SubscriptionToken)
+	}
+	if err := db.Where("email = ?", "bob@example.com").First(&bob).Error; err != nil {
+		t.Fatalf("get bob: %v", err)
+	}
+	if bob.Status != models.UserStatusExpired || bob.ExpiredAt == nil {
+		t.Errorf("bob = status %s, expired at %v, want expired", bob.Status, bob.ExpiredAt)
+	}
+
+	// Alice reaches the node through the group of her plan
+	var granted int64
+	db.Table("node_group_members").
+		Joins("JOIN plan_group_access ON plan_group_access.group_id = node_group_members.group_id").
+		Where("plan_group_access.plan_id = ? AND node_group_members.node_id = ?", plan.ID, node.ID).
+		Count(&granted)
+	if granted != 1 {
+		t.Error("the plan does not grant the node of its group")
+	}
+
+	resets := map[string]bool{}
+	for _, user := range report.Users {
+		resets[user.Username] = user.PasswordReset
+	}
+	if resets["alice_example.com"] || !resets["bob_example.com"] {
+		t.Errorf("password resets = %v, want bob only", resets)
+	}
+
+	// Rerunning finds everything in place
+	report, err = Run(db, data, Options{})
+	if err != nil {
+		t.Fatalf("rerun: %v", err)
+	}
+	if report.Count("user", ActionCreated) != 0 || report.Count("user", ActionExisted) != 2 || report.Count("plan", ActionExisted) != 1 {
+		t.Errorf("rerun created %d users, found %d users and %d plans",
+			report.Count("user", ActionCreated), report.Count("user", ActionExisted), report.Count("plan", ActionExisted))
+	}
+}
+
+func TestImportXUI(t *testing.T) {
+	path := newSourceDB(t,
+		`CREATE TABLE inbounds (id INTEGER PRIMARY KEY, user_id INTEGER, up INTEGER, down INTEGER, total INTEGER, remark TEXT,
+			enable INTEGER, expiry_time INTEGER, listen TEXT, port INTEGER, protocol TEXT, settings TEXT, stream_settings TEXT, tag TEXT)`,
+		`INSERT INTO inbounds VALUES (1, 1, 0, 0, 0, 'vless-tls', 1, 0, '', 443, 'vless',
+			'{"clients":[{"id":"11111111-1111-1111-1111-111111111111","email":"alice","totalGB":1000,"expiryTime":0,"enable":true},
+				{"id":"22222222-2222-2222-2222-222222222222","email":"bob","enable":false}]}',
+			'{"network":"tcp","security":"tls","tlsSettings":{"serverName":"vpn.example.com"}}', 'inbound-443')`,
+		`INSERT INTO inbounds VALUES (2, 1, 0, 0, 0, 'trojan', 1, 0, '', 8443, 'trojan',
+			'{"clients":[{"password":"pw","email":"alice"}]}', '{"network":"ws","wsSettings":{"path":"/t"}}', 'inbound-8443')`,
+		`INSERT INTO inbounds VALUES (3, 1, 0, 0, 0, 'socks', 1, 0, '', 1080, 'socks', '{"accounts":[]}', '', 'inbound-1080')`,
+		`CREATE TABLE client_traffics (id INTEGER PRIMARY KEY, inbound_id INTEGER, enable INTEGER, email TEXT, up INTEGER,
+			down INTEGER, expiry_time INTEGER, total INTEGER)`,
+		`INSERT INTO client_traffics VALUES (1, 1, 1, 'alice', 10, 20, 0, 1000)`,
+	)
+
+	if _, err := Load(SourceXUI, LoadOptions{Path: path}); err == nil {
+		t.Error("Load() without the server address succeeded")
+	}
+	data, err := Load(SourceXUI, LoadOptions{Path: path, NodeHost: "vpn.example.com"})
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	db := newTestDB(t)
+	if err := db.Create(&models.Plan{Name: "Default", Period: models.PlanPeriodMonthly}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Run(db, data, Options{})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	// socks inbounds are not a node type
+	if report.Count("node", ActionCreated) != 2 || report.Count("node", ActionSkipped) != 1 {
+		t.Errorf("nodes created %d, skipped %d, want 2 and 1", report.Count("node", ActionCreated), report.Count("node", ActionSkipped))
+	}
+
+	var alice models.User
+	if err := db.Where("username = ?", "alice").First(&alice).Error; err != nil {
+		t.Fatalf("get alice: %v", err)
+	}
+	if alice.Email != "alice@"+placeholderEmailDomain || alice.UUID != "11111111-1111-1111-1111-111111111111" ||
+		alice.TrafficQuota != 1000 || alice.TrafficUsed != 30 {
+		t.Errorf("alice = email %s, uuid %s, quota %d, used %d", alice.Email, alice.UUID, alice.TrafficQuota, alice.TrafficUsed)
+	}
+	var assigned int64
+	db.Model(&models.UserNode{}).Where("user_id = ?", alice.ID).Count(&assigned)
+	if assigned != 2 {
+		t.Errorf("alice is assigned to %d nodes, want both of her inbounds", assigned)
+	}
+
+	var bob models.User
+	if err := db.Where("username = ?", "bob").First(&bob).Error; err != nil {
+		t.Fatalf("get bob: %v", err)
+	}
+	if bob.Status != models.UserStatusDisabled {
+		t.Errorf("bob status = %s, want disabled", bob.Status)
+	}
+}
+
+func TestImportMarzbanExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	export := `{"users":[
+		{"username":"alice","status":"active","used_traffic":5,"data_limit":100,"expire":null,
+			"proxies":{"vless":{"id":"11111111-1111-1111-1111-111111111111","flow":""}},"created_at":"2024-01-02T03:04:05"},
+		{"username":"bob","status":"expired","used_traffic":0,"data_limit":null,"expire":1600000000,
+			"proxies":{"trojan":{"password":"pw"}},"created_at":"2024-01-02T03:04:05.123456"}
+	],"total":2}`
+	if err := os.WriteFile(path, []byte(export), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Load(SourceMarzban, LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if len(data.Users) != 2 {
+		t.Fatalf("loaded %d users, want 2", len(data.Users))
+	}
+	alice, bob := data.Users[0], data.Users[1]
+	if alice.UUID != "11111111-1111-1111-1111-111111111111" || alice.TrafficQuota != 100 || alice.ExpiresAt != nil {
+		t.Errorf("alice = %+v", alice)
+	}
+	if want := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC); !alice.CreatedAt.Equal(want) {
+		t.Errorf("alice created at %v, want %v", alice.CreatedAt, want)
+	}
+	if bob.UUID != "" || bob.Status != models.UserStatusExpired || bob.ExpiresAt == nil {
+		t.Errorf("bob = %+v", bob)
+	}
+
+	// Users are skipped when there is no plan to put them on
+	report, err := Run(newTestDB(t), data, Options{})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if report.Count("user", ActionSkipped) != 2 || report.Count("node", ActionSkipped) != 1 {
+		t.Errorf("report = %+v", report.Entries)
+	}
+}
+
+func TestImportMarzbanDatabase(t *testing.T) {
+	path := newSourceDB(t,
+		`CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT, status TEXT, used_traffic INTEGER, data_limit INTEGER,
+			expire INTEGER, created_at DATETIME, admin_id INTEGER)`,
+		`INSERT INTO users VALUES (1, 'alice', 'on_hold', 5, NULL, NULL, '2024-01-02 03:04:05.000000', 1)`,
+		`CREATE TABLE proxies (id INTEGER PRIMARY KEY, user_id INTEGER, type TEXT, settings TEXT)`,
+		`INSERT INTO proxies VALUES (1, 1, 'VMess', '{"id": "11111111-1111-1111-1111-111111111111"}')`,
+	)
+
+	data, err := Load(SourceMarzban, LoadOptions{Path: path})
+	if err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if len(data.Users) != 1 {
+		t.Fatalf("loaded %d users, want 1", len(data.Users))
+	}
+	alice := data.Users[0]
+	if alice.UUID != "11111111-1111-1111-1111-111111111111" || alice.Status != models.UserStatusActive || alice.CreatedAt.IsZero() {
+		t.Errorf("alice = %+v", alice)
+	}
+}
+
+func TestSanitizeUsername(t *testing.T) {
+	tests := map[string]string{
+		"alice@example.com": "alice_example.com",
+		"_bob":              "bob",
+		"  carol dave ":     "carol_dave",
+	}
+	for name, want := range tests {
+		if got := sanitizeUsername(name); got != want {
+			t.Errorf("sanitizeUsername(%q) = %q, want %q", name, got, want)
+		}
+		if err := models.ValidateUsername(sanitizeUsername(name)); err != nil {
+			t.Errorf("sanitizeUsername(%q) is not a valid username: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// marzbanNodesNote explains why no marzban node is imported
+const marzbanNodesNote = "marzban inbounds are defined in its Xray config, create the nodes and grant them to the default plan"
+
+// marzbanUser is a marzban user, as stored or as listed by its API. Proxies
+// are the credentials of the user by protocol.
+type marzbanUser struct {
+	ID          uint                         `json:"-"`
+	Username    string                       `json:"username"`
+	Status      string                       `json:"status"`
+	UsedTraffic int64                        `json:"used_traffic"`
+	DataLimit   int64                        `json:"data_limit"`
+	Expire      int64                        `json:"expire"`
+	CreatedAt   string                       `json:"created_at" gorm:"-"`
+	Created     time.Time                    `json:"-" gorm:"column:created_at"`
+	Proxies     map[string]map[string]string `json:"proxies" gorm:"-"`
+}
+
+type marzbanProxy struct {
+	UserID   uint
+	Type     string
+	Settings string
+}
+
+// loadMarzban reads a marzban database. Marzban has no plans, its users get
+// the default plan.
+func loadMarzban(db *gorm.DB) (*Dataset, error) {
+	var users []marzbanUser
+	if err := db.Table("users").Order("id").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to read marzban users: %w", err)
+	}
+	var proxies []marzbanProxy
+	if err := db.Table("proxies").Find(&proxies).Error; err != nil {
+		return nil, fmt.Errorf("failed to read marzban proxies: %w", err)
+	}
+
+	credentials := make(map[uint]map[string]map[string]string)
+	for _, proxy := range proxies {
+		var settings map[string]any
+		if json.Unmarshal([]byte(proxy.Settings), &settings) != nil {
+			continue
+		}
+		if credentials[proxy.UserID] == nil {
+			credentials[proxy.UserID] = make(map[string]map[string]string)
+		}
+		values := make(map[string]string, len(settings))
+		for name, value := range settings {
+			if s, ok := value.(string); ok {
+				values[name] = s
+			}
+		}
+		credentials[proxy.UserID][strings.ToLower(proxy.Type)] = values
+	}
+	for i := range users {
+		users[i].Proxies = credentials[users[i].ID]
+	}
+	return marzbanDataset(users), nil
+}
+
+// loadMarzbanExport reads the JSON of the marzban user listing API
+func loadMarzbanExport(path string) (*Dataset, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read marzban export: %w", err)
+	}
+	var export struct {
+		Users []marzbanUser `json:"users"`
+	}
+	if err := json.Unmarshal(content, &export); err != nil {
+		return nil, fmt.Errorf("failed to parse marzban export: %w", err)
+	}
+	return marzbanDataset(export.Users), nil
+}
+
+func marzbanDataset(users []marzbanUser) *Dataset {
+	data := &Dataset{Source: SourceMarzban}
+	data.Notes = append(data.Notes, Entry{Kind: "node", Action: ActionSkipped, Detail: marzbanNodesNote})
+	for _, u := range users {
+		data.Users = append(data.Users, u.user())
+	}
+	return data
+}
+
+// user maps a marzban user. Limited users ran out of traffic, which the
+// quota of the imported user enforces; users on hold start active.
+func (u *marzbanUser) user() User {
+	user := User{
+		Key:          "user:" + u.Username,
+		Username:     u.Username,
+		Status:       models.UserStatusActive,
+		TrafficQuota: u.DataLimit,
+		TrafficUsed:  u.UsedTraffic,
+		ExpiresAt:    unixTime(u.Expire),
+		CreatedAt:    u.Created,
+	}
+	switch u.Status {
+	case "disabled":
+		user.Status = models.UserStatusDisabled
+	case "expired":
+		user.Status = models.UserStatusExpired
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = parseMarzbanTime(u.CreatedAt)
+	}
+	for _, protocol := range []string{"vless", "vmess"} {
+		if id := u.Proxies[protocol]["id"]; isUUID(id) {
+			user.UUID = id
+			break
+		}
+	}
+	return user
+}
+
+// parseMarzbanTime parses the times of the marzban API, which have no zone
+// and are UTC
+func parseMarzbanTime(value string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999", "2006-01-02 15:04:05.999999"} {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,146 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Panels that can be imported
+const (
+	SourceV2Board = "v2board"
+	SourceXUI     = "x-ui"
+	SourceMarzban = "marzban"
+)
+
+// Sources lists the panels that can be imported
+var Sources = []string{SourceV2Board, SourceXUI, SourceMarzban}
+
+// mysqlPrefix marks a MySQL source database
+const mysqlPrefix = "mysql://"
+
+// LoadOptions locates the data of a source panel
+type LoadOptions struct {
+	// Path is the database of the panel, a SQLite file or a MySQL DSN
+	// prefixed with mysql://. Marzban may also be read from the JSON of
+	// its user listing API, a path ending in .json.
+	Path string
+	// NodeHost is the public address of an x-ui server, whose inbounds
+	// become nodes on that host
+	NodeHost string
+}
+
+// Load reads the users, plans and nodes of a source panel
+func Load(source string, opts LoadOptions) (*Dataset, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("the source database or export is required")
+	}
+	if source == SourceMarzban && strings.HasSuffix(strings.ToLower(opts.Path), ".json") {
+		return loadMarzbanExport(opts.Path)
+	}
+
+	var load func(*gorm.DB) (*Dataset, error)
+	switch source {
+	case SourceV2Board:
+		load = loadV2Board
+	case SourceXUI:
+		if opts.NodeHost == "" {
+			return nil, fmt.Errorf("the public address of the x-ui server is required")
+		}
+		load = func(db *gorm.DB) (*Dataset, error) { return loadXUI(db, opts.NodeHost) }
+	case SourceMarzban:
+		load = loadMarzban
+	default:
+		return nil, fmt.Errorf("unknown source %q, expected one of %s", source, strings.Join(Sources, ", "))
+	}
+
+	db, err := openSource(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	return load(db)
+}
+
+// openSource opens the database of a source panel, SQLite files read-only
+func openSource(path string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	if dsn, ok := strings.CutPrefix(path, mysqlPrefix); ok {
+		if !strings.Contains(dsn, "parseTime=") {
+			separator := "?"
+			if strings.Contains(dsn, "?") {
+				separator = "&"
+			}
+			dsn += separator + "parseTime=true"
+		}
+		dialector = mysql.Open(dsn)
+	} else {
+		dialector = sqlite.Open("file:" + path + "?mode=ro")
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source database: %w", err)
+	}
+	return db, nil
+}
+
+// hasTable checks that a source has a table, panel versions differ in the
+// protocols they have tables for
+func hasTable(db *gorm.DB, table string) bool {
+	return db.Migrator().HasTable(table)
+}
+
+// parsePort parses the port of a source node, 0 for port ranges
+func parsePort(port string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(port))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// parseKeys parses a JSON array of source keys, strings or numbers
+func parseKeys(list string) []string {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(list), &raw); err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(raw))
+	for _, item := range raw {
+		var key string
+		if err := json.Unmarshal(item, &key); err != nil {
+			key = string(item)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// unixTime converts a Unix timestamp of a source, nil for none
+func unixTime(seconds int64) *time.Time {
+	if seconds <= 0 {
+		return nil
+	}
+	t := time.Unix(seconds, 0)
+	return &t
+}
+
+// isUUID checks that a source credential can be a user UUID
+func isUUID(id string) bool {
+	return len(id) == 36 && strings.Count(id, "-") == 4
+}
+
+// key formats the key of a source record
+func key(table string, id uint) string {
+	return table + ":" + strconv.FormatUint(uint64(id), 10)
+}
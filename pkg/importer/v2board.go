@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+const (
+	// v2boardGB is the unit of v2board plan traffic
+	v2boardGB = 1 << 30
+	// v2boardMbps is the unit of v2board speed limits, in bytes/sec
+	v2boardMbps = 1_000_000 / 8
+)
+
+// v2boardServerTables are the server tables of v2board and its forks, by the
+// node type they hold
+var v2boardServerTables = []struct {
+	table string
+	typ   models.NodeType
+}{
+	{"v2_server_vmess", models.NodeTypeVMess},
+	{"v2_server_vless", models.NodeTypeVLESS},
+	{"v2_server_trojan", models.NodeTypeTrojan},
+	{"v2_server_shadowsocks", models.NodeTypeShadowsocks},
+	{"v2_server_hysteria", models.NodeTypeHysteria2},
+}
+
+type v2boardGroup struct {
+	ID   uint
+	Name string
+}
+
+type v2boardPlan struct {
+	ID             uint
+	GroupID        uint
+	Name           string
+	Content        string
+	Show           bool
+	TransferEnable int64
+	SpeedLimit     int64
+	DeviceLimit    int
+	MonthPrice     *int64
+	QuarterPrice   *int64
+	HalfYearPrice  *int64
+	YearPrice      *int64
+	OnetimePrice   *int64
+}
+
+// v2boardServer has the columns of every server table, the ones a table
+// lacks stay empty
+type v2boardServer struct {
+	ID              uint
+	GroupID         string
+	Name            string
+	Host            string
+	Port            string
+	Show            bool
+	TLS             int `gorm:"column:tls"`
+	Network         string
+	NetworkSettings string
+	ServerName      string
+	AllowInsecure   bool
+	Insecure        bool
+	Cipher          string
+	Version         int
+}
+
+type v2boardUser struct {
+	ID             uint
+	Email          string
+	Password       string
+	PasswordAlgo   string
+	UUID           string `gorm:"column:uuid"`
+	PlanID         uint
+	TransferEnable int64
+	U              int64 `gorm:"column:u"`
+	D              int64 `gorm:"column:d"`
+	ExpiredAt      int64
+	Banned         bool
+	SpeedLimit     int64
+	DeviceLimit    int
+	CreatedAt      int64
+}
+
+// loadV2Board reads a v2board database. Server groups become node groups
+// granted to the plans, and users keep their UUID and, when bcrypt hashed,
+// their password.
+func loadV2Board(db *gorm.DB) (*Dataset, error) {
+	data := &Dataset{Source: SourceV2Board}
+
+	var groups []v2boardGroup
+	if err := db.Table("v2_server_group").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to read v2board server groups: %w", err)
+	}
+	for _, group := range groups {
+		data.Groups = append(data.Groups, Group{Key: key("group", group.ID), Name: group.Name})
+	}
+
+	for _, source := range v2boardServerTables {
+		if !hasTable(db, source.table) {
+			continue
+		}
+		var servers []v2boardServer
+		if err := db.Table(source.table).Find(&servers).Error; err != nil {
+			return nil, fmt.Errorf("failed to read v2board %s servers: %w", source.typ, err)
+		}
+		for _, server := range servers {
+			node, ok := server.node(source.table, source.typ)
+			if !ok {
+				data.Notes = append(data.Notes, Entry{Kind: "node", Key: node.Key, Name: server.Name, Action: ActionSkipped,
+					Detail: fmt.Sprintf("port %q is not a single port", server.Port)})
+				continue
+			}
+			data.Nodes = append(data.Nodes, node)
+		}
+	}
+
+	var plans []v2boardPlan
+	if err := db.Table("v2_plan").Find(&plans).Error; err != nil {
+		return nil, fmt.Errorf("failed to read v2board plans: %w", err)
+	}
+	for _, plan := range plans {
+		data.Plans = append(data.Plans, plan.plan())
+	}
+
+	var users []v2boardUser
+	if err := db.Table("v2_user").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to read v2board users: %w", err)
+	}
+	now := time.Now()
+	for _, user := range users {
+		data.Users = append(data.Users, user.user(now))
+	}
+	return data, nil
+}
+
+func (s *v2boardServer) node(table string, typ models.NodeType) (Node, bool) {
+	node := Node{
+		Key:        key(table, s.ID),
+		Name:       s.Name,
+		Type:       typ,
+		Host:       s.Host,
+		Port:       parsePort(s.Port),
+		Method:     s.Cipher,
+		Network:    s.Network,
+		TLS:        s.TLS > 0 || typ == models.NodeTypeTrojan || typ == models.NodeTypeHysteria2,
+		ServerName: s.ServerName,
+		Insecure:   s.AllowInsecure || s.Insecure,
+		Enabled:    s.Show,
+	}
+	if typ == models.NodeTypeHysteria2 && s.Version == 1 {
+		node.Type = models.NodeTypeHysteria
+	}
+	for _, group := range parseKeys(s.GroupID) {
+		node.Groups = append(node.Groups, "group:"+group)
+	}
+
+	var settings struct {
+		Path        string            `json:"path"`
+		ServiceName string            `json:"serviceName"`
+		Headers     map[string]string `json:"headers"`
+	}
+	if json.Unmarshal([]byte(s.NetworkSettings), &settings) == nil {
+		node.Path = settings.Path
+		if node.Path == "" {
+			node.Path = settings.ServiceName
+		}
+		node.HostHeader = settings.Headers["Host"]
+	}
+	return node, node.Port > 0
+}
+
+// plan maps a v2board plan to its shortest priced period, quarterly and
+// half-yearly prices becoming monthly ones
+func (p *v2boardPlan) plan() Plan {
+	plan := Plan{
+		Key:          key("plan", p.ID),
+		Name:         p.Name,
+		Description:  p.Content,
+		Period:       models.PlanPeriodMonthly,
+		TrafficQuota: p.TransferEnable * v2boardGB,
+		SpeedLimit:   p.SpeedLimit * v2boardMbps,
+		DeviceLimit:  p.DeviceLimit,
+		Enabled:      p.Show,
+	}
+	if p.GroupID != 0 {
+		plan.Groups = []string{key("group", p.GroupID)}
+	}
+	switch {
+	case p.MonthPrice != nil:
+		plan.Price = *p.MonthPrice
+	case p.QuarterPrice != nil:
+		plan.Price = *p.QuarterPrice / 3
+	case p.HalfYearPrice != nil:
+		plan.Price = *p.HalfYearPrice / 6
+	case p.YearPrice != nil:
+		plan.Period, plan.Price = models.PlanPeriodYearly, *p.YearPrice
+	case p.OnetimePrice != nil:
+		plan.Period, plan.Price = models.PlanPeriodLifetime, *p.OnetimePrice
+	}
+	return plan
+}
+
+func (u *v2boardUser) user(now time.Time) User {
+	user := User{
+		Key:          key("user", u.ID),
+		Username:     u.Email,
+		Email:        u.Email,
+		UUID:         u.UUID,
+		Status:       models.UserStatusActive,
+		TrafficQuota: u.TransferEnable,
+		TrafficUsed:  u.U + u.D,
+		SpeedLimit:   u.SpeedLimit * v2boardMbps,
+		DeviceLimit:  u.DeviceLimit,
+		ExpiresAt:    unixTime(u.ExpiredAt),
+	}
+	if !isUUID(user.UUID) {
+		user.UUID = ""
+	}
+	if u.PlanID != 0 {
+		user.PlanKey = key("plan", u.PlanID)
+	}
+	if created := unixTime(u.CreatedAt); created != nil {
+		user.CreatedAt = *created
+	}
+	// Passwords migrated into v2board keep the hash of their former panel
+	if u.PasswordAlgo == "" && strings.HasPrefix(u.Password, "$2") {
+		user.PasswordHash = u.Password
+	}
+	switch {
+	case u.Banned:
+		user.Status = models.UserStatusDisabled
+	case user.ExpiresAt != nil && user.ExpiresAt.Before(now):
+		user.Status = models.UserStatusExpired
+	}
+	return user
+}
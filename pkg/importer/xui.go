@@ -0,0 +1,180 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+type xuiInbound struct {
+	ID             uint
+	Remark         string
+	Enable         bool
+	Port           int
+	Protocol       string
+	Settings       string
+	StreamSettings string
+}
+
+// xuiClientTraffic is the traffic of a client, kept by 3x-ui only
+type xuiClientTraffic struct {
+	Email  string
+	Enable bool
+	Up     int64
+	Down   int64
+}
+
+type xuiSettings struct {
+	Method  string `json:"method"`
+	Clients []struct {
+		ID         string `json:"id"`
+		Email      string `json:"email"`
+		Enable     *bool  `json:"enable"`
+		TotalGB    int64  `json:"totalGB"`
+		ExpiryTime int64  `json:"expiryTime"`
+	} `json:"clients"`
+}
+
+type xuiStreamSettings struct {
+	Network     string `json:"network"`
+	Security    string `json:"security"`
+	TLSSettings struct {
+		ServerName    string `json:"serverName"`
+		AllowInsecure bool   `json:"allowInsecure"`
+	} `json:"tlsSettings"`
+	WSSettings struct {
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers"`
+	} `json:"wsSettings"`
+	GRPCSettings struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"grpcSettings"`
+}
+
+// loadXUI reads an x-ui or 3x-ui database. Each inbound becomes a node on
+// the x-ui server and each client, identified by its email across inbounds,
+// a user assigned to the nodes of its inbounds.
+func loadXUI(db *gorm.DB, host string) (*Dataset, error) {
+	data := &Dataset{Source: SourceXUI}
+
+	var inbounds []xuiInbound
+	if err := db.Table("inbounds").Order("id").Find(&inbounds).Error; err != nil {
+		return nil, fmt.Errorf("failed to read x-ui inbounds: %w", err)
+	}
+
+	traffic := make(map[string]xuiClientTraffic)
+	if hasTable(db, "client_traffics") {
+		var rows []xuiClientTraffic
+		if err := db.Table("client_traffics").Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read x-ui client traffic: %w", err)
+		}
+		for _, row := range rows {
+			traffic[row.Email] = row
+		}
+	}
+
+	users := make(map[string]*User)
+	var emails []string
+	for _, inbound := range inbounds {
+		node := inbound.node(host)
+		data.Nodes = append(data.Nodes, node)
+
+		var settings xuiSettings
+		if err := json.Unmarshal([]byte(inbound.Settings), &settings); err != nil || len(settings.Clients) == 0 {
+			data.Notes = append(data.Notes, Entry{Kind: "user", Key: node.Key, Name: node.Name, Action: ActionSkipped,
+				Detail: "inbound without clients"})
+			continue
+		}
+		for i, client := range settings.Clients {
+			if client.Email == "" {
+				data.Notes = append(data.Notes, Entry{Kind: "user", Key: fmt.Sprintf("%s/%d", node.Key, i), Name: node.Name,
+					Action: ActionSkipped, Detail: "client without email"})
+				continue
+			}
+			if user, ok := users[client.Email]; ok {
+				user.Nodes = append(user.Nodes, node.Key)
+				continue
+			}
+
+			user := &User{
+				Key:          "client:" + client.Email,
+				Username:     client.Email,
+				Email:        client.Email,
+				Status:       models.UserStatusActive,
+				TrafficQuota: client.TotalGB,
+				ExpiresAt:    xuiExpiry(client.ExpiryTime),
+				Nodes:        []string{node.Key},
+			}
+			if isUUID(client.ID) {
+				user.UUID = client.ID
+			}
+			if client.Enable != nil && !*client.Enable {
+				user.Status = models.UserStatusDisabled
+			}
+			if row, ok := traffic[client.Email]; ok {
+				user.TrafficUsed = row.Up + row.Down
+				if !row.Enable && user.Status == models.UserStatusActive {
+					// 3x-ui disables the clients that ran out of traffic or time
+					user.Status = models.UserStatusSuspended
+				}
+			}
+			if user.ExpiresAt != nil && user.ExpiresAt.Before(time.Now()) && user.Status != models.UserStatusDisabled {
+				user.Status = models.UserStatusExpired
+			}
+			users[client.Email] = user
+			emails = append(emails, client.Email)
+		}
+	}
+
+	// Users are listed once their nodes across inbounds are collected
+	for _, email := range emails {
+		data.Users = append(data.Users, *users[email])
+	}
+	return data, nil
+}
+
+func (i *xuiInbound) node(host string) Node {
+	node := Node{
+		Key:     key("inbound", i.ID),
+		Name:    i.Remark,
+		Type:    models.NodeType(i.Protocol),
+		Host:    host,
+		Port:    i.Port,
+		Enabled: i.Enable,
+	}
+	if node.Name == "" {
+		node.Name = fmt.Sprintf("x-ui %s %d", i.Protocol, i.Port)
+	}
+
+	var settings xuiSettings
+	if json.Unmarshal([]byte(i.Settings), &settings) == nil {
+		node.Method = settings.Method
+	}
+	var stream xuiStreamSettings
+	if json.Unmarshal([]byte(i.StreamSettings), &stream) == nil {
+		node.Network = stream.Network
+		node.TLS = stream.Security == "tls" || stream.Security == "reality"
+		node.ServerName = stream.TLSSettings.ServerName
+		node.Insecure = stream.TLSSettings.AllowInsecure
+		node.Path = stream.WSSettings.Path
+		node.HostHeader = stream.WSSettings.Headers["Host"]
+		if stream.Network == "grpc" {
+			node.Path = stream.GRPCSettings.ServiceName
+		}
+	}
+	return node
+}
+
+// xuiExpiry converts the expiry of an x-ui client in milliseconds, nil for
+// none. Negative expiries of 3x-ui start counting at the first use.
+func xuiExpiry(millis int64) *time.Time {
+	if millis <= 0 {
+		return nil
+	}
+	t := time.UnixMilli(millis)
+	return &t
+}
@@ -0,0 +1,146 @@
+// Package lifecycle runs a server process until SIGINT or SIGTERM and then
+// shuts its parts down in order: readiness is reported lost first, then the
+// parts are stopped in the reverse order they were added, within a deadline.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// Readiness is told whether the process accepts new work, e.g. a server
+// answering its readiness checks
+type Readiness interface {
+	SetReady(ready bool)
+}
+
+// component is a part of the process stopped on shutdown
+type component struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager coordinates the shutdown of a server process
+type Manager struct {
+	config configv1.ShutdownConfig
+	logger *zap.Logger
+
+	mu         sync.Mutex
+	components []component
+	readiness  []Readiness
+	ready      atomic.Bool
+	once       sync.Once
+	err        error
+}
+
+// NewManager creates a new lifecycle manager
+func NewManager(config configv1.ShutdownConfig, logger *zap.Logger) *Manager {
+	return &Manager{
+		config: config,
+		logger: logger.Named("lifecycle"),
+	}
+}
+
+// Add registers a part to stop on shutdown. Parts are stopped in the reverse
+// order they were added, so a part may rely on the ones added before it.
+func (m *Manager) Add(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.components = append(m.components, component{name: name, stop: stop})
+}
+
+// AddReadiness registers a part told when the process becomes ready and when
+// it stops accepting work
+func (m *Manager) AddReadiness(r Readiness) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readiness = append(m.readiness, r)
+	r.SetReady(m.ready.Load())
+}
+
+// SetReady marks the process ready once everything started
+func (m *Manager) SetReady() {
+	m.setReady(true)
+}
+
+// Ready reports whether the process is ready and not shutting down
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+// Run waits for SIGINT, SIGTERM or the end of ctx and shuts down. A second
+// signal during the shutdown kills the process.
+func (m *Manager) Run(ctx context.Context) error {
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	<-signalCtx.Done()
+	// Restore the default behaviour so that a second signal exits right away
+	stop()
+
+	m.logger.Info("Shutdown requested")
+	return m.Shutdown()
+}
+
+// Shutdown reports the process not ready, waits the drain delay and stops
+// the parts within the shutdown timeout. Later calls return the first result.
+func (m *Manager) Shutdown() error {
+	m.once.Do(func() {
+		m.err = m.shutdown()
+	})
+	return m.err
+}
+
+func (m *Manager) shutdown() error {
+	m.setReady(false)
+	if m.config.DrainDelay > 0 {
+		m.logger.Info("Draining before shutdown", zap.Duration("delay", m.config.DrainDelay))
+		time.Sleep(m.config.DrainDelay)
+	}
+
+	ctx := context.Background()
+	if m.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.config.Timeout)
+		defer cancel()
+	}
+
+	m.mu.Lock()
+	components := append([]component(nil), m.components...)
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(components) - 1; i >= 0; i-- {
+		c := components[i]
+		start := time.Now()
+		if err := c.stop(ctx); err != nil {
+			m.logger.Error("Failed to stop", zap.String("component", c.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", c.name, err))
+			continue
+		}
+		m.logger.Info("Stopped", zap.String("component", c.name), zap.Duration("took", time.Since(start)))
+	}
+
+	if ctx.Err() != nil {
+		m.logger.Warn("Shutdown timed out", zap.Duration("timeout", m.config.Timeout))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) setReady(ready bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ready.Store(ready)
+	for _, r := range m.readiness {
+		r.SetReady(ready)
+	}
+}
@@ -0,0 +1,105 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+type readiness struct {
+	ready bool
+}
+
+func (r *readiness) SetReady(ready bool) {
+	r.ready = ready
+}
+
+func TestShutdown(t *testing.T) {
+	m := NewManager(configv1.ShutdownConfig{Timeout: time.Second}, zap.NewNop())
+	server := &readiness{}
+	m.AddReadiness(server)
+	if server.ready || m.Ready() {
+		t.Fatal("ready before SetReady")
+	}
+	m.SetReady()
+	if !server.ready || !m.Ready() {
+		t.Fatal("not ready after SetReady")
+	}
+
+	var stopped []string
+	add := func(name string, err error) {
+		m.Add(name, func(ctx context.Context) error {
+			// Readiness is lost before anything stops
+			if server.ready {
+				t.Errorf("%s stopped while ready", name)
+			}
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("%s stopped without a deadline", name)
+			}
+			stopped = append(stopped, name)
+			return err
+		})
+	}
+	add("database", nil)
+	add("jobs", errors.New("jobs still running"))
+	add("server", nil)
+
+	err := m.Shutdown()
+	if err == nil || !strings.Contains(err.Error(), "jobs: jobs still running") {
+		t.Errorf("Shutdown() = %v, want the jobs error", err)
+	}
+	if got := strings.Join(stopped, ","); got != "server,jobs,database" {
+		t.Errorf("stopped %s, want server,jobs,database", got)
+	}
+	if m.Ready() {
+		t.Error("ready after shutdown")
+	}
+
+	// Later calls do not stop anything again
+	if again := m.Shutdown(); again != err {
+		t.Errorf("second Shutdown() = %v, want %v", again, err)
+	}
+	if len(stopped) != 3 {
+		t.Errorf("stopped %d times, want 3", len(stopped))
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	m := NewManager(configv1.ShutdownConfig{Timeout: 10 * time.Millisecond}, zap.NewNop())
+	m.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- m.Shutdown() }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Shutdown() = %v, want deadline exceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not stop at the timeout")
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	m := NewManager(configv1.ShutdownConfig{Timeout: time.Second}, zap.NewNop())
+	stopped := false
+	m.Add("server", func(context.Context) error {
+		stopped = true
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Run(ctx); err != nil || !stopped {
+		t.Errorf("Run() = %v, stopped %v, want nil and stopped", err, stopped)
+	}
+}
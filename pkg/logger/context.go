@@ -0,0 +1,65 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the request ID of a request: the X-Request-ID HTTP
+// header, the x-request-id gRPC metadata and the response headers of both
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds the request IDs accepted from callers, which end up
+// in logs and the audit log
+const maxRequestIDLen = 64
+
+// requestIDContextKey is the context key of the request ID
+type requestIDContextKey struct{}
+
+// ContextWithRequestID returns a context carrying a request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID a context carries, empty when none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// RequestIDOrNew returns the request ID a caller supplied when it is a valid
+// one, a new ID otherwise
+func RequestIDOrNew(requestID string) string {
+	if !validRequestID(requestID) {
+		return uuid.New().String()
+	}
+	return requestID
+}
+
+// validRequestID checks that a request ID is short and made of letters,
+// digits and separators only, so that it is safe to log and to echo
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// FromContext returns base with the request ID of ctx as a field, base
+// itself when ctx carries none
+func FromContext(ctx context.Context, base *zap.Logger) *zap.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return base.With(zap.String("request_id", requestID))
+	}
+	return base
+}
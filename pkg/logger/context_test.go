@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestIDOrNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		supplied string
+		keep     bool
+	}{
+		{"uuid", "3f8c2a1e-5b7d-4c9a-8e6f-1a2b3c4d5e6f", true},
+		{"separators", "web:req_1.2", true},
+		{"empty", "", false},
+		{"too long", strings.Repeat("a", 65), false},
+		{"newline", "req\nforged", false},
+		{"space", "req 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RequestIDOrNew(tt.supplied)
+			if tt.keep && got != tt.supplied {
+				t.Errorf("RequestIDOrNew(%q) = %q, want it kept", tt.supplied, got)
+			}
+			if !tt.keep && (got == tt.supplied || !validRequestID(got)) {
+				t.Errorf("RequestIDOrNew(%q) = %q, want a new ID", tt.supplied, got)
+			}
+		})
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	base := zap.New(core)
+
+	FromContext(context.Background(), base).Info("without")
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	if got := RequestIDFromContext(ctx); got != "req-1" {
+		t.Fatalf("RequestIDFromContext() = %q, want req-1", got)
+	}
+	FromContext(ctx, base).Info("with")
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	if _, ok := entries[0].ContextMap()["request_id"]; ok {
+		t.Error("entry without request ID has a request_id field")
+	}
+	if got := entries[1].ContextMap()["request_id"]; got != "req-1" {
+		t.Errorf("request_id = %v, want req-1", got)
+	}
+}
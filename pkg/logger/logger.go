@@ -19,8 +19,8 @@ var (
 	globalLogger *zap.Logger
 	// Global sugared logger instance
 	globalSugar *zap.SugaredLogger
-	// Current log level for dynamic level changes
-	currentLevel zapcore.Level
+	// Level of the loggers created by NewLogger, changed by SetLevel
+	currentLevel = zap.NewAtomicLevel()
 )
 
 // Logger wraps zap.Logger with additional functionality
@@ -77,10 +77,10 @@ func NewLogger(config configv1.LogConfig) (*Logger, error) {
 	}
 
 	// Store current level for dynamic changes
-	currentLevel = level
+	currentLevel.SetLevel(level)
 
 	// Create core
-	core := zapcore.NewCore(encoder, writeSyncer, level)
+	core := zapcore.NewCore(encoder, writeSyncer, currentLevel)
 
 	// Create logger with options
 	opts := []zap.Option{
@@ -355,21 +355,20 @@ func LogWithLevel(level zapcore.Level, msg string, fields ...zap.Field) {
 	}
 }
 
-// SetLevel dynamically changes the log level
+// SetLevel dynamically changes the log level of the loggers created by
+// NewLogger
 func SetLevel(level string) error {
-	_, err := parseLogLevel(level)
+	parsed, err := parseLogLevel(level)
 	if err != nil {
 		return err
 	}
-
-	// This requires rebuilding the logger to change the level
-	// For simplicity, we'll return an error indicating restart is needed
-	return fmt.Errorf("dynamic level change requires logger restart")
+	currentLevel.SetLevel(parsed)
+	return nil
 }
 
 // GetCurrentLevel returns the current log level as a string
 func GetCurrentLevel() string {
-	switch currentLevel {
+	switch currentLevel.Level() {
 	case zapcore.DebugLevel:
 		return "debug"
 	case zapcore.InfoLevel:
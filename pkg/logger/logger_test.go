@@ -228,6 +228,27 @@ func TestParseLogLevel(t *testing.T) {
 	}
 }
 
+func TestSetLevel(t *testing.T) {
+	logger, err := NewLogger(configv1.LogConfig{Level: "info", Format: "json", Output: "stdout"})
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	if logger.Core().Enabled(zapcore.DebugLevel) {
+		t.Fatal("debug enabled at info level")
+	}
+
+	if err := SetLevel("debug"); err != nil {
+		t.Fatalf("SetLevel() error = %v", err)
+	}
+	if !logger.Core().Enabled(zapcore.DebugLevel) || GetCurrentLevel() != "debug" {
+		t.Error("SetLevel() did not change the level of the existing logger")
+	}
+	if err := SetLevel("invalid"); err == nil {
+		t.Error("SetLevel() accepted an invalid level")
+	}
+	SetLevel("info")
+}
+
 func TestHelperFunctions(t *testing.T) {
 	// Initialize logger
 	config := configv1.LogConfig{
@@ -0,0 +1,350 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+var (
+	// ErrUnknownTemplate is returned for a template name that does not exist
+	ErrUnknownTemplate = errors.New("unknown mail template")
+	// ErrTemplateDisabled is returned by Send for a template turned off in the configuration
+	ErrTemplateDisabled = errors.New("mail template is disabled")
+	// ErrQueueFull is returned by Send while the send queue is full
+	ErrQueueFull = errors.New("mail queue is full")
+)
+
+// base64LineLength is the length of the base64 lines of attachments
+const base64LineLength = 76
+
+// Sender delivers a rendered message
+type Sender interface {
+	Send(from string, to []string, message []byte) error
+}
+
+// Message is a mail to render from a template and send to one recipient
+type Message struct {
+	Template string
+	To       string
+	// Username addresses the recipient in the greeting
+	Username string
+	// TenantID selects the brand the mail is sent on behalf of, nil for the default one
+	TenantID *uint
+	// Data holds the template specific fields
+	Data map[string]string
+	// Attachments are sent along with the body
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to a mail
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// queuedMessage is a message waiting in the send queue
+type queuedMessage struct {
+	message  *Message
+	attempts int
+}
+
+// Mailer renders templated mails and sends them from a queue, retrying
+// failed deliveries with a growing backoff
+type Mailer struct {
+	config    configv1.MailConfig
+	defaults  models.Branding
+	tenants   repository.TenantRepository
+	sender    Sender
+	templates *templateSet
+	logger    *zap.Logger
+
+	queue chan *queuedMessage
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// NewMailer creates a mailer sending through the configured SMTP server.
+// defaults is the branding of users without a tenant.
+func NewMailer(config configv1.MailConfig, defaults models.Branding, tenants repository.TenantRepository, logger *zap.Logger) (*Mailer, error) {
+	return newMailer(config, defaults, tenants, newSMTPSender(config), logger)
+}
+
+func newMailer(config configv1.MailConfig, defaults models.Branding, tenants repository.TenantRepository, sender Sender, logger *zap.Logger) (*Mailer, error) {
+	templates, err := parseTemplates()
+	if err != nil {
+		return nil, err
+	}
+	if defaults.PanelName == "" {
+		defaults.PanelName = "sing-box-web"
+	}
+
+	return &Mailer{
+		config:    config,
+		defaults:  defaults,
+		tenants:   tenants,
+		sender:    sender,
+		templates: templates,
+		logger:    logger.Named("mail"),
+		queue:     make(chan *queuedMessage, config.QueueSize),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start starts sending queued mails
+func (m *Mailer) Start(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.done:
+				return
+			case queued := <-m.queue:
+				m.deliver(queued)
+			}
+		}
+	}()
+
+	m.logger.Info("mailer started",
+		zap.String("smtp_host", m.config.SMTPHost),
+		zap.Int("queue_size", m.config.QueueSize),
+	)
+}
+
+// Stop stops sending; mails still queued or waiting for a retry are dropped
+func (m *Mailer) Stop() {
+	m.once.Do(func() { close(m.done) })
+	m.wg.Wait()
+	if pending := len(m.queue); pending > 0 {
+		m.logger.Warn("Dropped queued mails on shutdown", zap.Int("mails", pending))
+	}
+}
+
+// Enabled reports whether a template exists and is not turned off
+func (m *Mailer) Enabled(template string) bool {
+	if _, ok := m.templates.subjects[template]; !ok {
+		return false
+	}
+	enabled, ok := m.config.Templates[template]
+	return !ok || enabled
+}
+
+// Send queues a mail. It fails when the template is unknown or disabled and
+// while the queue is full; delivery errors are only logged.
+func (m *Mailer) Send(message *Message) error {
+	if _, ok := m.templates.subjects[message.Template]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTemplate, message.Template)
+	}
+	if !m.Enabled(message.Template) {
+		return ErrTemplateDisabled
+	}
+
+	select {
+	case m.queue <- &queuedMessage{message: message}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// SendNow renders and sends a mail right away, once, and returns the
+// delivery error. Disabled templates are sent as well.
+func (m *Mailer) SendNow(message *Message) error {
+	raw, err := m.build(message)
+	if err != nil {
+		return err
+	}
+	return m.sender.Send(m.config.From, []string{message.To}, raw)
+}
+
+// SampleMessage returns a message of template filled with sample data, for test sends
+func SampleMessage(template, to string) (*Message, error) {
+	data, ok := sampleData[template]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, template)
+	}
+	return &Message{Template: template, To: to, Username: "user", Data: data}, nil
+}
+
+// deliver sends a queued mail and schedules a retry when that fails
+func (m *Mailer) deliver(queued *queuedMessage) {
+	message := queued.message
+	queued.attempts++
+
+	raw, err := m.build(message)
+	if err == nil {
+		err = m.sender.Send(m.config.From, []string{message.To}, raw)
+	}
+	if err == nil {
+		m.logger.Debug("Mail sent", zap.String("template", message.Template), zap.String("to", message.To))
+		return
+	}
+
+	if queued.attempts > m.config.MaxRetries {
+		m.logger.Error("Failed to send mail, giving up",
+			zap.String("template", message.Template),
+			zap.String("to", message.To),
+			zap.Int("attempts", queued.attempts),
+			zap.Error(err),
+		)
+		return
+	}
+
+	backoff := m.config.RetryBackoff * time.Duration(queued.attempts)
+	m.logger.Warn("Failed to send mail, retrying",
+		zap.String("template", message.Template),
+		zap.String("to", message.To),
+		zap.Int("attempts", queued.attempts),
+		zap.Duration("backoff", backoff),
+		zap.Error(err),
+	)
+	time.AfterFunc(backoff, func() {
+		select {
+		case <-m.done:
+		case m.queue <- queued:
+		default:
+			m.logger.Error("Dropped mail retry, queue is full",
+				zap.String("template", message.Template),
+				zap.String("to", message.To),
+			)
+		}
+	})
+}
+
+// build renders a message into an RFC 5322 mail with an HTML body, in a
+// multipart mail along with its attachments when it has any
+func (m *Mailer) build(message *Message) ([]byte, error) {
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", message.To, err)
+	}
+
+	brand := m.branding(message.TenantID)
+	subject, body, err := m.templates.render(message.Template, &view{
+		Brand:    brand,
+		BaseURL:  strings.TrimSuffix(m.config.BaseURL, "/"),
+		Username: message.Username,
+		Data:     message.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", brand.MailFrom(m.config.From))
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID(m.config.From))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	if len(message.Attachments) == 0 {
+		buf.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		if err := writeQuotedPrintable(&buf, body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(part, body); err != nil {
+		return nil, err
+	}
+	for _, attachment := range message.Attachments {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachment.ContentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, attachment.Content); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable writes a body encoded as quoted-printable
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeBase64 writes content encoded as base64 in lines of the length mail
+// allows
+func writeBase64(w io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 0 {
+		line := encoded[:min(base64LineLength, len(encoded))]
+		encoded = encoded[len(line):]
+		if _, err := io.WriteString(w, line+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// branding returns the brand of a tenant with empty fields taken from the
+// defaults. A tenant that cannot be loaded falls back to the defaults.
+func (m *Mailer) branding(tenantID *uint) models.Branding {
+	if tenantID == nil || m.tenants == nil {
+		return m.defaults
+	}
+	tenant, err := m.tenants.GetByID(*tenantID)
+	if err != nil {
+		m.logger.Warn("Failed to get tenant, using default branding", zap.Uint("tenant_id", *tenantID), zap.Error(err))
+		return m.defaults
+	}
+	return tenant.Branding.Merge(m.defaults)
+}
+
+// messageID returns a unique Message-ID in the domain of the sender
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		domain = from[at+1:]
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
@@ -0,0 +1,232 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	netmail "net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+// fakeSender records sent mails and fails the first failures sends
+type fakeSender struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	sent     [][]byte
+}
+
+func (s *fakeSender) Send(from string, to []string, message []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errors.New("connection refused")
+	}
+	s.sent = append(s.sent, message)
+	return nil
+}
+
+func (s *fakeSender) counts() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts, len(s.sent)
+}
+
+func testMailer(t *testing.T, sender Sender, templates map[string]bool) *Mailer {
+	t.Helper()
+	config := configv1.DefaultMailConfig()
+	config.From = "noreply@example.com"
+	config.BaseURL = "https://panel.example.com/"
+	config.RetryBackoff = time.Millisecond
+	config.Templates = templates
+	m, err := newMailer(config, models.Branding{PanelName: "Acme VPN"}, nil, sender, zap.NewNop())
+	if err != nil {
+		t.Fatalf("newMailer: %v", err)
+	}
+	return m
+}
+
+// decodeBody returns the headers and the decoded HTML body of a built mail
+func decodeBody(t *testing.T, raw []byte) (string, string) {
+	t.Helper()
+	headers, body, ok := strings.Cut(string(raw), "\r\n\r\n")
+	if !ok {
+		t.Fatalf("mail has no body: %q", raw)
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return headers, string(decoded)
+}
+
+func TestBuildTemplates(t *testing.T) {
+	m := testMailer(t, &fakeSender{}, nil)
+
+	for _, name := range Templates {
+		message, err := SampleMessage(name, "alice@example.com")
+		if err != nil {
+			t.Fatalf("SampleMessage(%s): %v", name, err)
+		}
+		message.Username = "alice"
+		raw, err := m.build(message)
+		if err != nil {
+			t.Fatalf("build(%s): %v", name, err)
+		}
+
+		headers, body := decodeBody(t, raw)
+		if !strings.Contains(headers, `From: "Acme VPN" <noreply@example.com>`) {
+			t.Errorf("%s: sender not branded: %s", name, headers)
+		}
+		if !strings.Contains(headers, "To: <alice@example.com>") {
+			t.Errorf("%s: missing recipient: %s", name, headers)
+		}
+		if !strings.Contains(body, "alice") || !strings.Contains(body, "Acme VPN") {
+			t.Errorf("%s: body misses user or brand: %s", name, body)
+		}
+	}
+}
+
+func TestBuildEscapesData(t *testing.T) {
+	m := testMailer(t, &fakeSender{}, nil)
+
+	raw, err := m.build(&Message{
+		Template: TemplateQuotaWarning,
+		To:       "alice@example.com",
+		Username: "<b>alice</b>",
+		Data:     map[string]string{"title": "t", "message": "<script>x</script>"},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	_, body := decodeBody(t, raw)
+	if strings.Contains(body, "<script>") || strings.Contains(body, "<b>alice") {
+		t.Errorf("data not escaped: %s", body)
+	}
+}
+
+func TestSendChecksTemplate(t *testing.T) {
+	m := testMailer(t, &fakeSender{}, map[string]bool{TemplateWelcome: false})
+
+	if err := m.Send(&Message{Template: "nope", To: "alice@example.com"}); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("unknown template: err = %v, want ErrUnknownTemplate", err)
+	}
+	if err := m.Send(&Message{Template: TemplateWelcome, To: "alice@example.com"}); !errors.Is(err, ErrTemplateDisabled) {
+		t.Errorf("disabled template: err = %v, want ErrTemplateDisabled", err)
+	}
+	if !m.Enabled(TemplatePasswordReset) {
+		t.Error("unlisted template should be enabled")
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		wantAttempts int
+		wantSent     int
+	}{
+		{"succeeds after retries", 2, 3, 1},
+		{"gives up", 10, 4, 0}, // one send and MaxRetries (3) retries
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &fakeSender{failures: tt.failures}
+			m := testMailer(t, sender, nil)
+			m.Start(context.Background())
+			defer m.Stop()
+
+			if err := m.Send(&Message{Template: TemplateWelcome, To: "alice@example.com"}); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			deadline := time.Now().Add(2 * time.Second)
+			for {
+				attempts, sent := sender.counts()
+				if attempts >= tt.wantAttempts && sent == tt.wantSent {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("attempts = %d, sent = %d, want %d and %d", attempts, sent, tt.wantAttempts, tt.wantSent)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			// No attempts beyond the retry limit
+			time.Sleep(20 * time.Millisecond)
+			if attempts, _ := sender.counts(); attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestBuildAttachments(t *testing.T) {
+	m := testMailer(t, &fakeSender{}, nil)
+	content := []byte(strings.Repeat("user,traffic\n", 20))
+
+	raw, err := m.build(&Message{
+		Template:    TemplateTrafficReport,
+		To:          "admin@example.com",
+		Username:    "admin",
+		Data:        map[string]string{"period": "2024-03", "total": "1.0 GB", "users": "2", "nodes": "1"},
+		Attachments: []Attachment{{Filename: "traffic-users-2024-03.csv", ContentType: "text/csv; charset=utf-8", Content: content}},
+	})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	msg, err := netmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("read mail: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("content type = %q, want multipart/mixed", msg.Header.Get("Content-Type"))
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	body, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("read body part: %v", err)
+	}
+	html, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if !strings.Contains(string(html), "Traffic report for 2024-03") {
+		t.Errorf("body = %s, want the report period", html)
+	}
+
+	attachment, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("read attachment part: %v", err)
+	}
+	if attachment.FileName() != "traffic-users-2024-03.csv" {
+		t.Errorf("attachment name = %q", attachment.FileName())
+	}
+	encoded, err := io.ReadAll(attachment)
+	if err != nil {
+		t.Fatalf("read attachment: %v", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if err != nil || !bytes.Equal(decoded, content) {
+		t.Errorf("attachment = %q, %v, want the report", decoded, err)
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("next part: %v, want the end of the mail", err)
+	}
+}
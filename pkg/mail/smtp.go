@@ -0,0 +1,82 @@
+package mail
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// smtpSender sends mails through an SMTP server
+type smtpSender struct {
+	config configv1.MailConfig
+}
+
+// newSMTPSender creates a sender for the configured SMTP server
+func newSMTPSender(config configv1.MailConfig) Sender {
+	return &smtpSender{config: config}
+}
+
+// Send delivers one message, the whole exchange bounded by the send timeout
+func (s *smtpSender) Send(from string, to []string, message []byte) error {
+	address := net.JoinHostPort(s.config.SMTPHost, strconv.Itoa(s.config.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: s.config.SMTPHost, MinVersion: tls.VersionTLS12}
+	dialer := &net.Dialer{Timeout: s.config.SendTimeout}
+
+	var conn net.Conn
+	var err error
+	if s.config.Encryption == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", address, err)
+	}
+	if s.config.SendTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.config.SendTimeout))
+	}
+
+	client, err := smtp.NewClient(conn, s.config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if s.config.Encryption == "starttls" {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if s.config.SMTPUser != "" {
+		auth := smtp.PlainAuth("", s.config.SMTPUser, s.config.SMTPPassword, s.config.SMTPHost)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,104 @@
+package mail
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"text/template"
+
+	"sing-box-web/pkg/models"
+)
+
+// Template names
+const (
+	TemplateWelcome           = "welcome"
+	TemplatePasswordReset     = "password_reset"
+	TemplateEmailVerification = "email_verification"
+	TemplateQuotaWarning      = "quota_warning"
+	TemplateExpiryReminder    = "expiry_reminder"
+	TemplateTrafficReport     = "traffic_report"
+)
+
+// Templates lists the names of all templates
+var Templates = []string{
+	TemplateWelcome, TemplatePasswordReset, TemplateEmailVerification, TemplateQuotaWarning, TemplateExpiryReminder,
+	TemplateTrafficReport,
+}
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// subjects are the subject lines of the templates, rendered as plain text
+var subjects = map[string]string{
+	TemplateWelcome:           "Welcome to {{.Brand.PanelName}}",
+	TemplatePasswordReset:     "Reset your {{.Brand.PanelName}} password",
+	TemplateEmailVerification: "Verify your {{.Brand.PanelName}} email address",
+	TemplateQuotaWarning:      "{{.Data.title}}",
+	TemplateExpiryReminder:    "Your {{.Brand.PanelName}} plan expires soon",
+	TemplateTrafficReport:     "{{.Brand.PanelName}} traffic report for {{.Data.period}}",
+}
+
+// sampleData fills the template specific fields of test sends
+var sampleData = map[string]map[string]string{
+	TemplateWelcome:           {},
+	TemplatePasswordReset:     {"reset_url": "https://panel.example.com/reset-password?token=sample", "expires_in": "1 hour"},
+	TemplateEmailVerification: {"verify_url": "https://panel.example.com/verify-email?token=sample", "expires_in": "2 days"},
+	TemplateQuotaWarning:      {"title": "Traffic quota almost used up", "message": "You have used 8.0 GB of 10.0 GB traffic for this period."},
+	TemplateExpiryReminder:    {"title": "Plan expiring soon", "message": "Your plan expires on 2030-01-01 00:00 UTC. Renew it to keep your service."},
+	TemplateTrafficReport:     {"period": "2030-01", "total": "1.2 TB", "users": "250", "nodes": "8"},
+}
+
+// view is what templates are rendered with
+type view struct {
+	Brand    models.Branding
+	BaseURL  string
+	Username string
+	// Data holds the template specific fields, see sampleData
+	Data map[string]string
+}
+
+// templateSet holds the parsed templates
+type templateSet struct {
+	subjects map[string]*template.Template
+	bodies   map[string]*htmltemplate.Template
+}
+
+// parseTemplates parses every template with the shared layout
+func parseTemplates() (*templateSet, error) {
+	set := &templateSet{
+		subjects: make(map[string]*template.Template, len(Templates)),
+		bodies:   make(map[string]*htmltemplate.Template, len(Templates)),
+	}
+	for _, name := range Templates {
+		subject, err := template.New(name).Option("missingkey=zero").Parse(subjects[name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse subject of mail template %s: %w", name, err)
+		}
+		body, err := htmltemplate.New(name).Option("missingkey=zero").
+			ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse mail template %s: %w", name, err)
+		}
+		set.subjects[name] = subject
+		set.bodies[name] = body
+	}
+	return set, nil
+}
+
+// render returns the subject and HTML body of a template
+func (s *templateSet) render(name string, v *view) (string, string, error) {
+	subjectTmpl, ok := s.subjects[name]
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+
+	var subject, body bytes.Buffer
+	if err := subjectTmpl.Execute(&subject, v); err != nil {
+		return "", "", fmt.Errorf("failed to render subject of mail template %s: %w", name, err)
+	}
+	if err := s.bodies[name].ExecuteTemplate(&body, "layout", v); err != nil {
+		return "", "", fmt.Errorf("failed to render mail template %s: %w", name, err)
+	}
+	return subject.String(), body.String(), nil
+}
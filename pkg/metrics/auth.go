@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// scrapeMetrics count the scrapes of a metrics endpoint
+type scrapeMetrics struct {
+	accepted *prometheus.CounterVec
+	rejected *prometheus.CounterVec
+}
+
+func newScrapeMetrics() *scrapeMetrics {
+	return &scrapeMetrics{
+		accepted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "sing_box_metrics_scrapes_total",
+				Help: "Accepted scrapes of the metrics endpoint by credential, empty without credentials",
+			},
+			[]string{"credential"},
+		),
+		rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "sing_box_metrics_scrapes_rejected_total",
+				Help: "Refused scrapes of the metrics endpoint by reason (source, credentials)",
+			},
+			[]string{"reason"},
+		),
+	}
+}
+
+// scrapeGuard admits the scrapes of the metrics endpoint that come from an
+// allowed network and present a known credential
+type scrapeGuard struct {
+	scrape      *scrapeMetrics
+	logger      *zap.Logger
+	next        http.Handler
+	credentials []configv1.ScrapeCredential
+	networks    []*net.IPNet
+	// challenge is the WWW-Authenticate header of unauthenticated scrapes
+	challenge string
+}
+
+// scrapeHandler protects next with the scrape credentials and networks of
+// config, returning next itself when there are none
+func scrapeHandler(config configv1.MetricsAuthConfig, next http.Handler, scrape *scrapeMetrics, logger *zap.Logger) (http.Handler, error) {
+	if len(config.Credentials) == 0 && len(config.AllowedCIDRs) == 0 {
+		return next, nil
+	}
+
+	guard := &scrapeGuard{
+		scrape:      scrape,
+		logger:      logger,
+		next:        next,
+		credentials: config.Credentials,
+		challenge:   `Bearer realm="metrics"`,
+	}
+	for _, credential := range config.Credentials {
+		if credential.Token == "" {
+			guard.challenge = `Basic realm="metrics"`
+		}
+	}
+	for _, cidr := range config.AllowedCIDRs {
+		network, err := parseNetwork(cidr)
+		if err != nil {
+			return nil, err
+		}
+		guard.networks = append(guard.networks, network)
+	}
+	return guard, nil
+}
+
+// parseNetwork parses a CIDR, or a single address as the network of only it
+func parseNetwork(value string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid metrics source network %q", value)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func (g *scrapeGuard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !g.allowedSource(r.RemoteAddr) {
+		g.scrape.rejected.WithLabelValues("source").Inc()
+		g.logger.Debug("Refused metrics scrape from a source outside the allowed networks",
+			zap.String("remote_addr", r.RemoteAddr))
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	name, ok := g.authenticate(r)
+	if !ok {
+		g.scrape.rejected.WithLabelValues("credentials").Inc()
+		g.logger.Debug("Refused metrics scrape without valid credentials",
+			zap.String("remote_addr", r.RemoteAddr))
+		w.Header().Set("WWW-Authenticate", g.challenge)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	g.scrape.accepted.WithLabelValues(name).Inc()
+	g.next.ServeHTTP(w, r)
+}
+
+// allowedSource reports whether the address of the connection is in one of
+// the allowed networks, any address being allowed without networks
+func (g *scrapeGuard) allowedSource(remoteAddr string) bool {
+	if len(g.networks) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range g.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the name of the credential a scrape presents. Without
+// credentials configured every scrape is accepted, under an empty name.
+func (g *scrapeGuard) authenticate(r *http.Request) (string, bool) {
+	if len(g.credentials) == 0 {
+		return "", true
+	}
+
+	header := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		token = strings.TrimSpace(token)
+		for _, credential := range g.credentials {
+			if credential.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(credential.Token)) == 1 {
+				return credential.Name, true
+			}
+		}
+		return "", false
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	for _, credential := range g.credentials {
+		if credential.Token != "" {
+			continue
+		}
+		userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(credential.Username))
+		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(credential.Password))
+		if userMatch&passwordMatch == 1 {
+			return credential.Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestScrapeHandler(t *testing.T) {
+	c := NewMetricsCollector(zap.NewNop())
+	handler, err := scrapeHandler(configv1.MetricsAuthConfig{
+		Credentials: []configv1.ScrapeCredential{
+			{Name: "prometheus", Token: "0123456789abcdef"},
+			{Name: "grafana", Username: "grafana", Password: "scrape-secret"},
+		},
+		AllowedCIDRs: []string{"10.0.0.0/8", "192.0.2.7"},
+	}, c.GetHandler(), c.scrape, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		setup      func(r *http.Request)
+		want       int
+	}{
+		{"bearer token", "10.1.2.3:5000", func(r *http.Request) { r.Header.Set("Authorization", "Bearer 0123456789abcdef") }, http.StatusOK},
+		{"basic auth from a single address", "192.0.2.7:5000", func(r *http.Request) { r.SetBasicAuth("grafana", "scrape-secret") }, http.StatusOK},
+		{"wrong token", "10.1.2.3:5000", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }, http.StatusUnauthorized},
+		{"wrong password", "10.1.2.3:5000", func(r *http.Request) { r.SetBasicAuth("grafana", "wrong") }, http.StatusUnauthorized},
+		{"token as basic password", "10.1.2.3:5000", func(r *http.Request) { r.SetBasicAuth("prometheus", "0123456789abcdef") }, http.StatusUnauthorized},
+		{"no credentials", "10.1.2.3:5000", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"outside the networks", "203.0.113.9:5000", func(r *http.Request) { r.Header.Set("Authorization", "Bearer 0123456789abcdef") }, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			r.RemoteAddr = tt.remoteAddr
+			tt.setup(r)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("unauthorized scrape without a WWW-Authenticate challenge")
+			}
+		})
+	}
+
+	if got := testutil.ToFloat64(c.scrape.accepted.WithLabelValues("prometheus")); got != 1 {
+		t.Errorf("prometheus scrapes = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.scrape.accepted.WithLabelValues("grafana")); got != 1 {
+		t.Errorf("grafana scrapes = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.scrape.rejected.WithLabelValues("credentials")); got != 4 {
+		t.Errorf("scrapes rejected for credentials = %v, want 4", got)
+	}
+	if got := testutil.ToFloat64(c.scrape.rejected.WithLabelValues("source")); got != 1 {
+		t.Errorf("scrapes rejected for source = %v, want 1", got)
+	}
+}
+
+func TestScrapeHandlerOpen(t *testing.T) {
+	c := NewMetricsCollector(zap.NewNop())
+	next := c.GetHandler()
+	handler, err := scrapeHandler(configv1.MetricsAuthConfig{}, next, c.scrape, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	if _, err := scrapeHandler(configv1.MetricsAuthConfig{AllowedCIDRs: []string{"not-a-network"}}, next, c.scrape, zap.NewNop()); err == nil {
+		t.Error("scrapeHandler accepted an invalid network")
+	}
+}
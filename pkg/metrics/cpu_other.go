@@ -0,0 +1,11 @@
+//go:build !unix
+
+package metrics
+
+import "time"
+
+// processCPUTime is not available on this platform, the CPU usage gauge
+// stays at 0
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -2,7 +2,9 @@ package metrics
 
 import (
 	"net/http"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,10 +33,13 @@ type MetricsCollector struct {
 	nodeLastSeen    *prometheus.GaugeVec
 	nodeUserCount   *prometheus.GaugeVec
 	nodeConnections *prometheus.GaugeVec
+	nodeNetworkRate *prometheus.GaugeVec
+	nodeHealthScore *prometheus.GaugeVec
 
 	// User metrics
 	userTotal        prometheus.Gauge
 	userActiveTotal  prometheus.Gauge
+	userOnlineTotal  prometheus.Gauge
 	userTrafficBytes *prometheus.CounterVec
 
 	// System metrics
@@ -52,6 +57,38 @@ type MetricsCollector struct {
 	trafficTotalBytes     *prometheus.CounterVec
 	traffic24hBytes       *prometheus.GaugeVec
 	userQuotaUsagePercent *prometheus.GaugeVec
+
+	// Traffic ingestion metrics
+	trafficIngestBuffered      prometheus.Gauge
+	trafficIngestRecords       *prometheus.CounterVec
+	trafficIngestFlushDuration prometheus.Histogram
+
+	// High availability metrics
+	haActive     *prometheus.GaugeVec
+	haLeaseEpoch prometheus.Gauge
+	haEvents     *prometheus.CounterVec
+
+	// API key metrics
+	apiKeyRequests *prometheus.CounterVec
+	apiKeyBytes    *prometheus.CounterVec
+	apiKeyRejected *prometheus.CounterVec
+
+	// Rate limit metrics
+	rateLimitRejected *prometheus.CounterVec
+
+	// Scrape metrics
+	scrape *scrapeMetrics
+
+	// Label lifecycle: per-user and per-node series are tracked by the user
+	// and node they belong to, so that they can be deleted when it goes away,
+	// and bounded by the series limits. A limit of 0 means no limit.
+	seriesMu       sync.Mutex
+	userSeries     map[string]struct{}
+	nodeSeries     map[string]struct{}
+	maxUserSeries  int
+	maxNodeSeries  int
+	seriesTracked  *prometheus.GaugeVec
+	seriesRejected *prometheus.CounterVec
 }
 
 // NewMetricsCollector creates a new metrics collector
@@ -59,8 +96,10 @@ func NewMetricsCollector(logger *zap.Logger) *MetricsCollector {
 	registry := prometheus.NewRegistry()
 
 	c := &MetricsCollector{
-		registry: registry,
-		logger:   logger,
+		registry:   registry,
+		logger:     logger,
+		userSeries: make(map[string]struct{}),
+		nodeSeries: make(map[string]struct{}),
 	}
 
 	c.initMetrics()
@@ -147,6 +186,22 @@ func (c *MetricsCollector) initMetrics() {
 		[]string{"node_id", "node_name"},
 	)
 
+	c.nodeNetworkRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sing_box_node_network_bytes_per_second",
+			Help: "Node network throughput in bytes per second",
+		},
+		[]string{"node_id", "node_name", "direction"},
+	)
+
+	c.nodeHealthScore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sing_box_node_health_score",
+			Help: "Node health score from 0 to 100",
+		},
+		[]string{"node_id", "node_name"},
+	)
+
 	// User metrics
 	c.userTotal = prometheus.NewGauge(
 		prometheus.GaugeOpts{
@@ -162,6 +217,13 @@ func (c *MetricsCollector) initMetrics() {
 		},
 	)
 
+	c.userOnlineTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "sing_box_users_online",
+			Help: "Number of users with an open connection or one closed within the last 5 minutes",
+		},
+	)
+
 	c.userTrafficBytes = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "sing_box_user_traffic_bytes_total",
@@ -249,6 +311,107 @@ func (c *MetricsCollector) initMetrics() {
 		},
 		[]string{"user_id", "node_id"},
 	)
+
+	// Traffic ingestion metrics
+	c.trafficIngestBuffered = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "sing_box_traffic_ingest_buffered_records",
+			Help: "Traffic records waiting to be flushed",
+		},
+	)
+
+	c.trafficIngestRecords = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sing_box_traffic_ingest_records_total",
+			Help: "Traffic records by ingestion result (flushed, failed, rejected, dropped)",
+		},
+		[]string{"result"},
+	)
+
+	c.trafficIngestFlushDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "sing_box_traffic_ingest_flush_duration_seconds",
+			Help:    "Traffic buffer flush duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+	// High availability metrics
+	c.haActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sing_box_ha_active",
+			Help: "Whether this instance holds the API lease (1) or is a standby (0)",
+		},
+		[]string{"instance"},
+	)
+
+	c.haLeaseEpoch = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "sing_box_ha_lease_epoch",
+			Help: "Epoch of the API lease, incremented on every failover",
+		},
+	)
+
+	c.haEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sing_box_ha_events_total",
+			Help: "High availability events by type (promoted, demoted, lease_error)",
+		},
+		[]string{"event"},
+	)
+
+	// API key metrics
+	c.apiKeyRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sing_box_web_api_key_requests_total",
+			Help: "Requests made with an API key by key and status class (2xx, 3xx, 4xx, 5xx)",
+		},
+		[]string{"key", "class"},
+	)
+
+	c.apiKeyBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sing_box_web_api_key_bytes_total",
+			Help: "Request and response body bytes of the requests made with an API key by key and direction (in, out)",
+		},
+		[]string{"key", "direction"},
+	)
+
+	c.apiKeyRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sing_box_web_api_key_rejected_total",
+			Help: "Requests made with an API key refused by key and reason (rate_limit, quota)",
+		},
+		[]string{"key", "reason"},
+	)
+
+	// Rate limit metrics
+	c.rateLimitRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sing_box_web_rate_limit_rejected_total",
+			Help: "Requests refused by the rate limits of the web API by scope (ip, user, subscription_ip, subscription_token)",
+		},
+		[]string{"scope"},
+	)
+
+	// Scrape metrics
+	c.scrape = newScrapeMetrics()
+
+	// Label lifecycle metrics
+	c.seriesTracked = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sing_box_metrics_tracked_labels",
+			Help: "Users and nodes that have per-user or per-node series, by kind (user, node)",
+		},
+		[]string{"kind"},
+	)
+
+	c.seriesRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sing_box_metrics_rejected_updates_total",
+			Help: "Metric updates dropped because the series limit of their kind (user, node) was reached",
+		},
+		[]string{"kind"},
+	)
 }
 
 // registerMetrics registers all metrics with the registry
@@ -267,10 +430,13 @@ func (c *MetricsCollector) registerMetrics() {
 	c.registry.MustRegister(c.nodeLastSeen)
 	c.registry.MustRegister(c.nodeUserCount)
 	c.registry.MustRegister(c.nodeConnections)
+	c.registry.MustRegister(c.nodeNetworkRate)
+	c.registry.MustRegister(c.nodeHealthScore)
 
 	// User metrics
 	c.registry.MustRegister(c.userTotal)
 	c.registry.MustRegister(c.userActiveTotal)
+	c.registry.MustRegister(c.userOnlineTotal)
 	c.registry.MustRegister(c.userTrafficBytes)
 
 	// System metrics
@@ -289,6 +455,32 @@ func (c *MetricsCollector) registerMetrics() {
 	c.registry.MustRegister(c.traffic24hBytes)
 	c.registry.MustRegister(c.userQuotaUsagePercent)
 
+	// Traffic ingestion metrics
+	c.registry.MustRegister(c.trafficIngestBuffered)
+	c.registry.MustRegister(c.trafficIngestRecords)
+	c.registry.MustRegister(c.trafficIngestFlushDuration)
+
+	// High availability metrics
+	c.registry.MustRegister(c.haActive)
+	c.registry.MustRegister(c.haLeaseEpoch)
+	c.registry.MustRegister(c.haEvents)
+
+	// API key metrics
+	c.registry.MustRegister(c.apiKeyRequests)
+	c.registry.MustRegister(c.apiKeyBytes)
+	c.registry.MustRegister(c.apiKeyRejected)
+
+	// Rate limit metrics
+	c.registry.MustRegister(c.rateLimitRejected)
+
+	// Scrape metrics
+	c.registry.MustRegister(c.scrape.accepted)
+	c.registry.MustRegister(c.scrape.rejected)
+
+	// Label lifecycle metrics
+	c.registry.MustRegister(c.seriesTracked)
+	c.registry.MustRegister(c.seriesRejected)
+
 	// Add Go runtime metrics
 	c.registry.MustRegister(prometheus.NewGoCollector())
 	c.registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
@@ -324,6 +516,11 @@ func (c *MetricsCollector) RecordGRPCRequest(service, method, status string, dur
 
 // SetNodeStatus sets the status of a node
 func (c *MetricsCollector) SetNodeStatus(nodeID, nodeName string, online bool) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
 	status := 0.0
 	if online {
 		status = 1.0
@@ -333,19 +530,55 @@ func (c *MetricsCollector) SetNodeStatus(nodeID, nodeName string, online bool) {
 
 // SetNodeLastSeen sets the last seen timestamp of a node
 func (c *MetricsCollector) SetNodeLastSeen(nodeID, nodeName string, timestamp time.Time) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
 	c.nodeLastSeen.WithLabelValues(nodeID, nodeName).Set(float64(timestamp.Unix()))
 }
 
 // SetNodeUserCount sets the user count for a node
 func (c *MetricsCollector) SetNodeUserCount(nodeID, nodeName string, count int) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
 	c.nodeUserCount.WithLabelValues(nodeID, nodeName).Set(float64(count))
 }
 
 // SetNodeConnections sets the connection count for a node
 func (c *MetricsCollector) SetNodeConnections(nodeID, nodeName string, count int) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
 	c.nodeConnections.WithLabelValues(nodeID, nodeName).Set(float64(count))
 }
 
+// SetNodeNetworkRate sets the inbound and outbound throughput of a node
+func (c *MetricsCollector) SetNodeNetworkRate(nodeID, nodeName string, inBytesPerSec, outBytesPerSec int64) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
+	c.nodeNetworkRate.WithLabelValues(nodeID, nodeName, "in").Set(float64(inBytesPerSec))
+	c.nodeNetworkRate.WithLabelValues(nodeID, nodeName, "out").Set(float64(outBytesPerSec))
+}
+
+// SetNodeHealthScore sets the health score of a node
+func (c *MetricsCollector) SetNodeHealthScore(nodeID, nodeName string, score float64) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
+	c.nodeHealthScore.WithLabelValues(nodeID, nodeName).Set(score)
+}
+
 // User Metrics
 
 // SetUserTotal sets the total number of users
@@ -358,8 +591,18 @@ func (c *MetricsCollector) SetUserActiveTotal(count float64) {
 	c.userActiveTotal.Set(count)
 }
 
+// SetUserOnlineTotal sets the number of online users
+func (c *MetricsCollector) SetUserOnlineTotal(count float64) {
+	c.userOnlineTotal.Set(count)
+}
+
 // RecordUserTraffic records user traffic
 func (c *MetricsCollector) RecordUserTraffic(userID, direction, nodeID string, bytes int64) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitUser(userID) || !c.admitNode(nodeID) {
+		return
+	}
 	c.userTrafficBytes.WithLabelValues(userID, direction, nodeID).Add(float64(bytes))
 }
 
@@ -404,31 +647,225 @@ func (c *MetricsCollector) RecordDBQuery(operation, status string, duration time
 
 // RecordTraffic records total traffic
 func (c *MetricsCollector) RecordTraffic(direction, nodeID string, bytes int64) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
 	c.trafficTotalBytes.WithLabelValues(direction, nodeID).Add(float64(bytes))
 }
 
 // SetTraffic24h sets 24-hour traffic
 func (c *MetricsCollector) SetTraffic24h(direction, nodeID string, bytes int64) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitNode(nodeID) {
+		return
+	}
 	c.traffic24hBytes.WithLabelValues(direction, nodeID).Set(float64(bytes))
 }
 
-// SetUserQuotaUsage sets user quota usage percentage
+// SetUserQuotaUsage sets user quota usage percentage, nodeID is empty for
+// a quota spanning every node
 func (c *MetricsCollector) SetUserQuotaUsage(userID, nodeID string, percent float64) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	if !c.admitUser(userID) || (nodeID != "" && !c.admitNode(nodeID)) {
+		return
+	}
 	c.userQuotaUsagePercent.WithLabelValues(userID, nodeID).Set(percent)
 }
 
+// Traffic Ingestion Metrics
+
+// SetTrafficIngestBuffered sets the number of buffered traffic records
+func (c *MetricsCollector) SetTrafficIngestBuffered(count int) {
+	c.trafficIngestBuffered.Set(float64(count))
+}
+
+// RecordTrafficIngestFlush records a flush of the traffic buffer
+func (c *MetricsCollector) RecordTrafficIngestFlush(records int, success bool, duration time.Duration) {
+	result := "flushed"
+	if !success {
+		result = "failed"
+	}
+	c.trafficIngestRecords.WithLabelValues(result).Add(float64(records))
+	c.trafficIngestFlushDuration.Observe(duration.Seconds())
+}
+
+// RecordTrafficIngestRejected records traffic records rejected because the buffer is full
+func (c *MetricsCollector) RecordTrafficIngestRejected(records int) {
+	c.trafficIngestRecords.WithLabelValues("rejected").Add(float64(records))
+}
+
+// RecordTrafficIngestDropped records traffic records dropped after a failed flush
+func (c *MetricsCollector) RecordTrafficIngestDropped(records int) {
+	c.trafficIngestRecords.WithLabelValues("dropped").Add(float64(records))
+}
+
+// High Availability Metrics
+
+// SetHAState sets whether the instance is active and the current lease epoch
+func (c *MetricsCollector) SetHAState(instance string, active bool, epoch int64) {
+	value := 0.0
+	if active {
+		value = 1.0
+	}
+	c.haActive.WithLabelValues(instance).Set(value)
+	c.haLeaseEpoch.Set(float64(epoch))
+}
+
+// RecordHAEvent records a high availability event
+func (c *MetricsCollector) RecordHAEvent(event string) {
+	c.haEvents.WithLabelValues(event).Inc()
+}
+
+// API Key Metrics
+
+// RecordAPIKeyRequest records a request made with an API key
+func (c *MetricsCollector) RecordAPIKeyRequest(keyID string, statusCode int, bytesIn, bytesOut int64) {
+	class := strconv.Itoa(statusCode/100) + "xx"
+	c.apiKeyRequests.WithLabelValues(keyID, class).Inc()
+	c.apiKeyBytes.WithLabelValues(keyID, "in").Add(float64(bytesIn))
+	c.apiKeyBytes.WithLabelValues(keyID, "out").Add(float64(bytesOut))
+}
+
+// RecordAPIKeyRejected records a request refused by the limits of an API key
+func (c *MetricsCollector) RecordAPIKeyRejected(keyID, reason string) {
+	c.apiKeyRejected.WithLabelValues(keyID, reason).Inc()
+}
+
+// Rate Limit Metrics
+
+// RecordRateLimitRejected records a request refused by a rate limit
+func (c *MetricsCollector) RecordRateLimitRejected(scope string) {
+	c.rateLimitRejected.WithLabelValues(scope).Inc()
+}
+
+// Label Lifecycle
+
+// SetSeriesLimits sets how many users and nodes may have series, 0 for no limit
+func (c *MetricsCollector) SetSeriesLimits(maxUsers, maxNodes int) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+	c.maxUserSeries = maxUsers
+	c.maxNodeSeries = maxNodes
+}
+
+// admitUser reports whether the user may have series, tracking it when it
+// is new and within the limit. Callers hold seriesMu across the check and the
+// update so that a concurrent delete cannot leave an untracked series behind.
+func (c *MetricsCollector) admitUser(userID string) bool {
+	return c.admit(c.userSeries, c.maxUserSeries, "user", userID)
+}
+
+// admitNode reports whether the node may have series, tracking it when it
+// is new and within the limit
+func (c *MetricsCollector) admitNode(nodeID string) bool {
+	return c.admit(c.nodeSeries, c.maxNodeSeries, "node", nodeID)
+}
+
+func (c *MetricsCollector) admit(tracked map[string]struct{}, limit int, kind, id string) bool {
+	if _, ok := tracked[id]; ok {
+		return true
+	}
+	if limit > 0 && len(tracked) >= limit {
+		c.seriesRejected.WithLabelValues(kind).Inc()
+		return false
+	}
+	tracked[id] = struct{}{}
+	c.seriesTracked.WithLabelValues(kind).Set(float64(len(tracked)))
+	return true
+}
+
+// TrackedUsers returns the IDs of the users that have series, sorted
+func (c *MetricsCollector) TrackedUsers() []string {
+	return c.tracked(c.userSeries)
+}
+
+// TrackedNodes returns the IDs of the nodes that have series, sorted
+func (c *MetricsCollector) TrackedNodes() []string {
+	return c.tracked(c.nodeSeries)
+}
+
+func (c *MetricsCollector) tracked(series map[string]struct{}) []string {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+
+	ids := make([]string, 0, len(series))
+	for id := range series {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// DeleteUserSeries deletes every series of a user
+func (c *MetricsCollector) DeleteUserSeries(userID string) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+
+	labels := prometheus.Labels{"user_id": userID}
+	c.userTrafficBytes.DeletePartialMatch(labels)
+	c.userQuotaUsagePercent.DeletePartialMatch(labels)
+
+	delete(c.userSeries, userID)
+	c.seriesTracked.WithLabelValues("user").Set(float64(len(c.userSeries)))
+}
+
+// DeleteNodeSeries deletes every series of a node, including the per-user
+// series reported by it
+func (c *MetricsCollector) DeleteNodeSeries(nodeID string) {
+	c.seriesMu.Lock()
+	defer c.seriesMu.Unlock()
+
+	labels := prometheus.Labels{"node_id": nodeID}
+	c.nodeStatus.DeletePartialMatch(labels)
+	c.nodeLastSeen.DeletePartialMatch(labels)
+	c.nodeUserCount.DeletePartialMatch(labels)
+	c.nodeConnections.DeletePartialMatch(labels)
+	c.nodeNetworkRate.DeletePartialMatch(labels)
+	c.nodeHealthScore.DeletePartialMatch(labels)
+	c.userTrafficBytes.DeletePartialMatch(labels)
+	c.userQuotaUsagePercent.DeletePartialMatch(labels)
+	c.trafficTotalBytes.DeletePartialMatch(labels)
+	c.traffic24hBytes.DeletePartialMatch(labels)
+
+	delete(c.nodeSeries, nodeID)
+	c.seriesTracked.WithLabelValues("node").Set(float64(len(c.nodeSeries)))
+}
+
 // StartMetricsServer starts the metrics HTTP server
 func (c *MetricsCollector) StartMetricsServer(config configv1.MetricsConfig) error {
+	return startServer(config, c.GetHandler(), c.scrape, c.logger)
+}
+
+// StartRegistryServer starts a metrics HTTP server, protected like the one of
+// StartMetricsServer, for the metrics of registry. It serves processes with
+// metrics of their own, such as the agent.
+func StartRegistryServer(config configv1.MetricsConfig, registry *prometheus.Registry, logger *zap.Logger) error {
+	scrape := newScrapeMetrics()
+	registry.MustRegister(scrape.accepted, scrape.rejected)
+	return startServer(config, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), scrape, logger)
+}
+
+// startServer serves the metrics of handler at the path of config
+func startServer(config configv1.MetricsConfig, handler http.Handler, scrape *scrapeMetrics, logger *zap.Logger) error {
 	if !config.Enabled {
-		c.logger.Info("Metrics server disabled")
+		logger.Info("Metrics server disabled")
 		return nil
 	}
 
 	addr := config.Address + ":" + strconv.Itoa(config.Port)
-	c.logger.Info("Starting metrics server", zap.String("address", addr), zap.String("path", config.Path))
+	logger.Info("Starting metrics server", zap.String("address", addr), zap.String("path", config.Path))
+
+	handler, err := scrapeHandler(config.Auth, handler, scrape, logger)
+	if err != nil {
+		return err
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle(config.Path, c.GetHandler())
+	mux.Handle(config.Path, handler)
 
 	server := &http.Server{
 		Addr:    addr,
@@ -437,7 +874,7 @@ func (c *MetricsCollector) StartMetricsServer(config configv1.MetricsConfig) err
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			c.logger.Error("Metrics server failed", zap.Error(err))
+			logger.Error("Metrics server failed", zap.Error(err))
 		}
 	}()
 
@@ -480,6 +917,20 @@ func SetNodeStatus(nodeID, nodeName string, online bool) {
 	}
 }
 
+// SetNodeNetworkRate sets node throughput using global metrics
+func SetNodeNetworkRate(nodeID, nodeName string, inBytesPerSec, outBytesPerSec int64) {
+	if globalMetrics != nil {
+		globalMetrics.SetNodeNetworkRate(nodeID, nodeName, inBytesPerSec, outBytesPerSec)
+	}
+}
+
+// SetNodeHealthScore sets the health score of a node using global metrics
+func SetNodeHealthScore(nodeID, nodeName string, score float64) {
+	if globalMetrics != nil {
+		globalMetrics.SetNodeHealthScore(nodeID, nodeName, score)
+	}
+}
+
 // RecordUserTraffic records user traffic using global metrics
 func RecordUserTraffic(userID, direction, nodeID string, bytes int64) {
 	if globalMetrics != nil {
@@ -487,9 +938,116 @@ func RecordUserTraffic(userID, direction, nodeID string, bytes int64) {
 	}
 }
 
+// SetDBConnections sets database connection counts using global metrics
+func SetDBConnections(open, idle, inUse int) {
+	if globalMetrics != nil {
+		globalMetrics.SetDBConnections(open, idle, inUse)
+	}
+}
+
 // RecordDBQuery records a database query using global metrics
 func RecordDBQuery(operation, status string, duration time.Duration) {
 	if globalMetrics != nil {
 		globalMetrics.RecordDBQuery(operation, status, duration)
 	}
 }
+
+// SetTrafficIngestBuffered sets the traffic buffer size using global metrics
+func SetTrafficIngestBuffered(count int) {
+	if globalMetrics != nil {
+		globalMetrics.SetTrafficIngestBuffered(count)
+	}
+}
+
+// RecordTrafficIngestFlush records a traffic buffer flush using global metrics
+func RecordTrafficIngestFlush(records int, success bool, duration time.Duration) {
+	if globalMetrics != nil {
+		globalMetrics.RecordTrafficIngestFlush(records, success, duration)
+	}
+}
+
+// RecordTrafficIngestRejected records rejected traffic records using global metrics
+func RecordTrafficIngestRejected(records int) {
+	if globalMetrics != nil {
+		globalMetrics.RecordTrafficIngestRejected(records)
+	}
+}
+
+// RecordTrafficIngestDropped records dropped traffic records using global metrics
+func RecordTrafficIngestDropped(records int) {
+	if globalMetrics != nil {
+		globalMetrics.RecordTrafficIngestDropped(records)
+	}
+}
+
+// SetHAState sets the high availability state using global metrics
+func SetHAState(instance string, active bool, epoch int64) {
+	if globalMetrics != nil {
+		globalMetrics.SetHAState(instance, active, epoch)
+	}
+}
+
+// RecordHAEvent records a high availability event using global metrics
+func RecordHAEvent(event string) {
+	if globalMetrics != nil {
+		globalMetrics.RecordHAEvent(event)
+	}
+}
+
+// RecordAPIKeyRequest records a request made with an API key using global metrics
+func RecordAPIKeyRequest(keyID string, statusCode int, bytesIn, bytesOut int64) {
+	if globalMetrics != nil {
+		globalMetrics.RecordAPIKeyRequest(keyID, statusCode, bytesIn, bytesOut)
+	}
+}
+
+// RecordAPIKeyRejected records a refused API key request using global metrics
+func RecordAPIKeyRejected(keyID, reason string) {
+	if globalMetrics != nil {
+		globalMetrics.RecordAPIKeyRejected(keyID, reason)
+	}
+}
+
+// RecordRateLimitRejected records a request refused by a rate limit using global metrics
+func RecordRateLimitRejected(scope string) {
+	if globalMetrics != nil {
+		globalMetrics.RecordRateLimitRejected(scope)
+	}
+}
+
+// SetSeriesLimits sets the series limits of the global metrics
+func SetSeriesLimits(maxUsers, maxNodes int) {
+	if globalMetrics != nil {
+		globalMetrics.SetSeriesLimits(maxUsers, maxNodes)
+	}
+}
+
+// TrackedUsers returns the users with series in the global metrics
+func TrackedUsers() []string {
+	if globalMetrics != nil {
+		return globalMetrics.TrackedUsers()
+	}
+	return nil
+}
+
+// TrackedNodes returns the nodes with series in the global metrics
+func TrackedNodes() []string {
+	if globalMetrics != nil {
+		return globalMetrics.TrackedNodes()
+	}
+	return nil
+}
+
+// DeleteUserSeries deletes the series of a user from the global metrics
+func DeleteUserSeries(userID string) {
+	if globalMetrics != nil {
+		globalMetrics.DeleteUserSeries(userID)
+	}
+}
+
+// DeleteNodeSeries deletes the series of a node from the global metrics
+func DeleteNodeSeries(nodeID string) {
+	if globalMetrics != nil {
+		globalMetrics.DeleteNodeSeries(nodeID)
+	}
+}
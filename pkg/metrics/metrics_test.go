@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+func TestSeriesLimits(t *testing.T) {
+	c := NewMetricsCollector(zap.NewNop())
+	c.SetSeriesLimits(2, 1)
+
+	c.RecordUserTraffic("1", "upload", "node-a", 100)
+	c.RecordUserTraffic("2", "upload", "node-a", 100)
+	c.RecordUserTraffic("3", "upload", "node-a", 100) // over the user limit
+	c.RecordUserTraffic("1", "upload", "node-b", 100) // over the node limit
+	c.SetNodeStatus("node-b", "b", true)
+
+	if got := testutil.CollectAndCount(c.userTrafficBytes); got != 2 {
+		t.Errorf("user traffic series = %d, want 2", got)
+	}
+	if got := testutil.CollectAndCount(c.nodeStatus); got != 0 {
+		t.Errorf("node status series = %d, want 0", got)
+	}
+	if got := testutil.ToFloat64(c.seriesRejected.WithLabelValues("user")); got != 1 {
+		t.Errorf("rejected user updates = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.seriesRejected.WithLabelValues("node")); got != 2 {
+		t.Errorf("rejected node updates = %v, want 2", got)
+	}
+	if got, want := c.TrackedUsers(), []string{"1", "2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TrackedUsers() = %v, want %v", got, want)
+	}
+}
+
+func TestDeleteSeries(t *testing.T) {
+	c := NewMetricsCollector(zap.NewNop())
+
+	c.RecordUserTraffic("1", "upload", "node-a", 100)
+	c.RecordUserTraffic("2", "upload", "node-a", 100)
+	c.RecordUserTraffic("2", "upload", "node-b", 100)
+	c.SetUserQuotaUsage("1", "node-a", 50)
+	c.SetNodeNetworkRate("node-a", "a", 10, 20)
+	c.SetNodeNetworkRate("node-b", "b", 10, 20)
+
+	c.DeleteUserSeries("1")
+	if got := testutil.CollectAndCount(c.userTrafficBytes); got != 2 {
+		t.Errorf("user traffic series after user delete = %d, want 2", got)
+	}
+	if got := testutil.CollectAndCount(c.userQuotaUsagePercent); got != 0 {
+		t.Errorf("quota series after user delete = %d, want 0", got)
+	}
+
+	c.DeleteNodeSeries("node-a")
+	if got := testutil.CollectAndCount(c.userTrafficBytes); got != 1 {
+		t.Errorf("user traffic series after node delete = %d, want 1", got)
+	}
+	if got := testutil.CollectAndCount(c.nodeNetworkRate); got != 2 {
+		t.Errorf("network rate series after node delete = %d, want 2", got)
+	}
+	if got, want := c.TrackedNodes(), []string{"node-b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TrackedNodes() = %v, want %v", got, want)
+	}
+}
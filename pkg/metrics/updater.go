@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// updaterEventBuffer is how many events the updater buffers between reads
+const updaterEventBuffer = 256
+
+// processStart is when the process started, the origin of the uptime gauge
+var processStart = time.Now()
+
+// Updater keeps the node, user, traffic and process gauges accurate. Node
+// status changes and ingested traffic are applied as their events arrive;
+// the rest, and the state of nodes and users changed without an event, is
+// refreshed from the database every interval.
+type Updater struct {
+	collector *MetricsCollector
+	repo      *repository.Manager
+	bus       *events.Bus
+	interval  time.Duration
+	logger    *zap.Logger
+
+	// CPU time and wall time of the previous refresh, for the CPU usage
+	lastCPU  time.Duration
+	lastWall time.Time
+}
+
+// NewUpdater creates an updater of the gauges of collector. A nil bus
+// leaves the gauges to the periodic refreshes.
+func NewUpdater(collector *MetricsCollector, repo *repository.Manager, bus *events.Bus, interval time.Duration, logger *zap.Logger) *Updater {
+	return &Updater{
+		collector: collector,
+		repo:      repo,
+		bus:       bus,
+		interval:  interval,
+		logger:    logger.Named("metrics-updater"),
+	}
+}
+
+// Run refreshes the gauges right away and then every interval, applying
+// the node and traffic events in between, until ctx is done
+func (u *Updater) Run(ctx context.Context) {
+	if u.collector == nil {
+		return
+	}
+
+	var received <-chan *pbv1.Event
+	if u.bus != nil {
+		sub := u.bus.Subscribe([]string{events.TopicNodes, events.TopicTraffic}, updaterEventBuffer)
+		defer sub.Close()
+		received = sub.Events()
+	}
+
+	u.Refresh()
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			u.Refresh()
+		case event, ok := <-received:
+			if !ok {
+				received = nil
+				continue
+			}
+			u.Apply(event)
+		}
+	}
+}
+
+// Apply updates the gauges affected by an event
+func (u *Updater) Apply(event *pbv1.Event) {
+	if status := event.GetNodeStatus(); status != nil && status.NodeId != "" {
+		online := status.Status == string(models.NodeStatusOnline) || status.Status == string(models.NodeStatusDegraded)
+		u.collector.SetNodeStatus(status.NodeId, status.NodeName, online)
+		if status.HealthScore > 0 {
+			u.collector.SetNodeHealthScore(status.NodeId, status.NodeName, status.HealthScore)
+		}
+		if status.Status == string(models.NodeStatusOffline) {
+			u.collector.SetNodeConnections(status.NodeId, status.NodeName, 0)
+			u.collector.SetNodeNetworkRate(status.NodeId, status.NodeName, 0, 0)
+		}
+	}
+
+	for _, node := range event.GetTraffic().GetNodes() {
+		if node.UploadBytes > 0 {
+			u.collector.RecordTraffic("upload", node.NodeId, node.UploadBytes)
+		}
+		if node.DownloadBytes > 0 {
+			u.collector.RecordTraffic("download", node.NodeId, node.DownloadBytes)
+		}
+	}
+}
+
+// Refresh sets the gauges from the database and the process. A failed
+// query leaves its gauges at their last values.
+func (u *Updater) Refresh() {
+	now := time.Now()
+	if err := u.refreshNodes(now); err != nil {
+		u.logger.Error("Failed to refresh node metrics", zap.Error(err))
+	}
+	if err := u.refreshUsers(now); err != nil {
+		u.logger.Error("Failed to refresh user metrics", zap.Error(err))
+	}
+	u.refreshProcess(now)
+}
+
+// refreshNodes sets the state, load and last 24 hours of traffic of every
+// node. Offline nodes keep their series with a status, connections and
+// throughput of 0.
+func (u *Updater) refreshNodes(now time.Time) error {
+	nodes, _, err := u.repo.Node.List(0, -1)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		nodeID := strconv.FormatUint(uint64(node.ID), 10)
+		online := node.IsOnline()
+		u.collector.SetNodeStatus(nodeID, node.Name, online)
+		if node.LastHeartbeat != nil {
+			u.collector.SetNodeLastSeen(nodeID, node.Name, *node.LastHeartbeat)
+		}
+		u.collector.SetNodeUserCount(nodeID, node.Name, node.CurrentUsers)
+		u.collector.SetNodeHealthScore(nodeID, node.Name, node.HealthScore)
+		if online {
+			u.collector.SetNodeConnections(nodeID, node.Name, node.ActiveConnections)
+			u.collector.SetNodeNetworkRate(nodeID, node.Name, node.NetworkInRate, node.NetworkOutRate)
+		} else {
+			u.collector.SetNodeConnections(nodeID, node.Name, 0)
+			u.collector.SetNodeNetworkRate(nodeID, node.Name, 0, 0)
+		}
+
+		upload, download, _, err := u.repo.Traffic.GetNodeTrafficSum(node.ID, now.Add(-24*time.Hour), now)
+		if err != nil {
+			return err
+		}
+		u.collector.SetTraffic24h("upload", nodeID, upload)
+		u.collector.SetTraffic24h("download", nodeID, download)
+	}
+	return nil
+}
+
+// refreshUsers sets the user counts and the quota usage of the active users
+// with a quota. The quota spans every node, its series has no node. Users
+// are online by the sessions agents report.
+func (u *Updater) refreshUsers(now time.Time) error {
+	_, total, err := u.repo.User.List(0, 1)
+	if err != nil {
+		return err
+	}
+	active, activeTotal, err := u.repo.User.ListByStatus(models.UserStatusActive, 0, -1)
+	if err != nil {
+		return err
+	}
+	u.collector.SetUserTotal(float64(total))
+	u.collector.SetUserActiveTotal(float64(activeTotal))
+
+	online, err := u.repo.Traffic.ListOnlineUserIDs(nil, now.Add(-models.UserOnlineWindow))
+	if err != nil {
+		return err
+	}
+	u.collector.SetUserOnlineTotal(float64(len(online)))
+
+	for _, user := range active {
+		if user.TrafficQuota <= 0 {
+			continue
+		}
+		percent := float64(user.TrafficUsed) / float64(user.TrafficQuota) * 100
+		u.collector.SetUserQuotaUsage(strconv.FormatUint(uint64(user.ID), 10), "", percent)
+	}
+	return nil
+}
+
+// refreshProcess sets the uptime, memory, goroutines and CPU usage of the
+// process. The CPU usage is the share of every core used since the
+// previous refresh.
+func (u *Updater) refreshProcess(now time.Time) {
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+
+	u.collector.SetSystemUptime(now.Sub(processStart).Seconds())
+	u.collector.SetSystemMemoryUsage(float64(memory.Sys))
+	u.collector.SetSystemGoroutines(float64(runtime.NumGoroutine()))
+
+	cpu, ok := processCPUTime()
+	if !ok {
+		return
+	}
+	if !u.lastWall.IsZero() {
+		if wall := now.Sub(u.lastWall); wall > 0 {
+			u.collector.SetSystemCPUUsage(float64(cpu-u.lastCPU) / float64(wall) / float64(runtime.NumCPU()) * 100)
+		}
+	}
+	u.lastCPU, u.lastWall = cpu, now
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+func newUpdaterTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := (&models.Database{DB: db}).AutoMigrate(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+func TestUpdaterRefresh(t *testing.T) {
+	db := newUpdaterTestDB(t)
+	now := time.Now()
+
+	online := &models.Node{Name: "online", Type: models.NodeType("vless"), Host: "a.example.com", Port: 443,
+		Status: models.NodeStatusOnline, LastHeartbeat: &now, CurrentUsers: 3, ActiveConnections: 7,
+		NetworkInRate: 100, NetworkOutRate: 200, IsEnabled: true}
+	offline := &models.Node{Name: "offline", Type: models.NodeType("vless"), Host: "b.example.com", Port: 443,
+		Status: models.NodeStatusOffline, ActiveConnections: 5, NetworkInRate: 100, IsEnabled: true}
+	for _, node := range []*models.Node{online, offline} {
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+
+	quota := &models.User{Username: "quota", Email: "quota@example.com", Password: "x",
+		Status: models.UserStatusActive, TrafficQuota: 1000, TrafficUsed: 250}
+	unlimited := &models.User{Username: "unlimited", Email: "unlimited@example.com", Password: "x",
+		Status: models.UserStatusActive}
+	disabled := &models.User{Username: "disabled", Email: "disabled@example.com", Password: "x",
+		Status: models.UserStatusDisabled, TrafficQuota: 1000}
+	for _, user := range []*models.User{quota, unlimited, disabled} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	record := &models.TrafficRecord{UserID: quota.ID, NodeID: online.ID, Upload: 300, Download: 700,
+		RecordDate: now.Add(-time.Hour)}
+	if err := db.Create(record).Error; err != nil {
+		t.Fatalf("create traffic record: %v", err)
+	}
+	session := &models.TrafficRecord{UserID: unlimited.ID, NodeID: online.ID, SessionID: "a", ConnectTime: now}
+	if err := db.Create(session).Error; err != nil {
+		t.Fatalf("create session record: %v", err)
+	}
+
+	c := NewMetricsCollector(zap.NewNop())
+	u := NewUpdater(c, repository.NewManager(db), nil, time.Minute, zap.NewNop())
+	u.Refresh()
+
+	for _, tc := range []struct {
+		name      string
+		collector prometheus.Collector
+		want      int
+	}{
+		{"node status", c.nodeStatus, 2},
+		{"node last seen", c.nodeLastSeen, 1},
+		{"node user count", c.nodeUserCount, 2},
+		{"node connections", c.nodeConnections, 2},
+		{"node network rate", c.nodeNetworkRate, 4},
+		{"node health score", c.nodeHealthScore, 2},
+		{"traffic 24h", c.traffic24hBytes, 4},
+		{"quota usage", c.userQuotaUsagePercent, 1},
+	} {
+		if got := testutil.CollectAndCount(tc.collector); got != tc.want {
+			t.Errorf("%s series = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	onlineID := strconv.FormatUint(uint64(online.ID), 10)
+	offlineID := strconv.FormatUint(uint64(offline.ID), 10)
+	if got := testutil.ToFloat64(c.nodeStatus.WithLabelValues(onlineID, "online")); got != 1 {
+		t.Errorf("online node status = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.nodeStatus.WithLabelValues(offlineID, "offline")); got != 0 {
+		t.Errorf("offline node status = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(c.nodeConnections.WithLabelValues(onlineID, "online")); got != 7 {
+		t.Errorf("online node connections = %v, want 7", got)
+	}
+	if got := testutil.ToFloat64(c.nodeConnections.WithLabelValues(offlineID, "offline")); got != 0 {
+		t.Errorf("offline node connections = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(c.nodeNetworkRate.WithLabelValues(onlineID, "online", "out")); got != 200 {
+		t.Errorf("online node outbound rate = %v, want 200", got)
+	}
+	if got := testutil.ToFloat64(c.traffic24hBytes.WithLabelValues("download", onlineID)); got != 700 {
+		t.Errorf("24h download = %v, want 700", got)
+	}
+	if got := testutil.ToFloat64(c.userTotal); got != 3 {
+		t.Errorf("user total = %v, want 3", got)
+	}
+	if got := testutil.ToFloat64(c.userActiveTotal); got != 2 {
+		t.Errorf("active users = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(c.userOnlineTotal); got != 1 {
+		t.Errorf("online users = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.userQuotaUsagePercent.WithLabelValues(strconv.FormatUint(uint64(quota.ID), 10), "")); got != 25 {
+		t.Errorf("quota usage = %v, want 25", got)
+	}
+	if got := testutil.ToFloat64(c.systemUptime); got <= 0 {
+		t.Errorf("uptime = %v, want > 0", got)
+	}
+	if got := testutil.ToFloat64(c.systemMemoryUsage); got <= 0 {
+		t.Errorf("memory usage = %v, want > 0", got)
+	}
+	if got := testutil.ToFloat64(c.systemGoroutines); got <= 0 {
+		t.Errorf("goroutines = %v, want > 0", got)
+	}
+	if got, want := c.TrackedNodes(), []string{onlineID, offlineID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TrackedNodes() = %v, want %v", got, want)
+	}
+}
+
+func TestUpdaterApply(t *testing.T) {
+	c := NewMetricsCollector(zap.NewNop())
+	u := NewUpdater(c, nil, nil, time.Minute, zap.NewNop())
+
+	u.Apply(&pbv1.Event{NodeStatus: &pbv1.NodeStatusEvent{NodeId: "1", NodeName: "a", Status: "degraded", HealthScore: 40}})
+	u.Apply(&pbv1.Event{NodeStatus: &pbv1.NodeStatusEvent{NodeId: "2", NodeName: "b", Status: "offline"}})
+	u.Apply(&pbv1.Event{Traffic: &pbv1.TrafficEvent{Nodes: []*pbv1.NodeTrafficCounter{
+		{NodeId: "1", UploadBytes: 10, DownloadBytes: 20},
+		{NodeId: "2", DownloadBytes: 5},
+	}}})
+	u.Apply(&pbv1.Event{Traffic: &pbv1.TrafficEvent{Nodes: []*pbv1.NodeTrafficCounter{
+		{NodeId: "1", UploadBytes: 30},
+	}}})
+
+	if got := testutil.ToFloat64(c.nodeStatus.WithLabelValues("1", "a")); got != 1 {
+		t.Errorf("degraded node status = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.nodeStatus.WithLabelValues("2", "b")); got != 0 {
+		t.Errorf("offline node status = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(c.nodeHealthScore.WithLabelValues("1", "a")); got != 40 {
+		t.Errorf("health score = %v, want 40", got)
+	}
+	if got := testutil.CollectAndCount(c.nodeConnections); got != 1 {
+		t.Errorf("connection series = %d, want 1 for the offline node", got)
+	}
+	if got := testutil.CollectAndCount(c.trafficTotalBytes); got != 3 {
+		t.Errorf("traffic series = %d, want 3", got)
+	}
+	if got := testutil.ToFloat64(c.trafficTotalBytes.WithLabelValues("upload", "1")); got != 40 {
+		t.Errorf("node 1 upload = %v, want 40", got)
+	}
+}
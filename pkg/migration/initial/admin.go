@@ -0,0 +1,27 @@
+package initial
+
+import (
+	"time"
+)
+
+// AdminAuditLog records a change an admin made through the panel
+type AdminAuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	AdminID       uint   `json:"admin_id" gorm:"not null;index"`
+	AdminUsername string `json:"admin_username" gorm:"not null;size:64"`
+	// Method and Route identify the operation, Path holds the actual IDs
+	Method   string `json:"method" gorm:"not null;size:8"`
+	Route    string `json:"route" gorm:"not null;size:255"`
+	Path     string `json:"path" gorm:"not null;size:512"`
+	Status   int    `json:"status" gorm:"not null"`
+	ClientIP string `json:"client_ip" gorm:"size:45"`
+	// RequestID matches the entry with the logs and the response of the request
+	RequestID string `json:"request_id" gorm:"size:64;index"`
+}
+
+// TableName returns the table name for AdminAuditLog model
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
+}
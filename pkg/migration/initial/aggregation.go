@@ -0,0 +1,21 @@
+package initial
+
+import (
+	"time"
+)
+
+// AggregationWatermark is the high-water mark of an incremental aggregation:
+// every record up to LastRecordID has been added to the aggregates
+type AggregationWatermark struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:64"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	LastRecordID uint `json:"last_record_id" gorm:"not null;default:0"`
+	// LastRecordAt is when the last aggregated record was created
+	LastRecordAt *time.Time `json:"last_record_at,omitempty"`
+}
+
+// TableName returns the table name for AggregationWatermark model
+func (AggregationWatermark) TableName() string {
+	return "aggregation_watermarks"
+}
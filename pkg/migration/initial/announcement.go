@@ -0,0 +1,41 @@
+package initial
+
+import (
+	"time"
+)
+
+// Announcement is a notice shown to users during its publish window
+type Announcement struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Title    string `json:"title" gorm:"not null;size:200"`
+	Content  string `json:"content" gorm:"type:text"`
+	Audience string `json:"audience" gorm:"not null;size:20;default:all"`
+	PlanIDs  []uint `json:"plan_ids,omitempty" gorm:"serializer:json;type:text;comment:Plans of the plans audience"`
+	IsPinned bool   `json:"is_pinned" gorm:"not null;default:false;index"`
+	// Publish window, nil means unbounded
+	StartsAt  *time.Time `json:"starts_at,omitempty" gorm:"index"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" gorm:"index"`
+	CreatedBy string     `json:"created_by" gorm:"size:64"`
+}
+
+// TableName returns the table name for Announcement model
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// AnnouncementRead records that a user has read an announcement
+type AnnouncementRead struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AnnouncementID uint `json:"announcement_id" gorm:"not null;uniqueIndex:idx_announcement_reads_user"`
+	UserID         uint `json:"user_id" gorm:"not null;uniqueIndex:idx_announcement_reads_user;index"`
+}
+
+// TableName returns the table name for AnnouncementRead model
+func (AnnouncementRead) TableName() string {
+	return "announcement_reads"
+}
@@ -0,0 +1,59 @@
+package initial
+
+import (
+	"time"
+)
+
+// RevokedToken represents a revoked JWT identified by its JTI
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	JTI       string    `json:"jti" gorm:"uniqueIndex;not null;size:64;comment:JWT ID of the revoked token"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index;comment:Entry can be purged after the token expires"`
+}
+
+// TableName returns the table name for RevokedToken model
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// NodeToken represents a registration token that authenticates an agent as a node
+type NodeToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeID      uint   `json:"node_id" gorm:"not null;index"`
+	TokenHash   string `json:"-" gorm:"uniqueIndex;not null;size:64;comment:SHA-256 of the token"`
+	Description string `json:"description" gorm:"size:255"`
+
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName returns the table name for NodeToken model
+func (NodeToken) TableName() string {
+	return "node_tokens"
+}
+
+// SubscriptionTokenRotation records the replacement of a user's subscription
+// token. The previous token keeps working until GraceUntil so that clients can
+// pick up the new link; rows are kept afterwards as the rotation audit trail.
+type SubscriptionTokenRotation struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	OldTokenHash string    `json:"-" gorm:"not null;index;size:64;comment:SHA-256 of the replaced token"`
+	GraceUntil   time.Time `json:"grace_until" gorm:"not null;index;comment:Replaced token is honored until this time"`
+	Operator     string    `json:"operator" gorm:"not null;size:64;comment:Admin or the user who rotated the token"`
+	Reason       string    `json:"reason" gorm:"size:255"`
+}
+
+// TableName returns the table name for SubscriptionTokenRotation model
+func (SubscriptionTokenRotation) TableName() string {
+	return "subscription_token_rotations"
+}
@@ -0,0 +1,24 @@
+package initial
+
+import (
+	"time"
+)
+
+// NodeCost records what a node cost in one month for one category
+type NodeCost struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeID   uint      `json:"node_id" gorm:"not null;uniqueIndex:idx_node_cost_month"`
+	Month    time.Time `json:"month" gorm:"not null;uniqueIndex:idx_node_cost_month;index;comment:First day of the billed month"`
+	Category string    `json:"category" gorm:"not null;size:20;uniqueIndex:idx_node_cost_month"`
+	Amount   int64     `json:"amount" gorm:"not null;default:0;comment:Amount in cents"`
+	Currency string    `json:"currency" gorm:"not null;default:'USD';size:3"`
+	Notes    string    `json:"notes" gorm:"size:255"`
+}
+
+// TableName returns the table name for NodeCost model
+func (NodeCost) TableName() string {
+	return "node_costs"
+}
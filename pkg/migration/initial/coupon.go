@@ -0,0 +1,53 @@
+package initial
+
+import (
+	"time"
+)
+
+// Coupon is a discount code redeemable when ordering a plan
+type Coupon struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Code is stored upper case and matched case-insensitively
+	Code        string `json:"code" gorm:"uniqueIndex;not null;size:32"`
+	Description string `json:"description" gorm:"size:255"`
+	Type        string `json:"type" gorm:"not null;size:20"`
+	// Value is a percentage for percentage coupons and cents for fixed ones
+	Value    int64  `json:"value" gorm:"not null;default:0"`
+	Currency string `json:"currency" gorm:"size:3;comment:Currency of fixed coupons"`
+
+	// Restrictions, zero values mean no restriction
+	MaxUses        int        `json:"max_uses" gorm:"not null;default:0;comment:Total redemptions allowed, 0 = unlimited"`
+	MaxUsesPerUser int        `json:"max_uses_per_user" gorm:"not null;default:0;comment:Redemptions allowed per user, 0 = unlimited"`
+	MinimumTotal   int64      `json:"minimum_total" gorm:"not null;default:0;comment:Minimum order total in cents before the discount"`
+	PlanIDs        []uint     `json:"plan_ids,omitempty" gorm:"serializer:json;type:text;comment:Plans the coupon applies to, empty = all"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	IsEnabled      bool       `json:"is_enabled" gorm:"not null;default:true"`
+	UsedCount      int        `json:"used_count" gorm:"not null;default:0;comment:Redemptions by pending and paid orders"`
+}
+
+// TableName returns the table name for Coupon model
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// CouponRedemption records the use of a coupon by an order. Redemptions of
+// cancelled orders are removed so that they do not count against limits.
+type CouponRedemption struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	CouponID uint   `json:"coupon_id" gorm:"not null;index"`
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	OrderID  uint   `json:"order_id" gorm:"not null;uniqueIndex"`
+	Discount int64  `json:"discount" gorm:"not null;default:0;comment:Discount in cents"`
+	Currency string `json:"currency" gorm:"not null;size:3"`
+}
+
+// TableName returns the table name for CouponRedemption model
+func (CouponRedemption) TableName() string {
+	return "coupon_redemptions"
+}
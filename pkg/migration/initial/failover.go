@@ -0,0 +1,31 @@
+package initial
+
+import (
+	"time"
+)
+
+// NodeFailover records a user moved off an offline node. The user's
+// assignment to FromNodeID is disabled and one to ToNodeID added until the
+// node recovers. A user moved again while failed over keeps one failover,
+// pointing at the latest node.
+type NodeFailover struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	FromNodeID uint       `json:"from_node_id" gorm:"not null;index"`
+	ToNodeID   uint       `json:"to_node_id" gorm:"not null;index"`
+	Status     string     `json:"status" gorm:"not null;size:20;index"`
+	Reason     string     `json:"reason" gorm:"size:255"`
+	RevertedAt *time.Time `json:"reverted_at,omitempty"`
+
+	// Relationships
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	ToNode Node `json:"to_node,omitempty" gorm:"foreignKey:ToNodeID"`
+}
+
+// TableName returns the table name for NodeFailover model
+func (NodeFailover) TableName() string {
+	return "node_failovers"
+}
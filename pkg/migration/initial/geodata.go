@@ -0,0 +1,47 @@
+package initial
+
+import (
+	"time"
+)
+
+// GeoDataArtifact is a geo database (geoip/geosite) downloaded by the API
+// server and distributed to the nodes. The file itself lives in the API
+// server's cache directory; SHA256 identifies its content.
+type GeoDataArtifact struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Name is the file name used on the nodes, e.g. geoip.db
+	Name      string `json:"name" gorm:"uniqueIndex;not null;size:64"`
+	Version   string `json:"version" gorm:"not null;size:32;comment:Fetch time of the content, YYYYMMDDHHMMSS"`
+	SHA256    string `json:"sha256" gorm:"not null;size:64"`
+	Size      int64  `json:"size" gorm:"not null;default:0"`
+	SourceURL string `json:"source_url" gorm:"size:512"`
+
+	// PublishedAt is when the current content was first fetched
+	PublishedAt time.Time `json:"published_at"`
+	// CheckedAt is when the source was last checked for a new release
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// TableName returns the table name for GeoDataArtifact model
+func (GeoDataArtifact) TableName() string {
+	return "geo_data_artifacts"
+}
+
+// NodeGeoData is the version of a geo database a node last reported
+type NodeGeoData struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+
+	NodeID     uint      `json:"node_id" gorm:"not null;uniqueIndex:idx_node_geo_data"`
+	Name       string    `json:"name" gorm:"not null;size:64;uniqueIndex:idx_node_geo_data"`
+	Version    string    `json:"version" gorm:"size:32"`
+	SHA256     string    `json:"sha256" gorm:"size:64"`
+	ReportedAt time.Time `json:"reported_at"`
+}
+
+// TableName returns the table name for NodeGeoData model
+func (NodeGeoData) TableName() string {
+	return "node_geo_data"
+}
@@ -0,0 +1,25 @@
+package initial
+
+import (
+	"time"
+)
+
+// NodeStatusTransition records a change of a node's status and why
+type NodeStatusTransition struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NodeID uint   `json:"node_id" gorm:"not null;index"`
+	From   string `json:"from" gorm:"not null;size:20"`
+	To     string `json:"to" gorm:"not null;size:20"`
+	Reason string `json:"reason" gorm:"size:512"`
+	// HealthScore is the node's score when the transition was made
+	HealthScore float64 `json:"health_score" gorm:"not null;default:0"`
+	// Automatic is set for transitions made by the health checks
+	Automatic bool `json:"automatic" gorm:"not null;default:false"`
+}
+
+// TableName returns the table name for NodeStatusTransition model
+func (NodeStatusTransition) TableName() string {
+	return "node_status_transitions"
+}
@@ -0,0 +1,59 @@
+// Package initial holds the models of the schema before migrations existed.
+// They are frozen copies of the models of that time, so that the initial
+// migration creates the same tables whatever the models became since. The
+// columns and tables added later are left to the migrations that added them.
+package initial
+
+// Tables are the tables of the initial schema, in the order of their creation
+func Tables() []any {
+	return []any{
+		&Plan{},
+		&PlanFeature{},
+		&User{},
+		&Node{},
+		&UserNode{},
+		&TrafficRecord{},
+		&TrafficSummary{},
+		&TrafficQuota{},
+		&NodeLog{},
+		&PlanNodeAccess{},
+		&RevokedToken{},
+		&NodeProbe{},
+		&NodeToken{},
+		&NodeMetricsHistory{},
+		&TrafficAdjustment{},
+		&ServiceLease{},
+		&SubscriptionTokenRotation{},
+		&NodeGroup{},
+		&NodeGroupMember{},
+		&PlanGroupAccess{},
+		&NodeCost{},
+		&Order{},
+		&Payment{},
+		&Tenant{},
+		&Coupon{},
+		&CouponRedemption{},
+		&GeoDataArtifact{},
+		&NodeGeoData{},
+		&NodeConfigVersion{},
+		&NodeConfigOverride{},
+		&NodeStatusTransition{},
+		&NodeFailover{},
+		&ReferralSettings{},
+		&ReferralCode{},
+		&Referral{},
+		&ReferralCommission{},
+		&SafetyPolicy{},
+		&BalanceTransaction{},
+		&LedgerEntry{},
+		&ShapingRecord{},
+		&Announcement{},
+		&AnnouncementRead{},
+		&AggregationWatermark{},
+		&Notification{},
+		&AlertDelivery{},
+		&SavedFilter{},
+		&BlocklistEntry{},
+		&AdminAuditLog{},
+	}
+}
@@ -0,0 +1,37 @@
+package initial
+
+import (
+	"time"
+)
+
+// NodeMetricsHistory is one bucket of node metrics. Agents report into minute
+// buckets, which are downsampled into hourly and daily buckets as they age.
+type NodeMetricsHistory struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeID     uint      `json:"node_id" gorm:"not null;uniqueIndex:idx_node_metrics_bucket"`
+	Resolution string    `json:"resolution" gorm:"not null;size:4;uniqueIndex:idx_node_metrics_bucket"`
+	Timestamp  time.Time `json:"timestamp" gorm:"not null;uniqueIndex:idx_node_metrics_bucket;index;comment:Bucket start"`
+
+	// Averages over the bucket
+	CPUUsage              float64 `json:"cpu_usage" gorm:"not null;default:0"`
+	MemoryUsage           float64 `json:"memory_usage" gorm:"not null;default:0"`
+	DiskUsage             float64 `json:"disk_usage" gorm:"not null;default:0"`
+	LoadAverage           float64 `json:"load_average" gorm:"not null;default:0"`
+	NetworkInBytesPerSec  int64   `json:"network_in_bytes_per_sec" gorm:"not null;default:0"`
+	NetworkOutBytesPerSec int64   `json:"network_out_bytes_per_sec" gorm:"not null;default:0"`
+	Connections           int32   `json:"connections" gorm:"not null;default:0"`
+
+	// Peaks over the bucket
+	MaxCPUUsage    float64 `json:"max_cpu_usage" gorm:"not null;default:0"`
+	MaxConnections int32   `json:"max_connections" gorm:"not null;default:0"`
+
+	SampleCount int `json:"sample_count" gorm:"not null;default:1;comment:Number of minute samples in the bucket"`
+}
+
+// TableName returns the table name for NodeMetricsHistory model
+func (NodeMetricsHistory) TableName() string {
+	return "node_metrics_history"
+}
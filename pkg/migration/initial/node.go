@@ -0,0 +1,166 @@
+package initial
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Node represents a sing-box server node
+type Node struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	// Basic information
+	Name        string `json:"name" gorm:"not null;size:128"`
+	Description string `json:"description" gorm:"type:text"`
+	Type        string `json:"type" gorm:"not null;size:20"`
+	Status      string `json:"status" gorm:"not null;default:'offline';size:20"`
+
+	// Connection information
+	Host string `json:"host" gorm:"not null;size:255"`
+	Port int    `json:"port" gorm:"not null"`
+
+	// Authentication and encryption
+	UUID     string `json:"uuid,omitempty" gorm:"size:36;comment:For VMess/VLESS"`
+	Password string `json:"password,omitempty" gorm:"size:255;comment:For Trojan/Shadowsocks"`
+	Method   string `json:"method,omitempty" gorm:"size:32;comment:Encryption method"`
+	Protocol string `json:"protocol,omitempty" gorm:"size:32;comment:Transport protocol"`
+
+	// Transport configuration
+	Network     string `json:"network,omitempty" gorm:"size:16;default:'tcp';comment:tcp/udp/ws/grpc"`
+	Path        string `json:"path,omitempty" gorm:"size:255;comment:WebSocket path or gRPC service name"`
+	Host_header string `json:"host_header,omitempty" gorm:"size:255;column:host_header;comment:Host header for disguise"`
+
+	// TLS configuration
+	TLS           bool   `json:"tls" gorm:"not null;default:false"`
+	ServerName    string `json:"server_name,omitempty" gorm:"size:255;comment:TLS server name"`
+	Fingerprint   string `json:"fingerprint,omitempty" gorm:"size:64;comment:TLS fingerprint"`
+	ALPN          string `json:"alpn,omitempty" gorm:"size:255;comment:ALPN protocols"`
+	AllowInsecure bool   `json:"allow_insecure" gorm:"not null;default:false"`
+
+	// Node configuration
+	MaxUsers    int     `json:"max_users" gorm:"not null;default:0;comment:0 means unlimited"`
+	SpeedLimit  int64   `json:"speed_limit" gorm:"not null;default:0;comment:Speed limit per user in bytes/sec"`
+	TrafficRate float64 `json:"traffic_rate" gorm:"not null;default:1.0;comment:Traffic rate multiplier"`
+
+	// Node management
+	Region    string `json:"region" gorm:"size:64"`
+	Country   string `json:"country" gorm:"size:64"`
+	City      string `json:"city" gorm:"size:64"`
+	ISP       string `json:"isp" gorm:"size:128"`
+	Tags      string `json:"tags" gorm:"size:512;comment:Comma-separated tags"`
+	Sort      int    `json:"sort" gorm:"not null;default:0;comment:Sort order"`
+	IsEnabled bool   `json:"is_enabled" gorm:"not null;default:true"`
+
+	// Statistics and monitoring
+	CurrentUsers    int        `json:"current_users" gorm:"not null;default:0"`
+	TotalTraffic    int64      `json:"total_traffic" gorm:"not null;default:0;comment:Total traffic in bytes"`
+	UploadTraffic   int64      `json:"upload_traffic" gorm:"not null;default:0"`
+	DownloadTraffic int64      `json:"download_traffic" gorm:"not null;default:0"`
+	LastHeartbeat   *time.Time `json:"last_heartbeat,omitempty"`
+
+	// System information
+	CPUUsage    float64 `json:"cpu_usage" gorm:"type:decimal(5,2);default:0"`
+	MemoryUsage float64 `json:"memory_usage" gorm:"type:decimal(5,2);default:0"`
+	DiskUsage   float64 `json:"disk_usage" gorm:"type:decimal(5,2);default:0"`
+	Load1       float64 `json:"load1" gorm:"type:decimal(8,2);default:0"`
+	Load5       float64 `json:"load5" gorm:"type:decimal(8,2);default:0"`
+	Load15      float64 `json:"load15" gorm:"type:decimal(8,2);default:0"`
+
+	// Network throughput derived from consecutive agent counter samples
+	NetworkInRate  int64 `json:"network_in_rate" gorm:"not null;default:0;comment:Inbound bytes per second"`
+	NetworkOutRate int64 `json:"network_out_rate" gorm:"not null;default:0;comment:Outbound bytes per second"`
+
+	// Divergences between the node's reports and the panel's observations,
+	// see NodeWitness. Cleared by the first window without any.
+	WitnessFlags     string     `json:"witness_flags,omitempty" gorm:"size:128;comment:Comma-separated witness findings"`
+	WitnessFlaggedAt *time.Time `json:"witness_flagged_at,omitempty" gorm:"comment:Start of the flagged stretch"`
+
+	// Health score (0-100) of the last health check, see NodeHealth.
+	// StatusAutomatic is set while the status was set by the health checks.
+	HealthScore     float64 `json:"health_score" gorm:"type:decimal(5,2);default:100"`
+	StatusReason    string  `json:"status_reason,omitempty" gorm:"size:512"`
+	StatusAutomatic bool    `json:"status_automatic" gorm:"not null;default:false"`
+
+	// Configuration and version
+	ConfigVersion  int    `json:"config_version" gorm:"not null;default:0"`
+	ConfigContent  string `json:"config_content,omitempty" gorm:"type:text;comment:Node configuration content"`
+	AgentVersion   string `json:"agent_version" gorm:"size:32"`
+	SingBoxVersion string `json:"sing_box_version" gorm:"size:32"`
+
+	// Metadata
+	Notes    string            `json:"notes" gorm:"type:text"`
+	Metadata map[string]string `json:"metadata,omitempty" gorm:"serializer:json"`
+
+	// MergedIntoID is set on a deleted node whose history was merged into a successor
+	MergedIntoID *uint `json:"merged_into_id,omitempty" gorm:"index"`
+
+	// Relationships
+	TrafficRecords []TrafficRecord `json:"traffic_records,omitempty" gorm:"foreignKey:NodeID"`
+	UserNodes      []UserNode      `json:"user_nodes,omitempty" gorm:"foreignKey:NodeID"`
+	NodeLogs       []NodeLog       `json:"node_logs,omitempty" gorm:"foreignKey:NodeID"`
+}
+
+// TableName returns the table name for Node model
+func (Node) TableName() string {
+	return "nodes"
+}
+
+// NodeLog represents node operation logs
+type NodeLog struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	NodeID uint `json:"node_id" gorm:"not null;index"`
+	Node   Node `json:"node,omitempty" gorm:"foreignKey:NodeID"`
+
+	Level   string `json:"level" gorm:"not null;size:10;index"`
+	Type    string `json:"type" gorm:"not null;size:32;index;comment:heartbeat/traffic/system/error"`
+	Message string `json:"message" gorm:"not null;type:text"`
+
+	// Additional data
+	Data map[string]interface{} `json:"data,omitempty" gorm:"serializer:json"`
+}
+
+// TableName returns the table name for NodeLog model
+func (NodeLog) TableName() string {
+	return "node_logs"
+}
+
+// NodeGroup is a named set of nodes that can be managed and granted to plans as a unit
+type NodeGroup struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	Name        string `json:"name" gorm:"not null;size:64;index"`
+	Description string `json:"description" gorm:"size:255"`
+	Sort        int    `json:"sort" gorm:"not null;default:0;comment:Sort order"`
+
+	// Relationships
+	Members []NodeGroupMember `json:"members,omitempty" gorm:"foreignKey:GroupID"`
+}
+
+// TableName returns the table name for NodeGroup model
+func (NodeGroup) TableName() string {
+	return "node_groups"
+}
+
+// NodeGroupMember assigns a node to a group; a node may belong to several groups
+type NodeGroupMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	GroupID uint `json:"group_id" gorm:"not null;uniqueIndex:idx_node_group_member"`
+	NodeID  uint `json:"node_id" gorm:"not null;uniqueIndex:idx_node_group_member;index"`
+}
+
+// TableName returns the table name for NodeGroupMember model
+func (NodeGroupMember) TableName() string {
+	return "node_group_members"
+}
@@ -0,0 +1,25 @@
+package initial
+
+import (
+	"time"
+)
+
+// NodeConfigOverride sets one value of a node's config on top of the config
+// applied to it, so that a node can differ from the nodes sharing its config.
+// Path is a dot-separated key path into the JSON config where numbers index
+// arrays, e.g. "log.level" or "inbounds.0.sniff"; Value is the JSON value.
+type NodeConfigOverride struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeID uint   `json:"node_id" gorm:"not null;uniqueIndex:idx_node_config_override"`
+	Path   string `json:"path" gorm:"not null;size:255;uniqueIndex:idx_node_config_override"`
+	Value  string `json:"value" gorm:"type:text"`
+	Author string `json:"author" gorm:"size:64;comment:Admin who set the override"`
+}
+
+// TableName returns the table name for NodeConfigOverride model
+func (NodeConfigOverride) TableName() string {
+	return "node_config_overrides"
+}
@@ -0,0 +1,32 @@
+package initial
+
+import (
+	"time"
+)
+
+// NodeConfigVersion is a config applied to a node. Version matches the node's
+// ConfigVersion after the config was applied, so versions are never reused.
+// Content includes the node's overrides, see NodeConfigOverride.
+type NodeConfigVersion struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NodeID  uint `json:"node_id" gorm:"not null;uniqueIndex:idx_node_config_version"`
+	Version int  `json:"version" gorm:"not null;uniqueIndex:idx_node_config_version"`
+
+	Author string `json:"author" gorm:"size:64;comment:Admin who applied the config"`
+	Source string `json:"source" gorm:"not null;size:20"`
+	// RestoredFrom is the version a restore copied, 0 otherwise
+	RestoredFrom int    `json:"restored_from" gorm:"not null;default:0"`
+	SHA256       string `json:"sha256" gorm:"not null;size:64"`
+	Size         int64  `json:"size" gorm:"not null;default:0"`
+	Content      string `json:"content,omitempty" gorm:"type:text"`
+	// Base is the config before the node's overrides were applied, empty
+	// when Content is the config as applied
+	Base string `json:"base,omitempty" gorm:"type:text"`
+}
+
+// TableName returns the table name for NodeConfigVersion model
+func (NodeConfigVersion) TableName() string {
+	return "node_config_versions"
+}
@@ -0,0 +1,41 @@
+package initial
+
+import (
+	"time"
+)
+
+// Notification is a message in a user's in-app notification center
+type Notification struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	UserID   uint   `json:"user_id" gorm:"not null;index;uniqueIndex:idx_notifications_dedup"`
+	Type     string `json:"type" gorm:"not null;size:32"`
+	Severity string `json:"severity" gorm:"not null;size:16;default:info"`
+	Title    string `json:"title" gorm:"not null;size:200"`
+	Message  string `json:"message" gorm:"size:1024"`
+	// DedupKey makes an event notify a user at most once, nil never deduplicates
+	DedupKey *string    `json:"-" gorm:"size:128;uniqueIndex:idx_notifications_dedup"`
+	ReadAt   *time.Time `json:"read_at,omitempty" gorm:"index"`
+}
+
+// TableName returns the table name for Notification model
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// AlertDelivery records an alert delivered to a user through a channel that
+// keeps no record of its own, such as email, so it is sent at most once
+type AlertDelivery struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	Channel string `json:"channel" gorm:"not null;size:32;uniqueIndex:idx_alert_deliveries_key"`
+	UserID  uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_alert_deliveries_key"`
+	Key     string `json:"key" gorm:"not null;size:128;uniqueIndex:idx_alert_deliveries_key"`
+}
+
+// TableName returns the table name for AlertDelivery model
+func (AlertDelivery) TableName() string {
+	return "alert_deliveries"
+}
@@ -0,0 +1,22 @@
+package initial
+
+import (
+	"time"
+)
+
+// NodeProbe represents a single reachability probe of a node
+type NodeProbe struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NodeID    uint      `json:"node_id" gorm:"not null;index:idx_node_probes_node_time"`
+	ProbedAt  time.Time `json:"probed_at" gorm:"not null;index:idx_node_probes_node_time"`
+	Success   bool      `json:"success" gorm:"not null;default:false"`
+	LatencyMs int       `json:"latency_ms" gorm:"not null;default:0;comment:Connect latency in ms, 0 when failed"`
+	Error     string    `json:"error,omitempty" gorm:"size:255"`
+}
+
+// TableName returns the table name for NodeProbe model
+func (NodeProbe) TableName() string {
+	return "node_probes"
+}
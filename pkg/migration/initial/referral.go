@@ -0,0 +1,87 @@
+package initial
+
+import (
+	"time"
+)
+
+// ReferralSettings configures the commission paid for orders of referred
+// users. There is a single row, edited by admins.
+type ReferralSettings struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Enabled        bool   `json:"enabled" gorm:"not null;default:false"`
+	CommissionType string `json:"commission_type" gorm:"not null;size:20"`
+	// CommissionRate is the percentage of the order total for balance commissions
+	CommissionRate int `json:"commission_rate" gorm:"not null;default:0"`
+	// TrafficBonus is the bytes added per paid order for traffic commissions
+	TrafficBonus int64 `json:"traffic_bonus" gorm:"not null;default:0"`
+	// FirstOrderOnly rewards only the first paid order of each referred user
+	FirstOrderOnly bool   `json:"first_order_only" gorm:"not null;default:false"`
+	UpdatedBy      string `json:"updated_by" gorm:"size:64"`
+}
+
+// TableName returns the table name for ReferralSettings model
+func (ReferralSettings) TableName() string {
+	return "referral_settings"
+}
+
+// ReferralCode is the code a user shares to refer others
+type ReferralCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID uint   `json:"user_id" gorm:"uniqueIndex;not null"`
+	Code   string `json:"code" gorm:"uniqueIndex;not null;size:16"`
+}
+
+// TableName returns the table name for ReferralCode model
+func (ReferralCode) TableName() string {
+	return "referral_codes"
+}
+
+// Referral records a user who signed up with another user's referral code
+type Referral struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ReferrerID uint   `json:"referrer_id" gorm:"not null;index"`
+	UserID     uint   `json:"user_id" gorm:"uniqueIndex;not null;comment:Referred user"`
+	Code       string `json:"code" gorm:"not null;size:16"`
+
+	// Filled by listings
+	Username   string `json:"username" gorm:"-"`
+	PaidOrders int64  `json:"paid_orders" gorm:"-"`
+}
+
+// TableName returns the table name for Referral model
+func (Referral) TableName() string {
+	return "referrals"
+}
+
+// ReferralCommission is a reward owed or given to a referrer, either for a
+// paid order of a referred user or as a manual adjustment by an admin
+type ReferralCommission struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ReferrerID     uint  `json:"referrer_id" gorm:"not null;index"`
+	ReferredUserID uint  `json:"referred_user_id" gorm:"not null;default:0;comment:0 for adjustments"`
+	OrderID        *uint `json:"order_id,omitempty" gorm:"uniqueIndex;comment:Rewarded order, nil for adjustments"`
+
+	Type string `json:"type" gorm:"not null;size:20"`
+	// Amount is in cents for balance commissions and bytes for traffic ones,
+	// negative for adjustments taking back a reward
+	Amount   int64      `json:"amount" gorm:"not null;default:0"`
+	Currency string     `json:"currency" gorm:"size:3"`
+	Status   string     `json:"status" gorm:"not null;size:20;index"`
+	Note     string     `json:"note" gorm:"size:255"`
+	Operator string     `json:"operator" gorm:"size:64;comment:Admin who made the adjustment or payout"`
+	PaidAt   *time.Time `json:"paid_at,omitempty"`
+}
+
+// TableName returns the table name for ReferralCommission model
+func (ReferralCommission) TableName() string {
+	return "referral_commissions"
+}
@@ -0,0 +1,34 @@
+package initial
+
+import (
+	"time"
+)
+
+// SafetyPolicy holds the panel environment and the rules guarding
+// destructive admin operations. There is a single row, edited through the
+// global config.
+type SafetyPolicy struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Environment string       `json:"environment" gorm:"not null;size:16"`
+	Rules       []SafetyRule `json:"rules" gorm:"serializer:json;type:text"`
+	UpdatedBy   string       `json:"updated_by" gorm:"size:64"`
+}
+
+// TableName returns the table name for SafetyPolicy model
+func (SafetyPolicy) TableName() string {
+	return "safety_policies"
+}
+
+// SafetyRule denies or asks for confirmation of an operation
+type SafetyRule struct {
+	Operation string `json:"operation"`
+	// Environments the rule applies in, empty = all
+	Environments []string `json:"environments,omitempty"`
+	// Threshold limits the rule to operations affecting more than this many
+	// objects, 0 = always
+	Threshold   int    `json:"threshold,omitempty"`
+	Action      string `json:"action"`
+	ConfirmText string `json:"confirm_text,omitempty"`
+}
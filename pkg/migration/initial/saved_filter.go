@@ -0,0 +1,27 @@
+package initial
+
+import (
+	"time"
+)
+
+// SavedFilter is a named filter and list view of an admin. Filter holds the
+// listing request fields as JSON, Sort and Columns are only stored for the UI.
+type SavedFilter struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OwnerID uint     `json:"owner_id" gorm:"not null;index;uniqueIndex:idx_saved_filters_name"`
+	Entity  string   `json:"entity" gorm:"not null;size:16;uniqueIndex:idx_saved_filters_name"`
+	Name    string   `json:"name" gorm:"not null;size:64;uniqueIndex:idx_saved_filters_name"`
+	Filter  string   `json:"filter" gorm:"type:text"`
+	Sort    string   `json:"sort" gorm:"size:64"`
+	Columns []string `json:"columns,omitempty" gorm:"serializer:json;type:text"`
+	// Shared makes the filter usable by every admin, only the owner can change it
+	Shared bool `json:"shared" gorm:"not null;default:false;index"`
+}
+
+// TableName returns the table name for SavedFilter model
+func (SavedFilter) TableName() string {
+	return "saved_filters"
+}
@@ -0,0 +1,25 @@
+package initial
+
+import (
+	"time"
+)
+
+// ShapingRecord holds the speed limit shaping a node applied to a user
+// during one traffic report. Reports without throttling are not stored.
+type ShapingRecord struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	UserID           uint    `json:"user_id" gorm:"not null;index"`
+	NodeID           uint    `json:"node_id" gorm:"not null;index"`
+	LimitBytesPerSec int64   `json:"limit_bytes_per_sec" gorm:"not null;default:0;comment:Speed limit in effect"`
+	ThrottleEvents   int     `json:"throttle_events" gorm:"not null;default:0;comment:Times the user ran out of tokens"`
+	ThrottledSeconds float64 `json:"throttled_seconds" gorm:"not null;default:0;comment:Time spent waiting for tokens"`
+	DelayedBytes     int64   `json:"delayed_bytes" gorm:"not null;default:0;comment:Traffic held back by the limiter"`
+	DroppedBytes     int64   `json:"dropped_bytes" gorm:"not null;default:0;comment:Traffic discarded by the limiter"`
+}
+
+// TableName returns the table name for ShapingRecord model
+func (ShapingRecord) TableName() string {
+	return "shaping_records"
+}
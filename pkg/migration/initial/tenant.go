@@ -0,0 +1,37 @@
+package initial
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Tenant is a reseller whose users see the panel under the tenant's own brand.
+// Users without a tenant see the default branding from the web configuration.
+type Tenant struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	Name        string `json:"name" gorm:"not null;size:64;index"`
+	Description string `json:"description" gorm:"size:255"`
+
+	Branding Branding `json:"branding" gorm:"embedded;embeddedPrefix:branding_"`
+}
+
+// TableName returns the table name for Tenant model
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// Branding is the white-label appearance of the panel. Empty fields fall
+// back to the default branding.
+type Branding struct {
+	PanelName    string `json:"panel_name" gorm:"size:64"`
+	LogoURL      string `json:"logo_url" gorm:"size:512"`
+	SupportEmail string `json:"support_email" gorm:"size:255"`
+	SupportURL   string `json:"support_url" gorm:"size:512"`
+	// SubscriptionHost is the host, optionally with port, put in subscription links
+	SubscriptionHost string `json:"subscription_host" gorm:"size:255"`
+}
@@ -0,0 +1,116 @@
+package initial
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User represents a sing-box user
+type User struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	// Basic information
+	Username    string `json:"username" gorm:"uniqueIndex;not null;size:64"`
+	Email       string `json:"email" gorm:"uniqueIndex;size:255"`
+	Password    string `json:"-" gorm:"not null;size:255"` // Exclude from JSON
+	DisplayName string `json:"display_name" gorm:"size:128"`
+	Avatar      string `json:"avatar" gorm:"size:512"`
+	Status      string `json:"status" gorm:"not null;default:'active';size:20"`
+	Role        string `json:"role" gorm:"not null;default:'user';size:20"`
+	// AdminPermissions are the areas an admin may manage, unused for other roles
+	AdminPermissions []string `json:"admin_permissions,omitempty" gorm:"serializer:json;type:text"`
+	// TenantID selects the reseller branding the user sees, nil for the default one
+	TenantID *uint `json:"tenant_id,omitempty" gorm:"index"`
+	// EmailVerified is set once the user followed a verification link, and
+	// cleared when the email address changes
+	EmailVerified   bool       `json:"email_verified" gorm:"not null;default:false"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// TelegramChatID receives the user's alerts from the Telegram bot, empty when not linked
+	TelegramChatID string `json:"telegram_chat_id,omitempty" gorm:"size:32"`
+
+	// Plan and quota
+	PlanID           uint      `json:"plan_id" gorm:"not null"`
+	Plan             Plan      `json:"plan,omitempty" gorm:"foreignKey:PlanID"`
+	TrafficQuota     int64     `json:"traffic_quota" gorm:"not null;default:0;comment:Monthly traffic quota in bytes"`
+	TrafficUsed      int64     `json:"traffic_used" gorm:"not null;default:0;comment:Used traffic in current period"`
+	TrafficResetDate time.Time `json:"traffic_reset_date" gorm:"comment:Next traffic reset date"`
+	DeviceLimit      int       `json:"device_limit" gorm:"not null;default:1;comment:Maximum concurrent devices"`
+	SpeedLimit       int64     `json:"speed_limit" gorm:"not null;default:0;comment:Speed limit in bytes/sec"`
+
+	// Wallet, only changed through balance transactions
+	Balance         int64  `json:"balance" gorm:"not null;default:0;comment:Wallet balance in cents"`
+	BalanceCurrency string `json:"balance_currency" gorm:"size:3;comment:Currency of the balance, set by a credit to an empty wallet"`
+
+	// Account validity
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" gorm:"comment:Account expiration time"`
+	LastLoginAt   *time.Time `json:"last_login_at,omitempty"`
+	LastLoginIP   string     `json:"last_login_ip" gorm:"size:45"`
+	LoginAttempts int        `json:"login_attempts" gorm:"not null;default:0"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+
+	// Activity, see the inactive account policy
+	LastTrafficAt *time.Time `json:"last_traffic_at,omitempty"`
+	// LastActivityAt is the latest login, traffic or reactivation
+	LastActivityAt        *time.Time `json:"last_activity_at,omitempty" gorm:"index"`
+	InactivityExempt      bool       `json:"inactivity_exempt" gorm:"not null;default:false"`
+	InactivityWarnedAt    *time.Time `json:"inactivity_warned_at,omitempty"`
+	InactivitySuspendedAt *time.Time `json:"inactivity_suspended_at,omitempty"`
+
+	// Two-factor authentication
+	TwoFactorEnabled     bool       `json:"two_factor_enabled" gorm:"not null;default:false"`
+	TwoFactorSecret      string     `json:"-" gorm:"size:64;comment:Base32 TOTP secret"`
+	TwoFactorBackupCodes []string   `json:"-" gorm:"serializer:json;type:text;comment:Hashed one-time backup codes"`
+	TwoFactorEnabledAt   *time.Time `json:"two_factor_enabled_at,omitempty"`
+
+	// Subscription and configuration
+	UUID                  string     `json:"uuid" gorm:"uniqueIndex;not null;size:36;comment:User UUID for sing-box config"`
+	SubscriptionToken     string     `json:"subscription_token" gorm:"uniqueIndex;size:64;comment:Subscription token"`
+	ConfigVersion         int        `json:"config_version" gorm:"not null;default:0;comment:Configuration version"`
+	SubscriptionHash      string     `json:"subscription_hash" gorm:"size:64;comment:SHA-256 of last served subscription"`
+	SubscriptionUpdatedAt *time.Time `json:"subscription_updated_at,omitempty" gorm:"comment:When subscription content last changed"`
+
+	// Metadata
+	Notes    string            `json:"notes" gorm:"type:text;comment:Admin notes"`
+	Metadata map[string]string `json:"metadata,omitempty" gorm:"serializer:json;comment:Additional metadata"`
+
+	// Relationships
+	TrafficRecords []TrafficRecord `json:"traffic_records,omitempty" gorm:"foreignKey:UserID"`
+	UserNodes      []UserNode      `json:"user_nodes,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// TableName returns the table name for User model
+func (User) TableName() string {
+	return "users"
+}
+
+// UserNode represents the relationship between users and nodes
+type UserNode struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	NodeID uint `json:"node_id" gorm:"not null;index"`
+
+	// Relationship
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Node Node `json:"node,omitempty" gorm:"foreignKey:NodeID"`
+
+	// Access control
+	IsEnabled bool `json:"is_enabled" gorm:"not null;default:true"`
+	Priority  int  `json:"priority" gorm:"not null;default:0;comment:Lower number means higher priority"`
+
+	// Statistics
+	ConnectCount int64      `json:"connect_count" gorm:"not null;default:0"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+}
+
+// TableName returns the table name for UserNode model
+func (UserNode) TableName() string {
+	return "user_nodes"
+}
@@ -0,0 +1,46 @@
+package initial
+
+import (
+	"time"
+)
+
+// BalanceTransaction is a change of a user's balance, booked as two ledger
+// entries moving the amount between the user's wallet and a counter account
+type BalanceTransaction struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	UserID uint   `json:"user_id" gorm:"not null;index"`
+	Type   string `json:"type" gorm:"not null;size:20;index"`
+	// Amount is the signed change of the user's balance in cents
+	Amount       int64  `json:"amount" gorm:"not null"`
+	Currency     string `json:"currency" gorm:"not null;size:3"`
+	BalanceAfter int64  `json:"balance_after" gorm:"not null;comment:User balance after the transaction in cents"`
+	OrderID      *uint  `json:"order_id,omitempty" gorm:"index"`
+	Note         string `json:"note" gorm:"size:255"`
+	Operator     string `json:"operator" gorm:"size:64"`
+
+	Entries []LedgerEntry `json:"entries,omitempty" gorm:"foreignKey:TransactionID"`
+}
+
+// TableName returns the table name for BalanceTransaction model
+func (BalanceTransaction) TableName() string {
+	return "balance_transactions"
+}
+
+// LedgerEntry is one side of a balance transaction. Amount is signed, so
+// the entries of a transaction sum to zero.
+type LedgerEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TransactionID uint   `json:"transaction_id" gorm:"not null;index"`
+	Account       string `json:"account" gorm:"not null;size:64;index"`
+	Amount        int64  `json:"amount" gorm:"not null"`
+	Currency      string `json:"currency" gorm:"not null;size:3"`
+}
+
+// TableName returns the table name for LedgerEntry model
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
@@ -0,0 +1,208 @@
+// Package migration versions the database schema. The schema is changed by
+// ordered migrations, each applied once in a transaction and recorded in the
+// schema_migrations table, and reverted by its Down function.
+//
+// A released migration must not change: schema changes go into a new
+// migration. Migrations only touch the columns they name, so that they keep
+// working while the models move on.
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSchemaTooNew is returned for databases migrated by a newer release
+	ErrSchemaTooNew = errors.New("database schema is newer than this release")
+	// ErrSchemaOutdated is returned for databases with pending migrations
+	ErrSchemaOutdated = errors.New("database schema has pending migrations")
+)
+
+// Migration is a change of the schema
+type Migration struct {
+	// Version orders the migrations, starting at 1
+	Version     uint
+	Description string
+	Up          func(tx *gorm.DB) error
+	// Down reverts Up
+	Down func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version     uint      `gorm:"primaryKey;autoIncrement:false"`
+	Description string    `gorm:"size:255"`
+	AppliedAt   time.Time `gorm:"not null"`
+}
+
+// TableName keeps the table name stable whatever the naming strategy
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Status is the state of a migration in a database
+type Status struct {
+	Migration
+	// AppliedAt is nil for pending migrations
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New creates a migrator of a database. The versions of the migrations must
+// increase, each migration reverting what it applies.
+func New(db *gorm.DB, migrations []Migration) (*Migrator, error) {
+	var last uint
+	for _, m := range migrations {
+		if m.Version <= last {
+			return nil, fmt.Errorf("migration %d (%s) is out of order", m.Version, m.Description)
+		}
+		if m.Up == nil || m.Down == nil {
+			return nil, fmt.Errorf("migration %d (%s) must be reversible", m.Version, m.Description)
+		}
+		last = m.Version
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest returns the version of the last migration known
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the last migration applied to the
+// database, 0 when none is
+func (m *Migrator) Version() (uint, error) {
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, nil
+	}
+	var version uint
+	err := m.db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Check fails unless the database is at the latest version, so that a
+// release does not run on a schema it does not know
+func (m *Migrator) Check() error {
+	version, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	switch latest := m.Latest(); {
+	case version > latest:
+		return fmt.Errorf("%w: version %d, this release knows up to %d", ErrSchemaTooNew, version, latest)
+	case version < latest:
+		return fmt.Errorf("%w: version %d, this release needs %d", ErrSchemaOutdated, version, latest)
+	}
+	return nil
+}
+
+// Status returns the migrations known, in order, with when they were applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i].Migration = migration
+		if record, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &record.AppliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies the pending migrations in order and returns them. The
+// migrations applied before a failing one stay applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if latest := m.Latest(); version > latest {
+		return nil, fmt.Errorf("%w: version %d, this release knows up to %d", ErrSchemaTooNew, version, latest)
+	}
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, latest first, and returns them
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// applied returns the applied migrations by version
+func (m *Migrator) applied() (map[uint]SchemaMigration, error) {
+	applied := make(map[uint]SchemaMigration)
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	var records []SchemaMigration
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
@@ -0,0 +1,309 @@
+package migration
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"sing-box-web/pkg/models"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	return db
+}
+
+type widget struct {
+	ID    uint
+	Name  string
+	Color string
+}
+
+var widgetMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "widgets",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY, name TEXT)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("widgets")
+		},
+	},
+	{
+		Version:     2,
+		Description: "widget colors",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &widget{}, "Color")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &widget{}, "Color")
+		},
+	},
+}
+
+func TestNewValidatesMigrations(t *testing.T) {
+	db := newTestDB(t)
+	noop := func(*gorm.DB) error { return nil }
+
+	if _, err := New(db, []Migration{{Version: 2, Up: noop, Down: noop}, {Version: 1, Up: noop, Down: noop}}); err == nil {
+		t.Error("New accepted migrations out of order")
+	}
+	if _, err := New(db, []Migration{{Version: 1, Up: noop}}); err == nil {
+		t.Error("New accepted a migration without Down")
+	}
+}
+
+func TestUpAndDown(t *testing.T) {
+	db := newTestDB(t)
+	m, err := New(db, widgetMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Check(); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("Check on an empty database = %v, want ErrSchemaOutdated", err)
+	}
+	applied, err := m.Up()
+	if err != nil || len(applied) != 2 {
+		t.Fatalf("Up = %v, %v", applied, err)
+	}
+	if version, err := m.Version(); err != nil || version != 2 {
+		t.Errorf("Version = %d, %v, want 2", version, err)
+	}
+	if err := m.Check(); err != nil {
+		t.Errorf("Check after Up = %v", err)
+	}
+	if !db.Migrator().HasColumn(&widget{}, "Color") {
+		t.Error("color column missing after Up")
+	}
+	if applied, err := m.Up(); err != nil || len(applied) != 0 {
+		t.Errorf("second Up = %v, %v, want nothing applied", applied, err)
+	}
+
+	reverted, err := m.Down(1)
+	if err != nil || len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Fatalf("Down(1) = %v, %v", reverted, err)
+	}
+	if db.Migrator().HasColumn(&widget{}, "Color") || !db.Migrator().HasTable("widgets") {
+		t.Error("Down(1) did not revert only the last migration")
+	}
+
+	statuses, err := m.Status()
+	if err != nil || len(statuses) != 2 || statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Errorf("Status = %v, %v", statuses, err)
+	}
+
+	if _, err := m.Down(5); err != nil {
+		t.Fatal(err)
+	}
+	if version, _ := m.Version(); version != 0 || db.Migrator().HasTable("widgets") {
+		t.Errorf("Down past the first migration left version %d", version)
+	}
+}
+
+func TestFailedMigrationIsNotRecorded(t *testing.T) {
+	db := newTestDB(t)
+	failing := append(widgetMigrations[:1:1], Migration{
+		Version:     2,
+		Description: "broken",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("ALTER TABLE missing ADD COLUMN x TEXT").Error
+		},
+		Down: func(*gorm.DB) error { return nil },
+	})
+	m, err := New(db, failing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := m.Up()
+	if err == nil || len(applied) != 1 {
+		t.Fatalf("Up = %v, %v, want the first migration applied and an error", applied, err)
+	}
+	if version, _ := m.Version(); version != 1 {
+		t.Errorf("Version = %d, want 1", version)
+	}
+}
+
+func TestSchemaTooNew(t *testing.T) {
+	db := newTestDB(t)
+	m, err := New(db, widgetMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// A newer release applied a migration this one does not know
+	if err := db.Create(&SchemaMigration{Version: 3, Description: "future", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Check(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Check = %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := m.Up(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Errorf("Up = %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestSharedMigrations(t *testing.T) {
+	db := newTestDB(t)
+	m, err := New(db, Shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up = %v", err)
+	}
+	if err := m.Check(); err != nil {
+		t.Errorf("Check = %v", err)
+	}
+	for _, table := range []any{&models.User{}, &models.APIKey{}, &models.ExternalAlert{}} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("table of %T missing", table)
+		}
+	}
+
+	if _, err := m.Down(len(Shared)); err != nil {
+		t.Fatalf("Down = %v", err)
+	}
+	if db.Migrator().HasTable(&models.User{}) {
+		t.Error("users table left after reverting every migration")
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up after Down = %v", err)
+	}
+}
+
+func TestSharedMigrationsAdoptLegacyDatabase(t *testing.T) {
+	db := newTestDB(t)
+	// Databases created before migrations existed were auto-migrated
+	if err := (&models.Database{DB: db}).AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	admin := &models.User{Username: "admin", Email: "admin@example.com", Password: "x", Role: models.UserRoleAdmin}
+	if err := db.Create(admin).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := New(db, Shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up = %v", err)
+	}
+
+	var user models.User
+	if err := db.First(&user, admin.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.UserRoleSuperAdmin {
+		t.Errorf("legacy admin role = %s, want super_admin", user.Role)
+	}
+}
+
+func TestTenantMigrations(t *testing.T) {
+	db := newTestDB(t)
+	m, err := New(db, Tenant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up = %v", err)
+	}
+	if !db.Migrator().HasTable(&models.TrafficRecord{}) || db.Migrator().HasTable(&models.User{}) {
+		t.Error("tenant database does not hold only the traffic tables")
+	}
+}
+
+// TestSharedMigrationsMatchModels checks that the migrations create the
+// tables and columns of the current models, as AutoMigrate would
+func TestSharedMigrationsMatchModels(t *testing.T) {
+	migrated := newTestDB(t)
+	m, err := New(migrated, Shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up = %v", err)
+	}
+
+	automigrated := newTestDB(t)
+	if err := (&models.Database{DB: automigrated}).AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tables, err := automigrated.Migrator().GetTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range tables {
+		want := tableColumns(t, automigrated, table)
+		got := tableColumns(t, migrated, table)
+		for column, typ := range want {
+			if got[column] != typ {
+				t.Errorf("column %s.%s = %q after the migrations, want %q", table, column, got[column], typ)
+			}
+		}
+		for column := range got {
+			if _, ok := want[column]; !ok {
+				t.Errorf("column %s.%s created by the migrations is not in the models", table, column)
+			}
+		}
+	}
+}
+
+// tableColumns returns the database types of the columns of a table
+func tableColumns(t *testing.T, db *gorm.DB, table string) map[string]string {
+	t.Helper()
+	columns := make(map[string]string)
+	if !db.Migrator().HasTable(table) {
+		return columns
+	}
+	types, err := db.Migrator().ColumnTypes(table)
+	if err != nil {
+		t.Fatalf("columns of %s: %v", table, err)
+	}
+	for _, column := range types {
+		columns[column.Name()] = column.DatabaseTypeName()
+	}
+	return columns
+}
+
+// TestSharedMigrationsFrozen checks that a migration creates the schema of
+// its time rather than the current one, the columns added later being left
+// to the migrations that add them
+func TestSharedMigrationsFrozen(t *testing.T) {
+	db := newTestDB(t)
+	// Up to the node commands, before their speed limits
+	m, err := New(db, Shared[:11])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up = %v", err)
+	}
+	for table, column := range map[string]string{
+		"node_commands": "speed_limit",
+		"users":         "trial_ends_at",
+		"nodes":         "active_connections",
+	} {
+		if db.Migrator().HasColumn(table, column) {
+			t.Errorf("column %s.%s created before the migration adding it", table, column)
+		}
+	}
+}
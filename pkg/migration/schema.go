@@ -0,0 +1,261 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/migration/initial"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// Shared are the migrations of the shared database
+var Shared = []Migration{
+	{
+		Version:     1,
+		Description: "initial schema",
+		// Databases created before migrations existed are brought up to date
+		// the way AutoMigrate always did, with the models of that time
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(initial.Tables()...)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, initial.Tables())
+		},
+	},
+	{
+		Version:     2,
+		Description: "promote legacy admins to super admins",
+		// Every admin could do everything before admin permissions existed
+		Up: func(tx *gorm.DB) error {
+			_, err := repository.NewUserRepository(tx).PromoteLegacyAdmins()
+			return err
+		},
+		// Promoted admins are left super admins, nothing tells them apart
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	},
+	{
+		Version:     3,
+		Description: "external alerts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&externalAlertV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&externalAlertV3{}})
+		},
+	},
+	{
+		Version:     4,
+		Description: "API keys and their daily usage",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&apiKeyV4{}, &apiKeyUsageV4{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&apiKeyV4{}, &apiKeyUsageV4{}})
+		},
+	},
+	{
+		Version:     5,
+		Description: "admin display preferences",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &userV5{}, "Locale", "TimeZone")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &userV5{}, "Locale", "TimeZone")
+		},
+	},
+	{
+		Version:     6,
+		Description: "user migrations",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&userMigrationV6{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&userMigrationV6{}})
+		},
+	},
+	{
+		Version:     7,
+		Description: "automation rules",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&automationRuleV7{}, &automationExecutionV7{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&automationRuleV7{}, &automationExecutionV7{}})
+		},
+	},
+	{
+		Version:     8,
+		Description: "system settings",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&systemSettingV8{}, &systemSettingChangeV8{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&systemSettingV8{}, &systemSettingChangeV8{}})
+		},
+	},
+	{
+		Version:     9,
+		Description: "node enrollments",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&nodeEnrollmentV9{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&nodeEnrollmentV9{}})
+		},
+	},
+	{
+		Version:     10,
+		Description: "agent report receipts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&agentReportReceiptV10{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&agentReportReceiptV10{}})
+		},
+	},
+	{
+		Version:     11,
+		Description: "node commands",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&nodeCommandV11{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&nodeCommandV11{}})
+		},
+	},
+	{
+		Version:     12,
+		Description: "node command speed limits",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &nodeCommandV12{}, "SpeedLimit")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &nodeCommandV12{}, "SpeedLimit")
+		},
+	},
+	{
+		Version:     13,
+		Description: "user credential rotations",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&userCredentialRotationV13{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&userCredentialRotationV13{}})
+		},
+	},
+	{
+		Version:     14,
+		Description: "user trials",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &userV14{}, "TrialStartedAt", "TrialEndsAt"); err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&userV14{}, "TrialEndsAt") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&userV14{}, "TrialEndsAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &userV14{}, "TrialStartedAt", "TrialEndsAt")
+		},
+	},
+	{
+		Version:     15,
+		Description: "user expiry",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &userV15{}, "ExpiredAt"); err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&userV15{}, "ExpiredAt") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&userV15{}, "ExpiredAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &userV15{}, "ExpiredAt")
+		},
+	},
+	{
+		Version:     16,
+		Description: "node active connections",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &nodeV16{}, "ActiveConnections")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &nodeV16{}, "ActiveConnections")
+		},
+	},
+	{
+		Version:     17,
+		Description: "subscription access logs",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&subscriptionAccessLogV17{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&subscriptionAccessLogV17{}})
+		},
+	},
+	{
+		Version:     18,
+		Description: "two-factor last step",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &userV18{}, "TwoFactorLastStep")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &userV18{}, "TwoFactorLastStep")
+		},
+	},
+}
+
+// Tenant are the migrations of the dedicated databases of tenants, which
+// only hold traffic tables
+var Tenant = []Migration{
+	{
+		Version:     1,
+		Description: "traffic tables",
+		Up:          repository.MigrateTenantDatabase,
+		Down: func(tx *gorm.DB) error {
+			return dropTables(tx, []any{&models.TrafficRecord{}, &models.TrafficSummary{}, &models.AggregationWatermark{}})
+		},
+	},
+}
+
+// dropTables drops tables in the reverse order of their creation, so that
+// the tables referencing others go first
+func dropTables(tx *gorm.DB, tables []any) error {
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(tables[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumns adds the columns of fields of a model missing from its table.
+// Databases auto-migrated before migrations existed may already hold them.
+func addColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumns drops the columns of fields of a model
+func dropColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if !tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().DropColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
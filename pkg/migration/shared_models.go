@@ -0,0 +1,291 @@
+package migration
+
+import "time"
+
+// The models of the shared migrations, frozen copies of the models of the
+// time each migration was added, so that it keeps creating the same tables
+// and columns whatever the models became since. Migrations adding columns
+// only hold those. Enumerations are plain strings and JSON columns the text
+// they are stored as.
+
+// Version 3
+
+type externalAlertV3 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Source      string `gorm:"not null;size:32;uniqueIndex:idx_external_alerts_fingerprint"`
+	Fingerprint string `gorm:"not null;size:64;uniqueIndex:idx_external_alerts_fingerprint"`
+	Name        string `gorm:"not null;size:128"`
+	Status      string `gorm:"not null;size:20;index"`
+	Severity    string `gorm:"not null;size:20"`
+	Summary     string `gorm:"size:1024"`
+	Labels      string `gorm:"type:text"`
+	NodeID      *uint  `gorm:"index"`
+	StartsAt    time.Time
+	EndsAt      *time.Time
+}
+
+func (externalAlertV3) TableName() string { return "external_alerts" }
+
+// Version 4
+
+type apiKeyV4 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID  uint   `gorm:"not null;index"`
+	Name    string `gorm:"not null;size:100"`
+	Hint    string `gorm:"not null;size:16;comment:Start of the key, shown to tell keys apart"`
+	KeyHash string `gorm:"uniqueIndex;not null;size:64;comment:SHA-256 of the key"`
+
+	DailyQuota int64   `gorm:"not null;default:0;comment:Requests per day"`
+	RateLimit  float64 `gorm:"not null;default:0;comment:Sustained requests per second"`
+	Burst      int     `gorm:"not null;default:0;comment:Requests allowed at once above the rate"`
+
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (apiKeyV4) TableName() string { return "api_keys" }
+
+type apiKeyUsageV4 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	KeyID uint      `gorm:"not null;uniqueIndex:idx_api_key_usage_day"`
+	Date  time.Time `gorm:"not null;uniqueIndex:idx_api_key_usage_day"`
+
+	Requests int64 `gorm:"not null;default:0"`
+	Errors   int64 `gorm:"not null;default:0;comment:Responses with a 5xx status"`
+	Rejected int64 `gorm:"not null;default:0;comment:Requests refused by the rate limit or the quota"`
+	BytesIn  int64 `gorm:"not null;default:0"`
+	BytesOut int64 `gorm:"not null;default:0"`
+}
+
+func (apiKeyUsageV4) TableName() string { return "api_key_usages" }
+
+// Version 5
+
+type userV5 struct {
+	Locale   string `gorm:"size:16"`
+	TimeZone string `gorm:"size:64"`
+}
+
+func (userV5) TableName() string { return "users" }
+
+// Version 6
+
+type userMigrationV6 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Kind           string `gorm:"not null;size:20"`
+	SourceID       uint   `gorm:"not null;index"`
+	TargetID       uint   `gorm:"not null"`
+	UsersPerMinute int    `gorm:"not null"`
+	Status         string `gorm:"not null;size:20;index"`
+	Operator       string `gorm:"size:100"`
+
+	Total      int64  `gorm:"not null;default:0"`
+	Moved      int64  `gorm:"not null;default:0"`
+	Failed     int64  `gorm:"not null;default:0"`
+	LastUserID uint   `gorm:"not null;default:0"`
+	LastError  string `gorm:"size:512"`
+
+	LastBatchAt *time.Time
+	CompletedAt *time.Time
+}
+
+func (userMigrationV6) TableName() string { return "user_migrations" }
+
+// Version 7
+
+type automationRuleV7 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Name        string `gorm:"not null;size:64;uniqueIndex"`
+	Description string `gorm:"size:255"`
+	EventType   string `gorm:"not null;size:32;index"`
+	Conditions  string `gorm:"type:text"`
+	Actions     string `gorm:"type:text"`
+	Enabled     bool   `gorm:"not null;default:false;index"`
+	Operator    string `gorm:"size:100"`
+
+	MatchCount    int64 `gorm:"not null;default:0"`
+	LastMatchedAt *time.Time
+}
+
+func (automationRuleV7) TableName() string { return "automation_rules" }
+
+type automationExecutionV7 struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	RuleID    uint   `gorm:"not null;index"`
+	RuleName  string `gorm:"size:64"`
+	EventType string `gorm:"size:32"`
+	Subject   string `gorm:"size:64"`
+	Results   string `gorm:"type:text"`
+	Failed    bool   `gorm:"not null;default:false"`
+}
+
+func (automationExecutionV7) TableName() string { return "automation_executions" }
+
+// Version 8
+
+type systemSettingV8 struct {
+	Key       string `gorm:"column:setting_key;primaryKey;size:64"`
+	UpdatedAt time.Time
+
+	Value     string `gorm:"not null;size:255"`
+	UpdatedBy string `gorm:"size:64"`
+}
+
+func (systemSettingV8) TableName() string { return "system_settings" }
+
+type systemSettingChangeV8 struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	Key      string `gorm:"column:setting_key;not null;size:64;index"`
+	OldValue string `gorm:"size:255"`
+	NewValue string `gorm:"size:255"`
+	Operator string `gorm:"size:100"`
+}
+
+func (systemSettingChangeV8) TableName() string { return "system_setting_changes" }
+
+// Version 9
+
+type nodeEnrollmentV9 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	TokenHash   string `gorm:"uniqueIndex;not null;size:64;comment:SHA-256 of the token"`
+	NodeName    string `gorm:"size:128;comment:Name of the node, else the name sent by the agent"`
+	Region      string `gorm:"size:64"`
+	Description string `gorm:"size:255"`
+	CreatedBy   string `gorm:"size:100"`
+
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	NodeID    *uint `gorm:"comment:Node created by the enrollment"`
+	RevokedAt *time.Time
+}
+
+func (nodeEnrollmentV9) TableName() string { return "node_enrollments" }
+
+// Version 10
+
+type agentReportReceiptV10 struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	NodeID   uint   `gorm:"not null;uniqueIndex:idx_agent_report_receipt"`
+	ReportID string `gorm:"not null;size:64;uniqueIndex:idx_agent_report_receipt"`
+}
+
+func (agentReportReceiptV10) TableName() string { return "agent_report_receipts" }
+
+// Version 11
+
+type nodeCommandV11 struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+
+	CommandID   string `gorm:"uniqueIndex;not null;size:64"`
+	NodeID      uint   `gorm:"not null;index"`
+	Type        string `gorm:"not null;size:32"`
+	UserID      string `gorm:"size:64"`
+	RequestID   string `gorm:"size:64"`
+	Status      string `gorm:"not null;size:16;index"`
+	Output      string `gorm:"type:text"`
+	DeliveredAt *time.Time
+	CompletedAt *time.Time
+}
+
+func (nodeCommandV11) TableName() string { return "node_commands" }
+
+// Version 12
+
+type nodeCommandV12 struct {
+	SpeedLimit *int64
+}
+
+func (nodeCommandV12) TableName() string { return "node_commands" }
+
+// Version 13
+
+type userCredentialRotationV13 struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID                 uint   `gorm:"not null;index"`
+	OldUUID                string `gorm:"not null;index;size:36;comment:Replaced UUID, rejected by nodes that took the new one"`
+	NewUUID                string `gorm:"not null;size:36"`
+	Operator               string `gorm:"not null;size:64;comment:Admin who regenerated the credentials"`
+	Reason                 string `gorm:"size:255"`
+	SubscriptionRotationID *uint
+
+	PushedNodeIDs string     `gorm:"type:text"`
+	PropagatedAt  *time.Time `gorm:"index"`
+}
+
+func (userCredentialRotationV13) TableName() string { return "user_credential_rotations" }
+
+// Version 14
+
+type userV14 struct {
+	TrialStartedAt *time.Time
+	TrialEndsAt    *time.Time `gorm:"index"`
+}
+
+func (userV14) TableName() string { return "users" }
+
+// Version 15
+
+type userV15 struct {
+	ExpiredAt *time.Time `gorm:"index"`
+}
+
+func (userV15) TableName() string { return "users" }
+
+// Version 16
+
+type nodeV16 struct {
+	ActiveConnections int `gorm:"not null;default:0"`
+}
+
+func (nodeV16) TableName() string { return "nodes" }
+
+// Version 17
+
+type subscriptionAccessLogV17 struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index;index:idx_subscription_access_user_time,priority:2"`
+
+	UserID        uint   `gorm:"not null;index:idx_subscription_access_user_time,priority:1"`
+	IP            string `gorm:"not null;size:45"`
+	UserAgent     string `gorm:"size:255"`
+	PreviousToken bool   `gorm:"not null;default:false"`
+}
+
+func (subscriptionAccessLogV17) TableName() string { return "subscription_access_logs" }
+
+// Version 18
+
+type userV18 struct {
+	TwoFactorLastStep int64 `gorm:"not null;default:0;comment:Time step of the last accepted TOTP code"`
+}
+
+func (userV18) TableName() string { return "users" }
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"net/http"
+	"slices"
+	"time"
+)
+
+// AdminPermission is an area of the panel an admin may manage. Super admins
+// hold every permission; managing admins, the global config and removing
+// nodes are left to them alone.
+type AdminPermission string
+
+const (
+	// AdminPermissionUsers manages users, their balances and the blocklist
+	AdminPermissionUsers AdminPermission = "users"
+	// AdminPermissionNodes manages nodes, their configs and geo data
+	AdminPermissionNodes AdminPermission = "nodes"
+	// AdminPermissionBilling manages plans, orders, coupons and referrals
+	AdminPermissionBilling AdminPermission = "billing"
+	// AdminPermissionContent manages announcements, notifications and mail
+	AdminPermissionContent AdminPermission = "content"
+	// AdminPermissionTenants manages reseller tenants
+	AdminPermissionTenants AdminPermission = "tenants"
+)
+
+// adminPermissions lists the known permissions
+var adminPermissions = []AdminPermission{
+	AdminPermissionUsers,
+	AdminPermissionNodes,
+	AdminPermissionBilling,
+	AdminPermissionContent,
+	AdminPermissionTenants,
+}
+
+// IsValid checks if the admin permission is known
+func (p AdminPermission) IsValid() bool {
+	return slices.Contains(adminPermissions, p)
+}
+
+// AdminPermissionNames returns the names of the known permissions
+func AdminPermissionNames() []string {
+	names := make([]string, len(adminPermissions))
+	for i, permission := range adminPermissions {
+		names[i] = string(permission)
+	}
+	return names
+}
+
+// HasAdminPermission checks if the user may manage an area of the panel
+func (u *User) HasAdminPermission(permission AdminPermission) bool {
+	switch u.Role {
+	case UserRoleSuperAdmin:
+		return true
+	case UserRoleAdmin:
+		return slices.Contains(u.AdminPermissions, permission)
+	}
+	return false
+}
+
+// AdminAuditLog records a change an admin made through the panel
+type AdminAuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	AdminID       uint   `json:"admin_id" gorm:"not null;index"`
+	AdminUsername string `json:"admin_username" gorm:"not null;size:64"`
+	// Method and Route identify the operation, Path holds the actual IDs
+	Method   string `json:"method" gorm:"not null;size:8"`
+	Route    string `json:"route" gorm:"not null;size:255"`
+	Path     string `json:"path" gorm:"not null;size:512"`
+	Status   int    `json:"status" gorm:"not null"`
+	ClientIP string `json:"client_ip" gorm:"size:45"`
+	// RequestID matches the entry with the logs and the response of the request
+	RequestID string `json:"request_id" gorm:"size:64;index"`
+}
+
+// TableName returns the table name for AdminAuditLog model
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
+}
+
+// ConfigReloadAuditor is the admin username of the audit entries of
+// configuration reloads, which no admin makes
+const ConfigReloadAuditor = "system"
+
+// NewConfigReloadAudit returns the audit entry of a reload of the
+// configuration of service from path, failed with err when not nil
+func NewConfigReloadAudit(service, path string, err error) *AdminAuditLog {
+	entry := &AdminAuditLog{
+		AdminUsername: ConfigReloadAuditor,
+		Method:        "RELOAD",
+		Route:         "config/" + service,
+		Path:          path,
+		Status:        http.StatusOK,
+	}
+	if err != nil {
+		entry.Status = http.StatusUnprocessableEntity
+	}
+	return entry
+}
+
+// AccessBlockedAuditor is the admin username of the audit entries of
+// requests refused by the access rules, which no admin makes
+const AccessBlockedAuditor = "access"
+
+// NewAccessBlockedAudit returns the audit entry of a request from clientIP
+// refused by an access rule, such as "admin_network" or "country/XX"
+func NewAccessBlockedAudit(method, path, clientIP, requestID, rule string) *AdminAuditLog {
+	return &AdminAuditLog{
+		AdminUsername: AccessBlockedAuditor,
+		Method:        method,
+		Route:         "blocked/" + rule,
+		Path:          path,
+		Status:        http.StatusForbidden,
+		ClientIP:      clientIP,
+		RequestID:     requestID,
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasAdminPermission(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"super admin", User{Role: UserRoleSuperAdmin}, true},
+		{"granted", User{Role: UserRoleAdmin, AdminPermissions: []AdminPermission{AdminPermissionNodes, AdminPermissionUsers}}, true},
+		{"not granted", User{Role: UserRoleAdmin, AdminPermissions: []AdminPermission{AdminPermissionNodes}}, false},
+		{"user with stray permission", User{Role: UserRoleUser, AdminPermissions: []AdminPermission{AdminPermissionUsers}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.HasAdminPermission(AdminPermissionUsers); got != tt.want {
+				t.Errorf("HasAdminPermission() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserValidateAdminPermissions(t *testing.T) {
+	valid := User{
+		Username:         "operator",
+		Email:            "operator@example.com",
+		Status:           UserStatusActive,
+		Role:             UserRoleAdmin,
+		AdminPermissions: []AdminPermission{AdminPermissionBilling},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(u *User)
+		want   []string
+	}{
+		{"valid", func(u *User) {}, nil},
+		{"super admin", func(u *User) { u.Role, u.AdminPermissions = UserRoleSuperAdmin, nil }, nil},
+		{"unknown permission", func(u *User) { u.AdminPermissions = []AdminPermission{"everything"} }, []string{"admin_permissions"}},
+		{"permissions of a user", func(u *User) { u.Role = UserRoleUser }, []string{"admin_permissions"}},
+		{"permissions of a super admin", func(u *User) { u.Role = UserRoleSuperAdmin }, []string{"admin_permissions"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := valid
+			tt.modify(&user)
+			if got := invalidFields(t, user.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// Summary types of TrafficSummary
+const (
+	SummaryTypeDaily   = "daily"
+	SummaryTypeMonthly = "monthly"
+)
+
+// TrafficSummaryWatermark names the watermark of the traffic summaries
+const TrafficSummaryWatermark = "traffic_summaries"
+
+// AggregationWatermark is the high-water mark of an incremental aggregation:
+// every record up to LastRecordID has been added to the aggregates
+type AggregationWatermark struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:64"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	LastRecordID uint `json:"last_record_id" gorm:"not null;default:0"`
+	// LastRecordAt is when the last aggregated record was created
+	LastRecordAt *time.Time `json:"last_record_at,omitempty"`
+}
+
+// TableName returns the table name for AggregationWatermark model
+func (AggregationWatermark) TableName() string {
+	return "aggregation_watermarks"
+}
+
+// SummaryCheckResult reports a consistency check of the summaries of a day
+// and of its month
+type SummaryCheckResult struct {
+	Date time.Time `json:"date"`
+	// Checked counts the summaries compared with their source data
+	Checked int `json:"checked"`
+	// Repaired counts the summaries that were missing, stale or orphaned
+	Repaired int `json:"repaired"`
+}
+
+// Subjects the traffic summaries are totalled by
+const (
+	TrafficTotalsByUser = "user"
+	TrafficTotalsByNode = "node"
+)
+
+// TrafficTotal is the traffic of a user or a node over a range of days, added
+// up from the daily summaries
+type TrafficTotal struct {
+	ID uint `json:"id"`
+	// Name is the username or the node name, empty once deleted
+	Name string `json:"name"`
+	// Address is the email of the user or the host of the node
+	Address string `json:"address"`
+
+	Upload      int64 `json:"upload"`
+	Download    int64 `json:"download"`
+	Total       int64 `json:"total"`
+	Connections int64 `json:"connections"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// AnnouncementAudience represents who an announcement is shown to
+type AnnouncementAudience string
+
+const (
+	// AnnouncementAudienceAll shows the announcement to every user
+	AnnouncementAudienceAll AnnouncementAudience = "all"
+	// AnnouncementAudiencePlans shows the announcement to users of PlanIDs
+	AnnouncementAudiencePlans AnnouncementAudience = "plans"
+)
+
+// IsValid checks if the audience is known
+func (a AnnouncementAudience) IsValid() bool {
+	return a == AnnouncementAudienceAll || a == AnnouncementAudiencePlans
+}
+
+// Length limits of announcements
+const (
+	MaxAnnouncementTitleLength   = 200
+	MaxAnnouncementContentLength = 20000
+)
+
+// Announcement is a notice shown to users during its publish window
+type Announcement struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Title    string               `json:"title" gorm:"not null;size:200"`
+	Content  string               `json:"content" gorm:"type:text"`
+	Audience AnnouncementAudience `json:"audience" gorm:"not null;size:20;default:all"`
+	PlanIDs  []uint               `json:"plan_ids,omitempty" gorm:"serializer:json;type:text;comment:Plans of the plans audience"`
+	IsPinned bool                 `json:"is_pinned" gorm:"not null;default:false;index"`
+	// Publish window, nil means unbounded
+	StartsAt  *time.Time `json:"starts_at,omitempty" gorm:"index"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" gorm:"index"`
+	CreatedBy string     `json:"created_by" gorm:"size:64"`
+}
+
+// TableName returns the table name for Announcement model
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// IsPublished reports whether the announcement is within its publish window
+func (a *Announcement) IsPublished(now time.Time) bool {
+	return (a.StartsAt == nil || !now.Before(*a.StartsAt)) && (a.EndsAt == nil || now.Before(*a.EndsAt))
+}
+
+// IsVisibleTo reports whether a user of planID belongs to the audience
+func (a *Announcement) IsVisibleTo(planID uint) bool {
+	return a.Audience != AnnouncementAudiencePlans || slices.Contains(a.PlanIDs, planID)
+}
+
+// Validate checks the announcement fields
+func (a *Announcement) Validate() error {
+	v := &validator{}
+	v.check(a.Title != "", "title", a.Title, "title is required")
+	v.check(len(a.Title) <= MaxAnnouncementTitleLength, "title", a.Title, "title is too long")
+	v.check(a.Content != "", "content", "", "content is required")
+	v.check(len(a.Content) <= MaxAnnouncementContentLength, "content", "", "content is too long")
+	v.check(a.Audience.IsValid(), "audience", string(a.Audience), "audience must be one of all, plans")
+	v.check(a.Audience != AnnouncementAudiencePlans || len(a.PlanIDs) > 0, "plan_ids", "",
+		"plan_ids are required for the plans audience")
+	v.check(a.StartsAt == nil || a.EndsAt == nil || a.StartsAt.Before(*a.EndsAt), "ends_at", "",
+		"ends_at must be after starts_at")
+	return v.err()
+}
+
+// AnnouncementRead records that a user has read an announcement
+type AnnouncementRead struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	AnnouncementID uint `json:"announcement_id" gorm:"not null;uniqueIndex:idx_announcement_reads_user"`
+	UserID         uint `json:"user_id" gorm:"not null;uniqueIndex:idx_announcement_reads_user;index"`
+}
+
+// TableName returns the table name for AnnouncementRead model
+func (AnnouncementRead) TableName() string {
+	return "announcement_reads"
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAnnouncementVisibility(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	yesterday := now.AddDate(0, 0, -1)
+	tomorrow := now.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name          string
+		announcement  Announcement
+		planID        uint
+		wantPublished bool
+		wantVisible   bool
+	}{
+		{"unbounded", Announcement{Audience: AnnouncementAudienceAll}, 1, true, true},
+		{"scheduled", Announcement{Audience: AnnouncementAudienceAll, StartsAt: &tomorrow}, 1, false, true},
+		{"ended", Announcement{Audience: AnnouncementAudienceAll, EndsAt: &yesterday}, 1, false, true},
+		{"starts now", Announcement{Audience: AnnouncementAudienceAll, StartsAt: &now, EndsAt: &tomorrow}, 1, true, true},
+		{"ends now", Announcement{Audience: AnnouncementAudienceAll, EndsAt: &now}, 1, false, true},
+		{"listed plan", Announcement{Audience: AnnouncementAudiencePlans, PlanIDs: []uint{1, 3}}, 1, true, true},
+		{"other plan", Announcement{Audience: AnnouncementAudiencePlans, PlanIDs: []uint{2, 3}}, 1, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.announcement.IsPublished(now); got != tt.wantPublished {
+				t.Errorf("IsPublished() = %v, want %v", got, tt.wantPublished)
+			}
+			if got := tt.announcement.IsVisibleTo(tt.planID); got != tt.wantVisible {
+				t.Errorf("IsVisibleTo(%d) = %v, want %v", tt.planID, got, tt.wantVisible)
+			}
+		})
+	}
+}
+
+func TestAnnouncementValidate(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	valid := Announcement{Title: "Maintenance", Content: "Nodes restart tonight", Audience: AnnouncementAudienceAll}
+
+	tests := []struct {
+		name   string
+		modify func(a *Announcement)
+		want   []string
+	}{
+		{"valid", func(a *Announcement) {}, nil},
+		{"missing title", func(a *Announcement) { a.Title = "" }, []string{"title"}},
+		{"missing content", func(a *Announcement) { a.Content = "" }, []string{"content"}},
+		{"unknown audience", func(a *Announcement) { a.Audience = "admins" }, []string{"audience"}},
+		{"plans without plan_ids", func(a *Announcement) { a.Audience = AnnouncementAudiencePlans }, []string{"plan_ids"}},
+		{"plans audience", func(a *Announcement) { a.Audience = AnnouncementAudiencePlans; a.PlanIDs = []uint{1} }, nil},
+		{"ends before start", func(a *Announcement) { a.StartsAt = &later; a.EndsAt = &now }, []string{"ends_at"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			announcement := valid
+			tt.modify(&announcement)
+			if got := invalidFields(t, announcement.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+)
+
+// MaxAPIKeysPerUser bounds the active API keys of a user
+const MaxAPIKeysPerUser = 10
+
+// APIKey authenticates an integration of a user on the REST API. Requests
+// made with a key act as its owner and are metered per key; the limits
+// override the configured defaults when set.
+type APIKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID  uint   `json:"user_id" gorm:"not null;index"`
+	Name    string `json:"name" gorm:"not null;size:100"`
+	Hint    string `json:"hint" gorm:"not null;size:16;comment:Start of the key, shown to tell keys apart"`
+	KeyHash string `json:"-" gorm:"uniqueIndex;not null;size:64;comment:SHA-256 of the key"`
+
+	// Limits, 0 applies the configured default
+	DailyQuota int64   `json:"daily_quota" gorm:"not null;default:0;comment:Requests per day"`
+	RateLimit  float64 `json:"rate_limit" gorm:"not null;default:0;comment:Sustained requests per second"`
+	Burst      int     `json:"burst" gorm:"not null;default:0;comment:Requests allowed at once above the rate"`
+
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName returns the table name for APIKey model
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsActive checks if the key has not been revoked
+func (k *APIKey) IsActive() bool {
+	return k.RevokedAt == nil
+}
+
+// APIKeyUsage is the metered usage of an API key on a day
+type APIKeyUsage struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	KeyID uint      `json:"key_id" gorm:"not null;uniqueIndex:idx_api_key_usage_day"`
+	Date  time.Time `json:"date" gorm:"not null;uniqueIndex:idx_api_key_usage_day"`
+
+	Requests int64 `json:"requests" gorm:"not null;default:0"`
+	Errors   int64 `json:"errors" gorm:"not null;default:0;comment:Responses with a 5xx status"`
+	Rejected int64 `json:"rejected" gorm:"not null;default:0;comment:Requests refused by the rate limit or the quota"`
+	BytesIn  int64 `json:"bytes_in" gorm:"not null;default:0"`
+	BytesOut int64 `json:"bytes_out" gorm:"not null;default:0"`
+}
+
+// TableName returns the table name for APIKeyUsage model
+func (APIKeyUsage) TableName() string {
+	return "api_key_usages"
+}
+
+// Add adds the counts of another usage
+func (u *APIKeyUsage) Add(other APIKeyUsage) {
+	u.Requests += other.Requests
+	u.Errors += other.Errors
+	u.Rejected += other.Rejected
+	u.BytesIn += other.BytesIn
+	u.BytesOut += other.BytesOut
+}
+
+// ErrorRate returns the share of the requests that failed
+func (u *APIKeyUsage) ErrorRate() float64 {
+	if u.Requests == 0 {
+		return 0
+	}
+	return float64(u.Errors) / float64(u.Requests)
+}
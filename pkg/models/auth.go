@@ -0,0 +1,196 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// RevokedToken represents a revoked JWT identified by its JTI
+type RevokedToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	JTI       string    `json:"jti" gorm:"uniqueIndex;not null;size:64;comment:JWT ID of the revoked token"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index;comment:Entry can be purged after the token expires"`
+}
+
+// TableName returns the table name for RevokedToken model
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// NodeToken represents a registration token that authenticates an agent as a node
+type NodeToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeID      uint   `json:"node_id" gorm:"not null;index"`
+	TokenHash   string `json:"-" gorm:"uniqueIndex;not null;size:64;comment:SHA-256 of the token"`
+	Description string `json:"description" gorm:"size:255"`
+
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName returns the table name for NodeToken model
+func (NodeToken) TableName() string {
+	return "node_tokens"
+}
+
+// IsValid checks if the token is neither revoked nor expired
+func (t *NodeToken) IsValid() bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	if t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now()) {
+		return false
+	}
+	return true
+}
+
+// SubscriptionTokenRotation records the replacement of a user's subscription
+// token. The previous token keeps working until GraceUntil so that clients can
+// pick up the new link; rows are kept afterwards as the rotation audit trail.
+type SubscriptionTokenRotation struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	OldTokenHash string    `json:"-" gorm:"not null;index;size:64;comment:SHA-256 of the replaced token"`
+	GraceUntil   time.Time `json:"grace_until" gorm:"not null;index;comment:Replaced token is honored until this time"`
+	Operator     string    `json:"operator" gorm:"not null;size:64;comment:Admin or the user who rotated the token"`
+	Reason       string    `json:"reason" gorm:"size:255"`
+}
+
+// TableName returns the table name for SubscriptionTokenRotation model
+func (SubscriptionTokenRotation) TableName() string {
+	return "subscription_token_rotations"
+}
+
+// InGracePeriod checks if the replaced token is still honored
+func (r *SubscriptionTokenRotation) InGracePeriod() bool {
+	return time.Now().Before(r.GraceUntil)
+}
+
+// UserCredentialRotation records the replacement of a user's proxy UUID,
+// which is also the password of the password based protocols. The new UUID
+// is pushed to the nodes of the user as they connect; rows are kept
+// afterwards as the credential history.
+type UserCredentialRotation struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	OldUUID  string `json:"old_uuid" gorm:"not null;index;size:36;comment:Replaced UUID, rejected by nodes that took the new one"`
+	NewUUID  string `json:"new_uuid" gorm:"not null;size:36"`
+	Operator string `json:"operator" gorm:"not null;size:64;comment:Admin who regenerated the credentials"`
+	Reason   string `json:"reason" gorm:"size:255"`
+	// SubscriptionRotationID is the subscription token rotation done along,
+	// nil when the old subscription links were kept
+	SubscriptionRotationID *uint `json:"subscription_rotation_id,omitempty"`
+
+	// PushedNodeIDs are the nodes the new UUID was pushed to
+	PushedNodeIDs []uint `json:"pushed_node_ids" gorm:"serializer:json;type:text"`
+	// PropagatedAt is set once every node of the user took the new UUID, or
+	// a later rotation superseded this one
+	PropagatedAt *time.Time `json:"propagated_at,omitempty" gorm:"index"`
+}
+
+// TableName returns the table name for UserCredentialRotation model
+func (UserCredentialRotation) TableName() string {
+	return "user_credential_rotations"
+}
+
+// Pushed reports whether the new UUID was pushed to a node
+func (r *UserCredentialRotation) Pushed(nodeID uint) bool {
+	for _, id := range r.PushedNodeIDs {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionAccessLog records a fetch of a user's subscription, by the
+// current token or one in its grace period
+type SubscriptionAccessLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index;index:idx_subscription_access_user_time,priority:2"`
+
+	UserID    uint   `json:"user_id" gorm:"not null;index:idx_subscription_access_user_time,priority:1"`
+	IP        string `json:"ip" gorm:"not null;size:45"`
+	UserAgent string `json:"user_agent" gorm:"size:255"`
+	// PreviousToken is set for fetches by a token replaced by a rotation
+	PreviousToken bool `json:"previous_token" gorm:"not null;default:false"`
+}
+
+// TableName returns the table name for SubscriptionAccessLog model
+func (SubscriptionAccessLog) TableName() string {
+	return "subscription_access_logs"
+}
+
+// maxUserAgentLength is the size of the user agent column
+const maxUserAgentLength = 255
+
+// NewSubscriptionAccessLog records a fetch, cutting long user agents
+func NewSubscriptionAccessLog(userID uint, ip, userAgent string, previousToken bool) *SubscriptionAccessLog {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+	}
+	return &SubscriptionAccessLog{
+		UserID:        userID,
+		IP:            ip,
+		UserAgent:     userAgent,
+		PreviousToken: previousToken,
+	}
+}
+
+// NodeEnrollment is a one-time token an agent exchanges on its first start for
+// a new node and its node token, so that nodes need not be created beforehand
+type NodeEnrollment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	TokenHash   string `json:"-" gorm:"uniqueIndex;not null;size:64;comment:SHA-256 of the token"`
+	NodeName    string `json:"node_name" gorm:"size:128;comment:Name of the node, else the name sent by the agent"`
+	Region      string `json:"region" gorm:"size:64"`
+	Description string `json:"description" gorm:"size:255"`
+	CreatedBy   string `json:"created_by" gorm:"size:100"`
+
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	NodeID    *uint      `json:"node_id,omitempty" gorm:"comment:Node created by the enrollment"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TableName returns the table name for NodeEnrollment model
+func (NodeEnrollment) TableName() string {
+	return "node_enrollments"
+}
+
+// Node enrollment states
+const (
+	NodeEnrollmentPending = "pending"
+	NodeEnrollmentUsed    = "used"
+	NodeEnrollmentRevoked = "revoked"
+	NodeEnrollmentExpired = "expired"
+)
+
+// State returns whether the enrollment is pending, used, revoked or expired
+func (e *NodeEnrollment) State(now time.Time) string {
+	switch {
+	case e.UsedAt != nil:
+		return NodeEnrollmentUsed
+	case e.RevokedAt != nil:
+		return NodeEnrollmentRevoked
+	case !now.Before(e.ExpiresAt):
+		return NodeEnrollmentExpired
+	default:
+		return NodeEnrollmentPending
+	}
+}
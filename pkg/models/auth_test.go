@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodeEnrollmentState(t *testing.T) {
+	now := time.Now()
+	nodeID := uint(1)
+	tests := []struct {
+		name       string
+		enrollment NodeEnrollment
+		want       string
+	}{
+		{"pending", NodeEnrollment{ExpiresAt: now.Add(time.Hour)}, NodeEnrollmentPending},
+		{"expired", NodeEnrollment{ExpiresAt: now}, NodeEnrollmentExpired},
+		{"revoked", NodeEnrollment{ExpiresAt: now.Add(time.Hour), RevokedAt: &now}, NodeEnrollmentRevoked},
+		{"used after expiry", NodeEnrollment{ExpiresAt: now.Add(-time.Hour), UsedAt: &now, NodeID: &nodeID}, NodeEnrollmentUsed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.enrollment.State(now); got != tt.want {
+				t.Errorf("State() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AutomationOperator compares an event field with the value of a condition
+type AutomationOperator string
+
+const (
+	AutomationOpEqual          AutomationOperator = "eq"
+	AutomationOpNotEqual       AutomationOperator = "ne"
+	AutomationOpGreater        AutomationOperator = "gt"
+	AutomationOpGreaterOrEqual AutomationOperator = "gte"
+	AutomationOpLess           AutomationOperator = "lt"
+	AutomationOpLessOrEqual    AutomationOperator = "lte"
+	// AutomationOpContains matches fields containing the value
+	AutomationOpContains AutomationOperator = "contains"
+	// AutomationOpIn matches fields equal to one of the comma-separated values
+	AutomationOpIn AutomationOperator = "in"
+)
+
+// IsValid checks if the operator is known
+func (o AutomationOperator) IsValid() bool {
+	switch o {
+	case AutomationOpEqual, AutomationOpNotEqual, AutomationOpGreater, AutomationOpGreaterOrEqual,
+		AutomationOpLess, AutomationOpLessOrEqual, AutomationOpContains, AutomationOpIn:
+		return true
+	}
+	return false
+}
+
+// numeric reports whether the operator compares numbers
+func (o AutomationOperator) numeric() bool {
+	switch o {
+	case AutomationOpGreater, AutomationOpGreaterOrEqual, AutomationOpLess, AutomationOpLessOrEqual:
+		return true
+	}
+	return false
+}
+
+// AutomationActionType is what an automation rule does when it matches
+type AutomationActionType string
+
+const (
+	// AutomationActionNotify sends a notification to the user of the event,
+	// or with the target param "admins" to the admins managing nodes. Params:
+	// title, message, severity and target.
+	AutomationActionNotify AutomationActionType = "notify"
+	// AutomationActionLabel sets the metadata key of the user or node of the
+	// event to value. Params: key, value and target, user or node.
+	AutomationActionLabel AutomationActionType = "label"
+	// AutomationActionSuspendUser suspends the user of the event
+	AutomationActionSuspendUser AutomationActionType = "suspend_user"
+	// AutomationActionSetNodeSort sets the sort of the node of the event,
+	// which orders it in subscriptions. Params: sort.
+	AutomationActionSetNodeSort AutomationActionType = "set_node_sort"
+)
+
+// IsValid checks if the action type is known
+func (t AutomationActionType) IsValid() bool {
+	switch t {
+	case AutomationActionNotify, AutomationActionLabel, AutomationActionSuspendUser, AutomationActionSetNodeSort:
+		return true
+	}
+	return false
+}
+
+// Subjects of automation rules, the user or node an event is about
+const (
+	AutomationSubjectUser = "user"
+	AutomationSubjectNode = "node"
+)
+
+// Limits of automation rules
+const (
+	MaxAutomationRuleNameLength = 64
+	MaxAutomationConditions     = 20
+	MaxAutomationActions        = 10
+	MaxAutomationValueLength    = 512
+)
+
+// automationFieldPattern matches the dotted field names of events, such as
+// node_status.status
+var automationFieldPattern = regexp.MustCompile(`^[a-z_]+(\.[a-z_]+)*$`)
+
+// automationTemplatePattern matches the {field} placeholders of action params
+var automationTemplatePattern = regexp.MustCompile(`\{([a-z_]+(?:\.[a-z_]+)*)\}`)
+
+// AutomationCondition compares a field of an event with a value
+type AutomationCondition struct {
+	Field    string             `json:"field"`
+	Operator AutomationOperator `json:"op"`
+	Value    string             `json:"value"`
+}
+
+// Matches reports whether the fields of an event meet the condition. A
+// missing field is empty, and numeric operators fail on fields that are not
+// numbers.
+func (c AutomationCondition) Matches(fields map[string]string) bool {
+	field := fields[c.Field]
+	switch c.Operator {
+	case AutomationOpEqual:
+		return field == c.Value
+	case AutomationOpNotEqual:
+		return field != c.Value
+	case AutomationOpContains:
+		return strings.Contains(field, c.Value)
+	case AutomationOpIn:
+		for _, value := range strings.Split(c.Value, ",") {
+			if strings.TrimSpace(value) == field {
+				return true
+			}
+		}
+		return false
+	}
+
+	got, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		return false
+	}
+	want, err := strconv.ParseFloat(c.Value, 64)
+	if err != nil {
+		return false
+	}
+	switch c.Operator {
+	case AutomationOpGreater:
+		return got > want
+	case AutomationOpGreaterOrEqual:
+		return got >= want
+	case AutomationOpLess:
+		return got < want
+	case AutomationOpLessOrEqual:
+		return got <= want
+	}
+	return false
+}
+
+// AutomationAction is an action of an automation rule with its params
+type AutomationAction struct {
+	Type   AutomationActionType `json:"type"`
+	Params map[string]string    `json:"params,omitempty"`
+}
+
+// Subject returns the subject the action changes or notifies, empty when
+// it needs none
+func (a AutomationAction) Subject() string {
+	switch a.Type {
+	case AutomationActionNotify:
+		if a.Params["target"] == "admins" {
+			return ""
+		}
+		return AutomationSubjectUser
+	case AutomationActionLabel:
+		return a.Params["target"]
+	case AutomationActionSuspendUser:
+		return AutomationSubjectUser
+	case AutomationActionSetNodeSort:
+		return AutomationSubjectNode
+	}
+	return ""
+}
+
+// Param returns a param of the action with the {field} placeholders
+// replaced by the fields of the event
+func (a AutomationAction) Param(name string, fields map[string]string) string {
+	return automationTemplatePattern.ReplaceAllStringFunc(a.Params[name], func(placeholder string) string {
+		return fields[placeholder[1:len(placeholder)-1]]
+	})
+}
+
+// AutomationEventSubjects returns the subjects the events of a type are
+// about: users for user and alert events, nodes for node events
+func AutomationEventSubjects(eventType string) []string {
+	subject, _, _ := strings.Cut(eventType, ".")
+	switch subject {
+	case "user", "alert":
+		return []string{AutomationSubjectUser}
+	case "node":
+		return []string{AutomationSubjectNode}
+	}
+	return nil
+}
+
+// AutomationRule runs its actions on each event of EventType whose fields
+// meet every condition. Rules are evaluated by the active API server as the
+// events are published.
+type AutomationRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name        string                `json:"name" gorm:"not null;size:64;uniqueIndex"`
+	Description string                `json:"description" gorm:"size:255"`
+	EventType   string                `json:"event_type" gorm:"not null;size:32;index"`
+	Conditions  []AutomationCondition `json:"conditions,omitempty" gorm:"serializer:json;type:text"`
+	Actions     []AutomationAction    `json:"actions" gorm:"serializer:json;type:text"`
+	Enabled     bool                  `json:"enabled" gorm:"not null;default:false;index"`
+	Operator    string                `json:"operator" gorm:"size:100"`
+
+	// MatchCount counts the events the rule matched
+	MatchCount    int64      `json:"match_count" gorm:"not null;default:0"`
+	LastMatchedAt *time.Time `json:"last_matched_at,omitempty"`
+}
+
+// TableName returns the table name for AutomationRule model
+func (AutomationRule) TableName() string {
+	return "automation_rules"
+}
+
+// Matches reports whether the fields of an event meet every condition
+func (r *AutomationRule) Matches(fields map[string]string) bool {
+	for _, condition := range r.Conditions {
+		if !condition.Matches(fields) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the fields of an automation rule. Actions must change or
+// notify a subject the events of the rule are about.
+func (r *AutomationRule) Validate() error {
+	v := &validator{}
+	v.check(strings.TrimSpace(r.Name) != "", "name", r.Name, "name is required")
+	v.check(len(r.Name) <= MaxAutomationRuleNameLength, "name", r.Name,
+		fmt.Sprintf("name cannot exceed %d characters", MaxAutomationRuleNameLength))
+	v.check(len(r.Description) <= 255, "description", "", "description cannot exceed 255 characters")
+	v.check(automationFieldPattern.MatchString(r.EventType) && strings.Contains(r.EventType, "."),
+		"event_type", r.EventType, "event_type must be an event type such as node.offline")
+
+	v.check(len(r.Conditions) <= MaxAutomationConditions, "conditions", "",
+		fmt.Sprintf("a rule can have at most %d conditions", MaxAutomationConditions))
+	for i, condition := range r.Conditions {
+		field := fmt.Sprintf("conditions[%d]", i)
+		v.check(automationFieldPattern.MatchString(condition.Field), field+".field", condition.Field,
+			"field must be a dotted event field such as node_status.status")
+		v.check(condition.Operator.IsValid(), field+".op", string(condition.Operator),
+			"op must be one of eq, ne, gt, gte, lt, lte, contains, in")
+		v.check(len(condition.Value) <= MaxAutomationValueLength, field+".value", "", "value is too long")
+		if condition.Operator.numeric() {
+			_, err := strconv.ParseFloat(condition.Value, 64)
+			v.check(err == nil, field+".value", condition.Value, "value must be a number")
+		}
+	}
+
+	v.check(len(r.Actions) > 0, "actions", "", "at least one action is required")
+	v.check(len(r.Actions) <= MaxAutomationActions, "actions", "",
+		fmt.Sprintf("a rule can have at most %d actions", MaxAutomationActions))
+	subjects := AutomationEventSubjects(r.EventType)
+	for i, action := range r.Actions {
+		v.validateAutomationAction(fmt.Sprintf("actions[%d]", i), action, subjects)
+	}
+	return v.err()
+}
+
+func (v *validator) validateAutomationAction(field string, action AutomationAction, subjects []string) {
+	if !action.Type.IsValid() {
+		v.check(false, field+".type", string(action.Type), "type must be one of notify, label, suspend_user, set_node_sort")
+		return
+	}
+	for name, value := range action.Params {
+		v.check(len(value) <= MaxAutomationValueLength, field+".params."+name, "", "param is too long")
+	}
+
+	params := action.Params
+	switch action.Type {
+	case AutomationActionNotify:
+		v.check(params["title"] != "", field+".params.title", "", "title is required")
+		target := params["target"]
+		v.check(target == "" || target == "user" || target == "admins", field+".params.target", target,
+			"target must be user or admins")
+		severity := params["severity"]
+		v.check(severity == "" || severity == SeverityInfo || severity == SeverityWarning || severity == SeverityCritical,
+			field+".params.severity", severity, "severity must be info, warning or critical")
+	case AutomationActionLabel:
+		v.check(params["key"] != "" && len(params["key"]) <= 64, field+".params.key", params["key"],
+			"key is required and at most 64 characters")
+		target := params["target"]
+		v.check(target == AutomationSubjectUser || target == AutomationSubjectNode, field+".params.target", target,
+			"target must be user or node")
+	case AutomationActionSetNodeSort:
+		_, err := strconv.Atoi(params["sort"])
+		v.check(err == nil, field+".params.sort", params["sort"], "sort must be an integer")
+	}
+
+	if subject := action.Subject(); subject != "" {
+		found := false
+		for _, s := range subjects {
+			found = found || s == subject
+		}
+		v.check(found, field+".type", string(action.Type),
+			fmt.Sprintf("the events of the rule are not about a %s", subject))
+	}
+}
+
+// AutomationActionResult is the outcome of an action of a matched rule
+type AutomationActionResult struct {
+	Type   AutomationActionType `json:"type"`
+	Detail string               `json:"detail,omitempty"`
+	Error  string               `json:"error,omitempty"`
+}
+
+// AutomationExecution logs a rule matching an event and the outcome of its
+// actions
+type AutomationExecution struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	RuleID    uint   `json:"rule_id" gorm:"not null;index"`
+	RuleName  string `json:"rule_name" gorm:"size:64"`
+	EventType string `json:"event_type" gorm:"size:32"`
+	// Subject is the user or node the event is about, such as node/3
+	Subject string                   `json:"subject" gorm:"size:64"`
+	Results []AutomationActionResult `json:"results" gorm:"serializer:json;type:text"`
+	// Failed marks executions with a failed action
+	Failed bool `json:"failed" gorm:"not null;default:false"`
+}
+
+// TableName returns the table name for AutomationExecution model
+func (AutomationExecution) TableName() string {
+	return "automation_executions"
+}
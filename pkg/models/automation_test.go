@@ -0,0 +1,108 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutomationConditionMatches(t *testing.T) {
+	fields := map[string]string{
+		"node_status.status":       "offline",
+		"node_status.health_score": "42.5",
+		"user.email":               "alice@example.com",
+	}
+
+	tests := []struct {
+		condition AutomationCondition
+		want      bool
+	}{
+		{AutomationCondition{"node_status.status", AutomationOpEqual, "offline"}, true},
+		{AutomationCondition{"node_status.status", AutomationOpNotEqual, "offline"}, false},
+		{AutomationCondition{"node_status.health_score", AutomationOpLess, "50"}, true},
+		{AutomationCondition{"node_status.health_score", AutomationOpGreaterOrEqual, "42.5"}, true},
+		{AutomationCondition{"node_status.health_score", AutomationOpGreater, "42.5"}, false},
+		{AutomationCondition{"user.email", AutomationOpContains, "@example.com"}, true},
+		{AutomationCondition{"node_status.status", AutomationOpIn, "degraded, offline"}, true},
+		{AutomationCondition{"node_status.status", AutomationOpIn, "degraded,maintenance"}, false},
+		// Missing fields are empty and not numbers
+		{AutomationCondition{"node_status.reason", AutomationOpEqual, ""}, true},
+		{AutomationCondition{"node_status.reason", AutomationOpLess, "1"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.condition.Matches(fields); got != tt.want {
+			t.Errorf("%+v matches = %v, want %v", tt.condition, got, tt.want)
+		}
+	}
+
+	rule := AutomationRule{Conditions: []AutomationCondition{tests[0].condition, tests[1].condition}}
+	if rule.Matches(fields) {
+		t.Error("rule matched with a condition not met")
+	}
+	if !(&AutomationRule{}).Matches(fields) {
+		t.Error("rule without conditions must match every event")
+	}
+}
+
+func TestAutomationActionParam(t *testing.T) {
+	action := AutomationAction{Type: AutomationActionNotify, Params: map[string]string{
+		"title": "Node {node_status.node_name} is {node_status.status}{missing}",
+	}}
+	fields := map[string]string{"node_status.node_name": "tokyo-1", "node_status.status": "offline"}
+
+	if got := action.Param("title", fields); got != "Node tokyo-1 is offline" {
+		t.Errorf("title = %q", got)
+	}
+}
+
+func TestAutomationRuleValidate(t *testing.T) {
+	valid := AutomationRule{
+		Name:       "Suspend abusers",
+		EventType:  "alert.raised",
+		Conditions: []AutomationCondition{{Field: "alert.type", Operator: AutomationOpEqual, Value: "quota_exceeded"}},
+		Actions: []AutomationAction{
+			{Type: AutomationActionSuspendUser},
+			{Type: AutomationActionNotify, Params: map[string]string{"title": "Suspended", "severity": SeverityCritical}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *AutomationRule)
+		want   []string
+	}{
+		{"valid", func(r *AutomationRule) {}, nil},
+		{"blank name", func(r *AutomationRule) { r.Name = "" }, []string{"name"}},
+		{"bad event type", func(r *AutomationRule) { r.EventType = "alert" }, []string{"event_type"}},
+		{"unknown operator", func(r *AutomationRule) { r.Conditions[0].Operator = "like" }, []string{"conditions[0].op"}},
+		{"numeric value", func(r *AutomationRule) {
+			r.Conditions[0].Operator = AutomationOpGreater
+		}, []string{"conditions[0].value"}},
+		{"no actions", func(r *AutomationRule) { r.Actions = nil }, []string{"actions"}},
+		{"notify without title", func(r *AutomationRule) {
+			r.Actions = []AutomationAction{{Type: AutomationActionNotify}}
+		}, []string{"actions[0].params.title"}},
+		{"node action on user events", func(r *AutomationRule) {
+			r.Actions = []AutomationAction{{Type: AutomationActionSetNodeSort, Params: map[string]string{"sort": "10"}}}
+		}, []string{"actions[0].type"}},
+		{"label without target", func(r *AutomationRule) {
+			r.Actions = []AutomationAction{{Type: AutomationActionLabel, Params: map[string]string{"key": "risk"}}}
+		}, []string{"actions[0].params.target"}},
+		{"admin notify on traffic events", func(r *AutomationRule) {
+			r.EventType = "traffic.reported"
+			r.Conditions = nil
+			r.Actions = []AutomationAction{{Type: AutomationActionNotify, Params: map[string]string{"title": "Traffic", "target": "admins"}}}
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := valid
+			rule.Conditions = append([]AutomationCondition(nil), valid.Conditions...)
+			tt.modify(&rule)
+			if got := invalidFields(t, rule.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
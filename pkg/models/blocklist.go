@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// BlocklistType is what a blocklist entry matches
+type BlocklistType string
+
+const (
+	// BlocklistTypeEmail matches one email address
+	BlocklistTypeEmail BlocklistType = "email"
+	// BlocklistTypeDomain matches the email addresses of a domain and its subdomains
+	BlocklistTypeDomain BlocklistType = "domain"
+	// BlocklistTypeCIDR matches the client IPs of a network
+	BlocklistTypeCIDR BlocklistType = "cidr"
+)
+
+// IsValid checks if the blocklist type is known
+func (t BlocklistType) IsValid() bool {
+	switch t {
+	case BlocklistTypeEmail, BlocklistTypeDomain, BlocklistTypeCIDR:
+		return true
+	}
+	return false
+}
+
+// BlocklistSourceManual is the source of entries added through the API.
+// Entries imported from a disposable domain list use the list URL.
+const BlocklistSourceManual = "manual"
+
+// MaxBlocklistReasonLength matches the size of the reason column
+const MaxBlocklistReasonLength = 255
+
+// BlocklistEntry blocks self-registration and trial activation by email,
+// email domain or client network
+type BlocklistEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Type BlocklistType `json:"type" gorm:"not null;size:16;uniqueIndex:idx_blocklist_entries_value"`
+	// Value is normalized: lowercase email or domain, canonical CIDR
+	Value    string `json:"value" gorm:"not null;size:255;uniqueIndex:idx_blocklist_entries_value"`
+	Source   string `json:"source" gorm:"not null;size:512;index"`
+	Reason   string `json:"reason" gorm:"size:255"`
+	Operator string `json:"operator" gorm:"size:64"`
+
+	// Blocked attempts
+	BlockedCount  int64      `json:"blocked_count" gorm:"not null;default:0"`
+	LastBlockedAt *time.Time `json:"last_blocked_at,omitempty"`
+}
+
+// TableName returns the table name for BlocklistEntry model
+func (BlocklistEntry) TableName() string {
+	return "blocklist_entries"
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+)
+
+// NodeCostCategory represents what a node cost is paid for
+type NodeCostCategory string
+
+const (
+	NodeCostCategoryServer    NodeCostCategory = "server"
+	NodeCostCategoryBandwidth NodeCostCategory = "bandwidth"
+	NodeCostCategoryEnergy    NodeCostCategory = "energy"
+	NodeCostCategoryOther     NodeCostCategory = "other"
+)
+
+// IsValid checks if the category is known
+func (c NodeCostCategory) IsValid() bool {
+	switch c {
+	case NodeCostCategoryServer, NodeCostCategoryBandwidth, NodeCostCategoryEnergy, NodeCostCategoryOther:
+		return true
+	}
+	return false
+}
+
+// NodeCost records what a node cost in one month for one category
+type NodeCost struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeID   uint             `json:"node_id" gorm:"not null;uniqueIndex:idx_node_cost_month"`
+	Month    time.Time        `json:"month" gorm:"not null;uniqueIndex:idx_node_cost_month;index;comment:First day of the billed month"`
+	Category NodeCostCategory `json:"category" gorm:"not null;size:20;uniqueIndex:idx_node_cost_month"`
+	Amount   int64            `json:"amount" gorm:"not null;default:0;comment:Amount in cents"`
+	Currency string           `json:"currency" gorm:"not null;default:'USD';size:3"`
+	Notes    string           `json:"notes" gorm:"size:255"`
+}
+
+// TableName returns the table name for NodeCost model
+func (NodeCost) TableName() string {
+	return "node_costs"
+}
+
+// NodeUsage is the traffic a node delivered and the users it served in a period
+type NodeUsage struct {
+	NodeID       uint  `json:"node_id"`
+	TotalTraffic int64 `json:"total_traffic"`
+	ActiveUsers  int64 `json:"active_users"`
+}
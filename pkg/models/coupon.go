@@ -0,0 +1,166 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CouponType represents how a coupon discounts an order
+type CouponType string
+
+const (
+	// CouponTypePercentage takes Value percent off the order
+	CouponTypePercentage CouponType = "percentage"
+	// CouponTypeFixed takes Value cents off the order, in the coupon's currency
+	CouponTypeFixed CouponType = "fixed"
+)
+
+// IsValid checks if the coupon type is known
+func (t CouponType) IsValid() bool {
+	return t == CouponTypePercentage || t == CouponTypeFixed
+}
+
+// Reasons a coupon cannot be applied to an order
+var (
+	ErrCouponDisabled     = errors.New("coupon is disabled")
+	ErrCouponNotStarted   = errors.New("coupon is not valid yet")
+	ErrCouponExpired      = errors.New("coupon has expired")
+	ErrCouponExhausted    = errors.New("coupon usage limit reached")
+	ErrCouponUserLimit    = errors.New("coupon already used the maximum number of times by this user")
+	ErrCouponPlan         = errors.New("coupon does not apply to this plan")
+	ErrCouponCurrency     = errors.New("coupon does not apply to orders in this currency")
+	ErrCouponMinimumTotal = errors.New("order total is below the coupon minimum")
+)
+
+// Coupon is a discount code redeemable when ordering a plan
+type Coupon struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Code is stored upper case and matched case-insensitively
+	Code        string     `json:"code" gorm:"uniqueIndex;not null;size:32"`
+	Description string     `json:"description" gorm:"size:255"`
+	Type        CouponType `json:"type" gorm:"not null;size:20"`
+	// Value is a percentage for percentage coupons and cents for fixed ones
+	Value    int64  `json:"value" gorm:"not null;default:0"`
+	Currency string `json:"currency" gorm:"size:3;comment:Currency of fixed coupons"`
+
+	// Restrictions, zero values mean no restriction
+	MaxUses        int        `json:"max_uses" gorm:"not null;default:0;comment:Total redemptions allowed, 0 = unlimited"`
+	MaxUsesPerUser int        `json:"max_uses_per_user" gorm:"not null;default:0;comment:Redemptions allowed per user, 0 = unlimited"`
+	MinimumTotal   int64      `json:"minimum_total" gorm:"not null;default:0;comment:Minimum order total in cents before the discount"`
+	PlanIDs        []uint     `json:"plan_ids,omitempty" gorm:"serializer:json;type:text;comment:Plans the coupon applies to, empty = all"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	IsEnabled      bool       `json:"is_enabled" gorm:"not null;default:true"`
+	UsedCount      int        `json:"used_count" gorm:"not null;default:0;comment:Redemptions by pending and paid orders"`
+}
+
+// TableName returns the table name for Coupon model
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// NormalizeCouponCode returns the stored form of a coupon code
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Check reports why the coupon cannot discount an order of planID with the
+// given total and currency, or nil if it can. Per-user limits need the
+// redemption history and are checked by the repository.
+func (c *Coupon) Check(planID uint, total int64, currency string, now time.Time) error {
+	switch {
+	case !c.IsEnabled:
+		return ErrCouponDisabled
+	case c.StartsAt != nil && now.Before(*c.StartsAt):
+		return ErrCouponNotStarted
+	case c.ExpiresAt != nil && !now.Before(*c.ExpiresAt):
+		return ErrCouponExpired
+	case c.MaxUses > 0 && c.UsedCount >= c.MaxUses:
+		return ErrCouponExhausted
+	case len(c.PlanIDs) > 0 && !slices.Contains(c.PlanIDs, planID):
+		return ErrCouponPlan
+	case c.Type == CouponTypeFixed && c.Currency != currency:
+		return ErrCouponCurrency
+	case total < c.MinimumTotal:
+		return ErrCouponMinimumTotal
+	}
+	return nil
+}
+
+// Discount returns the amount the coupon takes off total, never more than total
+func (c *Coupon) Discount(total int64) int64 {
+	var discount int64
+	switch c.Type {
+	case CouponTypePercentage:
+		discount = total * c.Value / 100
+	case CouponTypeFixed:
+		discount = c.Value
+	}
+	return max(0, min(discount, total))
+}
+
+// Validate checks the coupon fields
+func (c *Coupon) Validate() error {
+	v := &validator{}
+	v.check(c.Code != "", "code", c.Code, "code is required")
+	v.check(len(c.Code) <= 32 && (c.Code == "" || tagPattern.MatchString(c.Code)), "code", c.Code,
+		"code must be up to 32 letters, digits, '-' or '_'")
+	v.check(len(c.Description) <= 255, "description", c.Description, "description is too long")
+	v.check(c.Type.IsValid(), "type", string(c.Type), "type must be one of percentage, fixed")
+	switch c.Type {
+	case CouponTypePercentage:
+		v.check(c.Value >= 1 && c.Value <= 100, "value", fmt.Sprint(c.Value), "percentage must be between 1 and 100")
+	case CouponTypeFixed:
+		v.check(c.Value > 0, "value", fmt.Sprint(c.Value), "value must be positive")
+		v.check(currencyPattern.MatchString(c.Currency), "currency", c.Currency, "currency must be a 3-letter ISO 4217 code")
+	}
+	v.check(c.MaxUses >= 0, "max_uses", fmt.Sprint(c.MaxUses), "max_uses cannot be negative")
+	v.check(c.MaxUsesPerUser >= 0, "max_uses_per_user", fmt.Sprint(c.MaxUsesPerUser), "max_uses_per_user cannot be negative")
+	v.check(c.MinimumTotal >= 0, "minimum_total", fmt.Sprint(c.MinimumTotal), "minimum_total cannot be negative")
+	v.check(c.StartsAt == nil || c.ExpiresAt == nil || c.StartsAt.Before(*c.ExpiresAt), "expires_at", "",
+		"expires_at must be after starts_at")
+	return v.err()
+}
+
+// CouponRedemption records the use of a coupon by an order. Redemptions of
+// cancelled orders are removed so that they do not count against limits.
+type CouponRedemption struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	CouponID uint   `json:"coupon_id" gorm:"not null;index"`
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	OrderID  uint   `json:"order_id" gorm:"not null;uniqueIndex"`
+	Discount int64  `json:"discount" gorm:"not null;default:0;comment:Discount in cents"`
+	Currency string `json:"currency" gorm:"not null;size:3"`
+}
+
+// TableName returns the table name for CouponRedemption model
+func (CouponRedemption) TableName() string {
+	return "coupon_redemptions"
+}
+
+// CouponStatistics summarizes the redemptions of a coupon, or of all coupons
+type CouponStatistics struct {
+	// Redemptions counts the orders using the coupon that were not cancelled
+	Redemptions int64 `json:"redemptions"`
+	PaidOrders  int64 `json:"paid_orders"`
+	UniqueUsers int64 `json:"unique_users"`
+	// Amounts of paid orders, per currency, in cents
+	Currencies []CouponCurrencyStatistics `json:"currencies"`
+}
+
+// CouponCurrencyStatistics is the revenue impact of paid coupon orders in one currency
+type CouponCurrencyStatistics struct {
+	Currency string `json:"currency"`
+	// Discount is the revenue given up through the coupon
+	Discount int64 `json:"discount"`
+	// Revenue is what was paid for orders using the coupon
+	Revenue int64 `json:"revenue"`
+}
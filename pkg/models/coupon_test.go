@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCouponCheck(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	yesterday := now.AddDate(0, 0, -1)
+	tomorrow := now.AddDate(0, 0, 1)
+
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		planID   uint
+		total    int64
+		currency string
+		want     error
+	}{
+		{"percentage", Coupon{Type: CouponTypePercentage, Value: 10, IsEnabled: true}, 1, 1000, "USD", nil},
+		{"disabled", Coupon{Type: CouponTypePercentage, Value: 10}, 1, 1000, "USD", ErrCouponDisabled},
+		{"not started", Coupon{Type: CouponTypePercentage, Value: 10, IsEnabled: true, StartsAt: &tomorrow},
+			1, 1000, "USD", ErrCouponNotStarted},
+		{"expired", Coupon{Type: CouponTypePercentage, Value: 10, IsEnabled: true, ExpiresAt: &yesterday},
+			1, 1000, "USD", ErrCouponExpired},
+		{"within window", Coupon{Type: CouponTypePercentage, Value: 10, IsEnabled: true, StartsAt: &yesterday, ExpiresAt: &tomorrow},
+			1, 1000, "USD", nil},
+		{"exhausted", Coupon{Type: CouponTypePercentage, Value: 10, IsEnabled: true, MaxUses: 5, UsedCount: 5},
+			1, 1000, "USD", ErrCouponExhausted},
+		{"other plan", Coupon{Type: CouponTypePercentage, Value: 10, IsEnabled: true, PlanIDs: []uint{2, 3}},
+			1, 1000, "USD", ErrCouponPlan},
+		{"listed plan", Coupon{Type: CouponTypePercentage, Value: 10, IsEnabled: true, PlanIDs: []uint{1, 3}},
+			1, 1000, "USD", nil},
+		{"fixed other currency", Coupon{Type: CouponTypeFixed, Value: 500, Currency: "EUR", IsEnabled: true},
+			1, 1000, "USD", ErrCouponCurrency},
+		{"below minimum", Coupon{Type: CouponTypeFixed, Value: 500, Currency: "USD", IsEnabled: true, MinimumTotal: 2000},
+			1, 1000, "USD", ErrCouponMinimumTotal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.coupon.Check(tt.planID, tt.total, tt.currency, now); !errors.Is(err, tt.want) {
+				t.Errorf("Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCouponDiscount(t *testing.T) {
+	tests := []struct {
+		name   string
+		coupon Coupon
+		total  int64
+		want   int64
+	}{
+		{"percentage", Coupon{Type: CouponTypePercentage, Value: 15}, 1999, 299},
+		{"full percentage", Coupon{Type: CouponTypePercentage, Value: 100}, 1999, 1999},
+		{"fixed", Coupon{Type: CouponTypeFixed, Value: 500}, 1999, 500},
+		{"fixed above total", Coupon{Type: CouponTypeFixed, Value: 5000}, 1999, 1999},
+		{"free order", Coupon{Type: CouponTypeFixed, Value: 500}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.coupon.Discount(tt.total); got != tt.want {
+				t.Errorf("Discount(%d) = %d, want %d", tt.total, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// Dialector returns the GORM dialector of the driver of a database
+func Dialector(config configv1.DatabaseConfig) (gorm.Dialector, error) {
+	switch config.Driver {
+	case "mysql":
+		return mysql.Open(MySQLDSN(config)), nil
+	case "postgres":
+		return postgres.Open(PostgresDSN(config)), nil
+	case "sqlite":
+		dsn, err := SQLiteDSN(config)
+		if err != nil {
+			return nil, err
+		}
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", config.Driver)
+	}
+}
+
+// MySQLDSN builds the DSN of a MySQL database
+func MySQLDSN(config configv1.DatabaseConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		config.Username,
+		config.Password,
+		config.Host,
+		config.Port,
+		config.Database,
+	)
+}
+
+// PostgresDSN builds the keyword/value DSN of a PostgreSQL database, quoting
+// the values so passwords may hold spaces and quotes
+func PostgresDSN(config configv1.DatabaseConfig) string {
+	sslMode := config.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s TimeZone=Local",
+		quotePostgres(config.Host),
+		config.Port,
+		quotePostgres(config.Username),
+		quotePostgres(config.Password),
+		quotePostgres(config.Database),
+		quotePostgres(sslMode),
+	)
+}
+
+// quotePostgres quotes a value of a keyword/value DSN
+func quotePostgres(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+// SQLiteDSN builds the DSN of a file-based SQLite database. The directory of
+// the file is created, and unless the database already carries options, the
+// connections wait on locks instead of failing and the journal is written
+// ahead so readers do not block the writer.
+func SQLiteDSN(config configv1.DatabaseConfig) (string, error) {
+	dsn := config.Database
+	if dsn == ":memory:" || strings.HasPrefix(dsn, "file:") {
+		return dsn, nil
+	}
+
+	if dir := filepath.Dir(dsn); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_busy_timeout=5000&_journal_mode=WAL"
+	}
+	return dsn, nil
+}
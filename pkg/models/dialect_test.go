@@ -0,0 +1,44 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	dsn := PostgresDSN(configv1.DatabaseConfig{
+		Host:     "db.internal",
+		Port:     5432,
+		Database: "sing-box",
+		Username: "sing-box",
+		Password: `it's secret`,
+	})
+	want := `host='db.internal' port=5432 user='sing-box' password='it\'s secret' dbname='sing-box' sslmode='disable' TimeZone=Local`
+	if dsn != want {
+		t.Errorf("PostgresDSN = %s, want %s", dsn, want)
+	}
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "sing-box.db")
+	dsn, err := SQLiteDSN(configv1.DatabaseConfig{Database: path})
+	if err != nil {
+		t.Fatalf("SQLiteDSN failed: %v", err)
+	}
+	if dsn != path+"?_busy_timeout=5000&_journal_mode=WAL" {
+		t.Errorf("SQLiteDSN = %s", dsn)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); err != nil {
+		t.Errorf("database directory not created: %v", err)
+	}
+
+	// DSNs carrying options and in-memory databases are kept as is
+	for _, database := range []string{path + "?_txlock=immediate", ":memory:", "file::memory:?cache=shared"} {
+		if dsn, err := SQLiteDSN(configv1.DatabaseConfig{Database: database}); err != nil || dsn != database {
+			t.Errorf("SQLiteDSN(%s) = %s, %v", database, dsn, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ExpiryWarning returns the warning offset reached by the user at now, the
+// smallest of offsets not shorter than the time left until the account
+// expires. Accounts without an expiry, or already expired, are not warned.
+func (u *User) ExpiryWarning(now time.Time, offsets []time.Duration) (time.Duration, bool) {
+	if u.ExpiresAt == nil || !u.ExpiresAt.After(now) {
+		return 0, false
+	}
+	left := u.ExpiresAt.Sub(now)
+
+	var warning time.Duration
+	found := false
+	for _, offset := range offsets {
+		if offset >= left && (!found || offset < warning) {
+			warning, found = offset, true
+		}
+	}
+	return warning, found
+}
+
+// ExpiredOn reports whether the plan of an active user ran out at now
+func (u *User) ExpiredOn(now time.Time) bool {
+	return u.Status == UserStatusActive && u.ExpiresAt != nil && !u.ExpiresAt.After(now)
+}
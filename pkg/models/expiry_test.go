@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserExpiryWarning(t *testing.T) {
+	now := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 72 * time.Hour}
+
+	tests := []struct {
+		name    string
+		left    time.Duration
+		want    time.Duration
+		warning bool
+	}{
+		{"beyond every offset", 8 * 24 * time.Hour, 0, false},
+		{"within a week", 5 * 24 * time.Hour, 7 * 24 * time.Hour, true},
+		{"within three days", 72 * time.Hour, 72 * time.Hour, true},
+		{"within a day", time.Hour, 24 * time.Hour, true},
+		{"expired", -time.Hour, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expires := now.Add(tt.left)
+			user := &User{Status: UserStatusActive, ExpiresAt: &expires}
+			got, ok := user.ExpiryWarning(now, offsets)
+			if got != tt.want || ok != tt.warning {
+				t.Errorf("ExpiryWarning() = %v, %v, want %v, %v", got, ok, tt.want, tt.warning)
+			}
+			if user.ExpiredOn(now) != (tt.left < 0) {
+				t.Errorf("ExpiredOn() = %v", user.ExpiredOn(now))
+			}
+		})
+	}
+
+	if _, ok := (&User{}).ExpiryWarning(now, offsets); ok {
+		t.Error("account without expiry warned")
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ExternalAlertStatus represents the state of an external alert
+type ExternalAlertStatus string
+
+const (
+	ExternalAlertStatusFiring   ExternalAlertStatus = "firing"
+	ExternalAlertStatusResolved ExternalAlertStatus = "resolved"
+)
+
+// ExternalAlertSourceAlertmanager is the source of alerts posted by
+// Prometheus Alertmanager
+const ExternalAlertSourceAlertmanager = "alertmanager"
+
+// ExternalAlert is an infrastructure alert received from a monitoring
+// system, such as a full disk or a host down. A notification about the same
+// alert updates it, identified by its fingerprint.
+type ExternalAlert struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Source      string              `json:"source" gorm:"not null;size:32;uniqueIndex:idx_external_alerts_fingerprint"`
+	Fingerprint string              `json:"fingerprint" gorm:"not null;size:64;uniqueIndex:idx_external_alerts_fingerprint"`
+	Name        string              `json:"name" gorm:"not null;size:128"`
+	Status      ExternalAlertStatus `json:"status" gorm:"not null;size:20;index"`
+	Severity    string              `json:"severity" gorm:"not null;size:20"`
+	Summary     string              `json:"summary" gorm:"size:1024"`
+	// Labels holds the labels of the alert as a JSON object
+	Labels string `json:"labels" gorm:"type:text"`
+	// NodeID is the node the alert is about, nil when no node matched its labels
+	NodeID   *uint      `json:"node_id,omitempty" gorm:"index"`
+	StartsAt time.Time  `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+}
+
+// TableName returns the table name for ExternalAlert model
+func (ExternalAlert) TableName() string {
+	return "external_alerts"
+}
+
+// IsFiring reports whether the alert is still firing at now. Firing alerts
+// past their end were resolved without a notification reaching the panel.
+func (a *ExternalAlert) IsFiring(now time.Time) bool {
+	return a.Status == ExternalAlertStatusFiring && (a.EndsAt == nil || a.EndsAt.After(now))
+}
+
+// ExternalAlertSeverity maps the severity label of an external alert onto
+// the panel's severities, defaulting to warning
+func ExternalAlertSeverity(label string) string {
+	switch strings.ToLower(label) {
+	case "critical", "error", "page", "high":
+		return SeverityCritical
+	case "info", "informational", "none", "low":
+		return SeverityInfo
+	}
+	return SeverityWarning
+}
+
+// ExternalAlertFingerprint derives a fingerprint from the labels of an alert
+// for sources that do not send one
+func ExternalAlertFingerprint(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write([]byte(labels[name]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// ExternalAlertNodeKeys returns the values of the node labels an alert
+// carries, in label order and without ports, to match against node names
+// and hosts
+func ExternalAlertNodeKeys(labels map[string]string, nodeLabels []string) []string {
+	var keys []string
+	for _, name := range nodeLabels {
+		value := strings.TrimSpace(labels[name])
+		if value == "" {
+			continue
+		}
+		if host, _, err := net.SplitHostPort(value); err == nil {
+			value = host
+		}
+		if !slices.Contains(keys, value) {
+			keys = append(keys, value)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExternalAlertNodeKeys(t *testing.T) {
+	nodeLabels := []string{"node", "instance", "host"}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []string
+	}{
+		{"no node label", map[string]string{"job": "node"}, nil},
+		{"port stripped", map[string]string{"instance": "hk-1.example.com:9100"}, []string{"hk-1.example.com"}},
+		{"IPv6 instance", map[string]string{"instance": "[2001:db8::1]:9100"}, []string{"2001:db8::1"}},
+		{"label order", map[string]string{"host": "10.0.0.1", "node": "hk-1"}, []string{"hk-1", "10.0.0.1"}},
+		{"duplicates", map[string]string{"node": "hk-1", "instance": "hk-1:9100"}, []string{"hk-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExternalAlertNodeKeys(tt.labels, nodeLabels); !slices.Equal(got, tt.want) {
+				t.Errorf("ExternalAlertNodeKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalAlertFingerprint(t *testing.T) {
+	a := ExternalAlertFingerprint(map[string]string{"alertname": "HostDown", "instance": "hk-1:9100"})
+	b := ExternalAlertFingerprint(map[string]string{"instance": "hk-1:9100", "alertname": "HostDown"})
+	c := ExternalAlertFingerprint(map[string]string{"alertname": "HostDown", "instance": "hk-2:9100"})
+	if a != b {
+		t.Errorf("fingerprint depends on label order: %s != %s", a, b)
+	}
+	if a == c {
+		t.Errorf("different labels share fingerprint %s", a)
+	}
+}
+
+func TestExternalAlertIsFiring(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	tests := []struct {
+		name  string
+		alert ExternalAlert
+		want  bool
+	}{
+		{"firing", ExternalAlert{Status: ExternalAlertStatusFiring}, true},
+		{"firing until later", ExternalAlert{Status: ExternalAlertStatusFiring, EndsAt: &future}, true},
+		{"firing past its end", ExternalAlert{Status: ExternalAlertStatusFiring, EndsAt: &past}, false},
+		{"resolved", ExternalAlert{Status: ExternalAlertStatusResolved, EndsAt: &past}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.alert.IsFiring(now); got != tt.want {
+				t.Errorf("IsFiring() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
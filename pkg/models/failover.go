@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+// NodeFailoverStatus represents the state of a user failover
+type NodeFailoverStatus string
+
+const (
+	NodeFailoverStatusActive   NodeFailoverStatus = "active"
+	NodeFailoverStatusReverted NodeFailoverStatus = "reverted"
+)
+
+// NodeFailover records a user moved off an offline node. The user's
+// assignment to FromNodeID is disabled and one to ToNodeID added until the
+// node recovers. A user moved again while failed over keeps one failover,
+// pointing at the latest node.
+type NodeFailover struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint               `json:"user_id" gorm:"not null;index"`
+	FromNodeID uint               `json:"from_node_id" gorm:"not null;index"`
+	ToNodeID   uint               `json:"to_node_id" gorm:"not null;index"`
+	Status     NodeFailoverStatus `json:"status" gorm:"not null;size:20;index"`
+	Reason     string             `json:"reason" gorm:"size:255"`
+	RevertedAt *time.Time         `json:"reverted_at,omitempty"`
+
+	// Relationships
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	ToNode Node `json:"to_node,omitempty" gorm:"foreignKey:ToNodeID"`
+}
+
+// TableName returns the table name for NodeFailover model
+func (NodeFailover) TableName() string {
+	return "node_failovers"
+}
+
+// IsActive reports whether the user is still failed over
+func (f *NodeFailover) IsActive() bool {
+	return f.Status == NodeFailoverStatusActive
+}
+
+// FailoverCandidate is a node users of an offline node can be moved to
+type FailoverCandidate struct {
+	Node *Node
+	// Assigned counts the users assigned to the node
+	Assigned int64
+}
+
+// HasCapacity reports whether the node can take another user
+func (c *FailoverCandidate) HasCapacity() bool {
+	return c.Node.MaxUsers <= 0 || c.Assigned < int64(c.Node.MaxUsers)
+}
+
+// PickFailoverCandidate returns the candidate to move a user to: among the
+// nodes the user may use that have room, online nodes before degraded ones,
+// then the one with the fewest users, in candidate order on ties. It returns
+// nil when none fits.
+func PickFailoverCandidate(candidates []*FailoverCandidate, allowed func(nodeID uint) bool) *FailoverCandidate {
+	var best *FailoverCandidate
+	for _, candidate := range candidates {
+		if !candidate.HasCapacity() || !allowed(candidate.Node.ID) {
+			continue
+		}
+		if best == nil || candidate.preferredOver(best) {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// preferredOver reports whether the candidate is preferred over other
+func (c *FailoverCandidate) preferredOver(other *FailoverCandidate) bool {
+	online, otherOnline := c.Node.Status == NodeStatusOnline, other.Node.Status == NodeStatusOnline
+	if online != otherOnline {
+		return online
+	}
+	return c.Assigned < other.Assigned
+}
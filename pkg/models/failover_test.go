@@ -0,0 +1,57 @@
+package models
+
+import "testing"
+
+func TestPickFailoverCandidate(t *testing.T) {
+	candidate := func(id uint, status NodeStatus, maxUsers int, assigned int64) *FailoverCandidate {
+		return &FailoverCandidate{Node: &Node{ID: id, Status: status, MaxUsers: maxUsers}, Assigned: assigned}
+	}
+	all := func(uint) bool { return true }
+
+	tests := []struct {
+		name       string
+		candidates []*FailoverCandidate
+		allowed    func(uint) bool
+		want       uint
+	}{
+		{"none", nil, all, 0},
+		{
+			"fewest users",
+			[]*FailoverCandidate{candidate(1, NodeStatusOnline, 0, 5), candidate(2, NodeStatusOnline, 0, 2), candidate(3, NodeStatusOnline, 0, 2)},
+			all,
+			2,
+		},
+		{
+			"online before degraded",
+			[]*FailoverCandidate{candidate(1, NodeStatusDegraded, 0, 0), candidate(2, NodeStatusOnline, 0, 9)},
+			all,
+			2,
+		},
+		{
+			"degraded when no online node fits",
+			[]*FailoverCandidate{candidate(1, NodeStatusDegraded, 0, 3), candidate(2, NodeStatusOnline, 4, 4)},
+			all,
+			1,
+		},
+		{
+			"not granted",
+			[]*FailoverCandidate{candidate(1, NodeStatusOnline, 0, 0), candidate(2, NodeStatusOnline, 0, 7)},
+			func(id uint) bool { return id != 1 },
+			2,
+		},
+		{"full", []*FailoverCandidate{candidate(1, NodeStatusOnline, 2, 2)}, all, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PickFailoverCandidate(tt.candidates, tt.allowed)
+			var id uint
+			if got != nil {
+				id = got.Node.ID
+			}
+			if id != tt.want {
+				t.Errorf("picked node %d, want %d", id, tt.want)
+			}
+		})
+	}
+}
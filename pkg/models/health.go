@@ -0,0 +1,151 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NodeHealthInput is what a node's health is judged on over a window
+type NodeHealthInput struct {
+	// HeartbeatAge is the time since the node was last heard from
+	HeartbeatAge time.Duration
+	// CPUUsage and MemoryUsage are percentages (0-100)
+	CPUUsage    float64
+	MemoryUsage float64
+	// Heartbeats counts the heartbeats of the window, ErrorHeartbeats those
+	// reporting an error status
+	Heartbeats      int64
+	ErrorHeartbeats int64
+	// ProbeSamples and ProbeSuccesses count the probes of the window
+	ProbeSamples   int64
+	ProbeSuccesses int64
+}
+
+// NodeHealthPolicy defines how the health of a node is scored
+type NodeHealthPolicy struct {
+	// The heartbeat score drops from 1 after HeartbeatInterval to 0 at HeartbeatTimeout
+	HeartbeatInterval time.Duration
+	HeartbeatTimeout  time.Duration
+	// LoadThreshold is the CPU or memory usage percentage above which the
+	// load score drops, reaching 0 at 100%
+	LoadThreshold float64
+	// MinProbeSamples is the number of probes needed to score packet loss
+	MinProbeSamples int64
+
+	// Weights of the heartbeat, load, error rate and packet loss scores
+	HeartbeatWeight  float64
+	LoadWeight       float64
+	ErrorWeight      float64
+	PacketLossWeight float64
+}
+
+// NodeHealth is the health of a node, Score ranging from 0 to 100. Reasons
+// describe what lowered the score.
+type NodeHealth struct {
+	Score   float64
+	Reasons []string
+}
+
+// Evaluate scores the health of a node. Error rate and packet loss are left
+// out of the score without heartbeats or enough probes over the window.
+func (in NodeHealthInput) Evaluate(p NodeHealthPolicy) NodeHealth {
+	var health NodeHealth
+	var total, weights float64
+	add := func(weight, score float64, reason string) {
+		total += weight * score
+		weights += weight
+		if score < 1 && weight > 0 {
+			health.Reasons = append(health.Reasons, reason)
+		}
+	}
+
+	heartbeat := 1.0
+	if in.HeartbeatAge >= p.HeartbeatTimeout {
+		heartbeat = 0
+	} else if in.HeartbeatAge > p.HeartbeatInterval {
+		heartbeat = 1 - float64(in.HeartbeatAge-p.HeartbeatInterval)/float64(p.HeartbeatTimeout-p.HeartbeatInterval)
+	}
+	add(p.HeartbeatWeight, heartbeat, fmt.Sprintf("last heartbeat %s ago", in.HeartbeatAge.Round(time.Second)))
+
+	resource, usage := "cpu", in.CPUUsage
+	if in.MemoryUsage > usage {
+		resource, usage = "memory", in.MemoryUsage
+	}
+	load := 1.0
+	if usage > p.LoadThreshold {
+		load = max(0, 1-(usage-p.LoadThreshold)/(100-p.LoadThreshold))
+	}
+	add(p.LoadWeight, load, fmt.Sprintf("%s usage at %.0f%%", resource, usage))
+
+	if in.Heartbeats > 0 {
+		errorRate := float64(in.ErrorHeartbeats) / float64(in.Heartbeats)
+		add(p.ErrorWeight, 1-errorRate, fmt.Sprintf("%.0f%% of heartbeats reported errors", errorRate*100))
+	}
+
+	if in.ProbeSamples > 0 && in.ProbeSamples >= p.MinProbeSamples {
+		loss := 1 - float64(in.ProbeSuccesses)/float64(in.ProbeSamples)
+		add(p.PacketLossWeight, 1-loss, fmt.Sprintf("%.0f%% packet loss", loss*100))
+	}
+
+	if weights > 0 {
+		health.Score = total / weights * 100
+	}
+	return health
+}
+
+// Reason returns the reasons joined for a status transition
+func (h NodeHealth) Reason() string {
+	if len(h.Reasons) == 0 {
+		return fmt.Sprintf("health score %.0f", h.Score)
+	}
+	return fmt.Sprintf("health score %.0f: %s", h.Score, strings.Join(h.Reasons, ", "))
+}
+
+// NodeHealthThresholds define the health scores moving a node between
+// online, degraded and maintenance. A node recovers once its score reaches
+// RecoverAbove, above DegradedBelow so that it does not flap.
+type NodeHealthThresholds struct {
+	DegradedBelow    float64
+	MaintenanceBelow float64
+	RecoverAbove     float64
+}
+
+// NextStatus returns the status a node in status current moves to at this
+// health. Only statuses set by the health checks change: offline and
+// disabled nodes, and maintenance set otherwise, are kept.
+func (h NodeHealth) NextStatus(current NodeStatus, automatic bool, t NodeHealthThresholds) NodeStatus {
+	switch {
+	case current == NodeStatusMaintenance && !automatic:
+		return current
+	case current != NodeStatusOnline && current != NodeStatusDegraded && current != NodeStatusMaintenance:
+		return current
+	case h.Score < t.MaintenanceBelow:
+		return NodeStatusMaintenance
+	case h.Score >= t.RecoverAbove:
+		return NodeStatusOnline
+	case h.Score < t.DegradedBelow || current != NodeStatusOnline:
+		return NodeStatusDegraded
+	}
+	return current
+}
+
+// NodeStatusTransition records a change of a node's status and why
+type NodeStatusTransition struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NodeID uint       `json:"node_id" gorm:"not null;index"`
+	From   NodeStatus `json:"from" gorm:"not null;size:20"`
+	To     NodeStatus `json:"to" gorm:"not null;size:20"`
+	Reason string     `json:"reason" gorm:"size:512"`
+	// HealthScore is the node's score when the transition was made
+	HealthScore float64 `json:"health_score" gorm:"not null;default:0"`
+	// Automatic is set for transitions made by the health checks
+	Automatic bool `json:"automatic" gorm:"not null;default:false"`
+}
+
+// TableName returns the table name for NodeStatusTransition model
+func (NodeStatusTransition) TableName() string {
+	return "node_status_transitions"
+}
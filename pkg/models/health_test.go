@@ -0,0 +1,101 @@
+package models
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNodeHealthEvaluate(t *testing.T) {
+	policy := NodeHealthPolicy{
+		HeartbeatInterval: time.Minute,
+		HeartbeatTimeout:  5 * time.Minute,
+		LoadThreshold:     80,
+		MinProbeSamples:   5,
+		HeartbeatWeight:   0.4,
+		LoadWeight:        0.2,
+		ErrorWeight:       0.2,
+		PacketLossWeight:  0.2,
+	}
+
+	tests := []struct {
+		name    string
+		input   NodeHealthInput
+		score   float64
+		reasons []string
+	}{
+		{"healthy", NodeHealthInput{HeartbeatAge: 30 * time.Second, CPUUsage: 40, Heartbeats: 2, ProbeSamples: 10, ProbeSuccesses: 10}, 100, nil},
+		{
+			"stale heartbeat",
+			NodeHealthInput{HeartbeatAge: 3 * time.Minute, CPUUsage: 40},
+			// 0.4*0.5 + 0.2, error rate and packet loss left out
+			0.4 / 0.6 * 100,
+			[]string{"last heartbeat 3m0s ago"},
+		},
+		{"no heartbeat", NodeHealthInput{HeartbeatAge: 10 * time.Minute}, 0.2 / 0.6 * 100, []string{"last heartbeat 10m0s ago"}},
+		{"overloaded", NodeHealthInput{CPUUsage: 50, MemoryUsage: 90}, (0.4 + 0.2*0.5) / 0.6 * 100, []string{"memory usage at 90%"}},
+		{
+			"errors and packet loss",
+			NodeHealthInput{Heartbeats: 4, ErrorHeartbeats: 2, ProbeSamples: 10, ProbeSuccesses: 6},
+			(0.4 + 0.2 + 0.2*0.5 + 0.2*0.6) * 100,
+			[]string{"50% of heartbeats reported errors", "40% packet loss"},
+		},
+		{"too few probes", NodeHealthInput{ProbeSamples: 4}, 100, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := tt.input.Evaluate(policy)
+			if math.Abs(health.Score-tt.score) > 1e-9 {
+				t.Errorf("Score = %v, want %v", health.Score, tt.score)
+			}
+			if !reflect.DeepEqual(health.Reasons, tt.reasons) {
+				t.Errorf("Reasons = %v, want %v", health.Reasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestNodeHealthNextStatus(t *testing.T) {
+	thresholds := NodeHealthThresholds{DegradedBelow: 60, MaintenanceBelow: 30, RecoverAbove: 75}
+
+	tests := []struct {
+		name      string
+		score     float64
+		current   NodeStatus
+		automatic bool
+		want      NodeStatus
+	}{
+		{"healthy", 90, NodeStatusOnline, false, NodeStatusOnline},
+		{"between thresholds stays online", 65, NodeStatusOnline, false, NodeStatusOnline},
+		{"degrades", 50, NodeStatusOnline, false, NodeStatusDegraded},
+		{"taken into maintenance", 20, NodeStatusOnline, false, NodeStatusMaintenance},
+		{"between thresholds stays degraded", 70, NodeStatusDegraded, true, NodeStatusDegraded},
+		{"recovers", 80, NodeStatusDegraded, true, NodeStatusOnline},
+		{"leaves automatic maintenance", 50, NodeStatusMaintenance, true, NodeStatusDegraded},
+		{"recovers from automatic maintenance", 80, NodeStatusMaintenance, true, NodeStatusOnline},
+		{"keeps manual maintenance", 100, NodeStatusMaintenance, false, NodeStatusMaintenance},
+		{"keeps offline", 0, NodeStatusOffline, false, NodeStatusOffline},
+		{"keeps disabled", 100, NodeStatusDisabled, false, NodeStatusDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := NodeHealth{Score: tt.score}
+			if got := health.NextStatus(tt.current, tt.automatic, thresholds); got != tt.want {
+				t.Errorf("NextStatus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeHealthReason(t *testing.T) {
+	health := NodeHealth{Score: 42.4, Reasons: []string{"cpu usage at 95%", "20% packet loss"}}
+	if got := health.Reason(); got != "health score 42: cpu usage at 95%, 20% packet loss" {
+		t.Errorf("Reason() = %q", got)
+	}
+	if got := (NodeHealth{Score: 100}).Reason(); got != "health score 100" {
+		t.Errorf("Reason() = %q", got)
+	}
+}
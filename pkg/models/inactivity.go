@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// InactivityAction is the step of the inactive account policy due for a user
+type InactivityAction int
+
+const (
+	InactivityNone InactivityAction = iota
+	// InactivityWarn warns an active user of the coming suspension
+	InactivityWarn
+	// InactivitySuspend suspends an active user warned long enough ago
+	InactivitySuspend
+	// InactivityPurge deletes a user the policy suspended
+	InactivityPurge
+)
+
+// InactivityAction returns the step of the inactive account policy due for
+// the user at now. Users are only suspended once warned for the current idle
+// stretch at least warningBefore ago, so that a suspension never comes
+// unannounced, even for accounts idle before the policy was enabled. A
+// purgeAfter of 0 keeps suspended accounts.
+func (u *User) InactivityAction(now time.Time, suspendAfter, warningBefore, purgeAfter time.Duration) InactivityAction {
+	if u.InactivityExempt || u.Role.IsAdmin() {
+		return InactivityNone
+	}
+	idle := now.Sub(u.LastActiveAt())
+
+	if u.SuspendedForInactivity() {
+		if purgeAfter > 0 && idle >= purgeAfter {
+			return InactivityPurge
+		}
+		return InactivityNone
+	}
+	if u.Status != UserStatusActive || idle < suspendAfter-warningBefore {
+		return InactivityNone
+	}
+
+	// A warning sent before the latest activity was for an earlier idle stretch
+	warned := u.InactivityWarnedAt != nil && !u.InactivityWarnedAt.Before(u.LastActiveAt())
+	if !warned {
+		return InactivityWarn
+	}
+	if idle >= suspendAfter && now.Sub(*u.InactivityWarnedAt) >= warningBefore {
+		return InactivitySuspend
+	}
+	return InactivityNone
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserInactivityAction(t *testing.T) {
+	const day = 24 * time.Hour
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+
+	tests := []struct {
+		name string
+		user User
+		want InactivityAction
+	}{
+		{"recently active", User{LastActivityAt: ago(10 * day)}, InactivityNone},
+		{"never active uses creation", User{CreatedAt: now.Add(-85 * day)}, InactivityWarn},
+		{"within warning time", User{LastActivityAt: ago(84 * day)}, InactivityWarn},
+		{"idle past suspension without warning", User{LastActivityAt: ago(120 * day)}, InactivityWarn},
+		{"warned too recently", User{LastActivityAt: ago(120 * day), InactivityWarnedAt: ago(2 * day)}, InactivityNone},
+		{"warned long enough ago", User{LastActivityAt: ago(91 * day), InactivityWarnedAt: ago(7 * day)}, InactivitySuspend},
+		{"warning of an earlier idle stretch", User{LastActivityAt: ago(91 * day), InactivityWarnedAt: ago(200 * day)}, InactivityWarn},
+		{"exempt", User{LastActivityAt: ago(200 * day), InactivityExempt: true}, InactivityNone},
+		{"admin", User{LastActivityAt: ago(200 * day), Role: UserRoleSuperAdmin}, InactivityNone},
+		{"suspended by an admin", User{LastActivityAt: ago(200 * day), Status: UserStatusSuspended}, InactivityNone},
+		{"suspended by the policy", User{LastActivityAt: ago(100 * day), Status: UserStatusSuspended, InactivitySuspendedAt: ago(10 * day)}, InactivityNone},
+		{"purge due", User{LastActivityAt: ago(180 * day), Status: UserStatusSuspended, InactivitySuspendedAt: ago(90 * day)}, InactivityPurge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			if user.Status == "" {
+				user.Status = UserStatusActive
+			}
+			if user.Role == "" {
+				user.Role = UserRoleUser
+			}
+			if got := user.InactivityAction(now, 90*day, 7*day, 180*day); got != tt.want {
+				t.Errorf("InactivityAction() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// Without purging, suspended accounts are kept
+	user := User{Status: UserStatusSuspended, LastActivityAt: ago(400 * day), InactivitySuspendedAt: ago(300 * day)}
+	if got := user.InactivityAction(now, 90*day, 7*day, 0); got != InactivityNone {
+		t.Errorf("InactivityAction() without purge = %d, want none", got)
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// IntegrityCheck names an invariant verified by the integrity checker
+type IntegrityCheck string
+
+const (
+	// IntegrityPlanUserCount checks that plans count the users on them
+	IntegrityPlanUserCount IntegrityCheck = "plan_user_count"
+	// IntegrityNodeUserCount checks that nodes not reporting heartbeats count
+	// their enabled user assignments; online nodes report their connected
+	// users instead
+	IntegrityNodeUserCount IntegrityCheck = "node_user_count"
+	// IntegrityTrafficSummary checks that the daily traffic summaries add up
+	// to the aggregated traffic records
+	IntegrityTrafficSummary IntegrityCheck = "traffic_summary"
+	// IntegrityUserPlan checks that users reference an existing plan
+	IntegrityUserPlan IntegrityCheck = "user_plan"
+)
+
+// IntegrityIssue is a violation of an invariant found by the integrity checker
+type IntegrityIssue struct {
+	Check IntegrityCheck `json:"check"`
+	// SubjectID is the plan, node or user in violation, 0 for traffic days
+	SubjectID uint `json:"subject_id,omitempty"`
+	// Date is the day of a traffic summary issue
+	Date *time.Time `json:"date,omitempty"`
+	// Expected is the value derived from the source data, Actual the stored one
+	Expected int64 `json:"expected"`
+	Actual   int64 `json:"actual"`
+	// Repaired is set once the stored value was corrected
+	Repaired bool `json:"repaired"`
+}
+
+// String describes the issue for logs
+func (i IntegrityIssue) String() string {
+	switch i.Check {
+	case IntegrityPlanUserCount:
+		return fmt.Sprintf("plan %d counts %d users, has %d", i.SubjectID, i.Actual, i.Expected)
+	case IntegrityNodeUserCount:
+		return fmt.Sprintf("node %d counts %d users, has %d assignments", i.SubjectID, i.Actual, i.Expected)
+	case IntegrityTrafficSummary:
+		return fmt.Sprintf("daily summaries of %s total %d bytes, records %d", i.Date.Format("2006-01-02"), i.Actual, i.Expected)
+	case IntegrityUserPlan:
+		return fmt.Sprintf("user %d references missing plan %d", i.SubjectID, i.Actual)
+	}
+	return string(i.Check)
+}
+
+// IntegrityReport is the outcome of an integrity check run
+type IntegrityReport struct {
+	CheckedAt time.Time        `json:"checked_at"`
+	Issues    []IntegrityIssue `json:"issues"`
+}
+
+// Count counts the issues of a check, every check when check is empty
+func (r *IntegrityReport) Count(check IntegrityCheck) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if check == "" || issue.Check == check {
+			n++
+		}
+	}
+	return n
+}
+
+// Repaired counts the repaired issues
+func (r *IntegrityReport) Repaired() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// TrafficDrifted reports whether stored traffic differs from the expected
+// traffic by more than tolerance, a fraction of the expected traffic. Any
+// traffic where none is expected is a drift.
+func TrafficDrifted(expected, actual int64, tolerance float64) bool {
+	diff := actual - expected
+	if diff < 0 {
+		diff = -diff
+	}
+	if expected == 0 {
+		return diff != 0
+	}
+	return float64(diff) > float64(expected)*tolerance
+}
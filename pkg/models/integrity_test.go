@@ -0,0 +1,51 @@
+package models
+
+import "testing"
+
+func TestTrafficDrifted(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  int64
+		actual    int64
+		tolerance float64
+		want      bool
+	}{
+		{"equal", 1000, 1000, 0, false},
+		{"within tolerance", 1000, 1009, 0.01, false},
+		{"missing within tolerance", 1000, 991, 0.01, false},
+		{"over tolerance", 1000, 1011, 0.01, true},
+		{"missing over tolerance", 1000, 900, 0.01, true},
+		{"no tolerance", 1000, 1001, 0, true},
+		{"nothing expected", 0, 1, 0.5, true},
+		{"nothing at all", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrafficDrifted(tt.expected, tt.actual, tt.tolerance); got != tt.want {
+				t.Errorf("TrafficDrifted(%d, %d, %v) = %v, want %v", tt.expected, tt.actual, tt.tolerance, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegrityReportCount(t *testing.T) {
+	report := &IntegrityReport{Issues: []IntegrityIssue{
+		{Check: IntegrityPlanUserCount, Repaired: true},
+		{Check: IntegrityPlanUserCount},
+		{Check: IntegrityUserPlan},
+	}}
+
+	if got := report.Count(""); got != 3 {
+		t.Errorf("Count(all) = %d, want 3", got)
+	}
+	if got := report.Count(IntegrityPlanUserCount); got != 2 {
+		t.Errorf("Count(plan_user_count) = %d, want 2", got)
+	}
+	if got := report.Count(IntegrityNodeUserCount); got != 0 {
+		t.Errorf("Count(node_user_count) = %d, want 0", got)
+	}
+	if got := report.Repaired(); got != 1 {
+		t.Errorf("Repaired() = %d, want 1", got)
+	}
+}
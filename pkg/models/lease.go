@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// ServiceLease represents a named lease held by one service instance at a time
+type ServiceLease struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:64"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Holder     string    `json:"holder" gorm:"not null;size:128;comment:Instance holding the lease"`
+	Epoch      int64     `json:"epoch" gorm:"not null;default:0;comment:Incremented whenever the holder changes"`
+	AcquiredAt time.Time `json:"acquired_at" gorm:"comment:When the current holder acquired the lease"`
+	RenewedAt  time.Time `json:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// TableName returns the table name for ServiceLease model
+func (ServiceLease) TableName() string {
+	return "service_leases"
+}
+
+// IsHeldBy checks if the lease is held by the given instance and not expired
+func (l *ServiceLease) IsHeldBy(holder string, now time.Time) bool {
+	return l.Holder == holder && now.Before(l.ExpiresAt)
+}
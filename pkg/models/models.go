@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"time"
 
-	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
@@ -18,13 +17,10 @@ type Database struct {
 
 // NewDatabase creates a new database instance
 func NewDatabase(config configv1.DatabaseConfig) (*Database, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		config.Username,
-		config.Password,
-		config.Host,
-		config.Port,
-		config.Database,
-	)
+	dialector, err := Dialector(config)
+	if err != nil {
+		return nil, err
+	}
 
 	// Configure GORM
 	gormConfig := &gorm.Config{
@@ -34,7 +30,7 @@ func NewDatabase(config configv1.DatabaseConfig) (*Database, error) {
 		},
 	}
 
-	db, err := gorm.Open(mysql.Open(dsn), gormConfig)
+	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -65,6 +61,57 @@ func (d *Database) AutoMigrate() error {
 		&TrafficQuota{},
 		&UserNode{},
 		&NodeLog{},
+		&RevokedToken{},
+		&NodeProbe{},
+		&NodeToken{},
+		&NodeMetricsHistory{},
+		&TrafficAdjustment{},
+		&ServiceLease{},
+		&SubscriptionTokenRotation{},
+		&NodeGroup{},
+		&NodeGroupMember{},
+		&PlanGroupAccess{},
+		&NodeCost{},
+		&Order{},
+		&Payment{},
+		&Tenant{},
+		&Coupon{},
+		&CouponRedemption{},
+		&GeoDataArtifact{},
+		&NodeGeoData{},
+		&NodeConfigVersion{},
+		&NodeConfigOverride{},
+		&NodeStatusTransition{},
+		&NodeFailover{},
+		&ReferralSettings{},
+		&ReferralCode{},
+		&Referral{},
+		&ReferralCommission{},
+		&SafetyPolicy{},
+		&BalanceTransaction{},
+		&LedgerEntry{},
+		&ShapingRecord{},
+		&Announcement{},
+		&AnnouncementRead{},
+		&AggregationWatermark{},
+		&Notification{},
+		&AlertDelivery{},
+		&SavedFilter{},
+		&BlocklistEntry{},
+		&AdminAuditLog{},
+		&ExternalAlert{},
+		&APIKey{},
+		&APIKeyUsage{},
+		&UserMigration{},
+		&AutomationRule{},
+		&AutomationExecution{},
+		&SystemSetting{},
+		&SystemSettingChange{},
+		&NodeEnrollment{},
+		&AgentReportReceipt{},
+		&NodeCommand{},
+		&UserCredentialRotation{},
+		&SubscriptionAccessLog{},
 	)
 }
 
@@ -96,64 +143,6 @@ func (d *Database) Transaction(fn func(*gorm.DB) error) error {
 	return d.DB.Transaction(fn)
 }
 
-// CreateDefaultData creates default data for the application
-func (d *Database) CreateDefaultData() error {
-	return d.DB.Transaction(func(tx *gorm.DB) error {
-		// Create default plan
-		var count int64
-		tx.Model(&Plan{}).Count(&count)
-		if count == 0 {
-			defaultPlan := &Plan{
-				Name:         "Free Plan",
-				Description:  "Default free plan with basic features",
-				Status:       PlanStatusActive,
-				Period:       PlanPeriodMonthly,
-				Price:        0,
-				Currency:     "USD",
-				TrafficQuota: 10 * 1024 * 1024 * 1024, // 10GB
-				SpeedLimit:   0,                        // Unlimited
-				DeviceLimit:  1,
-				IsPublic:     true,
-				IsEnabled:    true,
-				Color:        "#2563eb",
-				SortOrder:    1,
-			}
-			if err := tx.Create(defaultPlan).Error; err != nil {
-				return fmt.Errorf("failed to create default plan: %w", err)
-			}
-		}
-
-		// Create admin user if not exists
-		var adminCount int64
-		tx.Model(&User{}).Where("username = ?", "admin").Count(&adminCount)
-		if adminCount == 0 {
-			// Get the default plan
-			var defaultPlan Plan
-			if err := tx.First(&defaultPlan).Error; err != nil {
-				return fmt.Errorf("failed to get default plan: %w", err)
-			}
-
-			adminUser := &User{
-				Username:     "admin",
-				Email:        "admin@localhost",
-				Password:     "$2a$12$example", // This should be properly hashed
-				DisplayName:  "Administrator",
-				Status:       UserStatusActive,
-				PlanID:       defaultPlan.ID,
-				TrafficQuota: -1, // Unlimited for admin
-				DeviceLimit:  10,
-				UUID:         generateUUID(),
-				SubscriptionToken: generateToken(32),
-			}
-			if err := tx.Create(adminUser).Error; err != nil {
-				return fmt.Errorf("failed to create admin user: %w", err)
-			}
-		}
-
-		return nil
-	})
-}
-
 // Statistics represents database statistics
 type Statistics struct {
 	TotalUsers       int64 `json:"total_users"`
@@ -177,7 +166,7 @@ func (d *Database) GetStatistics() (*Statistics, error) {
 
 	// Node statistics
 	d.DB.Model(&Node{}).Count(&stats.TotalNodes)
-	d.DB.Model(&Node{}).Where("status = ?", NodeStatusOnline).Count(&stats.OnlineNodes)
+	d.DB.Model(&Node{}).Where("status IN ?", []NodeStatus{NodeStatusOnline, NodeStatusDegraded}).Count(&stats.OnlineNodes)
 
 	// Plan statistics
 	d.DB.Model(&Plan{}).Count(&stats.TotalPlans)
@@ -13,6 +13,8 @@ const (
 	NodeStatusOnline     NodeStatus = "online"
 	NodeStatusOffline    NodeStatus = "offline"
 	NodeStatusMaintenance NodeStatus = "maintenance"
+	// NodeStatusDegraded is an online node with a low health score
+	NodeStatusDegraded NodeStatus = "degraded"
 	NodeStatusDisabled   NodeStatus = "disabled"
 )
 
@@ -29,6 +31,16 @@ const (
 	NodeTypeTUIC       NodeType = "tuic"
 )
 
+// IsValid checks if the node type is known
+func (t NodeType) IsValid() bool {
+	switch t {
+	case NodeTypeVMess, NodeTypeVLESS, NodeTypeTrojan, NodeTypeShadowsocks,
+		NodeTypeHysteria, NodeTypeHysteria2, NodeTypeTUIC:
+		return true
+	}
+	return false
+}
+
 // Node represents a sing-box server node
 type Node struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
@@ -80,6 +92,8 @@ type Node struct {
 
 	// Statistics and monitoring
 	CurrentUsers   int       `json:"current_users" gorm:"not null;default:0"`
+	// ActiveConnections is the connection count of the latest metrics report
+	ActiveConnections int    `json:"active_connections" gorm:"not null;default:0"`
 	TotalTraffic   int64     `json:"total_traffic" gorm:"not null;default:0;comment:Total traffic in bytes"`
 	UploadTraffic  int64     `json:"upload_traffic" gorm:"not null;default:0"`
 	DownloadTraffic int64    `json:"download_traffic" gorm:"not null;default:0"`
@@ -93,6 +107,21 @@ type Node struct {
 	Load5       float64 `json:"load5" gorm:"type:decimal(8,2);default:0"`
 	Load15      float64 `json:"load15" gorm:"type:decimal(8,2);default:0"`
 
+	// Network throughput derived from consecutive agent counter samples
+	NetworkInRate  int64 `json:"network_in_rate" gorm:"not null;default:0;comment:Inbound bytes per second"`
+	NetworkOutRate int64 `json:"network_out_rate" gorm:"not null;default:0;comment:Outbound bytes per second"`
+
+	// Divergences between the node's reports and the panel's observations,
+	// see NodeWitness. Cleared by the first window without any.
+	WitnessFlags     string     `json:"witness_flags,omitempty" gorm:"size:128;comment:Comma-separated witness findings"`
+	WitnessFlaggedAt *time.Time `json:"witness_flagged_at,omitempty" gorm:"comment:Start of the flagged stretch"`
+
+	// Health score (0-100) of the last health check, see NodeHealth.
+	// StatusAutomatic is set while the status was set by the health checks.
+	HealthScore     float64 `json:"health_score" gorm:"type:decimal(5,2);default:100"`
+	StatusReason    string  `json:"status_reason,omitempty" gorm:"size:512"`
+	StatusAutomatic bool    `json:"status_automatic" gorm:"not null;default:false"`
+
 	// Configuration and version
 	ConfigVersion  int    `json:"config_version" gorm:"not null;default:0"`
 	ConfigContent  string `json:"config_content,omitempty" gorm:"type:text;comment:Node configuration content"`
@@ -103,6 +132,9 @@ type Node struct {
 	Notes    string            `json:"notes" gorm:"type:text"`
 	Metadata map[string]string `json:"metadata,omitempty" gorm:"serializer:json"`
 
+	// MergedIntoID is set on a deleted node whose history was merged into a successor
+	MergedIntoID *uint `json:"merged_into_id,omitempty" gorm:"index"`
+
 	// Relationships
 	TrafficRecords []TrafficRecord `json:"traffic_records,omitempty" gorm:"foreignKey:NodeID"`
 	UserNodes      []UserNode      `json:"user_nodes,omitempty" gorm:"foreignKey:NodeID"`
@@ -116,7 +148,7 @@ func (Node) TableName() string {
 
 // IsOnline checks if node is online
 func (n *Node) IsOnline() bool {
-	if n.Status != NodeStatusOnline {
+	if n.Status != NodeStatusOnline && n.Status != NodeStatusDegraded {
 		return false
 	}
 	if n.LastHeartbeat == nil {
@@ -213,4 +245,69 @@ type SystemStats struct {
 type NodeStats struct {
 	TotalNodes  int64 `json:"total_nodes"`
 	OnlineNodes int64 `json:"online_nodes"`
-}
\ No newline at end of file
+	// Over the online nodes
+	AvgCPUUsage      float64 `json:"avg_cpu_usage"`
+	AvgMemoryUsage   float64 `json:"avg_memory_usage"`
+	TotalConnections int64   `json:"total_connections"`
+}
+
+// NodeMergeResult reports how many history rows were moved by a node merge
+type NodeMergeResult struct {
+	TrafficRecords   int64 `json:"traffic_records"`
+	TrafficSummaries int64 `json:"traffic_summaries"`
+	NodeLogs         int64 `json:"node_logs"`
+	NodeProbes       int64 `json:"node_probes"`
+	UserNodes        int64 `json:"user_nodes"`
+	PlanNodeAccesses int64 `json:"plan_node_accesses"`
+	NodeGroupMembers int64 `json:"node_group_members"`
+}
+
+// NodeGroup is a named set of nodes that can be managed and granted to plans as a unit
+type NodeGroup struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	Name        string `json:"name" gorm:"not null;size:64;index"`
+	Description string `json:"description" gorm:"size:255"`
+	Sort        int    `json:"sort" gorm:"not null;default:0;comment:Sort order"`
+
+	// Relationships
+	Members []NodeGroupMember `json:"members,omitempty" gorm:"foreignKey:GroupID"`
+}
+
+// TableName returns the table name for NodeGroup model
+func (NodeGroup) TableName() string {
+	return "node_groups"
+}
+
+// NodeGroupMember assigns a node to a group; a node may belong to several groups
+type NodeGroupMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	GroupID uint `json:"group_id" gorm:"not null;uniqueIndex:idx_node_group_member"`
+	NodeID  uint `json:"node_id" gorm:"not null;uniqueIndex:idx_node_group_member;index"`
+}
+
+// TableName returns the table name for NodeGroupMember model
+func (NodeGroupMember) TableName() string {
+	return "node_group_members"
+}
+
+// AgentReportReceipt records a traffic or metrics report received from a
+// node, so that a report the agent replays after an outage is only counted
+// once
+type AgentReportReceipt struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	NodeID   uint   `json:"node_id" gorm:"not null;uniqueIndex:idx_agent_report_receipt"`
+	ReportID string `json:"report_id" gorm:"not null;size:64;uniqueIndex:idx_agent_report_receipt"`
+}
+
+// TableName returns the table name for AgentReportReceipt model
+func (AgentReportReceipt) TableName() string {
+	return "agent_report_receipts"
+}
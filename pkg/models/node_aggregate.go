@@ -0,0 +1,87 @@
+package models
+
+// NodeAggregate sums the state of a set of nodes, such as a group or a
+// region, for dashboards covering many nodes
+type NodeAggregate struct {
+	TotalNodes    int
+	EnabledNodes  int
+	OnlineNodes   int
+	DegradedNodes int
+
+	// OnlineUsers sums the users connected to the nodes
+	OnlineUsers int64
+	// NetworkInRate and NetworkOutRate sum the throughput of the available
+	// nodes in bytes per second
+	NetworkInRate  int64
+	NetworkOutRate int64
+	// Traffic sums the traffic of the nodes over the requested period
+	Traffic int64
+
+	cpuUsage    float64
+	memoryUsage float64
+	load1       float64
+}
+
+// Add adds a node and its traffic over the period, usage may be nil
+func (a *NodeAggregate) Add(node *Node, usage *NodeUsage) {
+	a.TotalNodes++
+	if usage != nil {
+		a.Traffic += usage.TotalTraffic
+	}
+	if !node.IsEnabled {
+		return
+	}
+	a.EnabledNodes++
+
+	switch node.Status {
+	case NodeStatusOnline:
+		a.OnlineNodes++
+	case NodeStatusDegraded:
+		a.DegradedNodes++
+	default:
+		// Offline nodes keep reporting their last values
+		return
+	}
+	a.OnlineUsers += int64(node.CurrentUsers)
+	a.NetworkInRate += node.NetworkInRate
+	a.NetworkOutRate += node.NetworkOutRate
+	a.cpuUsage += node.CPUUsage
+	a.memoryUsage += node.MemoryUsage
+	a.load1 += node.Load1
+}
+
+// AvailableNodes counts the enabled nodes serving users, degraded included
+func (a *NodeAggregate) AvailableNodes() int {
+	return a.OnlineNodes + a.DegradedNodes
+}
+
+// Availability returns the percentage of enabled nodes available, 0 without
+// enabled nodes
+func (a *NodeAggregate) Availability() float64 {
+	if a.EnabledNodes == 0 {
+		return 0
+	}
+	return float64(a.AvailableNodes()) / float64(a.EnabledNodes) * 100
+}
+
+// AvgCPUUsage returns the average CPU usage of the available nodes
+func (a *NodeAggregate) AvgCPUUsage() float64 {
+	return a.average(a.cpuUsage)
+}
+
+// AvgMemoryUsage returns the average memory usage of the available nodes
+func (a *NodeAggregate) AvgMemoryUsage() float64 {
+	return a.average(a.memoryUsage)
+}
+
+// AvgLoad returns the average 1 minute load of the available nodes
+func (a *NodeAggregate) AvgLoad() float64 {
+	return a.average(a.load1)
+}
+
+func (a *NodeAggregate) average(total float64) float64 {
+	if a.AvailableNodes() == 0 {
+		return 0
+	}
+	return total / float64(a.AvailableNodes())
+}
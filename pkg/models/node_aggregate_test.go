@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestNodeAggregate(t *testing.T) {
+	var aggregate NodeAggregate
+	if aggregate.Availability() != 0 || aggregate.AvgCPUUsage() != 0 {
+		t.Fatalf("empty aggregate = %v%%, %v cpu, want 0", aggregate.Availability(), aggregate.AvgCPUUsage())
+	}
+
+	aggregate.Add(&Node{IsEnabled: true, Status: NodeStatusOnline, CurrentUsers: 10, NetworkInRate: 100, NetworkOutRate: 300, CPUUsage: 40, MemoryUsage: 50, Load1: 1}, &NodeUsage{TotalTraffic: 1000})
+	aggregate.Add(&Node{IsEnabled: true, Status: NodeStatusDegraded, CurrentUsers: 5, NetworkInRate: 50, NetworkOutRate: 100, CPUUsage: 80, MemoryUsage: 90, Load1: 3}, nil)
+	// Offline and disabled nodes count towards the traffic only
+	aggregate.Add(&Node{IsEnabled: true, Status: NodeStatusOffline, CurrentUsers: 7, CPUUsage: 99}, &NodeUsage{TotalTraffic: 500})
+	aggregate.Add(&Node{IsEnabled: false, Status: NodeStatusOnline, CurrentUsers: 3, CPUUsage: 99}, &NodeUsage{TotalTraffic: 1})
+
+	if aggregate.TotalNodes != 4 || aggregate.EnabledNodes != 3 || aggregate.OnlineNodes != 1 || aggregate.DegradedNodes != 1 {
+		t.Errorf("nodes = %d total, %d enabled, %d online, %d degraded, want 4, 3, 1, 1",
+			aggregate.TotalNodes, aggregate.EnabledNodes, aggregate.OnlineNodes, aggregate.DegradedNodes)
+	}
+	if aggregate.OnlineUsers != 15 || aggregate.NetworkInRate != 150 || aggregate.NetworkOutRate != 400 || aggregate.Traffic != 1501 {
+		t.Errorf("aggregate = %+v, want 15 users, 150/400 B/s and 1501 bytes", aggregate)
+	}
+	if got := aggregate.Availability(); got != float64(2)/3*100 {
+		t.Errorf("availability = %v, want 2 of 3 nodes", got)
+	}
+	if aggregate.AvgCPUUsage() != 60 || aggregate.AvgMemoryUsage() != 70 || aggregate.AvgLoad() != 2 {
+		t.Errorf("averages = %v cpu, %v memory, %v load, want 60, 70, 2",
+			aggregate.AvgCPUUsage(), aggregate.AvgMemoryUsage(), aggregate.AvgLoad())
+	}
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// NodeCommandStatus is the state of a command queued to a node
+type NodeCommandStatus string
+
+const (
+	// NodeCommandPending is queued, waiting for the node's next heartbeat
+	NodeCommandPending NodeCommandStatus = "pending"
+	// NodeCommandDelivered was handed to the node, which has not reported a
+	// result yet
+	NodeCommandDelivered NodeCommandStatus = "delivered"
+	// NodeCommandSucceeded was executed by the node
+	NodeCommandSucceeded NodeCommandStatus = "succeeded"
+	// NodeCommandFailed was executed by the node, which reported an error
+	NodeCommandFailed NodeCommandStatus = "failed"
+	// NodeCommandTimedOut got no result within the command timeout
+	NodeCommandTimedOut NodeCommandStatus = "timed_out"
+)
+
+// IsValid reports whether s is a known command status
+func (s NodeCommandStatus) IsValid() bool {
+	switch s {
+	case NodeCommandPending, NodeCommandDelivered, NodeCommandSucceeded, NodeCommandFailed, NodeCommandTimedOut:
+		return true
+	}
+	return false
+}
+
+// Finished reports whether the command will not change state anymore
+func (s NodeCommandStatus) Finished() bool {
+	return s == NodeCommandSucceeded || s == NodeCommandFailed || s == NodeCommandTimedOut
+}
+
+// NodeCommand is a command queued to a node and its result. The command
+// parameters are not kept, they may hold user credentials.
+type NodeCommand struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	CommandID string            `json:"command_id" gorm:"uniqueIndex;not null;size:64"`
+	NodeID    uint              `json:"node_id" gorm:"not null;index"`
+	Type      string            `json:"type" gorm:"not null;size:32"`
+	UserID    string            `json:"user_id" gorm:"size:64"`
+	RequestID string            `json:"request_id" gorm:"size:64"`
+	Status    NodeCommandStatus `json:"status" gorm:"not null;size:16;index"`
+	// Output is what the node reported with the result, the error of a
+	// failed command
+	Output string `json:"output" gorm:"type:text"`
+	// SpeedLimit is the speed limit in bytes/sec a user command set, nil
+	// for commands without one
+	SpeedLimit  *int64     `json:"speed_limit"`
+	DeliveredAt *time.Time `json:"delivered_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// TableName returns the table name for NodeCommand model
+func (NodeCommand) TableName() string {
+	return "node_commands"
+}
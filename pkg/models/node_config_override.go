@@ -0,0 +1,229 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxNodeConfigOverridePathLength matches the size of the path column
+const MaxNodeConfigOverridePathLength = 255
+
+// NodeConfigOverride sets one value of a node's config on top of the config
+// applied to it, so that a node can differ from the nodes sharing its config.
+// Path is a dot-separated key path into the JSON config where numbers index
+// arrays, e.g. "log.level" or "inbounds.0.sniff"; Value is the JSON value.
+type NodeConfigOverride struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	NodeID uint   `json:"node_id" gorm:"not null;uniqueIndex:idx_node_config_override"`
+	Path   string `json:"path" gorm:"not null;size:255;uniqueIndex:idx_node_config_override"`
+	Value  string `json:"value" gorm:"type:text"`
+	Author string `json:"author" gorm:"size:64;comment:Admin who set the override"`
+}
+
+// TableName returns the table name for NodeConfigOverride model
+func (NodeConfigOverride) TableName() string {
+	return "node_config_overrides"
+}
+
+// Validate checks the override's path and value
+func (o *NodeConfigOverride) Validate() error {
+	v := &validator{}
+	v.check(o.Path != "", "path", o.Path, "path is required")
+	v.check(len(o.Path) <= MaxNodeConfigOverridePathLength, "path", o.Path,
+		fmt.Sprintf("path cannot exceed %d characters", MaxNodeConfigOverridePathLength))
+	if o.Path != "" {
+		for _, segment := range strings.Split(o.Path, ".") {
+			if segment == "" {
+				v.check(false, "path", o.Path, "path cannot contain empty keys")
+				break
+			}
+		}
+	}
+	v.check(json.Valid([]byte(o.Value)), "value", o.Value, "value must be a JSON value")
+	return v.err()
+}
+
+// ApplyNodeConfigOverrides sets the overrides in a node config in order. The
+// config keeps its key order and is indented by two spaces when changed; it is
+// returned as is without overrides. Objects missing on a path are created,
+// array indexes must exist.
+func ApplyNodeConfigOverrides(content string, overrides []*NodeConfigOverride) (string, error) {
+	if len(overrides) == 0 {
+		return content, nil
+	}
+
+	config, err := decodeOrderedJSON(content)
+	if err != nil {
+		return "", &ValidationError{Fields: []FieldError{{Field: "config_content", Message: "config_content must be a JSON object"}}}
+	}
+	v := &validator{}
+	for _, override := range overrides {
+		value, err := decodeOrderedJSON(override.Value)
+		if err == nil {
+			err = setConfigPath(config, strings.Split(override.Path, "."), value)
+		}
+		if err != nil {
+			v.check(false, "config_content", override.Path, fmt.Sprintf("override %s: %v", override.Path, err))
+		}
+	}
+	if err := v.err(); err != nil {
+		return "", err
+	}
+
+	var compact, indented bytes.Buffer
+	encodeOrderedJSON(&compact, config)
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		return "", err
+	}
+	indented.WriteByte('\n')
+	return indented.String(), nil
+}
+
+// setConfigPath sets the value at the key path in a decoded config
+func setConfigPath(config any, path []string, value any) error {
+	current := config
+	for i, key := range path {
+		last := i == len(path)-1
+		switch node := current.(type) {
+		case *orderedObject:
+			if last {
+				node.set(key, value)
+				return nil
+			}
+			next, ok := node.values[key]
+			if !ok {
+				next = &orderedObject{values: map[string]any{}}
+				node.set(key, next)
+			}
+			current = next
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return fmt.Errorf("%s has no element %s", strings.Join(path[:i], "."), key)
+			}
+			if last {
+				node[index] = value
+				return nil
+			}
+			current = node[index]
+		default:
+			return fmt.Errorf("%s is not an object or array", strings.Join(path[:i], "."))
+		}
+	}
+	return nil
+}
+
+// orderedObject is a JSON object that keeps the order of its keys
+type orderedObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *orderedObject) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// decodeOrderedJSON decodes a JSON value into orderedObjects, []any and
+// scalars, numbers kept as json.Number
+func decodeOrderedJSON(content string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+	value, err := decodeOrderedValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+func decodeOrderedValue(decoder *json.Decoder) (any, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		object := &orderedObject{values: map[string]any{}}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.set(key.(string), value)
+		}
+		_, err = decoder.Token()
+		return object, err
+	case json.Delim('['):
+		array := []any{}
+		for decoder.More() {
+			value, err := decodeOrderedValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, value)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// encodeOrderedJSON writes a value decoded by decodeOrderedJSON as compact JSON
+func encodeOrderedJSON(buf *bytes.Buffer, value any) {
+	switch value := value.(type) {
+	case *orderedObject:
+		buf.WriteByte('{')
+		for i, key := range value.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeJSONString(buf, key)
+			buf.WriteByte(':')
+			encodeOrderedJSON(buf, value.values[key])
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, element := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeOrderedJSON(buf, element)
+		}
+		buf.WriteByte(']')
+	case string:
+		encodeJSONString(buf, value)
+	case json.Number:
+		buf.WriteString(value.String())
+	case bool:
+		buf.WriteString(strconv.FormatBool(value))
+	default:
+		buf.WriteString("null")
+	}
+}
+
+// encodeJSONString writes a JSON string without escaping HTML characters
+func encodeJSONString(buf *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	// Encode terminates the value with a newline
+	buf.Truncate(buf.Len() - 1)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const overrideTestConfig = `{
+  "log": {"level": "info"},
+  "inbounds": [
+    {"type": "vless", "tag": "in-<1>", "sniff": false}
+  ],
+  "outbounds": [{"type": "direct", "mtu": 1500}]
+}
+`
+
+func TestApplyNodeConfigOverrides(t *testing.T) {
+	if got, err := ApplyNodeConfigOverrides(overrideTestConfig, nil); err != nil || got != overrideTestConfig {
+		t.Fatalf("without overrides = %q, %v, want the config unchanged", got, err)
+	}
+
+	got, err := ApplyNodeConfigOverrides(overrideTestConfig, []*NodeConfigOverride{
+		{Path: "log.level", Value: `"debug"`},
+		{Path: "inbounds.0.sniff", Value: `true`},
+		{Path: "experimental.cache_file", Value: `{"enabled": true, "path": "cache.db"}`},
+	})
+	if err != nil {
+		t.Fatalf("apply overrides: %v", err)
+	}
+	want := `{
+  "log": {
+    "level": "debug"
+  },
+  "inbounds": [
+    {
+      "type": "vless",
+      "tag": "in-<1>",
+      "sniff": true
+    }
+  ],
+  "outbounds": [
+    {
+      "type": "direct",
+      "mtu": 1500
+    }
+  ],
+  "experimental": {
+    "cache_file": {
+      "enabled": true,
+      "path": "cache.db"
+    }
+  }
+}
+`
+	if got != want {
+		t.Errorf("config =\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		path string
+		err  string
+	}{
+		{"inbounds.1.sniff", "inbounds has no element 1"},
+		{"inbounds.first", "inbounds has no element first"},
+		{"log.level.name", "log.level is not an object or array"},
+	}
+	for _, tt := range tests {
+		_, err := ApplyNodeConfigOverrides(overrideTestConfig, []*NodeConfigOverride{{Path: tt.path, Value: `1`}})
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("override %s err = %v, want %q", tt.path, err, tt.err)
+		}
+	}
+}
+
+func TestNodeConfigOverrideValidate(t *testing.T) {
+	tests := []struct {
+		override NodeConfigOverride
+		valid    bool
+	}{
+		{NodeConfigOverride{Path: "log.level", Value: `"debug"`}, true},
+		{NodeConfigOverride{Path: "", Value: `1`}, false},
+		{NodeConfigOverride{Path: "log..level", Value: `1`}, false},
+		{NodeConfigOverride{Path: "log.level", Value: `debug`}, false},
+		{NodeConfigOverride{Path: strings.Repeat("a", MaxNodeConfigOverridePathLength+1), Value: `1`}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.override.Validate(); (err == nil) != tt.valid {
+			t.Errorf("Validate(%q, %q) = %v, want valid %v", tt.override.Path, tt.override.Value, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// NodeConfigSource tells how a node config version was applied
+type NodeConfigSource string
+
+const (
+	// NodeConfigSourceUpdate is a config set through UpdateNodeConfig
+	NodeConfigSourceUpdate NodeConfigSource = "update"
+	// NodeConfigSourceBatch is a config set by a batch node operation
+	NodeConfigSourceBatch NodeConfigSource = "batch"
+	// NodeConfigSourceRestore is an earlier version applied again
+	NodeConfigSourceRestore NodeConfigSource = "restore"
+	// NodeConfigSourceOverride is the config re-rendered after the node's
+	// overrides changed
+	NodeConfigSourceOverride NodeConfigSource = "override"
+)
+
+// NodeConfigVersion is a config applied to a node. Version matches the node's
+// ConfigVersion after the config was applied, so versions are never reused.
+// Content includes the node's overrides, see NodeConfigOverride.
+type NodeConfigVersion struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	NodeID  uint `json:"node_id" gorm:"not null;uniqueIndex:idx_node_config_version"`
+	Version int  `json:"version" gorm:"not null;uniqueIndex:idx_node_config_version"`
+
+	Author string           `json:"author" gorm:"size:64;comment:Admin who applied the config"`
+	Source NodeConfigSource `json:"source" gorm:"not null;size:20"`
+	// RestoredFrom is the version a restore copied, 0 otherwise
+	RestoredFrom int    `json:"restored_from" gorm:"not null;default:0"`
+	SHA256       string `json:"sha256" gorm:"not null;size:64"`
+	Size         int64  `json:"size" gorm:"not null;default:0"`
+	Content      string `json:"content,omitempty" gorm:"type:text"`
+	// Base is the config before the node's overrides were applied, empty
+	// when Content is the config as applied
+	Base string `json:"base,omitempty" gorm:"type:text"`
+}
+
+// BaseContent returns the config the version was rendered from
+func (v *NodeConfigVersion) BaseContent() string {
+	if v.Base != "" {
+		return v.Base
+	}
+	return v.Content
+}
+
+// TableName returns the table name for NodeConfigVersion model
+func (NodeConfigVersion) TableName() string {
+	return "node_config_versions"
+}
@@ -0,0 +1,106 @@
+package models
+
+import "time"
+
+// NotificationType represents the system event a notification is about
+type NotificationType string
+
+const (
+	// NotificationTypeQuotaWarning warns that the traffic quota is almost used up
+	NotificationTypeQuotaWarning NotificationType = "quota_warning"
+	// NotificationTypeQuotaExceeded tells that the traffic quota is used up
+	NotificationTypeQuotaExceeded NotificationType = "quota_exceeded"
+	// NotificationTypePlanExpiring warns that the account expires soon
+	NotificationTypePlanExpiring NotificationType = "plan_expiring"
+	// NotificationTypeTicketReply tells that support replied to a ticket
+	NotificationTypeTicketReply NotificationType = "ticket_reply"
+	// NotificationTypeAccountInactive warns of or tells about a suspension for inactivity
+	NotificationTypeAccountInactive NotificationType = "account_inactive"
+	// NotificationTypeNodeWitness tells admins that a node's reports diverge from observations
+	NotificationTypeNodeWitness NotificationType = "node_witness"
+	// NotificationTypeNodeDiskPressure tells admins that a node's disk is nearly full
+	NotificationTypeNodeDiskPressure NotificationType = "node_disk_pressure"
+	// NotificationTypeNodeConfigFailure tells admins that a node rejected or rolled back a config
+	NotificationTypeNodeConfigFailure NotificationType = "node_config_failure"
+	// NotificationTypeSubscriptionSharing tells admins that a subscription link
+	// is fetched from more addresses than one user has
+	NotificationTypeSubscriptionSharing NotificationType = "subscription_sharing"
+	// NotificationTypeAutomation is sent by the notify action of an automation rule
+	NotificationTypeAutomation NotificationType = "automation"
+	// NotificationTypeSystem is any other message of the panel
+	NotificationTypeSystem NotificationType = "system"
+)
+
+// IsValid checks if the notification type is known
+func (t NotificationType) IsValid() bool {
+	switch t {
+	case NotificationTypeQuotaWarning, NotificationTypeQuotaExceeded, NotificationTypePlanExpiring,
+		NotificationTypeTicketReply, NotificationTypeAccountInactive, NotificationTypeNodeWitness,
+		NotificationTypeNodeDiskPressure, NotificationTypeNodeConfigFailure, NotificationTypeSubscriptionSharing,
+		NotificationTypeAutomation, NotificationTypeSystem:
+		return true
+	}
+	return false
+}
+
+// Severities of notifications and alerts
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Length limits of notifications
+const (
+	MaxNotificationTitleLength   = 200
+	MaxNotificationMessageLength = 1024
+)
+
+// Notification is a message in a user's in-app notification center
+type Notification struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	UserID   uint             `json:"user_id" gorm:"not null;index;uniqueIndex:idx_notifications_dedup"`
+	Type     NotificationType `json:"type" gorm:"not null;size:32"`
+	Severity string           `json:"severity" gorm:"not null;size:16;default:info"`
+	Title    string           `json:"title" gorm:"not null;size:200"`
+	Message  string           `json:"message" gorm:"size:1024"`
+	// DedupKey makes an event notify a user at most once, nil never deduplicates
+	DedupKey *string    `json:"-" gorm:"size:128;uniqueIndex:idx_notifications_dedup"`
+	ReadAt   *time.Time `json:"read_at,omitempty" gorm:"index"`
+}
+
+// TableName returns the table name for Notification model
+func (Notification) TableName() string {
+	return "notifications"
+}
+
+// Validate checks the notification fields
+func (n *Notification) Validate() error {
+	v := &validator{}
+	v.check(n.Type.IsValid(), "type", string(n.Type),
+		"type must be one of quota_warning, quota_exceeded, plan_expiring, ticket_reply, account_inactive, system")
+	v.check(n.Severity == SeverityInfo || n.Severity == SeverityWarning || n.Severity == SeverityCritical,
+		"severity", n.Severity, "severity must be one of info, warning, critical")
+	v.check(n.Title != "", "title", n.Title, "title is required")
+	v.check(len(n.Title) <= MaxNotificationTitleLength, "title", n.Title, "title is too long")
+	v.check(len(n.Message) <= MaxNotificationMessageLength, "message", "", "message is too long")
+	return v.err()
+}
+
+// AlertDelivery records an alert delivered to a user through a channel that
+// keeps no record of its own, such as email, so it is sent at most once
+type AlertDelivery struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	Channel string `json:"channel" gorm:"not null;size:32;uniqueIndex:idx_alert_deliveries_key"`
+	UserID  uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_alert_deliveries_key"`
+	Key     string `json:"key" gorm:"not null;size:128;uniqueIndex:idx_alert_deliveries_key"`
+}
+
+// TableName returns the table name for AlertDelivery model
+func (AlertDelivery) TableName() string {
+	return "alert_deliveries"
+}
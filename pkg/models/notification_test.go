@@ -0,0 +1,33 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNotificationValidate(t *testing.T) {
+	valid := Notification{UserID: 1, Type: NotificationTypeTicketReply, Severity: SeverityInfo, Title: "Support replied"}
+
+	tests := []struct {
+		name   string
+		modify func(n *Notification)
+		want   []string
+	}{
+		{"valid", func(n *Notification) {}, nil},
+		{"unknown type", func(n *Notification) { n.Type = "promotion" }, []string{"type"}},
+		{"unknown severity", func(n *Notification) { n.Severity = "fatal" }, []string{"severity"}},
+		{"missing title", func(n *Notification) { n.Title = "" }, []string{"title"}},
+		{"long message", func(n *Notification) { n.Message = strings.Repeat("x", MaxNotificationMessageLength+1) }, []string{"message"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notification := valid
+			tt.modify(&notification)
+			if got := invalidFields(t, notification.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
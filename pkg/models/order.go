@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// OrderStatus represents the lifecycle state of an order
+type OrderStatus string
+
+const (
+	OrderStatusPending   OrderStatus = "pending"
+	OrderStatusPaid      OrderStatus = "paid"
+	OrderStatusCancelled OrderStatus = "cancelled"
+	OrderStatusRefunded  OrderStatus = "refunded"
+)
+
+// IsValid checks if the status is known
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusPending, OrderStatusPaid, OrderStatusCancelled, OrderStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// OrderType represents what an order buys
+type OrderType string
+
+const (
+	// OrderTypeNew buys a plan for a user without a running plan
+	OrderTypeNew OrderType = "new"
+	// OrderTypeRenewal extends the user's running plan
+	OrderTypeRenewal OrderType = "renewal"
+	// OrderTypeUpgrade switches a running plan to another one, crediting the unused time
+	OrderTypeUpgrade OrderType = "upgrade"
+)
+
+// PaymentStatus represents the state of a payment
+type PaymentStatus string
+
+const (
+	PaymentStatusSucceeded PaymentStatus = "succeeded"
+	PaymentStatusRefunded  PaymentStatus = "refunded"
+)
+
+// Order represents the purchase of a plan by a user
+type Order struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrderNo string      `json:"order_no" gorm:"uniqueIndex;not null;size:32"`
+	UserID  uint        `json:"user_id" gorm:"not null;index"`
+	PlanID  uint        `json:"plan_id" gorm:"not null;index"`
+	Type    OrderType   `json:"type" gorm:"not null;size:20"`
+	Status  OrderStatus `json:"status" gorm:"not null;default:'pending';size:20;index"`
+
+	// Amounts in cents; Total = Amount - ProrationCredit - Discount
+	Amount          int64  `json:"amount" gorm:"not null;default:0;comment:Plan price in cents"`
+	ProrationCredit int64  `json:"proration_credit" gorm:"not null;default:0;comment:Credit for unused time of the previous plan in cents"`
+	Discount        int64  `json:"discount" gorm:"not null;default:0;comment:Coupon discount in cents"`
+	Total           int64  `json:"total" gorm:"not null;default:0;comment:Amount to pay in cents"`
+	Currency        string `json:"currency" gorm:"not null;default:'USD';size:3"`
+
+	CouponID   *uint  `json:"coupon_id,omitempty" gorm:"index"`
+	CouponCode string `json:"coupon_code,omitempty" gorm:"size:32"`
+
+	Notes        string     `json:"notes" gorm:"size:255"`
+	StatusReason string     `json:"status_reason" gorm:"size:255;comment:Why the order was cancelled or refunded"`
+	Operator     string     `json:"operator" gorm:"size:64;comment:Who made the last status change"`
+	PaidAt       *time.Time `json:"paid_at,omitempty"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty"`
+	RefundedAt   *time.Time `json:"refunded_at,omitempty"`
+
+	Payments []Payment `json:"payments,omitempty" gorm:"foreignKey:OrderID"`
+}
+
+// TableName returns the table name for Order model
+func (Order) TableName() string {
+	return "orders"
+}
+
+// Payment records money received for an order
+type Payment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrderID       uint          `json:"order_id" gorm:"not null;index"`
+	Method        string        `json:"method" gorm:"not null;size:32;comment:Payment channel, e.g. manual, stripe, alipay"`
+	TransactionID string        `json:"transaction_id" gorm:"size:128;index;comment:Reference of the payment provider"`
+	Amount        int64         `json:"amount" gorm:"not null;default:0;comment:Amount in cents"`
+	Currency      string        `json:"currency" gorm:"not null;default:'USD';size:3"`
+	Status        PaymentStatus `json:"status" gorm:"not null;size:20"`
+	Operator      string        `json:"operator" gorm:"size:64"`
+}
+
+// TableName returns the table name for Payment model
+func (Payment) TableName() string {
+	return "payments"
+}
+
+// NewOrderNo generates a human readable, unique order number
+func NewOrderNo() string {
+	return fmt.Sprintf("ORD%s%s", time.Now().Format("20060102"), strings.ToUpper(generateToken(6)))
+}
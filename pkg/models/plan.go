@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"time"
 
 	"gorm.io/gorm"
@@ -15,6 +16,15 @@ const (
 	PlanStatusArchived PlanStatus = "archived"
 )
 
+// IsValid checks if the status is known
+func (s PlanStatus) IsValid() bool {
+	switch s {
+	case PlanStatusActive, PlanStatusInactive, PlanStatusArchived:
+		return true
+	}
+	return false
+}
+
 // PlanPeriod represents billing period
 type PlanPeriod string
 
@@ -26,6 +36,15 @@ const (
 	PlanPeriodLifetime PlanPeriod = "lifetime"
 )
 
+// IsValid checks if the period is known
+func (p PlanPeriod) IsValid() bool {
+	switch p {
+	case PlanPeriodDaily, PlanPeriodWeekly, PlanPeriodMonthly, PlanPeriodYearly, PlanPeriodLifetime:
+		return true
+	}
+	return false
+}
+
 // Plan represents a subscription plan
 type Plan struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
@@ -46,6 +65,9 @@ type Plan struct {
 	// Traffic limits
 	TrafficQuota int64 `json:"traffic_quota" gorm:"not null;default:0;comment:Monthly traffic quota in bytes, 0 = unlimited"`
 	SpeedLimit   int64 `json:"speed_limit" gorm:"not null;default:0;comment:Speed limit in bytes/sec, 0 = unlimited"`
+	// QuotaWarningThresholds are the percentages of the quota used that warn
+	// the users of the plan, ascending. Empty uses the configured defaults.
+	QuotaWarningThresholds []int `json:"quota_warning_thresholds,omitempty" gorm:"serializer:json;type:text"`
 	
 	// Connection limits
 	DeviceLimit      int `json:"device_limit" gorm:"not null;default:1;comment:Maximum concurrent devices"`
@@ -142,6 +164,55 @@ func (p *Plan) GetCurrentPrice() int64 {
 	return p.Price
 }
 
+// ExpiryFrom returns when one billing period starting at start ends, or nil
+// for lifetime plans
+func (p *Plan) ExpiryFrom(start time.Time) *time.Time {
+	var end time.Time
+	switch p.Period {
+	case PlanPeriodDaily:
+		end = start.AddDate(0, 0, 1)
+	case PlanPeriodWeekly:
+		end = start.AddDate(0, 0, 7)
+	case PlanPeriodMonthly:
+		end = start.AddDate(0, 1, 0)
+	case PlanPeriodYearly:
+		end = start.AddDate(1, 0, 0)
+	default:
+		return nil
+	}
+	return &end
+}
+
+// ProrationCredit returns the value of the time left until expiresAt, as the
+// share of the current price of one period. Lifetime plans and expired
+// periods have no credit.
+func (p *Plan) ProrationCredit(expiresAt, now time.Time) int64 {
+	if !expiresAt.After(now) {
+		return 0
+	}
+	var start time.Time
+	switch p.Period {
+	case PlanPeriodDaily:
+		start = expiresAt.AddDate(0, 0, -1)
+	case PlanPeriodWeekly:
+		start = expiresAt.AddDate(0, 0, -7)
+	case PlanPeriodMonthly:
+		start = expiresAt.AddDate(0, -1, 0)
+	case PlanPeriodYearly:
+		start = expiresAt.AddDate(-1, 0, 0)
+	default:
+		return 0
+	}
+
+	remaining := expiresAt.Sub(now)
+	period := expiresAt.Sub(start)
+	if remaining > period {
+		// Renewals stack periods, only credit a single one
+		remaining = period
+	}
+	return int64(math.Round(float64(p.GetCurrentPrice()) * float64(remaining) / float64(period)))
+}
+
 // GetTrafficQuotaGB returns traffic quota in GB
 func (p *Plan) GetTrafficQuotaGB() float64 {
 	if p.TrafficQuota <= 0 {
@@ -158,6 +229,23 @@ func (p *Plan) GetSpeedLimitMbps() float64 {
 	return float64(p.SpeedLimit) * 8 / (1024 * 1024)
 }
 
+// CrossedQuotaThreshold returns the highest of the ascending thresholds that
+// used reached as a percentage of quota, 0 when it reached none or the quota
+// is unlimited
+func CrossedQuotaThreshold(used, quota int64, thresholds []int) int {
+	if quota <= 0 {
+		return 0
+	}
+	crossed := 0
+	for _, threshold := range thresholds {
+		// Compared in integers, so that 80% of an odd quota is not rounded down
+		if used*100 >= quota*int64(threshold) {
+			crossed = threshold
+		}
+	}
+	return crossed
+}
+
 // CanAcceptNewUser checks if plan can accept new users
 func (p *Plan) CanAcceptNewUser() bool {
 	return p.IsAvailable()
@@ -236,4 +324,21 @@ type PlanNodeAccess struct {
 // TableName returns the table name for PlanNodeAccess model
 func (PlanNodeAccess) TableName() string {
 	return "plan_node_access"
-}
\ No newline at end of file
+}
+// PlanGroupAccess grants a plan access to every node of a node group
+type PlanGroupAccess struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PlanID  uint `json:"plan_id" gorm:"not null;uniqueIndex:idx_plan_group_access"`
+	GroupID uint `json:"group_id" gorm:"not null;uniqueIndex:idx_plan_group_access;index"`
+
+	IsEnabled bool `json:"is_enabled" gorm:"not null;default:true"`
+	Priority  int  `json:"priority" gorm:"not null;default:0;comment:Lower number means higher priority"`
+}
+
+// TableName returns the table name for PlanGroupAccess model
+func (PlanGroupAccess) TableName() string {
+	return "plan_group_access"
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxBlockedDomains bounds the blocked domain rules of a plan
+const MaxBlockedDomains = 1000
+
+// Prefixes of blocked domain rules. Rules without one block a domain and its
+// subdomains.
+const (
+	domainRuleFull    = "full:"
+	domainRuleKeyword = "keyword:"
+	domainRuleRegexp  = "regexp:"
+)
+
+// domainPattern matches domain names and domain suffixes such as ".example.com"
+var domainPattern = regexp.MustCompile(`^\.?([A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// AllowedProtocolList returns the protocols the plan allows, empty when it
+// allows every protocol
+func (p *Plan) AllowedProtocolList() []string {
+	return splitList(strings.ToLower(p.AllowedProtocols))
+}
+
+// AllowsProtocol reports whether users of the plan may use a protocol
+func (p *Plan) AllowsProtocol(protocol string) bool {
+	protocols := p.AllowedProtocolList()
+	if len(protocols) == 0 {
+		return true
+	}
+	for _, allowed := range protocols {
+		if allowed == strings.ToLower(protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedDomainList returns the blocked domain rules of the plan
+func (p *Plan) BlockedDomainList() []string {
+	return splitList(p.BlockedDomains)
+}
+
+// RouteRules renders the restrictions of the plan as the sing-box route
+// rules of one of its users on a node, a JSON array that is empty when the
+// plan restricts nothing. The rules match the user by its sing-box user name
+// and reject the blocked domains.
+func (p *Plan) RouteRules(username string) string {
+	rule := &planRouteRule{AuthUser: []string{username}, Action: "reject"}
+	for _, domain := range p.BlockedDomainList() {
+		switch {
+		case strings.HasPrefix(domain, domainRuleFull):
+			rule.Domain = append(rule.Domain, strings.TrimPrefix(domain, domainRuleFull))
+		case strings.HasPrefix(domain, domainRuleKeyword):
+			rule.DomainKeyword = append(rule.DomainKeyword, strings.TrimPrefix(domain, domainRuleKeyword))
+		case strings.HasPrefix(domain, domainRuleRegexp):
+			rule.DomainRegex = append(rule.DomainRegex, strings.TrimPrefix(domain, domainRuleRegexp))
+		default:
+			rule.DomainSuffix = append(rule.DomainSuffix, domain)
+		}
+	}
+
+	rules := []*planRouteRule{}
+	if len(rule.Domain)+len(rule.DomainSuffix)+len(rule.DomainKeyword)+len(rule.DomainRegex) > 0 {
+		rules = append(rules, rule)
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(rules)
+	return strings.TrimSpace(buf.String())
+}
+
+// planRouteRule is a sing-box route rule rendered from a plan
+type planRouteRule struct {
+	AuthUser      []string `json:"auth_user"`
+	Domain        []string `json:"domain,omitempty"`
+	DomainSuffix  []string `json:"domain_suffix,omitempty"`
+	DomainKeyword []string `json:"domain_keyword,omitempty"`
+	DomainRegex   []string `json:"domain_regex,omitempty"`
+	Action        string   `json:"action"`
+}
+
+// validatePlanRouting checks the allowed protocols and blocked domains of a
+// plan. Allowed protocols must be node types.
+func (v *validator) validatePlanRouting(p *Plan) {
+	for _, protocol := range p.AllowedProtocolList() {
+		v.check(NodeType(protocol).IsValid(), "allowed_protocols", protocol,
+			fmt.Sprintf("unknown protocol %q, expected a node type such as vless or trojan", protocol))
+	}
+
+	domains := p.BlockedDomainList()
+	v.check(len(domains) <= MaxBlockedDomains, "blocked_domains", "",
+		fmt.Sprintf("blocked_domains cannot have more than %d rules", MaxBlockedDomains))
+	for _, domain := range domains {
+		switch {
+		case strings.HasPrefix(domain, domainRuleRegexp):
+			_, err := regexp.Compile(strings.TrimPrefix(domain, domainRuleRegexp))
+			v.check(err == nil, "blocked_domains", domain, "invalid regular expression")
+		case strings.HasPrefix(domain, domainRuleKeyword):
+			v.check(len(domain) > len(domainRuleKeyword), "blocked_domains", domain, "keyword is empty")
+		default:
+			v.check(domainPattern.MatchString(strings.TrimPrefix(domain, domainRuleFull)), "blocked_domains", domain,
+				"blocked domains must be domain names, optionally prefixed by full:, keyword: or regexp:")
+		}
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanAllowsProtocol(t *testing.T) {
+	plan := &Plan{}
+	if !plan.AllowsProtocol("trojan") {
+		t.Error("plan without allowed protocols should allow every protocol")
+	}
+
+	plan.AllowedProtocols = "VLESS, trojan,"
+	if got := plan.AllowedProtocolList(); !reflect.DeepEqual(got, []string{"vless", "trojan"}) {
+		t.Errorf("AllowedProtocolList() = %v", got)
+	}
+	if !plan.AllowsProtocol("vless") || plan.AllowsProtocol("vmess") {
+		t.Errorf("AllowsProtocol() does not follow %q", plan.AllowedProtocols)
+	}
+}
+
+func TestPlanRouteRules(t *testing.T) {
+	plan := &Plan{}
+	if got := plan.RouteRules("user1"); got != "[]" {
+		t.Errorf("unrestricted RouteRules() = %s", got)
+	}
+
+	plan.BlockedDomains = "example.com, full:ads.example.org, keyword:torrent, regexp:^.+\\.cn$"
+	want := `[{"auth_user":["user1"],"domain":["ads.example.org"],"domain_suffix":["example.com"],` +
+		`"domain_keyword":["torrent"],"domain_regex":["^.+\\.cn$"],"action":"reject"}]`
+	if got := plan.RouteRules("user1"); got != want {
+		t.Errorf("RouteRules() = %s, want %s", got, want)
+	}
+}
+
+func TestPlanValidateRouting(t *testing.T) {
+	tests := []struct {
+		name      string
+		protocols string
+		domains   string
+		want      []string
+	}{
+		{"valid", "vless,hysteria2", ".example.com,full:a.example.org,keyword:ads,regexp:^ads\\.", nil},
+		{"unknown protocol", "vless,wireguard", "", []string{"allowed_protocols"}},
+		{"invalid domain", "", "exa mple.com", []string{"blocked_domains"}},
+		{"invalid regexp", "", "regexp:(", []string{"blocked_domains"}},
+		{"empty keyword", "", "keyword:", []string{"blocked_domains"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &Plan{
+				Name:             "Basic",
+				Status:           PlanStatusActive,
+				Period:           PlanPeriodMonthly,
+				Currency:         "USD",
+				AllowedProtocols: tt.protocols,
+				BlockedDomains:   tt.domains,
+			}
+			if got := invalidFields(t, plan.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlanProrationCredit(t *testing.T) {
+	now := time.Date(2026, 3, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		period    PlanPeriod
+		price     int64
+		expiresAt time.Time
+		want      int64
+	}{
+		{"half of a 31 day month left", PlanPeriodMonthly, 1000, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), 500},
+		{"one day of a week left", PlanPeriodWeekly, 700, now.AddDate(0, 0, 1), 100},
+		{"stacked renewals credit one period", PlanPeriodMonthly, 1000, now.AddDate(0, 3, 0), 1000},
+		{"expired", PlanPeriodMonthly, 1000, now.Add(-time.Hour), 0},
+		{"lifetime", PlanPeriodLifetime, 1000, now.AddDate(0, 0, 10), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &Plan{Period: tt.period, Price: tt.price}
+			if got := plan.ProrationCredit(tt.expiresAt, now); got != tt.want {
+				t.Errorf("ProrationCredit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserApplyPlan(t *testing.T) {
+	now := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+	expires := now.AddDate(0, 0, 10)
+	expired := now.AddDate(0, 0, -1)
+	plan := &Plan{ID: 2, Period: PlanPeriodMonthly, TrafficQuota: 100}
+
+	tests := []struct {
+		name        string
+		renew       bool
+		expiresAt   *time.Time
+		wantExpiry  time.Time
+		wantTraffic int64
+	}{
+		{"renewal extends the running period", true, &expires, expires.AddDate(0, 1, 0), 40},
+		{"renewal of an expired plan starts now", true, &expired, now.AddDate(0, 1, 0), 40},
+		{"upgrade starts now and resets traffic", false, &expires, now.AddDate(0, 1, 0), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{PlanID: 1, ExpiresAt: tt.expiresAt, TrafficUsed: 40, Status: UserStatusExpired}
+			user.ApplyPlan(plan, tt.renew, now)
+			if user.PlanID != plan.ID || user.TrafficQuota != plan.TrafficQuota {
+				t.Errorf("plan not applied: plan_id=%d quota=%d", user.PlanID, user.TrafficQuota)
+			}
+			if user.ExpiresAt == nil || !user.ExpiresAt.Equal(tt.wantExpiry) {
+				t.Errorf("ExpiresAt = %v, want %v", user.ExpiresAt, tt.wantExpiry)
+			}
+			if user.TrafficUsed != tt.wantTraffic {
+				t.Errorf("TrafficUsed = %d, want %d", user.TrafficUsed, tt.wantTraffic)
+			}
+			if user.Status != UserStatusActive {
+				t.Errorf("Status = %q, want %q", user.Status, UserStatusActive)
+			}
+		})
+	}
+}
+
+func TestUserApplyTrialPlan(t *testing.T) {
+	now := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+	trial := &Plan{ID: 3, Period: PlanPeriodMonthly, IsTrialPlan: true, TrialDays: 7}
+	user := &User{PlanID: 1}
+	if !user.CanStartTrial() {
+		t.Fatal("new user cannot start a trial")
+	}
+
+	user.ApplyPlan(trial, false, now)
+	end := now.AddDate(0, 0, 7)
+	if user.ExpiresAt == nil || !user.ExpiresAt.Equal(end) || user.TrialEndsAt == nil || !user.TrialEndsAt.Equal(end) {
+		t.Errorf("trial ends %v, expires %v, want %v", user.TrialEndsAt, user.ExpiresAt, end)
+	}
+	if user.CanStartTrial() || !user.OnTrial() {
+		t.Error("trial not started")
+	}
+
+	// A paid plan ends the trial but keeps it used
+	user.ApplyPlan(&Plan{ID: 2, Period: PlanPeriodMonthly}, false, now.AddDate(0, 0, 3))
+	if user.OnTrial() || user.CanStartTrial() || !user.TrialStartedAt.Equal(now) {
+		t.Errorf("trial after upgrade: started %v, ends %v", user.TrialStartedAt, user.TrialEndsAt)
+	}
+}
+
+func TestUserSpeedLimitOn(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     int64
+		override int64
+		node     int64
+		want     int64
+	}{
+		{"unlimited", 0, 0, 0, 0},
+		{"user limit", 1000, 0, 0, 1000},
+		{"plan override replaces the user limit", 1000, 4000, 0, 4000},
+		{"node caps the user limit", 4000, 0, 1000, 1000},
+		{"node caps the override", 0, 4000, 2000, 2000},
+		{"node limits unlimited users", 0, 0, 2000, 2000},
+		{"node above the user limit", 1000, 0, 2000, 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{SpeedLimit: tt.user}
+			if got := user.SpeedLimitOn(&Node{SpeedLimit: tt.node}, tt.override); got != tt.want {
+				t.Errorf("SpeedLimitOn() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrossedQuotaThreshold(t *testing.T) {
+	thresholds := []int{50, 80, 95}
+	tests := []struct {
+		name  string
+		used  int64
+		quota int64
+		want  int
+	}{
+		{"below all", 49, 100, 0},
+		{"first reached", 50, 100, 50},
+		{"highest of several reached", 96, 100, 95},
+		{"just below is not rounded up", 79, 99, 50},
+		{"unlimited", 1000, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CrossedQuotaThreshold(tt.used, tt.quota, thresholds); got != tt.want {
+				t.Errorf("CrossedQuotaThreshold() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanQuotaThresholdsValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds []int
+		wantErr    bool
+	}{
+		{"defaults", nil, false},
+		{"ascending", []int{50, 80, 95}, false},
+		{"not ascending", []int{80, 50}, true},
+		{"duplicate", []int{80, 80}, true},
+		{"whole quota", []int{50, 100}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &Plan{Name: "Basic", Status: PlanStatusActive, Period: PlanPeriodMonthly, Currency: "USD", QuotaWarningThresholds: tt.thresholds}
+			got := slices.Contains(invalidFields(t, plan.Validate()), "quota_warning_thresholds")
+			if got != tt.wantErr {
+				t.Errorf("quota_warning_thresholds invalid = %v, want %v", got, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// PortalStatus is the compact account status the user portal polls. Field
+// names are short and times are Unix seconds to keep the payload small on
+// mobile connections; zero values are left out.
+type PortalStatus struct {
+	// Active reports whether the account may connect
+	Active bool       `json:"active"`
+	Status UserStatus `json:"status"`
+	// Used and Quota are in bytes, Quota is 0 for unlimited traffic
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota,omitempty"`
+	// ResetAt is when the used traffic is reset, ExpiresAt when the account expires
+	ResetAt   int64 `json:"reset_at,omitempty"`
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// Unread is the number of unread notifications
+	Unread int64 `json:"unread,omitempty"`
+	// Sub is a prefix of the hash of the last served subscription, it
+	// changes when the client should update its profile
+	Sub string `json:"sub,omitempty"`
+}
+
+// NewPortalStatus summarizes the status of a user for the portal
+func NewPortalStatus(user *User, unread int64) PortalStatus {
+	status := PortalStatus{
+		Active: user.IsActive(),
+		Status: user.Status,
+		Used:   user.TrafficUsed,
+		Unread: unread,
+	}
+	if user.TrafficQuota > 0 {
+		status.Quota = user.TrafficQuota
+	}
+	if !user.TrafficResetDate.IsZero() {
+		status.ResetAt = user.TrafficResetDate.Unix()
+	}
+	if user.ExpiresAt != nil {
+		status.ExpiresAt = user.ExpiresAt.Unix()
+	}
+	if len(user.SubscriptionHash) >= 12 {
+		status.Sub = user.SubscriptionHash[:12]
+	}
+	return status
+}
+
+// ETag returns a strong entity tag of the status, equal for equal statuses
+func (s PortalStatus) ETag() string {
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// WebAppManifest is the web app manifest that makes the user portal
+// installable as a Progressive Web App
+type WebAppManifest struct {
+	Name            string       `json:"name"`
+	ShortName       string       `json:"short_name"`
+	StartURL        string       `json:"start_url"`
+	Scope           string       `json:"scope"`
+	Display         string       `json:"display"`
+	ThemeColor      string       `json:"theme_color,omitempty"`
+	BackgroundColor string       `json:"background_color,omitempty"`
+	Icons           []WebAppIcon `json:"icons,omitempty"`
+}
+
+// WebAppIcon is an icon listed in a web app manifest
+type WebAppIcon struct {
+	Src   string `json:"src"`
+	Sizes string `json:"sizes,omitempty"`
+	Type  string `json:"type,omitempty"`
+}
+
+// manifestShortNameLen is the length launchers display without truncation
+const manifestShortNameLen = 12
+
+// NewWebAppManifest builds the manifest of the portal from its branding
+func NewWebAppManifest(branding Branding, themeColor, backgroundColor string) WebAppManifest {
+	name := branding.PanelName
+	if name == "" {
+		name = "sing-box-web"
+	}
+	shortName := []rune(name)
+	if len(shortName) > manifestShortNameLen {
+		shortName = shortName[:manifestShortNameLen]
+	}
+
+	manifest := WebAppManifest{
+		Name:            name,
+		ShortName:       string(shortName),
+		StartURL:        "/",
+		Scope:           "/",
+		Display:         "standalone",
+		ThemeColor:      themeColor,
+		BackgroundColor: backgroundColor,
+	}
+	if branding.LogoURL != "" {
+		manifest.Icons = []WebAppIcon{{Src: branding.LogoURL, Sizes: "any"}}
+	}
+	return manifest
+}
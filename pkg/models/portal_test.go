@@ -0,0 +1,51 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewPortalStatus(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour)
+	user := &User{
+		Status:           UserStatusActive,
+		TrafficQuota:     100,
+		TrafficUsed:      40,
+		ExpiresAt:        &expires,
+		SubscriptionHash: "0123456789abcdef0123",
+	}
+
+	status := NewPortalStatus(user, 2)
+	if !status.Active || status.Used != 40 || status.Quota != 100 || status.Unread != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if status.ExpiresAt != expires.Unix() || status.ResetAt != 0 {
+		t.Fatalf("unexpected times %+v", status)
+	}
+	if status.Sub != "0123456789ab" {
+		t.Fatalf("Sub = %q", status.Sub)
+	}
+
+	etag := status.ETag()
+	if etag != NewPortalStatus(user, 2).ETag() {
+		t.Fatal("equal statuses have different ETags")
+	}
+	user.TrafficUsed = 41
+	if etag == NewPortalStatus(user, 2).ETag() {
+		t.Fatal("changed status kept its ETag")
+	}
+}
+
+func TestNewWebAppManifest(t *testing.T) {
+	manifest := NewWebAppManifest(Branding{PanelName: "Example Network Panel", LogoURL: "https://example.com/logo.png"}, "#000000", "")
+	if manifest.Name != "Example Network Panel" || manifest.ShortName != "Example Netw" {
+		t.Fatalf("unexpected names %q, %q", manifest.Name, manifest.ShortName)
+	}
+	if manifest.Display != "standalone" || manifest.StartURL != "/" || len(manifest.Icons) != 1 {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	if manifest := NewWebAppManifest(Branding{}, "", ""); manifest.Name != "sing-box-web" || manifest.Icons != nil {
+		t.Fatalf("unexpected default manifest %+v", manifest)
+	}
+}
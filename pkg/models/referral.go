@@ -0,0 +1,187 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReferralCommissionType represents how referrers are rewarded
+type ReferralCommissionType string
+
+const (
+	// ReferralCommissionBalance credits a share of the order total, paid out by an admin
+	ReferralCommissionBalance ReferralCommissionType = "balance"
+	// ReferralCommissionTraffic adds bytes to the referrer's traffic quota
+	ReferralCommissionTraffic ReferralCommissionType = "traffic"
+)
+
+// IsValid checks if the commission type is known
+func (t ReferralCommissionType) IsValid() bool {
+	return t == ReferralCommissionBalance || t == ReferralCommissionTraffic
+}
+
+// ReferralCommissionStatus represents the state of a commission
+type ReferralCommissionStatus string
+
+const (
+	// ReferralCommissionPending is a balance commission owed to the referrer
+	ReferralCommissionPending ReferralCommissionStatus = "pending"
+	// ReferralCommissionPaid is a balance commission paid out
+	ReferralCommissionPaid ReferralCommissionStatus = "paid"
+	// ReferralCommissionCredited is a traffic commission added to the quota
+	ReferralCommissionCredited ReferralCommissionStatus = "credited"
+	// ReferralCommissionCancelled is a commission of a refunded order
+	ReferralCommissionCancelled ReferralCommissionStatus = "cancelled"
+)
+
+// IsValid checks if the commission status is known
+func (s ReferralCommissionStatus) IsValid() bool {
+	switch s {
+	case ReferralCommissionPending, ReferralCommissionPaid, ReferralCommissionCredited, ReferralCommissionCancelled:
+		return true
+	}
+	return false
+}
+
+// ReferralSettingsID is the primary key of the single ReferralSettings row
+const ReferralSettingsID = 1
+
+// ReferralSettings configures the commission paid for orders of referred
+// users. There is a single row, edited by admins.
+type ReferralSettings struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Enabled        bool                   `json:"enabled" gorm:"not null;default:false"`
+	CommissionType ReferralCommissionType `json:"commission_type" gorm:"not null;size:20"`
+	// CommissionRate is the percentage of the order total for balance commissions
+	CommissionRate int `json:"commission_rate" gorm:"not null;default:0"`
+	// TrafficBonus is the bytes added per paid order for traffic commissions
+	TrafficBonus int64 `json:"traffic_bonus" gorm:"not null;default:0"`
+	// FirstOrderOnly rewards only the first paid order of each referred user
+	FirstOrderOnly bool   `json:"first_order_only" gorm:"not null;default:false"`
+	UpdatedBy      string `json:"updated_by" gorm:"size:64"`
+}
+
+// TableName returns the table name for ReferralSettings model
+func (ReferralSettings) TableName() string {
+	return "referral_settings"
+}
+
+// DefaultReferralSettings returns the settings used before an admin saved any
+func DefaultReferralSettings() *ReferralSettings {
+	return &ReferralSettings{ID: ReferralSettingsID, CommissionType: ReferralCommissionBalance, CommissionRate: 10}
+}
+
+// Commission returns the commission earned by a paid order, zero when the
+// settings give nothing for it
+func (s *ReferralSettings) Commission(order *Order) (amount int64, currency string) {
+	switch s.CommissionType {
+	case ReferralCommissionBalance:
+		return order.Total * int64(s.CommissionRate) / 100, order.Currency
+	case ReferralCommissionTraffic:
+		return s.TrafficBonus, ""
+	}
+	return 0, ""
+}
+
+// Validate checks the referral settings fields
+func (s *ReferralSettings) Validate() error {
+	v := &validator{}
+	v.check(s.CommissionType.IsValid(), "commission_type", string(s.CommissionType),
+		"commission_type must be one of balance, traffic")
+	v.check(s.CommissionRate >= 0 && s.CommissionRate <= 100, "commission_rate", fmt.Sprint(s.CommissionRate),
+		"commission_rate must be between 0 and 100")
+	v.checkRange(s.TrafficBonus, MaxTrafficQuota, "traffic_bonus")
+	return v.err()
+}
+
+// ReferralCode is the code a user shares to refer others
+type ReferralCode struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID uint   `json:"user_id" gorm:"uniqueIndex;not null"`
+	Code   string `json:"code" gorm:"uniqueIndex;not null;size:16"`
+}
+
+// TableName returns the table name for ReferralCode model
+func (ReferralCode) TableName() string {
+	return "referral_codes"
+}
+
+// NewReferralCode generates a new random referral code
+func NewReferralCode() string {
+	return strings.ToUpper(generateToken(4))
+}
+
+// NormalizeReferralCode returns the stored form of a referral code
+func NormalizeReferralCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Referral records a user who signed up with another user's referral code
+type Referral struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ReferrerID uint   `json:"referrer_id" gorm:"not null;index"`
+	UserID     uint   `json:"user_id" gorm:"uniqueIndex;not null;comment:Referred user"`
+	Code       string `json:"code" gorm:"not null;size:16"`
+
+	// Filled by listings
+	Username   string `json:"username" gorm:"-"`
+	PaidOrders int64  `json:"paid_orders" gorm:"-"`
+}
+
+// TableName returns the table name for Referral model
+func (Referral) TableName() string {
+	return "referrals"
+}
+
+// ReferralCommission is a reward owed or given to a referrer, either for a
+// paid order of a referred user or as a manual adjustment by an admin
+type ReferralCommission struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ReferrerID     uint  `json:"referrer_id" gorm:"not null;index"`
+	ReferredUserID uint  `json:"referred_user_id" gorm:"not null;default:0;comment:0 for adjustments"`
+	OrderID        *uint `json:"order_id,omitempty" gorm:"uniqueIndex;comment:Rewarded order, nil for adjustments"`
+
+	Type ReferralCommissionType `json:"type" gorm:"not null;size:20"`
+	// Amount is in cents for balance commissions and bytes for traffic ones,
+	// negative for adjustments taking back a reward
+	Amount   int64                    `json:"amount" gorm:"not null;default:0"`
+	Currency string                   `json:"currency" gorm:"size:3"`
+	Status   ReferralCommissionStatus `json:"status" gorm:"not null;size:20;index"`
+	Note     string                   `json:"note" gorm:"size:255"`
+	Operator string                   `json:"operator" gorm:"size:64;comment:Admin who made the adjustment or payout"`
+	PaidAt   *time.Time               `json:"paid_at,omitempty"`
+}
+
+// TableName returns the table name for ReferralCommission model
+func (ReferralCommission) TableName() string {
+	return "referral_commissions"
+}
+
+// ReferralStats summarizes the referrals of a user
+type ReferralStats struct {
+	ReferredUsers int64 `json:"referred_users"`
+	// PayingUsers counts referred users with at least one paid order
+	PayingUsers int64 `json:"paying_users"`
+	PaidOrders  int64 `json:"paid_orders"`
+	// Balances of balance commissions, per currency, in cents
+	Balances []ReferralBalance `json:"balances"`
+	// TrafficBonus is the bytes credited by traffic commissions
+	TrafficBonus int64 `json:"traffic_bonus"`
+}
+
+// ReferralBalance is what a referrer earned in one currency
+type ReferralBalance struct {
+	Currency string `json:"currency"`
+	Pending  int64  `json:"pending"`
+	Paid     int64  `json:"paid"`
+}
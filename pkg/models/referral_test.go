@@ -0,0 +1,36 @@
+package models
+
+import "testing"
+
+func TestReferralSettingsCommission(t *testing.T) {
+	order := &Order{Total: 1999, Currency: "USD"}
+
+	tests := []struct {
+		name         string
+		settings     ReferralSettings
+		wantAmount   int64
+		wantCurrency string
+	}{
+		{"balance", ReferralSettings{CommissionType: ReferralCommissionBalance, CommissionRate: 10}, 199, "USD"},
+		{"zero rate", ReferralSettings{CommissionType: ReferralCommissionBalance}, 0, "USD"},
+		{"traffic", ReferralSettings{CommissionType: ReferralCommissionTraffic, CommissionRate: 10, TrafficBonus: 1 << 30}, 1 << 30, ""},
+		{"unknown type", ReferralSettings{CommissionType: "points", CommissionRate: 10}, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, currency := tt.settings.Commission(order)
+			if amount != tt.wantAmount || currency != tt.wantCurrency {
+				t.Errorf("Commission() = %d %q, want %d %q", amount, currency, tt.wantAmount, tt.wantCurrency)
+			}
+		})
+	}
+}
+
+func TestNormalizeReferralCode(t *testing.T) {
+	for _, code := range []string{"AB12CD34", " ab12cd34 ", "Ab12Cd34\n"} {
+		if got := NormalizeReferralCode(code); got != "AB12CD34" {
+			t.Errorf("NormalizeReferralCode(%q) = %q, want %q", code, got, "AB12CD34")
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Environment is the deployment environment the panel runs in
+type Environment string
+
+const (
+	EnvironmentProduction Environment = "production"
+	EnvironmentStaging    Environment = "staging"
+)
+
+// IsValid checks if the environment is known
+func (e Environment) IsValid() bool {
+	return e == EnvironmentProduction || e == EnvironmentStaging
+}
+
+// SafetyAction is what a safety rule does to a matching operation
+type SafetyAction string
+
+const (
+	// SafetyActionConfirm requires the rule's confirmation text with the request
+	SafetyActionConfirm SafetyAction = "confirm"
+	// SafetyActionDeny rejects the operation outright
+	SafetyActionDeny SafetyAction = "deny"
+)
+
+// IsValid checks if the safety action is known
+func (a SafetyAction) IsValid() bool {
+	return a == SafetyActionConfirm || a == SafetyActionDeny
+}
+
+// Operations guarded by the safety policy
+const (
+	SafetyOpUserDelete      = "user.delete"
+	SafetyOpNodeRemove      = "node.remove"
+	SafetyOpTenantDelete    = "tenant.delete"
+	SafetyOpCleanupRun      = "cleanup.run"
+	SafetyOpUserBatchPrefix = "user.batch_"
+	SafetyOpNodeBatchPrefix = "node.batch_"
+)
+
+// SafetyOperations lists the operation names a rule can match
+var SafetyOperations = []string{
+	SafetyOpUserDelete,
+	SafetyOpNodeRemove,
+	SafetyOpTenantDelete,
+	SafetyOpCleanupRun,
+	SafetyOpUserBatchPrefix + "enable",
+	SafetyOpUserBatchPrefix + "disable",
+	SafetyOpUserBatchPrefix + "delete",
+	SafetyOpUserBatchPrefix + "reset_traffic",
+	SafetyOpUserBatchPrefix + "update_plan",
+	SafetyOpNodeBatchPrefix + "enable",
+	SafetyOpNodeBatchPrefix + "disable",
+	SafetyOpNodeBatchPrefix + "delete",
+	SafetyOpNodeBatchPrefix + "update_config",
+}
+
+// Errors returned by SafetyPolicy.Check
+var (
+	ErrOperationDisabled    = errors.New("operation is disabled in this environment")
+	ErrConfirmationRequired = errors.New("operation requires confirmation")
+)
+
+// SafetyRule denies or asks for confirmation of an operation
+type SafetyRule struct {
+	Operation string `json:"operation"`
+	// Environments the rule applies in, empty = all
+	Environments []Environment `json:"environments,omitempty"`
+	// Threshold limits the rule to operations affecting more than this many
+	// objects, 0 = always
+	Threshold   int          `json:"threshold,omitempty"`
+	Action      SafetyAction `json:"action"`
+	ConfirmText string       `json:"confirm_text,omitempty"`
+}
+
+// matches checks if the rule applies to the operation
+func (r *SafetyRule) matches(env Environment, operation string, count int) bool {
+	return r.Operation == operation &&
+		(len(r.Environments) == 0 || slices.Contains(r.Environments, env)) &&
+		count > r.Threshold
+}
+
+// SafetyPolicyID is the primary key of the single SafetyPolicy row
+const SafetyPolicyID = 1
+
+// SafetyPolicy holds the panel environment and the rules guarding
+// destructive admin operations. There is a single row, edited through the
+// global config.
+type SafetyPolicy struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Environment Environment  `json:"environment" gorm:"not null;size:16"`
+	Rules       []SafetyRule `json:"rules" gorm:"serializer:json;type:text"`
+	UpdatedBy   string       `json:"updated_by" gorm:"size:64"`
+}
+
+// TableName returns the table name for SafetyPolicy model
+func (SafetyPolicy) TableName() string {
+	return "safety_policies"
+}
+
+// DefaultSafetyPolicy returns the policy used before an admin saved any. It
+// assumes production, so a fresh install starts locked down.
+func DefaultSafetyPolicy() *SafetyPolicy {
+	production := []Environment{EnvironmentProduction}
+	return &SafetyPolicy{
+		ID:          SafetyPolicyID,
+		Environment: EnvironmentProduction,
+		Rules: []SafetyRule{
+			{Operation: SafetyOpUserBatchPrefix + "delete", Environments: production, Threshold: 50, Action: SafetyActionDeny},
+			{Operation: SafetyOpUserBatchPrefix + "delete", Environments: production, Action: SafetyActionConfirm, ConfirmText: "delete users"},
+			{Operation: SafetyOpNodeBatchPrefix + "delete", Environments: production, Action: SafetyActionConfirm, ConfirmText: "delete nodes"},
+			{Operation: SafetyOpNodeRemove, Environments: production, Action: SafetyActionConfirm, ConfirmText: "remove node"},
+			{Operation: SafetyOpCleanupRun, Environments: production, Action: SafetyActionConfirm, ConfirmText: "run cleanup"},
+		},
+	}
+}
+
+// Check applies the policy to an operation affecting count objects. A deny
+// rule wins over confirm rules; every matching confirm rule must be satisfied
+// by the confirmation text.
+func (p *SafetyPolicy) Check(operation string, count int, confirmation string) error {
+	var confirm *SafetyRule
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.matches(p.Environment, operation, count) {
+			continue
+		}
+		if rule.Action == SafetyActionDeny {
+			if rule.Threshold > 0 {
+				return fmt.Errorf("%w: %s is limited to %d objects in %s", ErrOperationDisabled, operation, rule.Threshold, p.Environment)
+			}
+			return fmt.Errorf("%w: %s in %s", ErrOperationDisabled, operation, p.Environment)
+		}
+		if confirm == nil && strings.TrimSpace(confirmation) != strings.TrimSpace(rule.ConfirmText) {
+			confirm = rule
+		}
+	}
+	if confirm != nil {
+		return fmt.Errorf("%w: type %q to %s in %s", ErrConfirmationRequired, confirm.ConfirmText, operation, p.Environment)
+	}
+	return nil
+}
+
+// Validate checks the safety policy fields
+func (p *SafetyPolicy) Validate() error {
+	v := &validator{}
+	v.check(p.Environment.IsValid(), "environment", string(p.Environment),
+		"environment must be one of production, staging")
+	for i, rule := range p.Rules {
+		field := fmt.Sprintf("safety_rules[%d]", i)
+		v.check(slices.Contains(SafetyOperations, rule.Operation), field+".operation", rule.Operation,
+			"operation is not a guarded operation")
+		for _, env := range rule.Environments {
+			v.check(env.IsValid(), field+".environments", string(env),
+				"environments must be production or staging")
+		}
+		v.check(rule.Threshold >= 0, field+".threshold", fmt.Sprint(rule.Threshold),
+			"threshold cannot be negative")
+		v.check(rule.Action.IsValid(), field+".action", string(rule.Action),
+			"action must be one of confirm, deny")
+		v.check(rule.Action != SafetyActionConfirm || strings.TrimSpace(rule.ConfirmText) != "", field+".confirm_text", rule.ConfirmText,
+			"confirm_text is required for confirm rules")
+	}
+	return v.err()
+}
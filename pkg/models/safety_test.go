@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSafetyPolicyCheck(t *testing.T) {
+	production := DefaultSafetyPolicy()
+	staging := DefaultSafetyPolicy()
+	staging.Environment = EnvironmentStaging
+	batchDelete := SafetyOpUserBatchPrefix + "delete"
+
+	tests := []struct {
+		name         string
+		policy       *SafetyPolicy
+		operation    string
+		count        int
+		confirmation string
+		want         error
+	}{
+		{"over threshold", production, batchDelete, 51, "delete users", ErrOperationDisabled},
+		{"missing confirmation", production, batchDelete, 50, "", ErrConfirmationRequired},
+		{"wrong confirmation", production, batchDelete, 2, "delete nodes", ErrConfirmationRequired},
+		{"confirmed", production, batchDelete, 2, " delete users ", nil},
+		{"unguarded", production, SafetyOpUserBatchPrefix + "enable", 500, "", nil},
+		{"staging", staging, batchDelete, 500, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Check(tt.operation, tt.count, tt.confirmation); !errors.Is(err, tt.want) {
+				t.Errorf("Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSafetyPolicyValidate(t *testing.T) {
+	if err := DefaultSafetyPolicy().Validate(); err != nil {
+		t.Fatalf("default policy is invalid: %v", err)
+	}
+
+	policy := &SafetyPolicy{
+		Environment: "dev",
+		Rules: []SafetyRule{
+			{Operation: "user.drop", Action: SafetyActionDeny},
+			{Operation: SafetyOpCleanupRun, Environments: []Environment{"qa"}, Threshold: -1, Action: SafetyActionConfirm},
+		},
+	}
+	want := []string{
+		"environment",
+		"safety_rules[0].operation",
+		"safety_rules[1].environments",
+		"safety_rules[1].threshold",
+		"safety_rules[1].confirm_text",
+	}
+	if got := invalidFields(t, policy.Validate()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() fields = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// SavedFilterEntity is the listing a saved filter belongs to
+type SavedFilterEntity string
+
+const (
+	// SavedFilterEntityUsers filters the user list
+	SavedFilterEntityUsers SavedFilterEntity = "users"
+	// SavedFilterEntityNodes filters the node list
+	SavedFilterEntityNodes SavedFilterEntity = "nodes"
+	// SavedFilterEntityTraffic filters the traffic of a user
+	SavedFilterEntityTraffic SavedFilterEntity = "traffic"
+)
+
+// IsValid checks if the entity is known
+func (e SavedFilterEntity) IsValid() bool {
+	switch e {
+	case SavedFilterEntityUsers, SavedFilterEntityNodes, SavedFilterEntityTraffic:
+		return true
+	}
+	return false
+}
+
+// Limits of saved filters
+const (
+	MaxSavedFilterNameLength   = 64
+	MaxSavedFilterLength       = 4096
+	MaxSavedFilterSortLength   = 64
+	MaxSavedFilterColumns      = 50
+	MaxSavedFilterColumnLength = 64
+)
+
+// SavedFilter is a named filter and list view of an admin. Filter holds the
+// listing request fields as JSON, Sort and Columns are only stored for the UI.
+type SavedFilter struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OwnerID uint              `json:"owner_id" gorm:"not null;index;uniqueIndex:idx_saved_filters_name"`
+	Entity  SavedFilterEntity `json:"entity" gorm:"not null;size:16;uniqueIndex:idx_saved_filters_name"`
+	Name    string            `json:"name" gorm:"not null;size:64;uniqueIndex:idx_saved_filters_name"`
+	Filter  string            `json:"filter" gorm:"type:text"`
+	Sort    string            `json:"sort" gorm:"size:64"`
+	Columns []string          `json:"columns,omitempty" gorm:"serializer:json;type:text"`
+	// Shared makes the filter usable by every admin, only the owner can change it
+	Shared bool `json:"shared" gorm:"not null;default:false;index"`
+}
+
+// TableName returns the table name for SavedFilter model
+func (SavedFilter) TableName() string {
+	return "saved_filters"
+}
+
+// VisibleTo reports whether an admin may see and apply the filter
+func (f *SavedFilter) VisibleTo(adminID uint) bool {
+	return f.Shared || f.OwnerID == adminID
+}
+
+// Validate checks the fields of a saved filter
+func (f *SavedFilter) Validate() error {
+	v := &validator{}
+	v.check(strings.TrimSpace(f.Name) != "", "name", f.Name, "name is required")
+	v.check(len(f.Name) <= MaxSavedFilterNameLength, "name", f.Name, "name is too long")
+	v.check(f.Entity.IsValid(), "entity", string(f.Entity), "entity must be one of users, nodes, traffic")
+	v.check(len(f.Filter) <= MaxSavedFilterLength, "filter", "", "filter is too long")
+	v.check(len(f.Sort) <= MaxSavedFilterSortLength, "sort", f.Sort, "sort is too long")
+	v.check(len(f.Columns) <= MaxSavedFilterColumns, "columns", "", "too many columns")
+	for _, column := range f.Columns {
+		v.check(column != "" && len(column) <= MaxSavedFilterColumnLength, "columns", column,
+			"columns must be non-empty and at most 64 characters")
+	}
+	return v.err()
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSavedFilterValidate(t *testing.T) {
+	valid := SavedFilter{
+		OwnerID: 1,
+		Entity:  SavedFilterEntityUsers,
+		Name:    "Suspended",
+		Filter:  `{"status_filter":"suspended"}`,
+		Columns: []string{"username", "email"},
+	}
+
+	tests := []struct {
+		name   string
+		modify func(f *SavedFilter)
+		want   []string
+	}{
+		{"valid", func(f *SavedFilter) {}, nil},
+		{"blank name", func(f *SavedFilter) { f.Name = "  " }, []string{"name"}},
+		{"long name", func(f *SavedFilter) { f.Name = strings.Repeat("n", MaxSavedFilterNameLength+1) }, []string{"name"}},
+		{"unknown entity", func(f *SavedFilter) { f.Entity = "orders" }, []string{"entity"}},
+		{"long filter", func(f *SavedFilter) { f.Filter = strings.Repeat(" ", MaxSavedFilterLength+1) }, []string{"filter"}},
+		{"empty column", func(f *SavedFilter) { f.Columns = []string{"username", ""} }, []string{"columns"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := valid
+			tt.modify(&filter)
+			if got := invalidFields(t, filter.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSavedFilterVisibleTo(t *testing.T) {
+	private := SavedFilter{OwnerID: 1}
+	shared := SavedFilter{OwnerID: 1, Shared: true}
+
+	if !private.VisibleTo(1) || private.VisibleTo(2) {
+		t.Error("private filter must only be visible to its owner")
+	}
+	if !shared.VisibleTo(2) {
+		t.Error("shared filter must be visible to other admins")
+	}
+}
@@ -0,0 +1,56 @@
+package models
+
+// SearchResultType is a kind of entity the panel-wide search looks across
+type SearchResultType string
+
+const (
+	// SearchResultUsers matches users by username, email or UUID
+	SearchResultUsers SearchResultType = "users"
+	// SearchResultNodes matches nodes by name, host or tag
+	SearchResultNodes SearchResultType = "nodes"
+	// SearchResultPlans matches plans by name or description
+	SearchResultPlans SearchResultType = "plans"
+)
+
+// searchResultTypes lists the searchable types in the order results are grouped
+var searchResultTypes = []SearchResultType{
+	SearchResultUsers,
+	SearchResultNodes,
+	SearchResultPlans,
+}
+
+// SearchResultTypes returns the searchable types in result order
+func SearchResultTypes() []SearchResultType {
+	return append([]SearchResultType(nil), searchResultTypes...)
+}
+
+// Permission returns the admin permission needed to see results of the
+// type, or "" for unknown types
+func (t SearchResultType) Permission() AdminPermission {
+	switch t {
+	case SearchResultUsers:
+		return AdminPermissionUsers
+	case SearchResultNodes:
+		return AdminPermissionNodes
+	case SearchResultPlans:
+		return AdminPermissionBilling
+	}
+	return ""
+}
+
+// IsValid checks if the search result type is known
+func (t SearchResultType) IsValid() bool {
+	return t.Permission() != ""
+}
+
+// SearchableTypes returns the types an admin may see search results of,
+// in result order
+func (u *User) SearchableTypes() []SearchResultType {
+	var types []SearchResultType
+	for _, t := range searchResultTypes {
+		if u.HasAdminPermission(t.Permission()) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchableTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want []SearchResultType
+	}{
+		{"super admin", User{Role: UserRoleSuperAdmin}, []SearchResultType{SearchResultUsers, SearchResultNodes, SearchResultPlans}},
+		{"billing admin", User{Role: UserRoleAdmin, AdminPermissions: []AdminPermission{AdminPermissionBilling}}, []SearchResultType{SearchResultPlans}},
+		{"content admin", User{Role: UserRoleAdmin, AdminPermissions: []AdminPermission{AdminPermissionContent}}, nil},
+		{"user", User{Role: UserRoleUser}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.SearchableTypes(); !slices.Equal(got, tt.want) {
+				t.Errorf("SearchableTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Thresholds of the limited experience indicator. A user throttled for this
+// long within the window is likely noticing their plan's speed limit.
+const (
+	LimitedExperienceWindow           = 24 * time.Hour
+	LimitedExperienceThrottledSeconds = 10 * 60
+)
+
+// ShapingRecord holds the speed limit shaping a node applied to a user
+// during one traffic report. Reports without throttling are not stored.
+type ShapingRecord struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	UserID           uint    `json:"user_id" gorm:"not null;index"`
+	NodeID           uint    `json:"node_id" gorm:"not null;index"`
+	LimitBytesPerSec int64   `json:"limit_bytes_per_sec" gorm:"not null;default:0;comment:Speed limit in effect"`
+	ThrottleEvents   int     `json:"throttle_events" gorm:"not null;default:0;comment:Times the user ran out of tokens"`
+	ThrottledSeconds float64 `json:"throttled_seconds" gorm:"not null;default:0;comment:Time spent waiting for tokens"`
+	DelayedBytes     int64   `json:"delayed_bytes" gorm:"not null;default:0;comment:Traffic held back by the limiter"`
+	DroppedBytes     int64   `json:"dropped_bytes" gorm:"not null;default:0;comment:Traffic discarded by the limiter"`
+}
+
+// TableName returns the table name for ShapingRecord model
+func (ShapingRecord) TableName() string {
+	return "shaping_records"
+}
+
+// ShapingSummary totals the shaping records of a user, optionally of one node
+type ShapingSummary struct {
+	UserID           uint    `json:"user_id"`
+	NodeID           uint    `json:"node_id,omitempty"`
+	ThrottleEvents   int64   `json:"throttle_events"`
+	ThrottledSeconds float64 `json:"throttled_seconds"`
+	DelayedBytes     int64   `json:"delayed_bytes"`
+	DroppedBytes     int64   `json:"dropped_bytes"`
+	// LimitBytesPerSec is the highest limit reported in the period
+	LimitBytesPerSec int64 `json:"limit_bytes_per_sec"`
+}
+
+// LimitedExperience reports whether a summary over LimitedExperienceWindow
+// shows enough throttling for the user to notice, or any dropped traffic
+func (s *ShapingSummary) LimitedExperience() bool {
+	return s.ThrottledSeconds >= LimitedExperienceThrottledSeconds || s.DroppedBytes > 0
+}
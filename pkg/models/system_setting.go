@@ -0,0 +1,132 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// SettingType is the type of the value of a system setting
+type SettingType string
+
+const (
+	SettingTypeBool     SettingType = "bool"
+	SettingTypeDuration SettingType = "duration"
+)
+
+// Keys of the system settings. An unset key falls back to the value of the
+// configuration file.
+const (
+	// SettingNodeCleanupInterval is how often nodes that stopped sending
+	// heartbeats are dropped from the registered nodes
+	SettingNodeCleanupInterval = "node.cleanup_interval"
+	// SettingNodeMaxOfflineTime is how long a node may go without heartbeats
+	// before the cleanup drops it
+	SettingNodeMaxOfflineTime = "node.max_offline_time"
+	// SettingNodeRegistrationEnabled lets agents of unknown nodes register
+	// new nodes; known nodes always register again
+	SettingNodeRegistrationEnabled = "node.registration_enabled"
+	// SettingNodeAutoRenameOnConflict renames registering nodes whose name
+	// is taken instead of rejecting them
+	SettingNodeAutoRenameOnConflict = "node.auto_rename_on_conflict"
+	// SettingSubscriptionShowNodeQuality appends the probed quality rating
+	// to the outbound tags of subscriptions
+	SettingSubscriptionShowNodeQuality = "subscription.show_node_quality"
+	// SettingSubscriptionTokenGracePeriod keeps a replaced subscription token
+	// working after a self-service rotation
+	SettingSubscriptionTokenGracePeriod = "subscription.token_grace_period"
+)
+
+// SettingDefinition describes a system setting and the values it accepts
+type SettingDefinition struct {
+	Key         string
+	Type        SettingType
+	Description string
+	// Min and Max bound duration settings, zero Max is unbounded
+	Min time.Duration
+	Max time.Duration
+}
+
+// settingDefinitions are the known system settings, in display order
+var settingDefinitions = []SettingDefinition{
+	{Key: SettingNodeCleanupInterval, Type: SettingTypeDuration, Min: 10 * time.Second, Max: 24 * time.Hour,
+		Description: "How often nodes without heartbeats are dropped"},
+	{Key: SettingNodeMaxOfflineTime, Type: SettingTypeDuration, Min: 30 * time.Second, Max: 7 * 24 * time.Hour,
+		Description: "How long a node may go without heartbeats before it is dropped"},
+	{Key: SettingNodeRegistrationEnabled, Type: SettingTypeBool,
+		Description: "Whether agents may register new nodes"},
+	{Key: SettingNodeAutoRenameOnConflict, Type: SettingTypeBool,
+		Description: "Whether registering nodes with a taken name are renamed"},
+	{Key: SettingSubscriptionShowNodeQuality, Type: SettingTypeBool,
+		Description: "Whether subscriptions show the probed node quality"},
+	{Key: SettingSubscriptionTokenGracePeriod, Type: SettingTypeDuration, Max: 30 * 24 * time.Hour,
+		Description: "How long a rotated subscription token keeps working"},
+}
+
+// SettingDefinitions returns the known system settings
+func SettingDefinitions() []SettingDefinition {
+	return slices.Clone(settingDefinitions)
+}
+
+// LookupSetting returns the definition of a system setting
+func LookupSetting(key string) (SettingDefinition, bool) {
+	for _, definition := range settingDefinitions {
+		if definition.Key == key {
+			return definition, true
+		}
+	}
+	return SettingDefinition{}, false
+}
+
+// Validate checks that value is a valid value of the setting
+func (d SettingDefinition) Validate(value string) error {
+	v := &validator{}
+	switch d.Type {
+	case SettingTypeBool:
+		_, err := strconv.ParseBool(value)
+		v.check(err == nil, d.Key, value, d.Key+" must be true or false")
+	case SettingTypeDuration:
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			v.check(false, d.Key, value, d.Key+" must be a duration such as 30s or 5m")
+			break
+		}
+		v.check(duration >= d.Min, d.Key, value, fmt.Sprintf("%s must be at least %s", d.Key, d.Min))
+		v.check(d.Max == 0 || duration <= d.Max, d.Key, value, fmt.Sprintf("%s cannot exceed %s", d.Key, d.Max))
+	}
+	return v.err()
+}
+
+// SystemSetting is a setting an admin changed at runtime, overriding the
+// configuration file. The key column is named setting_key, key being
+// reserved in MySQL.
+type SystemSetting struct {
+	Key       string    `json:"key" gorm:"column:setting_key;primaryKey;size:64"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Value     string `json:"value" gorm:"not null;size:255"`
+	UpdatedBy string `json:"updated_by" gorm:"size:64"`
+}
+
+// TableName returns the table name for SystemSetting model
+func (SystemSetting) TableName() string {
+	return "system_settings"
+}
+
+// SystemSettingChange records a change of a system setting. An empty value
+// means the setting was unset.
+type SystemSettingChange struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	Key      string `json:"key" gorm:"column:setting_key;not null;size:64;index"`
+	OldValue string `json:"old_value" gorm:"size:255"`
+	NewValue string `json:"new_value" gorm:"size:255"`
+	Operator string `json:"operator" gorm:"size:100"`
+}
+
+// TableName returns the table name for SystemSettingChange model
+func (SystemSettingChange) TableName() string {
+	return "system_setting_changes"
+}
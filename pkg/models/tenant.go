@@ -0,0 +1,127 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// Tenant is a reseller whose users see the panel under the tenant's own brand.
+// Users without a tenant see the default branding from the web configuration.
+type Tenant struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+
+	Name        string `json:"name" gorm:"not null;size:64;index"`
+	Description string `json:"description" gorm:"size:255"`
+
+	Branding Branding `json:"branding" gorm:"embedded;embeddedPrefix:branding_"`
+}
+
+// TableName returns the table name for Tenant model
+func (Tenant) TableName() string {
+	return "tenants"
+}
+
+// Branding is the white-label appearance of the panel. Empty fields fall
+// back to the default branding.
+type Branding struct {
+	PanelName    string `json:"panel_name" gorm:"size:64"`
+	LogoURL      string `json:"logo_url" gorm:"size:512"`
+	SupportEmail string `json:"support_email" gorm:"size:255"`
+	SupportURL   string `json:"support_url" gorm:"size:512"`
+	// SubscriptionHost is the host, optionally with port, put in subscription links
+	SubscriptionHost string `json:"subscription_host" gorm:"size:255"`
+}
+
+// DefaultBranding returns the branding configured for users without a tenant
+func DefaultBranding(config configv1.BrandingConfig) Branding {
+	return Branding{
+		PanelName:        config.PanelName,
+		LogoURL:          config.LogoURL,
+		SupportEmail:     config.SupportEmail,
+		SupportURL:       config.SupportURL,
+		SubscriptionHost: config.SubscriptionHost,
+	}
+}
+
+// Validate checks the branding fields, empty fields are valid
+func (b Branding) Validate() error {
+	v := &validator{}
+	v.check(len(b.PanelName) <= 64, "panel_name", b.PanelName, "panel name is too long")
+	v.check(isBrandingURL(b.LogoURL, 512), "logo_url", b.LogoURL, "logo URL must be an http or https URL")
+	v.validateEmail("support_email", b.SupportEmail)
+	v.check(isBrandingURL(b.SupportURL, 512), "support_url", b.SupportURL, "support URL must be an http or https URL")
+	if b.SubscriptionHost != "" {
+		// The host is used as the authority of subscription links
+		u, err := url.Parse("//" + b.SubscriptionHost)
+		v.check(err == nil && u.Host == b.SubscriptionHost && u.Hostname() != "" && len(b.SubscriptionHost) <= 255,
+			"subscription_host", b.SubscriptionHost, "subscription host must be a host name with an optional port")
+	}
+	return v.err()
+}
+
+// isBrandingURL reports whether s is empty or an absolute http(s) URL of at most maxLen bytes
+func isBrandingURL(s string, maxLen int) bool {
+	if s == "" {
+		return true
+	}
+	u, err := url.Parse(s)
+	return err == nil && len(s) <= maxLen && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Merge returns the branding with empty fields taken from defaults
+func (b Branding) Merge(defaults Branding) Branding {
+	if b.PanelName == "" {
+		b.PanelName = defaults.PanelName
+	}
+	if b.LogoURL == "" {
+		b.LogoURL = defaults.LogoURL
+	}
+	if b.SupportEmail == "" {
+		b.SupportEmail = defaults.SupportEmail
+	}
+	if b.SupportURL == "" {
+		b.SupportURL = defaults.SupportURL
+	}
+	if b.SubscriptionHost == "" {
+		b.SubscriptionHost = defaults.SubscriptionHost
+	}
+	return b
+}
+
+// SubscriptionURL returns the subscription link of a token. Without a
+// subscription host only the path is returned.
+func (b Branding) SubscriptionURL(token string) string {
+	path := "/api/v1/subscribe/" + token
+	if b.SubscriptionHost == "" {
+		return path
+	}
+	return "https://" + b.SubscriptionHost + path
+}
+
+// MailFrom returns the From header of mails sent on behalf of the brand
+func (b Branding) MailFrom(address string) string {
+	if b.PanelName == "" {
+		return address
+	}
+	return fmt.Sprintf("%q <%s>", b.PanelName, address)
+}
+
+// MailFooter returns the signature appended to mails sent on behalf of the brand
+func (b Branding) MailFooter() string {
+	footer := b.PanelName
+	if b.SupportEmail != "" {
+		footer += "\nSupport: " + b.SupportEmail
+	}
+	if b.SupportURL != "" {
+		footer += "\n" + b.SupportURL
+	}
+	return footer
+}
@@ -0,0 +1,57 @@
+package models
+
+import "testing"
+
+func TestBrandingValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		branding  Branding
+		wantField string
+	}{
+		{"empty", Branding{}, ""},
+		{"complete", Branding{
+			PanelName:        "Example VPN",
+			LogoURL:          "https://cdn.example.com/logo.png",
+			SupportEmail:     "help@example.com",
+			SupportURL:       "https://example.com/support",
+			SubscriptionHost: "sub.example.com:8443",
+		}, ""},
+		{"relative logo", Branding{LogoURL: "/logo.png"}, "logo_url"},
+		{"email with display name", Branding{SupportEmail: "Help <help@example.com>"}, "support_email"},
+		{"support URL scheme", Branding{SupportURL: "javascript:alert(1)"}, "support_url"},
+		{"host with scheme", Branding{SubscriptionHost: "https://sub.example.com"}, "subscription_host"},
+		{"host with path", Branding{SubscriptionHost: "sub.example.com/api"}, "subscription_host"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.branding.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if fields := invalidFields(t, err); len(fields) != 1 || fields[0] != tt.wantField {
+				t.Fatalf("Validate() = %v, want error for %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestBrandingMerge(t *testing.T) {
+	defaults := Branding{PanelName: "sing-box-web", SupportEmail: "help@example.com"}
+	tenant := Branding{PanelName: "Reseller", SubscriptionHost: "sub.reseller.io"}
+
+	got := tenant.Merge(defaults)
+	want := Branding{PanelName: "Reseller", SupportEmail: "help@example.com", SubscriptionHost: "sub.reseller.io"}
+	if got != want {
+		t.Errorf("Merge() = %+v, want %+v", got, want)
+	}
+	if url := got.SubscriptionURL("abc"); url != "https://sub.reseller.io/api/v1/subscribe/abc" {
+		t.Errorf("SubscriptionURL() = %q", url)
+	}
+	if url := defaults.SubscriptionURL("abc"); url != "/api/v1/subscribe/abc" {
+		t.Errorf("SubscriptionURL() without host = %q", url)
+	}
+}
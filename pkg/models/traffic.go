@@ -94,6 +94,29 @@ func (tr *TrafficRecord) CalculateAvgSpeed() {
 	}
 }
 
+// UserOnlineWindow is how long a user counts as online after its last
+// session closed. Users with an open session are online.
+const UserOnlineWindow = 5 * time.Minute
+
+// UserSession is one connection of a user, built from the traffic records
+// that share a session ID. It is not stored in its own table.
+type UserSession struct {
+	SessionID      string     `json:"session_id"`
+	NodeID         uint       `json:"node_id"`
+	ConnectTime    time.Time  `json:"connect_time"`
+	DisconnectTime *time.Time `json:"disconnect_time,omitempty"`
+	ClientIP       string     `json:"client_ip"`
+	DeviceID       string     `json:"device_id"`
+	Protocol       string     `json:"protocol"`
+	Upload         int64      `json:"upload"`
+	Download       int64      `json:"download"`
+}
+
+// Active reports whether the session has not been closed yet
+func (s *UserSession) Active() bool {
+	return s.DisconnectTime == nil
+}
+
 // TrafficSummary represents traffic summary for reporting
 type TrafficSummary struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
@@ -250,4 +273,33 @@ func (tq *TrafficQuota) GetUsagePercentage(usage int64) float64 {
 		return 0
 	}
 	return float64(usage) / float64(tq.QuotaBytes) * 100
-}
\ No newline at end of file
+}
+// TrafficAdjustment represents a signed correction of a user's traffic usage.
+// Adjustments are append-only: original TrafficRecords are never modified and
+// an adjustment is undone by recording an opposite one.
+type TrafficAdjustment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Foreign keys
+	UserID uint  `json:"user_id" gorm:"not null;index"`
+	NodeID *uint `json:"node_id,omitempty" gorm:"index;comment:Node the corrected traffic was reported by"`
+
+	// Relationships
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	// Correction
+	Bytes         int64     `json:"bytes" gorm:"not null;comment:Signed correction in bytes"`
+	Reason        string    `json:"reason" gorm:"not null;size:512"`
+	EffectiveDate time.Time `json:"effective_date" gorm:"not null;index;comment:Statement period the correction belongs to"`
+
+	// Audit
+	Operator    string `json:"operator" gorm:"not null;size:128;comment:Who recorded the correction"`
+	UsageBefore int64  `json:"usage_before" gorm:"not null;comment:Traffic used before the correction"`
+	UsageAfter  int64  `json:"usage_after" gorm:"not null;comment:Traffic used after the correction"`
+}
+
+// TableName returns the table name for TrafficAdjustment model
+func (TrafficAdjustment) TableName() string {
+	return "traffic_adjustments"
+}
@@ -0,0 +1,23 @@
+package models
+
+import "errors"
+
+// ErrTrialUsed is returned when a user that already had a trial activates a
+// trial plan
+var ErrTrialUsed = errors.New("user already had a trial")
+
+// StartsTrial reports whether applying the plan starts a trial, which needs
+// a trial plan with trial days
+func (p *Plan) StartsTrial() bool {
+	return p.IsTrialPlan && p.TrialDays > 0
+}
+
+// CanStartTrial reports whether the user may start a trial, users get one
+func (u *User) CanStartTrial() bool {
+	return u.TrialStartedAt == nil
+}
+
+// OnTrial reports whether the trial of the user is running or due to end
+func (u *User) OnTrial() bool {
+	return u.TrialEndsAt != nil
+}
@@ -16,14 +16,36 @@ const (
 	UserStatusDisabled  UserStatus = "disabled"
 )
 
+// IsValid checks if the status is known
+func (s UserStatus) IsValid() bool {
+	switch s {
+	case UserStatusActive, UserStatusSuspended, UserStatusExpired, UserStatusDisabled:
+		return true
+	}
+	return false
+}
+
 // UserRole represents user role
 type UserRole string
 
 const (
-	UserRoleUser  UserRole = "user"
+	UserRoleUser UserRole = "user"
+	// UserRoleAdmin administers the areas granted by its admin permissions
 	UserRoleAdmin UserRole = "admin"
+	// UserRoleSuperAdmin administers everything, including other admins
+	UserRoleSuperAdmin UserRole = "super_admin"
 )
 
+// IsValid checks if the role is known
+func (r UserRole) IsValid() bool {
+	return r == UserRoleUser || r.IsAdmin()
+}
+
+// IsAdmin checks if the role may use the administration endpoints
+func (r UserRole) IsAdmin() bool {
+	return r == UserRoleAdmin || r == UserRoleSuperAdmin
+}
+
 // User represents a sing-box user
 type User struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
@@ -39,6 +61,20 @@ type User struct {
 	Avatar      string     `json:"avatar" gorm:"size:512"`
 	Status      UserStatus `json:"status" gorm:"not null;default:'active';size:20"`
 	Role        UserRole   `json:"role" gorm:"not null;default:'user';size:20"`
+	// AdminPermissions are the areas an admin may manage, unused for other roles
+	AdminPermissions []AdminPermission `json:"admin_permissions,omitempty" gorm:"serializer:json;type:text"`
+	// TenantID selects the reseller branding the user sees, nil for the default one
+	TenantID    *uint      `json:"tenant_id,omitempty" gorm:"index"`
+	// EmailVerified is set once the user followed a verification link, and
+	// cleared when the email address changes
+	EmailVerified   bool       `json:"email_verified" gorm:"not null;default:false"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// TelegramChatID receives the user's alerts from the Telegram bot, empty when not linked
+	TelegramChatID string `json:"telegram_chat_id,omitempty" gorm:"size:32"`
+	// Locale and TimeZone are the display preferences of an admin, empty to
+	// negotiate the locale per request and to use the server time zone
+	Locale   string `json:"locale,omitempty" gorm:"size:16"`
+	TimeZone string `json:"time_zone,omitempty" gorm:"size:64"`
 
 	// Plan and quota
 	PlanID            uint      `json:"plan_id" gorm:"not null"`
@@ -49,17 +85,46 @@ type User struct {
 	DeviceLimit       int       `json:"device_limit" gorm:"not null;default:1;comment:Maximum concurrent devices"`
 	SpeedLimit        int64     `json:"speed_limit" gorm:"not null;default:0;comment:Speed limit in bytes/sec"`
 
+	// Wallet, only changed through balance transactions
+	Balance         int64  `json:"balance" gorm:"not null;default:0;comment:Wallet balance in cents"`
+	BalanceCurrency string `json:"balance_currency" gorm:"size:3;comment:Currency of the balance, set by a credit to an empty wallet"`
+
 	// Account validity
 	ExpiresAt    *time.Time `json:"expires_at,omitempty" gorm:"comment:Account expiration time"`
+	// ExpiredAt is when the account expired and was removed from its nodes,
+	// cleared once it was renewed and restored to them
+	ExpiredAt    *time.Time `json:"expired_at,omitempty" gorm:"index"`
 	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
 	LastLoginIP  string     `json:"last_login_ip" gorm:"size:45"`
 	LoginAttempts int       `json:"login_attempts" gorm:"not null;default:0"`
 	LockedUntil  *time.Time `json:"locked_until,omitempty"`
 
+	// Activity, see the inactive account policy
+	LastTrafficAt *time.Time `json:"last_traffic_at,omitempty"`
+	// LastActivityAt is the latest login, traffic or reactivation
+	LastActivityAt        *time.Time `json:"last_activity_at,omitempty" gorm:"index"`
+	InactivityExempt      bool       `json:"inactivity_exempt" gorm:"not null;default:false"`
+	InactivityWarnedAt    *time.Time `json:"inactivity_warned_at,omitempty"`
+	InactivitySuspendedAt *time.Time `json:"inactivity_suspended_at,omitempty"`
+
+	// Trial, see the trial policy. TrialStartedAt stays set to allow one
+	// trial per user; TrialEndsAt is cleared once the trial ended.
+	TrialStartedAt *time.Time `json:"trial_started_at,omitempty"`
+	TrialEndsAt    *time.Time `json:"trial_ends_at,omitempty" gorm:"index"`
+
+	// Two-factor authentication
+	TwoFactorEnabled     bool       `json:"two_factor_enabled" gorm:"not null;default:false"`
+	TwoFactorSecret      string     `json:"-" gorm:"size:64;comment:Base32 TOTP secret"`
+	TwoFactorBackupCodes []string   `json:"-" gorm:"serializer:json;type:text;comment:Hashed one-time backup codes"`
+	TwoFactorEnabledAt   *time.Time `json:"two_factor_enabled_at,omitempty"`
+	TwoFactorLastStep    int64      `json:"-" gorm:"not null;default:0;comment:Time step of the last accepted TOTP code"`
+
 	// Subscription and configuration
 	UUID         string `json:"uuid" gorm:"uniqueIndex;not null;size:36;comment:User UUID for sing-box config"`
 	SubscriptionToken string `json:"subscription_token" gorm:"uniqueIndex;size:64;comment:Subscription token"`
 	ConfigVersion     int    `json:"config_version" gorm:"not null;default:0;comment:Configuration version"`
+	SubscriptionHash      string     `json:"subscription_hash" gorm:"size:64;comment:SHA-256 of last served subscription"`
+	SubscriptionUpdatedAt *time.Time `json:"subscription_updated_at,omitempty" gorm:"comment:When subscription content last changed"`
 
 	// Metadata
 	Notes    string            `json:"notes" gorm:"type:text;comment:Admin notes"`
@@ -89,6 +154,26 @@ func (u *User) IsActive() bool {
 	return true
 }
 
+// LastActiveAt returns the latest activity of the user, its creation when
+// there was none
+func (u *User) LastActiveAt() time.Time {
+	if u.LastActivityAt != nil && u.LastActivityAt.After(u.CreatedAt) {
+		return *u.LastActivityAt
+	}
+	return u.CreatedAt
+}
+
+// SuspendedForInactivity checks if the inactive account policy suspended the
+// user, who reactivates the account by logging in
+func (u *User) SuspendedForInactivity() bool {
+	return u.Status == UserStatusSuspended && u.InactivitySuspendedAt != nil
+}
+
+// RequiresTwoFactor checks if login must be completed with a second factor
+func (u *User) RequiresTwoFactor() bool {
+	return u.TwoFactorEnabled && u.TwoFactorSecret != ""
+}
+
 // IsTrafficExceeded checks if user has exceeded traffic quota
 func (u *User) IsTrafficExceeded() bool {
 	return u.TrafficQuota > 0 && u.TrafficUsed >= u.TrafficQuota
@@ -106,6 +191,20 @@ func (u *User) RemainingTraffic() int64 {
 	return remaining
 }
 
+// SpeedLimitOn returns the speed limit of the user on a node in bytes/sec,
+// 0 for none. The plan's override for the node replaces the user's limit
+// and the node's per-user limit caps both.
+func (u *User) SpeedLimitOn(node *Node, planOverride int64) int64 {
+	limit := u.SpeedLimit
+	if planOverride > 0 {
+		limit = planOverride
+	}
+	if node != nil && node.SpeedLimit > 0 && (limit == 0 || node.SpeedLimit < limit) {
+		limit = node.SpeedLimit
+	}
+	return limit
+}
+
 // ShouldResetTraffic checks if traffic should be reset
 func (u *User) ShouldResetTraffic() bool {
 	return !u.TrafficResetDate.IsZero() && time.Now().After(u.TrafficResetDate)
@@ -117,6 +216,39 @@ func (u *User) ResetTraffic() {
 	u.TrafficResetDate = time.Now().AddDate(0, 1, 0) // Next month
 }
 
+// ApplyPlan switches the user to a purchased plan and its limits. A renewal
+// extends the running period; a new plan or an upgrade starts a new period
+// now and resets the traffic used. A trial plan runs for its trial days and
+// starts the trial of the user, see StartsTrial.
+func (u *User) ApplyPlan(plan *Plan, renew bool, now time.Time) {
+	start := now
+	if renew && u.ExpiresAt != nil && u.ExpiresAt.After(now) {
+		start = *u.ExpiresAt
+	}
+
+	u.PlanID = plan.ID
+	u.TrafficQuota = plan.TrafficQuota
+	u.SpeedLimit = plan.SpeedLimit
+	u.DeviceLimit = plan.DeviceLimit
+	u.ExpiresAt = plan.ExpiryFrom(start)
+	u.TrialEndsAt = nil
+	if plan.StartsTrial() {
+		end := now.AddDate(0, 0, plan.TrialDays)
+		u.ExpiresAt = &end
+		u.TrialEndsAt = &end
+		if u.TrialStartedAt == nil {
+			u.TrialStartedAt = &now
+		}
+	}
+	if !renew {
+		u.TrafficUsed = 0
+		u.TrafficResetDate = now.AddDate(0, 1, 0)
+	}
+	if u.Status == UserStatusExpired {
+		u.Status = UserStatusActive
+	}
+}
+
 // BeforeCreate GORM hook to set defaults before creating
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.UUID == "" {
@@ -125,7 +257,7 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 	}
 	if u.SubscriptionToken == "" {
 		// Generate subscription token if not set
-		u.SubscriptionToken = generateToken(32)
+		u.SubscriptionToken = NewSubscriptionToken()
 	}
 	if u.TrafficResetDate.IsZero() {
 		u.TrafficResetDate = time.Now().AddDate(0, 1, 0)
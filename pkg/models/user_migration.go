@@ -0,0 +1,123 @@
+package models
+
+import (
+	"time"
+)
+
+// UserMigrationKind is what a user migration moves users between
+type UserMigrationKind string
+
+const (
+	// UserMigrationKindPlan moves the users of a plan to another plan
+	UserMigrationKindPlan UserMigrationKind = "plan"
+	// UserMigrationKindNode moves the users assigned to a node to another node
+	UserMigrationKindNode UserMigrationKind = "node"
+)
+
+// IsValid reports whether the kind is known
+func (k UserMigrationKind) IsValid() bool {
+	return k == UserMigrationKindPlan || k == UserMigrationKindNode
+}
+
+// UserMigrationStatus represents the state of a user migration
+type UserMigrationStatus string
+
+const (
+	UserMigrationStatusRunning   UserMigrationStatus = "running"
+	UserMigrationStatusPaused    UserMigrationStatus = "paused"
+	UserMigrationStatusCompleted UserMigrationStatus = "completed"
+	UserMigrationStatusCancelled UserMigrationStatus = "cancelled"
+)
+
+// UserMigration moves the users of a source plan or node to a target one,
+// at most UsersPerMinute a minute so that nodes are not flooded with
+// provisioning commands. Users are moved in ID order; LastUserID is the last
+// one handled, so users failing to move are passed over and stay behind.
+type UserMigration struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Kind           UserMigrationKind   `json:"kind" gorm:"not null;size:20"`
+	SourceID       uint                `json:"source_id" gorm:"not null;index"`
+	TargetID       uint                `json:"target_id" gorm:"not null"`
+	UsersPerMinute int                 `json:"users_per_minute" gorm:"not null"`
+	Status         UserMigrationStatus `json:"status" gorm:"not null;size:20;index"`
+	Operator       string              `json:"operator" gorm:"size:100"`
+
+	// Total counts the users of the source when the migration was created
+	Total      int64  `json:"total" gorm:"not null;default:0"`
+	Moved      int64  `json:"moved" gorm:"not null;default:0"`
+	Failed     int64  `json:"failed" gorm:"not null;default:0"`
+	LastUserID uint   `json:"last_user_id" gorm:"not null;default:0"`
+	LastError  string `json:"last_error" gorm:"size:512"`
+
+	// LastBatchAt is when users were last moved, or the migration started or
+	// resumed; the users due are counted from it
+	LastBatchAt *time.Time `json:"last_batch_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName returns the table name for UserMigration model
+func (UserMigration) TableName() string {
+	return "user_migrations"
+}
+
+// IsFinished reports whether the migration completed or was cancelled
+func (m *UserMigration) IsFinished() bool {
+	return m.Status == UserMigrationStatusCompleted || m.Status == UserMigrationStatusCancelled
+}
+
+// Due returns how many users are due to be moved at now, at most a
+// minute's worth so that a migration resumed or delayed does not burst
+func (m *UserMigration) Due(now time.Time) int {
+	if m.Status != UserMigrationStatusRunning || m.UsersPerMinute <= 0 {
+		return 0
+	}
+	if m.LastBatchAt == nil {
+		return m.UsersPerMinute
+	}
+	due := int(float64(m.UsersPerMinute) * now.Sub(*m.LastBatchAt).Minutes())
+	return min(due, m.UsersPerMinute)
+}
+
+// BatchDone returns the LastBatchAt after moving handled users at now. It
+// advances by the time the users were due in rather than to now, so that the
+// rate holds when ticks are shorter than the gap between users.
+func (m *UserMigration) BatchDone(handled int, now time.Time) time.Time {
+	if m.LastBatchAt == nil || m.UsersPerMinute <= 0 {
+		return now
+	}
+	next := m.LastBatchAt.Add(time.Duration(float64(handled) / float64(m.UsersPerMinute) * float64(time.Minute)))
+	if oldest := now.Add(-time.Minute); next.Before(oldest) {
+		return oldest
+	}
+	if next.After(now) {
+		return now
+	}
+	return next
+}
+
+// Progress returns the percentage of the users handled, moved or failed
+func (m *UserMigration) Progress() float64 {
+	if m.Status == UserMigrationStatusCompleted {
+		return 100
+	}
+	if m.Total <= 0 {
+		return 0
+	}
+	return min(float64(m.Moved+m.Failed)/float64(m.Total)*100, 100)
+}
+
+// Remaining estimates how long the users left take at the current rate,
+// zero unless the migration is running
+func (m *UserMigration) Remaining() time.Duration {
+	if m.Status != UserMigrationStatusRunning || m.UsersPerMinute <= 0 {
+		return 0
+	}
+	left := m.Total - m.Moved - m.Failed
+	if left <= 0 {
+		return 0
+	}
+	return time.Duration(float64(left) / float64(m.UsersPerMinute) * float64(time.Minute))
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserMigrationPacing(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) *time.Time {
+		t := now.Add(-ago)
+		return &t
+	}
+
+	tests := []struct {
+		name   string
+		status UserMigrationStatus
+		last   *time.Time
+		want   int
+	}{
+		{"first batch", UserMigrationStatusRunning, nil, 60},
+		{"ten seconds", UserMigrationStatusRunning, at(10 * time.Second), 10},
+		{"capped at a minute", UserMigrationStatusRunning, at(time.Hour), 60},
+		{"paused", UserMigrationStatusPaused, at(time.Minute), 0},
+	}
+	for _, tt := range tests {
+		m := &UserMigration{Status: tt.status, UsersPerMinute: 60, LastBatchAt: tt.last}
+		if got := m.Due(now); got != tt.want {
+			t.Errorf("%s: Due = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// The fraction of a user not yet due carries over to the next tick
+	m := &UserMigration{Status: UserMigrationStatusRunning, UsersPerMinute: 4, LastBatchAt: at(20 * time.Second)}
+	if due := m.Due(now); due != 1 {
+		t.Fatalf("Due = %d, want 1", due)
+	}
+	if next := m.BatchDone(1, now); !next.Equal(now.Add(-5 * time.Second)) {
+		t.Errorf("BatchDone = %v, want 5s before now", next)
+	}
+	m.LastBatchAt = at(time.Hour)
+	if next := m.BatchDone(4, now); !next.Equal(now.Add(-time.Minute)) {
+		t.Errorf("BatchDone after a long pause = %v, want a minute before now", next)
+	}
+}
+
+func TestUserMigrationProgress(t *testing.T) {
+	m := &UserMigration{Status: UserMigrationStatusRunning, UsersPerMinute: 10, Total: 200, Moved: 90, Failed: 10}
+	if got := m.Progress(); got != 50 {
+		t.Errorf("Progress = %v, want 50", got)
+	}
+	if got := m.Remaining(); got != 10*time.Minute {
+		t.Errorf("Remaining = %v, want 10m", got)
+	}
+
+	m.Status = UserMigrationStatusPaused
+	if got := m.Remaining(); got != 0 {
+		t.Errorf("Remaining while paused = %v, want 0", got)
+	}
+	// Users added to the source after the migration was created
+	m.Status, m.Moved = UserMigrationStatusCompleted, 230
+	if got := m.Progress(); got != 100 {
+		t.Errorf("Progress once completed = %v, want 100", got)
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 
@@ -23,6 +24,22 @@ func generateToken(length int) string {
 	return hex.EncodeToString(bytes)
 }
 
+// NewSubscriptionToken generates a new random subscription token
+func NewSubscriptionToken() string {
+	return generateToken(32)
+}
+
+// NewUserUUID generates a proxy UUID for a user
+func NewUserUUID() string {
+	return generateUUID()
+}
+
+// HashSubscriptionToken returns the hash under which a replaced subscription token is kept
+func HashSubscriptionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // FormatBytes formats bytes to human readable string
 func FormatBytes(bytes int64) string {
 	const unit = 1024
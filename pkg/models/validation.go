@@ -0,0 +1,242 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Validation rules shared by every entry point that writes models: the gRPC
+// and REST handlers, agent registration and configuration loading. Each
+// model's Validate method reports all broken rules at once as a
+// *ValidationError whose fields use the JSON names of the model.
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 64
+	MaxEmailLength    = 255
+
+	// MaxTrafficQuota bounds traffic quotas, 1 PiB
+	MaxTrafficQuota int64 = 1 << 50
+	// MaxSpeedLimit bounds speed limits in bytes/sec, 100 Gbit/s
+	MaxSpeedLimit int64 = 100_000_000_000 / 8
+	// MaxDeviceLimit bounds device and connection limits
+	MaxDeviceLimit = 10000
+
+	MaxTagLength = 32
+	MaxTags      = 16
+
+	// MaxNodeConfigSize bounds a node's sing-box config, 1 MiB
+	MaxNodeConfigSize = 1 << 20
+)
+
+var (
+	// usernamePattern allows letters, digits, dot, dash and underscore, starting with a letter or digit
+	usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// tagPattern allows letters, digits, dash and underscore
+	tagPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// colorPattern matches #RRGGBB hex color codes
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	// currencyPattern matches ISO 4217 codes
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// FieldError reports a field that breaks a validation rule
+type FieldError struct {
+	Field   string
+	Value   string
+	Message string
+}
+
+// ValidationError lists every invalid field of a model
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validator collects the broken rules of one model
+type validator struct {
+	fields []FieldError
+}
+
+// check records a broken rule when ok is false
+func (v *validator) check(ok bool, field, value, message string) {
+	if !ok {
+		v.fields = append(v.fields, FieldError{Field: field, Value: value, Message: message})
+	}
+}
+
+// checkRange records a broken rule when value is outside [0, max]
+func (v *validator) checkRange(value, max int64, field string) {
+	v.check(value >= 0, field, fmt.Sprint(value), field+" cannot be negative")
+	v.check(value <= max, field, fmt.Sprint(value), fmt.Sprintf("%s cannot exceed %d", field, max))
+}
+
+// err returns the collected rules as a *ValidationError, or nil
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// ValidateUsername checks the charset and length of a username
+func ValidateUsername(username string) error {
+	v := &validator{}
+	v.validateUsername("username", username)
+	return v.err()
+}
+
+// ValidateEmail checks that email is a plain address without a display name
+func ValidateEmail(email string) error {
+	v := &validator{}
+	v.validateEmail("email", email)
+	return v.err()
+}
+
+// ValidateNodeConfig checks that a node config is a sing-box JSON config
+func ValidateNodeConfig(content string) error {
+	v := &validator{}
+	var config map[string]any
+	v.check(content != "", "config_content", "", "config_content is required")
+	v.check(len(content) <= MaxNodeConfigSize, "config_content", "",
+		fmt.Sprintf("config_content cannot exceed %d bytes", MaxNodeConfigSize))
+	v.check(content == "" || json.Unmarshal([]byte(content), &config) == nil, "config_content", "",
+		"config_content must be a JSON object")
+	return v.err()
+}
+
+// ValidateTags checks a comma-separated tag list
+func ValidateTags(tags string) error {
+	v := &validator{}
+	v.validateTags("tags", tags)
+	return v.err()
+}
+
+func (v *validator) validateUsername(field, username string) {
+	if username == "" {
+		v.check(false, field, username, "username is required")
+		return
+	}
+	v.check(len(username) >= MinUsernameLength && len(username) <= MaxUsernameLength, field, username,
+		fmt.Sprintf("username must be %d to %d characters long", MinUsernameLength, MaxUsernameLength))
+	v.check(usernamePattern.MatchString(username), field, username,
+		"username may only contain letters, digits, '.', '-' and '_' and must start with a letter or digit")
+}
+
+func (v *validator) validateEmail(field, email string) {
+	if email == "" {
+		return
+	}
+	addr, err := mail.ParseAddress(email)
+	v.check(err == nil && addr.Address == email && len(email) <= MaxEmailLength, field, email,
+		"email must be a plain email address")
+}
+
+func (v *validator) validateTags(field, tags string) {
+	if tags == "" {
+		return
+	}
+	list := strings.Split(tags, ",")
+	v.check(len(list) <= MaxTags, field, tags, fmt.Sprintf("at most %d tags are allowed", MaxTags))
+	for _, tag := range list {
+		tag = strings.TrimSpace(tag)
+		if !tagPattern.MatchString(tag) || len(tag) > MaxTagLength {
+			v.check(false, field, tag, fmt.Sprintf(
+				"tags must be 1 to %d letters, digits, '-' or '_', separated by commas", MaxTagLength))
+			return
+		}
+	}
+}
+
+// Validate checks the user fields
+func (u *User) Validate() error {
+	v := &validator{}
+	v.validateUsername("username", u.Username)
+	v.validateEmail("email", u.Email)
+	v.check(u.Status.IsValid(), "status", string(u.Status), "status must be one of active, suspended, expired, disabled")
+	v.check(u.Role.IsValid(), "role", string(u.Role), "role must be one of user, admin, super_admin")
+	v.check(u.Role == UserRoleAdmin || len(u.AdminPermissions) == 0, "admin_permissions", "",
+		"only admins have admin permissions")
+	for _, permission := range u.AdminPermissions {
+		v.check(permission.IsValid(), "admin_permissions", string(permission),
+			"admin permissions must be one of "+strings.Join(AdminPermissionNames(), ", "))
+	}
+	v.checkRange(u.TrafficQuota, MaxTrafficQuota, "traffic_quota")
+	v.checkRange(u.TrafficUsed, MaxTrafficQuota, "traffic_used")
+	v.checkRange(u.SpeedLimit, MaxSpeedLimit, "speed_limit")
+	v.checkRange(int64(u.DeviceLimit), MaxDeviceLimit, "device_limit")
+	return v.err()
+}
+
+// Validate checks the node fields
+func (n *Node) Validate() error {
+	v := &validator{}
+	v.check(n.Name != "", "name", n.Name, "name is required")
+	v.check(len(n.Name) <= 128, "name", n.Name, "name is too long")
+	v.check(n.Host != "", "host", n.Host, "host is required")
+	v.check(len(n.Host) <= 255 && !strings.ContainsAny(n.Host, " /"), "host", n.Host, "host must be a host name or IP address")
+	v.check(n.Port >= 1 && n.Port <= 65535, "port", fmt.Sprint(n.Port), "port must be between 1 and 65535")
+	v.check(n.Type == "" || n.Type.IsValid(), "type", string(n.Type),
+		"type must be one of vmess, vless, trojan, shadowsocks, hysteria, hysteria2, tuic")
+	v.validateTags("tags", n.Tags)
+	v.checkRange(int64(n.MaxUsers), 1<<31-1, "max_users")
+	v.checkRange(n.SpeedLimit, MaxSpeedLimit, "speed_limit")
+	v.check(n.TrafficRate >= 0 && n.TrafficRate <= 100, "traffic_rate", fmt.Sprint(n.TrafficRate),
+		"traffic_rate must be between 0 and 100")
+	return v.err()
+}
+
+// Validate checks the plan fields
+func (p *Plan) Validate() error {
+	v := &validator{}
+	v.check(p.Name != "", "name", p.Name, "name is required")
+	v.check(len(p.Name) <= 128, "name", p.Name, "name is too long")
+	v.check(p.Status.IsValid(), "status", string(p.Status), "status must be one of active, inactive, archived")
+	v.check(p.Period.IsValid(), "period", string(p.Period),
+		"period must be one of daily, weekly, monthly, yearly, lifetime")
+	v.check(currencyPattern.MatchString(p.Currency), "currency", p.Currency, "currency must be a 3-letter ISO 4217 code")
+	v.check(p.Price >= 0, "price", fmt.Sprint(p.Price), "price cannot be negative")
+	v.checkRange(p.TrafficQuota, MaxTrafficQuota, "traffic_quota")
+	v.checkRange(p.SpeedLimit, MaxSpeedLimit, "speed_limit")
+	v.checkRange(int64(p.DeviceLimit), MaxDeviceLimit, "device_limit")
+	v.checkRange(int64(p.ConnectionLimit), MaxDeviceLimit, "connection_limit")
+	v.check(p.MaxUsers >= 0, "max_users", fmt.Sprint(p.MaxUsers), "max_users cannot be negative")
+	v.check(p.Color == "" || colorPattern.MatchString(p.Color), "color", p.Color, "color must be a #RRGGBB hex code")
+	v.check(len(p.Icon) <= 64, "icon", p.Icon, "icon is too long")
+	v.validateQuotaThresholds("quota_warning_thresholds", p.QuotaWarningThresholds)
+	v.validatePlanRouting(p)
+	return v.err()
+}
+
+// validateQuotaThresholds checks that quota warning thresholds are ascending
+// percentages below 100; using the whole quota is alerted on its own
+func (v *validator) validateQuotaThresholds(field string, thresholds []int) {
+	for i, threshold := range thresholds {
+		if threshold < 1 || threshold > 99 || (i > 0 && threshold <= thresholds[i-1]) {
+			v.check(false, field, fmt.Sprint(thresholds), "thresholds must be ascending percentages between 1 and 99")
+			return
+		}
+	}
+}
+
+// Validate checks the plan feature fields
+func (f *PlanFeature) Validate() error {
+	v := &validator{}
+	v.check(len(f.Name) <= 128, "name", f.Name, "name is too long")
+	switch f.Type {
+	case "boolean", "numeric", "string", "json":
+	default:
+		v.check(false, "type", f.Type, "type must be one of boolean, numeric, string, json")
+	}
+	v.check(len(f.Icon) <= 64, "icon", f.Icon, "icon is too long")
+	return v.err()
+}
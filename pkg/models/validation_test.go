@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// invalidFields returns the fields reported by a validation error
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("error %v is not a *ValidationError", err)
+	}
+	fields := make([]string, len(validationErr.Fields))
+	for i, f := range validationErr.Fields {
+		fields[i] = f.Field
+	}
+	return fields
+}
+
+func TestUserValidate(t *testing.T) {
+	valid := func() *User {
+		return &User{Username: "alice_01", Email: "alice@example.com", Status: UserStatusActive, Role: UserRoleUser, DeviceLimit: 3}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(u *User)
+		want   []string
+	}{
+		{"valid", func(u *User) {}, nil},
+		{"username too short", func(u *User) { u.Username = "al" }, []string{"username"}},
+		{"username charset", func(u *User) { u.Username = "alice smith" }, []string{"username"}},
+		{"username leading dot", func(u *User) { u.Username = ".alice" }, []string{"username"}},
+		{"email display name", func(u *User) { u.Email = "Alice <alice@example.com>" }, []string{"email"}},
+		{"unknown status and role", func(u *User) { u.Status, u.Role = "gone", "root" }, []string{"status", "role"}},
+		{"negative quota", func(u *User) { u.TrafficQuota = -1 }, []string{"traffic_quota"}},
+		{"quota too large", func(u *User) { u.TrafficQuota = MaxTrafficQuota + 1 }, []string{"traffic_quota"}},
+		{"device limit", func(u *User) { u.DeviceLimit = MaxDeviceLimit + 1 }, []string{"device_limit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := valid()
+			tt.modify(user)
+			if got := invalidFields(t, user.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeValidate(t *testing.T) {
+	valid := func() *Node {
+		return &Node{Name: "tokyo-1", Host: "203.0.113.10", Port: 443, Type: NodeTypeVLESS, Tags: "jp,premium", TrafficRate: 1}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(n *Node)
+		want   []string
+	}{
+		{"valid", func(n *Node) {}, nil},
+		{"port zero", func(n *Node) { n.Port = 0 }, []string{"port"}},
+		{"port too large", func(n *Node) { n.Port = 65536 }, []string{"port"}},
+		{"host with path", func(n *Node) { n.Host = "example.com/path" }, []string{"host"}},
+		{"unknown type", func(n *Node) { n.Type = "wireguard" }, []string{"type"}},
+		{"empty tag", func(n *Node) { n.Tags = "jp,,premium" }, []string{"tags"}},
+		{"tag with space", func(n *Node) { n.Tags = "low latency" }, []string{"tags"}},
+		{"missing name and host", func(n *Node) { n.Name, n.Host = "", "" }, []string{"name", "host"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := valid()
+			tt.modify(node)
+			if got := invalidFields(t, node.Validate()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"valid", `{"log": {"level": "info"}, "inbounds": []}`, nil},
+		{"empty", "", []string{"config_content"}},
+		{"not json", "inbounds: []", []string{"config_content"}},
+		{"json array", `[{"type": "vless"}]`, []string{"config_content"}},
+		{"too large", `{"log": "` + strings.Repeat("x", MaxNodeConfigSize) + `"}`, []string{"config_content"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := invalidFields(t, ValidateNodeConfig(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("invalid fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PaymentMethodBalance is the payment method of orders paid from the user's balance
+const PaymentMethodBalance = "balance"
+
+// Errors returned when a balance transaction cannot be posted
+var (
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrBalanceCurrency     = errors.New("balance is held in another currency")
+)
+
+// BalanceTransactionType represents why a user's balance changed
+type BalanceTransactionType string
+
+const (
+	// BalanceTransactionTopUp is money added by an admin
+	BalanceTransactionTopUp BalanceTransactionType = "topup"
+	// BalanceTransactionDeduction is money taken by an admin
+	BalanceTransactionDeduction BalanceTransactionType = "deduction"
+	// BalanceTransactionPurchase is an order paid from the balance
+	BalanceTransactionPurchase BalanceTransactionType = "purchase"
+	// BalanceTransactionRefund is a refunded order credited back to the balance
+	BalanceTransactionRefund BalanceTransactionType = "refund"
+)
+
+// IsValid checks if the transaction type is known
+func (t BalanceTransactionType) IsValid() bool {
+	switch t {
+	case BalanceTransactionTopUp, BalanceTransactionDeduction, BalanceTransactionPurchase, BalanceTransactionRefund:
+		return true
+	}
+	return false
+}
+
+// IsCredit reports whether the transaction adds to the user's balance
+func (t BalanceTransactionType) IsCredit() bool {
+	return t == BalanceTransactionTopUp || t == BalanceTransactionRefund
+}
+
+// Counter accounts of balance transactions. Money enters and leaves the
+// panel through the external account and is earned or given back through the
+// revenue account, so the entries of all accounts always sum to zero.
+const (
+	LedgerAccountExternal = "external"
+	LedgerAccountRevenue  = "revenue"
+)
+
+// CounterAccount returns the account on the other side of the user's wallet
+func (t BalanceTransactionType) CounterAccount() string {
+	if t == BalanceTransactionPurchase || t == BalanceTransactionRefund {
+		return LedgerAccountRevenue
+	}
+	return LedgerAccountExternal
+}
+
+// UserLedgerAccount returns the wallet account of a user
+func UserLedgerAccount(userID uint) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// BalanceTransaction is a change of a user's balance, booked as two ledger
+// entries moving the amount between the user's wallet and a counter account
+type BalanceTransaction struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	UserID uint                   `json:"user_id" gorm:"not null;index"`
+	Type   BalanceTransactionType `json:"type" gorm:"not null;size:20;index"`
+	// Amount is the signed change of the user's balance in cents
+	Amount       int64  `json:"amount" gorm:"not null"`
+	Currency     string `json:"currency" gorm:"not null;size:3"`
+	BalanceAfter int64  `json:"balance_after" gorm:"not null;comment:User balance after the transaction in cents"`
+	OrderID      *uint  `json:"order_id,omitempty" gorm:"index"`
+	Note         string `json:"note" gorm:"size:255"`
+	Operator     string `json:"operator" gorm:"size:64"`
+
+	Entries []LedgerEntry `json:"entries,omitempty" gorm:"foreignKey:TransactionID"`
+}
+
+// TableName returns the table name for BalanceTransaction model
+func (BalanceTransaction) TableName() string {
+	return "balance_transactions"
+}
+
+// LedgerEntry is one side of a balance transaction. Amount is signed, so
+// the entries of a transaction sum to zero.
+type LedgerEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TransactionID uint   `json:"transaction_id" gorm:"not null;index"`
+	Account       string `json:"account" gorm:"not null;size:64;index"`
+	Amount        int64  `json:"amount" gorm:"not null"`
+	Currency      string `json:"currency" gorm:"not null;size:3"`
+}
+
+// TableName returns the table name for LedgerEntry model
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+// WalletReconciliation compares a user's balance with the ledger
+type WalletReconciliation struct {
+	UserID        uint   `json:"user_id"`
+	Balance       int64  `json:"balance"`
+	Currency      string `json:"currency"`
+	LedgerBalance int64  `json:"ledger_balance"`
+	// UnbalancedTransactions counts the user's transactions whose entries do not sum to zero
+	UnbalancedTransactions int64 `json:"unbalanced_transactions"`
+}
+
+// Consistent reports whether the balance matches the ledger
+func (r *WalletReconciliation) Consistent() bool {
+	return r.Balance == r.LedgerBalance && r.UnbalancedTransactions == 0
+}
+
+// Validate checks the fields an admin sets on a top-up or deduction
+func (t *BalanceTransaction) Validate() error {
+	v := &validator{}
+	v.check(t.Amount > 0, "amount", fmt.Sprint(t.Amount), "amount must be positive")
+	v.check(currencyPattern.MatchString(t.Currency), "currency", t.Currency, "currency must be a 3-letter ISO 4217 code")
+	v.check(len(t.Note) <= 255, "note", t.Note, "note is too long")
+	return v.err()
+}
@@ -0,0 +1,107 @@
+package models
+
+import "strings"
+
+// WitnessFinding is a divergence between what a node's agent reported and
+// what the panel observed of the node
+type WitnessFinding string
+
+const (
+	// WitnessTrafficInflated means more user traffic was reported than went
+	// through the node's network interfaces
+	WitnessTrafficInflated WitnessFinding = "traffic_inflated"
+	// WitnessTrafficUnderreported means far more went through the node's
+	// network interfaces than the user traffic reported
+	WitnessTrafficUnderreported WitnessFinding = "traffic_underreported"
+	// WitnessUnreachable means user traffic was reported while probes could
+	// not reach the node
+	WitnessUnreachable WitnessFinding = "unreachable"
+)
+
+// NodeWitness is what a node reported over a window next to the probes of
+// the node over the same window
+type NodeWitness struct {
+	// InterfaceBytes went in and out of the node's network interfaces,
+	// according to the counters of its metrics reports
+	InterfaceBytes int64
+	// TrafficBytes is the user traffic the node reported
+	TrafficBytes int64
+
+	ProbeSamples   int64
+	ProbeSuccesses int64
+}
+
+// WitnessThresholds bound the divergence tolerated of a node
+type WitnessThresholds struct {
+	// MinBytes is the traffic below which interface and user traffic are
+	// not compared
+	MinBytes int64
+	// MinInterfaceRatio and MaxInterfaceRatio bound the interface traffic
+	// per byte of user traffic. A proxy relays each byte, so about 2 is expected.
+	MinInterfaceRatio float64
+	MaxInterfaceRatio float64
+	// MinProbeSamples is the number of probes needed to judge reachability
+	MinProbeSamples int64
+	// MinProbeAvailability is the fraction of successful probes (0-1) below
+	// which a node serving MinBytes of user traffic is suspicious
+	MinProbeAvailability float64
+}
+
+// InterfaceRatio returns the interface traffic per byte of user traffic, 0
+// without user traffic
+func (w NodeWitness) InterfaceRatio() float64 {
+	if w.TrafficBytes <= 0 {
+		return 0
+	}
+	return float64(w.InterfaceBytes) / float64(w.TrafficBytes)
+}
+
+// ProbeAvailability returns the fraction of successful probes (0-1)
+func (w NodeWitness) ProbeAvailability() float64 {
+	if w.ProbeSamples == 0 {
+		return 0
+	}
+	return float64(w.ProbeSuccesses) / float64(w.ProbeSamples)
+}
+
+// Check returns the findings of the window, none when the node's reports
+// agree with the observations
+func (w NodeWitness) Check(t WitnessThresholds) []WitnessFinding {
+	var findings []WitnessFinding
+
+	if max(w.InterfaceBytes, w.TrafficBytes) >= t.MinBytes {
+		interfaceBytes, trafficBytes := float64(w.InterfaceBytes), float64(w.TrafficBytes)
+		if w.TrafficBytes > 0 && interfaceBytes < trafficBytes*t.MinInterfaceRatio {
+			findings = append(findings, WitnessTrafficInflated)
+		}
+		if interfaceBytes > trafficBytes*t.MaxInterfaceRatio {
+			findings = append(findings, WitnessTrafficUnderreported)
+		}
+	}
+
+	if w.TrafficBytes >= t.MinBytes && w.ProbeSamples >= t.MinProbeSamples && w.ProbeAvailability() < t.MinProbeAvailability {
+		findings = append(findings, WitnessUnreachable)
+	}
+	return findings
+}
+
+// WitnessFindings returns the findings the node is flagged with
+func (n *Node) WitnessFindings() []WitnessFinding {
+	if n.WitnessFlags == "" {
+		return nil
+	}
+	var findings []WitnessFinding
+	for _, flag := range strings.Split(n.WitnessFlags, ",") {
+		findings = append(findings, WitnessFinding(flag))
+	}
+	return findings
+}
+
+// JoinWitnessFindings returns the comma-separated form stored in Node.WitnessFlags
+func JoinWitnessFindings(findings []WitnessFinding) string {
+	flags := make([]string, len(findings))
+	for i, finding := range findings {
+		flags[i] = string(finding)
+	}
+	return strings.Join(flags, ",")
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNodeWitnessCheck(t *testing.T) {
+	const gib = 1 << 30
+	thresholds := WitnessThresholds{
+		MinBytes:             gib,
+		MinInterfaceRatio:    1,
+		MaxInterfaceRatio:    4,
+		MinProbeSamples:      10,
+		MinProbeAvailability: 0.5,
+	}
+
+	tests := []struct {
+		name    string
+		witness NodeWitness
+		want    []WitnessFinding
+	}{
+		{"relayed traffic", NodeWitness{InterfaceBytes: 20 * gib, TrafficBytes: 10 * gib}, nil},
+		{"below the minimum volume", NodeWitness{InterfaceBytes: gib / 4, TrafficBytes: gib / 2}, nil},
+		{"inflated traffic", NodeWitness{InterfaceBytes: 5 * gib, TrafficBytes: 10 * gib}, []WitnessFinding{WitnessTrafficInflated}},
+		{"underreported traffic", NodeWitness{InterfaceBytes: 50 * gib, TrafficBytes: 10 * gib}, []WitnessFinding{WitnessTrafficUnderreported}},
+		{"no traffic reported", NodeWitness{InterfaceBytes: 2 * gib}, []WitnessFinding{WitnessTrafficUnderreported}},
+		{
+			"unreachable while serving",
+			NodeWitness{InterfaceBytes: 20 * gib, TrafficBytes: 10 * gib, ProbeSamples: 20, ProbeSuccesses: 2},
+			[]WitnessFinding{WitnessUnreachable},
+		},
+		{
+			"too few probes",
+			NodeWitness{InterfaceBytes: 20 * gib, TrafficBytes: 10 * gib, ProbeSamples: 5},
+			nil,
+		},
+		{
+			"reachable",
+			NodeWitness{InterfaceBytes: 20 * gib, TrafficBytes: 10 * gib, ProbeSamples: 20, ProbeSuccesses: 19},
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.witness.Check(thresholds); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeWitnessFindings(t *testing.T) {
+	findings := []WitnessFinding{WitnessTrafficInflated, WitnessUnreachable}
+	node := Node{WitnessFlags: JoinWitnessFindings(findings)}
+	if node.WitnessFlags != "traffic_inflated,unreachable" {
+		t.Errorf("JoinWitnessFindings() = %q", node.WitnessFlags)
+	}
+	if got := node.WitnessFindings(); !reflect.DeepEqual(got, findings) {
+		t.Errorf("WitnessFindings() = %v, want %v", got, findings)
+	}
+	if got := (&Node{}).WitnessFindings(); got != nil {
+		t.Errorf("WitnessFindings() of an unflagged node = %v, want none", got)
+	}
+}
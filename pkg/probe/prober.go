@@ -0,0 +1,115 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// maxConcurrentProbes limits the number of simultaneous dials per round
+const maxConcurrentProbes = 16
+
+// Prober periodically measures TCP connect latency to every enabled node
+type Prober struct {
+	config configv1.ProbeConfig
+	repo   *repository.Manager
+	logger *zap.Logger
+}
+
+// NewProber creates a new node prober
+func NewProber(config configv1.ProbeConfig, repo *repository.Manager, logger *zap.Logger) *Prober {
+	return &Prober{
+		config: config,
+		repo:   repo,
+		logger: logger.Named("prober"),
+	}
+}
+
+// Start runs probe rounds until the context is cancelled
+func (p *Prober) Start(ctx context.Context) {
+	p.logger.Info("node prober starting", zap.Duration("interval", p.config.Interval))
+
+	go func() {
+		ticker := time.NewTicker(p.config.Interval)
+		defer ticker.Stop()
+
+		p.runRound(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				p.logger.Info("node prober stopped")
+				return
+			case <-ticker.C:
+				p.runRound(ctx)
+			}
+		}
+	}()
+}
+
+// runRound probes all enabled nodes once and stores the results
+func (p *Prober) runRound(ctx context.Context) {
+	nodes, _, err := p.repo.Node.ListEnabled(0, 10000)
+	if err != nil {
+		p.logger.Error("Failed to list nodes for probing", zap.Error(err))
+		return
+	}
+
+	results := make([]*models.NodeProbe, len(nodes))
+	sem := make(chan struct{}, maxConcurrentProbes)
+	var wg sync.WaitGroup
+
+	for i, node := range nodes {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, node *models.Node) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = p.probeNode(ctx, node)
+		}(i, node)
+	}
+	wg.Wait()
+
+	if err := p.repo.Probe.BatchCreate(results); err != nil {
+		p.logger.Error("Failed to store probe results", zap.Error(err))
+		return
+	}
+
+	p.logger.Debug("Probe round completed", zap.Int("nodes", len(nodes)))
+}
+
+// probeNode measures the TCP connect time to a node's public endpoint
+func (p *Prober) probeNode(ctx context.Context, node *models.Node) *models.NodeProbe {
+	result := &models.NodeProbe{
+		NodeID:   node.ID,
+		ProbedAt: time.Now(),
+	}
+
+	dialer := net.Dialer{Timeout: p.config.Timeout}
+	address := net.JoinHostPort(node.Host, strconv.Itoa(node.Port))
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		result.Error = err.Error()
+		if len(result.Error) > 255 {
+			result.Error = result.Error[:255]
+		}
+		return result
+	}
+	conn.Close()
+
+	result.Success = true
+	result.LatencyMs = int(time.Since(start).Milliseconds())
+	if result.LatencyMs == 0 {
+		result.LatencyMs = 1
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+func TestProbeNode(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	openPort := listener.Addr().(*net.TCPAddr).Port
+
+	// A port that was just released refuses connections
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	prober := NewProber(configv1.ProbeConfig{Timeout: time.Second}, nil, zap.NewNop())
+	tests := []struct {
+		name        string
+		port        int
+		wantSuccess bool
+	}{
+		{"reachable", openPort, true},
+		{"refused", closedPort, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &models.Node{ID: 7, Host: "127.0.0.1", Port: tt.port}
+			result := prober.probeNode(context.Background(), node)
+			if result.NodeID != node.ID || result.ProbedAt.IsZero() {
+				t.Errorf("result = %+v, want a probe of node %d", result, node.ID)
+			}
+			if result.Success != tt.wantSuccess {
+				t.Fatalf("success = %v (%s), want %v", result.Success, result.Error, tt.wantSuccess)
+			}
+			if tt.wantSuccess {
+				// Sub-millisecond connects still count as reachable
+				if result.LatencyMs < 1 || result.Error != "" {
+					t.Errorf("latency = %dms, error = %q", result.LatencyMs, result.Error)
+				}
+			} else if result.Error == "" || len(result.Error) > 255 || result.LatencyMs != 0 {
+				t.Errorf("failed probe = %+v, want an error of at most 255 bytes", result)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package probe
+
+import (
+	"math"
+
+	"sing-box-web/pkg/models"
+)
+
+// Quality is a coarse, user-facing rating of a node's responsiveness
+type Quality string
+
+const (
+	QualityExcellent Quality = "excellent"
+	QualityGood      Quality = "good"
+	QualityFair      Quality = "fair"
+	QualityPoor      Quality = "poor"
+	QualityUnknown   Quality = "unknown"
+)
+
+// NodeLatency is the sanitized latency view of a node shown to end users.
+// It intentionally carries no host, load or resource information.
+type NodeLatency struct {
+	NodeID       uint    `json:"node_id"`
+	Name         string  `json:"name"`
+	Region       string  `json:"region,omitempty"`
+	Country      string  `json:"country,omitempty"`
+	LatencyMs    int     `json:"latency_ms"`
+	Availability float64 `json:"availability"`
+	Quality      Quality `json:"quality"`
+}
+
+// Summarize builds the sanitized latency view for the given nodes
+func Summarize(nodes []*models.Node, stats map[uint]*models.NodeProbeStats) []*NodeLatency {
+	result := make([]*NodeLatency, 0, len(nodes))
+	for _, node := range nodes {
+		entry := &NodeLatency{
+			NodeID:  node.ID,
+			Name:    node.Name,
+			Region:  node.Region,
+			Country: node.Country,
+			Quality: QualityUnknown,
+		}
+
+		if s, ok := stats[node.ID]; ok && s.Samples > 0 {
+			// Round to hide probe-level jitter and internal measurement detail
+			entry.LatencyMs = int(math.Round(s.AvgLatencyMs/10) * 10)
+			entry.Availability = math.Round(s.Availability()*1000) / 10
+			entry.Quality = Rate(entry.LatencyMs, entry.Availability)
+		}
+
+		result = append(result, entry)
+	}
+	return result
+}
+
+// Rate derives a quality rating from latency (ms) and availability (percent)
+func Rate(latencyMs int, availability float64) Quality {
+	switch {
+	case availability <= 0:
+		return QualityPoor
+	case latencyMs < 100 && availability >= 99:
+		return QualityExcellent
+	case latencyMs < 200 && availability >= 95:
+		return QualityGood
+	case latencyMs < 400 && availability >= 80:
+		return QualityFair
+	default:
+		return QualityPoor
+	}
+}
+
+// Labels returns per-node quality labels suitable for subscription entries.
+// Only the coarse rating is used so that labels (and therefore subscription
+// hashes) stay stable across probe rounds.
+func Labels(latencies []*NodeLatency) map[uint]string {
+	labels := make(map[uint]string, len(latencies))
+	for _, l := range latencies {
+		if l.Quality != QualityUnknown {
+			labels[l.NodeID] = string(l.Quality)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,104 @@
+package probe
+
+import (
+	"testing"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name             string
+		stats            *models.NodeProbeStats
+		wantLatency      int
+		wantAvailability float64
+		wantQuality      Quality
+	}{
+		{"never probed", nil, 0, 0, QualityUnknown},
+		{"no samples", &models.NodeProbeStats{}, 0, 0, QualityUnknown},
+		{"all failed", &models.NodeProbeStats{Samples: 10}, 0, 0, QualityPoor},
+		{"all succeeded", &models.NodeProbeStats{Samples: 10, Successes: 10, AvgLatencyMs: 42}, 40, 100, QualityExcellent},
+		// Latency is rounded to 10ms, availability to 0.1%
+		{"latency rounded up", &models.NodeProbeStats{Samples: 4, Successes: 4, AvgLatencyMs: 95}, 100, 100, QualityGood},
+		{"latency rounded down", &models.NodeProbeStats{Samples: 4, Successes: 4, AvgLatencyMs: 94.9}, 90, 100, QualityExcellent},
+		{"loss rounded", &models.NodeProbeStats{Samples: 3, Successes: 2, AvgLatencyMs: 150}, 150, 66.7, QualityPoor},
+		{"small loss", &models.NodeProbeStats{Samples: 1000, Successes: 989, AvgLatencyMs: 150}, 150, 98.9, QualityGood},
+		{"loss rounded to none", &models.NodeProbeStats{Samples: 10000, Successes: 9999, AvgLatencyMs: 50}, 50, 100, QualityExcellent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &models.Node{ID: 1, Name: "hk-1", Region: "asia", Country: "HK"}
+			stats := map[uint]*models.NodeProbeStats{}
+			if tt.stats != nil {
+				stats[node.ID] = tt.stats
+			}
+
+			got := Summarize([]*models.Node{node}, stats)
+			if len(got) != 1 {
+				t.Fatalf("Summarize() returned %d entries, want 1", len(got))
+			}
+			entry := got[0]
+			if entry.NodeID != node.ID || entry.Name != node.Name || entry.Region != node.Region || entry.Country != node.Country {
+				t.Errorf("entry = %+v, want the node's identity", entry)
+			}
+			if entry.LatencyMs != tt.wantLatency || entry.Availability != tt.wantAvailability || entry.Quality != tt.wantQuality {
+				t.Errorf("entry = %dms %v%% %s, want %dms %v%% %s", entry.LatencyMs, entry.Availability, entry.Quality,
+					tt.wantLatency, tt.wantAvailability, tt.wantQuality)
+			}
+		})
+	}
+}
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		latencyMs    int
+		availability float64
+		want         Quality
+	}{
+		{0, 0, QualityPoor},
+		{10, 0, QualityPoor},
+		{99, 99, QualityExcellent},
+		{100, 99, QualityGood},
+		{99, 98.9, QualityGood},
+		{199, 95, QualityGood},
+		{200, 95, QualityFair},
+		{199, 94.9, QualityFair},
+		{399, 80, QualityFair},
+		{400, 80, QualityPoor},
+		{399, 79.9, QualityPoor},
+		{1000, 100, QualityPoor},
+	}
+	for _, tt := range tests {
+		if got := Rate(tt.latencyMs, tt.availability); got != tt.want {
+			t.Errorf("Rate(%d, %v) = %s, want %s", tt.latencyMs, tt.availability, got, tt.want)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		latencies []*NodeLatency
+		want      map[uint]string
+	}{
+		{"none", nil, map[uint]string{}},
+		{"unknown left out", []*NodeLatency{
+			{NodeID: 1, Quality: QualityGood},
+			{NodeID: 2, Quality: QualityUnknown},
+			{NodeID: 3, Quality: QualityPoor},
+		}, map[uint]string{1: "good", 3: "poor"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Labels(tt.latencies)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Labels() = %v, want %v", got, tt.want)
+			}
+			for id, label := range tt.want {
+				if got[id] != label {
+					t.Errorf("Labels()[%d] = %q, want %q", id, got[id], label)
+				}
+			}
+		})
+	}
+}
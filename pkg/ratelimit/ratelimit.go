@@ -0,0 +1,220 @@
+// Package ratelimit limits the requests of keys, such as client IPs or
+// users, with token buckets kept in memory or in Redis.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/events"
+)
+
+// sweepInterval is how often the memory store drops the buckets that are
+// full again, which are the same as no bucket
+const sweepInterval = time.Minute
+
+// Limit allows Requests requests per Period, in bursts of up to Requests
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// rate returns the tokens a bucket gains per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Store keeps the token buckets of the keys
+type Store interface {
+	// Take takes a token of the bucket of key. It returns 0 when the request
+	// is allowed, or how long to wait for the next token.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error)
+	Close() error
+}
+
+// bucket is the state of a token bucket
+type bucket struct {
+	// tokens as of updated
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// MemoryStore keeps the buckets of a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= sweepInterval {
+		s.sweep(now)
+	}
+
+	capacity := float64(limit.Requests)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	b.period = limit.Period
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*limit.rate())
+		b.updated = now
+	}
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / limit.rate() * float64(time.Second)), nil
+	}
+	b.tokens--
+	return 0, nil
+}
+
+// sweep drops the buckets untouched for their period, s.mu must be held
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) >= b.period {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// Close implements Store
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// redisTakeScript refills and takes a token of the bucket hash KEYS[1] with
+// ARGV capacity, period in milliseconds and now in milliseconds. It returns
+// 0 or the milliseconds to wait. The bucket expires once full again.
+const redisTakeScript = `
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or capacity
+local updated = tonumber(state[2]) or now
+if now > updated then
+  tokens = math.min(capacity, tokens + (now - updated) * capacity / period)
+  updated = now
+end
+local wait = 0
+if tokens < 1 then
+  wait = math.ceil((1 - tokens) * period / capacity)
+else
+  tokens = tokens - 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(updated))
+redis.call('PEXPIRE', KEYS[1], period)
+return wait
+`
+
+// RedisStore keeps the buckets in Redis, shared by every instance using the
+// same key prefix. The time of the instance taking a token is used, the
+// clocks of the instances should be synchronized.
+type RedisStore struct {
+	client *events.RedisClient
+	prefix string
+}
+
+// NewRedisStore creates a Redis store, connecting on first use
+func NewRedisStore(config configv1.RedisConfig, prefix string) *RedisStore {
+	return &RedisStore{client: events.NewRedisClient(config), prefix: prefix}
+}
+
+// Take implements Store
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (time.Duration, error) {
+	reply, err := s.client.Do(ctx, "EVAL", redisTakeScript, "1", s.prefix+key,
+		strconv.Itoa(limit.Requests),
+		strconv.FormatInt(limit.Period.Milliseconds(), 10),
+		strconv.FormatInt(now.UnixMilli(), 10))
+	if err != nil {
+		return 0, err
+	}
+	wait, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected rate limit reply %v", reply)
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// Close implements Store
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// Limiter takes the tokens of the requests from its store. While the store
+// fails, the buckets are kept in memory instead, each instance then limiting
+// on its own.
+type Limiter struct {
+	store    Store
+	fallback *MemoryStore
+	logger   *zap.Logger
+	now      func() time.Time
+
+	// degraded is set while the store fails
+	degraded atomic.Bool
+}
+
+// New creates a limiter on the configured store
+func New(config configv1.RateLimitStoreConfig, logger *zap.Logger) (*Limiter, error) {
+	var store Store
+	switch config.Backend {
+	case "", "memory":
+		store = NewMemoryStore()
+	case "redis":
+		store = NewRedisStore(config.Redis, config.KeyPrefix)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store backend %q", config.Backend)
+	}
+	return NewLimiter(store, logger), nil
+}
+
+// NewLimiter creates a limiter on a store
+func NewLimiter(store Store, logger *zap.Logger) *Limiter {
+	return &Limiter{store: store, fallback: NewMemoryStore(), logger: logger, now: time.Now}
+}
+
+// Allow takes a token of the bucket of key. It returns whether the request
+// is allowed and, when not, how long to wait before retrying.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (bool, time.Duration) {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return true, 0
+	}
+
+	now := l.now()
+	wait, err := l.store.Take(ctx, key, limit, now)
+	if err != nil {
+		if !l.degraded.Swap(true) {
+			l.logger.Warn("Rate limit store failed, limiting in memory", zap.Error(err))
+		}
+		wait, _ = l.fallback.Take(ctx, key, limit, now)
+	} else if l.degraded.Swap(false) {
+		l.logger.Info("Rate limit store recovered")
+	}
+	return wait <= 0, wait
+}
+
+// Close closes the store
+func (l *Limiter) Close() error {
+	return errors.Join(l.store.Close(), l.fallback.Close())
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: time.Second}
+	start := time.Now()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if wait, _ := store.Take(ctx, "a", limit, start); wait != 0 {
+			t.Fatalf("request %d waits %v, want allowed", i, wait)
+		}
+	}
+	if wait, _ := store.Take(ctx, "a", limit, start); wait != 500*time.Millisecond {
+		t.Errorf("request above the burst waits %v, want 500ms", wait)
+	}
+	if wait, _ := store.Take(ctx, "b", limit, start); wait != 0 {
+		t.Errorf("other key waits %v, want allowed", wait)
+	}
+
+	// Half a period refills one token
+	if wait, _ := store.Take(ctx, "a", limit, start.Add(500*time.Millisecond)); wait != 0 {
+		t.Errorf("request after the refill waits %v, want allowed", wait)
+	}
+
+	// Buckets untouched for their period are dropped
+	store.Take(ctx, "c", limit, start.Add(2*sweepInterval))
+	if _, ok := store.buckets["a"]; ok {
+		t.Error("bucket a not swept")
+	}
+}
+
+// failingStore fails every take
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, Limit, time.Time) (time.Duration, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (failingStore) Close() error { return nil }
+
+func TestLimiterFallsBackToMemory(t *testing.T) {
+	limiter := NewLimiter(failingStore{}, zap.NewNop())
+	limit := Limit{Requests: 1, Period: time.Minute}
+
+	if ok, _ := limiter.Allow(context.Background(), "a", limit); !ok {
+		t.Fatal("first request refused")
+	}
+	ok, wait := limiter.Allow(context.Background(), "a", limit)
+	if ok || wait <= 0 {
+		t.Errorf("second request allowed = %v, wait %v; want refused with a wait", ok, wait)
+	}
+	if !limiter.degraded.Load() {
+		t.Error("limiter not degraded while the store fails")
+	}
+
+	if ok, _ := limiter.Allow(context.Background(), "a", Limit{}); !ok {
+		t.Error("request refused without a limit")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	commands := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			args, err := readCommand(reader)
+			if err != nil {
+				return
+			}
+			commands <- args
+			fmt.Fprint(conn, ":1500\r\n")
+		}
+	}()
+
+	store := NewRedisStore(configv1.RedisConfig{Address: listener.Addr().String(), DialTimeout: time.Second}, "limits:")
+	defer store.Close()
+	now := time.UnixMilli(1700000000000)
+
+	wait, err := store.Take(context.Background(), "ip:192.0.2.1", Limit{Requests: 100, Period: time.Minute}, now)
+	if err != nil {
+		t.Fatalf("Take() error = %v", err)
+	}
+	if wait != 1500*time.Millisecond {
+		t.Errorf("wait = %v, want 1.5s", wait)
+	}
+
+	args := <-commands
+	want := []string{"EVAL", redisTakeScript, "1", "limits:ip:192.0.2.1", "100", "60000", "1700000000000"}
+	if strings.Join(args, "|") != strings.Join(want, "|") {
+		t.Errorf("command = %q, want %q", args, want)
+	}
+}
+
+// readCommand reads a RESP array of bulk strings
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,98 @@
+// Package report renders tabular reports, such as the traffic reports admins
+// export or are mailed, as CSV or XLSX files.
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Formats of rendered reports
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Formats lists the supported formats
+var Formats = []string{FormatCSV, FormatXLSX}
+
+// ErrUnknownFormat is returned for a format that is not supported
+var ErrUnknownFormat = errors.New("unknown report format")
+
+// contentTypes are the MIME types of the formats
+var contentTypes = map[string]string{
+	FormatCSV:  "text/csv; charset=utf-8",
+	FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// Table is a report of rows under a header. Cells are strings or numbers,
+// int64 or float64; numbers stay numbers in spreadsheets.
+type Table struct {
+	// Name names the sheet of XLSX files
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// ContentType returns the MIME type of a format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Render renders a table in a format
+func Render(format string, table *Table) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatCSV:
+		err = writeCSV(&buf, table)
+	case FormatXLSX:
+		err = writeXLSX(&buf, table)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeCSV writes the table as CSV
+func writeCSV(buf *bytes.Buffer, table *Table) error {
+	w := csv.NewWriter(buf)
+	if err := w.Write(table.Header); err != nil {
+		return err
+	}
+	for _, row := range table.Rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = csvCell(cell)
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// csvCell renders a cell. Strings spreadsheets would take for a formula,
+// such as a username starting with =, are quoted with an apostrophe.
+func csvCell(cell any) string {
+	switch v := cell.(type) {
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(cell)
+}
@@ -0,0 +1,91 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testTable() *Table {
+	return &Table{
+		Name:   "Traffic: 2024/03",
+		Header: []string{"ID", "Name", "Total"},
+		Rows: [][]any{
+			{int64(1), "alice", int64(1024)},
+			{int64(2), "=cmd() & <b>", 1.5},
+		},
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	content, err := Render(FormatCSV, testTable())
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	want := "ID,Name,Total\n1,alice,1024\n2,'=cmd() & <b>,1.5\n"
+	if string(content) != want {
+		t.Errorf("csv = %q, want %q", content, want)
+	}
+}
+
+func TestRenderXLSX(t *testing.T) {
+	content, err := Render(FormatXLSX, testTable())
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatalf("open workbook: %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, file := range zr.File {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		parts[file.Name] = string(data)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook lacks %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Traffic_ 2024_03"`) {
+		t.Errorf("workbook = %s, want the sheet named Traffic_ 2024_03", parts["xl/workbook.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, cell := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">ID</t></is></c>`,
+		`<c r="C2"><v>1024</v></c>`,
+		`<c r="B3" t="inlineStr"><is><t xml:space="preserve">=cmd() &amp; &lt;b&gt;</t></is></c>`,
+		`<c r="C3"><v>1.5</v></c>`,
+	} {
+		if !strings.Contains(sheet, cell) {
+			t.Errorf("sheet lacks %s", cell)
+		}
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if _, err := Render("pdf", testTable()); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("render pdf: %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %s, want %s", index, got, want)
+		}
+	}
+}
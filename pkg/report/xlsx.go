@@ -0,0 +1,127 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+
+	// maxSheetName is the longest sheet name spreadsheets accept
+	maxSheetName = 31
+)
+
+// writeXLSX writes the table as a workbook of one sheet, strings stored
+// inline so that no shared string table is needed
+func writeXLSX(buf *bytes.Buffer, table *Table) error {
+	var sheet bytes.Buffer
+	sheet.WriteString(xlsxSheetStart)
+	header := make([]any, len(table.Header))
+	for i, name := range table.Header {
+		header[i] = name
+	}
+	writeXLSXRow(&sheet, 1, header)
+	for i, row := range table.Rows {
+		writeXLSXRow(&sheet, i+2, row)
+	}
+	sheet.WriteString(xlsxSheetEnd)
+
+	var name bytes.Buffer
+	if err := xml.EscapeText(&name, []byte(sheetName(table.Name))); err != nil {
+		return err
+	}
+	parts := []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", []byte(xlsxContentTypes)},
+		{"_rels/.rels", []byte(xlsxRels)},
+		{"xl/workbook.xml", []byte(fmt.Sprintf(xlsxWorkbook, name.String()))},
+		{"xl/_rels/workbook.xml.rels", []byte(xlsxWorkbookRels)},
+		{"xl/worksheets/sheet1.xml", sheet.Bytes()},
+	}
+
+	zw := zip.NewWriter(buf)
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(part.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// writeXLSXRow writes the cells of a row, numbered from 1
+func writeXLSXRow(sheet *bytes.Buffer, number int, cells []any) {
+	fmt.Fprintf(sheet, `<row r="%d">`, number)
+	for i, cell := range cells {
+		ref := columnName(i) + strconv.Itoa(number)
+		switch v := cell.(type) {
+		case int64:
+			fmt.Fprintf(sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case float64:
+			fmt.Fprintf(sheet, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+		default:
+			fmt.Fprintf(sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			_ = xml.EscapeText(sheet, []byte(fmt.Sprint(cell)))
+			sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	sheet.WriteString(`</row>`)
+}
+
+// columnName returns the letters of a column numbered from 0: A to Z, then
+// AA and on
+func columnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// sheetName returns a name spreadsheets accept for a sheet
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > maxSheetName {
+		name = string(runes[:maxSheetName])
+	}
+	if name == "" {
+		return "Report"
+	}
+	return name
+}
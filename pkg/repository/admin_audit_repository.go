@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// AdminAuditFilter narrows an admin audit listing, empty fields match every entry
+type AdminAuditFilter struct {
+	AdminID uint
+	Since   time.Time
+	Until   time.Time
+}
+
+// AdminAuditRepository interface defines admin audit log data access methods
+type AdminAuditRepository interface {
+	Create(entry *models.AdminAuditLog) error
+	List(filter AdminAuditFilter, offset, limit int) ([]*models.AdminAuditLog, int64, error)
+}
+
+// adminAuditRepository implements AdminAuditRepository interface
+type adminAuditRepository struct {
+	db *gorm.DB
+}
+
+// NewAdminAuditRepository creates a new admin audit repository
+func NewAdminAuditRepository(db *gorm.DB) AdminAuditRepository {
+	return &adminAuditRepository{db: db}
+}
+
+// Create records an admin action
+func (r *adminAuditRepository) Create(entry *models.AdminAuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+// List gets admin actions with pagination, newest first
+func (r *adminAuditRepository) List(filter AdminAuditFilter, offset, limit int) ([]*models.AdminAuditLog, int64, error) {
+	var entries []*models.AdminAuditLog
+	var total int64
+
+	query := r.db.Model(&models.AdminAuditLog{})
+	if filter.AdminID != 0 {
+		query = query.Where("admin_id = ?", filter.AdminID)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// AgentReportRepository interface defines agent report receipt data access methods
+type AgentReportRepository interface {
+	// Record records the receipt of a report of a node, false when the
+	// report was received before
+	Record(nodeID uint, reportID string) (bool, error)
+	// RecordBatch records the receipts of reports stored together, skipping
+	// those recorded before
+	RecordBatch(receipts []*models.AgentReportReceipt) error
+	// Exists reports whether the receipt of a report was recorded
+	Exists(nodeID uint, reportID string) (bool, error)
+	// Forget deletes the receipt of a report that could not be processed, so
+	// that the agent may send it again
+	Forget(nodeID uint, reportID string) error
+	// DeleteBefore deletes the receipts of reports received before cutoff
+	DeleteBefore(cutoff time.Time) (int64, error)
+}
+
+// agentReportRepository implements AgentReportRepository interface
+type agentReportRepository struct {
+	db *gorm.DB
+}
+
+// NewAgentReportRepository creates a new agent report repository
+func NewAgentReportRepository(db *gorm.DB) AgentReportRepository {
+	return &agentReportRepository{db: db}
+}
+
+// Record records the receipt of a report of a node
+func (r *agentReportRepository) Record(nodeID uint, reportID string) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.AgentReportReceipt{NodeID: nodeID, ReportID: reportID})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RecordBatch records the receipts of reports stored together
+func (r *agentReportRepository) RecordBatch(receipts []*models.AgentReportReceipt) error {
+	if len(receipts) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(receipts, 100).Error
+}
+
+// Exists reports whether the receipt of a report was recorded
+func (r *agentReportRepository) Exists(nodeID uint, reportID string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.AgentReportReceipt{}).
+		Where("node_id = ? AND report_id = ?", nodeID, reportID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Forget deletes the receipt of a report
+func (r *agentReportRepository) Forget(nodeID uint, reportID string) error {
+	return r.db.Where("node_id = ? AND report_id = ?", nodeID, reportID).
+		Delete(&models.AgentReportReceipt{}).Error
+}
+
+// DeleteBefore deletes the receipts of reports received before cutoff
+func (r *agentReportRepository) DeleteBefore(cutoff time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", cutoff).Delete(&models.AgentReportReceipt{})
+	return result.RowsAffected, result.Error
+}
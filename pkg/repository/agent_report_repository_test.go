@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestAgentReportRepositoryRecord(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewAgentReportRepository(db)
+
+	if fresh, err := repo.Record(1, "report-1"); err != nil || !fresh {
+		t.Fatalf("Record = %v, %v, want a new receipt", fresh, err)
+	}
+	// A replayed report is recognized
+	if fresh, err := repo.Record(1, "report-1"); err != nil || fresh {
+		t.Fatalf("second Record = %v, %v, want a duplicate", fresh, err)
+	}
+	// Report IDs are only unique per node
+	if fresh, err := repo.Record(2, "report-1"); err != nil || !fresh {
+		t.Fatalf("Record of another node = %v, %v, want a new receipt", fresh, err)
+	}
+
+	// A forgotten report is accepted again
+	if err := repo.Forget(1, "report-1"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	if fresh, err := repo.Record(1, "report-1"); err != nil || !fresh {
+		t.Fatalf("Record after Forget = %v, %v, want a new receipt", fresh, err)
+	}
+}
+
+func TestAgentReportRepositoryRecordBatch(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewAgentReportRepository(db)
+
+	if _, err := repo.Record(1, "report-1"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	// Receipts recorded before are skipped rather than failing the batch
+	err := repo.RecordBatch([]*models.AgentReportReceipt{
+		{NodeID: 1, ReportID: "report-1"},
+		{NodeID: 1, ReportID: "report-2"},
+	})
+	if err != nil {
+		t.Fatalf("RecordBatch: %v", err)
+	}
+
+	for _, id := range []string{"report-1", "report-2"} {
+		if exists, err := repo.Exists(1, id); err != nil || !exists {
+			t.Errorf("Exists(1, %s) = %v, %v, want true", id, exists, err)
+		}
+	}
+	if exists, err := repo.Exists(2, "report-2"); err != nil || exists {
+		t.Errorf("Exists of another node = %v, %v, want false", exists, err)
+	}
+}
+
+func TestAgentReportRepositoryDeleteBefore(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewAgentReportRepository(db)
+
+	old := &models.AgentReportReceipt{NodeID: 1, ReportID: "old", CreatedAt: time.Now().Add(-48 * time.Hour)}
+	if err := db.Create(old).Error; err != nil {
+		t.Fatalf("create receipt: %v", err)
+	}
+	if _, err := repo.Record(1, "recent"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	deleted, err := repo.DeleteBefore(time.Now().Add(-24 * time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteBefore = %d, %v, want 1 receipt deleted", deleted, err)
+	}
+	if fresh, _ := repo.Record(1, "recent"); fresh {
+		t.Error("recent receipt was deleted")
+	}
+}
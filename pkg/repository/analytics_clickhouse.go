@@ -0,0 +1,6 @@
+//go:build clickhouse
+
+package repository
+
+// Registers the "clickhouse" database/sql driver used by the clickhouse analytics backend
+import _ "github.com/ClickHouse/clickhouse-go/v2"
@@ -0,0 +1,349 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+// AnalyticsBucket is the width of a traffic series bucket
+type AnalyticsBucket string
+
+const (
+	AnalyticsBucketHourly AnalyticsBucket = "hourly"
+	AnalyticsBucketDaily  AnalyticsBucket = "daily"
+)
+
+// AnalyticsFilter restricts analytics queries to a user and/or a node, zero means any
+type AnalyticsFilter struct {
+	UserID uint
+	NodeID uint
+}
+
+// AnalyticsStore is a column or time-series store holding a copy of the
+// traffic records, used to serve the summary queries at scale.
+type AnalyticsStore interface {
+	// Schema operations
+	Migrate() error
+	Close() error
+
+	// Write operations
+	InsertRecords(records []*models.TrafficRecord) error
+
+	// Query operations. Zero start and end times select all records.
+	Sum(filter AnalyticsFilter, start, end time.Time) (upload, download, total int64, err error)
+	Series(filter AnalyticsFilter, bucket AnalyticsBucket, start, end time.Time) ([]models.TrafficSummary, error)
+	TopUsers(start, end time.Time, limit int) ([]uint, error)
+	TopNodes(start, end time.Time, limit int) ([]uint, error)
+}
+
+// analyticsDialect holds the SQL differences between the analytics backends
+type analyticsDialect struct {
+	// sqlDriver is the database/sql driver name registered by the backend's driver package
+	sqlDriver string
+	// buildTag is the build tag compiling the driver package in
+	buildTag string
+	schema   []string
+	buckets  map[AnalyticsBucket]string
+	// placeholder returns the bind parameter for the n-th argument, starting at 1
+	placeholder func(n int) string
+}
+
+var analyticsDialects = map[string]analyticsDialect{
+	"clickhouse": {
+		sqlDriver: "clickhouse",
+		buildTag:  "clickhouse",
+		schema: []string{`
+			CREATE TABLE IF NOT EXISTS traffic_records (
+				timestamp DateTime,
+				user_id UInt32,
+				node_id UInt32,
+				upload Int64,
+				download Int64,
+				total Int64,
+				session_id String,
+				protocol LowCardinality(String)
+			) ENGINE = MergeTree
+			PARTITION BY toYYYYMM(timestamp)
+			ORDER BY (user_id, node_id, timestamp)`,
+		},
+		buckets: map[AnalyticsBucket]string{
+			AnalyticsBucketHourly: "toStartOfHour(timestamp)",
+			AnalyticsBucketDaily:  "toStartOfDay(timestamp)",
+		},
+		placeholder: func(int) string { return "?" },
+	},
+	"timescaledb": {
+		sqlDriver: "pgx",
+		buildTag:  "timescaledb",
+		schema: []string{`
+			CREATE TABLE IF NOT EXISTS traffic_records (
+				timestamp TIMESTAMPTZ NOT NULL,
+				user_id BIGINT NOT NULL,
+				node_id BIGINT NOT NULL,
+				upload BIGINT NOT NULL,
+				download BIGINT NOT NULL,
+				total BIGINT NOT NULL,
+				session_id TEXT NOT NULL DEFAULT '',
+				protocol TEXT NOT NULL DEFAULT ''
+			)`,
+			`SELECT create_hypertable('traffic_records', 'timestamp', if_not_exists => TRUE)`,
+			`CREATE INDEX IF NOT EXISTS idx_traffic_records_user_time ON traffic_records (user_id, timestamp DESC)`,
+			`CREATE INDEX IF NOT EXISTS idx_traffic_records_node_time ON traffic_records (node_id, timestamp DESC)`,
+		},
+		buckets: map[AnalyticsBucket]string{
+			AnalyticsBucketHourly: "time_bucket('1 hour', timestamp)",
+			AnalyticsBucketDaily:  "time_bucket('1 day', timestamp)",
+		},
+		placeholder: func(n int) string { return fmt.Sprintf("$%d", n) },
+	},
+}
+
+// sqlAnalyticsStore implements AnalyticsStore on top of database/sql
+type sqlAnalyticsStore struct {
+	db      *sql.DB
+	dialect analyticsDialect
+	timeout time.Duration
+}
+
+// NewAnalyticsStore opens the analytics backend selected by the configuration.
+// The backend's driver must be compiled in with its build tag.
+func NewAnalyticsStore(config configv1.AnalyticsConfig) (AnalyticsStore, error) {
+	dialect, ok := analyticsDialects[config.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported analytics driver: %s", config.Driver)
+	}
+	if !analyticsDriverRegistered(dialect.sqlDriver) {
+		return nil, fmt.Errorf("analytics driver %s is not compiled in, build with -tags %s", config.Driver, dialect.buildTag)
+	}
+
+	db, err := sql.Open(dialect.sqlDriver, config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics database: %w", err)
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+
+	store := &sqlAnalyticsStore{db: db, dialect: dialect, timeout: config.QueryTimeout}
+
+	ctx, cancel := store.context()
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping analytics database: %w", err)
+	}
+
+	return store, nil
+}
+
+// analyticsDriverRegistered reports whether a database/sql driver is registered
+func analyticsDriverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// context returns a context bounded by the query timeout
+func (s *sqlAnalyticsStore) context() (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+// Migrate creates the traffic records table if it does not exist
+func (s *sqlAnalyticsStore) Migrate() error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	for _, statement := range s.dialect.schema {
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to migrate analytics schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the analytics database
+func (s *sqlAnalyticsStore) Close() error {
+	return s.db.Close()
+}
+
+// InsertRecords copies traffic records in one transaction, which both backends
+// turn into a single batch insert
+func (s *sqlAnalyticsStore) InsertRecords(records []*models.TrafficRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(
+		"INSERT INTO traffic_records (timestamp, user_id, node_id, upload, download, total, session_id, protocol) VALUES (%s)",
+		s.placeholders(1, 8),
+	))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, record := range records {
+		timestamp := record.CreatedAt
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		_, err := stmt.ExecContext(ctx, timestamp.UTC(), record.UserID, record.NodeID,
+			record.Upload, record.Download, record.Upload+record.Download, record.SessionID, record.Protocol)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Sum returns the traffic totals matching the filter
+func (s *sqlAnalyticsStore) Sum(filter AnalyticsFilter, start, end time.Time) (upload, download, total int64, err error) {
+	where, args := s.where(filter, start, end)
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	err = s.db.QueryRowContext(ctx,
+		"SELECT COALESCE(SUM(upload), 0), COALESCE(SUM(download), 0), COALESCE(SUM(total), 0) FROM traffic_records"+where,
+		args...,
+	).Scan(&upload, &download, &total)
+	return upload, download, total, err
+}
+
+// Series returns the traffic matching the filter grouped in buckets, newest first
+func (s *sqlAnalyticsStore) Series(filter AnalyticsFilter, bucket AnalyticsBucket, start, end time.Time) ([]models.TrafficSummary, error) {
+	expr, ok := s.dialect.buckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("unsupported analytics bucket: %s", bucket)
+	}
+	where, args := s.where(filter, start, end)
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s AS bucket, SUM(upload), SUM(download), SUM(total), COUNT(*)
+		FROM traffic_records%s
+		GROUP BY bucket
+		ORDER BY bucket DESC`, expr, where),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.TrafficSummary
+	for rows.Next() {
+		summary := models.TrafficSummary{
+			UserID:      filter.UserID,
+			NodeID:      filter.NodeID,
+			SummaryType: string(bucket),
+		}
+		if err := rows.Scan(&summary.SummaryDate, &summary.TotalUpload, &summary.TotalDownload,
+			&summary.TotalTraffic, &summary.TotalConnections); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, rows.Err()
+}
+
+// TopUsers returns the IDs of the users with the most traffic, highest first
+func (s *sqlAnalyticsStore) TopUsers(start, end time.Time, limit int) ([]uint, error) {
+	return s.top("user_id", start, end, limit)
+}
+
+// TopNodes returns the IDs of the nodes with the most traffic, highest first
+func (s *sqlAnalyticsStore) TopNodes(start, end time.Time, limit int) ([]uint, error) {
+	return s.top("node_id", start, end, limit)
+}
+
+// top returns the values of column with the most traffic
+func (s *sqlAnalyticsStore) top(column string, start, end time.Time, limit int) ([]uint, error) {
+	where, args := s.where(AnalyticsFilter{}, start, end)
+	args = append(args, limit)
+
+	ctx, cancel := s.context()
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %[1]s, SUM(total) AS total_traffic
+		FROM traffic_records%[2]s
+		GROUP BY %[1]s
+		ORDER BY total_traffic DESC
+		LIMIT %[3]s`, column, where, s.dialect.placeholder(len(args))),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		var total int64
+		if err := rows.Scan(&id, &total); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// where builds the WHERE clause of a query
+func (s *sqlAnalyticsStore) where(filter AnalyticsFilter, start, end time.Time) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, condition+" "+s.dialect.placeholder(len(args)))
+	}
+
+	if filter.UserID > 0 {
+		add("user_id =", filter.UserID)
+	}
+	if filter.NodeID > 0 {
+		add("node_id =", filter.NodeID)
+	}
+	if !start.IsZero() && !end.IsZero() {
+		add("timestamp >=", start.UTC())
+		add("timestamp <", end.UTC())
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// placeholders returns count comma separated bind parameters starting at first
+func (s *sqlAnalyticsStore) placeholders(first, count int) string {
+	params := make([]string, count)
+	for i := range params {
+		params[i] = s.dialect.placeholder(first + i)
+	}
+	return strings.Join(params, ", ")
+}
@@ -0,0 +1,6 @@
+//go:build timescaledb
+
+package repository
+
+// Registers the "pgx" database/sql driver used by the timescaledb analytics backend
+import _ "github.com/jackc/pgx/v5/stdlib"
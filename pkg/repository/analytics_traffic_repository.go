@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// analyticsTrafficRepository serves the traffic summary queries from an
+// analytics store. Records are still written to the primary database, which
+// stays the source of truth for record level reads, quotas and billing.
+type analyticsTrafficRepository struct {
+	TrafficRepository
+	db    *gorm.DB
+	store AnalyticsStore
+}
+
+// NewAnalyticsTrafficRepository wraps a traffic repository with an analytics store
+func NewAnalyticsTrafficRepository(db *gorm.DB, primary TrafficRepository, store AnalyticsStore) TrafficRepository {
+	return &analyticsTrafficRepository{TrafficRepository: primary, db: db, store: store}
+}
+
+// CreateRecord creates a traffic record and copies it to the analytics store
+func (r *analyticsTrafficRepository) CreateRecord(record *models.TrafficRecord) error {
+	if err := r.TrafficRepository.CreateRecord(record); err != nil {
+		return err
+	}
+	if err := r.store.InsertRecords([]*models.TrafficRecord{record}); err != nil {
+		return fmt.Errorf("failed to copy traffic record to analytics store: %w", err)
+	}
+	return nil
+}
+
+// BatchCreateRecords creates traffic records and copies them to the analytics store
+func (r *analyticsTrafficRepository) BatchCreateRecords(records []*models.TrafficRecord) error {
+	if err := r.TrafficRepository.BatchCreateRecords(records); err != nil {
+		return err
+	}
+	if err := r.store.InsertRecords(records); err != nil {
+		return fmt.Errorf("failed to copy traffic records to analytics store: %w", err)
+	}
+	return nil
+}
+
+// GetUserTrafficSum gets total traffic for a user from the analytics store
+func (r *analyticsTrafficRepository) GetUserTrafficSum(userID uint, start, end time.Time) (upload, download, total int64, err error) {
+	return r.store.Sum(AnalyticsFilter{UserID: userID}, start, end)
+}
+
+// GetNodeTrafficSum gets total traffic for a node from the analytics store
+func (r *analyticsTrafficRepository) GetNodeTrafficSum(nodeID uint, start, end time.Time) (upload, download, total int64, err error) {
+	return r.store.Sum(AnalyticsFilter{NodeID: nodeID}, start, end)
+}
+
+// GetTotalTrafficSum gets total traffic for all users from the analytics store
+func (r *analyticsTrafficRepository) GetTotalTrafficSum(start, end time.Time) (upload, download, total int64, err error) {
+	return r.store.Sum(AnalyticsFilter{}, start, end)
+}
+
+// GetTotalTrafficInRange gets total traffic in a time range from the analytics store
+func (r *analyticsTrafficRepository) GetTotalTrafficInRange(start, end time.Time) (int64, error) {
+	_, _, total, err := r.store.Sum(AnalyticsFilter{}, start, end)
+	return total, err
+}
+
+// GetUserDailyTraffic gets daily traffic of a user from the analytics store
+func (r *analyticsTrafficRepository) GetUserDailyTraffic(userID uint, days int) ([]models.TrafficSummary, error) {
+	start := time.Now().AddDate(0, 0, -days).Truncate(24 * time.Hour)
+	return r.store.Series(AnalyticsFilter{UserID: userID}, AnalyticsBucketDaily, start, time.Now())
+}
+
+// GetNodeDailyTraffic gets daily traffic of a node from the analytics store
+func (r *analyticsTrafficRepository) GetNodeDailyTraffic(nodeID uint, days int) ([]models.TrafficSummary, error) {
+	start := time.Now().AddDate(0, 0, -days).Truncate(24 * time.Hour)
+	return r.store.Series(AnalyticsFilter{NodeID: nodeID}, AnalyticsBucketDaily, start, time.Now())
+}
+
+// GetHourlyTraffic gets hourly traffic from the analytics store
+func (r *analyticsTrafficRepository) GetHourlyTraffic(start, end time.Time) ([]models.TrafficSummary, error) {
+	return r.store.Series(AnalyticsFilter{}, AnalyticsBucketHourly, start, end)
+}
+
+// GetUserHourlyTraffic gets hourly traffic of a user from the analytics store
+func (r *analyticsTrafficRepository) GetUserHourlyTraffic(userID uint, start, end time.Time) ([]models.TrafficSummary, error) {
+	return r.store.Series(AnalyticsFilter{UserID: userID}, AnalyticsBucketHourly, start, end)
+}
+
+// GetNodeHourlyTraffic gets hourly traffic of a node from the analytics store
+func (r *analyticsTrafficRepository) GetNodeHourlyTraffic(nodeID uint, start, end time.Time) ([]models.TrafficSummary, error) {
+	return r.store.Series(AnalyticsFilter{NodeID: nodeID}, AnalyticsBucketHourly, start, end)
+}
+
+// GetTopTrafficUsers gets the users with the most traffic, ranked by the analytics store
+func (r *analyticsTrafficRepository) GetTopTrafficUsers(start, end time.Time, limit int) ([]*models.User, error) {
+	ids, err := r.store.TopUsers(start, end, limit)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var users []*models.User
+	if err := r.db.Preload("Plan").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return orderByIDs(users, ids, func(user *models.User) uint { return user.ID }), nil
+}
+
+// GetTopTrafficNodes gets the nodes with the most traffic, ranked by the analytics store
+func (r *analyticsTrafficRepository) GetTopTrafficNodes(start, end time.Time, limit int) ([]*models.Node, error) {
+	ids, err := r.store.TopNodes(start, end, limit)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var nodes []*models.Node
+	if err := r.db.Where("id IN ?", ids).Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return orderByIDs(nodes, ids, func(node *models.Node) uint { return node.ID }), nil
+}
+
+// orderByIDs sorts items in the order of ids, dropping items not in ids
+func orderByIDs[T any](items []T, ids []uint, id func(T) uint) []T {
+	byID := make(map[uint]T, len(items))
+	for _, item := range items {
+		byID[id(item)] = item
+	}
+
+	ordered := make([]T, 0, len(items))
+	for _, itemID := range ids {
+		if item, ok := byID[itemID]; ok {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// AnnouncementRepository interface defines announcement data access methods
+type AnnouncementRepository interface {
+	// Basic CRUD operations
+	Create(announcement *models.Announcement) error
+	GetByID(id uint) (*models.Announcement, error)
+	Update(announcement *models.Announcement) error
+	Delete(id uint) error
+
+	// List operations
+	List(offset, limit int) ([]*models.Announcement, int64, error)
+	// ListPublished gets the announcements within their publish window, pinned first
+	ListPublished(now time.Time) ([]*models.Announcement, error)
+
+	// Read tracking
+	ReadIDs(userID uint, announcementIDs []uint) (map[uint]bool, error)
+	MarkRead(userID uint, announcementIDs []uint) (int64, error)
+	CountReads(announcementIDs []uint) (map[uint]int64, error)
+}
+
+// announcementRepository implements AnnouncementRepository interface
+type announcementRepository struct {
+	db *gorm.DB
+}
+
+// NewAnnouncementRepository creates a new announcement repository
+func NewAnnouncementRepository(db *gorm.DB) AnnouncementRepository {
+	return &announcementRepository{db: db}
+}
+
+// Create creates a new announcement
+func (r *announcementRepository) Create(announcement *models.Announcement) error {
+	return r.db.Create(announcement).Error
+}
+
+// GetByID gets announcement by ID
+func (r *announcementRepository) GetByID(id uint) (*models.Announcement, error) {
+	var announcement models.Announcement
+	if err := r.db.First(&announcement, id).Error; err != nil {
+		return nil, err
+	}
+	return &announcement, nil
+}
+
+// Update updates announcement
+func (r *announcementRepository) Update(announcement *models.Announcement) error {
+	return r.db.Save(announcement).Error
+}
+
+// Delete removes an announcement and its read receipts
+func (r *announcementRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("announcement_id = ?", id).Delete(&models.AnnouncementRead{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Announcement{}, id).Error
+	})
+}
+
+// List gets announcements with pagination, pinned first, then newest first
+func (r *announcementRepository) List(offset, limit int) ([]*models.Announcement, int64, error) {
+	var announcements []*models.Announcement
+	var total int64
+
+	query := r.db.Model(&models.Announcement{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("is_pinned DESC, id DESC").Offset(offset).Limit(limit).Find(&announcements).Error
+	return announcements, total, err
+}
+
+// ListPublished gets the announcements within their publish window, pinned
+// first, then newest first. Audiences are filtered by the caller.
+func (r *announcementRepository) ListPublished(now time.Time) ([]*models.Announcement, error) {
+	var announcements []*models.Announcement
+	err := r.db.Where("(starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", now, now).
+		Order("is_pinned DESC, id DESC").
+		Find(&announcements).Error
+	return announcements, err
+}
+
+// ReadIDs reports which of the announcements the user has read
+func (r *announcementRepository) ReadIDs(userID uint, announcementIDs []uint) (map[uint]bool, error) {
+	read := make(map[uint]bool)
+	if len(announcementIDs) == 0 {
+		return read, nil
+	}
+
+	var ids []uint
+	err := r.db.Model(&models.AnnouncementRead{}).
+		Where("user_id = ? AND announcement_id IN ?", userID, announcementIDs).
+		Pluck("announcement_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		read[id] = true
+	}
+	return read, nil
+}
+
+// MarkRead records that the user read the announcements and returns how
+// many were not read before
+func (r *announcementRepository) MarkRead(userID uint, announcementIDs []uint) (int64, error) {
+	if len(announcementIDs) == 0 {
+		return 0, nil
+	}
+
+	reads := make([]*models.AnnouncementRead, len(announcementIDs))
+	for i, id := range announcementIDs {
+		reads[i] = &models.AnnouncementRead{AnnouncementID: id, UserID: userID}
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reads)
+	return result.RowsAffected, result.Error
+}
+
+// CountReads counts the readers of each announcement
+func (r *announcementRepository) CountReads(announcementIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(announcementIDs))
+	if len(announcementIDs) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		AnnouncementID uint
+		Readers        int64
+	}
+	err := r.db.Model(&models.AnnouncementRead{}).
+		Select("announcement_id, COUNT(*) AS readers").
+		Where("announcement_id IN ?", announcementIDs).
+		Group("announcement_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.AnnouncementID] = row.Readers
+	}
+	return counts, nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// APIKeyRepository interface defines API key and usage data access methods
+type APIKeyRepository interface {
+	// Basic CRUD operations
+	Create(key *models.APIKey) error
+	GetByID(id uint) (*models.APIKey, error)
+	GetByHash(hash string) (*models.APIKey, error)
+
+	// List operations
+	ListByUser(userID uint) ([]*models.APIKey, error)
+	CountActiveByUser(userID uint) (int64, error)
+
+	// Business operations
+	Revoke(id uint) error
+	UpdateLimits(id uint, dailyQuota int64, rateLimit float64, burst int) error
+	UpdateLastUsed(id uint, usedAt time.Time) error
+
+	// Usage operations
+	AddUsage(keyID uint, date time.Time, delta models.APIKeyUsage) error
+	GetUsage(keyID uint, from, to time.Time) ([]*models.APIKeyUsage, error)
+	GetDailyRequests(keyID uint, date time.Time) (int64, error)
+}
+
+// apiKeyRepository implements APIKeyRepository interface
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *gorm.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// Create creates a new API key
+func (r *apiKeyRepository) Create(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+// GetByID gets an API key by ID
+func (r *apiKeyRepository) GetByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.First(&key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByHash gets an API key by key hash
+func (r *apiKeyRepository) GetByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.Where("key_hash = ?", hash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListByUser gets all keys of a user, newest first
+func (r *apiKeyRepository) ListByUser(userID uint) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	err := r.db.Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Find(&keys).Error
+	return keys, err
+}
+
+// CountActiveByUser counts the keys of a user that are not revoked
+func (r *apiKeyRepository) CountActiveByUser(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// Revoke revokes an API key
+func (r *apiKeyRepository) Revoke(id uint) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).
+		Error
+}
+
+// UpdateLimits sets the limits of an API key, 0 applying the defaults
+func (r *apiKeyRepository) UpdateLimits(id uint, dailyQuota int64, rateLimit float64, burst int) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"daily_quota": dailyQuota,
+			"rate_limit":  rateLimit,
+			"burst":       burst,
+		}).Error
+}
+
+// UpdateLastUsed updates the last used time of an API key
+func (r *apiKeyRepository) UpdateLastUsed(id uint, usedAt time.Time) error {
+	return r.db.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).
+		Error
+}
+
+// AddUsage adds to the usage of a key on a day, creating it when missing
+func (r *apiKeyRepository) AddUsage(keyID uint, date time.Time, delta models.APIKeyUsage) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.APIKeyUsage{}).
+			Where("key_id = ? AND date = ?", keyID, date).
+			UpdateColumns(map[string]interface{}{
+				"requests":   gorm.Expr("requests + ?", delta.Requests),
+				"errors":     gorm.Expr("errors + ?", delta.Errors),
+				"rejected":   gorm.Expr("rejected + ?", delta.Rejected),
+				"bytes_in":   gorm.Expr("bytes_in + ?", delta.BytesIn),
+				"bytes_out":  gorm.Expr("bytes_out + ?", delta.BytesOut),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+
+		usage := delta
+		usage.ID = 0
+		usage.KeyID = keyID
+		usage.Date = date
+		return tx.Create(&usage).Error
+	})
+}
+
+// GetUsage gets the daily usage of a key within [from, to), oldest first
+func (r *apiKeyRepository) GetUsage(keyID uint, from, to time.Time) ([]*models.APIKeyUsage, error) {
+	var usage []*models.APIKeyUsage
+	err := r.db.Where("key_id = ? AND date >= ? AND date < ?", keyID, from, to).
+		Order("date ASC").
+		Find(&usage).Error
+	return usage, err
+}
+
+// GetDailyRequests gets the requests counted for a key on a day
+func (r *apiKeyRepository) GetDailyRequests(keyID uint, date time.Time) (int64, error) {
+	var requests int64
+	err := r.db.Model(&models.APIKeyUsage{}).
+		Select("COALESCE(SUM(requests), 0)").
+		Where("key_id = ? AND date = ?", keyID, date).
+		Scan(&requests).Error
+	return requests, err
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestAPIKeyUsageAccumulates(t *testing.T) {
+	repo := NewAPIKeyRepository(newTestDB(t))
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, delta := range []models.APIKeyUsage{
+		{Requests: 10, Errors: 1, BytesIn: 100, BytesOut: 1000},
+		{Requests: 5, Rejected: 2, BytesOut: 500},
+	} {
+		if err := repo.AddUsage(1, day, delta); err != nil {
+			t.Fatalf("add usage: %v", err)
+		}
+	}
+	if err := repo.AddUsage(1, day.AddDate(0, 0, 1), models.APIKeyUsage{Requests: 3}); err != nil {
+		t.Fatalf("add usage: %v", err)
+	}
+	if err := repo.AddUsage(2, day, models.APIKeyUsage{Requests: 7}); err != nil {
+		t.Fatalf("add usage: %v", err)
+	}
+
+	requests, err := repo.GetDailyRequests(1, day)
+	if err != nil || requests != 15 {
+		t.Errorf("daily requests = %d, %v, want 15", requests, err)
+	}
+	if requests, err := repo.GetDailyRequests(3, day); err != nil || requests != 0 {
+		t.Errorf("daily requests of an unused key = %d, %v, want 0", requests, err)
+	}
+
+	usage, err := repo.GetUsage(1, day, day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("get usage: %v", err)
+	}
+	if len(usage) != 2 {
+		t.Fatalf("got %d days of usage, want 2", len(usage))
+	}
+	first := usage[0]
+	if first.Requests != 15 || first.Errors != 1 || first.Rejected != 2 || first.BytesIn != 100 || first.BytesOut != 1500 {
+		t.Errorf("first day = %+v, want 15 requests, 1 error, 2 rejected, 100 in, 1500 out", first)
+	}
+	if usage[1].Requests != 3 {
+		t.Errorf("second day = %+v, want 3 requests", usage[1])
+	}
+}
+
+func TestAPIKeyCountActiveByUser(t *testing.T) {
+	repo := NewAPIKeyRepository(newTestDB(t))
+	for i, hash := range []string{"a", "b", "c"} {
+		key := &models.APIKey{UserID: 1, Name: "key", Hint: "sbk_", KeyHash: hash}
+		if err := repo.Create(key); err != nil {
+			t.Fatalf("create key: %v", err)
+		}
+		if i == 0 {
+			if err := repo.Revoke(key.ID); err != nil {
+				t.Fatalf("revoke key: %v", err)
+			}
+		}
+	}
+
+	if count, err := repo.CountActiveByUser(1); err != nil || count != 2 {
+		t.Errorf("active keys = %d, %v, want 2", count, err)
+	}
+	keys, err := repo.ListByUser(1)
+	if err != nil || len(keys) != 3 {
+		t.Fatalf("list keys = %d, %v, want 3", len(keys), err)
+	}
+	if keys[2].IsActive() || !keys[0].IsActive() {
+		t.Errorf("only the first key created should be revoked")
+	}
+}
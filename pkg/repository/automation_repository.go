@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// AutomationRepository interface defines automation rule data access methods
+type AutomationRepository interface {
+	// Basic CRUD operations
+	Create(rule *models.AutomationRule) error
+	GetByID(id uint) (*models.AutomationRule, error)
+	GetByName(name string) (*models.AutomationRule, error)
+	Update(rule *models.AutomationRule) error
+	// Delete deletes a rule with its executions
+	Delete(id uint) error
+	// List gets the rules ordered by name
+	List(offset, limit int) ([]*models.AutomationRule, int64, error)
+	// ListEnabled gets the enabled rules of an event type, oldest first
+	ListEnabled(eventType string) ([]*models.AutomationRule, error)
+
+	// RecordExecution logs a rule matching an event and counts the match
+	RecordExecution(execution *models.AutomationExecution) error
+	// ListExecutions gets the executions of a rule, of all when ruleID is
+	// zero, newest first
+	ListExecutions(ruleID uint, offset, limit int) ([]*models.AutomationExecution, int64, error)
+	// DeleteExecutionsBefore deletes the executions logged before t
+	DeleteExecutionsBefore(t time.Time) (int64, error)
+
+	// SetUserLabel sets a metadata key of a user
+	SetUserLabel(userID uint, key, value string) error
+	// SetNodeLabel sets a metadata key of a node
+	SetNodeLabel(nodeID uint, key, value string) error
+	// SuspendUser suspends an active user, reporting whether it was active
+	SuspendUser(userID uint) (bool, error)
+	// SetNodeSort sets the sort of a node
+	SetNodeSort(nodeID uint, sort int) error
+}
+
+// automationRepository implements AutomationRepository interface
+type automationRepository struct {
+	db *gorm.DB
+}
+
+// NewAutomationRepository creates a new automation rule repository
+func NewAutomationRepository(db *gorm.DB) AutomationRepository {
+	return &automationRepository{db: db}
+}
+
+// Create creates a new automation rule
+func (r *automationRepository) Create(rule *models.AutomationRule) error {
+	return r.db.Create(rule).Error
+}
+
+// GetByID gets an automation rule by ID
+func (r *automationRepository) GetByID(id uint) (*models.AutomationRule, error) {
+	var rule models.AutomationRule
+	if err := r.db.First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetByName gets an automation rule by name
+func (r *automationRepository) GetByName(name string) (*models.AutomationRule, error) {
+	var rule models.AutomationRule
+	if err := r.db.Where("name = ?", name).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Update updates an automation rule
+func (r *automationRepository) Update(rule *models.AutomationRule) error {
+	return r.db.Save(rule).Error
+}
+
+// Delete deletes an automation rule with its executions
+func (r *automationRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rule_id = ?", id).Delete(&models.AutomationExecution{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.AutomationRule{}, id).Error
+	})
+}
+
+// List gets the automation rules ordered by name
+func (r *automationRepository) List(offset, limit int) ([]*models.AutomationRule, int64, error) {
+	var rules []*models.AutomationRule
+	var total int64
+
+	query := r.db.Model(&models.AutomationRule{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("name ASC").Offset(offset).Limit(limit).Find(&rules).Error
+	return rules, total, err
+}
+
+// ListEnabled gets the enabled rules of an event type
+func (r *automationRepository) ListEnabled(eventType string) ([]*models.AutomationRule, error) {
+	var rules []*models.AutomationRule
+	err := r.db.Where("event_type = ? AND enabled = ?", eventType, true).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// RecordExecution logs an execution and counts the match of its rule
+func (r *automationRepository) RecordExecution(execution *models.AutomationExecution) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(execution).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AutomationRule{}).Where("id = ?", execution.RuleID).Updates(map[string]any{
+			"match_count":     gorm.Expr("match_count + 1"),
+			"last_matched_at": execution.CreatedAt,
+		}).Error
+	})
+}
+
+// ListExecutions gets the executions of a rule, newest first
+func (r *automationRepository) ListExecutions(ruleID uint, offset, limit int) ([]*models.AutomationExecution, int64, error) {
+	var executions []*models.AutomationExecution
+	var total int64
+
+	query := r.db.Model(&models.AutomationExecution{})
+	if ruleID != 0 {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&executions).Error
+	return executions, total, err
+}
+
+// DeleteExecutionsBefore deletes the executions logged before t
+func (r *automationRepository) DeleteExecutionsBefore(t time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", t).Delete(&models.AutomationExecution{})
+	return result.RowsAffected, result.Error
+}
+
+// SetUserLabel sets a metadata key of a user
+func (r *automationRepository) SetUserLabel(userID uint, key, value string) error {
+	var user models.User
+	if err := r.db.Select("id", "metadata").First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.Metadata == nil {
+		user.Metadata = make(map[string]string)
+	}
+	user.Metadata[key] = value
+	return r.db.Model(&user).Select("Metadata").Updates(&user).Error
+}
+
+// SetNodeLabel sets a metadata key of a node
+func (r *automationRepository) SetNodeLabel(nodeID uint, key, value string) error {
+	var node models.Node
+	if err := r.db.Select("id", "metadata").First(&node, nodeID).Error; err != nil {
+		return err
+	}
+	if node.Metadata == nil {
+		node.Metadata = make(map[string]string)
+	}
+	node.Metadata[key] = value
+	return r.db.Model(&node).Select("Metadata").Updates(&node).Error
+}
+
+// SuspendUser suspends an active user
+func (r *automationRepository) SuspendUser(userID uint) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND status = ?", userID, models.UserStatusActive).
+		Update("status", models.UserStatusSuspended)
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetNodeSort sets the sort of a node
+func (r *automationRepository) SetNodeSort(nodeID uint, sort int) error {
+	result := r.db.Model(&models.Node{}).Where("id = ?", nodeID).Update("sort", sort)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestAutomationRepositoryRules(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewAutomationRepository(db)
+
+	offline := &models.AutomationRule{Name: "Offline", EventType: "node.offline", Enabled: true,
+		Actions: []models.AutomationAction{{Type: models.AutomationActionSetNodeSort, Params: map[string]string{"sort": "100"}}}}
+	disabled := &models.AutomationRule{Name: "Disabled", EventType: "node.offline",
+		Actions: []models.AutomationAction{{Type: models.AutomationActionSuspendUser}}}
+	for _, rule := range []*models.AutomationRule{offline, disabled} {
+		if err := repo.Create(rule); err != nil {
+			t.Fatalf("create rule: %v", err)
+		}
+	}
+	disabled.Description = "kept for later"
+	if err := repo.Update(disabled); err != nil {
+		t.Fatalf("update rule: %v", err)
+	}
+
+	rules, err := repo.ListEnabled("node.offline")
+	if err != nil || len(rules) != 1 || rules[0].ID != offline.ID {
+		t.Fatalf("ListEnabled = %v, %v, want the enabled rule", rules, err)
+	}
+	if rules[0].Actions[0].Params["sort"] != "100" {
+		t.Errorf("actions = %+v, want them decoded", rules[0].Actions)
+	}
+
+	now := time.Now()
+	for _, createdAt := range []time.Time{now.Add(-48 * time.Hour), now} {
+		execution := &models.AutomationExecution{CreatedAt: createdAt, RuleID: offline.ID, EventType: "node.offline", Subject: "node/1",
+			Results: []models.AutomationActionResult{{Type: models.AutomationActionSetNodeSort, Detail: "sort 100"}}}
+		if err := repo.RecordExecution(execution); err != nil {
+			t.Fatalf("RecordExecution: %v", err)
+		}
+	}
+	rule, _ := repo.GetByID(offline.ID)
+	if rule.MatchCount != 2 || rule.LastMatchedAt == nil {
+		t.Errorf("rule = %+v, want 2 matches", rule)
+	}
+
+	if deleted, err := repo.DeleteExecutionsBefore(now.Add(-24 * time.Hour)); err != nil || deleted != 1 {
+		t.Errorf("DeleteExecutionsBefore = %d, %v, want 1", deleted, err)
+	}
+	executions, total, err := repo.ListExecutions(offline.ID, 0, 10)
+	if err != nil || total != 1 || len(executions[0].Results) != 1 {
+		t.Errorf("ListExecutions = %v, %d, %v, want the recent execution", executions, total, err)
+	}
+
+	if err := repo.Delete(offline.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, total, _ := repo.ListExecutions(0, 0, 10); total != 0 {
+		t.Errorf("executions left after deleting the rule = %d", total)
+	}
+}
+
+func TestAutomationRepositoryActions(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewAutomationRepository(db)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "x", Metadata: map[string]string{"source": "import"}}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	node := &models.Node{Name: "tokyo", Type: models.NodeTypeVLESS, Host: "192.0.2.1", Port: 443}
+	if err := db.Create(node).Error; err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+
+	if err := repo.SetUserLabel(user.ID, "risk", "high"); err != nil {
+		t.Fatalf("SetUserLabel: %v", err)
+	}
+	if suspended, err := repo.SuspendUser(user.ID); err != nil || !suspended {
+		t.Fatalf("SuspendUser = %v, %v", suspended, err)
+	}
+	if suspended, _ := repo.SuspendUser(user.ID); suspended {
+		t.Error("suspending a suspended user must report no change")
+	}
+	var got models.User
+	db.First(&got, user.ID)
+	if got.Metadata["risk"] != "high" || got.Metadata["source"] != "import" || got.Status != models.UserStatusSuspended {
+		t.Errorf("user = %v %s, want labeled and suspended", got.Metadata, got.Status)
+	}
+
+	if err := repo.SetNodeLabel(node.ID, "state", "drained"); err != nil {
+		t.Fatalf("SetNodeLabel: %v", err)
+	}
+	if err := repo.SetNodeSort(node.ID, 99); err != nil {
+		t.Fatalf("SetNodeSort: %v", err)
+	}
+	if err := repo.SetNodeSort(node.ID+1, 99); err == nil {
+		t.Error("SetNodeSort of a missing node must fail")
+	}
+	var gotNode models.Node
+	db.First(&gotNode, node.ID)
+	if gotNode.Metadata["state"] != "drained" || gotNode.Sort != 99 {
+		t.Errorf("node = %v %d, want labeled and sorted", gotNode.Metadata, gotNode.Sort)
+	}
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// BlocklistFilter narrows a blocklist listing, empty fields match every entry
+type BlocklistFilter struct {
+	Type   models.BlocklistType
+	Source string
+	// Query matches part of the value
+	Query string
+}
+
+// BlocklistRepository interface defines registration blocklist data access methods
+type BlocklistRepository interface {
+	// Basic CRUD operations
+	Create(entry *models.BlocklistEntry) error
+	GetByID(id uint) (*models.BlocklistEntry, error)
+	GetByValue(t models.BlocklistType, value string) (*models.BlocklistEntry, error)
+	Delete(id uint) error
+	List(filter BlocklistFilter, offset, limit int) ([]*models.BlocklistEntry, int64, error)
+
+	// Matching
+	// Match gets the entries of the email itself and of any of its domains
+	Match(email string, domains []string) ([]*models.BlocklistEntry, error)
+	// ListByType gets every entry of a type
+	ListByType(t models.BlocklistType) ([]*models.BlocklistEntry, error)
+	// RecordBlocked counts a blocked attempt on the entries
+	RecordBlocked(ids []uint, at time.Time) error
+	// BlockedTotal sums the blocked attempts of all entries
+	BlockedTotal() (int64, error)
+
+	// Imported lists
+	// ReplaceSource makes the domain entries of a source exactly domains.
+	// Domains that already have an entry of another source are left alone.
+	ReplaceSource(source string, domains []string) (added, removed int, err error)
+}
+
+// blocklistRepository implements BlocklistRepository interface
+type blocklistRepository struct {
+	db *gorm.DB
+}
+
+// NewBlocklistRepository creates a new blocklist repository
+func NewBlocklistRepository(db *gorm.DB) BlocklistRepository {
+	return &blocklistRepository{db: db}
+}
+
+// Create creates a new blocklist entry
+func (r *blocklistRepository) Create(entry *models.BlocklistEntry) error {
+	return r.db.Create(entry).Error
+}
+
+// GetByID gets blocklist entry by ID
+func (r *blocklistRepository) GetByID(id uint) (*models.BlocklistEntry, error) {
+	var entry models.BlocklistEntry
+	if err := r.db.First(&entry, id).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetByValue gets the entry of a normalized value
+func (r *blocklistRepository) GetByValue(t models.BlocklistType, value string) (*models.BlocklistEntry, error) {
+	var entry models.BlocklistEntry
+	if err := r.db.Where("type = ? AND value = ?", t, value).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Delete deletes blocklist entry
+func (r *blocklistRepository) Delete(id uint) error {
+	return r.db.Delete(&models.BlocklistEntry{}, id).Error
+}
+
+// List gets blocklist entries with pagination, newest first
+func (r *blocklistRepository) List(filter BlocklistFilter, offset, limit int) ([]*models.BlocklistEntry, int64, error) {
+	var entries []*models.BlocklistEntry
+	var total int64
+
+	query := r.db.Model(&models.BlocklistEntry{})
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Source != "" {
+		query = query.Where("source = ?", filter.Source)
+	}
+	if filter.Query != "" {
+		query = query.Where("value LIKE ?", "%"+filter.Query+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
+
+// Match gets the entries of the email itself and of any of its domains
+func (r *blocklistRepository) Match(email string, domains []string) ([]*models.BlocklistEntry, error) {
+	var entries []*models.BlocklistEntry
+	query := r.db.Where("type = ? AND value = ?", models.BlocklistTypeEmail, email)
+	if len(domains) > 0 {
+		query = query.Or("type = ? AND value IN ?", models.BlocklistTypeDomain, domains)
+	}
+	err := query.Order("id ASC").Find(&entries).Error
+	return entries, err
+}
+
+// ListByType gets every entry of a type
+func (r *blocklistRepository) ListByType(t models.BlocklistType) ([]*models.BlocklistEntry, error) {
+	var entries []*models.BlocklistEntry
+	err := r.db.Where("type = ?", t).Order("id ASC").Find(&entries).Error
+	return entries, err
+}
+
+// RecordBlocked counts a blocked attempt on the entries
+func (r *blocklistRepository) RecordBlocked(ids []uint, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.BlocklistEntry{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{
+			"blocked_count":   gorm.Expr("blocked_count + 1"),
+			"last_blocked_at": at,
+		}).Error
+}
+
+// BlockedTotal sums the blocked attempts of all entries
+func (r *blocklistRepository) BlockedTotal() (int64, error) {
+	var total int64
+	err := r.db.Model(&models.BlocklistEntry{}).Select("COALESCE(SUM(blocked_count), 0)").Scan(&total).Error
+	return total, err
+}
+
+// ReplaceSource makes the domain entries of a source exactly domains
+func (r *blocklistRepository) ReplaceSource(source string, domains []string) (added, removed int, err error) {
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var existing []*models.BlocklistEntry
+		if err := tx.Select("id", "value").
+			Where("source = ? AND type = ?", source, models.BlocklistTypeDomain).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+
+		wanted := make(map[string]bool, len(domains))
+		for _, domain := range domains {
+			wanted[domain] = true
+		}
+		have := make(map[string]bool, len(existing))
+		var stale []uint
+		for _, entry := range existing {
+			have[entry.Value] = true
+			if !wanted[entry.Value] {
+				stale = append(stale, entry.ID)
+			}
+		}
+
+		var entries []*models.BlocklistEntry
+		for _, domain := range domains {
+			if !have[domain] {
+				entries = append(entries, &models.BlocklistEntry{
+					Type:   models.BlocklistTypeDomain,
+					Value:  domain,
+					Source: source,
+				})
+			}
+		}
+		if len(entries) > 0 {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(entries, 500)
+			if result.Error != nil {
+				return result.Error
+			}
+			added = int(result.RowsAffected)
+		}
+
+		for start := 0; start < len(stale); start += 500 {
+			end := min(start+500, len(stale))
+			result := tx.Where("id IN ?", stale[start:end]).Delete(&models.BlocklistEntry{})
+			if result.Error != nil {
+				return result.Error
+			}
+			removed += int(result.RowsAffected)
+		}
+		return nil
+	})
+	return added, removed, err
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestBlocklistMatch(t *testing.T) {
+	repo := NewBlocklistRepository(newTestDB(t))
+
+	entries := []*models.BlocklistEntry{
+		{Type: models.BlocklistTypeEmail, Value: "spam@example.com", Source: models.BlocklistSourceManual},
+		{Type: models.BlocklistTypeDomain, Value: "mailinator.com", Source: models.BlocklistSourceManual},
+		{Type: models.BlocklistTypeCIDR, Value: "192.0.2.0/24", Source: models.BlocklistSourceManual},
+	}
+	for _, entry := range entries {
+		if err := repo.Create(entry); err != nil {
+			t.Fatalf("create entry: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		email   string
+		domains []string
+		want    []string
+	}{
+		{"blocked email", "spam@example.com", []string{"example.com", "com"}, []string{"spam@example.com"}},
+		{"blocked parent domain", "a@x.mailinator.com", []string{"x.mailinator.com", "mailinator.com", "com"}, []string{"mailinator.com"}},
+		{"allowed", "user@example.com", []string{"example.com", "com"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, err := repo.Match(tt.email, tt.domains)
+			if err != nil {
+				t.Fatalf("match: %v", err)
+			}
+			var got []string
+			for _, entry := range matched {
+				got = append(got, entry.Value)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := repo.RecordBlocked([]uint{entries[0].ID, entries[1].ID}, time.Now()); err != nil {
+		t.Fatalf("record blocked: %v", err)
+	}
+	if err := repo.RecordBlocked([]uint{entries[0].ID}, time.Now()); err != nil {
+		t.Fatalf("record blocked: %v", err)
+	}
+	entry, err := repo.GetByID(entries[0].ID)
+	if err != nil {
+		t.Fatalf("get entry: %v", err)
+	}
+	if entry.BlockedCount != 2 || entry.LastBlockedAt == nil {
+		t.Errorf("entry = %+v, want 2 blocked attempts", entry)
+	}
+	total, err := repo.BlockedTotal()
+	if err != nil {
+		t.Fatalf("blocked total: %v", err)
+	}
+	if total != 3 {
+		t.Errorf("BlockedTotal() = %d, want 3", total)
+	}
+}
+
+func TestBlocklistReplaceSource(t *testing.T) {
+	repo := NewBlocklistRepository(newTestDB(t))
+	const source = "https://lists.example/disposable.txt"
+
+	// A manual entry of a listed domain is kept as it is
+	manual := &models.BlocklistEntry{Type: models.BlocklistTypeDomain, Value: "shared.com", Source: models.BlocklistSourceManual}
+	if err := repo.Create(manual); err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	added, removed, err := repo.ReplaceSource(source, []string{"a.com", "b.com", "shared.com"})
+	if err != nil {
+		t.Fatalf("replace source: %v", err)
+	}
+	if added != 2 || removed != 0 {
+		t.Errorf("first import added %d, removed %d, want 2 and 0", added, removed)
+	}
+
+	added, removed, err = repo.ReplaceSource(source, []string{"b.com", "c.com"})
+	if err != nil {
+		t.Fatalf("replace source: %v", err)
+	}
+	if added != 1 || removed != 1 {
+		t.Errorf("second import added %d, removed %d, want 1 and 1", added, removed)
+	}
+
+	imported, _, err := repo.List(BlocklistFilter{Source: source}, 0, 10)
+	if err != nil {
+		t.Fatalf("list entries: %v", err)
+	}
+	var got []string
+	for _, entry := range imported {
+		got = append(got, entry.Value)
+	}
+	slices.Sort(got)
+	if want := []string{"b.com", "c.com"}; !slices.Equal(got, want) {
+		t.Errorf("imported entries = %v, want %v", got, want)
+	}
+	if _, err := repo.GetByValue(models.BlocklistTypeDomain, "shared.com"); err != nil {
+		t.Errorf("manual entry was removed: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// CouponRepository interface defines coupon data access methods
+type CouponRepository interface {
+	// Basic CRUD operations
+	Create(coupon *models.Coupon) error
+	GetByID(id uint) (*models.Coupon, error)
+	GetByCode(code string) (*models.Coupon, error)
+	Update(coupon *models.Coupon) error
+	Delete(id uint) error
+
+	// List operations
+	List(offset, limit int, search string) ([]*models.Coupon, int64, error)
+
+	// Redemptions
+	CountUserRedemptions(couponID, userID uint) (int64, error)
+	GetStatistics(couponID uint) (*models.CouponStatistics, error)
+}
+
+// couponRepository implements CouponRepository interface
+type couponRepository struct {
+	db *gorm.DB
+}
+
+// NewCouponRepository creates a new coupon repository
+func NewCouponRepository(db *gorm.DB) CouponRepository {
+	return &couponRepository{db: db}
+}
+
+// Create creates a new coupon
+func (r *couponRepository) Create(coupon *models.Coupon) error {
+	return r.db.Create(coupon).Error
+}
+
+// GetByID gets coupon by ID
+func (r *couponRepository) GetByID(id uint) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := r.db.First(&coupon, id).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// GetByCode gets coupon by its normalized code
+func (r *couponRepository) GetByCode(code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := r.db.Where("code = ?", models.NormalizeCouponCode(code)).First(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// Update updates coupon. The usage counter is maintained by order operations
+// and never overwritten here.
+func (r *couponRepository) Update(coupon *models.Coupon) error {
+	return r.db.Omit("used_count").Save(coupon).Error
+}
+
+// Delete removes a coupon and frees its code. Orders keep the code they were
+// placed with and the redemptions still count in the overall statistics.
+func (r *couponRepository) Delete(id uint) error {
+	return r.db.Delete(&models.Coupon{}, id).Error
+}
+
+// List gets coupons with pagination, newest first
+func (r *couponRepository) List(offset, limit int, search string) ([]*models.Coupon, int64, error) {
+	var coupons []*models.Coupon
+	var total int64
+
+	query := r.db.Model(&models.Coupon{})
+	if search != "" {
+		pattern := "%" + search + "%"
+		query = query.Where("code LIKE ? OR description LIKE ?", pattern, pattern)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&coupons).Error
+	return coupons, total, err
+}
+
+// CountUserRedemptions counts the uses of a coupon by a user
+func (r *couponRepository) CountUserRedemptions(couponID, userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.CouponRedemption{}).
+		Where("coupon_id = ? AND user_id = ?", couponID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// GetStatistics summarizes the redemptions of a coupon, or of all coupons when couponID is 0
+func (r *couponRepository) GetStatistics(couponID uint) (*models.CouponStatistics, error) {
+	stats := &models.CouponStatistics{Currencies: []models.CouponCurrencyStatistics{}}
+
+	redemptions := r.db.Model(&models.CouponRedemption{})
+	if couponID != 0 {
+		redemptions = redemptions.Where("coupon_id = ?", couponID)
+	}
+	var counts struct {
+		Redemptions int64
+		UniqueUsers int64
+	}
+	err := redemptions.Select("COUNT(*) AS redemptions, COUNT(DISTINCT user_id) AS unique_users").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.Redemptions = counts.Redemptions
+	stats.UniqueUsers = counts.UniqueUsers
+
+	// Refunded orders gave nothing up, only paid orders count towards revenue impact
+	orders := r.db.Model(&models.Order{}).
+		Where("coupon_id IS NOT NULL AND status = ?", models.OrderStatusPaid)
+	if couponID != 0 {
+		orders = orders.Where("coupon_id = ?", couponID)
+	}
+	var rows []struct {
+		Currency string
+		Orders   int64
+		Discount int64
+		Revenue  int64
+	}
+	err = orders.Select("currency, COUNT(*) AS orders, SUM(discount) AS discount, SUM(total) AS revenue").
+		Group("currency").
+		Order("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		stats.PaidOrders += row.Orders
+		stats.Currencies = append(stats.Currencies, models.CouponCurrencyStatistics{
+			Currency: row.Currency,
+			Discount: row.Discount,
+			Revenue:  row.Revenue,
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// secondsSince returns the SQL expression of the whole seconds from a
+// timestamp column to a point in time in the dialect of a database
+func secondsSince(db *gorm.DB, column string, to time.Time) clause.Expr {
+	switch db.Dialector.Name() {
+	case "postgres":
+		return gorm.Expr("CAST(EXTRACT(EPOCH FROM (CAST(? AS TIMESTAMPTZ) - "+column+")) AS BIGINT)", to)
+	case "sqlite":
+		return gorm.Expr("CAST((julianday(?) - julianday("+column+")) * 86400 AS INTEGER)", to)
+	default:
+		return gorm.Expr("TIMESTAMPDIFF(SECOND, "+column+", ?)", to)
+	}
+}
+
+// dateOf returns the SQL expression of the date of a timestamp column. SQLite
+// stores timestamps as text in local time and its DATE() converts them to
+// UTC, so the date is cut from the text instead.
+func dateOf(db *gorm.DB, column string) string {
+	if db.Dialector.Name() == "sqlite" {
+		return "substr(" + column + ", 1, 10)"
+	}
+	return "DATE(" + column + ")"
+}
+
+// sqlDate scans a date computed by the database, a time from MySQL and
+// PostgreSQL but text from SQLite
+type sqlDate struct {
+	time.Time
+}
+
+// Scan implements sql.Scanner
+func (d *sqlDate) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		d.Time = v
+		return nil
+	case []byte:
+		return d.parse(string(v))
+	case string:
+		return d.parse(v)
+	case nil:
+		d.Time = time.Time{}
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into a date", value)
+	}
+}
+
+// Value implements driver.Valuer
+func (d sqlDate) Value() (driver.Value, error) {
+	return d.Time, nil
+}
+
+func (d *sqlDate) parse(value string) error {
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// hourlyTrafficRow is a row of the hourly traffic aggregations
+type hourlyTrafficRow struct {
+	UserID           uint
+	NodeID           uint
+	SummaryDate      sqlDate
+	TotalUpload      int64
+	TotalDownload    int64
+	TotalTraffic     int64
+	TotalConnections int64
+}
+
+// scanHourlyTraffic runs an hourly traffic aggregation into summaries
+func scanHourlyTraffic(query *gorm.DB) ([]models.TrafficSummary, error) {
+	var rows []hourlyTrafficRow
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make([]models.TrafficSummary, len(rows))
+	for i, row := range rows {
+		summaries[i] = models.TrafficSummary{
+			UserID:           row.UserID,
+			NodeID:           row.NodeID,
+			SummaryDate:      row.SummaryDate.Time,
+			TotalUpload:      row.TotalUpload,
+			TotalDownload:    row.TotalDownload,
+			TotalTraffic:     row.TotalTraffic,
+			TotalConnections: row.TotalConnections,
+		}
+	}
+	return summaries, nil
+}
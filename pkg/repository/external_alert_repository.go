@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// ExternalAlertRepository interface defines external alert data access methods
+type ExternalAlertRepository interface {
+	// Upsert stores an alert, updating the one of the same source and fingerprint
+	Upsert(alert *models.ExternalAlert) error
+	// FindNodeID gets the node whose name or host equals one of keys, trying
+	// the keys in order, or 0 when none matches
+	FindNodeID(keys []string) (uint, error)
+	// ListFiring gets the alerts still firing at now, newest first
+	ListFiring(now time.Time, limit int) ([]*models.ExternalAlert, error)
+
+	// Maintenance operations
+	CleanupOldResolved(retentionDays int) error
+	CountOldResolved(retentionDays int) (int64, error)
+}
+
+// externalAlertRepository implements ExternalAlertRepository interface
+type externalAlertRepository struct {
+	db *gorm.DB
+}
+
+// NewExternalAlertRepository creates a new external alert repository
+func NewExternalAlertRepository(db *gorm.DB) ExternalAlertRepository {
+	return &externalAlertRepository{db: db}
+}
+
+// Upsert stores an alert, updating the one of the same source and fingerprint
+func (r *externalAlertRepository) Upsert(alert *models.ExternalAlert) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "source"}, {Name: "fingerprint"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "name", "status", "severity", "summary", "labels", "node_id", "starts_at", "ends_at",
+		}),
+	}).Create(alert).Error
+}
+
+// FindNodeID gets the node whose name or host equals one of keys
+func (r *externalAlertRepository) FindNodeID(keys []string) (uint, error) {
+	for _, key := range keys {
+		var nodeIDs []uint
+		err := r.db.Model(&models.Node{}).
+			Where("name = ? OR host = ?", key, key).
+			Order("id ASC").
+			Limit(1).
+			Pluck("id", &nodeIDs).Error
+		if err != nil {
+			return 0, err
+		}
+		if len(nodeIDs) > 0 {
+			return nodeIDs[0], nil
+		}
+	}
+	return 0, nil
+}
+
+// ListFiring gets the alerts still firing at now, newest first
+func (r *externalAlertRepository) ListFiring(now time.Time, limit int) ([]*models.ExternalAlert, error) {
+	var alerts []*models.ExternalAlert
+	err := r.db.
+		Where("status = ? AND (ends_at IS NULL OR ends_at > ?)", models.ExternalAlertStatusFiring, now).
+		Order("starts_at DESC, id DESC").
+		Limit(limit).
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// CleanupOldResolved removes alerts resolved more than retentionDays ago
+func (r *externalAlertRepository) CleanupOldResolved(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.Where("status = ? AND updated_at < ?", models.ExternalAlertStatusResolved, cutoff).
+		Delete(&models.ExternalAlert{}).Error
+}
+
+// CountOldResolved counts the alerts CleanupOldResolved would remove
+func (r *externalAlertRepository) CountOldResolved(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.ExternalAlert{}).
+		Where("status = ? AND updated_at < ?", models.ExternalAlertStatusResolved, cutoff).
+		Count(&count).Error
+	return count, err
+}
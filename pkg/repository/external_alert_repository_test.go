@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestExternalAlertUpsert(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewExternalAlertRepository(db)
+	now := time.Now()
+
+	alert := &models.ExternalAlert{
+		Source:      models.ExternalAlertSourceAlertmanager,
+		Fingerprint: "abc",
+		Name:        "HostDown",
+		Status:      models.ExternalAlertStatusFiring,
+		Severity:    models.SeverityCritical,
+		StartsAt:    now.Add(-time.Hour),
+	}
+	if err := repo.Upsert(alert); err != nil {
+		t.Fatalf("upsert firing alert: %v", err)
+	}
+	firing, err := repo.ListFiring(now, 10)
+	if err != nil {
+		t.Fatalf("list firing alerts: %v", err)
+	}
+	if len(firing) != 1 || firing[0].Name != "HostDown" {
+		t.Fatalf("firing alerts = %v, want HostDown", firing)
+	}
+
+	// The resolution of the same alert updates it
+	ended := now.Add(-time.Minute)
+	resolved := *alert
+	resolved.ID = 0
+	resolved.Status = models.ExternalAlertStatusResolved
+	resolved.EndsAt = &ended
+	if err := repo.Upsert(&resolved); err != nil {
+		t.Fatalf("upsert resolved alert: %v", err)
+	}
+	var count int64
+	if err := db.Model(&models.ExternalAlert{}).Count(&count).Error; err != nil {
+		t.Fatalf("count alerts: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("alerts = %d, want 1", count)
+	}
+	if firing, err = repo.ListFiring(now, 10); err != nil || len(firing) != 0 {
+		t.Errorf("firing alerts = %v (%v), want none", firing, err)
+	}
+
+	// Only alerts resolved past the retention are cleaned up
+	if err := db.Model(&models.ExternalAlert{}).Where("id = ?", alert.ID).
+		Update("updated_at", now.AddDate(0, 0, -8)).Error; err != nil {
+		t.Fatalf("age alert: %v", err)
+	}
+	if old, err := repo.CountOldResolved(7); err != nil || old != 1 {
+		t.Errorf("old resolved alerts = %d (%v), want 1", old, err)
+	}
+	if err := repo.CleanupOldResolved(7); err != nil {
+		t.Fatalf("cleanup resolved alerts: %v", err)
+	}
+	if old, err := repo.CountOldResolved(0); err != nil || old != 0 {
+		t.Errorf("resolved alerts after cleanup = %d (%v), want 0", old, err)
+	}
+}
+
+func TestExternalAlertFindNodeID(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewExternalAlertRepository(db)
+
+	node := &models.Node{Name: "hk-1", Type: models.NodeTypeVLESS, Host: "192.0.2.1", Port: 443}
+	if err := db.Create(node).Error; err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		keys []string
+		want uint
+	}{
+		{"name", []string{"hk-1"}, node.ID},
+		{"host", []string{"192.0.2.1"}, node.ID},
+		{"later key", []string{"unknown", "hk-1"}, node.ID},
+		{"no match", []string{"unknown"}, 0},
+		{"no keys", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.FindNodeID(tt.keys)
+			if err != nil {
+				t.Fatalf("find node: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FindNodeID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// GeoDataRepository interface defines geo database distribution data access methods
+type GeoDataRepository interface {
+	// Artifacts cached by the API server
+	SaveArtifact(artifact *models.GeoDataArtifact) error
+	ListArtifacts() ([]*models.GeoDataArtifact, error)
+
+	// Versions reported by the nodes
+	SaveNodeVersions(nodeID uint, versions []*models.NodeGeoData) error
+	ListNodeVersions() ([]*models.NodeGeoData, error)
+}
+
+// geoDataRepository implements GeoDataRepository interface
+type geoDataRepository struct {
+	db *gorm.DB
+}
+
+// NewGeoDataRepository creates a new geo data repository
+func NewGeoDataRepository(db *gorm.DB) GeoDataRepository {
+	return &geoDataRepository{db: db}
+}
+
+// SaveArtifact creates or updates the artifact with the same name
+func (r *geoDataRepository) SaveArtifact(artifact *models.GeoDataArtifact) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "version", "sha256", "size", "source_url", "published_at", "checked_at",
+		}),
+	}).Create(artifact).Error
+}
+
+// ListArtifacts gets all artifacts ordered by name
+func (r *geoDataRepository) ListArtifacts() ([]*models.GeoDataArtifact, error) {
+	var artifacts []*models.GeoDataArtifact
+	err := r.db.Order("name ASC").Find(&artifacts).Error
+	return artifacts, err
+}
+
+// SaveNodeVersions replaces the versions reported by a node
+func (r *geoDataRepository) SaveNodeVersions(nodeID uint, versions []*models.NodeGeoData) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("node_id = ?", nodeID).Delete(&models.NodeGeoData{}).Error; err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			return nil
+		}
+		for _, version := range versions {
+			version.NodeID = nodeID
+		}
+		return tx.Create(&versions).Error
+	})
+}
+
+// ListNodeVersions gets the versions reported by all nodes
+func (r *geoDataRepository) ListNodeVersions() ([]*models.NodeGeoData, error) {
+	var versions []*models.NodeGeoData
+	err := r.db.Order("node_id ASC, name ASC").Find(&versions).Error
+	return versions, err
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// IntegrityRepository interface defines the queries of the integrity checker,
+// which verifies invariants spanning several tables. The issues it finds are
+// repaired through the repositories owning the data.
+type IntegrityRepository interface {
+	// PlanUserCountIssues gets the plans whose user count differs from the
+	// users on them
+	PlanUserCountIssues(limit int) ([]models.IntegrityIssue, error)
+	// NodeUserCountIssues gets the nodes that are neither online nor degraded
+	// whose user count differs from their enabled user assignments
+	NodeUserCountIssues(limit int) ([]models.IntegrityIssue, error)
+	// UserPlanIssues gets the users whose plan does not exist
+	UserPlanIssues(limit int) ([]models.IntegrityIssue, error)
+	// TrafficSummaryIssues gets the days from from to before to whose daily
+	// summaries total more or less than tolerance away from the records
+	// aggregated so far
+	TrafficSummaryIssues(from, to time.Time, tolerance float64) ([]models.IntegrityIssue, error)
+}
+
+// integrityRepository implements IntegrityRepository interface
+type integrityRepository struct {
+	db *gorm.DB
+}
+
+// NewIntegrityRepository creates a new integrity repository
+func NewIntegrityRepository(db *gorm.DB) IntegrityRepository {
+	return &integrityRepository{db: db}
+}
+
+// PlanUserCountIssues gets the plans whose user count differs from the users on them
+func (r *integrityRepository) PlanUserCountIssues(limit int) ([]models.IntegrityIssue, error) {
+	var issues []models.IntegrityIssue
+	err := r.db.Table("plans").
+		Select("plans.id AS subject_id, COUNT(users.id) AS expected, plans.current_users AS actual").
+		Joins("LEFT JOIN users ON users.plan_id = plans.id AND users.deleted_at IS NULL").
+		Where("plans.deleted_at IS NULL").
+		Group("plans.id, plans.current_users").
+		Having("COUNT(users.id) <> plans.current_users").
+		Order("plans.id").
+		Limit(limit).
+		Scan(&issues).Error
+	return withIntegrityCheck(issues, models.IntegrityPlanUserCount), err
+}
+
+// NodeUserCountIssues gets the nodes not reporting heartbeats whose user
+// count differs from their enabled user assignments
+func (r *integrityRepository) NodeUserCountIssues(limit int) ([]models.IntegrityIssue, error) {
+	var issues []models.IntegrityIssue
+	err := r.db.Table("nodes").
+		Select("nodes.id AS subject_id, COUNT(users.id) AS expected, nodes.current_users AS actual").
+		Joins("LEFT JOIN user_nodes ON user_nodes.node_id = nodes.id AND user_nodes.is_enabled = ? AND user_nodes.deleted_at IS NULL", true).
+		Joins("LEFT JOIN users ON users.id = user_nodes.user_id AND users.deleted_at IS NULL").
+		Where("nodes.deleted_at IS NULL AND nodes.status NOT IN ?", []models.NodeStatus{models.NodeStatusOnline, models.NodeStatusDegraded}).
+		Group("nodes.id, nodes.current_users").
+		Having("COUNT(users.id) <> nodes.current_users").
+		Order("nodes.id").
+		Limit(limit).
+		Scan(&issues).Error
+	return withIntegrityCheck(issues, models.IntegrityNodeUserCount), err
+}
+
+// UserPlanIssues gets the users whose plan does not exist; Actual is the
+// missing plan ID
+func (r *integrityRepository) UserPlanIssues(limit int) ([]models.IntegrityIssue, error) {
+	var issues []models.IntegrityIssue
+	err := r.db.Table("users").
+		Select("users.id AS subject_id, users.plan_id AS actual").
+		Joins("LEFT JOIN plans ON plans.id = users.plan_id AND plans.deleted_at IS NULL").
+		Where("users.deleted_at IS NULL AND plans.id IS NULL").
+		Order("users.id").
+		Limit(limit).
+		Scan(&issues).Error
+	return withIntegrityCheck(issues, models.IntegrityUserPlan), err
+}
+
+// TrafficSummaryIssues compares the daily summaries of each day with the
+// records up to the summary watermark, the records aggregated so far
+func (r *integrityRepository) TrafficSummaryIssues(from, to time.Time, tolerance float64) ([]models.IntegrityIssue, error) {
+	watermark, err := getWatermark(r.db, models.TrafficSummaryWatermark)
+	if err != nil {
+		return nil, err
+	}
+
+	type dayTotal struct {
+		Date  time.Time
+		Bytes int64
+	}
+	var records, summaries []dayTotal
+	err = r.db.Model(&models.TrafficRecord{}).
+		Select("record_date AS date, SUM(upload + download) AS bytes").
+		Where("record_date >= ? AND record_date < ? AND id <= ?", from, to, watermark.LastRecordID).
+		Group("record_date").
+		Scan(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	err = r.db.Model(&models.TrafficSummary{}).
+		Select("summary_date AS date, SUM(total_upload + total_download) AS bytes").
+		Where("summary_type = ? AND summary_date >= ? AND summary_date < ?", models.SummaryTypeDaily, from, to).
+		Group("summary_date").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Days with records or summaries only are compared with zero
+	totals := make(map[time.Time]*models.IntegrityIssue)
+	var days []time.Time
+	total := func(date time.Time) *models.IntegrityIssue {
+		day := date.UTC().Truncate(24 * time.Hour)
+		issue, ok := totals[day]
+		if !ok {
+			issue = &models.IntegrityIssue{Check: models.IntegrityTrafficSummary, Date: &day}
+			totals[day] = issue
+			days = append(days, day)
+		}
+		return issue
+	}
+	for _, record := range records {
+		total(record.Date).Expected += record.Bytes
+	}
+	for _, summary := range summaries {
+		total(summary.Date).Actual += summary.Bytes
+	}
+
+	var issues []models.IntegrityIssue
+	for _, day := range days {
+		if issue := totals[day]; models.TrafficDrifted(issue.Expected, issue.Actual, tolerance) {
+			issues = append(issues, *issue)
+		}
+	}
+	return issues, nil
+}
+
+// withIntegrityCheck sets the check of issues scanned from a query
+func withIntegrityCheck(issues []models.IntegrityIssue, check models.IntegrityCheck) []models.IntegrityIssue {
+	for i := range issues {
+		issues[i].Check = check
+	}
+	return issues
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestIntegrityUserCountIssues(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewIntegrityRepository(db)
+
+	plan := &models.Plan{Name: "Pro", CurrentUsers: 5}
+	if err := db.Create(plan).Error; err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+	offline := &models.Node{Name: "offline", Type: models.NodeTypeVLESS, Host: "192.0.2.1", Port: 443, CurrentUsers: 3}
+	online := &models.Node{Name: "online", Type: models.NodeTypeVLESS, Host: "192.0.2.2", Port: 443, CurrentUsers: 40,
+		Status: models.NodeStatusOnline}
+	for _, node := range []*models.Node{offline, online} {
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+
+	users := []*models.User{
+		{Username: "a", PlanID: plan.ID},
+		{Username: "b", PlanID: plan.ID},
+		{Username: "orphan", PlanID: plan.ID + 100},
+	}
+	for _, user := range users {
+		user.Email = user.Username + "@example.com"
+		user.Password = "x"
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		for _, node := range []*models.Node{offline, online} {
+			if err := db.Create(&models.UserNode{UserID: user.ID, NodeID: node.ID, IsEnabled: true}).Error; err != nil {
+				t.Fatalf("assign user: %v", err)
+			}
+		}
+	}
+
+	planIssues, err := repo.PlanUserCountIssues(10)
+	if err != nil {
+		t.Fatalf("plan user count issues: %v", err)
+	}
+	if len(planIssues) != 1 || planIssues[0].SubjectID != plan.ID || planIssues[0].Expected != 2 || planIssues[0].Actual != 5 {
+		t.Errorf("plan issues = %+v, want plan %d with 2 users counting 5", planIssues, plan.ID)
+	}
+
+	// Online nodes report their connected users instead
+	nodeIssues, err := repo.NodeUserCountIssues(10)
+	if err != nil {
+		t.Fatalf("node user count issues: %v", err)
+	}
+	if len(nodeIssues) != 0 {
+		t.Errorf("node issues = %+v, want none", nodeIssues)
+	}
+	if err := db.Model(offline).Update("current_users", 1).Error; err != nil {
+		t.Fatalf("update node: %v", err)
+	}
+	nodeIssues, err = repo.NodeUserCountIssues(10)
+	if err != nil {
+		t.Fatalf("node user count issues: %v", err)
+	}
+	if len(nodeIssues) != 1 || nodeIssues[0].SubjectID != offline.ID || nodeIssues[0].Expected != 3 || nodeIssues[0].Check != models.IntegrityNodeUserCount {
+		t.Errorf("node issues = %+v, want node %d with 3 assignments", nodeIssues, offline.ID)
+	}
+
+	planRefIssues, err := repo.UserPlanIssues(10)
+	if err != nil {
+		t.Fatalf("user plan issues: %v", err)
+	}
+	if len(planRefIssues) != 1 || planRefIssues[0].SubjectID != users[2].ID || planRefIssues[0].Actual != int64(plan.ID+100) {
+		t.Errorf("user plan issues = %+v, want user %d", planRefIssues, users[2].ID)
+	}
+}
+
+func TestIntegrityTrafficSummaryIssues(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewIntegrityRepository(db)
+	traffic := NewTrafficRepository(db)
+	now := time.Now()
+	day := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -2)
+	old := now.Add(-time.Hour)
+
+	records := []*models.TrafficRecord{
+		{UserID: 1, NodeID: 1, Upload: 100, Download: 900, RecordDate: day, CreatedAt: old},
+		{UserID: 1, NodeID: 1, Upload: 500, Download: 500, RecordDate: day.AddDate(0, 0, 1), CreatedAt: old},
+	}
+	if err := traffic.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+	if _, err := traffic.AggregateNewRecords(now, 10); err != nil {
+		t.Fatalf("aggregate records: %v", err)
+	}
+
+	from, to := day, day.AddDate(0, 0, 2)
+	issues, err := repo.TrafficSummaryIssues(from, to, 0.01)
+	if err != nil {
+		t.Fatalf("traffic summary issues: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("issues = %+v, want none", issues)
+	}
+
+	// A drift within the tolerance is not an issue
+	err = db.Model(&models.TrafficSummary{}).
+		Where("summary_type = ? AND summary_date = ?", models.SummaryTypeDaily, day).
+		Update("total_download", 905).Error
+	if err != nil {
+		t.Fatalf("update summary: %v", err)
+	}
+	if issues, err = repo.TrafficSummaryIssues(from, to, 0.01); err != nil || len(issues) != 0 {
+		t.Fatalf("issues = %+v (%v), want none", issues, err)
+	}
+
+	err = db.Model(&models.TrafficSummary{}).
+		Where("summary_type = ? AND summary_date = ?", models.SummaryTypeDaily, day).
+		Update("total_download", 1900).Error
+	if err != nil {
+		t.Fatalf("update summary: %v", err)
+	}
+	issues, err = repo.TrafficSummaryIssues(from, to, 0.01)
+	if err != nil {
+		t.Fatalf("traffic summary issues: %v", err)
+	}
+	if len(issues) != 1 || !issues[0].Date.Equal(day) || issues[0].Expected != 1000 || issues[0].Actual != 2000 {
+		t.Errorf("issues = %+v, want %s with 1000 bytes recorded and 2000 summarized", issues, day.Format("2006-01-02"))
+	}
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// LeaseRepository interface defines service lease data access methods
+type LeaseRepository interface {
+	// TryAcquire acquires or renews the lease for holder. It returns the lease
+	// and whether holder owns it afterwards.
+	TryAcquire(name, holder string, ttl time.Duration) (*models.ServiceLease, bool, error)
+	Release(name, holder string) error
+	Get(name string) (*models.ServiceLease, error)
+}
+
+// leaseRepository implements LeaseRepository interface
+type leaseRepository struct {
+	db *gorm.DB
+}
+
+// NewLeaseRepository creates a new lease repository
+func NewLeaseRepository(db *gorm.DB) LeaseRepository {
+	return &leaseRepository{db: db}
+}
+
+// TryAcquire takes the lease over when it is free or expired, or renews it when
+// holder already owns it. The conditional UPDATE makes concurrent attempts safe.
+func (r *leaseRepository) TryAcquire(name, holder string, ttl time.Duration) (*models.ServiceLease, bool, error) {
+	now := time.Now()
+
+	// Make sure the lease row exists, an expired row is free to take
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ServiceLease{
+		Name:      name,
+		ExpiresAt: time.Unix(0, 0),
+	}).Error
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Epoch and acquired_at are assigned before holder, MySQL evaluates
+	// assignments left to right
+	result := r.db.Exec(`
+		UPDATE service_leases SET
+			epoch = CASE WHEN holder = ? THEN epoch ELSE epoch + 1 END,
+			acquired_at = CASE WHEN holder = ? THEN acquired_at ELSE ? END,
+			holder = ?,
+			renewed_at = ?,
+			expires_at = ?
+		WHERE name = ? AND (holder = ? OR expires_at < ?)`,
+		holder, holder, now, holder, now, now.Add(ttl), name, holder, now,
+	)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	lease, err := r.Get(name)
+	if err != nil {
+		return nil, false, err
+	}
+	return lease, result.RowsAffected > 0 && lease.Holder == holder, nil
+}
+
+// Release gives the lease up so that a standby can take over without waiting for it to expire
+func (r *leaseRepository) Release(name, holder string) error {
+	return r.db.Model(&models.ServiceLease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Update("expires_at", time.Unix(0, 0)).Error
+}
+
+// Get gets a lease by name
+func (r *leaseRepository) Get(name string) (*models.ServiceLease, error) {
+	var lease models.ServiceLease
+	if err := r.db.Where("name = ?", name).First(&lease).Error; err != nil {
+		return nil, err
+	}
+	return &lease, nil
+}
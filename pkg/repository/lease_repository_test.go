@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaseAcquireRenewTakeover(t *testing.T) {
+	repo := NewLeaseRepository(newTestDB(t))
+	const ttl = 200 * time.Millisecond
+
+	lease, held, err := repo.TryAcquire("api", "a", ttl)
+	if err != nil || !held {
+		t.Fatalf("TryAcquire(a) = %v, %v", held, err)
+	}
+	if lease.Holder != "a" || lease.Epoch != 1 {
+		t.Fatalf("acquired lease = holder %q, epoch %d; want a, 1", lease.Holder, lease.Epoch)
+	}
+	acquired := lease.AcquiredAt
+
+	// Renewing extends the lease in the same epoch
+	renewed, held, err := repo.TryAcquire("api", "a", ttl)
+	if err != nil || !held {
+		t.Fatalf("TryAcquire(a) renewal = %v, %v", held, err)
+	}
+	if renewed.Epoch != 1 || !renewed.AcquiredAt.Equal(acquired) || renewed.ExpiresAt.Before(lease.ExpiresAt) {
+		t.Errorf("renewed lease = epoch %d, acquired %v, expires %v", renewed.Epoch, renewed.AcquiredAt, renewed.ExpiresAt)
+	}
+
+	// A held lease is not taken over
+	lease, held, err = repo.TryAcquire("api", "b", ttl)
+	if err != nil || held {
+		t.Fatalf("TryAcquire(b) of a held lease = %v, %v", held, err)
+	}
+	if lease.Holder != "a" {
+		t.Errorf("lease holder = %q, want a", lease.Holder)
+	}
+
+	// Once expired it is, in a new epoch
+	time.Sleep(ttl + 50*time.Millisecond)
+	lease, held, err = repo.TryAcquire("api", "b", ttl)
+	if err != nil || !held {
+		t.Fatalf("TryAcquire(b) of an expired lease = %v, %v", held, err)
+	}
+	if lease.Holder != "b" || lease.Epoch != 2 {
+		t.Errorf("taken over lease = holder %q, epoch %d; want b, 2", lease.Holder, lease.Epoch)
+	}
+
+	// The former holder lost it
+	if _, held, err := repo.TryAcquire("api", "a", ttl); err != nil || held {
+		t.Errorf("TryAcquire(a) of a lost lease = %v, %v", held, err)
+	}
+}
+
+func TestLeaseRelease(t *testing.T) {
+	repo := NewLeaseRepository(newTestDB(t))
+
+	if _, held, err := repo.TryAcquire("api", "a", time.Hour); err != nil || !held {
+		t.Fatalf("TryAcquire(a) = %v, %v", held, err)
+	}
+	// Only the holder releases the lease
+	if err := repo.Release("api", "b"); err != nil {
+		t.Fatalf("Release(b): %v", err)
+	}
+	if _, held, err := repo.TryAcquire("api", "b", time.Hour); err != nil || held {
+		t.Fatalf("TryAcquire(b) after a release by another instance = %v, %v", held, err)
+	}
+
+	if err := repo.Release("api", "a"); err != nil {
+		t.Fatalf("Release(a): %v", err)
+	}
+	lease, held, err := repo.TryAcquire("api", "b", time.Hour)
+	if err != nil || !held {
+		t.Fatalf("TryAcquire(b) after a release = %v, %v", held, err)
+	}
+	if lease.Epoch != 2 {
+		t.Errorf("epoch = %d after the takeover, want 2", lease.Epoch)
+	}
+}
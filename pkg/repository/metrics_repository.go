@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// MetricsRepository interface defines node metrics history data access methods
+type MetricsRepository interface {
+	// Basic operations
+	RecordSample(sample *models.NodeMetricsHistory) error
+
+	// Query operations
+	GetRange(nodeID uint, resolution models.MetricsResolution, start, end time.Time) ([]*models.NodeMetricsHistory, error)
+
+	// Maintenance operations
+	Downsample(source, target models.MetricsResolution, before time.Time) (int64, error)
+	DeleteOlderThan(resolution models.MetricsResolution, before time.Time) (int64, error)
+}
+
+// metricsRepository implements MetricsRepository interface
+type metricsRepository struct {
+	db *gorm.DB
+}
+
+// NewMetricsRepository creates a new metrics repository
+func NewMetricsRepository(db *gorm.DB) MetricsRepository {
+	return &metricsRepository{db: db}
+}
+
+// RecordSample stores a sample in its bucket, replacing an earlier sample of the same bucket
+func (r *metricsRepository) RecordSample(sample *models.NodeMetricsHistory) error {
+	if sample.Resolution == "" {
+		sample.Resolution = models.MetricsResolutionMinute
+	}
+	sample.Timestamp = sample.Timestamp.UTC().Truncate(sample.Resolution.Duration())
+	if sample.SampleCount == 0 {
+		sample.SampleCount = 1
+	}
+
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "node_id"}, {Name: "resolution"}, {Name: "timestamp"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "cpu_usage", "memory_usage", "disk_usage", "load_average",
+			"network_in_bytes_per_sec", "network_out_bytes_per_sec", "connections",
+			"max_cpu_usage", "max_connections", "sample_count",
+		}),
+	}).Create(sample).Error
+}
+
+// GetRange gets the samples of a node at the given resolution within [start, end)
+func (r *metricsRepository) GetRange(nodeID uint, resolution models.MetricsResolution, start, end time.Time) ([]*models.NodeMetricsHistory, error) {
+	var samples []*models.NodeMetricsHistory
+	err := r.db.Where("node_id = ? AND resolution = ? AND timestamp >= ? AND timestamp < ?",
+		nodeID, resolution, start.UTC(), end.UTC()).
+		Order("timestamp ASC").
+		Find(&samples).Error
+	return samples, err
+}
+
+// Downsample folds source samples older than before into target buckets and
+// removes them. Only complete target buckets are folded. Aggregation happens in
+// Go so that bucketing does not depend on dialect specific date functions.
+func (r *metricsRepository) Downsample(source, target models.MetricsResolution, before time.Time) (int64, error) {
+	before = before.UTC().Truncate(target.Duration())
+
+	var nodeIDs []uint
+	err := r.db.Model(&models.NodeMetricsHistory{}).
+		Where("resolution = ? AND timestamp < ?", source, before).
+		Distinct("node_id").
+		Pluck("node_id", &nodeIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var folded int64
+	for _, nodeID := range nodeIDs {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var samples []*models.NodeMetricsHistory
+			err := tx.Where("node_id = ? AND resolution = ? AND timestamp < ?", nodeID, source, before).
+				Order("timestamp ASC").
+				Find(&samples).Error
+			if err != nil || len(samples) == 0 {
+				return err
+			}
+
+			buckets := make(map[time.Time]*models.NodeMetricsHistory)
+			var order []time.Time
+			for _, sample := range samples {
+				ts := sample.Timestamp.UTC().Truncate(target.Duration())
+				bucket, ok := buckets[ts]
+				if !ok {
+					bucket = &models.NodeMetricsHistory{
+						NodeID:     nodeID,
+						Resolution: target,
+						Timestamp:  ts,
+					}
+					// Late samples are merged into a bucket that already exists
+					var existing models.NodeMetricsHistory
+					err := tx.Where("node_id = ? AND resolution = ? AND timestamp = ?", nodeID, target, ts).
+						Limit(1).Find(&existing).Error
+					if err != nil {
+						return err
+					}
+					if existing.ID != 0 {
+						bucket = &existing
+					}
+					buckets[ts] = bucket
+					order = append(order, ts)
+				}
+				mergeMetricsSample(bucket, sample)
+			}
+
+			for _, ts := range order {
+				if err := tx.Save(buckets[ts]).Error; err != nil {
+					return err
+				}
+			}
+
+			res := tx.Where("node_id = ? AND resolution = ? AND timestamp < ?", nodeID, source, before).
+				Delete(&models.NodeMetricsHistory{})
+			folded += res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			return folded, err
+		}
+	}
+	return folded, nil
+}
+
+// DeleteOlderThan removes samples of a resolution older than the given time
+func (r *metricsRepository) DeleteOlderThan(resolution models.MetricsResolution, before time.Time) (int64, error) {
+	res := r.db.Where("resolution = ? AND timestamp < ?", resolution, before.UTC()).
+		Delete(&models.NodeMetricsHistory{})
+	return res.RowsAffected, res.Error
+}
+
+// mergeMetricsSample folds a sample into a bucket, weighting averages by sample count
+func mergeMetricsSample(bucket, sample *models.NodeMetricsHistory) {
+	n := float64(bucket.SampleCount)
+	m := float64(sample.SampleCount)
+	if m == 0 {
+		m = 1
+	}
+	avg := func(a, b float64) float64 {
+		return (a*n + b*m) / (n + m)
+	}
+
+	bucket.CPUUsage = avg(bucket.CPUUsage, sample.CPUUsage)
+	bucket.MemoryUsage = avg(bucket.MemoryUsage, sample.MemoryUsage)
+	bucket.DiskUsage = avg(bucket.DiskUsage, sample.DiskUsage)
+	bucket.LoadAverage = avg(bucket.LoadAverage, sample.LoadAverage)
+	bucket.NetworkInBytesPerSec = int64(avg(float64(bucket.NetworkInBytesPerSec), float64(sample.NetworkInBytesPerSec)))
+	bucket.NetworkOutBytesPerSec = int64(avg(float64(bucket.NetworkOutBytesPerSec), float64(sample.NetworkOutBytesPerSec)))
+	bucket.Connections = int32(avg(float64(bucket.Connections), float64(sample.Connections)))
+
+	if sample.MaxCPUUsage > bucket.MaxCPUUsage {
+		bucket.MaxCPUUsage = sample.MaxCPUUsage
+	}
+	if sample.MaxConnections > bucket.MaxConnections {
+		bucket.MaxConnections = sample.MaxConnections
+	}
+	bucket.SampleCount += int(m)
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// ErrNodeCommandFinished is returned when a result is reported for a command
+// that already has one or timed out
+var ErrNodeCommandFinished = errors.New("node command already finished")
+
+// NodeCommandFilter narrows a node command listing, empty fields match every command
+type NodeCommandFilter struct {
+	NodeID uint
+	UserID string
+	Status models.NodeCommandStatus
+}
+
+// NodeCommandRepository interface defines node command data access methods
+type NodeCommandRepository interface {
+	Create(command *models.NodeCommand) error
+	List(filter NodeCommandFilter, offset, limit int) ([]*models.NodeCommand, int64, error)
+
+	// MarkDelivered marks the pending commands among commandIDs delivered
+	MarkDelivered(commandIDs []string, at time.Time) error
+	// Complete records the result a node reported for one of its commands,
+	// gorm.ErrRecordNotFound if the node has no such command and
+	// ErrNodeCommandFinished if the command already has a result
+	Complete(nodeID uint, commandID string, status models.NodeCommandStatus, output string, at time.Time) (*models.NodeCommand, error)
+	// TimeOut marks the commands created before cutoff that have no result
+	// timed out, returning how many were
+	TimeOut(cutoff, at time.Time) (int64, error)
+	// LatestSpeedLimits returns the latest command that set the speed limit
+	// of each user on each node, by node and user
+	LatestSpeedLimits(filter NodeCommandFilter) ([]*models.NodeCommand, error)
+}
+
+// nodeCommandRepository implements NodeCommandRepository interface
+type nodeCommandRepository struct {
+	db *gorm.DB
+}
+
+// NewNodeCommandRepository creates a new node command repository
+func NewNodeCommandRepository(db *gorm.DB) NodeCommandRepository {
+	return &nodeCommandRepository{db: db}
+}
+
+// Create records a command queued to a node
+func (r *nodeCommandRepository) Create(command *models.NodeCommand) error {
+	return r.db.Create(command).Error
+}
+
+// List gets node commands with pagination, newest first
+func (r *nodeCommandRepository) List(filter NodeCommandFilter, offset, limit int) ([]*models.NodeCommand, int64, error) {
+	var commands []*models.NodeCommand
+	var total int64
+
+	query := r.db.Model(&models.NodeCommand{})
+	if filter.NodeID != 0 {
+		query = query.Where("node_id = ?", filter.NodeID)
+	}
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&commands).Error
+	return commands, total, err
+}
+
+// MarkDelivered marks pending commands delivered
+func (r *nodeCommandRepository) MarkDelivered(commandIDs []string, at time.Time) error {
+	if len(commandIDs) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.NodeCommand{}).
+		Where("command_id IN ? AND status = ?", commandIDs, models.NodeCommandPending).
+		Updates(map[string]any{"status": models.NodeCommandDelivered, "delivered_at": at}).Error
+}
+
+// Complete records the result of a command
+func (r *nodeCommandRepository) Complete(nodeID uint, commandID string, status models.NodeCommandStatus, output string, at time.Time) (*models.NodeCommand, error) {
+	var command models.NodeCommand
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("command_id = ? AND node_id = ?", commandID, nodeID).First(&command).Error; err != nil {
+			return err
+		}
+
+		// A command may time out while the node executes it
+		result := tx.Model(&models.NodeCommand{}).
+			Where("id = ? AND status IN ?", command.ID, []models.NodeCommandStatus{models.NodeCommandPending, models.NodeCommandDelivered}).
+			Updates(map[string]any{"status": status, "output": output, "completed_at": at})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNodeCommandFinished
+		}
+		return tx.First(&command, command.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &command, nil
+}
+
+// TimeOut marks stale commands timed out
+func (r *nodeCommandRepository) TimeOut(cutoff, at time.Time) (int64, error) {
+	result := r.db.Model(&models.NodeCommand{}).
+		Where("created_at < ? AND status IN ?", cutoff, []models.NodeCommandStatus{models.NodeCommandPending, models.NodeCommandDelivered}).
+		Updates(map[string]any{"status": models.NodeCommandTimedOut, "completed_at": at})
+	return result.RowsAffected, result.Error
+}
+
+// LatestSpeedLimits returns the latest speed limit commands per node and user
+func (r *nodeCommandRepository) LatestSpeedLimits(filter NodeCommandFilter) ([]*models.NodeCommand, error) {
+	latest := r.db.Model(&models.NodeCommand{}).
+		Select("MAX(id)").
+		Where("speed_limit IS NOT NULL").
+		Group("node_id, user_id")
+	if filter.NodeID != 0 {
+		latest = latest.Where("node_id = ?", filter.NodeID)
+	}
+	if filter.UserID != "" {
+		latest = latest.Where("user_id = ?", filter.UserID)
+	}
+
+	var commands []*models.NodeCommand
+	err := r.db.Where("id IN (?)", latest).Order("node_id, user_id").Find(&commands).Error
+	return commands, err
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestNodeCommandRepositoryLifecycle(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewNodeCommandRepository(db)
+
+	for _, id := range []string{"cmd-1", "cmd-2"} {
+		if err := repo.Create(&models.NodeCommand{CommandID: id, NodeID: 1, Type: "ADD_USER", Status: models.NodeCommandPending}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	now := time.Now()
+	if err := repo.MarkDelivered([]string{"cmd-1", "cmd-2"}, now); err != nil {
+		t.Fatalf("MarkDelivered: %v", err)
+	}
+	delivered, total, err := repo.List(NodeCommandFilter{Status: models.NodeCommandDelivered}, 0, 10)
+	if err != nil || total != 2 || delivered[0].DeliveredAt == nil {
+		t.Fatalf("List delivered = %d commands, %v, want 2 with a delivery time", total, err)
+	}
+
+	command, err := repo.Complete(1, "cmd-1", models.NodeCommandFailed, "user not found", now)
+	if err != nil || command.Status != models.NodeCommandFailed || command.Output != "user not found" || command.CompletedAt == nil {
+		t.Fatalf("Complete = %+v, %v, want the failure recorded", command, err)
+	}
+	if _, err := repo.Complete(1, "cmd-1", models.NodeCommandSucceeded, "", now); !errors.Is(err, ErrNodeCommandFinished) {
+		t.Errorf("second Complete = %v, want ErrNodeCommandFinished", err)
+	}
+	// Nodes only report the results of their own commands
+	if _, err := repo.Complete(2, "cmd-2", models.NodeCommandSucceeded, "", now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Complete by another node = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	timedOut, err := repo.TimeOut(now.Add(time.Minute), now)
+	if err != nil || timedOut != 1 {
+		t.Fatalf("TimeOut = %d, %v, want cmd-2 timed out", timedOut, err)
+	}
+	if _, err := repo.Complete(1, "cmd-2", models.NodeCommandSucceeded, "", now); !errors.Is(err, ErrNodeCommandFinished) {
+		t.Errorf("Complete after the timeout = %v, want ErrNodeCommandFinished", err)
+	}
+}
+
+func TestNodeCommandRepositoryLatestSpeedLimits(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewNodeCommandRepository(db)
+
+	limit := func(n int64) *int64 { return &n }
+	commands := []*models.NodeCommand{
+		{CommandID: "cmd-1", NodeID: 1, UserID: "7", Type: "ADD_USER", SpeedLimit: limit(1000)},
+		{CommandID: "cmd-2", NodeID: 1, UserID: "7", Type: "UPDATE_USER", SpeedLimit: limit(0)},
+		// Commands without a speed limit leave the latest one in place
+		{CommandID: "cmd-3", NodeID: 1, UserID: "7", Type: "RESET_TRAFFIC"},
+		{CommandID: "cmd-4", NodeID: 2, UserID: "7", Type: "ADD_USER", SpeedLimit: limit(2000)},
+		{CommandID: "cmd-5", NodeID: 2, UserID: "8", Type: "ADD_USER", SpeedLimit: limit(3000)},
+	}
+	for _, command := range commands {
+		command.Status = models.NodeCommandPending
+		if err := repo.Create(command); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	latest, err := repo.LatestSpeedLimits(NodeCommandFilter{UserID: "7"})
+	if err != nil {
+		t.Fatalf("LatestSpeedLimits: %v", err)
+	}
+	if len(latest) != 2 || latest[0].CommandID != "cmd-2" || latest[1].CommandID != "cmd-4" {
+		t.Fatalf("LatestSpeedLimits of user 7 = %+v, want cmd-2 and cmd-4", latest)
+	}
+
+	latest, err = repo.LatestSpeedLimits(NodeCommandFilter{NodeID: 2})
+	if err != nil || len(latest) != 2 || latest[0].UserID != "7" || latest[1].UserID != "8" {
+		t.Fatalf("LatestSpeedLimits of node 2 = %+v, %v, want users 7 and 8", latest, err)
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// NodeConfigVersionRepository interface defines node config history data access methods
+type NodeConfigVersionRepository interface {
+	Apply(nodeID uint, version *models.NodeConfigVersion) error
+	Get(nodeID uint, version int) (*models.NodeConfigVersion, error)
+	List(nodeID uint, offset, limit int) ([]*models.NodeConfigVersion, int64, error)
+
+	// Overrides
+	ListOverrides(nodeID uint) ([]*models.NodeConfigOverride, error)
+	SetOverride(override *models.NodeConfigOverride) error
+	DeleteOverrides(nodeID uint, paths []string) (int64, error)
+}
+
+// nodeConfigVersionRepository implements NodeConfigVersionRepository interface
+type nodeConfigVersionRepository struct {
+	db *gorm.DB
+}
+
+// NewNodeConfigVersionRepository creates a new node config version repository
+func NewNodeConfigVersionRepository(db *gorm.DB) NodeConfigVersionRepository {
+	return &nodeConfigVersionRepository{db: db}
+}
+
+// Apply sets the node's config to the content of version, bumps its config
+// version and records the version under the new number. It returns
+// gorm.ErrRecordNotFound when the node does not exist.
+func (r *nodeConfigVersionRepository) Apply(nodeID uint, version *models.NodeConfigVersion) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Node{}).Where("id = ?", nodeID).Updates(map[string]interface{}{
+			"config_content": version.Content,
+			"config_version": gorm.Expr("config_version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var number int
+		if err := tx.Model(&models.Node{}).Where("id = ?", nodeID).Select("config_version").Scan(&number).Error; err != nil {
+			return err
+		}
+
+		version.NodeID = nodeID
+		version.Version = number
+		return tx.Create(version).Error
+	})
+}
+
+// Get gets a version of a node's config with its content
+func (r *nodeConfigVersionRepository) Get(nodeID uint, version int) (*models.NodeConfigVersion, error) {
+	var v models.NodeConfigVersion
+	err := r.db.Where("node_id = ? AND version = ?", nodeID, version).First(&v).Error
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List gets the versions of a node's config, newest first, without their content
+func (r *nodeConfigVersionRepository) List(nodeID uint, offset, limit int) ([]*models.NodeConfigVersion, int64, error) {
+	var versions []*models.NodeConfigVersion
+	var total int64
+
+	query := r.db.Model(&models.NodeConfigVersion{}).Where("node_id = ?", nodeID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Omit("content", "base").
+		Order("version DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&versions).Error
+	return versions, total, err
+}
+
+// ListOverrides gets the overrides of a node by path, so that an override of
+// an object comes before overrides of its keys
+func (r *nodeConfigVersionRepository) ListOverrides(nodeID uint) ([]*models.NodeConfigOverride, error) {
+	var overrides []*models.NodeConfigOverride
+	err := r.db.Where("node_id = ?", nodeID).Order("path ASC").Find(&overrides).Error
+	return overrides, err
+}
+
+// SetOverride creates an override or replaces the value of the node's
+// override with the same path
+func (r *nodeConfigVersionRepository) SetOverride(override *models.NodeConfigOverride) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "node_id"}, {Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "author", "updated_at"}),
+	}).Create(override).Error
+}
+
+// DeleteOverrides deletes the overrides of a node with the given paths, or
+// all of them without paths
+func (r *nodeConfigVersionRepository) DeleteOverrides(nodeID uint, paths []string) (int64, error) {
+	query := r.db.Where("node_id = ?", nodeID)
+	if len(paths) > 0 {
+		query = query.Where("path IN ?", paths)
+	}
+	result := query.Delete(&models.NodeConfigOverride{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"testing"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestNodeConfigOverrides(t *testing.T) {
+	repo := NewNodeConfigVersionRepository(newTestDB(t))
+
+	for _, override := range []*models.NodeConfigOverride{
+		{NodeID: 1, Path: "route.final", Value: `"direct"`},
+		{NodeID: 1, Path: "log.level", Value: `"debug"`, Author: "alice"},
+		{NodeID: 1, Path: "log.level", Value: `"warn"`, Author: "bob"},
+		{NodeID: 2, Path: "log.level", Value: `"error"`},
+	} {
+		if err := repo.SetOverride(override); err != nil {
+			t.Fatalf("set override %s: %v", override.Path, err)
+		}
+	}
+
+	overrides, err := repo.ListOverrides(1)
+	if err != nil {
+		t.Fatalf("list overrides: %v", err)
+	}
+	if len(overrides) != 2 || overrides[0].Path != "log.level" || overrides[1].Path != "route.final" {
+		t.Fatalf("overrides = %+v, want log.level and route.final", overrides)
+	}
+	if overrides[0].Value != `"warn"` || overrides[0].Author != "bob" {
+		t.Errorf("log.level = %s by %s, want the replaced value by bob", overrides[0].Value, overrides[0].Author)
+	}
+
+	if cleared, err := repo.DeleteOverrides(1, []string{"route.final", "dns"}); err != nil || cleared != 1 {
+		t.Errorf("delete route.final = %d, %v, want 1", cleared, err)
+	}
+	if cleared, err := repo.DeleteOverrides(1, nil); err != nil || cleared != 1 {
+		t.Errorf("delete all = %d, %v, want 1", cleared, err)
+	}
+	if overrides, _ := repo.ListOverrides(2); len(overrides) != 1 {
+		t.Errorf("other node has %d overrides, want 1", len(overrides))
+	}
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// NodeCostRepository interface defines node cost data access methods
+type NodeCostRepository interface {
+	// Basic operations
+	Upsert(cost *models.NodeCost) error
+	GetByID(id uint) (*models.NodeCost, error)
+	Delete(id uint) error
+
+	// Query operations
+	List(nodeID uint, startMonth, endMonth time.Time) ([]*models.NodeCost, error)
+	GetNodeUsage(start, end time.Time) (map[uint]*models.NodeUsage, error)
+}
+
+// nodeCostRepository implements NodeCostRepository interface
+type nodeCostRepository struct {
+	db *gorm.DB
+}
+
+// NewNodeCostRepository creates a new node cost repository
+func NewNodeCostRepository(db *gorm.DB) NodeCostRepository {
+	return &nodeCostRepository{db: db}
+}
+
+// Upsert records a node's cost for a month and category, replacing an earlier entry
+func (r *nodeCostRepository) Upsert(cost *models.NodeCost) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "node_id"}, {Name: "month"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"amount", "currency", "notes", "updated_at"}),
+	}).Create(cost).Error
+	if err != nil {
+		return err
+	}
+
+	// The conflict path does not report the ID of the existing row
+	return r.db.Where("node_id = ? AND month = ? AND category = ?", cost.NodeID, cost.Month, cost.Category).
+		First(cost).Error
+}
+
+// GetByID gets node cost by ID
+func (r *nodeCostRepository) GetByID(id uint) (*models.NodeCost, error) {
+	var cost models.NodeCost
+	if err := r.db.First(&cost, id).Error; err != nil {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+// Delete removes a node cost entry
+func (r *nodeCostRepository) Delete(id uint) error {
+	return r.db.Delete(&models.NodeCost{}, id).Error
+}
+
+// List gets the costs of the months in [startMonth, endMonth], of all nodes when nodeID is 0
+func (r *nodeCostRepository) List(nodeID uint, startMonth, endMonth time.Time) ([]*models.NodeCost, error) {
+	var costs []*models.NodeCost
+	query := r.db.Where("month >= ? AND month <= ?", startMonth, endMonth)
+	if nodeID != 0 {
+		query = query.Where("node_id = ?", nodeID)
+	}
+	err := query.Order("node_id ASC, month ASC, category ASC").Find(&costs).Error
+	return costs, err
+}
+
+// GetNodeUsage sums the daily traffic summaries of [start, end) per node and
+// counts the distinct users each node served
+func (r *nodeCostRepository) GetNodeUsage(start, end time.Time) (map[uint]*models.NodeUsage, error) {
+	var rows []models.NodeUsage
+	err := r.db.Model(&models.TrafficSummary{}).
+		Select("node_id, SUM(total_traffic) AS total_traffic, COUNT(DISTINCT user_id) AS active_users").
+		Where("summary_type = ? AND summary_date >= ? AND summary_date < ?", "daily", start, end).
+		Group("node_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[uint]*models.NodeUsage, len(rows))
+	for i := range rows {
+		usage[rows[i].NodeID] = &rows[i]
+	}
+	return usage, nil
+}
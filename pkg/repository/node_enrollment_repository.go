@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// ErrNodeEnrollmentUnavailable is returned when an enrollment token was used,
+// revoked or expired before a node enrolled with it
+var ErrNodeEnrollmentUnavailable = errors.New("node enrollment is no longer available")
+
+// NodeEnrollmentRepository interface defines node enrollment data access methods
+type NodeEnrollmentRepository interface {
+	// Basic CRUD operations
+	Create(enrollment *models.NodeEnrollment) error
+	GetByID(id uint) (*models.NodeEnrollment, error)
+	GetByHash(hash string) (*models.NodeEnrollment, error)
+
+	// List operations
+	List(offset, limit int) ([]*models.NodeEnrollment, int64, error)
+
+	// Business operations
+	Revoke(id uint) error
+	// Enroll marks a pending enrollment used and creates its node and the
+	// node token of the node in one transaction, ErrNodeEnrollmentUnavailable
+	// if the enrollment is not pending anymore
+	Enroll(id uint, node *models.Node, token *models.NodeToken) error
+}
+
+// nodeEnrollmentRepository implements NodeEnrollmentRepository interface
+type nodeEnrollmentRepository struct {
+	db *gorm.DB
+}
+
+// NewNodeEnrollmentRepository creates a new node enrollment repository
+func NewNodeEnrollmentRepository(db *gorm.DB) NodeEnrollmentRepository {
+	return &nodeEnrollmentRepository{db: db}
+}
+
+// Create creates a new node enrollment
+func (r *nodeEnrollmentRepository) Create(enrollment *models.NodeEnrollment) error {
+	return r.db.Create(enrollment).Error
+}
+
+// GetByID gets node enrollment by ID
+func (r *nodeEnrollmentRepository) GetByID(id uint) (*models.NodeEnrollment, error) {
+	var enrollment models.NodeEnrollment
+	err := r.db.First(&enrollment, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// GetByHash gets node enrollment by token hash
+func (r *nodeEnrollmentRepository) GetByHash(hash string) (*models.NodeEnrollment, error) {
+	var enrollment models.NodeEnrollment
+	err := r.db.Where("token_hash = ?", hash).First(&enrollment).Error
+	if err != nil {
+		return nil, err
+	}
+	return &enrollment, nil
+}
+
+// List lists node enrollments, newest first
+func (r *nodeEnrollmentRepository) List(offset, limit int) ([]*models.NodeEnrollment, int64, error) {
+	var enrollments []*models.NodeEnrollment
+	var total int64
+
+	query := r.db.Model(&models.NodeEnrollment{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&enrollments).Error
+	return enrollments, total, err
+}
+
+// Revoke revokes a node enrollment that was not used
+func (r *nodeEnrollmentRepository) Revoke(id uint) error {
+	return r.db.Model(&models.NodeEnrollment{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).
+		Error
+}
+
+// Enroll marks an enrollment used and creates its node and node token
+func (r *nodeEnrollmentRepository) Enroll(id uint, node *models.Node, token *models.NodeToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Claiming the enrollment first makes concurrent uses of a token fail
+		now := time.Now()
+		result := tx.Model(&models.NodeEnrollment{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, now).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNodeEnrollmentUnavailable
+		}
+
+		if err := tx.Create(node).Error; err != nil {
+			return err
+		}
+		token.NodeID = node.ID
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.NodeEnrollment{}).Where("id = ?", id).Update("node_id", node.ID).Error
+	})
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestNodeEnrollmentRepositoryEnroll(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewNodeEnrollmentRepository(db)
+
+	enrollment := &models.NodeEnrollment{TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour), CreatedBy: "alice"}
+	if err := repo.Create(enrollment); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	node := &models.Node{Name: "edge-1", Host: "10.0.0.1", Port: 8080, MaxUsers: 1000, Status: models.NodeStatusOnline}
+	token := &models.NodeToken{TokenHash: "node-token-hash"}
+	if err := repo.Enroll(enrollment.ID, node, token); err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	if node.ID == 0 || token.NodeID != node.ID {
+		t.Errorf("node %d, token node %d, want the token bound to the created node", node.ID, token.NodeID)
+	}
+
+	used, err := repo.GetByHash("hash")
+	if err != nil || used.State(time.Now()) != models.NodeEnrollmentUsed || used.NodeID == nil || *used.NodeID != node.ID {
+		t.Fatalf("GetByHash = %+v, %v, want the enrollment used by node %d", used, err, node.ID)
+	}
+
+	// An enrollment token works once
+	err = repo.Enroll(enrollment.ID, &models.Node{Name: "edge-2"}, &models.NodeToken{TokenHash: "other"})
+	if !errors.Is(err, ErrNodeEnrollmentUnavailable) {
+		t.Errorf("second Enroll = %v, want ErrNodeEnrollmentUnavailable", err)
+	}
+	var nodes int64
+	db.Model(&models.Node{}).Count(&nodes)
+	if nodes != 1 {
+		t.Errorf("%d nodes, want 1", nodes)
+	}
+}
+
+func TestNodeEnrollmentRepositoryRevoke(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewNodeEnrollmentRepository(db)
+
+	enrollment := &models.NodeEnrollment{TokenHash: "hash", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repo.Create(enrollment); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repo.Revoke(enrollment.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	err := repo.Enroll(enrollment.ID, &models.Node{Name: "edge-1"}, &models.NodeToken{TokenHash: "node-token-hash"})
+	if !errors.Is(err, ErrNodeEnrollmentUnavailable) {
+		t.Errorf("Enroll = %v, want ErrNodeEnrollmentUnavailable", err)
+	}
+
+	enrollments, total, err := repo.List(0, 10)
+	if err != nil || total != 1 || enrollments[0].State(time.Now()) != models.NodeEnrollmentRevoked {
+		t.Errorf("List = %+v, %d, %v, want the revoked enrollment", enrollments, total, err)
+	}
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// ErrFailoverStale is returned when a user's assignment changed since the
+// failover was planned
+var ErrFailoverStale = errors.New("user assignment changed")
+
+// NodeFailoverRepository interface defines user failover data access methods
+type NodeFailoverRepository interface {
+	// ListAffectedUsers gets the active users assigned to a node directly
+	ListAffectedUsers(nodeID uint) ([]*models.User, error)
+	// ListCandidates gets the online and degraded nodes users of a node can be
+	// moved to: those sharing a group with it, or its region when it is in
+	// no group. Nodes whose heartbeat is older than since are left out.
+	ListCandidates(node *models.Node, since time.Time) ([]*models.FailoverCandidate, error)
+	// GrantedNodeIDs gets the nodes a plan grants per node or through groups
+	GrantedNodeIDs(planID uint) (map[uint]bool, error)
+	// AssignedNodeIDs gets the nodes a user is assigned to, enabled or not
+	AssignedNodeIDs(userID uint) (map[uint]bool, error)
+
+	// Failover moves a user off failover.FromNodeID to failover.ToNodeID
+	Failover(failover *models.NodeFailover) error
+	// Revert moves a failed over user back, returning ErrFailoverStale when
+	// the failover was reverted already
+	Revert(failover *models.NodeFailover) error
+
+	ListActive(fromNodeID uint) ([]*models.NodeFailover, error)
+	// ListActiveNodeIDs gets the nodes users are failed over from
+	ListActiveNodeIDs() ([]uint, error)
+	// List gets the failovers from or to a node, newest first
+	List(nodeID uint, activeOnly bool, offset, limit int) ([]*models.NodeFailover, int64, error)
+}
+
+// nodeFailoverRepository implements NodeFailoverRepository interface
+type nodeFailoverRepository struct {
+	db *gorm.DB
+}
+
+// NewNodeFailoverRepository creates a new node failover repository
+func NewNodeFailoverRepository(db *gorm.DB) NodeFailoverRepository {
+	return &nodeFailoverRepository{db: db}
+}
+
+// ListAffectedUsers gets the active users assigned to a node directly
+func (r *nodeFailoverRepository) ListAffectedUsers(nodeID uint) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.
+		Joins("JOIN user_nodes ON users.id = user_nodes.user_id AND user_nodes.deleted_at IS NULL").
+		Where("user_nodes.node_id = ? AND user_nodes.is_enabled = ? AND users.status = ?", nodeID, true, models.UserStatusActive).
+		Order("users.id ASC").
+		Find(&users).Error
+	return users, err
+}
+
+// ListCandidates gets the nodes users of a node can be moved to, with the
+// number of users assigned to each
+func (r *nodeFailoverRepository) ListCandidates(node *models.Node, since time.Time) ([]*models.FailoverCandidate, error) {
+	query := r.db.Model(&models.Node{}).
+		Where("id <> ? AND is_enabled = ? AND status IN ? AND last_heartbeat >= ?",
+			node.ID, true, []models.NodeStatus{models.NodeStatusOnline, models.NodeStatusDegraded}, since)
+
+	groupIDs := r.db.Model(&models.NodeGroupMember{}).Select("group_id").Where("node_id = ?", node.ID)
+	var grouped int64
+	if err := r.db.Model(&models.NodeGroupMember{}).Where("node_id = ?", node.ID).Count(&grouped).Error; err != nil {
+		return nil, err
+	}
+	switch {
+	case grouped > 0:
+		query = query.Where("id IN (?)", r.db.Model(&models.NodeGroupMember{}).Select("node_id").Where("group_id IN (?)", groupIDs))
+	case node.Region != "":
+		query = query.Where("region = ?", node.Region)
+	default:
+		return nil, nil
+	}
+
+	var nodes []*models.Node
+	if err := query.Order("sort ASC, id ASC").Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	nodeIDs := make([]uint, len(nodes))
+	for i, candidate := range nodes {
+		nodeIDs[i] = candidate.ID
+	}
+	var counts []struct {
+		NodeID uint
+		Count  int64
+	}
+	err := r.db.Model(&models.UserNode{}).
+		Select("node_id, COUNT(*) AS count").
+		Where("node_id IN ? AND is_enabled = ?", nodeIDs, true).
+		Group("node_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	assigned := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		assigned[count.NodeID] = count.Count
+	}
+
+	candidates := make([]*models.FailoverCandidate, len(nodes))
+	for i, candidate := range nodes {
+		candidates[i] = &models.FailoverCandidate{Node: candidate, Assigned: assigned[candidate.ID]}
+	}
+	return candidates, nil
+}
+
+// GrantedNodeIDs gets the nodes a plan grants per node or through groups
+func (r *nodeFailoverRepository) GrantedNodeIDs(planID uint) (map[uint]bool, error) {
+	var nodeIDs []uint
+	err := r.db.Model(&models.PlanNodeAccess{}).
+		Where("plan_id = ? AND is_enabled = ?", planID, true).
+		Pluck("node_id", &nodeIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var groupNodeIDs []uint
+	err = r.db.Table("node_group_members").
+		Joins("JOIN plan_group_access ON plan_group_access.group_id = node_group_members.group_id").
+		Where("plan_group_access.plan_id = ? AND plan_group_access.is_enabled = ?", planID, true).
+		Pluck("node_group_members.node_id", &groupNodeIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	granted := make(map[uint]bool, len(nodeIDs)+len(groupNodeIDs))
+	for _, id := range append(nodeIDs, groupNodeIDs...) {
+		granted[id] = true
+	}
+	return granted, nil
+}
+
+// AssignedNodeIDs gets the nodes a user is assigned to, enabled or not
+func (r *nodeFailoverRepository) AssignedNodeIDs(userID uint) (map[uint]bool, error) {
+	var nodeIDs []uint
+	if err := r.db.Model(&models.UserNode{}).Where("user_id = ?", userID).Pluck("node_id", &nodeIDs).Error; err != nil {
+		return nil, err
+	}
+	assigned := make(map[uint]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		assigned[id] = true
+	}
+	return assigned, nil
+}
+
+// Failover moves a user off a node. A user whose assignment to the node was
+// itself added by a failover has that failover moved on instead, so that the
+// user returns to the original node when it recovers.
+func (r *nodeFailoverRepository) Failover(failover *models.NodeFailover) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var previous models.NodeFailover
+		err := tx.Where("user_id = ? AND to_node_id = ? AND status = ?",
+			failover.UserID, failover.FromNodeID, models.NodeFailoverStatusActive).
+			First(&previous).Error
+		switch {
+		case err == nil:
+			// The assignment only existed for the previous failover
+			if err := tx.Where("user_id = ? AND node_id = ?", failover.UserID, failover.FromNodeID).
+				Delete(&models.UserNode{}).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			res := tx.Model(&models.UserNode{}).
+				Where("user_id = ? AND node_id = ? AND is_enabled = ?", failover.UserID, failover.FromNodeID, true).
+				Update("is_enabled", false)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrFailoverStale
+			}
+		default:
+			return err
+		}
+
+		assignment := &models.UserNode{UserID: failover.UserID, NodeID: failover.ToNodeID, IsEnabled: true}
+		if err := tx.Create(assignment).Error; err != nil {
+			return err
+		}
+
+		if previous.ID != 0 {
+			failover.ID = previous.ID
+			failover.FromNodeID = previous.FromNodeID
+			failover.CreatedAt = previous.CreatedAt
+			return tx.Model(&previous).Updates(map[string]interface{}{
+				"to_node_id": failover.ToNodeID,
+				"reason":     failover.Reason,
+			}).Error
+		}
+		failover.Status = models.NodeFailoverStatusActive
+		return tx.Create(failover).Error
+	})
+}
+
+// Revert moves a failed over user back to the node it was moved off
+func (r *nodeFailoverRepository) Revert(failover *models.NodeFailover) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&models.NodeFailover{}).
+			Where("id = ? AND status = ?", failover.ID, models.NodeFailoverStatusActive).
+			Updates(map[string]interface{}{
+				"status":      models.NodeFailoverStatusReverted,
+				"reverted_at": now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrFailoverStale
+		}
+
+		if err := tx.Where("user_id = ? AND node_id = ?", failover.UserID, failover.ToNodeID).
+			Delete(&models.UserNode{}).Error; err != nil {
+			return err
+		}
+		// An assignment removed by an admin in the meantime stays removed
+		if err := tx.Model(&models.UserNode{}).
+			Where("user_id = ? AND node_id = ?", failover.UserID, failover.FromNodeID).
+			Update("is_enabled", true).Error; err != nil {
+			return err
+		}
+
+		failover.Status = models.NodeFailoverStatusReverted
+		failover.RevertedAt = &now
+		return nil
+	})
+}
+
+// ListActive gets the active failovers off a node
+func (r *nodeFailoverRepository) ListActive(fromNodeID uint) ([]*models.NodeFailover, error) {
+	var failovers []*models.NodeFailover
+	err := r.db.Preload("User").
+		Where("from_node_id = ? AND status = ?", fromNodeID, models.NodeFailoverStatusActive).
+		Order("id ASC").
+		Find(&failovers).Error
+	return failovers, err
+}
+
+// ListActiveNodeIDs gets the nodes users are failed over from
+func (r *nodeFailoverRepository) ListActiveNodeIDs() ([]uint, error) {
+	var nodeIDs []uint
+	err := r.db.Model(&models.NodeFailover{}).
+		Where("status = ?", models.NodeFailoverStatusActive).
+		Distinct().
+		Order("from_node_id ASC").
+		Pluck("from_node_id", &nodeIDs).Error
+	return nodeIDs, err
+}
+
+// List gets the failovers from or to a node, newest first
+func (r *nodeFailoverRepository) List(nodeID uint, activeOnly bool, offset, limit int) ([]*models.NodeFailover, int64, error) {
+	var failovers []*models.NodeFailover
+	var total int64
+
+	query := r.db.Model(&models.NodeFailover{}).Where("from_node_id = ? OR to_node_id = ?", nodeID, nodeID)
+	if activeOnly {
+		query = query.Where("status = ?", models.NodeFailoverStatusActive)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("User").Preload("ToNode").
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&failovers).Error
+	return failovers, total, err
+}
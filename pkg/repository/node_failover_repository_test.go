@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// enabledNodeIDs gets the nodes a user is assigned to and enabled on
+func enabledNodeIDs(t *testing.T, db *gorm.DB, userID uint) []uint {
+	t.Helper()
+	var nodeIDs []uint
+	if err := db.Model(&models.UserNode{}).Where("user_id = ? AND is_enabled = ?", userID, true).
+		Order("node_id ASC").Pluck("node_id", &nodeIDs).Error; err != nil {
+		t.Fatalf("list user nodes: %v", err)
+	}
+	return nodeIDs
+}
+
+func TestNodeFailoverRepository(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewNodeFailoverRepository(db)
+
+	now := time.Now()
+	stale, fresh := now.Add(-time.Hour), now.Add(-time.Minute)
+	nodes := []*models.Node{
+		{Name: "offline", Type: models.NodeTypeVLESS, Host: "192.0.2.1", Port: 443, Region: "jp", Status: models.NodeStatusOffline, LastHeartbeat: &stale},
+		{Name: "granted", Type: models.NodeTypeVLESS, Host: "192.0.2.2", Port: 443, Region: "jp", Status: models.NodeStatusOnline, LastHeartbeat: &fresh},
+		{Name: "other", Type: models.NodeTypeVLESS, Host: "192.0.2.3", Port: 443, Region: "jp", Status: models.NodeStatusDegraded, LastHeartbeat: &fresh},
+		{Name: "elsewhere", Type: models.NodeTypeVLESS, Host: "192.0.2.4", Port: 443, Region: "us", Status: models.NodeStatusOnline, LastHeartbeat: &fresh},
+	}
+	for _, node := range nodes {
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+	user := &models.User{Username: "failover", Email: "failover@example.com", Password: "x", PlanID: 3, Status: models.UserStatusActive}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := db.Create(&models.UserNode{UserID: user.ID, NodeID: nodes[0].ID, IsEnabled: true}).Error; err != nil {
+		t.Fatalf("assign user: %v", err)
+	}
+	if err := db.Create(&models.PlanNodeAccess{PlanID: 3, NodeID: nodes[1].ID}).Error; err != nil {
+		t.Fatalf("grant node: %v", err)
+	}
+
+	users, err := repo.ListAffectedUsers(nodes[0].ID)
+	if err != nil || len(users) != 1 || users[0].ID != user.ID {
+		t.Fatalf("affected users = %v, %v, want the user", users, err)
+	}
+	candidates, err := repo.ListCandidates(nodes[0], now.Add(-10*time.Minute))
+	if err != nil {
+		t.Fatalf("list candidates: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].Node.ID != nodes[1].ID || candidates[1].Node.ID != nodes[2].ID {
+		t.Fatalf("candidates = %+v, want the other nodes of the region", candidates)
+	}
+	granted, err := repo.GrantedNodeIDs(3)
+	if err != nil || len(granted) != 1 || !granted[nodes[1].ID] {
+		t.Fatalf("granted nodes = %v, %v, want the granted node", granted, err)
+	}
+
+	failover := &models.NodeFailover{UserID: user.ID, FromNodeID: nodes[0].ID, ToNodeID: nodes[1].ID, Reason: "offline"}
+	if err := repo.Failover(failover); err != nil {
+		t.Fatalf("fail over: %v", err)
+	}
+	if got := enabledNodeIDs(t, db, user.ID); len(got) != 1 || got[0] != nodes[1].ID {
+		t.Errorf("user nodes = %v, want the granted node", got)
+	}
+	// The user was moved already
+	again := &models.NodeFailover{UserID: user.ID, FromNodeID: nodes[0].ID, ToNodeID: nodes[2].ID}
+	if err := repo.Failover(again); !errors.Is(err, ErrFailoverStale) {
+		t.Errorf("second failover err = %v, want ErrFailoverStale", err)
+	}
+
+	// Failing over from the failover node keeps the original node
+	chained := &models.NodeFailover{UserID: user.ID, FromNodeID: nodes[1].ID, ToNodeID: nodes[2].ID, Reason: "offline too"}
+	if err := repo.Failover(chained); err != nil {
+		t.Fatalf("chain failover: %v", err)
+	}
+	if chained.ID != failover.ID || chained.FromNodeID != nodes[0].ID {
+		t.Errorf("chained failover %d from %d, want %d from %d", chained.ID, chained.FromNodeID, failover.ID, nodes[0].ID)
+	}
+	if got := enabledNodeIDs(t, db, user.ID); len(got) != 1 || got[0] != nodes[2].ID {
+		t.Errorf("user nodes = %v, want the other node", got)
+	}
+	if nodeIDs, err := repo.ListActiveNodeIDs(); err != nil || len(nodeIDs) != 1 || nodeIDs[0] != nodes[0].ID {
+		t.Errorf("active nodes = %v, %v, want the offline node", nodeIDs, err)
+	}
+
+	active, err := repo.ListActive(nodes[0].ID)
+	if err != nil || len(active) != 1 {
+		t.Fatalf("active failovers = %v, %v, want one", active, err)
+	}
+	if err := repo.Revert(active[0]); err != nil {
+		t.Fatalf("revert: %v", err)
+	}
+	if err := repo.Revert(active[0]); !errors.Is(err, ErrFailoverStale) {
+		t.Errorf("second revert err = %v, want ErrFailoverStale", err)
+	}
+	if got := enabledNodeIDs(t, db, user.ID); len(got) != 1 || got[0] != nodes[0].ID {
+		t.Errorf("user nodes = %v, want the original node", got)
+	}
+
+	listed, total, err := repo.List(nodes[2].ID, false, 0, 10)
+	if err != nil || total != 1 || len(listed) != 1 {
+		t.Fatalf("list failovers = %v, %d, %v, want one", listed, total, err)
+	}
+	if listed[0].IsActive() || listed[0].RevertedAt == nil || listed[0].User.Username != "failover" || listed[0].ToNode.Name != "other" {
+		t.Errorf("failover = %+v, want reverted to other", listed[0])
+	}
+	if _, total, _ := repo.List(nodes[0].ID, true, 0, 10); total != 0 {
+		t.Errorf("%d active failovers after revert, want 0", total)
+	}
+}
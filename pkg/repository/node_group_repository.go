@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// NodeGroupRepository interface defines node group data access methods
+type NodeGroupRepository interface {
+	// Basic CRUD operations
+	Create(group *models.NodeGroup) error
+	GetByID(id uint) (*models.NodeGroup, error)
+	GetByName(name string) (*models.NodeGroup, error)
+	Update(group *models.NodeGroup) error
+	Delete(id uint) error
+
+	// List operations
+	List(offset, limit int) ([]*models.NodeGroup, int64, error)
+	ListByNode(nodeID uint) ([]*models.NodeGroup, error)
+	ListByPlan(planID uint) ([]*models.NodeGroup, error)
+
+	// Membership
+	AddNodes(groupID uint, nodeIDs []uint) error
+	RemoveNodes(groupID uint, nodeIDs []uint) error
+	GetNodeIDs(groupID uint) ([]uint, error)
+	ListMembers() ([]*models.NodeGroupMember, error)
+
+	// Plan access
+	AssignToPlan(access *models.PlanGroupAccess) error
+	UnassignFromPlan(planID, groupID uint) error
+	GetPlanIDs(groupID uint) ([]uint, error)
+}
+
+// nodeGroupRepository implements NodeGroupRepository interface
+type nodeGroupRepository struct {
+	db *gorm.DB
+}
+
+// NewNodeGroupRepository creates a new node group repository
+func NewNodeGroupRepository(db *gorm.DB) NodeGroupRepository {
+	return &nodeGroupRepository{db: db}
+}
+
+// Create creates a new node group
+func (r *nodeGroupRepository) Create(group *models.NodeGroup) error {
+	return r.db.Create(group).Error
+}
+
+// GetByID gets node group by ID
+func (r *nodeGroupRepository) GetByID(id uint) (*models.NodeGroup, error) {
+	var group models.NodeGroup
+	if err := r.db.First(&group, id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// GetByName gets node group by name
+func (r *nodeGroupRepository) GetByName(name string) (*models.NodeGroup, error) {
+	var group models.NodeGroup
+	if err := r.db.Where("name = ?", name).First(&group).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// Update updates node group
+func (r *nodeGroupRepository) Update(group *models.NodeGroup) error {
+	return r.db.Omit("Members").Save(group).Error
+}
+
+// Delete soft deletes a node group together with its memberships and plan grants
+func (r *nodeGroupRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&models.NodeGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("group_id = ?", id).Delete(&models.PlanGroupAccess{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.NodeGroup{}, id).Error
+	})
+}
+
+// List lists node groups with pagination
+func (r *nodeGroupRepository) List(offset, limit int) ([]*models.NodeGroup, int64, error) {
+	var groups []*models.NodeGroup
+	var total int64
+
+	if err := r.db.Model(&models.NodeGroup{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Order("sort ASC, id ASC").Offset(offset).Limit(limit).Find(&groups).Error
+	return groups, total, err
+}
+
+// ListByNode gets the groups a node belongs to
+func (r *nodeGroupRepository) ListByNode(nodeID uint) ([]*models.NodeGroup, error) {
+	var groups []*models.NodeGroup
+	err := r.db.Joins("JOIN node_group_members ON node_group_members.group_id = node_groups.id").
+		Where("node_group_members.node_id = ?", nodeID).
+		Order("node_groups.sort ASC, node_groups.id ASC").
+		Find(&groups).Error
+	return groups, err
+}
+
+// ListByPlan gets the groups granted to a plan
+func (r *nodeGroupRepository) ListByPlan(planID uint) ([]*models.NodeGroup, error) {
+	var groups []*models.NodeGroup
+	err := r.db.Joins("JOIN plan_group_access ON plan_group_access.group_id = node_groups.id").
+		Where("plan_group_access.plan_id = ?", planID).
+		Order("plan_group_access.priority ASC, node_groups.sort ASC").
+		Find(&groups).Error
+	return groups, err
+}
+
+// AddNodes adds nodes to a group, skipping nodes that are already members
+func (r *nodeGroupRepository) AddNodes(groupID uint, nodeIDs []uint) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+
+	members := make([]models.NodeGroupMember, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		members[i] = models.NodeGroupMember{GroupID: groupID, NodeID: nodeID}
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error
+}
+
+// RemoveNodes removes nodes from a group
+func (r *nodeGroupRepository) RemoveNodes(groupID uint, nodeIDs []uint) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	return r.db.Where("group_id = ? AND node_id IN ?", groupID, nodeIDs).
+		Delete(&models.NodeGroupMember{}).Error
+}
+
+// GetNodeIDs gets the IDs of a group's member nodes
+func (r *nodeGroupRepository) GetNodeIDs(groupID uint) ([]uint, error) {
+	var nodeIDs []uint
+	err := r.db.Model(&models.NodeGroupMember{}).
+		Where("group_id = ?", groupID).
+		Order("node_id ASC").
+		Pluck("node_id", &nodeIDs).Error
+	return nodeIDs, err
+}
+
+// ListMembers gets the members of every group
+func (r *nodeGroupRepository) ListMembers() ([]*models.NodeGroupMember, error) {
+	var members []*models.NodeGroupMember
+	err := r.db.Order("group_id ASC, node_id ASC").Find(&members).Error
+	return members, err
+}
+
+// AssignToPlan grants a plan access to a group, updating an existing grant
+func (r *nodeGroupRepository) AssignToPlan(access *models.PlanGroupAccess) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "plan_id"}, {Name: "group_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"is_enabled", "priority", "updated_at"}),
+	}).Create(access).Error
+}
+
+// UnassignFromPlan revokes a plan's access to a group
+func (r *nodeGroupRepository) UnassignFromPlan(planID, groupID uint) error {
+	return r.db.Where("plan_id = ? AND group_id = ?", planID, groupID).
+		Delete(&models.PlanGroupAccess{}).Error
+}
+
+// GetPlanIDs gets the IDs of the plans a group is granted to
+func (r *nodeGroupRepository) GetPlanIDs(groupID uint) ([]uint, error) {
+	var planIDs []uint
+	err := r.db.Model(&models.PlanGroupAccess{}).
+		Where("group_id = ?", groupID).
+		Order("plan_id ASC").
+		Pluck("plan_id", &planIDs).Error
+	return planIDs, err
+}
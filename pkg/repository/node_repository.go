@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,6 +10,23 @@ import (
 	"sing-box-web/pkg/models"
 )
 
+// NodeSortFields are the columns node listings can be sorted by
+var NodeSortFields = []string{"id", "name", "status", "region", "created_at", "current_users", "active_connections", "cpu_usage"}
+
+// NodeListFilter narrows and orders a node listing, empty fields match every
+// node
+type NodeListFilter struct {
+	Status models.NodeStatus
+	Region string
+	// Keyword matches the name or host
+	Keyword string
+	// OnlineOnly keeps the online and degraded nodes
+	OnlineOnly bool
+	// SortBy is one of NodeSortFields, the configured node order when empty
+	SortBy string
+	Desc   bool
+}
+
 // NodeRepository interface defines node data access methods
 type NodeRepository interface {
 	// Basic CRUD operations
@@ -25,6 +44,7 @@ type NodeRepository interface {
 	ListEnabled(offset, limit int) ([]*models.Node, int64, error)
 	ListAvailable(offset, limit int) ([]*models.Node, int64, error)
 	Search(query string, offset, limit int) ([]*models.Node, int64, error)
+	ListFiltered(filter NodeListFilter, offset, limit int) ([]*models.Node, int64, error)
 	
 	// Business operations
 	UpdateHeartbeat(nodeID uint) error
@@ -34,6 +54,16 @@ type NodeRepository interface {
 	UpdateUserCount(nodeID uint, count int) error
 	IncrementUserCount(nodeID uint) error
 	DecrementUserCount(nodeID uint) error
+	// SetWitnessFlags stores the witness findings of a node, flaggedAt being
+	// nil with no findings
+	SetWitnessFlags(nodeID uint, flags string, flaggedAt *time.Time) error
+	// SetHealthScore stores the score of a health check that kept the status
+	SetHealthScore(nodeID uint, score float64) error
+	// TransitionStatus moves a node from transition.From to transition.To
+	// and records the transition, ErrNodeStatusChanged if the node is no
+	// longer in transition.From
+	TransitionStatus(transition *models.NodeStatusTransition) error
+	ListStatusTransitions(nodeID uint, offset, limit int) ([]*models.NodeStatusTransition, int64, error)
 	
 	// Statistics
 	GetNodeCount() (int64, error)
@@ -59,6 +89,36 @@ type NodeRepository interface {
 	BatchEnable(nodeIDs []uint) error
 	BatchDisable(nodeIDs []uint) error
 	BatchDelete(nodeIDs []uint) error
+	
+	// Name conflict handling, soft-deleted nodes included
+	GetByIDUnscoped(id uint) (*models.Node, error)
+	CheckNameAvailable(name string, excludeID uint) error
+	NextAvailableName(name string) (string, error)
+	MergeHistory(fromID, toID uint) (*models.NodeMergeResult, error)
+}
+
+// maxNameSuffix bounds the search for a free node name suffix
+const maxNameSuffix = 1000
+
+// ErrNodeAlreadyMerged is returned when a node's history was already merged into another node
+var ErrNodeAlreadyMerged = errors.New("node history already merged")
+
+// ErrNodeStatusChanged is returned when a node's status changed before a transition was applied
+var ErrNodeStatusChanged = errors.New("node status changed concurrently")
+
+// NodeNameConflictError is returned when a node name is already taken,
+// either by an active node or by a soft-deleted one that still holds the name
+type NodeNameConflictError struct {
+	Name    string
+	NodeID  uint
+	Deleted bool
+}
+
+func (e *NodeNameConflictError) Error() string {
+	if e.Deleted {
+		return fmt.Sprintf("node name %q is held by deleted node %d", e.Name, e.NodeID)
+	}
+	return fmt.Sprintf("node name %q is already used by node %d", e.Name, e.NodeID)
 }
 
 // nodeRepository implements NodeRepository interface
@@ -125,6 +185,51 @@ func (r *nodeRepository) List(offset, limit int) ([]*models.Node, int64, error)
 	return nodes, total, err
 }
 
+// ListFiltered gets a page of the nodes matching the filter
+func (r *nodeRepository) ListFiltered(filter NodeListFilter, offset, limit int) ([]*models.Node, int64, error) {
+	var nodes []*models.Node
+	var total int64
+
+	query := r.db.Model(&models.Node{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Region != "" {
+		query = query.Where("region = ?", filter.Region)
+	}
+	if filter.Keyword != "" {
+		keyword := "%" + filter.Keyword + "%"
+		query = query.Where("name LIKE ? OR host LIKE ?", keyword, keyword)
+	}
+	if filter.OnlineOnly {
+		query = query.Where("status IN ?", []models.NodeStatus{models.NodeStatusOnline, models.NodeStatusDegraded})
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "sort ASC, created_at DESC"
+	if filter.SortBy != "" {
+		order = sortOrder(filter.SortBy, filter.Desc)
+	}
+	err := query.Offset(offset).
+		Limit(limit).
+		Order(order).
+		Find(&nodes).Error
+
+	return nodes, total, err
+}
+
+// sortOrder orders by a column, then by ID to keep pages stable. The column
+// must come from a list of sort fields.
+func sortOrder(column string, desc bool) string {
+	if desc {
+		return column + " DESC, id DESC"
+	}
+	return column + " ASC, id ASC"
+}
+
 // ListByStatus gets nodes by status with pagination
 func (r *nodeRepository) ListByStatus(status models.NodeStatus, offset, limit int) ([]*models.Node, int64, error) {
 	var nodes []*models.Node
@@ -209,14 +314,14 @@ func (r *nodeRepository) ListEnabled(offset, limit int) ([]*models.Node, int64,
 	return nodes, total, err
 }
 
-// ListAvailable gets available nodes (enabled and online) with pagination
+// ListAvailable gets available nodes (enabled, online or degraded) with pagination
 func (r *nodeRepository) ListAvailable(offset, limit int) ([]*models.Node, int64, error) {
 	var nodes []*models.Node
 	var total int64
 	
 	query := r.db.Model(&models.Node{}).Where(
-		"is_enabled = ? AND status = ?",
-		true, models.NodeStatusOnline,
+		"is_enabled = ? AND status IN ?",
+		true, []models.NodeStatus{models.NodeStatusOnline, models.NodeStatusDegraded},
 	)
 	
 	// Get total count
@@ -233,15 +338,15 @@ func (r *nodeRepository) ListAvailable(offset, limit int) ([]*models.Node, int64
 	return nodes, total, err
 }
 
-// Search searches nodes by name, description, or region
+// Search searches nodes by name, description, host, tags, or location
 func (r *nodeRepository) Search(query string, offset, limit int) ([]*models.Node, int64, error) {
 	var nodes []*models.Node
 	var total int64
 	
 	searchQuery := "%" + query + "%"
 	dbQuery := r.db.Model(&models.Node{}).Where(
-		"name LIKE ? OR description LIKE ? OR region LIKE ? OR country LIKE ? OR city LIKE ?",
-		searchQuery, searchQuery, searchQuery, searchQuery, searchQuery,
+		"name LIKE ? OR description LIKE ? OR host LIKE ? OR tags LIKE ? OR region LIKE ? OR country LIKE ? OR city LIKE ?",
+		searchQuery, searchQuery, searchQuery, searchQuery, searchQuery, searchQuery, searchQuery,
 	)
 	
 	// Get total count
@@ -310,6 +415,62 @@ func (r *nodeRepository) UpdateUserCount(nodeID uint, count int) error {
 		Error
 }
 
+// SetWitnessFlags stores the witness findings of a node
+func (r *nodeRepository) SetWitnessFlags(nodeID uint, flags string, flaggedAt *time.Time) error {
+	return r.db.Model(&models.Node{}).
+		Where("id = ?", nodeID).
+		Updates(map[string]interface{}{
+			"witness_flags":      flags,
+			"witness_flagged_at": flaggedAt,
+		}).
+		Error
+}
+
+// SetHealthScore stores the health score of a node
+func (r *nodeRepository) SetHealthScore(nodeID uint, score float64) error {
+	return r.db.Model(&models.Node{}).
+		Where("id = ?", nodeID).
+		Update("health_score", score).
+		Error
+}
+
+// TransitionStatus changes the status of a node and records the transition
+func (r *nodeRepository) TransitionStatus(transition *models.NodeStatusTransition) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Node{}).
+			Where("id = ? AND status = ?", transition.NodeID, transition.From).
+			Updates(map[string]interface{}{
+				"status":           transition.To,
+				"status_reason":    transition.Reason,
+				"status_automatic": transition.Automatic,
+				"health_score":     transition.HealthScore,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNodeStatusChanged
+		}
+		return tx.Create(transition).Error
+	})
+}
+
+// ListStatusTransitions lists the status transitions of a node, newest first
+func (r *nodeRepository) ListStatusTransitions(nodeID uint, offset, limit int) ([]*models.NodeStatusTransition, int64, error) {
+	var transitions []*models.NodeStatusTransition
+	var total int64
+
+	query := r.db.Model(&models.NodeStatusTransition{}).Where("node_id = ?", nodeID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&transitions).Error
+	return transitions, total, err
+}
+
 // IncrementUserCount increments node user count
 func (r *nodeRepository) IncrementUserCount(nodeID uint) error {
 	return r.db.Model(&models.Node{}).
@@ -337,7 +498,7 @@ func (r *nodeRepository) GetNodeCount() (int64, error) {
 func (r *nodeRepository) GetOnlineNodeCount() (int64, error) {
 	var count int64
 	err := r.db.Model(&models.Node{}).
-		Where("status = ?", models.NodeStatusOnline).
+		Where("status IN ?", []models.NodeStatus{models.NodeStatusOnline, models.NodeStatusDegraded}).
 		Count(&count).Error
 	return count, err
 }
@@ -416,7 +577,9 @@ func (r *nodeRepository) GetOfflineNodes(threshold time.Duration) ([]*models.Nod
 	return nodes, err
 }
 
-// GetUserNodes gets nodes accessible by a user
+// GetUserNodes gets nodes accessible by a user. Nodes assigned to the user
+// directly come first, followed by nodes the user's plan grants either per
+// node or through a node group.
 func (r *nodeRepository) GetUserNodes(userID uint) ([]*models.Node, error) {
 	var nodes []*models.Node
 	err := r.db.Table("nodes").
@@ -424,7 +587,38 @@ func (r *nodeRepository) GetUserNodes(userID uint) ([]*models.Node, error) {
 		Where("user_nodes.user_id = ? AND user_nodes.is_enabled = ?", userID, true).
 		Order("user_nodes.priority ASC, nodes.sort ASC").
 		Find(&nodes).Error
-	return nodes, err
+	if err != nil {
+		return nil, err
+	}
+
+	planNodeIDs := r.db.Table("plan_node_access").
+		Select("plan_node_access.node_id").
+		Joins("JOIN users ON users.plan_id = plan_node_access.plan_id").
+		Where("users.id = ? AND plan_node_access.is_enabled = ? AND plan_node_access.deleted_at IS NULL", userID, true)
+	groupNodeIDs := r.db.Table("node_group_members").
+		Select("node_group_members.node_id").
+		Joins("JOIN plan_group_access ON plan_group_access.group_id = node_group_members.group_id").
+		Joins("JOIN users ON users.plan_id = plan_group_access.plan_id").
+		Where("users.id = ? AND plan_group_access.is_enabled = ?", userID, true)
+
+	var planNodes []*models.Node
+	err = r.db.Where("id IN (?) OR id IN (?)", planNodeIDs, groupNodeIDs).
+		Order("sort ASC, id ASC").
+		Find(&planNodes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool, len(nodes))
+	for _, node := range nodes {
+		seen[node.ID] = true
+	}
+	for _, node := range planNodes {
+		if !seen[node.ID] {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
 }
 
 // GetNodeUsers gets users who have access to a node
@@ -507,23 +701,164 @@ func (r *nodeRepository) BatchDelete(nodeIDs []uint) error {
 	return r.db.Delete(&models.Node{}, nodeIDs).Error
 }
 
-// GetNodeStats gets node statistics
+// GetNodeStats gets node statistics in one query, the usage and connections
+// of the online nodes
 func (r *nodeRepository) GetNodeStats() (*models.NodeStats, error) {
+	online := []models.NodeStatus{models.NodeStatusOnline, models.NodeStatusDegraded}
+
 	var stats models.NodeStats
-	
-	// Get total nodes
-	err := r.db.Model(&models.Node{}).Count(&stats.TotalNodes).Error
+	err := r.db.Model(&models.Node{}).
+		Select(`COUNT(*) AS total_nodes,
+			COALESCE(SUM(CASE WHEN status IN ? THEN 1 ELSE 0 END), 0) AS online_nodes,
+			COALESCE(AVG(CASE WHEN status IN ? THEN cpu_usage END), 0) AS avg_cpu_usage,
+			COALESCE(AVG(CASE WHEN status IN ? THEN memory_usage END), 0) AS avg_memory_usage,
+			COALESCE(SUM(CASE WHEN status IN ? THEN active_connections ELSE 0 END), 0) AS total_connections`,
+			online, online, online, online).
+		Scan(&stats).Error
 	if err != nil {
 		return nil, err
 	}
-	
-	// Get online nodes
-	err = r.db.Model(&models.Node{}).
-		Where("status = ?", models.NodeStatusOnline).
-		Count(&stats.OnlineNodes).Error
+	return &stats, nil
+}
+// GetByIDUnscoped gets node by ID including soft-deleted nodes
+func (r *nodeRepository) GetByIDUnscoped(id uint) (*models.Node, error) {
+	var node models.Node
+	err := r.db.Unscoped().First(&node, id).Error
 	if err != nil {
 		return nil, err
 	}
-	
-	return &stats, nil
-}
\ No newline at end of file
+	return &node, nil
+}
+
+// FindNameConflict returns a NodeNameConflictError if another node, active or
+// soft-deleted, already uses the name. Active nodes are reported first.
+func (r *nodeRepository) CheckNameAvailable(name string, excludeID uint) error {
+	var node models.Node
+	err := r.db.Unscoped().
+		Where("name = ? AND id <> ?", name, excludeID).
+		Order("deleted_at IS NOT NULL").
+		Order("id DESC").
+		First(&node).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &NodeNameConflictError{
+		Name:    name,
+		NodeID:  node.ID,
+		Deleted: node.DeletedAt.Valid,
+	}
+}
+
+// NextAvailableName returns name, or name with the lowest free "-N" suffix
+func (r *nodeRepository) NextAvailableName(name string) (string, error) {
+	candidate := name
+	for i := 2; i <= maxNameSuffix; i++ {
+		var count int64
+		err := r.db.Unscoped().Model(&models.Node{}).
+			Where("name = ?", candidate).
+			Count(&count).Error
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+	return "", fmt.Errorf("no free name for node %q", name)
+}
+
+// MergeHistory moves the history of a deleted node to its successor.
+// Traffic, logs and probes are reassigned, traffic counters are added up and
+// user/plan access is carried over where the successor does not have it yet.
+func (r *nodeRepository) MergeHistory(fromID, toID uint) (*models.NodeMergeResult, error) {
+	result := &models.NodeMergeResult{}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var from models.Node
+		if err := tx.Unscoped().First(&from, fromID).Error; err != nil {
+			return err
+		}
+		if from.MergedIntoID != nil {
+			return ErrNodeAlreadyMerged
+		}
+		var to models.Node
+		if err := tx.First(&to, toID).Error; err != nil {
+			return err
+		}
+
+		reassign := func(model interface{}, count *int64) error {
+			res := tx.Unscoped().Model(model).
+				Where("node_id = ?", fromID).
+				Update("node_id", toID)
+			*count = res.RowsAffected
+			return res.Error
+		}
+		if err := reassign(&models.TrafficRecord{}, &result.TrafficRecords); err != nil {
+			return err
+		}
+		if err := reassign(&models.TrafficSummary{}, &result.TrafficSummaries); err != nil {
+			return err
+		}
+		if err := reassign(&models.NodeLog{}, &result.NodeLogs); err != nil {
+			return err
+		}
+		if err := reassign(&models.NodeProbe{}, &result.NodeProbes); err != nil {
+			return err
+		}
+
+		res := tx.Model(&models.UserNode{}).
+			Where("node_id = ?", fromID).
+			Where("user_id NOT IN (?)", tx.Model(&models.UserNode{}).Select("user_id").Where("node_id = ?", toID)).
+			Update("node_id", toID)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.UserNodes = res.RowsAffected
+
+		res = tx.Model(&models.PlanNodeAccess{}).
+			Where("node_id = ?", fromID).
+			Where("plan_id NOT IN (?)", tx.Model(&models.PlanNodeAccess{}).Select("plan_id").Where("node_id = ?", toID)).
+			Update("node_id", toID)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.PlanNodeAccesses = res.RowsAffected
+
+		res = tx.Model(&models.NodeGroupMember{}).
+			Where("node_id = ?", fromID).
+			Where("group_id NOT IN (?)", tx.Model(&models.NodeGroupMember{}).Select("group_id").Where("node_id = ?", toID)).
+			Update("node_id", toID)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.NodeGroupMembers = res.RowsAffected
+
+		err := tx.Model(&models.Node{}).
+			Where("id = ?", toID).
+			Updates(map[string]interface{}{
+				"upload_traffic":   gorm.Expr("upload_traffic + ?", from.UploadTraffic),
+				"download_traffic": gorm.Expr("download_traffic + ?", from.DownloadTraffic),
+				"total_traffic":    gorm.Expr("total_traffic + ?", from.TotalTraffic),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Model(&models.Node{}).
+			Where("id = ?", fromID).
+			Updates(map[string]interface{}{
+				"merged_into_id":   toID,
+				"upload_traffic":   0,
+				"download_traffic": 0,
+				"total_traffic":    0,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestNodeGetNodeStats(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewNodeRepository(db)
+
+	nodes := []*models.Node{
+		{Name: "online", Status: models.NodeStatusOnline, CPUUsage: 20, MemoryUsage: 40, ActiveConnections: 12},
+		{Name: "degraded", Status: models.NodeStatusDegraded, CPUUsage: 60, MemoryUsage: 80, ActiveConnections: 3},
+		// Offline nodes keep their last report, which is not counted
+		{Name: "offline", Status: models.NodeStatusOffline, CPUUsage: 90, MemoryUsage: 90, ActiveConnections: 50},
+	}
+	for i, node := range nodes {
+		node.Type = models.NodeTypeVLESS
+		node.Host = "192.0.2." + string(rune('1'+i))
+		node.Port = 443
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+
+	stats, err := repo.GetNodeStats()
+	if err != nil {
+		t.Fatalf("GetNodeStats() = %v", err)
+	}
+	if stats.TotalNodes != 3 || stats.OnlineNodes != 2 || stats.TotalConnections != 15 {
+		t.Errorf("stats = %+v, want 3 nodes, 2 online, 15 connections", stats)
+	}
+	if stats.AvgCPUUsage != 40 || stats.AvgMemoryUsage != 60 {
+		t.Errorf("averages = %v cpu, %v memory, want 40 and 60", stats.AvgCPUUsage, stats.AvgMemoryUsage)
+	}
+
+	// No nodes at all
+	empty, err := NewNodeRepository(newTestDB(t)).GetNodeStats()
+	if err != nil || empty.TotalNodes != 0 || empty.AvgCPUUsage != 0 {
+		t.Errorf("empty stats = %+v, %v", empty, err)
+	}
+}
+
+func TestNodeListFiltered(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewNodeRepository(db)
+
+	nodes := []*models.Node{
+		{Name: "tokyo-1", Host: "tyo1.example.com", Region: "jp", Status: models.NodeStatusOnline, CurrentUsers: 5},
+		{Name: "tokyo-2", Host: "tyo2.example.com", Region: "jp", Status: models.NodeStatusOffline, CurrentUsers: 0},
+		{Name: "osaka-1", Host: "tokyo-backup.example.com", Region: "jp", Status: models.NodeStatusDegraded, CurrentUsers: 9},
+		{Name: "berlin-1", Host: "ber1.example.com", Region: "de", Status: models.NodeStatusOnline, CurrentUsers: 2},
+	}
+	for _, node := range nodes {
+		node.Type = models.NodeTypeVLESS
+		node.Port = 443
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter NodeListFilter
+		want   []string
+	}{
+		{"region", NodeListFilter{Region: "de"}, []string{"berlin-1"}},
+		{"keyword matches name or host", NodeListFilter{Keyword: "tokyo", SortBy: "id"}, []string{"tokyo-1", "tokyo-2", "osaka-1"}},
+		// The keyword alternatives must not escape the status condition
+		{"status and keyword", NodeListFilter{Status: models.NodeStatusOffline, Keyword: "tokyo"}, []string{"tokyo-2"}},
+		{"online only", NodeListFilter{Region: "jp", OnlineOnly: true, SortBy: "name"}, []string{"osaka-1", "tokyo-1"}},
+		{"sorted descending", NodeListFilter{SortBy: "current_users", Desc: true}, []string{"osaka-1", "tokyo-1", "berlin-1", "tokyo-2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := repo.ListFiltered(tt.filter, 0, 10)
+			if err != nil {
+				t.Fatalf("list nodes: %v", err)
+			}
+			names := make([]string, len(got))
+			for i, node := range got {
+				names[i] = node.Name
+			}
+			if !slices.Equal(names, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("nodes = %v (total %d), want %v", names, total, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// NodeTokenRepository interface defines node registration token data access methods
+type NodeTokenRepository interface {
+	// Basic CRUD operations
+	Create(token *models.NodeToken) error
+	GetByID(id uint) (*models.NodeToken, error)
+	GetByHash(hash string) (*models.NodeToken, error)
+
+	// List operations
+	ListByNode(nodeID uint) ([]*models.NodeToken, error)
+
+	// Business operations
+	Revoke(id uint) error
+	RevokeByNode(nodeID uint) error
+	UpdateLastUsed(id uint) error
+}
+
+// nodeTokenRepository implements NodeTokenRepository interface
+type nodeTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewNodeTokenRepository creates a new node token repository
+func NewNodeTokenRepository(db *gorm.DB) NodeTokenRepository {
+	return &nodeTokenRepository{db: db}
+}
+
+// Create creates a new node token
+func (r *nodeTokenRepository) Create(token *models.NodeToken) error {
+	return r.db.Create(token).Error
+}
+
+// GetByID gets node token by ID
+func (r *nodeTokenRepository) GetByID(id uint) (*models.NodeToken, error) {
+	var token models.NodeToken
+	err := r.db.First(&token, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// GetByHash gets node token by token hash
+func (r *nodeTokenRepository) GetByHash(hash string) (*models.NodeToken, error) {
+	var token models.NodeToken
+	err := r.db.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListByNode gets all tokens issued for a node
+func (r *nodeTokenRepository) ListByNode(nodeID uint) ([]*models.NodeToken, error) {
+	var tokens []*models.NodeToken
+	err := r.db.Where("node_id = ?", nodeID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// Revoke revokes a node token
+func (r *nodeTokenRepository) Revoke(id uint) error {
+	return r.db.Model(&models.NodeToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).
+		Error
+}
+
+// RevokeByNode revokes all tokens of a node
+func (r *nodeTokenRepository) RevokeByNode(nodeID uint) error {
+	return r.db.Model(&models.NodeToken{}).
+		Where("node_id = ? AND revoked_at IS NULL", nodeID).
+		Update("revoked_at", time.Now()).
+		Error
+}
+
+// UpdateLastUsed updates the last used time of a token
+func (r *nodeTokenRepository) UpdateLastUsed(id uint) error {
+	return r.db.Model(&models.NodeToken{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", time.Now()).
+		Error
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// NotificationRepository interface defines in-app notification data access methods
+type NotificationRepository interface {
+	// Create stores a notification unless the user already has one with the
+	// same dedup key, and reports whether it was stored
+	Create(notification *models.Notification) (bool, error)
+	List(userID uint, unreadOnly bool, offset, limit int) ([]*models.Notification, int64, error)
+	CountUnread(userID uint) (int64, error)
+	// MarkRead marks the user's notifications as read, all unread ones when
+	// ids is empty, and returns how many were unread
+	MarkRead(userID uint, ids []uint) (int64, error)
+
+	// Maintenance
+	CleanupOldNotifications(retentionDays int) error
+	CountOldNotifications(retentionDays int) (int64, error)
+}
+
+// notificationRepository implements NotificationRepository interface
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// Create stores a notification unless it is a duplicate
+func (r *notificationRepository) Create(notification *models.Notification) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(notification)
+	return result.RowsAffected > 0, result.Error
+}
+
+// List gets a user's notifications with pagination, newest first
+func (r *notificationRepository) List(userID uint, unreadOnly bool, offset, limit int) ([]*models.Notification, int64, error) {
+	var notifications []*models.Notification
+	var total int64
+
+	query := r.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&notifications).Error
+	return notifications, total, err
+}
+
+// CountUnread counts a user's unread notifications
+func (r *notificationRepository) CountUnread(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead marks the user's notifications as read
+func (r *notificationRepository) MarkRead(userID uint, ids []uint) (int64, error) {
+	query := r.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	result := query.UpdateColumn("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// CleanupOldNotifications removes old notifications, read or not
+func (r *notificationRepository) CleanupOldNotifications(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.Where("created_at < ?", cutoff).Delete(&models.Notification{}).Error
+}
+
+// CountOldNotifications counts the notifications CleanupOldNotifications would remove
+func (r *notificationRepository) CountOldNotifications(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.Notification{}).Where("created_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
+// AlertDeliveryRepository interface defines alert delivery record data access methods
+type AlertDeliveryRepository interface {
+	// Record stores the delivery of the alert with key to the user through
+	// channel and reports whether it was not recorded before
+	Record(channel string, userID uint, key string) (bool, error)
+	// Forget removes a delivery record, e.g. after the delivery failed
+	Forget(channel string, userID uint, key string) error
+
+	// Maintenance
+	CleanupOldDeliveries(retentionDays int) error
+	CountOldDeliveries(retentionDays int) (int64, error)
+}
+
+// alertDeliveryRepository implements AlertDeliveryRepository interface
+type alertDeliveryRepository struct {
+	db *gorm.DB
+}
+
+// NewAlertDeliveryRepository creates a new alert delivery repository
+func NewAlertDeliveryRepository(db *gorm.DB) AlertDeliveryRepository {
+	return &alertDeliveryRepository{db: db}
+}
+
+// Record stores a delivery unless it is already recorded
+func (r *alertDeliveryRepository) Record(channel string, userID uint, key string) (bool, error) {
+	delivery := &models.AlertDelivery{Channel: channel, UserID: userID, Key: key}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Forget removes a delivery record
+func (r *alertDeliveryRepository) Forget(channel string, userID uint, key string) error {
+	// Struct conditions let gorm quote the key column, a reserved word in MySQL
+	return r.db.Where(&models.AlertDelivery{Channel: channel, UserID: userID, Key: key}).
+		Delete(&models.AlertDelivery{}).Error
+}
+
+// CleanupOldDeliveries removes old delivery records
+func (r *alertDeliveryRepository) CleanupOldDeliveries(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.Where("created_at < ?", cutoff).Delete(&models.AlertDelivery{}).Error
+}
+
+// CountOldDeliveries counts the records CleanupOldDeliveries would remove
+func (r *alertDeliveryRepository) CountOldDeliveries(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.AlertDelivery{}).Where("created_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,362 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+var (
+	// ErrOrderNotPending is returned when paying or cancelling an order that is no longer pending
+	ErrOrderNotPending = errors.New("order is not pending")
+	// ErrOrderNotPaid is returned when refunding an order that was not paid
+	ErrOrderNotPaid = errors.New("order is not paid")
+)
+
+// OrderRepository interface defines order and payment data access methods
+type OrderRepository interface {
+	// Basic operations
+	Create(order *models.Order) error
+	GetByID(id uint) (*models.Order, error)
+	GetByOrderNo(orderNo string) (*models.Order, error)
+	List(filter OrderFilter, offset, limit int) ([]*models.Order, int64, error)
+
+	// Lifecycle operations
+	ConfirmPayment(orderID uint, payment *models.Payment) (*models.Order, error)
+	Cancel(orderID uint, reason, operator string) (*models.Order, error)
+	Refund(orderID uint, reason, operator string, revokeAccess, toBalance bool) (*models.Order, error)
+}
+
+// OrderFilter narrows an order listing, zero values match everything
+type OrderFilter struct {
+	UserID uint
+	Status models.OrderStatus
+}
+
+// orderRepository implements OrderRepository interface
+type orderRepository struct {
+	db *gorm.DB
+}
+
+// NewOrderRepository creates a new order repository
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &orderRepository{db: db}
+}
+
+// Create creates a new order. An order with a coupon takes one of the
+// coupon's uses and records the redemption in the same transaction, failing
+// with models.ErrCouponExhausted or models.ErrCouponUserLimit when none is left.
+func (r *orderRepository) Create(order *models.Order) error {
+	if order.CouponID == nil {
+		return r.db.Create(order).Error
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// The usage condition makes concurrent checkouts respect the limit
+		result := tx.Model(&models.Coupon{}).
+			Where("id = ? AND (max_uses = 0 OR used_count < max_uses)", *order.CouponID).
+			UpdateColumn("used_count", gorm.Expr("used_count + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return models.ErrCouponExhausted
+		}
+
+		var coupon models.Coupon
+		if err := tx.First(&coupon, *order.CouponID).Error; err != nil {
+			return err
+		}
+		if coupon.MaxUsesPerUser > 0 {
+			var used int64
+			err := tx.Model(&models.CouponRedemption{}).
+				Where("coupon_id = ? AND user_id = ?", coupon.ID, order.UserID).
+				Count(&used).Error
+			if err != nil {
+				return err
+			}
+			if used >= int64(coupon.MaxUsesPerUser) {
+				return models.ErrCouponUserLimit
+			}
+		}
+
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.CouponRedemption{
+			CouponID: coupon.ID,
+			UserID:   order.UserID,
+			OrderID:  order.ID,
+			Discount: order.Discount,
+			Currency: order.Currency,
+		}).Error
+	})
+}
+
+// GetByID gets order by ID with its payments
+func (r *orderRepository) GetByID(id uint) (*models.Order, error) {
+	var order models.Order
+	if err := r.db.Preload("Payments").First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetByOrderNo gets order by order number with its payments
+func (r *orderRepository) GetByOrderNo(orderNo string) (*models.Order, error) {
+	var order models.Order
+	if err := r.db.Preload("Payments").Where("order_no = ?", orderNo).First(&order).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// List gets orders with pagination, newest first
+func (r *orderRepository) List(filter OrderFilter, offset, limit int) ([]*models.Order, int64, error) {
+	var orders []*models.Order
+	var total int64
+
+	query := r.db.Model(&models.Order{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Preload("Payments").
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&orders).Error
+	return orders, total, err
+}
+
+// ConfirmPayment marks a pending order as paid, records the payment,
+// activates the ordered plan for the user and rewards the user's referrer,
+// all in one transaction. A payment with models.PaymentMethodBalance is taken
+// from the user's balance and fails with models.ErrInsufficientBalance when
+// it does not cover the order.
+func (r *orderRepository) ConfirmPayment(orderID uint, payment *models.Payment) (*models.Order, error) {
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// The status condition makes concurrent confirmations pay only once
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", orderID, models.OrderStatusPending).
+			Updates(map[string]interface{}{
+				"status":   models.OrderStatusPaid,
+				"paid_at":  now,
+				"operator": payment.Operator,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return r.transitionError(tx, orderID, ErrOrderNotPending)
+		}
+
+		var order models.Order
+		if err := tx.First(&order, orderID).Error; err != nil {
+			return err
+		}
+		if payment.Method == models.PaymentMethodBalance && order.Total > 0 {
+			err := postBalanceTransaction(tx, &models.BalanceTransaction{
+				UserID:   order.UserID,
+				Type:     models.BalanceTransactionPurchase,
+				Amount:   order.Total,
+				Currency: order.Currency,
+				OrderID:  &order.ID,
+				Note:     "order " + order.OrderNo,
+				Operator: payment.Operator,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		var plan models.Plan
+		if err := tx.First(&plan, order.PlanID).Error; err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.First(&user, order.UserID).Error; err != nil {
+			return err
+		}
+
+		// A trial may have started since the order was placed
+		if plan.StartsTrial() && !user.CanStartTrial() {
+			return models.ErrTrialUsed
+		}
+
+		previousPlanID := user.PlanID
+		user.ApplyPlan(&plan, order.Type == models.OrderTypeRenewal, now)
+		err := tx.Model(&user).
+			Select("plan_id", "traffic_quota", "speed_limit", "device_limit", "expires_at",
+				"traffic_used", "traffic_reset_date", "status", "trial_started_at", "trial_ends_at").
+			Updates(&user).Error
+		if err != nil {
+			return err
+		}
+
+		if previousPlanID != plan.ID {
+			err := tx.Model(&models.Plan{}).
+				Where("id = ?", plan.ID).
+				UpdateColumn("current_users", gorm.Expr("current_users + 1")).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&models.Plan{}).
+				Where("id = ? AND current_users > 0", previousPlanID).
+				UpdateColumn("current_users", gorm.Expr("current_users - 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		payment.OrderID = orderID
+		payment.Status = models.PaymentStatusSucceeded
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		return grantReferralCommission(tx, &order)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(orderID)
+}
+
+// Cancel cancels a pending order and gives back its coupon use
+func (r *orderRepository) Cancel(orderID uint, reason, operator string) (*models.Order, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", orderID, models.OrderStatusPending).
+			Updates(map[string]interface{}{
+				"status":        models.OrderStatusCancelled,
+				"cancelled_at":  time.Now(),
+				"status_reason": reason,
+				"operator":      operator,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return r.transitionError(tx, orderID, ErrOrderNotPending)
+		}
+		return r.releaseCoupon(tx, orderID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(orderID)
+}
+
+// Refund marks a paid order and its payments as refunded and cancels its
+// referral commission. Orders paid from the balance, or all orders with
+// toBalance set, are credited back to the user's balance. With revokeAccess
+// set, the user's plan expires now if it is still the refunded one.
+func (r *orderRepository) Refund(orderID uint, reason, operator string, revokeAccess, toBalance bool) (*models.Order, error) {
+	now := time.Now()
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", orderID, models.OrderStatusPaid).
+			Updates(map[string]interface{}{
+				"status":        models.OrderStatusRefunded,
+				"refunded_at":   now,
+				"status_reason": reason,
+				"operator":      operator,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return r.transitionError(tx, orderID, ErrOrderNotPaid)
+		}
+
+		var order models.Order
+		if err := tx.First(&order, orderID).Error; err != nil {
+			return err
+		}
+		var balancePayments int64
+		err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND method = ? AND status = ?", orderID, models.PaymentMethodBalance, models.PaymentStatusSucceeded).
+			Count(&balancePayments).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status = ?", orderID, models.PaymentStatusSucceeded).
+			Update("status", models.PaymentStatusRefunded).Error
+		if err != nil {
+			return err
+		}
+		if err := revokeReferralCommission(tx, orderID); err != nil {
+			return err
+		}
+
+		if (toBalance || balancePayments > 0) && order.Total > 0 {
+			err := postBalanceTransaction(tx, &models.BalanceTransaction{
+				UserID:   order.UserID,
+				Type:     models.BalanceTransactionRefund,
+				Amount:   order.Total,
+				Currency: order.Currency,
+				OrderID:  &order.ID,
+				Note:     "refund of order " + order.OrderNo,
+				Operator: operator,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !revokeAccess {
+			return nil
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ? AND plan_id = ?", order.UserID, order.PlanID).
+			Update("expires_at", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(orderID)
+}
+
+// releaseCoupon removes the coupon redemption of an order and frees its use
+func (r *orderRepository) releaseCoupon(tx *gorm.DB, orderID uint) error {
+	var redemption models.CouponRedemption
+	err := tx.Where("order_id = ?", orderID).First(&redemption).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Delete(&redemption).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.Coupon{}).
+		Where("id = ? AND used_count > 0", redemption.CouponID).
+		UpdateColumn("used_count", gorm.Expr("used_count - 1")).Error
+}
+
+// transitionError tells a missing order apart from one in the wrong state
+func (r *orderRepository) transitionError(tx *gorm.DB, orderID uint, stateErr error) error {
+	var count int64
+	if err := tx.Model(&models.Order{}).Where("id = ?", orderID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return stateErr
+}
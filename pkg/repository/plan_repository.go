@@ -31,16 +31,22 @@ type PlanRepository interface {
 	IncrementUserCount(planID uint) error
 	DecrementUserCount(planID uint) error
 	UpdateUserCount(planID uint, count int) error
+	// ListQuotaWarningThresholds gets the thresholds of the plans that set their own
+	ListQuotaWarningThresholds() (map[uint][]int, error)
 	
 	// Plan features
 	CreateFeature(feature *models.PlanFeature) error
+	GetFeatureByID(featureID uint) (*models.PlanFeature, error)
 	GetPlanFeatures(planID uint) ([]*models.PlanFeature, error)
+	ListFeatures(planID uint) ([]*models.PlanFeature, error)
 	UpdateFeature(feature *models.PlanFeature) error
 	DeleteFeature(featureID uint) error
 	
 	// Plan node access
 	CreateNodeAccess(access *models.PlanNodeAccess) error
+	GetNodeAccess(planID, nodeID uint) (*models.PlanNodeAccess, error)
 	GetPlanNodeAccess(planID uint) ([]*models.PlanNodeAccess, error)
+	ListNodeAccess(planID uint) ([]*models.PlanNodeAccess, error)
 	GetNodeAccessPlans(nodeID uint) ([]*models.PlanNodeAccess, error)
 	UpdateNodeAccess(access *models.PlanNodeAccess) error
 	DeleteNodeAccess(planID, nodeID uint) error
@@ -88,7 +94,7 @@ func (r *planRepository) Create(plan *models.Plan) error {
 // GetByID gets plan by ID
 func (r *planRepository) GetByID(id uint) (*models.Plan, error) {
 	var plan models.Plan
-	err := r.db.Preload("Users").First(&plan, id).Error
+	err := r.db.First(&plan, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -110,9 +116,20 @@ func (r *planRepository) Update(plan *models.Plan) error {
 	return r.db.Save(plan).Error
 }
 
-// Delete soft deletes a plan
+// Delete soft deletes a plan together with its features and node access grants
 func (r *planRepository) Delete(id uint) error {
-	return r.db.Delete(&models.Plan{}, id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("plan_id = ?", id).Delete(&models.PlanFeature{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_id = ?", id).Delete(&models.PlanNodeAccess{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_id = ?", id).Delete(&models.PlanGroupAccess{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Plan{}, id).Error
+	})
 }
 
 // List gets plans with pagination
@@ -296,6 +313,15 @@ func (r *planRepository) CreateFeature(feature *models.PlanFeature) error {
 	return r.db.Create(feature).Error
 }
 
+// GetFeatureByID gets plan feature by ID
+func (r *planRepository) GetFeatureByID(featureID uint) (*models.PlanFeature, error) {
+	var feature models.PlanFeature
+	if err := r.db.First(&feature, featureID).Error; err != nil {
+		return nil, err
+	}
+	return &feature, nil
+}
+
 // GetPlanFeatures gets features for a plan
 func (r *planRepository) GetPlanFeatures(planID uint) ([]*models.PlanFeature, error) {
 	var features []*models.PlanFeature
@@ -305,6 +331,15 @@ func (r *planRepository) GetPlanFeatures(planID uint) ([]*models.PlanFeature, er
 	return features, err
 }
 
+// ListFeatures gets all features for a plan, including hidden ones
+func (r *planRepository) ListFeatures(planID uint) ([]*models.PlanFeature, error) {
+	var features []*models.PlanFeature
+	err := r.db.Where("plan_id = ?", planID).
+		Order("sort_order ASC, id ASC").
+		Find(&features).Error
+	return features, err
+}
+
 // UpdateFeature updates plan feature
 func (r *planRepository) UpdateFeature(feature *models.PlanFeature) error {
 	return r.db.Save(feature).Error
@@ -320,6 +355,16 @@ func (r *planRepository) CreateNodeAccess(access *models.PlanNodeAccess) error {
 	return r.db.Create(access).Error
 }
 
+// GetNodeAccess gets the node access setting of a plan for one node, enabled or not
+func (r *planRepository) GetNodeAccess(planID, nodeID uint) (*models.PlanNodeAccess, error) {
+	var access models.PlanNodeAccess
+	err := r.db.Where("plan_id = ? AND node_id = ?", planID, nodeID).First(&access).Error
+	if err != nil {
+		return nil, err
+	}
+	return &access, nil
+}
+
 // GetPlanNodeAccess gets node access settings for a plan
 func (r *planRepository) GetPlanNodeAccess(planID uint) ([]*models.PlanNodeAccess, error) {
 	var access []*models.PlanNodeAccess
@@ -340,6 +385,16 @@ func (r *planRepository) GetNodeAccessPlans(nodeID uint) ([]*models.PlanNodeAcce
 	return access, err
 }
 
+// ListNodeAccess gets all node access settings for a plan, including disabled ones
+func (r *planRepository) ListNodeAccess(planID uint) ([]*models.PlanNodeAccess, error) {
+	var access []*models.PlanNodeAccess
+	err := r.db.Preload("Node").
+		Where("plan_id = ?", planID).
+		Order("priority ASC, node_id ASC").
+		Find(&access).Error
+	return access, err
+}
+
 // UpdateNodeAccess updates plan node access
 func (r *planRepository) UpdateNodeAccess(access *models.PlanNodeAccess) error {
 	return r.db.Save(access).Error
@@ -351,12 +406,20 @@ func (r *planRepository) DeleteNodeAccess(planID, nodeID uint) error {
 		Delete(&models.PlanNodeAccess{}).Error
 }
 
-// HasNodeAccess checks if plan has access to a node
+// HasNodeAccess checks if plan has access to a node, directly or through a node group
 func (r *planRepository) HasNodeAccess(planID, nodeID uint) (bool, error) {
 	var count int64
 	err := r.db.Model(&models.PlanNodeAccess{}).
 		Where("plan_id = ? AND node_id = ? AND is_enabled = ?", planID, nodeID, true).
 		Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+
+	err = r.db.Model(&models.PlanGroupAccess{}).
+		Joins("JOIN node_group_members ON node_group_members.group_id = plan_group_access.group_id").
+		Where("plan_group_access.plan_id = ? AND node_group_members.node_id = ? AND plan_group_access.is_enabled = ?", planID, nodeID, true).
+		Count(&count).Error
 	return count > 0, err
 }
 
@@ -462,4 +525,19 @@ func (r *planRepository) BatchDisable(planIDs []uint) error {
 // BatchDelete soft deletes multiple plans
 func (r *planRepository) BatchDelete(planIDs []uint) error {
 	return r.db.Delete(&models.Plan{}, planIDs).Error
-}
\ No newline at end of file
+}
+
+// ListQuotaWarningThresholds gets the thresholds of the plans that set their own
+func (r *planRepository) ListQuotaWarningThresholds() (map[uint][]int, error) {
+	var plans []*models.Plan
+	if err := r.db.Select("id", "quota_warning_thresholds").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	thresholds := make(map[uint][]int)
+	for _, plan := range plans {
+		if len(plan.QuotaWarningThresholds) > 0 {
+			thresholds[plan.ID] = plan.QuotaWarningThresholds
+		}
+	}
+	return thresholds, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// ProbeRepository interface defines node probe data access methods
+type ProbeRepository interface {
+	// Basic operations
+	Create(probe *models.NodeProbe) error
+	BatchCreate(probes []*models.NodeProbe) error
+
+	// Statistics
+	GetNodeStats(nodeIDs []uint, since time.Time) (map[uint]*models.NodeProbeStats, error)
+
+	// Maintenance operations
+	CleanupOldProbes(retentionDays int) error
+	CountOldProbes(retentionDays int) (int64, error)
+}
+
+// probeRepository implements ProbeRepository interface
+type probeRepository struct {
+	db *gorm.DB
+}
+
+// NewProbeRepository creates a new probe repository
+func NewProbeRepository(db *gorm.DB) ProbeRepository {
+	return &probeRepository{db: db}
+}
+
+// Create creates a new probe result
+func (r *probeRepository) Create(probe *models.NodeProbe) error {
+	return r.db.Create(probe).Error
+}
+
+// BatchCreate creates multiple probe results
+func (r *probeRepository) BatchCreate(probes []*models.NodeProbe) error {
+	if len(probes) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(probes, 100).Error
+}
+
+// GetNodeStats aggregates probe results per node since the given time
+func (r *probeRepository) GetNodeStats(nodeIDs []uint, since time.Time) (map[uint]*models.NodeProbeStats, error) {
+	stats := make(map[uint]*models.NodeProbeStats)
+	if len(nodeIDs) == 0 {
+		return stats, nil
+	}
+
+	var rows []*models.NodeProbeStats
+	err := r.db.Model(&models.NodeProbe{}).
+		Select(`node_id,
+			COUNT(*) as samples,
+			SUM(CASE WHEN success THEN 1 ELSE 0 END) as successes,
+			COALESCE(AVG(CASE WHEN success THEN latency_ms END), 0) as avg_latency_ms`).
+		Where("node_id IN ? AND probed_at >= ?", nodeIDs, since).
+		Group("node_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		stats[row.NodeID] = row
+	}
+	return stats, nil
+}
+
+// CleanupOldProbes removes old probe results
+func (r *probeRepository) CleanupOldProbes(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.Where("probed_at < ?", cutoff).Delete(&models.NodeProbe{}).Error
+}
+
+// CountOldProbes counts the probe results CleanupOldProbes would remove
+func (r *probeRepository) CountOldProbes(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.NodeProbe{}).Where("probed_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
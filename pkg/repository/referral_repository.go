@@ -0,0 +1,393 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// maxReferralCodeAttempts bounds the retries after a generated code collided
+const maxReferralCodeAttempts = 5
+
+// ReferralRepository interface defines referral program data access methods
+type ReferralRepository interface {
+	// Program settings
+	GetSettings() (*models.ReferralSettings, error)
+	SaveSettings(settings *models.ReferralSettings) error
+
+	// Codes and referred signups
+	GetOrCreateCode(userID uint) (*models.ReferralCode, error)
+	GetCode(code string) (*models.ReferralCode, error)
+	CreateReferral(referral *models.Referral) error
+	ListReferrals(referrerID uint, offset, limit int) ([]*models.Referral, int64, error)
+	GetStats(referrerID uint) (*models.ReferralStats, error)
+
+	// Commissions
+	ListCommissions(filter ReferralCommissionFilter, offset, limit int) ([]*models.ReferralCommission, int64, error)
+	CreateAdjustment(commission *models.ReferralCommission) error
+	Payout(referrerID uint, currency, operator, note string) (int64, int64, error)
+}
+
+// ReferralCommissionFilter narrows a commission listing, zero values match everything
+type ReferralCommissionFilter struct {
+	ReferrerID uint
+	Status     models.ReferralCommissionStatus
+}
+
+// referralRepository implements ReferralRepository interface
+type referralRepository struct {
+	db *gorm.DB
+}
+
+// NewReferralRepository creates a new referral repository
+func NewReferralRepository(db *gorm.DB) ReferralRepository {
+	return &referralRepository{db: db}
+}
+
+// GetSettings gets the program settings, the defaults until an admin saved them
+func (r *referralRepository) GetSettings() (*models.ReferralSettings, error) {
+	return getReferralSettings(r.db)
+}
+
+// SaveSettings creates or replaces the program settings
+func (r *referralRepository) SaveSettings(settings *models.ReferralSettings) error {
+	settings.ID = models.ReferralSettingsID
+	return r.db.Save(settings).Error
+}
+
+// GetOrCreateCode gets the referral code of a user, generating it on first use
+func (r *referralRepository) GetOrCreateCode(userID uint) (*models.ReferralCode, error) {
+	for attempt := 0; attempt < maxReferralCodeAttempts; attempt++ {
+		var code models.ReferralCode
+		err := r.db.Where("user_id = ?", userID).First(&code).Error
+		if err == nil {
+			return &code, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		// A conflict on either unique index means another request created the
+		// user's code or the random code is taken; both are settled by retrying
+		code = models.ReferralCode{UserID: userID, Code: models.NewReferralCode()}
+		result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&code)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return &code, nil
+		}
+	}
+	return nil, errors.New("failed to generate a unique referral code")
+}
+
+// GetCode gets a referral code by its normalized value
+func (r *referralRepository) GetCode(code string) (*models.ReferralCode, error) {
+	var referralCode models.ReferralCode
+	if err := r.db.Where("code = ?", code).First(&referralCode).Error; err != nil {
+		return nil, err
+	}
+	return &referralCode, nil
+}
+
+// CreateReferral records a referred signup
+func (r *referralRepository) CreateReferral(referral *models.Referral) error {
+	return r.db.Create(referral).Error
+}
+
+// ListReferrals lists the users referred by a user, newest first, with their
+// username and number of paid orders
+func (r *referralRepository) ListReferrals(referrerID uint, offset, limit int) ([]*models.Referral, int64, error) {
+	var referrals []*models.Referral
+	var total int64
+
+	query := r.db.Model(&models.Referral{}).Where("referrer_id = ?", referrerID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&referrals).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(referrals) == 0 {
+		return referrals, total, nil
+	}
+
+	userIDs := make([]uint, len(referrals))
+	for i, referral := range referrals {
+		userIDs[i] = referral.UserID
+	}
+	var users []struct {
+		ID       uint
+		Username string
+	}
+	if err := r.db.Model(&models.User{}).Unscoped().Where("id IN ?", userIDs).Select("id, username").Scan(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	var orders []struct {
+		UserID uint
+		Count  int64
+	}
+	err := r.db.Model(&models.Order{}).
+		Where("user_id IN ? AND status = ?", userIDs, models.OrderStatusPaid).
+		Select("user_id, COUNT(*) AS count").
+		Group("user_id").
+		Scan(&orders).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+	paidOrders := make(map[uint]int64, len(orders))
+	for _, order := range orders {
+		paidOrders[order.UserID] = order.Count
+	}
+	for _, referral := range referrals {
+		referral.Username = usernames[referral.UserID]
+		referral.PaidOrders = paidOrders[referral.UserID]
+	}
+	return referrals, total, nil
+}
+
+// GetStats summarizes the referrals and commissions of a user
+func (r *referralRepository) GetStats(referrerID uint) (*models.ReferralStats, error) {
+	stats := &models.ReferralStats{Balances: []models.ReferralBalance{}}
+
+	err := r.db.Model(&models.Referral{}).Where("referrer_id = ?", referrerID).Count(&stats.ReferredUsers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	referred := r.db.Model(&models.Referral{}).Select("user_id").Where("referrer_id = ?", referrerID)
+	var orders struct {
+		PaidOrders  int64
+		PayingUsers int64
+	}
+	err = r.db.Model(&models.Order{}).
+		Where("status = ? AND user_id IN (?)", models.OrderStatusPaid, referred).
+		Select("COUNT(*) AS paid_orders, COUNT(DISTINCT user_id) AS paying_users").
+		Scan(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+	stats.PaidOrders = orders.PaidOrders
+	stats.PayingUsers = orders.PayingUsers
+
+	var rows []struct {
+		Type     models.ReferralCommissionType
+		Currency string
+		Status   models.ReferralCommissionStatus
+		Amount   int64
+	}
+	err = r.db.Model(&models.ReferralCommission{}).
+		Where("referrer_id = ? AND status <> ?", referrerID, models.ReferralCommissionCancelled).
+		Select("type, currency, status, SUM(amount) AS amount").
+		Group("type, currency, status").
+		Order("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]*models.ReferralBalance)
+	var currencies []string
+	for _, row := range rows {
+		if row.Type == models.ReferralCommissionTraffic {
+			stats.TrafficBonus += row.Amount
+			continue
+		}
+		balance, ok := balances[row.Currency]
+		if !ok {
+			balance = &models.ReferralBalance{Currency: row.Currency}
+			balances[row.Currency] = balance
+			currencies = append(currencies, row.Currency)
+		}
+		if row.Status == models.ReferralCommissionPaid {
+			balance.Paid += row.Amount
+		} else {
+			balance.Pending += row.Amount
+		}
+	}
+	for _, currency := range currencies {
+		stats.Balances = append(stats.Balances, *balances[currency])
+	}
+	return stats, nil
+}
+
+// ListCommissions lists commissions, newest first
+func (r *referralRepository) ListCommissions(filter ReferralCommissionFilter, offset, limit int) ([]*models.ReferralCommission, int64, error) {
+	var commissions []*models.ReferralCommission
+	var total int64
+
+	query := r.db.Model(&models.ReferralCommission{})
+	if filter.ReferrerID != 0 {
+		query = query.Where("referrer_id = ?", filter.ReferrerID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&commissions).Error
+	return commissions, total, err
+}
+
+// CreateAdjustment records a manual commission. Traffic adjustments change
+// the referrer's traffic quota in the same transaction.
+func (r *referralRepository) CreateAdjustment(commission *models.ReferralCommission) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if commission.Type == models.ReferralCommissionTraffic {
+			if err := addTrafficQuota(tx, commission.ReferrerID, commission.Amount); err != nil {
+				return err
+			}
+		}
+		return tx.Create(commission).Error
+	})
+}
+
+// Payout marks the pending balance commissions of a referrer in a currency as
+// paid and returns how many there were and their total
+func (r *referralRepository) Payout(referrerID uint, currency, operator, note string) (int64, int64, error) {
+	var count, amount int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		pending := func() *gorm.DB {
+			return tx.Model(&models.ReferralCommission{}).Where("referrer_id = ? AND type = ? AND currency = ? AND status = ?",
+				referrerID, models.ReferralCommissionBalance, currency, models.ReferralCommissionPending)
+		}
+
+		var sum struct {
+			Count  int64
+			Amount int64
+		}
+		if err := pending().Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").Scan(&sum).Error; err != nil {
+			return err
+		}
+		count, amount = sum.Count, sum.Amount
+		if count == 0 {
+			return nil
+		}
+
+		updates := map[string]interface{}{
+			"status":   models.ReferralCommissionPaid,
+			"paid_at":  time.Now(),
+			"operator": operator,
+		}
+		if note != "" {
+			updates["note"] = note
+		}
+		return pending().Updates(updates).Error
+	})
+	return count, amount, err
+}
+
+// getReferralSettings gets the program settings within a transaction or not
+func getReferralSettings(db *gorm.DB) (*models.ReferralSettings, error) {
+	var settings models.ReferralSettings
+	err := db.First(&settings, models.ReferralSettingsID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultReferralSettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// grantReferralCommission rewards the referrer of a user whose order was just
+// paid, as part of the payment transaction
+func grantReferralCommission(tx *gorm.DB, order *models.Order) error {
+	var referral models.Referral
+	err := tx.Where("user_id = ?", order.UserID).First(&referral).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	settings, err := getReferralSettings(tx)
+	if err != nil {
+		return err
+	}
+	if !settings.Enabled {
+		return nil
+	}
+	if settings.FirstOrderOnly {
+		var rewarded int64
+		err := tx.Model(&models.ReferralCommission{}).
+			Where("referred_user_id = ? AND order_id IS NOT NULL", order.UserID).
+			Count(&rewarded).Error
+		if err != nil {
+			return err
+		}
+		if rewarded > 0 {
+			return nil
+		}
+	}
+
+	amount, currency := settings.Commission(order)
+	if amount <= 0 {
+		return nil
+	}
+
+	commission := &models.ReferralCommission{
+		ReferrerID:     referral.ReferrerID,
+		ReferredUserID: order.UserID,
+		OrderID:        &order.ID,
+		Type:           settings.CommissionType,
+		Amount:         amount,
+		Currency:       currency,
+		Status:         models.ReferralCommissionPending,
+	}
+	if settings.CommissionType == models.ReferralCommissionTraffic {
+		commission.Status = models.ReferralCommissionCredited
+		if err := addTrafficQuota(tx, referral.ReferrerID, amount); err != nil {
+			return err
+		}
+	}
+	return tx.Create(commission).Error
+}
+
+// revokeReferralCommission cancels the commission of a refunded order. Paid
+// out balance commissions stay paid, admins settle them with an adjustment.
+func revokeReferralCommission(tx *gorm.DB, orderID uint) error {
+	var commission models.ReferralCommission
+	err := tx.Where("order_id = ?", orderID).First(&commission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch commission.Status {
+	case models.ReferralCommissionPending:
+	case models.ReferralCommissionCredited:
+		if err := addTrafficQuota(tx, commission.ReferrerID, -commission.Amount); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	return tx.Model(&commission).Update("status", models.ReferralCommissionCancelled).Error
+}
+
+// addTrafficQuota changes a user's traffic quota by delta bytes, never below zero
+func addTrafficQuota(tx *gorm.DB, userID uint, delta int64) error {
+	return tx.Model(&models.User{}).
+		Where("id = ?", userID).
+		UpdateColumn("traffic_quota",
+			gorm.Expr("CASE WHEN traffic_quota + ? < 0 THEN 0 ELSE traffic_quota + ? END", delta, delta)).Error
+}
@@ -13,23 +13,129 @@ type Manager struct {
 	db *gorm.DB
 	
 	// Repository instances
-	User    UserRepository
-	Node    NodeRepository
-	Plan    PlanRepository
-	Traffic TrafficRepository
+	User      UserRepository
+	Node      NodeRepository
+	Plan      PlanRepository
+	Traffic   TrafficRepository
+	Token     TokenRepository
+	Probe     ProbeRepository
+	NodeToken NodeTokenRepository
+	Metrics   MetricsRepository
+
+	TrafficAdjustment TrafficAdjustmentRepository
+	Lease             LeaseRepository
+	SubscriptionToken SubscriptionTokenRepository
+	NodeGroup         NodeGroupRepository
+	NodeCost          NodeCostRepository
+	Order             OrderRepository
+	Tenant            TenantRepository
+	Coupon            CouponRepository
+	GeoData           GeoDataRepository
+	NodeConfigVersion NodeConfigVersionRepository
+	Referral          ReferralRepository
+	SafetyPolicy      SafetyPolicyRepository
+	Wallet            WalletRepository
+	Shaping           ShapingRepository
+	Announcement      AnnouncementRepository
+	Notification      NotificationRepository
+	AlertDelivery     AlertDeliveryRepository
+	SavedFilter       SavedFilterRepository
+	Blocklist         BlocklistRepository
+	AdminAudit        AdminAuditRepository
+	NodeFailover      NodeFailoverRepository
+	ExternalAlert     ExternalAlertRepository
+	Integrity         IntegrityRepository
+	APIKey            APIKeyRepository
+	UserMigration     UserMigrationRepository
+	Automation        AutomationRepository
+	SystemSetting     SystemSettingRepository
+	NodeEnrollment    NodeEnrollmentRepository
+	AgentReport       AgentReportRepository
+	NodeCommand       NodeCommandRepository
+	UserCredential    UserCredentialRepository
+	SubscriptionLog   SubscriptionLogRepository
+
+	// analytics is the optional analytics store serving traffic summaries
+	analytics AnalyticsStore
+	// tenants routes the traffic of tenants with a dedicated database, nil
+	// when no tenant has one
+	tenants *TenantDatabases
 }
 
 // NewManager creates a new repository manager
 func NewManager(db *gorm.DB) *Manager {
 	return &Manager{
-		db:      db,
-		User:    NewUserRepository(db),
-		Node:    NewNodeRepository(db),
-		Plan:    NewPlanRepository(db),
-		Traffic: NewTrafficRepository(db),
+		db:        db,
+		User:      NewUserRepository(db),
+		Node:      NewNodeRepository(db),
+		Plan:      NewPlanRepository(db),
+		Traffic:   NewTrafficRepository(db),
+		Token:     NewTokenRepository(db),
+		Probe:     NewProbeRepository(db),
+		NodeToken: NewNodeTokenRepository(db),
+		Metrics:   NewMetricsRepository(db),
+
+		TrafficAdjustment: NewTrafficAdjustmentRepository(db),
+		Lease:             NewLeaseRepository(db),
+		SubscriptionToken: NewSubscriptionTokenRepository(db),
+		NodeGroup:         NewNodeGroupRepository(db),
+		NodeCost:          NewNodeCostRepository(db),
+		Order:             NewOrderRepository(db),
+		Tenant:            NewTenantRepository(db),
+		Coupon:            NewCouponRepository(db),
+		GeoData:           NewGeoDataRepository(db),
+		NodeConfigVersion: NewNodeConfigVersionRepository(db),
+		Referral:          NewReferralRepository(db),
+		SafetyPolicy:      NewSafetyPolicyRepository(db),
+		Wallet:            NewWalletRepository(db),
+		Shaping:           NewShapingRepository(db),
+		Announcement:      NewAnnouncementRepository(db),
+		Notification:      NewNotificationRepository(db),
+		AlertDelivery:     NewAlertDeliveryRepository(db),
+		SavedFilter:       NewSavedFilterRepository(db),
+		Blocklist:         NewBlocklistRepository(db),
+		AdminAudit:        NewAdminAuditRepository(db),
+		NodeFailover:      NewNodeFailoverRepository(db),
+		ExternalAlert:     NewExternalAlertRepository(db),
+		Integrity:         NewIntegrityRepository(db),
+		APIKey:            NewAPIKeyRepository(db),
+		UserMigration:     NewUserMigrationRepository(db),
+		Automation:        NewAutomationRepository(db),
+		SystemSetting:     NewSystemSettingRepository(db),
+		NodeEnrollment:    NewNodeEnrollmentRepository(db),
+		AgentReport:       NewAgentReportRepository(db),
+		NodeCommand:       NewNodeCommandRepository(db),
+		UserCredential:    NewUserCredentialRepository(db),
+		SubscriptionLog:   NewSubscriptionLogRepository(db),
 	}
 }
 
+// EnableAnalytics serves the traffic summary queries from an analytics store
+func (m *Manager) EnableAnalytics(store AnalyticsStore) {
+	m.analytics = store
+	m.Traffic = NewAnalyticsTrafficRepository(m.db, m.Traffic, store)
+}
+
+// EnableTenantDatabases keeps the traffic of tenants in their dedicated
+// databases, keyed by tenant ID
+func (m *Manager) EnableTenantDatabases(tenants map[uint]*gorm.DB) {
+	m.tenants = NewTenantDatabases(m.db, tenants)
+	m.Traffic = NewTenantTrafficRepository(m.db, m.Traffic, m.tenants)
+	m.Node = &tenantNodeRepository{NodeRepository: m.Node, tenants: m.tenants}
+	m.NodeCost = &tenantNodeCostRepository{NodeCostRepository: m.NodeCost, tenants: m.tenants}
+}
+
+// TenantDatabases returns the tenant database router, or nil when no tenant
+// has a dedicated database
+func (m *Manager) TenantDatabases() *TenantDatabases {
+	return m.tenants
+}
+
+// Analytics returns the analytics store, or nil when it is not enabled
+func (m *Manager) Analytics() AnalyticsStore {
+	return m.analytics
+}
+
 // GetDB returns the underlying database instance
 func (m *Manager) GetDB() *gorm.DB {
 	return m.db
@@ -46,11 +152,28 @@ func (m *Manager) Health() error {
 	if err != nil {
 		return err
 	}
-	return sqlDB.Ping()
+	if err := sqlDB.Ping(); err != nil {
+		return err
+	}
+	if m.tenants != nil {
+		return m.tenants.Health()
+	}
+	return nil
 }
 
-// Close closes the database connection
+// Close closes the database connections and the analytics store
 func (m *Manager) Close() error {
+	if m.analytics != nil {
+		if err := m.analytics.Close(); err != nil {
+			return err
+		}
+	}
+	if m.tenants != nil {
+		if err := m.tenants.Close(); err != nil {
+			return err
+		}
+	}
+
 	sqlDB, err := m.db.DB()
 	if err != nil {
 		return err
@@ -128,77 +251,3 @@ func (m *Manager) GetStatistics() (*models.Statistics, error) {
 	
 	return stats, nil
 }
-
-// InitializeDefaultData creates default data in the database
-func (m *Manager) InitializeDefaultData() error {
-	return m.db.Transaction(func(tx *gorm.DB) error {
-		// Create default plan if not exists
-		var planCount int64
-		if err := tx.Model(&models.Plan{}).Count(&planCount).Error; err != nil {
-			return err
-		}
-		
-		if planCount == 0 {
-			defaultPlan := &models.Plan{
-				Name:         "Free Plan",
-				Description:  "Default free plan with basic features",
-				Status:       models.PlanStatusActive,
-				Period:       models.PlanPeriodMonthly,
-				Price:        0,
-				Currency:     "USD",
-				TrafficQuota: 10 * 1024 * 1024 * 1024, // 10GB
-				SpeedLimit:   0,                        // Unlimited
-				DeviceLimit:  1,
-				IsPublic:     true,
-				IsEnabled:    true,
-				Color:        "#2563eb",
-				SortOrder:    1,
-			}
-			if err := tx.Create(defaultPlan).Error; err != nil {
-				return err
-			}
-		}
-		
-		// Create admin user if not exists
-		var adminCount int64
-		if err := tx.Model(&models.User{}).Where("username = ?", "admin").Count(&adminCount).Error; err != nil {
-			return err
-		}
-		
-		if adminCount == 0 {
-			// Get the default plan
-			var defaultPlan models.Plan
-			if err := tx.First(&defaultPlan).Error; err != nil {
-				return err
-			}
-			
-			adminUser := &models.User{
-				Username:          "admin",
-				Email:            "admin@localhost",
-				Password:         "$2a$12$example", // This should be properly hashed in production
-				DisplayName:      "Administrator",
-				Status:           models.UserStatusActive,
-				PlanID:           defaultPlan.ID,
-				TrafficQuota:     -1, // Unlimited for admin
-				DeviceLimit:      10,
-				UUID:             generateUUID(),
-				SubscriptionToken: generateToken(32),
-			}
-			if err := tx.Create(adminUser).Error; err != nil {
-				return err
-			}
-		}
-		
-		return nil
-	})
-}
-
-// generateUUID generates a UUID (simple implementation for the repository layer)
-func generateUUID() string {
-	return "xxxxxxxx-xxxx-4xxx-yxxx-xxxxxxxxxxxx" // This should use a proper UUID library
-}
-
-// generateToken generates a random token (simple implementation)
-func generateToken(length int) string {
-	return "random-token-placeholder" // This should use proper random generation
-}
\ No newline at end of file
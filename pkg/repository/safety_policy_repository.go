@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// SafetyPolicyRepository interface defines safety policy data access methods
+type SafetyPolicyRepository interface {
+	Get() (*models.SafetyPolicy, error)
+	Save(policy *models.SafetyPolicy) error
+}
+
+// safetyPolicyRepository implements SafetyPolicyRepository interface
+type safetyPolicyRepository struct {
+	db *gorm.DB
+}
+
+// NewSafetyPolicyRepository creates a new safety policy repository
+func NewSafetyPolicyRepository(db *gorm.DB) SafetyPolicyRepository {
+	return &safetyPolicyRepository{db: db}
+}
+
+// Get gets the safety policy, or the default one when none was saved
+func (r *safetyPolicyRepository) Get() (*models.SafetyPolicy, error) {
+	var policy models.SafetyPolicy
+	err := r.db.First(&policy, models.SafetyPolicyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultSafetyPolicy(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Save creates or replaces the safety policy
+func (r *safetyPolicyRepository) Save(policy *models.SafetyPolicy) error {
+	policy.ID = models.SafetyPolicyID
+	return r.db.Save(policy).Error
+}
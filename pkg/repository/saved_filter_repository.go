@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// SavedFilterRepository interface defines saved filter data access methods
+type SavedFilterRepository interface {
+	// Basic CRUD operations
+	Create(filter *models.SavedFilter) error
+	GetByID(id uint) (*models.SavedFilter, error)
+	GetByName(ownerID uint, entity models.SavedFilterEntity, name string) (*models.SavedFilter, error)
+	Update(filter *models.SavedFilter) error
+	Delete(id uint) error
+
+	// ListVisible gets the filters an admin owns or that are shared, of one
+	// entity or of all when entity is empty, ordered by name
+	ListVisible(adminID uint, entity models.SavedFilterEntity) ([]*models.SavedFilter, error)
+}
+
+// savedFilterRepository implements SavedFilterRepository interface
+type savedFilterRepository struct {
+	db *gorm.DB
+}
+
+// NewSavedFilterRepository creates a new saved filter repository
+func NewSavedFilterRepository(db *gorm.DB) SavedFilterRepository {
+	return &savedFilterRepository{db: db}
+}
+
+// Create creates a new saved filter
+func (r *savedFilterRepository) Create(filter *models.SavedFilter) error {
+	return r.db.Create(filter).Error
+}
+
+// GetByID gets saved filter by ID
+func (r *savedFilterRepository) GetByID(id uint) (*models.SavedFilter, error) {
+	var filter models.SavedFilter
+	if err := r.db.First(&filter, id).Error; err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// GetByName gets a saved filter of an admin by entity and name
+func (r *savedFilterRepository) GetByName(ownerID uint, entity models.SavedFilterEntity, name string) (*models.SavedFilter, error) {
+	var filter models.SavedFilter
+	err := r.db.Where("owner_id = ? AND entity = ? AND name = ?", ownerID, entity, name).First(&filter).Error
+	if err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// Update updates saved filter
+func (r *savedFilterRepository) Update(filter *models.SavedFilter) error {
+	return r.db.Save(filter).Error
+}
+
+// Delete deletes saved filter
+func (r *savedFilterRepository) Delete(id uint) error {
+	return r.db.Delete(&models.SavedFilter{}, id).Error
+}
+
+// ListVisible gets the filters an admin owns or that are shared
+func (r *savedFilterRepository) ListVisible(adminID uint, entity models.SavedFilterEntity) ([]*models.SavedFilter, error) {
+	var filters []*models.SavedFilter
+	query := r.db.Where("owner_id = ? OR shared = ?", adminID, true)
+	if entity != "" {
+		query = query.Where("entity = ?", entity)
+	}
+	err := query.Order("entity ASC, name ASC, id ASC").Find(&filters).Error
+	return filters, err
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestSavedFilterListVisible(t *testing.T) {
+	repo := NewSavedFilterRepository(newTestDB(t))
+
+	filters := []*models.SavedFilter{
+		{OwnerID: 1, Entity: models.SavedFilterEntityUsers, Name: "mine"},
+		{OwnerID: 1, Entity: models.SavedFilterEntityNodes, Name: "my nodes"},
+		{OwnerID: 2, Entity: models.SavedFilterEntityUsers, Name: "shared", Shared: true},
+		{OwnerID: 2, Entity: models.SavedFilterEntityUsers, Name: "private"},
+	}
+	for _, filter := range filters {
+		if err := repo.Create(filter); err != nil {
+			t.Fatalf("create filter: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		adminID uint
+		entity  models.SavedFilterEntity
+		want    []string
+	}{
+		{"own and shared users filters", 1, models.SavedFilterEntityUsers, []string{"mine", "shared"}},
+		{"all entities", 1, "", []string{"my nodes", "mine", "shared"}},
+		{"other admin", 2, models.SavedFilterEntityUsers, []string{"private", "shared"}},
+		{"admin without filters", 3, "", []string{"shared"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.ListVisible(tt.adminID, tt.entity)
+			if err != nil {
+				t.Fatalf("list filters: %v", err)
+			}
+			names := make([]string, len(got))
+			for i, filter := range got {
+				names[i] = filter.Name
+			}
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("filters = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// ShapingRepository interface defines speed limit shaping data access methods
+type ShapingRepository interface {
+	CreateBatch(records []*models.ShapingRecord) error
+	// Summaries totals the records of each user since a time, keyed by user ID
+	Summaries(userIDs []uint, since time.Time) (map[uint]*models.ShapingSummary, error)
+	// NodeSummaries totals the records of a user per node since a time
+	NodeSummaries(userID uint, since time.Time) ([]*models.ShapingSummary, error)
+
+	// Maintenance
+	CleanupOldRecords(retentionDays int) error
+	CountOldRecords(retentionDays int) (int64, error)
+}
+
+// shapingRepository implements ShapingRepository interface
+type shapingRepository struct {
+	db *gorm.DB
+}
+
+// NewShapingRepository creates a new shaping repository
+func NewShapingRepository(db *gorm.DB) ShapingRepository {
+	return &shapingRepository{db: db}
+}
+
+// shapingTotals selects the summed shaping columns
+const shapingTotals = "COALESCE(SUM(throttle_events), 0) AS throttle_events, " +
+	"COALESCE(SUM(throttled_seconds), 0) AS throttled_seconds, " +
+	"COALESCE(SUM(delayed_bytes), 0) AS delayed_bytes, " +
+	"COALESCE(SUM(dropped_bytes), 0) AS dropped_bytes, " +
+	"COALESCE(MAX(limit_bytes_per_sec), 0) AS limit_bytes_per_sec"
+
+// CreateBatch creates shaping records in batches
+func (r *shapingRepository) CreateBatch(records []*models.ShapingRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(records, 100).Error
+}
+
+// Summaries totals the records of each user since a time. Users without
+// records are missing from the result.
+func (r *shapingRepository) Summaries(userIDs []uint, since time.Time) (map[uint]*models.ShapingSummary, error) {
+	result := make(map[uint]*models.ShapingSummary, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	var summaries []*models.ShapingSummary
+	err := r.db.Model(&models.ShapingRecord{}).
+		Select("user_id, "+shapingTotals).
+		Where("user_id IN ? AND created_at >= ?", userIDs, since).
+		Group("user_id").
+		Scan(&summaries).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		result[summary.UserID] = summary
+	}
+	return result, nil
+}
+
+// NodeSummaries totals the records of a user per node since a time, most
+// throttled node first
+func (r *shapingRepository) NodeSummaries(userID uint, since time.Time) ([]*models.ShapingSummary, error) {
+	var summaries []*models.ShapingSummary
+	err := r.db.Model(&models.ShapingRecord{}).
+		Select("user_id, node_id, "+shapingTotals).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Group("user_id, node_id").
+		Order("throttled_seconds DESC").
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+// CleanupOldRecords removes old shaping records
+func (r *shapingRepository) CleanupOldRecords(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.Where("created_at < ?", cutoff).Delete(&models.ShapingRecord{}).Error
+}
+
+// CountOldRecords counts the shaping records CleanupOldRecords would remove
+func (r *shapingRepository) CountOldRecords(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.ShapingRecord{}).Where("created_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// SubscriptionSharing is a user whose subscription was fetched from many addresses
+type SubscriptionSharing struct {
+	UserID      uint
+	DistinctIPs int64
+	Fetches     int64
+}
+
+// SubscriptionLogRepository interface defines subscription access log data access methods
+type SubscriptionLogRepository interface {
+	Create(log *models.SubscriptionAccessLog) error
+	// ListByUser gets the fetches of a user's subscription, newest first
+	ListByUser(userID uint, offset, limit int) ([]*models.SubscriptionAccessLog, int64, error)
+	// CountDistinctIPs counts the addresses a user's subscription was fetched from since a time
+	CountDistinctIPs(userID uint, since time.Time) (int64, error)
+	// ListSharing gets the users whose subscription was fetched from at least
+	// minIPs addresses since a time, most addresses first
+	ListSharing(since time.Time, minIPs int) ([]SubscriptionSharing, error)
+
+	// Maintenance operations
+	CleanupOldLogs(retentionDays int) error
+	CountOldLogs(retentionDays int) (int64, error)
+}
+
+// subscriptionLogRepository implements SubscriptionLogRepository interface
+type subscriptionLogRepository struct {
+	db *gorm.DB
+}
+
+// NewSubscriptionLogRepository creates a new subscription log repository
+func NewSubscriptionLogRepository(db *gorm.DB) SubscriptionLogRepository {
+	return &subscriptionLogRepository{db: db}
+}
+
+// Create records a subscription fetch
+func (r *subscriptionLogRepository) Create(log *models.SubscriptionAccessLog) error {
+	return r.db.Create(log).Error
+}
+
+// ListByUser gets the fetches of a user's subscription, newest first
+func (r *subscriptionLogRepository) ListByUser(userID uint, offset, limit int) ([]*models.SubscriptionAccessLog, int64, error) {
+	var logs []*models.SubscriptionAccessLog
+	var total int64
+
+	query := r.db.Model(&models.SubscriptionAccessLog{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, total, err
+}
+
+// CountDistinctIPs counts the addresses a user's subscription was fetched from since a time
+func (r *subscriptionLogRepository) CountDistinctIPs(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.SubscriptionAccessLog{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Distinct("ip").
+		Count(&count).Error
+	return count, err
+}
+
+// ListSharing gets the users whose subscription was fetched from at least
+// minIPs addresses since a time, most addresses first
+func (r *subscriptionLogRepository) ListSharing(since time.Time, minIPs int) ([]SubscriptionSharing, error) {
+	var sharing []SubscriptionSharing
+	err := r.db.Model(&models.SubscriptionAccessLog{}).
+		Select("user_id, COUNT(DISTINCT ip) AS distinct_ips, COUNT(*) AS fetches").
+		Where("created_at >= ?", since).
+		Group("user_id").
+		Having("COUNT(DISTINCT ip) >= ?", minIPs).
+		Order("distinct_ips DESC, user_id").
+		Scan(&sharing).Error
+	return sharing, err
+}
+
+// CleanupOldLogs removes fetches recorded more than retentionDays ago
+func (r *subscriptionLogRepository) CleanupOldLogs(retentionDays int) error {
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.Where("created_at < ?", cutoff).Delete(&models.SubscriptionAccessLog{}).Error
+}
+
+// CountOldLogs counts the fetches CleanupOldLogs would remove
+func (r *subscriptionLogRepository) CountOldLogs(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.SubscriptionAccessLog{}).Where("created_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
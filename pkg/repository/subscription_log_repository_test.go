@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestSubscriptionLogRepositorySharing(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewSubscriptionLogRepository(db)
+
+	now := time.Now()
+	fetch := func(userID uint, ip string, at time.Time) {
+		t.Helper()
+		log := &models.SubscriptionAccessLog{CreatedAt: at, UserID: userID, IP: ip, UserAgent: "sing-box 1.11"}
+		if err := repo.Create(log); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	// User 1 fetches from three addresses today, user 2 from one address
+	// today and from others long ago
+	fetch(1, "192.0.2.1", now.Add(-time.Hour))
+	fetch(1, "192.0.2.1", now.Add(-30*time.Minute))
+	fetch(1, "192.0.2.2", now.Add(-20*time.Minute))
+	fetch(1, "2001:db8::1", now.Add(-10*time.Minute))
+	fetch(2, "198.51.100.1", now.Add(-time.Hour))
+	fetch(2, "198.51.100.2", now.AddDate(0, 0, -40))
+	fetch(2, "198.51.100.3", now.AddDate(0, 0, -40))
+
+	since := now.Add(-24 * time.Hour)
+	sharing, err := repo.ListSharing(since, 2)
+	if err != nil {
+		t.Fatalf("ListSharing: %v", err)
+	}
+	if len(sharing) != 1 || sharing[0].UserID != 1 || sharing[0].DistinctIPs != 3 || sharing[0].Fetches != 4 {
+		t.Fatalf("ListSharing = %+v, want user 1 with 3 addresses and 4 fetches", sharing)
+	}
+
+	if count, err := repo.CountDistinctIPs(2, since); err != nil || count != 1 {
+		t.Errorf("CountDistinctIPs(2) = %d, %v, want 1", count, err)
+	}
+
+	logs, total, err := repo.ListByUser(1, 0, 2)
+	if err != nil || total != 4 || len(logs) != 2 || logs[0].IP != "2001:db8::1" {
+		t.Fatalf("ListByUser = %d logs of %d, %v, want the 2 newest of 4", len(logs), total, err)
+	}
+
+	if count, err := repo.CountOldLogs(30); err != nil || count != 2 {
+		t.Fatalf("CountOldLogs = %d, %v, want 2", count, err)
+	}
+	if err := repo.CleanupOldLogs(30); err != nil {
+		t.Fatalf("CleanupOldLogs: %v", err)
+	}
+	if _, total, _ := repo.ListByUser(2, 0, 10); total != 1 {
+		t.Errorf("user 2 has %d fetches after the cleanup, want 1", total)
+	}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// SubscriptionTokenRepository interface defines subscription token rotation data access methods
+type SubscriptionTokenRepository interface {
+	// Business operations
+	Rotate(rotation *models.SubscriptionTokenRotation, newToken string) error
+	GetUserByPreviousToken(token string) (*models.User, error)
+
+	// List operations
+	ListByUser(userID uint, offset, limit int) ([]*models.SubscriptionTokenRotation, int64, error)
+}
+
+// subscriptionTokenRepository implements SubscriptionTokenRepository interface
+type subscriptionTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewSubscriptionTokenRepository creates a new subscription token repository
+func NewSubscriptionTokenRepository(db *gorm.DB) SubscriptionTokenRepository {
+	return &subscriptionTokenRepository{db: db}
+}
+
+// Rotate replaces the user's subscription token and records the replaced one
+// in one transaction. A rotation whose grace period has already ended also
+// ends the grace periods of earlier rotations, so that no old link survives.
+func (r *subscriptionTokenRepository) Rotate(rotation *models.SubscriptionTokenRotation, newToken string) error {
+	now := time.Now()
+	if rotation.GraceUntil.IsZero() {
+		rotation.GraceUntil = now
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id", "subscription_token").First(&user, rotation.UserID).Error; err != nil {
+			return err
+		}
+		rotation.OldTokenHash = models.HashSubscriptionToken(user.SubscriptionToken)
+
+		err := tx.Model(&models.User{}).
+			Where("id = ?", rotation.UserID).
+			Update("subscription_token", newToken).Error
+		if err != nil {
+			return err
+		}
+
+		if !rotation.GraceUntil.After(now) {
+			err := tx.Model(&models.SubscriptionTokenRotation{}).
+				Where("user_id = ? AND grace_until > ?", rotation.UserID, now).
+				Update("grace_until", now).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(rotation).Error
+	})
+}
+
+// GetUserByPreviousToken gets the user whose replaced token is still in its grace period
+func (r *subscriptionTokenRepository) GetUserByPreviousToken(token string) (*models.User, error) {
+	var rotation models.SubscriptionTokenRotation
+	err := r.db.Where("old_token_hash = ? AND grace_until > ?", models.HashSubscriptionToken(token), time.Now()).
+		Order("id DESC").
+		First(&rotation).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := r.db.Preload("Plan").First(&user, rotation.UserID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListByUser gets the token rotations of a user, newest first
+func (r *subscriptionTokenRepository) ListByUser(userID uint, offset, limit int) ([]*models.SubscriptionTokenRotation, int64, error) {
+	var rotations []*models.SubscriptionTokenRotation
+	var total int64
+
+	query := r.db.Model(&models.SubscriptionTokenRotation{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&rotations).Error
+	return rotations, total, err
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// SystemSettingRepository interface defines system setting data access methods
+type SystemSettingRepository interface {
+	// List gets the settings that are set
+	List() ([]*models.SystemSetting, error)
+	// Apply sets the settings to values, unsetting those with an empty
+	// value, and records a change for each value that differs. It returns
+	// the changes.
+	Apply(values map[string]string, operator string) ([]*models.SystemSettingChange, error)
+	// ListChanges gets the changes of a setting, of all when key is empty,
+	// newest first
+	ListChanges(key string, offset, limit int) ([]*models.SystemSettingChange, int64, error)
+	// LatestChangeID gets the ID of the latest change, zero when none
+	LatestChangeID() (uint, error)
+}
+
+// systemSettingRepository implements SystemSettingRepository interface
+type systemSettingRepository struct {
+	db *gorm.DB
+}
+
+// NewSystemSettingRepository creates a new system setting repository
+func NewSystemSettingRepository(db *gorm.DB) SystemSettingRepository {
+	return &systemSettingRepository{db: db}
+}
+
+// List gets the settings that are set, ordered by key
+func (r *systemSettingRepository) List() ([]*models.SystemSetting, error) {
+	var settings []*models.SystemSetting
+	err := r.db.Order("setting_key").Find(&settings).Error
+	return settings, err
+}
+
+// Apply sets the settings in one transaction, in key order
+func (r *systemSettingRepository) Apply(values map[string]string, operator string) ([]*models.SystemSettingChange, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var changes []*models.SystemSettingChange
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		changes = nil
+		for _, key := range keys {
+			var setting models.SystemSetting
+			err := tx.Where("setting_key = ?", key).First(&setting).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			value := values[key]
+			if setting.Value == value {
+				continue
+			}
+
+			if value == "" {
+				err = tx.Where("setting_key = ?", key).Delete(&models.SystemSetting{}).Error
+			} else {
+				err = tx.Save(&models.SystemSetting{Key: key, Value: value, UpdatedBy: operator}).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			change := &models.SystemSettingChange{Key: key, OldValue: setting.Value, NewValue: value, Operator: operator}
+			if err := tx.Create(change).Error; err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ListChanges gets the changes of a setting, newest first
+func (r *systemSettingRepository) ListChanges(key string, offset, limit int) ([]*models.SystemSettingChange, int64, error) {
+	var changes []*models.SystemSettingChange
+	var total int64
+
+	query := r.db.Model(&models.SystemSettingChange{})
+	if key != "" {
+		query = query.Where("setting_key = ?", key)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&changes).Error
+	return changes, total, err
+}
+
+// LatestChangeID gets the ID of the latest change
+func (r *systemSettingRepository) LatestChangeID() (uint, error) {
+	var id uint
+	err := r.db.Model(&models.SystemSettingChange{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
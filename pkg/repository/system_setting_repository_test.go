@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"testing"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestSystemSettingRepositoryApply(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewSystemSettingRepository(db)
+
+	changes, err := repo.Apply(map[string]string{
+		models.SettingNodeCleanupInterval:     "1m",
+		models.SettingNodeRegistrationEnabled: "false",
+	}, "alice")
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Apply = %v, %v, want 2 changes", changes, err)
+	}
+
+	// Unchanged values record nothing, empty values unset
+	changes, err = repo.Apply(map[string]string{
+		models.SettingNodeCleanupInterval:     "1m",
+		models.SettingNodeRegistrationEnabled: "",
+	}, "bob")
+	if err != nil || len(changes) != 1 || changes[0].OldValue != "false" || changes[0].NewValue != "" {
+		t.Fatalf("Apply = %+v, %v, want the unset recorded", changes, err)
+	}
+
+	settings, err := repo.List()
+	if err != nil || len(settings) != 1 || settings[0].Key != models.SettingNodeCleanupInterval || settings[0].UpdatedBy != "alice" {
+		t.Errorf("List = %+v, %v, want the cleanup interval", settings, err)
+	}
+
+	history, total, err := repo.ListChanges(models.SettingNodeRegistrationEnabled, 0, 10)
+	if err != nil || total != 2 || history[0].Operator != "bob" {
+		t.Errorf("ListChanges = %+v, %d, %v, want 2 changes newest first", history, total, err)
+	}
+	if id, err := repo.LatestChangeID(); err != nil || id != history[0].ID {
+		t.Errorf("LatestChangeID = %d, %v, want %d", id, err, history[0].ID)
+	}
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// TenantDatabases routes the traffic data of tenants with a dedicated
+// database to it. Users, nodes and billing stay in the shared database, only
+// the traffic records, summaries and the aggregation watermark of a tenant's
+// users live in its own one.
+type TenantDatabases struct {
+	shared  *gorm.DB
+	tenants map[uint]*gorm.DB
+}
+
+// NewTenantDatabases creates a router over the shared database and the
+// dedicated databases of tenants, keyed by tenant ID
+func NewTenantDatabases(shared *gorm.DB, tenants map[uint]*gorm.DB) *TenantDatabases {
+	return &TenantDatabases{shared: shared, tenants: tenants}
+}
+
+// MigrateTenantDatabase creates the traffic tables in a tenant database
+func MigrateTenantDatabase(db *gorm.DB) error {
+	// Users and nodes live in the shared database, their tables are not created here
+	tx := db.Session(&gorm.Session{})
+	tx.Config.IgnoreRelationshipsWhenMigrating = true
+	return tx.AutoMigrate(
+		&models.TrafficRecord{},
+		&models.TrafficSummary{},
+		&models.AggregationWatermark{},
+	)
+}
+
+// DB returns the database holding the traffic of a tenant, the shared one
+// for tenants without a dedicated database and users without a tenant
+func (t *TenantDatabases) DB(tenantID *uint) *gorm.DB {
+	if tenantID != nil {
+		if db, ok := t.tenants[*tenantID]; ok {
+			return db
+		}
+	}
+	return t.shared
+}
+
+// TenantIDs returns the IDs of the tenants with a dedicated database, in order
+func (t *TenantDatabases) TenantIDs() []uint {
+	ids := make([]uint, 0, len(t.tenants))
+	for id := range t.tenants {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// databases returns all databases, the shared one first
+func (t *TenantDatabases) databases() []*gorm.DB {
+	dbs := []*gorm.DB{t.shared}
+	for _, id := range t.TenantIDs() {
+		dbs = append(dbs, t.tenants[id])
+	}
+	return dbs
+}
+
+// SplitRecords groups records by the tenant database of their user. Records
+// staying in the shared database are grouped under 0.
+func (t *TenantDatabases) SplitRecords(records []*models.TrafficRecord) (map[uint][]*models.TrafficRecord, error) {
+	groups := make(map[uint][]*models.TrafficRecord)
+	if len(t.tenants) == 0 {
+		if len(records) > 0 {
+			groups[0] = records
+		}
+		return groups, nil
+	}
+
+	userIDs := make([]uint, 0, len(records))
+	seen := make(map[uint]bool, len(records))
+	for _, record := range records {
+		if !seen[record.UserID] {
+			seen[record.UserID] = true
+			userIDs = append(userIDs, record.UserID)
+		}
+	}
+
+	// Deleted users keep their tenant, late records follow their history
+	var users []struct {
+		ID       uint
+		TenantID *uint
+	}
+	err := t.shared.Unscoped().Model(&models.User{}).
+		Select("id, tenant_id").
+		Where("id IN ?", userIDs).
+		Scan(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	tenantOf := make(map[uint]uint, len(users))
+	for _, user := range users {
+		if user.TenantID != nil {
+			if _, ok := t.tenants[*user.TenantID]; ok {
+				tenantOf[user.ID] = *user.TenantID
+			}
+		}
+	}
+
+	for _, record := range records {
+		tenantID := tenantOf[record.UserID]
+		groups[tenantID] = append(groups[tenantID], record)
+	}
+	return groups, nil
+}
+
+// Health pings every database
+func (t *TenantDatabases) Health() error {
+	for _, id := range t.TenantIDs() {
+		sqlDB, err := t.tenants[id].DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.Ping(); err != nil {
+			return fmt.Errorf("tenant %d database: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the tenant databases, the shared one is left to its owner
+func (t *TenantDatabases) Close() error {
+	var errs []error
+	for _, id := range t.TenantIDs() {
+		sqlDB, err := t.tenants[id].DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %d database: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tenantNodeRepository moves the traffic history of merged nodes in the
+// tenant databases too
+type tenantNodeRepository struct {
+	NodeRepository
+	tenants *TenantDatabases
+}
+
+// MergeHistory merges the history of a node in the shared database, then
+// reassigns its traffic in the tenant databases. A failure there is returned
+// after the shared merge, merging again finishes the tenant databases.
+func (r *tenantNodeRepository) MergeHistory(fromID, toID uint) (*models.NodeMergeResult, error) {
+	result, err := r.NodeRepository.MergeHistory(fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+	records, summaries, err := r.tenants.reassignNode(fromID, toID)
+	result.TrafficRecords += records
+	result.TrafficSummaries += summaries
+	return result, err
+}
+
+// reassignNode moves the traffic of a node to another one in every tenant database
+func (t *TenantDatabases) reassignNode(fromID, toID uint) (records, summaries int64, err error) {
+	for _, id := range t.TenantIDs() {
+		err := t.tenants[id].Transaction(func(tx *gorm.DB) error {
+			res := tx.Unscoped().Model(&models.TrafficRecord{}).Where("node_id = ?", fromID).Update("node_id", toID)
+			if res.Error != nil {
+				return res.Error
+			}
+			records += res.RowsAffected
+			res = tx.Model(&models.TrafficSummary{}).Where("node_id = ?", fromID).Update("node_id", toID)
+			summaries += res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			return records, summaries, fmt.Errorf("tenant %d database: %w", id, err)
+		}
+	}
+	return records, summaries, nil
+}
+
+// tenantNodeCostRepository adds the node usage of the tenant databases
+type tenantNodeCostRepository struct {
+	NodeCostRepository
+	tenants *TenantDatabases
+}
+
+// GetNodeUsage sums the node usage of all databases. Users have their
+// summaries in a single database, so the active users add up too.
+func (r *tenantNodeCostRepository) GetNodeUsage(start, end time.Time) (map[uint]*models.NodeUsage, error) {
+	usage, err := r.NodeCostRepository.GetNodeUsage(start, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range r.tenants.TenantIDs() {
+		tenantUsage, err := NewNodeCostRepository(r.tenants.tenants[id]).GetNodeUsage(start, end)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %d database: %w", id, err)
+		}
+		for nodeID, u := range tenantUsage {
+			if total, ok := usage[nodeID]; ok {
+				total.TotalTraffic += u.TotalTraffic
+				total.ActiveUsers += u.ActiveUsers
+			} else {
+				usage[nodeID] = u
+			}
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// TenantRepository interface defines tenant data access methods
+type TenantRepository interface {
+	// Basic CRUD operations
+	Create(tenant *models.Tenant) error
+	GetByID(id uint) (*models.Tenant, error)
+	GetByName(name string) (*models.Tenant, error)
+	Update(tenant *models.Tenant) error
+	Delete(id uint) error
+
+	// List operations
+	List(offset, limit int) ([]*models.Tenant, int64, error)
+
+	// Users
+	CountUsers(tenantID uint) (int64, error)
+	AssignUsers(tenantID *uint, userIDs []uint) (int64, error)
+}
+
+// tenantRepository implements TenantRepository interface
+type tenantRepository struct {
+	db *gorm.DB
+}
+
+// NewTenantRepository creates a new tenant repository
+func NewTenantRepository(db *gorm.DB) TenantRepository {
+	return &tenantRepository{db: db}
+}
+
+// Create creates a new tenant
+func (r *tenantRepository) Create(tenant *models.Tenant) error {
+	return r.db.Create(tenant).Error
+}
+
+// GetByID gets tenant by ID
+func (r *tenantRepository) GetByID(id uint) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.db.First(&tenant, id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// GetByName gets tenant by name
+func (r *tenantRepository) GetByName(name string) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.db.Where("name = ?", name).First(&tenant).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Update updates tenant
+func (r *tenantRepository) Update(tenant *models.Tenant) error {
+	return r.db.Save(tenant).Error
+}
+
+// Delete soft deletes a tenant; its users fall back to the default branding
+func (r *tenantRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("tenant_id = ?", id).
+			Update("tenant_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&models.Tenant{}, id).Error
+	})
+}
+
+// List gets tenants with pagination
+func (r *tenantRepository) List(offset, limit int) ([]*models.Tenant, int64, error) {
+	var tenants []*models.Tenant
+	var total int64
+
+	if err := r.db.Model(&models.Tenant{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := r.db.Order("id ASC").Offset(offset).Limit(limit).Find(&tenants).Error
+	return tenants, total, err
+}
+
+// CountUsers counts the users of a tenant
+func (r *tenantRepository) CountUsers(tenantID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).Where("tenant_id = ?", tenantID).Count(&count).Error
+	return count, err
+}
+
+// AssignUsers moves users to a tenant, a nil tenant returns them to the default branding
+func (r *tenantRepository) AssignUsers(tenantID *uint, userIDs []uint) (int64, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id IN ?", userIDs).
+		Update("tenant_id", tenantID)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,620 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// tenantTrafficRepository spreads the traffic data over the shared and the
+// tenant databases. Records are written to the database of their user's
+// tenant and reads fan out to every database, merging the results. Methods
+// addressing a record or summary by ID or key are served by the shared
+// database, IDs are not unique across databases.
+type tenantTrafficRepository struct {
+	TrafficRepository
+	db      *gorm.DB
+	tenants *TenantDatabases
+	// repos holds a repository per database, the shared one first
+	repos []TrafficRepository
+}
+
+// NewTenantTrafficRepository wraps the shared traffic repository with the tenant databases
+func NewTenantTrafficRepository(db *gorm.DB, shared TrafficRepository, tenants *TenantDatabases) TrafficRepository {
+	repos := []TrafficRepository{shared}
+	for _, tenantDB := range tenants.databases()[1:] {
+		repos = append(repos, &trafficRepository{db: tenantDB, detached: true})
+	}
+	return &tenantTrafficRepository{TrafficRepository: shared, db: db, tenants: tenants, repos: repos}
+}
+
+// tenantRepository returns the repository of a tenant's database
+func (r *tenantTrafficRepository) tenantRepository(tenantID uint) TrafficRepository {
+	for i, id := range r.tenants.TenantIDs() {
+		if id == tenantID {
+			return r.repos[i+1]
+		}
+	}
+	return r.repos[0]
+}
+
+// CreateRecord creates a traffic record in the database of its user's tenant
+func (r *tenantTrafficRepository) CreateRecord(record *models.TrafficRecord) error {
+	return r.BatchCreateRecords([]*models.TrafficRecord{record})
+}
+
+// BatchCreateRecords creates traffic records in the databases of their users'
+// tenants. Databases are written one after the other, a failure leaves the
+// records already written in place.
+func (r *tenantTrafficRepository) BatchCreateRecords(records []*models.TrafficRecord) error {
+	groups, err := r.tenants.SplitRecords(records)
+	if err != nil {
+		return err
+	}
+	for tenantID, group := range groups {
+		if err := r.tenantRepository(tenantID).BatchCreateRecords(group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRecords gets traffic records of all databases with filters and pagination
+func (r *tenantTrafficRepository) ListRecords(userID, nodeID uint, start, end time.Time, offset, limit int) ([]*models.TrafficRecord, int64, error) {
+	var pages [][]*models.TrafficRecord
+	var total int64
+	for _, repo := range r.repos {
+		records, count, err := repo.ListRecords(userID, nodeID, start, end, 0, pageWindow(offset, limit))
+		if err != nil {
+			return nil, 0, err
+		}
+		pages = append(pages, records)
+		total += count
+	}
+
+	records := mergePage(pages, func(a, b *models.TrafficRecord) bool { return a.CreatedAt.After(b.CreatedAt) }, offset, limit)
+	return records, total, r.attachRelations(records)
+}
+
+// ListUserRecords gets traffic records for a specific user
+func (r *tenantTrafficRepository) ListUserRecords(userID uint, start, end time.Time, offset, limit int) ([]*models.TrafficRecord, int64, error) {
+	return r.ListRecords(userID, 0, start, end, offset, limit)
+}
+
+// ListNodeRecords gets traffic records for a specific node
+func (r *tenantTrafficRepository) ListNodeRecords(nodeID uint, start, end time.Time, offset, limit int) ([]*models.TrafficRecord, int64, error) {
+	return r.ListRecords(0, nodeID, start, end, offset, limit)
+}
+
+// ListRecentRecords gets the most recent traffic records of all databases
+func (r *tenantTrafficRepository) ListRecentRecords(limit int) ([]*models.TrafficRecord, error) {
+	return r.mergeRecords(func(repo TrafficRepository) ([]*models.TrafficRecord, error) {
+		return repo.ListRecentRecords(limit)
+	}, func(a, b *models.TrafficRecord) bool { return a.CreatedAt.After(b.CreatedAt) }, limit)
+}
+
+// GetUserTrafficSum gets total traffic for a user within date range
+func (r *tenantTrafficRepository) GetUserTrafficSum(userID uint, start, end time.Time) (upload, download, total int64, err error) {
+	return r.sum(func(repo TrafficRepository) (int64, int64, int64, error) {
+		return repo.GetUserTrafficSum(userID, start, end)
+	})
+}
+
+// GetNodeTrafficSum gets total traffic for a node within date range
+func (r *tenantTrafficRepository) GetNodeTrafficSum(nodeID uint, start, end time.Time) (upload, download, total int64, err error) {
+	return r.sum(func(repo TrafficRepository) (int64, int64, int64, error) {
+		return repo.GetNodeTrafficSum(nodeID, start, end)
+	})
+}
+
+// GetTotalTrafficSum gets total traffic for all users within date range
+func (r *tenantTrafficRepository) GetTotalTrafficSum(start, end time.Time) (upload, download, total int64, err error) {
+	return r.sum(func(repo TrafficRepository) (int64, int64, int64, error) {
+		return repo.GetTotalTrafficSum(start, end)
+	})
+}
+
+// GetTotalTrafficInRange gets total traffic in a time range
+func (r *tenantTrafficRepository) GetTotalTrafficInRange(start, end time.Time) (int64, error) {
+	var total int64
+	for _, repo := range r.repos {
+		value, err := repo.GetTotalTrafficInRange(start, end)
+		if err != nil {
+			return 0, err
+		}
+		total += value
+	}
+	return total, nil
+}
+
+// GetUserDailyTraffic gets daily traffic summary for a user
+func (r *tenantTrafficRepository) GetUserDailyTraffic(userID uint, days int) ([]models.TrafficSummary, error) {
+	return r.mergeSeries(func(repo TrafficRepository) ([]models.TrafficSummary, error) {
+		return repo.GetUserDailyTraffic(userID, days)
+	})
+}
+
+// GetNodeDailyTraffic gets daily traffic summary for a node
+func (r *tenantTrafficRepository) GetNodeDailyTraffic(nodeID uint, days int) ([]models.TrafficSummary, error) {
+	return r.mergeSeries(func(repo TrafficRepository) ([]models.TrafficSummary, error) {
+		return repo.GetNodeDailyTraffic(nodeID, days)
+	})
+}
+
+// GetHourlyTraffic gets hourly traffic statistics
+func (r *tenantTrafficRepository) GetHourlyTraffic(start, end time.Time) ([]models.TrafficSummary, error) {
+	return r.mergeSeries(func(repo TrafficRepository) ([]models.TrafficSummary, error) {
+		return repo.GetHourlyTraffic(start, end)
+	})
+}
+
+// GetUserHourlyTraffic gets hourly traffic for a specific user
+func (r *tenantTrafficRepository) GetUserHourlyTraffic(userID uint, start, end time.Time) ([]models.TrafficSummary, error) {
+	return r.mergeSeries(func(repo TrafficRepository) ([]models.TrafficSummary, error) {
+		return repo.GetUserHourlyTraffic(userID, start, end)
+	})
+}
+
+// GetNodeHourlyTraffic gets hourly traffic for a specific node
+func (r *tenantTrafficRepository) GetNodeHourlyTraffic(nodeID uint, start, end time.Time) ([]models.TrafficSummary, error) {
+	return r.mergeSeries(func(repo TrafficRepository) ([]models.TrafficSummary, error) {
+		return repo.GetNodeHourlyTraffic(nodeID, start, end)
+	})
+}
+
+// GetTopTrafficUsers gets users with highest traffic usage over all databases
+func (r *tenantTrafficRepository) GetTopTrafficUsers(start, end time.Time, limit int) ([]*models.User, error) {
+	// Users rarely have traffic in more than one database, the top of each is enough
+	ids, err := r.topTotals("user_id", start, end, limit)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var users []*models.User
+	if err := r.db.Preload("Plan").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return orderByIDs(users, ids, func(user *models.User) uint { return user.ID }), nil
+}
+
+// GetTopTrafficNodes gets nodes with highest traffic usage over all databases
+func (r *tenantTrafficRepository) GetTopTrafficNodes(start, end time.Time, limit int) ([]*models.Node, error) {
+	// Every node serves users of every database, all totals are added up
+	ids, err := r.topTotals("node_id", start, end, -1)
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	var nodes []*models.Node
+	if err := r.db.Where("id IN ?", ids).Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return orderByIDs(nodes, ids, func(node *models.Node) uint { return node.ID }), nil
+}
+
+// topTotals ranks the IDs of a column by their traffic over all databases,
+// taking up to limit IDs of each database
+func (r *tenantTrafficRepository) topTotals(column string, start, end time.Time, limit int) ([]uint, error) {
+	totals := make(map[uint]int64)
+	for _, db := range r.tenants.databases() {
+		var rows []struct {
+			ID    uint
+			Total int64
+		}
+		query := db.Model(&models.TrafficRecord{}).
+			Select(column+" AS id, SUM(total) AS total").
+			Where("record_date BETWEEN ? AND ?", start, end).
+			Group(column).
+			Order("total DESC")
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+		if err := query.Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			totals[row.ID] += row.Total
+		}
+	}
+
+	ids := make([]uint, 0, len(totals))
+	for id := range totals {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if totals[ids[i]] != totals[ids[j]] {
+			return totals[ids[i]] > totals[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// ListSummaries gets traffic summaries of all databases with pagination
+func (r *tenantTrafficRepository) ListSummaries(start, end time.Time, summaryType string, offset, limit int) ([]*models.TrafficSummary, int64, error) {
+	var pages [][]*models.TrafficSummary
+	var total int64
+	for _, repo := range r.repos {
+		summaries, count, err := repo.ListSummaries(start, end, summaryType, 0, pageWindow(offset, limit))
+		if err != nil {
+			return nil, 0, err
+		}
+		pages = append(pages, summaries)
+		total += count
+	}
+
+	summaries := mergePage(pages, func(a, b *models.TrafficSummary) bool { return a.SummaryDate.After(b.SummaryDate) }, offset, limit)
+	users, nodes, err := loadRelations(r.db, summaries, func(s *models.TrafficSummary) (uint, uint, bool) {
+		return s.UserID, s.NodeID, s.User.ID == 0
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, summary := range summaries {
+		if summary.User.ID == 0 {
+			summary.User, summary.Node = users[summary.UserID], nodes[summary.NodeID]
+		}
+	}
+	return summaries, total, nil
+}
+
+// TotalDailySummaries adds up the daily summaries of all databases per user
+// or per node
+func (r *tenantTrafficRepository) TotalDailySummaries(by string, start, end time.Time) ([]*models.TrafficTotal, error) {
+	merged := make(map[uint]*models.TrafficTotal)
+	var totals []*models.TrafficTotal
+	for _, repo := range r.repos {
+		list, err := repo.TotalDailySummaries(by, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, total := range list {
+			// Nodes serve the users of every database
+			if sum, ok := merged[total.ID]; ok {
+				sum.Upload += total.Upload
+				sum.Download += total.Download
+				sum.Total += total.Total
+				sum.Connections += total.Connections
+				continue
+			}
+			merged[total.ID] = total
+			totals = append(totals, total)
+		}
+	}
+
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Total != totals[j].Total {
+			return totals[i].Total > totals[j].Total
+		}
+		return totals[i].ID < totals[j].ID
+	})
+	return totals, nameTrafficTotals(r.db, by, totals)
+}
+
+// AggregateNewRecords aggregates the new records of every database to its
+// own summaries
+func (r *tenantTrafficRepository) AggregateNewRecords(before time.Time, limit int) (int, error) {
+	aggregated := 0
+	for _, repo := range r.repos {
+		count, err := repo.AggregateNewRecords(before, limit)
+		aggregated += count
+		if err != nil {
+			return aggregated, err
+		}
+	}
+	return aggregated, nil
+}
+
+// CheckSummaries checks the summaries of every database
+func (r *tenantTrafficRepository) CheckSummaries(date time.Time) (*models.SummaryCheckResult, error) {
+	result := &models.SummaryCheckResult{}
+	for _, repo := range r.repos {
+		checked, err := repo.CheckSummaries(date)
+		if err != nil {
+			return nil, err
+		}
+		result.Date = checked.Date
+		result.Checked += checked.Checked
+		result.Repaired += checked.Repaired
+	}
+	return result, nil
+}
+
+// CleanupOldRecords removes old traffic records from every database
+func (r *tenantTrafficRepository) CleanupOldRecords(retentionDays int) error {
+	for _, repo := range r.repos {
+		if err := repo.CleanupOldRecords(retentionDays); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CleanupOldSummaries removes old traffic summaries from every database
+func (r *tenantTrafficRepository) CleanupOldSummaries(retentionDays int) error {
+	for _, repo := range r.repos {
+		if err := repo.CleanupOldSummaries(retentionDays); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CountOldRecords counts the old traffic records of every database
+func (r *tenantTrafficRepository) CountOldRecords(retentionDays int) (int64, error) {
+	var total int64
+	for _, repo := range r.repos {
+		count, err := repo.CountOldRecords(retentionDays)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// CountOldSummaries counts the old traffic summaries of every database
+func (r *tenantTrafficRepository) CountOldSummaries(retentionDays int) (int64, error) {
+	var total int64
+	for _, repo := range r.repos {
+		count, err := repo.CountOldSummaries(retentionDays)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// GetActiveConnections gets the active connections of all databases
+func (r *tenantTrafficRepository) GetActiveConnections() ([]*models.TrafficRecord, error) {
+	return r.mergeRecords(func(repo TrafficRepository) ([]*models.TrafficRecord, error) {
+		return repo.GetActiveConnections()
+	}, byConnectTime, -1)
+}
+
+// GetActiveUserConnections gets active connections for a specific user
+func (r *tenantTrafficRepository) GetActiveUserConnections(userID uint) ([]*models.TrafficRecord, error) {
+	return r.mergeRecords(func(repo TrafficRepository) ([]*models.TrafficRecord, error) {
+		return repo.GetActiveUserConnections(userID)
+	}, byConnectTime, -1)
+}
+
+// GetActiveNodeConnections gets active connections for a specific node
+func (r *tenantTrafficRepository) GetActiveNodeConnections(nodeID uint) ([]*models.TrafficRecord, error) {
+	return r.mergeRecords(func(repo TrafficRepository) ([]*models.TrafficRecord, error) {
+		return repo.GetActiveNodeConnections(nodeID)
+	}, byConnectTime, -1)
+}
+
+// GetActiveConnection gets an open session from whichever database holds it
+func (r *tenantTrafficRepository) GetActiveConnection(sessionID string) (*models.TrafficRecord, error) {
+	for _, repo := range r.repos {
+		session, err := repo.GetActiveConnection(sessionID)
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, err
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// CloseConnection closes an active connection in whichever database holds it
+func (r *tenantTrafficRepository) CloseConnection(sessionID string) error {
+	for _, repo := range r.repos {
+		if err := repo.CloseConnection(sessionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseConnections closes the sessions in whichever databases hold them
+func (r *tenantTrafficRepository) CloseConnections(sessionIDs []string) error {
+	for _, repo := range r.repos {
+		if err := repo.CloseConnections(sessionIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseNodeConnections closes the open sessions of a node in all databases
+func (r *tenantTrafficRepository) CloseNodeConnections(nodeID uint) error {
+	for _, repo := range r.repos {
+		if err := repo.CloseNodeConnections(nodeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListUserSessions gets the latest sessions of a user over all databases,
+// newest first
+func (r *tenantTrafficRepository) ListUserSessions(userID uint, limit int) ([]*models.UserSession, error) {
+	var sessions []*models.UserSession
+	for _, repo := range r.repos {
+		list, err := repo.ListUserSessions(userID, limit)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, list...)
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].ConnectTime.After(sessions[j].ConnectTime) })
+	if limit > 0 && len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+// ListOnlineUserIDs gets the online users over all databases
+func (r *tenantTrafficRepository) ListOnlineUserIDs(userIDs []uint, since time.Time) ([]uint, error) {
+	var online []uint
+	seen := make(map[uint]bool)
+	for _, repo := range r.repos {
+		ids, err := repo.ListOnlineUserIDs(userIDs, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				online = append(online, id)
+			}
+		}
+	}
+	return online, nil
+}
+
+// GetUserTraffic gets traffic records for a specific user in time range
+func (r *tenantTrafficRepository) GetUserTraffic(userID uint, start, end time.Time) ([]*models.TrafficRecord, error) {
+	return r.mergeRecords(func(repo TrafficRepository) ([]*models.TrafficRecord, error) {
+		return repo.GetUserTraffic(userID, start, end)
+	}, byRecordDate, -1)
+}
+
+// GetNodeTraffic gets traffic records for a specific node in time range
+func (r *tenantTrafficRepository) GetNodeTraffic(nodeID uint, start, end time.Time) ([]*models.TrafficRecord, error) {
+	return r.mergeRecords(func(repo TrafficRepository) ([]*models.TrafficRecord, error) {
+		return repo.GetNodeTraffic(nodeID, start, end)
+	}, byRecordDate, -1)
+}
+
+// sum adds up traffic sums over all databases
+func (r *tenantTrafficRepository) sum(fn func(TrafficRepository) (int64, int64, int64, error)) (upload, download, total int64, err error) {
+	for _, repo := range r.repos {
+		u, d, t, err := fn(repo)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		upload, download, total = upload+u, download+d, total+t
+	}
+	return upload, download, total, nil
+}
+
+// mergeSeries concatenates the summaries of all databases, newest first
+func (r *tenantTrafficRepository) mergeSeries(fn func(TrafficRepository) ([]models.TrafficSummary, error)) ([]models.TrafficSummary, error) {
+	var series []models.TrafficSummary
+	for _, repo := range r.repos {
+		list, err := fn(repo)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, list...)
+	}
+	sort.SliceStable(series, func(i, j int) bool { return series[i].SummaryDate.After(series[j].SummaryDate) })
+	return series, nil
+}
+
+// mergeRecords merges the records of all databases in order, keeping up to
+// limit of them when limit is positive
+func (r *tenantTrafficRepository) mergeRecords(fn func(TrafficRepository) ([]*models.TrafficRecord, error), less func(a, b *models.TrafficRecord) bool, limit int) ([]*models.TrafficRecord, error) {
+	var pages [][]*models.TrafficRecord
+	for _, repo := range r.repos {
+		records, err := fn(repo)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, records)
+	}
+	records := mergePage(pages, less, 0, limit)
+	return records, r.attachRelations(records)
+}
+
+// attachRelations sets the user and node of records read from tenant
+// databases, which cannot preload them
+func (r *tenantTrafficRepository) attachRelations(records []*models.TrafficRecord) error {
+	users, nodes, err := loadRelations(r.db, records, func(record *models.TrafficRecord) (uint, uint, bool) {
+		return record.UserID, record.NodeID, record.User.ID == 0
+	})
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.User.ID == 0 {
+			record.User, record.Node = users[record.UserID], nodes[record.NodeID]
+		}
+	}
+	return nil
+}
+
+// loadRelations loads the users and nodes of the items missing them from
+// the shared database
+func loadRelations[T any](db *gorm.DB, items []T, keys func(T) (userID, nodeID uint, missing bool)) (map[uint]models.User, map[uint]models.Node, error) {
+	var userIDs, nodeIDs []uint
+	for _, item := range items {
+		if userID, nodeID, missing := keys(item); missing {
+			userIDs = append(userIDs, userID)
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+	users := make(map[uint]models.User)
+	nodes := make(map[uint]models.Node)
+	if len(userIDs) == 0 {
+		return users, nodes, nil
+	}
+
+	var userList []models.User
+	if err := db.Where("id IN ?", userIDs).Find(&userList).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, user := range userList {
+		users[user.ID] = user
+	}
+	var nodeList []models.Node
+	if err := db.Where("id IN ?", nodeIDs).Find(&nodeList).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, node := range nodeList {
+		nodes[node.ID] = node
+	}
+	return users, nodes, nil
+}
+
+// byConnectTime orders records by connection time, newest first
+func byConnectTime(a, b *models.TrafficRecord) bool {
+	return a.ConnectTime.After(b.ConnectTime)
+}
+
+// byRecordDate orders records by record date, newest first
+func byRecordDate(a, b *models.TrafficRecord) bool {
+	return a.RecordDate.After(b.RecordDate)
+}
+
+// pageWindow returns how many rows each database has to return for a page
+// of the merged rows, -1 for all of them
+func pageWindow(offset, limit int) int {
+	if limit < 0 {
+		return -1
+	}
+	return offset + limit
+}
+
+// mergePage merges sorted pages and returns limit items from offset, all
+// remaining ones when limit is negative
+func mergePage[T any](pages [][]T, less func(a, b T) bool, offset, limit int) []T {
+	var items []T
+	for _, page := range pages {
+		items = append(items, page...)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit >= 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
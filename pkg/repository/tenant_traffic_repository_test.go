@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"sing-box-web/pkg/models"
+)
+
+// newTenantTestDB opens a SQLite database migrated as a tenant database
+func newTenantTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "tenant.db")
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatalf("open tenant database: %v", err)
+	}
+	if err := MigrateTenantDatabase(db); err != nil {
+		t.Fatalf("migrate tenant database: %v", err)
+	}
+	return db
+}
+
+func countRecords(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.TrafficRecord{}).Count(&count).Error; err != nil {
+		t.Fatalf("count records: %v", err)
+	}
+	return count
+}
+
+func TestTenantTrafficRepository(t *testing.T) {
+	shared := newTestDB(t)
+	tenantDB := newTenantTestDB(t)
+	if tenantDB.Migrator().HasTable(&models.User{}) {
+		t.Fatal("tenant database has a users table")
+	}
+
+	tenantID := uint(7)
+	otherTenant := uint(8)
+	users := []*models.User{
+		{Username: "shared", Email: "shared@example.com", Password: "x"},
+		{Username: "tenant", Email: "tenant@example.com", Password: "x", TenantID: &tenantID},
+		// Tenants without a dedicated database stay in the shared one
+		{Username: "other", Email: "other@example.com", Password: "x", TenantID: &otherTenant},
+	}
+	for _, user := range users {
+		if err := shared.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	node := &models.Node{Name: "node", Type: models.NodeTypeVLESS, Host: "192.0.2.1", Port: 443}
+	if err := shared.Create(node).Error; err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+
+	manager := NewManager(shared)
+	manager.EnableTenantDatabases(map[uint]*gorm.DB{tenantID: tenantDB})
+	repo := manager.Traffic
+
+	now := time.Now()
+	day := now.Truncate(24 * time.Hour)
+	records := []*models.TrafficRecord{
+		{UserID: users[0].ID, NodeID: node.ID, Upload: 10, Download: 10, RecordDate: day, CreatedAt: now.Add(-3 * time.Hour)},
+		{UserID: users[1].ID, NodeID: node.ID, Upload: 100, Download: 100, RecordDate: day, CreatedAt: now.Add(-2 * time.Hour)},
+		{UserID: users[1].ID, NodeID: node.ID, Upload: 50, Download: 50, RecordDate: day, CreatedAt: now.Add(-time.Hour)},
+		{UserID: users[2].ID, NodeID: node.ID, Upload: 1, Download: 1, RecordDate: day, CreatedAt: now.Add(-4 * time.Hour)},
+	}
+	if err := repo.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+	if got := countRecords(t, shared); got != 2 {
+		t.Errorf("shared database has %d records, want 2", got)
+	}
+	if got := countRecords(t, tenantDB); got != 2 {
+		t.Errorf("tenant database has %d records, want 2", got)
+	}
+
+	page, total, err := repo.ListRecords(0, 0, time.Time{}, time.Time{}, 1, 2)
+	if err != nil {
+		t.Fatalf("list records: %v", err)
+	}
+	if total != 4 || len(page) != 2 {
+		t.Fatalf("got %d of %d records, want 2 of 4", len(page), total)
+	}
+	if page[0].Total != 200 || page[1].Total != 20 {
+		t.Errorf("page = %d, %d bytes, want 200, 20", page[0].Total, page[1].Total)
+	}
+	if page[0].User.Username != "tenant" || page[0].Node.Name != "node" {
+		t.Errorf("tenant record user %q node %q, want tenant and node", page[0].User.Username, page[0].Node.Name)
+	}
+
+	_, _, sum, err := repo.GetTotalTrafficSum(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("sum traffic: %v", err)
+	}
+	if sum != 322 {
+		t.Errorf("total traffic = %d, want 322", sum)
+	}
+
+	top, err := repo.GetTopTrafficUsers(day.Add(-time.Hour), day.Add(time.Hour), 2)
+	if err != nil {
+		t.Fatalf("top users: %v", err)
+	}
+	if len(top) != 2 || top[0].ID != users[1].ID || top[1].ID != users[0].ID {
+		t.Errorf("top users = %+v, want tenant then shared", top)
+	}
+
+	aggregated, err := repo.AggregateNewRecords(now, 100)
+	if err != nil {
+		t.Fatalf("aggregate records: %v", err)
+	}
+	if aggregated != 4 {
+		t.Errorf("aggregated %d records, want 4", aggregated)
+	}
+	if summary := getSummary(t, tenantDB, users[1].ID, "daily", day); summary == nil || summary.TotalTraffic != 300 {
+		t.Errorf("tenant daily summary = %+v, want 300 bytes", summary)
+	}
+	if summary := getSummary(t, shared, users[1].ID, "daily", day); summary != nil {
+		t.Errorf("tenant user has a summary in the shared database: %+v", summary)
+	}
+
+	usage, err := manager.NodeCost.GetNodeUsage(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("node usage: %v", err)
+	}
+	if u := usage[node.ID]; u == nil || u.TotalTraffic != 322 || u.ActiveUsers != 3 {
+		t.Errorf("node usage = %+v, want 322 bytes of 3 users", u)
+	}
+
+	userTotals, err := repo.TotalDailySummaries(models.TrafficTotalsByUser, day, day)
+	if err != nil {
+		t.Fatalf("total user summaries: %v", err)
+	}
+	if len(userTotals) != 3 || userTotals[0].ID != users[1].ID || userTotals[0].Total != 300 || userTotals[0].Name != "tenant" {
+		t.Errorf("user totals = %+v, want the tenant user first with 300 bytes", userTotals)
+	}
+	nodeTotals, err := repo.TotalDailySummaries(models.TrafficTotalsByNode, day, day)
+	if err != nil {
+		t.Fatalf("total node summaries: %v", err)
+	}
+	if len(nodeTotals) != 1 || nodeTotals[0].Total != 322 || nodeTotals[0].Address != "192.0.2.1" {
+		t.Errorf("node totals = %+v, want 322 bytes over both databases", nodeTotals)
+	}
+}
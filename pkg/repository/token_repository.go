@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// TokenRepository interface defines token revocation data access methods
+type TokenRepository interface {
+	// Revocation operations
+	RevokeToken(jti string, userID uint, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+
+	// Maintenance operations
+	DeleteExpired() (int64, error)
+	CountExpired() (int64, error)
+}
+
+// tokenRepository implements TokenRepository interface
+type tokenRepository struct {
+	db *gorm.DB
+}
+
+// NewTokenRepository creates a new token repository
+func NewTokenRepository(db *gorm.DB) TokenRepository {
+	return &tokenRepository{db: db}
+}
+
+// RevokeToken adds a token to the revocation list; revoking twice is a no-op
+func (r *tokenRepository) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+// IsTokenRevoked checks whether an unexpired revocation exists for the JTI
+func (r *tokenRepository) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).
+		Where("jti = ? AND expires_at > ?", jti, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpired removes revocation entries whose tokens have expired
+func (r *tokenRepository) DeleteExpired() (int64, error) {
+	result := r.db.Where("expires_at <= ?", time.Now()).Delete(&models.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
+
+// CountExpired counts the revocation entries DeleteExpired would remove
+func (r *tokenRepository) CountExpired() (int64, error) {
+	var count int64
+	err := r.db.Model(&models.RevokedToken{}).Where("expires_at <= ?", time.Now()).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// TrafficAdjustmentRepository interface defines traffic adjustment data access methods
+type TrafficAdjustmentRepository interface {
+	// Basic operations
+	Apply(adjustment *models.TrafficAdjustment) error
+	GetByID(id uint) (*models.TrafficAdjustment, error)
+
+	// Query operations
+	ListByUser(userID uint, start, end time.Time, offset, limit int) ([]*models.TrafficAdjustment, int64, error)
+	SumByUser(userID uint, start, end time.Time) (int64, error)
+}
+
+// trafficAdjustmentRepository implements TrafficAdjustmentRepository interface
+type trafficAdjustmentRepository struct {
+	db *gorm.DB
+}
+
+// NewTrafficAdjustmentRepository creates a new traffic adjustment repository
+func NewTrafficAdjustmentRepository(db *gorm.DB) TrafficAdjustmentRepository {
+	return &trafficAdjustmentRepository{db: db}
+}
+
+// Apply records the adjustment and applies it to the user's traffic usage in one
+// transaction. Usage never drops below zero; UsageBefore and UsageAfter are
+// filled in with the values observed while applying it.
+func (r *trafficAdjustmentRepository) Apply(adjustment *models.TrafficAdjustment) error {
+	if adjustment.EffectiveDate.IsZero() {
+		adjustment.EffectiveDate = time.Now()
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id", "traffic_used").First(&user, adjustment.UserID).Error; err != nil {
+			return err
+		}
+		adjustment.UsageBefore = user.TrafficUsed
+
+		err := tx.Model(&models.User{}).
+			Where("id = ?", adjustment.UserID).
+			UpdateColumn("traffic_used", gorm.Expr(
+				"CASE WHEN traffic_used + ? < 0 THEN 0 ELSE traffic_used + ? END",
+				adjustment.Bytes, adjustment.Bytes,
+			)).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Select("id", "traffic_used").First(&user, adjustment.UserID).Error; err != nil {
+			return err
+		}
+		adjustment.UsageAfter = user.TrafficUsed
+
+		return tx.Omit("User").Create(adjustment).Error
+	})
+}
+
+// GetByID gets traffic adjustment by ID
+func (r *trafficAdjustmentRepository) GetByID(id uint) (*models.TrafficAdjustment, error) {
+	var adjustment models.TrafficAdjustment
+	if err := r.db.First(&adjustment, id).Error; err != nil {
+		return nil, err
+	}
+	return &adjustment, nil
+}
+
+// ListByUser gets the adjustments of a user effective within [start, end)
+func (r *trafficAdjustmentRepository) ListByUser(userID uint, start, end time.Time, offset, limit int) ([]*models.TrafficAdjustment, int64, error) {
+	var adjustments []*models.TrafficAdjustment
+	var total int64
+
+	query := r.db.Model(&models.TrafficAdjustment{}).
+		Where("user_id = ? AND effective_date >= ? AND effective_date < ?", userID, start, end)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("effective_date DESC, id DESC").
+		Offset(offset).
+		Limit(limit).
+		Find(&adjustments).Error
+
+	return adjustments, total, err
+}
+
+// SumByUser returns the net adjustment of a user effective within [start, end)
+func (r *trafficAdjustmentRepository) SumByUser(userID uint, start, end time.Time) (int64, error) {
+	var sum int64
+	err := r.db.Model(&models.TrafficAdjustment{}).
+		Select("COALESCE(SUM(bytes), 0)").
+		Where("user_id = ? AND effective_date >= ? AND effective_date < ?", userID, start, end).
+		Scan(&sum).Error
+	return sum, err
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"sing-box-web/pkg/models"
+)
+
+// ErrWatermarkMoved is returned when another instance aggregated the same
+// records first. The work was rolled back and can be retried.
+var ErrWatermarkMoved = errors.New("aggregation watermark moved concurrently")
+
+// summaryTotals are the additive columns of a traffic summary for one key
+type summaryTotals struct {
+	UserID      uint
+	NodeID      uint
+	Date        time.Time
+	Upload      int64
+	Download    int64
+	Connections int64
+	Duration    int64
+}
+
+// AggregateNewRecords adds the records after the watermark to the daily and
+// monthly summaries and advances the watermark, all in one transaction.
+// Records created at or after before are left for a later pass, so that
+// batches still being committed are not skipped; records committed later
+// than that are picked up by CheckSummaries.
+func (r *trafficRepository) AggregateNewRecords(before time.Time, limit int) (int, error) {
+	processed := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		watermark, err := getWatermark(tx, models.TrafficSummaryWatermark)
+		if err != nil {
+			return err
+		}
+
+		var pending []struct {
+			ID        uint
+			CreatedAt time.Time
+		}
+		err = tx.Model(&models.TrafficRecord{}).
+			Select("id, created_at").
+			Where("id > ?", watermark.LastRecordID).
+			Order("id").
+			Limit(limit).
+			Scan(&pending).Error
+		if err != nil {
+			return err
+		}
+		for processed < len(pending) && pending[processed].CreatedAt.Before(before) {
+			processed++
+		}
+		if processed == 0 {
+			return nil
+		}
+		last := pending[processed-1]
+
+		var deltas []summaryTotals
+		err = tx.Model(&models.TrafficRecord{}).
+			Select("user_id, node_id, record_date AS date, SUM(upload) AS upload, SUM(download) AS download, "+
+				"COUNT(*) AS connections, SUM(duration) AS duration").
+			Where("id > ? AND id <= ?", watermark.LastRecordID, last.ID).
+			Group("user_id, node_id, record_date").
+			Scan(&deltas).Error
+		if err != nil {
+			return err
+		}
+		for _, delta := range deltas {
+			if err := addToSummary(tx, models.SummaryTypeDaily, delta.Date, delta); err != nil {
+				return err
+			}
+			if err := addToSummary(tx, models.SummaryTypeMonthly, monthStart(delta.Date), delta); err != nil {
+				return err
+			}
+		}
+
+		return advanceWatermark(tx, watermark, map[string]interface{}{
+			"last_record_id": last.ID,
+			"last_record_at": last.CreatedAt,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return processed, nil
+}
+
+// CheckSummaries recomputes the daily summaries of date from the records up
+// to the watermark and the monthly summaries of its month from the daily
+// ones, and overwrites the summaries that differ. The records of date must
+// still be within their retention period.
+func (r *trafficRepository) CheckSummaries(date time.Time) (*models.SummaryCheckResult, error) {
+	day := date.Truncate(24 * time.Hour)
+	result := &models.SummaryCheckResult{Date: day}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		watermark, err := getWatermark(tx, models.TrafficSummaryWatermark)
+		if err != nil {
+			return err
+		}
+
+		var daily []summaryTotals
+		err = tx.Model(&models.TrafficRecord{}).
+			Select("user_id, node_id, SUM(upload) AS upload, SUM(download) AS download, "+
+				"COUNT(*) AS connections, SUM(duration) AS duration").
+			Where("record_date = ? AND id <= ?", day, watermark.LastRecordID).
+			Group("user_id, node_id").
+			Scan(&daily).Error
+		if err != nil {
+			return err
+		}
+		if err := reconcileSummaries(tx, models.SummaryTypeDaily, day, daily, result); err != nil {
+			return err
+		}
+
+		month := monthStart(day)
+		var monthly []summaryTotals
+		err = tx.Model(&models.TrafficSummary{}).
+			Select("user_id, node_id, SUM(total_upload) AS upload, SUM(total_download) AS download, "+
+				"SUM(total_connections) AS connections, SUM(total_duration) AS duration").
+			Where("summary_type = ? AND summary_date >= ? AND summary_date < ?", models.SummaryTypeDaily, month, month.AddDate(0, 1, 0)).
+			Group("user_id, node_id").
+			Scan(&monthly).Error
+		if err != nil {
+			return err
+		}
+		if err := reconcileSummaries(tx, models.SummaryTypeMonthly, month, monthly, result); err != nil {
+			return err
+		}
+
+		// Records aggregated meanwhile would be counted twice by the repairs
+		return advanceWatermark(tx, watermark, map[string]interface{}{"updated_at": time.Now()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// addToSummary adds totals to a summary, creating it when missing
+func addToSummary(tx *gorm.DB, summaryType string, date time.Time, delta summaryTotals) error {
+	// Assignments are applied in column order and avg_duration sorts first,
+	// so it sees the old totals on every database
+	result := tx.Model(&models.TrafficSummary{}).
+		Where("user_id = ? AND node_id = ? AND summary_date = ? AND summary_type = ?",
+			delta.UserID, delta.NodeID, date, summaryType).
+		UpdateColumns(map[string]interface{}{
+			"avg_duration":      gorm.Expr("(total_duration + ?) / (total_connections + ?)", delta.Duration, delta.Connections),
+			"total_connections": gorm.Expr("total_connections + ?", delta.Connections),
+			"total_download":    gorm.Expr("total_download + ?", delta.Download),
+			"total_duration":    gorm.Expr("total_duration + ?", delta.Duration),
+			"total_traffic":     gorm.Expr("total_traffic + ?", delta.Upload+delta.Download),
+			"total_upload":      gorm.Expr("total_upload + ?", delta.Upload),
+			"updated_at":        time.Now(),
+		})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return tx.Omit(clause.Associations).Create(newSummary(summaryType, date, delta)).Error
+}
+
+// reconcileSummaries makes the summaries of a type and date match the
+// expected totals, deleting summaries of keys without any
+func reconcileSummaries(tx *gorm.DB, summaryType string, date time.Time, expected []summaryTotals, result *models.SummaryCheckResult) error {
+	var existing []*models.TrafficSummary
+	err := tx.Where("summary_type = ? AND summary_date = ?", summaryType, date).Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	stored := make(map[[2]uint]*models.TrafficSummary, len(existing))
+	for _, summary := range existing {
+		stored[[2]uint{summary.UserID, summary.NodeID}] = summary
+	}
+
+	for _, totals := range expected {
+		result.Checked++
+		key := [2]uint{totals.UserID, totals.NodeID}
+		summary, ok := stored[key]
+		delete(stored, key)
+		if ok && summary.TotalUpload == totals.Upload && summary.TotalDownload == totals.Download &&
+			summary.TotalConnections == totals.Connections && summary.TotalDuration == totals.Duration {
+			continue
+		}
+
+		result.Repaired++
+		repaired := newSummary(summaryType, date, totals)
+		if !ok {
+			if err := tx.Omit(clause.Associations).Create(repaired).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		err := tx.Model(summary).UpdateColumns(map[string]interface{}{
+			"total_upload":      repaired.TotalUpload,
+			"total_download":    repaired.TotalDownload,
+			"total_traffic":     repaired.TotalUpload + repaired.TotalDownload,
+			"total_connections": repaired.TotalConnections,
+			"total_duration":    repaired.TotalDuration,
+			"avg_duration":      averageDuration(repaired.TotalDuration, repaired.TotalConnections),
+			"updated_at":        time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, orphan := range stored {
+		result.Checked++
+		result.Repaired++
+		if err := tx.Delete(orphan).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getWatermark loads a watermark, starting it at zero on first use
+func getWatermark(tx *gorm.DB, name string) (*models.AggregationWatermark, error) {
+	watermark := &models.AggregationWatermark{}
+	if err := tx.FirstOrCreate(watermark, models.AggregationWatermark{Name: name}).Error; err != nil {
+		return nil, err
+	}
+	return watermark, nil
+}
+
+// advanceWatermark updates a watermark unless another transaction moved it
+// since it was loaded
+func advanceWatermark(tx *gorm.DB, watermark *models.AggregationWatermark, updates map[string]interface{}) error {
+	result := tx.Model(&models.AggregationWatermark{}).
+		Where("name = ? AND last_record_id = ?", watermark.Name, watermark.LastRecordID).
+		UpdateColumns(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrWatermarkMoved, watermark.Name)
+	}
+	return nil
+}
+
+// newSummary returns a summary holding totals
+func newSummary(summaryType string, date time.Time, totals summaryTotals) *models.TrafficSummary {
+	return &models.TrafficSummary{
+		UserID:           totals.UserID,
+		NodeID:           totals.NodeID,
+		SummaryDate:      date,
+		SummaryType:      summaryType,
+		TotalUpload:      totals.Upload,
+		TotalDownload:    totals.Download,
+		TotalConnections: totals.Connections,
+		TotalDuration:    totals.Duration,
+	}
+}
+
+// averageDuration returns the average connection duration in seconds
+func averageDuration(duration, connections int64) int64 {
+	if connections == 0 {
+		return 0
+	}
+	return duration / connections
+}
+
+// monthStart returns the first day of the month of date
+func monthStart(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+}
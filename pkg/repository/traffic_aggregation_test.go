@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// getSummary loads a summary, nil when missing
+func getSummary(t *testing.T, db *gorm.DB, userID uint, summaryType string, date time.Time) *models.TrafficSummary {
+	t.Helper()
+	var summaries []models.TrafficSummary
+	err := db.Where("user_id = ? AND node_id = 1 AND summary_type = ? AND summary_date = ?", userID, summaryType, date).
+		Find(&summaries).Error
+	if err != nil {
+		t.Fatalf("get summary: %v", err)
+	}
+	switch len(summaries) {
+	case 0:
+		return nil
+	case 1:
+		return &summaries[0]
+	}
+	t.Fatalf("%d %s summaries of user %d", len(summaries), summaryType, userID)
+	return nil
+}
+
+func TestAggregateNewRecords(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+	now := time.Now()
+	day := now.Truncate(24 * time.Hour)
+	old := now.Add(-time.Hour)
+
+	records := []*models.TrafficRecord{
+		{UserID: 1, NodeID: 1, Upload: 100, Download: 200, Duration: 10, RecordDate: day, CreatedAt: old},
+		{UserID: 1, NodeID: 1, Upload: 50, Download: 50, Duration: 30, RecordDate: day, CreatedAt: old},
+		{UserID: 2, NodeID: 1, Upload: 1, Download: 2, RecordDate: day, CreatedAt: old},
+		// Still being committed as far as the first pass is concerned
+		{UserID: 1, NodeID: 1, Upload: 1000, Download: 0, RecordDate: day, CreatedAt: now},
+	}
+	if err := repo.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+
+	passes := []struct {
+		name          string
+		before        time.Time
+		limit         int
+		wantProcessed int
+		wantUpload    int64
+	}{
+		{"first batch", now.Add(-time.Minute), 2, 2, 150},
+		{"rest of settled records", now.Add(-time.Minute), 10, 1, 150},
+		{"nothing settled", now.Add(-time.Minute), 10, 0, 150},
+		{"newest record", now.Add(time.Minute), 10, 1, 1150},
+		{"nothing new", now.Add(time.Minute), 10, 0, 1150},
+	}
+	for _, pass := range passes {
+		t.Run(pass.name, func(t *testing.T) {
+			processed, err := repo.AggregateNewRecords(pass.before, pass.limit)
+			if err != nil {
+				t.Fatalf("AggregateNewRecords() error = %v", err)
+			}
+			if processed != pass.wantProcessed {
+				t.Errorf("AggregateNewRecords() = %d, want %d", processed, pass.wantProcessed)
+			}
+			for _, summaryType := range []string{models.SummaryTypeDaily, models.SummaryTypeMonthly} {
+				date := day
+				if summaryType == models.SummaryTypeMonthly {
+					date = monthStart(day)
+				}
+				summary := getSummary(t, db, 1, summaryType, date)
+				if summary == nil || summary.TotalUpload != pass.wantUpload {
+					t.Errorf("%s summary = %+v, want upload %d", summaryType, summary, pass.wantUpload)
+				}
+			}
+		})
+	}
+
+	daily := getSummary(t, db, 1, models.SummaryTypeDaily, day)
+	if daily.TotalTraffic != 1400 || daily.TotalConnections != 3 || daily.AvgDuration != 13 {
+		t.Errorf("daily summary = %+v, want 1400 bytes over 3 connections averaging 13s", daily)
+	}
+}
+
+func TestCheckSummaries(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+	day := time.Now().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+	old := time.Now().Add(-time.Hour)
+
+	records := []*models.TrafficRecord{
+		{UserID: 1, NodeID: 1, Upload: 100, Download: 200, RecordDate: day, CreatedAt: old},
+		{UserID: 2, NodeID: 1, Upload: 10, Download: 20, RecordDate: day, CreatedAt: old},
+	}
+	if err := repo.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+	if _, err := repo.AggregateNewRecords(time.Now(), 10); err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+
+	result, err := repo.CheckSummaries(day)
+	if err != nil {
+		t.Fatalf("CheckSummaries() error = %v", err)
+	}
+	if result.Checked != 4 || result.Repaired != 0 {
+		t.Errorf("CheckSummaries() = %+v, want 4 consistent summaries", result)
+	}
+
+	// Drift: a stale total, a lost summary and one without any traffic
+	db.Model(&models.TrafficSummary{}).Where("user_id = 1 AND summary_type = ?", models.SummaryTypeDaily).
+		UpdateColumn("total_upload", 1)
+	db.Where("user_id = 2 AND summary_type = ?", models.SummaryTypeMonthly).Delete(&models.TrafficSummary{})
+	db.Create(&models.TrafficSummary{UserID: 3, NodeID: 1, SummaryDate: day, SummaryType: models.SummaryTypeDaily, TotalUpload: 5})
+
+	result, err = repo.CheckSummaries(day)
+	if err != nil {
+		t.Fatalf("CheckSummaries() error = %v", err)
+	}
+	if result.Checked != 5 || result.Repaired != 3 {
+		t.Errorf("CheckSummaries() = %+v, want 3 of 5 summaries repaired", result)
+	}
+	if summary := getSummary(t, db, 1, models.SummaryTypeDaily, day); summary == nil || summary.TotalUpload != 100 || summary.TotalTraffic != 300 {
+		t.Errorf("repaired daily summary = %+v, want upload 100 of 300", summary)
+	}
+	if summary := getSummary(t, db, 2, models.SummaryTypeMonthly, monthStart(day)); summary == nil || summary.TotalTraffic != 30 {
+		t.Errorf("recreated monthly summary = %+v, want 30 bytes", summary)
+	}
+	if summary := getSummary(t, db, 3, models.SummaryTypeDaily, day); summary != nil {
+		t.Errorf("orphaned summary = %+v, want it deleted", summary)
+	}
+
+	// Records after the watermark are left to the next aggregation pass
+	late := &models.TrafficRecord{UserID: 1, NodeID: 1, Upload: 7, RecordDate: day, CreatedAt: old}
+	if err := repo.CreateRecord(late); err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+	if result, err = repo.CheckSummaries(day); err != nil || result.Repaired != 0 {
+		t.Errorf("CheckSummaries() = %+v, %v, want no repairs before aggregation", result, err)
+	}
+	if _, err := repo.AggregateNewRecords(time.Now(), 10); err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+	if summary := getSummary(t, db, 1, models.SummaryTypeDaily, day); summary == nil || summary.TotalUpload != 107 {
+		t.Errorf("daily summary = %+v, want upload 107", summary)
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"gorm.io/gorm"
@@ -43,21 +44,42 @@ type TrafficRepository interface {
 	UpdateSummary(summary *models.TrafficSummary) error
 	UpsertSummary(summary *models.TrafficSummary) error
 	ListSummaries(start, end time.Time, summaryType string, offset, limit int) ([]*models.TrafficSummary, int64, error)
+	// TotalDailySummaries adds up the daily summaries of the days from start
+	// to end, both included, per user or per node, the most traffic first
+	TotalDailySummaries(by string, start, end time.Time) ([]*models.TrafficTotal, error)
 	
 	// Data aggregation
-	AggregateHourlyData(date time.Time) error
-	AggregateDailyData(date time.Time) error
-	AggregateMonthlyData(date time.Time) error
+	// AggregateNewRecords adds up to limit records created before a time and
+	// not yet aggregated to the daily and monthly summaries
+	AggregateNewRecords(before time.Time, limit int) (int, error)
+	// CheckSummaries recomputes the summaries of a day and its month and
+	// repairs the ones that drifted
+	CheckSummaries(date time.Time) (*models.SummaryCheckResult, error)
 	
 	// Data cleanup
 	CleanupOldRecords(retentionDays int) error
 	CleanupOldSummaries(retentionDays int) error
+	CountOldRecords(retentionDays int) (int64, error)
+	CountOldSummaries(retentionDays int) (int64, error)
 	
 	// Real-time operations
 	GetActiveConnections() ([]*models.TrafficRecord, error)
 	GetActiveUserConnections(userID uint) ([]*models.TrafficRecord, error)
 	GetActiveNodeConnections(nodeID uint) ([]*models.TrafficRecord, error)
+	// GetActiveConnection gets an open session, gorm.ErrRecordNotFound when
+	// it is closed or unknown
+	GetActiveConnection(sessionID string) (*models.TrafficRecord, error)
 	CloseConnection(sessionID string) error
+	// CloseConnections closes the sessions agents reported closed
+	CloseConnections(sessionIDs []string) error
+	// CloseNodeConnections closes the open sessions of a node, whose agent
+	// lost track of them
+	CloseNodeConnections(nodeID uint) error
+	// ListUserSessions gets the latest sessions of a user, newest first
+	ListUserSessions(userID uint, limit int) ([]*models.UserSession, error)
+	// ListOnlineUserIDs gets the users among userIDs, or of all users when
+	// nil, with a session open or closed since the given time
+	ListOnlineUserIDs(userIDs []uint, since time.Time) ([]uint, error)
 	
 	// Batch operations
 	BatchCreateRecords(records []*models.TrafficRecord) error
@@ -72,6 +94,8 @@ type TrafficRepository interface {
 // trafficRepository implements TrafficRepository interface
 type trafficRepository struct {
 	db *gorm.DB
+	// detached is set for tenant databases, which hold no users or nodes
+	detached bool
 }
 
 // NewTrafficRepository creates a new traffic repository
@@ -79,6 +103,15 @@ func NewTrafficRepository(db *gorm.DB) TrafficRepository {
 	return &trafficRepository{db: db}
 }
 
+// withRelations preloads the user and node of the queried rows, unless they
+// live in another database
+func (r *trafficRepository) withRelations(query *gorm.DB) *gorm.DB {
+	if r.detached {
+		return query
+	}
+	return query.Preload("User").Preload("Node")
+}
+
 // CreateRecord creates a new traffic record
 func (r *trafficRepository) CreateRecord(record *models.TrafficRecord) error {
 	return r.db.Create(record).Error
@@ -87,7 +120,7 @@ func (r *trafficRepository) CreateRecord(record *models.TrafficRecord) error {
 // GetRecordByID gets traffic record by ID
 func (r *trafficRepository) GetRecordByID(id uint) (*models.TrafficRecord, error) {
 	var record models.TrafficRecord
-	err := r.db.Preload("User").Preload("Node").First(&record, id).Error
+	err := r.withRelations(r.db).First(&record, id).Error
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +161,7 @@ func (r *trafficRepository) ListRecords(userID, nodeID uint, start, end time.Tim
 	}
 	
 	// Get records with pagination
-	err := query.Preload("User").Preload("Node").
+	err := r.withRelations(query).
 		Offset(offset).
 		Limit(limit).
 		Order("created_at DESC").
@@ -150,7 +183,7 @@ func (r *trafficRepository) ListNodeRecords(nodeID uint, start, end time.Time, o
 // ListRecentRecords gets recent traffic records
 func (r *trafficRepository) ListRecentRecords(limit int) ([]*models.TrafficRecord, error) {
 	var records []*models.TrafficRecord
-	err := r.db.Preload("User").Preload("Node").
+	err := r.withRelations(r.db).
 		Order("created_at DESC").
 		Limit(limit).
 		Find(&records).Error
@@ -283,11 +316,9 @@ func (r *trafficRepository) GetTopTrafficNodes(start, end time.Time, limit int)
 
 // GetHourlyTraffic gets hourly traffic statistics
 func (r *trafficRepository) GetHourlyTraffic(start, end time.Time) ([]models.TrafficSummary, error) {
-	var summaries []models.TrafficSummary
-	
-	err := r.db.Model(&models.TrafficRecord{}).
+	query := r.db.Model(&models.TrafficRecord{}).
 		Select(`
-			DATE(record_date) as summary_date,
+			`+dateOf(r.db, "record_date")+` as summary_date,
 			record_hour,
 			SUM(upload) as total_upload,
 			SUM(download) as total_download,
@@ -295,21 +326,18 @@ func (r *trafficRepository) GetHourlyTraffic(start, end time.Time) ([]models.Tra
 			COUNT(*) as total_connections
 		`).
 		Where("record_date BETWEEN ? AND ?", start, end).
-		Group("DATE(record_date), record_hour").
-		Order("summary_date DESC, record_hour DESC").
-		Scan(&summaries).Error
-	
-	return summaries, err
+		Group(dateOf(r.db, "record_date") + ", record_hour").
+		Order("summary_date DESC, record_hour DESC")
+
+	return scanHourlyTraffic(query)
 }
 
 // GetUserHourlyTraffic gets hourly traffic for a specific user
 func (r *trafficRepository) GetUserHourlyTraffic(userID uint, start, end time.Time) ([]models.TrafficSummary, error) {
-	var summaries []models.TrafficSummary
-	
-	err := r.db.Model(&models.TrafficRecord{}).
+	query := r.db.Model(&models.TrafficRecord{}).
 		Select(`
 			user_id,
-			DATE(record_date) as summary_date,
+			`+dateOf(r.db, "record_date")+` as summary_date,
 			record_hour,
 			SUM(upload) as total_upload,
 			SUM(download) as total_download,
@@ -317,21 +345,18 @@ func (r *trafficRepository) GetUserHourlyTraffic(userID uint, start, end time.Ti
 			COUNT(*) as total_connections
 		`).
 		Where("user_id = ? AND record_date BETWEEN ? AND ?", userID, start, end).
-		Group("user_id, DATE(record_date), record_hour").
-		Order("summary_date DESC, record_hour DESC").
-		Scan(&summaries).Error
-	
-	return summaries, err
+		Group("user_id, " + dateOf(r.db, "record_date") + ", record_hour").
+		Order("summary_date DESC, record_hour DESC")
+
+	return scanHourlyTraffic(query)
 }
 
 // GetNodeHourlyTraffic gets hourly traffic for a specific node
 func (r *trafficRepository) GetNodeHourlyTraffic(nodeID uint, start, end time.Time) ([]models.TrafficSummary, error) {
-	var summaries []models.TrafficSummary
-	
-	err := r.db.Model(&models.TrafficRecord{}).
+	query := r.db.Model(&models.TrafficRecord{}).
 		Select(`
 			node_id,
-			DATE(record_date) as summary_date,
+			`+dateOf(r.db, "record_date")+` as summary_date,
 			record_hour,
 			SUM(upload) as total_upload,
 			SUM(download) as total_download,
@@ -339,11 +364,10 @@ func (r *trafficRepository) GetNodeHourlyTraffic(nodeID uint, start, end time.Ti
 			COUNT(*) as total_connections
 		`).
 		Where("node_id = ? AND record_date BETWEEN ? AND ?", nodeID, start, end).
-		Group("node_id, DATE(record_date), record_hour").
-		Order("summary_date DESC, record_hour DESC").
-		Scan(&summaries).Error
-	
-	return summaries, err
+		Group("node_id, " + dateOf(r.db, "record_date") + ", record_hour").
+		Order("summary_date DESC, record_hour DESC")
+
+	return scanHourlyTraffic(query)
 }
 
 // CreateSummary creates a new traffic summary
@@ -389,7 +413,7 @@ func (r *trafficRepository) ListSummaries(start, end time.Time, summaryType stri
 	}
 	
 	// Get summaries with pagination
-	err := query.Preload("User").Preload("Node").
+	err := r.withRelations(query).
 		Offset(offset).
 		Limit(limit).
 		Order("summary_date DESC").
@@ -398,92 +422,67 @@ func (r *trafficRepository) ListSummaries(start, end time.Time, summaryType stri
 	return summaries, total, err
 }
 
-// AggregateHourlyData aggregates traffic data into hourly summaries
-func (r *trafficRepository) AggregateHourlyData(date time.Time) error {
-	// This would typically be implemented as a SQL procedure or complex query
-	// For now, we'll use a simplified Go implementation
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		var records []models.TrafficRecord
-		
-		// Get all records for the date
-		if err := tx.Where("record_date = ?", date.Truncate(24*time.Hour)).Find(&records).Error; err != nil {
-			return err
-		}
-		
-		// Group by user, node, and hour
-		summaryMap := make(map[string]*models.TrafficSummary)
-		
-		for _, record := range records {
-			key := fmt.Sprintf("%d-%d-%d", record.UserID, record.NodeID, record.RecordHour)
-			
-			if summary, exists := summaryMap[key]; exists {
-				summary.TotalUpload += record.Upload
-				summary.TotalDownload += record.Download
-				summary.TotalTraffic += record.Total
-				summary.TotalConnections++
-			} else {
-				summaryMap[key] = &models.TrafficSummary{
-					UserID:           record.UserID,
-					NodeID:           record.NodeID,
-					SummaryDate:      date.Truncate(24 * time.Hour),
-					SummaryType:      "hourly",
-					TotalUpload:      record.Upload,
-					TotalDownload:    record.Download,
-					TotalTraffic:     record.Total,
-					TotalConnections: 1,
-				}
-			}
-		}
-		
-		// Save summaries
-		for _, summary := range summaryMap {
-			if err := r.UpsertSummary(summary); err != nil {
-				return err
-			}
-		}
-		
-		return nil
-	})
+// TotalDailySummaries adds up the daily summaries per user or per node
+func (r *trafficRepository) TotalDailySummaries(by string, start, end time.Time) ([]*models.TrafficTotal, error) {
+	column, ok := trafficTotalColumns[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown traffic total subject %q", by)
+	}
+
+	var totals []*models.TrafficTotal
+	err := r.db.Model(&models.TrafficSummary{}).
+		Select(column+" AS id, SUM(total_upload) AS upload, SUM(total_download) AS download, "+
+			"SUM(total_traffic) AS total, SUM(total_connections) AS connections").
+		Where("summary_type = ? AND summary_date BETWEEN ? AND ?", models.SummaryTypeDaily, start, end).
+		Group(column).
+		Order("total DESC, id").
+		Scan(&totals).Error
+	if err != nil || r.detached {
+		return totals, err
+	}
+	return totals, nameTrafficTotals(r.db, by, totals)
+}
+
+// trafficTotalColumns maps the subjects of traffic totals to their column
+var trafficTotalColumns = map[string]string{
+	models.TrafficTotalsByUser: "user_id",
+	models.TrafficTotalsByNode: "node_id",
 }
 
-// AggregateDailyData aggregates traffic data into daily summaries
-func (r *trafficRepository) AggregateDailyData(date time.Time) error {
-	return r.db.Exec(`
-		INSERT INTO traffic_summaries (user_id, node_id, summary_date, summary_type, 
-			total_upload, total_download, total_traffic, total_connections, created_at, updated_at)
-		SELECT user_id, node_id, ?, 'daily',
-			SUM(upload), SUM(download), SUM(total), COUNT(*), NOW(), NOW()
-		FROM traffic_records 
-		WHERE record_date = ?
-		GROUP BY user_id, node_id
-		ON DUPLICATE KEY UPDATE
-			total_upload = VALUES(total_upload),
-			total_download = VALUES(total_download),
-			total_traffic = VALUES(total_traffic),
-			total_connections = VALUES(total_connections),
-			updated_at = NOW()
-	`, date.Truncate(24*time.Hour), date.Truncate(24*time.Hour)).Error
-}
-
-// AggregateMonthlyData aggregates traffic data into monthly summaries
-func (r *trafficRepository) AggregateMonthlyData(date time.Time) error {
-	monthStart := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
-	
-	return r.db.Exec(`
-		INSERT INTO traffic_summaries (user_id, node_id, summary_date, summary_type, 
-			total_upload, total_download, total_traffic, total_connections, created_at, updated_at)
-		SELECT user_id, node_id, ?, 'monthly',
-			SUM(upload), SUM(download), SUM(total), COUNT(*), NOW(), NOW()
-		FROM traffic_records 
-		WHERE record_date >= ? AND record_date < ?
-		GROUP BY user_id, node_id
-		ON DUPLICATE KEY UPDATE
-			total_upload = VALUES(total_upload),
-			total_download = VALUES(total_download),
-			total_traffic = VALUES(total_traffic),
-			total_connections = VALUES(total_connections),
-			updated_at = NOW()
-	`, monthStart, monthStart.AddDate(0, 1, 0), monthStart).Error
+// nameTrafficTotals sets the names and addresses of the users or nodes of
+// totals from the shared database
+func nameTrafficTotals(db *gorm.DB, by string, totals []*models.TrafficTotal) error {
+	if len(totals) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(totals))
+	for i, total := range totals {
+		ids[i] = total.ID
+	}
+
+	var rows []struct {
+		ID      uint
+		Name    string
+		Address string
+	}
+	query := db.Table("users").Select("id, username AS name, email AS address")
+	if by == models.TrafficTotalsByNode {
+		query = db.Table("nodes").Select("id, name, host AS address")
+	}
+	if err := query.Where("id IN ?", ids).Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	byID := make(map[uint]int, len(rows))
+	for i, row := range rows {
+		byID[row.ID] = i
+	}
+	for _, total := range totals {
+		if i, ok := byID[total.ID]; ok {
+			total.Name, total.Address = rows[i].Name, rows[i].Address
+		}
+	}
+	return nil
 }
 
 // CleanupOldRecords removes old traffic records
@@ -498,47 +497,206 @@ func (r *trafficRepository) CleanupOldSummaries(retentionDays int) error {
 	return r.db.Where("summary_date < ?", cutoff).Delete(&models.TrafficSummary{}).Error
 }
 
+// CountOldRecords counts the traffic records CleanupOldRecords would remove
+func (r *trafficRepository) CountOldRecords(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.TrafficRecord{}).Where("created_at < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
+// CountOldSummaries counts the traffic summaries CleanupOldSummaries would remove
+func (r *trafficRepository) CountOldSummaries(retentionDays int) (int64, error) {
+	var count int64
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	err := r.db.Model(&models.TrafficSummary{}).Where("summary_date < ?", cutoff).Count(&count).Error
+	return count, err
+}
+
 // GetActiveConnections gets all active connections
 func (r *trafficRepository) GetActiveConnections() ([]*models.TrafficRecord, error) {
-	var records []*models.TrafficRecord
-	err := r.db.Preload("User").Preload("Node").
-		Where("disconnect_time IS NULL").
-		Order("connect_time DESC").
-		Find(&records).Error
-	return records, err
+	return r.activeSessions(r.db)
 }
 
 // GetActiveUserConnections gets active connections for a specific user
 func (r *trafficRepository) GetActiveUserConnections(userID uint) ([]*models.TrafficRecord, error) {
-	var records []*models.TrafficRecord
-	err := r.db.Preload("User").Preload("Node").
-		Where("user_id = ? AND disconnect_time IS NULL", userID).
-		Order("connect_time DESC").
-		Find(&records).Error
-	return records, err
+	return r.activeSessions(r.db.Where("user_id = ?", userID))
 }
 
 // GetActiveNodeConnections gets active connections for a specific node
 func (r *trafficRepository) GetActiveNodeConnections(nodeID uint) ([]*models.TrafficRecord, error) {
+	return r.activeSessions(r.db.Where("node_id = ?", nodeID))
+}
+
+// GetActiveConnection gets an open session
+func (r *trafficRepository) GetActiveConnection(sessionID string) (*models.TrafficRecord, error) {
+	sessions, err := r.activeSessions(r.db.Where("session_id = ?", sessionID))
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return sessions[0], nil
+}
+
+// activeSessions gets the open sessions of a query, newest first. Agents
+// report a session's traffic over several records, which are folded into
+// one record per session. Records without a session ID are traffic not
+// broken down by connection and never count as connections.
+func (r *trafficRepository) activeSessions(query *gorm.DB) ([]*models.TrafficRecord, error) {
 	var records []*models.TrafficRecord
-	err := r.db.Preload("User").Preload("Node").
-		Where("node_id = ? AND disconnect_time IS NULL", nodeID).
-		Order("connect_time DESC").
+	err := r.withRelations(query).
+		Where("session_id <> '' AND disconnect_time IS NULL").
+		Order("id ASC").
 		Find(&records).Error
-	return records, err
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*models.TrafficRecord, 0, len(records))
+	bySession := make(map[string]*models.TrafficRecord, len(records))
+	for _, record := range records {
+		session, ok := bySession[record.SessionID]
+		if !ok {
+			bySession[record.SessionID] = record
+			sessions = append(sessions, record)
+			continue
+		}
+		session.Upload += record.Upload
+		session.Download += record.Download
+		session.Total += record.Total
+		if record.ConnectTime.Before(session.ConnectTime) {
+			session.ConnectTime = record.ConnectTime
+		}
+		if record.ClientIP != "" {
+			session.ClientIP = record.ClientIP
+		}
+		if record.Protocol != "" {
+			session.Protocol = record.Protocol
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].ConnectTime.After(sessions[j].ConnectTime) })
+	return sessions, nil
 }
 
 // CloseConnection closes an active connection
 func (r *trafficRepository) CloseConnection(sessionID string) error {
+	return r.CloseConnections([]string{sessionID})
+}
+
+// CloseConnections closes the sessions agents reported closed
+func (r *trafficRepository) CloseConnections(sessionIDs []string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	return r.closeSessions(r.db.Where("session_id IN ?", sessionIDs))
+}
+
+// CloseNodeConnections closes the open sessions of a node
+func (r *trafficRepository) CloseNodeConnections(nodeID uint) error {
+	return r.closeSessions(r.db.Where("node_id = ? AND session_id <> ''", nodeID))
+}
+
+// closeSessions closes the open session records of a query now
+func (r *trafficRepository) closeSessions(query *gorm.DB) error {
 	now := time.Now()
-	return r.db.Model(&models.TrafficRecord{}).
-		Where("session_id = ? AND disconnect_time IS NULL", sessionID).
+	return query.Model(&models.TrafficRecord{}).
+		Where("disconnect_time IS NULL").
 		Updates(map[string]interface{}{
 			"disconnect_time": now,
-			"duration":        gorm.Expr("TIMESTAMPDIFF(SECOND, connect_time, ?)", now),
+			"duration":        secondsSince(r.db, "connect_time", now),
 		}).Error
 }
 
+// ListUserSessions gets the latest sessions of a user, newest first. A
+// session spans every record reported with its session ID, so the sessions
+// are picked first and their records folded together afterwards.
+func (r *trafficRepository) ListUserSessions(userID uint, limit int) ([]*models.UserSession, error) {
+	var sessionIDs []string
+	err := r.db.Model(&models.TrafficRecord{}).
+		Select("session_id").
+		Where("user_id = ? AND session_id <> ''", userID).
+		Group("session_id").
+		Order("MAX(id) DESC").
+		Limit(limit).
+		Pluck("session_id", &sessionIDs).Error
+	if err != nil || len(sessionIDs) == 0 {
+		return nil, err
+	}
+
+	var records []*models.TrafficRecord
+	err = r.db.Where("user_id = ? AND session_id IN ?", userID, sessionIDs).
+		Order("id ASC").
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make(map[string]*models.UserSession, len(sessionIDs))
+	open := make(map[string]bool, len(sessionIDs))
+	for _, record := range records {
+		session, ok := sessions[record.SessionID]
+		if !ok {
+			session = &models.UserSession{
+				SessionID:   record.SessionID,
+				NodeID:      record.NodeID,
+				ConnectTime: record.ConnectTime,
+			}
+			sessions[record.SessionID] = session
+		}
+		if record.ConnectTime.Before(session.ConnectTime) {
+			session.ConnectTime = record.ConnectTime
+		}
+		if record.DisconnectTime == nil {
+			open[record.SessionID] = true
+		} else if session.DisconnectTime == nil || record.DisconnectTime.After(*session.DisconnectTime) {
+			session.DisconnectTime = record.DisconnectTime
+		}
+		if record.ClientIP != "" {
+			session.ClientIP = record.ClientIP
+		}
+		if record.DeviceID != "" {
+			session.DeviceID = record.DeviceID
+		}
+		if record.Protocol != "" {
+			session.Protocol = record.Protocol
+		}
+		session.Upload += record.Upload
+		session.Download += record.Download
+	}
+
+	result := make([]*models.UserSession, 0, len(sessionIDs))
+	for _, id := range sessionIDs {
+		session, ok := sessions[id]
+		if !ok {
+			continue
+		}
+		if open[id] {
+			session.DisconnectTime = nil
+		}
+		result = append(result, session)
+	}
+	return result, nil
+}
+
+// ListOnlineUserIDs gets the users among userIDs, or of all users when nil,
+// with a session open or closed since the given time
+func (r *trafficRepository) ListOnlineUserIDs(userIDs []uint, since time.Time) ([]uint, error) {
+	if userIDs != nil && len(userIDs) == 0 {
+		return nil, nil
+	}
+	query := r.db.Model(&models.TrafficRecord{}).
+		Where("session_id <> '' AND (disconnect_time IS NULL OR disconnect_time >= ?)", since)
+	if userIDs != nil {
+		query = query.Where("user_id IN ?", userIDs)
+	}
+	var online []uint
+	err := query.Distinct().Pluck("user_id", &online).Error
+	return online, err
+}
+
 // BatchCreateRecords creates multiple traffic records
 func (r *trafficRepository) BatchCreateRecords(records []*models.TrafficRecord) error {
 	if len(records) == 0 {
@@ -566,7 +724,7 @@ func (r *trafficRepository) BatchUpdateRecords(records []*models.TrafficRecord)
 // GetUserTraffic gets traffic records for a specific user in time range
 func (r *trafficRepository) GetUserTraffic(userID uint, start, end time.Time) ([]*models.TrafficRecord, error) {
 	var records []*models.TrafficRecord
-	query := r.db.Preload("User").Preload("Node").Where("user_id = ?", userID)
+	query := r.withRelations(r.db).Where("user_id = ?", userID)
 	
 	if !start.IsZero() && !end.IsZero() {
 		query = query.Where("record_date BETWEEN ? AND ?", start, end)
@@ -579,7 +737,7 @@ func (r *trafficRepository) GetUserTraffic(userID uint, start, end time.Time) ([
 // GetNodeTraffic gets traffic records for a specific node in time range
 func (r *trafficRepository) GetNodeTraffic(nodeID uint, start, end time.Time) ([]*models.TrafficRecord, error) {
 	var records []*models.TrafficRecord
-	query := r.db.Preload("User").Preload("Node").Where("node_id = ?", nodeID)
+	query := r.withRelations(r.db).Where("node_id = ?", nodeID)
 	
 	if !start.IsZero() && !end.IsZero() {
 		query = query.Where("record_date BETWEEN ? AND ?", start, end)
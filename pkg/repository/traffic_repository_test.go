@@ -0,0 +1,260 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestListUserSessions(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	end := start.Add(time.Hour)
+
+	records := []*models.TrafficRecord{
+		{UserID: 1, NodeID: 1, SessionID: "a", ConnectTime: start, DisconnectTime: &end, ClientIP: "192.0.2.1", DeviceID: "phone", Upload: 10, Download: 20},
+		{UserID: 1, NodeID: 1, SessionID: "a", ConnectTime: start, DisconnectTime: &end, Upload: 5, Download: 5},
+		{UserID: 1, NodeID: 2, SessionID: "b", ConnectTime: end, ClientIP: "192.0.2.2", Protocol: "vless", Upload: 1},
+		{UserID: 1, NodeID: 2, SessionID: "b", ConnectTime: end, Download: 2},
+		// Records without a session and of other users are left out
+		{UserID: 1, NodeID: 1, Upload: 100},
+		{UserID: 2, NodeID: 1, SessionID: "c", Upload: 100},
+	}
+	if err := repo.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+
+	sessions, err := repo.ListUserSessions(1, 10)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+
+	latest, first := sessions[0], sessions[1]
+	if latest.SessionID != "b" || !latest.Active() || latest.NodeID != 2 {
+		t.Errorf("latest session = %+v, want active session b on node 2", latest)
+	}
+	if latest.Upload != 1 || latest.Download != 2 || latest.Protocol != "vless" {
+		t.Errorf("latest session = %+v, want 1 up, 2 down over vless", latest)
+	}
+	if first.SessionID != "a" || first.Active() || !first.DisconnectTime.Equal(end) {
+		t.Errorf("first session = %+v, want session a closed at %v", first, end)
+	}
+	if first.Upload != 15 || first.Download != 25 || first.ClientIP != "192.0.2.1" || first.DeviceID != "phone" {
+		t.Errorf("first session = %+v, want 15 up, 25 down from the phone", first)
+	}
+
+	sessions, err = repo.ListUserSessions(1, 1)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].SessionID != "b" {
+		t.Errorf("limited sessions = %+v, want only session b", sessions)
+	}
+}
+
+func TestCloseConnection(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+
+	record := &models.TrafficRecord{UserID: 1, NodeID: 1, SessionID: "a", ConnectTime: time.Now().Add(-90 * time.Second)}
+	if err := repo.CreateRecord(record); err != nil {
+		t.Fatalf("create record: %v", err)
+	}
+	if err := repo.CloseConnection("a"); err != nil {
+		t.Fatalf("close connection: %v", err)
+	}
+
+	var closed models.TrafficRecord
+	if err := db.First(&closed, record.ID).Error; err != nil {
+		t.Fatalf("get record: %v", err)
+	}
+	if closed.DisconnectTime == nil {
+		t.Fatal("connection not closed")
+	}
+	if closed.Duration < 89 || closed.Duration > 91 {
+		t.Errorf("duration = %d, want 90 seconds", closed.Duration)
+	}
+}
+
+func TestActiveConnections(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	records := []*models.TrafficRecord{
+		{UserID: 1, NodeID: 1, SessionID: "a", ConnectTime: start, ClientIP: "192.0.2.1", Protocol: "vless", Upload: 10, Download: 20, Total: 30},
+		{UserID: 1, NodeID: 1, SessionID: "a", ConnectTime: start, Upload: 5, Download: 5, Total: 10},
+		{UserID: 1, NodeID: 2, SessionID: "b", ConnectTime: start.Add(time.Minute), Upload: 1, Total: 1},
+		{UserID: 2, NodeID: 2, SessionID: "c", ConnectTime: start, Upload: 1, Total: 1},
+		// Traffic not broken down by connection is no session
+		{UserID: 1, NodeID: 1, ConnectTime: start, Upload: 100, Total: 100},
+	}
+	if err := repo.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+
+	sessions, err := repo.GetActiveUserConnections(1)
+	if err != nil {
+		t.Fatalf("get active connections: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].SessionID != "b" || sessions[1].SessionID != "a" {
+		t.Fatalf("sessions = %+v, want b then a", sessions)
+	}
+	if a := sessions[1]; a.Total != 40 || a.Upload != 15 || a.ClientIP != "192.0.2.1" || a.Protocol != "vless" {
+		t.Errorf("session a = %+v, want 40 bytes over vless from 192.0.2.1", a)
+	}
+
+	if session, err := repo.GetActiveConnection("c"); err != nil || session.UserID != 2 {
+		t.Errorf("session c = %+v, %v, want the session of user 2", session, err)
+	}
+
+	if err := repo.CloseConnections([]string{"a"}); err != nil {
+		t.Fatalf("close connections: %v", err)
+	}
+	if err := repo.CloseNodeConnections(2); err != nil {
+		t.Fatalf("close node connections: %v", err)
+	}
+	sessions, err = repo.GetActiveConnections()
+	if err != nil {
+		t.Fatalf("get active connections: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("sessions = %+v, want none left open", sessions)
+	}
+	if _, err := repo.GetActiveConnection("a"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("closed session error = %v, want not found", err)
+	}
+}
+
+func TestListOnlineUserIDs(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+	now := time.Now()
+	recent, old := now.Add(-time.Minute), now.Add(-time.Hour)
+
+	records := []*models.TrafficRecord{
+		{UserID: 1, NodeID: 1, SessionID: "open", ConnectTime: old},
+		{UserID: 2, NodeID: 1, SessionID: "recent", ConnectTime: old, DisconnectTime: &recent},
+		{UserID: 3, NodeID: 1, SessionID: "old", ConnectTime: old, DisconnectTime: &old},
+		// Traffic without a session says nothing about connections
+		{UserID: 4, NodeID: 1, ConnectTime: now},
+	}
+	if err := repo.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+
+	online, err := repo.ListOnlineUserIDs(nil, now.Add(-5*time.Minute))
+	if err != nil {
+		t.Fatalf("list online users: %v", err)
+	}
+	slices.Sort(online)
+	if !slices.Equal(online, []uint{1, 2}) {
+		t.Errorf("online users = %v, want 1 and 2", online)
+	}
+
+	online, err = repo.ListOnlineUserIDs([]uint{2, 3}, now.Add(-5*time.Minute))
+	if err != nil {
+		t.Fatalf("list online users: %v", err)
+	}
+	if !slices.Equal(online, []uint{2}) {
+		t.Errorf("online users among 2 and 3 = %v, want 2", online)
+	}
+}
+
+func TestGetHourlyTraffic(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+
+	records := []*models.TrafficRecord{
+		{UserID: 1, NodeID: 1, RecordDate: day.Add(10 * time.Hour), RecordHour: 10, Upload: 10, Download: 20, Total: 30},
+		{UserID: 2, NodeID: 1, RecordDate: day.Add(10 * time.Hour), RecordHour: 10, Upload: 1, Download: 2, Total: 3},
+		{UserID: 1, NodeID: 2, RecordDate: day.Add(11 * time.Hour), RecordHour: 11, Upload: 5, Download: 5, Total: 10},
+	}
+	if err := repo.BatchCreateRecords(records); err != nil {
+		t.Fatalf("create records: %v", err)
+	}
+
+	summaries, err := repo.GetHourlyTraffic(day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("get hourly traffic: %v", err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("got %d summaries, want 2", len(summaries))
+	}
+	if summaries[1].TotalTraffic != 33 || summaries[1].TotalConnections != 2 {
+		t.Errorf("10:00 summary = %+v, want 33 bytes over 2 connections", summaries[1])
+	}
+	if y, m, d := summaries[0].SummaryDate.Date(); y != 2024 || m != 3 || d != 1 {
+		t.Errorf("summary date = %v, want 2024-03-01", summaries[0].SummaryDate)
+	}
+
+	summaries, err = repo.GetUserHourlyTraffic(1, day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("get user hourly traffic: %v", err)
+	}
+	if len(summaries) != 2 || summaries[0].TotalTraffic != 10 || summaries[1].TotalTraffic != 30 {
+		t.Errorf("user summaries = %+v, want 10 then 30 bytes", summaries)
+	}
+}
+
+func TestTotalDailySummaries(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTrafficRepository(db)
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	summaries := []*models.TrafficSummary{
+		{UserID: user.ID, NodeID: 1, SummaryDate: day, SummaryType: models.SummaryTypeDaily, TotalUpload: 10, TotalDownload: 20, TotalTraffic: 30, TotalConnections: 1},
+		{UserID: user.ID, NodeID: 2, SummaryDate: day.AddDate(0, 0, 1), SummaryType: models.SummaryTypeDaily, TotalUpload: 5, TotalDownload: 5, TotalTraffic: 10, TotalConnections: 2},
+		{UserID: 99, NodeID: 1, SummaryDate: day, SummaryType: models.SummaryTypeDaily, TotalUpload: 40, TotalDownload: 60, TotalTraffic: 100, TotalConnections: 1},
+		// Outside of the range
+		{UserID: user.ID, NodeID: 1, SummaryDate: day.AddDate(0, 0, 2), SummaryType: models.SummaryTypeDaily, TotalUpload: 1000, TotalTraffic: 1000},
+		// Monthly summaries repeat the daily ones
+		{UserID: user.ID, NodeID: 1, SummaryDate: day, SummaryType: models.SummaryTypeMonthly, TotalUpload: 1030, TotalTraffic: 1030},
+	}
+	for _, summary := range summaries {
+		if err := repo.CreateSummary(summary); err != nil {
+			t.Fatalf("create summary: %v", err)
+		}
+	}
+
+	totals, err := repo.TotalDailySummaries(models.TrafficTotalsByUser, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("total user summaries: %v", err)
+	}
+	if len(totals) != 2 {
+		t.Fatalf("got %d user totals, want 2", len(totals))
+	}
+	if totals[0].ID != 99 || totals[0].Name != "" {
+		t.Errorf("first total = %+v, want the deleted user 99", totals[0])
+	}
+	if got := totals[1]; got.Name != "alice" || got.Address != "alice@example.com" || got.Upload != 15 ||
+		got.Download != 25 || got.Total != 40 || got.Connections != 3 {
+		t.Errorf("alice total = %+v, want 15 up, 25 down, 40 bytes over 3 connections", got)
+	}
+
+	totals, err = repo.TotalDailySummaries(models.TrafficTotalsByNode, day, day)
+	if err != nil {
+		t.Fatalf("total node summaries: %v", err)
+	}
+	if len(totals) != 1 || totals[0].ID != 1 || totals[0].Total != 130 {
+		t.Errorf("node totals = %+v, want node 1 with 130 bytes", totals)
+	}
+
+	if _, err := repo.TotalDailySummaries("plan", day, day); err == nil {
+		t.Error("totals by plan succeeded, want an error")
+	}
+}
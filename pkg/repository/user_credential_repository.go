@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// UserCredentialRepository interface defines user credential rotation data access methods
+type UserCredentialRepository interface {
+	// Business operations
+	Regenerate(rotation *models.UserCredentialRotation) error
+	MarkPushed(rotation *models.UserCredentialRotation, propagated bool) error
+
+	// List operations
+	ListByUser(userID uint, offset, limit int) ([]*models.UserCredentialRotation, int64, error)
+	ListUnpropagated(limit int) ([]*models.UserCredentialRotation, error)
+}
+
+// userCredentialRepository implements UserCredentialRepository interface
+type userCredentialRepository struct {
+	db *gorm.DB
+}
+
+// NewUserCredentialRepository creates a new user credential repository
+func NewUserCredentialRepository(db *gorm.DB) UserCredentialRepository {
+	return &userCredentialRepository{db: db}
+}
+
+// Regenerate replaces the user's UUID with the rotation's new one and
+// records the replaced one in one transaction. Earlier rotations still
+// being pushed are superseded, pushing the user pushes the latest UUID.
+func (r *userCredentialRepository) Regenerate(rotation *models.UserCredentialRotation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id", "uuid").First(&user, rotation.UserID).Error; err != nil {
+			return err
+		}
+		rotation.OldUUID = user.UUID
+
+		err := tx.Model(&models.User{}).
+			Where("id = ?", rotation.UserID).
+			Update("uuid", rotation.NewUUID).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&models.UserCredentialRotation{}).
+			Where("user_id = ? AND propagated_at IS NULL", rotation.UserID).
+			Update("propagated_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(rotation).Error
+	})
+}
+
+// MarkPushed saves the nodes the new UUID of a rotation was pushed to, and
+// whether every node of the user has it now
+func (r *userCredentialRepository) MarkPushed(rotation *models.UserCredentialRotation, propagated bool) error {
+	if propagated {
+		now := time.Now()
+		rotation.PropagatedAt = &now
+	}
+	return r.db.Model(rotation).
+		Select("PushedNodeIDs", "PropagatedAt").
+		Updates(rotation).Error
+}
+
+// ListByUser gets the credential rotations of a user, newest first
+func (r *userCredentialRepository) ListByUser(userID uint, offset, limit int) ([]*models.UserCredentialRotation, int64, error) {
+	var rotations []*models.UserCredentialRotation
+	var total int64
+
+	query := r.db.Model(&models.UserCredentialRotation{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&rotations).Error
+	return rotations, total, err
+}
+
+// ListUnpropagated gets the rotations whose new UUID some nodes still miss,
+// oldest first
+func (r *userCredentialRepository) ListUnpropagated(limit int) ([]*models.UserCredentialRotation, error) {
+	var rotations []*models.UserCredentialRotation
+	err := r.db.Where("propagated_at IS NULL").
+		Order("id ASC").
+		Limit(limit).
+		Find(&rotations).Error
+	return rotations, err
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"testing"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestUserCredentialRepositoryRegenerate(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserCredentialRepository(db)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	oldUUID := user.UUID
+
+	first := &models.UserCredentialRotation{UserID: user.ID, NewUUID: "11111111-1111-1111-1111-111111111111", Operator: "admin"}
+	if err := repo.Regenerate(first); err != nil {
+		t.Fatalf("Regenerate: %v", err)
+	}
+	if first.OldUUID != oldUUID {
+		t.Errorf("OldUUID = %q, want %q", first.OldUUID, oldUUID)
+	}
+	var stored models.User
+	if err := db.First(&stored, user.ID).Error; err != nil || stored.UUID != first.NewUUID {
+		t.Fatalf("user UUID = %q, %v, want the new UUID", stored.UUID, err)
+	}
+
+	first.PushedNodeIDs = []uint{3}
+	if err := repo.MarkPushed(first, false); err != nil {
+		t.Fatalf("MarkPushed: %v", err)
+	}
+	pending, err := repo.ListUnpropagated(10)
+	if err != nil || len(pending) != 1 || !pending[0].Pushed(3) || pending[0].Pushed(4) {
+		t.Fatalf("ListUnpropagated = %+v, %v, want the first rotation pushed to node 3", pending, err)
+	}
+
+	// A later rotation supersedes the one still being pushed
+	second := &models.UserCredentialRotation{UserID: user.ID, NewUUID: "22222222-2222-2222-2222-222222222222", Operator: "admin"}
+	if err := repo.Regenerate(second); err != nil {
+		t.Fatalf("second Regenerate: %v", err)
+	}
+	if second.OldUUID != first.NewUUID {
+		t.Errorf("second OldUUID = %q, want %q", second.OldUUID, first.NewUUID)
+	}
+	pending, err = repo.ListUnpropagated(10)
+	if err != nil || len(pending) != 1 || pending[0].ID != second.ID {
+		t.Fatalf("ListUnpropagated = %+v, %v, want only the second rotation", pending, err)
+	}
+
+	if err := repo.MarkPushed(second, true); err != nil {
+		t.Fatalf("MarkPushed propagated: %v", err)
+	}
+	if pending, err = repo.ListUnpropagated(10); err != nil || len(pending) != 0 {
+		t.Errorf("ListUnpropagated = %+v, %v, want none", pending, err)
+	}
+
+	history, total, err := repo.ListByUser(user.ID, 0, 10)
+	if err != nil || total != 2 || history[0].ID != second.ID {
+		t.Errorf("ListByUser = %d rotations, %v, want both newest first", total, err)
+	}
+}
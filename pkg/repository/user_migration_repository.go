@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// ErrMigrationStateChanged is returned when a user migration is no longer in
+// the state a change expects
+var ErrMigrationStateChanged = errors.New("user migration state changed")
+
+// UserMigrationProgress is the outcome of a batch of a user migration
+type UserMigrationProgress struct {
+	LastUserID  uint
+	Moved       int64
+	Failed      int64
+	LastError   string
+	LastBatchAt time.Time
+	// Completed marks the migration completed, no user being left
+	Completed bool
+}
+
+// UserMigrationRepository interface defines user migration data access methods
+type UserMigrationRepository interface {
+	Create(migration *models.UserMigration) error
+	GetByID(id uint) (*models.UserMigration, error)
+	// List gets the migrations, all when status is empty, newest first
+	List(status models.UserMigrationStatus, offset, limit int) ([]*models.UserMigration, int64, error)
+	ListRunning() ([]*models.UserMigration, error)
+	// FindUnfinished gets a running or paused migration of a source, nil when
+	// there is none
+	FindUnfinished(kind models.UserMigrationKind, sourceID uint) (*models.UserMigration, error)
+
+	// CountUsers counts the users of a source
+	CountUsers(kind models.UserMigrationKind, sourceID uint) (int64, error)
+	// NextUsers gets up to limit users of a migration's source after its
+	// cursor, in ID order
+	NextUsers(migration *models.UserMigration, limit int) ([]*models.User, error)
+	// MovePlan switches a user from one plan to another, taking the limits
+	// of the new plan but keeping the usage and expiry
+	MovePlan(user *models.User, from uint, to *models.Plan) error
+	// MoveNode moves a user's assignment from one node to another, returning
+	// ErrMigrationStateChanged when the user is no longer on the first
+	MoveNode(userID, from, to uint) error
+
+	// SetStatus changes the status of a migration in one of the from states,
+	// returning ErrMigrationStateChanged otherwise. A rate above zero
+	// replaces the migration's.
+	SetStatus(migration *models.UserMigration, from []models.UserMigrationStatus, to models.UserMigrationStatus, usersPerMinute int) error
+	// RecordProgress adds the outcome of a batch to a migration
+	RecordProgress(id uint, progress UserMigrationProgress) error
+}
+
+// userMigrationRepository implements UserMigrationRepository interface
+type userMigrationRepository struct {
+	db *gorm.DB
+}
+
+// NewUserMigrationRepository creates a new user migration repository
+func NewUserMigrationRepository(db *gorm.DB) UserMigrationRepository {
+	return &userMigrationRepository{db: db}
+}
+
+// Create creates a new user migration
+func (r *userMigrationRepository) Create(migration *models.UserMigration) error {
+	return r.db.Create(migration).Error
+}
+
+// GetByID gets a user migration by ID
+func (r *userMigrationRepository) GetByID(id uint) (*models.UserMigration, error) {
+	var migration models.UserMigration
+	if err := r.db.First(&migration, id).Error; err != nil {
+		return nil, err
+	}
+	return &migration, nil
+}
+
+// List gets the user migrations, newest first
+func (r *userMigrationRepository) List(status models.UserMigrationStatus, offset, limit int) ([]*models.UserMigration, int64, error) {
+	var migrations []*models.UserMigration
+	var total int64
+
+	query := r.db.Model(&models.UserMigration{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&migrations).Error
+	return migrations, total, err
+}
+
+// ListRunning gets the running user migrations, oldest first
+func (r *userMigrationRepository) ListRunning() ([]*models.UserMigration, error) {
+	var migrations []*models.UserMigration
+	err := r.db.Where("status = ?", models.UserMigrationStatusRunning).Order("id ASC").Find(&migrations).Error
+	return migrations, err
+}
+
+// FindUnfinished gets a running or paused migration of a source
+func (r *userMigrationRepository) FindUnfinished(kind models.UserMigrationKind, sourceID uint) (*models.UserMigration, error) {
+	var migrations []*models.UserMigration
+	err := r.db.Where("kind = ? AND source_id = ? AND status IN ?", kind, sourceID,
+		[]models.UserMigrationStatus{models.UserMigrationStatusRunning, models.UserMigrationStatusPaused}).
+		Limit(1).
+		Find(&migrations).Error
+	if err != nil || len(migrations) == 0 {
+		return nil, err
+	}
+	return migrations[0], nil
+}
+
+// sourceUsers returns the query of the users of a source
+func (r *userMigrationRepository) sourceUsers(kind models.UserMigrationKind, sourceID uint) *gorm.DB {
+	query := r.db.Model(&models.User{})
+	if kind == models.UserMigrationKindPlan {
+		return query.Where("plan_id = ?", sourceID)
+	}
+	return query.Where("id IN (?)", r.db.Model(&models.UserNode{}).Select("user_id").Where("node_id = ?", sourceID))
+}
+
+// CountUsers counts the users of a source
+func (r *userMigrationRepository) CountUsers(kind models.UserMigrationKind, sourceID uint) (int64, error) {
+	var count int64
+	err := r.sourceUsers(kind, sourceID).Count(&count).Error
+	return count, err
+}
+
+// NextUsers gets the next users of a migration's source
+func (r *userMigrationRepository) NextUsers(migration *models.UserMigration, limit int) ([]*models.User, error) {
+	var users []*models.User
+	err := r.sourceUsers(migration.Kind, migration.SourceID).
+		Where("id > ?", migration.LastUserID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// MovePlan switches a user to another plan and moves the plan user counts
+func (r *userMigrationRepository) MovePlan(user *models.User, from uint, to *models.Plan) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.User{}).
+			Where("id = ? AND plan_id = ?", user.ID, from).
+			Updates(map[string]interface{}{
+				"plan_id":       to.ID,
+				"traffic_quota": to.TrafficQuota,
+				"speed_limit":   to.SpeedLimit,
+				"device_limit":  to.DeviceLimit,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrMigrationStateChanged
+		}
+
+		if err := tx.Model(&models.Plan{}).
+			Where("id = ? AND current_users > 0", from).
+			UpdateColumn("current_users", gorm.Expr("current_users - 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Plan{}).
+			Where("id = ?", to.ID).
+			UpdateColumn("current_users", gorm.Expr("current_users + 1")).Error; err != nil {
+			return err
+		}
+
+		user.PlanID = to.ID
+		user.TrafficQuota = to.TrafficQuota
+		user.SpeedLimit = to.SpeedLimit
+		user.DeviceLimit = to.DeviceLimit
+		return nil
+	})
+}
+
+// MoveNode moves a user's assignment to another node. The assignment keeps
+// its priority and enabled state; a user already on the target node only
+// loses the source assignment.
+func (r *userMigrationRepository) MoveNode(userID, from, to uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var assignment models.UserNode
+		err := tx.Where("user_id = ? AND node_id = ?", userID, from).First(&assignment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMigrationStateChanged
+		}
+		if err != nil {
+			return err
+		}
+
+		var existing int64
+		if err := tx.Model(&models.UserNode{}).Where("user_id = ? AND node_id = ?", userID, to).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return tx.Delete(&assignment).Error
+		}
+		return tx.Model(&assignment).Update("node_id", to).Error
+	})
+}
+
+// SetStatus changes the status of a migration in one of the from states.
+// Starting a migration again restarts the pacing, so that the users not
+// moved while paused are not due at once.
+func (r *userMigrationRepository) SetStatus(migration *models.UserMigration, from []models.UserMigrationStatus, to models.UserMigrationStatus, usersPerMinute int) error {
+	now := time.Now()
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.UserMigrationStatusRunning:
+		updates["last_batch_at"] = now
+	case models.UserMigrationStatusCompleted, models.UserMigrationStatusCancelled:
+		updates["completed_at"] = now
+	}
+	if usersPerMinute > 0 {
+		updates["users_per_minute"] = usersPerMinute
+	}
+
+	res := r.db.Model(&models.UserMigration{}).
+		Where("id = ? AND status IN ?", migration.ID, from).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrMigrationStateChanged
+	}
+
+	migration.Status = to
+	switch to {
+	case models.UserMigrationStatusRunning:
+		migration.LastBatchAt = &now
+	case models.UserMigrationStatusCompleted, models.UserMigrationStatusCancelled:
+		migration.CompletedAt = &now
+	}
+	if usersPerMinute > 0 {
+		migration.UsersPerMinute = usersPerMinute
+	}
+	return nil
+}
+
+// RecordProgress adds the outcome of a batch to a migration. A migration
+// paused or cancelled during the batch keeps its status.
+func (r *userMigrationRepository) RecordProgress(id uint, progress UserMigrationProgress) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"last_user_id":  progress.LastUserID,
+			"moved":         gorm.Expr("moved + ?", progress.Moved),
+			"failed":        gorm.Expr("failed + ?", progress.Failed),
+			"last_batch_at": progress.LastBatchAt,
+		}
+		if progress.LastError != "" {
+			updates["last_error"] = progress.LastError
+		}
+		if err := tx.Model(&models.UserMigration{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return err
+		}
+		if !progress.Completed {
+			return nil
+		}
+		return tx.Model(&models.UserMigration{}).
+			Where("id = ? AND status = ?", id, models.UserMigrationStatusRunning).
+			Updates(map[string]interface{}{
+				"status":       models.UserMigrationStatusCompleted,
+				"completed_at": progress.LastBatchAt,
+			}).Error
+	})
+}
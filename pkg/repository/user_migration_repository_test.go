@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestUserMigrationRepositoryPlan(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserMigrationRepository(db)
+
+	from := &models.Plan{Name: "Basic", TrafficQuota: 10 << 30, CurrentUsers: 3}
+	to := &models.Plan{Name: "Pro", TrafficQuota: 100 << 30, SpeedLimit: 1 << 20, DeviceLimit: 5}
+	for _, plan := range []*models.Plan{from, to} {
+		if err := db.Create(plan).Error; err != nil {
+			t.Fatalf("create plan: %v", err)
+		}
+	}
+	var users []*models.User
+	for i := range 3 {
+		user := &models.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "x", PlanID: from.ID, TrafficUsed: 42}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	if count, err := repo.CountUsers(models.UserMigrationKindPlan, from.ID); err != nil || count != 3 {
+		t.Fatalf("CountUsers = %d, %v, want 3", count, err)
+	}
+	migration := &models.UserMigration{Kind: models.UserMigrationKindPlan, SourceID: from.ID, TargetID: to.ID,
+		UsersPerMinute: 2, Status: models.UserMigrationStatusRunning, Total: 3}
+	if err := repo.Create(migration); err != nil {
+		t.Fatalf("create migration: %v", err)
+	}
+	if found, err := repo.FindUnfinished(models.UserMigrationKindPlan, from.ID); err != nil || found == nil || found.ID != migration.ID {
+		t.Errorf("FindUnfinished = %v, %v, want the migration", found, err)
+	}
+
+	batch, err := repo.NextUsers(migration, 2)
+	if err != nil || len(batch) != 2 || batch[0].ID != users[0].ID {
+		t.Fatalf("NextUsers = %v, %v, want the first two users", batch, err)
+	}
+	for _, user := range batch {
+		if err := repo.MovePlan(user, from.ID, to); err != nil {
+			t.Fatalf("MovePlan: %v", err)
+		}
+	}
+	if err := repo.MovePlan(batch[0], from.ID, to); !errors.Is(err, ErrMigrationStateChanged) {
+		t.Errorf("moving a moved user = %v, want ErrMigrationStateChanged", err)
+	}
+	var moved models.User
+	db.First(&moved, users[0].ID)
+	if moved.PlanID != to.ID || moved.TrafficQuota != to.TrafficQuota || moved.DeviceLimit != 5 || moved.TrafficUsed != 42 {
+		t.Errorf("moved user = %+v, want the new plan limits and the usage kept", moved)
+	}
+	db.First(from, from.ID)
+	db.First(to, to.ID)
+	if from.CurrentUsers != 1 || to.CurrentUsers != 2 {
+		t.Errorf("plan users = %d, %d, want 1, 2", from.CurrentUsers, to.CurrentUsers)
+	}
+
+	now := time.Now()
+	if err := repo.RecordProgress(migration.ID, UserMigrationProgress{LastUserID: batch[1].ID, Moved: 2, LastBatchAt: now}); err != nil {
+		t.Fatalf("RecordProgress: %v", err)
+	}
+	migration, _ = repo.GetByID(migration.ID)
+	if migration.Moved != 2 || migration.LastUserID != batch[1].ID {
+		t.Errorf("migration = %+v, want 2 moved", migration)
+	}
+
+	// Pausing stops the migration from being listed as running
+	if err := repo.SetStatus(migration, []models.UserMigrationStatus{models.UserMigrationStatusRunning}, models.UserMigrationStatusPaused, 0); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if running, _ := repo.ListRunning(); len(running) != 0 {
+		t.Errorf("running migrations = %d, want none", len(running))
+	}
+	if err := repo.SetStatus(migration, []models.UserMigrationStatus{models.UserMigrationStatusRunning}, models.UserMigrationStatusPaused, 0); !errors.Is(err, ErrMigrationStateChanged) {
+		t.Errorf("pausing twice = %v, want ErrMigrationStateChanged", err)
+	}
+	if err := repo.SetStatus(migration, []models.UserMigrationStatus{models.UserMigrationStatusPaused}, models.UserMigrationStatusRunning, 5); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+
+	rest, err := repo.NextUsers(migration, 2)
+	if err != nil || len(rest) != 1 || rest[0].ID != users[2].ID {
+		t.Fatalf("NextUsers after the cursor = %v, %v, want the last user", rest, err)
+	}
+	if err := repo.RecordProgress(migration.ID, UserMigrationProgress{LastUserID: users[2].ID, Failed: 1, LastError: "boom", LastBatchAt: now, Completed: true}); err != nil {
+		t.Fatalf("RecordProgress: %v", err)
+	}
+	migration, _ = repo.GetByID(migration.ID)
+	if migration.Status != models.UserMigrationStatusCompleted || migration.Failed != 1 || migration.UsersPerMinute != 5 || migration.CompletedAt == nil {
+		t.Errorf("migration = %+v, want completed with one failure at the new rate", migration)
+	}
+	if found, _ := repo.FindUnfinished(models.UserMigrationKindPlan, from.ID); found != nil {
+		t.Errorf("FindUnfinished = %v, want nil once completed", found)
+	}
+}
+
+func TestUserMigrationRepositoryNode(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserMigrationRepository(db)
+
+	from := &models.Node{Name: "from", Type: models.NodeTypeVLESS, Host: "192.0.2.1", Port: 443}
+	to := &models.Node{Name: "to", Type: models.NodeTypeVLESS, Host: "192.0.2.2", Port: 443}
+	for _, node := range []*models.Node{from, to} {
+		if err := db.Create(node).Error; err != nil {
+			t.Fatalf("create node: %v", err)
+		}
+	}
+	var users []*models.User
+	for i := range 2 {
+		user := &models.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Password: "x"}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if err := db.Create(&models.UserNode{UserID: user.ID, NodeID: from.ID, IsEnabled: true, Priority: 3}).Error; err != nil {
+			t.Fatalf("assign user: %v", err)
+		}
+		users = append(users, user)
+	}
+	// The second user is on the target node already
+	if err := db.Create(&models.UserNode{UserID: users[1].ID, NodeID: to.ID, IsEnabled: true}).Error; err != nil {
+		t.Fatalf("assign user: %v", err)
+	}
+
+	if count, err := repo.CountUsers(models.UserMigrationKindNode, from.ID); err != nil || count != 2 {
+		t.Fatalf("CountUsers = %d, %v, want 2", count, err)
+	}
+	for _, user := range users {
+		if err := repo.MoveNode(user.ID, from.ID, to.ID); err != nil {
+			t.Fatalf("MoveNode: %v", err)
+		}
+	}
+	if err := repo.MoveNode(users[0].ID, from.ID, to.ID); !errors.Is(err, ErrMigrationStateChanged) {
+		t.Errorf("moving a moved user = %v, want ErrMigrationStateChanged", err)
+	}
+
+	if count, _ := repo.CountUsers(models.UserMigrationKindNode, from.ID); count != 0 {
+		t.Errorf("users left on the source node = %d", count)
+	}
+	for _, user := range users {
+		if nodeIDs := enabledNodeIDs(t, db, user.ID); len(nodeIDs) != 1 || nodeIDs[0] != to.ID {
+			t.Errorf("user %d nodes = %v, want only the target", user.ID, nodeIDs)
+		}
+	}
+	var assignment models.UserNode
+	db.Where("user_id = ? AND node_id = ?", users[0].ID, to.ID).First(&assignment)
+	if assignment.Priority != 3 {
+		t.Errorf("moved assignment priority = %d, want it kept", assignment.Priority)
+	}
+}
@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -25,6 +28,8 @@ type UserRepository interface {
 	ListByPlanID(planID uint, offset, limit int) ([]*models.User, int64, error)
 	ListByStatus(status models.UserStatus, offset, limit int) ([]*models.User, int64, error)
 	Search(query string, offset, limit int) ([]*models.User, int64, error)
+	// ListFiltered gets users matching every set field of the filter
+	ListFiltered(filter UserListFilter, offset, limit int) ([]*models.User, int64, error)
 	
 	// Business operations
 	UpdateTrafficUsage(userID uint, upload, download int64) error
@@ -36,6 +41,58 @@ type UserRepository interface {
 	LockUser(userID uint, until time.Time) error
 	UnlockUser(userID uint) error
 	
+	// Two-factor authentication
+	SetTwoFactorSecret(userID uint, secret string) error
+	EnableTwoFactor(userID uint, backupCodes []string) error
+	DisableTwoFactor(userID uint) error
+	// ReplaceBackupCodes stores the hashed backup codes left after one was
+	// used unless the stored ones are no longer old, false then
+	ReplaceBackupCodes(userID uint, old, backupCodes []string) (bool, error)
+	// UseTwoFactorStep stores the time step of an accepted TOTP code unless
+	// a code of this step or a later one was accepted, false then
+	UseTwoFactorStep(userID uint, step int64) (bool, error)
+	
+	// Account recovery
+	// ReplacePassword sets a new password hash if the current one is still
+	// oldHash, and clears failed login attempts and the lock
+	ReplacePassword(userID uint, oldHash, newHash string) (bool, error)
+	// MarkEmailVerified marks the email verified if it is still email
+	MarkEmailVerified(userID uint, email string) (bool, error)
+	
+	// Subscription
+	UpdateSubscriptionHash(userID uint, hash string) error
+
+	// Alerts
+	UpdateTelegramChatID(userID uint, chatID string) error
+
+	// Inactive account policy
+	// ListInactive gets the users idle since before idleBefore that are
+	// active or suspended by the policy, of free plans only when freeOnly
+	ListInactive(idleBefore time.Time, freeOnly bool) ([]*models.User, error)
+	MarkInactivityWarned(userID uint, at time.Time) error
+	// SuspendForInactivity suspends the user when still active
+	SuspendForInactivity(userID uint, at time.Time) (bool, error)
+	// ReactivateInactive reactivates the user when suspended by the policy
+	ReactivateInactive(userID uint) error
+	SetInactivityExempt(userID uint, exempt bool) error
+
+	// Trials
+	// ListTrialsEndingBefore gets the users whose trial ends before a time
+	ListTrialsEndingBefore(before time.Time) ([]*models.User, error)
+	// EndTrial ends the trial of the user when due, moving it to downgrade
+	// or expiring it when downgrade is nil
+	EndTrial(userID uint, downgrade *models.Plan, now time.Time) (bool, error)
+
+	// Account expiry
+	// ListExpired gets the active users whose plan ran out
+	ListExpired(now time.Time) ([]*models.User, error)
+	// Expire expires the user when still active and its plan ran out
+	Expire(userID uint, now time.Time) (bool, error)
+	// ListRenewed gets the expired users that are active again
+	ListRenewed(now time.Time) ([]*models.User, error)
+	// MarkRestored records that the user is back on its nodes
+	MarkRestored(userID uint) (bool, error)
+	
 	// Statistics
 	GetUserCount() (int64, error)
 	GetActiveUserCount() (int64, error)
@@ -45,10 +102,39 @@ type UserRepository interface {
 	// Batch operations
 	BatchUpdateStatus(userIDs []uint, status models.UserStatus) error
 	BatchDelete(userIDs []uint) error
+	BatchAddTrafficUsage(usage map[uint]int64) error
+	GetOverQuota(userIDs []uint) ([]*models.User, error)
+	GetNearQuota(userIDs []uint, percent int) ([]*models.User, error)
+	ListExpiring(from, to time.Time) ([]*models.User, error)
+	FilterActiveIDs(userIDs []uint) ([]uint, error)
 	
 	// Statistics
 	GetSystemStats() (*models.SystemStats, error)
 	UpdateStatus(userID uint, status models.UserStatus) error
+
+	// Admins
+	// CountActiveByRole counts the active users of a role
+	CountActiveByRole(role models.UserRole) (int64, error)
+	// PromoteLegacyAdmins makes the admins without permissions super admins
+	// while there is no super admin, as every admin was one before
+	// permissions existed
+	PromoteLegacyAdmins() (int64, error)
+}
+
+// UserSortFields are the columns user listings can be sorted by
+var UserSortFields = []string{"id", "username", "email", "status", "created_at", "expires_at", "traffic_used", "last_login_at"}
+
+// UserListFilter narrows and orders a user listing, empty fields match every
+// user
+type UserListFilter struct {
+	Status models.UserStatus
+	// Keyword matches the username, email or display name
+	Keyword string
+	Roles   []models.UserRole
+	PlanID  uint
+	// SortBy is one of UserSortFields, the newest users first when empty
+	SortBy string
+	Desc   bool
 }
 
 // userRepository implements UserRepository interface
@@ -190,15 +276,15 @@ func (r *userRepository) ListByStatus(status models.UserStatus, offset, limit in
 	return users, total, err
 }
 
-// Search searches users by username, email, or display name
+// Search searches users by username, email, display name, or UUID
 func (r *userRepository) Search(query string, offset, limit int) ([]*models.User, int64, error) {
 	var users []*models.User
 	var total int64
 	
 	searchQuery := "%" + query + "%"
 	dbQuery := r.db.Model(&models.User{}).Where(
-		"username LIKE ? OR email LIKE ? OR display_name LIKE ?",
-		searchQuery, searchQuery, searchQuery,
+		"username LIKE ? OR email LIKE ? OR display_name LIKE ? OR uuid LIKE ?",
+		searchQuery, searchQuery, searchQuery, searchQuery,
 	)
 	
 	// Get total count
@@ -254,6 +340,7 @@ func (r *userRepository) UpdateLastLogin(userID uint, ip string) error {
 		Updates(map[string]interface{}{
 			"last_login_at": now,
 			"last_login_ip": ip,
+			"last_activity_at": now,
 			"login_attempts": 0, // Reset login attempts on successful login
 		}).Error
 }
@@ -290,6 +377,255 @@ func (r *userRepository) UnlockUser(userID uint) error {
 		Error
 }
 
+// SetTwoFactorSecret stores a pending TOTP secret; 2FA stays disabled until confirmed
+func (r *userRepository) SetTwoFactorSecret(userID uint, secret string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"two_factor_secret":  secret,
+			"two_factor_enabled": false,
+		}).Error
+}
+
+// EnableTwoFactor enables 2FA and stores the hashed backup codes
+func (r *userRepository) EnableTwoFactor(userID uint, backupCodes []string) error {
+	now := time.Now()
+	return r.db.Model(&models.User{ID: userID}).
+		Select("two_factor_enabled", "two_factor_backup_codes", "two_factor_enabled_at").
+		Updates(&models.User{
+			TwoFactorEnabled:     true,
+			TwoFactorBackupCodes: backupCodes,
+			TwoFactorEnabledAt:   &now,
+		}).Error
+}
+
+// DisableTwoFactor disables 2FA and clears the secret and backup codes
+func (r *userRepository) DisableTwoFactor(userID uint) error {
+	return r.db.Model(&models.User{ID: userID}).
+		Select("two_factor_enabled", "two_factor_secret", "two_factor_backup_codes", "two_factor_enabled_at").
+		Updates(&models.User{}).Error
+}
+
+// ReplaceBackupCodes swaps the stored hashed backup codes unless they changed
+// in the meantime, so that a backup code used by concurrent logins is only
+// accepted once
+func (r *userRepository) ReplaceBackupCodes(userID uint, old, backupCodes []string) (bool, error) {
+	// Compared in the JSON form the serializer of the column stores
+	encoded, err := json.Marshal(old)
+	if err != nil {
+		return false, err
+	}
+	result := r.db.Model(&models.User{ID: userID}).
+		Where("two_factor_backup_codes = ?", string(encoded)).
+		Select("two_factor_backup_codes").
+		Updates(&models.User{TwoFactorBackupCodes: backupCodes})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UseTwoFactorStep stores the time step of an accepted TOTP code, unless one
+// of this step or a later one was accepted
+func (r *userRepository) UseTwoFactorStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", userID, step).
+		Update("two_factor_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReplacePassword swaps the password hash unless it changed in the meantime
+func (r *userRepository) ReplacePassword(userID uint, oldHash, newHash string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND password = ?", userID, oldHash).
+		Updates(map[string]interface{}{
+			"password":       newHash,
+			"login_attempts": 0,
+			"locked_until":   nil,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkEmailVerified marks the email verified unless it changed in the meantime
+func (r *userRepository) MarkEmailVerified(userID uint, email string) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND email = ?", userID, email).
+		Updates(map[string]interface{}{
+			"email_verified":    true,
+			"email_verified_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// UpdateSubscriptionHash records a changed subscription content hash
+func (r *userRepository) UpdateSubscriptionHash(userID uint, hash string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"subscription_hash":       hash,
+			"subscription_updated_at": time.Now(),
+		}).Error
+}
+
+// UpdateTelegramChatID links the Telegram chat receiving the user's alerts,
+// an empty chatID unlinks it
+func (r *userRepository) UpdateTelegramChatID(userID uint, chatID string) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("telegram_chat_id", chatID).Error
+}
+
+// ListInactive gets the users idle since before idleBefore that are active
+// or suspended by the policy, of free plans only when freeOnly. Admins and
+// exempt users are left out.
+func (r *userRepository) ListInactive(idleBefore time.Time, freeOnly bool) ([]*models.User, error) {
+	query := r.db.Where("role = ? AND inactivity_exempt = ?", models.UserRoleUser, false).
+		Where("status = ? OR (status = ? AND inactivity_suspended_at IS NOT NULL)", models.UserStatusActive, models.UserStatusSuspended).
+		Where("COALESCE(last_activity_at, created_at) < ?", idleBefore)
+	if freeOnly {
+		query = query.Where("plan_id IN (?)", r.db.Model(&models.Plan{}).Select("id").Where("price = 0"))
+	}
+
+	var users []*models.User
+	err := query.Order("id").Find(&users).Error
+	return users, err
+}
+
+// MarkInactivityWarned records that the user was warned of the suspension
+func (r *userRepository) MarkInactivityWarned(userID uint, at time.Time) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("inactivity_warned_at", at).Error
+}
+
+// SuspendForInactivity suspends the user when still active, reporting
+// whether it did
+func (r *userRepository) SuspendForInactivity(userID uint, at time.Time) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND status = ?", userID, models.UserStatusActive).
+		Updates(map[string]interface{}{
+			"status":                  models.UserStatusSuspended,
+			"inactivity_suspended_at": at,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReactivateInactive reactivates the user when suspended by the policy, an
+// activity that starts a new idle stretch
+func (r *userRepository) ReactivateInactive(userID uint) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ? AND status = ? AND inactivity_suspended_at IS NOT NULL", userID, models.UserStatusSuspended).
+		Updates(map[string]interface{}{
+			"status":                  models.UserStatusActive,
+			"inactivity_suspended_at": nil,
+			"last_activity_at":        time.Now(),
+		}).Error
+}
+
+// SetInactivityExempt exempts the user from the inactive account policy or
+// subjects it again
+func (r *userRepository) SetInactivityExempt(userID uint, exempt bool) error {
+	return r.db.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("inactivity_exempt", exempt).Error
+}
+
+// ListTrialsEndingBefore gets the users whose trial ends before a time,
+// the trials ending first first
+func (r *userRepository) ListTrialsEndingBefore(before time.Time) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.Where("trial_ends_at IS NOT NULL AND trial_ends_at < ?", before).
+		Order("trial_ends_at, id").
+		Find(&users).Error
+	return users, err
+}
+
+// EndTrial ends the trial of the user when it is still due at now, reporting
+// whether it did. A user that upgraded meanwhile is no longer on trial. With
+// a downgrade plan the user moves to it, otherwise an active user expires.
+func (r *userRepository) EndTrial(userID uint, downgrade *models.Plan, now time.Time) (bool, error) {
+	ended := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		err := tx.Where("id = ? AND trial_ends_at IS NOT NULL AND trial_ends_at <= ?", userID, now).
+			First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ended = true
+
+		if downgrade == nil {
+			updates := map[string]interface{}{"trial_ends_at": nil}
+			if user.Status == models.UserStatusActive {
+				updates["status"] = models.UserStatusExpired
+				updates["expired_at"] = now
+			}
+			return tx.Model(&user).Updates(updates).Error
+		}
+
+		previousPlanID := user.PlanID
+		user.ApplyPlan(downgrade, false, now)
+		err = tx.Model(&user).
+			Select("plan_id", "traffic_quota", "speed_limit", "device_limit", "expires_at",
+				"traffic_used", "traffic_reset_date", "status", "trial_ends_at").
+			Updates(&user).Error
+		if err != nil || previousPlanID == downgrade.ID {
+			return err
+		}
+		err = tx.Model(&models.Plan{}).
+			Where("id = ?", downgrade.ID).
+			UpdateColumn("current_users", gorm.Expr("current_users + 1")).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.Plan{}).
+			Where("id = ? AND current_users > 0", previousPlanID).
+			UpdateColumn("current_users", gorm.Expr("current_users - 1")).Error
+	})
+	return ended, err
+}
+
+// ListExpired gets the active users whose plan ran out at now
+func (r *userRepository) ListExpired(now time.Time) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.Where("status = ? AND expires_at <= ?", models.UserStatusActive, now).
+		Order("expires_at, id").
+		Find(&users).Error
+	return users, err
+}
+
+// Expire expires the user when it is still active and its plan ran out at
+// now, reporting whether it did. A renewal meanwhile keeps it active.
+func (r *userRepository) Expire(userID uint, now time.Time) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND status = ? AND expires_at <= ?", userID, models.UserStatusActive, now).
+		Updates(map[string]interface{}{
+			"status":     models.UserStatusExpired,
+			"expired_at": now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListRenewed gets the users that expired and are active again with a plan
+// running at now, such as after a renewal
+func (r *userRepository) ListRenewed(now time.Time) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.Where("expired_at IS NOT NULL AND status = ?", models.UserStatusActive).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("id").
+		Find(&users).Error
+	return users, err
+}
+
+// MarkRestored records that the user was restored to its nodes, reporting
+// whether it was expired until now
+func (r *userRepository) MarkRestored(userID uint) (bool, error) {
+	result := r.db.Model(&models.User{}).
+		Where("id = ? AND expired_at IS NOT NULL", userID).
+		Update("expired_at", nil)
+	return result.RowsAffected > 0, result.Error
+}
+
 // GetUserCount gets total user count
 func (r *userRepository) GetUserCount() (int64, error) {
 	var count int64
@@ -339,6 +675,114 @@ func (r *userRepository) BatchDelete(userIDs []uint) error {
 	return r.db.Delete(&models.User{}, userIDs).Error
 }
 
+// BatchAddTrafficUsage adds traffic to many users with a single UPDATE,
+// recording it as their latest activity
+func (r *userRepository) BatchAddTrafficUsage(usage map[uint]int64) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(usage))
+	args := make([]interface{}, 0, len(usage)*2)
+	var cases strings.Builder
+	cases.WriteString("traffic_used + CASE id")
+	for id, bytes := range usage {
+		cases.WriteString(" WHEN ? THEN ?")
+		ids = append(ids, id)
+		args = append(args, id, bytes)
+	}
+	cases.WriteString(" ELSE 0 END")
+
+	now := time.Now()
+	return r.db.Model(&models.User{}).
+		Where("id IN ?", ids).
+		UpdateColumns(map[string]interface{}{
+			"traffic_used":     gorm.Expr(cases.String(), args...),
+			"last_traffic_at":  now,
+			"last_activity_at": now,
+		}).
+		Error
+}
+
+// GetOverQuota gets the users among userIDs whose traffic exceeds their quota
+func (r *userRepository) GetOverQuota(userIDs []uint) ([]*models.User, error) {
+	var users []*models.User
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ? AND traffic_quota > 0 AND traffic_used > traffic_quota", userIDs).
+		Find(&users).Error
+	return users, err
+}
+
+// GetNearQuota gets the users among userIDs that used at least percent of their quota
+func (r *userRepository) GetNearQuota(userIDs []uint, percent int) ([]*models.User, error) {
+	var users []*models.User
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ? AND traffic_quota > 0 AND traffic_used * 100 >= traffic_quota * ?", userIDs, percent).
+		Find(&users).Error
+	return users, err
+}
+
+// ListFiltered gets users matching every set field of the filter, newest first
+func (r *userRepository) ListFiltered(filter UserListFilter, offset, limit int) ([]*models.User, int64, error) {
+	var users []*models.User
+	var total int64
+
+	query := r.db.Model(&models.User{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Keyword != "" {
+		keyword := "%" + filter.Keyword + "%"
+		query = query.Where("username LIKE ? OR email LIKE ? OR display_name LIKE ?", keyword, keyword, keyword)
+	}
+	if len(filter.Roles) > 0 {
+		query = query.Where("role IN ?", filter.Roles)
+	}
+	if filter.PlanID != 0 {
+		query = query.Where("plan_id = ?", filter.PlanID)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "created_at DESC"
+	if filter.SortBy != "" {
+		order = sortOrder(filter.SortBy, filter.Desc)
+	}
+	err := query.Preload("Plan").
+		Offset(offset).
+		Limit(limit).
+		Order(order).
+		Find(&users).Error
+
+	return users, total, err
+}
+
+// ListExpiring gets the active users whose account expires after from and no later than to
+func (r *userRepository) ListExpiring(from, to time.Time) ([]*models.User, error) {
+	var users []*models.User
+	err := r.db.Where("status = ? AND expires_at > ? AND expires_at <= ?", models.UserStatusActive, from, to).
+		Find(&users).Error
+	return users, err
+}
+
+// FilterActiveIDs returns the IDs among userIDs of users that exist and are active
+func (r *userRepository) FilterActiveIDs(userIDs []uint) ([]uint, error) {
+	var ids []uint
+	if len(userIDs) == 0 {
+		return ids, nil
+	}
+	err := r.db.Model(&models.User{}).
+		Where("id IN ? AND status = ?", userIDs, models.UserStatusActive).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
 // GetSystemStats gets system statistics
 func (r *userRepository) GetSystemStats() (*models.SystemStats, error) {
 	var stats models.SystemStats
@@ -366,4 +810,34 @@ func (r *userRepository) UpdateStatus(userID uint, status models.UserStatus) err
 		Where("id = ?", userID).
 		Update("status", status).
 		Error
-}
\ No newline at end of file
+}
+
+// CountActiveByRole counts the active users of a role
+func (r *userRepository) CountActiveByRole(role models.UserRole) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.User{}).
+		Where("role = ? AND status = ?", role, models.UserStatusActive).
+		Count(&count).Error
+	return count, err
+}
+
+// PromoteLegacyAdmins makes the admins without permissions super admins
+// while there is no super admin
+func (r *userRepository) PromoteLegacyAdmins() (int64, error) {
+	var promoted int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var superAdmins int64
+		if err := tx.Model(&models.User{}).Where("role = ?", models.UserRoleSuperAdmin).Count(&superAdmins).Error; err != nil {
+			return err
+		}
+		if superAdmins > 0 {
+			return nil
+		}
+		result := tx.Model(&models.User{}).
+			Where("role = ? AND (admin_permissions IS NULL OR admin_permissions IN ?)", models.UserRoleAdmin, []string{"", "null", "[]"}).
+			Update("role", models.UserRoleSuperAdmin)
+		promoted = result.RowsAffected
+		return result.Error
+	})
+	return promoted, err
+}
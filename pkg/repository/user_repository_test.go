@@ -0,0 +1,415 @@
+package repository
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestUserListFiltered(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	users := []*models.User{
+		{Username: "alice", Email: "alice@example.com", Status: models.UserStatusActive},
+		{Username: "bob", Email: "bob@example.com", Status: models.UserStatusSuspended, PlanID: 7},
+		{Username: "carol", Email: "carol@alice.example", Status: models.UserStatusSuspended},
+	}
+	for _, user := range users {
+		user.Password = "x"
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter UserListFilter
+		want   []string
+	}{
+		{"no filter", UserListFilter{}, []string{"alice", "bob", "carol"}},
+		{"status", UserListFilter{Status: models.UserStatusSuspended}, []string{"bob", "carol"}},
+		{"keyword", UserListFilter{Keyword: "alice"}, []string{"alice", "carol"}},
+		// The keyword alternatives must not escape the status condition
+		{"status and keyword", UserListFilter{Status: models.UserStatusSuspended, Keyword: "alice"}, []string{"carol"}},
+		{"plan", UserListFilter{PlanID: 7}, []string{"bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := repo.ListFiltered(tt.filter, 0, 10)
+			if err != nil {
+				t.Fatalf("list users: %v", err)
+			}
+			names := make([]string, len(got))
+			for i, user := range got {
+				names[i] = user.Username
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("users = %v (total %d), want %v", names, total, tt.want)
+			}
+		})
+	}
+
+	// Sorted listings keep their order
+	got, _, err := repo.ListFiltered(UserListFilter{SortBy: "username", Desc: true}, 0, 10)
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	if len(got) != 3 || got[0].Username != "carol" || got[2].Username != "alice" {
+		t.Errorf("sorted users = %v, want carol, bob, alice", got)
+	}
+}
+
+func TestPromoteLegacyAdmins(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	users := []*models.User{
+		{Username: "legacy", Email: "legacy@example.com", Role: models.UserRoleAdmin},
+		{Username: "scoped", Email: "scoped@example.com", Role: models.UserRoleAdmin,
+			AdminPermissions: []models.AdminPermission{models.AdminPermissionNodes}},
+		{Username: "user", Email: "user@example.com", Role: models.UserRoleUser},
+	}
+	for _, user := range users {
+		user.Password = "x"
+		user.Status = models.UserStatusActive
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	promoted, err := repo.PromoteLegacyAdmins()
+	if err != nil {
+		t.Fatalf("promote admins: %v", err)
+	}
+	if promoted != 1 {
+		t.Errorf("promoted %d admins, want 1", promoted)
+	}
+	for _, user := range users {
+		got, err := repo.GetByID(user.ID)
+		if err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+		want := user.Role
+		if user.Username == "legacy" {
+			want = models.UserRoleSuperAdmin
+		}
+		if got.Role != want {
+			t.Errorf("role of %s = %s, want %s", user.Username, got.Role, want)
+		}
+	}
+
+	// Once a super admin exists, admins without permissions stay admins
+	if err := db.Create(&models.User{Username: "new", Email: "new@example.com", Password: "x", Role: models.UserRoleAdmin}).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if promoted, err := repo.PromoteLegacyAdmins(); err != nil || promoted != 0 {
+		t.Errorf("second promotion = %d, %v, want none", promoted, err)
+	}
+
+	count, err := repo.CountActiveByRole(models.UserRoleSuperAdmin)
+	if err != nil {
+		t.Fatalf("count super admins: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("CountActiveByRole() = %d, want 1", count)
+	}
+}
+
+func TestUserListInactive(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	free := &models.Plan{Name: "Free", Price: 0}
+	paid := &models.Plan{Name: "Pro", Price: 500}
+	for _, plan := range []*models.Plan{free, paid} {
+		if err := db.Create(plan).Error; err != nil {
+			t.Fatalf("create plan: %v", err)
+		}
+	}
+
+	now := time.Now()
+	idle := now.Add(-100 * 24 * time.Hour)
+	recent := now.Add(-time.Hour)
+	users := []*models.User{
+		{Username: "idle", PlanID: free.ID, LastActivityAt: &idle},
+		{Username: "recent", PlanID: free.ID, LastActivityAt: &recent},
+		{Username: "paid", PlanID: paid.ID, LastActivityAt: &idle},
+		{Username: "exempt", PlanID: free.ID, LastActivityAt: &idle, InactivityExempt: true},
+		{Username: "admin", PlanID: free.ID, LastActivityAt: &idle, Role: models.UserRoleAdmin},
+		{Username: "disabled", PlanID: free.ID, LastActivityAt: &idle, Status: models.UserStatusDisabled},
+		{Username: "policy", PlanID: free.ID, LastActivityAt: &idle, Status: models.UserStatusSuspended, InactivitySuspendedAt: &recent},
+	}
+	for _, user := range users {
+		user.Email = user.Username + "@example.com"
+		user.Password = "x"
+		if user.Role == "" {
+			user.Role = models.UserRoleUser
+		}
+		if user.Status == "" {
+			user.Status = models.UserStatusActive
+		}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	cutoff := now.Add(-30 * 24 * time.Hour)
+	for _, tt := range []struct {
+		freeOnly bool
+		want     []string
+	}{
+		{true, []string{"idle", "policy"}},
+		{false, []string{"idle", "paid", "policy"}},
+	} {
+		got, err := repo.ListInactive(cutoff, tt.freeOnly)
+		if err != nil {
+			t.Fatalf("list inactive: %v", err)
+		}
+		var names []string
+		for _, user := range got {
+			names = append(names, user.Username)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("ListInactive(freeOnly=%v) = %v, want %v", tt.freeOnly, names, tt.want)
+		}
+	}
+
+	// Logging in after the suspension reactivates the account
+	if err := repo.ReactivateInactive(users[6].ID); err != nil {
+		t.Fatalf("reactivate: %v", err)
+	}
+	user, err := repo.GetByID(users[6].ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if user.Status != models.UserStatusActive || user.InactivitySuspendedAt != nil || !user.LastActiveAt().After(recent) {
+		t.Errorf("reactivated user = status %s, suspended at %v, last active %v", user.Status, user.InactivitySuspendedAt, user.LastActiveAt())
+	}
+}
+
+func TestUserEndTrial(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	trial := &models.Plan{Name: "Trial", IsTrialPlan: true, TrialDays: 7, Period: models.PlanPeriodMonthly, CurrentUsers: 2}
+	free := &models.Plan{Name: "Free", Period: models.PlanPeriodMonthly}
+	for _, plan := range []*models.Plan{trial, free} {
+		if err := db.Create(plan).Error; err != nil {
+			t.Fatalf("create plan: %v", err)
+		}
+	}
+
+	now := time.Now()
+	started := now.AddDate(0, 0, -7)
+	ended := now.Add(-time.Minute)
+	later := now.Add(48 * time.Hour)
+	users := []*models.User{
+		{Username: "expiring", TrialEndsAt: &ended},
+		{Username: "downgrading", TrialEndsAt: &ended},
+		{Username: "running", TrialEndsAt: &later},
+	}
+	for _, user := range users {
+		user.Email = user.Username + "@example.com"
+		user.Password = "x"
+		user.PlanID = trial.ID
+		user.Status = models.UserStatusActive
+		user.TrialStartedAt = &started
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	due, err := repo.ListTrialsEndingBefore(now)
+	if err != nil {
+		t.Fatalf("list trials: %v", err)
+	}
+	if len(due) != 2 {
+		t.Fatalf("ListTrialsEndingBefore() = %d users, want 2", len(due))
+	}
+
+	if ok, err := repo.EndTrial(users[0].ID, nil, now); err != nil || !ok {
+		t.Fatalf("EndTrial(expire) = %v, %v", ok, err)
+	}
+	if ok, err := repo.EndTrial(users[1].ID, free, now); err != nil || !ok {
+		t.Fatalf("EndTrial(downgrade) = %v, %v", ok, err)
+	}
+	// Running trials are not ended, nor ended trials again
+	for _, user := range []*models.User{users[2], users[0]} {
+		if ok, err := repo.EndTrial(user.ID, nil, now); err != nil || ok {
+			t.Errorf("EndTrial(%s) = %v, %v", user.Username, ok, err)
+		}
+	}
+
+	expired, _ := repo.GetByID(users[0].ID)
+	if expired.Status != models.UserStatusExpired || expired.OnTrial() || expired.CanStartTrial() {
+		t.Errorf("expired user = status %s, trial ends %v", expired.Status, expired.TrialEndsAt)
+	}
+	downgraded, _ := repo.GetByID(users[1].ID)
+	if downgraded.PlanID != free.ID || downgraded.Status != models.UserStatusActive || downgraded.OnTrial() {
+		t.Errorf("downgraded user = plan %d, status %s, trial ends %v", downgraded.PlanID, downgraded.Status, downgraded.TrialEndsAt)
+	}
+	var plan models.Plan
+	db.First(&plan, free.ID)
+	if plan.CurrentUsers != 1 {
+		t.Errorf("downgrade plan users = %d, want 1", plan.CurrentUsers)
+	}
+}
+
+func TestUserExpireAndRestore(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(24 * time.Hour)
+	users := []*models.User{
+		{Username: "ran-out", ExpiresAt: &past},
+		{Username: "running", ExpiresAt: &future},
+		{Username: "lifetime"},
+	}
+	for _, user := range users {
+		user.Email = user.Username + "@example.com"
+		user.Password = "x"
+		user.Status = models.UserStatusActive
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	expired, err := repo.ListExpired(now)
+	if err != nil || len(expired) != 1 || expired[0].ID != users[0].ID {
+		t.Fatalf("ListExpired() = %v, %v", expired, err)
+	}
+	if ok, err := repo.Expire(users[0].ID, now); err != nil || !ok {
+		t.Fatalf("Expire() = %v, %v", ok, err)
+	}
+	if ok, err := repo.Expire(users[1].ID, now); err != nil || ok {
+		t.Errorf("Expire(running) = %v, %v", ok, err)
+	}
+	user, _ := repo.GetByID(users[0].ID)
+	if user.Status != models.UserStatusExpired || user.ExpiredAt == nil {
+		t.Errorf("expired user = status %s, expired at %v", user.Status, user.ExpiredAt)
+	}
+
+	// Not renewed until active with a running plan
+	if renewed, _ := repo.ListRenewed(now); len(renewed) != 0 {
+		t.Errorf("ListRenewed() before renewal = %d users", len(renewed))
+	}
+	user.ApplyPlan(&models.Plan{ID: user.PlanID, Period: models.PlanPeriodMonthly}, true, now)
+	if err := db.Model(user).Select("expires_at", "status").Updates(user).Error; err != nil {
+		t.Fatalf("renew: %v", err)
+	}
+	renewed, err := repo.ListRenewed(now)
+	if err != nil || len(renewed) != 1 {
+		t.Fatalf("ListRenewed() = %v, %v", renewed, err)
+	}
+	if ok, err := repo.MarkRestored(user.ID); err != nil || !ok {
+		t.Fatalf("MarkRestored() = %v, %v", ok, err)
+	}
+	if ok, _ := repo.MarkRestored(user.ID); ok {
+		t.Error("user restored twice")
+	}
+}
+
+func TestUserSearch(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	bob := &models.User{Username: "bob", Email: "bob@example.com", Password: "x"}
+	for _, user := range []*models.User{alice, bob} {
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"username", "ali", []string{"alice"}},
+		{"email", "example.com", []string{"alice", "bob"}},
+		{"uuid", bob.UUID[:13], []string{"bob"}},
+		{"no match", "carol", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := repo.Search(tt.query, 0, 10)
+			if err != nil {
+				t.Fatalf("search users: %v", err)
+			}
+			names := make([]string, len(got))
+			for i, user := range got {
+				names[i] = user.Username
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("users = %v (total %d), want %v", names, total, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserUseTwoFactorStep(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "x", Status: models.UserStatusActive}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if ok, err := repo.UseTwoFactorStep(user.ID, 100); err != nil || !ok {
+		t.Fatalf("UseTwoFactorStep(100) = %v, %v", ok, err)
+	}
+	// A code of the same or an earlier step is a replay
+	for _, step := range []int64{100, 99} {
+		if ok, err := repo.UseTwoFactorStep(user.ID, step); err != nil || ok {
+			t.Errorf("UseTwoFactorStep(%d) = %v, %v", step, ok, err)
+		}
+	}
+	if ok, err := repo.UseTwoFactorStep(user.ID, 101); err != nil || !ok {
+		t.Fatalf("UseTwoFactorStep(101) = %v, %v", ok, err)
+	}
+
+	stored, _ := repo.GetByID(user.ID)
+	if stored.TwoFactorLastStep != 101 {
+		t.Errorf("last step = %d, want 101", stored.TwoFactorLastStep)
+	}
+}
+
+func TestUserReplaceBackupCodes(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewUserRepository(db)
+
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "x", Status: models.UserStatusActive}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := repo.EnableTwoFactor(user.ID, []string{"a", "b"}); err != nil {
+		t.Fatalf("enable two-factor: %v", err)
+	}
+
+	if ok, err := repo.ReplaceBackupCodes(user.ID, []string{"a", "b"}, []string{"b"}); err != nil || !ok {
+		t.Fatalf("ReplaceBackupCodes() = %v, %v", ok, err)
+	}
+	// A second use of code a was loaded with both codes
+	if ok, err := repo.ReplaceBackupCodes(user.ID, []string{"a", "b"}, []string{"b"}); err != nil || ok {
+		t.Errorf("ReplaceBackupCodes() of stale codes = %v, %v", ok, err)
+	}
+	if ok, err := repo.ReplaceBackupCodes(user.ID, []string{"b"}, []string{}); err != nil || !ok {
+		t.Fatalf("ReplaceBackupCodes() of the last code = %v, %v", ok, err)
+	}
+
+	stored, _ := repo.GetByID(user.ID)
+	if len(stored.TwoFactorBackupCodes) != 0 {
+		t.Errorf("backup codes = %v, want none", stored.TwoFactorBackupCodes)
+	}
+}
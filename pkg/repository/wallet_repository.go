@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// WalletRepository interface defines user balance and ledger data access methods
+type WalletRepository interface {
+	// Post books a top-up or deduction and updates the user's balance
+	Post(transaction *models.BalanceTransaction) error
+	ListTransactions(filter BalanceTransactionFilter, offset, limit int) ([]*models.BalanceTransaction, int64, error)
+	Reconcile(userID uint) (*models.WalletReconciliation, error)
+}
+
+// BalanceTransactionFilter narrows a transaction listing, zero values match everything
+type BalanceTransactionFilter struct {
+	UserID uint
+	Type   models.BalanceTransactionType
+}
+
+// walletRepository implements WalletRepository interface
+type walletRepository struct {
+	db *gorm.DB
+}
+
+// NewWalletRepository creates a new wallet repository
+func NewWalletRepository(db *gorm.DB) WalletRepository {
+	return &walletRepository{db: db}
+}
+
+// Post books a balance transaction in its own database transaction. A debit
+// fails with models.ErrInsufficientBalance when it would overdraw the wallet.
+func (r *walletRepository) Post(transaction *models.BalanceTransaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return postBalanceTransaction(tx, transaction)
+	})
+}
+
+// ListTransactions lists balance transactions, newest first
+func (r *walletRepository) ListTransactions(filter BalanceTransactionFilter, offset, limit int) ([]*models.BalanceTransaction, int64, error) {
+	query := r.db.Model(&models.BalanceTransaction{})
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var transactions []*models.BalanceTransaction
+	err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&transactions).Error
+	return transactions, total, err
+}
+
+// Reconcile compares the stored balance of a user with the sum of the
+// ledger entries of the user's wallet
+func (r *walletRepository) Reconcile(userID uint) (*models.WalletReconciliation, error) {
+	var user models.User
+	if err := r.db.Select("id", "balance", "balance_currency").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	result := &models.WalletReconciliation{
+		UserID:   user.ID,
+		Balance:  user.Balance,
+		Currency: user.BalanceCurrency,
+	}
+	err := r.db.Model(&models.LedgerEntry{}).
+		Where("account = ?", models.UserLedgerAccount(userID)).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&result.LedgerBalance).Error
+	if err != nil {
+		return nil, err
+	}
+
+	unbalanced := r.db.Model(&models.LedgerEntry{}).
+		Select("transaction_id").
+		Where("transaction_id IN (?)", r.db.Model(&models.BalanceTransaction{}).Select("id").Where("user_id = ?", userID)).
+		Group("transaction_id").
+		Having("SUM(amount) <> 0 OR COUNT(*) <> 2")
+	err = r.db.Table("(?) AS unbalanced", unbalanced).Count(&result.UnbalancedTransactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// postBalanceTransaction applies a balance transaction to the user's wallet
+// and books its ledger entries as part of tx. Credits to an empty wallet
+// switch it to the transaction's currency; other transactions must be in the
+// wallet's currency.
+func postBalanceTransaction(tx *gorm.DB, transaction *models.BalanceTransaction) error {
+	amount := transaction.Amount
+	if amount < 0 {
+		amount = -amount
+	}
+
+	// The conditions make concurrent spends unable to overdraw the wallet
+	var result *gorm.DB
+	if transaction.Type.IsCredit() {
+		transaction.Amount = amount
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND (balance_currency = ? OR balance = 0)", transaction.UserID, transaction.Currency).
+			Updates(map[string]interface{}{
+				"balance":          gorm.Expr("balance + ?", amount),
+				"balance_currency": transaction.Currency,
+			})
+	} else {
+		transaction.Amount = -amount
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND balance_currency = ? AND balance >= ?", transaction.UserID, transaction.Currency, amount).
+			Update("balance", gorm.Expr("balance - ?", amount))
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return walletError(tx, transaction)
+	}
+
+	var user models.User
+	if err := tx.Select("id", "balance").First(&user, transaction.UserID).Error; err != nil {
+		return err
+	}
+	transaction.BalanceAfter = user.Balance
+	transaction.Entries = []models.LedgerEntry{
+		{Account: models.UserLedgerAccount(transaction.UserID), Amount: transaction.Amount, Currency: transaction.Currency},
+		{Account: transaction.Type.CounterAccount(), Amount: -transaction.Amount, Currency: transaction.Currency},
+	}
+	return tx.Create(transaction).Error
+}
+
+// walletError tells why a balance update matched no user
+func walletError(tx *gorm.DB, transaction *models.BalanceTransaction) error {
+	var user models.User
+	if err := tx.Select("id", "balance", "balance_currency").First(&user, transaction.UserID).Error; err != nil {
+		return err
+	}
+	if user.Balance > 0 && user.BalanceCurrency != transaction.Currency {
+		return models.ErrBalanceCurrency
+	}
+	return models.ErrInsufficientBalance
+}
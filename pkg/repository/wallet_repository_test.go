@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"sing-box-web/pkg/models"
+)
+
+// newTestDB opens a migrated SQLite database whose transactions take
+// the write lock up front, so concurrent spends queue instead of failing
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := (&models.Database{DB: db}).AutoMigrate(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newWalletTestUser creates a user whose balance was topped up by amount
+func newWalletTestUser(t *testing.T, db *gorm.DB, amount int64) *models.User {
+	t.Helper()
+	user := &models.User{Username: "wallet", Email: "wallet@example.com", Password: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if amount > 0 {
+		topUp := &models.BalanceTransaction{UserID: user.ID, Type: models.BalanceTransactionTopUp, Amount: amount, Currency: "USD"}
+		if err := NewWalletRepository(db).Post(topUp); err != nil {
+			t.Fatalf("top up: %v", err)
+		}
+	}
+	return user
+}
+
+// checkReconciled fails unless the user's balance is want and matches the ledger
+func checkReconciled(t *testing.T, db *gorm.DB, userID uint, want int64) {
+	t.Helper()
+	result, err := NewWalletRepository(db).Reconcile(userID)
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+	if result.Balance != want || !result.Consistent() {
+		t.Errorf("reconcile = %+v, want balance %d matching the ledger", result, want)
+	}
+}
+
+func TestWalletConcurrentSpends(t *testing.T) {
+	db := newTestDB(t)
+	user := newWalletTestUser(t, db, 1000)
+	repo := NewWalletRepository(db)
+
+	const spends = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, spends)
+	for i := 0; i < spends; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.Post(&models.BalanceTransaction{
+				UserID:   user.ID,
+				Type:     models.BalanceTransactionDeduction,
+				Amount:   30,
+				Currency: "USD",
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, models.ErrInsufficientBalance):
+			t.Errorf("Post() = %v, want nil or %v", err, models.ErrInsufficientBalance)
+		}
+	}
+	if succeeded != 33 {
+		t.Errorf("%d spends succeeded, want 33", succeeded)
+	}
+	checkReconciled(t, db, user.ID, 10)
+}
+
+func TestWalletConcurrentOrderPayments(t *testing.T) {
+	db := newTestDB(t)
+	user := newWalletTestUser(t, db, 2500)
+	plan := &models.Plan{Name: "pro", Status: models.PlanStatusActive, Period: models.PlanPeriodMonthly, Price: 1000, Currency: "USD"}
+	if err := db.Create(plan).Error; err != nil {
+		t.Fatalf("create plan: %v", err)
+	}
+
+	orders := NewOrderRepository(db)
+	const count = 5
+	ids := make([]uint, count)
+	for i := range ids {
+		order := &models.Order{OrderNo: models.NewOrderNo(), UserID: user.ID, PlanID: plan.ID, Type: models.OrderTypeNew,
+			Status: models.OrderStatusPending, Amount: 1000, Total: 1000, Currency: "USD"}
+		if err := orders.Create(order); err != nil {
+			t.Fatalf("create order: %v", err)
+		}
+		ids[i] = order.ID
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = orders.ConfirmPayment(id, &models.Payment{Method: models.PaymentMethodBalance, Amount: 1000, Currency: "USD"})
+		}()
+	}
+	wg.Wait()
+
+	var paid []uint
+	for i, err := range errs {
+		order, getErr := orders.GetByID(ids[i])
+		if getErr != nil {
+			t.Fatalf("get order: %v", getErr)
+		}
+		switch {
+		case err == nil:
+			paid = append(paid, ids[i])
+			if order.Status != models.OrderStatusPaid || len(order.Payments) != 1 {
+				t.Errorf("paid order %d has status %s and %d payments", ids[i], order.Status, len(order.Payments))
+			}
+		case errors.Is(err, models.ErrInsufficientBalance):
+			// A failed payment must leave no trace of the order being paid
+			if order.Status != models.OrderStatusPending || len(order.Payments) != 0 {
+				t.Errorf("unpaid order %d has status %s and %d payments", ids[i], order.Status, len(order.Payments))
+			}
+		default:
+			t.Errorf("ConfirmPayment() = %v, want nil or %v", err, models.ErrInsufficientBalance)
+		}
+	}
+	if len(paid) != 2 {
+		t.Fatalf("%d orders paid, want 2", len(paid))
+	}
+	checkReconciled(t, db, user.ID, 500)
+
+	// Orders paid from the balance are refunded to it
+	if _, err := orders.Refund(paid[0], "test", "admin", false, false); err != nil {
+		t.Fatalf("refund: %v", err)
+	}
+	checkReconciled(t, db, user.ID, 1500)
+}
+
+func TestWalletPostErrors(t *testing.T) {
+	db := newTestDB(t)
+	funded := newWalletTestUser(t, db, 100)
+	empty := &models.User{Username: "empty", Email: "empty@example.com", Password: "x"}
+	if err := db.Create(empty).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	repo := NewWalletRepository(db)
+
+	tests := []struct {
+		name        string
+		transaction models.BalanceTransaction
+		want        error
+	}{
+		{"overdraw", models.BalanceTransaction{UserID: funded.ID, Type: models.BalanceTransactionDeduction, Amount: 101, Currency: "USD"},
+			models.ErrInsufficientBalance},
+		{"spend other currency", models.BalanceTransaction{UserID: funded.ID, Type: models.BalanceTransactionDeduction, Amount: 1, Currency: "EUR"},
+			models.ErrBalanceCurrency},
+		{"credit other currency", models.BalanceTransaction{UserID: funded.ID, Type: models.BalanceTransactionTopUp, Amount: 1, Currency: "EUR"},
+			models.ErrBalanceCurrency},
+		{"spend empty wallet", models.BalanceTransaction{UserID: empty.ID, Type: models.BalanceTransactionDeduction, Amount: 1, Currency: "USD"},
+			models.ErrInsufficientBalance},
+		{"credit empty wallet", models.BalanceTransaction{UserID: empty.ID, Type: models.BalanceTransactionTopUp, Amount: 1, Currency: "EUR"},
+			nil},
+		{"unknown user", models.BalanceTransaction{UserID: 999, Type: models.BalanceTransactionTopUp, Amount: 1, Currency: "USD"},
+			gorm.ErrRecordNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := repo.Post(&tt.transaction); !errors.Is(err, tt.want) {
+				t.Errorf("Post() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	checkReconciled(t, db, funded.ID, 100)
+	checkReconciled(t, db, empty.ID, 1)
+}
@@ -0,0 +1,101 @@
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+const (
+	// AdminPasswordEnv names the environment variable holding the password
+	// of the super admin created on first run, generated when unset
+	AdminPasswordEnv = "SING_BOX_ADMIN_PASSWORD"
+	// MinAdminPasswordLength is the shortest admin password accepted
+	MinAdminPasswordLength = 12
+	// PlaceholderPasswordHash is the hash earlier releases created the admin
+	// with. It matches no password, but is public and must be replaced.
+	PlaceholderPasswordHash = "$2a$12$example"
+)
+
+// ErrPlaceholderPassword is returned when users still have the placeholder
+// password hash
+var ErrPlaceholderPassword = errors.New("placeholder password hash in use")
+
+// validateAdminPassword checks a password given for an admin
+func validateAdminPassword(password string) error {
+	if len(password) < MinAdminPasswordLength {
+		return fmt.Errorf("admin password must be at least %d characters", MinAdminPasswordLength)
+	}
+	return nil
+}
+
+// Bootstrap creates the default data on first run, when there is no super
+// admin yet. The super admin gets password, or a generated one returned in
+// the result, which is then the only place it is found.
+func Bootstrap(db *gorm.DB, password string) (*Result, error) {
+	var admins int64
+	if err := db.Model(&models.User{}).Where("role = ?", models.UserRoleSuperAdmin).Count(&admins).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up super admins: %w", err)
+	}
+	if admins > 0 {
+		return &Result{}, nil
+	}
+	return Run(db, Options{AdminPassword: password})
+}
+
+// CheckPlaceholderPasswords returns ErrPlaceholderPassword, naming the users,
+// when any user has the placeholder password hash
+func CheckPlaceholderPasswords(db *gorm.DB) error {
+	var usernames []string
+	if err := db.Model(&models.User{}).Where("password = ?", PlaceholderPasswordHash).
+		Order("id").Pluck("username", &usernames).Error; err != nil {
+		return fmt.Errorf("failed to look up placeholder passwords: %w", err)
+	}
+	if len(usernames) > 0 {
+		return fmt.Errorf("%w by %s", ErrPlaceholderPassword, strings.Join(usernames, ", "))
+	}
+	return nil
+}
+
+// ResetPassword sets the password of a user, clearing failed login attempts
+// and the lock. Without a password one is generated and returned.
+func ResetPassword(db *gorm.DB, username, password string) (string, error) {
+	generated := ""
+	if password == "" {
+		var err error
+		if password, err = randomPassword(); err != nil {
+			return "", err
+		}
+		generated = password
+	} else if err := validateAdminPassword(password); err != nil {
+		return "", err
+	}
+
+	users := repository.NewUserRepository(db)
+	user, err := users.GetByUsername(username)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", fmt.Errorf("user %q not found", username)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get user %q: %w", username, err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	replaced, err := users.ReplacePassword(user.ID, user.Password, string(hash))
+	if err != nil {
+		return "", fmt.Errorf("failed to set password: %w", err)
+	}
+	if !replaced {
+		return "", fmt.Errorf("password of %q changed concurrently, try again", username)
+	}
+	return generated, nil
+}
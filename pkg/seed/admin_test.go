@@ -0,0 +1,78 @@
+package seed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"sing-box-web/pkg/models"
+)
+
+func TestBootstrap(t *testing.T) {
+	db := newTestDB(t)
+
+	result, err := Bootstrap(db, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.AdminCreated || result.AdminPassword == "" {
+		t.Fatalf("result = %+v, want a super admin with a generated password", result)
+	}
+
+	// A renamed super admin is not created again
+	if err := db.Model(&models.User{}).Where("username = ?", AdminUsername).Update("username", "root").Error; err != nil {
+		t.Fatal(err)
+	}
+	again, err := Bootstrap(db, "another-password")
+	if err != nil || again.AdminCreated {
+		t.Errorf("second bootstrap = %+v, %v, want nothing created", again, err)
+	}
+
+	if _, err := Bootstrap(newTestDB(t), "short"); err == nil {
+		t.Error("Bootstrap accepted a short password")
+	}
+}
+
+func TestPlaceholderPasswords(t *testing.T) {
+	db := newTestDB(t)
+	if err := CheckPlaceholderPasswords(db); err != nil {
+		t.Fatalf("CheckPlaceholderPasswords on an empty database = %v", err)
+	}
+
+	legacy := &models.User{Username: "admin", Email: "admin@localhost", Password: PlaceholderPasswordHash, Role: models.UserRoleSuperAdmin, LoginAttempts: 5}
+	if err := db.Create(legacy).Error; err != nil {
+		t.Fatal(err)
+	}
+	err := CheckPlaceholderPasswords(db)
+	if !errors.Is(err, ErrPlaceholderPassword) || !strings.Contains(err.Error(), "admin") {
+		t.Fatalf("CheckPlaceholderPasswords = %v, want ErrPlaceholderPassword naming admin", err)
+	}
+
+	generated, err := ResetPassword(db, "admin", "")
+	if err != nil || generated == "" {
+		t.Fatalf("ResetPassword = %q, %v, want a generated password", generated, err)
+	}
+	var user models.User
+	db.First(&user, legacy.ID)
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(generated)); err != nil {
+		t.Error("password does not match the generated one")
+	}
+	if user.LoginAttempts != 0 {
+		t.Errorf("login attempts = %d, want them cleared", user.LoginAttempts)
+	}
+	if err := CheckPlaceholderPasswords(db); err != nil {
+		t.Errorf("CheckPlaceholderPasswords after the reset = %v", err)
+	}
+
+	if generated, err := ResetPassword(db, "admin", "chosen-password"); err != nil || generated != "" {
+		t.Errorf("ResetPassword with a password = %q, %v", generated, err)
+	}
+	if _, err := ResetPassword(db, "admin", "short"); err == nil {
+		t.Error("ResetPassword accepted a short password")
+	}
+	if _, err := ResetPassword(db, "nobody", ""); err == nil {
+		t.Error("ResetPassword of a missing user succeeded")
+	}
+}
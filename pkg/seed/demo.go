@@ -0,0 +1,305 @@
+package seed
+
+import (
+	"fmt"
+	mathrand "math/rand/v2"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+// demoPlans are the plans the demo plans cycle through
+var demoPlans = []struct {
+	period models.PlanPeriod
+	price  int64
+	quota  int64
+	color  string
+}{
+	{models.PlanPeriodMonthly, 500, 100 << 30, "#16a34a"},
+	{models.PlanPeriodMonthly, 1500, 500 << 30, "#9333ea"},
+	{models.PlanPeriodYearly, 9900, 0, "#ea580c"},
+}
+
+// demoNodes are the node types and locations the demo nodes cycle through
+var demoNodes = []struct {
+	nodeType models.NodeType
+	region   string
+	country  string
+	city     string
+}{
+	{models.NodeTypeVLESS, "asia", "JP", "Tokyo"},
+	{models.NodeTypeTrojan, "asia", "SG", "Singapore"},
+	{models.NodeTypeVMess, "north-america", "US", "Los Angeles"},
+	{models.NodeTypeShadowsocks, "europe", "DE", "Frankfurt"},
+	{models.NodeTypeHysteria2, "asia", "HK", "Hong Kong"},
+}
+
+// demoSeeder creates the demo data of a run
+type demoSeeder struct {
+	db     *gorm.DB
+	opts   Options
+	result *Result
+	now    time.Time
+
+	plans []models.Plan
+	nodes []models.Node
+	users []models.User
+}
+
+func (s *demoSeeder) run() error {
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"plans", s.seedPlans},
+		{"nodes", s.seedNodes},
+		{"plan node access", s.seedPlanNodeAccess},
+		{"users", s.seedUsers},
+		{"traffic", s.seedTraffic},
+	}
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			return fmt.Errorf("failed to seed demo %s: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// rng returns the random source of a demo record, the same on every run
+func (s *demoSeeder) rng(kind, index uint64) *mathrand.Rand {
+	return mathrand.New(mathrand.NewPCG(s.opts.RandSeed, kind<<56|index))
+}
+
+func (s *demoSeeder) seedPlans() error {
+	for i := 1; i <= s.opts.Plans; i++ {
+		name := fmt.Sprintf("%splan-%d", demoPrefix, i)
+		template := demoPlans[(i-1)%len(demoPlans)]
+		plan := models.Plan{
+			Name:         name,
+			Description:  "Demo plan",
+			Status:       models.PlanStatusActive,
+			Period:       template.period,
+			Price:        template.price,
+			Currency:     "USD",
+			TrafficQuota: template.quota,
+			DeviceLimit:  3,
+			IsPublic:     true,
+			IsEnabled:    true,
+			Color:        template.color,
+			SortOrder:    100 + i,
+		}
+		created, err := firstOrCreate(s.db, &plan, "name = ?", name)
+		if err != nil {
+			return err
+		}
+		if created {
+			s.result.Plans++
+		}
+		s.plans = append(s.plans, plan)
+	}
+	return nil
+}
+
+func (s *demoSeeder) seedNodes() error {
+	for i := 1; i <= s.opts.Nodes; i++ {
+		name := fmt.Sprintf("%snode-%02d", demoPrefix, i)
+		template := demoNodes[(i-1)%len(demoNodes)]
+		node := models.Node{
+			Name:      name,
+			Type:      template.nodeType,
+			Status:    models.NodeStatusOnline,
+			Host:      name + ".demo.invalid",
+			Port:      443,
+			Region:    template.region,
+			Country:   template.country,
+			City:      template.city,
+			Tags:      "demo",
+			Sort:      100 + i,
+			IsEnabled: true,
+		}
+		switch template.nodeType {
+		case models.NodeTypeTrojan, models.NodeTypeHysteria2:
+			node.Password = DemoPassword
+			node.TLS = true
+			node.ServerName = node.Host
+		case models.NodeTypeShadowsocks:
+			node.Password = DemoPassword
+			node.Method = "aes-256-gcm"
+		}
+		created, err := firstOrCreate(s.db, &node, "name = ?", name)
+		if err != nil {
+			return err
+		}
+		if created {
+			s.result.Nodes++
+		}
+		s.nodes = append(s.nodes, node)
+	}
+	return nil
+}
+
+// seedPlanNodeAccess grants every demo plan the demo nodes
+func (s *demoSeeder) seedPlanNodeAccess() error {
+	for _, plan := range s.plans {
+		for _, node := range s.nodes {
+			access := models.PlanNodeAccess{PlanID: plan.ID, NodeID: node.ID, IsEnabled: true}
+			if _, err := firstOrCreate(s.db, &access, "plan_id = ? AND node_id = ?", plan.ID, node.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *demoSeeder) seedUsers() error {
+	if s.opts.Users == 0 {
+		return nil
+	}
+	// Hashing is slow, every demo user shares the hash
+	hash, err := bcrypt.GenerateFromPassword([]byte(DemoPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= s.opts.Users; i++ {
+		rng := s.rng(1, uint64(i))
+		name := fmt.Sprintf("%suser-%04d", demoPrefix, i)
+		plan := s.plans[rng.IntN(len(s.plans))]
+		expiresAt := s.now.AddDate(0, 0, rng.IntN(180)-30)
+
+		user := models.User{
+			Username:     name,
+			Email:        name + "@demo.invalid",
+			Password:     string(hash),
+			DisplayName:  fmt.Sprintf("Demo User %d", i),
+			Status:       models.UserStatusActive,
+			Role:         models.UserRoleUser,
+			PlanID:       plan.ID,
+			TrafficQuota: plan.TrafficQuota,
+			DeviceLimit:  plan.DeviceLimit,
+			ExpiresAt:    &expiresAt,
+		}
+		// A few users show the other account states in the UI
+		switch {
+		case expiresAt.Before(s.now):
+			user.Status = models.UserStatusExpired
+		case rng.IntN(20) == 0:
+			user.Status = models.UserStatusSuspended
+		}
+
+		created, err := firstOrCreate(s.db, &user, "username = ?", name)
+		if err != nil {
+			return err
+		}
+		if created {
+			s.result.Users++
+		}
+		s.users = append(s.users, user)
+	}
+	return nil
+}
+
+// seedTraffic creates up to three sessions a day per demo user. Sessions are
+// identified by user, day and index, those already present are skipped and
+// the traffic of the current month added to the users' usage.
+func (s *demoSeeder) seedTraffic() error {
+	if s.opts.TrafficDays == 0 || len(s.users) == 0 {
+		return nil
+	}
+	today := s.now.Truncate(24 * time.Hour)
+	// Record dates are whole days since the epoch, like the ones agents report
+	from := today.Add(-time.Duration(s.opts.TrafficDays-1) * 24 * time.Hour)
+	monthStart := time.Date(s.now.Year(), s.now.Month(), 1, 0, 0, 0, 0, s.now.Location())
+
+	var existing []string
+	if err := s.db.Model(&models.TrafficRecord{}).
+		Where("session_id LIKE ? AND record_date >= ?", demoPrefix+"%", from).
+		Pluck("session_id", &existing).Error; err != nil {
+		return err
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, id := range existing {
+		seen[id] = true
+	}
+
+	for _, user := range s.users {
+		var records []models.TrafficRecord
+		var monthUsage int64
+		for day := from; !day.After(today); day = day.Add(24 * time.Hour) {
+			rng := s.rng(2, uint64(user.ID)<<32|uint64(day.Unix()/86400))
+			for session := range rng.IntN(4) {
+				sessionID := fmt.Sprintf("%s%d-%s-%d", demoPrefix, user.ID, day.Format("20060102"), session)
+				record := s.trafficRecord(rng, user.ID, day, sessionID)
+				if seen[sessionID] {
+					continue
+				}
+				records = append(records, record)
+				if !day.Before(monthStart) {
+					monthUsage += record.Total
+				}
+			}
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.CreateInBatches(records, trafficBatchSize).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.User{}).Where("id = ?", user.ID).
+				UpdateColumn("traffic_used", gorm.Expr("traffic_used + ?", monthUsage)).Error
+		})
+		if err != nil {
+			return err
+		}
+		s.result.TrafficRecords += len(records)
+	}
+	return nil
+}
+
+// trafficRecord returns a session of a user on a day
+func (s *demoSeeder) trafficRecord(rng *mathrand.Rand, userID uint, day time.Time, sessionID string) models.TrafficRecord {
+	node := s.nodes[rng.IntN(len(s.nodes))]
+	hour := rng.IntN(24)
+	connectTime := day.Add(time.Duration(hour)*time.Hour + time.Duration(rng.IntN(3600))*time.Second)
+	duration := int64(60 + rng.IntN(3*3600))
+	disconnectTime := connectTime.Add(time.Duration(duration) * time.Second)
+	download := int64(rng.IntN(2<<30)) + 1<<20
+	upload := download / int64(5+rng.IntN(15))
+
+	return models.TrafficRecord{
+		UserID:         userID,
+		NodeID:         node.ID,
+		Upload:         upload,
+		Download:       download,
+		Total:          upload + download,
+		RecordDate:     day,
+		RecordHour:     hour,
+		SessionID:      sessionID,
+		ConnectTime:    connectTime,
+		DisconnectTime: &disconnectTime,
+		Duration:       duration,
+		ClientIP:       fmt.Sprintf("203.0.113.%d", 1+rng.IntN(254)),
+		Protocol:       string(node.Type),
+		AvgSpeed:       (upload + download) / duration,
+		MaxSpeed:       (upload + download) / duration * int64(2+rng.IntN(4)),
+		Latency:        20 + rng.IntN(200),
+	}
+}
+
+// firstOrCreate loads the record matching a condition into record, creating
+// record when there is none, and reports whether it was created
+func firstOrCreate(db *gorm.DB, record any, query string, args ...any) (bool, error) {
+	result := db.Where(query, args...).Limit(1).Find(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return false, nil
+	}
+	return true, db.Create(record).Error
+}
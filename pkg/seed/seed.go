@@ -0,0 +1,187 @@
+// Package seed fills a database with the data every installation starts
+// with and, on request, with demo plans, nodes, users and traffic history
+// for load tests and UI development. Seeding is idempotent: the records
+// present are kept and only the missing ones are created, so raising a
+// count on a later run adds to the demo data.
+package seed
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+)
+
+const (
+	// AdminUsername is the name of the super admin created by default
+	AdminUsername = "admin"
+	// DemoPassword is the password of every demo user
+	DemoPassword = "demo-password"
+
+	// demoPrefix names the demo records so reruns find them
+	demoPrefix = "demo-"
+	// trafficBatchSize is the number of traffic records inserted at once
+	trafficBatchSize = 500
+)
+
+// Options selects the data to seed
+type Options struct {
+	// AdminPassword is the password of the super admin when it is created,
+	// a random one is generated when empty
+	AdminPassword string
+
+	// Demo enables the demo data, nothing below is created otherwise
+	Demo  bool
+	Plans int
+	Nodes int
+	Users int
+	// TrafficDays is the number of days of traffic history of each demo
+	// user, up to today
+	TrafficDays int
+	// RandSeed makes the demo data reproducible
+	RandSeed uint64
+}
+
+// DefaultOptions returns the options of a small demo
+func DefaultOptions() Options {
+	return Options{
+		Plans:       3,
+		Nodes:       5,
+		Users:       50,
+		TrafficDays: 30,
+		RandSeed:    1,
+	}
+}
+
+// Validate checks the admin password and that the counts can be seeded
+func (o Options) Validate() error {
+	if o.AdminPassword != "" {
+		if err := validateAdminPassword(o.AdminPassword); err != nil {
+			return err
+		}
+	}
+	if o.Plans < 0 || o.Nodes < 0 || o.Users < 0 || o.TrafficDays < 0 {
+		return fmt.Errorf("demo counts cannot be negative")
+	}
+	if o.Users > 0 && o.Plans == 0 {
+		return fmt.Errorf("demo users need at least one demo plan")
+	}
+	if o.TrafficDays > 0 && o.Users > 0 && o.Nodes == 0 {
+		return fmt.Errorf("demo traffic needs at least one demo node")
+	}
+	return nil
+}
+
+// Result counts the records a run created
+type Result struct {
+	// AdminPassword is the generated password of the super admin, empty
+	// unless it was created without a password
+	AdminPassword  string
+	AdminCreated   bool
+	Plans          int
+	Nodes          int
+	Users          int
+	TrafficRecords int
+}
+
+// Run seeds a migrated database
+func Run(db *gorm.DB, opts Options) (*Result, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return seedDefaults(tx, opts.AdminPassword, result)
+	}); err != nil {
+		return nil, err
+	}
+	if !opts.Demo {
+		return result, nil
+	}
+
+	s := &demoSeeder{db: db, opts: opts, result: result, now: time.Now()}
+	if err := s.run(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// seedDefaults creates the default plan and the super admin unless present
+func seedDefaults(tx *gorm.DB, adminPassword string, result *Result) error {
+	var plan models.Plan
+	err := tx.Order("id").First(&plan).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		plan = models.Plan{
+			Name:         "Free Plan",
+			Description:  "Default free plan with basic features",
+			Status:       models.PlanStatusActive,
+			Period:       models.PlanPeriodMonthly,
+			Currency:     "USD",
+			TrafficQuota: 10 * 1024 * 1024 * 1024, // 10GB
+			DeviceLimit:  1,
+			IsPublic:     true,
+			IsEnabled:    true,
+			Color:        "#2563eb",
+			SortOrder:    1,
+		}
+		if err := tx.Create(&plan).Error; err != nil {
+			return fmt.Errorf("failed to create default plan: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to get default plan: %w", err)
+	}
+
+	var admins int64
+	if err := tx.Model(&models.User{}).Where("username = ?", AdminUsername).Count(&admins).Error; err != nil {
+		return fmt.Errorf("failed to look up admin: %w", err)
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	password := adminPassword
+	if password == "" {
+		var err error
+		if password, err = randomPassword(); err != nil {
+			return err
+		}
+		result.AdminPassword = password
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash admin password: %w", err)
+	}
+	admin := &models.User{
+		Username:     AdminUsername,
+		Email:        "admin@localhost",
+		Password:     string(hash),
+		DisplayName:  "Administrator",
+		Status:       models.UserStatusActive,
+		Role:         models.UserRoleSuperAdmin,
+		PlanID:       plan.ID,
+		TrafficQuota: -1, // Unlimited for admin
+		DeviceLimit:  10,
+	}
+	if err := tx.Create(admin).Error; err != nil {
+		return fmt.Errorf("failed to create admin: %w", err)
+	}
+	result.AdminCreated = true
+	return nil
+}
+
+// randomPassword generates the password of an admin set up without one
+func randomPassword() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate admin password: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,118 @@
+package seed
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"sing-box-web/pkg/migration"
+	"sing-box-web/pkg/models"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	migrator, err := migration.New(db, migration.Shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+func TestSeedDefaults(t *testing.T) {
+	db := newTestDB(t)
+
+	result, err := Run(db, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.AdminCreated || result.AdminPassword == "" {
+		t.Fatalf("result = %+v, want a created admin with a generated password", result)
+	}
+
+	var admin models.User
+	if err := db.Where("username = ?", AdminUsername).First(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+	if admin.Role != models.UserRoleSuperAdmin {
+		t.Errorf("admin role = %s", admin.Role)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(result.AdminPassword)); err != nil {
+		t.Error("admin password does not match the generated one")
+	}
+
+	again, err := Run(db, Options{AdminPassword: "another-password"})
+	if err != nil || again.AdminCreated || again.AdminPassword != "" {
+		t.Errorf("second run = %+v, %v, want nothing created", again, err)
+	}
+	var plans int64
+	db.Model(&models.Plan{}).Count(&plans)
+	if plans != 1 {
+		t.Errorf("plans = %d, want the default one", plans)
+	}
+}
+
+func TestSeedDemo(t *testing.T) {
+	db := newTestDB(t)
+	opts := Options{AdminPassword: "admin-password", Demo: true, Plans: 2, Nodes: 3, Users: 4, TrafficDays: 5, RandSeed: 7}
+
+	result, err := Run(db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Plans != 2 || result.Nodes != 3 || result.Users != 4 || result.TrafficRecords == 0 {
+		t.Fatalf("result = %+v", result)
+	}
+	var access int64
+	db.Model(&models.PlanNodeAccess{}).Count(&access)
+	if access != 6 {
+		t.Errorf("plan node access = %d, want 6", access)
+	}
+
+	// Rerunning creates nothing
+	again, err := Run(db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Plans != 0 || again.Nodes != 0 || again.Users != 0 || again.TrafficRecords != 0 {
+		t.Errorf("second run = %+v, want nothing created", again)
+	}
+
+	// Raising a count only adds the missing records
+	opts.Users = 5
+	more, err := Run(db, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if more.Users != 1 {
+		t.Errorf("third run created %d users, want 1", more.Users)
+	}
+	var records int64
+	db.Model(&models.TrafficRecord{}).Count(&records)
+	if records != int64(result.TrafficRecords+more.TrafficRecords) {
+		t.Errorf("traffic records = %d, want %d", records, result.TrafficRecords+more.TrafficRecords)
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Users: -1},
+		{Users: 1},
+		{Users: 1, Plans: 1, TrafficDays: 1},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) accepted invalid options", opts)
+		}
+	}
+}
@@ -4,16 +4,28 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/config"
 	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/health"
 	"sing-box-web/pkg/logger"
 	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/tracing"
+	"sing-box-web/pkg/util"
 )
 
 // Agent represents the sing-box agent
@@ -24,6 +36,11 @@ type Agent struct {
 	// gRPC client connection
 	apiClient pbv1.AgentServiceClient
 	conn      *grpc.ClientConn
+	connMu    sync.RWMutex
+
+	// API server addresses, the configured server followed by its failover servers
+	apiAddresses  []string
+	apiAddressIdx int
 
 	// Node management
 	nodeInfo     *pbv1.RegisterNodeRequest
@@ -37,6 +54,42 @@ type Agent struct {
 	// Sing-box management
 	singboxManager *SingboxManager
 
+	// Disk footprint of the agent
+	disk *diskGuard
+
+	// Reports kept during outages of the API server, nil when disabled
+	spool *reportSpool
+
+	// Report IDs are the sequence number of a report prefixed by the random
+	// ID of this run of the agent
+	runID     string
+	reportSeq atomic.Uint64
+
+	// Set while a diagnostic runs, one runs at a time
+	diagnosing atomic.Bool
+
+	// Geo databases installed from the API server, by name
+	geoData       map[string]*pbv1.GeoDataVersion
+	geoDataMu     sync.RWMutex
+	geoDataSyncCh chan struct{}
+
+	// Health and readiness endpoints
+	health       *health.Checker
+	healthServer *health.Server
+
+	// Flushes the spans of the agent's RPCs and stops their export
+	stopTracing func(context.Context) error
+
+	// Monitor settings, replaced when the configuration is reloaded, and a
+	// channel closed when they are
+	monitor        configv1.MonitorConfig
+	monitorChanged chan struct{}
+	monitorMu      sync.RWMutex
+
+	// Configuration as loaded, before enrolling filled in the node
+	// credentials, which reloaded configurations are compared with
+	loaded configv1.AgentConfig
+
 	// Shutdown
 	shutdownCtx context.Context
 	shutdown    context.CancelFunc
@@ -51,10 +104,19 @@ func NewAgent(config configv1.AgentConfig) (*Agent, error) {
 
 	// Create agent
 	agent := &Agent{
-		config:      config,
-		logger:      logger,
-		shutdownCtx: shutdownCtx,
-		shutdown:    shutdown,
+		config:         config,
+		loaded:         config,
+		logger:         logger,
+		shutdownCtx:    shutdownCtx,
+		shutdown:       shutdown,
+		geoData:        make(map[string]*pbv1.GeoDataVersion),
+		geoDataSyncCh:  make(chan struct{}, 1),
+		monitor:        config.Monitor,
+		monitorChanged: make(chan struct{}),
+		runID:          randomHex(8),
+		apiAddresses: append([]string{
+			net.JoinHostPort(config.APIServer.Address, strconv.Itoa(config.APIServer.Port)),
+		}, config.APIServer.FailoverAddresses...),
 	}
 
 	// Initialize node info
@@ -62,12 +124,25 @@ func NewAgent(config configv1.AgentConfig) (*Agent, error) {
 		return nil, fmt.Errorf("failed to initialize node info: %w", err)
 	}
 
+	// Create disk guard
+	agent.disk = newDiskGuard(config, logger)
+
 	// Create metrics collector
 	agent.metricsCollector = NewMetricsCollector(logger)
+	agent.metricsCollector.disk = agent.disk
 
 	// Create sing-box manager
 	agent.singboxManager = NewSingboxManager(config, logger)
 
+	// The node serves users as long as sing-box runs, the API server only
+	// manages it
+	agent.health = health.NewChecker(config.HealthEndpoints.CheckTimeout)
+	agent.health.Add("sing-box", func(context.Context) error {
+		return agent.singboxManager.Running()
+	})
+	agent.health.AddOptional("api server", agent.checkAPIServer)
+	agent.healthServer = health.NewServer(config.HealthEndpoints, agent.health, logger)
+
 	return agent, nil
 }
 
@@ -75,6 +150,28 @@ func NewAgent(config configv1.AgentConfig) (*Agent, error) {
 func (a *Agent) Start(ctx context.Context) error {
 	a.logger.Info("agent starting")
 
+	// Probes get answers, not ready, while the agent starts
+	if err := a.healthServer.Start(); err != nil {
+		return fmt.Errorf("failed to start health endpoints: %w", err)
+	}
+
+	// Serve the node-local metrics to scrapers of the node
+	if err := a.startMetricsServer(); err != nil {
+		return fmt.Errorf("failed to start metrics server: %w", err)
+	}
+
+	// Trace the RPCs to the API server
+	stopTracing, err := tracing.Setup(ctx, a.config.SkyWalking, a.logger.Named("tracing"))
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	a.stopTracing = stopTracing
+
+	// Obtain the node ID and token of a node that was not configured with one
+	if err := a.enroll(); err != nil {
+		return fmt.Errorf("failed to enroll node: %w", err)
+	}
+
 	// Connect to API server
 	if err := a.connectToAPI(); err != nil {
 		return fmt.Errorf("failed to connect to API server: %w", err)
@@ -85,6 +182,12 @@ func (a *Agent) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to register node: %w", err)
 	}
 
+	// Start watching the disk
+	go a.disk.run(a.shutdownCtx.Done())
+
+	// Keep the reports the API server does not receive
+	a.openSpool()
+
 	// Start metrics collection
 	if err := a.metricsCollector.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start metrics collector: %w", err)
@@ -100,14 +203,26 @@ func (a *Agent) Start(ctx context.Context) error {
 	go a.metricsReportLoop()
 	go a.trafficReportLoop()
 	go a.commandProcessorLoop()
+	if a.spool != nil {
+		go a.spoolReplayLoop()
+	}
+	if a.config.GeoData.Enabled {
+		go a.geoDataSyncLoop()
+	}
 
 	a.logger.Info("agent started successfully")
 	return nil
 }
 
+// SetReady sets whether the agent answers its readiness endpoint as ready
+func (a *Agent) SetReady(ready bool) {
+	a.health.SetReady(ready)
+}
+
 // Stop stops the agent
 func (a *Agent) Stop(ctx context.Context) error {
 	a.logger.Info("agent stopping")
+	a.health.SetReady(false)
 
 	// Cancel background tasks
 	a.shutdown()
@@ -122,11 +237,27 @@ func (a *Agent) Stop(ctx context.Context) error {
 		a.logger.Error("failed to stop metrics collector", zap.Error(err))
 	}
 
+	if a.spool != nil {
+		if err := a.spool.Close(); err != nil {
+			a.logger.Error("failed to close report spool", zap.Error(err))
+		}
+	}
+
 	// Close gRPC connection
 	if a.conn != nil {
 		a.conn.Close()
 	}
 
+	if err := a.healthServer.Stop(ctx); err != nil {
+		a.logger.Error("failed to stop health endpoints", zap.Error(err))
+	}
+
+	if a.stopTracing != nil {
+		if err := a.stopTracing(ctx); err != nil {
+			a.logger.Error("failed to stop tracing", zap.Error(err))
+		}
+	}
+
 	a.logger.Info("agent stopped")
 	return nil
 }
@@ -180,31 +311,101 @@ func (a *Agent) getNodeCapabilities() *pbv1.NodeCapability {
 	}
 }
 
-// connectToAPI connects to the API server
+// connectToAPI connects to the current API server
 func (a *Agent) connectToAPI() error {
-	apiAddress := fmt.Sprintf("%s:%d", a.config.APIServer.Address, a.config.APIServer.Port)
+	a.connMu.RLock()
+	apiAddress := a.apiAddresses[a.apiAddressIdx]
+	a.connMu.RUnlock()
+
 	a.logger.Info("connecting to API server", zap.String("address", apiAddress))
 
+	conn, err := a.dialAPI(apiAddress)
+	if err != nil {
+		return fmt.Errorf("failed to connect to API server: %w", err)
+	}
+
+	a.connMu.Lock()
+	previous := a.conn
+	a.conn = conn
+	a.apiClient = pbv1.NewAgentServiceClient(conn)
+	a.connMu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+
+	a.logger.Info("connected to API server successfully", zap.String("address", apiAddress))
+	return nil
+}
+
+// dialAPI opens a connection to the API server at address ("host:port")
+func (a *Agent) dialAPI(apiAddress string) (*grpc.ClientConn, error) {
+	host, _, err := net.SplitHostPort(apiAddress)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create connection options
 	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	}
+
+	if a.config.APIServer.Insecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig, err := util.NewClientTLSConfig(
+			a.config.APIServer.CertFile,
+			a.config.APIServer.KeyFile,
+			a.config.APIServer.CAFile,
+			host,
+			a.config.APIServer.PinnedPublicKeys,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS configuration: %w", err)
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+
+	if a.config.APIServer.AuthToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&nodeTokenCredentials{
+			token:      a.config.APIServer.AuthToken,
+			requireTLS: !a.config.APIServer.Insecure,
+		}))
 	}
 
 	// Connect with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	conn, err := grpc.DialContext(ctx, apiAddress, opts...)
-	if err != nil {
-		return fmt.Errorf("failed to connect to API server: %w", err)
-	}
+	return grpc.DialContext(ctx, apiAddress, opts...)
+}
 
-	a.conn = conn
-	a.apiClient = pbv1.NewAgentServiceClient(conn)
+// client returns the client of the current API server connection
+func (a *Agent) client() pbv1.AgentServiceClient {
+	a.connMu.RLock()
+	defer a.connMu.RUnlock()
+	return a.apiClient
+}
 
-	a.logger.Info("connected to API server successfully")
-	return nil
+// failover moves on to the next configured API server and registers there.
+// It is used when the current server is down or is a warm standby.
+func (a *Agent) failover() {
+	a.connMu.Lock()
+	if len(a.apiAddresses) < 2 {
+		a.connMu.Unlock()
+		return
+	}
+	a.apiAddressIdx = (a.apiAddressIdx + 1) % len(a.apiAddresses)
+	a.connMu.Unlock()
+
+	if err := a.connectToAPI(); err != nil {
+		a.logger.Error("failed to fail over to next API server", zap.Error(err))
+		return
+	}
+	if err := a.registerNode(); err != nil {
+		a.logger.Error("failed to register node after failover", zap.Error(err))
+	}
 }
 
 // registerNode registers the node with the API server
@@ -214,8 +415,11 @@ func (a *Agent) registerNode() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	resp, err := a.apiClient.RegisterNode(ctx, a.nodeInfo)
+	resp, err := a.client().RegisterNode(ctx, a.nodeInfo)
 	if err != nil {
+		if reason := apierror.Reason(err); reason != "" {
+			return fmt.Errorf("failed to register node (%s): %w", reason, err)
+		}
 		return fmt.Errorf("failed to register node: %w", err)
 	}
 
@@ -232,15 +436,53 @@ func (a *Agent) registerNode() error {
 	return nil
 }
 
+// Reload applies the log level and monitor intervals of a reloaded
+// configuration, which must be valid. The report loops restart their
+// tickers with the new intervals. Other changes are logged as needing a
+// restart.
+func (a *Agent) Reload(reloaded *configv1.AgentConfig) {
+	if err := logger.SetLevel(reloaded.Log.Level); err != nil {
+		a.logger.Error("Failed to change the log level", zap.Error(err))
+	}
+
+	a.monitorMu.Lock()
+	a.monitor = reloaded.Monitor
+	close(a.monitorChanged)
+	a.monitorChanged = make(chan struct{})
+	a.monitorMu.Unlock()
+
+	a.logger.Info("Configuration reloaded",
+		zap.String("log_level", reloaded.Log.Level),
+		zap.Duration("heartbeat_interval", reloaded.Monitor.HeartbeatInterval),
+		zap.Duration("system_metrics_interval", reloaded.Monitor.SystemMetricsInterval),
+		zap.Duration("traffic_report_interval", reloaded.Monitor.TrafficReportInterval),
+	)
+	if changed := config.ChangedSections(&a.loaded, reloaded, "log", "monitor"); len(changed) > 0 {
+		a.logger.Warn("Configuration changes need a restart to apply", zap.Strings("sections", changed))
+	}
+}
+
+// monitorConfig returns the current monitor settings and a channel closed
+// when they are replaced
+func (a *Agent) monitorConfig() (configv1.MonitorConfig, <-chan struct{}) {
+	a.monitorMu.RLock()
+	defer a.monitorMu.RUnlock()
+	return a.monitor, a.monitorChanged
+}
+
 // heartbeatLoop sends periodic heartbeats to the API server
 func (a *Agent) heartbeatLoop() {
-	ticker := time.NewTicker(a.config.Monitor.HeartbeatInterval)
+	monitor, changed := a.monitorConfig()
+	ticker := time.NewTicker(monitor.HeartbeatInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-a.shutdownCtx.Done():
 			return
+		case <-changed:
+			monitor, changed = a.monitorConfig()
+			ticker.Reset(monitor.HeartbeatInterval)
 		case <-ticker.C:
 			a.sendHeartbeat()
 		}
@@ -260,21 +502,39 @@ func (a *Agent) sendHeartbeat() {
 	defer cancel()
 
 	// Get current status
-	status := &pbv1.NodeStatus{
+	nodeStatus := &pbv1.NodeStatus{
 		Status:            "online",
 		SingBoxVersion:    "1.0.0",
 		ActiveConnections: int32(10), // TODO: Get actual connection count
 		ErrorMessage:      "",
+		GeoData:           a.geoDataVersions(),
+		Disk:              a.disk.Status(),
+	}
+	nodeStatus.Disk.CachedTraffic = int64(a.singboxManager.CachedTraffic())
+	nodeStatus.Disk.SpooledReports = a.spooledReports()
+	if failure := a.singboxManager.ConfigFailure(); failure != nil {
+		nodeStatus.ConfigFailure = failure
+		nodeStatus.ErrorMessage = failure.Message
 	}
 
 	req := &pbv1.HeartbeatRequest{
 		NodeId: a.nodeInfo.NodeId,
-		Status: status,
+		Status: nodeStatus,
 	}
 
-	resp, err := a.apiClient.Heartbeat(ctx, req)
+	resp, err := a.client().Heartbeat(ctx, req)
 	if err != nil {
 		a.logger.Error("failed to send heartbeat", zap.Error(err))
+		switch {
+		// The API server keeps registrations in memory and forgets them on restart
+		case apierror.Reason(err) == apierror.ReasonNodeNotRegistered:
+			if err := a.registerNode(); err != nil {
+				a.logger.Error("failed to re-register node", zap.Error(err))
+			}
+		// The server is down or is a warm standby, move on to the next one
+		case status.Code(err) == codes.Unavailable:
+			a.failover()
+		}
 		return
 	}
 
@@ -293,13 +553,17 @@ func (a *Agent) sendHeartbeat() {
 
 // metricsReportLoop reports metrics to the API server
 func (a *Agent) metricsReportLoop() {
-	ticker := time.NewTicker(a.config.Monitor.SystemMetricsInterval)
+	monitor, changed := a.monitorConfig()
+	ticker := time.NewTicker(monitor.SystemMetricsInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-a.shutdownCtx.Done():
 			return
+		case <-changed:
+			monitor, changed = a.monitorConfig()
+			ticker.Reset(monitor.SystemMetricsInterval)
 		case <-ticker.C:
 			a.reportMetrics()
 		}
@@ -324,13 +588,16 @@ func (a *Agent) reportMetrics() {
 	defer cancel()
 
 	req := &pbv1.ReportMetricsRequest{
-		NodeId:  a.nodeInfo.NodeId,
-		Metrics: metrics,
+		NodeId:    a.nodeInfo.NodeId,
+		Metrics:   metrics,
+		Timestamp: timestamppb.Now(),
+		ReportId:  a.nextReportID(),
 	}
 
-	resp, err := a.apiClient.ReportMetrics(ctx, req)
+	resp, err := a.client().ReportMetrics(ctx, req)
 	if err != nil {
 		a.logger.Error("failed to report metrics", zap.Error(err))
+		a.spoolReport(spoolKindMetrics, req, err)
 		return
 	}
 
@@ -341,13 +608,17 @@ func (a *Agent) reportMetrics() {
 
 // trafficReportLoop reports traffic statistics to the API server
 func (a *Agent) trafficReportLoop() {
-	ticker := time.NewTicker(a.config.Monitor.TrafficReportInterval)
+	monitor, changed := a.monitorConfig()
+	ticker := time.NewTicker(monitor.TrafficReportInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-a.shutdownCtx.Done():
 			return
+		case <-changed:
+			monitor, changed = a.monitorConfig()
+			ticker.Reset(monitor.TrafficReportInterval)
 		case <-ticker.C:
 			a.reportTraffic()
 		}
@@ -369,17 +640,17 @@ func (a *Agent) reportTraffic() {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
 	req := &pbv1.ReportTrafficRequest{
 		NodeId:      a.nodeInfo.NodeId,
 		UserTraffic: trafficData,
+		Timestamp:   timestamppb.Now(),
+		ReportId:    a.nextReportID(),
 	}
 
-	resp, err := a.apiClient.ReportTraffic(ctx, req)
+	resp, err := a.sendTraffic(req)
 	if err != nil {
 		a.logger.Error("failed to report traffic", zap.Error(err))
+		a.spoolReport(spoolKindTraffic, req, err)
 		return
 	}
 
@@ -388,6 +659,37 @@ func (a *Agent) reportTraffic() {
 	}
 }
 
+// sendTraffic sends a traffic report, retrying failures that may be
+// transient. Retries send the same report ID, so the API server counts a
+// report that reached it before a timeout once.
+func (a *Agent) sendTraffic(req *pbv1.ReportTrafficRequest) (*pbv1.ReportTrafficResponse, error) {
+	monitor, _ := a.monitorConfig()
+	ctx, cancel := context.WithTimeout(a.shutdownCtx, monitor.RetryTimeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		attemptCtx, attemptCancel := context.WithTimeout(ctx, 5*time.Second)
+		resp, err := a.client().ReportTraffic(attemptCtx, req)
+		attemptCancel()
+		if err == nil || !retryableReportError(err) || attempt >= monitor.MaxRetries {
+			return resp, err
+		}
+
+		a.logger.Warn("traffic report failed, retrying",
+			zap.String("report_id", req.ReportId), zap.Int("attempt", attempt+1), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(monitor.RetryBackoff):
+		}
+	}
+}
+
+// nextReportID returns the ID of the next report
+func (a *Agent) nextReportID() string {
+	return fmt.Sprintf("%s-%d", a.runID, a.reportSeq.Add(1))
+}
+
 // commandProcessorLoop processes commands from the API server
 func (a *Agent) commandProcessorLoop() {
 	// This loop would handle long-running command processing
@@ -401,77 +703,116 @@ func (a *Agent) processPendingCommands(commands []*pbv1.PendingCommand) {
 		a.logger.Info("processing command",
 			zap.String("command_id", cmd.CommandId),
 			zap.String("command_type", cmd.Command.Type.String()),
+			zap.String("request_id", cmd.RequestId),
 		)
 
+		var err error
 		switch cmd.Command.Type {
 		case pbv1.UserCommand_ADD_USER:
-			a.handleAddUser(cmd)
+			err = a.handleAddUser(cmd)
 		case pbv1.UserCommand_REMOVE_USER:
-			a.handleRemoveUser(cmd)
+			err = a.handleRemoveUser(cmd)
 		case pbv1.UserCommand_UPDATE_USER:
-			a.handleUpdateUser(cmd)
+			err = a.handleUpdateUser(cmd)
 		case pbv1.UserCommand_RESET_TRAFFIC:
-			a.handleResetTraffic(cmd)
+			err = a.handleResetTraffic(cmd)
+		case pbv1.UserCommand_SYNC_GEODATA:
+			err = a.handleSyncGeoData(cmd)
+		case pbv1.UserCommand_FETCH_LOGS:
+			err = a.handleFetchLogs(cmd)
+		case pbv1.UserCommand_CLOSE_CONNECTIONS:
+			err = a.handleCloseConnections(cmd)
+		case pbv1.UserCommand_RUN_DIAGNOSTIC:
+			// Diagnostics take up to a minute, they report their result themselves
+			a.handleRunDiagnostic(cmd)
+			continue
 		default:
 			a.logger.Warn("unknown command type", zap.String("type", cmd.Command.Type.String()))
+			err = fmt.Errorf("unknown command type %s", cmd.Command.Type)
 		}
+		a.reportCommandResult(cmd, err)
+	}
+}
+
+// reportCommandResult reports the result of a command to the API server,
+// which times out commands whose result does not arrive
+func (a *Agent) reportCommandResult(cmd *pbv1.PendingCommand, cmdErr error) {
+	ctx, cancel := context.WithTimeout(a.shutdownCtx, 5*time.Second)
+	defer cancel()
+
+	req := &pbv1.ReportCommandResultRequest{
+		NodeId:      a.nodeInfo.NodeId,
+		CommandId:   cmd.CommandId,
+		Success:     cmdErr == nil,
+		CompletedAt: timestamppb.Now(),
+	}
+	if cmdErr != nil {
+		req.Output = cmdErr.Error()
+	}
+
+	if _, err := a.client().ReportCommandResult(ctx, req); err != nil {
+		a.logger.Error("failed to report command result", zap.String("command_id", cmd.CommandId), zap.Error(err))
 	}
 }
 
 // handleAddUser handles add user command
-func (a *Agent) handleAddUser(cmd *pbv1.PendingCommand) {
+func (a *Agent) handleAddUser(cmd *pbv1.PendingCommand) error {
 	userID := cmd.Command.UserId
 	a.logger.Info("adding user", zap.String("user_id", userID))
 
 	// Add user to sing-box configuration
 	if err := a.singboxManager.AddUser(userID, cmd.Command.Parameters); err != nil {
 		a.logger.Error("failed to add user", zap.Error(err))
-		return
+		return err
 	}
 
 	a.logger.Info("user added successfully", zap.String("user_id", userID))
+	return nil
 }
 
 // handleRemoveUser handles remove user command
-func (a *Agent) handleRemoveUser(cmd *pbv1.PendingCommand) {
+func (a *Agent) handleRemoveUser(cmd *pbv1.PendingCommand) error {
 	userID := cmd.Command.UserId
 	a.logger.Info("removing user", zap.String("user_id", userID))
 
 	// Remove user from sing-box configuration
 	if err := a.singboxManager.RemoveUser(userID); err != nil {
 		a.logger.Error("failed to remove user", zap.Error(err))
-		return
+		return err
 	}
 
 	a.logger.Info("user removed successfully", zap.String("user_id", userID))
+	return nil
 }
 
 // handleUpdateUser handles update user command
-func (a *Agent) handleUpdateUser(cmd *pbv1.PendingCommand) {
+func (a *Agent) handleUpdateUser(cmd *pbv1.PendingCommand) error {
 	userID := cmd.Command.UserId
 	a.logger.Info("updating user", zap.String("user_id", userID))
 
 	// Update user in sing-box configuration
 	if err := a.singboxManager.UpdateUser(userID, cmd.Command.Parameters); err != nil {
 		a.logger.Error("failed to update user", zap.Error(err))
-		return
+		return err
 	}
 
 	a.logger.Info("user updated successfully", zap.String("user_id", userID))
+	return nil
 }
 
 // handleResetTraffic handles reset traffic command
-func (a *Agent) handleResetTraffic(cmd *pbv1.PendingCommand) {
+func (a *Agent) handleResetTraffic(cmd *pbv1.PendingCommand) error {
 	userID := cmd.Command.UserId
 	a.logger.Info("resetting traffic", zap.String("user_id", userID))
 
 	// Reset traffic for user
 	if err := a.singboxManager.ResetTraffic(userID); err != nil {
 		a.logger.Error("failed to reset traffic", zap.Error(err))
-		return
+		return err
 	}
 
 	a.logger.Info("traffic reset successfully", zap.String("user_id", userID))
+	return nil
 }
 
 // IsRegistered returns true if the node is registered
@@ -488,6 +829,23 @@ func (a *Agent) GetLastSeen() time.Time {
 	return a.lastSeen
 }
 
+// checkAPIServer returns an error unless the node is registered and its
+// heartbeats reached the API server recently. Three heartbeats may be missed.
+func (a *Agent) checkAPIServer(context.Context) error {
+	a.registeredMu.RLock()
+	registered, lastSeen := a.registered, a.lastSeen
+	a.registeredMu.RUnlock()
+
+	if !registered {
+		return fmt.Errorf("node not registered")
+	}
+	monitor, _ := a.monitorConfig()
+	if age := time.Since(lastSeen); age > 3*monitor.HeartbeatInterval {
+		return fmt.Errorf("no heartbeat accepted for %s", age.Round(time.Second))
+	}
+	return nil
+}
+
 // GetNodeInfo returns the node information
 func (a *Agent) GetNodeInfo() *pbv1.RegisterNodeRequest {
 	return a.nodeInfo
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestAgentReload(t *testing.T) {
+	a := &Agent{
+		logger:         zap.NewNop(),
+		monitor:        configv1.MonitorConfig{HeartbeatInterval: 30 * time.Second},
+		monitorChanged: make(chan struct{}),
+	}
+	_, changed := a.monitorConfig()
+
+	config := configv1.DefaultAgentConfig()
+	config.Monitor.HeartbeatInterval = 10 * time.Second
+	a.Reload(config)
+
+	select {
+	case <-changed:
+	default:
+		t.Fatal("Reload did not signal the report loops")
+	}
+	monitor, next := a.monitorConfig()
+	if monitor.HeartbeatInterval != 10*time.Second {
+		t.Errorf("heartbeat interval = %v, want 10s", monitor.HeartbeatInterval)
+	}
+	select {
+	case <-next:
+		t.Error("the channel of the new settings is already closed")
+	default:
+	}
+}
+
+// resultClient records the command results reported to it
+type resultClient struct {
+	pbv1.AgentServiceClient
+	results []*pbv1.ReportCommandResultRequest
+}
+
+func (c *resultClient) ReportCommandResult(_ context.Context, req *pbv1.ReportCommandResultRequest, _ ...grpc.CallOption) (*pbv1.ReportCommandResultResponse, error) {
+	c.results = append(c.results, req)
+	return &pbv1.ReportCommandResultResponse{Success: true}, nil
+}
+
+func TestProcessPendingCommandsReportsResults(t *testing.T) {
+	client := &resultClient{}
+	a := &Agent{
+		logger:        zap.NewNop(),
+		apiClient:     client,
+		nodeInfo:      &pbv1.RegisterNodeRequest{NodeId: "7"},
+		shutdownCtx:   context.Background(),
+		geoDataSyncCh: make(chan struct{}, 1),
+	}
+	a.config.GeoData.Enabled = true
+
+	a.processPendingCommands([]*pbv1.PendingCommand{
+		{CommandId: "sync", Command: &pbv1.UserCommand{Type: pbv1.UserCommand_SYNC_GEODATA}},
+		{CommandId: "unknown", Command: &pbv1.UserCommand{Type: pbv1.UserCommand_CommandType(99)}},
+	})
+
+	if len(client.results) != 2 {
+		t.Fatalf("reported %d results, want 2", len(client.results))
+	}
+	if sync := client.results[0]; sync.CommandId != "sync" || !sync.Success || sync.NodeId != "7" {
+		t.Errorf("geo data sync result = %v, want a success of node 7", sync)
+	}
+	if unknown := client.results[1]; unknown.Success || unknown.Output == "" {
+		t.Errorf("unknown command result = %v, want a failure with its error", unknown)
+	}
+}
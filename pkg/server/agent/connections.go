@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// maxReportedSessions caps the sessions of a user in one traffic report.
+// The traffic of further sessions is reported as the user's traffic only.
+const maxReportedSessions = 200
+
+// closeConnectionsTimeout bounds the closing of the connections of a
+// CLOSE_CONNECTIONS command
+const closeConnectionsTimeout = 30 * time.Second
+
+// trackedConnection is a connection seen at the previous poll
+type trackedConnection struct {
+	userID string
+	bytes  connectionBytes
+}
+
+// sessionTracker breaks the traffic of users down by connection from the
+// connections listed by the Clash API. Each poll reports what the open
+// connections carried since the previous one and the connections closed in
+// between; the last bytes of closed connections are not counted.
+type sessionTracker struct {
+	last map[string]trackedConnection
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{last: make(map[string]trackedConnection)}
+}
+
+// panelUserID returns the user ID of a sing-box user name, "user<ID>",
+// empty for connections of no panel user
+func panelUserID(name string) string {
+	id, ok := strings.CutPrefix(name, "user")
+	if !ok || id == "" {
+		return ""
+	}
+	for _, c := range id {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	return id
+}
+
+// observe returns the sessions of each user since the previous poll
+func (t *sessionTracker) observe(connections *clashConnections) map[string][]*pbv1.ConnectionSession {
+	sessions := make(map[string][]*pbv1.ConnectionSession)
+	seen := make(map[string]trackedConnection, len(connections.Connections))
+	for _, conn := range connections.Connections {
+		user := panelUserID(conn.Metadata.User)
+		if user == "" {
+			continue
+		}
+		current := connectionBytes{upload: conn.Upload, download: conn.Download}
+		seen[conn.ID] = trackedConnection{userID: user, bytes: current}
+
+		previous := t.last[conn.ID].bytes
+		session := &pbv1.ConnectionSession{
+			SessionId: conn.ID,
+			ClientIp:  conn.Metadata.SourceIP,
+			Protocol:  inboundType(conn.Metadata.Type),
+			Network:   conn.Metadata.Network,
+		}
+		if !conn.Start.IsZero() {
+			session.StartTime = timestamppb.New(conn.Start)
+		}
+		if host := firstNonEmpty(conn.Metadata.Host, conn.Metadata.DestinationIP); host != "" {
+			session.Destination = net.JoinHostPort(host, conn.Metadata.DestinationPort)
+		}
+		if current.upload >= previous.upload {
+			session.UploadBytes = current.upload - previous.upload
+		}
+		if current.download >= previous.download {
+			session.DownloadBytes = current.download - previous.download
+		}
+		sessions[user] = append(sessions[user], session)
+	}
+
+	for id, conn := range t.last {
+		if _, open := seen[id]; !open {
+			sessions[conn.userID] = append(sessions[conn.userID], &pbv1.ConnectionSession{SessionId: id, Closed: true})
+		}
+	}
+	t.last = seen
+	return sessions
+}
+
+// firstNonEmpty returns the first of values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// addSession adds the traffic of a session since the previous poll to the
+// cached traffic of its user
+func addSession(traffic *pbv1.UserTraffic, session *pbv1.ConnectionSession) {
+	traffic.UploadBytes += session.UploadBytes
+	traffic.DownloadBytes += session.DownloadBytes
+	for _, cached := range traffic.Sessions {
+		if cached.SessionId == session.SessionId {
+			cached.UploadBytes += session.UploadBytes
+			cached.DownloadBytes += session.DownloadBytes
+			cached.Closed = cached.Closed || session.Closed
+			return
+		}
+	}
+	if len(traffic.Sessions) < maxReportedSessions {
+		traffic.Sessions = append(traffic.Sessions, session)
+	}
+}
+
+// handleCloseConnections closes connections through the Clash API: those of
+// the session_ids parameter, or else all connections of the user. The
+// closed connections are reported closed with the next traffic report.
+func (a *Agent) handleCloseConnections(cmd *pbv1.PendingCommand) error {
+	clash := newClashAPI(a.config.SingBox.ClashAPI)
+	if clash == nil {
+		return errors.New("the Clash API is disabled, connections cannot be closed")
+	}
+
+	ctx, cancel := context.WithTimeout(a.shutdownCtx, closeConnectionsTimeout)
+	defer cancel()
+	connections, err := clash.connections(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list connections: %w", err)
+	}
+
+	userID := cmd.Command.UserId
+	sessions := make(map[string]bool)
+	if ids := cmd.Command.Parameters["session_ids"]; ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			sessions[id] = true
+		}
+	}
+	if len(sessions) == 0 && panelUserID("user"+userID) == "" {
+		return fmt.Errorf("invalid user ID %q", userID)
+	}
+
+	closed := 0
+	for _, conn := range connections.Connections {
+		if len(sessions) > 0 && !sessions[conn.ID] {
+			continue
+		}
+		if len(sessions) == 0 && panelUserID(conn.Metadata.User) != userID {
+			continue
+		}
+		if err := clash.closeConnection(ctx, conn.ID); err != nil {
+			return fmt.Errorf("failed to close connection %s: %w", conn.ID, err)
+		}
+		closed++
+	}
+
+	a.logger.Info("closed connections", zap.String("user_id", userID), zap.Int("closed", closed))
+	return nil
+}
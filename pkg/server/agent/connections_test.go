@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestSessionTracker(t *testing.T) {
+	tracker := newSessionTracker()
+	poll := func(body string) *clashConnections {
+		var connections clashConnections
+		if err := json.Unmarshal([]byte(body), &connections); err != nil {
+			t.Fatalf("decode connections: %v", err)
+		}
+		return &connections
+	}
+
+	sessions := tracker.observe(poll(`{"connections":[
+		{"id":"a","upload":100,"download":1000,"start":"2024-03-01T10:00:00Z","metadata":{"type":"vless/vless-in","network":"tcp","sourceIP":"192.0.2.1","host":"example.com","destinationPort":"443","user":"user1"}},
+		{"id":"b","upload":10,"download":20,"metadata":{"type":"trojan/trojan-in","destinationIP":"198.51.100.1","destinationPort":"53","user":"user2"}},
+		{"id":"c","upload":5,"download":5,"metadata":{"type":"vless/vless-in","user":"admin"}}]}`))
+	if len(sessions) != 2 || len(sessions["1"]) != 1 || len(sessions["2"]) != 1 {
+		t.Fatalf("sessions = %v, want one of users 1 and 2", sessions)
+	}
+	a := sessions["1"][0]
+	if a.SessionId != "a" || a.ClientIp != "192.0.2.1" || a.Protocol != "vless" || a.Destination != "example.com:443" || a.UploadBytes != 100 || a.DownloadBytes != 1000 {
+		t.Errorf("session a = %v, want 100 up and 1000 down over vless to example.com:443", a)
+	}
+	if a.StartTime.AsTime().Hour() != 10 {
+		t.Errorf("session a started at %v, want 10:00", a.StartTime.AsTime())
+	}
+	if b := sessions["2"][0]; b.Destination != "198.51.100.1:53" {
+		t.Errorf("session b destination = %q, want the destination IP", b.Destination)
+	}
+
+	// a carries more and b closes
+	sessions = tracker.observe(poll(`{"connections":[
+		{"id":"a","upload":150,"download":1500,"metadata":{"type":"vless/vless-in","user":"user1"}}]}`))
+	if a := sessions["1"][0]; a.UploadBytes != 50 || a.DownloadBytes != 500 || a.Closed {
+		t.Errorf("session a = %v, want 50 up and 500 down since the last poll", a)
+	}
+	if b := sessions["2"]; len(b) != 1 || b[0].SessionId != "b" || !b[0].Closed {
+		t.Errorf("sessions of user 2 = %v, want b closed", b)
+	}
+}
+
+func TestAddSession(t *testing.T) {
+	traffic := &pbv1.UserTraffic{UserId: "1"}
+	addSession(traffic, &pbv1.ConnectionSession{SessionId: "a", UploadBytes: 1, DownloadBytes: 2})
+	addSession(traffic, &pbv1.ConnectionSession{SessionId: "a", UploadBytes: 2, DownloadBytes: 4})
+	addSession(traffic, &pbv1.ConnectionSession{SessionId: "a", Closed: true})
+
+	if traffic.UploadBytes != 3 || traffic.DownloadBytes != 6 || len(traffic.Sessions) != 1 {
+		t.Fatalf("traffic = %v, want 3 up and 6 down over one session", traffic)
+	}
+	if session := traffic.Sessions[0]; session.UploadBytes != 3 || session.DownloadBytes != 6 || !session.Closed {
+		t.Errorf("session = %v, want 3 up and 6 down, closed", session)
+	}
+
+	// Sessions past the cap count for the user only
+	for i := 0; i < maxReportedSessions; i++ {
+		addSession(traffic, &pbv1.ConnectionSession{SessionId: strconv.Itoa(i), UploadBytes: 1})
+	}
+	if len(traffic.Sessions) != maxReportedSessions || traffic.UploadBytes != 3+maxReportedSessions {
+		t.Errorf("got %d sessions and %d bytes up, want %d sessions and all the traffic",
+			len(traffic.Sessions), traffic.UploadBytes, maxReportedSessions)
+	}
+}
+
+func TestHandleCloseConnections(t *testing.T) {
+	var mu sync.Mutex
+	var closed []string
+	clash := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			mu.Lock()
+			closed = append(closed, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"connections":[
+			{"id":"a","metadata":{"user":"user1"}},
+			{"id":"b","metadata":{"user":"user1"}},
+			{"id":"c","metadata":{"user":"user2"}}]}`))
+	}))
+	defer clash.Close()
+	host, port, _ := net.SplitHostPort(clash.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	a := &Agent{logger: zap.NewNop(), shutdownCtx: context.Background()}
+	a.config.SingBox.ClashAPI = configv1.ClashAPIConfig{Enabled: true, Address: host, Port: portNumber}
+
+	kick := &pbv1.PendingCommand{Command: &pbv1.UserCommand{Type: pbv1.UserCommand_CLOSE_CONNECTIONS, UserId: "1"}}
+	if err := a.handleCloseConnections(kick); err != nil {
+		t.Fatalf("close user connections: %v", err)
+	}
+	kill := &pbv1.PendingCommand{Command: &pbv1.UserCommand{
+		Type:       pbv1.UserCommand_CLOSE_CONNECTIONS,
+		UserId:     "2",
+		Parameters: map[string]string{"session_ids": "c"},
+	}}
+	if err := a.handleCloseConnections(kill); err != nil {
+		t.Fatalf("close connection: %v", err)
+	}
+	if want := []string{"/connections/a", "/connections/b", "/connections/c"}; !slices.Equal(closed, want) {
+		t.Errorf("closed %v, want %v", closed, want)
+	}
+
+	a.config.SingBox.ClashAPI.Enabled = false
+	if err := a.handleCloseConnections(kick); err == nil {
+		t.Error("closed connections without the Clash API")
+	}
+}
@@ -0,0 +1,23 @@
+package agent
+
+import (
+	"context"
+)
+
+// nodeTokenCredentials attaches the node registration token to every RPC
+type nodeTokenCredentials struct {
+	token      string
+	requireTLS bool
+}
+
+// GetRequestMetadata returns the authorization metadata for a call
+func (c *nodeTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		"authorization": "Bearer " + c.token,
+	}, nil
+}
+
+// RequireTransportSecurity reports whether the token may only be sent over TLS
+func (c *nodeTokenCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
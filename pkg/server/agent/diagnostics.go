@@ -0,0 +1,351 @@
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Diagnostic types of RUN_DIAGNOSTIC commands
+const (
+	diagnosticPing       = "ping"
+	diagnosticTraceroute = "traceroute"
+	diagnosticHTTP       = "http"
+	diagnosticTCP        = "tcp"
+	diagnosticSpeedtest  = "speedtest"
+)
+
+const (
+	// diagnosticTimeout bounds a diagnostic
+	diagnosticTimeout = 60 * time.Second
+	// defaultPingCount and maxPingCount bound the echo requests of a ping
+	defaultPingCount = 4
+	maxPingCount     = 20
+	// maxTracerouteHops bounds the hops of a traceroute
+	maxTracerouteHops = 30
+	// maxHTTPBodyBytes bounds the body read by an http diagnostic
+	maxHTTPBodyBytes = 1 << 20
+	// defaultSpeedtestURL is downloaded by speedtests without a target
+	defaultSpeedtestURL = "https://speed.cloudflare.com/__down?bytes=100000000"
+	// speedtestDuration and maxSpeedtestBytes bound the download of a speedtest
+	speedtestDuration = 10 * time.Second
+	maxSpeedtestBytes = 100 << 20
+)
+
+// diagnosticHost matches the host names diagnostics accept, leading dashes
+// would be taken for options by ping and traceroute
+var diagnosticHost = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+
+// diagnosticQuery is a diagnostic asked for by a RUN_DIAGNOSTIC command
+type diagnosticQuery struct {
+	kind   string
+	target string
+	port   int
+	count  int
+}
+
+// parseDiagnosticQuery reads and checks the parameters of a RUN_DIAGNOSTIC
+// command. Only hosts and http(s) URLs are accepted as targets, the agent
+// never runs a shell.
+func parseDiagnosticQuery(parameters map[string]string) (diagnosticQuery, error) {
+	query := diagnosticQuery{kind: parameters["type"], target: parameters["target"], count: defaultPingCount}
+
+	switch query.kind {
+	case diagnosticPing, diagnosticTraceroute, diagnosticTCP:
+		if !validDiagnosticHost(query.target) {
+			return query, fmt.Errorf("invalid target %q, expected a host name or IP address", query.target)
+		}
+	case diagnosticHTTP, diagnosticSpeedtest:
+		if query.kind == diagnosticSpeedtest && query.target == "" {
+			query.target = defaultSpeedtestURL
+		}
+		target, err := url.Parse(query.target)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			return query, fmt.Errorf("invalid target %q, expected an http or https URL", query.target)
+		}
+	default:
+		return query, fmt.Errorf("unknown diagnostic type %q", query.kind)
+	}
+
+	if query.kind == diagnosticTCP {
+		port, err := strconv.Atoi(parameters["port"])
+		if err != nil || port < 1 || port > 65535 {
+			return query, fmt.Errorf("invalid port %q", parameters["port"])
+		}
+		query.port = port
+	}
+	if count := parameters["count"]; count != "" && query.kind == diagnosticPing {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 || n > maxPingCount {
+			return query, fmt.Errorf("count must be between 1 and %d", maxPingCount)
+		}
+		query.count = n
+	}
+	return query, nil
+}
+
+// validDiagnosticHost reports whether target is an IP address or host name
+func validDiagnosticHost(target string) bool {
+	return net.ParseIP(target) != nil || (len(target) <= 253 && diagnosticHost.MatchString(target))
+}
+
+// handleRunDiagnostic starts the diagnostic of a RUN_DIAGNOSTIC command. It
+// runs apart from the heartbeats, one at a time, and reports its result and
+// the command result once done.
+func (a *Agent) handleRunDiagnostic(cmd *pbv1.PendingCommand) {
+	if !a.diagnosing.CompareAndSwap(false, true) {
+		err := errors.New("another diagnostic is running on this node")
+		a.reportDiagnosticResult(cmd, &pbv1.DiagnosticResult{Type: cmd.Command.Parameters["type"], Error: err.Error()})
+		a.reportCommandResult(cmd, err)
+		return
+	}
+
+	go func() {
+		defer a.diagnosing.Store(false)
+
+		ctx, cancel := context.WithTimeout(a.shutdownCtx, diagnosticTimeout)
+		defer cancel()
+
+		var result *pbv1.DiagnosticResult
+		query, err := parseDiagnosticQuery(cmd.Command.Parameters)
+		if err != nil {
+			result = &pbv1.DiagnosticResult{Type: query.kind, Target: query.target, Error: err.Error()}
+		} else {
+			a.logger.Info("running diagnostic", zap.String("type", query.kind), zap.String("target", query.target))
+			result = runDiagnostic(ctx, query)
+		}
+
+		if err := a.reportDiagnosticResult(cmd, result); err != nil {
+			a.reportCommandResult(cmd, err)
+			return
+		}
+		if result.Error != "" {
+			a.reportCommandResult(cmd, errors.New(result.Error))
+			return
+		}
+		a.reportCommandResult(cmd, nil)
+	}()
+}
+
+// reportDiagnosticResult sends the result of a diagnostic to the API server
+func (a *Agent) reportDiagnosticResult(cmd *pbv1.PendingCommand, result *pbv1.DiagnosticResult) error {
+	ctx, cancel := context.WithTimeout(a.shutdownCtx, 10*time.Second)
+	defer cancel()
+
+	_, err := a.client().ReportDiagnosticResult(ctx, &pbv1.ReportDiagnosticResultRequest{
+		NodeId:    a.nodeInfo.NodeId,
+		CommandId: cmd.CommandId,
+		Result:    result,
+	})
+	if err != nil {
+		a.logger.Error("failed to report diagnostic result", zap.String("command_id", cmd.CommandId), zap.Error(err))
+		return fmt.Errorf("failed to report diagnostic result: %w", err)
+	}
+	return nil
+}
+
+// runDiagnostic runs a diagnostic, the error of a failed one is in the result
+func runDiagnostic(ctx context.Context, query diagnosticQuery) *pbv1.DiagnosticResult {
+	result := &pbv1.DiagnosticResult{Type: query.kind, Target: query.target}
+	start := time.Now()
+
+	var err error
+	switch query.kind {
+	case diagnosticPing:
+		err = runPing(ctx, query, result)
+	case diagnosticTraceroute:
+		err = runTraceroute(ctx, query, result)
+	case diagnosticHTTP:
+		err = runHTTPCheck(ctx, query, result)
+	case diagnosticTCP:
+		err = runTCPCheck(ctx, query, result)
+	case diagnosticSpeedtest:
+		err = runSpeedtest(ctx, query, result)
+	}
+
+	result.DurationMs = time.Since(start).Milliseconds()
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+var (
+	pingPackets   = regexp.MustCompile(`(\d+) packets transmitted, (\d+) (?:packets )?received`)
+	pingRTT       = regexp.MustCompile(`min/avg/max[^=]*= ([\d.]+)/([\d.]+)/([\d.]+)`)
+	tracerouteHop = regexp.MustCompile(`^\s*(\d+)\s+(\S+)(?:\s+([\d.]+) ms)?`)
+)
+
+// runPing runs the system ping, which exits non-zero when no reply arrives
+func runPing(ctx context.Context, query diagnosticQuery, result *pbv1.DiagnosticResult) error {
+	output, err := exec.CommandContext(ctx, "ping", "-n", "-c", strconv.Itoa(query.count), "-W", "2", query.target).CombinedOutput()
+	if !parsePingOutput(string(output), result) {
+		if err != nil {
+			return fmt.Errorf("ping failed: %w: %s", err, strings.TrimSpace(string(output)))
+		}
+		return errors.New("ping printed no statistics")
+	}
+	if result.PacketsReceived == 0 {
+		return errors.New("no reply")
+	}
+	return nil
+}
+
+// parsePingOutput reads the statistics of iputils and busybox ping
+func parsePingOutput(output string, result *pbv1.DiagnosticResult) bool {
+	packets := pingPackets.FindStringSubmatch(output)
+	if packets == nil {
+		return false
+	}
+	sent, _ := strconv.Atoi(packets[1])
+	received, _ := strconv.Atoi(packets[2])
+	result.PacketsSent = int32(sent)
+	result.PacketsReceived = int32(received)
+	if sent > 0 {
+		result.LossPercent = float64(sent-received) * 100 / float64(sent)
+	}
+	if rtt := pingRTT.FindStringSubmatch(output); rtt != nil {
+		result.RttMinMs, _ = strconv.ParseFloat(rtt[1], 64)
+		result.RttAvgMs, _ = strconv.ParseFloat(rtt[2], 64)
+		result.RttMaxMs, _ = strconv.ParseFloat(rtt[3], 64)
+	}
+	return true
+}
+
+// runTraceroute runs the system traceroute with one probe per hop
+func runTraceroute(ctx context.Context, query diagnosticQuery, result *pbv1.DiagnosticResult) error {
+	output, err := exec.CommandContext(ctx, "traceroute", "-n", "-q", "1", "-w", "2", "-m", strconv.Itoa(maxTracerouteHops), query.target).CombinedOutput()
+	result.Hops = parseTracerouteOutput(string(output))
+	if err != nil && len(result.Hops) == 0 {
+		return fmt.Errorf("traceroute failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// parseTracerouteOutput reads the hops of traceroute -n -q 1
+func parseTracerouteOutput(output string) []*pbv1.TracerouteHop {
+	var hops []*pbv1.TracerouteHop
+	for _, line := range strings.Split(output, "\n") {
+		match := tracerouteHop.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(match[1])
+		hop := &pbv1.TracerouteHop{Hop: int32(n)}
+		if match[2] != "*" {
+			hop.Address = match[2]
+			hop.RttMs, _ = strconv.ParseFloat(match[3], 64)
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// runHTTPCheck requests the target URL without following redirects and
+// times the phases of the request
+func runHTTPCheck(ctx context.Context, query diagnosticQuery, result *pbv1.DiagnosticResult) error {
+	start := time.Now()
+	var dnsStart, connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:           func(httptrace.DNSDoneInfo) { result.DnsMs = milliseconds(time.Since(dnsStart)) },
+		ConnectStart:      func(string, string) { connectStart = time.Now() },
+		ConnectDone:       func(string, string, error) { result.ConnectMs = milliseconds(time.Since(connectStart)) },
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { result.TlsMs = milliseconds(time.Since(tlsStart)) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			result.RemoteAddress = info.Conn.RemoteAddr().String()
+		},
+		GotFirstResponseByte: func() { result.FirstByteMs = milliseconds(time.Since(start)) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, query.target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := diagnosticHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = int32(resp.StatusCode)
+	result.Bytes, err = io.Copy(io.Discard, io.LimitReader(resp.Body, maxHTTPBodyBytes))
+	return err
+}
+
+// runTCPCheck connects to the target port
+func runTCPCheck(ctx context.Context, query diagnosticQuery, result *pbv1.DiagnosticResult) error {
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(query.target, strconv.Itoa(query.port)))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	result.ConnectMs = milliseconds(time.Since(start))
+	result.RemoteAddress = conn.RemoteAddr().String()
+	return nil
+}
+
+// runSpeedtest downloads the target URL for at most speedtestDuration and
+// maxSpeedtestBytes, measuring the throughput
+func runSpeedtest(ctx context.Context, query diagnosticQuery, result *pbv1.DiagnosticResult) error {
+	ctx, cancel := context.WithTimeout(ctx, speedtestDuration)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, query.target, nil)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	resp, err := diagnosticHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = int32(resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download returned %s", resp.Status)
+	}
+	result.Bytes, err = io.Copy(io.Discard, io.LimitReader(resp.Body, maxSpeedtestBytes))
+	// The download stopping at the time limit is the expected end
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		result.DownloadMbps = float64(result.Bytes) * 8 / elapsed / 1e6
+	}
+	return nil
+}
+
+// diagnosticHTTPClient returns the client of http diagnostics, which does
+// not follow redirects
+func diagnosticHTTPClient() *http.Client {
+	return &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestParseDiagnosticQuery(t *testing.T) {
+	valid := []map[string]string{
+		{"type": "ping", "target": "example.com", "count": "10"},
+		{"type": "traceroute", "target": "2001:db8::1"},
+		{"type": "tcp", "target": "10.0.0.1", "port": "443"},
+		{"type": "http", "target": "https://example.com/health"},
+		{"type": "speedtest"},
+	}
+	for _, parameters := range valid {
+		if _, err := parseDiagnosticQuery(parameters); err != nil {
+			t.Errorf("parseDiagnosticQuery(%v) = %v", parameters, err)
+		}
+	}
+
+	invalid := []map[string]string{
+		{"type": "shell", "target": "example.com"},
+		{"type": "ping", "target": "-f"},
+		{"type": "ping", "target": "example.com; reboot"},
+		{"type": "ping", "target": "example.com", "count": "100"},
+		{"type": "tcp", "target": "example.com"},
+		{"type": "http", "target": "file:///etc/passwd"},
+	}
+	for _, parameters := range invalid {
+		if _, err := parseDiagnosticQuery(parameters); err == nil {
+			t.Errorf("parseDiagnosticQuery(%v) accepted", parameters)
+		}
+	}
+}
+
+func TestParsePingOutput(t *testing.T) {
+	iputils := `PING 1.1.1.1 (1.1.1.1) 56(84) bytes of data.
+64 bytes from 1.1.1.1: icmp_seq=1 ttl=57 time=1.10 ms
+
+--- 1.1.1.1 ping statistics ---
+4 packets transmitted, 3 received, 25% packet loss, time 3004ms
+rtt min/avg/max/mdev = 1.051/1.102/1.160/0.045 ms
+`
+	result := &pbv1.DiagnosticResult{}
+	if !parsePingOutput(iputils, result) {
+		t.Fatal("iputils statistics not parsed")
+	}
+	if result.PacketsSent != 4 || result.PacketsReceived != 3 || result.LossPercent != 25 || result.RttAvgMs != 1.102 {
+		t.Errorf("iputils result = %v", result)
+	}
+
+	busybox := `--- 1.1.1.1 ping statistics ---
+2 packets transmitted, 2 packets received, 0% packet loss
+round-trip min/avg/max = 0.500/0.750/1.000 ms
+`
+	result = &pbv1.DiagnosticResult{}
+	if !parsePingOutput(busybox, result) || result.PacketsReceived != 2 || result.RttMaxMs != 1 {
+		t.Errorf("busybox result = %v", result)
+	}
+}
+
+func TestParseTracerouteOutput(t *testing.T) {
+	output := `traceroute to 1.1.1.1 (1.1.1.1), 30 hops max, 60 byte packets
+ 1  192.168.1.1  0.421 ms
+ 2  *
+ 3  1.1.1.1  9.870 ms
+`
+	hops := parseTracerouteOutput(output)
+	if len(hops) != 3 {
+		t.Fatalf("hops = %v", hops)
+	}
+	if hops[0].Address != "192.168.1.1" || hops[0].RttMs != 0.421 {
+		t.Errorf("hop 1 = %v", hops[0])
+	}
+	if hops[1].Hop != 2 || hops[1].Address != "" {
+		t.Errorf("unanswered hop 2 = %v", hops[1])
+	}
+}
+
+func TestRunTCPAndHTTPDiagnostics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	result := runDiagnostic(context.Background(), diagnosticQuery{kind: diagnosticTCP, target: host, port: portNumber})
+	if !result.Success || result.RemoteAddress == "" {
+		t.Errorf("tcp result = %v", result)
+	}
+
+	result = runDiagnostic(context.Background(), diagnosticQuery{kind: diagnosticHTTP, target: server.URL})
+	if !result.Success || result.StatusCode != http.StatusNoContent {
+		t.Errorf("http result = %v", result)
+	}
+
+	// A closed port fails with the error in the result
+	server.Close()
+	result = runDiagnostic(context.Background(), diagnosticQuery{kind: diagnosticTCP, target: host, port: portNumber})
+	if result.Success || result.Error == "" {
+		t.Errorf("closed port result = %v", result)
+	}
+}
@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// diskGuard watches the disk footprint of the agent: the usage of the
+// filesystem holding its working directory and the size of the sing-box log
+type diskGuard struct {
+	config  configv1.DiskGuardConfig
+	path    string
+	logPath string
+	logger  *zap.Logger
+
+	// statfs returns the total and available bytes of the filesystem of a path
+	statfs func(path string) (total, free uint64, err error)
+
+	mu     sync.RWMutex
+	status *pbv1.DiskStatus
+}
+
+func newDiskGuard(config configv1.AgentConfig, logger *zap.Logger) *diskGuard {
+	path := config.Disk.Path
+	if path == "" {
+		path = config.SingBox.WorkingDir
+	}
+	return &diskGuard{
+		config:  config.Disk,
+		path:    path,
+		logPath: config.SingBox.LogPath,
+		logger:  logger.Named("disk"),
+		statfs:  statfs,
+		status:  &pbv1.DiskStatus{Path: path, Pressure: configv1.DiskPressureOK},
+	}
+}
+
+// statfs returns the total and available bytes of the filesystem of path
+func statfs(path string) (total, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// run checks the disk until done is closed
+func (g *diskGuard) run(done <-chan struct{}) {
+	ticker := time.NewTicker(g.config.CheckInterval)
+	defer ticker.Stop()
+
+	g.check()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			g.check()
+		}
+	}
+}
+
+// check measures the disk and logs pressure changes
+func (g *diskGuard) check() {
+	total, free, err := g.statfs(g.path)
+	if err != nil {
+		g.logger.Error("failed to measure disk usage", zap.String("path", g.path), zap.Error(err))
+		return
+	}
+
+	status := &pbv1.DiskStatus{
+		Path:      g.path,
+		FreeBytes: int64(free),
+		LogBytes:  logFootprint(g.logPath),
+	}
+	if total > 0 {
+		status.UsagePercent = float64(total-free) / float64(total) * 100
+	}
+	status.Pressure = g.pressure(status.UsagePercent)
+
+	g.mu.Lock()
+	previous := g.status.Pressure
+	g.status = status
+	g.mu.Unlock()
+
+	if status.Pressure != previous {
+		g.logger.Warn("disk pressure changed",
+			zap.String("pressure", status.Pressure),
+			zap.String("previous", previous),
+			zap.Float64("usage_percent", status.UsagePercent),
+			zap.Int64("free_bytes", status.FreeBytes),
+		)
+	}
+}
+
+// pressure returns the pressure level of a disk usage
+func (g *diskGuard) pressure(usagePercent float64) string {
+	switch {
+	case usagePercent >= g.config.CriticalPercent:
+		return configv1.DiskPressureCritical
+	case usagePercent >= g.config.WarningPercent:
+		return configv1.DiskPressureWarning
+	default:
+		return configv1.DiskPressureOK
+	}
+}
+
+// Status returns the last measurement
+func (g *diskGuard) Status() *pbv1.DiskStatus {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return proto.Clone(g.status).(*pbv1.DiskStatus)
+}
+
+// Allow returns an error when the disk is too full for an operation that
+// writes to it
+func (g *diskGuard) Allow(operation string) error {
+	status := g.Status()
+	if status.Pressure == configv1.DiskPressureCritical {
+		return fmt.Errorf("refusing %s, disk %s is %.1f%% full", operation, status.Path, status.UsagePercent)
+	}
+	return nil
+}
+
+// logFootprint returns the size of a log file and its rotated backups,
+// named by lumberjack as name-<timestamp>.ext, possibly compressed
+func logFootprint(path string) int64 {
+	if path == "" {
+		return 0
+	}
+	ext := filepath.Ext(path)
+	backups, _ := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext + "*")
+
+	var size int64
+	for _, file := range append(backups, path) {
+		if info, err := os.Stat(file); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func newTestDiskGuard(used uint64) *diskGuard {
+	config := configv1.AgentConfig{}
+	config.SingBox.WorkingDir = "/var/lib/sing-box"
+	config.Disk = configv1.DiskGuardConfig{WarningPercent: 85, CriticalPercent: 95}
+
+	guard := newDiskGuard(config, zap.NewNop())
+	guard.statfs = func(string) (uint64, uint64, error) {
+		return 100, 100 - used, nil
+	}
+	return guard
+}
+
+func TestDiskGuardPressure(t *testing.T) {
+	tests := []struct {
+		used        uint64
+		pressure    string
+		allowWrites bool
+	}{
+		{50, configv1.DiskPressureOK, true},
+		{85, configv1.DiskPressureWarning, true},
+		{94, configv1.DiskPressureWarning, true},
+		{95, configv1.DiskPressureCritical, false},
+		{100, configv1.DiskPressureCritical, false},
+	}
+	for _, tt := range tests {
+		guard := newTestDiskGuard(tt.used)
+		guard.check()
+
+		status := guard.Status()
+		if status.Pressure != tt.pressure {
+			t.Errorf("%d%% used: pressure = %q, want %q", tt.used, status.Pressure, tt.pressure)
+		}
+		if status.FreeBytes != int64(100-tt.used) || status.Path != "/var/lib/sing-box" {
+			t.Errorf("%d%% used: status = %v", tt.used, status)
+		}
+		if err := guard.Allow("test"); (err == nil) != tt.allowWrites {
+			t.Errorf("%d%% used: Allow = %v, want allowed %v", tt.used, err, tt.allowWrites)
+		}
+	}
+}
+
+func TestLogFootprint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"sing-box.log":                            100,
+		"sing-box-2024-01-01T00-00-00.000.log":    200,
+		"sing-box-2024-01-02T00-00-00.000.log.gz": 50,
+		"other.log": 1000,
+	}
+	for name, size := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := logFootprint(filepath.Join(dir, "sing-box.log")); got != 350 {
+		t.Errorf("logFootprint = %d, want 350", got)
+	}
+	if got := logFootprint(""); got != 0 {
+		t.Errorf("logFootprint without a log = %d, want 0", got)
+	}
+}
@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/apierror"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// nodeCredentials are the node ID and token an agent obtained by enrolling
+type nodeCredentials struct {
+	NodeID    string `json:"node_id"`
+	NodeName  string `json:"node_name"`
+	NodeToken string `json:"node_token"`
+}
+
+// enroll gives an agent configured without a node ID the node ID and token
+// it registers with. They are read from the credentials file, or obtained
+// with the enrollment token on the first start and saved to it.
+func (a *Agent) enroll() error {
+	if a.config.Node.NodeID != "" {
+		return nil
+	}
+
+	path := a.config.Node.CredentialsFile
+	credentials, err := loadCredentials(path)
+	if err == nil {
+		a.logger.Info("using enrolled node credentials", zap.String("node_id", credentials.NodeID), zap.String("path", path))
+		a.useCredentials(credentials)
+		return nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if a.config.Node.EnrollmentToken == "" {
+		return errors.New("no node ID is configured and no enrollment token is set")
+	}
+
+	// The token only works once, the credentials must be savable before using it
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+
+	a.connMu.RLock()
+	apiAddress := a.apiAddresses[a.apiAddressIdx]
+	a.connMu.RUnlock()
+
+	a.logger.Info("enrolling node", zap.String("address", apiAddress))
+
+	conn, err := a.dialAPI(apiAddress)
+	if err != nil {
+		return fmt.Errorf("failed to connect to API server: %w", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	resp, err := pbv1.NewAgentServiceClient(conn).EnrollNode(ctx, &pbv1.EnrollNodeRequest{
+		EnrollmentToken: a.config.Node.EnrollmentToken,
+		NodeName:        a.nodeInfo.NodeName,
+		NodeIp:          a.nodeInfo.NodeIp,
+		Version:         a.nodeInfo.Version,
+	})
+	if err != nil {
+		if reason := apierror.Reason(err); reason != "" {
+			return fmt.Errorf("failed to enroll node (%s): %w", reason, err)
+		}
+		return fmt.Errorf("failed to enroll node: %w", err)
+	}
+
+	credentials = &nodeCredentials{NodeID: resp.NodeId, NodeName: resp.NodeName, NodeToken: resp.NodeToken}
+	if err := saveCredentials(path, credentials); err != nil {
+		return err
+	}
+
+	a.logger.Info("node enrolled", zap.String("node_id", resp.NodeId), zap.String("node_name", resp.NodeName))
+	a.useCredentials(credentials)
+	return nil
+}
+
+// useCredentials makes the agent register and authenticate as the enrolled node
+func (a *Agent) useCredentials(credentials *nodeCredentials) {
+	a.config.Node.NodeID = credentials.NodeID
+	a.config.APIServer.AuthToken = credentials.NodeToken
+	a.nodeInfo.NodeId = credentials.NodeID
+	if credentials.NodeName != "" {
+		a.nodeInfo.NodeName = credentials.NodeName
+	}
+}
+
+// loadCredentials reads the credentials file at path
+func loadCredentials(path string) (*nodeCredentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read node credentials: %w", err)
+	}
+	var credentials nodeCredentials
+	if err := json.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse node credentials %s: %w", path, err)
+	}
+	if credentials.NodeID == "" || credentials.NodeToken == "" {
+		return nil, fmt.Errorf("node credentials %s lack the node ID or token", path)
+	}
+	return &credentials, nil
+}
+
+// saveCredentials writes the credentials file at path, readable by its owner
+// only. It is replaced by a rename so that a crash leaves no partial file.
+func saveCredentials(path string, credentials *nodeCredentials) error {
+	data, err := json.MarshalIndent(credentials, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode node credentials: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write node credentials: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write node credentials: %w", err)
+	}
+	return nil
+}
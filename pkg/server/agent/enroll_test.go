@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestEnrollUsesSavedCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := saveCredentials(path, &nodeCredentials{NodeID: "7", NodeName: "edge-1-2", NodeToken: "sbn_token"}); err != nil {
+		t.Fatalf("saveCredentials: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("credentials file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	// An enrollment token that was used already is not sent again
+	config := configv1.AgentConfig{}
+	config.Node.NodeName = "edge-1"
+	config.Node.EnrollmentToken = "sbe_used"
+	config.Node.CredentialsFile = path
+	a := &Agent{
+		config:   config,
+		logger:   zap.NewNop(),
+		nodeInfo: &pbv1.RegisterNodeRequest{NodeName: "edge-1"},
+	}
+	if err := a.enroll(); err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	if a.nodeInfo.NodeId != "7" || a.nodeInfo.NodeName != "edge-1-2" || a.config.APIServer.AuthToken != "sbn_token" {
+		t.Errorf("node %q named %q with token %q, want the saved credentials",
+			a.nodeInfo.NodeId, a.nodeInfo.NodeName, a.config.APIServer.AuthToken)
+	}
+}
+
+func TestEnrollWithoutToken(t *testing.T) {
+	config := configv1.AgentConfig{}
+	config.Node.CredentialsFile = filepath.Join(t.TempDir(), "credentials.json")
+	a := &Agent{config: config, logger: zap.NewNop(), nodeInfo: &pbv1.RegisterNodeRequest{}}
+	if err := a.enroll(); err == nil {
+		t.Error("enroll succeeded without a node ID, credentials or enrollment token")
+	}
+}
@@ -0,0 +1,368 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/metrics"
+)
+
+// clashAPITimeout bounds a read of the Clash API during a scrape
+const clashAPITimeout = 2 * time.Second
+
+// Reasons of sing-box restarts
+const (
+	// restartReasonCrash is a process that exited on its own
+	restartReasonCrash = "crash"
+	// restartReasonStopped is a process found not running, e.g. after a
+	// failed restart
+	restartReasonStopped = "stopped"
+	// restartReasonConfig is a restart applying a configuration change
+	restartReasonConfig = "config"
+	// restartReasonSwap is a blue-green swap applying a configuration change
+	restartReasonSwap = "blue_green"
+)
+
+// reasonCounts counts events by reason
+type reasonCounts struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+func newReasonCounts() *reasonCounts {
+	return &reasonCounts{counts: make(map[string]uint64)}
+}
+
+func (r *reasonCounts) inc(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[reason]++
+}
+
+// snapshot returns a copy of the counts
+func (r *reasonCounts) snapshot() map[string]uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]uint64, len(r.counts))
+	for reason, count := range r.counts {
+		counts[reason] = count
+	}
+	return counts
+}
+
+// clashConnections is the response of the /connections endpoint of the
+// Clash API. The type of a connection is its inbound type and tag, as
+// "vless/vless-in"; its user is the name of the inbound user it
+// authenticated as.
+type clashConnections struct {
+	Connections []struct {
+		ID       string    `json:"id"`
+		Upload   int64     `json:"upload"`
+		Download int64     `json:"download"`
+		Start    time.Time `json:"start"`
+		Metadata struct {
+			Type            string `json:"type"`
+			Network         string `json:"network"`
+			SourceIP        string `json:"sourceIP"`
+			Host            string `json:"host"`
+			DestinationIP   string `json:"destinationIP"`
+			DestinationPort string `json:"destinationPort"`
+			User            string `json:"user"`
+		} `json:"metadata"`
+	} `json:"connections"`
+}
+
+// inbound returns the inbound tag of a Clash API connection type
+func inbound(connectionType string) string {
+	if _, tag, ok := strings.Cut(connectionType, "/"); ok && tag != "" {
+		return tag
+	}
+	return connectionType
+}
+
+// inboundType returns the inbound type of a Clash API connection type
+func inboundType(connectionType string) string {
+	kind, _, _ := strings.Cut(connectionType, "/")
+	return kind
+}
+
+// clashAPI reads the open connections of sing-box from its Clash API
+type clashAPI struct {
+	// url is the /connections endpoint
+	url    string
+	secret string
+	client *http.Client
+}
+
+// newClashAPI returns the client of the Clash API, nil when it is disabled
+func newClashAPI(config configv1.ClashAPIConfig) *clashAPI {
+	if !config.Enabled {
+		return nil
+	}
+	return &clashAPI{
+		url:    "http://" + net.JoinHostPort(config.Address, strconv.Itoa(config.Port)) + "/connections",
+		secret: config.Secret,
+		client: &http.Client{Timeout: clashAPITimeout},
+	}
+}
+
+// connections lists the open connections of sing-box
+func (c *clashAPI) connections(ctx context.Context) (*clashConnections, error) {
+	ctx, cancel := context.WithTimeout(ctx, clashAPITimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("clash API answered %s", resp.Status)
+	}
+
+	var connections clashConnections
+	if err := json.NewDecoder(resp.Body).Decode(&connections); err != nil {
+		return nil, fmt.Errorf("failed to decode clash API connections: %w", err)
+	}
+	return &connections, nil
+}
+
+// connectionBytes are the bytes a connection carried up to a poll
+type connectionBytes struct {
+	upload, download int64
+}
+
+// inboundTraffic adds up the traffic of the connections listed by the Clash
+// API per inbound. Each poll adds what the open connections carried since
+// the previous one; the last bytes of connections closed in between are not
+// counted.
+type inboundTraffic struct {
+	mu     sync.Mutex
+	last   map[string]connectionBytes
+	upload map[string]int64
+	down   map[string]int64
+}
+
+func newInboundTraffic() *inboundTraffic {
+	return &inboundTraffic{
+		last:   make(map[string]connectionBytes),
+		upload: make(map[string]int64),
+		down:   make(map[string]int64),
+	}
+}
+
+// observe adds the traffic of the open connections and returns their count
+// per inbound
+func (t *inboundTraffic) observe(connections *clashConnections) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	open := make(map[string]int)
+	seen := make(map[string]connectionBytes, len(connections.Connections))
+	for _, conn := range connections.Connections {
+		tag := inbound(conn.Metadata.Type)
+		open[tag]++
+		previous := t.last[conn.ID]
+		if conn.Upload >= previous.upload {
+			t.upload[tag] += conn.Upload - previous.upload
+		}
+		if conn.Download >= previous.download {
+			t.down[tag] += conn.Download - previous.download
+		}
+		seen[conn.ID] = connectionBytes{upload: conn.Upload, download: conn.Download}
+	}
+	t.last = seen
+	return open
+}
+
+// totals returns the upload and download bytes counted per inbound
+func (t *inboundTraffic) totals() (map[string]int64, map[string]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	upload := make(map[string]int64, len(t.upload))
+	for tag, bytes := range t.upload {
+		upload[tag] = bytes
+	}
+	download := make(map[string]int64, len(t.down))
+	for tag, bytes := range t.down {
+		download[tag] = bytes
+	}
+	return upload, download
+}
+
+var (
+	exporterUp = prometheus.NewDesc("sing_box_agent_singbox_up",
+		"Whether the sing-box process runs (1) or not (0)", nil, nil)
+	exporterStartTime = prometheus.NewDesc("sing_box_agent_singbox_start_time_seconds",
+		"Start time of the running sing-box process as a Unix timestamp", nil, nil)
+	exporterRestarts = prometheus.NewDesc("sing_box_agent_singbox_restarts_total",
+		"Restarts of sing-box by reason (crash, stopped, config, blue_green)", []string{"reason"}, nil)
+	exporterApplyErrors = prometheus.NewDesc("sing_box_agent_config_apply_errors_total",
+		"Configurations that failed to apply by reason (rejected, rolled_back, crash_loop)", []string{"reason"}, nil)
+	exporterConfigFailure = prometheus.NewDesc("sing_box_agent_config_failure",
+		"1 while the last configuration failure, by reason, is not cleared by an accepted configuration", []string{"reason"}, nil)
+	exporterConnections = prometheus.NewDesc("sing_box_agent_connections",
+		"Open connections of sing-box", nil, nil)
+	exporterInboundConnections = prometheus.NewDesc("sing_box_agent_inbound_connections",
+		"Open connections of sing-box by inbound, read from the Clash API", []string{"inbound"}, nil)
+	exporterInboundTraffic = prometheus.NewDesc("sing_box_agent_inbound_traffic_bytes_total",
+		"Traffic of the connections of sing-box by inbound and direction (upload, download), read from the Clash API", []string{"inbound", "direction"}, nil)
+	exporterClashAPIUp = prometheus.NewDesc("sing_box_agent_clash_api_up",
+		"Whether the last read of the Clash API succeeded (1) or not (0)", nil, nil)
+	exporterCachedTraffic = prometheus.NewDesc("sing_box_agent_cached_traffic_users",
+		"Users with traffic awaiting a report to the API server", nil, nil)
+	exporterRegistered = prometheus.NewDesc("sing_box_agent_registered",
+		"Whether the node is registered with the API server (1) or not (0)", nil, nil)
+)
+
+// exporter exposes the node-local state of the agent and of sing-box to
+// Prometheus. It is read at each scrape, so that nodes can be scraped
+// directly next to what they report to the API server.
+type exporter struct {
+	singbox    *SingboxManager
+	system     *MetricsCollector
+	registered func() bool
+	logger     *zap.Logger
+
+	// clash is nil when the Clash API is disabled
+	clash   *clashAPI
+	traffic *inboundTraffic
+}
+
+func newExporter(config configv1.ClashAPIConfig, singbox *SingboxManager, system *MetricsCollector, registered func() bool, logger *zap.Logger) *exporter {
+	return &exporter{
+		singbox:    singbox,
+		system:     system,
+		registered: registered,
+		logger:     logger,
+		clash:      newClashAPI(config),
+		traffic:    newInboundTraffic(),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (e *exporter) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		exporterUp, exporterStartTime, exporterRestarts, exporterApplyErrors, exporterConfigFailure,
+		exporterConnections, exporterInboundConnections, exporterInboundTraffic, exporterClashAPIUp,
+		exporterCachedTraffic, exporterRegistered,
+	} {
+		ch <- desc
+	}
+}
+
+// Collect implements prometheus.Collector
+func (e *exporter) Collect(ch chan<- prometheus.Metric) {
+	up := 0.0
+	if e.singbox.Running() == nil {
+		up = 1
+		e.singbox.processMu.RLock()
+		startedAt := e.singbox.startedAt
+		e.singbox.processMu.RUnlock()
+		ch <- prometheus.MustNewConstMetric(exporterStartTime, prometheus.GaugeValue, float64(startedAt.Unix()))
+	}
+	ch <- prometheus.MustNewConstMetric(exporterUp, prometheus.GaugeValue, up)
+
+	for reason, count := range e.singbox.restarts.snapshot() {
+		ch <- prometheus.MustNewConstMetric(exporterRestarts, prometheus.CounterValue, float64(count), reason)
+	}
+	for reason, count := range e.singbox.applyErrors.snapshot() {
+		ch <- prometheus.MustNewConstMetric(exporterApplyErrors, prometheus.CounterValue, float64(count), reason)
+	}
+	if failure := e.singbox.ConfigFailure(); failure != nil {
+		ch <- prometheus.MustNewConstMetric(exporterConfigFailure, prometheus.GaugeValue, 1, failure.Reason)
+	}
+	ch <- prometheus.MustNewConstMetric(exporterCachedTraffic, prometheus.GaugeValue, float64(e.singbox.CachedTraffic()))
+
+	registered := 0.0
+	if e.registered != nil && e.registered() {
+		registered = 1
+	}
+	ch <- prometheus.MustNewConstMetric(exporterRegistered, prometheus.GaugeValue, registered)
+
+	e.collectConnections(ch)
+}
+
+// collectConnections exports the connections and the inbound traffic read
+// from the Clash API, or the connection count of the system metrics without
+// the Clash API
+func (e *exporter) collectConnections(ch chan<- prometheus.Metric) {
+	if e.clash == nil {
+		if current := e.system.GetMetrics(); current != nil {
+			ch <- prometheus.MustNewConstMetric(exporterConnections, prometheus.GaugeValue, float64(current.ActiveConnections))
+		}
+		return
+	}
+
+	connections, err := e.clash.connections(context.Background())
+	if err != nil {
+		e.logger.Debug("failed to read the Clash API", zap.Error(err))
+		ch <- prometheus.MustNewConstMetric(exporterClashAPIUp, prometheus.GaugeValue, 0)
+	} else {
+		ch <- prometheus.MustNewConstMetric(exporterClashAPIUp, prometheus.GaugeValue, 1)
+		ch <- prometheus.MustNewConstMetric(exporterConnections, prometheus.GaugeValue, float64(len(connections.Connections)))
+		for tag, count := range e.traffic.observe(connections) {
+			ch <- prometheus.MustNewConstMetric(exporterInboundConnections, prometheus.GaugeValue, float64(count), tag)
+		}
+	}
+
+	upload, download := e.traffic.totals()
+	for tag, bytes := range upload {
+		ch <- prometheus.MustNewConstMetric(exporterInboundTraffic, prometheus.CounterValue, float64(bytes), tag, "upload")
+	}
+	for tag, bytes := range download {
+		ch <- prometheus.MustNewConstMetric(exporterInboundTraffic, prometheus.CounterValue, float64(bytes), tag, "download")
+	}
+}
+
+// closeConnection closes an open connection of sing-box
+func (c *clashAPI) closeConnection(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, clashAPITimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.url+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	if c.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+c.secret)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("clash API answered %s", resp.Status)
+	}
+	return nil
+}
+
+// startMetricsServer serves the node-local metrics, with the Go runtime and
+// process metrics of the agent, on the metrics endpoint
+func (a *Agent) startMetricsServer() error {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newExporter(a.config.SingBox.ClashAPI, a.singboxManager, a.metricsCollector, a.IsRegistered, a.logger))
+	registry.MustRegister(prometheus.NewGoCollector())
+	registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	return metrics.StartRegistryServer(a.config.Metrics, registry, a.logger.Named("metrics"))
+}
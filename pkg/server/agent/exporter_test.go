@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestExporter(t *testing.T) {
+	var body string
+	clash := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/connections" || r.Header.Get("Authorization") != "Bearer clash-secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer clash.Close()
+	host, port, _ := net.SplitHostPort(clash.Listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+
+	manager := newRollbackManager(t)
+	manager.restarts.inc(restartReasonCrash)
+	manager.restarts.inc(restartReasonCrash)
+	manager.restarts.inc(restartReasonSwap)
+	manager.setFailure(configFailureRejected, "decode config: unknown field")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newExporter(configv1.ClashAPIConfig{Enabled: true, Address: host, Port: portNumber, Secret: "clash-secret"},
+		manager, NewMetricsCollector(zap.NewNop()), func() bool { return true }, zap.NewNop()))
+
+	body = `{"connections":[
+		{"id":"a","upload":100,"download":1000,"metadata":{"type":"vless/vless-in"}},
+		{"id":"b","upload":10,"download":20,"metadata":{"type":"vless/vless-in"}},
+		{"id":"c","upload":5,"download":5,"metadata":{"type":"trojan/trojan-in"}}]}`
+	want := `
+# HELP sing_box_agent_connections Open connections of sing-box
+# TYPE sing_box_agent_connections gauge
+sing_box_agent_connections 3
+# HELP sing_box_agent_inbound_connections Open connections of sing-box by inbound, read from the Clash API
+# TYPE sing_box_agent_inbound_connections gauge
+sing_box_agent_inbound_connections{inbound="trojan-in"} 1
+sing_box_agent_inbound_connections{inbound="vless-in"} 2
+# HELP sing_box_agent_singbox_restarts_total Restarts of sing-box by reason (crash, stopped, config, blue_green)
+# TYPE sing_box_agent_singbox_restarts_total counter
+sing_box_agent_singbox_restarts_total{reason="blue_green"} 1
+sing_box_agent_singbox_restarts_total{reason="crash"} 2
+# HELP sing_box_agent_config_apply_errors_total Configurations that failed to apply by reason (rejected, rolled_back, crash_loop)
+# TYPE sing_box_agent_config_apply_errors_total counter
+sing_box_agent_config_apply_errors_total{reason="rejected"} 1
+# HELP sing_box_agent_config_failure 1 while the last configuration failure, by reason, is not cleared by an accepted configuration
+# TYPE sing_box_agent_config_failure gauge
+sing_box_agent_config_failure{reason="rejected"} 1
+# HELP sing_box_agent_singbox_up Whether the sing-box process runs (1) or not (0)
+# TYPE sing_box_agent_singbox_up gauge
+sing_box_agent_singbox_up 0
+# HELP sing_box_agent_clash_api_up Whether the last read of the Clash API succeeded (1) or not (0)
+# TYPE sing_box_agent_clash_api_up gauge
+sing_box_agent_clash_api_up 1
+# HELP sing_box_agent_registered Whether the node is registered with the API server (1) or not (0)
+# TYPE sing_box_agent_registered gauge
+sing_box_agent_registered 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want),
+		"sing_box_agent_connections", "sing_box_agent_inbound_connections", "sing_box_agent_singbox_restarts_total",
+		"sing_box_agent_config_apply_errors_total", "sing_box_agent_config_failure", "sing_box_agent_singbox_up",
+		"sing_box_agent_clash_api_up", "sing_box_agent_registered"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Connection a carries more, b closes and d opens
+	body = `{"connections":[
+		{"id":"a","upload":150,"download":1500,"metadata":{"type":"vless/vless-in"}},
+		{"id":"c","upload":5,"download":5,"metadata":{"type":"trojan/trojan-in"}},
+		{"id":"d","upload":1,"download":2,"metadata":{"type":"vless/vless-in"}}]}`
+	want = `
+# HELP sing_box_agent_inbound_traffic_bytes_total Traffic of the connections of sing-box by inbound and direction (upload, download), read from the Clash API
+# TYPE sing_box_agent_inbound_traffic_bytes_total counter
+sing_box_agent_inbound_traffic_bytes_total{direction="download",inbound="trojan-in"} 5
+sing_box_agent_inbound_traffic_bytes_total{direction="download",inbound="vless-in"} 1522
+sing_box_agent_inbound_traffic_bytes_total{direction="upload",inbound="trojan-in"} 5
+sing_box_agent_inbound_traffic_bytes_total{direction="upload",inbound="vless-in"} 161
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "sing_box_agent_inbound_traffic_bytes_total"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Traffic counted so far stays exported while the Clash API is down
+	clash.Close()
+	want = `
+# HELP sing_box_agent_clash_api_up Whether the last read of the Clash API succeeded (1) or not (0)
+# TYPE sing_box_agent_clash_api_up gauge
+sing_box_agent_clash_api_up 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want), "sing_box_agent_clash_api_up"); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(registry, "sing_box_agent_inbound_traffic_bytes_total"); got != 4 {
+		t.Errorf("inbound traffic series = %d, want 4", got)
+	}
+}
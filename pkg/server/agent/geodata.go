@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/geodata"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// geoDataSyncLoop syncs the geo databases on startup, on every sync interval
+// and whenever the API server asks for it
+func (a *Agent) geoDataSyncLoop() {
+	ticker := time.NewTicker(a.config.GeoData.SyncInterval)
+	defer ticker.Stop()
+
+	a.syncGeoData()
+	for {
+		select {
+		case <-a.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			a.syncGeoData()
+		case <-a.geoDataSyncCh:
+			a.syncGeoData()
+		}
+	}
+}
+
+// handleSyncGeoData schedules a geo data sync; downloads run in the sync loop
+// so that they do not hold up the heartbeat
+func (a *Agent) handleSyncGeoData(cmd *pbv1.PendingCommand) error {
+	if !a.config.GeoData.Enabled {
+		a.logger.Warn("ignoring geo data sync, geo data sync is disabled", zap.String("command_id", cmd.CommandId))
+		return fmt.Errorf("geo data sync is disabled on this node")
+	}
+
+	select {
+	case a.geoDataSyncCh <- struct{}{}:
+	default:
+		// A sync is already pending
+	}
+	return nil
+}
+
+// syncGeoData downloads the databases of the manifest that differ from the
+// local files and restarts sing-box when one changed
+func (a *Agent) syncGeoData() {
+	// Downloads could fill up a nearly full disk
+	if err := a.disk.Allow("geo data sync"); err != nil {
+		a.logger.Warn("skipping geo data sync", zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(a.shutdownCtx, 10*time.Minute)
+	defer cancel()
+
+	resp, err := a.client().GetGeoDataManifest(ctx, &pbv1.GetGeoDataManifestRequest{NodeId: a.nodeInfo.NodeId})
+	if err != nil {
+		if apierror.Reason(err) == apierror.ReasonGeoDataDisabled {
+			a.logger.Debug("geo data distribution is disabled on the API server")
+		} else {
+			a.logger.Error("failed to get geo data manifest", zap.Error(err))
+		}
+		return
+	}
+
+	var updated bool
+	for _, artifact := range resp.Artifacts {
+		changed, err := a.syncGeoDataArtifact(ctx, artifact)
+		if err != nil {
+			a.logger.Error("failed to sync geo data", zap.Error(err), zap.String("name", artifact.Name))
+			continue
+		}
+		updated = updated || changed
+	}
+
+	if updated && a.config.GeoData.RestartOnUpdate {
+		if err := a.singboxManager.applyConfig(); err != nil {
+			a.logger.Error("failed to restart sing-box after geo data update", zap.Error(err))
+		}
+	}
+}
+
+// syncGeoDataArtifact installs one database unless the local file already has
+// its content, and reports whether the file changed
+func (a *Agent) syncGeoDataArtifact(ctx context.Context, artifact *pbv1.GeoDataArtifact) (bool, error) {
+	if !geodata.ValidName(artifact.Name) {
+		return false, fmt.Errorf("invalid geo data name %q", artifact.Name)
+	}
+
+	version := &pbv1.GeoDataVersion{Name: artifact.Name, Version: artifact.Version, Sha256: artifact.Sha256}
+
+	sum, _, err := geodata.FileSHA256(filepath.Join(a.config.GeoData.Dir, artifact.Name))
+	if err == nil && sum == artifact.Sha256 {
+		a.setGeoDataVersion(version)
+		return false, nil
+	}
+
+	reader := &geoDataReader{ctx: ctx, agent: a, artifact: artifact}
+	if _, _, err := geodata.Install(a.config.GeoData.Dir, artifact.Name, reader, artifact.Sha256); err != nil {
+		return false, err
+	}
+
+	a.setGeoDataVersion(version)
+	a.logger.Info("geo data updated",
+		zap.String("name", artifact.Name),
+		zap.String("version", artifact.Version),
+		zap.String("sha256", artifact.Sha256),
+	)
+	return true, nil
+}
+
+// setGeoDataVersion records the version of a local database
+func (a *Agent) setGeoDataVersion(version *pbv1.GeoDataVersion) {
+	a.geoDataMu.Lock()
+	a.geoData[version.Name] = version
+	a.geoDataMu.Unlock()
+}
+
+// geoDataVersions returns the versions of the local databases for the heartbeat
+func (a *Agent) geoDataVersions() []*pbv1.GeoDataVersion {
+	a.geoDataMu.RLock()
+	defer a.geoDataMu.RUnlock()
+
+	versions := make([]*pbv1.GeoDataVersion, 0, len(a.geoData))
+	for _, version := range a.geoData {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Name < versions[j].Name })
+	return versions
+}
+
+// geoDataReader reads an artifact from the API server chunk by chunk
+type geoDataReader struct {
+	ctx      context.Context
+	agent    *Agent
+	artifact *pbv1.GeoDataArtifact
+
+	offset int64
+	buf    []byte
+	eof    bool
+}
+
+// Read implements io.Reader
+func (r *geoDataReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+
+		resp, err := r.agent.client().DownloadGeoData(r.ctx, &pbv1.DownloadGeoDataRequest{
+			NodeId: r.agent.nodeInfo.NodeId,
+			Name:   r.artifact.Name,
+			Sha256: r.artifact.Sha256,
+			Offset: r.offset,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(resp.Data) == 0 && !resp.Eof {
+			return 0, errors.New("empty geo data chunk")
+		}
+		r.buf = resp.Data
+		r.offset += int64(len(resp.Data))
+		r.eof = resp.Eof
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
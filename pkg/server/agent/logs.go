@@ -0,0 +1,262 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Log sources of FETCH_LOGS commands
+const (
+	logSourceSingBox = "sing-box"
+	logSourceAgent   = "agent"
+)
+
+const (
+	// defaultLogLines is the number of lines fetched when the command does
+	// not say
+	defaultLogLines = 200
+	// maxLogLines bounds the lines of a fetch
+	maxLogLines = 5000
+	// maxLogScanBytes is how much of the end of a log file is read
+	maxLogScanBytes = 4 << 20
+	// maxLogBytes bounds the lines uploaded for a fetch, the oldest are dropped
+	maxLogBytes = 256 << 10
+	// logChunkBytes bounds the lines of one upload
+	logChunkBytes = 64 << 10
+)
+
+// logRedactions hide the secrets log lines may hold: user UUIDs and
+// passwords of sing-box inbounds, tokens and key material
+var logRedactions = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`(?i)"(password|passwd|uuid|token|secret|private_key|auth_token|psk)"\s*:\s*"[^"]*"`), `"$1":"[REDACTED]"`},
+	{regexp.MustCompile(`(?i)\b(password|passwd|uuid|token|secret|private_key|psk)=[^\s&"]+`), `$1=[REDACTED]`},
+	{regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9._~+/=-]+`), `Bearer [REDACTED]`},
+	{regexp.MustCompile(`\bsb[ne]_[A-Za-z0-9_-]+`), `[REDACTED]`},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), `[REDACTED]`},
+}
+
+// logTimestamp matches the time of sing-box ("+0800 2024-01-02 15:04:05")
+// and agent ("2024-01-02T15:04:05.000Z") log lines
+var logTimestamp = regexp.MustCompile(`(?:([+-]\d{4}) )?(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}:\d{2})(?:\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+
+// logQuery selects the lines of a FETCH_LOGS command
+type logQuery struct {
+	source string
+	lines  int
+	since  time.Time
+	until  time.Time
+}
+
+// parseLogQuery reads the parameters of a FETCH_LOGS command
+func parseLogQuery(parameters map[string]string) (logQuery, error) {
+	query := logQuery{source: logSourceSingBox, lines: defaultLogLines}
+	if source := parameters["source"]; source != "" {
+		query.source = source
+	}
+	if query.source != logSourceSingBox && query.source != logSourceAgent {
+		return query, fmt.Errorf("unknown log source %q", query.source)
+	}
+	if lines := parameters["lines"]; lines != "" {
+		n, err := strconv.Atoi(lines)
+		if err != nil || n <= 0 {
+			return query, fmt.Errorf("invalid lines %q", lines)
+		}
+		query.lines = min(n, maxLogLines)
+	}
+	for key, field := range map[string]*time.Time{"since": &query.since, "until": &query.until} {
+		if value := parameters[key]; value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s %q", key, value)
+			}
+			*field = t
+		}
+	}
+	return query, nil
+}
+
+// logPath returns the file the logs of a source are written to
+func (a *Agent) logPath(source string) (string, error) {
+	switch source {
+	case logSourceSingBox:
+		if a.config.SingBox.LogPath == "" {
+			return "", fmt.Errorf("sing-box does not log to a file on this node")
+		}
+		return a.config.SingBox.LogPath, nil
+	default:
+		switch output := a.config.Log.Output; output {
+		case "", "stdout", "stderr", "console":
+			return "", fmt.Errorf("the agent logs to %s on this node", output)
+		default:
+			return output, nil
+		}
+	}
+}
+
+// handleFetchLogs reads the log lines a FETCH_LOGS command asks for and
+// uploads them to the API server
+func (a *Agent) handleFetchLogs(cmd *pbv1.PendingCommand) error {
+	lines, truncated, err := a.readLogs(cmd.Command.Parameters)
+	if err != nil {
+		// The API server is waiting for the upload, tell it why there is none
+		if uploadErr := a.uploadLogChunk(&pbv1.UploadNodeLogsRequest{CommandId: cmd.CommandId, Last: true, Error: err.Error()}); uploadErr != nil {
+			a.logger.Error("failed to upload log error", zap.Error(uploadErr))
+		}
+		return err
+	}
+
+	a.logger.Info("uploading logs", zap.String("command_id", cmd.CommandId), zap.Int("lines", len(lines)))
+	return a.uploadLogs(cmd.CommandId, lines, truncated)
+}
+
+// readLogs reads the log lines selected by the parameters of a FETCH_LOGS
+// command
+func (a *Agent) readLogs(parameters map[string]string) ([]string, bool, error) {
+	query, err := parseLogQuery(parameters)
+	if err != nil {
+		return nil, false, err
+	}
+	path, err := a.logPath(query.source)
+	if err != nil {
+		return nil, false, err
+	}
+	return readLogLines(path, query)
+}
+
+// uploadLogs uploads log lines in chunks
+func (a *Agent) uploadLogs(commandID string, lines []string, truncated bool) error {
+	chunk := &pbv1.UploadNodeLogsRequest{CommandId: commandID, Truncated: truncated}
+	size := 0
+	for _, line := range lines {
+		if size+len(line) > logChunkBytes && len(chunk.Lines) > 0 {
+			if err := a.uploadLogChunk(chunk); err != nil {
+				return err
+			}
+			chunk = &pbv1.UploadNodeLogsRequest{CommandId: commandID, Truncated: truncated}
+			size = 0
+		}
+		chunk.Lines = append(chunk.Lines, line)
+		size += len(line)
+	}
+	chunk.Last = true
+	return a.uploadLogChunk(chunk)
+}
+
+// uploadLogChunk uploads one chunk of log lines
+func (a *Agent) uploadLogChunk(chunk *pbv1.UploadNodeLogsRequest) error {
+	ctx, cancel := context.WithTimeout(a.shutdownCtx, 10*time.Second)
+	defer cancel()
+
+	chunk.NodeId = a.nodeInfo.NodeId
+	if _, err := a.client().UploadNodeLogs(ctx, chunk); err != nil {
+		return fmt.Errorf("failed to upload logs: %w", err)
+	}
+	return nil
+}
+
+// readLogLines returns the last lines of the log file at path within the
+// time range of query, redacted and at most maxLogBytes of them. Only the
+// end of the current file is read, not the rotated backups. Lines without a
+// time, such as stack traces, take the time of the line before them.
+func readLogLines(path string, query logQuery) ([]string, bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open log: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read log: %w", err)
+	}
+	offset := max(info.Size()-maxLogScanBytes, 0)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, false, fmt.Errorf("failed to read log: %w", err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	var lines []string
+	var at time.Time
+	first := offset > 0
+	for scanner.Scan() {
+		// The read starts in the middle of a line
+		if first {
+			first = false
+			continue
+		}
+		line := scanner.Text()
+		if t, ok := parseLogTime(line); ok {
+			at = t
+		}
+		if !at.IsZero() && (!query.since.IsZero() && at.Before(query.since) || !query.until.IsZero() && at.After(query.until)) {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) > query.lines {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read log: %w", err)
+	}
+
+	// Keep the newest lines within the size limit
+	truncated := false
+	size := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		lines[i] = redactLogLine(lines[i])
+		size += len(lines[i])
+		if size > maxLogBytes {
+			lines, truncated = lines[i+1:], true
+			break
+		}
+	}
+	return lines, truncated, nil
+}
+
+// parseLogTime returns the time of a log line
+func parseLogTime(line string) (time.Time, bool) {
+	match := logTimestamp.FindStringSubmatch(line)
+	if match == nil {
+		return time.Time{}, false
+	}
+	zone := match[1]
+	if zone == "" {
+		zone = strings.ReplaceAll(match[4], ":", "")
+	}
+	value := match[2] + " " + match[3]
+	switch {
+	case zone == "Z":
+		t, err := time.Parse("2006-01-02 15:04:05", value)
+		return t, err == nil
+	case zone != "":
+		t, err := time.Parse("2006-01-02 15:04:05 -0700", value+" "+zone)
+		return t, err == nil
+	default:
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local)
+		return t, err == nil
+	}
+}
+
+// redactLogLine hides the secrets of a log line
+func redactLogLine(line string) string {
+	for _, redaction := range logRedactions {
+		line = redaction.pattern.ReplaceAllString(line, redaction.replacement)
+	}
+	return line
+}
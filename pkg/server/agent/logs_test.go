@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadLogLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sing-box.log")
+	content := strings.Join([]string{
+		"+0000 2024-01-02 10:00:00 INFO started",
+		"+0000 2024-01-02 11:00:00 INFO inbound user uuid=0b6f2c3e-8a51-4b8f-9f0a-6c1d2e3f4a5b",
+		"+0000 2024-01-02 12:00:00 ERROR panic",
+		"goroutine 1 [running]:",
+		"+0000 2024-01-02 13:00:00 INFO authorization: Bearer sbn_secret",
+	}, "\n") + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+
+	lines, truncated, err := readLogLines(path, logQuery{lines: 2})
+	if err != nil || truncated {
+		t.Fatalf("readLogLines = %v, %v", truncated, err)
+	}
+	if len(lines) != 2 || lines[0] != "goroutine 1 [running]:" {
+		t.Fatalf("last 2 lines = %q", lines)
+	}
+	if strings.Contains(lines[1], "sbn_secret") {
+		t.Errorf("node token not redacted: %q", lines[1])
+	}
+
+	// Lines without a time belong to the line before them
+	lines, _, err = readLogLines(path, logQuery{
+		lines: 10,
+		since: time.Date(2024, 1, 2, 11, 0, 0, 0, time.UTC),
+		until: time.Date(2024, 1, 2, 12, 30, 0, 0, time.UTC),
+	})
+	if err != nil || len(lines) != 3 {
+		t.Fatalf("lines from 11:00 to 12:30 = %q, %v, want 3", lines, err)
+	}
+	if strings.Contains(lines[0], "0b6f2c3e") || !strings.Contains(lines[0], "uuid=[REDACTED]") {
+		t.Errorf("user UUID not redacted: %q", lines[0])
+	}
+}
+
+func TestParseLogQuery(t *testing.T) {
+	query, err := parseLogQuery(map[string]string{"source": "agent", "lines": "99999", "since": "2024-01-02T10:00:00Z"})
+	if err != nil || query.source != logSourceAgent || query.lines != maxLogLines || query.since.IsZero() {
+		t.Fatalf("parseLogQuery = %+v, %v", query, err)
+	}
+	for _, parameters := range []map[string]string{{"source": "kernel"}, {"lines": "-1"}, {"until": "yesterday"}} {
+		if _, err := parseLogQuery(parameters); err == nil {
+			t.Errorf("parseLogQuery(%v) accepted invalid parameters", parameters)
+		}
+	}
+}
+
+func TestRedactLogLine(t *testing.T) {
+	line := redactLogLine(`{"type":"vless","users":[{"uuid":"x","password":"hunter2"}]}`)
+	if strings.Contains(line, "hunter2") || !strings.Contains(line, `"password":"[REDACTED]"`) {
+		t.Errorf("redacted line = %s", line)
+	}
+}
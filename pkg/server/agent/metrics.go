@@ -15,6 +15,9 @@ import (
 type MetricsCollector struct {
 	logger *zap.Logger
 
+	// disk measures the disk usage
+	disk *diskGuard
+
 	// Current metrics
 	metrics   *pbv1.NodeMetrics
 	metricsMu sync.RWMutex
@@ -131,9 +134,10 @@ func (m *MetricsCollector) getMemoryUsage() float32 {
 
 // getDiskUsage gets disk usage percentage
 func (m *MetricsCollector) getDiskUsage() float32 {
-	// Placeholder implementation
-	// In a real implementation, you would use syscalls to get disk usage
-	return 45.0 // Placeholder: 45% disk usage
+	if m.disk == nil {
+		return 0
+	}
+	return float32(m.disk.Status().UsagePercent)
 }
 
 // getNetworkIn gets network input bytes
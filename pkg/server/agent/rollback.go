@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// configCheckTimeout bounds a "sing-box check" run
+const configCheckTimeout = 30 * time.Second
+
+// maxCheckOutput bounds the output of "sing-box check" kept in a failure,
+// which ends up in admin notifications
+const maxCheckOutput = 512
+
+// Reasons of configuration failures reported to the API server
+const (
+	// configFailureRejected is a configuration "sing-box check" rejected,
+	// which was not applied
+	configFailureRejected = "rejected"
+	// configFailureRolledBack is a configuration sing-box crash-looped on,
+	// replaced by the last working one
+	configFailureRolledBack = "rolled_back"
+	// configFailureCrashLoop is a crash loop without a different working
+	// configuration to roll back to
+	configFailureCrashLoop = "crash_loop"
+)
+
+// checkConfig validates the configuration at path with "sing-box check"
+func checkConfig(path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), configCheckTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, "sing-box", "check", "-c", path).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if message := strings.TrimSpace(string(output)); message != "" {
+		if len(message) > maxCheckOutput {
+			message = strings.ToValidUTF8(message[:maxCheckOutput], "") + "..."
+		}
+		return fmt.Errorf("sing-box check failed: %s", message)
+	}
+	return fmt.Errorf("sing-box check failed: %w", err)
+}
+
+// promoteConfig keeps the configuration as the one rolled back to once the
+// process started at startedAt, after the configuration was written, ran on
+// it for rollback.stableAfter
+func (s *SingboxManager) promoteConfig(startedAt time.Time) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if s.promoted || startedAt.Before(s.writtenAt) || time.Since(startedAt) < s.config.SingBox.Rollback.StableAfter {
+		return
+	}
+	data, err := os.ReadFile(s.configPath)
+	if err == nil {
+		err = os.WriteFile(s.lastGoodPath, data, 0644)
+	}
+	if err != nil {
+		s.logger.Error("failed to keep the working configuration", zap.Error(err))
+		return
+	}
+	s.promoted = true
+	s.crashes = 0
+	s.logger.Info("configuration kept as the last working one", zap.String("path", s.lastGoodPath))
+}
+
+// recordExit counts an exit of the process started at startedAt as a crash
+// when it ran for less than rollback.stableAfter, and rolls back to the last
+// working configuration after rollback.maxCrashes crashes in a row
+func (s *SingboxManager) recordExit(startedAt time.Time) {
+	policy := s.config.SingBox.Rollback
+
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	if time.Since(startedAt) >= policy.StableAfter {
+		s.crashes = 0
+		return
+	}
+	s.crashes++
+	if s.crashes < policy.MaxCrashes {
+		return
+	}
+	s.crashes = 0
+
+	current, err := os.ReadFile(s.configPath)
+	if err != nil {
+		s.logger.Error("failed to read the configuration", zap.Error(err))
+		return
+	}
+	lastGood, err := os.ReadFile(s.lastGoodPath)
+	if err != nil || bytes.Equal(current, lastGood) {
+		message := fmt.Sprintf("sing-box exited %d times in a row within %s of starting and there is no other working configuration to roll back to",
+			policy.MaxCrashes, policy.StableAfter)
+		s.logger.Error("sing-box is crash-looping", zap.Int("crashes", policy.MaxCrashes))
+		s.setFailure(configFailureCrashLoop, message)
+		return
+	}
+
+	if err := os.WriteFile(s.configPath, lastGood, 0644); err != nil {
+		s.logger.Error("failed to roll back the configuration", zap.Error(err))
+		return
+	}
+	s.writtenAt = time.Now()
+	s.promoted = true
+
+	message := fmt.Sprintf("sing-box exited %d times in a row within %s of starting, rolled back to the last working configuration",
+		policy.MaxCrashes, policy.StableAfter)
+	s.logger.Error("configuration rolled back", zap.Int("crashes", policy.MaxCrashes))
+	s.setFailure(configFailureRolledBack, message)
+}
+
+// setFailure records a failure to apply a configuration
+func (s *SingboxManager) setFailure(reason, message string) {
+	s.applyErrors.inc(reason)
+	s.failureMu.Lock()
+	defer s.failureMu.Unlock()
+	s.failure = &pbv1.ConfigFailure{
+		Reason:     reason,
+		Message:    message,
+		OccurredAt: timestamppb.Now(),
+	}
+}
+
+// clearFailure forgets the last configuration failure once a configuration
+// was accepted
+func (s *SingboxManager) clearFailure() {
+	s.failureMu.Lock()
+	defer s.failureMu.Unlock()
+	s.failure = nil
+}
+
+// ConfigFailure returns the last failure to apply a configuration, nil when
+// a configuration was accepted since
+func (s *SingboxManager) ConfigFailure() *pbv1.ConfigFailure {
+	s.failureMu.RLock()
+	defer s.failureMu.RUnlock()
+	return s.failure
+}
@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func newRollbackManager(t *testing.T) *SingboxManager {
+	t.Helper()
+	config := configv1.AgentConfig{}
+	config.SingBox.WorkingDir = t.TempDir()
+	config.SingBox.Rollback = configv1.RollbackConfig{StableAfter: time.Minute, MaxCrashes: 2}
+	manager := NewSingboxManager(config, zap.NewNop())
+	manager.check = func(string) error { return nil }
+	return manager
+}
+
+func testConfig(level string) SingboxConfig {
+	var config SingboxConfig
+	config.Log.Level = level
+	return config
+}
+
+func TestWriteConfigRejectsInvalidConfig(t *testing.T) {
+	manager := newRollbackManager(t)
+	if err := manager.writeConfig(testConfig("info")); err != nil {
+		t.Fatalf("writeConfig: %v", err)
+	}
+
+	manager.check = func(string) error { return errors.New("unknown log level") }
+	if err := manager.writeConfig(testConfig("loud")); err == nil {
+		t.Fatal("writeConfig accepted a configuration sing-box check rejected")
+	}
+	if config, err := manager.readConfig(); err != nil || config.Log.Level != "info" {
+		t.Errorf("config level = %v, %v, want the previous configuration kept", config, err)
+	}
+	if failure := manager.ConfigFailure(); failure == nil || failure.Reason != configFailureRejected {
+		t.Fatalf("ConfigFailure = %v, want the rejection", failure)
+	}
+
+	// An accepted configuration clears the failure
+	manager.check = func(string) error { return nil }
+	if err := manager.writeConfig(testConfig("debug")); err != nil {
+		t.Fatalf("writeConfig: %v", err)
+	}
+	if failure := manager.ConfigFailure(); failure != nil {
+		t.Errorf("ConfigFailure = %v after an accepted configuration", failure)
+	}
+}
+
+func TestCrashLoopRollsBack(t *testing.T) {
+	manager := newRollbackManager(t)
+	if err := manager.writeConfig(testConfig("info")); err != nil {
+		t.Fatalf("writeConfig: %v", err)
+	}
+
+	// sing-box ran on the first configuration long enough
+	manager.writtenAt = time.Now().Add(-3 * time.Minute)
+	manager.promoteConfig(time.Now().Add(-2 * time.Minute))
+	if _, err := os.Stat(manager.lastGoodPath); err != nil {
+		t.Fatalf("working configuration not kept: %v", err)
+	}
+
+	if err := manager.writeConfig(testConfig("debug")); err != nil {
+		t.Fatalf("writeConfig: %v", err)
+	}
+	justStarted := time.Now()
+	manager.recordExit(justStarted)
+	if manager.ConfigFailure() != nil {
+		t.Fatal("rolled back before maxCrashes crashes")
+	}
+	manager.recordExit(justStarted)
+
+	if config, err := manager.readConfig(); err != nil || config.Log.Level != "info" {
+		t.Errorf("config level = %v, %v, want the working configuration restored", config, err)
+	}
+	if failure := manager.ConfigFailure(); failure == nil || failure.Reason != configFailureRolledBack {
+		t.Fatalf("ConfigFailure = %v, want the rollback", failure)
+	}
+
+	// Crashing on the working configuration too leaves nothing to roll back to
+	manager.recordExit(time.Now())
+	manager.recordExit(time.Now())
+	if failure := manager.ConfigFailure(); failure == nil || failure.Reason != configFailureCrashLoop {
+		t.Errorf("ConfigFailure = %v, want the crash loop", failure)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	fakeSingbox(t, `echo "FATAL decode config: unknown field"; exit 1`)
+	err := checkConfig("/tmp/config.json")
+	if err == nil || err.Error() != "sing-box check failed: FATAL decode config: unknown field" {
+		t.Errorf("checkConfig = %v, want the output of sing-box check", err)
+	}
+
+	fakeSingbox(t, "exit 0")
+	if err := checkConfig("/tmp/config.json"); err != nil {
+		t.Errorf("checkConfig = %v, want nil", err)
+	}
+}
@@ -0,0 +1,81 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// userInboundTypes are the inbound types users are added to by UUID
+var userInboundTypes = []string{"vless", "vmess"}
+
+// singboxRoute is the routing of the sing-box config. The agent only
+// manages the rules of users, each rule is kept as is.
+type singboxRoute struct {
+	Rules []json.RawMessage `json:"rules,omitempty"`
+	Final string            `json:"final,omitempty"`
+}
+
+// userInbound returns the index of the inbound to add a user to, the first
+// one of the protocols the user may use. Users without a protocols
+// parameter use the vless inbound.
+func userInbound(config *SingboxConfig, parameters map[string]string) (int, error) {
+	protocols := []string{"vless"}
+	if value := parameters["protocols"]; value != "" {
+		protocols = strings.Split(value, ",")
+	}
+	for i, inbound := range config.Inbounds {
+		if slices.Contains(userInboundTypes, inbound.Type) && slices.Contains(protocols, inbound.Type) {
+			return i, nil
+		}
+	}
+	if _, ok := parameters["protocols"]; ok {
+		return -1, fmt.Errorf("no inbound for the allowed protocols %s", parameters["protocols"])
+	}
+	return -1, nil
+}
+
+// setUserRouteRules replaces the route rules of a user with the ones of the
+// route_rules parameter, a JSON array of sing-box rules. The rules of a user
+// are the ones matching only the user, they go before the other rules so
+// that they apply first. Parameters without route rules keep the current
+// ones.
+func setUserRouteRules(config *SingboxConfig, userID string, parameters map[string]string) error {
+	value, ok := parameters["route_rules"]
+	if !ok {
+		return nil
+	}
+	var rules []json.RawMessage
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return fmt.Errorf("invalid route_rules: %w", err)
+	}
+	removeUserRouteRules(config, userID)
+	if len(rules) == 0 {
+		return nil
+	}
+	if config.Route == nil {
+		config.Route = &singboxRoute{}
+	}
+	config.Route.Rules = append(rules, config.Route.Rules...)
+	return nil
+}
+
+// removeUserRouteRules removes the route rules of a user
+func removeUserRouteRules(config *SingboxConfig, userID string) {
+	if config.Route == nil {
+		return
+	}
+	username := "user" + userID
+	rules := config.Route.Rules[:0]
+	for _, rule := range config.Route.Rules {
+		var match struct {
+			AuthUser []string `json:"auth_user"`
+		}
+		if json.Unmarshal(rule, &match) == nil && len(match.AuthUser) == 1 && match.AuthUser[0] == username {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	config.Route.Rules = rules
+}
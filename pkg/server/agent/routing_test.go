@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUserInbound(t *testing.T) {
+	var config SingboxConfig
+	if err := json.Unmarshal([]byte(`{"inbounds":[{"type":"trojan"},{"type":"vmess"},{"type":"vless"}]}`), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if i, err := userInbound(&config, map[string]string{}); err != nil || i != 2 {
+		t.Errorf("default inbound = %d, %v", i, err)
+	}
+	if i, err := userInbound(&config, map[string]string{"protocols": "vmess,vless"}); err != nil || i != 1 {
+		t.Errorf("inbound for vmess,vless = %d, %v", i, err)
+	}
+	if _, err := userInbound(&config, map[string]string{"protocols": "hysteria2"}); err == nil {
+		t.Error("inbound found for a protocol the node does not serve")
+	}
+}
+
+func TestSetUserRouteRules(t *testing.T) {
+	config := &SingboxConfig{Route: &singboxRoute{
+		Rules: []json.RawMessage{json.RawMessage(`{"protocol":"dns","action":"hijack-dns"}`)},
+	}}
+	rules := `[{"auth_user":["user7"],"domain_suffix":["example.com"],"action":"reject"}]`
+	if err := setUserRouteRules(config, "7", map[string]string{"route_rules": rules}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setUserRouteRules(config, "8", map[string]string{"route_rules": rules}); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Route.Rules) != 3 || string(config.Route.Rules[2]) != `{"protocol":"dns","action":"hijack-dns"}` {
+		t.Fatalf("rules = %s", config.Route.Rules)
+	}
+
+	// New rules replace the old ones, no rules remove them
+	if err := setUserRouteRules(config, "7", map[string]string{"route_rules": rules}); err != nil {
+		t.Fatal(err)
+	}
+	if err := setUserRouteRules(config, "8", map[string]string{"route_rules": "[]"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Route.Rules) != 2 {
+		t.Errorf("rules after update = %s", config.Route.Rules)
+	}
+
+	if err := setUserRouteRules(config, "7", map[string]string{"route_rules": "{"}); err == nil {
+		t.Error("invalid route_rules accepted")
+	}
+}
@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// shapingQueueSeconds bounds how much traffic a throttled user can have
+// queued, in seconds of their speed limit. Traffic beyond it is dropped.
+const shapingQueueSeconds = 5
+
+// shapingTracker estimates the speed limit shaping of each user with a token
+// bucket refilled at the user's limit and holding one second of burst
+type shapingTracker struct {
+	mu      sync.Mutex
+	buckets map[string]*shapingBucket
+}
+
+// shapingBucket is the token bucket and the shaping stats of one user since
+// the last report. A negative token count is traffic queued by the limiter.
+type shapingBucket struct {
+	rate      int64
+	tokens    float64
+	last      time.Time
+	throttled bool
+
+	events           int32
+	throttledSeconds float64
+	delayed          int64
+	dropped          int64
+}
+
+func newShapingTracker() *shapingTracker {
+	return &shapingTracker{buckets: make(map[string]*shapingBucket)}
+}
+
+// setLimit sets the speed limit of a user in bytes/sec, 0 removes it
+func (t *shapingTracker) setLimit(userID string, rate int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if rate <= 0 {
+		delete(t.buckets, userID)
+		return
+	}
+	if bucket, ok := t.buckets[userID]; ok {
+		bucket.rate = rate
+		return
+	}
+	t.buckets[userID] = &shapingBucket{rate: rate, tokens: float64(rate)}
+}
+
+// remove forgets a user
+func (t *shapingTracker) remove(userID string) {
+	t.setLimit(userID, 0)
+}
+
+// observe accounts bytes a user transferred up to now
+func (t *shapingTracker) observe(userID string, bytes int64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket, ok := t.buckets[userID]
+	if !ok {
+		return
+	}
+	rate := float64(bucket.rate)
+	if !bucket.last.IsZero() {
+		bucket.tokens += rate * now.Sub(bucket.last).Seconds()
+	}
+	bucket.last = now
+	if bucket.tokens > rate {
+		bucket.tokens = rate
+	}
+	if bucket.tokens >= 0 {
+		bucket.throttled = false
+	}
+
+	available := bucket.tokens
+	bucket.tokens -= float64(bytes)
+	if bucket.tokens >= 0 {
+		return
+	}
+
+	if !bucket.throttled {
+		bucket.throttled = true
+		bucket.events++
+	}
+	// Bytes beyond the tokens available wait for new ones, unless the queue is full
+	excess := float64(bytes)
+	if available > 0 {
+		excess -= available
+	}
+	if queueLimit := -rate * shapingQueueSeconds; bucket.tokens < queueLimit {
+		dropped := queueLimit - bucket.tokens
+		bucket.dropped += int64(dropped)
+		excess -= dropped
+		bucket.tokens = queueLimit
+	}
+	bucket.delayed += int64(excess)
+	bucket.throttledSeconds += excess / rate
+}
+
+// take returns the shaping stats of a user since the last call and resets
+// them. Users that were not throttled return nil.
+func (t *shapingTracker) take(userID string) *pbv1.ShapingStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	bucket, ok := t.buckets[userID]
+	if !ok || (bucket.events == 0 && bucket.delayed == 0 && bucket.dropped == 0) {
+		return nil
+	}
+	stats := &pbv1.ShapingStats{
+		LimitBytesPerSec: bucket.rate,
+		ThrottleEvents:   bucket.events,
+		ThrottledSeconds: bucket.throttledSeconds,
+		DelayedBytes:     bucket.delayed,
+		DroppedBytes:     bucket.dropped,
+	}
+	bucket.events, bucket.throttledSeconds, bucket.delayed, bucket.dropped = 0, 0, 0, 0
+	return stats
+}
+
+// parseSpeedLimit reads the speed_limit parameter of a user command
+func parseSpeedLimit(parameters map[string]string) (int64, bool) {
+	value, ok := parameters["speed_limit"]
+	if !ok {
+		return 0, false
+	}
+	rate, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || rate < 0 {
+		return 0, false
+	}
+	return rate, true
+}
+
+// singboxLimiter is the speed limit of users in the sing-box config. Rates
+// are in sing-box's bandwidth format, such as "8 Mbps".
+type singboxLimiter struct {
+	Tag      string   `json:"tag"`
+	Download string   `json:"download"`
+	Upload   string   `json:"upload"`
+	AuthUser []string `json:"auth_user"`
+}
+
+// limiterTagPrefix prefixes the tags of the limiters the agent renders
+const limiterTagPrefix = "user-limit-"
+
+// setUserLimiter renders the speed limit of a user in bytes/sec as a limiter
+// of the user, in both directions. A zero rate removes it.
+func setUserLimiter(config *SingboxConfig, userID string, rate int64) {
+	tag := limiterTagPrefix + userID
+	limiters := config.Limiters[:0]
+	for _, limiter := range config.Limiters {
+		if limiter.Tag != tag {
+			limiters = append(limiters, limiter)
+		}
+	}
+	if rate > 0 {
+		limiters = append(limiters, singboxLimiter{
+			Tag:      tag,
+			Download: formatLimiterRate(rate),
+			Upload:   formatLimiterRate(rate),
+			AuthUser: []string{"user" + userID},
+		})
+	}
+	config.Limiters = limiters
+}
+
+// formatLimiterRate formats a rate in bytes/sec as a bandwidth in Mbps
+func formatLimiterRate(rate int64) string {
+	mbps := strconv.FormatFloat(float64(rate)*8/1e6, 'f', 3, 64)
+	mbps = strings.TrimRight(strings.TrimRight(mbps, "0"), ".")
+	return mbps + " Mbps"
+}
@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShapingTracker(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	type sample struct {
+		after time.Duration
+		bytes int64
+	}
+	tests := []struct {
+		name        string
+		rate        int64
+		samples     []sample
+		wantNil     bool
+		wantEvents  int32
+		wantSeconds float64
+		wantDelayed int64
+		wantDropped int64
+	}{
+		{"within burst", 1000, []sample{{0, 600}, {time.Second, 1000}}, true, 0, 0, 0, 0},
+		{"delayed", 1000, []sample{{0, 500}, {0, 1500}}, false, 1, 1, 1000, 0},
+		{"throttled twice", 1000, []sample{{0, 2000}, {10 * time.Second, 1500}}, false, 2, 1.5, 1500, 0},
+		{"still throttled", 1000, []sample{{0, 2000}, {0, 1000}}, false, 1, 2, 2000, 0},
+		{"queue full", 100, []sample{{0, 1000}}, false, 1, 5, 500, 400},
+		{"unlimited", 0, []sample{{0, 1 << 30}}, true, 0, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newShapingTracker()
+			tracker.setLimit("1", tt.rate)
+			for _, s := range tt.samples {
+				tracker.observe("1", s.bytes, start.Add(s.after))
+			}
+
+			stats := tracker.take("1")
+			if tt.wantNil {
+				if stats != nil {
+					t.Fatalf("take() = %v, want nil", stats)
+				}
+				return
+			}
+			if stats == nil {
+				t.Fatal("take() = nil, want stats")
+			}
+			if stats.ThrottleEvents != tt.wantEvents || stats.ThrottledSeconds != tt.wantSeconds ||
+				stats.DelayedBytes != tt.wantDelayed || stats.DroppedBytes != tt.wantDropped || stats.LimitBytesPerSec != tt.rate {
+				t.Errorf("take() = %v, want %d events, %gs, %d delayed, %d dropped",
+					stats, tt.wantEvents, tt.wantSeconds, tt.wantDelayed, tt.wantDropped)
+			}
+			if again := tracker.take("1"); again != nil {
+				t.Errorf("second take() = %v, want nil", again)
+			}
+		})
+	}
+}
+
+func TestSetUserLimiter(t *testing.T) {
+	config := &SingboxConfig{}
+	setUserLimiter(config, "7", 1_250_000)
+	setUserLimiter(config, "8", 1000)
+	if len(config.Limiters) != 2 {
+		t.Fatalf("limiters = %+v", config.Limiters)
+	}
+	limiter := config.Limiters[0]
+	if limiter.Tag != "user-limit-7" || limiter.Download != "10 Mbps" || limiter.Upload != "10 Mbps" || limiter.AuthUser[0] != "user7" {
+		t.Errorf("limiter of user 7 = %+v", limiter)
+	}
+	if config.Limiters[1].Download != "0.008 Mbps" {
+		t.Errorf("limiter of user 8 = %+v", config.Limiters[1])
+	}
+
+	// A new limit replaces the old one, no limit removes it
+	setUserLimiter(config, "7", 2_500_000)
+	setUserLimiter(config, "8", 0)
+	if len(config.Limiters) != 1 || config.Limiters[0].Download != "20 Mbps" {
+		t.Errorf("limiters after update = %+v", config.Limiters)
+	}
+}
@@ -8,11 +8,13 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sync"
 	"syscall"
 	"time"
 
 	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
 
 	configv1 "sing-box-web/pkg/config/v1"
 	pbv1 "sing-box-web/pkg/pb/v1"
@@ -23,18 +25,52 @@ type SingboxManager struct {
 	config configv1.AgentConfig
 	logger *zap.Logger
 
-	// Process management
+	// Process management; exited is closed once cmd has exited
 	cmd       *exec.Cmd
+	exited    chan struct{}
 	pid       int
+	startedAt time.Time
 	processMu sync.RWMutex
 
+	// swapMu serializes the application of configuration changes
+	swapMu sync.Mutex
+
+	// output receives the output of the sing-box processes, rotated at the
+	// disk guard's log size; nil discards it
+	output *lumberjack.Logger
+
 	// Configuration
 	configPath string
 	configMu   sync.RWMutex
 
+	// Configurations are checked before they are written. The one sing-box
+	// last ran on for rollback.stableAfter is kept at lastGoodPath; writtenAt,
+	// promoted and crashes are guarded by configMu.
+	check        func(path string) error
+	lastGoodPath string
+	writtenAt    time.Time
+	promoted     bool
+	crashes      int
+
+	// failure is the last failure to apply a configuration, reported to the
+	// API server with the heartbeats
+	failure   *pbv1.ConfigFailure
+	failureMu sync.RWMutex
+
+	// Restarts of sing-box and configuration failures by reason, exported
+	// on the metrics endpoint of the agent
+	restarts    *reasonCounts
+	applyErrors *reasonCounts
+
 	// Traffic data
 	trafficData map[string]*pbv1.UserTraffic
 	trafficMu   sync.RWMutex
+	shaping     *shapingTracker
+
+	// With connection stats the traffic is read from the Clash API and
+	// broken down by connection; clash is nil otherwise
+	clash    *clashAPI
+	sessions *sessionTracker
 
 	// Shutdown
 	shutdownCtx context.Context
@@ -52,7 +88,9 @@ type SingboxConfig struct {
 		Tag    string `json:"tag"`
 		Listen string `json:"listen"`
 		Port   int    `json:"port"`
-		Users  []struct {
+		// ReuseAddr lets a second instance listen on the port (SO_REUSEPORT)
+		ReuseAddr bool `json:"reuse_addr,omitempty"`
+		Users     []struct {
 			UUID     string `json:"uuid"`
 			Username string `json:"username"`
 		} `json:"users,omitempty"`
@@ -61,20 +99,43 @@ type SingboxConfig struct {
 		Type string `json:"type"`
 		Tag  string `json:"tag"`
 	} `json:"outbounds"`
+	// Limiters are the per-user speed limits, enforced by sing-box builds
+	// with the limiter
+	Limiters []singboxLimiter `json:"limiters,omitempty"`
+	// Route holds the route rules rendered from the plans of users
+	Route *singboxRoute `json:"route,omitempty"`
 }
 
 // NewSingboxManager creates a new sing-box manager
 func NewSingboxManager(config configv1.AgentConfig, logger *zap.Logger) *SingboxManager {
 	shutdownCtx, shutdown := context.WithCancel(context.Background())
 
-	return &SingboxManager{
+	manager := &SingboxManager{
 		config:      config,
 		logger:      logger.Named("singbox"),
 		configPath:  filepath.Join(config.SingBox.WorkingDir, "config.json"),
+		check:       checkConfig,
 		trafficData: make(map[string]*pbv1.UserTraffic),
+		shaping:     newShapingTracker(),
+		restarts:    newReasonCounts(),
+		applyErrors: newReasonCounts(),
 		shutdownCtx: shutdownCtx,
 		shutdown:    shutdown,
 	}
+	manager.lastGoodPath = manager.configPath + ".last-good"
+	if config.Monitor.EnableConnectionStats {
+		manager.clash = newClashAPI(config.SingBox.ClashAPI)
+		manager.sessions = newSessionTracker()
+	}
+	if config.SingBox.LogPath != "" {
+		manager.output = &lumberjack.Logger{
+			Filename:   config.SingBox.LogPath,
+			MaxSize:    config.Disk.LogMaxSize,
+			MaxBackups: config.Disk.LogMaxBackups,
+			Compress:   true,
+		}
+	}
+	return manager
 }
 
 // Start starts the sing-box manager
@@ -115,6 +176,10 @@ func (s *SingboxManager) Stop(ctx context.Context) error {
 		s.logger.Error("failed to stop sing-box process", zap.Error(err))
 	}
 
+	if s.output != nil {
+		s.output.Close()
+	}
+
 	return nil
 }
 
@@ -136,7 +201,9 @@ func (s *SingboxManager) initializeConfig() error {
 			Tag    string `json:"tag"`
 			Listen string `json:"listen"`
 			Port   int    `json:"port"`
-			Users  []struct {
+			// ReuseAddr lets a second instance listen on the port (SO_REUSEPORT)
+			ReuseAddr bool `json:"reuse_addr,omitempty"`
+			Users     []struct {
 				UUID     string `json:"uuid"`
 				Username string `json:"username"`
 			} `json:"users,omitempty"`
@@ -167,19 +234,42 @@ func (s *SingboxManager) initializeConfig() error {
 	return s.writeConfig(config)
 }
 
-// writeConfig writes the configuration to file
+// writeConfig writes the configuration to file once "sing-box check"
+// accepted it. A rejected configuration is not written and reported as a
+// configuration failure; the file keeps the configuration sing-box runs on.
 func (s *SingboxManager) writeConfig(config SingboxConfig) error {
 	s.configMu.Lock()
 	defer s.configMu.Unlock()
 
+	// Blue-green swaps run two instances on the same ports
+	for i := range config.Inbounds {
+		config.Inbounds[i].ReuseAddr = s.config.SingBox.SwapMode == configv1.SwapModeBlueGreen
+	}
+
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := ioutil.WriteFile(s.configPath, data, 0644); err != nil {
+	pending := s.configPath + ".pending"
+	if err := ioutil.WriteFile(pending, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	if err := s.check(pending); err != nil {
+		os.Remove(pending)
+		s.logger.Error("configuration rejected", zap.Error(err))
+		s.setFailure(configFailureRejected, err.Error())
+		return fmt.Errorf("configuration rejected: %w", err)
+	}
+	if err := os.Rename(pending, s.configPath); err != nil {
+		os.Remove(pending)
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	s.writtenAt = time.Now()
+	s.promoted = false
+	s.crashes = 0
+	s.clearFailure()
 
 	s.logger.Debug("configuration written", zap.String("path", s.configPath))
 	return nil
@@ -203,6 +293,41 @@ func (s *SingboxManager) readConfig() (*SingboxConfig, error) {
 	return &config, nil
 }
 
+// launchSingbox starts a sing-box process with the current configuration.
+// The returned channel is closed once the process has exited.
+func (s *SingboxManager) launchSingbox() (*exec.Cmd, chan struct{}, error) {
+	cmd := exec.Command("sing-box", "run", "-c", s.configPath)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if s.output != nil {
+		cmd.Stdout = s.output
+		cmd.Stderr = s.output
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start sing-box process: %w", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	return cmd, exited, nil
+}
+
+// terminateSingbox stops a sing-box process and waits for it to exit
+func (s *SingboxManager) terminateSingbox(cmd *exec.Cmd, exited chan struct{}) {
+	// Send SIGTERM
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		s.logger.Error("failed to send SIGTERM", zap.Error(err))
+		// Force kill
+		cmd.Process.Kill()
+	}
+
+	// Wait for process to exit
+	<-exited
+}
+
 // startSingboxProcess starts the sing-box process
 func (s *SingboxManager) startSingboxProcess() error {
 	s.processMu.Lock()
@@ -214,16 +339,15 @@ func (s *SingboxManager) startSingboxProcess() error {
 
 	s.logger.Info("starting sing-box process", zap.String("config", s.configPath))
 
-	// Create command
-	s.cmd = exec.Command("sing-box", "run", "-c", s.configPath)
-	s.cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-
-	// Start process
-	if err := s.cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start sing-box process: %w", err)
+	cmd, exited, err := s.launchSingbox()
+	if err != nil {
+		return err
 	}
 
-	s.pid = s.cmd.Process.Pid
+	s.cmd = cmd
+	s.exited = exited
+	s.pid = cmd.Process.Pid
+	s.startedAt = time.Now()
 	s.logger.Info("sing-box process started", zap.Int("pid", s.pid))
 
 	return nil
@@ -240,18 +364,12 @@ func (s *SingboxManager) stopSingboxProcess() error {
 
 	s.logger.Info("stopping sing-box process", zap.Int("pid", s.pid))
 
-	// Send SIGTERM
-	if err := s.cmd.Process.Signal(syscall.SIGTERM); err != nil {
-		s.logger.Error("failed to send SIGTERM", zap.Error(err))
-		// Force kill
-		s.cmd.Process.Kill()
-	}
-
-	// Wait for process to exit
-	s.cmd.Wait()
+	s.terminateSingbox(s.cmd, s.exited)
 
 	s.cmd = nil
+	s.exited = nil
 	s.pid = 0
+	s.startedAt = time.Time{}
 
 	s.logger.Info("sing-box process stopped")
 	return nil
@@ -274,29 +392,120 @@ func (s *SingboxManager) restartSingboxProcess() error {
 	return nil
 }
 
-// monitorProcess monitors the sing-box process
+// applyConfig makes sing-box use the configuration just written, by the
+// configured swap mode
+func (s *SingboxManager) applyConfig() error {
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+
+	if s.config.SingBox.SwapMode != configv1.SwapModeBlueGreen {
+		s.restarts.inc(restartReasonConfig)
+		return s.restartSingboxProcess()
+	}
+
+	err := s.swapSingboxProcess()
+	if err == nil {
+		s.restarts.inc(restartReasonSwap)
+		return nil
+	}
+	if !s.config.SingBox.BlueGreen.FallbackToRestart {
+		return err
+	}
+	s.logger.Warn("blue-green swap failed, restarting sing-box instead", zap.Error(err))
+	s.restarts.inc(restartReasonConfig)
+	return s.restartSingboxProcess()
+}
+
+// swapSingboxProcess starts a second sing-box process with the current
+// configuration next to the running one, switches to it once it stayed up
+// for the health delay and stops the old one after the drain timeout. The
+// old process keeps serving when the new one fails.
+func (s *SingboxManager) swapSingboxProcess() error {
+	policy := s.config.SingBox.BlueGreen
+	s.logger.Info("starting standby sing-box process", zap.String("config", s.configPath))
+
+	next, nextExited, err := s.launchSingbox()
+	if err != nil {
+		return err
+	}
+
+	// The new process must not exit, e.g. on a port it cannot share
+	select {
+	case <-nextExited:
+		return fmt.Errorf("standby sing-box process exited with code %d during its health check", next.ProcessState.ExitCode())
+	case <-s.shutdownCtx.Done():
+		s.terminateSingbox(next, nextExited)
+		return s.shutdownCtx.Err()
+	case <-time.After(policy.HealthDelay):
+	}
+
+	s.processMu.Lock()
+	old, oldExited, oldPID := s.cmd, s.exited, s.pid
+	s.cmd = next
+	s.exited = nextExited
+	s.pid = next.Process.Pid
+	s.startedAt = time.Now()
+	s.processMu.Unlock()
+
+	s.logger.Info("switched to standby sing-box process", zap.Int("pid", s.pid), zap.Int("old_pid", oldPID))
+	if old == nil {
+		return nil
+	}
+
+	// The old process finishes its connections while the new one accepts
+	go func() {
+		select {
+		case <-oldExited:
+			return
+		case <-s.shutdownCtx.Done():
+		case <-time.After(policy.DrainTimeout):
+		}
+		s.terminateSingbox(old, oldExited)
+		s.logger.Info("old sing-box process stopped", zap.Int("pid", oldPID))
+	}()
+	return nil
+}
+
+// monitorProcess monitors the sing-box process, checking it periodically
+// and as soon as it exits
 func (s *SingboxManager) monitorProcess() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
 	for {
+		s.processMu.RLock()
+		exited := s.exited
+		s.processMu.RUnlock()
+
 		select {
 		case <-s.shutdownCtx.Done():
 			return
 		case <-ticker.C:
 			s.checkProcessHealth()
+		case <-exited:
+			// A crashing sing-box is not restarted in a tight loop
+			select {
+			case <-s.shutdownCtx.Done():
+				return
+			case <-time.After(s.config.SingBox.RestartDelay):
+			}
+			s.checkProcessHealth()
 		}
 	}
 }
 
-// checkProcessHealth checks if the sing-box process is healthy
+// checkProcessHealth checks if the sing-box process is healthy. A process
+// that exited counts as a crash when it ran for less than
+// rollback.stableAfter, one that keeps running makes its configuration the
+// one rolled back to.
 func (s *SingboxManager) checkProcessHealth() {
 	s.processMu.RLock()
-	cmd := s.cmd
+	cmd, exited, startedAt := s.cmd, s.exited, s.startedAt
 	s.processMu.RUnlock()
 
 	if cmd == nil {
 		s.logger.Warn("sing-box process is not running, attempting to restart")
+		s.restarts.inc(restartReasonStopped)
 		if err := s.startSingboxProcess(); err != nil {
 			s.logger.Error("failed to restart sing-box process", zap.Error(err))
 		}
@@ -304,11 +513,16 @@ func (s *SingboxManager) checkProcessHealth() {
 	}
 
 	// Check if process is still alive
-	if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+	select {
+	case <-exited:
 		s.logger.Warn("sing-box process has exited, attempting to restart")
+		s.restarts.inc(restartReasonCrash)
+		s.recordExit(startedAt)
 		if err := s.restartSingboxProcess(); err != nil {
 			s.logger.Error("failed to restart sing-box process", zap.Error(err))
 		}
+	default:
+		s.promoteConfig(startedAt)
 	}
 }
 
@@ -329,24 +543,83 @@ func (s *SingboxManager) trafficCollectionLoop() {
 
 // collectTrafficData collects traffic data from sing-box
 func (s *SingboxManager) collectTrafficData() {
+	if s.clash != nil {
+		s.collectConnectionTraffic()
+		return
+	}
+
 	// This is a placeholder implementation
 	// In a real implementation, you would query sing-box API or parse logs
 	s.trafficMu.Lock()
 	defer s.trafficMu.Unlock()
 
 	// Generate some mock traffic data
-	s.trafficData["user1"] = &pbv1.UserTraffic{
-		UserId:      "1",
+	s.cacheTraffic("user1", &pbv1.UserTraffic{
+		UserId:        "1",
 		UploadBytes:   1024 * 1024,     // 1MB
 		DownloadBytes: 1024 * 1024 * 5, // 5MB
-	}
-	s.trafficData["user2"] = &pbv1.UserTraffic{
-		UserId:      "2",
+	})
+	s.cacheTraffic("user2", &pbv1.UserTraffic{
+		UserId:        "2",
 		UploadBytes:   1024 * 1024 * 2, // 2MB
 		DownloadBytes: 1024 * 1024 * 3, // 3MB
+	})
+
+	now := time.Now()
+	for _, traffic := range s.trafficData {
+		s.shaping.observe(traffic.UserId, traffic.UploadBytes+traffic.DownloadBytes, now)
+	}
+}
+
+// collectConnectionTraffic collects the traffic of users per connection
+// from the Clash API
+func (s *SingboxManager) collectConnectionTraffic() {
+	connections, err := s.clash.connections(s.shutdownCtx)
+	if err != nil {
+		s.logger.Debug("failed to read the Clash API", zap.Error(err))
+		return
+	}
+
+	s.trafficMu.Lock()
+	defer s.trafficMu.Unlock()
+
+	now := time.Now()
+	for userID, sessions := range s.sessions.observe(connections) {
+		key := "user" + userID
+		traffic, cached := s.trafficData[key]
+		if !cached {
+			traffic = &pbv1.UserTraffic{UserId: userID}
+		}
+		for _, session := range sessions {
+			addSession(traffic, session)
+		}
+		s.cacheTraffic(key, traffic)
+		s.shaping.observe(userID, traffic.UploadBytes+traffic.DownloadBytes, now)
 	}
 }
 
+// cacheTraffic keeps the traffic of a user until the next report. While the
+// API server is unreachable the cache is capped at monitor.localCacheSize
+// users, the traffic of further users is dropped. Callers hold trafficMu.
+func (s *SingboxManager) cacheTraffic(key string, traffic *pbv1.UserTraffic) {
+	limit := s.config.Monitor.LocalCacheSize
+	if _, cached := s.trafficData[key]; !cached && limit > 0 && len(s.trafficData) >= limit {
+		s.logger.Warn("traffic cache is full, dropping traffic",
+			zap.String("user_id", traffic.UserId),
+			zap.Int("cached", len(s.trafficData)),
+		)
+		return
+	}
+	s.trafficData[key] = traffic
+}
+
+// CachedTraffic returns the number of users with traffic awaiting a report
+func (s *SingboxManager) CachedTraffic() int {
+	s.trafficMu.RLock()
+	defer s.trafficMu.RUnlock()
+	return len(s.trafficData)
+}
+
 // GetPID returns the sing-box process PID
 func (s *SingboxManager) GetPID() int {
 	s.processMu.RLock()
@@ -354,6 +627,26 @@ func (s *SingboxManager) GetPID() int {
 	return s.pid
 }
 
+// Running returns an error unless the sing-box process is running
+func (s *SingboxManager) Running() error {
+	s.processMu.RLock()
+	defer s.processMu.RUnlock()
+
+	if s.cmd == nil || s.cmd.Process == nil {
+		return fmt.Errorf("sing-box process is not running")
+	}
+	select {
+	case <-s.exited:
+		return fmt.Errorf("sing-box process exited with code %d", s.cmd.ProcessState.ExitCode())
+	default:
+	}
+	// Signal 0 only checks that the process exists
+	if err := s.cmd.Process.Signal(syscall.Signal(0)); err != nil {
+		return fmt.Errorf("sing-box process is gone: %w", err)
+	}
+	return nil
+}
+
 // GetTrafficData returns and clears the traffic data
 func (s *SingboxManager) GetTrafficData() []*pbv1.UserTraffic {
 	s.trafficMu.Lock()
@@ -366,6 +659,7 @@ func (s *SingboxManager) GetTrafficData() []*pbv1.UserTraffic {
 	// Convert map to slice
 	data := make([]*pbv1.UserTraffic, 0, len(s.trafficData))
 	for _, traffic := range s.trafficData {
+		traffic.Shaping = s.shaping.take(traffic.UserId)
 		data = append(data, traffic)
 	}
 
@@ -385,23 +679,32 @@ func (s *SingboxManager) AddUser(userID string, parameters map[string]string) er
 		return fmt.Errorf("failed to read config: %w", err)
 	}
 
+	inbound, err := userInbound(config, parameters)
+	if err != nil {
+		return err
+	}
+	if err := setUserRouteRules(config, userID, parameters); err != nil {
+		return err
+	}
+	if rate, ok := parseSpeedLimit(parameters); ok {
+		s.shaping.setLimit(userID, rate)
+		setUserLimiter(config, userID, rate)
+	}
+
 	// Add user to inbound configuration
 	uuid := parameters["uuid"]
 	if uuid == "" {
 		uuid = "user-" + userID + "-uuid" // Generate UUID
 	}
 
-	for i := range config.Inbounds {
-		if config.Inbounds[i].Type == "vless" {
-			config.Inbounds[i].Users = append(config.Inbounds[i].Users, struct {
-				UUID     string `json:"uuid"`
-				Username string `json:"username"`
-			}{
-				UUID:     uuid,
-				Username: "user" + userID,
-			})
-			break
-		}
+	if inbound >= 0 {
+		config.Inbounds[inbound].Users = append(config.Inbounds[inbound].Users, struct {
+			UUID     string `json:"uuid"`
+			Username string `json:"username"`
+		}{
+			UUID:     uuid,
+			Username: "user" + userID,
+		})
 	}
 
 	// Write updated configuration
@@ -409,8 +712,8 @@ func (s *SingboxManager) AddUser(userID string, parameters map[string]string) er
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
-	// Restart sing-box to apply changes
-	return s.restartSingboxProcess()
+	// Apply the changes by the configured swap mode
+	return s.applyConfig()
 }
 
 // RemoveUser removes a user from the sing-box configuration
@@ -423,10 +726,14 @@ func (s *SingboxManager) RemoveUser(userID string) error {
 		return fmt.Errorf("failed to read config: %w", err)
 	}
 
-	// Remove user from inbound configuration
+	s.shaping.remove(userID)
+	setUserLimiter(config, userID, 0)
+	removeUserRouteRules(config, userID)
+
+	// Remove user from the inbounds it may have been added to
 	username := "user" + userID
 	for i := range config.Inbounds {
-		if config.Inbounds[i].Type == "vless" {
+		if slices.Contains(userInboundTypes, config.Inbounds[i].Type) {
 			newUsers := make([]struct {
 				UUID     string `json:"uuid"`
 				Username string `json:"username"`
@@ -437,7 +744,6 @@ func (s *SingboxManager) RemoveUser(userID string) error {
 				}
 			}
 			config.Inbounds[i].Users = newUsers
-			break
 		}
 	}
 
@@ -446,17 +752,54 @@ func (s *SingboxManager) RemoveUser(userID string) error {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
-	// Restart sing-box to apply changes
-	return s.restartSingboxProcess()
+	// Apply the changes by the configured swap mode
+	return s.applyConfig()
 }
 
 // UpdateUser updates a user in the sing-box configuration
 func (s *SingboxManager) UpdateUser(userID string, parameters map[string]string) error {
 	s.logger.Info("updating user in sing-box", zap.String("user_id", userID))
 
-	// For now, just restart the process
-	// In a real implementation, you would update the user configuration
-	return s.restartSingboxProcess()
+	rate, ok := parseSpeedLimit(parameters)
+	_, routed := parameters["route_rules"]
+	uuid := parameters["uuid"]
+	if !ok && !routed && uuid == "" {
+		// For now, just apply the configuration again
+		// In a real implementation, you would update the user configuration
+		return s.applyConfig()
+	}
+
+	config, err := s.readConfig()
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := setUserRouteRules(config, userID, parameters); err != nil {
+		return err
+	}
+	if ok {
+		s.shaping.setLimit(userID, rate)
+		setUserLimiter(config, userID, rate)
+	}
+	if uuid != "" {
+		setUserUUID(config, userID, uuid)
+	}
+	if err := s.writeConfig(*config); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return s.applyConfig()
+}
+
+// setUserUUID replaces the UUID of a user in the inbounds it was added to,
+// such as after its credentials were regenerated
+func setUserUUID(config *SingboxConfig, userID, uuid string) {
+	username := "user" + userID
+	for i := range config.Inbounds {
+		for j := range config.Inbounds[i].Users {
+			if config.Inbounds[i].Users[j].Username == username {
+				config.Inbounds[i].Users[j].UUID = uuid
+			}
+		}
+	}
 }
 
 // ResetTraffic resets traffic for a user
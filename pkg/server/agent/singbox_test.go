@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// fakeSingbox puts a sing-box script running body on the PATH
+func fakeSingbox(t *testing.T, body string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" + body + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "sing-box"), []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake sing-box: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func newBlueGreenManager(t *testing.T, fallback bool) *SingboxManager {
+	t.Helper()
+	config := configv1.AgentConfig{}
+	config.SingBox.WorkingDir = t.TempDir()
+	config.SingBox.SwapMode = configv1.SwapModeBlueGreen
+	config.SingBox.BlueGreen = configv1.BlueGreenConfig{
+		HealthDelay:       100 * time.Millisecond,
+		DrainTimeout:      50 * time.Millisecond,
+		FallbackToRestart: fallback,
+	}
+	manager := NewSingboxManager(config, zap.NewNop())
+	t.Cleanup(func() {
+		manager.shutdown()
+		manager.stopSingboxProcess()
+	})
+	return manager
+}
+
+func TestBlueGreenSwap(t *testing.T) {
+	fakeSingbox(t, "exec sleep 30")
+	manager := newBlueGreenManager(t, false)
+
+	if err := manager.startSingboxProcess(); err != nil {
+		t.Fatalf("startSingboxProcess failed: %v", err)
+	}
+	manager.processMu.RLock()
+	oldPID, oldExited := manager.pid, manager.exited
+	manager.processMu.RUnlock()
+
+	if err := manager.applyConfig(); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if pid := manager.GetPID(); pid == oldPID || pid == 0 {
+		t.Fatalf("pid after swap = %d, old pid %d", pid, oldPID)
+	}
+	if err := manager.Running(); err != nil {
+		t.Fatalf("new process not running: %v", err)
+	}
+
+	// The old process is stopped once drained
+	select {
+	case <-oldExited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("old process not stopped after the drain timeout")
+	}
+}
+
+func TestBlueGreenSwapKeepsOldProcessOnFailure(t *testing.T) {
+	fakeSingbox(t, "exec sleep 30")
+	manager := newBlueGreenManager(t, false)
+
+	if err := manager.startSingboxProcess(); err != nil {
+		t.Fatalf("startSingboxProcess failed: %v", err)
+	}
+	oldPID := manager.GetPID()
+
+	// New instances fail, e.g. on a port they cannot share
+	fakeSingbox(t, "exit 1")
+	if err := manager.applyConfig(); err == nil {
+		t.Fatal("applyConfig succeeded with a failing instance")
+	}
+	if pid := manager.GetPID(); pid != oldPID {
+		t.Errorf("pid after failed swap = %d, want %d", pid, oldPID)
+	}
+	if err := manager.Running(); err != nil {
+		t.Errorf("old process not running after failed swap: %v", err)
+	}
+}
+
+func TestSetUserUUID(t *testing.T) {
+	var config SingboxConfig
+	raw := `{"inbounds":[{"type":"vless","users":[{"uuid":"old","username":"user7"},{"uuid":"other","username":"user8"}]}]}`
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	setUserUUID(&config, "7", "new")
+	users := config.Inbounds[0].Users
+	if users[0].UUID != "new" || users[1].UUID != "other" {
+		t.Errorf("users = %+v, want only user7 changed", users)
+	}
+}
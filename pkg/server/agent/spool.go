@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Kinds of spooled reports
+const (
+	spoolKindTraffic = "traffic"
+	spoolKindMetrics = "metrics"
+)
+
+// spoolReplayBatch is the number of spooled reports read at once
+const spoolReplayBatch = 100
+
+// spooledReport is a report the API server did not receive, kept on disk
+// until it is replayed
+type spooledReport struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Kind      string `gorm:"not null;size:16"`
+	Payload   []byte `gorm:"not null"`
+}
+
+// TableName returns the table name for spooledReport model
+func (spooledReport) TableName() string {
+	return "spooled_reports"
+}
+
+// reportSpool is a disk-backed queue of traffic and metrics reports, so that
+// the reports of an outage of the API server survive it and restarts of the
+// agent
+type reportSpool struct {
+	db         *gorm.DB
+	maxReports int
+}
+
+// openReportSpool opens the spool database at path, creating it if needed
+func openReportSpool(path string, maxReports int) (*reportSpool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	db, err := gorm.Open(sqlite.Open(path+"?_busy_timeout=5000"), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+	if err := db.AutoMigrate(&spooledReport{}); err != nil {
+		return nil, fmt.Errorf("failed to create spool table: %w", err)
+	}
+	return &reportSpool{db: db, maxReports: maxReports}, nil
+}
+
+// Add appends a report, dropping the oldest reports beyond the maximum. It
+// returns the number of reports dropped.
+func (s *reportSpool) Add(kind string, report proto.Message) (int64, error) {
+	payload, err := proto.Marshal(report)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode %s report: %w", kind, err)
+	}
+	if err := s.db.Create(&spooledReport{Kind: kind, Payload: payload}).Error; err != nil {
+		return 0, err
+	}
+
+	excess := s.Len() - int64(s.maxReports)
+	if excess <= 0 {
+		return 0, nil
+	}
+	oldest := s.db.Model(&spooledReport{}).Select("id").Order("id").Limit(int(excess))
+	result := s.db.Where("id IN (?)", oldest).Delete(&spooledReport{})
+	return result.RowsAffected, result.Error
+}
+
+// Oldest returns up to limit reports, oldest first
+func (s *reportSpool) Oldest(limit int) ([]*spooledReport, error) {
+	var reports []*spooledReport
+	err := s.db.Order("id").Limit(limit).Find(&reports).Error
+	return reports, err
+}
+
+// Remove deletes a replayed report
+func (s *reportSpool) Remove(id uint) error {
+	return s.db.Delete(&spooledReport{}, id).Error
+}
+
+// Len returns the number of spooled reports, 0 when it cannot be counted
+func (s *reportSpool) Len() int64 {
+	var count int64
+	s.db.Model(&spooledReport{}).Count(&count)
+	return count
+}
+
+// Close closes the spool database
+func (s *reportSpool) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// retryableReportError reports whether a report that failed with err may
+// succeed later. Reports the API server refused are not worth keeping.
+func retryableReportError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled, codes.ResourceExhausted, codes.Aborted, codes.Internal:
+		return true
+	default:
+		return false
+	}
+}
+
+// openSpool opens the report spool of the agent. The agent runs without one,
+// losing the reports of outages, when it is disabled or cannot be opened.
+func (a *Agent) openSpool() {
+	monitor := a.config.Monitor
+	if monitor.SpoolPath == "" {
+		return
+	}
+	spool, err := openReportSpool(monitor.SpoolPath, monitor.SpoolMaxReports)
+	if err != nil {
+		a.logger.Error("failed to open report spool, reports of API outages will be lost",
+			zap.String("path", monitor.SpoolPath), zap.Error(err))
+		return
+	}
+	a.spool = spool
+	if pending := spool.Len(); pending > 0 {
+		a.logger.Info("spooled reports awaiting replay", zap.Int64("reports", pending))
+	}
+}
+
+// spooledReports returns the number of reports awaiting replay
+func (a *Agent) spooledReports() int64 {
+	if a.spool == nil {
+		return 0
+	}
+	return a.spool.Len()
+}
+
+// spoolReport keeps a report that failed with err for replay, unless the
+// failure is permanent
+func (a *Agent) spoolReport(kind string, report proto.Message, err error) {
+	if a.spool == nil || !retryableReportError(err) {
+		return
+	}
+	if err := a.disk.Allow("spooling a " + kind + " report"); err != nil {
+		a.logger.Warn("dropping report", zap.String("kind", kind), zap.Error(err))
+		return
+	}
+	dropped, err := a.spool.Add(kind, report)
+	if err != nil {
+		a.logger.Error("failed to spool report", zap.String("kind", kind), zap.Error(err))
+		return
+	}
+	if dropped > 0 {
+		a.logger.Warn("report spool is full, dropped the oldest reports", zap.Int64("dropped", dropped))
+	}
+}
+
+// spoolReplayLoop replays the spooled reports every local cache flush interval
+func (a *Agent) spoolReplayLoop() {
+	monitor, changed := a.monitorConfig()
+	ticker := time.NewTicker(monitor.LocalCacheFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.shutdownCtx.Done():
+			return
+		case <-changed:
+			monitor, changed = a.monitorConfig()
+			ticker.Reset(monitor.LocalCacheFlushInterval)
+		case <-ticker.C:
+			a.replaySpool()
+		}
+	}
+}
+
+// replaySpool sends the spooled reports oldest first, stopping at the first
+// one the API server may accept later. Reports it refused are dropped.
+func (a *Agent) replaySpool() {
+	if !a.IsRegistered() {
+		return
+	}
+
+	var replayed int
+	defer func() {
+		if replayed > 0 {
+			a.logger.Info("replayed spooled reports", zap.Int("reports", replayed))
+		}
+	}()
+
+	for {
+		reports, err := a.spool.Oldest(spoolReplayBatch)
+		if err != nil {
+			a.logger.Error("failed to read report spool", zap.Error(err))
+			return
+		}
+		if len(reports) == 0 {
+			return
+		}
+
+		for _, report := range reports {
+			if err := a.replayReport(report); err != nil {
+				if retryableReportError(err) {
+					a.logger.Warn("replay of spooled reports paused", zap.Error(err))
+					return
+				}
+				a.logger.Error("dropping spooled report the API server refused",
+					zap.String("kind", report.Kind), zap.Error(err))
+			}
+			if err := a.spool.Remove(report.ID); err != nil {
+				a.logger.Error("failed to remove replayed report", zap.Error(err))
+				return
+			}
+			replayed++
+		}
+	}
+}
+
+// replayReport sends a spooled report to the API server
+func (a *Agent) replayReport(report *spooledReport) error {
+	ctx, cancel := context.WithTimeout(a.shutdownCtx, 5*time.Second)
+	defer cancel()
+
+	switch report.Kind {
+	case spoolKindTraffic:
+		req := &pbv1.ReportTrafficRequest{}
+		if err := proto.Unmarshal(report.Payload, req); err != nil {
+			return fmt.Errorf("failed to decode traffic report: %w", err)
+		}
+		req.Replayed = true
+		_, err := a.client().ReportTraffic(ctx, req)
+		return err
+	case spoolKindMetrics:
+		req := &pbv1.ReportMetricsRequest{}
+		if err := proto.Unmarshal(report.Payload, req); err != nil {
+			return fmt.Errorf("failed to decode metrics report: %w", err)
+		}
+		req.Replayed = true
+		_, err := a.client().ReportMetrics(ctx, req)
+		return err
+	default:
+		return fmt.Errorf("unknown spooled report kind %q", report.Kind)
+	}
+}
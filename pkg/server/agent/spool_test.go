@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func newTestSpool(t *testing.T, maxReports int) *reportSpool {
+	t.Helper()
+	spool, err := openReportSpool(filepath.Join(t.TempDir(), "state", "spool.db"), maxReports)
+	if err != nil {
+		t.Fatalf("openReportSpool: %v", err)
+	}
+	t.Cleanup(func() { spool.Close() })
+	return spool
+}
+
+func TestReportSpoolDropsOldest(t *testing.T) {
+	spool := newTestSpool(t, 2)
+	for _, id := range []string{"r1", "r2", "r3"} {
+		if _, err := spool.Add(spoolKindTraffic, &pbv1.ReportTrafficRequest{ReportId: id}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+
+	reports, err := spool.Oldest(10)
+	if err != nil || len(reports) != 2 {
+		t.Fatalf("Oldest = %d reports, %v, want 2", len(reports), err)
+	}
+	var first pbv1.ReportTrafficRequest
+	if err := proto.Unmarshal(reports[0].Payload, &first); err != nil || first.ReportId != "r2" {
+		t.Errorf("oldest report = %q, %v, want r2 once r1 was dropped", first.ReportId, err)
+	}
+}
+
+// replayClient records the replayed traffic reports, failing them with the
+// errors in fail by report ID
+type replayClient struct {
+	pbv1.AgentServiceClient
+	fail     map[string]error
+	replayed []*pbv1.ReportTrafficRequest
+}
+
+func (c *replayClient) ReportTraffic(_ context.Context, req *pbv1.ReportTrafficRequest, _ ...grpc.CallOption) (*pbv1.ReportTrafficResponse, error) {
+	if err := c.fail[req.ReportId]; err != nil {
+		return nil, err
+	}
+	c.replayed = append(c.replayed, req)
+	return &pbv1.ReportTrafficResponse{Success: true}, nil
+}
+
+func TestReplaySpool(t *testing.T) {
+	spool := newTestSpool(t, 10)
+	for _, id := range []string{"r1", "refused", "r2", "r3"} {
+		if _, err := spool.Add(spoolKindTraffic, &pbv1.ReportTrafficRequest{ReportId: id}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	client := &replayClient{fail: map[string]error{
+		"refused": status.Error(codes.InvalidArgument, "invalid node_id format"),
+		"r3":      status.Error(codes.Unavailable, "connection refused"),
+	}}
+	agent := &Agent{
+		logger:      zap.NewNop(),
+		apiClient:   client,
+		spool:       spool,
+		registered:  true,
+		shutdownCtx: context.Background(),
+	}
+
+	// Replay stops at the report the server may accept later, dropping the
+	// one it refused
+	agent.replaySpool()
+	if len(client.replayed) != 2 || client.replayed[0].ReportId != "r1" || client.replayed[1].ReportId != "r2" {
+		t.Fatalf("replayed %v, want r1 and r2", client.replayed)
+	}
+	if !client.replayed[0].Replayed {
+		t.Error("replayed report is not flagged as replayed")
+	}
+	if pending := spool.Len(); pending != 1 {
+		t.Fatalf("%d reports spooled after the replay, want r3 kept", pending)
+	}
+
+	delete(client.fail, "r3")
+	agent.replaySpool()
+	if pending := spool.Len(); pending != 0 || len(client.replayed) != 3 {
+		t.Errorf("%d reports spooled, %d replayed, want all replayed", pending, len(client.replayed))
+	}
+}
+
+// flakyClient fails the first traffic reports with err
+type flakyClient struct {
+	pbv1.AgentServiceClient
+	failures int
+	err      error
+	sent     []string
+}
+
+func (c *flakyClient) ReportTraffic(_ context.Context, req *pbv1.ReportTrafficRequest, _ ...grpc.CallOption) (*pbv1.ReportTrafficResponse, error) {
+	c.sent = append(c.sent, req.ReportId)
+	if len(c.sent) <= c.failures {
+		return nil, c.err
+	}
+	return &pbv1.ReportTrafficResponse{Success: true}, nil
+}
+
+func TestSendTrafficRetriesWithSameReportID(t *testing.T) {
+	client := &flakyClient{failures: 2, err: status.Error(codes.DeadlineExceeded, "deadline exceeded")}
+	agent := &Agent{
+		logger:      zap.NewNop(),
+		apiClient:   client,
+		runID:       "run",
+		shutdownCtx: context.Background(),
+		monitor: configv1.MonitorConfig{
+			MaxRetries:   3,
+			RetryBackoff: time.Millisecond,
+			RetryTimeout: time.Second,
+		},
+	}
+
+	req := &pbv1.ReportTrafficRequest{ReportId: agent.nextReportID()}
+	if _, err := agent.sendTraffic(req); err != nil {
+		t.Fatalf("sendTraffic: %v", err)
+	}
+	if len(client.sent) != 3 || client.sent[0] != "run-1" || client.sent[2] != "run-1" {
+		t.Errorf("sent %v, want run-1 three times", client.sent)
+	}
+	if next := agent.nextReportID(); next != "run-2" {
+		t.Errorf("next report ID = %q, want run-2", next)
+	}
+
+	// Refused reports are not retried
+	client.sent, client.failures, client.err = nil, 1, status.Error(codes.InvalidArgument, "invalid node_id format")
+	if _, err := agent.sendTraffic(req); status.Code(err) != codes.InvalidArgument || len(client.sent) != 1 {
+		t.Errorf("sendTraffic = %v after %d attempts, want the refusal after one", err, len(client.sent))
+	}
+}
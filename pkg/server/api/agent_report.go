@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// reportReceiptRetention is how long the IDs of received reports are
+	// kept. Agents replaying reports spooled for longer may count them twice.
+	reportReceiptRetention = 7 * 24 * time.Hour
+	// reportReceiptPruneInterval is how often expired receipts are deleted
+	reportReceiptPruneInterval = time.Hour
+)
+
+// acceptReport records the receipt of a report of a node, false when the
+// agent sent it before. Reports without an ID, from older agents, are always
+// accepted.
+func (s *AgentService) acceptReport(nodeID uint, reportID string) (bool, error) {
+	if reportID == "" {
+		return true, nil
+	}
+	return s.dbService.GetRepository().AgentReport.Record(nodeID, reportID)
+}
+
+// forgetReport drops the receipt of a report that could not be processed,
+// so that the agent's retry is accepted
+func (s *AgentService) forgetReport(nodeID uint, reportID string) {
+	if reportID == "" {
+		return
+	}
+	if err := s.dbService.GetRepository().AgentReport.Forget(nodeID, reportID); err != nil {
+		s.logger.Error("Failed to forget report receipt", zap.Error(err),
+			zap.Uint("node_id", nodeID), zap.String("report_id", reportID))
+	}
+}
+
+// pruneReportReceipts periodically deletes the receipts of old reports
+func (s *AgentService) pruneReportReceipts(ctx context.Context) {
+	ticker := time.NewTicker(reportReceiptPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.active() {
+				continue
+			}
+			deleted, err := s.dbService.GetRepository().AgentReport.DeleteBefore(time.Now().Add(-reportReceiptRetention))
+			if err != nil {
+				s.logger.Error("Failed to prune report receipts", zap.Error(err))
+				continue
+			}
+			if deleted > 0 {
+				s.logger.Debug("Pruned report receipts", zap.Int64("deleted", deleted))
+			}
+		}
+	}
+}
@@ -2,20 +2,37 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
-	"gorm.io/gorm"
 
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/blocklist"
 	configv1 "sing-box-web/pkg/config/v1"
 	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/geodata"
+	"sing-box-web/pkg/ha"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/metrics"
 	"sing-box-web/pkg/models"
 	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+	"sing-box-web/pkg/settings"
+	"sing-box-web/pkg/traffic"
 )
 
 // AgentService implements the AgentService gRPC service
@@ -33,6 +50,55 @@ type AgentService struct {
 	// Command queue for nodes
 	commandQueues map[string]chan *pbv1.PendingCommand
 	queuesMux     sync.RWMutex
+
+	// Callers of ExecuteUserCommand waiting for the results of their commands
+	commandResults commandResults
+
+	// Admins waiting for the logs of nodes
+	logUploads logUploads
+
+	// Admins waiting for the diagnostics of nodes
+	diagnosticResults diagnosticResults
+
+	// Buffered traffic ingestion
+	ingester *traffic.Ingester
+
+	// Previous network counter reading per node for rate computation
+	counters    map[uint]networkCounter
+	countersMux sync.Mutex
+
+	// Lease elector when running with a warm standby, nil otherwise
+	elector *ha.Elector
+
+	// Geo data cache when distribution is enabled, nil otherwise
+	geoData *geodata.Cache
+
+	// Disposable domain list updater when lists are configured, nil otherwise
+	blocklists *blocklist.Updater
+
+	// User alert engine when user alerts are enabled, nil otherwise
+	alerts *alert.Engine
+
+	// Mailer of the monthly traffic reports when mail is enabled, nil otherwise
+	mailer *mail.Mailer
+
+	// Event bus of the domain events, also streamed by ManagementService.WatchEvents
+	events *events.Bus
+
+	// Reports of the current witness window when cross-checking is enabled, nil otherwise
+	witness *nodeWitness
+
+	// Business settings, replaced when the configuration is reloaded
+	businessConfig atomic.Pointer[configv1.BusinessConfig]
+
+	// System settings admins change at runtime, overriding some business settings
+	settings *settings.Store
+
+	// Jobs driven by the business settings, restarted when they are reloaded
+	ctx      context.Context
+	stopJobs context.CancelFunc
+	jobs     sync.WaitGroup
+	jobsMux  sync.Mutex
 }
 
 // NodeState represents the state of a connected node
@@ -41,33 +107,143 @@ type NodeState struct {
 	LastSeen time.Time
 	Status   *pbv1.NodeStatus
 	Metrics  *pbv1.NodeMetrics
+	// RegisteredAt is when the node last registered with this instance
+	RegisteredAt time.Time
+
+	// Heartbeats since the last health check, and those reporting an error
+	Heartbeats      int64
+	ErrorHeartbeats int64
 }
 
-// NewAgentService creates a new AgentService instance
-func NewAgentService(config configv1.APIConfig, dbService *database.Service, logger *zap.Logger) *AgentService {
-	return &AgentService{
+// NewAgentService creates a new AgentService instance publishing its events to bus
+func NewAgentService(config configv1.APIConfig, dbService *database.Service, bus *events.Bus, logger *zap.Logger) *AgentService {
+	ingester := traffic.NewIngester(config.Business.Traffic, dbService.GetRepository(), logger)
+	ingester.SetEvents(bus)
+
+	s := &AgentService{
 		config:        config,
 		logger:        logger.Named("agent-service"),
 		dbService:     dbService,
 		nodes:         make(map[string]*NodeState),
 		commandQueues: make(map[string]chan *pbv1.PendingCommand),
+		ingester:      ingester,
+		counters:      make(map[uint]networkCounter),
+		events:        bus,
+		settings:      settings.NewStore(dbService.GetRepository().SystemSetting, logger),
+	}
+	s.businessConfig.Store(&config.Business)
+	if config.Business.Witness.Enabled {
+		s.witness = newNodeWitness(time.Now())
 	}
+	return s
 }
 
 // Start starts the agent service
 func (s *AgentService) Start(ctx context.Context) error {
 	s.logger.Info("agent service starting")
 
+	// Start batched traffic ingestion
+	s.ingester.Start(ctx)
+
 	// Start cleanup goroutine for offline nodes
 	go s.cleanupOfflineNodes(ctx)
 
+	// Start deleting the metric series of departed users and nodes
+	if s.config.Metrics.SeriesSyncInterval > 0 {
+		go s.syncMetricSeries(ctx)
+	}
+
+	// Start keeping the node, user, traffic and process gauges up to date
+	if s.config.Metrics.RefreshInterval > 0 {
+		updater := metrics.NewUpdater(metrics.GetGlobalMetrics(), s.dbService.GetRepository(), s.events, s.config.Metrics.RefreshInterval, s.logger)
+		go updater.Run(ctx)
+	}
+
+	// Start reporting anonymous usage telemetry, only when opted in
+	if s.config.Telemetry.Enabled {
+		go s.reportTelemetry(ctx)
+	}
+
+	// Start the jobs driven by the business settings
+	s.jobsMux.Lock()
+	s.ctx = ctx
+	s.startJobs()
+	s.jobsMux.Unlock()
+
 	return nil
 }
 
+// startJobs starts the background jobs enabled by the business settings,
+// which Reload restarts with new settings. The caller holds jobsMux.
+func (s *AgentService) startJobs() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.stopJobs = cancel
+	business := s.business()
+
+	jobs := []struct {
+		enabled bool
+		run     func(context.Context)
+	}{
+		// Maintain the traffic summaries
+		{business.Traffic.EnableAggregation, s.aggregateTraffic},
+		// Downsample the node metrics history
+		{business.Metrics.Enabled, s.downsampleMetrics},
+		// Refresh the geo databases distributed to nodes
+		{s.geoData != nil, s.refreshGeoData},
+		// Import the disposable email domain lists
+		{s.blocklists != nil, s.refreshBlocklists},
+		// Warn users about accounts that expire soon, unless expiry does
+		{s.alerts != nil && !business.Expiry.Enabled, s.checkExpiringAccounts},
+		// Expire the accounts whose plan ran out and restore renewed ones
+		{business.Expiry.Enabled, s.checkExpiry},
+		// Apply the inactive account policy
+		{business.Inactivity.Enabled, s.checkInactiveAccounts},
+		// Warn about and end the trials of users
+		{business.Trial.Enabled, s.checkTrials},
+		// Cross-check the reports of the nodes
+		{s.witness != nil, s.crossCheckNodes},
+		// Score the health of the nodes
+		{business.Health.Enabled, s.checkNodeHealth},
+		// Move users off offline nodes
+		{business.Failover.Enabled, s.failoverUsers},
+		// Move the users of running user migrations
+		{business.UserMigration.CheckInterval > 0, s.migrateUsers},
+		// Evaluate the automation rules on the published events
+		{business.Automation.Enabled, s.runAutomation},
+		// Verify the invariants spanning several tables
+		{business.Integrity.Enabled, s.checkIntegrity},
+		// Mail the traffic report of the previous month
+		{business.TrafficReport.Enabled && s.mailer != nil, s.sendTrafficReports},
+		// Alert about subscription links fetched from many addresses
+		{business.SubscriptionSharing.Enabled && s.alerts != nil, s.checkSubscriptionSharing},
+		// Forget the reports too old to be replayed
+		{true, s.pruneReportReceipts},
+		// Time out the commands nodes did not report a result for
+		{true, s.timeOutCommands},
+		// Push the speed limits that changed to the connected nodes
+		{true, s.syncSpeedLimits},
+		// Push the regenerated credentials of users to their nodes
+		{true, s.syncCredentials},
+	}
+	for _, job := range jobs {
+		if !job.enabled {
+			continue
+		}
+		s.jobs.Add(1)
+		go func(run func(context.Context)) {
+			defer s.jobs.Done()
+			run(ctx)
+		}(job.run)
+	}
+}
+
 // Stop stops the agent service
 func (s *AgentService) Stop(ctx context.Context) error {
 	s.logger.Info("agent service stopping")
 
+	// Write traffic that is still buffered
+	s.ingester.Stop()
+
 	// Close all command queues
 	s.queuesMux.Lock()
 	for nodeID, queue := range s.commandQueues {
@@ -87,13 +263,21 @@ func (s *AgentService) RegisterNode(ctx context.Context, req *pbv1.RegisterNodeR
 	)
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID for database operations
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+
+	repo := s.dbService.GetRepository()
+
+	// A deleted node keeps its ID, so re-registering it would collide on the primary key
+	if existingNode, err := repo.Node.GetByIDUnscoped(uint(nodeID)); err == nil && existingNode.DeletedAt.Valid {
+		return nil, apierror.FailedPrecondition(apierror.ReasonNodeDeleted, "node/"+req.NodeId,
+			fmt.Sprintf("node %d was deleted, register with a new node_id and merge its history if needed", nodeID))
 	}
 
 	// Update or create node in database
@@ -121,33 +305,82 @@ func (s *AgentService) RegisterNode(ctx context.Context, req *pbv1.RegisterNodeR
 	}
 
 	// Check if node exists, update or create
-	if existingNode, err := s.dbService.GetRepository().Node.GetByID(uint(nodeID)); err == nil {
+	if existingNode, err := repo.Node.GetByID(uint(nodeID)); err == nil {
+		name := existingNode.Name
+		if req.NodeName != existingNode.Name {
+			name, err = s.resolveNodeName(req.NodeName, existingNode.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Registering brings a node online, whatever the health checks decided
+		if existingNode.Status != models.NodeStatusOnline {
+			if err := repo.Node.TransitionStatus(&models.NodeStatusTransition{
+				NodeID:      existingNode.ID,
+				From:        existingNode.Status,
+				To:          models.NodeStatusOnline,
+				Reason:      "node registered",
+				HealthScore: existingNode.HealthScore,
+			}); err != nil && !errors.Is(err, repository.ErrNodeStatusChanged) {
+				s.logger.Error("Failed to record node status transition", zap.Error(err))
+			}
+			existingNode.StatusReason = "node registered"
+			existingNode.StatusAutomatic = false
+		}
+
 		// Update existing node
-		existingNode.Name = req.NodeName
+		existingNode.Name = name
 		existingNode.Host = req.NodeIp
 		existingNode.Status = models.NodeStatusOnline
 		existingNode.LastHeartbeat = &now
 		existingNode.SingBoxVersion = req.Version
-		err = s.dbService.GetRepository().Node.Update(existingNode)
+		if err := existingNode.Validate(); err != nil {
+			return nil, validationError(err, "node.")
+		}
+		err = repo.Node.Update(existingNode)
 		if err != nil {
 			s.logger.Error("Failed to update node in database", zap.Error(err))
 			return nil, status.Error(codes.Internal, "failed to update node")
 		}
+		node = existingNode
 	} else {
+		if !s.settings.Bool(models.SettingNodeRegistrationEnabled, true) {
+			s.logger.Warn("Registration of a new node rejected, node registration is disabled",
+				zap.String("node_id", req.NodeId), zap.String("node_name", req.NodeName))
+			return nil, apierror.FailedPrecondition(apierror.ReasonNodeRegistrationDisabled, req.NodeId,
+				"registration of new nodes is disabled")
+		}
+		node.Name, err = s.resolveNodeName(req.NodeName, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := node.Validate(); err != nil {
+			return nil, validationError(err, "node.")
+		}
+
 		// Create new node
-		err = s.dbService.GetRepository().Node.Create(node)
+		err = repo.Node.Create(node)
 		if err != nil {
 			s.logger.Error("Failed to create node in database", zap.Error(err))
 			return nil, status.Error(codes.Internal, "failed to create node")
 		}
 	}
 
+	// A registering agent starts tracking connections afresh, so the
+	// sessions it reported before are never closed otherwise
+	if err := repo.Traffic.CloseNodeConnections(uint(nodeID)); err != nil {
+		s.logger.Error("Failed to close node sessions", zap.Error(err), zap.String("node_id", req.NodeId))
+	}
+
 	// Update node state in memory
 	s.nodesMux.Lock()
 	s.nodes[req.NodeId] = &NodeState{
-		Info:     req,
-		LastSeen: now,
-		Status:   &pbv1.NodeStatus{Status: "online"},
+		Info:         req,
+		LastSeen:     now,
+		Status:       &pbv1.NodeStatus{Status: "online"},
+		RegisteredAt: now,
 	}
 	s.nodesMux.Unlock()
 
@@ -159,34 +392,119 @@ func (s *AgentService) RegisterNode(ctx context.Context, req *pbv1.RegisterNodeR
 	s.queuesMux.Unlock()
 
 	s.logger.Info("node registered successfully", zap.String("node_id", req.NodeId))
+	s.publishNodeStatus(req.NodeId, node.Name, models.NodeStatusOnline)
+
+	message := "node registered successfully"
+	if node.Name != req.NodeName {
+		message = fmt.Sprintf("node registered successfully as %q", node.Name)
+	}
 
 	return &pbv1.RegisterNodeResponse{
 		Success: true,
-		Message: "node registered successfully",
+		Message: message,
 	}, nil
 }
 
+// conflictMetadata describes a node name conflict for error details
+func conflictMetadata(conflict *repository.NodeNameConflictError) map[string]string {
+	return map[string]string{
+		"name":    conflict.Name,
+		"node_id": strconv.FormatUint(uint64(conflict.NodeID), 10),
+		"deleted": strconv.FormatBool(conflict.Deleted),
+	}
+}
+
+// resolveNodeName checks that a node name is free, including names still held by
+// soft-deleted nodes. With business.node.autoRenameOnConflict the next free
+// "-N" suffix is used instead of rejecting the registration.
+func (s *AgentService) resolveNodeName(name string, nodeID uint) (string, error) {
+	repo := s.dbService.GetRepository()
+
+	err := repo.Node.CheckNameAvailable(name, nodeID)
+	if err == nil {
+		return name, nil
+	}
+
+	var conflict *repository.NodeNameConflictError
+	if !errors.As(err, &conflict) {
+		s.logger.Error("Failed to check node name", zap.Error(err))
+		return "", status.Error(codes.Internal, "failed to check node name")
+	}
+
+	if !s.settings.Bool(models.SettingNodeAutoRenameOnConflict, s.business().Node.AutoRenameOnConflict) {
+		if conflict.Deleted {
+			return "", apierror.AlreadyExists(apierror.ResourceNode, apierror.ReasonNodeNameTaken,
+				fmt.Sprintf("node name %q is still held by deleted node %d, choose another name or enable autoRenameOnConflict",
+					name, conflict.NodeID), conflictMetadata(conflict))
+		}
+		return "", apierror.AlreadyExists(apierror.ResourceNode, apierror.ReasonNodeNameTaken,
+			fmt.Sprintf("node name %q is already used by node %d", name, conflict.NodeID), conflictMetadata(conflict))
+	}
+
+	renamed, err := repo.Node.NextAvailableName(name)
+	if err != nil {
+		s.logger.Error("Failed to find free node name", zap.Error(err))
+		return "", status.Error(codes.Internal, "failed to find free node name")
+	}
+
+	s.logger.Info("node name conflict resolved by renaming",
+		zap.String("name", name),
+		zap.String("renamed", renamed),
+		zap.Uint("conflicting_node_id", conflict.NodeID),
+		zap.Bool("conflicting_node_deleted", conflict.Deleted),
+	)
+	return renamed, nil
+}
+
 // Heartbeat handles node heartbeat
 func (s *AgentService) Heartbeat(ctx context.Context, req *pbv1.HeartbeatRequest) (*pbv1.HeartbeatResponse, error) {
 	s.logger.Debug("Heartbeat called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Update node last seen time and status
+	var geoDataChanged bool
+	var previousDisk *pbv1.DiskStatus
+	var previousFailure *pbv1.ConfigFailure
+	var nodeName string
 	s.nodesMux.Lock()
 	if node, exists := s.nodes[req.NodeId]; exists {
 		node.LastSeen = time.Now()
+		node.Heartbeats++
+		nodeName = node.Info.GetNodeName()
 		if req.Status != nil {
+			geoDataChanged = !sameGeoData(node.Status, req.Status)
+			previousDisk = node.Status.GetDisk()
+			previousFailure = node.Status.GetConfigFailure()
 			node.Status = req.Status
+			if req.Status.Status == "error" {
+				node.ErrorHeartbeats++
+			}
 		}
 	} else {
 		s.nodesMux.Unlock()
-		return nil, status.Error(codes.NotFound, "node not registered")
+		return nil, apierror.New(codes.NotFound, apierror.ReasonNodeNotRegistered, "node not registered",
+			map[string]string{"node_id": req.NodeId})
 	}
 	s.nodesMux.Unlock()
 
+	// Versions are only written when they change, not on every heartbeat
+	if geoDataChanged {
+		s.recordGeoDataVersions(req.NodeId, req.Status.GeoData)
+	}
+
+	// Admins are alerted when a node's disk fills up
+	if disk := req.Status.GetDisk(); disk != nil && disk.Pressure != previousDisk.GetPressure() {
+		go s.handleDiskPressure(req.NodeId, nodeName, previousDisk.GetPressure(), disk)
+	}
+
+	// Admins are alerted once per configuration the node rejected or rolled back
+	if failure := req.Status.GetConfigFailure(); failure != nil && !proto.Equal(failure, previousFailure) {
+		go s.handleConfigFailure(req.NodeId, nodeName, failure)
+	}
+
 	// Get pending commands
 	commands := s.getPendingCommands(req.NodeId)
 
@@ -201,13 +519,13 @@ func (s *AgentService) ReportMetrics(ctx context.Context, req *pbv1.ReportMetric
 	s.logger.Debug("ReportMetrics called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
 	}
 
 	// Update node metrics in database
@@ -215,11 +533,45 @@ func (s *AgentService) ReportMetrics(ctx context.Context, req *pbv1.ReportMetric
 		node, err := s.dbService.GetRepository().Node.GetByID(uint(nodeID))
 		if err != nil {
 			s.logger.Error("Failed to get node for metrics update", zap.Error(err))
-			return nil, status.Error(codes.NotFound, "node not found")
+			return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
+		}
+
+		// Agents replay the reports the server may have received before a
+		// connection dropped
+		fresh, err := s.acceptReport(node.ID, req.ReportId)
+		if err != nil {
+			s.logger.Error("Failed to record metrics report", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to record metrics report")
+		}
+		if !fresh {
+			return &pbv1.ReportMetricsResponse{Success: true, Message: "duplicate report ignored"}, nil
 		}
 
 		// Update node metrics
 		now := time.Now()
+		reportedAt := now
+		if req.Timestamp != nil {
+			reportedAt = req.Timestamp.AsTime()
+		}
+
+		// Replayed metrics only fill the history, the current state of the
+		// node comes from its live reports
+		if req.Replayed {
+			s.recordMetricsSample(node, req.Metrics, reportedAt)
+			return &pbv1.ReportMetricsResponse{Success: true, Message: "replayed metrics received"}, nil
+		}
+
+		// Agents report cumulative counters, derive throughput from the previous reading
+		inRate, outRate, ok := s.updateNetworkRate(node.ID, networkCounter{
+			in:  req.Metrics.NetworkInBytesPerSec,
+			out: req.Metrics.NetworkOutBytesPerSec,
+			at:  reportedAt,
+		})
+		if ok {
+			node.NetworkInRate = inRate
+			node.NetworkOutRate = outRate
+		}
+
 		node.LastHeartbeat = &now
 		node.CPUUsage = req.Metrics.CpuUsagePercent
 		node.MemoryUsage = req.Metrics.MemoryUsagePercent
@@ -227,15 +579,19 @@ func (s *AgentService) ReportMetrics(ctx context.Context, req *pbv1.ReportMetric
 		node.Load1 = req.Metrics.LoadAverage
 		node.Load5 = req.Metrics.LoadAverage
 		node.Load15 = req.Metrics.LoadAverage
-		node.CurrentUsers = int(req.Metrics.ActiveConnections)
+		node.ActiveConnections = int(req.Metrics.ActiveConnections)
 		node.UploadTraffic = req.Metrics.NetworkOutBytesPerSec
 		node.DownloadTraffic = req.Metrics.NetworkInBytesPerSec
 
 		err = s.dbService.GetRepository().Node.Update(node)
 		if err != nil {
 			s.logger.Error("Failed to update node metrics in database", zap.Error(err))
+			s.forgetReport(node.ID, req.ReportId)
 			return nil, status.Error(codes.Internal, "failed to update node metrics")
 		}
+
+		s.recordMetricsSample(node, req.Metrics, reportedAt)
+		metrics.SetNodeNetworkRate(req.NodeId, node.Name, node.NetworkInRate, node.NetworkOutRate)
 	}
 
 	// Update node metrics in memory
@@ -260,16 +616,25 @@ func (s *AgentService) ReportTraffic(ctx context.Context, req *pbv1.ReportTraffi
 	)
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+
+	// Replayed traffic is recorded at the time it was collected
+	now := time.Now()
+	if req.Replayed && req.Timestamp != nil && req.Timestamp.AsTime().Before(now) {
+		now = req.Timestamp.AsTime()
 	}
 
-	// Store traffic data in database
+	// Queue traffic records, they are written in batches by the ingester
+	records := make([]*models.TrafficRecord, 0, len(req.UserTraffic))
+	var shaping []*models.ShapingRecord
+	var closedSessions []string
 	for _, userTraffic := range req.UserTraffic {
 		// Parse user ID
 		userID, err := strconv.ParseUint(userTraffic.UserId, 10, 32)
@@ -278,59 +643,62 @@ func (s *AgentService) ReportTraffic(ctx context.Context, req *pbv1.ReportTraffi
 			continue
 		}
 
-		// Create traffic record with proper time values
-		now := time.Now()
-		trafficRecord := &models.TrafficRecord{
-			UserID:      uint(userID),
-			NodeID:      uint(nodeID),
-			Upload:      userTraffic.UploadBytes,
-			Download:    userTraffic.DownloadBytes,
-			Total:       userTraffic.UploadBytes + userTraffic.DownloadBytes,
-			ConnectTime: now,
-			RecordDate:  now.Truncate(24 * time.Hour),
-			RecordHour:  now.Hour(),
+		userRecords, closed := userTrafficRecords(uint(userID), uint(nodeID), userTraffic, now)
+		records = append(records, userRecords...)
+		closedSessions = append(closedSessions, closed...)
+
+		// Only throttled users are worth a shaping record
+		if stats := userTraffic.Shaping; stats != nil && (stats.ThrottleEvents > 0 || stats.DelayedBytes > 0 || stats.DroppedBytes > 0) {
+			shaping = append(shaping, &models.ShapingRecord{
+				UserID:           uint(userID),
+				NodeID:           uint(nodeID),
+				LimitBytesPerSec: stats.LimitBytesPerSec,
+				ThrottleEvents:   int(stats.ThrottleEvents),
+				ThrottledSeconds: stats.ThrottledSeconds,
+				DelayedBytes:     stats.DelayedBytes,
+				DroppedBytes:     stats.DroppedBytes,
+			})
 		}
+	}
 
-		// Save traffic record
-		err = s.dbService.GetRepository().Traffic.CreateRecord(trafficRecord)
-		if err != nil {
-			s.logger.Error("Failed to save traffic record", zap.Error(err))
-			continue
+	// Agents replay the reports the server may have received before a
+	// connection dropped. The receipt of a report is committed with its
+	// records, a report lost before they are stored is accepted again.
+	if err := s.ingester.Add(uint(nodeID), req.ReportId, records); err != nil {
+		if errors.Is(err, traffic.ErrDuplicateReport) {
+			return &pbv1.ReportTrafficResponse{Success: true, Message: "duplicate report ignored"}, nil
 		}
-
-		// Check if user exists before updating traffic
-		user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
-		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// Log as debug instead of warning - this might be normal during testing
-				s.logger.Debug("User not found for traffic update - traffic record saved but user traffic not updated", 
-					zap.Uint("user_id", uint(userID)),
-					zap.Int64("upload_bytes", userTraffic.UploadBytes),
-					zap.Int64("download_bytes", userTraffic.DownloadBytes))
-				continue
-			} else {
-				s.logger.Error("Failed to get user for traffic update", zap.Error(err))
-				continue
-			}
+		if errors.Is(err, traffic.ErrBufferFull) {
+			s.logger.Warn("Traffic report rejected, ingestion buffer is full",
+				zap.String("node_id", req.NodeId),
+				zap.Int("records", len(records)),
+			)
+			return nil, apierror.New(codes.ResourceExhausted, apierror.ReasonTrafficBufferFull,
+				"traffic ingestion buffer is full, retry later", map[string]string{"node_id": req.NodeId})
 		}
+		s.logger.Error("Failed to queue traffic records", zap.Error(err), zap.String("node_id", req.NodeId))
+		return nil, apierror.Internal("failed to queue traffic records")
+	}
 
-		// Update user traffic usage only if user exists
-		user.TrafficUsed += userTraffic.UploadBytes + userTraffic.DownloadBytes
-		err = s.dbService.GetRepository().User.Update(user)
-		if err != nil {
-			s.logger.Error("Failed to update user traffic usage", zap.Error(err))
-			continue
+	// Ingested traffic is compared with the node's interface counters, which
+	// replayed traffic went through in an earlier window
+	if s.witness != nil && !req.Replayed {
+		var reported int64
+		for _, record := range records {
+			reported += record.Total
 		}
+		s.witness.addTraffic(uint(nodeID), reported)
+	}
 
-		// Check traffic limits and generate alerts (only for users with traffic quota > 0)
-		if user.TrafficQuota > 0 && user.TrafficUsed > user.TrafficQuota {
-			s.logger.Warn("User exceeded traffic quota",
-				zap.String("user_id", userTraffic.UserId),
-				zap.Int64("used", user.TrafficUsed),
-				zap.Int64("quota", user.TrafficQuota),
-			)
-			// TODO: Generate alert or suspend user
-		}
+	// The traffic is queued, so a shaping failure must not make the agent resend it
+	if err := s.dbService.GetRepository().Shaping.CreateBatch(shaping); err != nil {
+		s.logger.Error("Failed to save shaping records", zap.Error(err), zap.String("node_id", req.NodeId))
+	}
+
+	// The queued records of closed sessions are closed already, the earlier
+	// ones are closed here
+	if err := s.dbService.GetRepository().Traffic.CloseConnections(closedSessions); err != nil {
+		s.logger.Error("Failed to close sessions", zap.Error(err), zap.String("node_id", req.NodeId))
 	}
 
 	return &pbv1.ReportTrafficResponse{
@@ -344,7 +712,7 @@ func (s *AgentService) UpdateConfig(ctx context.Context, req *pbv1.UpdateConfigR
 	s.logger.Debug("UpdateConfig called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// TODO: Validate and store configuration
@@ -366,20 +734,64 @@ func (s *AgentService) ExecuteUserCommand(ctx context.Context, req *pbv1.Execute
 	)
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	if req.Command == nil {
-		return nil, status.Error(codes.InvalidArgument, "command is required")
+		return nil, apierror.MissingField("command")
+	}
+	if req.Command.UserId == "" {
+		return nil, apierror.MissingField("command.user_id")
+	}
+	wait := time.Duration(req.WaitTimeoutSeconds) * time.Second
+	if wait < 0 || wait > maxCommandWait {
+		return nil, apierror.InvalidField("wait_timeout_seconds", fmt.Sprintf("wait_timeout_seconds must be between 0 and %d", int(maxCommandWait.Seconds())))
 	}
 
-	// TODO: Execute user command on the specified node
+	command := &pbv1.PendingCommand{
+		CommandId: generateCommandID(),
+		Command:   req.Command,
+		CreatedAt: timestamppb.Now(),
+	}
 
-	return &pbv1.ExecuteUserCommandResponse{
-		Success: false,
-		Message: "not implemented",
-		Result:  "",
-	}, nil
+	// Wait for the result before queuing, the node may report it at once
+	var result <-chan *models.NodeCommand
+	if wait > 0 {
+		var stop func()
+		result, stop = s.commandResults.wait(command.CommandId)
+		defer stop()
+	}
+
+	if err := s.sendCommandToNode(ctx, req.NodeId, command); err != nil {
+		return nil, err
+	}
+
+	resp := &pbv1.ExecuteUserCommandResponse{
+		Success:   true,
+		Message:   "command queued",
+		CommandId: command.CommandId,
+		Status:    string(models.NodeCommandPending),
+	}
+	if wait == 0 {
+		return resp, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case completed := <-result:
+		resp.Status = string(completed.Status)
+		resp.Success = completed.Status == models.NodeCommandSucceeded
+		resp.Result = completed.Output
+		resp.Message = "command " + string(completed.Status)
+	case <-timer.C:
+		// The command stays queued, its status is listed with ListCommands
+		resp.Success = false
+		resp.Message = fmt.Sprintf("no result within %s", wait)
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return resp, nil
 }
 
 // RestartSingBox handles sing-box restart requests
@@ -387,7 +799,7 @@ func (s *AgentService) RestartSingBox(ctx context.Context, req *pbv1.RestartSing
 	s.logger.Debug("RestartSingBox called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Add restart command to node's command queue
@@ -404,7 +816,7 @@ func (s *AgentService) RestartSingBox(ctx context.Context, req *pbv1.RestartSing
 		CreatedAt: timestamppb.Now(),
 	}
 
-	if err := s.sendCommandToNode(req.NodeId, command); err != nil {
+	if err := s.sendCommandToNode(ctx, req.NodeId, command); err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to send restart command: %v", err)
 	}
 
@@ -419,7 +831,7 @@ func (s *AgentService) GetNodeStatus(ctx context.Context, req *pbv1.GetNodeStatu
 	s.logger.Debug("GetNodeStatus called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	s.nodesMux.RLock()
@@ -427,7 +839,7 @@ func (s *AgentService) GetNodeStatus(ctx context.Context, req *pbv1.GetNodeStatu
 	s.nodesMux.RUnlock()
 
 	if !exists {
-		return nil, status.Error(codes.NotFound, "node not found")
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
 	}
 
 	return &pbv1.GetNodeStatusResponse{
@@ -458,32 +870,43 @@ func (s *AgentService) getPendingCommands(nodeID string) []*pbv1.PendingCommand
 			commands = append(commands, cmd)
 		default:
 			// No more commands available
+			if len(commands) > 0 {
+				s.markCommandsDelivered(commands)
+			}
 			return commands
 		}
 	}
 }
 
-// sendCommandToNode sends a command to a specific node
-func (s *AgentService) sendCommandToNode(nodeID string, command *pbv1.PendingCommand) error {
+// sendCommandToNode sends a command to a specific node, tagged with the
+// request ID of ctx so that the agent logs it with the command
+func (s *AgentService) sendCommandToNode(ctx context.Context, nodeID string, command *pbv1.PendingCommand) error {
+	command.RequestId = logger.RequestIDFromContext(ctx)
+
 	s.queuesMux.RLock()
 	queue, exists := s.commandQueues[nodeID]
 	s.queuesMux.RUnlock()
 
 	if !exists {
-		return status.Errorf(codes.NotFound, "node %s not found", nodeID)
+		return apierror.NotFound(apierror.ResourceNode, nodeID)
 	}
 
+	// Recorded before it is queued, the next heartbeat may take it at once
+	s.recordCommand(nodeID, command)
 	select {
 	case queue <- command:
 		return nil
 	default:
-		return status.Errorf(codes.ResourceExhausted, "command queue full for node %s", nodeID)
+		s.failCommand(nodeID, command.CommandId, "command queue full")
+		return apierror.New(codes.ResourceExhausted, apierror.ReasonCommandQueueFull,
+			fmt.Sprintf("command queue full for node %s", nodeID), map[string]string{"node_id": nodeID})
 	}
 }
 
 // cleanupOfflineNodes periodically removes offline nodes
 func (s *AgentService) cleanupOfflineNodes(ctx context.Context) {
-	ticker := time.NewTicker(s.config.Business.Node.ConfigSyncInterval)
+	interval := s.cleanupInterval()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
@@ -492,13 +915,25 @@ func (s *AgentService) cleanupOfflineNodes(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.performCleanup()
+
+			// The interval is a system setting admins may change
+			if next := s.cleanupInterval(); next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
 		}
 	}
 }
 
+// cleanupInterval returns how often offline nodes are removed, the
+// node.cleanup_interval setting or business.node.configSyncInterval
+func (s *AgentService) cleanupInterval() time.Duration {
+	return s.settings.Duration(models.SettingNodeCleanupInterval, s.business().Node.ConfigSyncInterval)
+}
+
 // performCleanup removes nodes that haven't been seen for too long
 func (s *AgentService) performCleanup() {
-	maxOfflineTime := s.config.Business.Node.MaxOfflineTime
+	maxOfflineTime := s.settings.Duration(models.SettingNodeMaxOfflineTime, s.business().Node.MaxOfflineTime)
 	cutoff := time.Now().Add(-maxOfflineTime)
 
 	s.nodesMux.Lock()
@@ -509,6 +944,7 @@ func (s *AgentService) performCleanup() {
 				zap.Time("last_seen", node.LastSeen),
 			)
 			delete(s.nodes, nodeID)
+			s.publishNodeStatus(nodeID, node.Info.GetNodeName(), models.NodeStatusOffline)
 
 			// Close command queue
 			s.queuesMux.Lock()
@@ -522,6 +958,59 @@ func (s *AgentService) performCleanup() {
 	s.nodesMux.Unlock()
 }
 
+// publishNodeStatus publishes a node status change to the event bus
+func (s *AgentService) publishNodeStatus(nodeID, name string, status models.NodeStatus) {
+	s.events.Publish(&pbv1.Event{
+		Type: nodeStatusEventTypes[status],
+		NodeStatus: &pbv1.NodeStatusEvent{
+			NodeId:   nodeID,
+			NodeName: name,
+			Status:   string(status),
+		},
+	})
+}
+
+// active reports whether this instance serves agents, always true without a standby
+func (s *AgentService) active() bool {
+	return s.elector == nil || s.elector.IsActive()
+}
+
+// runActiveJob runs job every interval until ctx is done. Standbys share the
+// database, the active instance does the work.
+func (s *AgentService) runActiveJob(ctx context.Context, interval time.Duration, job func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if s.active() {
+				job()
+			}
+		}
+	}
+}
+
+// forgetNodes drops the registrations of all nodes after losing the lease.
+// Nodes register again when they come back to this instance.
+func (s *AgentService) forgetNodes() {
+	s.nodesMux.Lock()
+	s.queuesMux.Lock()
+	for nodeID, queue := range s.commandQueues {
+		close(queue)
+		delete(s.commandQueues, nodeID)
+	}
+	s.nodes = make(map[string]*NodeState)
+	s.queuesMux.Unlock()
+	s.nodesMux.Unlock()
+
+	s.countersMux.Lock()
+	s.counters = make(map[uint]networkCounter)
+	s.countersMux.Unlock()
+}
+
 // GetNodeStates returns current states of all nodes (for monitoring)
 func (s *AgentService) GetNodeStates() map[string]*NodeState {
 	s.nodesMux.RLock()
@@ -531,17 +1020,22 @@ func (s *AgentService) GetNodeStates() map[string]*NodeState {
 	for nodeID, state := range s.nodes {
 		// Create a copy to avoid race conditions
 		states[nodeID] = &NodeState{
-			Info:     state.Info,
-			LastSeen: state.LastSeen,
-			Status:   state.Status,
-			Metrics:  state.Metrics,
+			Info:         state.Info,
+			LastSeen:     state.LastSeen,
+			Status:       state.Status,
+			Metrics:      state.Metrics,
+			RegisteredAt: state.RegisteredAt,
 		}
 	}
 
 	return states
 }
 
-// generateCommandID generates a unique command ID
+// generateCommandID generates a unique command ID. Results are matched to
+// commands by ID, so the random suffix keeps commands queued within the same
+// second apart.
 func generateCommandID() string {
-	return "cmd-" + time.Now().Format("20060102-150405") + "-" + time.Now().Format("000000")
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return "cmd-" + time.Now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
 }
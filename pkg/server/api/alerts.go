@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// alertNodeManagers raises the alert for each active admin allowed to
+// manage nodes
+func (s *AgentService) alertNodeManagers(template alert.Alert) {
+	s.alertAdmins(models.AdminPermissionNodes, template)
+}
+
+// alertAdmins raises the alert for each active admin with a permission
+func (s *AgentService) alertAdmins(permission models.AdminPermission, template alert.Alert) {
+	if s.alerts == nil {
+		return
+	}
+	admins, _, err := s.dbService.GetRepository().User.ListFiltered(repository.UserListFilter{Roles: adminRoles}, 0, -1)
+	if err != nil {
+		s.logger.Error("Failed to list admins for an alert", zap.Error(err), zap.String("type", string(template.Type)))
+		return
+	}
+
+	for _, admin := range admins {
+		if admin.Status != models.UserStatusActive || !admin.HasAdminPermission(permission) {
+			continue
+		}
+		a := template
+		a.UserID = admin.ID
+		s.alerts.Raise(&a)
+	}
+}
+
+// checkExpiringAccounts periodically alerts users whose account expires
+// within the plan expiry warning
+func (s *AgentService) checkExpiringAccounts(ctx context.Context) {
+	s.runActiveJob(ctx, s.business().Alert.CheckInterval, func() {
+		s.performExpiryCheck(time.Now())
+	})
+}
+
+// performExpiryCheck raises a plan expiring alert for each user whose
+// account expires within the warning, once per expiration time. Users on
+// trial are left to the trial check while trials are enabled.
+func (s *AgentService) performExpiryCheck(now time.Time) {
+	users, err := s.dbService.GetRepository().User.ListExpiring(now, now.Add(s.business().Alert.PlanExpiryWarning))
+	if err != nil {
+		s.logger.Error("Failed to list expiring accounts", zap.Error(err))
+		return
+	}
+
+	trials := s.business().Trial.Enabled
+	for _, user := range users {
+		// Trials are warned about by the trial check
+		if trials && user.OnTrial() {
+			continue
+		}
+		s.alerts.Raise(&alert.Alert{
+			UserID:   user.ID,
+			Type:     models.NotificationTypePlanExpiring,
+			Severity: models.SeverityWarning,
+			Title:    "Plan expiring soon",
+			Message:  fmt.Sprintf("Your plan expires on %s. Renew it to keep your service.", user.ExpiresAt.UTC().Format("2006-01-02 15:04 MST")),
+			Key:      fmt.Sprintf("plan_expiring:%d", user.ExpiresAt.Unix()),
+		})
+	}
+}
+
+// raiseUserAlert notifies a user when user alerts are enabled, for the jobs
+// that run without them
+func (s *AgentService) raiseUserAlert(a *alert.Alert) {
+	if s.alerts != nil {
+		s.alerts.Raise(a)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/ha"
+	"sing-box-web/pkg/repository"
+)
+
+// agentServicePrefix is the full method prefix of AgentService RPCs
+const agentServicePrefix = "/api.v1.AgentService/"
+
+// lastUsedUpdateInterval limits how often a token's last used time is written
+const lastUsedUpdateInterval = time.Minute
+
+// nodeIDContextKey is the context key of the authenticated node ID
+type nodeIDContextKey struct{}
+
+// nodeIDRequest is implemented by every AgentService request carrying a node ID
+type nodeIDRequest interface {
+	GetNodeId() string
+}
+
+// NodeIDFromContext returns the node ID authenticated by the node token, if any
+func NodeIDFromContext(ctx context.Context) (string, bool) {
+	nodeID, ok := ctx.Value(nodeIDContextKey{}).(string)
+	return nodeID, ok
+}
+
+// newNodeAuthInterceptor authenticates AgentService calls with node registration tokens.
+// The token must be valid and bound to the node_id carried by the request.
+// EnrollNode, which issues the first token of a node, is not authenticated.
+func newNodeAuthInterceptor(repo *repository.Manager, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	logger = logger.Named("node-auth")
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		// Enrolling agents have no node token yet, they present an enrollment token
+		if !strings.HasPrefix(info.FullMethod, agentServicePrefix) || info.FullMethod == enrollNodeMethod {
+			return handler(ctx, req)
+		}
+
+		token := bearerTokenFromContext(ctx)
+		if token == "" {
+			return nil, apierror.New(codes.Unauthenticated, apierror.ReasonNodeTokenMissing, "node token is required", nil)
+		}
+
+		nodeToken, err := repo.NodeToken.GetByHash(auth.HashNodeToken(token))
+		if err != nil || !nodeToken.IsValid() {
+			logger.Warn("Rejected invalid node token", zap.String("method", info.FullMethod))
+			return nil, apierror.New(codes.Unauthenticated, apierror.ReasonNodeTokenInvalid, "invalid node token", nil)
+		}
+
+		nodeID := strconv.FormatUint(uint64(nodeToken.NodeID), 10)
+		if r, ok := req.(nodeIDRequest); ok && r.GetNodeId() != nodeID {
+			logger.Warn("Node token used for another node",
+				zap.String("method", info.FullMethod),
+				zap.String("token_node_id", nodeID),
+				zap.String("request_node_id", r.GetNodeId()),
+			)
+			return nil, apierror.New(codes.PermissionDenied, apierror.ReasonNodeTokenMismatch, "node token is not valid for this node",
+				map[string]string{"node_id": r.GetNodeId()})
+		}
+
+		if nodeToken.LastUsedAt == nil || time.Since(*nodeToken.LastUsedAt) > lastUsedUpdateInterval {
+			if err := repo.NodeToken.UpdateLastUsed(nodeToken.ID); err != nil {
+				logger.Warn("Failed to update node token last used time", zap.Error(err))
+			}
+		}
+
+		return handler(context.WithValue(ctx, nodeIDContextKey{}, nodeID), req)
+	}
+}
+
+// bearerTokenFromContext extracts the bearer token from incoming metadata
+func bearerTokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+// newStandbyInterceptor rejects AgentService calls while this instance is a
+// warm standby, so that agents move on to the active instance
+func newStandbyInterceptor(elector *ha.Elector) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, agentServicePrefix) && !elector.IsActive() {
+			return nil, apierror.New(codes.Unavailable, apierror.ReasonStandbyInstance, "this API instance is a standby",
+				map[string]string{"instance_id": elector.InstanceID()})
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// newTestDatabase returns a migrated database on a new SQLite file
+func newTestDatabase(t *testing.T) *database.Service {
+	t.Helper()
+	dbService, err := database.New(configv1.DatabaseConfig{
+		Driver:       "sqlite",
+		Database:     filepath.Join(t.TempDir(), "api.db"),
+		MaxIdleConns: 1,
+		MaxOpenConns: 1,
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { dbService.Close() })
+	if err := dbService.Migrate(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return dbService
+}
+
+// createTestNode stores a node and returns its ID as sent by agents
+func createTestNode(t *testing.T, dbService *database.Service, name string) string {
+	t.Helper()
+	node := &models.Node{Name: name, Type: models.NodeTypeVLESS, Host: "192.0.2.1", Port: 443}
+	if err := dbService.GetRepository().Node.Create(node); err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+	return strconv.FormatUint(uint64(node.ID), 10)
+}
+
+// withBearerToken returns a context carrying token as incoming metadata
+func withBearerToken(token string) context.Context {
+	ctx := context.Background()
+	if token == "" {
+		return ctx
+	}
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+}
+
+func TestNodeAuthInterceptor(t *testing.T) {
+	dbService := newTestDatabase(t)
+	repo := dbService.GetRepository()
+	management := NewManagementService(*configv1.DefaultAPIConfig(), dbService, zap.NewNop())
+	interceptor := newNodeAuthInterceptor(repo, zap.NewNop())
+
+	nodeID := createTestNode(t, dbService, "hk-1")
+	otherNodeID := createTestNode(t, dbService, "jp-1")
+	issue := func() string {
+		resp, err := management.CreateNodeToken(context.Background(), &pbv1.CreateNodeTokenRequest{NodeId: nodeID})
+		if err != nil {
+			t.Fatalf("CreateNodeToken: %v", err)
+		}
+		return resp.Token
+	}
+
+	token := issue()
+
+	revoked := issue()
+	stored, err := repo.NodeToken.GetByHash(auth.HashNodeToken(revoked))
+	if err != nil {
+		t.Fatalf("get node token: %v", err)
+	}
+	if _, err := management.RevokeNodeToken(context.Background(), &pbv1.RevokeNodeTokenRequest{
+		TokenId: strconv.FormatUint(uint64(stored.ID), 10),
+	}); err != nil {
+		t.Fatalf("RevokeNodeToken: %v", err)
+	}
+
+	expired, err := auth.GenerateNodeToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().Add(-time.Minute)
+	nodeIDValue, _ := strconv.ParseUint(nodeID, 10, 32)
+	if err := repo.NodeToken.Create(&models.NodeToken{
+		NodeID:    uint(nodeIDValue),
+		TokenHash: auth.HashNodeToken(expired),
+		ExpiresAt: &expiresAt,
+	}); err != nil {
+		t.Fatalf("create node token: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		req        interface{}
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{"valid token", pbv1.AgentService_Heartbeat_FullMethodName, token, &pbv1.HeartbeatRequest{NodeId: nodeID}, codes.OK, ""},
+		{"missing token", pbv1.AgentService_Heartbeat_FullMethodName, "", &pbv1.HeartbeatRequest{NodeId: nodeID},
+			codes.Unauthenticated, apierror.ReasonNodeTokenMissing},
+		{"unknown token", pbv1.AgentService_Heartbeat_FullMethodName, "not-a-node-token", &pbv1.HeartbeatRequest{NodeId: nodeID},
+			codes.Unauthenticated, apierror.ReasonNodeTokenInvalid},
+		{"token of another node", pbv1.AgentService_Heartbeat_FullMethodName, token, &pbv1.HeartbeatRequest{NodeId: otherNodeID},
+			codes.PermissionDenied, apierror.ReasonNodeTokenMismatch},
+		{"revoked token", pbv1.AgentService_Heartbeat_FullMethodName, revoked, &pbv1.HeartbeatRequest{NodeId: nodeID},
+			codes.Unauthenticated, apierror.ReasonNodeTokenInvalid},
+		{"expired token", pbv1.AgentService_Heartbeat_FullMethodName, expired, &pbv1.HeartbeatRequest{NodeId: nodeID},
+			codes.Unauthenticated, apierror.ReasonNodeTokenInvalid},
+		// Enrolling agents present an enrollment token, checked by EnrollNode
+		{"enrollment without node token", pbv1.AgentService_EnrollNode_FullMethodName, "", &pbv1.EnrollNodeRequest{}, codes.OK, ""},
+		{"management call", pbv1.ManagementService_ListNodes_FullMethodName, "", &pbv1.ListNodesRequest{}, codes.OK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authenticated string
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				authenticated, _ = NodeIDFromContext(ctx)
+				return req, nil
+			}
+			_, err := interceptor(withBearerToken(tt.token), tt.req, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+			if reason := apierror.Reason(err); reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+			if tt.wantCode == codes.OK && tt.token != "" && authenticated != nodeID {
+				t.Errorf("authenticated node = %q, want %q", authenticated, nodeID)
+			}
+		})
+	}
+}
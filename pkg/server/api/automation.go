@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// automationBuffer is the number of events waiting to be evaluated
+	automationBuffer = 256
+	// automationPruneInterval is how often old executions are deleted
+	automationPruneInterval = time.Hour
+)
+
+// automationSubjectFields are the event fields holding the ID of the user or
+// node of the event, in the order they are looked up
+var automationSubjectFields = map[string][]string{
+	models.AutomationSubjectUser: {"user.user_id", "alert.user_id"},
+	models.AutomationSubjectNode: {"node_status.node_id", "node_witness.node_id"},
+}
+
+// runAutomation evaluates the automation rules on the published events and
+// deletes the executions older than the retention
+func (s *AgentService) runAutomation(ctx context.Context) {
+	sub := s.events.Subscribe(events.Topics(), automationBuffer)
+	defer sub.Close()
+
+	ticker := time.NewTicker(automationPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			// Every instance of a shared bus receives the event, the active one acts
+			if !s.active() {
+				continue
+			}
+			s.evaluateAutomation(event, time.Now())
+		case <-ticker.C:
+			if !s.active() || s.business().Automation.ExecutionRetention <= 0 {
+				continue
+			}
+			s.pruneAutomationExecutions(time.Now())
+		}
+	}
+}
+
+// evaluateAutomation runs the actions of the enabled rules matching an
+// event, logging an execution for each
+func (s *AgentService) evaluateAutomation(event *pbv1.Event, now time.Time) {
+	// Notifications of rules raise alerts of their own, which must not
+	// trigger rules again
+	if event.Type == events.TypeAlertRaised && event.GetAlert().GetType() == string(models.NotificationTypeAutomation) {
+		return
+	}
+
+	repo := s.dbService.GetRepository().Automation
+	rules, err := repo.ListEnabled(event.Type)
+	if err != nil {
+		s.logger.Error("Failed to list automation rules", zap.Error(err), zap.String("event_type", event.Type))
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+	fields, err := events.Fields(event)
+	if err != nil {
+		s.logger.Error("Failed to read event fields", zap.Error(err), zap.String("event_type", event.Type))
+		return
+	}
+
+	for _, rule := range rules {
+		if !rule.Matches(fields) {
+			continue
+		}
+		execution := &models.AutomationExecution{
+			CreatedAt: now,
+			RuleID:    rule.ID,
+			RuleName:  rule.Name,
+			EventType: event.Type,
+			Subject:   automationEventSubject(event.Type, fields),
+		}
+		for _, action := range rule.Actions {
+			result := s.applyAutomationAction(action, fields)
+			execution.Results = append(execution.Results, result)
+			execution.Failed = execution.Failed || result.Error != ""
+		}
+
+		if err := repo.RecordExecution(execution); err != nil {
+			s.logger.Error("Failed to record automation execution", zap.Error(err), zap.Uint("rule_id", rule.ID))
+		}
+		s.logger.Info("Automation rule executed",
+			zap.Uint("rule_id", rule.ID),
+			zap.String("rule", rule.Name),
+			zap.String("event_type", event.Type),
+			zap.String("subject", execution.Subject),
+			zap.Bool("failed", execution.Failed),
+		)
+	}
+}
+
+// pruneAutomationExecutions deletes the executions older than the retention
+func (s *AgentService) pruneAutomationExecutions(now time.Time) {
+	cutoff := now.Add(-s.business().Automation.ExecutionRetention)
+	deleted, err := s.dbService.GetRepository().Automation.DeleteExecutionsBefore(cutoff)
+	if err != nil {
+		s.logger.Error("Failed to delete automation executions", zap.Error(err))
+		return
+	}
+	if deleted > 0 {
+		s.logger.Debug("Automation executions deleted", zap.Int64("count", deleted))
+	}
+}
+
+// applyAutomationAction runs an action on the subject of an event
+func (s *AgentService) applyAutomationAction(action models.AutomationAction, fields map[string]string) models.AutomationActionResult {
+	detail, id, err := describeAutomationAction(action, fields)
+	result := models.AutomationActionResult{Type: action.Type, Detail: detail}
+	if err == nil {
+		err = s.runAutomationAction(action, id, fields)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		s.logger.Warn("Automation action failed", zap.Error(err), zap.String("action", string(action.Type)))
+	}
+	return result
+}
+
+// runAutomationAction runs an action on the user or node id
+func (s *AgentService) runAutomationAction(action models.AutomationAction, id uint, fields map[string]string) error {
+	repo := s.dbService.GetRepository().Automation
+	switch action.Type {
+	case models.AutomationActionNotify:
+		if s.alerts == nil {
+			return errors.New("user alerts are disabled")
+		}
+		template := alert.Alert{
+			Type:     models.NotificationTypeAutomation,
+			Severity: automationSeverity(action),
+			Title:    action.Param("title", fields),
+			Message:  action.Param("message", fields),
+		}
+		if id == 0 {
+			s.alertNodeManagers(template)
+			return nil
+		}
+		template.UserID = id
+		s.alerts.Raise(&template)
+		return nil
+
+	case models.AutomationActionLabel:
+		if action.Params["target"] == models.AutomationSubjectNode {
+			return repo.SetNodeLabel(id, action.Params["key"], action.Param("value", fields))
+		}
+		return repo.SetUserLabel(id, action.Params["key"], action.Param("value", fields))
+
+	case models.AutomationActionSuspendUser:
+		_, err := repo.SuspendUser(id)
+		return err
+
+	case models.AutomationActionSetNodeSort:
+		sort, _ := strconv.Atoi(action.Params["sort"])
+		return repo.SetNodeSort(id, sort)
+	}
+	return fmt.Errorf("unknown action %q", action.Type)
+}
+
+// describeAutomationAction describes what an action does on an event,
+// returning the ID of the user or node it acts on, zero when it acts on
+// none. It fails when the event does not name the subject.
+func describeAutomationAction(action models.AutomationAction, fields map[string]string) (string, uint, error) {
+	subject := action.Subject()
+	var id uint
+	if subject != "" {
+		var ok bool
+		if id, ok = automationSubjectID(subject, fields); !ok {
+			return "", 0, fmt.Errorf("the event does not name a %s", subject)
+		}
+	}
+
+	switch action.Type {
+	case models.AutomationActionNotify:
+		to := "admins managing nodes"
+		if id != 0 {
+			to = fmt.Sprintf("user %d", id)
+		}
+		return fmt.Sprintf("notify %s: %s", to, action.Param("title", fields)), id, nil
+	case models.AutomationActionLabel:
+		return fmt.Sprintf("set %s=%s on %s %d", action.Params["key"], action.Param("value", fields), subject, id), id, nil
+	case models.AutomationActionSuspendUser:
+		return fmt.Sprintf("suspend user %d", id), id, nil
+	case models.AutomationActionSetNodeSort:
+		return fmt.Sprintf("set sort %s on node %d", action.Params["sort"], id), id, nil
+	}
+	return "", 0, fmt.Errorf("unknown action %q", action.Type)
+}
+
+// automationSubjectID returns the ID of the user or node an event names
+func automationSubjectID(subject string, fields map[string]string) (uint, bool) {
+	for _, name := range automationSubjectFields[subject] {
+		if id, err := strconv.ParseUint(fields[name], 10, 32); err == nil && id > 0 {
+			return uint(id), true
+		}
+	}
+	return 0, false
+}
+
+// automationEventSubject names the user or node an event is about, such as
+// node/3, empty for events about neither
+func automationEventSubject(eventType string, fields map[string]string) string {
+	for _, subject := range models.AutomationEventSubjects(eventType) {
+		if id, ok := automationSubjectID(subject, fields); ok {
+			return fmt.Sprintf("%s/%d", subject, id)
+		}
+	}
+	return ""
+}
+
+// automationSeverity returns the severity of a notify action, info by default
+func automationSeverity(action models.AutomationAction) string {
+	if severity := action.Params["severity"]; severity != "" {
+		return severity
+	}
+	return models.SeverityInfo
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleConfigFailure logs a configuration a node rejected or rolled back
+// and alerts the node admins. The node keeps serving on its previous
+// configuration, unless no working one was left to roll back to.
+func (s *AgentService) handleConfigFailure(nodeID, nodeName string, failure *pbv1.ConfigFailure) {
+	s.logger.Warn("Node failed to apply a configuration",
+		zap.String("node_id", nodeID),
+		zap.String("node_name", nodeName),
+		zap.String("reason", failure.Reason),
+		zap.String("message", failure.Message),
+	)
+
+	title, severity := "Node rejected a configuration", models.SeverityWarning
+	switch failure.Reason {
+	case "rolled_back":
+		title = "Node rolled back a configuration"
+	case "crash_loop":
+		title, severity = "sing-box is crash-looping on a node", models.SeverityCritical
+	}
+	s.alertNodeManagers(alert.Alert{
+		Type:     models.NotificationTypeNodeConfigFailure,
+		Severity: severity,
+		Title:    title,
+		Message:  fmt.Sprintf("Node %s (%s): %s", nodeName, nodeID, failure.Message),
+		Key:      fmt.Sprintf("node_config_failure:%s:%d", nodeID, failure.GetOccurredAt().GetSeconds()),
+	})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// maxSessionIDLength is the size of the session ID column, sessions with
+// longer IDs are recorded as the user's traffic without session details
+const maxSessionIDLength = 64
+
+// userTrafficRecords returns the traffic records of a user's traffic on a
+// node and the IDs of the sessions the agent reported closed. Traffic broken
+// down by connection is recorded per session, the rest in one record
+// without a session.
+func userTrafficRecords(userID, nodeID uint, traffic *pbv1.UserTraffic, now time.Time) ([]*models.TrafficRecord, []string) {
+	var records []*models.TrafficRecord
+	var closed []string
+	upload, download := traffic.UploadBytes, traffic.DownloadBytes
+	for _, session := range traffic.Sessions {
+		if session.SessionId == "" || len(session.SessionId) > maxSessionIDLength {
+			continue
+		}
+		if session.Closed {
+			closed = append(closed, session.SessionId)
+		}
+		if session.UploadBytes+session.DownloadBytes <= 0 {
+			continue
+		}
+
+		record := newTrafficRecord(userID, nodeID, session.UploadBytes, session.DownloadBytes, now)
+		record.SessionID = session.SessionId
+		record.ClientIP = truncate(session.ClientIp, 45)
+		record.Protocol = truncate(session.Protocol, 32)
+		if session.StartTime != nil && session.StartTime.AsTime().Before(now) {
+			record.ConnectTime = session.StartTime.AsTime()
+		}
+		if session.Closed {
+			record.DisconnectTime = &now
+			record.Duration = int64(now.Sub(record.ConnectTime).Seconds())
+		}
+		records = append(records, record)
+		upload -= session.UploadBytes
+		download -= session.DownloadBytes
+	}
+
+	upload, download = max(upload, 0), max(download, 0)
+	if len(records) == 0 || upload+download > 0 {
+		records = append(records, newTrafficRecord(userID, nodeID, upload, download, now))
+	}
+	return records, closed
+}
+
+// newTrafficRecord returns a record of traffic reported at now
+func newTrafficRecord(userID, nodeID uint, upload, download int64, now time.Time) *models.TrafficRecord {
+	return &models.TrafficRecord{
+		UserID:      userID,
+		NodeID:      nodeID,
+		Upload:      upload,
+		Download:    download,
+		Total:       upload + download,
+		ConnectTime: now,
+		RecordDate:  now.Truncate(24 * time.Hour),
+		RecordHour:  now.Hour(),
+	}
+}
+
+// truncate cuts s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// closeConnections queues a command closing connections of a user on a
+// node, the sessions given or else all of them, and returns its ID
+func (s *AgentService) closeConnections(ctx context.Context, nodeID, userID string, sessionIDs []string) (string, error) {
+	command := &pbv1.PendingCommand{
+		CommandId: generateCommandID(),
+		Command: &pbv1.UserCommand{
+			Type:   pbv1.UserCommand_CLOSE_CONNECTIONS,
+			UserId: userID,
+		},
+		CreatedAt: timestamppb.Now(),
+	}
+	if len(sessionIDs) > 0 {
+		command.Command.Parameters = map[string]string{"session_ids": strings.Join(sessionIDs, ",")}
+	}
+	if err := s.sendCommandToNode(ctx, nodeID, command); err != nil {
+		return "", err
+	}
+	return command.CommandId, nil
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleDiskPressure logs a change of a node's disk pressure and alerts the
+// node admins once per rise to warning or critical
+func (s *AgentService) handleDiskPressure(nodeID, nodeName, previous string, disk *pbv1.DiskStatus) {
+	fields := []zap.Field{
+		zap.String("node_id", nodeID),
+		zap.String("node_name", nodeName),
+		zap.String("pressure", disk.Pressure),
+		zap.Float64("usage_percent", disk.UsagePercent),
+		zap.Int64("free_bytes", disk.FreeBytes),
+		zap.Int64("log_bytes", disk.LogBytes),
+	}
+
+	var severity string
+	switch disk.Pressure {
+	case configv1.DiskPressureWarning:
+		severity = models.SeverityWarning
+	case configv1.DiskPressureCritical:
+		severity = models.SeverityCritical
+	default:
+		if previous != "" && previous != configv1.DiskPressureOK {
+			s.logger.Info("Node disk pressure relieved", fields...)
+		}
+		return
+	}
+	s.logger.Warn("Node disk is filling up", fields...)
+
+	message := fmt.Sprintf("The disk of node %s (%s) is %.1f%% full, %s free; sing-box logs take %s.",
+		nodeName, disk.Path, disk.UsagePercent, models.FormatBytes(disk.FreeBytes), models.FormatBytes(disk.LogBytes))
+	if disk.Pressure == configv1.DiskPressureCritical {
+		message += " The agent refuses disk writes such as geo data downloads until space is freed."
+	}
+	s.alertNodeManagers(alert.Alert{
+		Type:     models.NotificationTypeNodeDiskPressure,
+		Severity: severity,
+		Title:    "Node disk nearly full",
+		Message:  message,
+		Key:      fmt.Sprintf("node_disk:%s:%s:%d", nodeID, disk.Pressure, time.Now().Unix()),
+	})
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// dryRunSampleSize caps the sample changes returned with a dry-run report
+const dryRunSampleSize = 20
+
+// dryRunReport collects the changes a batch operation would make
+type dryRunReport struct {
+	report *pbv1.DryRunReport
+}
+
+// newDryRunReport creates an empty dry-run report
+func newDryRunReport() *dryRunReport {
+	return &dryRunReport{report: &pbv1.DryRunReport{}}
+}
+
+// add records an object that would change. Objects are counted once, while
+// every changed field is kept as a sample until the sample cap is reached.
+func (r *dryRunReport) add(id string, changes ...*pbv1.DryRunChange) {
+	if len(changes) == 0 {
+		return
+	}
+	r.report.AffectedCount++
+	r.report.AffectedIds = append(r.report.AffectedIds, id)
+	for _, change := range changes {
+		if len(r.report.SampleChanges) >= dryRunSampleSize {
+			return
+		}
+		change.Id = id
+		r.report.SampleChanges = append(r.report.SampleChanges, change)
+	}
+}
+
+// proto returns the collected report
+func (r *dryRunReport) proto() *pbv1.DryRunReport {
+	return r.report
+}
+
+// previewUserOperation returns the changes a batch user operation would make
+// to the user; no changes means the operation would leave the user as is
+func previewUserOperation(user *models.User, operation pbv1.BatchUserOperationRequest_OperationType) []*pbv1.DryRunChange {
+	switch operation {
+	case pbv1.BatchUserOperationRequest_DISABLE:
+		return changedField("status", string(user.Status), string(models.UserStatusSuspended))
+	case pbv1.BatchUserOperationRequest_ENABLE:
+		return changedField("status", string(user.Status), string(models.UserStatusActive))
+	case pbv1.BatchUserOperationRequest_RESET_TRAFFIC:
+		return changedField("traffic_used", strconv.FormatInt(user.TrafficUsed, 10), "0")
+	case pbv1.BatchUserOperationRequest_DELETE:
+		return changedField("deleted", "false", "true")
+	}
+	return nil
+}
+
+// previewNodeOperation returns the changes a batch node operation would make to the node
+func previewNodeOperation(node *models.Node, operation pbv1.BatchNodeOperationRequest_OperationType, configContent string) []*pbv1.DryRunChange {
+	switch operation {
+	case pbv1.BatchNodeOperationRequest_ENABLE:
+		return changedField("is_enabled", strconv.FormatBool(node.IsEnabled), "true")
+	case pbv1.BatchNodeOperationRequest_DISABLE:
+		return changedField("is_enabled", strconv.FormatBool(node.IsEnabled), "false")
+	case pbv1.BatchNodeOperationRequest_DELETE:
+		return changedField("deleted", "false", "true")
+	case pbv1.BatchNodeOperationRequest_UPDATE_CONFIG:
+		// Configs are too large for a report, so they are compared by hash
+		return changedField("config_sha256", contentHash(node.ConfigContent), contentHash(configContent))
+	}
+	return nil
+}
+
+// changedField returns a single change, or none when the value stays the same
+func changedField(field, before, after string) []*pbv1.DryRunChange {
+	if before == after {
+		return nil
+	}
+	return []*pbv1.DryRunChange{{Field: field, Before: before, After: after}}
+}
+
+// contentHash returns the hex SHA-256 of a config, or an empty string for no config
+func contentHash(content string) string {
+	if content == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
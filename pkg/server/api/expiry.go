@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// checkExpiry periodically warns users whose account expires soon, expires
+// the accounts whose plan ran out and restores the renewed ones
+func (s *AgentService) checkExpiry(ctx context.Context) {
+	s.runActiveJob(ctx, s.business().Expiry.CheckInterval, func() {
+		now := time.Now()
+		s.warnExpiringAccounts(now)
+		s.expireAccounts(now)
+		s.restoreRenewedAccounts(now)
+	})
+}
+
+// warnExpiringAccounts raises a plan expiring alert for each warning offset
+// an account reached, once per offset and expiration time. Users on trial
+// are left to the trial check while trials are enabled.
+func (s *AgentService) warnExpiringAccounts(now time.Time) {
+	offsets := s.business().Expiry.WarningOffsets
+	if len(offsets) == 0 {
+		return
+	}
+	users, err := s.dbService.GetRepository().User.ListExpiring(now, now.Add(slices.Max(offsets)))
+	if err != nil {
+		s.logger.Error("Failed to list expiring accounts", zap.Error(err))
+		return
+	}
+
+	trials := s.business().Trial.Enabled
+	for _, user := range users {
+		if trials && user.OnTrial() {
+			continue
+		}
+		offset, ok := user.ExpiryWarning(now, offsets)
+		if !ok {
+			continue
+		}
+		s.raiseUserAlert(&alert.Alert{
+			UserID:   user.ID,
+			Type:     models.NotificationTypePlanExpiring,
+			Severity: models.SeverityWarning,
+			Title:    "Plan expiring soon",
+			Message:  fmt.Sprintf("Your plan expires on %s. Renew it to keep your service.", user.ExpiresAt.UTC().Format("2006-01-02 15:04 MST")),
+			Key:      fmt.Sprintf("plan_expiring:%d:%d", user.ExpiresAt.Unix(), int64(offset.Seconds())),
+		})
+	}
+}
+
+// expireAccounts expires the active users whose plan ran out and removes
+// them from their nodes
+func (s *AgentService) expireAccounts(now time.Time) {
+	repo := s.dbService.GetRepository()
+	users, err := repo.User.ListExpired(now)
+	if err != nil {
+		s.logger.Error("Failed to list expired accounts", zap.Error(err))
+		return
+	}
+
+	trials := s.business().Trial.Enabled
+	var expired int
+	for _, user := range users {
+		// Trials end with a downgrade or an expiry of the trial check
+		if trials && user.OnTrial() {
+			continue
+		}
+		nodes, err := repo.Node.GetUserNodes(user.ID)
+		if err != nil {
+			s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint("user_id", user.ID))
+			continue
+		}
+		ok, err := repo.User.Expire(user.ID, now)
+		if err != nil {
+			s.logger.Error("Failed to expire account", zap.Error(err), zap.Uint("user_id", user.ID))
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		for _, node := range nodes {
+			s.pushUser(node.ID, user, pbv1.UserCommand_REMOVE_USER)
+		}
+		s.raiseUserAlert(&alert.Alert{
+			UserID:   user.ID,
+			Type:     models.NotificationTypePlanExpiring,
+			Severity: models.SeverityCritical,
+			Title:    "Plan expired",
+			Message:  "Your plan has expired and your service is paused. Renew it to restore your service.",
+			Key:      fmt.Sprintf("plan_expired:%d", user.ExpiresAt.Unix()),
+		})
+		expired++
+	}
+
+	if expired > 0 {
+		s.logger.Info("Expired accounts", zap.Int("users", expired))
+	}
+}
+
+// restoreRenewedAccounts restores the expired users that were renewed to
+// their nodes, for renewals that were not restored when paid
+func (s *AgentService) restoreRenewedAccounts(now time.Time) {
+	users, err := s.dbService.GetRepository().User.ListRenewed(now)
+	if err != nil {
+		s.logger.Error("Failed to list renewed accounts", zap.Error(err))
+		return
+	}
+	for _, user := range users {
+		if err := s.restoreUser(user); err != nil {
+			s.logger.Error("Failed to restore renewed account", zap.Error(err), zap.Uint("user_id", user.ID))
+		}
+	}
+}
+
+// restoreUser adds a renewed user back to its nodes, once
+func (s *AgentService) restoreUser(user *models.User) error {
+	repo := s.dbService.GetRepository()
+	ok, err := repo.User.MarkRestored(user.ID)
+	if err != nil || !ok {
+		return err
+	}
+	nodes, err := repo.Node.GetUserNodes(user.ID)
+	if err != nil {
+		return fmt.Errorf("restored, but the nodes were not provisioned: %w", err)
+	}
+	for _, node := range nodes {
+		s.pushUser(node.ID, user, pbv1.UserCommand_ADD_USER)
+	}
+	s.logger.Info("Renewed account restored", zap.Uint("user_id", user.ID), zap.Int("nodes", len(nodes)))
+	return nil
+}
+
+// restorePaidUser restores a user whose expired account a payment renewed
+// right away when this instance serves the agents, leaving it to the expiry
+// check otherwise
+func (s *ManagementService) restorePaidUser(userID uint) {
+	if s.agents == nil || !s.agents.active() {
+		return
+	}
+	user, err := s.dbService.GetRepository().User.GetByID(userID)
+	if err != nil {
+		s.logger.Warn("Failed to get paid user", zap.Error(err), zap.Uint("user_id", userID))
+		return
+	}
+	if user.ExpiredAt == nil || user.Status != models.UserStatusActive {
+		return
+	}
+	if err := s.agents.restoreUser(user); err != nil {
+		s.logger.Warn("Failed to restore paid user", zap.Error(err), zap.Uint("user_id", userID))
+	}
+}
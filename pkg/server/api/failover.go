@@ -0,0 +1,285 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// failoverUsers periodically moves users off offline nodes and back once
+// the nodes recovered
+func (s *AgentService) failoverUsers(ctx context.Context) {
+	ticker := time.NewTicker(s.business().Failover.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Standbys do not know which nodes are connected
+			if !s.active() {
+				continue
+			}
+			s.performFailover(time.Now())
+		}
+	}
+}
+
+// performFailover moves the users of nodes offline for longer than
+// maxOfflineTime and reverts the failovers of nodes connected for revertAfter
+func (s *AgentService) performFailover(now time.Time) {
+	repo := s.dbService.GetRepository()
+	maxOfflineTime := s.business().Node.MaxOfflineTime
+
+	offline, err := repo.Node.GetOfflineNodes(maxOfflineTime)
+	if err != nil {
+		s.logger.Error("Failed to list offline nodes for failover", zap.Error(err))
+	}
+	for _, node := range offline {
+		// Nodes that never connected have no users relying on them
+		if node.LastHeartbeat == nil || s.connectedSince(node.ID) != nil {
+			continue
+		}
+		s.failoverNode(node, now.Add(-maxOfflineTime))
+	}
+
+	nodeIDs, err := repo.NodeFailover.ListActiveNodeIDs()
+	if err != nil {
+		s.logger.Error("Failed to list failed over nodes", zap.Error(err))
+		return
+	}
+	for _, nodeID := range nodeIDs {
+		since := s.connectedSince(nodeID)
+		if since == nil || now.Sub(*since) < s.business().Failover.RevertAfter {
+			continue
+		}
+		node, err := repo.Node.GetByID(nodeID)
+		if err != nil {
+			s.logger.Error("Failed to get recovered node", zap.Error(err), zap.Uint("node_id", nodeID))
+			continue
+		}
+		// Degraded nodes and those in maintenance keep their users away
+		if node.Status != models.NodeStatusOnline {
+			continue
+		}
+		s.revertFailovers(node)
+	}
+}
+
+// connectedSince returns when a node connected to this instance, nil when it
+// is not connected
+func (s *AgentService) connectedSince(nodeID uint) *time.Time {
+	s.nodesMux.RLock()
+	defer s.nodesMux.RUnlock()
+
+	state, ok := s.nodes[strconv.FormatUint(uint64(nodeID), 10)]
+	if !ok {
+		return nil
+	}
+	since := state.RegisteredAt
+	return &since
+}
+
+// failoverNode moves the users assigned to an offline node to the candidate
+// nodes their plans grant, skipping users without one
+func (s *AgentService) failoverNode(node *models.Node, since time.Time) {
+	repo := s.dbService.GetRepository().NodeFailover
+
+	users, err := repo.ListAffectedUsers(node.ID)
+	if err != nil {
+		s.logger.Error("Failed to list users of offline node", zap.Error(err), zap.Uint("node_id", node.ID))
+		return
+	}
+	if len(users) == 0 {
+		return
+	}
+	candidates, err := repo.ListCandidates(node, since)
+	if err != nil {
+		s.logger.Error("Failed to list failover nodes", zap.Error(err), zap.Uint("node_id", node.ID))
+		return
+	}
+
+	reason := fmt.Sprintf("node %s offline since %s", node.Name, node.LastHeartbeat.Format(time.RFC3339))
+	grants := make(map[uint]map[uint]bool)
+	moved, stranded := 0, 0
+	for _, user := range users {
+		granted, ok := grants[user.PlanID]
+		if !ok {
+			if granted, err = repo.GrantedNodeIDs(user.PlanID); err != nil {
+				s.logger.Error("Failed to get plan nodes", zap.Error(err), zap.Uint("plan_id", user.PlanID))
+				continue
+			}
+			grants[user.PlanID] = granted
+		}
+		assigned, err := repo.AssignedNodeIDs(user.ID)
+		if err != nil {
+			s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint("user_id", user.ID))
+			continue
+		}
+
+		target := models.PickFailoverCandidate(candidates, func(nodeID uint) bool {
+			return granted[nodeID] && !assigned[nodeID]
+		})
+		if target == nil {
+			stranded++
+			continue
+		}
+
+		failover := &models.NodeFailover{UserID: user.ID, FromNodeID: node.ID, ToNodeID: target.Node.ID, Reason: reason}
+		err = repo.Failover(failover)
+		if errors.Is(err, repository.ErrFailoverStale) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to fail over user", zap.Error(err), zap.Uint("user_id", user.ID), zap.Uint("node_id", node.ID))
+			continue
+		}
+		target.Assigned++
+		moved++
+		s.pushUser(target.Node.ID, user, pbv1.UserCommand_ADD_USER)
+	}
+
+	if moved > 0 {
+		s.logger.Info("Failed over users of offline node",
+			zap.Uint("node_id", node.ID),
+			zap.String("node_name", node.Name),
+			zap.Int("users", moved),
+		)
+	}
+	if stranded > 0 {
+		s.logger.Warn("No failover node for users of offline node",
+			zap.Uint("node_id", node.ID),
+			zap.String("node_name", node.Name),
+			zap.Int("users", stranded),
+		)
+	}
+}
+
+// revertFailovers moves the users failed over from a node back to it
+func (s *AgentService) revertFailovers(node *models.Node) {
+	repo := s.dbService.GetRepository()
+
+	failovers, err := repo.NodeFailover.ListActive(node.ID)
+	if err != nil {
+		s.logger.Error("Failed to list failovers", zap.Error(err), zap.Uint("node_id", node.ID))
+		return
+	}
+
+	reverted := 0
+	for _, failover := range failovers {
+		err := repo.NodeFailover.Revert(failover)
+		if errors.Is(err, repository.ErrFailoverStale) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to revert failover", zap.Error(err), zap.Uint("user_id", failover.UserID), zap.Uint("node_id", node.ID))
+			continue
+		}
+		reverted++
+
+		s.pushUser(node.ID, &failover.User, pbv1.UserCommand_ADD_USER)
+		// Plans often grant the failover node too, the user stays there then
+		if !s.userHasNode(failover.UserID, failover.ToNodeID) {
+			s.pushUser(failover.ToNodeID, &failover.User, pbv1.UserCommand_REMOVE_USER)
+		}
+	}
+
+	if reverted > 0 {
+		s.logger.Info("Moved failed over users back to recovered node",
+			zap.Uint("node_id", node.ID),
+			zap.String("node_name", node.Name),
+			zap.Int("users", reverted),
+		)
+	}
+}
+
+// userSpeedLimitOn returns the speed limit of a user on a node, the user's
+// own limit when the node's cannot be read
+func (s *AgentService) userSpeedLimitOn(nodeID uint, user *models.User) int64 {
+	repo := s.dbService.GetRepository()
+	node, err := repo.Node.GetByID(nodeID)
+	if err == nil {
+		var limit int64
+		if limit, err = userSpeedLimit(repo, user, node); err == nil {
+			return limit
+		}
+	}
+	s.logger.Error("Failed to get speed limit of user on node", zap.Error(err),
+		zap.Uint("user_id", user.ID), zap.Uint("node_id", nodeID))
+	return user.SpeedLimit
+}
+
+// addPlanRouting adds the protocols and the route rules the plan of a user
+// restricts the user to, to the parameters of a user command. Users whose
+// plan cannot be read keep their current routing.
+func (s *AgentService) addPlanRouting(parameters map[string]string, user *models.User) {
+	plan, err := s.dbService.GetRepository().Plan.GetByID(user.PlanID)
+	if err != nil {
+		s.logger.Error("Failed to get plan of user", zap.Error(err),
+			zap.Uint("user_id", user.ID), zap.Uint("plan_id", user.PlanID))
+		return
+	}
+	if protocols := plan.AllowedProtocolList(); len(protocols) > 0 {
+		parameters["protocols"] = strings.Join(protocols, ",")
+	}
+	parameters["route_rules"] = plan.RouteRules("user" + strconv.FormatUint(uint64(user.ID), 10))
+}
+
+// userHasNode reports whether a user may still use a node, assuming so
+// when that cannot be told
+func (s *AgentService) userHasNode(userID, nodeID uint) bool {
+	nodes, err := s.dbService.GetRepository().Node.GetUserNodes(userID)
+	if err != nil {
+		s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint("user_id", userID))
+		return true
+	}
+	for _, node := range nodes {
+		if node.ID == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// pushUser queues a command adding a user to, updating one on or removing
+// one from a node. Users added or updated carry their speed limit on the
+// node. Nodes not connected to this instance are skipped, the error is
+// logged and returned.
+func (s *AgentService) pushUser(nodeID uint, user *models.User, commandType pbv1.UserCommand_CommandType) error {
+	parameters := map[string]string{"uuid": user.UUID}
+	if commandType != pbv1.UserCommand_REMOVE_USER {
+		parameters["speed_limit"] = strconv.FormatInt(s.userSpeedLimitOn(nodeID, user), 10)
+		s.addPlanRouting(parameters, user)
+	}
+
+	id := strconv.FormatUint(uint64(nodeID), 10)
+	err := s.sendCommandToNode(context.Background(), id, &pbv1.PendingCommand{
+		CommandId: generateCommandID(),
+		Command: &pbv1.UserCommand{
+			Type:       commandType,
+			UserId:     strconv.FormatUint(uint64(user.ID), 10),
+			Parameters: parameters,
+		},
+		CreatedAt: timestamppb.Now(),
+	})
+	if err != nil {
+		s.logger.Warn("Failed to push user to node",
+			zap.Error(err),
+			zap.String("node_id", id),
+			zap.Uint("user_id", user.ID),
+			zap.String("command", commandType.String()),
+		)
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/geodata"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// errGeoDataDisabled is returned by geo data RPCs when distribution is not configured
+var errGeoDataDisabled = apierror.FailedPrecondition(apierror.ReasonGeoDataDisabled, "geo_data",
+	"geo data distribution is not enabled on this API server")
+
+// GetGeoDataManifest lists the geo databases nodes should hold
+func (s *AgentService) GetGeoDataManifest(ctx context.Context, req *pbv1.GetGeoDataManifestRequest) (*pbv1.GetGeoDataManifestResponse, error) {
+	s.logger.Debug("GetGeoDataManifest called", zap.String("node_id", req.NodeId))
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	if s.geoData == nil {
+		return nil, errGeoDataDisabled
+	}
+
+	artifacts := s.geoData.Artifacts()
+	resp := &pbv1.GetGeoDataManifestResponse{Artifacts: make([]*pbv1.GeoDataArtifact, len(artifacts))}
+	for i, artifact := range artifacts {
+		resp.Artifacts[i] = &pbv1.GeoDataArtifact{
+			Name:        artifact.Name,
+			Version:     artifact.Version,
+			Sha256:      artifact.SHA256,
+			Size:        artifact.Size,
+			PublishedAt: timestamppb.New(artifact.PublishedAt),
+		}
+	}
+	return resp, nil
+}
+
+// DownloadGeoData returns one chunk of a cached geo database
+func (s *AgentService) DownloadGeoData(ctx context.Context, req *pbv1.DownloadGeoDataRequest) (*pbv1.DownloadGeoDataResponse, error) {
+	s.logger.Debug("DownloadGeoData called",
+		zap.String("node_id", req.NodeId),
+		zap.String("name", req.Name),
+		zap.Int64("offset", req.Offset),
+	)
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	if req.Name == "" {
+		return nil, apierror.MissingField("name")
+	}
+	if req.Sha256 == "" {
+		return nil, apierror.MissingField("sha256")
+	}
+	if s.geoData == nil {
+		return nil, errGeoDataDisabled
+	}
+
+	data, artifact, err := s.geoData.ReadChunk(req.Name, req.Sha256, req.Offset)
+	switch {
+	case errors.Is(err, geodata.ErrUnknownArtifact):
+		return nil, apierror.NotFound(apierror.ResourceGeoData, req.Name)
+	case errors.Is(err, geodata.ErrArtifactChanged):
+		return nil, apierror.FailedPrecondition(apierror.ReasonGeoDataChanged, "geo_data/"+req.Name,
+			"geo data was updated, fetch the manifest again")
+	case err != nil && artifact != nil && (req.Offset < 0 || req.Offset > artifact.Size):
+		return nil, apierror.InvalidField("offset", "offset is out of range")
+	case err != nil:
+		s.logger.Error("Failed to read geo data", zap.Error(err), zap.String("name", req.Name))
+		return nil, status.Error(codes.Internal, "failed to read geo data")
+	}
+
+	return &pbv1.DownloadGeoDataResponse{
+		Data: data,
+		Size: artifact.Size,
+		Eof:  req.Offset+int64(len(data)) >= artifact.Size,
+	}, nil
+}
+
+// refreshGeoData restores the geo data cache and refreshes it from the
+// sources periodically, right away when something is missing or outdated
+func (s *AgentService) refreshGeoData(ctx context.Context) {
+	upToDate, err := s.geoData.Load()
+	if err != nil {
+		s.logger.Error("Failed to load geo data cache", zap.Error(err))
+	}
+	if !upToDate && s.active() {
+		s.performGeoDataRefresh(ctx)
+	}
+
+	s.runActiveJob(ctx, s.business().GeoData.RefreshInterval, func() {
+		s.performGeoDataRefresh(ctx)
+	})
+}
+
+// performGeoDataRefresh refreshes the geo data cache and asks the connected
+// nodes to sync when a database changed
+func (s *AgentService) performGeoDataRefresh(ctx context.Context) {
+	changed, err := s.geoData.Refresh(ctx)
+	if err != nil {
+		s.logger.Error("Failed to refresh geo data", zap.Error(err))
+	}
+	if len(changed) > 0 {
+		for nodeID := range s.GetNodeStates() {
+			if err := s.sendGeoDataSync(ctx, nodeID); err != nil {
+				s.logger.Warn("Failed to request geo data sync", zap.Error(err), zap.String("node_id", nodeID))
+			}
+		}
+	}
+}
+
+// sendGeoDataSync queues a geo data sync command for a connected node
+func (s *AgentService) sendGeoDataSync(ctx context.Context, nodeID string) error {
+	return s.sendCommandToNode(ctx, nodeID, &pbv1.PendingCommand{
+		CommandId: generateCommandID(),
+		Command: &pbv1.UserCommand{
+			Type:   pbv1.UserCommand_SYNC_GEODATA,
+			UserId: "system",
+		},
+		CreatedAt: timestamppb.Now(),
+	})
+}
+
+// recordGeoDataVersions stores the geo data versions a node reported
+func (s *AgentService) recordGeoDataVersions(nodeID string, versions []*pbv1.GeoDataVersion) {
+	id, err := strconv.ParseUint(nodeID, 10, 32)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	records := make([]*models.NodeGeoData, len(versions))
+	for i, version := range versions {
+		records[i] = &models.NodeGeoData{
+			Name:       version.Name,
+			Version:    version.Version,
+			SHA256:     version.Sha256,
+			ReportedAt: now,
+		}
+	}
+
+	// Versions are informational, a failed write must not fail the heartbeat
+	if err := s.dbService.GetRepository().GeoData.SaveNodeVersions(uint(id), records); err != nil {
+		s.logger.Warn("Failed to record geo data versions", zap.Error(err), zap.String("node_id", nodeID))
+	}
+}
+
+// sameGeoData reports whether two node statuses carry the same geo data versions
+func sameGeoData(a, b *pbv1.NodeStatus) bool {
+	return slices.EqualFunc(a.GetGeoData(), b.GetGeoData(), func(x, y *pbv1.GeoDataVersion) bool {
+		return x.Name == y.Name && x.Sha256 == y.Sha256 && x.Version == y.Version
+	})
+}
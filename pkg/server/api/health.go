@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/metrics"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// healthCheckedStatuses are the statuses the health checks score and move between
+var healthCheckedStatuses = []models.NodeStatus{models.NodeStatusOnline, models.NodeStatusDegraded, models.NodeStatusMaintenance}
+
+// nodeStatusEventTypes maps node statuses to the type of their events
+var nodeStatusEventTypes = map[models.NodeStatus]string{
+	models.NodeStatusOnline:      events.TypeNodeOnline,
+	models.NodeStatusOffline:     events.TypeNodeOffline,
+	models.NodeStatusDegraded:    events.TypeNodeDegraded,
+	models.NodeStatusMaintenance: events.TypeNodeMaintenance,
+}
+
+// heartbeatCount is what a node's heartbeats reported since the last health check
+type heartbeatCount struct {
+	lastSeen time.Time
+	total    int64
+	errors   int64
+}
+
+// checkNodeHealth periodically scores the nodes and moves their status
+func (s *AgentService) checkNodeHealth(ctx context.Context) {
+	ticker := time.NewTicker(s.business().Health.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Standbys receive no heartbeats, the active instance does the work
+			if !s.active() {
+				continue
+			}
+			s.performHealthCheck(time.Now())
+		}
+	}
+}
+
+// takeHeartbeatCounts returns the heartbeat counts of the connected nodes
+// and starts new ones
+func (s *AgentService) takeHeartbeatCounts() map[uint]heartbeatCount {
+	s.nodesMux.Lock()
+	defer s.nodesMux.Unlock()
+
+	counts := make(map[uint]heartbeatCount, len(s.nodes))
+	for nodeID, node := range s.nodes {
+		id, err := strconv.ParseUint(nodeID, 10, 32)
+		if err != nil {
+			continue
+		}
+		counts[uint(id)] = heartbeatCount{lastSeen: node.LastSeen, total: node.Heartbeats, errors: node.ErrorHeartbeats}
+		node.Heartbeats, node.ErrorHeartbeats = 0, 0
+	}
+	return counts
+}
+
+// performHealthCheck scores the health of the nodes and applies the status
+// transitions the scores call for
+func (s *AgentService) performHealthCheck(now time.Time) {
+	config := s.business().Health
+	repo := s.dbService.GetRepository()
+	counts := s.takeHeartbeatCounts()
+
+	var nodes []*models.Node
+	for _, nodeStatus := range healthCheckedStatuses {
+		list, _, err := repo.Node.ListByStatus(nodeStatus, 0, -1)
+		if err != nil {
+			s.logger.Error("Failed to list nodes for the health check", zap.Error(err))
+			return
+		}
+		nodes = append(nodes, list...)
+	}
+	if len(nodes) == 0 {
+		return
+	}
+
+	nodeIDs := make([]uint, len(nodes))
+	for i, node := range nodes {
+		nodeIDs[i] = node.ID
+	}
+	// Without probe data packet loss is left out of the scores
+	probes, err := repo.Probe.GetNodeStats(nodeIDs, now.Add(-config.ProbeWindow))
+	if err != nil {
+		s.logger.Warn("Failed to get probe stats for the health check", zap.Error(err))
+	}
+
+	// A missed heartbeat is tolerated, nodes are gone after maxOfflineTime
+	policy := models.NodeHealthPolicy{
+		HeartbeatInterval: 2 * s.business().Node.HeartbeatInterval,
+		HeartbeatTimeout:  s.business().Node.MaxOfflineTime,
+		LoadThreshold:     config.LoadThreshold,
+		MinProbeSamples:   int64(config.MinProbeSamples),
+		HeartbeatWeight:   config.HeartbeatWeight,
+		LoadWeight:        config.LoadWeight,
+		ErrorWeight:       config.ErrorWeight,
+		PacketLossWeight:  config.PacketLossWeight,
+	}
+	thresholds := models.NodeHealthThresholds{
+		DegradedBelow:    config.DegradedBelow,
+		MaintenanceBelow: config.MaintenanceBelow,
+		RecoverAbove:     config.RecoverAbove,
+	}
+
+	for _, node := range nodes {
+		count := counts[node.ID]
+		lastSeen := count.lastSeen
+		if node.LastHeartbeat != nil && node.LastHeartbeat.After(lastSeen) {
+			lastSeen = *node.LastHeartbeat
+		}
+
+		input := models.NodeHealthInput{
+			HeartbeatAge:    now.Sub(lastSeen),
+			CPUUsage:        node.CPUUsage,
+			MemoryUsage:     node.MemoryUsage,
+			Heartbeats:      count.total,
+			ErrorHeartbeats: count.errors,
+		}
+		if stats := probes[node.ID]; stats != nil {
+			input.ProbeSamples = stats.Samples
+			input.ProbeSuccesses = stats.Successes
+		}
+		health := input.Evaluate(policy)
+		metrics.SetNodeHealthScore(strconv.FormatUint(uint64(node.ID), 10), node.Name, health.Score)
+
+		next := health.NextStatus(node.Status, node.StatusAutomatic, thresholds)
+		if next == node.Status {
+			if err := repo.Node.SetHealthScore(node.ID, health.Score); err != nil {
+				s.logger.Error("Failed to store node health score", zap.Error(err), zap.Uint("node_id", node.ID))
+			}
+			continue
+		}
+		s.transitionNodeStatus(node, next, health)
+	}
+}
+
+// transitionNodeStatus moves a node to the status its health calls for,
+// recording the transition and publishing it to the event bus
+func (s *AgentService) transitionNodeStatus(node *models.Node, next models.NodeStatus, health models.NodeHealth) {
+	transition := &models.NodeStatusTransition{
+		NodeID:      node.ID,
+		From:        node.Status,
+		To:          next,
+		Reason:      health.Reason(),
+		HealthScore: health.Score,
+		Automatic:   true,
+	}
+	err := s.dbService.GetRepository().Node.TransitionStatus(transition)
+	if errors.Is(err, repository.ErrNodeStatusChanged) {
+		// The next check judges the node in its new status
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to change node status", zap.Error(err), zap.Uint("node_id", node.ID))
+		return
+	}
+
+	s.logger.Info("Node status changed by the health check",
+		zap.Uint("node_id", node.ID),
+		zap.String("node_name", node.Name),
+		zap.String("from", string(transition.From)),
+		zap.String("to", string(transition.To)),
+		zap.String("reason", transition.Reason),
+	)
+	s.events.Publish(&pbv1.Event{
+		Type: nodeStatusEventTypes[next],
+		NodeStatus: &pbv1.NodeStatusEvent{
+			NodeId:      strconv.FormatUint(uint64(node.ID), 10),
+			NodeName:    node.Name,
+			Status:      string(next),
+			Reason:      transition.Reason,
+			HealthScore: health.Score,
+		},
+	})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/models"
+)
+
+// checkInactiveAccounts periodically applies the inactive account policy
+func (s *AgentService) checkInactiveAccounts(ctx context.Context) {
+	s.runActiveJob(ctx, s.business().Inactivity.CheckInterval, func() {
+		s.performInactivityCheck(time.Now())
+	})
+}
+
+// performInactivityCheck warns, suspends and purges the idle accounts the
+// policy applies to, see models.User.InactivityAction
+func (s *AgentService) performInactivityCheck(now time.Time) {
+	policy := s.business().Inactivity
+	repo := s.dbService.GetRepository().User
+
+	// Nothing is due for users idle less than the warning time
+	users, err := repo.ListInactive(now.Add(-(policy.SuspendAfter - policy.WarningBefore)), policy.FreeOnly)
+	if err != nil {
+		s.logger.Error("Failed to list inactive accounts", zap.Error(err))
+		return
+	}
+
+	var warned, suspended, purged int
+	for _, user := range users {
+		switch user.InactivityAction(now, policy.SuspendAfter, policy.WarningBefore, policy.PurgeAfter) {
+		case models.InactivityWarn:
+			if err := repo.MarkInactivityWarned(user.ID, now); err != nil {
+				s.logger.Error("Failed to record inactivity warning", zap.Error(err), zap.Uint("user_id", user.ID))
+				continue
+			}
+			suspendAt := now.Add(policy.WarningBefore)
+			s.raiseUserAlert(&alert.Alert{
+				UserID:   user.ID,
+				Type:     models.NotificationTypeAccountInactive,
+				Severity: models.SeverityWarning,
+				Title:    "Account inactive",
+				Message: fmt.Sprintf("Your account has not been used since %s and will be suspended on %s. Log in or connect to keep it.",
+					user.LastActiveAt().UTC().Format("2006-01-02"), suspendAt.UTC().Format("2006-01-02")),
+				Key: fmt.Sprintf("inactivity_warning:%d", now.Unix()),
+			})
+			warned++
+
+		case models.InactivitySuspend:
+			ok, err := repo.SuspendForInactivity(user.ID, now)
+			if err != nil {
+				s.logger.Error("Failed to suspend inactive account", zap.Error(err), zap.Uint("user_id", user.ID))
+				continue
+			}
+			if !ok {
+				continue
+			}
+			message := "Your account was suspended for inactivity. Log in to reactivate it."
+			if policy.PurgeAfter > 0 {
+				message = fmt.Sprintf("Your account was suspended for inactivity. Log in to reactivate it before %s, when it will be deleted.",
+					user.LastActiveAt().Add(policy.PurgeAfter).UTC().Format("2006-01-02"))
+			}
+			s.raiseUserAlert(&alert.Alert{
+				UserID:   user.ID,
+				Type:     models.NotificationTypeAccountInactive,
+				Severity: models.SeverityCritical,
+				Title:    "Account suspended for inactivity",
+				Message:  message,
+				Key:      fmt.Sprintf("inactivity_suspended:%d", now.Unix()),
+			})
+			suspended++
+
+		case models.InactivityPurge:
+			if err := repo.Delete(user.ID); err != nil {
+				s.logger.Error("Failed to purge inactive account", zap.Error(err), zap.Uint("user_id", user.ID))
+				continue
+			}
+			s.logger.Info("Inactive account purged",
+				zap.Uint("user_id", user.ID),
+				zap.String("username", user.Username),
+				zap.Time("last_active_at", user.LastActiveAt()),
+			)
+			purged++
+		}
+	}
+
+	if warned+suspended+purged > 0 {
+		s.logger.Info("Inactive account policy applied",
+			zap.Int("warned", warned),
+			zap.Int("suspended", suspended),
+			zap.Int("purged", purged),
+		)
+	}
+}
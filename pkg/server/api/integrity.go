@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/models"
+)
+
+// maxIntegrityIssues bounds the issues of each check per run
+const maxIntegrityIssues = 1000
+
+// checkIntegrity periodically verifies the invariants spanning several tables
+func (s *AgentService) checkIntegrity(ctx context.Context) {
+	s.runActiveJob(ctx, s.business().Integrity.CheckInterval, func() {
+		s.performIntegrityCheck(time.Now())
+	})
+}
+
+// performIntegrityCheck finds the violated invariants, repairs them when
+// configured to and logs them
+func (s *AgentService) performIntegrityCheck(now time.Time) {
+	policy := s.business().Integrity
+	repos := s.dbService.GetRepository()
+	report := &models.IntegrityReport{CheckedAt: now}
+
+	checks := []struct {
+		check models.IntegrityCheck
+		find  func() ([]models.IntegrityIssue, error)
+	}{
+		{models.IntegrityPlanUserCount, func() ([]models.IntegrityIssue, error) {
+			return repos.Integrity.PlanUserCountIssues(maxIntegrityIssues)
+		}},
+		{models.IntegrityNodeUserCount, func() ([]models.IntegrityIssue, error) {
+			return repos.Integrity.NodeUserCountIssues(maxIntegrityIssues)
+		}},
+		{models.IntegrityUserPlan, func() ([]models.IntegrityIssue, error) {
+			return repos.Integrity.UserPlanIssues(maxIntegrityIssues)
+		}},
+		{models.IntegrityTrafficSummary, func() ([]models.IntegrityIssue, error) {
+			// Today's summaries are still being aggregated
+			today := now.UTC().Truncate(24 * time.Hour)
+			return repos.Integrity.TrafficSummaryIssues(today.AddDate(0, 0, -policy.TrafficDays), today, policy.TrafficTolerance)
+		}},
+	}
+	for _, c := range checks {
+		issues, err := c.find()
+		if err != nil {
+			s.logger.Error("Failed to run integrity check", zap.Error(err), zap.String("check", string(c.check)))
+			continue
+		}
+		for i := range issues {
+			if policy.Repair {
+				issues[i].Repaired = s.repairIntegrityIssue(issues[i])
+			}
+			s.logger.Warn("Integrity issue found",
+				zap.String("check", string(issues[i].Check)),
+				zap.String("issue", issues[i].String()),
+				zap.Bool("repaired", issues[i].Repaired),
+			)
+		}
+		report.Issues = append(report.Issues, issues...)
+	}
+
+	if len(report.Issues) > 0 {
+		s.logger.Warn("Integrity check found issues",
+			zap.Int("plan_user_count", report.Count(models.IntegrityPlanUserCount)),
+			zap.Int("node_user_count", report.Count(models.IntegrityNodeUserCount)),
+			zap.Int("user_plan", report.Count(models.IntegrityUserPlan)),
+			zap.Int("traffic_summary", report.Count(models.IntegrityTrafficSummary)),
+			zap.Int("repaired", report.Repaired()),
+		)
+	} else {
+		s.logger.Debug("integrity check passed")
+	}
+}
+
+// repairIntegrityIssue corrects the stored value of an issue and reports
+// whether it did; users of missing plans are left to the admins
+func (s *AgentService) repairIntegrityIssue(issue models.IntegrityIssue) bool {
+	repos := s.dbService.GetRepository()
+
+	var err error
+	switch issue.Check {
+	case models.IntegrityPlanUserCount:
+		err = repos.Plan.UpdateUserCount(issue.SubjectID, int(issue.Expected))
+	case models.IntegrityNodeUserCount:
+		err = repos.Node.UpdateUserCount(issue.SubjectID, int(issue.Expected))
+	case models.IntegrityTrafficSummary:
+		_, err = repos.Traffic.CheckSummaries(*issue.Date)
+	default:
+		return false
+	}
+	if err != nil {
+		s.logger.Error("Failed to repair integrity issue", zap.Error(err), zap.String("issue", issue.String()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/metrics"
+	"sing-box-web/pkg/ratelimit"
+)
+
+// managementServicePrefix is the full method prefix of ManagementService RPCs
+const managementServicePrefix = "/api.v1.ManagementService/"
+
+// splitMethod splits a full method name "/package.Service/Method" into the
+// service and method names
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, _ = strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
+
+// newRecoveryInterceptor turns a panic of a handler into an Internal error,
+// so that one faulty call does not bring the server down
+func newRecoveryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer recoverCall(ctx, logger, info.FullMethod, &err)
+		return handler(ctx, req)
+	}
+}
+
+// newStreamRecoveryInterceptor is newRecoveryInterceptor for streams
+func newStreamRecoveryInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer recoverCall(ss.Context(), logger, info.FullMethod, &err)
+		return handler(srv, ss)
+	}
+}
+
+// recoverCall recovers a panic of a call, setting err to an Internal error
+func recoverCall(ctx context.Context, log *zap.Logger, fullMethod string, err *error) {
+	if r := recover(); r != nil {
+		logger.FromContext(ctx, log).Error("Recovered from panic in gRPC handler",
+			zap.String("method", fullMethod),
+			zap.Any("panic", r),
+			zap.ByteString("stack", debug.Stack()),
+		)
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
+
+// newRequestInterceptor assigns each call a request ID, taken from the
+// caller's x-request-id metadata or generated, returns it in the response
+// header, records the call in the gRPC request metrics and logs it as
+// configured
+func newRequestInterceptor(config configv1.GRPCInterceptorConfig, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = withRequestID(ctx)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeCall(ctx, config, logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// newStreamRequestInterceptor is newRequestInterceptor for streams; a stream
+// is observed once it ends
+func newStreamRequestInterceptor(config configv1.GRPCInterceptorConfig, logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := withRequestID(ss.Context())
+		start := time.Now()
+		err := handler(srv, &contextServerStream{ServerStream: ss, ctx: ctx})
+		observeCall(ctx, config, logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// withRequestID stores the request ID of a call in its context and sends it
+// back in the response header
+func withRequestID(ctx context.Context) context.Context {
+	var requestID string
+	if values := metadata.ValueFromIncomingContext(ctx, logger.RequestIDHeader); len(values) > 0 {
+		requestID = values[0]
+	}
+	requestID = logger.RequestIDOrNew(requestID)
+	grpc.SetHeader(ctx, metadata.Pairs(logger.RequestIDHeader, requestID))
+	return logger.ContextWithRequestID(ctx, requestID)
+}
+
+// observeCall records a finished call in the metrics and logs it
+func observeCall(ctx context.Context, config configv1.GRPCInterceptorConfig, log *zap.Logger, fullMethod string, start time.Time, err error) {
+	duration := time.Since(start)
+	code := status.Code(err)
+	service, method := splitMethod(fullMethod)
+	metrics.RecordGRPCRequest(service, method, code.String(), duration)
+
+	slow := config.SlowCallThreshold > 0 && duration > config.SlowCallThreshold
+	if !config.LogRequests && !slow {
+		return
+	}
+	log = logger.FromContext(ctx, log)
+	fields := []zap.Field{
+		zap.String("method", fullMethod),
+		zap.String("code", code.String()),
+		zap.Duration("duration", duration),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+	switch {
+	case slow:
+		log.Warn("Slow gRPC call", fields...)
+	case err != nil:
+		log.Warn("gRPC call failed", append(fields, zap.Error(err))...)
+	default:
+		log.Debug("gRPC call", fields...)
+	}
+}
+
+// contextServerStream replaces the context of a server stream
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the replaced context
+func (s *contextServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// newManagementAuthInterceptor requires the management token as the bearer
+// token of ManagementService calls
+func newManagementAuthInterceptor(token string, logger *zap.Logger) grpc.UnaryServerInterceptor {
+	logger = logger.Named("management-auth")
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkManagementToken(ctx, token, info.FullMethod, logger); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// newStreamManagementAuthInterceptor is newManagementAuthInterceptor for streams
+func newStreamManagementAuthInterceptor(token string, logger *zap.Logger) grpc.StreamServerInterceptor {
+	logger = logger.Named("management-auth")
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkManagementToken(ss.Context(), token, info.FullMethod, logger); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// checkManagementToken checks the bearer token of ManagementService calls
+func checkManagementToken(ctx context.Context, token, fullMethod string, logger *zap.Logger) error {
+	if !strings.HasPrefix(fullMethod, managementServicePrefix) {
+		return nil
+	}
+	presented := bearerTokenFromContext(ctx)
+	if presented == "" {
+		return apierror.New(codes.Unauthenticated, apierror.ReasonManagementTokenMissing, "management token is required", nil)
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+		logger.Warn("Rejected invalid management token", zap.String("method", fullMethod))
+		return apierror.New(codes.Unauthenticated, apierror.ReasonManagementTokenInvalid, "invalid management token", nil)
+	}
+	return nil
+}
+
+// rateLimiter limits the calls of each caller to each method with the token
+// buckets of pkg/ratelimit, refilled over the window. They are kept in
+// memory, so every API instance enforces its own limits.
+type rateLimiter struct {
+	config configv1.GRPCRateLimitConfig
+	store  *ratelimit.MemoryStore
+}
+
+func newRateLimiter(config configv1.GRPCRateLimitConfig) *rateLimiter {
+	return &rateLimiter{config: config, store: ratelimit.NewMemoryStore()}
+}
+
+// limit returns the limit of a method, 0 for unlimited
+func (l *rateLimiter) limit(fullMethod string) int {
+	if limit, ok := l.config.Methods[fullMethod]; ok {
+		return limit
+	}
+	_, method := splitMethod(fullMethod)
+	if limit, ok := l.config.Methods[method]; ok {
+		return limit
+	}
+	return l.config.Requests
+}
+
+// allow counts a call of caller to a method and returns 0 when it is within
+// the limit of the method, else how long to wait before retrying
+func (l *rateLimiter) allow(ctx context.Context, fullMethod, caller string, now time.Time) time.Duration {
+	limit := l.limit(fullMethod)
+	if limit <= 0 || l.config.Window <= 0 {
+		return 0
+	}
+	// The memory store does not fail
+	wait, _ := l.store.Take(ctx, fullMethod+" "+caller, ratelimit.Limit{Requests: limit, Period: l.config.Window}, now)
+	return wait
+}
+
+// check rejects a call over the limit with ResourceExhausted
+func (l *rateLimiter) check(ctx context.Context, fullMethod string) error {
+	if l.allow(ctx, fullMethod, callerAddress(ctx), time.Now()) <= 0 {
+		return nil
+	}
+	return apierror.New(codes.ResourceExhausted, apierror.ReasonRateLimited, "rate limit exceeded, retry later",
+		map[string]string{"window": l.config.Window.String()})
+}
+
+// callerAddress returns the IP address of the caller, callers behind one
+// address sharing their limits
+func callerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// newRateLimitInterceptor limits the calls per method and caller
+func newRateLimitInterceptor(limiter *rateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := limiter.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// newStreamRateLimitInterceptor limits the streams opened per method and caller
+func newStreamRateLimitInterceptor(limiter *rateLimiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := limiter.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
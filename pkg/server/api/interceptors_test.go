@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestRecoveryInterceptor(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: pbv1.AgentService_Heartbeat_FullMethodName}
+	panicking := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("handler bug")
+	}
+	if _, err := newRecoveryInterceptor(zap.NewNop())(context.Background(), nil, info, panicking); status.Code(err) != codes.Internal {
+		t.Errorf("unary panic = %v, want Internal", err)
+	}
+
+	streamInfo := &grpc.StreamServerInfo{FullMethod: pbv1.AgentService_Heartbeat_FullMethodName}
+	panickingStream := func(srv interface{}, ss grpc.ServerStream) error {
+		panic("handler bug")
+	}
+	stream := &contextServerStream{ctx: context.Background()}
+	if err := newStreamRecoveryInterceptor(zap.NewNop())(nil, stream, streamInfo, panickingStream); status.Code(err) != codes.Internal {
+		t.Errorf("stream panic = %v, want Internal", err)
+	}
+}
+
+func TestCheckManagementToken(t *testing.T) {
+	const token = "management-token"
+	tests := []struct {
+		name       string
+		method     string
+		presented  string
+		wantCode   codes.Code
+		wantReason string
+	}{
+		{"valid token", pbv1.ManagementService_ListNodes_FullMethodName, token, codes.OK, ""},
+		{"missing token", pbv1.ManagementService_ListNodes_FullMethodName, "", codes.Unauthenticated, apierror.ReasonManagementTokenMissing},
+		{"wrong token", pbv1.ManagementService_ListNodes_FullMethodName, "guessed-token", codes.Unauthenticated, apierror.ReasonManagementTokenInvalid},
+		{"token prefix", pbv1.ManagementService_ListNodes_FullMethodName, token[:4], codes.Unauthenticated, apierror.ReasonManagementTokenInvalid},
+		// Agents authenticate with node tokens instead
+		{"agent call", pbv1.AgentService_Heartbeat_FullMethodName, "", codes.OK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkManagementToken(withBearerToken(tt.presented), token, tt.method, zap.NewNop())
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+			if reason := apierror.Reason(err); reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(configv1.GRPCRateLimitConfig{
+		Enabled:  true,
+		Window:   time.Minute,
+		Requests: 2,
+		Methods:  map[string]int{"EnrollNode": 1, pbv1.AgentService_ReportTraffic_FullMethodName: 0},
+	})
+	ctx := context.Background()
+	heartbeat := pbv1.AgentService_Heartbeat_FullMethodName
+	start := time.Unix(1700000000, 0)
+
+	allowed := func(method, caller string, at time.Duration) bool {
+		return limiter.allow(ctx, method, caller, start.Add(at)) <= 0
+	}
+
+	for i := 0; i < 2; i++ {
+		if !allowed(heartbeat, "192.0.2.1", 0) {
+			t.Fatalf("call %d within the limit refused", i+1)
+		}
+	}
+	if allowed(heartbeat, "192.0.2.1", time.Second) {
+		t.Error("call over the limit allowed")
+	}
+	if !allowed(heartbeat, "192.0.2.2", time.Second) {
+		t.Error("call of another caller refused")
+	}
+	if !allowed(pbv1.AgentService_ReportMetrics_FullMethodName, "192.0.2.1", time.Second) {
+		t.Error("call to another method refused")
+	}
+
+	// The whole allowance is back once the window rolled over
+	for i := 0; i < 2; i++ {
+		if !allowed(heartbeat, "192.0.2.1", time.Minute+time.Second) {
+			t.Fatalf("call %d of the next window refused", i+1)
+		}
+	}
+	if allowed(heartbeat, "192.0.2.1", time.Minute+time.Second) {
+		t.Error("call over the limit of the next window allowed")
+	}
+
+	// Limits of single methods, by name or full name
+	enroll := pbv1.AgentService_EnrollNode_FullMethodName
+	if !allowed(enroll, "192.0.2.1", 0) || allowed(enroll, "192.0.2.1", 0) {
+		t.Error("limit of EnrollNode by name not applied")
+	}
+	for i := 0; i < 5; i++ {
+		if !allowed(pbv1.AgentService_ReportTraffic_FullMethodName, "192.0.2.1", 0) {
+			t.Fatal("call to an unlimited method refused")
+		}
+	}
+}
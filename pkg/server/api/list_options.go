@@ -0,0 +1,60 @@
+package api
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"sing-box-web/pkg/apierror"
+)
+
+// parseSort checks the sort field and order of a listing against its sort
+// fields, reporting whether it sorts descending
+func parseSort(sortBy, sortOrder string, fields []string) (bool, error) {
+	if sortBy != "" && !slices.Contains(fields, sortBy) {
+		return false, apierror.InvalidField("sort_by", "sort_by must be one of "+strings.Join(fields, ", "))
+	}
+	switch sortOrder {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	}
+	return false, apierror.InvalidField("sort_order", "sort_order must be asc or desc")
+}
+
+// checkFieldMask checks that the paths of a field mask are top-level fields
+// of the listed message
+func checkFieldMask(mask *fieldmaskpb.FieldMask, item proto.Message) error {
+	fields := item.ProtoReflect().Descriptor().Fields()
+	for _, path := range mask.GetPaths() {
+		if fields.ByName(protoreflect.Name(path)) == nil {
+			return apierror.InvalidField("field_mask", fmt.Sprintf("%q is not a field of %s", path, item.ProtoReflect().Descriptor().Name()))
+		}
+	}
+	return nil
+}
+
+// applyFieldMask clears the fields of a listed message the mask leaves out,
+// keeping all of them with an empty mask
+func applyFieldMask(mask *fieldmaskpb.FieldMask, item proto.Message) {
+	paths := mask.GetPaths()
+	if len(paths) == 0 {
+		return
+	}
+	message := item.ProtoReflect()
+	var cleared []protoreflect.FieldDescriptor
+	message.Range(func(field protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if !slices.Contains(paths, string(field.Name())) {
+			cleared = append(cleared, field)
+		}
+		return true
+	})
+	for _, field := range cleared {
+		message.Clear(field)
+	}
+}
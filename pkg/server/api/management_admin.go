@@ -0,0 +1,428 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/format"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// adminPasswordMinLength is the shortest password an admin is created with
+const adminPasswordMinLength = 12
+
+// adminRoles are the roles listed as admins
+var adminRoles = []models.UserRole{models.UserRoleAdmin, models.UserRoleSuperAdmin}
+
+// Admin management methods
+
+func (s *ManagementService) CreateAdmin(ctx context.Context, req *pbv1.CreateAdminRequest) (*pbv1.CreateAdminResponse, error) {
+	s.logger.Debug("CreateAdmin called", zap.String("username", req.Username), zap.String("operator", req.Operator))
+
+	if req.Username == "" {
+		return nil, apierror.MissingField("username")
+	}
+	if req.Email == "" {
+		return nil, apierror.MissingField("email")
+	}
+	if req.Password == "" {
+		return nil, apierror.MissingField("password")
+	}
+	if len(req.Password) < adminPasswordMinLength {
+		return nil, apierror.InvalidField("password",
+			"password must be at least "+strconv.Itoa(adminPasswordMinLength)+" characters")
+	}
+
+	admin := &models.User{
+		Username:    req.Username,
+		Email:       req.Email,
+		DisplayName: req.Username,
+		Status:      models.UserStatusActive,
+		DeviceLimit: 1,
+	}
+	if err := setAdminAccess(admin, req.SuperAdmin, req.Permissions); err != nil {
+		return nil, err
+	}
+	if err := admin.Validate(); err != nil {
+		return nil, validationError(err, "")
+	}
+
+	repo := s.dbService.GetRepository().User
+	if _, err := repo.GetByUsername(req.Username); err == nil {
+		return nil, apierror.AlreadyExists(apierror.ResourceAdmin, apierror.ReasonUsernameTaken,
+			"username already exists", map[string]string{"username": req.Username})
+	}
+	if _, err := repo.GetByEmail(req.Email); err == nil {
+		return nil, apierror.AlreadyExists(apierror.ResourceAdmin, apierror.ReasonEmailTaken,
+			"email already exists", map[string]string{"email": req.Email})
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create admin")
+	}
+	admin.Password = string(hash)
+
+	if err := repo.Create(admin); err != nil {
+		s.logger.Error("Failed to create admin", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create admin")
+	}
+
+	s.logger.Info("Admin created",
+		zap.Uint("admin_id", admin.ID),
+		zap.String("username", admin.Username),
+		zap.String("role", string(admin.Role)),
+		zap.Any("permissions", admin.AdminPermissions),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.CreateAdminResponse{
+		Success: true,
+		Message: "admin created successfully",
+		Admin:   convertAdminToProto(admin),
+	}, nil
+}
+
+func (s *ManagementService) UpdateAdmin(ctx context.Context, req *pbv1.UpdateAdminRequest) (*pbv1.UpdateAdminResponse, error) {
+	s.logger.Debug("UpdateAdmin called", zap.String("admin_id", req.AdminId), zap.String("operator", req.Operator))
+
+	admin, err := s.getAdmin(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+
+	if admin.Role == models.UserRoleSuperAdmin && !req.SuperAdmin {
+		if err := s.checkOtherSuperAdmin(admin); err != nil {
+			return nil, err
+		}
+	}
+	if err := setAdminAccess(admin, req.SuperAdmin, req.Permissions); err != nil {
+		return nil, err
+	}
+	if err := admin.Validate(); err != nil {
+		return nil, validationError(err, "")
+	}
+
+	if err := s.dbService.GetRepository().User.Update(admin); err != nil {
+		s.logger.Error("Failed to update admin", zap.Error(err), zap.String("admin_id", req.AdminId))
+		return nil, status.Error(codes.Internal, "failed to update admin")
+	}
+
+	s.logger.Info("Admin updated",
+		zap.Uint("admin_id", admin.ID),
+		zap.String("role", string(admin.Role)),
+		zap.Any("permissions", admin.AdminPermissions),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.UpdateAdminResponse{
+		Success: true,
+		Message: "admin updated successfully",
+		Admin:   convertAdminToProto(admin),
+	}, nil
+}
+
+func (s *ManagementService) SetAdminStatus(ctx context.Context, req *pbv1.SetAdminStatusRequest) (*pbv1.SetAdminStatusResponse, error) {
+	s.logger.Debug("SetAdminStatus called",
+		zap.String("admin_id", req.AdminId),
+		zap.Bool("disabled", req.Disabled),
+		zap.String("operator", req.Operator),
+	)
+
+	admin, err := s.getAdmin(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+
+	newStatus := models.UserStatusActive
+	if req.Disabled {
+		newStatus = models.UserStatusDisabled
+		if req.Operator != "" && req.Operator == admin.Username {
+			return nil, apierror.FailedPrecondition(apierror.ReasonAdminSelfDisable, "admin/"+req.AdminId,
+				"admins cannot disable themselves")
+		}
+		if admin.Role == models.UserRoleSuperAdmin {
+			if err := s.checkOtherSuperAdmin(admin); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.dbService.GetRepository().User.UpdateStatus(admin.ID, newStatus); err != nil {
+		s.logger.Error("Failed to update admin status", zap.Error(err), zap.String("admin_id", req.AdminId))
+		return nil, status.Error(codes.Internal, "failed to update admin status")
+	}
+	admin.Status = newStatus
+
+	s.logger.Info("Admin status changed",
+		zap.Uint("admin_id", admin.ID),
+		zap.String("status", string(newStatus)),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.SetAdminStatusResponse{
+		Success: true,
+		Message: "admin status updated successfully",
+		Admin:   convertAdminToProto(admin),
+	}, nil
+}
+
+func (s *ManagementService) ListAdmins(ctx context.Context, req *pbv1.ListAdminsRequest) (*pbv1.ListAdminsResponse, error) {
+	s.logger.Debug("ListAdmins called", zap.Int32("page", req.Page), zap.Int32("page_size", req.PageSize))
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	offset := int((page - 1) * pageSize)
+
+	admins, total, err := s.dbService.GetRepository().User.ListFiltered(repository.UserListFilter{Roles: adminRoles}, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list admins", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list admins")
+	}
+
+	infos := make([]*pbv1.AdminInfo, len(admins))
+	for i, admin := range admins {
+		infos[i] = convertAdminToProto(admin)
+	}
+
+	return &pbv1.ListAdminsResponse{
+		Admins:   infos,
+		Total:    int32(total),
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *ManagementService) ListAdminAuditLogs(ctx context.Context, req *pbv1.ListAdminAuditLogsRequest) (*pbv1.ListAdminAuditLogsResponse, error) {
+	s.logger.Debug("ListAdminAuditLogs called", zap.String("admin_id", req.AdminId))
+
+	var filter repository.AdminAuditFilter
+	if req.AdminId != "" {
+		id, err := strconv.ParseUint(req.AdminId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("admin_id", "invalid admin_id format")
+		}
+		filter.AdminID = uint(id)
+	}
+	if req.StartTime != nil {
+		filter.Since = req.StartTime.AsTime()
+	}
+	if req.EndTime != nil {
+		filter.Until = req.EndTime.AsTime()
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+	if pageSize > 200 {
+		pageSize = 200
+	}
+	offset := int((page - 1) * pageSize)
+
+	entries, total, err := s.dbService.GetRepository().AdminAudit.List(filter, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list admin audit logs", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list admin audit logs")
+	}
+
+	infos := make([]*pbv1.AdminAuditLogInfo, len(entries))
+	for i, entry := range entries {
+		infos[i] = &pbv1.AdminAuditLogInfo{
+			Id:            strconv.FormatUint(uint64(entry.ID), 10),
+			AdminId:       strconv.FormatUint(uint64(entry.AdminID), 10),
+			AdminUsername: entry.AdminUsername,
+			Method:        entry.Method,
+			Route:         entry.Route,
+			Path:          entry.Path,
+			Status:        int32(entry.Status),
+			ClientIp:      entry.ClientIP,
+			CreatedAt:     timestamppb.New(entry.CreatedAt),
+			RequestId:     entry.RequestID,
+		}
+	}
+
+	return &pbv1.ListAdminAuditLogsResponse{
+		Entries:  infos,
+		Total:    int32(total),
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// getAdmin parses the admin ID and loads the admin. Users that are not
+// admins are reported as not found.
+func (s *ManagementService) GetAdminPreferences(ctx context.Context, req *pbv1.GetAdminPreferencesRequest) (*pbv1.GetAdminPreferencesResponse, error) {
+	s.logger.Debug("GetAdminPreferences called", zap.String("admin_id", req.AdminId))
+
+	admin, err := s.getAdmin(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.GetAdminPreferencesResponse{
+		Preferences: convertAdminPreferencesToProto(admin),
+	}, nil
+}
+
+func (s *ManagementService) UpdateAdminPreferences(ctx context.Context, req *pbv1.UpdateAdminPreferencesRequest) (*pbv1.UpdateAdminPreferencesResponse, error) {
+	s.logger.Debug("UpdateAdminPreferences called",
+		zap.String("admin_id", req.AdminId),
+		zap.String("locale", req.Locale),
+		zap.String("time_zone", req.TimeZone),
+	)
+
+	var violations []apierror.FieldViolation
+	locale := format.Locale(req.Locale)
+	if req.Locale != "" {
+		var ok bool
+		if locale, ok = format.ParseLocale(req.Locale); !ok {
+			violations = append(violations, apierror.FieldViolation{Field: "locale", Description: "locale must be one of en, zh-CN"})
+		}
+	}
+	if _, err := format.LoadLocation(req.TimeZone); err != nil {
+		violations = append(violations, apierror.FieldViolation{Field: "time_zone", Description: "time_zone must be an IANA time zone such as Asia/Shanghai"})
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidFields(violations)
+	}
+
+	admin, err := s.getAdmin(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	admin.Locale, admin.TimeZone = string(locale), req.TimeZone
+	if err := s.dbService.GetRepository().User.Update(admin); err != nil {
+		s.logger.Error("Failed to update admin preferences", zap.Error(err), zap.String("admin_id", req.AdminId))
+		return nil, status.Error(codes.Internal, "failed to update admin preferences")
+	}
+
+	return &pbv1.UpdateAdminPreferencesResponse{
+		Success:     true,
+		Message:     "preferences updated successfully",
+		Preferences: convertAdminPreferencesToProto(admin),
+	}, nil
+}
+
+func (s *ManagementService) getAdmin(adminID string) (*models.User, error) {
+	if adminID == "" {
+		return nil, apierror.MissingField("admin_id")
+	}
+	id, err := strconv.ParseUint(adminID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("admin_id", "invalid admin_id format")
+	}
+
+	admin, err := s.dbService.GetRepository().User.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceAdmin, adminID)
+		}
+		s.logger.Error("Failed to get admin", zap.Error(err), zap.String("admin_id", adminID))
+		return nil, status.Error(codes.Internal, "failed to get admin")
+	}
+	if !admin.Role.IsAdmin() {
+		return nil, apierror.NotFound(apierror.ResourceAdmin, adminID)
+	}
+	return admin, nil
+}
+
+// checkOtherSuperAdmin fails unless an active super admin other than admin
+// remains, so that admins can always be managed
+func (s *ManagementService) checkOtherSuperAdmin(admin *models.User) error {
+	count, err := s.dbService.GetRepository().User.CountActiveByRole(models.UserRoleSuperAdmin)
+	if err != nil {
+		s.logger.Error("Failed to count super admins", zap.Error(err))
+		return status.Error(codes.Internal, "failed to count super admins")
+	}
+	if admin.Status == models.UserStatusActive {
+		count--
+	}
+	if count < 1 {
+		return apierror.FailedPrecondition(apierror.ReasonLastSuperAdmin,
+			"admin/"+strconv.FormatUint(uint64(admin.ID), 10), "the last active super admin cannot be demoted or disabled")
+	}
+	return nil
+}
+
+// setAdminAccess sets the role and permissions of an admin. Super admins
+// hold every permission, so none are stored for them.
+func setAdminAccess(admin *models.User, superAdmin bool, permissions []string) error {
+	if superAdmin {
+		admin.Role = models.UserRoleSuperAdmin
+		admin.AdminPermissions = nil
+		return nil
+	}
+	if len(permissions) == 0 {
+		return apierror.MissingField("permissions")
+	}
+
+	admin.Role = models.UserRoleAdmin
+	admin.AdminPermissions = make([]models.AdminPermission, 0, len(permissions))
+	seen := make(map[models.AdminPermission]bool, len(permissions))
+	for _, name := range permissions {
+		permission := models.AdminPermission(name)
+		if !seen[permission] {
+			seen[permission] = true
+			admin.AdminPermissions = append(admin.AdminPermissions, permission)
+		}
+	}
+	return nil
+}
+
+// convertAdminToProto converts an admin to protobuf
+func convertAdminToProto(admin *models.User) *pbv1.AdminInfo {
+	info := &pbv1.AdminInfo{
+		Id:               strconv.FormatUint(uint64(admin.ID), 10),
+		Username:         admin.Username,
+		Email:            admin.Email,
+		Role:             string(admin.Role),
+		Status:           string(admin.Status),
+		TwoFactorEnabled: admin.TwoFactorEnabled,
+		CreatedAt:        timestamppb.New(admin.CreatedAt),
+		Locale:           admin.Locale,
+		TimeZone:         admin.TimeZone,
+	}
+	for _, permission := range admin.AdminPermissions {
+		info.Permissions = append(info.Permissions, string(permission))
+	}
+	if admin.LastLoginAt != nil {
+		info.LastLoginAt = timestamppb.New(*admin.LastLoginAt)
+	}
+	return info
+}
+
+func convertAdminPreferencesToProto(admin *models.User) *pbv1.AdminPreferences {
+	preferences := &pbv1.AdminPreferences{
+		Locale:   admin.Locale,
+		TimeZone: admin.TimeZone,
+	}
+	for _, locale := range format.Locales {
+		preferences.SupportedLocales = append(preferences.SupportedLocales, string(locale))
+	}
+	return preferences
+}
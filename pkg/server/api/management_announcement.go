@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Announcement methods
+
+func (s *ManagementService) CreateAnnouncement(ctx context.Context, req *pbv1.CreateAnnouncementRequest) (*pbv1.CreateAnnouncementResponse, error) {
+	if req.Announcement == nil {
+		return nil, apierror.MissingField("announcement")
+	}
+	s.logger.Debug("CreateAnnouncement called", zap.String("title", req.Announcement.Title))
+
+	announcement := &models.Announcement{CreatedBy: req.Operator}
+	if err := s.applyAnnouncementSpec(announcement, req.Announcement); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Announcement.Create(announcement); err != nil {
+		s.logger.Error("Failed to create announcement", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create announcement")
+	}
+
+	s.logger.Info("Announcement created",
+		zap.Uint("announcement_id", announcement.ID),
+		zap.String("title", announcement.Title),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.CreateAnnouncementResponse{
+		Success:      true,
+		Message:      "announcement created successfully",
+		Announcement: convertAnnouncementToProto(announcement, time.Now()),
+	}, nil
+}
+
+func (s *ManagementService) UpdateAnnouncement(ctx context.Context, req *pbv1.UpdateAnnouncementRequest) (*pbv1.UpdateAnnouncementResponse, error) {
+	s.logger.Debug("UpdateAnnouncement called", zap.String("announcement_id", req.AnnouncementId))
+
+	announcement, err := s.getAnnouncement(req.AnnouncementId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Announcement == nil {
+		return nil, apierror.MissingField("announcement")
+	}
+	if err := s.applyAnnouncementSpec(announcement, req.Announcement); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Announcement.Update(announcement); err != nil {
+		s.logger.Error("Failed to update announcement", zap.Error(err), zap.String("announcement_id", req.AnnouncementId))
+		return nil, status.Error(codes.Internal, "failed to update announcement")
+	}
+
+	s.logger.Info("Announcement updated", zap.Uint("announcement_id", announcement.ID))
+
+	infos, err := s.announcementsWithReadCounts([]*models.Announcement{announcement})
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.UpdateAnnouncementResponse{
+		Success:      true,
+		Message:      "announcement updated successfully",
+		Announcement: infos[0],
+	}, nil
+}
+
+func (s *ManagementService) DeleteAnnouncement(ctx context.Context, req *pbv1.DeleteAnnouncementRequest) (*pbv1.DeleteAnnouncementResponse, error) {
+	s.logger.Debug("DeleteAnnouncement called", zap.String("announcement_id", req.AnnouncementId))
+
+	announcement, err := s.getAnnouncement(req.AnnouncementId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Announcement.Delete(announcement.ID); err != nil {
+		s.logger.Error("Failed to delete announcement", zap.Error(err), zap.String("announcement_id", req.AnnouncementId))
+		return nil, status.Error(codes.Internal, "failed to delete announcement")
+	}
+
+	s.logger.Info("Announcement deleted", zap.Uint("announcement_id", announcement.ID))
+
+	return &pbv1.DeleteAnnouncementResponse{
+		Success: true,
+		Message: "announcement deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) GetAnnouncement(ctx context.Context, req *pbv1.GetAnnouncementRequest) (*pbv1.GetAnnouncementResponse, error) {
+	s.logger.Debug("GetAnnouncement called", zap.String("announcement_id", req.AnnouncementId))
+
+	announcement, err := s.getAnnouncement(req.AnnouncementId)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := s.announcementsWithReadCounts([]*models.Announcement{announcement})
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.GetAnnouncementResponse{Announcement: infos[0]}, nil
+}
+
+func (s *ManagementService) ListAnnouncements(ctx context.Context, req *pbv1.ListAnnouncementsRequest) (*pbv1.ListAnnouncementsResponse, error) {
+	s.logger.Debug("ListAnnouncements called", zap.Int32("page", req.Page), zap.Int32("page_size", req.PageSize))
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	announcements, total, err := s.dbService.GetRepository().Announcement.List(int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list announcements", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list announcements")
+	}
+	infos, err := s.announcementsWithReadCounts(announcements)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.ListAnnouncementsResponse{
+		Announcements: infos,
+		Total:         int32(total),
+		Page:          page,
+		PageSize:      pageSize,
+	}, nil
+}
+
+func (s *ManagementService) ListUserAnnouncements(ctx context.Context, req *pbv1.ListUserAnnouncementsRequest) (*pbv1.ListUserAnnouncementsResponse, error) {
+	s.logger.Debug("ListUserAnnouncements called", zap.String("user_id", req.UserId), zap.Bool("include_read", req.IncludeRead))
+
+	user, announcements, err := s.userAnnouncements(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(announcements))
+	for i, announcement := range announcements {
+		ids[i] = announcement.ID
+	}
+	read, err := s.dbService.GetRepository().Announcement.ReadIDs(user.ID, ids)
+	if err != nil {
+		s.logger.Error("Failed to get read announcements", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to list announcements")
+	}
+
+	now := time.Now()
+	resp := &pbv1.ListUserAnnouncementsResponse{Announcements: []*pbv1.AnnouncementInfo{}}
+	for _, announcement := range announcements {
+		if !read[announcement.ID] {
+			resp.UnreadCount++
+		} else if !req.IncludeRead {
+			continue
+		}
+		info := convertAnnouncementToProto(announcement, now)
+		info.Read = read[announcement.ID]
+		resp.Announcements = append(resp.Announcements, info)
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) MarkAnnouncementsRead(ctx context.Context, req *pbv1.MarkAnnouncementsReadRequest) (*pbv1.MarkAnnouncementsReadResponse, error) {
+	s.logger.Debug("MarkAnnouncementsRead called",
+		zap.String("user_id", req.UserId),
+		zap.Strings("announcement_ids", req.AnnouncementIds),
+	)
+
+	user, announcements, err := s.userAnnouncements(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(map[uint]bool, len(announcements))
+	ids := make([]uint, 0, len(announcements))
+	for _, announcement := range announcements {
+		visible[announcement.ID] = true
+		ids = append(ids, announcement.ID)
+	}
+	if len(req.AnnouncementIds) > 0 {
+		ids = ids[:0]
+		for _, announcementID := range req.AnnouncementIds {
+			id, err := strconv.ParseUint(announcementID, 10, 32)
+			if err != nil {
+				return nil, apierror.InvalidField("announcement_ids", "invalid announcement_id format")
+			}
+			if !visible[uint(id)] {
+				return nil, apierror.NotFound(apierror.ResourceAnnouncement, announcementID)
+			}
+			ids = append(ids, uint(id))
+		}
+	}
+
+	marked, err := s.dbService.GetRepository().Announcement.MarkRead(user.ID, ids)
+	if err != nil {
+		s.logger.Error("Failed to mark announcements read", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to mark announcements read")
+	}
+
+	return &pbv1.MarkAnnouncementsReadResponse{
+		Success: true,
+		Message: "announcements marked as read",
+		Marked:  int32(marked),
+	}, nil
+}
+
+// userAnnouncements loads a user and the published announcements of their audience
+func (s *ManagementService) userAnnouncements(userID string) (*models.User, []*models.Announcement, error) {
+	if userID == "" {
+		return nil, nil, apierror.MissingField("user_id")
+	}
+	id, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return nil, nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	repo := s.dbService.GetRepository()
+	user, err := repo.User.GetByID(uint(id))
+	if err != nil {
+		return nil, nil, apierror.NotFound(apierror.ResourceUser, userID)
+	}
+
+	published, err := repo.Announcement.ListPublished(time.Now())
+	if err != nil {
+		s.logger.Error("Failed to list published announcements", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "failed to list announcements")
+	}
+	announcements := make([]*models.Announcement, 0, len(published))
+	for _, announcement := range published {
+		if announcement.IsVisibleTo(user.PlanID) {
+			announcements = append(announcements, announcement)
+		}
+	}
+	return user, announcements, nil
+}
+
+// announcementsWithReadCounts converts announcements for admins, with their readers counted
+func (s *ManagementService) announcementsWithReadCounts(announcements []*models.Announcement) ([]*pbv1.AnnouncementInfo, error) {
+	ids := make([]uint, len(announcements))
+	for i, announcement := range announcements {
+		ids[i] = announcement.ID
+	}
+	counts, err := s.dbService.GetRepository().Announcement.CountReads(ids)
+	if err != nil {
+		s.logger.Error("Failed to count announcement reads", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to count announcement reads")
+	}
+
+	now := time.Now()
+	infos := make([]*pbv1.AnnouncementInfo, len(announcements))
+	for i, announcement := range announcements {
+		infos[i] = convertAnnouncementToProto(announcement, now)
+		infos[i].ReadCount = counts[announcement.ID]
+	}
+	return infos, nil
+}
+
+// getAnnouncement parses the announcement ID and loads the announcement
+func (s *ManagementService) getAnnouncement(announcementID string) (*models.Announcement, error) {
+	if announcementID == "" {
+		return nil, apierror.MissingField("announcement_id")
+	}
+	id, err := strconv.ParseUint(announcementID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("announcement_id", "invalid announcement_id format")
+	}
+
+	announcement, err := s.dbService.GetRepository().Announcement.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceAnnouncement, announcementID)
+		}
+		s.logger.Error("Failed to get announcement", zap.Error(err), zap.String("announcement_id", announcementID))
+		return nil, status.Error(codes.Internal, "failed to get announcement")
+	}
+	return announcement, nil
+}
+
+// applyAnnouncementSpec validates an announcement spec and copies it onto the announcement
+func (s *ManagementService) applyAnnouncementSpec(announcement *models.Announcement, spec *pbv1.AnnouncementSpec) error {
+	planIDs := make([]uint, 0, len(spec.PlanIds))
+	for _, planID := range spec.PlanIds {
+		plan, err := s.getPlan(planID)
+		if err != nil {
+			return err
+		}
+		planIDs = append(planIDs, plan.ID)
+	}
+
+	announcement.Title = spec.Title
+	announcement.Content = spec.Content
+	announcement.Audience = models.AnnouncementAudience(spec.Audience)
+	if announcement.Audience == "" {
+		announcement.Audience = models.AnnouncementAudienceAll
+	}
+	announcement.PlanIDs = planIDs
+	announcement.IsPinned = spec.IsPinned
+	announcement.StartsAt = nil
+	if spec.StartsAt != nil {
+		startsAt := spec.StartsAt.AsTime()
+		announcement.StartsAt = &startsAt
+	}
+	announcement.EndsAt = nil
+	if spec.EndsAt != nil {
+		endsAt := spec.EndsAt.AsTime()
+		announcement.EndsAt = &endsAt
+	}
+
+	return validationError(announcement.Validate(), "announcement.")
+}
+
+// convertAnnouncementToProto converts an announcement to protobuf
+func convertAnnouncementToProto(announcement *models.Announcement, now time.Time) *pbv1.AnnouncementInfo {
+	spec := &pbv1.AnnouncementSpec{
+		Title:    announcement.Title,
+		Content:  announcement.Content,
+		Audience: string(announcement.Audience),
+		PlanIds:  make([]string, len(announcement.PlanIDs)),
+		IsPinned: announcement.IsPinned,
+	}
+	for i, planID := range announcement.PlanIDs {
+		spec.PlanIds[i] = strconv.FormatUint(uint64(planID), 10)
+	}
+	if announcement.StartsAt != nil {
+		spec.StartsAt = timestamppb.New(*announcement.StartsAt)
+	}
+	if announcement.EndsAt != nil {
+		spec.EndsAt = timestamppb.New(*announcement.EndsAt)
+	}
+
+	return &pbv1.AnnouncementInfo{
+		Id:        strconv.FormatUint(uint64(announcement.ID), 10),
+		Spec:      spec,
+		CreatedBy: announcement.CreatedBy,
+		Published: announcement.IsPublished(now),
+		CreatedAt: timestamppb.New(announcement.CreatedAt),
+		UpdatedAt: timestamppb.New(announcement.UpdatedAt),
+	}
+}
@@ -0,0 +1,288 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// defaultAPIKeyUsageDays is the usage period returned by default
+	defaultAPIKeyUsageDays = 30
+	// maxAPIKeyUsageDays bounds the usage period of a request
+	maxAPIKeyUsageDays = 90
+)
+
+// API key methods
+
+func (s *ManagementService) CreateAPIKey(ctx context.Context, req *pbv1.CreateAPIKeyRequest) (*pbv1.CreateAPIKeyResponse, error) {
+	s.logger.Debug("CreateAPIKey called", zap.String("user_id", req.UserId))
+
+	userID, err := parseAPIKeyUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, apierror.MissingField("name")
+	}
+	if len(name) > 100 {
+		return nil, apierror.InvalidField("name", "name cannot be longer than 100 characters")
+	}
+
+	repo := s.dbService.GetRepository()
+	if _, err := repo.User.GetByID(userID); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+	count, err := repo.APIKey.CountActiveByUser(userID)
+	if err != nil {
+		s.logger.Error("Failed to count API keys", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to create API key")
+	}
+	if count >= models.MaxAPIKeysPerUser {
+		return nil, apierror.FailedPrecondition(apierror.ReasonAPIKeyLimit, "user/"+req.UserId,
+			fmt.Sprintf("a user can have at most %d active API keys", models.MaxAPIKeysPerUser))
+	}
+
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		s.logger.Error("Failed to generate API key", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to generate API key")
+	}
+
+	apiKey := &models.APIKey{
+		UserID:  userID,
+		Name:    name,
+		Hint:    auth.APIKeyHint(key),
+		KeyHash: auth.HashAPIKey(key),
+	}
+	if err := repo.APIKey.Create(apiKey); err != nil {
+		s.logger.Error("Failed to create API key", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to create API key")
+	}
+
+	s.logger.Info("API key created", zap.String("user_id", req.UserId), zap.Uint("key_id", apiKey.ID))
+
+	return &pbv1.CreateAPIKeyResponse{
+		Key:  key,
+		Info: convertAPIKeyToProto(apiKey),
+	}, nil
+}
+
+func (s *ManagementService) ListAPIKeys(ctx context.Context, req *pbv1.ListAPIKeysRequest) (*pbv1.ListAPIKeysResponse, error) {
+	s.logger.Debug("ListAPIKeys called", zap.String("user_id", req.UserId))
+
+	userID, err := parseAPIKeyUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.dbService.GetRepository().APIKey.ListByUser(userID)
+	if err != nil {
+		s.logger.Error("Failed to list API keys", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to list API keys")
+	}
+
+	infos := make([]*pbv1.APIKeyInfo, len(keys))
+	for i, key := range keys {
+		infos[i] = convertAPIKeyToProto(key)
+	}
+
+	return &pbv1.ListAPIKeysResponse{
+		Keys: infos,
+	}, nil
+}
+
+func (s *ManagementService) RevokeAPIKey(ctx context.Context, req *pbv1.RevokeAPIKeyRequest) (*pbv1.RevokeAPIKeyResponse, error) {
+	s.logger.Debug("RevokeAPIKey called", zap.String("key_id", req.KeyId))
+
+	key, err := s.getAPIKey(req.KeyId, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().APIKey.Revoke(key.ID); err != nil {
+		s.logger.Error("Failed to revoke API key", zap.Error(err), zap.String("key_id", req.KeyId))
+		return nil, status.Error(codes.Internal, "failed to revoke API key")
+	}
+
+	s.logger.Info("API key revoked", zap.String("key_id", req.KeyId), zap.Uint("user_id", key.UserID))
+
+	return &pbv1.RevokeAPIKeyResponse{
+		Success: true,
+		Message: "API key revoked",
+	}, nil
+}
+
+func (s *ManagementService) UpdateAPIKeyLimits(ctx context.Context, req *pbv1.UpdateAPIKeyLimitsRequest) (*pbv1.UpdateAPIKeyLimitsResponse, error) {
+	s.logger.Debug("UpdateAPIKeyLimits called", zap.String("key_id", req.KeyId))
+
+	var violations []apierror.FieldViolation
+	if req.DailyQuota < 0 {
+		violations = append(violations, apierror.FieldViolation{Field: "daily_quota", Description: "daily_quota cannot be negative"})
+	}
+	if req.RateLimit < 0 {
+		violations = append(violations, apierror.FieldViolation{Field: "rate_limit", Description: "rate_limit cannot be negative"})
+	}
+	if req.Burst < 0 {
+		violations = append(violations, apierror.FieldViolation{Field: "burst", Description: "burst cannot be negative"})
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidFields(violations)
+	}
+
+	key, err := s.getAPIKey(req.KeyId, "")
+	if err != nil {
+		return nil, err
+	}
+	if !key.IsActive() {
+		return nil, apierror.FailedPrecondition(apierror.ReasonAPIKeyRevoked, "api_key/"+req.KeyId, "API key has been revoked")
+	}
+
+	repo := s.dbService.GetRepository().APIKey
+	if err := repo.UpdateLimits(key.ID, req.DailyQuota, req.RateLimit, int(req.Burst)); err != nil {
+		s.logger.Error("Failed to update API key limits", zap.Error(err), zap.String("key_id", req.KeyId))
+		return nil, status.Error(codes.Internal, "failed to update API key limits")
+	}
+	key.DailyQuota, key.RateLimit, key.Burst = req.DailyQuota, req.RateLimit, int(req.Burst)
+
+	s.logger.Info("API key limits updated",
+		zap.String("key_id", req.KeyId),
+		zap.Int64("daily_quota", key.DailyQuota),
+		zap.Float64("rate_limit", key.RateLimit),
+		zap.Int("burst", key.Burst),
+	)
+
+	return &pbv1.UpdateAPIKeyLimitsResponse{
+		Success: true,
+		Message: "API key limits updated",
+		Info:    convertAPIKeyToProto(key),
+	}, nil
+}
+
+func (s *ManagementService) GetAPIKeyUsage(ctx context.Context, req *pbv1.GetAPIKeyUsageRequest) (*pbv1.GetAPIKeyUsageResponse, error) {
+	s.logger.Debug("GetAPIKeyUsage called", zap.String("key_id", req.KeyId), zap.Int32("days", req.Days))
+
+	days := int(req.Days)
+	switch {
+	case days < 0 || days > maxAPIKeyUsageDays:
+		return nil, apierror.InvalidField("days", fmt.Sprintf("days must be between 1 and %d", maxAPIKeyUsageDays))
+	case days == 0:
+		days = defaultAPIKeyUsageDays
+	}
+
+	key, err := s.getAPIKey(req.KeyId, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage is metered per UTC day
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, 1-days)
+	usage, err := s.dbService.GetRepository().APIKey.GetUsage(key.ID, from, today.AddDate(0, 0, 1))
+	if err != nil {
+		s.logger.Error("Failed to get API key usage", zap.Error(err), zap.String("key_id", req.KeyId))
+		return nil, status.Error(codes.Internal, "failed to get API key usage")
+	}
+
+	var total models.APIKeyUsage
+	pbDays := make([]*pbv1.APIKeyUsageDay, len(usage))
+	for i, day := range usage {
+		pbDays[i] = convertAPIKeyUsageToProto(day)
+		total.Add(*day)
+	}
+
+	return &pbv1.GetAPIKeyUsageResponse{
+		Info:  convertAPIKeyToProto(key),
+		Days:  pbDays,
+		Total: convertAPIKeyUsageToProto(&total),
+	}, nil
+}
+
+// getAPIKey loads an API key, which must belong to the user when one is given
+func (s *ManagementService) getAPIKey(keyID, userID string) (*models.APIKey, error) {
+	if keyID == "" {
+		return nil, apierror.MissingField("key_id")
+	}
+	id, err := strconv.ParseUint(keyID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("key_id", "invalid key_id format")
+	}
+
+	key, err := s.dbService.GetRepository().APIKey.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apierror.NotFound(apierror.ResourceAPIKey, keyID)
+	}
+	if err != nil {
+		s.logger.Error("Failed to get API key", zap.Error(err), zap.String("key_id", keyID))
+		return nil, status.Error(codes.Internal, "failed to get API key")
+	}
+
+	// Keys of other users are reported missing rather than forbidden
+	if userID != "" && strconv.FormatUint(uint64(key.UserID), 10) != userID {
+		return nil, apierror.NotFound(apierror.ResourceAPIKey, keyID)
+	}
+	return key, nil
+}
+
+// parseAPIKeyUserID parses the required ID of the owner of API keys
+func parseAPIKeyUserID(id string) (uint, error) {
+	if id == "" {
+		return 0, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	return uint(userID), nil
+}
+
+func convertAPIKeyToProto(key *models.APIKey) *pbv1.APIKeyInfo {
+	info := &pbv1.APIKeyInfo{
+		KeyId:      strconv.FormatUint(uint64(key.ID), 10),
+		UserId:     strconv.FormatUint(uint64(key.UserID), 10),
+		Name:       key.Name,
+		Hint:       key.Hint,
+		Active:     key.IsActive(),
+		DailyQuota: key.DailyQuota,
+		RateLimit:  key.RateLimit,
+		Burst:      int32(key.Burst),
+		CreatedAt:  timestamppb.New(key.CreatedAt),
+	}
+	if key.LastUsedAt != nil {
+		info.LastUsedAt = timestamppb.New(*key.LastUsedAt)
+	}
+	if key.RevokedAt != nil {
+		info.RevokedAt = timestamppb.New(*key.RevokedAt)
+	}
+	return info
+}
+
+func convertAPIKeyUsageToProto(usage *models.APIKeyUsage) *pbv1.APIKeyUsageDay {
+	day := &pbv1.APIKeyUsageDay{
+		Requests:  usage.Requests,
+		Errors:    usage.Errors,
+		Rejected:  usage.Rejected,
+		BytesIn:   usage.BytesIn,
+		BytesOut:  usage.BytesOut,
+		ErrorRate: usage.ErrorRate(),
+	}
+	if !usage.Date.IsZero() {
+		day.Date = usage.Date.Format("2006-01-02")
+	}
+	return day
+}
@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Automation rule methods
+
+func (s *ManagementService) ListAutomationRules(ctx context.Context, req *pbv1.ListAutomationRulesRequest) (*pbv1.ListAutomationRulesResponse, error) {
+	s.logger.Debug("ListAutomationRules called", zap.Int32("page", req.Page))
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	rules, total, err := s.dbService.GetRepository().Automation.List(offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list automation rules", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list automation rules")
+	}
+
+	resp := &pbv1.ListAutomationRulesResponse{
+		Rules: make([]*pbv1.AutomationRuleInfo, len(rules)),
+		Total: int32(total),
+	}
+	for i, rule := range rules {
+		resp.Rules[i] = convertAutomationRuleToProto(rule)
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) CreateAutomationRule(ctx context.Context, req *pbv1.CreateAutomationRuleRequest) (*pbv1.CreateAutomationRuleResponse, error) {
+	if req.Rule == nil {
+		return nil, apierror.MissingField("rule")
+	}
+	s.logger.Debug("CreateAutomationRule called", zap.String("name", req.Rule.Name), zap.String("event_type", req.Rule.EventType))
+
+	rule := &models.AutomationRule{Operator: req.Operator}
+	if err := s.applyAutomationRuleSpec(rule, req.Rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Automation.Create(rule); err != nil {
+		s.logger.Error("Failed to create automation rule", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create automation rule")
+	}
+
+	s.logger.Info("Automation rule created",
+		zap.Uint("rule_id", rule.ID),
+		zap.String("name", rule.Name),
+		zap.String("event_type", rule.EventType),
+		zap.Bool("enabled", rule.Enabled),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.CreateAutomationRuleResponse{
+		Success: true,
+		Message: "automation rule created successfully",
+		Rule:    convertAutomationRuleToProto(rule),
+	}, nil
+}
+
+func (s *ManagementService) UpdateAutomationRule(ctx context.Context, req *pbv1.UpdateAutomationRuleRequest) (*pbv1.UpdateAutomationRuleResponse, error) {
+	s.logger.Debug("UpdateAutomationRule called", zap.String("rule_id", req.RuleId))
+
+	rule, err := s.getAutomationRule(req.RuleId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Rule == nil {
+		return nil, apierror.MissingField("rule")
+	}
+	if err := s.applyAutomationRuleSpec(rule, req.Rule); err != nil {
+		return nil, err
+	}
+	rule.Operator = req.Operator
+
+	if err := s.dbService.GetRepository().Automation.Update(rule); err != nil {
+		s.logger.Error("Failed to update automation rule", zap.Error(err), zap.String("rule_id", req.RuleId))
+		return nil, status.Error(codes.Internal, "failed to update automation rule")
+	}
+
+	s.logger.Info("Automation rule updated",
+		zap.Uint("rule_id", rule.ID),
+		zap.Bool("enabled", rule.Enabled),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.UpdateAutomationRuleResponse{
+		Success: true,
+		Message: "automation rule updated successfully",
+		Rule:    convertAutomationRuleToProto(rule),
+	}, nil
+}
+
+func (s *ManagementService) DeleteAutomationRule(ctx context.Context, req *pbv1.DeleteAutomationRuleRequest) (*pbv1.DeleteAutomationRuleResponse, error) {
+	s.logger.Debug("DeleteAutomationRule called", zap.String("rule_id", req.RuleId))
+
+	rule, err := s.getAutomationRule(req.RuleId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Automation.Delete(rule.ID); err != nil {
+		s.logger.Error("Failed to delete automation rule", zap.Error(err), zap.String("rule_id", req.RuleId))
+		return nil, status.Error(codes.Internal, "failed to delete automation rule")
+	}
+
+	s.logger.Info("Automation rule deleted", zap.Uint("rule_id", rule.ID), zap.String("name", rule.Name))
+
+	return &pbv1.DeleteAutomationRuleResponse{
+		Success: true,
+		Message: "automation rule deleted successfully",
+	}, nil
+}
+
+// TestAutomationRule evaluates a saved or unsaved rule on a sample event,
+// describing the actions a match would run without running them
+func (s *ManagementService) TestAutomationRule(ctx context.Context, req *pbv1.TestAutomationRuleRequest) (*pbv1.TestAutomationRuleResponse, error) {
+	s.logger.Debug("TestAutomationRule called", zap.String("rule_id", req.RuleId))
+
+	var rule *models.AutomationRule
+	switch {
+	case req.RuleId != "":
+		var err error
+		if rule, err = s.getAutomationRule(req.RuleId); err != nil {
+			return nil, err
+		}
+	case req.Rule != nil:
+		rule = &models.AutomationRule{}
+		if err := s.applyAutomationRuleSpec(rule, req.Rule); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, apierror.MissingField("rule_id")
+	}
+
+	event := &pbv1.Event{}
+	if req.Event != nil {
+		event = req.Event
+	}
+	if event.Type == "" {
+		event.Type = rule.EventType
+	}
+	if event.Type != rule.EventType {
+		return nil, apierror.InvalidField("event.type", "event type must be the rule's, "+rule.EventType)
+	}
+	if event.Topic == "" {
+		event.Topic = events.TopicOf(event.Type)
+	}
+
+	fields, err := events.Fields(event)
+	if err != nil {
+		return nil, apierror.InvalidField("event", err.Error())
+	}
+
+	resp := &pbv1.TestAutomationRuleResponse{
+		Matched: true,
+		Fields:  fields,
+		Subject: automationEventSubject(event.Type, fields),
+	}
+	for _, condition := range rule.Conditions {
+		met := condition.Matches(fields)
+		resp.Matched = resp.Matched && met
+		resp.Conditions = append(resp.Conditions, &pbv1.AutomationConditionResult{
+			Condition: convertAutomationConditionToProto(condition),
+			Met:       met,
+			Actual:    fields[condition.Field],
+		})
+	}
+	if resp.Matched {
+		for _, action := range rule.Actions {
+			detail, _, err := describeAutomationAction(action, fields)
+			result := &pbv1.AutomationActionResult{Type: string(action.Type), Detail: detail}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			resp.Actions = append(resp.Actions, result)
+		}
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) ListAutomationExecutions(ctx context.Context, req *pbv1.ListAutomationExecutionsRequest) (*pbv1.ListAutomationExecutionsResponse, error) {
+	s.logger.Debug("ListAutomationExecutions called", zap.String("rule_id", req.RuleId))
+
+	var ruleID uint
+	if req.RuleId != "" {
+		rule, err := s.getAutomationRule(req.RuleId)
+		if err != nil {
+			return nil, err
+		}
+		ruleID = rule.ID
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	executions, total, err := s.dbService.GetRepository().Automation.ListExecutions(ruleID, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list automation executions", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list automation executions")
+	}
+
+	resp := &pbv1.ListAutomationExecutionsResponse{
+		Executions: make([]*pbv1.AutomationExecutionInfo, len(executions)),
+		Total:      int32(total),
+	}
+	for i, execution := range executions {
+		resp.Executions[i] = convertAutomationExecutionToProto(execution)
+	}
+	return resp, nil
+}
+
+// getAutomationRule loads an automation rule by its ID
+func (s *ManagementService) getAutomationRule(ruleID string) (*models.AutomationRule, error) {
+	if ruleID == "" {
+		return nil, apierror.MissingField("rule_id")
+	}
+	id, err := strconv.ParseUint(ruleID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("rule_id", "invalid rule_id format")
+	}
+
+	rule, err := s.dbService.GetRepository().Automation.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apierror.NotFound(apierror.ResourceAutomationRule, ruleID)
+	}
+	if err != nil {
+		s.logger.Error("Failed to get automation rule", zap.Error(err), zap.String("rule_id", ruleID))
+		return nil, status.Error(codes.Internal, "failed to get automation rule")
+	}
+	return rule, nil
+}
+
+// applyAutomationRuleSpec validates an automation rule spec and copies it
+// onto the rule
+func (s *ManagementService) applyAutomationRuleSpec(rule *models.AutomationRule, spec *pbv1.AutomationRuleSpec) error {
+	rule.Name = strings.TrimSpace(spec.Name)
+	rule.Description = strings.TrimSpace(spec.Description)
+	rule.EventType = spec.EventType
+	rule.Enabled = spec.Enabled
+	rule.Conditions = make([]models.AutomationCondition, len(spec.Conditions))
+	for i, condition := range spec.Conditions {
+		rule.Conditions[i] = models.AutomationCondition{
+			Field:    condition.Field,
+			Operator: models.AutomationOperator(condition.Op),
+			Value:    condition.Value,
+		}
+	}
+	rule.Actions = make([]models.AutomationAction, len(spec.Actions))
+	for i, action := range spec.Actions {
+		rule.Actions[i] = models.AutomationAction{Type: models.AutomationActionType(action.Type), Params: action.Params}
+	}
+
+	if err := validationError(rule.Validate(), "rule."); err != nil {
+		return err
+	}
+	if !events.IsValidType(rule.EventType) {
+		return apierror.InvalidField("rule.event_type", "event_type must be one of "+strings.Join(events.Types(), ", "))
+	}
+
+	existing, err := s.dbService.GetRepository().Automation.GetByName(rule.Name)
+	if err == nil && existing.ID != rule.ID {
+		return apierror.AlreadyExists(apierror.ResourceAutomationRule, apierror.ReasonAutomationRuleNameTaken,
+			"automation rule name already exists", map[string]string{"name": rule.Name})
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to check automation rule name", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check automation rule name")
+	}
+	return nil
+}
+
+// convertAutomationRuleToProto converts an automation rule
+func convertAutomationRuleToProto(rule *models.AutomationRule) *pbv1.AutomationRuleInfo {
+	info := &pbv1.AutomationRuleInfo{
+		RuleId:      strconv.FormatUint(uint64(rule.ID), 10),
+		Name:        rule.Name,
+		Description: rule.Description,
+		EventType:   rule.EventType,
+		Conditions:  make([]*pbv1.AutomationCondition, len(rule.Conditions)),
+		Actions:     make([]*pbv1.AutomationAction, len(rule.Actions)),
+		Enabled:     rule.Enabled,
+		Operator:    rule.Operator,
+		MatchCount:  rule.MatchCount,
+		CreatedAt:   timestamppb.New(rule.CreatedAt),
+		UpdatedAt:   timestamppb.New(rule.UpdatedAt),
+	}
+	for i, condition := range rule.Conditions {
+		info.Conditions[i] = convertAutomationConditionToProto(condition)
+	}
+	for i, action := range rule.Actions {
+		info.Actions[i] = &pbv1.AutomationAction{Type: string(action.Type), Params: action.Params}
+	}
+	if rule.LastMatchedAt != nil {
+		info.LastMatchedAt = timestamppb.New(*rule.LastMatchedAt)
+	}
+	return info
+}
+
+// convertAutomationConditionToProto converts a condition of an automation rule
+func convertAutomationConditionToProto(condition models.AutomationCondition) *pbv1.AutomationCondition {
+	return &pbv1.AutomationCondition{
+		Field: condition.Field,
+		Op:    string(condition.Operator),
+		Value: condition.Value,
+	}
+}
+
+// convertAutomationExecutionToProto converts an automation execution
+func convertAutomationExecutionToProto(execution *models.AutomationExecution) *pbv1.AutomationExecutionInfo {
+	info := &pbv1.AutomationExecutionInfo{
+		ExecutionId: strconv.FormatUint(uint64(execution.ID), 10),
+		RuleId:      strconv.FormatUint(uint64(execution.RuleID), 10),
+		RuleName:    execution.RuleName,
+		EventType:   execution.EventType,
+		Subject:     execution.Subject,
+		Results:     make([]*pbv1.AutomationActionResult, len(execution.Results)),
+		Failed:      execution.Failed,
+		CreatedAt:   timestamppb.New(execution.CreatedAt),
+	}
+	for i, result := range execution.Results {
+		info.Results[i] = &pbv1.AutomationActionResult{
+			Type:   string(result.Type),
+			Detail: result.Detail,
+			Error:  result.Error,
+		}
+	}
+	return info
+}
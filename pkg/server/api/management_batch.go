@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Batch node and maintenance methods
+
+func (s *ManagementService) BatchNodeOperation(ctx context.Context, req *pbv1.BatchNodeOperationRequest) (*pbv1.BatchNodeOperationResponse, error) {
+	s.logger.Debug("BatchNodeOperation called",
+		zap.String("operation", req.Operation.String()),
+		zap.Int("node_count", len(req.NodeIds)),
+		zap.Bool("dry_run", req.DryRun),
+	)
+
+	if len(req.NodeIds) == 0 {
+		return nil, apierror.MissingField("node_ids")
+	}
+
+	configContent := req.Parameters["config_content"]
+	switch req.Operation {
+	case pbv1.BatchNodeOperationRequest_ENABLE, pbv1.BatchNodeOperationRequest_DISABLE,
+		pbv1.BatchNodeOperationRequest_DELETE:
+	case pbv1.BatchNodeOperationRequest_UPDATE_CONFIG:
+		if err := models.ValidateNodeConfig(configContent); err != nil {
+			return nil, validationError(err, "parameters.")
+		}
+	default:
+		return nil, apierror.InvalidField("operation", "unsupported operation")
+	}
+
+	if !req.DryRun {
+		operation := batchSafetyOperation(models.SafetyOpNodeBatchPrefix, req.Operation)
+		if err := s.checkSafety(operation, len(req.NodeIds), req.Confirmation); err != nil {
+			return nil, err
+		}
+	}
+
+	repo := s.dbService.GetRepository().Node
+	results := make([]*pbv1.NodeOperationResult, len(req.NodeIds))
+	successCount := 0
+	report := newDryRunReport()
+
+	for i, nodeID := range req.NodeIds {
+		id, err := strconv.ParseUint(nodeID, 10, 32)
+		if err != nil {
+			results[i] = &pbv1.NodeOperationResult{NodeId: nodeID, Success: false, Message: "invalid node ID format"}
+			continue
+		}
+
+		node, err := repo.GetByID(uint(id))
+		if err != nil {
+			results[i] = &pbv1.NodeOperationResult{NodeId: nodeID, Success: false, Message: "node not found"}
+			continue
+		}
+
+		if req.DryRun {
+			changes := previewNodeOperation(node, req.Operation, configContent)
+			message := "no change"
+			if len(changes) > 0 {
+				report.add(nodeID, changes...)
+				message = "would be changed"
+			}
+			results[i] = &pbv1.NodeOperationResult{NodeId: nodeID, Success: true, Message: message}
+			successCount++
+			continue
+		}
+
+		switch req.Operation {
+		case pbv1.BatchNodeOperationRequest_ENABLE:
+			err = repo.BatchEnable([]uint{node.ID})
+		case pbv1.BatchNodeOperationRequest_DISABLE:
+			err = repo.BatchDisable([]uint{node.ID})
+		case pbv1.BatchNodeOperationRequest_DELETE:
+			err = repo.Delete(node.ID)
+		case pbv1.BatchNodeOperationRequest_UPDATE_CONFIG:
+			_, err = s.applyNodeConfig(node, configContent, req.Operator, models.NodeConfigSourceBatch, 0)
+		}
+
+		if err != nil {
+			s.logger.Error("Batch node operation failed", zap.Error(err), zap.String("node_id", nodeID))
+			results[i] = &pbv1.NodeOperationResult{NodeId: nodeID, Success: false, Message: err.Error()}
+			continue
+		}
+		results[i] = &pbv1.NodeOperationResult{NodeId: nodeID, Success: true, Message: "operation completed successfully"}
+		successCount++
+	}
+
+	s.logger.Info("Batch node operation completed",
+		zap.String("operation", req.Operation.String()),
+		zap.Int("success_count", successCount),
+		zap.Int("total_count", len(req.NodeIds)),
+		zap.Bool("dry_run", req.DryRun),
+	)
+
+	if req.DryRun {
+		return &pbv1.BatchNodeOperationResponse{
+			Success:      true,
+			Message:      fmt.Sprintf("dry run: %d/%d nodes would change", report.proto().AffectedCount, len(req.NodeIds)),
+			Results:      results,
+			DryRunReport: report.proto(),
+		}, nil
+	}
+
+	// Per-node failures are reported in results, the batch itself succeeded
+	return &pbv1.BatchNodeOperationResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d/%d operations completed successfully", successCount, len(req.NodeIds)),
+		Results: results,
+	}, nil
+}
+
+func (s *ManagementService) RunCleanup(ctx context.Context, req *pbv1.RunCleanupRequest) (*pbv1.RunCleanupResponse, error) {
+	s.logger.Debug("RunCleanup called", zap.Bool("dry_run", req.DryRun))
+
+	if !req.DryRun {
+		if err := s.checkSafety(models.SafetyOpCleanupRun, 1, req.Confirmation); err != nil {
+			return nil, err
+		}
+	}
+
+	targets, err := s.dbService.RunCleanup(req.DryRun)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to run cleanup")
+	}
+
+	var total int64
+	pbTargets := make([]*pbv1.CleanupTarget, len(targets))
+	for i, target := range targets {
+		pbTargets[i] = &pbv1.CleanupTarget{
+			Name:   target.Name,
+			Cutoff: timestamppb.New(target.Cutoff),
+			Count:  target.Count,
+		}
+		total += target.Count
+	}
+
+	message := fmt.Sprintf("%d records removed", total)
+	if req.DryRun {
+		message = fmt.Sprintf("dry run: %d records would be removed", total)
+	}
+
+	return &pbv1.RunCleanupResponse{
+		Success: true,
+		Message: message,
+		Targets: pbTargets,
+		DryRun:  req.DryRun,
+	}, nil
+}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/blocklist"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// errBlocklisted is returned when a registration or trial is blocked. It
+// does not tell which entry matched.
+var errBlocklisted = apierror.New(codes.PermissionDenied, apierror.ReasonBlocklisted,
+	"registration is not allowed for this email address or network", nil)
+
+// Blocklist methods
+
+func (s *ManagementService) CreateBlocklistEntry(ctx context.Context, req *pbv1.CreateBlocklistEntryRequest) (*pbv1.CreateBlocklistEntryResponse, error) {
+	s.logger.Debug("CreateBlocklistEntry called", zap.String("type", req.Type), zap.String("value", req.Value))
+
+	entryType := models.BlocklistType(req.Type)
+	if req.Type == "" {
+		return nil, apierror.MissingField("type")
+	}
+	if !entryType.IsValid() {
+		return nil, apierror.InvalidField("type", "type must be one of email, domain, cidr")
+	}
+	if req.Value == "" {
+		return nil, apierror.MissingField("value")
+	}
+	value, err := blocklist.Normalize(entryType, req.Value)
+	if err != nil {
+		return nil, apierror.InvalidField("value", err.Error())
+	}
+	if len(req.Reason) > models.MaxBlocklistReasonLength {
+		return nil, apierror.InvalidField("reason", "reason is too long")
+	}
+
+	repo := s.dbService.GetRepository().Blocklist
+	if _, err := repo.GetByValue(entryType, value); err == nil {
+		return nil, apierror.AlreadyExists(apierror.ResourceBlocklistEntry, apierror.ReasonBlocklistEntryExists,
+			"value is already blocklisted", map[string]string{"type": req.Type, "value": value})
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to check blocklist entry", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to check blocklist entry")
+	}
+
+	entry := &models.BlocklistEntry{
+		Type:     entryType,
+		Value:    value,
+		Source:   models.BlocklistSourceManual,
+		Reason:   req.Reason,
+		Operator: req.Operator,
+	}
+	if err := repo.Create(entry); err != nil {
+		s.logger.Error("Failed to create blocklist entry", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create blocklist entry")
+	}
+
+	s.logger.Info("Blocklist entry created",
+		zap.Uint("entry_id", entry.ID),
+		zap.String("type", string(entry.Type)),
+		zap.String("value", entry.Value),
+		zap.String("operator", entry.Operator),
+	)
+
+	return &pbv1.CreateBlocklistEntryResponse{
+		Success: true,
+		Message: "blocklist entry created successfully",
+		Entry:   convertBlocklistEntryToProto(entry),
+	}, nil
+}
+
+func (s *ManagementService) DeleteBlocklistEntry(ctx context.Context, req *pbv1.DeleteBlocklistEntryRequest) (*pbv1.DeleteBlocklistEntryResponse, error) {
+	s.logger.Debug("DeleteBlocklistEntry called", zap.String("entry_id", req.EntryId))
+
+	if req.EntryId == "" {
+		return nil, apierror.MissingField("entry_id")
+	}
+	id, err := strconv.ParseUint(req.EntryId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("entry_id", "invalid entry_id format")
+	}
+
+	repo := s.dbService.GetRepository().Blocklist
+	entry, err := repo.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceBlocklistEntry, req.EntryId)
+		}
+		s.logger.Error("Failed to get blocklist entry", zap.Error(err), zap.String("entry_id", req.EntryId))
+		return nil, status.Error(codes.Internal, "failed to get blocklist entry")
+	}
+
+	if err := repo.Delete(entry.ID); err != nil {
+		s.logger.Error("Failed to delete blocklist entry", zap.Error(err), zap.String("entry_id", req.EntryId))
+		return nil, status.Error(codes.Internal, "failed to delete blocklist entry")
+	}
+
+	s.logger.Info("Blocklist entry deleted",
+		zap.Uint("entry_id", entry.ID),
+		zap.String("type", string(entry.Type)),
+		zap.String("value", entry.Value),
+	)
+
+	return &pbv1.DeleteBlocklistEntryResponse{
+		Success: true,
+		Message: "blocklist entry deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) ListBlocklistEntries(ctx context.Context, req *pbv1.ListBlocklistEntriesRequest) (*pbv1.ListBlocklistEntriesResponse, error) {
+	s.logger.Debug("ListBlocklistEntries called", zap.String("type", req.Type), zap.String("source", req.Source))
+
+	entryType := models.BlocklistType(req.Type)
+	if req.Type != "" && !entryType.IsValid() {
+		return nil, apierror.InvalidField("type", "type must be one of email, domain, cidr")
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	offset := int((page - 1) * pageSize)
+
+	repo := s.dbService.GetRepository().Blocklist
+	filter := repository.BlocklistFilter{
+		Type:   entryType,
+		Source: req.Source,
+		Query:  strings.ToLower(strings.TrimSpace(req.Search)),
+	}
+	entries, total, err := repo.List(filter, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list blocklist entries", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list blocklist entries")
+	}
+	blocked, err := repo.BlockedTotal()
+	if err != nil {
+		s.logger.Error("Failed to count blocked attempts", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to count blocked attempts")
+	}
+
+	infos := make([]*pbv1.BlocklistEntryInfo, len(entries))
+	for i, entry := range entries {
+		infos[i] = convertBlocklistEntryToProto(entry)
+	}
+
+	return &pbv1.ListBlocklistEntriesResponse{
+		Entries:      infos,
+		Total:        int32(total),
+		Page:         page,
+		PageSize:     pageSize,
+		TotalBlocked: blocked,
+	}, nil
+}
+
+// RefreshBlocklists imports the configured disposable domain lists now
+// instead of waiting for the next scheduled refresh
+func (s *ManagementService) RefreshBlocklists(ctx context.Context, req *pbv1.RefreshBlocklistsRequest) (*pbv1.RefreshBlocklistsResponse, error) {
+	s.logger.Debug("RefreshBlocklists called")
+
+	if s.blocklists == nil {
+		return nil, apierror.FailedPrecondition(apierror.ReasonBlocklistListsDisabled, "blocklist",
+			"no disposable domain lists are configured on this API server")
+	}
+
+	results, err := s.blocklists.Refresh(ctx)
+	if err != nil {
+		s.logger.Warn("Some disposable domain lists failed to import", zap.Error(err))
+	}
+
+	resp := &pbv1.RefreshBlocklistsResponse{
+		Success: err == nil,
+		Message: "disposable domain lists refreshed",
+		Results: make([]*pbv1.BlocklistListResult, len(results)),
+	}
+	if err != nil {
+		resp.Message = "some disposable domain lists failed to import"
+	}
+	for i, result := range results {
+		resp.Results[i] = &pbv1.BlocklistListResult{
+			Url:     result.URL,
+			Domains: int32(result.Domains),
+			Added:   int32(result.Added),
+			Removed: int32(result.Removed),
+		}
+		if result.Err != nil {
+			resp.Results[i].Error = result.Err.Error()
+		}
+	}
+	return resp, nil
+}
+
+// checkBlocklist checks a self-service email and client IP against the
+// blocklist and counts the attempt on every matching entry
+func (s *ManagementService) checkBlocklist(email, clientIP string) error {
+	repo := s.dbService.GetRepository().Blocklist
+
+	matched, err := repo.Match(strings.ToLower(strings.TrimSpace(email)), blocklist.EmailDomains(email))
+	if err != nil {
+		s.logger.Error("Failed to match blocklist", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check blocklist")
+	}
+
+	if ip := net.ParseIP(clientIP); ip != nil {
+		networks, err := repo.ListByType(models.BlocklistTypeCIDR)
+		if err != nil {
+			s.logger.Error("Failed to list blocklisted networks", zap.Error(err))
+			return status.Error(codes.Internal, "failed to check blocklist")
+		}
+		for _, network := range networks {
+			if blocklist.ContainsIP(network.Value, ip) {
+				matched = append(matched, network)
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(matched))
+	for i, entry := range matched {
+		ids[i] = entry.ID
+	}
+	// The attempt is blocked even when it cannot be counted
+	if err := repo.RecordBlocked(ids, time.Now()); err != nil {
+		s.logger.Warn("Failed to count blocked attempt", zap.Error(err))
+	}
+
+	s.logger.Info("Blocked by blocklist",
+		zap.String("email", email),
+		zap.String("client_ip", clientIP),
+		zap.Uints("entry_ids", ids),
+	)
+	return errBlocklisted
+}
+
+// checkTrialBlocklist checks an order the user placed themselves against the
+// blocklist when it activates a trial plan
+func (s *ManagementService) checkTrialBlocklist(order *models.Order, clientIP string) error {
+	repo := s.dbService.GetRepository()
+
+	plan, err := repo.Plan.GetByID(order.PlanID)
+	if err != nil {
+		s.logger.Error("Failed to get plan", zap.Error(err), zap.Uint("plan_id", order.PlanID))
+		return status.Error(codes.Internal, "failed to check blocklist")
+	}
+	if !plan.IsTrialPlan {
+		return nil
+	}
+
+	user, err := repo.User.GetByID(order.UserID)
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err), zap.Uint("user_id", order.UserID))
+		return status.Error(codes.Internal, "failed to check blocklist")
+	}
+	return s.checkBlocklist(user.Email, clientIP)
+}
+
+// convertBlocklistEntryToProto converts a blocklist entry model to protobuf
+func convertBlocklistEntryToProto(entry *models.BlocklistEntry) *pbv1.BlocklistEntryInfo {
+	info := &pbv1.BlocklistEntryInfo{
+		Id:           strconv.FormatUint(uint64(entry.ID), 10),
+		Type:         string(entry.Type),
+		Value:        entry.Value,
+		Source:       entry.Source,
+		Reason:       entry.Reason,
+		Operator:     entry.Operator,
+		BlockedCount: entry.BlockedCount,
+		CreatedAt:    timestamppb.New(entry.CreatedAt),
+	}
+	if entry.LastBlockedAt != nil {
+		info.LastBlockedAt = timestamppb.New(*entry.LastBlockedAt)
+	}
+	return info
+}
+
+// refreshBlocklists imports the disposable domain lists on start and then
+// at the configured interval
+func (s *AgentService) refreshBlocklists(ctx context.Context) {
+	if s.active() {
+		s.performBlocklistRefresh(ctx)
+	}
+
+	s.runActiveJob(ctx, s.business().Blocklist.RefreshInterval, func() {
+		s.performBlocklistRefresh(ctx)
+	})
+}
+
+// performBlocklistRefresh imports the disposable domain lists once
+func (s *AgentService) performBlocklistRefresh(ctx context.Context) {
+	if _, err := s.blocklists.Refresh(ctx); err != nil {
+		s.logger.Error("Failed to refresh disposable domain lists", zap.Error(err))
+	}
+}
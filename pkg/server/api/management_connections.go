@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Live connection methods
+
+func (s *ManagementService) ListActiveConnections(ctx context.Context, req *pbv1.ListActiveConnectionsRequest) (*pbv1.ListActiveConnectionsResponse, error) {
+	s.logger.Debug("ListActiveConnections called", zap.String("user_id", req.UserId), zap.String("node_id", req.NodeId))
+
+	var userID, nodeID uint64
+	var err error
+	if req.UserId != "" {
+		if userID, err = strconv.ParseUint(req.UserId, 10, 32); err != nil {
+			return nil, apierror.InvalidField("user_id", "invalid user_id format")
+		}
+	}
+	if req.NodeId != "" {
+		if nodeID, err = strconv.ParseUint(req.NodeId, 10, 32); err != nil {
+			return nil, apierror.InvalidField("node_id", "invalid node_id format")
+		}
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	repo := s.dbService.GetRepository()
+	var connections []*models.TrafficRecord
+	switch {
+	case userID != 0:
+		connections, err = repo.Traffic.GetActiveUserConnections(uint(userID))
+	case nodeID != 0:
+		connections, err = repo.Traffic.GetActiveNodeConnections(uint(nodeID))
+	default:
+		connections, err = repo.Traffic.GetActiveConnections()
+	}
+	if err != nil {
+		s.logger.Error("Failed to list active connections", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list active connections")
+	}
+	if userID != 0 && nodeID != 0 {
+		onNode := connections[:0]
+		for _, conn := range connections {
+			if conn.NodeID == uint(nodeID) {
+				onNode = append(onNode, conn)
+			}
+		}
+		connections = onNode
+	}
+
+	total := len(connections)
+	offset := min(int((page-1)*pageSize), total)
+	connections = connections[offset:min(offset+int(pageSize), total)]
+
+	// Tenant databases hold no users or nodes to preload, so the names of
+	// the listed page are looked up
+	usernames := make(map[uint]string)
+	nodeNames := make(map[uint]string)
+	pbConnections := make([]*pbv1.ActiveConnectionInfo, len(connections))
+	for i, conn := range connections {
+		username, ok := usernames[conn.UserID]
+		if !ok {
+			if user, err := repo.User.GetByID(conn.UserID); err == nil {
+				username = user.Username
+			}
+			usernames[conn.UserID] = username
+		}
+		nodeName, ok := nodeNames[conn.NodeID]
+		if !ok {
+			if node, err := repo.Node.GetByID(conn.NodeID); err == nil {
+				nodeName = node.Name
+			}
+			nodeNames[conn.NodeID] = nodeName
+		}
+
+		pbConnections[i] = &pbv1.ActiveConnectionInfo{
+			SessionId:     conn.SessionID,
+			UserId:        strconv.FormatUint(uint64(conn.UserID), 10),
+			Username:      username,
+			NodeId:        strconv.FormatUint(uint64(conn.NodeID), 10),
+			NodeName:      nodeName,
+			ClientIp:      conn.ClientIP,
+			Protocol:      conn.Protocol,
+			ConnectTime:   timestamppb.New(conn.ConnectTime),
+			UploadBytes:   conn.Upload,
+			DownloadBytes: conn.Download,
+		}
+	}
+
+	return &pbv1.ListActiveConnectionsResponse{
+		Connections: pbConnections,
+		Total:       int32(total),
+		Page:        page,
+		PageSize:    pageSize,
+	}, nil
+}
+
+func (s *ManagementService) KillConnection(ctx context.Context, req *pbv1.KillConnectionRequest) (*pbv1.KillConnectionResponse, error) {
+	s.logger.Debug("KillConnection called", zap.String("session_id", req.SessionId))
+
+	if err := s.requireActiveAgents("connection commands"); err != nil {
+		return nil, err
+	}
+	if req.SessionId == "" {
+		return nil, apierror.MissingField("session_id")
+	}
+
+	session, err := s.dbService.GetRepository().Traffic.GetActiveConnection(req.SessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceConnection, req.SessionId)
+		}
+		s.logger.Error("Failed to get connection", zap.Error(err), zap.String("session_id", req.SessionId))
+		return nil, status.Error(codes.Internal, "failed to get connection")
+	}
+
+	nodeID := strconv.FormatUint(uint64(session.NodeID), 10)
+	commandID, err := s.agents.closeConnections(ctx, nodeID, strconv.FormatUint(uint64(session.UserID), 10), []string{session.SessionID})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Connection close queued",
+		zap.String("session_id", session.SessionID),
+		zap.Uint("user_id", session.UserID),
+		zap.String("node_id", nodeID),
+		zap.String("command_id", commandID),
+	)
+	return &pbv1.KillConnectionResponse{
+		SessionId: session.SessionID,
+		NodeId:    nodeID,
+		CommandId: commandID,
+	}, nil
+}
+
+func (s *ManagementService) KickUser(ctx context.Context, req *pbv1.KickUserRequest) (*pbv1.KickUserResponse, error) {
+	s.logger.Debug("KickUser called", zap.String("user_id", req.UserId))
+
+	if err := s.requireActiveAgents("connection commands"); err != nil {
+		return nil, err
+	}
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	repo := s.dbService.GetRepository()
+	if _, err := repo.User.GetByID(uint(userID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+		}
+		s.logger.Error("Failed to get user", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to get user")
+	}
+
+	// The user may still be connected to nodes it lost access to
+	nodes, err := repo.Node.GetUserNodes(uint(userID))
+	if err != nil {
+		s.logger.Error("Failed to get user nodes", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to get user nodes")
+	}
+	connections, err := repo.Traffic.GetActiveUserConnections(uint(userID))
+	if err != nil {
+		s.logger.Error("Failed to list active connections", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to list active connections")
+	}
+	var nodeIDs []uint
+	seen := make(map[uint]bool)
+	for _, node := range nodes {
+		if !seen[node.ID] {
+			seen[node.ID] = true
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+	}
+	for _, conn := range connections {
+		if !seen[conn.NodeID] {
+			seen[conn.NodeID] = true
+			nodeIDs = append(nodeIDs, conn.NodeID)
+		}
+	}
+
+	resp := &pbv1.KickUserResponse{UserId: req.UserId}
+	connected := s.agents.GetNodeStates()
+	for _, id := range nodeIDs {
+		nodeID := strconv.FormatUint(uint64(id), 10)
+		if _, ok := connected[nodeID]; !ok {
+			continue
+		}
+		commandID, err := s.agents.closeConnections(ctx, nodeID, req.UserId, nil)
+		if err != nil {
+			s.logger.Warn("Failed to queue connection close", zap.Error(err),
+				zap.String("node_id", nodeID), zap.String("user_id", req.UserId))
+			continue
+		}
+		resp.NodeIds = append(resp.NodeIds, nodeID)
+		resp.CommandIds = append(resp.CommandIds, commandID)
+	}
+
+	s.logger.Info("User kick queued", zap.String("user_id", req.UserId), zap.Strings("node_ids", resp.NodeIds))
+	return resp, nil
+}
+
+// onlineUsers reports which of the users have a session open or closed
+// within UserOnlineWindow
+func (s *ManagementService) onlineUsers(userIDs []uint) (map[uint]bool, error) {
+	ids, err := s.dbService.GetRepository().Traffic.ListOnlineUserIDs(userIDs, time.Now().Add(-models.UserOnlineWindow))
+	if err != nil {
+		s.logger.Error("Failed to get online users", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get online users")
+	}
+	online := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		online[id] = true
+	}
+	return online, nil
+}
@@ -0,0 +1,400 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// couponErrors are the reasons a coupon cannot be applied to an order
+var couponErrors = []error{
+	models.ErrCouponDisabled,
+	models.ErrCouponNotStarted,
+	models.ErrCouponExpired,
+	models.ErrCouponExhausted,
+	models.ErrCouponUserLimit,
+	models.ErrCouponPlan,
+	models.ErrCouponCurrency,
+	models.ErrCouponMinimumTotal,
+}
+
+// Coupon methods
+
+func (s *ManagementService) CreateCoupon(ctx context.Context, req *pbv1.CreateCouponRequest) (*pbv1.CreateCouponResponse, error) {
+	if req.Coupon == nil {
+		return nil, apierror.MissingField("coupon")
+	}
+	s.logger.Debug("CreateCoupon called", zap.String("code", req.Coupon.Code))
+
+	coupon := &models.Coupon{}
+	if err := s.applyCouponSpec(coupon, req.Coupon); err != nil {
+		return nil, err
+	}
+
+	repo := s.dbService.GetRepository().Coupon
+	wanted := *coupon
+	if err := repo.Create(coupon); err != nil {
+		s.logger.Error("Failed to create coupon", zap.Error(err), zap.String("code", coupon.Code))
+		return nil, status.Error(codes.Internal, "failed to create coupon")
+	}
+	// is_enabled defaults to true on insert
+	if coupon.IsEnabled != wanted.IsEnabled {
+		coupon.IsEnabled = wanted.IsEnabled
+		if err := repo.Update(coupon); err != nil {
+			s.logger.Error("Failed to create coupon", zap.Error(err), zap.String("code", coupon.Code))
+			return nil, status.Error(codes.Internal, "failed to create coupon")
+		}
+	}
+
+	s.logger.Info("Coupon created", zap.Uint("coupon_id", coupon.ID), zap.String("code", coupon.Code))
+
+	return &pbv1.CreateCouponResponse{
+		Success: true,
+		Message: "coupon created successfully",
+		Coupon:  convertCouponToProto(coupon),
+	}, nil
+}
+
+func (s *ManagementService) UpdateCoupon(ctx context.Context, req *pbv1.UpdateCouponRequest) (*pbv1.UpdateCouponResponse, error) {
+	s.logger.Debug("UpdateCoupon called", zap.String("coupon_id", req.CouponId))
+
+	coupon, err := s.getCoupon(req.CouponId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Coupon == nil {
+		return nil, apierror.MissingField("coupon")
+	}
+	if err := s.applyCouponSpec(coupon, req.Coupon); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Coupon.Update(coupon); err != nil {
+		s.logger.Error("Failed to update coupon", zap.Error(err), zap.String("coupon_id", req.CouponId))
+		return nil, status.Error(codes.Internal, "failed to update coupon")
+	}
+
+	s.logger.Info("Coupon updated", zap.Uint("coupon_id", coupon.ID), zap.String("code", coupon.Code))
+
+	return &pbv1.UpdateCouponResponse{
+		Success: true,
+		Message: "coupon updated successfully",
+		Coupon:  convertCouponToProto(coupon),
+	}, nil
+}
+
+func (s *ManagementService) DeleteCoupon(ctx context.Context, req *pbv1.DeleteCouponRequest) (*pbv1.DeleteCouponResponse, error) {
+	s.logger.Debug("DeleteCoupon called", zap.String("coupon_id", req.CouponId))
+
+	coupon, err := s.getCoupon(req.CouponId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Coupon.Delete(coupon.ID); err != nil {
+		s.logger.Error("Failed to delete coupon", zap.Error(err), zap.String("coupon_id", req.CouponId))
+		return nil, status.Error(codes.Internal, "failed to delete coupon")
+	}
+
+	s.logger.Info("Coupon deleted", zap.Uint("coupon_id", coupon.ID), zap.String("code", coupon.Code))
+
+	return &pbv1.DeleteCouponResponse{
+		Success: true,
+		Message: "coupon deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) GetCoupon(ctx context.Context, req *pbv1.GetCouponRequest) (*pbv1.GetCouponResponse, error) {
+	s.logger.Debug("GetCoupon called", zap.String("coupon_id", req.CouponId))
+
+	coupon, err := s.getCoupon(req.CouponId)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.GetCouponResponse{Coupon: convertCouponToProto(coupon)}, nil
+}
+
+func (s *ManagementService) ListCoupons(ctx context.Context, req *pbv1.ListCouponsRequest) (*pbv1.ListCouponsResponse, error) {
+	s.logger.Debug("ListCoupons called",
+		zap.Int32("page", req.Page),
+		zap.Int32("page_size", req.PageSize),
+		zap.String("search", req.Search),
+	)
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	coupons, total, err := s.dbService.GetRepository().Coupon.List(int(offset), int(pageSize), req.Search)
+	if err != nil {
+		s.logger.Error("Failed to list coupons", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list coupons")
+	}
+
+	pbCoupons := make([]*pbv1.CouponInfo, len(coupons))
+	for i, coupon := range coupons {
+		pbCoupons[i] = convertCouponToProto(coupon)
+	}
+
+	return &pbv1.ListCouponsResponse{
+		Coupons:  pbCoupons,
+		Total:    int32(total),
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *ManagementService) GetCouponStatistics(ctx context.Context, req *pbv1.GetCouponStatisticsRequest) (*pbv1.GetCouponStatisticsResponse, error) {
+	s.logger.Debug("GetCouponStatistics called", zap.String("coupon_id", req.CouponId))
+
+	var couponID uint
+	if req.CouponId != "" {
+		coupon, err := s.getCoupon(req.CouponId)
+		if err != nil {
+			return nil, err
+		}
+		couponID = coupon.ID
+	}
+
+	stats, err := s.dbService.GetRepository().Coupon.GetStatistics(couponID)
+	if err != nil {
+		s.logger.Error("Failed to get coupon statistics", zap.Error(err), zap.String("coupon_id", req.CouponId))
+		return nil, status.Error(codes.Internal, "failed to get coupon statistics")
+	}
+
+	pbStats := &pbv1.CouponStatistics{
+		Redemptions: stats.Redemptions,
+		PaidOrders:  stats.PaidOrders,
+		UniqueUsers: stats.UniqueUsers,
+		Currencies:  make([]*pbv1.CouponCurrencyStatistics, len(stats.Currencies)),
+	}
+	for i, currency := range stats.Currencies {
+		pbStats.Currencies[i] = &pbv1.CouponCurrencyStatistics{
+			Currency: currency.Currency,
+			Discount: currency.Discount,
+			Revenue:  currency.Revenue,
+		}
+	}
+	return &pbv1.GetCouponStatisticsResponse{Statistics: pbStats}, nil
+}
+
+// ValidateCoupon prices an order with the coupon as CreateOrder would. A
+// coupon that cannot be used is reported in the response rather than as an
+// error, so that checkout pages can show the reason next to the code.
+func (s *ManagementService) ValidateCoupon(ctx context.Context, req *pbv1.ValidateCouponRequest) (*pbv1.ValidateCouponResponse, error) {
+	s.logger.Debug("ValidateCoupon called",
+		zap.String("code", req.Code),
+		zap.String("user_id", req.UserId),
+		zap.String("plan_id", req.PlanId),
+	)
+
+	if models.NormalizeCouponCode(req.Code) == "" {
+		return nil, apierror.MissingField("code")
+	}
+
+	order, err := s.priceOrder(req.UserId, req.PlanId, req.Code, time.Now())
+	if err != nil {
+		reason := apierror.Reason(err)
+		unknownCode := reason == apierror.ReasonNotFound && apierror.Metadata(err)["resource"] == apierror.ResourceCoupon
+		if reason != apierror.ReasonCouponNotApplicable && !unknownCode {
+			return nil, err
+		}
+		return &pbv1.ValidateCouponResponse{
+			Valid:   false,
+			Reason:  reason,
+			Message: status.Convert(err).Message(),
+		}, nil
+	}
+
+	return &pbv1.ValidateCouponResponse{
+		Valid:           true,
+		Message:         "coupon can be applied",
+		Amount:          order.Amount,
+		ProrationCredit: order.ProrationCredit,
+		Discount:        order.Discount,
+		Total:           order.Total,
+		Currency:        order.Currency,
+	}, nil
+}
+
+// applyCoupon discounts an order with the coupon of code. The per-user limit
+// is checked here for a helpful error and again when the order is saved.
+func (s *ManagementService) applyCoupon(order *models.Order, code string, now time.Time) error {
+	code = models.NormalizeCouponCode(code)
+	repo := s.dbService.GetRepository().Coupon
+
+	coupon, err := repo.GetByCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apierror.NotFound(apierror.ResourceCoupon, code)
+		}
+		s.logger.Error("Failed to get coupon", zap.Error(err), zap.String("code", code))
+		return status.Error(codes.Internal, "failed to apply coupon")
+	}
+
+	// The discount applies to what is left after the upgrade credit
+	if err := coupon.Check(order.PlanID, order.Total, order.Currency, now); err != nil {
+		return couponNotApplicable(err, code)
+	}
+	if coupon.MaxUsesPerUser > 0 {
+		used, err := repo.CountUserRedemptions(coupon.ID, order.UserID)
+		if err != nil {
+			s.logger.Error("Failed to count coupon redemptions", zap.Error(err), zap.String("code", code))
+			return status.Error(codes.Internal, "failed to apply coupon")
+		}
+		if used >= int64(coupon.MaxUsesPerUser) {
+			return couponNotApplicable(models.ErrCouponUserLimit, code)
+		}
+	}
+
+	order.Discount = coupon.Discount(order.Total)
+	order.Total -= order.Discount
+	order.CouponID = &coupon.ID
+	order.CouponCode = coupon.Code
+	return nil
+}
+
+// isCouponError reports whether err tells why a coupon cannot be applied
+func isCouponError(err error) bool {
+	for _, couponErr := range couponErrors {
+		if errors.Is(err, couponErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// couponNotApplicable converts a coupon error into a FailedPrecondition error
+func couponNotApplicable(err error, code string) error {
+	return apierror.FailedPrecondition(apierror.ReasonCouponNotApplicable, "coupon/"+code, err.Error())
+}
+
+// getCoupon parses the coupon ID and loads the coupon
+func (s *ManagementService) getCoupon(couponID string) (*models.Coupon, error) {
+	if couponID == "" {
+		return nil, apierror.MissingField("coupon_id")
+	}
+	id, err := strconv.ParseUint(couponID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("coupon_id", "invalid coupon_id format")
+	}
+
+	coupon, err := s.dbService.GetRepository().Coupon.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceCoupon, couponID)
+		}
+		s.logger.Error("Failed to get coupon", zap.Error(err), zap.String("coupon_id", couponID))
+		return nil, status.Error(codes.Internal, "failed to get coupon")
+	}
+	return coupon, nil
+}
+
+// applyCouponSpec validates a coupon spec and copies it onto the coupon. An
+// empty code keeps the current one, so it is only required on creation.
+func (s *ManagementService) applyCouponSpec(coupon *models.Coupon, spec *pbv1.CouponSpec) error {
+	if code := models.NormalizeCouponCode(spec.Code); code != "" && code != coupon.Code {
+		if err := s.checkCouponCode(code, coupon.ID); err != nil {
+			return err
+		}
+		coupon.Code = code
+	}
+	if coupon.Code == "" {
+		return apierror.MissingField("coupon.code")
+	}
+
+	planIDs := make([]uint, 0, len(spec.PlanIds))
+	for _, planID := range spec.PlanIds {
+		plan, err := s.getPlan(planID)
+		if err != nil {
+			return err
+		}
+		planIDs = append(planIDs, plan.ID)
+	}
+
+	coupon.Description = spec.Description
+	coupon.Type = models.CouponType(spec.Type)
+	coupon.Value = spec.Value
+	coupon.Currency = spec.Currency
+	coupon.MaxUses = int(spec.MaxUses)
+	coupon.MaxUsesPerUser = int(spec.MaxUsesPerUser)
+	coupon.MinimumTotal = spec.MinimumTotal
+	coupon.PlanIDs = planIDs
+	coupon.StartsAt = nil
+	if spec.StartsAt != nil {
+		startsAt := spec.StartsAt.AsTime()
+		coupon.StartsAt = &startsAt
+	}
+	coupon.ExpiresAt = nil
+	if spec.ExpiresAt != nil {
+		expiresAt := spec.ExpiresAt.AsTime()
+		coupon.ExpiresAt = &expiresAt
+	}
+	coupon.IsEnabled = spec.IsEnabled
+
+	return validationError(coupon.Validate(), "coupon.")
+}
+
+// checkCouponCode checks that no other coupon uses a code
+func (s *ManagementService) checkCouponCode(code string, excludeID uint) error {
+	existing, err := s.dbService.GetRepository().Coupon.GetByCode(code)
+	if err == nil && existing.ID != excludeID {
+		return apierror.AlreadyExists(apierror.ResourceCoupon, apierror.ReasonCouponCodeTaken,
+			"coupon code already exists", map[string]string{"code": code})
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to check coupon code", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check coupon code")
+	}
+	return nil
+}
+
+// convertCouponToProto converts a coupon to protobuf
+func convertCouponToProto(coupon *models.Coupon) *pbv1.CouponInfo {
+	spec := &pbv1.CouponSpec{
+		Code:           coupon.Code,
+		Description:    coupon.Description,
+		Type:           string(coupon.Type),
+		Value:          coupon.Value,
+		Currency:       coupon.Currency,
+		MaxUses:        int32(coupon.MaxUses),
+		MaxUsesPerUser: int32(coupon.MaxUsesPerUser),
+		MinimumTotal:   coupon.MinimumTotal,
+		PlanIds:        make([]string, len(coupon.PlanIDs)),
+		IsEnabled:      coupon.IsEnabled,
+	}
+	for i, planID := range coupon.PlanIDs {
+		spec.PlanIds[i] = strconv.FormatUint(uint64(planID), 10)
+	}
+	if coupon.StartsAt != nil {
+		spec.StartsAt = timestamppb.New(*coupon.StartsAt)
+	}
+	if coupon.ExpiresAt != nil {
+		spec.ExpiresAt = timestamppb.New(*coupon.ExpiresAt)
+	}
+
+	return &pbv1.CouponInfo{
+		Id:        strconv.FormatUint(uint64(coupon.ID), 10),
+		Spec:      spec,
+		UsedCount: int32(coupon.UsedCount),
+		CreatedAt: timestamppb.New(coupon.CreatedAt),
+		UpdatedAt: timestamppb.New(coupon.UpdatedAt),
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/events"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// eventStreamBuffer is the number of events buffered for a slow watcher
+const eventStreamBuffer = 256
+
+// WatchEvents streams the real-time events of the requested topics until the
+// caller goes away. Only the instance serving agents has events to stream.
+func (s *ManagementService) WatchEvents(req *pbv1.WatchEventsRequest, stream pbv1.ManagementService_WatchEventsServer) error {
+	if err := s.requireActiveAgents("events"); err != nil {
+		return err
+	}
+
+	topics := req.Topics
+	for _, topic := range topics {
+		if !events.IsValidTopic(topic) {
+			return apierror.InvalidField("topics", fmt.Sprintf("unknown topic %q", topic))
+		}
+	}
+	if len(topics) == 0 {
+		topics = events.Topics()
+	}
+
+	sub := s.agents.events.Subscribe(topics, eventStreamBuffer)
+	defer sub.Close()
+	s.logger.Debug("Event watcher connected", zap.Strings("topics", topics))
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			if dropped := sub.Dropped(); dropped > 0 {
+				s.logger.Warn("Event watcher lost events", zap.Uint64("dropped", dropped))
+			}
+			return nil
+		case event := <-sub.Events():
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// maxExternalAlertSummaryLen is the size of the summary column
+	maxExternalAlertSummaryLen = 1024
+	// maxOverviewExternalAlerts caps the external alerts in the system overview
+	maxOverviewExternalAlerts = 50
+)
+
+// External alert methods
+
+// IngestExternalAlerts stores the infrastructure alerts of a monitoring
+// system notification, correlated with the nodes their labels name
+func (s *ManagementService) IngestExternalAlerts(ctx context.Context, req *pbv1.IngestExternalAlertsRequest) (*pbv1.IngestExternalAlertsResponse, error) {
+	source := req.Source
+	if source == "" {
+		source = models.ExternalAlertSourceAlertmanager
+	}
+	s.logger.Debug("IngestExternalAlerts called", zap.String("source", source), zap.Int("alerts", len(req.Alerts)))
+
+	now := time.Now()
+	resp := &pbv1.IngestExternalAlertsResponse{Success: true}
+	repo := s.dbService.GetRepository().ExternalAlert
+	for i, pbAlert := range req.Alerts {
+		alert, err := convertExternalAlertFromProto(source, pbAlert, now)
+		if err != nil {
+			return nil, apierror.InvalidField(fmt.Sprintf("alerts[%d]", i), err.Error())
+		}
+
+		nodeID, err := repo.FindNodeID(models.ExternalAlertNodeKeys(pbAlert.Labels, req.NodeLabels))
+		if err != nil {
+			s.logger.Error("Failed to correlate external alert", zap.Error(err), zap.String("fingerprint", alert.Fingerprint))
+			return nil, status.Error(codes.Internal, "failed to ingest external alerts")
+		}
+		if nodeID != 0 {
+			alert.NodeID = &nodeID
+			resp.Correlated++
+		}
+
+		if err := repo.Upsert(alert); err != nil {
+			s.logger.Error("Failed to store external alert", zap.Error(err), zap.String("fingerprint", alert.Fingerprint))
+			return nil, status.Error(codes.Internal, "failed to ingest external alerts")
+		}
+		if alert.Status == models.ExternalAlertStatusFiring {
+			resp.Firing++
+		} else {
+			resp.Resolved++
+		}
+	}
+
+	s.logger.Info("External alerts ingested",
+		zap.String("source", source),
+		zap.Int32("firing", resp.Firing),
+		zap.Int32("resolved", resp.Resolved),
+		zap.Int32("correlated", resp.Correlated),
+	)
+	return resp, nil
+}
+
+// externalAlerts reports the external alerts still firing for the system
+// overview, next to the panel's own alerts
+func (s *ManagementService) externalAlerts(now time.Time) []*pbv1.AlertInfo {
+	alerts, err := s.dbService.GetRepository().ExternalAlert.ListFiring(now, maxOverviewExternalAlerts)
+	if err != nil {
+		s.logger.Error("Failed to list external alerts", zap.Error(err))
+		return nil
+	}
+
+	infos := make([]*pbv1.AlertInfo, len(alerts))
+	for i, alert := range alerts {
+		infos[i] = &pbv1.AlertInfo{
+			AlertId:   alert.Source + "-" + alert.Fingerprint,
+			Type:      alert.Name,
+			Severity:  alert.Severity,
+			Message:   alert.Summary,
+			CreatedAt: timestamppb.New(alert.StartsAt),
+			Source:    alert.Source,
+		}
+		if alert.NodeID != nil {
+			infos[i].NodeId = strconv.FormatUint(uint64(*alert.NodeID), 10)
+		}
+	}
+	return infos
+}
+
+// convertExternalAlertFromProto converts an external alert from protobuf
+// format. The alert name and summary fall back on one another, the start
+// on now.
+func convertExternalAlertFromProto(source string, pbAlert *pbv1.ExternalAlert, now time.Time) (*models.ExternalAlert, error) {
+	alertStatus := models.ExternalAlertStatus(pbAlert.Status)
+	if alertStatus != models.ExternalAlertStatusFiring && alertStatus != models.ExternalAlertStatusResolved {
+		return nil, fmt.Errorf("status must be firing or resolved")
+	}
+
+	labels, err := json.Marshal(pbAlert.Labels)
+	if err != nil {
+		return nil, err
+	}
+	alert := &models.ExternalAlert{
+		Source:      source,
+		Fingerprint: pbAlert.Fingerprint,
+		Name:        pbAlert.Labels["alertname"],
+		Status:      alertStatus,
+		Severity:    models.ExternalAlertSeverity(pbAlert.Labels["severity"]),
+		Labels:      string(labels),
+		StartsAt:    now,
+	}
+	if alert.Fingerprint == "" {
+		alert.Fingerprint = models.ExternalAlertFingerprint(pbAlert.Labels)
+	}
+
+	for _, key := range []string{"summary", "description", "message"} {
+		if summary := pbAlert.Annotations[key]; summary != "" {
+			alert.Summary = summary
+			break
+		}
+	}
+	switch {
+	case alert.Name == "" && alert.Summary == "":
+		return nil, fmt.Errorf("alertname label or summary annotation is required")
+	case alert.Name == "":
+		alert.Name = "external"
+	case alert.Summary == "":
+		alert.Summary = alert.Name
+	}
+	if runes := []rune(alert.Summary); len(runes) > maxExternalAlertSummaryLen {
+		alert.Summary = string(runes[:maxExternalAlertSummaryLen])
+	}
+
+	if pbAlert.StartsAt != nil {
+		alert.StartsAt = pbAlert.StartsAt.AsTime()
+	}
+	if pbAlert.EndsAt != nil {
+		endsAt := pbAlert.EndsAt.AsTime()
+		alert.EndsAt = &endsAt
+	}
+	return alert, nil
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// defaultGeoDataStaleAfter applies where the geo data config is not loaded,
+// such as in the web server
+const defaultGeoDataStaleAfter = 48 * time.Hour
+
+// Geo data distribution methods
+
+func (s *ManagementService) GetGeoDataStatus(ctx context.Context, req *pbv1.GetGeoDataStatusRequest) (*pbv1.GetGeoDataStatusResponse, error) {
+	s.logger.Debug("GetGeoDataStatus called")
+
+	artifacts, nodes, err := s.geoDataStatus(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pbv1.GetGeoDataStatusResponse{
+		Artifacts: make([]*pbv1.GeoDataArtifactInfo, len(artifacts)),
+		Nodes:     nodes,
+	}
+	for i, artifact := range artifacts {
+		resp.Artifacts[i] = convertGeoDataArtifactToProto(artifact)
+	}
+	for _, node := range nodes {
+		if node.Stale {
+			resp.StaleNodes++
+		}
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) SyncGeoData(ctx context.Context, req *pbv1.SyncGeoDataRequest) (*pbv1.SyncGeoDataResponse, error) {
+	s.logger.Debug("SyncGeoData called",
+		zap.Bool("refresh", req.Refresh),
+		zap.Strings("node_ids", req.NodeIds),
+	)
+
+	// Only the API server holds the cache and the agent command queues
+	if s.geoData == nil || s.agents == nil {
+		return nil, errGeoDataDisabled
+	}
+
+	resp := &pbv1.SyncGeoDataResponse{}
+	if req.Refresh {
+		changed, err := s.geoData.Refresh(ctx)
+		if err != nil {
+			// A failing source keeps its previous artifact, nodes can still sync
+			s.logger.Warn("Failed to refresh geo data", zap.Error(err))
+		}
+		for _, artifact := range changed {
+			resp.UpdatedArtifacts = append(resp.UpdatedArtifacts, convertGeoDataArtifactToProto(artifact))
+		}
+	}
+
+	nodeIDs := req.NodeIds
+	if len(nodeIDs) == 0 {
+		for nodeID := range s.agents.GetNodeStates() {
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+
+	var queued int
+	resp.Results = make([]*pbv1.NodeOperationResult, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		if err := s.agents.sendGeoDataSync(ctx, nodeID); err != nil {
+			resp.Results[i] = &pbv1.NodeOperationResult{NodeId: nodeID, Success: false, Message: err.Error()}
+			continue
+		}
+		resp.Results[i] = &pbv1.NodeOperationResult{NodeId: nodeID, Success: true, Message: "sync queued"}
+		queued++
+	}
+
+	resp.Success = queued == len(nodeIDs)
+	resp.Message = fmt.Sprintf("geo data sync queued for %d of %d nodes", queued, len(nodeIDs))
+	return resp, nil
+}
+
+// geoDataStatus compares the versions each node reported with the cached artifacts
+func (s *ManagementService) geoDataStatus(now time.Time) ([]*models.GeoDataArtifact, []*pbv1.NodeGeoDataStatus, error) {
+	repo := s.dbService.GetRepository()
+
+	artifacts, err := repo.GeoData.ListArtifacts()
+	if err != nil {
+		s.logger.Error("Failed to list geo data artifacts", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "failed to get geo data status")
+	}
+	versions, err := repo.GeoData.ListNodeVersions()
+	if err != nil {
+		s.logger.Error("Failed to list node geo data versions", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "failed to get geo data status")
+	}
+	nodes, _, err := repo.Node.List(0, -1)
+	if err != nil {
+		s.logger.Error("Failed to list nodes", zap.Error(err))
+		return nil, nil, status.Error(codes.Internal, "failed to get geo data status")
+	}
+
+	staleAfter := s.business().GeoData.StaleAfter
+	if staleAfter <= 0 {
+		staleAfter = defaultGeoDataStaleAfter
+	}
+
+	reported := make(map[uint]map[string]*models.NodeGeoData)
+	for _, version := range versions {
+		if reported[version.NodeID] == nil {
+			reported[version.NodeID] = make(map[string]*models.NodeGeoData)
+		}
+		reported[version.NodeID][version.Name] = version
+	}
+
+	statuses := make([]*pbv1.NodeGeoDataStatus, len(nodes))
+	for i, node := range nodes {
+		nodeStatus := &pbv1.NodeGeoDataStatus{
+			NodeId:   strconv.FormatUint(uint64(node.ID), 10),
+			NodeName: node.Name,
+			Versions: make([]*pbv1.NodeGeoDataVersion, len(artifacts)),
+		}
+		for j, artifact := range artifacts {
+			version := &pbv1.NodeGeoDataVersion{Name: artifact.Name}
+			if held := reported[node.ID][artifact.Name]; held != nil {
+				version.Version = held.Version
+				version.Sha256 = held.SHA256
+				version.ReportedAt = timestamppb.New(held.ReportedAt)
+			}
+			version.UpToDate = version.Sha256 == artifact.SHA256
+			version.Stale = artifact.IsStaleFor(version.Sha256, staleAfter, now)
+			nodeStatus.Stale = nodeStatus.Stale || version.Stale
+			nodeStatus.Versions[j] = version
+		}
+		statuses[i] = nodeStatus
+	}
+	return artifacts, statuses, nil
+}
+
+// geoDataAlerts returns a warning for each node with stale geo data. Alerts
+// are best effort and never fail the overview.
+func (s *ManagementService) geoDataAlerts(now time.Time) []*pbv1.AlertInfo {
+	_, nodes, err := s.geoDataStatus(now)
+	if err != nil {
+		return nil
+	}
+
+	var alerts []*pbv1.AlertInfo
+	for _, node := range nodes {
+		if !node.Stale {
+			continue
+		}
+		for _, version := range node.Versions {
+			if !version.Stale {
+				continue
+			}
+			alerts = append(alerts, &pbv1.AlertInfo{
+				AlertId:   fmt.Sprintf("geodata-%s-%s", node.NodeId, version.Name),
+				Type:      "geodata_stale",
+				Severity:  "warning",
+				Message:   fmt.Sprintf("node %s has an outdated %s", node.NodeName, version.Name),
+				NodeId:    node.NodeId,
+				CreatedAt: timestamppb.New(now),
+			})
+		}
+	}
+	return alerts
+}
+
+// convertGeoDataArtifactToProto converts a geo data artifact to protobuf format
+func convertGeoDataArtifactToProto(artifact *models.GeoDataArtifact) *pbv1.GeoDataArtifactInfo {
+	return &pbv1.GeoDataArtifactInfo{
+		Name:        artifact.Name,
+		Version:     artifact.Version,
+		Sha256:      artifact.SHA256,
+		Size:        artifact.Size,
+		SourceUrl:   artifact.SourceURL,
+		PublishedAt: timestamppb.New(artifact.PublishedAt),
+		CheckedAt:   timestamppb.New(artifact.CheckedAt),
+	}
+}
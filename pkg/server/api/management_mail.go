@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"errors"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// errMailDisabled is returned by mail RPCs when outgoing mail is not configured
+var errMailDisabled = apierror.FailedPrecondition(apierror.ReasonMailDisabled, "mail",
+	"outgoing mail is not enabled")
+
+// Mail methods
+
+func (s *ManagementService) SendTestMail(ctx context.Context, req *pbv1.SendTestMailRequest) (*pbv1.SendTestMailResponse, error) {
+	s.logger.Debug("SendTestMail called", zap.String("template", req.Template), zap.String("to", req.To))
+
+	if s.mailer == nil {
+		return nil, errMailDisabled
+	}
+	if req.Template == "" {
+		return nil, apierror.MissingField("template")
+	}
+	if req.To == "" {
+		return nil, apierror.MissingField("to")
+	}
+	if _, err := netmail.ParseAddress(req.To); err != nil {
+		return nil, apierror.InvalidField("to", "invalid email address")
+	}
+
+	message, err := mail.SampleMessage(req.Template, req.To)
+	if errors.Is(err, mail.ErrUnknownTemplate) {
+		return nil, apierror.InvalidField("template", "template must be one of "+strings.Join(mail.Templates, ", "))
+	}
+	if err != nil {
+		return nil, apierror.Internal("failed to send test mail")
+	}
+
+	// Delivery errors are the point of a test send, report them to the caller
+	if err := s.mailer.SendNow(message); err != nil {
+		s.logger.Warn("Test mail failed", zap.String("template", req.Template), zap.String("to", req.To), zap.Error(err))
+		return &pbv1.SendTestMailResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	s.logger.Info("Test mail sent", zap.String("template", req.Template), zap.String("to", req.To))
+
+	return &pbv1.SendTestMailResponse{
+		Success: true,
+		Message: "test mail sent successfully",
+	}, nil
+}
+
+// sendWelcomeMail queues the welcome mail of a new user, failures are only logged
+func (s *ManagementService) sendWelcomeMail(user *models.User) {
+	if s.mailer == nil || user.Email == "" || !s.mailer.Enabled(mail.TemplateWelcome) {
+		return
+	}
+
+	err := s.mailer.Send(&mail.Message{
+		Template: mail.TemplateWelcome,
+		To:       user.Email,
+		Username: user.Username,
+		TenantID: user.TenantID,
+	})
+	if err != nil {
+		s.logger.Warn("Failed to queue welcome mail", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+}
+
+// sendVerificationMail queues the email verification mail of a user whose
+// address is not verified, failures are only logged
+func (s *ManagementService) sendVerificationMail(user *models.User) {
+	if s.mailer == nil || s.accountTokens == nil || user.Email == "" || user.EmailVerified ||
+		!s.mailer.Enabled(mail.TemplateEmailVerification) {
+		return
+	}
+
+	if err := s.mailer.Send(s.accountTokens.VerificationMessage(user, time.Now())); err != nil {
+		s.logger.Warn("Failed to queue verification mail", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+}
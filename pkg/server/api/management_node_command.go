@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// Node command management methods
+
+func (s *ManagementService) ListCommands(ctx context.Context, req *pbv1.ListCommandsRequest) (*pbv1.ListCommandsResponse, error) {
+	s.logger.Debug("ListCommands called", zap.String("node_id", req.NodeId), zap.String("status", req.Status))
+
+	var filter repository.NodeCommandFilter
+	if req.NodeId != "" {
+		nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("node_id", "invalid node_id format")
+		}
+		filter.NodeID = uint(nodeID)
+	}
+	if req.Status != "" {
+		filter.Status = models.NodeCommandStatus(req.Status)
+		if !filter.Status.IsValid() {
+			return nil, apierror.InvalidField("status", "status must be pending, delivered, succeeded, failed or timed_out")
+		}
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	commands, total, err := s.dbService.GetRepository().NodeCommand.List(filter, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list node commands", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list node commands")
+	}
+
+	resp := &pbv1.ListCommandsResponse{
+		Commands: make([]*pbv1.NodeCommandInfo, len(commands)),
+		Total:    int32(total),
+	}
+	for i, command := range commands {
+		resp.Commands[i] = s.convertNodeCommandToProto(command)
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) convertNodeCommandToProto(command *models.NodeCommand) *pbv1.NodeCommandInfo {
+	info := &pbv1.NodeCommandInfo{
+		CommandId: command.CommandID,
+		NodeId:    strconv.FormatUint(uint64(command.NodeID), 10),
+		Type:      command.Type,
+		UserId:    command.UserID,
+		RequestId: command.RequestID,
+		Status:    string(command.Status),
+		Output:    command.Output,
+		CreatedAt: timestamppb.New(command.CreatedAt),
+	}
+	if command.DeliveredAt != nil {
+		info.DeliveredAt = timestamppb.New(*command.DeliveredAt)
+	}
+	if command.CompletedAt != nil {
+		info.CompletedAt = timestamppb.New(*command.CompletedAt)
+	}
+	return info
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Node config history methods
+
+func (s *ManagementService) ListNodeConfigVersions(ctx context.Context, req *pbv1.ListNodeConfigVersionsRequest) (*pbv1.ListNodeConfigVersionsResponse, error) {
+	s.logger.Debug("ListNodeConfigVersions called", zap.String("node_id", req.NodeId))
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	versions, total, err := s.dbService.GetRepository().NodeConfigVersion.List(node.ID, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list node config versions", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list node config versions")
+	}
+
+	resp := &pbv1.ListNodeConfigVersionsResponse{
+		Versions:       make([]*pbv1.NodeConfigVersionInfo, len(versions)),
+		Total:          int32(total),
+		CurrentVersion: int32(node.ConfigVersion),
+	}
+	for i, version := range versions {
+		resp.Versions[i] = convertNodeConfigVersionToProto(version, false)
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) GetNodeConfigVersion(ctx context.Context, req *pbv1.GetNodeConfigVersionRequest) (*pbv1.GetNodeConfigVersionResponse, error) {
+	s.logger.Debug("GetNodeConfigVersion called", zap.String("node_id", req.NodeId), zap.Int32("version", req.Version))
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.getNodeConfigVersion(node, "version", req.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.GetNodeConfigVersionResponse{Version: convertNodeConfigVersionToProto(version, true)}, nil
+}
+
+func (s *ManagementService) DiffNodeConfigVersions(ctx context.Context, req *pbv1.DiffNodeConfigVersionsRequest) (*pbv1.DiffNodeConfigVersionsResponse, error) {
+	s.logger.Debug("DiffNodeConfigVersions called",
+		zap.String("node_id", req.NodeId),
+		zap.Int32("from_version", req.FromVersion),
+		zap.Int32("to_version", req.ToVersion),
+	)
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	from, err := s.getNodeConfigVersion(node, "from_version", req.FromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.getNodeConfigVersion(node, "to_version", req.ToVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pbv1.DiffNodeConfigVersionsResponse{
+		From:      convertNodeConfigVersionToProto(from, false),
+		To:        convertNodeConfigVersionToProto(to, false),
+		Identical: from.SHA256 == to.SHA256,
+	}
+	if !resp.Identical {
+		resp.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(from.Content),
+			B:        difflib.SplitLines(to.Content),
+			FromFile: fmt.Sprintf("version %d", from.Version),
+			ToFile:   fmt.Sprintf("version %d", to.Version),
+			Context:  3,
+		})
+		if err != nil {
+			s.logger.Error("Failed to diff node config versions", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to diff node config versions")
+		}
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) RestoreNodeConfigVersion(ctx context.Context, req *pbv1.RestoreNodeConfigVersionRequest) (*pbv1.RestoreNodeConfigVersionResponse, error) {
+	s.logger.Debug("RestoreNodeConfigVersion called",
+		zap.String("node_id", req.NodeId),
+		zap.Int32("version", req.Version),
+		zap.String("operator", req.Operator),
+	)
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.getNodeConfigVersion(node, "version", req.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// The old config goes through the same checks as a new config and gets
+	// the node's current overrides
+	applied, err := s.applyNodeConfig(node, version.BaseContent(), req.Operator, models.NodeConfigSourceRestore, version.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.RestoreNodeConfigVersionResponse{
+		Success:       true,
+		Message:       fmt.Sprintf("version %d restored as version %d", version.Version, applied.Version),
+		ConfigVersion: int32(applied.Version),
+	}, nil
+}
+
+// applyNodeConfig validates a config, sets the node's overrides on top of it,
+// applies it to the node and records it as the node's next config version.
+// Every config change goes through here.
+func (s *ManagementService) applyNodeConfig(node *models.Node, content, author string, source models.NodeConfigSource, restoredFrom int) (*models.NodeConfigVersion, error) {
+	if err := models.ValidateNodeConfig(content); err != nil {
+		return nil, validationError(err, "")
+	}
+	if len(author) > 64 {
+		return nil, apierror.InvalidField("operator", "operator is too long")
+	}
+
+	overrides, err := s.dbService.GetRepository().NodeConfigVersion.ListOverrides(node.ID)
+	if err != nil {
+		s.logger.Error("Failed to list node config overrides", zap.Error(err), zap.Uint("node_id", node.ID))
+		return nil, apierror.Internal("failed to update node config")
+	}
+	rendered, err := renderNodeConfig(content, overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	version := &models.NodeConfigVersion{
+		Author:       author,
+		Source:       source,
+		RestoredFrom: restoredFrom,
+		SHA256:       contentHash(rendered),
+		Size:         int64(len(rendered)),
+		Content:      rendered,
+	}
+	if rendered != content {
+		version.Base = content
+	}
+	if err := s.dbService.GetRepository().NodeConfigVersion.Apply(node.ID, version); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceNode, strconv.FormatUint(uint64(node.ID), 10))
+		}
+		s.logger.Error("Failed to apply node config", zap.Error(err), zap.Uint("node_id", node.ID))
+		return nil, apierror.Internal("failed to update node config")
+	}
+
+	node.ConfigContent = rendered
+	node.ConfigVersion = version.Version
+
+	s.logger.Info("Node config applied",
+		zap.Uint("node_id", node.ID),
+		zap.Int("version", version.Version),
+		zap.String("source", string(source)),
+		zap.String("author", author),
+	)
+	return version, nil
+}
+
+// getConfigNode gets the node whose config history is requested
+func (s *ManagementService) getConfigNode(nodeID string) (*models.Node, error) {
+	if nodeID == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	id, err := strconv.ParseUint(nodeID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+	node, err := s.dbService.GetRepository().Node.GetByID(uint(id))
+	if err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNode, nodeID)
+	}
+	return node, nil
+}
+
+// getNodeConfigVersion gets a config version of the node named by field in the request
+func (s *ManagementService) getNodeConfigVersion(node *models.Node, field string, number int32) (*models.NodeConfigVersion, error) {
+	if number <= 0 {
+		return nil, apierror.MissingField(field)
+	}
+	version, err := s.dbService.GetRepository().NodeConfigVersion.Get(node.ID, int(number))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apierror.NotFound(apierror.ResourceNodeConfigVersion, fmt.Sprintf("%d/%d", node.ID, number))
+	}
+	if err != nil {
+		s.logger.Error("Failed to get node config version", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get node config version")
+	}
+	return version, nil
+}
+
+// convertNodeConfigVersionToProto converts a node config version to protobuf
+// format, with its content only when withContent is set
+func convertNodeConfigVersionToProto(version *models.NodeConfigVersion, withContent bool) *pbv1.NodeConfigVersionInfo {
+	info := &pbv1.NodeConfigVersionInfo{
+		Version:      int32(version.Version),
+		Author:       version.Author,
+		Source:       string(version.Source),
+		RestoredFrom: int32(version.RestoredFrom),
+		Sha256:       version.SHA256,
+		Size:         version.Size,
+		CreatedAt:    timestamppb.New(version.CreatedAt),
+	}
+	if withContent {
+		info.Content = version.Content
+		info.BaseContent = version.Base
+	}
+	return info
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Node config override methods
+
+func (s *ManagementService) ListNodeConfigOverrides(ctx context.Context, req *pbv1.ListNodeConfigOverridesRequest) (*pbv1.ListNodeConfigOverridesResponse, error) {
+	s.logger.Debug("ListNodeConfigOverrides called", zap.String("node_id", req.NodeId))
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := s.dbService.GetRepository().NodeConfigVersion.ListOverrides(node.ID)
+	if err != nil {
+		s.logger.Error("Failed to list node config overrides", zap.Error(err), zap.Uint("node_id", node.ID))
+		return nil, apierror.Internal("failed to list node config overrides")
+	}
+
+	resp := &pbv1.ListNodeConfigOverridesResponse{
+		Overrides: make([]*pbv1.NodeConfigOverrideInfo, len(overrides)),
+	}
+	for i, override := range overrides {
+		resp.Overrides[i] = convertNodeConfigOverrideToProto(override)
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) SetNodeConfigOverride(ctx context.Context, req *pbv1.SetNodeConfigOverrideRequest) (*pbv1.SetNodeConfigOverrideResponse, error) {
+	s.logger.Debug("SetNodeConfigOverride called",
+		zap.String("node_id", req.NodeId),
+		zap.String("path", req.Path),
+		zap.String("operator", req.Operator),
+	)
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	override := &models.NodeConfigOverride{
+		NodeID: node.ID,
+		Path:   req.Path,
+		Value:  req.Value,
+		Author: req.Operator,
+	}
+	if err := override.Validate(); err != nil {
+		return nil, validationError(err, "")
+	}
+	if len(req.Operator) > 64 {
+		return nil, apierror.InvalidField("operator", "operator is too long")
+	}
+
+	base, err := s.nodeConfigBase(node)
+	if err != nil {
+		return nil, err
+	}
+	repo := s.dbService.GetRepository().NodeConfigVersion
+	overrides, err := repo.ListOverrides(node.ID)
+	if err != nil {
+		s.logger.Error("Failed to list node config overrides", zap.Error(err), zap.Uint("node_id", node.ID))
+		return nil, apierror.Internal("failed to set node config override")
+	}
+
+	// An override that does not fit the config is not stored
+	if _, err := renderNodeConfig(base, withNodeConfigOverride(overrides, override)); err != nil {
+		return nil, err
+	}
+	if err := repo.SetOverride(override); err != nil {
+		s.logger.Error("Failed to set node config override", zap.Error(err), zap.Uint("node_id", node.ID))
+		return nil, apierror.Internal("failed to set node config override")
+	}
+
+	configVersion, err := s.reapplyNodeConfig(node, base, req.Operator)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Node config override set",
+		zap.Uint("node_id", node.ID),
+		zap.String("path", override.Path),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.SetNodeConfigOverrideResponse{
+		Success:       true,
+		Message:       "node config override set successfully",
+		ConfigVersion: configVersion,
+		Override:      convertNodeConfigOverrideToProto(override),
+	}, nil
+}
+
+func (s *ManagementService) ClearNodeConfigOverrides(ctx context.Context, req *pbv1.ClearNodeConfigOverridesRequest) (*pbv1.ClearNodeConfigOverridesResponse, error) {
+	s.logger.Debug("ClearNodeConfigOverrides called",
+		zap.String("node_id", req.NodeId),
+		zap.Strings("paths", req.Paths),
+		zap.String("operator", req.Operator),
+	)
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Operator) > 64 {
+		return nil, apierror.InvalidField("operator", "operator is too long")
+	}
+
+	cleared, err := s.dbService.GetRepository().NodeConfigVersion.DeleteOverrides(node.ID, req.Paths)
+	if err != nil {
+		s.logger.Error("Failed to clear node config overrides", zap.Error(err), zap.Uint("node_id", node.ID))
+		return nil, apierror.Internal("failed to clear node config overrides")
+	}
+
+	configVersion := int32(node.ConfigVersion)
+	if cleared > 0 {
+		base, err := s.nodeConfigBase(node)
+		if err != nil {
+			return nil, err
+		}
+		if configVersion, err = s.reapplyNodeConfig(node, base, req.Operator); err != nil {
+			return nil, err
+		}
+		s.logger.Info("Node config overrides cleared",
+			zap.Uint("node_id", node.ID),
+			zap.Int64("cleared", cleared),
+			zap.String("operator", req.Operator),
+		)
+	}
+
+	return &pbv1.ClearNodeConfigOverridesResponse{
+		Success:       true,
+		Message:       fmt.Sprintf("%d node config overrides cleared", cleared),
+		Cleared:       int32(cleared),
+		ConfigVersion: configVersion,
+	}, nil
+}
+
+// nodeConfigBase returns the config the node's current config was rendered
+// from, before its overrides
+func (s *ManagementService) nodeConfigBase(node *models.Node) (string, error) {
+	if node.ConfigVersion > 0 {
+		version, err := s.dbService.GetRepository().NodeConfigVersion.Get(node.ID, node.ConfigVersion)
+		if err == nil {
+			return version.BaseContent(), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Error("Failed to get node config version", zap.Error(err), zap.Uint("node_id", node.ID))
+			return "", apierror.Internal("failed to get node config")
+		}
+	}
+
+	// Configs set before the config history have no version
+	if node.ConfigContent == "" {
+		return "", apierror.FailedPrecondition(apierror.ReasonNodeConfigMissing, "node/"+strconv.FormatUint(uint64(node.ID), 10),
+			"node has no config to override")
+	}
+	return node.ConfigContent, nil
+}
+
+// reapplyNodeConfig applies the node's config again after its overrides
+// changed, returning the node's config version. No version is recorded when
+// the config stays the same.
+func (s *ManagementService) reapplyNodeConfig(node *models.Node, base, author string) (int32, error) {
+	overrides, err := s.dbService.GetRepository().NodeConfigVersion.ListOverrides(node.ID)
+	if err != nil {
+		s.logger.Error("Failed to list node config overrides", zap.Error(err), zap.Uint("node_id", node.ID))
+		return 0, apierror.Internal("failed to update node config")
+	}
+	rendered, err := renderNodeConfig(base, overrides)
+	if err != nil {
+		return 0, err
+	}
+	if rendered == node.ConfigContent {
+		return int32(node.ConfigVersion), nil
+	}
+
+	version, err := s.applyNodeConfig(node, base, author, models.NodeConfigSourceOverride, 0)
+	if err != nil {
+		return 0, err
+	}
+	return int32(version.Version), nil
+}
+
+// renderNodeConfig sets overrides in a node config and checks the result
+func renderNodeConfig(content string, overrides []*models.NodeConfigOverride) (string, error) {
+	rendered, err := models.ApplyNodeConfigOverrides(content, overrides)
+	if err != nil {
+		return "", validationError(err, "")
+	}
+	if err := models.ValidateNodeConfig(rendered); err != nil {
+		return "", validationError(err, "")
+	}
+	return rendered, nil
+}
+
+// withNodeConfigOverride returns the overrides with override added or
+// replacing the one with its path, in path order
+func withNodeConfigOverride(overrides []*models.NodeConfigOverride, override *models.NodeConfigOverride) []*models.NodeConfigOverride {
+	result := []*models.NodeConfigOverride{override}
+	for _, existing := range overrides {
+		if existing.Path != override.Path {
+			result = append(result, existing)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+func convertNodeConfigOverrideToProto(override *models.NodeConfigOverride) *pbv1.NodeConfigOverrideInfo {
+	return &pbv1.NodeConfigOverrideInfo{
+		Path:      override.Path,
+		Value:     override.Value,
+		Author:    override.Author,
+		UpdatedAt: timestamppb.New(override.UpdatedAt),
+	}
+}
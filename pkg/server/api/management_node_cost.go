@@ -0,0 +1,302 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// costMonthLayout is the format of months in cost requests
+	costMonthLayout = "2006-01"
+	// bytesPerGB converts traffic to the unit of the cost per GB
+	bytesPerGB = 1 << 30
+	// maxCostReportMonths bounds the range of a cost report
+	maxCostReportMonths = 24
+)
+
+// Node cost methods
+
+func (s *ManagementService) SetNodeCost(ctx context.Context, req *pbv1.SetNodeCostRequest) (*pbv1.SetNodeCostResponse, error) {
+	s.logger.Debug("SetNodeCost called",
+		zap.String("node_id", req.NodeId),
+		zap.String("month", req.Month),
+		zap.String("category", req.Category),
+	)
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+	if req.Month == "" {
+		return nil, apierror.MissingField("month")
+	}
+	month, err := parseCostMonth("month", req.Month)
+	if err != nil {
+		return nil, err
+	}
+	category := models.NodeCostCategory(req.Category)
+	if !category.IsValid() {
+		return nil, apierror.InvalidField("category", "category must be one of server, bandwidth, energy, other")
+	}
+	if req.Amount < 0 {
+		return nil, apierror.InvalidField("amount", "amount cannot be negative")
+	}
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = "USD"
+	}
+	if len(currency) != 3 {
+		return nil, apierror.InvalidField("currency", "currency must be a 3-letter ISO 4217 code")
+	}
+	if len(req.Notes) > 255 {
+		return nil, apierror.InvalidField("notes", "notes are too long")
+	}
+
+	// Costs may be recorded for removed nodes to complete past reports
+	if _, err := s.dbService.GetRepository().Node.GetByIDUnscoped(uint(nodeID)); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
+	}
+
+	cost := &models.NodeCost{
+		NodeID:   uint(nodeID),
+		Month:    month,
+		Category: category,
+		Amount:   req.Amount,
+		Currency: currency,
+		Notes:    req.Notes,
+	}
+	if err := s.dbService.GetRepository().NodeCost.Upsert(cost); err != nil {
+		s.logger.Error("Failed to record node cost", zap.Error(err), zap.String("node_id", req.NodeId))
+		return nil, status.Error(codes.Internal, "failed to record node cost")
+	}
+
+	s.logger.Info("Node cost recorded",
+		zap.String("node_id", req.NodeId),
+		zap.String("month", req.Month),
+		zap.String("category", req.Category),
+		zap.Int64("amount", cost.Amount),
+		zap.String("currency", cost.Currency),
+	)
+
+	return &pbv1.SetNodeCostResponse{
+		Success: true,
+		Message: "node cost recorded successfully",
+		Cost:    s.convertNodeCostToProto(cost),
+	}, nil
+}
+
+func (s *ManagementService) ListNodeCosts(ctx context.Context, req *pbv1.ListNodeCostsRequest) (*pbv1.ListNodeCostsResponse, error) {
+	s.logger.Debug("ListNodeCosts called", zap.String("node_id", req.NodeId))
+
+	var nodeID uint64
+	if req.NodeId != "" {
+		var err error
+		nodeID, err = strconv.ParseUint(req.NodeId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("node_id", "invalid node_id format")
+		}
+	}
+
+	endMonth, err := parseCostMonth("end_month", req.EndMonth)
+	if err != nil {
+		return nil, err
+	}
+	startMonth := endMonth.AddDate(0, -11, 0)
+	if req.StartMonth != "" {
+		if startMonth, err = parseCostMonth("start_month", req.StartMonth); err != nil {
+			return nil, err
+		}
+	}
+
+	costs, err := s.dbService.GetRepository().NodeCost.List(uint(nodeID), startMonth, endMonth)
+	if err != nil {
+		s.logger.Error("Failed to list node costs", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list node costs")
+	}
+
+	pbCosts := make([]*pbv1.NodeCostInfo, len(costs))
+	for i, cost := range costs {
+		pbCosts[i] = s.convertNodeCostToProto(cost)
+	}
+	return &pbv1.ListNodeCostsResponse{Costs: pbCosts}, nil
+}
+
+func (s *ManagementService) DeleteNodeCost(ctx context.Context, req *pbv1.DeleteNodeCostRequest) (*pbv1.DeleteNodeCostResponse, error) {
+	s.logger.Debug("DeleteNodeCost called", zap.String("cost_id", req.CostId))
+
+	if req.CostId == "" {
+		return nil, apierror.MissingField("cost_id")
+	}
+	costID, err := strconv.ParseUint(req.CostId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("cost_id", "invalid cost_id format")
+	}
+
+	repo := s.dbService.GetRepository().NodeCost
+	if _, err := repo.GetByID(uint(costID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceNodeCost, req.CostId)
+		}
+		s.logger.Error("Failed to get node cost", zap.Error(err), zap.String("cost_id", req.CostId))
+		return nil, status.Error(codes.Internal, "failed to get node cost")
+	}
+	if err := repo.Delete(uint(costID)); err != nil {
+		s.logger.Error("Failed to delete node cost", zap.Error(err), zap.String("cost_id", req.CostId))
+		return nil, status.Error(codes.Internal, "failed to delete node cost")
+	}
+
+	return &pbv1.DeleteNodeCostResponse{
+		Success: true,
+		Message: "node cost deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) GetNodeCostReport(ctx context.Context, req *pbv1.GetNodeCostReportRequest) (*pbv1.GetNodeCostReportResponse, error) {
+	s.logger.Debug("GetNodeCostReport called",
+		zap.String("start_month", req.StartMonth),
+		zap.String("end_month", req.EndMonth),
+	)
+
+	startMonth, err := parseCostMonth("start_month", req.StartMonth)
+	if err != nil {
+		return nil, err
+	}
+	endMonth := startMonth
+	if req.EndMonth != "" {
+		if endMonth, err = parseCostMonth("end_month", req.EndMonth); err != nil {
+			return nil, err
+		}
+	}
+	if endMonth.Before(startMonth) {
+		return nil, apierror.InvalidField("end_month", "end_month cannot be before start_month")
+	}
+	if endMonth.After(startMonth.AddDate(0, maxCostReportMonths-1, 0)) {
+		return nil, apierror.InvalidField("end_month", "report cannot span more than 24 months")
+	}
+
+	repo := s.dbService.GetRepository()
+	costs, err := repo.NodeCost.List(0, startMonth, endMonth)
+	if err != nil {
+		s.logger.Error("Failed to list node costs", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to build cost report")
+	}
+	usage, err := repo.NodeCost.GetNodeUsage(startMonth, endMonth.AddDate(0, 1, 0))
+	if err != nil {
+		s.logger.Error("Failed to get node usage", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to build cost report")
+	}
+
+	entries := buildNodeCostReport(costs, usage)
+	for _, entry := range entries {
+		nodeID, _ := strconv.ParseUint(entry.NodeId, 10, 32)
+		if node, err := repo.Node.GetByIDUnscoped(uint(nodeID)); err == nil {
+			entry.NodeName = node.Name
+		}
+	}
+
+	return &pbv1.GetNodeCostReportResponse{
+		Entries:    entries,
+		StartMonth: startMonth.Format(costMonthLayout),
+		EndMonth:   endMonth.Format(costMonthLayout),
+	}, nil
+}
+
+// buildNodeCostReport combines the costs with the usage of the same period.
+// A node paid for in several currencies gets one entry per currency, each
+// set against the node's full usage.
+func buildNodeCostReport(costs []*models.NodeCost, usage map[uint]*models.NodeUsage) []*pbv1.NodeCostReportEntry {
+	type key struct {
+		nodeID   uint
+		currency string
+	}
+
+	totals := make(map[key]int64)
+	for _, cost := range costs {
+		totals[key{cost.NodeID, cost.Currency}] += cost.Amount
+	}
+
+	var allTraffic int64
+	for _, u := range usage {
+		allTraffic += u.TotalTraffic
+	}
+
+	entries := make([]*pbv1.NodeCostReportEntry, 0, len(totals))
+	for k, total := range totals {
+		entry := &pbv1.NodeCostReportEntry{
+			NodeId:    strconv.FormatUint(uint64(k.nodeID), 10),
+			Currency:  k.currency,
+			TotalCost: total,
+		}
+		if u, ok := usage[k.nodeID]; ok {
+			entry.TotalTraffic = u.TotalTraffic
+			entry.ActiveUsers = u.ActiveUsers
+		}
+		if entry.TotalTraffic > 0 {
+			entry.CostPerGb = float64(total) / (float64(entry.TotalTraffic) / bytesPerGB)
+		}
+		if entry.ActiveUsers > 0 {
+			entry.CostPerActiveUser = float64(total) / float64(entry.ActiveUsers)
+		}
+		if allTraffic > 0 {
+			entry.TrafficShare = float64(entry.TotalTraffic) / float64(allTraffic)
+		}
+		entries = append(entries, entry)
+	}
+
+	// Most expensive per GB first; nodes without traffic lead as retirement candidates
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if (a.TotalTraffic == 0) != (b.TotalTraffic == 0) {
+			return a.TotalTraffic == 0
+		}
+		if a.CostPerGb != b.CostPerGb {
+			return a.CostPerGb > b.CostPerGb
+		}
+		return a.NodeId < b.NodeId
+	})
+	return entries
+}
+
+// parseCostMonth parses a YYYY-MM month, defaulting to the current month
+func parseCostMonth(field, value string) (time.Time, error) {
+	if value == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), nil
+	}
+	month, err := time.ParseInLocation(costMonthLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, apierror.InvalidField(field, field+" must be formatted as YYYY-MM")
+	}
+	return month, nil
+}
+
+// convertNodeCostToProto converts a node cost to protobuf
+func (s *ManagementService) convertNodeCostToProto(cost *models.NodeCost) *pbv1.NodeCostInfo {
+	return &pbv1.NodeCostInfo{
+		Id:        strconv.FormatUint(uint64(cost.ID), 10),
+		NodeId:    strconv.FormatUint(uint64(cost.NodeID), 10),
+		Month:     cost.Month.Format(costMonthLayout),
+		Category:  string(cost.Category),
+		Amount:    cost.Amount,
+		Currency:  cost.Currency,
+		Notes:     cost.Notes,
+		UpdatedAt: timestamppb.New(cost.UpdatedAt),
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/apierror"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// nodeDiagnosticTypes are the diagnostics agents run
+var nodeDiagnosticTypes = []string{"ping", "traceroute", "http", "tcp", "speedtest"}
+
+const (
+	// maxNodeDiagnosticPings bounds the echo requests of a ping
+	maxNodeDiagnosticPings = 20
+	// defaultNodeDiagnosticTimeout and maxNodeDiagnosticTimeout bound the
+	// wait for the result, nodes take the command with their next heartbeat
+	// and take up to a minute to run it
+	defaultNodeDiagnosticTimeout = 90 * time.Second
+	maxNodeDiagnosticTimeout     = 3 * time.Minute
+)
+
+func (s *ManagementService) RunNodeDiagnostic(ctx context.Context, req *pbv1.RunNodeDiagnosticRequest) (*pbv1.RunNodeDiagnosticResponse, error) {
+	s.logger.Debug("RunNodeDiagnostic called",
+		zap.String("node_id", req.NodeId),
+		zap.String("type", req.Type),
+		zap.String("target", req.Target),
+	)
+
+	if err := s.requireActiveAgents("node diagnostics"); err != nil {
+		return nil, err
+	}
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	if !slices.Contains(nodeDiagnosticTypes, req.Type) {
+		return nil, apierror.InvalidField("type", "type must be ping, traceroute, http, tcp or speedtest")
+	}
+	// Speedtests download a default URL without a target
+	if req.Target == "" && req.Type != "speedtest" {
+		return nil, apierror.MissingField("target")
+	}
+	if req.Type == "tcp" && (req.Port < 1 || req.Port > 65535) {
+		return nil, apierror.InvalidField("port", "port must be between 1 and 65535")
+	}
+	if req.Count < 0 || req.Count > maxNodeDiagnosticPings {
+		return nil, apierror.InvalidField("count", fmt.Sprintf("count must be between 1 and %d", maxNodeDiagnosticPings))
+	}
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultNodeDiagnosticTimeout
+	}
+	if timeout < 0 || timeout > maxNodeDiagnosticTimeout {
+		return nil, apierror.InvalidField("timeout_seconds", fmt.Sprintf("timeout_seconds must be between 1 and %d", int(maxNodeDiagnosticTimeout.Seconds())))
+	}
+
+	parameters := map[string]string{
+		"type":   req.Type,
+		"target": req.Target,
+	}
+	if req.Port > 0 {
+		parameters["port"] = strconv.Itoa(int(req.Port))
+	}
+	if req.Count > 0 {
+		parameters["count"] = strconv.Itoa(int(req.Count))
+	}
+
+	result, err := s.agents.runNodeDiagnostic(ctx, req.NodeId, parameters, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.RunNodeDiagnosticResponse{
+		NodeId: req.NodeId,
+		Result: result,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// maxEnrollmentTTL bounds how long an enrollment token may stay valid
+const maxEnrollmentTTL = 30 * 24 * time.Hour
+
+// Node enrollment methods
+
+func (s *ManagementService) CreateNodeEnrollment(ctx context.Context, req *pbv1.CreateNodeEnrollmentRequest) (*pbv1.CreateNodeEnrollmentResponse, error) {
+	s.logger.Debug("CreateNodeEnrollment called", zap.String("node_name", req.NodeName))
+
+	config := s.business().Node.Enrollment
+	ttl := config.TokenTTL
+	if req.TtlSeconds < 0 {
+		return nil, apierror.InvalidField("ttl_seconds", "ttl_seconds cannot be negative")
+	}
+	if req.TtlSeconds > 0 {
+		ttl = time.Duration(req.TtlSeconds) * time.Second
+	}
+	if ttl > maxEnrollmentTTL {
+		return nil, apierror.InvalidField("ttl_seconds", fmt.Sprintf("ttl_seconds cannot exceed %d", int64(maxEnrollmentTTL.Seconds())))
+	}
+
+	apiAddress := req.ApiAddress
+	if apiAddress == "" {
+		apiAddress = config.AgentAPIAddress
+	}
+	if apiAddress == "" {
+		return nil, apierror.InvalidField("api_address",
+			"api_address is required when business.node.enrollment.agentAPIAddress is not set")
+	}
+	if _, _, err := net.SplitHostPort(apiAddress); err != nil {
+		return nil, apierror.InvalidField("api_address", "api_address must be host:port")
+	}
+
+	if len(req.NodeName) > 128 {
+		return nil, apierror.InvalidField("node_name", "node_name must be at most 128 characters")
+	}
+
+	token, err := auth.GenerateEnrollmentToken()
+	if err != nil {
+		s.logger.Error("Failed to generate enrollment token", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to generate enrollment token")
+	}
+
+	enrollment := &models.NodeEnrollment{
+		TokenHash:   auth.HashNodeToken(token),
+		NodeName:    req.NodeName,
+		Region:      req.Region,
+		Description: req.Description,
+		CreatedBy:   req.Operator,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := s.dbService.GetRepository().NodeEnrollment.Create(enrollment); err != nil {
+		s.logger.Error("Failed to create node enrollment", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create node enrollment")
+	}
+
+	s.logger.Info("Node enrollment created",
+		zap.Uint("enrollment_id", enrollment.ID),
+		zap.String("node_name", req.NodeName),
+		zap.Time("expires_at", enrollment.ExpiresAt),
+	)
+
+	return &pbv1.CreateNodeEnrollmentResponse{
+		Token:          token,
+		Info:           s.convertNodeEnrollmentToProto(enrollment),
+		InstallCommand: installCommand(config.InstallScriptURL, apiAddress, token),
+	}, nil
+}
+
+func (s *ManagementService) ListNodeEnrollments(ctx context.Context, req *pbv1.ListNodeEnrollmentsRequest) (*pbv1.ListNodeEnrollmentsResponse, error) {
+	s.logger.Debug("ListNodeEnrollments called")
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	enrollments, total, err := s.dbService.GetRepository().NodeEnrollment.List(offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list node enrollments", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list node enrollments")
+	}
+
+	resp := &pbv1.ListNodeEnrollmentsResponse{
+		Enrollments: make([]*pbv1.NodeEnrollmentInfo, len(enrollments)),
+		Total:       int32(total),
+	}
+	for i, enrollment := range enrollments {
+		resp.Enrollments[i] = s.convertNodeEnrollmentToProto(enrollment)
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) RevokeNodeEnrollment(ctx context.Context, req *pbv1.RevokeNodeEnrollmentRequest) (*pbv1.RevokeNodeEnrollmentResponse, error) {
+	s.logger.Debug("RevokeNodeEnrollment called", zap.String("enrollment_id", req.EnrollmentId))
+
+	if req.EnrollmentId == "" {
+		return nil, apierror.MissingField("enrollment_id")
+	}
+
+	// Parse enrollment ID
+	enrollmentID, err := strconv.ParseUint(req.EnrollmentId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("enrollment_id", "invalid enrollment_id format")
+	}
+
+	repo := s.dbService.GetRepository()
+	enrollment, err := repo.NodeEnrollment.GetByID(uint(enrollmentID))
+	if err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNodeEnrollment, req.EnrollmentId)
+	}
+	if enrollment.UsedAt != nil {
+		return nil, apierror.FailedPrecondition(apierror.ReasonNodeEnrollmentUsed, "node_enrollment/"+req.EnrollmentId,
+			"node enrollment was already used, revoke the tokens of the node instead")
+	}
+
+	if err := repo.NodeEnrollment.Revoke(uint(enrollmentID)); err != nil {
+		s.logger.Error("Failed to revoke node enrollment", zap.Error(err), zap.String("enrollment_id", req.EnrollmentId))
+		return nil, status.Error(codes.Internal, "failed to revoke node enrollment")
+	}
+
+	s.logger.Info("Node enrollment revoked", zap.String("enrollment_id", req.EnrollmentId))
+
+	return &pbv1.RevokeNodeEnrollmentResponse{
+		Success: true,
+		Message: "node enrollment revoked",
+	}, nil
+}
+
+// installCommand returns the shell command installing the agent on a new
+// node and enrolling it with token
+func installCommand(scriptURL, apiAddress, token string) string {
+	return fmt.Sprintf("curl -fsSL %s | sudo sh -s -- --api %s --token %s",
+		shellQuote(scriptURL), shellQuote(apiAddress), shellQuote(token))
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (s *ManagementService) convertNodeEnrollmentToProto(enrollment *models.NodeEnrollment) *pbv1.NodeEnrollmentInfo {
+	info := &pbv1.NodeEnrollmentInfo{
+		EnrollmentId: strconv.FormatUint(uint64(enrollment.ID), 10),
+		NodeName:     enrollment.NodeName,
+		Region:       enrollment.Region,
+		Description:  enrollment.Description,
+		State:        enrollment.State(time.Now()),
+		CreatedBy:    enrollment.CreatedBy,
+		CreatedAt:    timestamppb.New(enrollment.CreatedAt),
+		ExpiresAt:    timestamppb.New(enrollment.ExpiresAt),
+	}
+	if enrollment.UsedAt != nil {
+		info.UsedAt = timestamppb.New(*enrollment.UsedAt)
+	}
+	if enrollment.NodeID != nil {
+		info.NodeId = strconv.FormatUint(uint64(*enrollment.NodeID), 10)
+	}
+	if enrollment.RevokedAt != nil {
+		info.RevokedAt = timestamppb.New(*enrollment.RevokedAt)
+	}
+	return info
+}
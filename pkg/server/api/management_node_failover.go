@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Node failover methods
+
+func (s *ManagementService) ListNodeFailovers(ctx context.Context, req *pbv1.ListNodeFailoversRequest) (*pbv1.ListNodeFailoversResponse, error) {
+	s.logger.Debug("ListNodeFailovers called", zap.String("node_id", req.NodeId))
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	failovers, total, err := s.dbService.GetRepository().NodeFailover.List(node.ID, req.ActiveOnly, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list node failovers", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list node failovers")
+	}
+
+	resp := &pbv1.ListNodeFailoversResponse{
+		Failovers: make([]*pbv1.NodeFailoverInfo, len(failovers)),
+		Total:     int32(total),
+	}
+	for i, failover := range failovers {
+		resp.Failovers[i] = convertNodeFailoverToProto(failover)
+	}
+	return resp, nil
+}
+
+func convertNodeFailoverToProto(failover *models.NodeFailover) *pbv1.NodeFailoverInfo {
+	info := &pbv1.NodeFailoverInfo{
+		Id:         strconv.FormatUint(uint64(failover.ID), 10),
+		UserId:     strconv.FormatUint(uint64(failover.UserID), 10),
+		Username:   failover.User.Username,
+		FromNodeId: strconv.FormatUint(uint64(failover.FromNodeID), 10),
+		ToNodeId:   strconv.FormatUint(uint64(failover.ToNodeID), 10),
+		ToNodeName: failover.ToNode.Name,
+		Status:     string(failover.Status),
+		Reason:     failover.Reason,
+		CreatedAt:  timestamppb.New(failover.CreatedAt),
+	}
+	if failover.RevertedAt != nil {
+		info.RevertedAt = timestamppb.New(*failover.RevertedAt)
+	}
+	return info
+}
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// maxNodeGroupNameLength matches the size of the name column
+const maxNodeGroupNameLength = 64
+
+// Node group methods
+
+func (s *ManagementService) CreateNodeGroup(ctx context.Context, req *pbv1.CreateNodeGroupRequest) (*pbv1.CreateNodeGroupResponse, error) {
+	s.logger.Debug("CreateNodeGroup called", zap.String("name", req.Name))
+
+	if err := s.checkNodeGroupName(req.Name, 0); err != nil {
+		return nil, err
+	}
+	nodeIDs, err := s.parseGroupNodeIDs(req.NodeIds)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &models.NodeGroup{
+		Name:        req.Name,
+		Description: req.Description,
+		Sort:        int(req.Sort),
+	}
+
+	repo := s.dbService.GetRepository().NodeGroup
+	if err := repo.Create(group); err != nil {
+		s.logger.Error("Failed to create node group", zap.Error(err), zap.String("name", req.Name))
+		return nil, status.Error(codes.Internal, "failed to create node group")
+	}
+	if err := repo.AddNodes(group.ID, nodeIDs); err != nil {
+		s.logger.Error("Failed to add nodes to group", zap.Error(err), zap.Uint("group_id", group.ID))
+		return nil, status.Error(codes.Internal, "failed to add nodes to group")
+	}
+
+	s.logger.Info("Node group created", zap.Uint("group_id", group.ID), zap.String("name", group.Name))
+
+	info, err := s.nodeGroupInfo(group)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.CreateNodeGroupResponse{
+		Success: true,
+		Message: "node group created successfully",
+		Group:   info,
+	}, nil
+}
+
+func (s *ManagementService) UpdateNodeGroup(ctx context.Context, req *pbv1.UpdateNodeGroupRequest) (*pbv1.UpdateNodeGroupResponse, error) {
+	s.logger.Debug("UpdateNodeGroup called", zap.String("group_id", req.GroupId))
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" && req.Name != group.Name {
+		if err := s.checkNodeGroupName(req.Name, group.ID); err != nil {
+			return nil, err
+		}
+		group.Name = req.Name
+	}
+	group.Description = req.Description
+	group.Sort = int(req.Sort)
+
+	if err := s.dbService.GetRepository().NodeGroup.Update(group); err != nil {
+		s.logger.Error("Failed to update node group", zap.Error(err), zap.String("group_id", req.GroupId))
+		return nil, status.Error(codes.Internal, "failed to update node group")
+	}
+
+	info, err := s.nodeGroupInfo(group)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.UpdateNodeGroupResponse{
+		Success: true,
+		Message: "node group updated successfully",
+		Group:   info,
+	}, nil
+}
+
+func (s *ManagementService) DeleteNodeGroup(ctx context.Context, req *pbv1.DeleteNodeGroupRequest) (*pbv1.DeleteNodeGroupResponse, error) {
+	s.logger.Debug("DeleteNodeGroup called", zap.String("group_id", req.GroupId))
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Memberships and plan grants go with the group; the nodes themselves stay
+	if err := s.dbService.GetRepository().NodeGroup.Delete(group.ID); err != nil {
+		s.logger.Error("Failed to delete node group", zap.Error(err), zap.String("group_id", req.GroupId))
+		return nil, status.Error(codes.Internal, "failed to delete node group")
+	}
+
+	s.logger.Info("Node group deleted", zap.Uint("group_id", group.ID), zap.String("name", group.Name))
+
+	return &pbv1.DeleteNodeGroupResponse{
+		Success: true,
+		Message: "node group deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) GetNodeGroup(ctx context.Context, req *pbv1.GetNodeGroupRequest) (*pbv1.GetNodeGroupResponse, error) {
+	s.logger.Debug("GetNodeGroup called", zap.String("group_id", req.GroupId))
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.nodeGroupInfo(group)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.GetNodeGroupResponse{Group: info}, nil
+}
+
+func (s *ManagementService) ListNodeGroups(ctx context.Context, req *pbv1.ListNodeGroupsRequest) (*pbv1.ListNodeGroupsResponse, error) {
+	s.logger.Debug("ListNodeGroups called",
+		zap.Int32("page", req.Page),
+		zap.Int32("page_size", req.PageSize),
+	)
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	repo := s.dbService.GetRepository().NodeGroup
+	var groups []*models.NodeGroup
+	var total int64
+	var err error
+
+	switch {
+	case req.NodeId != "":
+		nodeID, parseErr := strconv.ParseUint(req.NodeId, 10, 32)
+		if parseErr != nil {
+			return nil, apierror.InvalidField("node_id", "invalid node_id format")
+		}
+		groups, err = repo.ListByNode(uint(nodeID))
+		total = int64(len(groups))
+	case req.PlanId != "":
+		planID, parseErr := strconv.ParseUint(req.PlanId, 10, 32)
+		if parseErr != nil {
+			return nil, apierror.InvalidField("plan_id", "invalid plan_id format")
+		}
+		groups, err = repo.ListByPlan(uint(planID))
+		total = int64(len(groups))
+	default:
+		groups, total, err = repo.List(int(offset), int(pageSize))
+	}
+	if err != nil {
+		s.logger.Error("Failed to list node groups", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list node groups")
+	}
+
+	pbGroups := make([]*pbv1.NodeGroupInfo, len(groups))
+	for i, group := range groups {
+		info, err := s.nodeGroupInfo(group)
+		if err != nil {
+			return nil, err
+		}
+		pbGroups[i] = info
+	}
+
+	return &pbv1.ListNodeGroupsResponse{
+		Groups:   pbGroups,
+		Total:    int32(total),
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *ManagementService) AddNodesToGroup(ctx context.Context, req *pbv1.AddNodesToGroupRequest) (*pbv1.AddNodesToGroupResponse, error) {
+	s.logger.Debug("AddNodesToGroup called",
+		zap.String("group_id", req.GroupId),
+		zap.Int("node_count", len(req.NodeIds)),
+	)
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.NodeIds) == 0 {
+		return nil, apierror.MissingField("node_ids")
+	}
+	nodeIDs, err := s.parseGroupNodeIDs(req.NodeIds)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().NodeGroup.AddNodes(group.ID, nodeIDs); err != nil {
+		s.logger.Error("Failed to add nodes to group", zap.Error(err), zap.String("group_id", req.GroupId))
+		return nil, status.Error(codes.Internal, "failed to add nodes to group")
+	}
+
+	info, err := s.nodeGroupInfo(group)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.AddNodesToGroupResponse{
+		Success: true,
+		Message: "nodes added to group successfully",
+		Group:   info,
+	}, nil
+}
+
+func (s *ManagementService) RemoveNodesFromGroup(ctx context.Context, req *pbv1.RemoveNodesFromGroupRequest) (*pbv1.RemoveNodesFromGroupResponse, error) {
+	s.logger.Debug("RemoveNodesFromGroup called",
+		zap.String("group_id", req.GroupId),
+		zap.Int("node_count", len(req.NodeIds)),
+	)
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.NodeIds) == 0 {
+		return nil, apierror.MissingField("node_ids")
+	}
+
+	nodeIDs := make([]uint, len(req.NodeIds))
+	for i, nodeID := range req.NodeIds {
+		id, err := strconv.ParseUint(nodeID, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("node_ids", "invalid node ID format: "+nodeID)
+		}
+		nodeIDs[i] = uint(id)
+	}
+
+	if err := s.dbService.GetRepository().NodeGroup.RemoveNodes(group.ID, nodeIDs); err != nil {
+		s.logger.Error("Failed to remove nodes from group", zap.Error(err), zap.String("group_id", req.GroupId))
+		return nil, status.Error(codes.Internal, "failed to remove nodes from group")
+	}
+
+	info, err := s.nodeGroupInfo(group)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.RemoveNodesFromGroupResponse{
+		Success: true,
+		Message: "nodes removed from group successfully",
+		Group:   info,
+	}, nil
+}
+
+func (s *ManagementService) SetNodeGroupEnabled(ctx context.Context, req *pbv1.SetNodeGroupEnabledRequest) (*pbv1.SetNodeGroupEnabledResponse, error) {
+	s.logger.Debug("SetNodeGroupEnabled called",
+		zap.String("group_id", req.GroupId),
+		zap.Bool("enabled", req.Enabled),
+	)
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := s.dbService.GetRepository()
+	nodeIDs, err := repo.NodeGroup.GetNodeIDs(group.ID)
+	if err != nil {
+		s.logger.Error("Failed to get group nodes", zap.Error(err), zap.String("group_id", req.GroupId))
+		return nil, status.Error(codes.Internal, "failed to get group nodes")
+	}
+
+	if len(nodeIDs) > 0 {
+		if req.Enabled {
+			err = repo.Node.BatchEnable(nodeIDs)
+		} else {
+			err = repo.Node.BatchDisable(nodeIDs)
+		}
+		if err != nil {
+			s.logger.Error("Failed to update group nodes", zap.Error(err), zap.String("group_id", req.GroupId))
+			return nil, status.Error(codes.Internal, "failed to update group nodes")
+		}
+	}
+
+	s.logger.Info("Node group enabled state changed",
+		zap.Uint("group_id", group.ID),
+		zap.Bool("enabled", req.Enabled),
+		zap.Int("affected_nodes", len(nodeIDs)),
+	)
+
+	return &pbv1.SetNodeGroupEnabledResponse{
+		Success:       true,
+		Message:       "node group updated successfully",
+		AffectedNodes: int32(len(nodeIDs)),
+	}, nil
+}
+
+func (s *ManagementService) AssignNodeGroupToPlan(ctx context.Context, req *pbv1.AssignNodeGroupToPlanRequest) (*pbv1.AssignNodeGroupToPlanResponse, error) {
+	s.logger.Debug("AssignNodeGroupToPlan called",
+		zap.String("group_id", req.GroupId),
+		zap.String("plan_id", req.PlanId),
+	)
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	planID, err := s.getGroupPlanID(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &models.PlanGroupAccess{
+		PlanID:    planID,
+		GroupID:   group.ID,
+		IsEnabled: true,
+		Priority:  int(req.Priority),
+	}
+	if err := s.dbService.GetRepository().NodeGroup.AssignToPlan(access); err != nil {
+		s.logger.Error("Failed to assign node group to plan", zap.Error(err),
+			zap.String("group_id", req.GroupId), zap.String("plan_id", req.PlanId))
+		return nil, status.Error(codes.Internal, "failed to assign node group to plan")
+	}
+
+	s.logger.Info("Node group assigned to plan", zap.Uint("group_id", group.ID), zap.Uint("plan_id", planID))
+
+	return &pbv1.AssignNodeGroupToPlanResponse{
+		Success: true,
+		Message: "node group assigned to plan successfully",
+	}, nil
+}
+
+func (s *ManagementService) UnassignNodeGroupFromPlan(ctx context.Context, req *pbv1.UnassignNodeGroupFromPlanRequest) (*pbv1.UnassignNodeGroupFromPlanResponse, error) {
+	s.logger.Debug("UnassignNodeGroupFromPlan called",
+		zap.String("group_id", req.GroupId),
+		zap.String("plan_id", req.PlanId),
+	)
+
+	group, err := s.getNodeGroup(req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	planID, err := s.getGroupPlanID(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().NodeGroup.UnassignFromPlan(planID, group.ID); err != nil {
+		s.logger.Error("Failed to unassign node group from plan", zap.Error(err),
+			zap.String("group_id", req.GroupId), zap.String("plan_id", req.PlanId))
+		return nil, status.Error(codes.Internal, "failed to unassign node group from plan")
+	}
+
+	s.logger.Info("Node group unassigned from plan", zap.Uint("group_id", group.ID), zap.Uint("plan_id", planID))
+
+	return &pbv1.UnassignNodeGroupFromPlanResponse{
+		Success: true,
+		Message: "node group unassigned from plan successfully",
+	}, nil
+}
+
+// getNodeGroup parses the group ID and loads the group
+func (s *ManagementService) getNodeGroup(groupID string) (*models.NodeGroup, error) {
+	if groupID == "" {
+		return nil, apierror.MissingField("group_id")
+	}
+	id, err := strconv.ParseUint(groupID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("group_id", "invalid group_id format")
+	}
+
+	group, err := s.dbService.GetRepository().NodeGroup.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceNodeGroup, groupID)
+		}
+		s.logger.Error("Failed to get node group", zap.Error(err), zap.String("group_id", groupID))
+		return nil, status.Error(codes.Internal, "failed to get node group")
+	}
+	return group, nil
+}
+
+// getGroupPlanID parses the plan ID and checks that the plan exists
+func (s *ManagementService) getGroupPlanID(planID string) (uint, error) {
+	if planID == "" {
+		return 0, apierror.MissingField("plan_id")
+	}
+	id, err := strconv.ParseUint(planID, 10, 32)
+	if err != nil {
+		return 0, apierror.InvalidField("plan_id", "invalid plan_id format")
+	}
+	if _, err := s.dbService.GetRepository().Plan.GetByID(uint(id)); err != nil {
+		return 0, apierror.NotFound(apierror.ResourcePlan, planID)
+	}
+	return uint(id), nil
+}
+
+// checkNodeGroupName validates a group name and checks that no other group uses it
+func (s *ManagementService) checkNodeGroupName(name string, excludeID uint) error {
+	if name == "" {
+		return apierror.MissingField("name")
+	}
+	if len(name) > maxNodeGroupNameLength {
+		return apierror.InvalidField("name", "name is too long")
+	}
+
+	existing, err := s.dbService.GetRepository().NodeGroup.GetByName(name)
+	if err == nil && existing.ID != excludeID {
+		return apierror.AlreadyExists(apierror.ResourceNodeGroup, apierror.ReasonNodeGroupNameTaken,
+			"node group name already exists", map[string]string{"name": name})
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to check node group name", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check node group name")
+	}
+	return nil
+}
+
+// parseGroupNodeIDs parses node IDs and checks that every node exists
+func (s *ManagementService) parseGroupNodeIDs(ids []string) ([]uint, error) {
+	nodeIDs := make([]uint, len(ids))
+	for i, nodeID := range ids {
+		id, err := strconv.ParseUint(nodeID, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("node_ids", "invalid node ID format: "+nodeID)
+		}
+		if _, err := s.dbService.GetRepository().Node.GetByID(uint(id)); err != nil {
+			return nil, apierror.NotFound(apierror.ResourceNode, nodeID)
+		}
+		nodeIDs[i] = uint(id)
+	}
+	return nodeIDs, nil
+}
+
+// nodeGroupInfo converts a node group with its members and plan grants to protobuf
+func (s *ManagementService) nodeGroupInfo(group *models.NodeGroup) (*pbv1.NodeGroupInfo, error) {
+	repo := s.dbService.GetRepository().NodeGroup
+	nodeIDs, err := repo.GetNodeIDs(group.ID)
+	if err != nil {
+		s.logger.Error("Failed to get group nodes", zap.Error(err), zap.Uint("group_id", group.ID))
+		return nil, status.Error(codes.Internal, "failed to get group nodes")
+	}
+	planIDs, err := repo.GetPlanIDs(group.ID)
+	if err != nil {
+		s.logger.Error("Failed to get group plans", zap.Error(err), zap.Uint("group_id", group.ID))
+		return nil, status.Error(codes.Internal, "failed to get group plans")
+	}
+
+	info := &pbv1.NodeGroupInfo{
+		Id:          strconv.FormatUint(uint64(group.ID), 10),
+		Name:        group.Name,
+		Description: group.Description,
+		Sort:        int32(group.Sort),
+		NodeIds:     make([]string, len(nodeIDs)),
+		PlanIds:     make([]string, len(planIDs)),
+		CreatedAt:   timestamppb.New(group.CreatedAt),
+		UpdatedAt:   timestamppb.New(group.UpdatedAt),
+	}
+	for i, id := range nodeIDs {
+		info.NodeIds[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	for i, id := range planIDs {
+		info.PlanIds[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return info, nil
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// nodeStatsByGroup aggregates nodes per node group
+	nodeStatsByGroup = "group"
+	// nodeStatsByRegion aggregates nodes per region
+	nodeStatsByRegion = "region"
+)
+
+// nodeStatsEntry is one row of the node group stats
+type nodeStatsEntry struct {
+	id        string
+	name      string
+	aggregate models.NodeAggregate
+}
+
+func (s *ManagementService) GetNodeGroupStats(ctx context.Context, req *pbv1.GetNodeGroupStatsRequest) (*pbv1.GetNodeGroupStatsResponse, error) {
+	s.logger.Debug("GetNodeGroupStats called", zap.String("group_by", req.GroupBy))
+
+	groupBy := req.GroupBy
+	if groupBy == "" {
+		groupBy = nodeStatsByGroup
+	}
+	if groupBy != nodeStatsByGroup && groupBy != nodeStatsByRegion {
+		return nil, apierror.InvalidField("group_by", "group_by must be group or region")
+	}
+
+	// Traffic is summed from daily summaries, the period covers whole days
+	start := time.Now().Truncate(24 * time.Hour)
+	if req.StartTime != nil {
+		start = req.StartTime.AsTime().Truncate(24 * time.Hour)
+	}
+	end := start.AddDate(0, 0, 1)
+	if req.EndTime != nil {
+		end = req.EndTime.AsTime().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	}
+	if !end.After(start) {
+		return nil, apierror.InvalidField("end_time", "end_time cannot be before start_time")
+	}
+
+	repo := s.dbService.GetRepository()
+	nodes, _, err := repo.Node.List(0, -1)
+	if err != nil {
+		s.logger.Error("Failed to list nodes", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get node group stats")
+	}
+	usage, err := repo.NodeCost.GetNodeUsage(start, end)
+	if err != nil {
+		s.logger.Error("Failed to get node usage", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get node group stats")
+	}
+
+	var entries []*nodeStatsEntry
+	if groupBy == nodeStatsByRegion {
+		entries = aggregateNodesByRegion(nodes, usage)
+	} else if entries, err = s.aggregateNodesByGroup(nodes, usage); err != nil {
+		return nil, err
+	}
+
+	resp := &pbv1.GetNodeGroupStatsResponse{
+		Groups:  make([]*pbv1.NodeGroupStats, len(entries)),
+		GroupBy: groupBy,
+	}
+	for i, entry := range entries {
+		resp.Groups[i] = convertNodeStatsEntryToProto(entry)
+	}
+	return resp, nil
+}
+
+// aggregateNodesByGroup aggregates nodes per group in group order, a node in
+// several groups counting in each. Nodes in no group come last.
+func (s *ManagementService) aggregateNodesByGroup(nodes []*models.Node, usage map[uint]*models.NodeUsage) ([]*nodeStatsEntry, error) {
+	repo := s.dbService.GetRepository().NodeGroup
+	groups, _, err := repo.List(0, -1)
+	if err != nil {
+		s.logger.Error("Failed to list node groups", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get node group stats")
+	}
+	members, err := repo.ListMembers()
+	if err != nil {
+		s.logger.Error("Failed to list node group members", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get node group stats")
+	}
+
+	entries := make([]*nodeStatsEntry, len(groups))
+	byGroup := make(map[uint]*nodeStatsEntry, len(groups))
+	for i, group := range groups {
+		entries[i] = &nodeStatsEntry{id: strconv.FormatUint(uint64(group.ID), 10), name: group.Name}
+		byGroup[group.ID] = entries[i]
+	}
+
+	byID := make(map[uint]*models.Node, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+	}
+	grouped := make(map[uint]bool, len(members))
+	for _, member := range members {
+		entry, node := byGroup[member.GroupID], byID[member.NodeID]
+		if entry == nil || node == nil {
+			continue
+		}
+		entry.aggregate.Add(node, usage[node.ID])
+		grouped[node.ID] = true
+	}
+
+	ungrouped := &nodeStatsEntry{}
+	for _, node := range nodes {
+		if !grouped[node.ID] {
+			ungrouped.aggregate.Add(node, usage[node.ID])
+		}
+	}
+	if ungrouped.aggregate.TotalNodes > 0 {
+		entries = append(entries, ungrouped)
+	}
+	return entries, nil
+}
+
+// aggregateNodesByRegion aggregates nodes per region by name, nodes without a
+// region last
+func aggregateNodesByRegion(nodes []*models.Node, usage map[uint]*models.NodeUsage) []*nodeStatsEntry {
+	byRegion := make(map[string]*nodeStatsEntry)
+	var entries []*nodeStatsEntry
+	for _, node := range nodes {
+		entry, ok := byRegion[node.Region]
+		if !ok {
+			entry = &nodeStatsEntry{id: node.Region, name: node.Region}
+			byRegion[node.Region] = entry
+			entries = append(entries, entry)
+		}
+		entry.aggregate.Add(node, usage[node.ID])
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if (entries[i].id == "") != (entries[j].id == "") {
+			return entries[j].id == ""
+		}
+		return entries[i].id < entries[j].id
+	})
+	return entries
+}
+
+func convertNodeStatsEntryToProto(entry *nodeStatsEntry) *pbv1.NodeGroupStats {
+	aggregate := &entry.aggregate
+	return &pbv1.NodeGroupStats{
+		Id:             entry.id,
+		Name:           entry.name,
+		TotalNodes:     int32(aggregate.TotalNodes),
+		EnabledNodes:   int32(aggregate.EnabledNodes),
+		OnlineNodes:    int32(aggregate.OnlineNodes),
+		DegradedNodes:  int32(aggregate.DegradedNodes),
+		Availability:   aggregate.Availability(),
+		OnlineUsers:    aggregate.OnlineUsers,
+		NetworkInRate:  aggregate.NetworkInRate,
+		NetworkOutRate: aggregate.NetworkOutRate,
+		Traffic:        aggregate.Traffic,
+		AvgCpuUsage:    aggregate.AvgCPUUsage(),
+		AvgMemoryUsage: aggregate.AvgMemoryUsage(),
+		AvgLoad:        aggregate.AvgLoad(),
+	}
+}
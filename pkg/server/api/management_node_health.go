@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Node status transition methods
+
+func (s *ManagementService) ListNodeStatusTransitions(ctx context.Context, req *pbv1.ListNodeStatusTransitionsRequest) (*pbv1.ListNodeStatusTransitionsResponse, error) {
+	s.logger.Debug("ListNodeStatusTransitions called", zap.String("node_id", req.NodeId))
+
+	node, err := s.getConfigNode(req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	transitions, total, err := s.dbService.GetRepository().Node.ListStatusTransitions(node.ID, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list node status transitions", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list node status transitions")
+	}
+
+	resp := &pbv1.ListNodeStatusTransitionsResponse{
+		Transitions: make([]*pbv1.NodeStatusTransitionInfo, len(transitions)),
+		Total:       int32(total),
+	}
+	for i, transition := range transitions {
+		resp.Transitions[i] = convertNodeStatusTransitionToProto(transition)
+	}
+	return resp, nil
+}
+
+func convertNodeStatusTransitionToProto(transition *models.NodeStatusTransition) *pbv1.NodeStatusTransitionInfo {
+	return &pbv1.NodeStatusTransitionInfo{
+		From:        string(transition.From),
+		To:          string(transition.To),
+		Reason:      transition.Reason,
+		HealthScore: transition.HealthScore,
+		Automatic:   transition.Automatic,
+		CreatedAt:   timestamppb.New(transition.CreatedAt),
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/apierror"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// defaultNodeLogLines and maxNodeLogLines bound the lines of a fetch
+	defaultNodeLogLines = 200
+	maxNodeLogLines     = 5000
+	// defaultNodeLogTimeout and maxNodeLogTimeout bound the wait for the
+	// upload, nodes take the command with their next heartbeat
+	defaultNodeLogTimeout = 45 * time.Second
+	maxNodeLogTimeout     = 2 * time.Minute
+)
+
+func (s *ManagementService) FetchNodeLogs(ctx context.Context, req *pbv1.FetchNodeLogsRequest) (*pbv1.FetchNodeLogsResponse, error) {
+	s.logger.Debug("FetchNodeLogs called",
+		zap.String("node_id", req.NodeId),
+		zap.String("source", req.Source),
+		zap.Int32("lines", req.Lines),
+	)
+
+	if err := s.requireActiveAgents("node logs"); err != nil {
+		return nil, err
+	}
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	source := req.Source
+	if source == "" {
+		source = "sing-box"
+	}
+	if source != "sing-box" && source != "agent" {
+		return nil, apierror.InvalidField("source", "source must be sing-box or agent")
+	}
+	lines := req.Lines
+	if lines == 0 {
+		lines = defaultNodeLogLines
+	}
+	if lines < 0 || lines > maxNodeLogLines {
+		return nil, apierror.InvalidField("lines", fmt.Sprintf("lines must be between 1 and %d", maxNodeLogLines))
+	}
+	timeout := time.Duration(req.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = defaultNodeLogTimeout
+	}
+	if timeout < 0 || timeout > maxNodeLogTimeout {
+		return nil, apierror.InvalidField("timeout_seconds", fmt.Sprintf("timeout_seconds must be between 1 and %d", int(maxNodeLogTimeout.Seconds())))
+	}
+
+	parameters := map[string]string{
+		"source": source,
+		"lines":  strconv.Itoa(int(lines)),
+	}
+	if req.Since != nil {
+		parameters["since"] = req.Since.AsTime().Format(time.RFC3339)
+	}
+	if req.Until != nil {
+		parameters["until"] = req.Until.AsTime().Format(time.RFC3339)
+	}
+	if req.Since != nil && req.Until != nil && !req.Until.AsTime().After(req.Since.AsTime()) {
+		return nil, apierror.InvalidField("until", "until must be after since")
+	}
+
+	logs, err := s.agents.fetchNodeLogs(ctx, req.NodeId, parameters, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.FetchNodeLogsResponse{
+		NodeId:    req.NodeId,
+		Source:    source,
+		Lines:     logs.lines,
+		Truncated: logs.truncated,
+	}, nil
+}
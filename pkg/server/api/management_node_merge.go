@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// Node history merge methods
+
+func (s *ManagementService) MergeNodeHistory(ctx context.Context, req *pbv1.MergeNodeHistoryRequest) (*pbv1.MergeNodeHistoryResponse, error) {
+	s.logger.Debug("MergeNodeHistory called",
+		zap.String("source_node_id", req.SourceNodeId),
+		zap.String("target_node_id", req.TargetNodeId),
+	)
+
+	if req.SourceNodeId == "" {
+		return nil, apierror.MissingField("source_node_id")
+	}
+	if req.TargetNodeId == "" {
+		return nil, apierror.MissingField("target_node_id")
+	}
+
+	sourceID, err := strconv.ParseUint(req.SourceNodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("source_node_id", "invalid source_node_id format")
+	}
+	targetID, err := strconv.ParseUint(req.TargetNodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("target_node_id", "invalid target_node_id format")
+	}
+	if sourceID == targetID {
+		return nil, apierror.InvalidField("target_node_id", "source and target node must differ")
+	}
+
+	repo := s.dbService.GetRepository()
+
+	// Only the history of a removed node can be merged, active nodes keep their own
+	source, err := repo.Node.GetByIDUnscoped(uint(sourceID))
+	if err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNode, req.SourceNodeId)
+	}
+	if !source.DeletedAt.Valid {
+		return nil, apierror.FailedPrecondition(apierror.ReasonNodeNotRemoved, "node/"+req.SourceNodeId,
+			"source node must be removed before merging its history")
+	}
+	if _, err := repo.Node.GetByID(uint(targetID)); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNode, req.TargetNodeId)
+	}
+
+	result, err := repo.Node.MergeHistory(uint(sourceID), uint(targetID))
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNodeAlreadyMerged):
+			return nil, apierror.FailedPrecondition(apierror.ReasonNodeAlreadyMerged, "node/"+req.SourceNodeId,
+				"source node history was already merged")
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, apierror.NotFound(apierror.ResourceNode, req.TargetNodeId)
+		}
+		s.logger.Error("Failed to merge node history", zap.Error(err),
+			zap.String("source_node_id", req.SourceNodeId),
+			zap.String("target_node_id", req.TargetNodeId),
+		)
+		return nil, status.Error(codes.Internal, "failed to merge node history")
+	}
+
+	s.logger.Info("node history merged",
+		zap.String("source_node_id", req.SourceNodeId),
+		zap.String("target_node_id", req.TargetNodeId),
+		zap.Int64("traffic_records", result.TrafficRecords),
+		zap.Int64("user_nodes", result.UserNodes),
+	)
+
+	return &pbv1.MergeNodeHistoryResponse{
+		Success:          true,
+		Message:          "node history merged successfully",
+		TrafficRecords:   result.TrafficRecords,
+		TrafficSummaries: result.TrafficSummaries,
+		NodeLogs:         result.NodeLogs,
+		NodeProbes:       result.NodeProbes,
+		UserNodes:        result.UserNodes,
+		PlanNodeAccesses: result.PlanNodeAccesses,
+		NodeGroupMembers: result.NodeGroupMembers,
+	}, nil
+}
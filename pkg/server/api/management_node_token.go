@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Node registration token methods
+
+func (s *ManagementService) CreateNodeToken(ctx context.Context, req *pbv1.CreateNodeTokenRequest) (*pbv1.CreateNodeTokenResponse, error) {
+	s.logger.Debug("CreateNodeToken called", zap.String("node_id", req.NodeId))
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	if req.TtlSeconds < 0 {
+		return nil, apierror.InvalidField("ttl_seconds", "ttl_seconds cannot be negative")
+	}
+
+	// Parse node ID
+	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+
+	// Tokens can only be issued for nodes provisioned by an admin
+	if _, err := s.dbService.GetRepository().Node.GetByID(uint(nodeID)); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
+	}
+
+	token, err := auth.GenerateNodeToken()
+	if err != nil {
+		s.logger.Error("Failed to generate node token", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to generate node token")
+	}
+
+	nodeToken := &models.NodeToken{
+		NodeID:      uint(nodeID),
+		TokenHash:   auth.HashNodeToken(token),
+		Description: req.Description,
+	}
+	if req.TtlSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.TtlSeconds) * time.Second)
+		nodeToken.ExpiresAt = &expiresAt
+	}
+
+	if err := s.dbService.GetRepository().NodeToken.Create(nodeToken); err != nil {
+		s.logger.Error("Failed to create node token", zap.Error(err), zap.String("node_id", req.NodeId))
+		return nil, status.Error(codes.Internal, "failed to create node token")
+	}
+
+	s.logger.Info("Node token created", zap.String("node_id", req.NodeId), zap.Uint("token_id", nodeToken.ID))
+
+	return &pbv1.CreateNodeTokenResponse{
+		Token: token,
+		Info:  s.convertNodeTokenToProto(nodeToken),
+	}, nil
+}
+
+func (s *ManagementService) ListNodeTokens(ctx context.Context, req *pbv1.ListNodeTokensRequest) (*pbv1.ListNodeTokensResponse, error) {
+	s.logger.Debug("ListNodeTokens called", zap.String("node_id", req.NodeId))
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+
+	// Parse node ID
+	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+
+	tokens, err := s.dbService.GetRepository().NodeToken.ListByNode(uint(nodeID))
+	if err != nil {
+		s.logger.Error("Failed to list node tokens", zap.Error(err), zap.String("node_id", req.NodeId))
+		return nil, status.Error(codes.Internal, "failed to list node tokens")
+	}
+
+	pbTokens := make([]*pbv1.NodeTokenInfo, len(tokens))
+	for i, token := range tokens {
+		pbTokens[i] = s.convertNodeTokenToProto(token)
+	}
+
+	return &pbv1.ListNodeTokensResponse{
+		Tokens: pbTokens,
+	}, nil
+}
+
+func (s *ManagementService) RevokeNodeToken(ctx context.Context, req *pbv1.RevokeNodeTokenRequest) (*pbv1.RevokeNodeTokenResponse, error) {
+	s.logger.Debug("RevokeNodeToken called", zap.String("token_id", req.TokenId))
+
+	if req.TokenId == "" {
+		return nil, apierror.MissingField("token_id")
+	}
+
+	// Parse token ID
+	tokenID, err := strconv.ParseUint(req.TokenId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("token_id", "invalid token_id format")
+	}
+
+	if _, err := s.dbService.GetRepository().NodeToken.GetByID(uint(tokenID)); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNodeToken, req.TokenId)
+	}
+
+	if err := s.dbService.GetRepository().NodeToken.Revoke(uint(tokenID)); err != nil {
+		s.logger.Error("Failed to revoke node token", zap.Error(err), zap.String("token_id", req.TokenId))
+		return nil, status.Error(codes.Internal, "failed to revoke node token")
+	}
+
+	s.logger.Info("Node token revoked", zap.String("token_id", req.TokenId))
+
+	return &pbv1.RevokeNodeTokenResponse{
+		Success: true,
+		Message: "node token revoked",
+	}, nil
+}
+
+func (s *ManagementService) convertNodeTokenToProto(token *models.NodeToken) *pbv1.NodeTokenInfo {
+	info := &pbv1.NodeTokenInfo{
+		TokenId:     strconv.FormatUint(uint64(token.ID), 10),
+		NodeId:      strconv.FormatUint(uint64(token.NodeID), 10),
+		Description: token.Description,
+		Valid:       token.IsValid(),
+		CreatedAt:   timestamppb.New(token.CreatedAt),
+	}
+	if token.ExpiresAt != nil {
+		info.ExpiresAt = timestamppb.New(*token.ExpiresAt)
+	}
+	if token.LastUsedAt != nil {
+		info.LastUsedAt = timestamppb.New(*token.LastUsedAt)
+	}
+	if token.RevokedAt != nil {
+		info.RevokedAt = timestamppb.New(*token.RevokedAt)
+	}
+	return info
+}
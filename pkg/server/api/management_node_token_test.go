@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/auth"
+	configv1 "sing-box-web/pkg/config/v1"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+func TestCreateNodeToken(t *testing.T) {
+	dbService := newTestDatabase(t)
+	management := NewManagementService(*configv1.DefaultAPIConfig(), dbService, zap.NewNop())
+	nodeID := createTestNode(t, dbService, "hk-1")
+
+	tests := []struct {
+		name     string
+		req      *pbv1.CreateNodeTokenRequest
+		wantCode codes.Code
+	}{
+		{"missing node", &pbv1.CreateNodeTokenRequest{}, codes.InvalidArgument},
+		{"malformed node", &pbv1.CreateNodeTokenRequest{NodeId: "hk-1"}, codes.InvalidArgument},
+		{"unknown node", &pbv1.CreateNodeTokenRequest{NodeId: "999"}, codes.NotFound},
+		{"negative lifetime", &pbv1.CreateNodeTokenRequest{NodeId: nodeID, TtlSeconds: -1}, codes.InvalidArgument},
+		{"without expiry", &pbv1.CreateNodeTokenRequest{NodeId: nodeID}, codes.OK},
+		{"with expiry", &pbv1.CreateNodeTokenRequest{NodeId: nodeID, TtlSeconds: 3600}, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := management.CreateNodeToken(context.Background(), tt.req)
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v (%v), want %v", code, err, tt.wantCode)
+			}
+			if err != nil {
+				return
+			}
+			if resp.Info.NodeId != nodeID || !resp.Info.Valid || (resp.Info.ExpiresAt != nil) != (tt.req.TtlSeconds > 0) {
+				t.Errorf("token info = %+v", resp.Info)
+			}
+
+			// Only the hash of the token is stored
+			stored, err := dbService.GetRepository().NodeToken.GetByHash(auth.HashNodeToken(resp.Token))
+			if err != nil {
+				t.Fatalf("token not found by its hash: %v", err)
+			}
+			if stored.TokenHash == resp.Token {
+				t.Error("token stored in clear")
+			}
+		})
+	}
+}
+
+func TestRevokeNodeToken(t *testing.T) {
+	dbService := newTestDatabase(t)
+	management := NewManagementService(*configv1.DefaultAPIConfig(), dbService, zap.NewNop())
+	nodeID := createTestNode(t, dbService, "hk-1")
+	ctx := context.Background()
+
+	created, err := management.CreateNodeToken(ctx, &pbv1.CreateNodeTokenRequest{NodeId: nodeID})
+	if err != nil {
+		t.Fatalf("CreateNodeToken: %v", err)
+	}
+	if _, err := management.RevokeNodeToken(ctx, &pbv1.RevokeNodeTokenRequest{TokenId: "999"}); status.Code(err) != codes.NotFound {
+		t.Errorf("revoke unknown token = %v, want NotFound", err)
+	}
+	if _, err := management.RevokeNodeToken(ctx, &pbv1.RevokeNodeTokenRequest{TokenId: created.Info.TokenId}); err != nil {
+		t.Fatalf("RevokeNodeToken: %v", err)
+	}
+
+	list, err := management.ListNodeTokens(ctx, &pbv1.ListNodeTokensRequest{NodeId: nodeID})
+	if err != nil {
+		t.Fatalf("ListNodeTokens: %v", err)
+	}
+	if len(list.Tokens) != 1 || list.Tokens[0].Valid || list.Tokens[0].RevokedAt == nil {
+		t.Errorf("tokens after revoking = %+v, want one revoked token", list.Tokens)
+	}
+}
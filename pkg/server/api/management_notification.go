@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Notification center methods
+
+func (s *ManagementService) ListUserNotifications(ctx context.Context, req *pbv1.ListUserNotificationsRequest) (*pbv1.ListUserNotificationsResponse, error) {
+	s.logger.Debug("ListUserNotifications called", zap.String("user_id", req.UserId), zap.Bool("unread_only", req.UnreadOnly))
+
+	userID, err := parseNotificationUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	repo := s.dbService.GetRepository().Notification
+	notifications, total, err := repo.List(userID, req.UnreadOnly, int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list notifications", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to list notifications")
+	}
+	unread, err := repo.CountUnread(userID)
+	if err != nil {
+		s.logger.Error("Failed to count unread notifications", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to list notifications")
+	}
+
+	infos := make([]*pbv1.NotificationInfo, len(notifications))
+	for i, notification := range notifications {
+		infos[i] = convertNotificationToProto(notification)
+	}
+
+	return &pbv1.ListUserNotificationsResponse{
+		Notifications: infos,
+		Total:         int32(total),
+		UnreadCount:   int32(unread),
+		Page:          page,
+		PageSize:      pageSize,
+	}, nil
+}
+
+func (s *ManagementService) GetUnreadNotificationCount(ctx context.Context, req *pbv1.GetUnreadNotificationCountRequest) (*pbv1.GetUnreadNotificationCountResponse, error) {
+	s.logger.Debug("GetUnreadNotificationCount called", zap.String("user_id", req.UserId))
+
+	userID, err := parseNotificationUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	unread, err := s.dbService.GetRepository().Notification.CountUnread(userID)
+	if err != nil {
+		s.logger.Error("Failed to count unread notifications", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to count unread notifications")
+	}
+
+	return &pbv1.GetUnreadNotificationCountResponse{UnreadCount: int32(unread)}, nil
+}
+
+func (s *ManagementService) MarkNotificationsRead(ctx context.Context, req *pbv1.MarkNotificationsReadRequest) (*pbv1.MarkNotificationsReadResponse, error) {
+	s.logger.Debug("MarkNotificationsRead called",
+		zap.String("user_id", req.UserId),
+		zap.Strings("notification_ids", req.NotificationIds),
+	)
+
+	userID, err := parseNotificationUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(req.NotificationIds))
+	for _, notificationID := range req.NotificationIds {
+		id, err := strconv.ParseUint(notificationID, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("notification_ids", "invalid notification_id format")
+		}
+		ids = append(ids, uint(id))
+	}
+
+	marked, err := s.dbService.GetRepository().Notification.MarkRead(userID, ids)
+	if err != nil {
+		s.logger.Error("Failed to mark notifications read", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to mark notifications read")
+	}
+
+	return &pbv1.MarkNotificationsReadResponse{
+		Success: true,
+		Message: "notifications marked as read",
+		Marked:  int32(marked),
+	}, nil
+}
+
+func (s *ManagementService) SendNotification(ctx context.Context, req *pbv1.SendNotificationRequest) (*pbv1.SendNotificationResponse, error) {
+	s.logger.Debug("SendNotification called", zap.String("user_id", req.UserId), zap.String("type", req.Type))
+
+	userID, err := parseNotificationUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.dbService.GetRepository().User.GetByID(userID); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+
+	notification := &models.Notification{
+		UserID:   userID,
+		Type:     models.NotificationType(req.Type),
+		Severity: req.Severity,
+		Title:    req.Title,
+		Message:  req.Message,
+	}
+	if notification.Type == "" {
+		notification.Type = models.NotificationTypeSystem
+	}
+	if notification.Severity == "" {
+		notification.Severity = models.SeverityInfo
+	}
+	if err := validationError(notification.Validate(), ""); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.dbService.GetRepository().Notification.Create(notification); err != nil {
+		s.logger.Error("Failed to send notification", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to send notification")
+	}
+
+	s.logger.Info("Notification sent",
+		zap.Uint("notification_id", notification.ID),
+		zap.Uint("user_id", userID),
+		zap.String("type", string(notification.Type)),
+	)
+
+	return &pbv1.SendNotificationResponse{
+		Success:      true,
+		Message:      "notification sent successfully",
+		Notification: convertNotificationToProto(notification),
+	}, nil
+}
+
+// SetUserTelegramChat links the Telegram chat receiving a user's alerts, or
+// unlinks it when chat_id is empty
+func (s *ManagementService) SetUserTelegramChat(ctx context.Context, req *pbv1.SetUserTelegramChatRequest) (*pbv1.SetUserTelegramChatResponse, error) {
+	s.logger.Debug("SetUserTelegramChat called", zap.String("user_id", req.UserId))
+
+	userID, err := parseNotificationUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	chatID := strings.TrimSpace(req.ChatId)
+	if chatID != "" {
+		// Chat IDs are integers, negative for groups and channels
+		if _, err := strconv.ParseInt(chatID, 10, 64); err != nil {
+			return nil, apierror.InvalidField("chat_id", "chat_id must be a Telegram chat ID")
+		}
+	}
+	repo := s.dbService.GetRepository().User
+	if _, err := repo.GetByID(userID); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+
+	if err := repo.UpdateTelegramChatID(userID, chatID); err != nil {
+		s.logger.Error("Failed to update Telegram chat", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to update Telegram chat")
+	}
+
+	message := "Telegram chat linked"
+	if chatID == "" {
+		message = "Telegram chat unlinked"
+	}
+	return &pbv1.SetUserTelegramChatResponse{Success: true, Message: message}, nil
+}
+
+// parseNotificationUserID parses the required ID of a notification's user
+func parseNotificationUserID(id string) (uint, error) {
+	if id == "" {
+		return 0, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	return uint(userID), nil
+}
+
+// convertNotificationToProto converts a notification to protobuf
+func convertNotificationToProto(notification *models.Notification) *pbv1.NotificationInfo {
+	info := &pbv1.NotificationInfo{
+		Id:        strconv.FormatUint(uint64(notification.ID), 10),
+		Type:      string(notification.Type),
+		Severity:  notification.Severity,
+		Title:     notification.Title,
+		Message:   notification.Message,
+		Read:      notification.ReadAt != nil,
+		CreatedAt: timestamppb.New(notification.CreatedAt),
+	}
+	if notification.ReadAt != nil {
+		info.ReadAt = timestamppb.New(*notification.ReadAt)
+	}
+	return info
+}
@@ -0,0 +1,431 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// Order management methods
+
+func (s *ManagementService) CreateOrder(ctx context.Context, req *pbv1.CreateOrderRequest) (*pbv1.CreateOrderResponse, error) {
+	s.logger.Debug("CreateOrder called",
+		zap.String("user_id", req.UserId),
+		zap.String("plan_id", req.PlanId),
+		zap.String("coupon_code", req.CouponCode),
+	)
+
+	if len(req.Notes) > 255 {
+		return nil, apierror.InvalidField("notes", "notes are too long")
+	}
+
+	order, err := s.priceOrder(req.UserId, req.PlanId, req.CouponCode, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	order.Notes = req.Notes
+
+	// Trials activated by the user are checked against the blocklist
+	if req.ClientIp != "" {
+		if err := s.checkTrialBlocklist(order, req.ClientIp); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.dbService.GetRepository().Order.Create(order); err != nil {
+		if isCouponError(err) {
+			return nil, couponNotApplicable(err, order.CouponCode)
+		}
+		s.logger.Error("Failed to create order", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to create order")
+	}
+
+	s.logger.Info("Order created",
+		zap.String("order_no", order.OrderNo),
+		zap.Uint("user_id", order.UserID),
+		zap.Uint("plan_id", order.PlanID),
+		zap.String("type", string(order.Type)),
+		zap.String("coupon_code", order.CouponCode),
+		zap.Int64("total", order.Total),
+	)
+
+	return &pbv1.CreateOrderResponse{
+		Success: true,
+		Message: "order created successfully",
+		Order:   s.convertOrderToProto(order),
+	}, nil
+}
+
+func (s *ManagementService) GetOrder(ctx context.Context, req *pbv1.GetOrderRequest) (*pbv1.GetOrderResponse, error) {
+	s.logger.Debug("GetOrder called", zap.String("order_id", req.OrderId))
+
+	orderID, err := parseOrderID(req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.dbService.GetRepository().Order.GetByID(orderID)
+	if err != nil {
+		return nil, s.orderError(err, req.OrderId, "failed to get order")
+	}
+	return &pbv1.GetOrderResponse{Order: s.convertOrderToProto(order)}, nil
+}
+
+func (s *ManagementService) ListOrders(ctx context.Context, req *pbv1.ListOrdersRequest) (*pbv1.ListOrdersResponse, error) {
+	s.logger.Debug("ListOrders called",
+		zap.Int32("page", req.Page),
+		zap.Int32("page_size", req.PageSize),
+		zap.String("user_id", req.UserId),
+		zap.String("status_filter", req.StatusFilter),
+	)
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	var filter repository.OrderFilter
+	if req.UserId != "" {
+		userID, err := strconv.ParseUint(req.UserId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("user_id", "invalid user_id format")
+		}
+		filter.UserID = uint(userID)
+	}
+	if req.StatusFilter != "" && req.StatusFilter != "all" {
+		filter.Status = models.OrderStatus(req.StatusFilter)
+		if !filter.Status.IsValid() {
+			return nil, apierror.InvalidField("status_filter", "status_filter must be one of all, pending, paid, cancelled, refunded")
+		}
+	}
+
+	orders, total, err := s.dbService.GetRepository().Order.List(filter, int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list orders", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list orders")
+	}
+
+	pbOrders := make([]*pbv1.OrderInfo, len(orders))
+	for i, order := range orders {
+		pbOrders[i] = s.convertOrderToProto(order)
+	}
+
+	return &pbv1.ListOrdersResponse{
+		Orders:   pbOrders,
+		Total:    int32(total),
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *ManagementService) ConfirmOrderPayment(ctx context.Context, req *pbv1.ConfirmOrderPaymentRequest) (*pbv1.ConfirmOrderPaymentResponse, error) {
+	s.logger.Debug("ConfirmOrderPayment called",
+		zap.String("order_id", req.OrderId),
+		zap.String("method", req.Method),
+	)
+
+	orderID, err := parseOrderID(req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Method == "" {
+		return nil, apierror.MissingField("method")
+	}
+	if len(req.Method) > 32 {
+		return nil, apierror.InvalidField("method", "method is too long")
+	}
+	if len(req.TransactionId) > 128 {
+		return nil, apierror.InvalidField("transaction_id", "transaction_id is too long")
+	}
+	if req.Operator == "" {
+		return nil, apierror.MissingField("operator")
+	}
+
+	repo := s.dbService.GetRepository().Order
+	order, err := repo.GetByID(orderID)
+	if err != nil {
+		return nil, s.orderError(err, req.OrderId, "failed to confirm payment")
+	}
+	if req.Amount != 0 && req.Amount != order.Total {
+		return nil, apierror.InvalidField("amount", "amount does not match the order total")
+	}
+
+	payment := &models.Payment{
+		Method:        req.Method,
+		TransactionID: req.TransactionId,
+		Amount:        order.Total,
+		Currency:      order.Currency,
+		Operator:      req.Operator,
+	}
+	order, err = repo.ConfirmPayment(orderID, payment)
+	if err != nil {
+		return nil, s.orderError(err, req.OrderId, "failed to confirm payment")
+	}
+
+	s.logger.Info("Order paid",
+		zap.String("order_no", order.OrderNo),
+		zap.Uint("user_id", order.UserID),
+		zap.Uint("plan_id", order.PlanID),
+		zap.Int64("total", order.Total),
+		zap.String("method", req.Method),
+		zap.String("operator", req.Operator),
+	)
+	s.restorePaidUser(order.UserID)
+
+	return &pbv1.ConfirmOrderPaymentResponse{
+		Success: true,
+		Message: "payment confirmed and plan activated",
+		Order:   s.convertOrderToProto(order),
+	}, nil
+}
+
+func (s *ManagementService) CancelOrder(ctx context.Context, req *pbv1.CancelOrderRequest) (*pbv1.CancelOrderResponse, error) {
+	s.logger.Debug("CancelOrder called", zap.String("order_id", req.OrderId))
+
+	orderID, err := parseOrderID(req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Reason) > 255 {
+		return nil, apierror.InvalidField("reason", "reason is too long")
+	}
+
+	order, err := s.dbService.GetRepository().Order.Cancel(orderID, req.Reason, req.Operator)
+	if err != nil {
+		return nil, s.orderError(err, req.OrderId, "failed to cancel order")
+	}
+
+	s.logger.Info("Order cancelled",
+		zap.String("order_no", order.OrderNo),
+		zap.String("reason", req.Reason),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.CancelOrderResponse{
+		Success: true,
+		Message: "order cancelled successfully",
+		Order:   s.convertOrderToProto(order),
+	}, nil
+}
+
+func (s *ManagementService) RefundOrder(ctx context.Context, req *pbv1.RefundOrderRequest) (*pbv1.RefundOrderResponse, error) {
+	s.logger.Debug("RefundOrder called",
+		zap.String("order_id", req.OrderId),
+		zap.Bool("revoke_access", req.RevokeAccess),
+		zap.Bool("refund_to_balance", req.RefundToBalance),
+	)
+
+	orderID, err := parseOrderID(req.OrderId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Operator == "" {
+		return nil, apierror.MissingField("operator")
+	}
+	if len(req.Reason) > 255 {
+		return nil, apierror.InvalidField("reason", "reason is too long")
+	}
+
+	order, err := s.dbService.GetRepository().Order.Refund(orderID, req.Reason, req.Operator, req.RevokeAccess, req.RefundToBalance)
+	if err != nil {
+		return nil, s.orderError(err, req.OrderId, "failed to refund order")
+	}
+
+	s.logger.Info("Order refunded",
+		zap.String("order_no", order.OrderNo),
+		zap.Int64("total", order.Total),
+		zap.Bool("revoke_access", req.RevokeAccess),
+		zap.String("reason", req.Reason),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.RefundOrderResponse{
+		Success: true,
+		Message: "order refunded successfully",
+		Order:   s.convertOrderToProto(order),
+	}, nil
+}
+
+// priceOrder builds an order of a plan for a user with the optional coupon
+// applied, without saving it
+func (s *ManagementService) priceOrder(userID, planID, couponCode string, now time.Time) (*models.Order, error) {
+	if userID == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	uid, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	plan, err := s.getPlan(planID)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Status != models.PlanStatusActive || !plan.IsEnabled {
+		return nil, apierror.FailedPrecondition(apierror.ReasonPlanUnavailable, "plan/"+planID,
+			"plan is not available for purchase")
+	}
+
+	repo := s.dbService.GetRepository()
+	user, err := repo.User.GetByID(uint(uid))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceUser, userID)
+		}
+		s.logger.Error("Failed to get user", zap.Error(err), zap.String("user_id", userID))
+		return nil, status.Error(codes.Internal, "failed to price order")
+	}
+
+	// The current plan prices the unused time of an upgrade; a removed plan has none
+	var current *models.Plan
+	if user.PlanID != 0 && user.PlanID != plan.ID {
+		if current, err = repo.Plan.GetByID(user.PlanID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Error("Failed to get current plan", zap.Error(err), zap.String("user_id", userID))
+			return nil, status.Error(codes.Internal, "failed to price order")
+		}
+	}
+
+	order, err := buildOrder(user, current, plan, now)
+	if err != nil {
+		return nil, err
+	}
+	if couponCode != "" {
+		if err := s.applyCoupon(order, couponCode, now); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// buildOrder prices an order of plan for user. current is the user's
+// running plan when it differs from the ordered one, nil if it is gone.
+func buildOrder(user *models.User, current, plan *models.Plan, now time.Time) (*models.Order, error) {
+	order := &models.Order{
+		OrderNo:  models.NewOrderNo(),
+		UserID:   user.ID,
+		PlanID:   plan.ID,
+		Type:     models.OrderTypeNew,
+		Status:   models.OrderStatusPending,
+		Amount:   plan.GetCurrentPrice(),
+		Currency: plan.Currency,
+	}
+
+	// Users get one trial, ordered or started on signup
+	if plan.StartsTrial() && !user.CanStartTrial() {
+		return nil, apierror.FailedPrecondition(apierror.ReasonTrialUsed,
+			"user/"+strconv.FormatUint(uint64(user.ID), 10), "user already had a trial")
+	}
+
+	running := user.ExpiresAt == nil || user.ExpiresAt.After(now)
+	switch {
+	case !running:
+	case user.PlanID == plan.ID:
+		if plan.ExpiryFrom(now) == nil {
+			return nil, apierror.FailedPrecondition(apierror.ReasonLifetimePlanOwned,
+				"user/"+strconv.FormatUint(uint64(user.ID), 10), "user already owns this lifetime plan")
+		}
+		order.Type = models.OrderTypeRenewal
+	case current != nil:
+		order.Type = models.OrderTypeUpgrade
+		// Unused time is only credited in the currency it was paid in
+		if user.ExpiresAt != nil && current.Currency == plan.Currency {
+			order.ProrationCredit = min(current.ProrationCredit(*user.ExpiresAt, now), order.Amount)
+		}
+	}
+
+	order.Total = order.Amount - order.ProrationCredit
+	return order, nil
+}
+
+// parseOrderID parses a required order ID
+func parseOrderID(orderID string) (uint, error) {
+	if orderID == "" {
+		return 0, apierror.MissingField("order_id")
+	}
+	id, err := strconv.ParseUint(orderID, 10, 32)
+	if err != nil {
+		return 0, apierror.InvalidField("order_id", "invalid order_id format")
+	}
+	return uint(id), nil
+}
+
+// orderError maps repository errors of order operations to API errors
+func (s *ManagementService) orderError(err error, orderID, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apierror.NotFound(apierror.ResourceOrder, orderID)
+	case errors.Is(err, repository.ErrOrderNotPending):
+		return apierror.FailedPrecondition(apierror.ReasonOrderNotPending, "order/"+orderID, "order is not pending")
+	case errors.Is(err, repository.ErrOrderNotPaid):
+		return apierror.FailedPrecondition(apierror.ReasonOrderNotPaid, "order/"+orderID, "order is not paid")
+	case errors.Is(err, models.ErrInsufficientBalance):
+		return apierror.FailedPrecondition(apierror.ReasonInsufficientBalance, "order/"+orderID, "insufficient balance")
+	case errors.Is(err, models.ErrBalanceCurrency):
+		return apierror.FailedPrecondition(apierror.ReasonBalanceCurrency, "order/"+orderID, "balance is held in another currency")
+	case errors.Is(err, models.ErrTrialUsed):
+		return apierror.FailedPrecondition(apierror.ReasonTrialUsed, "order/"+orderID, "user already had a trial")
+	}
+	s.logger.Error(message, zap.Error(err), zap.String("order_id", orderID))
+	return status.Error(codes.Internal, message)
+}
+
+// convertOrderToProto converts an order with its payments to protobuf
+func (s *ManagementService) convertOrderToProto(order *models.Order) *pbv1.OrderInfo {
+	info := &pbv1.OrderInfo{
+		Id:              strconv.FormatUint(uint64(order.ID), 10),
+		OrderNo:         order.OrderNo,
+		UserId:          strconv.FormatUint(uint64(order.UserID), 10),
+		PlanId:          strconv.FormatUint(uint64(order.PlanID), 10),
+		Type:            string(order.Type),
+		Status:          string(order.Status),
+		Amount:          order.Amount,
+		ProrationCredit: order.ProrationCredit,
+		Discount:        order.Discount,
+		Total:           order.Total,
+		Currency:        order.Currency,
+		CouponCode:      order.CouponCode,
+		Notes:           order.Notes,
+		StatusReason:    order.StatusReason,
+		Operator:        order.Operator,
+		Payments:        make([]*pbv1.PaymentInfo, len(order.Payments)),
+		CreatedAt:       timestamppb.New(order.CreatedAt),
+	}
+	if order.PaidAt != nil {
+		info.PaidAt = timestamppb.New(*order.PaidAt)
+	}
+	if order.CancelledAt != nil {
+		info.CancelledAt = timestamppb.New(*order.CancelledAt)
+	}
+	if order.RefundedAt != nil {
+		info.RefundedAt = timestamppb.New(*order.RefundedAt)
+	}
+	for i, payment := range order.Payments {
+		info.Payments[i] = &pbv1.PaymentInfo{
+			Id:            strconv.FormatUint(uint64(payment.ID), 10),
+			Method:        payment.Method,
+			TransactionId: payment.TransactionID,
+			Amount:        payment.Amount,
+			Currency:      payment.Currency,
+			Status:        string(payment.Status),
+			Operator:      payment.Operator,
+			CreatedAt:     timestamppb.New(payment.CreatedAt),
+		}
+	}
+	return info
+}
@@ -0,0 +1,682 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// maxPlanNameLength matches the size of the name column
+const maxPlanNameLength = 128
+
+// Plan management methods
+
+func (s *ManagementService) CreatePlan(ctx context.Context, req *pbv1.CreatePlanRequest) (*pbv1.CreatePlanResponse, error) {
+	if req.Plan == nil {
+		return nil, apierror.MissingField("plan")
+	}
+	s.logger.Debug("CreatePlan called", zap.String("name", req.Plan.Name))
+
+	plan := &models.Plan{
+		Status:   models.PlanStatusActive,
+		Currency: "USD",
+	}
+	if err := s.applyPlanSpec(plan, req.Plan); err != nil {
+		return nil, err
+	}
+
+	repo := s.dbService.GetRepository().Plan
+	wanted := *plan
+	if err := repo.Create(plan); err != nil {
+		s.logger.Error("Failed to create plan", zap.Error(err), zap.String("name", plan.Name))
+		return nil, status.Error(codes.Internal, "failed to create plan")
+	}
+	// Columns with a default replace false and zero values on insert
+	if plan.IsPublic != wanted.IsPublic || plan.IsEnabled != wanted.IsEnabled || plan.DeviceLimit != wanted.DeviceLimit {
+		plan.IsPublic, plan.IsEnabled, plan.DeviceLimit = wanted.IsPublic, wanted.IsEnabled, wanted.DeviceLimit
+		if err := repo.Update(plan); err != nil {
+			s.logger.Error("Failed to create plan", zap.Error(err), zap.String("name", plan.Name))
+			return nil, status.Error(codes.Internal, "failed to create plan")
+		}
+	}
+
+	s.logger.Info("Plan created", zap.Uint("plan_id", plan.ID), zap.String("name", plan.Name))
+
+	info, err := s.planInfo(plan)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.CreatePlanResponse{
+		Success: true,
+		Message: "plan created successfully",
+		Plan:    info,
+	}, nil
+}
+
+func (s *ManagementService) UpdatePlan(ctx context.Context, req *pbv1.UpdatePlanRequest) (*pbv1.UpdatePlanResponse, error) {
+	s.logger.Debug("UpdatePlan called", zap.String("plan_id", req.PlanId))
+
+	plan, err := s.getPlan(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Plan == nil {
+		return nil, apierror.MissingField("plan")
+	}
+	if err := s.applyPlanSpec(plan, req.Plan); err != nil {
+		return nil, err
+	}
+	if err := s.checkPlanNodeProtocols(plan); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Plan.Update(plan); err != nil {
+		s.logger.Error("Failed to update plan", zap.Error(err), zap.String("plan_id", req.PlanId))
+		return nil, status.Error(codes.Internal, "failed to update plan")
+	}
+
+	s.logger.Info("Plan updated", zap.Uint("plan_id", plan.ID), zap.String("name", plan.Name))
+
+	info, err := s.planInfo(plan)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.UpdatePlanResponse{
+		Success: true,
+		Message: "plan updated successfully",
+		Plan:    info,
+	}, nil
+}
+
+func (s *ManagementService) DeletePlan(ctx context.Context, req *pbv1.DeletePlanRequest) (*pbv1.DeletePlanResponse, error) {
+	s.logger.Debug("DeletePlan called", zap.String("plan_id", req.PlanId))
+
+	plan, err := s.getPlan(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := s.dbService.GetRepository()
+	_, userCount, err := repo.User.ListByPlanID(plan.ID, 0, 1)
+	if err != nil {
+		s.logger.Error("Failed to count plan users", zap.Error(err), zap.String("plan_id", req.PlanId))
+		return nil, status.Error(codes.Internal, "failed to delete plan")
+	}
+	if userCount > 0 {
+		return nil, apierror.FailedPrecondition(apierror.ReasonPlanInUse, "plan/"+req.PlanId,
+			fmt.Sprintf("plan is still assigned to %d users", userCount))
+	}
+
+	if err := repo.Plan.Delete(plan.ID); err != nil {
+		s.logger.Error("Failed to delete plan", zap.Error(err), zap.String("plan_id", req.PlanId))
+		return nil, status.Error(codes.Internal, "failed to delete plan")
+	}
+
+	s.logger.Info("Plan deleted", zap.Uint("plan_id", plan.ID), zap.String("name", plan.Name))
+
+	return &pbv1.DeletePlanResponse{
+		Success: true,
+		Message: "plan deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) GetPlan(ctx context.Context, req *pbv1.GetPlanRequest) (*pbv1.GetPlanResponse, error) {
+	s.logger.Debug("GetPlan called", zap.String("plan_id", req.PlanId))
+
+	plan, err := s.getPlan(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.planInfo(plan)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.GetPlanResponse{Plan: info}, nil
+}
+
+func (s *ManagementService) ListPlans(ctx context.Context, req *pbv1.ListPlansRequest) (*pbv1.ListPlansResponse, error) {
+	s.logger.Debug("ListPlans called",
+		zap.Int32("page", req.Page),
+		zap.Int32("page_size", req.PageSize),
+		zap.String("status_filter", req.StatusFilter),
+	)
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	statusFilter := req.StatusFilter
+	if statusFilter == "all" {
+		statusFilter = ""
+	}
+	if statusFilter != "" && !models.PlanStatus(statusFilter).IsValid() {
+		return nil, apierror.InvalidField("status_filter", "status_filter must be one of all, active, inactive, archived")
+	}
+	if statusFilter != "" && req.Search != "" {
+		return nil, apierror.InvalidField("search", "search cannot be combined with status_filter")
+	}
+
+	repo := s.dbService.GetRepository().Plan
+	var plans []*models.Plan
+	var total int64
+	var err error
+
+	switch {
+	case req.Search != "":
+		plans, total, err = repo.Search(req.Search, int(offset), int(pageSize))
+	case statusFilter != "":
+		plans, total, err = repo.ListByStatus(models.PlanStatus(statusFilter), int(offset), int(pageSize))
+	default:
+		plans, total, err = repo.List(int(offset), int(pageSize))
+	}
+	if err != nil {
+		s.logger.Error("Failed to list plans", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list plans")
+	}
+
+	pbPlans := make([]*pbv1.PlanInfo, len(plans))
+	for i, plan := range plans {
+		info, err := s.planInfo(plan)
+		if err != nil {
+			return nil, err
+		}
+		pbPlans[i] = info
+	}
+
+	return &pbv1.ListPlansResponse{
+		Plans:    pbPlans,
+		Total:    int32(total),
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *ManagementService) GetPlanStatistics(ctx context.Context, req *pbv1.GetPlanStatisticsRequest) (*pbv1.GetPlanStatisticsResponse, error) {
+	s.logger.Debug("GetPlanStatistics called", zap.String("plan_id", req.PlanId))
+
+	repo := s.dbService.GetRepository().Plan
+	var stats []*repository.PlanStatistics
+
+	if req.PlanId != "" {
+		planID, err := strconv.ParseUint(req.PlanId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("plan_id", "invalid plan_id format")
+		}
+		planStats, err := repo.GetPlanStatistics(uint(planID))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, apierror.NotFound(apierror.ResourcePlan, req.PlanId)
+			}
+			s.logger.Error("Failed to get plan statistics", zap.Error(err), zap.String("plan_id", req.PlanId))
+			return nil, status.Error(codes.Internal, "failed to get plan statistics")
+		}
+		stats = append(stats, planStats)
+	} else {
+		var err error
+		if stats, err = repo.GetAllPlanStatistics(); err != nil {
+			s.logger.Error("Failed to get plan statistics", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to get plan statistics")
+		}
+	}
+
+	pbStats := make([]*pbv1.PlanStatistics, len(stats))
+	for i, stat := range stats {
+		pbStats[i] = &pbv1.PlanStatistics{
+			PlanId:          strconv.FormatUint(uint64(stat.PlanID), 10),
+			PlanName:        stat.PlanName,
+			TotalUsers:      stat.TotalUsers,
+			ActiveUsers:     stat.ActiveUsers,
+			UsagePercentage: stat.UsagePercentage,
+			TotalRevenue:    stat.TotalRevenue,
+			AvgTrafficUsage: stat.AvgTrafficUsage,
+		}
+	}
+	return &pbv1.GetPlanStatisticsResponse{Statistics: pbStats}, nil
+}
+
+func (s *ManagementService) AddPlanFeature(ctx context.Context, req *pbv1.AddPlanFeatureRequest) (*pbv1.AddPlanFeatureResponse, error) {
+	s.logger.Debug("AddPlanFeature called",
+		zap.String("plan_id", req.PlanId),
+		zap.String("name", req.Name),
+	)
+
+	plan, err := s.getPlan(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name == "" {
+		return nil, apierror.MissingField("name")
+	}
+	if req.Type == "" {
+		return nil, apierror.MissingField("type")
+	}
+
+	feature := &models.PlanFeature{
+		PlanID:      plan.ID,
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        req.Type,
+		Value:       req.Value,
+		Icon:        req.Icon,
+		SortOrder:   int(req.SortOrder),
+		IsVisible:   !req.Hidden,
+	}
+	if err := validationError(feature.Validate(), ""); err != nil {
+		return nil, err
+	}
+
+	repo := s.dbService.GetRepository().Plan
+	err = repo.CreateFeature(feature)
+	if err == nil && req.Hidden {
+		// is_visible defaults to true on insert
+		feature.IsVisible = false
+		err = repo.UpdateFeature(feature)
+	}
+	if err != nil {
+		s.logger.Error("Failed to create plan feature", zap.Error(err), zap.String("plan_id", req.PlanId))
+		return nil, status.Error(codes.Internal, "failed to create plan feature")
+	}
+
+	return &pbv1.AddPlanFeatureResponse{
+		Success: true,
+		Message: "plan feature added successfully",
+		Feature: s.convertPlanFeatureToProto(feature),
+	}, nil
+}
+
+func (s *ManagementService) UpdatePlanFeature(ctx context.Context, req *pbv1.UpdatePlanFeatureRequest) (*pbv1.UpdatePlanFeatureResponse, error) {
+	s.logger.Debug("UpdatePlanFeature called", zap.String("feature_id", req.FeatureId))
+
+	feature, err := s.getPlanFeature(req.FeatureId)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != "" {
+		feature.Name = req.Name
+	}
+	if req.Type != "" {
+		feature.Type = req.Type
+	}
+	feature.Description = req.Description
+	feature.Value = req.Value
+	feature.Icon = req.Icon
+	feature.SortOrder = int(req.SortOrder)
+	feature.IsVisible = !req.Hidden
+	if err := validationError(feature.Validate(), ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Plan.UpdateFeature(feature); err != nil {
+		s.logger.Error("Failed to update plan feature", zap.Error(err), zap.String("feature_id", req.FeatureId))
+		return nil, status.Error(codes.Internal, "failed to update plan feature")
+	}
+
+	return &pbv1.UpdatePlanFeatureResponse{
+		Success: true,
+		Message: "plan feature updated successfully",
+		Feature: s.convertPlanFeatureToProto(feature),
+	}, nil
+}
+
+func (s *ManagementService) DeletePlanFeature(ctx context.Context, req *pbv1.DeletePlanFeatureRequest) (*pbv1.DeletePlanFeatureResponse, error) {
+	s.logger.Debug("DeletePlanFeature called", zap.String("feature_id", req.FeatureId))
+
+	feature, err := s.getPlanFeature(req.FeatureId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Plan.DeleteFeature(feature.ID); err != nil {
+		s.logger.Error("Failed to delete plan feature", zap.Error(err), zap.String("feature_id", req.FeatureId))
+		return nil, status.Error(codes.Internal, "failed to delete plan feature")
+	}
+
+	return &pbv1.DeletePlanFeatureResponse{
+		Success: true,
+		Message: "plan feature deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) SetPlanNodeAccess(ctx context.Context, req *pbv1.SetPlanNodeAccessRequest) (*pbv1.SetPlanNodeAccessResponse, error) {
+	s.logger.Debug("SetPlanNodeAccess called",
+		zap.String("plan_id", req.PlanId),
+		zap.String("node_id", req.NodeId),
+		zap.Bool("is_enabled", req.IsEnabled),
+	)
+
+	plan, err := s.getPlan(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+	if req.SpeedLimitOverride < 0 {
+		return nil, apierror.InvalidField("speed_limit_override", "speed_limit_override cannot be negative")
+	}
+	if req.MaxConnections < 0 {
+		return nil, apierror.InvalidField("max_connections", "max_connections cannot be negative")
+	}
+
+	repo := s.dbService.GetRepository()
+	node, err := repo.Node.GetByID(uint(nodeID))
+	if err != nil {
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
+	}
+	if req.IsEnabled && !plan.AllowsProtocol(string(node.Type)) {
+		return nil, planProtocolUnsupported(plan, node)
+	}
+
+	access, err := repo.Plan.GetNodeAccess(plan.ID, node.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to get plan node access", zap.Error(err),
+			zap.String("plan_id", req.PlanId), zap.String("node_id", req.NodeId))
+		return nil, status.Error(codes.Internal, "failed to set plan node access")
+	}
+	creating := access == nil
+	if creating {
+		access = &models.PlanNodeAccess{PlanID: plan.ID, NodeID: node.ID}
+	}
+	access.IsEnabled = req.IsEnabled
+	access.Priority = int(req.Priority)
+	access.SpeedLimitOverride = req.SpeedLimitOverride
+	access.MaxConnections = int(req.MaxConnections)
+
+	if creating {
+		err = repo.Plan.CreateNodeAccess(access)
+		if err == nil && !req.IsEnabled {
+			// is_enabled defaults to true on insert
+			access.IsEnabled = false
+			err = repo.Plan.UpdateNodeAccess(access)
+		}
+	} else {
+		err = repo.Plan.UpdateNodeAccess(access)
+	}
+	if err != nil {
+		s.logger.Error("Failed to set plan node access", zap.Error(err),
+			zap.String("plan_id", req.PlanId), zap.String("node_id", req.NodeId))
+		return nil, status.Error(codes.Internal, "failed to set plan node access")
+	}
+
+	s.logger.Info("Plan node access set",
+		zap.Uint("plan_id", plan.ID),
+		zap.Uint("node_id", node.ID),
+		zap.Bool("is_enabled", access.IsEnabled),
+	)
+
+	access.Node = *node
+	return &pbv1.SetPlanNodeAccessResponse{
+		Success: true,
+		Message: "plan node access set successfully",
+		Access:  s.convertPlanNodeAccessToProto(access),
+	}, nil
+}
+
+func (s *ManagementService) RemovePlanNodeAccess(ctx context.Context, req *pbv1.RemovePlanNodeAccessRequest) (*pbv1.RemovePlanNodeAccessResponse, error) {
+	s.logger.Debug("RemovePlanNodeAccess called",
+		zap.String("plan_id", req.PlanId),
+		zap.String("node_id", req.NodeId),
+	)
+
+	plan, err := s.getPlan(req.PlanId)
+	if err != nil {
+		return nil, err
+	}
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+
+	if err := s.dbService.GetRepository().Plan.DeleteNodeAccess(plan.ID, uint(nodeID)); err != nil {
+		s.logger.Error("Failed to remove plan node access", zap.Error(err),
+			zap.String("plan_id", req.PlanId), zap.String("node_id", req.NodeId))
+		return nil, status.Error(codes.Internal, "failed to remove plan node access")
+	}
+
+	s.logger.Info("Plan node access removed", zap.Uint("plan_id", plan.ID), zap.Uint64("node_id", nodeID))
+
+	return &pbv1.RemovePlanNodeAccessResponse{
+		Success: true,
+		Message: "plan node access removed successfully",
+	}, nil
+}
+
+// getPlan parses the plan ID and loads the plan
+func (s *ManagementService) getPlan(planID string) (*models.Plan, error) {
+	if planID == "" {
+		return nil, apierror.MissingField("plan_id")
+	}
+	id, err := strconv.ParseUint(planID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("plan_id", "invalid plan_id format")
+	}
+
+	plan, err := s.dbService.GetRepository().Plan.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourcePlan, planID)
+		}
+		s.logger.Error("Failed to get plan", zap.Error(err), zap.String("plan_id", planID))
+		return nil, status.Error(codes.Internal, "failed to get plan")
+	}
+	return plan, nil
+}
+
+// getPlanFeature parses the feature ID and loads the feature
+func (s *ManagementService) getPlanFeature(featureID string) (*models.PlanFeature, error) {
+	if featureID == "" {
+		return nil, apierror.MissingField("feature_id")
+	}
+	id, err := strconv.ParseUint(featureID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("feature_id", "invalid feature_id format")
+	}
+
+	feature, err := s.dbService.GetRepository().Plan.GetFeatureByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourcePlanFeature, featureID)
+		}
+		s.logger.Error("Failed to get plan feature", zap.Error(err), zap.String("feature_id", featureID))
+		return nil, status.Error(codes.Internal, "failed to get plan feature")
+	}
+	return feature, nil
+}
+
+// applyPlanSpec validates a plan spec and copies it onto the plan. Empty
+// name, status, period and currency keep the current values, so required
+// ones are only enforced when the plan does not have them yet.
+func (s *ManagementService) applyPlanSpec(plan *models.Plan, spec *pbv1.PlanSpec) error {
+	if spec.Name != "" && spec.Name != plan.Name {
+		if err := s.checkPlanName(spec.Name, plan.ID); err != nil {
+			return err
+		}
+		plan.Name = spec.Name
+	}
+	if plan.Name == "" {
+		return apierror.MissingField("plan.name")
+	}
+
+	if spec.Status != "" {
+		plan.Status = models.PlanStatus(spec.Status)
+	}
+	if spec.Period != "" {
+		plan.Period = models.PlanPeriod(spec.Period)
+	}
+	if spec.Currency != "" {
+		plan.Currency = strings.ToUpper(spec.Currency)
+	}
+
+	plan.Description = spec.Description
+	plan.Price = spec.Price
+	plan.TrafficQuota = spec.TrafficQuota
+	plan.SpeedLimit = spec.SpeedLimit
+	plan.DeviceLimit = int(spec.DeviceLimit)
+	plan.ConnectionLimit = int(spec.ConnectionLimit)
+	plan.MaxUsers = int(spec.MaxUsers)
+	plan.IsPublic = spec.IsPublic
+	plan.IsEnabled = spec.IsEnabled
+	plan.IsRecommended = spec.IsRecommended
+	plan.SortOrder = int(spec.SortOrder)
+	plan.Color = spec.Color
+	plan.Icon = spec.Icon
+	plan.QuotaWarningThresholds = nil
+	for _, threshold := range spec.QuotaWarningThresholds {
+		plan.QuotaWarningThresholds = append(plan.QuotaWarningThresholds, int(threshold))
+	}
+	plan.AllowedProtocols = strings.Join(spec.AllowedProtocols, ",")
+	plan.BlockedDomains = strings.Join(spec.BlockedDomains, ",")
+	return validationError(plan.Validate(), "plan.")
+}
+
+// checkPlanNodeProtocols checks that the plan still allows the protocols of
+// the nodes it grants access to
+func (s *ManagementService) checkPlanNodeProtocols(plan *models.Plan) error {
+	access, err := s.dbService.GetRepository().Plan.ListNodeAccess(plan.ID)
+	if err != nil {
+		s.logger.Error("Failed to get plan node access", zap.Error(err), zap.Uint("plan_id", plan.ID))
+		return status.Error(codes.Internal, "failed to check plan node access")
+	}
+	for _, a := range access {
+		if a.IsEnabled && !plan.AllowsProtocol(string(a.Node.Type)) {
+			return planProtocolUnsupported(plan, &a.Node)
+		}
+	}
+	return nil
+}
+
+// planProtocolUnsupported reports a node whose protocol the plan does not allow
+func planProtocolUnsupported(plan *models.Plan, node *models.Node) error {
+	return apierror.FailedPrecondition(apierror.ReasonPlanProtocolUnsupported,
+		fmt.Sprintf("node/%d", node.ID),
+		fmt.Sprintf("plan %s does not allow the %s protocol of node %s", plan.Name, node.Type, node.Name))
+}
+
+// checkPlanName validates a plan name and checks that no other plan uses it
+func (s *ManagementService) checkPlanName(name string, excludeID uint) error {
+	if len(name) > maxPlanNameLength {
+		return apierror.InvalidField("plan.name", "name is too long")
+	}
+
+	existing, err := s.dbService.GetRepository().Plan.GetByName(name)
+	if err == nil && existing.ID != excludeID {
+		return apierror.AlreadyExists(apierror.ResourcePlan, apierror.ReasonPlanNameTaken,
+			"plan name already exists", map[string]string{"name": name})
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to check plan name", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check plan name")
+	}
+	return nil
+}
+
+// planInfo converts a plan with its features and node access to protobuf
+func (s *ManagementService) planInfo(plan *models.Plan) (*pbv1.PlanInfo, error) {
+	repo := s.dbService.GetRepository().Plan
+	features, err := repo.ListFeatures(plan.ID)
+	if err != nil {
+		s.logger.Error("Failed to get plan features", zap.Error(err), zap.Uint("plan_id", plan.ID))
+		return nil, status.Error(codes.Internal, "failed to get plan features")
+	}
+	access, err := repo.ListNodeAccess(plan.ID)
+	if err != nil {
+		s.logger.Error("Failed to get plan node access", zap.Error(err), zap.Uint("plan_id", plan.ID))
+		return nil, status.Error(codes.Internal, "failed to get plan node access")
+	}
+
+	info := &pbv1.PlanInfo{
+		Id: strconv.FormatUint(uint64(plan.ID), 10),
+		Spec: &pbv1.PlanSpec{
+			Name:            plan.Name,
+			Description:     plan.Description,
+			Status:          string(plan.Status),
+			Period:          string(plan.Period),
+			Price:           plan.Price,
+			Currency:        plan.Currency,
+			TrafficQuota:    plan.TrafficQuota,
+			SpeedLimit:      plan.SpeedLimit,
+			DeviceLimit:     int32(plan.DeviceLimit),
+			ConnectionLimit: int32(plan.ConnectionLimit),
+			MaxUsers:        int32(plan.MaxUsers),
+			IsPublic:        plan.IsPublic,
+			IsEnabled:       plan.IsEnabled,
+			IsRecommended:   plan.IsRecommended,
+			SortOrder:       int32(plan.SortOrder),
+			Color:           plan.Color,
+			Icon:            plan.Icon,
+		},
+		CurrentUsers: int32(plan.CurrentUsers),
+		Features:     make([]*pbv1.PlanFeatureInfo, len(features)),
+		NodeAccess:   make([]*pbv1.PlanNodeAccessInfo, len(access)),
+		CreatedAt:    timestamppb.New(plan.CreatedAt),
+		UpdatedAt:    timestamppb.New(plan.UpdatedAt),
+	}
+	for _, threshold := range plan.QuotaWarningThresholds {
+		info.Spec.QuotaWarningThresholds = append(info.Spec.QuotaWarningThresholds, int32(threshold))
+	}
+	info.Spec.AllowedProtocols = plan.AllowedProtocolList()
+	info.Spec.BlockedDomains = plan.BlockedDomainList()
+	for i, feature := range features {
+		info.Features[i] = s.convertPlanFeatureToProto(feature)
+	}
+	for i, a := range access {
+		info.NodeAccess[i] = s.convertPlanNodeAccessToProto(a)
+	}
+	return info, nil
+}
+
+// convertPlanFeatureToProto converts a plan feature to protobuf
+func (s *ManagementService) convertPlanFeatureToProto(feature *models.PlanFeature) *pbv1.PlanFeatureInfo {
+	return &pbv1.PlanFeatureInfo{
+		Id:          strconv.FormatUint(uint64(feature.ID), 10),
+		PlanId:      strconv.FormatUint(uint64(feature.PlanID), 10),
+		Name:        feature.Name,
+		Description: feature.Description,
+		Type:        feature.Type,
+		Value:       feature.Value,
+		Icon:        feature.Icon,
+		SortOrder:   int32(feature.SortOrder),
+		Hidden:      !feature.IsVisible,
+	}
+}
+
+// convertPlanNodeAccessToProto converts a plan node access grant to protobuf
+func (s *ManagementService) convertPlanNodeAccessToProto(access *models.PlanNodeAccess) *pbv1.PlanNodeAccessInfo {
+	return &pbv1.PlanNodeAccessInfo{
+		PlanId:             strconv.FormatUint(uint64(access.PlanID), 10),
+		NodeId:             strconv.FormatUint(uint64(access.NodeID), 10),
+		NodeName:           access.Node.Name,
+		IsEnabled:          access.IsEnabled,
+		Priority:           int32(access.Priority),
+		SpeedLimitOverride: access.SpeedLimitOverride,
+		MaxConnections:     int32(access.MaxConnections),
+	}
+}
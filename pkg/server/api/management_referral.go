@@ -0,0 +1,402 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// Referral program methods
+
+func (s *ManagementService) GetReferralSettings(ctx context.Context, req *pbv1.GetReferralSettingsRequest) (*pbv1.GetReferralSettingsResponse, error) {
+	s.logger.Debug("GetReferralSettings called")
+
+	settings, err := s.dbService.GetRepository().Referral.GetSettings()
+	if err != nil {
+		s.logger.Error("Failed to get referral settings", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get referral settings")
+	}
+
+	return &pbv1.GetReferralSettingsResponse{Settings: convertReferralSettingsToProto(settings)}, nil
+}
+
+func (s *ManagementService) UpdateReferralSettings(ctx context.Context, req *pbv1.UpdateReferralSettingsRequest) (*pbv1.UpdateReferralSettingsResponse, error) {
+	s.logger.Debug("UpdateReferralSettings called", zap.String("operator", req.Operator))
+
+	if req.Settings == nil {
+		return nil, apierror.MissingField("settings")
+	}
+	if req.Operator == "" {
+		return nil, apierror.MissingField("operator")
+	}
+
+	settings := &models.ReferralSettings{
+		Enabled:        req.Settings.Enabled,
+		CommissionType: models.ReferralCommissionType(req.Settings.CommissionType),
+		CommissionRate: int(req.Settings.CommissionRate),
+		TrafficBonus:   req.Settings.TrafficBonus,
+		FirstOrderOnly: req.Settings.FirstOrderOnly,
+		UpdatedBy:      req.Operator,
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, validationError(err, "settings.")
+	}
+
+	if err := s.dbService.GetRepository().Referral.SaveSettings(settings); err != nil {
+		s.logger.Error("Failed to save referral settings", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to update referral settings")
+	}
+
+	s.logger.Info("Referral settings updated",
+		zap.Bool("enabled", settings.Enabled),
+		zap.String("commission_type", string(settings.CommissionType)),
+		zap.Int("commission_rate", settings.CommissionRate),
+		zap.Int64("traffic_bonus", settings.TrafficBonus),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.UpdateReferralSettingsResponse{
+		Success:  true,
+		Message:  "referral settings updated successfully",
+		Settings: convertReferralSettingsToProto(settings),
+	}, nil
+}
+
+func (s *ManagementService) GetReferralStats(ctx context.Context, req *pbv1.GetReferralStatsRequest) (*pbv1.GetReferralStatsResponse, error) {
+	s.logger.Debug("GetReferralStats called", zap.String("user_id", req.UserId))
+
+	user, err := s.getReferralUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := s.dbService.GetRepository().Referral
+	code, err := repo.GetOrCreateCode(user.ID)
+	if err != nil {
+		s.logger.Error("Failed to get referral code", zap.Error(err), zap.Uint("user_id", user.ID))
+		return nil, status.Error(codes.Internal, "failed to get referral stats")
+	}
+	settings, err := repo.GetSettings()
+	if err != nil {
+		s.logger.Error("Failed to get referral settings", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get referral stats")
+	}
+	stats, err := repo.GetStats(user.ID)
+	if err != nil {
+		s.logger.Error("Failed to get referral stats", zap.Error(err), zap.Uint("user_id", user.ID))
+		return nil, status.Error(codes.Internal, "failed to get referral stats")
+	}
+
+	resp := &pbv1.GetReferralStatsResponse{
+		Code:           code.Code,
+		ProgramEnabled: settings.Enabled,
+		CommissionType: string(settings.CommissionType),
+		ReferredUsers:  int32(stats.ReferredUsers),
+		PayingUsers:    int32(stats.PayingUsers),
+		PaidOrders:     int32(stats.PaidOrders),
+		Balances:       make([]*pbv1.ReferralBalance, len(stats.Balances)),
+		TrafficBonus:   stats.TrafficBonus,
+	}
+	for i, balance := range stats.Balances {
+		resp.Balances[i] = &pbv1.ReferralBalance{
+			Currency: balance.Currency,
+			Pending:  balance.Pending,
+			Paid:     balance.Paid,
+		}
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) ListReferrals(ctx context.Context, req *pbv1.ListReferralsRequest) (*pbv1.ListReferralsResponse, error) {
+	s.logger.Debug("ListReferrals called", zap.String("user_id", req.UserId))
+
+	user, err := s.getReferralUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	referrals, total, err := s.dbService.GetRepository().Referral.ListReferrals(user.ID, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list referrals", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list referrals")
+	}
+
+	infos := make([]*pbv1.ReferralInfo, len(referrals))
+	for i, referral := range referrals {
+		infos[i] = &pbv1.ReferralInfo{
+			UserId:     strconv.FormatUint(uint64(referral.UserID), 10),
+			Username:   referral.Username,
+			ReferredAt: timestamppb.New(referral.CreatedAt),
+			PaidOrders: int32(referral.PaidOrders),
+		}
+	}
+
+	return &pbv1.ListReferralsResponse{
+		Referrals: infos,
+		Total:     int32(total),
+		Page:      page,
+		PageSize:  pageSize,
+	}, nil
+}
+
+func (s *ManagementService) ListReferralCommissions(ctx context.Context, req *pbv1.ListReferralCommissionsRequest) (*pbv1.ListReferralCommissionsResponse, error) {
+	s.logger.Debug("ListReferralCommissions called",
+		zap.String("user_id", req.UserId),
+		zap.String("status_filter", req.StatusFilter),
+	)
+
+	var filter repository.ReferralCommissionFilter
+	if req.UserId != "" {
+		userID, err := strconv.ParseUint(req.UserId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("user_id", "invalid user_id format")
+		}
+		filter.ReferrerID = uint(userID)
+	}
+	if req.StatusFilter != "" {
+		filter.Status = models.ReferralCommissionStatus(req.StatusFilter)
+		if !filter.Status.IsValid() {
+			return nil, apierror.InvalidField("status_filter", "status_filter must be one of pending, paid, credited, cancelled")
+		}
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	commissions, total, err := s.dbService.GetRepository().Referral.ListCommissions(filter, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list referral commissions", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list referral commissions")
+	}
+
+	infos := make([]*pbv1.ReferralCommissionInfo, len(commissions))
+	for i, commission := range commissions {
+		infos[i] = convertReferralCommissionToProto(commission)
+	}
+
+	return &pbv1.ListReferralCommissionsResponse{
+		Commissions: infos,
+		Total:       int32(total),
+		Page:        page,
+		PageSize:    pageSize,
+	}, nil
+}
+
+func (s *ManagementService) PayoutReferralCommissions(ctx context.Context, req *pbv1.PayoutReferralCommissionsRequest) (*pbv1.PayoutReferralCommissionsResponse, error) {
+	s.logger.Debug("PayoutReferralCommissions called",
+		zap.String("user_id", req.UserId),
+		zap.String("currency", req.Currency),
+	)
+
+	user, err := s.getReferralUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Currency == "" {
+		return nil, apierror.MissingField("currency")
+	}
+	if req.Operator == "" {
+		return nil, apierror.MissingField("operator")
+	}
+	if len(req.Note) > 255 {
+		return nil, apierror.InvalidField("note", "note is too long")
+	}
+
+	count, amount, err := s.dbService.GetRepository().Referral.Payout(user.ID, req.Currency, req.Operator, req.Note)
+	if err != nil {
+		s.logger.Error("Failed to pay out referral commissions", zap.Error(err), zap.Uint("user_id", user.ID))
+		return nil, status.Error(codes.Internal, "failed to pay out referral commissions")
+	}
+	if count == 0 {
+		return &pbv1.PayoutReferralCommissionsResponse{
+			Success: true,
+			Message: "no pending commissions to pay out",
+		}, nil
+	}
+
+	s.logger.Info("Referral commissions paid out",
+		zap.Uint("user_id", user.ID),
+		zap.Int64("count", count),
+		zap.Int64("amount", amount),
+		zap.String("currency", req.Currency),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.PayoutReferralCommissionsResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("%d commissions paid out", count),
+		PaidCount: int32(count),
+		Amount:    amount,
+	}, nil
+}
+
+func (s *ManagementService) CreateReferralAdjustment(ctx context.Context, req *pbv1.CreateReferralAdjustmentRequest) (*pbv1.CreateReferralAdjustmentResponse, error) {
+	s.logger.Debug("CreateReferralAdjustment called",
+		zap.String("user_id", req.UserId),
+		zap.String("type", req.Type),
+		zap.Int64("amount", req.Amount),
+	)
+
+	user, err := s.getReferralUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	commission := &models.ReferralCommission{
+		ReferrerID: user.ID,
+		Type:       models.ReferralCommissionType(req.Type),
+		Amount:     req.Amount,
+		Note:       req.Note,
+		Operator:   req.Operator,
+	}
+	switch commission.Type {
+	case models.ReferralCommissionBalance:
+		if req.Currency == "" {
+			return nil, apierror.MissingField("currency")
+		}
+		commission.Currency = req.Currency
+		commission.Status = models.ReferralCommissionPending
+	case models.ReferralCommissionTraffic:
+		commission.Status = models.ReferralCommissionCredited
+	default:
+		return nil, apierror.InvalidField("type", "type must be one of balance, traffic")
+	}
+	if req.Amount == 0 {
+		return nil, apierror.MissingField("amount")
+	}
+	if req.Operator == "" {
+		return nil, apierror.MissingField("operator")
+	}
+	if len(req.Note) > 255 {
+		return nil, apierror.InvalidField("note", "note is too long")
+	}
+
+	if err := s.dbService.GetRepository().Referral.CreateAdjustment(commission); err != nil {
+		s.logger.Error("Failed to create referral adjustment", zap.Error(err), zap.Uint("user_id", user.ID))
+		return nil, status.Error(codes.Internal, "failed to create referral adjustment")
+	}
+
+	s.logger.Info("Referral adjustment created",
+		zap.Uint("user_id", user.ID),
+		zap.String("type", req.Type),
+		zap.Int64("amount", req.Amount),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.CreateReferralAdjustmentResponse{
+		Success:    true,
+		Message:    "referral adjustment created successfully",
+		Commission: convertReferralCommissionToProto(commission),
+	}, nil
+}
+
+// recordReferral links a new user to the owner of a referral code
+func (s *ManagementService) recordReferral(user *models.User, code *models.ReferralCode) {
+	referral := &models.Referral{ReferrerID: code.UserID, UserID: user.ID, Code: code.Code}
+	if err := s.dbService.GetRepository().Referral.CreateReferral(referral); err != nil {
+		// The account exists already, a lost referral must not fail the signup
+		s.logger.Error("Failed to record referral", zap.Error(err),
+			zap.Uint("user_id", user.ID), zap.Uint("referrer_id", code.UserID))
+		return
+	}
+	s.logger.Info("Referral recorded", zap.Uint("user_id", user.ID), zap.Uint("referrer_id", code.UserID))
+}
+
+// getReferralCode resolves the referral code given at signup
+func (s *ManagementService) getReferralCode(code string) (*models.ReferralCode, error) {
+	referralCode, err := s.dbService.GetRepository().Referral.GetCode(models.NormalizeReferralCode(code))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apierror.InvalidField("referral_code", "unknown referral code")
+	}
+	if err != nil {
+		s.logger.Error("Failed to get referral code", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get referral code")
+	}
+	return referralCode, nil
+}
+
+// getReferralUser gets the referrer named by a request
+func (s *ManagementService) getReferralUser(id string) (*models.User, error) {
+	if id == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
+	if err != nil {
+		return nil, apierror.NotFound(apierror.ResourceUser, id)
+	}
+	return user, nil
+}
+
+// convertReferralSettingsToProto converts referral settings to protobuf format
+func convertReferralSettingsToProto(settings *models.ReferralSettings) *pbv1.ReferralSettings {
+	info := &pbv1.ReferralSettings{
+		Enabled:        settings.Enabled,
+		CommissionType: string(settings.CommissionType),
+		CommissionRate: int32(settings.CommissionRate),
+		TrafficBonus:   settings.TrafficBonus,
+		FirstOrderOnly: settings.FirstOrderOnly,
+		UpdatedBy:      settings.UpdatedBy,
+	}
+	if !settings.UpdatedAt.IsZero() {
+		info.UpdatedAt = timestamppb.New(settings.UpdatedAt)
+	}
+	return info
+}
+
+// convertReferralCommissionToProto converts a referral commission to protobuf format
+func convertReferralCommissionToProto(commission *models.ReferralCommission) *pbv1.ReferralCommissionInfo {
+	info := &pbv1.ReferralCommissionInfo{
+		CommissionId: strconv.FormatUint(uint64(commission.ID), 10),
+		ReferrerId:   strconv.FormatUint(uint64(commission.ReferrerID), 10),
+		Type:         string(commission.Type),
+		Amount:       commission.Amount,
+		Currency:     commission.Currency,
+		Status:       string(commission.Status),
+		Note:         commission.Note,
+		Operator:     commission.Operator,
+		CreatedAt:    timestamppb.New(commission.CreatedAt),
+	}
+	if commission.ReferredUserID != 0 {
+		info.ReferredUserId = strconv.FormatUint(uint64(commission.ReferredUserID), 10)
+	}
+	if commission.OrderID != nil {
+		info.OrderId = strconv.FormatUint(uint64(*commission.OrderID), 10)
+	}
+	if commission.PaidAt != nil {
+		info.PaidAt = timestamppb.New(*commission.PaidAt)
+	}
+	return info
+}
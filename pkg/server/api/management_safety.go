@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+)
+
+// Global config keys backed by the safety policy
+const (
+	globalConfigEnvironment = "environment"
+	globalConfigSafetyRules = "safety_rules"
+)
+
+// Safety policy methods
+
+// checkSafety applies the safety policy to an operation affecting count
+// objects, returning a FailedPrecondition error when it is disabled or the
+// confirmation text does not match
+func (s *ManagementService) checkSafety(operation string, count int, confirmation string) error {
+	policy, err := s.dbService.GetRepository().SafetyPolicy.Get()
+	if err != nil {
+		s.logger.Error("Failed to get safety policy", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check safety policy")
+	}
+
+	err = policy.Check(operation, count, confirmation)
+	switch {
+	case errors.Is(err, models.ErrOperationDisabled):
+		s.logger.Warn("Operation blocked by safety policy",
+			zap.String("operation", operation),
+			zap.Int("count", count),
+			zap.String("environment", string(policy.Environment)),
+		)
+		return apierror.FailedPrecondition(apierror.ReasonOperationDisabled, operation, err.Error())
+	case errors.Is(err, models.ErrConfirmationRequired):
+		return apierror.FailedPrecondition(apierror.ReasonConfirmationRequired, operation, err.Error())
+	}
+	return nil
+}
+
+// batchSafetyOperation returns the safety operation name of a batch operation
+func batchSafetyOperation(prefix string, operation interface{ String() string }) string {
+	return prefix + strings.ToLower(operation.String())
+}
+
+// safetyPolicyConfig returns the global config entries of the safety policy
+func (s *ManagementService) safetyPolicyConfig() (map[string]string, error) {
+	policy, err := s.dbService.GetRepository().SafetyPolicy.Get()
+	if err != nil {
+		return nil, err
+	}
+	rules, err := json.Marshal(policy.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		globalConfigEnvironment: string(policy.Environment),
+		globalConfigSafetyRules: string(rules),
+	}, nil
+}
+
+// updateSafetyPolicy saves the safety policy entries of a global config
+// update; the policy is left alone when the update has none of them
+func (s *ManagementService) updateSafetyPolicy(config map[string]string, operator string) error {
+	environment, hasEnvironment := config[globalConfigEnvironment]
+	rules, hasRules := config[globalConfigSafetyRules]
+	if !hasEnvironment && !hasRules {
+		return nil
+	}
+	if operator == "" {
+		return apierror.MissingField("operator")
+	}
+
+	repo := s.dbService.GetRepository().SafetyPolicy
+	policy, err := repo.Get()
+	if err != nil {
+		s.logger.Error("Failed to get safety policy", zap.Error(err))
+		return status.Error(codes.Internal, "failed to update safety policy")
+	}
+
+	if hasEnvironment {
+		policy.Environment = models.Environment(strings.TrimSpace(environment))
+	}
+	if hasRules {
+		policy.Rules = nil
+		if err := json.Unmarshal([]byte(rules), &policy.Rules); err != nil {
+			return apierror.InvalidField("config."+globalConfigSafetyRules, "must be a JSON array of rules")
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		return validationError(err, "config.")
+	}
+
+	policy.UpdatedBy = operator
+	if err := repo.Save(policy); err != nil {
+		s.logger.Error("Failed to save safety policy", zap.Error(err))
+		return status.Error(codes.Internal, "failed to update safety policy")
+	}
+
+	s.logger.Info("Safety policy updated",
+		zap.String("environment", string(policy.Environment)),
+		zap.Int("rules", len(policy.Rules)),
+		zap.String("operator", operator),
+	)
+	return nil
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// savedFilterRequests creates an empty listing request of each entity, which
+// the filter JSON of a saved filter is read into
+var savedFilterRequests = map[models.SavedFilterEntity]func() proto.Message{
+	models.SavedFilterEntityUsers:   func() proto.Message { return &pbv1.ListUsersRequest{} },
+	models.SavedFilterEntityNodes:   func() proto.Message { return &pbv1.ListNodesRequest{} },
+	models.SavedFilterEntityTraffic: func() proto.Message { return &pbv1.GetUserTrafficRequest{} },
+}
+
+// savedFilterReservedFields cannot be part of a saved filter
+var savedFilterReservedFields = []string{"saved_filter_id", "admin_id"}
+
+// Saved filter methods
+
+func (s *ManagementService) CreateSavedFilter(ctx context.Context, req *pbv1.CreateSavedFilterRequest) (*pbv1.CreateSavedFilterResponse, error) {
+	adminID, err := parseAdminID(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Filter == nil {
+		return nil, apierror.MissingField("filter")
+	}
+	s.logger.Debug("CreateSavedFilter called", zap.Uint("admin_id", adminID), zap.String("name", req.Filter.Name))
+
+	filter := &models.SavedFilter{OwnerID: adminID, Entity: models.SavedFilterEntity(req.Filter.Entity)}
+	if err := s.applySavedFilterSpec(filter, req.Filter); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().SavedFilter.Create(filter); err != nil {
+		s.logger.Error("Failed to create saved filter", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create saved filter")
+	}
+
+	s.logger.Info("Saved filter created",
+		zap.Uint("filter_id", filter.ID),
+		zap.Uint("admin_id", adminID),
+		zap.String("entity", string(filter.Entity)),
+		zap.String("name", filter.Name),
+	)
+
+	return &pbv1.CreateSavedFilterResponse{
+		Success: true,
+		Message: "saved filter created successfully",
+		Filter:  convertSavedFilterToProto(filter, adminID),
+	}, nil
+}
+
+func (s *ManagementService) UpdateSavedFilter(ctx context.Context, req *pbv1.UpdateSavedFilterRequest) (*pbv1.UpdateSavedFilterResponse, error) {
+	s.logger.Debug("UpdateSavedFilter called", zap.String("filter_id", req.FilterId))
+
+	adminID, err := parseAdminID(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := s.getOwnedSavedFilter(req.FilterId, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Filter == nil {
+		return nil, apierror.MissingField("filter")
+	}
+	if req.Filter.Entity != "" && models.SavedFilterEntity(req.Filter.Entity) != filter.Entity {
+		return nil, apierror.InvalidField("filter.entity", "entity of a saved filter cannot be changed")
+	}
+	if err := s.applySavedFilterSpec(filter, req.Filter); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().SavedFilter.Update(filter); err != nil {
+		s.logger.Error("Failed to update saved filter", zap.Error(err), zap.String("filter_id", req.FilterId))
+		return nil, status.Error(codes.Internal, "failed to update saved filter")
+	}
+
+	s.logger.Info("Saved filter updated", zap.Uint("filter_id", filter.ID), zap.Uint("admin_id", adminID))
+
+	return &pbv1.UpdateSavedFilterResponse{
+		Success: true,
+		Message: "saved filter updated successfully",
+		Filter:  convertSavedFilterToProto(filter, adminID),
+	}, nil
+}
+
+func (s *ManagementService) DeleteSavedFilter(ctx context.Context, req *pbv1.DeleteSavedFilterRequest) (*pbv1.DeleteSavedFilterResponse, error) {
+	s.logger.Debug("DeleteSavedFilter called", zap.String("filter_id", req.FilterId))
+
+	adminID, err := parseAdminID(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := s.getOwnedSavedFilter(req.FilterId, adminID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().SavedFilter.Delete(filter.ID); err != nil {
+		s.logger.Error("Failed to delete saved filter", zap.Error(err), zap.String("filter_id", req.FilterId))
+		return nil, status.Error(codes.Internal, "failed to delete saved filter")
+	}
+
+	s.logger.Info("Saved filter deleted", zap.Uint("filter_id", filter.ID), zap.Uint("admin_id", adminID))
+
+	return &pbv1.DeleteSavedFilterResponse{
+		Success: true,
+		Message: "saved filter deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) ListSavedFilters(ctx context.Context, req *pbv1.ListSavedFiltersRequest) (*pbv1.ListSavedFiltersResponse, error) {
+	s.logger.Debug("ListSavedFilters called", zap.String("admin_id", req.AdminId), zap.String("entity", req.Entity))
+
+	adminID, err := parseAdminID(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	entity := models.SavedFilterEntity(req.Entity)
+	if entity != "" && !entity.IsValid() {
+		return nil, apierror.InvalidField("entity", "entity must be one of users, nodes, traffic")
+	}
+
+	filters, err := s.dbService.GetRepository().SavedFilter.ListVisible(adminID, entity)
+	if err != nil {
+		s.logger.Error("Failed to list saved filters", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list saved filters")
+	}
+
+	infos := make([]*pbv1.SavedFilterInfo, len(filters))
+	for i, filter := range filters {
+		infos[i] = convertSavedFilterToProto(filter, adminID)
+	}
+	return &pbv1.ListSavedFiltersResponse{Filters: infos}, nil
+}
+
+// applySavedFilter fills the fields of a listing request that the caller left
+// unset from a saved filter. Nothing happens when filterID is empty.
+func (s *ManagementService) applySavedFilter(req proto.Message, entity models.SavedFilterEntity, filterID, adminID string) error {
+	if filterID == "" {
+		return nil
+	}
+	id, err := parseAdminID(adminID)
+	if err != nil {
+		return err
+	}
+	filter, err := s.getSavedFilter(filterID, id)
+	if err != nil {
+		return err
+	}
+	if filter.Entity != entity {
+		return apierror.InvalidField("saved_filter_id", "saved filter belongs to the "+string(filter.Entity)+" list")
+	}
+
+	merged := req.ProtoReflect().New().Interface()
+	if filter.Filter != "" {
+		if err := protojson.Unmarshal([]byte(filter.Filter), merged); err != nil {
+			s.logger.Error("Failed to read saved filter", zap.Error(err), zap.Uint("filter_id", filter.ID))
+			return status.Error(codes.Internal, "failed to read saved filter")
+		}
+	}
+	proto.Merge(merged, req)
+	proto.Reset(req)
+	proto.Merge(req, merged)
+	return nil
+}
+
+// getSavedFilter parses the filter ID and loads a filter visible to the admin
+func (s *ManagementService) getSavedFilter(filterID string, adminID uint) (*models.SavedFilter, error) {
+	if filterID == "" {
+		return nil, apierror.MissingField("filter_id")
+	}
+	id, err := strconv.ParseUint(filterID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("filter_id", "invalid filter_id format")
+	}
+
+	filter, err := s.dbService.GetRepository().SavedFilter.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceSavedFilter, filterID)
+		}
+		s.logger.Error("Failed to get saved filter", zap.Error(err), zap.String("filter_id", filterID))
+		return nil, status.Error(codes.Internal, "failed to get saved filter")
+	}
+	// Private filters of other admins are not revealed
+	if !filter.VisibleTo(adminID) {
+		return nil, apierror.NotFound(apierror.ResourceSavedFilter, filterID)
+	}
+	return filter, nil
+}
+
+// getOwnedSavedFilter loads a filter the admin may change
+func (s *ManagementService) getOwnedSavedFilter(filterID string, adminID uint) (*models.SavedFilter, error) {
+	filter, err := s.getSavedFilter(filterID, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if filter.OwnerID != adminID {
+		return nil, apierror.FailedPrecondition(apierror.ReasonSavedFilterNotOwned, "saved_filter/"+filterID,
+			"only the owner can change a shared saved filter")
+	}
+	return filter, nil
+}
+
+// applySavedFilterSpec validates a saved filter spec and copies it onto the filter
+func (s *ManagementService) applySavedFilterSpec(filter *models.SavedFilter, spec *pbv1.SavedFilterSpec) error {
+	filter.Name = strings.TrimSpace(spec.Name)
+	filter.Sort = spec.Sort
+	filter.Columns = spec.Columns
+	filter.Shared = spec.Shared
+	filter.Filter = strings.TrimSpace(spec.FilterJson)
+	if err := validationError(filter.Validate(), "filter."); err != nil {
+		return err
+	}
+
+	if filter.Filter != "" {
+		request := savedFilterRequests[filter.Entity]()
+		if err := protojson.Unmarshal([]byte(filter.Filter), request); err != nil {
+			return apierror.InvalidField("filter.filter_json", "filter_json is not a valid "+string(filter.Entity)+" filter: "+err.Error())
+		}
+		fields := request.ProtoReflect().Descriptor().Fields()
+		for _, name := range savedFilterReservedFields {
+			if request.ProtoReflect().Has(fields.ByName(protoreflect.Name(name))) {
+				return apierror.InvalidField("filter.filter_json", "filter_json cannot contain "+name)
+			}
+		}
+	}
+
+	existing, err := s.dbService.GetRepository().SavedFilter.GetByName(filter.OwnerID, filter.Entity, filter.Name)
+	if err == nil && existing.ID != filter.ID {
+		return apierror.AlreadyExists(apierror.ResourceSavedFilter, apierror.ReasonSavedFilterNameTaken,
+			"saved filter name already exists", map[string]string{"name": filter.Name})
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to check saved filter name", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check saved filter name")
+	}
+	return nil
+}
+
+// convertSavedFilterToProto converts a saved filter as seen by an admin
+func convertSavedFilterToProto(filter *models.SavedFilter, adminID uint) *pbv1.SavedFilterInfo {
+	return &pbv1.SavedFilterInfo{
+		Id: strconv.FormatUint(uint64(filter.ID), 10),
+		Spec: &pbv1.SavedFilterSpec{
+			Name:       filter.Name,
+			Entity:     string(filter.Entity),
+			FilterJson: filter.Filter,
+			Sort:       filter.Sort,
+			Columns:    filter.Columns,
+			Shared:     filter.Shared,
+		},
+		OwnerId:   strconv.FormatUint(uint64(filter.OwnerID), 10),
+		Owned:     filter.OwnerID == adminID,
+		CreatedAt: timestamppb.New(filter.CreatedAt),
+		UpdatedAt: timestamppb.New(filter.UpdatedAt),
+	}
+}
+
+// parseAdminID parses the ID of the admin a request is made for
+func parseAdminID(adminID string) (uint, error) {
+	if adminID == "" {
+		return 0, apierror.MissingField("admin_id")
+	}
+	id, err := strconv.ParseUint(adminID, 10, 32)
+	if err != nil {
+		return 0, apierror.InvalidField("admin_id", "invalid admin_id format")
+	}
+	return uint(id), nil
+}
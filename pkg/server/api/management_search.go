@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// defaultSearchLimit is the number of results per group when none is requested
+	defaultSearchLimit = 5
+	// maxSearchLimit caps the results per group, the search only feeds an omnibox
+	maxSearchLimit = 20
+)
+
+// Search looks for the query across users, nodes and plans at once and groups
+// the matches by type. Types the admin lacks the permission of are left out,
+// and requesting only such types is denied.
+func (s *ManagementService) Search(ctx context.Context, req *pbv1.SearchRequest) (*pbv1.SearchResponse, error) {
+	adminID, err := parseAdminID(req.AdminId)
+	if err != nil {
+		return nil, err
+	}
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, apierror.MissingField("query")
+	}
+	s.logger.Debug("Search called", zap.Uint("admin_id", adminID), zap.String("query", query))
+
+	limit := int(req.Limit)
+	switch {
+	case limit < 0:
+		return nil, apierror.InvalidField("limit", "limit must not be negative")
+	case limit == 0:
+		limit = defaultSearchLimit
+	case limit > maxSearchLimit:
+		limit = maxSearchLimit
+	}
+
+	admin, err := s.dbService.GetRepository().User.GetByID(adminID)
+	if err != nil || !admin.Role.IsAdmin() {
+		return nil, status.Error(codes.PermissionDenied, "admin role required")
+	}
+	types, err := searchTypes(admin, req.Types)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*pbv1.SearchResultGroup, len(types))
+	errs := make([]error, len(types))
+	var wg sync.WaitGroup
+	for i, t := range types {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			groups[i], errs[i] = s.searchGroup(t, query, limit)
+		}()
+	}
+	wg.Wait()
+
+	resp := &pbv1.SearchResponse{}
+	for i, group := range groups {
+		if errs[i] != nil {
+			s.logger.Error("Failed to search", zap.Error(errs[i]), zap.String("type", string(types[i])))
+			return nil, status.Error(codes.Internal, "failed to search")
+		}
+		if group.Total > 0 {
+			resp.Groups = append(resp.Groups, group)
+		}
+	}
+	return resp, nil
+}
+
+// searchTypes resolves the requested types against the ones the admin may
+// search, all of them when none are requested
+func searchTypes(admin *models.User, requested []string) ([]models.SearchResultType, error) {
+	allowed := admin.SearchableTypes()
+	if len(requested) == 0 {
+		if len(allowed) == 0 {
+			return nil, status.Error(codes.PermissionDenied, "no searchable types permitted")
+		}
+		return allowed, nil
+	}
+
+	var types []models.SearchResultType
+	for _, name := range requested {
+		t := models.SearchResultType(name)
+		if !t.IsValid() {
+			return nil, apierror.InvalidField("types", fmt.Sprintf("unknown search type %q", name))
+		}
+		if !slices.Contains(allowed, t) {
+			return nil, status.Error(codes.PermissionDenied, "admin permission "+string(t.Permission())+" required")
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	// Keep the result order independent of the request order
+	slices.SortFunc(types, func(a, b models.SearchResultType) int {
+		return slices.Index(allowed, a) - slices.Index(allowed, b)
+	})
+	return types, nil
+}
+
+// searchGroup searches the entities of one type
+func (s *ManagementService) searchGroup(t models.SearchResultType, query string, limit int) (*pbv1.SearchResultGroup, error) {
+	repo := s.dbService.GetRepository()
+	group := &pbv1.SearchResultGroup{Type: string(t)}
+
+	switch t {
+	case models.SearchResultUsers:
+		users, total, err := repo.User.Search(query, 0, limit)
+		if err != nil {
+			return nil, err
+		}
+		group.Total = total
+		for _, user := range users {
+			group.Results = append(group.Results, &pbv1.SearchResult{
+				Id:       strconv.FormatUint(uint64(user.ID), 10),
+				Title:    user.Username,
+				Subtitle: user.Email,
+				Status:   string(user.Status),
+			})
+		}
+	case models.SearchResultNodes:
+		nodes, total, err := repo.Node.Search(query, 0, limit)
+		if err != nil {
+			return nil, err
+		}
+		group.Total = total
+		for _, node := range nodes {
+			group.Results = append(group.Results, &pbv1.SearchResult{
+				Id:       strconv.FormatUint(uint64(node.ID), 10),
+				Title:    node.Name,
+				Subtitle: fmt.Sprintf("%s:%d", node.Host, node.Port),
+				Status:   string(node.Status),
+			})
+		}
+	case models.SearchResultPlans:
+		plans, total, err := repo.Plan.Search(query, 0, limit)
+		if err != nil {
+			return nil, err
+		}
+		group.Total = total
+		for _, plan := range plans {
+			price := plan.GetCurrentPrice()
+			group.Results = append(group.Results, &pbv1.SearchResult{
+				Id:       strconv.FormatUint(uint64(plan.ID), 10),
+				Title:    plan.Name,
+				Subtitle: fmt.Sprintf("%d.%02d %s", price/100, price%100, plan.Currency),
+				Status:   string(plan.Status),
+			})
+		}
+	}
+	return group, nil
+}
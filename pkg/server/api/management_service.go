@@ -2,8 +2,11 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,30 +15,124 @@ import (
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/blocklist"
+	configv1 "sing-box-web/pkg/config/v1"
 	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/geodata"
+	"sing-box-web/pkg/mail"
 	"sing-box-web/pkg/models"
 	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+	"sing-box-web/pkg/settings"
 )
 
 // ManagementService implements the ManagementService gRPC service
 type ManagementService struct {
 	pbv1.UnimplementedManagementServiceServer
 
+	config    configv1.APIConfig
 	dbService *database.Service
 	logger    *zap.Logger
+
+	// Set by the API server; nil in the web server, which has neither
+	geoData    *geodata.Cache
+	agents     *AgentService
+	blocklists *blocklist.Updater
+
+	// mailer and accountTokens are set when outgoing mail is enabled
+	mailer        *mail.Mailer
+	accountTokens *auth.AccountTokens
+
+	// events receives the domain events of management calls, nil when unset
+	events *events.Bus
+
+	// Business settings, replaced when the configuration is reloaded
+	businessConfig atomic.Pointer[configv1.BusinessConfig]
+
+	// System settings admins change at runtime
+	settings *settings.Store
+
+	// twoFactorIssuer names the service in authenticator apps
+	twoFactorIssuer string
+
+	// overview is the latest system overview, see aggregateOverview
+	overview atomic.Pointer[pbv1.GetSystemOverviewResponse]
 }
 
 // NewManagementService creates a new ManagementService instance
-func NewManagementService(dbService *database.Service, logger *zap.Logger) *ManagementService {
-	return &ManagementService{
+func NewManagementService(config configv1.APIConfig, dbService *database.Service, logger *zap.Logger) *ManagementService {
+	s := &ManagementService{
+		config:    config,
 		dbService: dbService,
 		logger:    logger.Named("management-service"),
+		settings:  settings.NewStore(dbService.GetRepository().SystemSetting, logger),
+
+		twoFactorIssuer: defaultTwoFactorIssuer,
 	}
+	s.businessConfig.Store(&config.Business)
+	return s
+}
+
+// SetSettings reads and changes the system settings through store, sharing
+// its cache with the other readers of the process
+func (s *ManagementService) SetSettings(store *settings.Store) {
+	s.settings = store
+}
+
+// SetTwoFactorIssuer names the service in the provisioning URIs of 2FA
+// setups that do not name one themselves
+func (s *ManagementService) SetTwoFactorIssuer(issuer string) {
+	if issuer != "" {
+		s.twoFactorIssuer = issuer
+	}
+}
+
+// SetMailer sends the welcome and test mails through mailer
+func (s *ManagementService) SetMailer(mailer *mail.Mailer) {
+	s.mailer = mailer
+}
+
+// SetAccountTokens signs the email verification links sent to new users
+// and after email changes
+func (s *ManagementService) SetAccountTokens(tokens *auth.AccountTokens) {
+	s.accountTokens = tokens
+}
+
+// SetEvents publishes the domain events of management calls to bus
+func (s *ManagementService) SetEvents(bus *events.Bus) {
+	s.events = bus
+}
+
+// publishEvent publishes a domain event when an event bus is set
+func (s *ManagementService) publishEvent(event *pbv1.Event) {
+	if s.events != nil {
+		s.events.Publish(event)
+	}
+}
+
+// requireActiveAgents fails calls that queue agent commands where this
+// instance holds no agent command queues. Only the API server holds them,
+// what names the calls in the error of the web server.
+func (s *ManagementService) requireActiveAgents(what string) error {
+	if s.agents == nil {
+		return status.Error(codes.Unimplemented, what+" are only served by the API server")
+	}
+	if !s.agents.active() {
+		return apierror.New(codes.Unavailable, apierror.ReasonStandbyInstance, "this API instance is a standby", nil)
+	}
+	return nil
 }
 
 // Start starts the management service
 func (s *ManagementService) Start(ctx context.Context) error {
 	s.logger.Info("management service starting")
+
+	// Keep the system overview of the dashboards up to date
+	go s.aggregateOverview(ctx)
+
 	return nil
 }
 
@@ -50,6 +147,10 @@ func (s *ManagementService) Stop(ctx context.Context) error {
 func (s *ManagementService) ListNodes(ctx context.Context, req *pbv1.ListNodesRequest) (*pbv1.ListNodesResponse, error) {
 	s.logger.Debug("ListNodes called", zap.Any("request", req))
 
+	if err := s.applySavedFilter(req, models.SavedFilterEntityNodes, req.SavedFilterId, req.AdminId); err != nil {
+		return nil, err
+	}
+
 	// Set default values
 	page := req.Page
 	if page <= 0 {
@@ -62,8 +163,30 @@ func (s *ManagementService) ListNodes(ctx context.Context, req *pbv1.ListNodesRe
 
 	offset := (page - 1) * pageSize
 
+	filter := repository.NodeListFilter{
+		Region:     strings.TrimSpace(req.Region),
+		Keyword:    strings.TrimSpace(req.SearchKeyword),
+		OnlineOnly: req.OnlineOnly,
+		SortBy:     req.SortBy,
+	}
+	switch nodeStatus := models.NodeStatus(req.StatusFilter); nodeStatus {
+	case "", "all":
+	case models.NodeStatusOnline, models.NodeStatusDegraded, models.NodeStatusOffline, models.NodeStatusMaintenance, models.NodeStatusDisabled:
+		filter.Status = nodeStatus
+	default:
+		return nil, apierror.InvalidField("status_filter", "status_filter must be one of all, online, degraded, offline, maintenance, disabled")
+	}
+	desc, err := parseSort(req.SortBy, req.SortOrder, repository.NodeSortFields)
+	if err != nil {
+		return nil, err
+	}
+	filter.Desc = desc
+	if err := checkFieldMask(req.FieldMask, &pbv1.NodeInfo{}); err != nil {
+		return nil, err
+	}
+
 	// Get nodes from database
-	nodes, total, err := s.dbService.GetRepository().Node.List(int(offset), int(pageSize))
+	nodes, total, err := s.dbService.GetRepository().Node.ListFiltered(filter, int(offset), int(pageSize))
 	if err != nil {
 		s.logger.Error("Failed to list nodes", zap.Error(err))
 		return nil, status.Error(codes.Internal, "failed to list nodes")
@@ -73,6 +196,7 @@ func (s *ManagementService) ListNodes(ctx context.Context, req *pbv1.ListNodesRe
 	pbNodes := make([]*pbv1.NodeInfo, len(nodes))
 	for i, node := range nodes {
 		pbNodes[i] = s.convertNodeToProto(node)
+		applyFieldMask(req.FieldMask, pbNodes[i])
 	}
 
 	return &pbv1.ListNodesResponse{
@@ -87,20 +211,20 @@ func (s *ManagementService) GetNode(ctx context.Context, req *pbv1.GetNodeReques
 	s.logger.Debug("GetNode called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
 	}
 
 	// Get node from database
 	node, err := s.dbService.GetRepository().Node.GetByID(uint(nodeID))
 	if err != nil {
 		s.logger.Error("Failed to get node", zap.Error(err), zap.String("node_id", req.NodeId))
-		return nil, status.Error(codes.NotFound, "node not found")
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
 	}
 
 	return &pbv1.GetNodeResponse{
@@ -112,32 +236,35 @@ func (s *ManagementService) RemoveNode(ctx context.Context, req *pbv1.RemoveNode
 	s.logger.Debug("RemoveNode called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
 	}
 
 	// Check if node exists
 	node, err := s.dbService.GetRepository().Node.GetByID(uint(nodeID))
 	if err != nil {
-		return &pbv1.RemoveNodeResponse{
-			Success: false,
-			Message: "node not found",
-		}, nil
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
+	}
+
+	if err := s.checkSafety(models.SafetyOpNodeRemove, 1, req.Confirmation); err != nil {
+		return nil, err
 	}
 
 	// Delete the node
 	err = s.dbService.GetRepository().Node.Delete(node.ID)
 	if err != nil {
 		s.logger.Error("Failed to delete node", zap.Error(err), zap.String("node_id", req.NodeId))
-		return &pbv1.RemoveNodeResponse{
-			Success: false,
-			Message: "failed to delete node",
-		}, nil
+		return nil, apierror.Internal("failed to delete node")
+	}
+
+	// Removed nodes must not be able to reconnect with old tokens
+	if err := s.dbService.GetRepository().NodeToken.RevokeByNode(node.ID); err != nil {
+		s.logger.Error("Failed to revoke node tokens", zap.Error(err), zap.String("node_id", req.NodeId))
 	}
 
 	s.logger.Info("Node removed successfully", zap.String("node_id", req.NodeId), zap.String("name", node.Name))
@@ -152,80 +279,62 @@ func (s *ManagementService) UpdateNodeConfig(ctx context.Context, req *pbv1.Upda
 	s.logger.Debug("UpdateNodeConfig called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
 	}
 
 	// Get node from database
 	node, err := s.dbService.GetRepository().Node.GetByID(uint(nodeID))
 	if err != nil {
-		return &pbv1.UpdateNodeConfigResponse{
-			Success: false,
-			Message: "node not found",
-		}, nil
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
 	}
 
-	// Update node configuration
-	if req.ConfigContent != "" {
-		node.ConfigContent = req.ConfigContent
-	}
-
-	// Update node in database
-	err = s.dbService.GetRepository().Node.Update(node)
+	// Apply the configuration and record it in the node's config history
+	version, err := s.applyNodeConfig(node, req.ConfigContent, req.Operator, models.NodeConfigSourceUpdate, 0)
 	if err != nil {
-		s.logger.Error("Failed to update node config", zap.Error(err))
-		return &pbv1.UpdateNodeConfigResponse{
-			Success: false,
-			Message: "failed to update node config",
-		}, nil
+		return nil, err
 	}
 
-	s.logger.Info("Node config updated successfully", zap.String("node_id", req.NodeId))
-
 	return &pbv1.UpdateNodeConfigResponse{
-		Success: true,
-		Message: "node config updated successfully",
+		Success:       true,
+		Message:       "node config updated successfully",
+		ConfigVersion: strconv.Itoa(version.Version),
 	}, nil
 }
 
 // User management methods
 
+// hashPassword returns the hash a password is stored as
+func (s *ManagementService) hashPassword(password string) (string, error) {
+	hash, err := auth.HashPassword(password)
+	if errors.Is(err, auth.ErrPasswordTooLong) {
+		return "", apierror.InvalidField("password", err.Error())
+	}
+	if err != nil {
+		s.logger.Error("Failed to hash password", zap.Error(err))
+		return "", apierror.Internal("failed to hash password")
+	}
+	return hash, nil
+}
+
 func (s *ManagementService) CreateUser(ctx context.Context, req *pbv1.CreateUserRequest) (*pbv1.CreateUserResponse, error) {
 	s.logger.Debug("CreateUser called", zap.String("username", req.Username))
 
 	if req.Username == "" {
-		return nil, status.Error(codes.InvalidArgument, "username is required")
+		return nil, apierror.MissingField("username")
 	}
 
 	if req.Email == "" {
-		return nil, status.Error(codes.InvalidArgument, "email is required")
+		return nil, apierror.MissingField("email")
 	}
 
 	if req.Password == "" {
-		return nil, status.Error(codes.InvalidArgument, "password is required")
-	}
-
-	// Check if username already exists
-	if _, err := s.dbService.GetRepository().User.GetByUsername(req.Username); err == nil {
-		return &pbv1.CreateUserResponse{
-			Success: false,
-			Message: "username already exists",
-			User:    nil,
-		}, nil
-	}
-
-	// Check if email already exists
-	if _, err := s.dbService.GetRepository().User.GetByEmail(req.Email); err == nil {
-		return &pbv1.CreateUserResponse{
-			Success: false,
-			Message: "email already exists",
-			User:    nil,
-		}, nil
+		return nil, apierror.MissingField("password")
 	}
 
 	// Set default plan ID if not provided
@@ -238,25 +347,84 @@ func (s *ManagementService) CreateUser(ctx context.Context, req *pbv1.CreateUser
 	user := &models.User{
 		Username:     req.Username,
 		Email:        req.Email,
-		Password:     req.Password, // TODO: Hash password
 		DisplayName:  req.Username, // Use username as display name
 		Status:       models.UserStatusActive,
+		Role:         models.UserRoleUser,
 		PlanID:       planID,
 		TrafficQuota: 10737418240, // Default 10GB
 		DeviceLimit:  3,           // Default 3 devices
 		SpeedLimit:   0,           // No speed limit
 	}
 
-	err := s.dbService.GetRepository().User.Create(user)
+	if err := user.Validate(); err != nil {
+		return nil, validationError(err, "")
+	}
+
+	// Self-registration is checked against the blocklist
+	if req.ClientIp != "" {
+		if err := s.checkBlocklist(req.Email, req.ClientIp); err != nil {
+			return nil, err
+		}
+	}
+
+	// Self-registered users without a chosen plan start on the trial plan
+	if req.ClientIp != "" && req.PlanId == 0 {
+		plan, err := s.signupTrialPlan()
+		if err != nil {
+			return nil, err
+		}
+		if plan != nil {
+			user.ApplyPlan(plan, false, time.Now())
+		}
+	}
+
+	// Check if username already exists
+	if _, err := s.dbService.GetRepository().User.GetByUsername(req.Username); err == nil {
+		return nil, apierror.AlreadyExists(apierror.ResourceUser, apierror.ReasonUsernameTaken,
+			"username already exists", map[string]string{"username": req.Username})
+	}
+
+	// Check if email already exists
+	if _, err := s.dbService.GetRepository().User.GetByEmail(req.Email); err == nil {
+		return nil, apierror.AlreadyExists(apierror.ResourceUser, apierror.ReasonEmailTaken,
+			"email already exists", map[string]string{"email": req.Email})
+	}
+
+	var referralCode *models.ReferralCode
+	if req.ReferralCode != "" {
+		code, err := s.getReferralCode(req.ReferralCode)
+		if err != nil {
+			return nil, err
+		}
+		referralCode = code
+	}
+
+	hash, err := s.hashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = hash
+
+	err = s.dbService.GetRepository().User.Create(user)
 	if err != nil {
 		s.logger.Error("Failed to create user", zap.Error(err))
-		return &pbv1.CreateUserResponse{
-			Success: false,
-			Message: "failed to create user",
-			User:    nil,
-		}, nil
+		return nil, apierror.Internal("failed to create user")
 	}
 
+	if referralCode != nil {
+		s.recordReferral(user, referralCode)
+	}
+	s.sendWelcomeMail(user)
+	s.sendVerificationMail(user)
+	s.publishEvent(&pbv1.Event{
+		Type: events.TypeUserCreated,
+		User: &pbv1.UserEvent{
+			UserId:   strconv.FormatUint(uint64(user.ID), 10),
+			Username: user.Username,
+			Email:    user.Email,
+		},
+	})
+
 	s.logger.Info("User created successfully", zap.String("username", user.Username), zap.Uint("id", user.ID))
 
 	return &pbv1.CreateUserResponse{
@@ -270,28 +438,27 @@ func (s *ManagementService) UpdateUser(ctx context.Context, req *pbv1.UpdateUser
 	s.logger.Debug("UpdateUser called", zap.String("user_id", req.UserId))
 
 	if req.UserId == "" {
-		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+		return nil, apierror.MissingField("user_id")
 	}
 
 	// Parse user ID
 	userID, err := strconv.ParseUint(req.UserId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid user_id format")
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
 	}
 
 	// Get existing user
 	user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
 	if err != nil {
-		return &pbv1.UpdateUserResponse{
-			Success: false,
-			Message: "user not found",
-			User:    nil,
-		}, nil
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
 	}
 
-	// Update user fields
-	if req.Email != "" {
+	// Update user fields; a new email address has to be verified again
+	emailChanged := req.Email != "" && req.Email != user.Email
+	if emailChanged {
 		user.Email = req.Email
+		user.EmailVerified = false
+		user.EmailVerifiedAt = nil
 	}
 	if req.Username != "" {
 		user.Username = req.Username
@@ -300,22 +467,34 @@ func (s *ManagementService) UpdateUser(ctx context.Context, req *pbv1.UpdateUser
 	if req.PlanId > 0 {
 		user.PlanID = uint(req.PlanId)
 	}
-	if req.Status != "" {
+	if req.Status != "" && models.UserStatus(req.Status) != user.Status {
+		// Reactivating an account counts as activity, restarting the
+		// inactive account policy
+		user.InactivitySuspendedAt = nil
+		if models.UserStatus(req.Status) == models.UserStatusActive {
+			now := time.Now()
+			user.LastActivityAt = &now
+		}
 		user.Status = models.UserStatus(req.Status)
 	}
 	if req.Password != "" {
-		user.Password = req.Password // TODO: Hash password
+		if user.Password, err = s.hashPassword(req.Password); err != nil {
+			return nil, err
+		}
+	}
+	if err := user.Validate(); err != nil {
+		return nil, validationError(err, "")
 	}
 
 	// Update user in database
 	err = s.dbService.GetRepository().User.Update(user)
 	if err != nil {
 		s.logger.Error("Failed to update user", zap.Error(err))
-		return &pbv1.UpdateUserResponse{
-			Success: false,
-			Message: "failed to update user",
-			User:    nil,
-		}, nil
+		return nil, apierror.Internal("failed to update user")
+	}
+
+	if emailChanged {
+		s.sendVerificationMail(user)
 	}
 
 	s.logger.Info("User updated successfully", zap.String("user_id", req.UserId), zap.String("username", user.Username))
@@ -331,32 +510,30 @@ func (s *ManagementService) DeleteUser(ctx context.Context, req *pbv1.DeleteUser
 	s.logger.Debug("DeleteUser called", zap.String("user_id", req.UserId))
 
 	if req.UserId == "" {
-		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+		return nil, apierror.MissingField("user_id")
 	}
 
 	// Parse user ID
 	userID, err := strconv.ParseUint(req.UserId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid user_id format")
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
 	}
 
 	// Check if user exists
 	user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
 	if err != nil {
-		return &pbv1.DeleteUserResponse{
-			Success: false,
-			Message: "user not found",
-		}, nil
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+
+	if err := s.checkSafety(models.SafetyOpUserDelete, 1, req.Confirmation); err != nil {
+		return nil, err
 	}
 
 	// Delete user
 	err = s.dbService.GetRepository().User.Delete(user.ID)
 	if err != nil {
 		s.logger.Error("Failed to delete user", zap.Error(err))
-		return &pbv1.DeleteUserResponse{
-			Success: false,
-			Message: "failed to delete user",
-		}, nil
+		return nil, apierror.Internal("failed to delete user")
 	}
 
 	s.logger.Info("User deleted successfully", zap.String("user_id", req.UserId), zap.String("username", user.Username))
@@ -367,34 +544,101 @@ func (s *ManagementService) DeleteUser(ctx context.Context, req *pbv1.DeleteUser
 	}, nil
 }
 
+// SetUserInactivityExempt exempts a user from the inactive account policy,
+// or subjects it again
+func (s *ManagementService) SetUserInactivityExempt(ctx context.Context, req *pbv1.SetUserInactivityExemptRequest) (*pbv1.SetUserInactivityExemptResponse, error) {
+	s.logger.Debug("SetUserInactivityExempt called", zap.String("user_id", req.UserId), zap.Bool("exempt", req.Exempt))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	repo := s.dbService.GetRepository().User
+	if _, err := repo.GetByID(uint(userID)); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+
+	if err := repo.SetInactivityExempt(uint(userID), req.Exempt); err != nil {
+		s.logger.Error("Failed to update inactivity exemption", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, apierror.Internal("failed to update inactivity exemption")
+	}
+
+	message := "user subjected to the inactivity policy"
+	if req.Exempt {
+		message = "user exempted from the inactivity policy"
+	}
+	return &pbv1.SetUserInactivityExemptResponse{Success: true, Message: message}, nil
+}
+
 func (s *ManagementService) GetUser(ctx context.Context, req *pbv1.GetUserRequest) (*pbv1.GetUserResponse, error) {
 	s.logger.Debug("GetUser called", zap.String("user_id", req.UserId))
 
 	if req.UserId == "" {
-		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+		return nil, apierror.MissingField("user_id")
 	}
 
 	// Parse user ID
 	userID, err := strconv.ParseUint(req.UserId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid user_id format")
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
 	}
 
 	// Get user from database
 	user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
 	if err != nil {
 		s.logger.Error("Failed to get user", zap.Error(err), zap.String("user_id", req.UserId))
-		return nil, status.Error(codes.NotFound, "user not found")
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+
+	limited, err := s.limitedExperience([]uint{user.ID})
+	if err != nil {
+		return nil, err
+	}
+	online, err := s.onlineUsers([]uint{user.ID})
+	if err != nil {
+		return nil, err
 	}
+	pbUser := s.convertUserToProto(user)
+	pbUser.LimitedExperience = limited[user.ID]
+	pbUser.Online = online[user.ID]
 
 	return &pbv1.GetUserResponse{
-		User: s.convertUserToProto(user),
+		User: pbUser,
 	}, nil
 }
 
 func (s *ManagementService) ListUsers(ctx context.Context, req *pbv1.ListUsersRequest) (*pbv1.ListUsersResponse, error) {
 	s.logger.Debug("ListUsers called", zap.Any("request", req))
 
+	if err := s.applySavedFilter(req, models.SavedFilterEntityUsers, req.SavedFilterId, req.AdminId); err != nil {
+		return nil, err
+	}
+	filter := repository.UserListFilter{Keyword: strings.TrimSpace(req.SearchKeyword), SortBy: req.SortBy}
+	if req.StatusFilter != "" && req.StatusFilter != "all" {
+		filter.Status = models.UserStatus(req.StatusFilter)
+		if !filter.Status.IsValid() {
+			return nil, apierror.InvalidField("status_filter", "status_filter must be one of all, active, suspended, expired, disabled")
+		}
+	}
+	if req.PlanId != "" {
+		planID, err := strconv.ParseUint(req.PlanId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("plan_id", "invalid plan_id format")
+		}
+		filter.PlanID = uint(planID)
+	}
+	desc, err := parseSort(req.SortBy, req.SortOrder, repository.UserSortFields)
+	if err != nil {
+		return nil, err
+	}
+	filter.Desc = desc
+	if err := checkFieldMask(req.FieldMask, &pbv1.UserInfo{}); err != nil {
+		return nil, err
+	}
+
 	// Set default values
 	page := req.Page
 	if page <= 0 {
@@ -408,16 +652,32 @@ func (s *ManagementService) ListUsers(ctx context.Context, req *pbv1.ListUsersRe
 	offset := (page - 1) * pageSize
 
 	// Get users from database
-	users, total, err := s.dbService.GetRepository().User.List(int(offset), int(pageSize))
+	users, total, err := s.dbService.GetRepository().User.ListFiltered(filter, int(offset), int(pageSize))
 	if err != nil {
 		s.logger.Error("Failed to list users", zap.Error(err))
 		return nil, status.Error(codes.Internal, "failed to list users")
 	}
 
+	userIDs := make([]uint, len(users))
+	for i, user := range users {
+		userIDs[i] = user.ID
+	}
+	limited, err := s.limitedExperience(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	online, err := s.onlineUsers(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert to protobuf format
 	pbUsers := make([]*pbv1.UserInfo, len(users))
 	for i, user := range users {
 		pbUsers[i] = s.convertUserToProto(user)
+		pbUsers[i].LimitedExperience = limited[user.ID]
+		pbUsers[i].Online = online[user.ID]
+		applyFieldMask(req.FieldMask, pbUsers[i])
 	}
 
 	return &pbv1.ListUsersResponse{
@@ -433,14 +693,17 @@ func (s *ManagementService) ListUsers(ctx context.Context, req *pbv1.ListUsersRe
 func (s *ManagementService) GetUserTraffic(ctx context.Context, req *pbv1.GetUserTrafficRequest) (*pbv1.GetUserTrafficResponse, error) {
 	s.logger.Debug("GetUserTraffic called", zap.String("user_id", req.UserId))
 
+	if err := s.applySavedFilter(req, models.SavedFilterEntityTraffic, req.SavedFilterId, req.AdminId); err != nil {
+		return nil, err
+	}
 	if req.UserId == "" {
-		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+		return nil, apierror.MissingField("user_id")
 	}
 
 	// Parse user ID
 	userID, err := strconv.ParseUint(req.UserId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid user_id format")
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
 	}
 
 	// Parse time range
@@ -463,17 +726,30 @@ func (s *ManagementService) GetUserTraffic(ctx context.Context, req *pbv1.GetUse
 		return nil, status.Error(codes.Internal, "failed to get user traffic")
 	}
 
+	// Corrections are reported next to the original records, never merged into them
+	totalAdjustment, err := s.dbService.GetRepository().TrafficAdjustment.SumByUser(uint(userID), startTime, endTime)
+	if err != nil {
+		s.logger.Error("Failed to sum traffic adjustments", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get user traffic")
+	}
+
 	// Calculate totals
 	var totalUpload, totalDownload int64
 	for _, record := range records {
 		totalUpload += record.Upload
 		totalDownload += record.Download
 	}
+	totalBilled := totalUpload + totalDownload + totalAdjustment
+	if totalBilled < 0 {
+		totalBilled = 0
+	}
 
 	return &pbv1.GetUserTrafficResponse{
-		TrafficData:   s.convertTrafficToProto(records),
-		TotalUpload:   totalUpload,
-		TotalDownload: totalDownload,
+		TrafficData:     s.convertTrafficToProto(records),
+		TotalUpload:     totalUpload,
+		TotalDownload:   totalDownload,
+		TotalAdjustment: totalAdjustment,
+		TotalBilled:     totalBilled,
 	}, nil
 }
 
@@ -481,13 +757,13 @@ func (s *ManagementService) GetNodeTraffic(ctx context.Context, req *pbv1.GetNod
 	s.logger.Debug("GetNodeTraffic called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
 	}
 
 	// Parse time range
@@ -530,36 +806,51 @@ func (s *ManagementService) GetNodeMetrics(ctx context.Context, req *pbv1.GetNod
 	s.logger.Debug("GetNodeMetrics called", zap.String("node_id", req.NodeId))
 
 	if req.NodeId == "" {
-		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+		return nil, apierror.MissingField("node_id")
 	}
 
 	// Parse node ID
 	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
 	if err != nil {
-		return nil, status.Error(codes.InvalidArgument, "invalid node_id format")
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
 	}
 
 	// Get node from database
 	node, err := s.dbService.GetRepository().Node.GetByID(uint(nodeID))
 	if err != nil {
 		s.logger.Error("Failed to get node", zap.Error(err), zap.String("node_id", req.NodeId))
-		return nil, status.Error(codes.NotFound, "node not found")
+		return nil, apierror.NotFound(apierror.ResourceNode, req.NodeId)
 	}
 
-	// Create current metrics from node data
-	timestamp := timestamppb.New(time.Now())
-	currentMetrics := &pbv1.MetricsData{
-		Timestamp:   timestamp,
-		CpuUsage:    node.CPUUsage,
-		MemoryUsage: node.MemoryUsage,
-		DiskUsage:   node.DiskUsage,
-		NetworkIn:   node.UploadTraffic,
-		NetworkOut:  node.DownloadTraffic,
-		Connections: int32(node.CurrentUsers),
+	// Historical metrics, defaulting to the last hour
+	end := time.Now()
+	if req.EndTime != nil {
+		end = req.EndTime.AsTime()
+	}
+	start := end.Add(-time.Hour)
+	if req.StartTime != nil {
+		start = req.StartTime.AsTime()
+	}
+	if !start.Before(end) {
+		return nil, apierror.InvalidField("start_time", "start_time must be before end_time")
 	}
 
-	// TODO: Get historical metrics data from time series database
-	metricsData := []*pbv1.MetricsData{currentMetrics}
+	resolution, err := s.metricsResolution(req.Granularity, start)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.dbService.GetRepository().Metrics.GetRange(uint(nodeID), resolution, start, end)
+	if err != nil {
+		s.logger.Error("Failed to get node metrics history", zap.Error(err), zap.String("node_id", req.NodeId))
+		return nil, status.Error(codes.Internal, "failed to get node metrics history")
+	}
+
+	timestamp := timestamppb.New(time.Now())
+	metricsData := make([]*pbv1.MetricsData, 0, len(history))
+	for _, sample := range history {
+		metricsData = append(metricsData, convertMetricsSampleToProto(sample))
+	}
 
 	return &pbv1.GetNodeMetricsResponse{
 		MetricsData:    metricsData,
@@ -567,9 +858,9 @@ func (s *ManagementService) GetNodeMetrics(ctx context.Context, req *pbv1.GetNod
 			CpuUsagePercent:       node.CPUUsage,
 			MemoryUsagePercent:    node.MemoryUsage,
 			DiskUsagePercent:      node.DiskUsage,
-			NetworkInBytesPerSec:  0, // TODO: Calculate per-second rates
-			NetworkOutBytesPerSec: 0, // TODO: Calculate per-second rates
-			ActiveConnections:     int32(node.CurrentUsers),
+			NetworkInBytesPerSec:  node.NetworkInRate,
+			NetworkOutBytesPerSec: node.NetworkOutRate,
+			ActiveConnections:     int32(node.ActiveConnections),
 			LoadAverage:           node.Load1,
 			Timestamp:             timestamp,
 		},
@@ -579,73 +870,11 @@ func (s *ManagementService) GetNodeMetrics(ctx context.Context, req *pbv1.GetNod
 func (s *ManagementService) GetSystemOverview(ctx context.Context, req *emptypb.Empty) (*pbv1.GetSystemOverviewResponse, error) {
 	s.logger.Debug("GetSystemOverview called")
 
-	// Get system statistics
-	stats, err := s.dbService.GetRepository().User.GetSystemStats()
-	if err != nil {
-		s.logger.Error("Failed to get system stats", zap.Error(err))
-		return nil, status.Error(codes.Internal, "failed to get system stats")
-	}
-
-	// Get node statistics
-	nodeStats, err := s.dbService.GetRepository().Node.GetNodeStats()
-	if err != nil {
-		s.logger.Error("Failed to get node stats", zap.Error(err))
-		return nil, status.Error(codes.Internal, "failed to get node stats")
-	}
-
-	// Get today's traffic
-	today := time.Now().Truncate(24 * time.Hour)
-	tomorrow := today.AddDate(0, 0, 1)
-	todayTraffic, err := s.dbService.GetRepository().Traffic.GetTotalTrafficInRange(today, tomorrow)
-	if err != nil {
-		s.logger.Error("Failed to get today's traffic", zap.Error(err))
-		todayTraffic = 0
-	}
-
-	// Get all nodes for summary
-	nodes, _, err := s.dbService.GetRepository().Node.List(0, 100) // Get first 100 nodes
+	overview, err := s.cachedOverview()
 	if err != nil {
-		s.logger.Error("Failed to get nodes for summary", zap.Error(err))
-		nodes = []*models.Node{}
+		return nil, status.Error(codes.Internal, "failed to get system overview")
 	}
-
-	// Convert nodes to node summaries
-	nodeSummaries := make([]*pbv1.NodeSummary, len(nodes))
-	var totalCPU, totalMemory float64
-	for i, node := range nodes {
-		nodeSummaries[i] = &pbv1.NodeSummary{
-			NodeId:          strconv.FormatUint(uint64(node.ID), 10),
-			NodeName:        node.Name,
-			Status:          string(node.Status),
-			UserCount:       int32(node.CurrentUsers),
-			ConnectionCount: int32(node.CurrentUsers), // TODO: Get actual connection count
-			CpuUsage:        node.CPUUsage,
-		}
-		totalCPU += node.CPUUsage
-		totalMemory += node.MemoryUsage
-	}
-
-	// Calculate averages
-	var avgCPU, avgMemory float64
-	if len(nodes) > 0 {
-		avgCPU = totalCPU / float64(len(nodes))
-		avgMemory = totalMemory / float64(len(nodes))
-	}
-
-	return &pbv1.GetSystemOverviewResponse{
-		Stats: &pbv1.SystemStats{
-			TotalNodes:        int32(nodeStats.TotalNodes),
-			OnlineNodes:       int32(nodeStats.OnlineNodes),
-			TotalUsers:        int32(stats.TotalUsers),
-			ActiveUsers:       int32(stats.ActiveUsers),
-			TotalTrafficToday: todayTraffic,
-			TotalConnections:  0, // TODO: Get connection count
-			AvgCpuUsage:       avgCPU,
-			AvgMemoryUsage:    avgMemory,
-		},
-		NodeSummaries: nodeSummaries,
-		RecentAlerts:  []*pbv1.AlertInfo{}, // TODO: Implement alerts
-	}, nil
+	return overview, nil
 }
 
 // Configuration management methods
@@ -653,39 +882,108 @@ func (s *ManagementService) GetSystemOverview(ctx context.Context, req *emptypb.
 func (s *ManagementService) UpdateGlobalConfig(ctx context.Context, req *pbv1.UpdateGlobalConfigRequest) (*pbv1.UpdateGlobalConfigResponse, error) {
 	s.logger.Debug("UpdateGlobalConfig called", zap.String("version", req.Version))
 
-	// TODO: Implement global config update logic with proper storage
-	// For now, just return success
-	newVersion := req.Version
-	if newVersion == "" {
-		newVersion = time.Now().Format("20060102150405")
+	// The safety policy keys are kept in their own table, the others are
+	// system settings
+	values := make(map[string]string, len(req.Config))
+	for key, value := range req.Config {
+		if key != globalConfigEnvironment && key != globalConfigSafetyRules {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+	if err := settings.Validate(values); err != nil {
+		return nil, validationError(err, "config.")
+	}
+	if len(values) > 0 && req.Operator == "" {
+		return nil, apierror.MissingField("operator")
 	}
 
-	s.logger.Info("Global config updated", zap.String("version", newVersion))
+	repo := s.dbService.GetRepository().SystemSetting
+	if req.Version != "" {
+		version, err := repo.LatestChangeID()
+		if err != nil {
+			s.logger.Error("Failed to get global config version", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to update global config")
+		}
+		if req.Version != strconv.FormatUint(uint64(version), 10) {
+			return nil, apierror.FailedPrecondition(apierror.ReasonGlobalConfigChanged, "global_config",
+				fmt.Sprintf("the global config changed since version %s, it is now at version %d", req.Version, version))
+		}
+	}
+
+	if err := s.updateSafetyPolicy(req.Config, req.Operator); err != nil {
+		return nil, err
+	}
 
-	return &pbv1.UpdateGlobalConfigResponse{
+	changes, err := s.settings.Set(values, req.Operator)
+	if err != nil {
+		s.logger.Error("Failed to save system settings", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to update global config")
+	}
+	version, err := repo.LatestChangeID()
+	if err != nil {
+		s.logger.Error("Failed to get global config version", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to update global config")
+	}
+
+	resp := &pbv1.UpdateGlobalConfigResponse{
 		Success:    true,
 		Message:    "global config updated successfully",
-		NewVersion: newVersion,
-	}, nil
+		NewVersion: strconv.FormatUint(uint64(version), 10),
+		Changes:    make([]*pbv1.GlobalSettingChange, len(changes)),
+	}
+	for i, change := range changes {
+		resp.Changes[i] = globalSettingChangeToProto(change)
+		s.logger.Info("System setting changed",
+			zap.String("key", change.Key),
+			zap.String("old_value", change.OldValue),
+			zap.String("new_value", change.NewValue),
+			zap.String("operator", req.Operator),
+		)
+	}
+	return resp, nil
 }
 
 func (s *ManagementService) GetGlobalConfig(ctx context.Context, req *emptypb.Empty) (*pbv1.GetGlobalConfigResponse, error) {
 	s.logger.Debug("GetGlobalConfig called")
 
-	// TODO: Implement global config retrieval logic with proper storage
-	// For now, return default config
-	config := map[string]string{
-		"log_level":         "info",
-		"max_connections":   "1000",
-		"traffic_limit":     "1TB",
-		"heartbeat_interval": "30s",
-		"backup_enabled":    "true",
+	repo := s.dbService.GetRepository().SystemSetting
+	stored, err := repo.List()
+	if err != nil {
+		s.logger.Error("Failed to list system settings", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get global config")
+	}
+	version, err := repo.LatestChangeID()
+	if err != nil {
+		s.logger.Error("Failed to get global config version", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get global config")
 	}
 
-	return &pbv1.GetGlobalConfigResponse{
-		Config:  config,
-		Version: "1.0.0",
-	}, nil
+	config := make(map[string]string, len(stored)+2)
+	byKey := make(map[string]*models.SystemSetting, len(stored))
+	for _, setting := range stored {
+		config[setting.Key] = setting.Value
+		byKey[setting.Key] = setting
+	}
+
+	safety, err := s.safetyPolicyConfig()
+	if err != nil {
+		s.logger.Error("Failed to get safety policy", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get global config")
+	}
+	for key, value := range safety {
+		config[key] = value
+	}
+
+	definitions := models.SettingDefinitions()
+	resp := &pbv1.GetGlobalConfigResponse{
+		Config:   config,
+		Version:  strconv.FormatUint(uint64(version), 10),
+		Settings: make([]*pbv1.GlobalSetting, len(definitions)),
+	}
+	for i, definition := range definitions {
+		resp.Settings[i] = globalSettingToProto(definition, byKey[definition.Key])
+	}
+	return resp, nil
 }
 
 // Batch operations
@@ -694,14 +992,24 @@ func (s *ManagementService) BatchUserOperation(ctx context.Context, req *pbv1.Ba
 	s.logger.Debug("BatchUserOperation called",
 		zap.String("operation", req.Operation.String()),
 		zap.Int("user_count", len(req.UserIds)),
+		zap.Bool("dry_run", req.DryRun),
 	)
 
 	if len(req.UserIds) == 0 {
-		return nil, status.Error(codes.InvalidArgument, "user_ids is required")
+		return nil, apierror.MissingField("user_ids")
+	}
+
+	// Dry runs change nothing, so only real runs are subject to the policy
+	if !req.DryRun {
+		operation := batchSafetyOperation(models.SafetyOpUserBatchPrefix, req.Operation)
+		if err := s.checkSafety(operation, len(req.UserIds), req.Confirmation); err != nil {
+			return nil, err
+		}
 	}
 
 	results := make([]*pbv1.OperationResult, len(req.UserIds))
 	successCount := 0
+	report := newDryRunReport()
 
 	for i, userID := range req.UserIds {
 		// Parse user ID
@@ -715,6 +1023,14 @@ func (s *ManagementService) BatchUserOperation(ctx context.Context, req *pbv1.Ba
 			continue
 		}
 
+		if req.DryRun {
+			results[i] = s.previewBatchUser(uint(id), userID, req.Operation, report)
+			if results[i].Success {
+				successCount++
+			}
+			continue
+		}
+
 		// Perform operation based on type
 		switch req.Operation {
 		case pbv1.BatchUserOperationRequest_DISABLE:
@@ -726,7 +1042,7 @@ func (s *ManagementService) BatchUserOperation(ctx context.Context, req *pbv1.Ba
 		case pbv1.BatchUserOperationRequest_DELETE:
 			err = s.dbService.GetRepository().User.Delete(uint(id))
 		default:
-			err = status.Error(codes.InvalidArgument, "unsupported operation")
+			err = apierror.InvalidField("operation", "unsupported operation")
 		}
 
 		if err != nil {
@@ -749,15 +1065,48 @@ func (s *ManagementService) BatchUserOperation(ctx context.Context, req *pbv1.Ba
 		zap.String("operation", req.Operation.String()),
 		zap.Int("success_count", successCount),
 		zap.Int("total_count", len(req.UserIds)),
+		zap.Bool("dry_run", req.DryRun),
 	)
 
+	if req.DryRun {
+		return &pbv1.BatchUserOperationResponse{
+			Success:      true,
+			Message:      fmt.Sprintf("dry run: %d/%d users would change", report.proto().AffectedCount, len(req.UserIds)),
+			Results:      results,
+			DryRunReport: report.proto(),
+		}, nil
+	}
+
+	// Per-user failures are reported in results, the batch itself succeeded
 	return &pbv1.BatchUserOperationResponse{
-		Success: successCount > 0,
+		Success: true,
 		Message: fmt.Sprintf("%d/%d operations completed successfully", successCount, len(req.UserIds)),
 		Results: results,
 	}, nil
 }
 
+// previewBatchUser reports what a batch operation would do to one user without writing
+func (s *ManagementService) previewBatchUser(id uint, userID string, operation pbv1.BatchUserOperationRequest_OperationType, report *dryRunReport) *pbv1.OperationResult {
+	user, err := s.dbService.GetRepository().User.GetByID(id)
+	if err != nil {
+		return &pbv1.OperationResult{UserId: userID, Success: false, Message: "user not found"}
+	}
+
+	switch operation {
+	case pbv1.BatchUserOperationRequest_DISABLE, pbv1.BatchUserOperationRequest_ENABLE,
+		pbv1.BatchUserOperationRequest_RESET_TRAFFIC, pbv1.BatchUserOperationRequest_DELETE:
+	default:
+		return &pbv1.OperationResult{UserId: userID, Success: false, Message: "unsupported operation"}
+	}
+
+	changes := previewUserOperation(user, operation)
+	if len(changes) == 0 {
+		return &pbv1.OperationResult{UserId: userID, Success: true, Message: "no change"}
+	}
+	report.add(userID, changes...)
+	return &pbv1.OperationResult{UserId: userID, Success: true, Message: "would be changed"}
+}
+
 // Helper functions for converting between models and protobuf
 
 func (s *ManagementService) convertNodeToProto(node *models.Node) *pbv1.NodeInfo {
@@ -766,6 +1115,15 @@ func (s *ManagementService) convertNodeToProto(node *models.Node) *pbv1.NodeInfo
 		lastSeen = timestamppb.New(*node.LastHeartbeat)
 	}
 
+	var witnessFlaggedAt *timestamppb.Timestamp
+	if node.WitnessFlaggedAt != nil {
+		witnessFlaggedAt = timestamppb.New(*node.WitnessFlaggedAt)
+	}
+	var witnessFlags []string
+	for _, finding := range node.WitnessFindings() {
+		witnessFlags = append(witnessFlags, string(finding))
+	}
+
 	return &pbv1.NodeInfo{
 		NodeId:        strconv.FormatUint(uint64(node.ID), 10),
 		NodeName:      node.Name,
@@ -775,6 +1133,11 @@ func (s *ManagementService) convertNodeToProto(node *models.Node) *pbv1.NodeInfo
 		LastSeen:      lastSeen,
 		UserCount:     int32(node.CurrentUsers),
 		ConfigVersion: strconv.Itoa(node.ConfigVersion),
+
+		WitnessFlags:     witnessFlags,
+		WitnessFlaggedAt: witnessFlaggedAt,
+		HealthScore:      node.HealthScore,
+		StatusReason:     node.StatusReason,
 	}
 }
 
@@ -784,6 +1147,16 @@ func (s *ManagementService) convertUserToProto(user *models.User) *pbv1.UserInfo
 		expiresAt = timestamppb.New(*user.ExpiresAt)
 	}
 
+	var subscriptionUpdatedAt *timestamppb.Timestamp
+	if user.SubscriptionUpdatedAt != nil {
+		subscriptionUpdatedAt = timestamppb.New(*user.SubscriptionUpdatedAt)
+	}
+
+	var inactivitySuspendedAt *timestamppb.Timestamp
+	if user.InactivitySuspendedAt != nil {
+		inactivitySuspendedAt = timestamppb.New(*user.InactivitySuspendedAt)
+	}
+
 	createdAt := timestamppb.New(user.CreatedAt)
 	updatedAt := timestamppb.New(user.UpdatedAt)
 
@@ -796,6 +1169,18 @@ func (s *ManagementService) convertUserToProto(user *models.User) *pbv1.UserInfo
 		CreatedAt: createdAt,
 		UpdatedAt: updatedAt,
 		ExpiresAt: expiresAt,
+
+		TwoFactorEnabled:      user.TwoFactorEnabled,
+		EmailVerified:         user.EmailVerified,
+		TelegramLinked:        user.TelegramChatID != "",
+		SubscriptionHash:      user.SubscriptionHash,
+		SubscriptionUpdatedAt: subscriptionUpdatedAt,
+		Balance:               user.Balance,
+		BalanceCurrency:       user.BalanceCurrency,
+
+		LastActiveAt:          timestamppb.New(user.LastActiveAt()),
+		InactivityExempt:      user.InactivityExempt,
+		InactivitySuspendedAt: inactivitySuspendedAt,
 	}
 }
 
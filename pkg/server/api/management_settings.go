@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// System setting methods
+
+// ListGlobalConfigHistory lists the changes of the system settings
+func (s *ManagementService) ListGlobalConfigHistory(ctx context.Context, req *pbv1.ListGlobalConfigHistoryRequest) (*pbv1.ListGlobalConfigHistoryResponse, error) {
+	s.logger.Debug("ListGlobalConfigHistory called", zap.String("key", req.Key))
+
+	if req.Key != "" {
+		if _, ok := models.LookupSetting(req.Key); !ok {
+			return nil, apierror.InvalidField("key", "unknown setting")
+		}
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	changes, total, err := s.dbService.GetRepository().SystemSetting.ListChanges(req.Key, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list system setting changes", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list global config history")
+	}
+
+	resp := &pbv1.ListGlobalConfigHistoryResponse{
+		Changes: make([]*pbv1.GlobalSettingChange, len(changes)),
+		Total:   int32(total),
+	}
+	for i, change := range changes {
+		resp.Changes[i] = globalSettingChangeToProto(change)
+	}
+	return resp, nil
+}
+
+// globalSettingToProto converts a system setting to protobuf, stored is nil
+// when the setting is unset
+func globalSettingToProto(definition models.SettingDefinition, stored *models.SystemSetting) *pbv1.GlobalSetting {
+	setting := &pbv1.GlobalSetting{
+		Key:         definition.Key,
+		Type:        string(definition.Type),
+		Description: definition.Description,
+	}
+	if stored != nil {
+		setting.Value = stored.Value
+		setting.Set = true
+		setting.UpdatedBy = stored.UpdatedBy
+		setting.UpdatedAt = timestamppb.New(stored.UpdatedAt)
+	}
+	return setting
+}
+
+// globalSettingChangeToProto converts a system setting change to protobuf
+func globalSettingChangeToProto(change *models.SystemSettingChange) *pbv1.GlobalSettingChange {
+	return &pbv1.GlobalSettingChange{
+		ChangeId:  strconv.FormatUint(uint64(change.ID), 10),
+		Key:       change.Key,
+		OldValue:  change.OldValue,
+		NewValue:  change.NewValue,
+		Operator:  change.Operator,
+		CreatedAt: timestamppb.New(change.CreatedAt),
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Speed limit shaping methods
+
+func (s *ManagementService) GetUserShapingStats(ctx context.Context, req *pbv1.GetUserShapingStatsRequest) (*pbv1.GetUserShapingStatsResponse, error) {
+	s.logger.Debug("GetUserShapingStats called", zap.String("user_id", req.UserId), zap.Int32("hours", req.Hours))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	hours := req.Hours
+	if hours < 0 {
+		return nil, apierror.InvalidField("hours", "hours must not be negative")
+	}
+	if hours == 0 {
+		hours = 24
+	}
+
+	if _, err := s.dbService.GetRepository().User.GetByID(uint(userID)); err != nil {
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+
+	now := time.Now()
+	repo := s.dbService.GetRepository().Shaping
+	ids := []uint{uint(userID)}
+	totals, err := repo.Summaries(ids, now.Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		s.logger.Error("Failed to get user shaping stats", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to get user shaping stats")
+	}
+	nodes, err := repo.NodeSummaries(uint(userID), now.Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		s.logger.Error("Failed to get user shaping stats per node", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to get user shaping stats")
+	}
+	limited, err := s.limitedExperience(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	total := totals[uint(userID)]
+	if total == nil {
+		total = &models.ShapingSummary{UserID: uint(userID)}
+	}
+	pbNodes := make([]*pbv1.ShapingSummary, len(nodes))
+	for i, node := range nodes {
+		pbNodes[i] = convertShapingSummaryToProto(node)
+	}
+
+	return &pbv1.GetUserShapingStatsResponse{
+		UserId:            req.UserId,
+		Total:             convertShapingSummaryToProto(total),
+		Nodes:             pbNodes,
+		LimitedExperience: limited[uint(userID)],
+	}, nil
+}
+
+// limitedExperience reports which of the users were noticeably throttled
+// within LimitedExperienceWindow
+func (s *ManagementService) limitedExperience(userIDs []uint) (map[uint]bool, error) {
+	summaries, err := s.dbService.GetRepository().Shaping.Summaries(userIDs, time.Now().Add(-models.LimitedExperienceWindow))
+	if err != nil {
+		s.logger.Error("Failed to get shaping summaries", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get shaping summaries")
+	}
+	limited := make(map[uint]bool, len(summaries))
+	for userID, summary := range summaries {
+		limited[userID] = summary.LimitedExperience()
+	}
+	return limited, nil
+}
+
+// convertShapingSummaryToProto converts a shaping summary to protobuf format
+func convertShapingSummaryToProto(summary *models.ShapingSummary) *pbv1.ShapingSummary {
+	info := &pbv1.ShapingSummary{
+		ThrottleEvents:   summary.ThrottleEvents,
+		ThrottledSeconds: summary.ThrottledSeconds,
+		DelayedBytes:     summary.DelayedBytes,
+		DroppedBytes:     summary.DroppedBytes,
+		LimitBytesPerSec: summary.LimitBytesPerSec,
+	}
+	if summary.NodeID != 0 {
+		info.NodeId = strconv.FormatUint(uint64(summary.NodeID), 10)
+	}
+	return info
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// maxSubscriptionGracePeriod bounds how long a replaced token keeps working
+	maxSubscriptionGracePeriod = 30 * 24 * time.Hour
+	// bulkRotationPageSize is the number of users loaded per page when rotating by plan or for everyone
+	bulkRotationPageSize = 500
+	// subscriptionAccessWindow is the window of the distinct addresses reported with the access history
+	subscriptionAccessWindow = 24 * time.Hour
+)
+
+// Subscription token rotation methods
+
+func (s *ManagementService) RotateSubscriptionToken(ctx context.Context, req *pbv1.RotateSubscriptionTokenRequest) (*pbv1.RotateSubscriptionTokenResponse, error) {
+	s.logger.Debug("RotateSubscriptionToken called", zap.String("user_id", req.UserId))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	grace, err := validateRotation(req.GracePeriodSeconds, req.Operator, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	token, rotation, err := s.rotateSubscriptionToken(uint(userID), grace, req.Operator, req.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+		}
+		s.logger.Error("Failed to rotate subscription token", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to rotate subscription token")
+	}
+
+	return &pbv1.RotateSubscriptionTokenResponse{
+		Success:           true,
+		Message:           "subscription token rotated successfully",
+		SubscriptionToken: token,
+		Rotation:          s.convertSubscriptionTokenRotationToProto(rotation),
+	}, nil
+}
+
+func (s *ManagementService) BulkRotateSubscriptionTokens(ctx context.Context, req *pbv1.BulkRotateSubscriptionTokensRequest) (*pbv1.BulkRotateSubscriptionTokensResponse, error) {
+	s.logger.Debug("BulkRotateSubscriptionTokens called",
+		zap.Int("user_count", len(req.UserIds)),
+		zap.String("plan_id", req.PlanId),
+		zap.Bool("all_users", req.AllUsers),
+		zap.Bool("dry_run", req.DryRun),
+	)
+
+	grace, err := validateRotation(req.GracePeriodSeconds, req.Operator, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err := s.bulkRotationTargets(req.UserIds, req.PlanId, req.AllUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DryRun {
+		report := newDryRunReport()
+		results := s.previewBulkRotation(userIDs, "subscription_token", report)
+		return &pbv1.BulkRotateSubscriptionTokensResponse{
+			Success:      true,
+			Message:      fmt.Sprintf("dry run: %d/%d tokens would be rotated", report.proto().AffectedCount, len(userIDs)),
+			Results:      results,
+			DryRunReport: report.proto(),
+		}, nil
+	}
+
+	results := make([]*pbv1.OperationResult, len(userIDs))
+	rotatedCount := 0
+	for i, userID := range userIDs {
+		id := strconv.FormatUint(uint64(userID), 10)
+		if _, _, err := s.rotateSubscriptionToken(userID, grace, req.Operator, req.Reason); err != nil {
+			message := "failed to rotate subscription token"
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				message = "user not found"
+			} else {
+				s.logger.Error("Failed to rotate subscription token", zap.Error(err), zap.String("user_id", id))
+			}
+			results[i] = &pbv1.OperationResult{UserId: id, Success: false, Message: message}
+			continue
+		}
+		results[i] = &pbv1.OperationResult{UserId: id, Success: true, Message: "subscription token rotated"}
+		rotatedCount++
+	}
+
+	s.logger.Info("Bulk subscription token rotation completed",
+		zap.Int("rotated_count", rotatedCount),
+		zap.Int("total_count", len(userIDs)),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.BulkRotateSubscriptionTokensResponse{
+		Success:      true,
+		Message:      "bulk rotation completed",
+		Results:      results,
+		RotatedCount: int32(rotatedCount),
+	}, nil
+}
+
+func (s *ManagementService) ListSubscriptionTokenRotations(ctx context.Context, req *pbv1.ListSubscriptionTokenRotationsRequest) (*pbv1.ListSubscriptionTokenRotationsResponse, error) {
+	s.logger.Debug("ListSubscriptionTokenRotations called", zap.String("user_id", req.UserId))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	rotations, total, err := s.dbService.GetRepository().SubscriptionToken.ListByUser(uint(userID), int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list subscription token rotations", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list subscription token rotations")
+	}
+
+	pbRotations := make([]*pbv1.SubscriptionTokenRotationInfo, len(rotations))
+	for i, rotation := range rotations {
+		pbRotations[i] = s.convertSubscriptionTokenRotationToProto(rotation)
+	}
+
+	return &pbv1.ListSubscriptionTokenRotationsResponse{
+		Rotations: pbRotations,
+		Total:     int32(total),
+		Page:      page,
+		PageSize:  pageSize,
+	}, nil
+}
+
+func (s *ManagementService) ListSubscriptionAccessLogs(ctx context.Context, req *pbv1.ListSubscriptionAccessLogsRequest) (*pbv1.ListSubscriptionAccessLogsResponse, error) {
+	s.logger.Debug("ListSubscriptionAccessLogs called", zap.String("user_id", req.UserId))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	repo := s.dbService.GetRepository().SubscriptionLog
+	logs, total, err := repo.ListByUser(uint(userID), int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list subscription access logs", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list subscription access logs")
+	}
+	distinctIPs, err := repo.CountDistinctIPs(uint(userID), time.Now().Add(-subscriptionAccessWindow))
+	if err != nil {
+		s.logger.Error("Failed to count subscription access addresses", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list subscription access logs")
+	}
+
+	pbLogs := make([]*pbv1.SubscriptionAccessLogInfo, len(logs))
+	for i, log := range logs {
+		pbLogs[i] = &pbv1.SubscriptionAccessLogInfo{
+			Id:            strconv.FormatUint(uint64(log.ID), 10),
+			UserId:        strconv.FormatUint(uint64(log.UserID), 10),
+			Ip:            log.IP,
+			UserAgent:     log.UserAgent,
+			PreviousToken: log.PreviousToken,
+			FetchedAt:     timestamppb.New(log.CreatedAt),
+		}
+	}
+
+	return &pbv1.ListSubscriptionAccessLogsResponse{
+		Logs:              pbLogs,
+		Total:             int32(total),
+		Page:              page,
+		PageSize:          pageSize,
+		RecentDistinctIps: int32(distinctIPs),
+	}, nil
+}
+
+// validateRotation checks the common rotation fields and returns the grace period
+func validateRotation(graceSeconds int64, operator, reason string) (time.Duration, error) {
+	if graceSeconds < 0 {
+		return 0, apierror.InvalidField("grace_period_seconds", "grace_period_seconds cannot be negative")
+	}
+	grace := time.Duration(graceSeconds) * time.Second
+	if grace > maxSubscriptionGracePeriod {
+		return 0, apierror.InvalidField("grace_period_seconds", "grace period cannot exceed 30 days")
+	}
+	if operator == "" {
+		return 0, apierror.MissingField("operator")
+	}
+	if len(reason) > 255 {
+		return 0, apierror.InvalidField("reason", "reason is too long")
+	}
+	return grace, nil
+}
+
+// bulkRotationTargets resolves the users selected by a bulk rotation
+// request, by ID, by plan or all of them
+func (s *ManagementService) bulkRotationTargets(ids []string, planID string, allUsers bool) ([]uint, error) {
+	selectors := 0
+	for _, set := range []bool{len(ids) > 0, planID != "", allUsers} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors == 0 {
+		return nil, apierror.MissingField("user_ids")
+	}
+	if selectors > 1 {
+		return nil, apierror.InvalidField("user_ids", "only one of user_ids, plan_id and all_users may be set")
+	}
+
+	if len(ids) > 0 {
+		userIDs := make([]uint, len(ids))
+		for i, userID := range ids {
+			id, err := strconv.ParseUint(userID, 10, 32)
+			if err != nil {
+				return nil, apierror.InvalidField("user_ids", "invalid user ID format: "+userID)
+			}
+			userIDs[i] = uint(id)
+		}
+		return userIDs, nil
+	}
+
+	list := s.dbService.GetRepository().User.List
+	if planID != "" {
+		id, err := strconv.ParseUint(planID, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("plan_id", "invalid plan_id format")
+		}
+		list = func(offset, limit int) ([]*models.User, int64, error) {
+			return s.dbService.GetRepository().User.ListByPlanID(uint(id), offset, limit)
+		}
+	}
+
+	var userIDs []uint
+	for offset := 0; ; offset += bulkRotationPageSize {
+		users, _, err := list(offset, bulkRotationPageSize)
+		if err != nil {
+			s.logger.Error("Failed to list users for bulk rotation", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to list users")
+		}
+		for _, user := range users {
+			userIDs = append(userIDs, user.ID)
+		}
+		if len(users) < bulkRotationPageSize {
+			return userIDs, nil
+		}
+	}
+}
+
+// previewBulkRotation reports the users a bulk rotation of a field would
+// reach without rotating
+func (s *ManagementService) previewBulkRotation(userIDs []uint, field string, report *dryRunReport) []*pbv1.OperationResult {
+	results := make([]*pbv1.OperationResult, len(userIDs))
+	for i, userID := range userIDs {
+		id := strconv.FormatUint(uint64(userID), 10)
+		if _, err := s.dbService.GetRepository().User.GetByID(userID); err != nil {
+			results[i] = &pbv1.OperationResult{UserId: id, Success: false, Message: "user not found"}
+			continue
+		}
+		report.add(id, &pbv1.DryRunChange{Field: field, Before: "current", After: "rotated"})
+		results[i] = &pbv1.OperationResult{UserId: id, Success: true, Message: "would be rotated"}
+	}
+	return results
+}
+
+// rotateSubscriptionToken replaces the user's token, keeping the old one valid for the grace period
+func (s *ManagementService) rotateSubscriptionToken(userID uint, grace time.Duration, operator, reason string) (string, *models.SubscriptionTokenRotation, error) {
+	token := models.NewSubscriptionToken()
+	rotation := &models.SubscriptionTokenRotation{
+		UserID:     userID,
+		GraceUntil: time.Now().Add(grace),
+		Operator:   operator,
+		Reason:     reason,
+	}
+
+	if err := s.dbService.GetRepository().SubscriptionToken.Rotate(rotation, token); err != nil {
+		return "", nil, err
+	}
+
+	s.logger.Info("subscription token rotated",
+		zap.Uint("rotation_id", rotation.ID),
+		zap.Uint("user_id", userID),
+		zap.Time("grace_until", rotation.GraceUntil),
+		zap.String("operator", operator),
+		zap.String("reason", reason),
+	)
+	return token, rotation, nil
+}
+
+// convertSubscriptionTokenRotationToProto converts a rotation record to protobuf
+func (s *ManagementService) convertSubscriptionTokenRotationToProto(rotation *models.SubscriptionTokenRotation) *pbv1.SubscriptionTokenRotationInfo {
+	return &pbv1.SubscriptionTokenRotationInfo{
+		Id:            strconv.FormatUint(uint64(rotation.ID), 10),
+		UserId:        strconv.FormatUint(uint64(rotation.UserID), 10),
+		Operator:      rotation.Operator,
+		Reason:        rotation.Reason,
+		GraceUntil:    timestamppb.New(rotation.GraceUntil),
+		InGracePeriod: rotation.InGracePeriod(),
+		CreatedAt:     timestamppb.New(rotation.CreatedAt),
+	}
+}
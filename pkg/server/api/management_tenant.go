@@ -0,0 +1,308 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// maxTenantNameLength matches the size of the name column
+const maxTenantNameLength = 64
+
+// Tenant methods
+
+func (s *ManagementService) CreateTenant(ctx context.Context, req *pbv1.CreateTenantRequest) (*pbv1.CreateTenantResponse, error) {
+	if req.Tenant == nil {
+		return nil, apierror.MissingField("tenant")
+	}
+	s.logger.Debug("CreateTenant called", zap.String("name", req.Tenant.Name))
+
+	tenant := &models.Tenant{}
+	if err := s.applyTenantSpec(tenant, req.Tenant); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Tenant.Create(tenant); err != nil {
+		s.logger.Error("Failed to create tenant", zap.Error(err), zap.String("name", tenant.Name))
+		return nil, status.Error(codes.Internal, "failed to create tenant")
+	}
+
+	s.logger.Info("Tenant created", zap.Uint("tenant_id", tenant.ID), zap.String("name", tenant.Name))
+
+	info, err := s.tenantInfo(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.CreateTenantResponse{
+		Success: true,
+		Message: "tenant created successfully",
+		Tenant:  info,
+	}, nil
+}
+
+func (s *ManagementService) UpdateTenant(ctx context.Context, req *pbv1.UpdateTenantRequest) (*pbv1.UpdateTenantResponse, error) {
+	s.logger.Debug("UpdateTenant called", zap.String("tenant_id", req.TenantId))
+
+	tenant, err := s.getTenant(req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+	if req.Tenant == nil {
+		return nil, apierror.MissingField("tenant")
+	}
+	if err := s.applyTenantSpec(tenant, req.Tenant); err != nil {
+		return nil, err
+	}
+
+	if err := s.dbService.GetRepository().Tenant.Update(tenant); err != nil {
+		s.logger.Error("Failed to update tenant", zap.Error(err), zap.String("tenant_id", req.TenantId))
+		return nil, status.Error(codes.Internal, "failed to update tenant")
+	}
+
+	s.logger.Info("Tenant updated", zap.Uint("tenant_id", tenant.ID), zap.String("name", tenant.Name))
+
+	info, err := s.tenantInfo(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.UpdateTenantResponse{
+		Success: true,
+		Message: "tenant updated successfully",
+		Tenant:  info,
+	}, nil
+}
+
+func (s *ManagementService) DeleteTenant(ctx context.Context, req *pbv1.DeleteTenantRequest) (*pbv1.DeleteTenantResponse, error) {
+	s.logger.Debug("DeleteTenant called", zap.String("tenant_id", req.TenantId))
+
+	tenant, err := s.getTenant(req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSafety(models.SafetyOpTenantDelete, 1, req.Confirmation); err != nil {
+		return nil, err
+	}
+
+	// The tenant's users stay and fall back to the default branding
+	if err := s.dbService.GetRepository().Tenant.Delete(tenant.ID); err != nil {
+		s.logger.Error("Failed to delete tenant", zap.Error(err), zap.String("tenant_id", req.TenantId))
+		return nil, status.Error(codes.Internal, "failed to delete tenant")
+	}
+
+	s.logger.Info("Tenant deleted", zap.Uint("tenant_id", tenant.ID), zap.String("name", tenant.Name))
+
+	return &pbv1.DeleteTenantResponse{
+		Success: true,
+		Message: "tenant deleted successfully",
+	}, nil
+}
+
+func (s *ManagementService) GetTenant(ctx context.Context, req *pbv1.GetTenantRequest) (*pbv1.GetTenantResponse, error) {
+	s.logger.Debug("GetTenant called", zap.String("tenant_id", req.TenantId))
+
+	tenant, err := s.getTenant(req.TenantId)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.tenantInfo(tenant)
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.GetTenantResponse{Tenant: info}, nil
+}
+
+func (s *ManagementService) ListTenants(ctx context.Context, req *pbv1.ListTenantsRequest) (*pbv1.ListTenantsResponse, error) {
+	s.logger.Debug("ListTenants called",
+		zap.Int32("page", req.Page),
+		zap.Int32("page_size", req.PageSize),
+	)
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	tenants, total, err := s.dbService.GetRepository().Tenant.List(int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list tenants", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list tenants")
+	}
+
+	pbTenants := make([]*pbv1.TenantInfo, len(tenants))
+	for i, tenant := range tenants {
+		info, err := s.tenantInfo(tenant)
+		if err != nil {
+			return nil, err
+		}
+		pbTenants[i] = info
+	}
+
+	return &pbv1.ListTenantsResponse{
+		Tenants:  pbTenants,
+		Total:    int32(total),
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+func (s *ManagementService) AssignUsersToTenant(ctx context.Context, req *pbv1.AssignUsersToTenantRequest) (*pbv1.AssignUsersToTenantResponse, error) {
+	s.logger.Debug("AssignUsersToTenant called",
+		zap.String("tenant_id", req.TenantId),
+		zap.Int("user_count", len(req.UserIds)),
+	)
+
+	var tenantID *uint
+	if req.TenantId != "" {
+		tenant, err := s.getTenant(req.TenantId)
+		if err != nil {
+			return nil, err
+		}
+		tenantID = &tenant.ID
+	}
+	if len(req.UserIds) == 0 {
+		return nil, apierror.MissingField("user_ids")
+	}
+
+	userIDs := make([]uint, len(req.UserIds))
+	for i, userID := range req.UserIds {
+		id, err := strconv.ParseUint(userID, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("user_ids", "invalid user ID format: "+userID)
+		}
+		userIDs[i] = uint(id)
+	}
+
+	affected, err := s.dbService.GetRepository().Tenant.AssignUsers(tenantID, userIDs)
+	if err != nil {
+		s.logger.Error("Failed to assign users to tenant", zap.Error(err), zap.String("tenant_id", req.TenantId))
+		return nil, status.Error(codes.Internal, "failed to assign users to tenant")
+	}
+
+	s.logger.Info("Users assigned to tenant",
+		zap.String("tenant_id", req.TenantId),
+		zap.Int64("affected_users", affected),
+	)
+
+	return &pbv1.AssignUsersToTenantResponse{
+		Success:       true,
+		Message:       "users assigned successfully",
+		AffectedUsers: int32(affected),
+	}, nil
+}
+
+// getTenant parses the tenant ID and loads the tenant
+func (s *ManagementService) getTenant(tenantID string) (*models.Tenant, error) {
+	if tenantID == "" {
+		return nil, apierror.MissingField("tenant_id")
+	}
+	id, err := strconv.ParseUint(tenantID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("tenant_id", "invalid tenant_id format")
+	}
+
+	tenant, err := s.dbService.GetRepository().Tenant.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceTenant, tenantID)
+		}
+		s.logger.Error("Failed to get tenant", zap.Error(err), zap.String("tenant_id", tenantID))
+		return nil, status.Error(codes.Internal, "failed to get tenant")
+	}
+	return tenant, nil
+}
+
+// applyTenantSpec validates a tenant spec and copies it onto the tenant. An
+// empty name keeps the current one, so it is only required on creation.
+func (s *ManagementService) applyTenantSpec(tenant *models.Tenant, spec *pbv1.TenantSpec) error {
+	if spec.Name != "" && spec.Name != tenant.Name {
+		if err := s.checkTenantName(spec.Name, tenant.ID); err != nil {
+			return err
+		}
+		tenant.Name = spec.Name
+	}
+	if tenant.Name == "" {
+		return apierror.MissingField("tenant.name")
+	}
+	if len(spec.Description) > 255 {
+		return apierror.InvalidField("tenant.description", "description is too long")
+	}
+	tenant.Description = spec.Description
+
+	var branding models.Branding
+	if b := spec.Branding; b != nil {
+		branding = models.Branding{
+			PanelName:        b.PanelName,
+			LogoURL:          b.LogoUrl,
+			SupportEmail:     b.SupportEmail,
+			SupportURL:       b.SupportUrl,
+			SubscriptionHost: b.SubscriptionHost,
+		}
+	}
+	if err := branding.Validate(); err != nil {
+		return validationError(err, "tenant.branding.")
+	}
+	tenant.Branding = branding
+	return nil
+}
+
+// checkTenantName validates a tenant name and checks that no other tenant uses it
+func (s *ManagementService) checkTenantName(name string, excludeID uint) error {
+	if len(name) > maxTenantNameLength {
+		return apierror.InvalidField("tenant.name", "name is too long")
+	}
+
+	existing, err := s.dbService.GetRepository().Tenant.GetByName(name)
+	if err == nil && existing.ID != excludeID {
+		return apierror.AlreadyExists(apierror.ResourceTenant, apierror.ReasonTenantNameTaken,
+			"tenant name already exists", map[string]string{"name": name})
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Error("Failed to check tenant name", zap.Error(err))
+		return status.Error(codes.Internal, "failed to check tenant name")
+	}
+	return nil
+}
+
+// tenantInfo converts a tenant with its user count to protobuf
+func (s *ManagementService) tenantInfo(tenant *models.Tenant) (*pbv1.TenantInfo, error) {
+	users, err := s.dbService.GetRepository().Tenant.CountUsers(tenant.ID)
+	if err != nil {
+		s.logger.Error("Failed to count tenant users", zap.Error(err), zap.Uint("tenant_id", tenant.ID))
+		return nil, status.Error(codes.Internal, "failed to count tenant users")
+	}
+
+	return &pbv1.TenantInfo{
+		Id: strconv.FormatUint(uint64(tenant.ID), 10),
+		Spec: &pbv1.TenantSpec{
+			Name:        tenant.Name,
+			Description: tenant.Description,
+			Branding: &pbv1.Branding{
+				PanelName:        tenant.Branding.PanelName,
+				LogoUrl:          tenant.Branding.LogoURL,
+				SupportEmail:     tenant.Branding.SupportEmail,
+				SupportUrl:       tenant.Branding.SupportURL,
+				SubscriptionHost: tenant.Branding.SubscriptionHost,
+			},
+		},
+		UserCount: int32(users),
+		CreatedAt: timestamppb.New(tenant.CreatedAt),
+		UpdatedAt: timestamppb.New(tenant.UpdatedAt),
+	}, nil
+}
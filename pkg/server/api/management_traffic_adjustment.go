@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// maxAdjustmentReasonLength matches the size of the reason column
+const maxAdjustmentReasonLength = 512
+
+// Traffic adjustment methods
+
+func (s *ManagementService) CreateTrafficAdjustment(ctx context.Context, req *pbv1.CreateTrafficAdjustmentRequest) (*pbv1.CreateTrafficAdjustmentResponse, error) {
+	s.logger.Debug("CreateTrafficAdjustment called",
+		zap.String("user_id", req.UserId),
+		zap.Int64("bytes", req.Bytes),
+	)
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	if req.Bytes == 0 {
+		return nil, apierror.InvalidField("bytes", "bytes must not be zero")
+	}
+	if req.Reason == "" {
+		return nil, apierror.MissingField("reason")
+	}
+	if len(req.Reason) > maxAdjustmentReasonLength {
+		return nil, apierror.InvalidField("reason", "reason is too long")
+	}
+	if req.Operator == "" {
+		return nil, apierror.MissingField("operator")
+	}
+
+	adjustment := &models.TrafficAdjustment{
+		UserID:   uint(userID),
+		Bytes:    req.Bytes,
+		Reason:   req.Reason,
+		Operator: req.Operator,
+	}
+	if req.NodeId != "" {
+		nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+		if err != nil {
+			return nil, apierror.InvalidField("node_id", "invalid node_id format")
+		}
+		id := uint(nodeID)
+		adjustment.NodeID = &id
+	}
+	if req.EffectiveTime != nil {
+		adjustment.EffectiveDate = req.EffectiveTime.AsTime()
+	}
+
+	if err := s.dbService.GetRepository().TrafficAdjustment.Apply(adjustment); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+		}
+		s.logger.Error("Failed to apply traffic adjustment", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to apply traffic adjustment")
+	}
+
+	s.logger.Info("traffic adjustment applied",
+		zap.Uint("adjustment_id", adjustment.ID),
+		zap.String("user_id", req.UserId),
+		zap.Int64("bytes", adjustment.Bytes),
+		zap.Int64("usage_before", adjustment.UsageBefore),
+		zap.Int64("usage_after", adjustment.UsageAfter),
+		zap.String("operator", adjustment.Operator),
+		zap.String("reason", adjustment.Reason),
+	)
+
+	return &pbv1.CreateTrafficAdjustmentResponse{
+		Success:    true,
+		Message:    "traffic adjustment applied successfully",
+		Adjustment: s.convertTrafficAdjustmentToProto(adjustment),
+	}, nil
+}
+
+func (s *ManagementService) ListTrafficAdjustments(ctx context.Context, req *pbv1.ListTrafficAdjustmentsRequest) (*pbv1.ListTrafficAdjustmentsResponse, error) {
+	s.logger.Debug("ListTrafficAdjustments called", zap.String("user_id", req.UserId))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	// Default to the last 30 days
+	endTime := time.Now()
+	if req.EndTime != nil {
+		endTime = req.EndTime.AsTime()
+	}
+	startTime := endTime.AddDate(0, 0, -30)
+	if req.StartTime != nil {
+		startTime = req.StartTime.AsTime()
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	repo := s.dbService.GetRepository().TrafficAdjustment
+	adjustments, total, err := repo.ListByUser(uint(userID), startTime, endTime, int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list traffic adjustments", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list traffic adjustments")
+	}
+	totalBytes, err := repo.SumByUser(uint(userID), startTime, endTime)
+	if err != nil {
+		s.logger.Error("Failed to sum traffic adjustments", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list traffic adjustments")
+	}
+
+	pbAdjustments := make([]*pbv1.TrafficAdjustmentInfo, len(adjustments))
+	for i, adjustment := range adjustments {
+		pbAdjustments[i] = s.convertTrafficAdjustmentToProto(adjustment)
+	}
+
+	return &pbv1.ListTrafficAdjustmentsResponse{
+		Adjustments: pbAdjustments,
+		Total:       int32(total),
+		Page:        page,
+		PageSize:    pageSize,
+		TotalBytes:  totalBytes,
+	}, nil
+}
+
+// convertTrafficAdjustmentToProto converts a traffic adjustment to protobuf format
+func (s *ManagementService) convertTrafficAdjustmentToProto(adjustment *models.TrafficAdjustment) *pbv1.TrafficAdjustmentInfo {
+	info := &pbv1.TrafficAdjustmentInfo{
+		Id:            strconv.FormatUint(uint64(adjustment.ID), 10),
+		UserId:        strconv.FormatUint(uint64(adjustment.UserID), 10),
+		Bytes:         adjustment.Bytes,
+		Reason:        adjustment.Reason,
+		Operator:      adjustment.Operator,
+		UsageBefore:   adjustment.UsageBefore,
+		UsageAfter:    adjustment.UsageAfter,
+		EffectiveTime: timestamppb.New(adjustment.EffectiveDate),
+		CreatedAt:     timestamppb.New(adjustment.CreatedAt),
+	}
+	if adjustment.NodeID != nil {
+		info.NodeId = strconv.FormatUint(uint64(*adjustment.NodeID), 10)
+	}
+	return info
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// defaultTwoFactorIssuer is used when neither the caller nor the
+// configuration provides an issuer
+const defaultTwoFactorIssuer = "sing-box-web"
+
+// Two-factor authentication methods
+
+func (s *ManagementService) SetupTwoFactor(ctx context.Context, req *pbv1.SetupTwoFactorRequest) (*pbv1.SetupTwoFactorResponse, error) {
+	s.logger.Debug("SetupTwoFactor called", zap.String("user_id", req.UserId))
+
+	user, err := s.getTwoFactorUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, apierror.FailedPrecondition(apierror.ReasonTwoFactorEnabled, "user/"+req.UserId,
+			"two-factor authentication is already enabled")
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		s.logger.Error("Failed to generate TOTP secret", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to generate secret")
+	}
+
+	if err := s.dbService.GetRepository().User.SetTwoFactorSecret(user.ID, secret); err != nil {
+		s.logger.Error("Failed to store TOTP secret", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to store secret")
+	}
+
+	issuer := req.Issuer
+	if issuer == "" {
+		issuer = s.twoFactorIssuer
+	}
+
+	return &pbv1.SetupTwoFactorResponse{
+		Secret:          secret,
+		ProvisioningUri: auth.TOTPProvisioningURI(issuer, user.Username, secret),
+	}, nil
+}
+
+func (s *ManagementService) EnableTwoFactor(ctx context.Context, req *pbv1.EnableTwoFactorRequest) (*pbv1.EnableTwoFactorResponse, error) {
+	s.logger.Debug("EnableTwoFactor called", zap.String("user_id", req.UserId))
+
+	if req.Code == "" {
+		return nil, apierror.MissingField("code")
+	}
+
+	user, err := s.getTwoFactorUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TwoFactorEnabled {
+		return nil, apierror.FailedPrecondition(apierror.ReasonTwoFactorEnabled, "user/"+req.UserId,
+			"two-factor authentication is already enabled")
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, apierror.FailedPrecondition(apierror.ReasonTwoFactorSetupRequired, "user/"+req.UserId,
+			"two-factor setup has not been started")
+	}
+
+	step, valid, err := auth.TOTPStep(user.TwoFactorSecret, req.Code, time.Now())
+	if err != nil || !valid {
+		return nil, apierror.New(codes.InvalidArgument, apierror.ReasonInvalidTwoFactorCode, "invalid verification code", nil)
+	}
+
+	backupCodes, hashes, err := auth.GenerateBackupCodes(auth.BackupCodeCount)
+	if err != nil {
+		s.logger.Error("Failed to generate backup codes", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to generate backup codes")
+	}
+
+	if err := s.dbService.GetRepository().User.EnableTwoFactor(user.ID, hashes); err != nil {
+		s.logger.Error("Failed to enable two-factor", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to enable two-factor authentication")
+	}
+	// The code confirming the setup is not accepted again at the next login
+	if _, err := s.dbService.GetRepository().User.UseTwoFactorStep(user.ID, step); err != nil {
+		s.logger.Warn("Failed to record two-factor code", zap.Error(err), zap.String("user_id", req.UserId))
+	}
+
+	s.logger.Info("Two-factor authentication enabled", zap.String("user_id", req.UserId), zap.String("username", user.Username))
+
+	return &pbv1.EnableTwoFactorResponse{
+		Success:     true,
+		Message:     "two-factor authentication enabled",
+		BackupCodes: backupCodes,
+	}, nil
+}
+
+func (s *ManagementService) DisableTwoFactor(ctx context.Context, req *pbv1.DisableTwoFactorRequest) (*pbv1.DisableTwoFactorResponse, error) {
+	s.logger.Debug("DisableTwoFactor called", zap.String("user_id", req.UserId), zap.Bool("force", req.Force))
+
+	user, err := s.getTwoFactorUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TwoFactorEnabled {
+		return &pbv1.DisableTwoFactorResponse{
+			Success: true,
+			Message: "two-factor authentication is not enabled",
+		}, nil
+	}
+
+	if !req.Force {
+		if req.Code == "" {
+			return nil, apierror.MissingField("code")
+		}
+		if _, err := auth.ConsumeTwoFactorCode(s.dbService.GetRepository().User, user, req.Code, time.Now()); err != nil {
+			if !isInvalidTwoFactorCode(err) {
+				s.logger.Error("Failed to verify two-factor code", zap.Error(err), zap.String("user_id", req.UserId))
+				return nil, status.Error(codes.Internal, "failed to verify two-factor code")
+			}
+			return nil, apierror.New(codes.InvalidArgument, apierror.ReasonInvalidTwoFactorCode, "invalid verification code", nil)
+		}
+	}
+
+	if err := s.dbService.GetRepository().User.DisableTwoFactor(user.ID); err != nil {
+		s.logger.Error("Failed to disable two-factor", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to disable two-factor authentication")
+	}
+
+	s.logger.Info("Two-factor authentication disabled",
+		zap.String("user_id", req.UserId),
+		zap.String("username", user.Username),
+		zap.Bool("force", req.Force))
+
+	return &pbv1.DisableTwoFactorResponse{
+		Success: true,
+		Message: "two-factor authentication disabled",
+	}, nil
+}
+
+func (s *ManagementService) VerifyTwoFactor(ctx context.Context, req *pbv1.VerifyTwoFactorRequest) (*pbv1.VerifyTwoFactorResponse, error) {
+	s.logger.Debug("VerifyTwoFactor called", zap.String("user_id", req.UserId))
+
+	if req.Code == "" {
+		return nil, apierror.MissingField("code")
+	}
+
+	user, err := s.getTwoFactorUser(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.RequiresTwoFactor() {
+		return nil, apierror.FailedPrecondition(apierror.ReasonTwoFactorNotEnabled, "user/"+req.UserId,
+			"two-factor authentication is not enabled")
+	}
+
+	check, err := auth.ConsumeTwoFactorCode(s.dbService.GetRepository().User, user, req.Code, time.Now())
+	if err != nil {
+		if !isInvalidTwoFactorCode(err) {
+			s.logger.Error("Failed to verify two-factor code", zap.Error(err), zap.String("user_id", req.UserId))
+			return nil, status.Error(codes.Internal, "failed to verify two-factor code")
+		}
+		s.logger.Info("Two-factor verification failed", zap.String("user_id", req.UserId))
+		return &pbv1.VerifyTwoFactorResponse{
+			Valid:                false,
+			RemainingBackupCodes: int32(len(user.TwoFactorBackupCodes)),
+		}, nil
+	}
+
+	remaining := len(user.TwoFactorBackupCodes)
+	if check.BackupCodeUsed {
+		remaining = len(check.Remaining)
+	}
+	return &pbv1.VerifyTwoFactorResponse{
+		Valid:                true,
+		BackupCodeUsed:       check.BackupCodeUsed,
+		RemainingBackupCodes: int32(remaining),
+	}, nil
+}
+
+// isInvalidTwoFactorCode tells rejected codes from failures to check them
+func isInvalidTwoFactorCode(err error) bool {
+	return errors.Is(err, auth.ErrInvalidTOTPCode) || errors.Is(err, auth.ErrInvalidTOTPSecret)
+}
+
+// getTwoFactorUser parses the user ID and loads the user for 2FA operations
+func (s *ManagementService) getTwoFactorUser(id string) (*models.User, error) {
+	if id == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err), zap.String("user_id", id))
+		return nil, apierror.NotFound(apierror.ResourceUser, id)
+	}
+	return user, nil
+}
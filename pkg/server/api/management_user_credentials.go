@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// User credential regeneration methods
+
+func (s *ManagementService) RegenerateUserCredentials(ctx context.Context, req *pbv1.RegenerateUserCredentialsRequest) (*pbv1.RegenerateUserCredentialsResponse, error) {
+	s.logger.Debug("RegenerateUserCredentials called",
+		zap.String("user_id", req.UserId),
+		zap.Bool("rotate_subscription_token", req.RotateSubscriptionToken),
+	)
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	grace, err := validateRotation(req.GracePeriodSeconds, req.Operator, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	rotation, token, err := s.regenerateUserCredentials(uint(userID), req.RotateSubscriptionToken, grace, req.Operator, req.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+		}
+		s.logger.Error("Failed to regenerate user credentials", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, status.Error(codes.Internal, "failed to regenerate user credentials")
+	}
+
+	info, err := s.credentialRotationInfos(uint(userID), []*models.UserCredentialRotation{rotation})
+	if err != nil {
+		return nil, err
+	}
+	return &pbv1.RegenerateUserCredentialsResponse{
+		Success:           true,
+		Message:           "user credentials regenerated successfully",
+		Uuid:              rotation.NewUUID,
+		SubscriptionToken: token,
+		Rotation:          info[0],
+	}, nil
+}
+
+func (s *ManagementService) BulkRegenerateUserCredentials(ctx context.Context, req *pbv1.BulkRegenerateUserCredentialsRequest) (*pbv1.BulkRegenerateUserCredentialsResponse, error) {
+	s.logger.Debug("BulkRegenerateUserCredentials called",
+		zap.Int("user_count", len(req.UserIds)),
+		zap.String("plan_id", req.PlanId),
+		zap.Bool("all_users", req.AllUsers),
+		zap.Bool("dry_run", req.DryRun),
+	)
+
+	grace, err := validateRotation(req.GracePeriodSeconds, req.Operator, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs, err := s.bulkRotationTargets(req.UserIds, req.PlanId, req.AllUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DryRun {
+		report := newDryRunReport()
+		results := s.previewBulkRotation(userIDs, "uuid", report)
+		return &pbv1.BulkRegenerateUserCredentialsResponse{
+			Success:      true,
+			Message:      fmt.Sprintf("dry run: %d/%d credentials would be regenerated", report.proto().AffectedCount, len(userIDs)),
+			Results:      results,
+			DryRunReport: report.proto(),
+		}, nil
+	}
+
+	results := make([]*pbv1.OperationResult, len(userIDs))
+	regeneratedCount := 0
+	for i, userID := range userIDs {
+		id := strconv.FormatUint(uint64(userID), 10)
+		if _, _, err := s.regenerateUserCredentials(userID, req.RotateSubscriptionTokens, grace, req.Operator, req.Reason); err != nil {
+			message := "failed to regenerate credentials"
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				message = "user not found"
+			} else {
+				s.logger.Error("Failed to regenerate user credentials", zap.Error(err), zap.String("user_id", id))
+			}
+			results[i] = &pbv1.OperationResult{UserId: id, Success: false, Message: message}
+			continue
+		}
+		results[i] = &pbv1.OperationResult{UserId: id, Success: true, Message: "credentials regenerated"}
+		regeneratedCount++
+	}
+
+	s.logger.Info("Bulk credential regeneration completed",
+		zap.Int("regenerated_count", regeneratedCount),
+		zap.Int("total_count", len(userIDs)),
+		zap.String("operator", req.Operator),
+		zap.String("reason", req.Reason),
+	)
+
+	return &pbv1.BulkRegenerateUserCredentialsResponse{
+		Success:          true,
+		Message:          "bulk regeneration completed",
+		Results:          results,
+		RegeneratedCount: int32(regeneratedCount),
+	}, nil
+}
+
+func (s *ManagementService) ListUserCredentialRotations(ctx context.Context, req *pbv1.ListUserCredentialRotationsRequest) (*pbv1.ListUserCredentialRotationsResponse, error) {
+	s.logger.Debug("ListUserCredentialRotations called", zap.String("user_id", req.UserId))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	rotations, total, err := s.dbService.GetRepository().UserCredential.ListByUser(uint(userID), int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list user credential rotations", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list user credential rotations")
+	}
+	pbRotations, err := s.credentialRotationInfos(uint(userID), rotations)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.ListUserCredentialRotationsResponse{
+		Rotations: pbRotations,
+		Total:     int32(total),
+		Page:      page,
+		PageSize:  pageSize,
+	}, nil
+}
+
+// regenerateUserCredentials replaces the user's UUID, optionally rotating
+// the subscription token along, and pushes the new UUID to the nodes of the
+// user this instance serves. Nodes it misses get it from the credential
+// sync of the API server. The new subscription token is empty when it was
+// kept.
+func (s *ManagementService) regenerateUserCredentials(userID uint, rotateToken bool, grace time.Duration, operator, reason string) (*models.UserCredentialRotation, string, error) {
+	rotation := &models.UserCredentialRotation{
+		UserID:   userID,
+		NewUUID:  models.NewUserUUID(),
+		Operator: operator,
+		Reason:   reason,
+	}
+
+	var token string
+	if rotateToken {
+		var tokenRotation *models.SubscriptionTokenRotation
+		var err error
+		if token, tokenRotation, err = s.rotateSubscriptionToken(userID, grace, operator, reason); err != nil {
+			return nil, "", err
+		}
+		rotation.SubscriptionRotationID = &tokenRotation.ID
+	}
+
+	if err := s.dbService.GetRepository().UserCredential.Regenerate(rotation); err != nil {
+		return nil, "", err
+	}
+
+	s.logger.Info("user credentials regenerated",
+		zap.Uint("rotation_id", rotation.ID),
+		zap.Uint("user_id", userID),
+		zap.String("operator", operator),
+		zap.String("reason", reason),
+	)
+
+	if s.agents != nil && s.agents.active() {
+		if err := s.agents.pushCredentialRotation(rotation); err != nil {
+			// Retried by the credential sync
+			s.logger.Warn("Failed to push regenerated credentials", zap.Error(err), zap.Uint("rotation_id", rotation.ID))
+		}
+	}
+	return rotation, token, nil
+}
+
+// credentialRotationInfos converts the credential rotations of a user to
+// protobuf, with the nodes of the user still missing the new UUID of the
+// ones being pushed
+func (s *ManagementService) credentialRotationInfos(userID uint, rotations []*models.UserCredentialRotation) ([]*pbv1.UserCredentialRotationInfo, error) {
+	var nodes []*models.Node
+	for _, rotation := range rotations {
+		if rotation.PropagatedAt == nil {
+			var err error
+			if nodes, err = s.dbService.GetRepository().Node.GetUserNodes(userID); err != nil {
+				s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint("user_id", userID))
+				return nil, status.Error(codes.Internal, "failed to get user nodes")
+			}
+			break
+		}
+	}
+
+	infos := make([]*pbv1.UserCredentialRotationInfo, len(rotations))
+	for i, rotation := range rotations {
+		info := &pbv1.UserCredentialRotationInfo{
+			Id:        strconv.FormatUint(uint64(rotation.ID), 10),
+			UserId:    strconv.FormatUint(uint64(rotation.UserID), 10),
+			OldUuid:   rotation.OldUUID,
+			NewUuid:   rotation.NewUUID,
+			Operator:  rotation.Operator,
+			Reason:    rotation.Reason,
+			CreatedAt: timestamppb.New(rotation.CreatedAt),
+		}
+		if rotation.SubscriptionRotationID != nil {
+			info.SubscriptionRotationId = strconv.FormatUint(uint64(*rotation.SubscriptionRotationID), 10)
+		}
+		for _, nodeID := range rotation.PushedNodeIDs {
+			info.PushedNodeIds = append(info.PushedNodeIds, strconv.FormatUint(uint64(nodeID), 10))
+		}
+		if rotation.PropagatedAt != nil {
+			info.PropagatedAt = timestamppb.New(*rotation.PropagatedAt)
+		} else {
+			for _, node := range nodes {
+				if !rotation.Pushed(node.ID) {
+					info.PendingNodeIds = append(info.PendingNodeIds, strconv.FormatUint(uint64(node.ID), 10))
+				}
+			}
+		}
+		infos[i] = info
+	}
+	return infos, nil
+}
@@ -0,0 +1,320 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+const (
+	// userDetailSessions is the number of sessions in a user detail
+	userDetailSessions = 10
+	// userDetailAuditEvents is the number of audit events in a user detail
+	userDetailAuditEvents = 10
+)
+
+// Audit event types of a user detail
+const (
+	auditEventTrafficAdjustment = "traffic_adjustment"
+	auditEventBalance           = "balance_transaction"
+	auditEventTokenRotation     = "subscription_token_rotation"
+)
+
+// GetUserDetail gets everything the user detail page shows. The parts are
+// independent of each other, so they are read concurrently.
+func (s *ManagementService) GetUserDetail(ctx context.Context, req *pbv1.GetUserDetailRequest) (*pbv1.GetUserDetailResponse, error) {
+	s.logger.Debug("GetUserDetail called", zap.String("user_id", req.UserId))
+
+	if req.UserId == "" {
+		return nil, apierror.MissingField("user_id")
+	}
+
+	userID, err := strconv.ParseUint(req.UserId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+
+	user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
+	if err != nil {
+		s.logger.Error("Failed to get user", zap.Error(err), zap.String("user_id", req.UserId))
+		return nil, apierror.NotFound(apierror.ResourceUser, req.UserId)
+	}
+
+	resp := &pbv1.GetUserDetailResponse{}
+	var limited map[uint]bool
+	var usage *pbv1.TrafficSummary
+
+	parts := []func() error{
+		func() (err error) {
+			limited, err = s.limitedExperience([]uint{user.ID})
+			return err
+		},
+		func() (err error) {
+			usage, err = s.userUsage(user)
+			return err
+		},
+		func() (err error) {
+			resp.Plan, err = s.userPlan(user)
+			return err
+		},
+		func() (err error) {
+			resp.Nodes, err = s.userNodes(user.ID)
+			return err
+		},
+		func() (err error) {
+			resp.Sessions, err = s.userSessions(user.ID)
+			return err
+		},
+		func() (err error) {
+			resp.Devices, err = s.userDevices(user.ID)
+			return err
+		},
+		func() (err error) {
+			resp.AuditEvents, err = s.userAuditEvents(user.ID)
+			return err
+		},
+		func() (err error) {
+			resp.SpeedLimits, err = s.userSpeedLimits(user)
+			return err
+		},
+	}
+
+	errs := make([]error, len(parts))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = part()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp.User = s.convertUserToProto(user)
+	resp.User.LimitedExperience = limited[user.ID]
+	resp.User.TrafficSummary = usage
+	if resp.Plan != nil {
+		resp.User.PlanName = resp.Plan.Spec.GetName()
+	}
+	return resp, nil
+}
+
+// userUsage gets the traffic usage of a user in the current period and today
+func (s *ManagementService) userUsage(user *models.User) (*pbv1.TrafficSummary, error) {
+	today := time.Now().Truncate(24 * time.Hour)
+	_, _, daily, err := s.dbService.GetRepository().Traffic.GetUserTrafficSum(user.ID, today, today)
+	if err != nil {
+		s.logger.Error("Failed to get user traffic", zap.Error(err), zap.Uint("user_id", user.ID))
+		return nil, status.Error(codes.Internal, "failed to get user traffic")
+	}
+
+	usage := &pbv1.TrafficSummary{
+		UsedBytes:  user.TrafficUsed,
+		TotalBytes: user.TrafficQuota,
+		DailyUsage: daily,
+	}
+	if user.TrafficQuota > 0 {
+		usage.UsagePercent = float64(user.TrafficUsed) / float64(user.TrafficQuota) * 100
+	}
+	return usage, nil
+}
+
+// userPlan gets the plan of a user with its entitlements, nil when the plan
+// is gone
+func (s *ManagementService) userPlan(user *models.User) (*pbv1.PlanInfo, error) {
+	plan, err := s.dbService.GetRepository().Plan.GetByID(user.PlanID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		s.logger.Error("Failed to get plan", zap.Error(err), zap.Uint("plan_id", user.PlanID))
+		return nil, status.Error(codes.Internal, "failed to get plan")
+	}
+	return s.planInfo(plan)
+}
+
+// userNodes gets the nodes a user may use
+func (s *ManagementService) userNodes(userID uint) ([]*pbv1.NodeInfo, error) {
+	nodes, err := s.dbService.GetRepository().Node.GetUserNodes(userID)
+	if err != nil {
+		s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint("user_id", userID))
+		return nil, status.Error(codes.Internal, "failed to get user nodes")
+	}
+	pbNodes := make([]*pbv1.NodeInfo, len(nodes))
+	for i, node := range nodes {
+		pbNodes[i] = s.convertNodeToProto(node)
+	}
+	return pbNodes, nil
+}
+
+// userSessions gets the latest sessions of a user
+func (s *ManagementService) userSessions(userID uint) ([]*pbv1.UserSessionInfo, error) {
+	sessions, err := s.dbService.GetRepository().Traffic.ListUserSessions(userID, userDetailSessions)
+	if err != nil {
+		s.logger.Error("Failed to get user sessions", zap.Error(err), zap.Uint("user_id", userID))
+		return nil, status.Error(codes.Internal, "failed to get user sessions")
+	}
+	pbSessions := make([]*pbv1.UserSessionInfo, len(sessions))
+	for i, session := range sessions {
+		var disconnectTime *timestamppb.Timestamp
+		if session.DisconnectTime != nil {
+			disconnectTime = timestamppb.New(*session.DisconnectTime)
+		}
+		pbSessions[i] = &pbv1.UserSessionInfo{
+			SessionId:      session.SessionID,
+			NodeId:         strconv.FormatUint(uint64(session.NodeID), 10),
+			ConnectTime:    timestamppb.New(session.ConnectTime),
+			DisconnectTime: disconnectTime,
+			ClientIp:       session.ClientIP,
+			DeviceId:       session.DeviceID,
+			Protocol:       session.Protocol,
+			UploadBytes:    session.Upload,
+			DownloadBytes:  session.Download,
+			Active:         session.Active(),
+		}
+	}
+	return pbSessions, nil
+}
+
+// userDevices gets the devices a user is connected from
+func (s *ManagementService) userDevices(userID uint) ([]*pbv1.UserDeviceInfo, error) {
+	connections, err := s.dbService.GetRepository().Traffic.GetActiveUserConnections(userID)
+	if err != nil {
+		s.logger.Error("Failed to get active connections", zap.Error(err), zap.Uint("user_id", userID))
+		return nil, status.Error(codes.Internal, "failed to get active connections")
+	}
+	return groupDevices(connections), nil
+}
+
+// groupDevices folds active connections into devices. Connections without a
+// device ID are told apart by their client IP.
+func groupDevices(connections []*models.TrafficRecord) []*pbv1.UserDeviceInfo {
+	var devices []*pbv1.UserDeviceInfo
+	byKey := make(map[string]*pbv1.UserDeviceInfo)
+	nodes := make(map[string]map[uint]bool)
+	for _, conn := range connections {
+		key := "device:" + conn.DeviceID
+		if conn.DeviceID == "" {
+			key = "ip:" + conn.ClientIP
+		}
+		device, ok := byKey[key]
+		if !ok {
+			device = &pbv1.UserDeviceInfo{
+				DeviceId:       conn.DeviceID,
+				ClientIp:       conn.ClientIP,
+				ConnectedSince: timestamppb.New(conn.ConnectTime),
+			}
+			byKey[key] = device
+			nodes[key] = make(map[uint]bool)
+			devices = append(devices, device)
+		}
+		device.Connections++
+		if conn.ConnectTime.Before(device.ConnectedSince.AsTime()) {
+			device.ConnectedSince = timestamppb.New(conn.ConnectTime)
+		}
+		if !nodes[key][conn.NodeID] {
+			nodes[key][conn.NodeID] = true
+			device.NodeIds = append(device.NodeIds, strconv.FormatUint(uint64(conn.NodeID), 10))
+		}
+	}
+	return devices
+}
+
+// userAuditEvents gets the latest audited changes to a user: traffic
+// corrections, balance transactions and subscription token rotations
+func (s *ManagementService) userAuditEvents(userID uint) ([]*pbv1.UserAuditEvent, error) {
+	repo := s.dbService.GetRepository()
+
+	adjustments, _, err := repo.TrafficAdjustment.ListByUser(userID, time.Time{}, time.Now().AddDate(1, 0, 0), 0, userDetailAuditEvents)
+	if err != nil {
+		s.logger.Error("Failed to get traffic adjustments", zap.Error(err), zap.Uint("user_id", userID))
+		return nil, status.Error(codes.Internal, "failed to get traffic adjustments")
+	}
+	transactions, _, err := repo.Wallet.ListTransactions(repository.BalanceTransactionFilter{UserID: userID}, 0, userDetailAuditEvents)
+	if err != nil {
+		s.logger.Error("Failed to get balance transactions", zap.Error(err), zap.Uint("user_id", userID))
+		return nil, status.Error(codes.Internal, "failed to get balance transactions")
+	}
+	rotations, _, err := repo.SubscriptionToken.ListByUser(userID, 0, userDetailAuditEvents)
+	if err != nil {
+		s.logger.Error("Failed to get token rotations", zap.Error(err), zap.Uint("user_id", userID))
+		return nil, status.Error(codes.Internal, "failed to get token rotations")
+	}
+
+	return mergeAuditEvents(adjustments, transactions, rotations, userDetailAuditEvents), nil
+}
+
+// mergeAuditEvents merges the audit sources of a user into at most limit
+// events, newest first
+func mergeAuditEvents(adjustments []*models.TrafficAdjustment, transactions []*models.BalanceTransaction,
+	rotations []*models.SubscriptionTokenRotation, limit int) []*pbv1.UserAuditEvent {
+	events := make([]*pbv1.UserAuditEvent, 0, len(adjustments)+len(transactions)+len(rotations))
+	for _, adjustment := range adjustments {
+		sign := "+"
+		if adjustment.Bytes < 0 {
+			sign = "-"
+		}
+		bytes := adjustment.Bytes
+		if bytes < 0 {
+			bytes = -bytes
+		}
+		events = append(events, &pbv1.UserAuditEvent{
+			Type:        auditEventTrafficAdjustment,
+			Operator:    adjustment.Operator,
+			Description: fmt.Sprintf("Traffic corrected by %s%s: %s", sign, models.FormatBytes(bytes), adjustment.Reason),
+			CreatedAt:   timestamppb.New(adjustment.CreatedAt),
+		})
+	}
+	for _, transaction := range transactions {
+		description := fmt.Sprintf("Balance %s of %+.2f %s", transaction.Type, float64(transaction.Amount)/100, transaction.Currency)
+		if transaction.Note != "" {
+			description += ": " + transaction.Note
+		}
+		events = append(events, &pbv1.UserAuditEvent{
+			Type:        auditEventBalance,
+			Operator:    transaction.Operator,
+			Description: description,
+			CreatedAt:   timestamppb.New(transaction.CreatedAt),
+		})
+	}
+	for _, rotation := range rotations {
+		description := "Subscription token rotated"
+		if rotation.Reason != "" {
+			description += ": " + rotation.Reason
+		}
+		events = append(events, &pbv1.UserAuditEvent{
+			Type:        auditEventTokenRotation,
+			Operator:    rotation.Operator,
+			Description: description,
+			CreatedAt:   timestamppb.New(rotation.CreatedAt),
+		})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.AsTime().After(events[j].CreatedAt.AsTime())
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// User migration methods
+
+func (s *ManagementService) CreateUserMigration(ctx context.Context, req *pbv1.CreateUserMigrationRequest) (*pbv1.CreateUserMigrationResponse, error) {
+	s.logger.Debug("CreateUserMigration called",
+		zap.String("kind", req.Kind),
+		zap.String("source_id", req.SourceId),
+		zap.String("target_id", req.TargetId),
+	)
+
+	kind := models.UserMigrationKind(req.Kind)
+	var violations []apierror.FieldViolation
+	if !kind.IsValid() {
+		violations = append(violations, apierror.FieldViolation{Field: "kind", Description: "kind must be plan or node"})
+	}
+	sourceID, err := strconv.ParseUint(req.SourceId, 10, 32)
+	if err != nil || sourceID == 0 {
+		violations = append(violations, apierror.FieldViolation{Field: "source_id", Description: "invalid source_id format"})
+	}
+	targetID, err := strconv.ParseUint(req.TargetId, 10, 32)
+	if err != nil || targetID == 0 {
+		violations = append(violations, apierror.FieldViolation{Field: "target_id", Description: "invalid target_id format"})
+	} else if targetID == sourceID {
+		violations = append(violations, apierror.FieldViolation{Field: "target_id", Description: "target must differ from the source"})
+	}
+	if violation, ok := s.validateUsersPerMinute(req.UsersPerMinute); !ok {
+		violations = append(violations, violation)
+	}
+	if len(violations) > 0 {
+		return nil, apierror.InvalidFields(violations)
+	}
+
+	repo := s.dbService.GetRepository()
+	if kind == models.UserMigrationKindPlan {
+		if _, err := repo.Plan.GetByID(uint(sourceID)); err != nil {
+			return nil, apierror.NotFound(apierror.ResourcePlan, req.SourceId)
+		}
+		if _, err := repo.Plan.GetByID(uint(targetID)); err != nil {
+			return nil, apierror.NotFound(apierror.ResourcePlan, req.TargetId)
+		}
+	} else {
+		if _, err := repo.Node.GetByID(uint(sourceID)); err != nil {
+			return nil, apierror.NotFound(apierror.ResourceNode, req.SourceId)
+		}
+		if _, err := repo.Node.GetByID(uint(targetID)); err != nil {
+			return nil, apierror.NotFound(apierror.ResourceNode, req.TargetId)
+		}
+	}
+
+	// Two migrations of a source would race for its users
+	unfinished, err := repo.UserMigration.FindUnfinished(kind, uint(sourceID))
+	if err != nil {
+		s.logger.Error("Failed to look up user migrations", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create user migration")
+	}
+	if unfinished != nil {
+		return nil, apierror.FailedPrecondition(apierror.ReasonUserMigrationActive, "user_migration/"+strconv.FormatUint(uint64(unfinished.ID), 10),
+			fmt.Sprintf("the %s already has an unfinished migration", kind))
+	}
+
+	total, err := repo.UserMigration.CountUsers(kind, uint(sourceID))
+	if err != nil {
+		s.logger.Error("Failed to count users to migrate", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create user migration")
+	}
+
+	// The first users are due after a check interval rather than at once
+	now := time.Now()
+	migration := &models.UserMigration{
+		Kind:           kind,
+		SourceID:       uint(sourceID),
+		TargetID:       uint(targetID),
+		UsersPerMinute: int(req.UsersPerMinute),
+		Status:         models.UserMigrationStatusRunning,
+		Operator:       req.Operator,
+		Total:          total,
+		LastBatchAt:    &now,
+	}
+	if err := repo.UserMigration.Create(migration); err != nil {
+		s.logger.Error("Failed to create user migration", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create user migration")
+	}
+
+	s.logger.Info("User migration created",
+		zap.Uint("migration_id", migration.ID),
+		zap.String("kind", string(kind)),
+		zap.Uint("source_id", migration.SourceID),
+		zap.Uint("target_id", migration.TargetID),
+		zap.Int64("users", total),
+		zap.Int("users_per_minute", migration.UsersPerMinute),
+		zap.String("operator", req.Operator),
+	)
+
+	return &pbv1.CreateUserMigrationResponse{
+		Success:   true,
+		Message:   "user migration created",
+		Migration: s.convertUserMigrationToProto(migration),
+	}, nil
+}
+
+func (s *ManagementService) GetUserMigration(ctx context.Context, req *pbv1.GetUserMigrationRequest) (*pbv1.GetUserMigrationResponse, error) {
+	s.logger.Debug("GetUserMigration called", zap.String("migration_id", req.MigrationId))
+
+	migration, err := s.getUserMigration(req.MigrationId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.GetUserMigrationResponse{
+		Migration: s.convertUserMigrationToProto(migration),
+	}, nil
+}
+
+func (s *ManagementService) ListUserMigrations(ctx context.Context, req *pbv1.ListUserMigrationsRequest) (*pbv1.ListUserMigrationsResponse, error) {
+	s.logger.Debug("ListUserMigrations called", zap.String("status", req.Status))
+
+	migrationStatus := models.UserMigrationStatus(req.Status)
+	switch migrationStatus {
+	case "", models.UserMigrationStatusRunning, models.UserMigrationStatusPaused,
+		models.UserMigrationStatusCompleted, models.UserMigrationStatusCancelled:
+	default:
+		return nil, apierror.InvalidField("status", "status must be running, paused, completed or cancelled")
+	}
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	offset := int((page - 1) * pageSize)
+	migrations, total, err := s.dbService.GetRepository().UserMigration.List(migrationStatus, offset, int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list user migrations", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list user migrations")
+	}
+
+	resp := &pbv1.ListUserMigrationsResponse{
+		Migrations: make([]*pbv1.UserMigrationInfo, len(migrations)),
+		Total:      int32(total),
+	}
+	for i, migration := range migrations {
+		resp.Migrations[i] = s.convertUserMigrationToProto(migration)
+	}
+	return resp, nil
+}
+
+func (s *ManagementService) SetUserMigrationState(ctx context.Context, req *pbv1.SetUserMigrationStateRequest) (*pbv1.SetUserMigrationStateResponse, error) {
+	s.logger.Debug("SetUserMigrationState called", zap.String("migration_id", req.MigrationId), zap.String("action", req.Action))
+
+	var from []models.UserMigrationStatus
+	var to models.UserMigrationStatus
+	switch req.Action {
+	case "pause":
+		from, to = []models.UserMigrationStatus{models.UserMigrationStatusRunning}, models.UserMigrationStatusPaused
+	case "resume":
+		from, to = []models.UserMigrationStatus{models.UserMigrationStatusPaused}, models.UserMigrationStatusRunning
+	case "cancel":
+		from = []models.UserMigrationStatus{models.UserMigrationStatusRunning, models.UserMigrationStatusPaused}
+		to = models.UserMigrationStatusCancelled
+	default:
+		return nil, apierror.InvalidField("action", "action must be pause, resume or cancel")
+	}
+	if req.UsersPerMinute != 0 {
+		if req.Action != "resume" {
+			return nil, apierror.InvalidField("users_per_minute", "the rate can only be changed when resuming")
+		}
+		if violation, ok := s.validateUsersPerMinute(req.UsersPerMinute); !ok {
+			return nil, apierror.InvalidFields([]apierror.FieldViolation{violation})
+		}
+	}
+
+	migration, err := s.getUserMigration(req.MigrationId)
+	if err != nil {
+		return nil, err
+	}
+	resource := "user_migration/" + req.MigrationId
+	if migration.IsFinished() {
+		return nil, apierror.FailedPrecondition(apierror.ReasonUserMigrationFinished, resource,
+			fmt.Sprintf("user migration is %s", migration.Status))
+	}
+
+	err = s.dbService.GetRepository().UserMigration.SetStatus(migration, from, to, int(req.UsersPerMinute))
+	if errors.Is(err, repository.ErrMigrationStateChanged) {
+		return nil, apierror.FailedPrecondition(apierror.ReasonUserMigrationState, resource,
+			fmt.Sprintf("cannot %s a %s user migration", req.Action, migration.Status))
+	}
+	if err != nil {
+		s.logger.Error("Failed to change user migration state", zap.Error(err), zap.String("migration_id", req.MigrationId))
+		return nil, status.Error(codes.Internal, "failed to change user migration state")
+	}
+
+	s.logger.Info("User migration state changed",
+		zap.String("migration_id", req.MigrationId),
+		zap.String("status", string(to)),
+		zap.Int("users_per_minute", migration.UsersPerMinute),
+	)
+
+	return &pbv1.SetUserMigrationStateResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("user migration %s", to),
+		Migration: s.convertUserMigrationToProto(migration),
+	}, nil
+}
+
+// validateUsersPerMinute checks a migration rate against the configured maximum
+func (s *ManagementService) validateUsersPerMinute(usersPerMinute int32) (apierror.FieldViolation, bool) {
+	limit := s.business().UserMigration.MaxUsersPerMinute
+	if usersPerMinute <= 0 || int(usersPerMinute) > limit {
+		return apierror.FieldViolation{
+			Field:       "users_per_minute",
+			Description: fmt.Sprintf("users_per_minute must be between 1 and %d", limit),
+		}, false
+	}
+	return apierror.FieldViolation{}, true
+}
+
+// getUserMigration loads a user migration by its ID
+func (s *ManagementService) getUserMigration(migrationID string) (*models.UserMigration, error) {
+	if migrationID == "" {
+		return nil, apierror.MissingField("migration_id")
+	}
+	id, err := strconv.ParseUint(migrationID, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("migration_id", "invalid migration_id format")
+	}
+
+	migration, err := s.dbService.GetRepository().UserMigration.GetByID(uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apierror.NotFound(apierror.ResourceUserMigration, migrationID)
+	}
+	if err != nil {
+		s.logger.Error("Failed to get user migration", zap.Error(err), zap.String("migration_id", migrationID))
+		return nil, status.Error(codes.Internal, "failed to get user migration")
+	}
+	return migration, nil
+}
+
+// convertUserMigrationToProto converts a user migration, naming its source
+// and target when they still exist
+func (s *ManagementService) convertUserMigrationToProto(migration *models.UserMigration) *pbv1.UserMigrationInfo {
+	info := &pbv1.UserMigrationInfo{
+		MigrationId:               strconv.FormatUint(uint64(migration.ID), 10),
+		Kind:                      string(migration.Kind),
+		SourceId:                  strconv.FormatUint(uint64(migration.SourceID), 10),
+		TargetId:                  strconv.FormatUint(uint64(migration.TargetID), 10),
+		UsersPerMinute:            int32(migration.UsersPerMinute),
+		Status:                    string(migration.Status),
+		TotalUsers:                migration.Total,
+		MovedUsers:                migration.Moved,
+		FailedUsers:               migration.Failed,
+		ProgressPercent:           migration.Progress(),
+		LastError:                 migration.LastError,
+		Operator:                  migration.Operator,
+		CreatedAt:                 timestamppb.New(migration.CreatedAt),
+		EstimatedSecondsRemaining: int64(migration.Remaining().Seconds()),
+	}
+	if migration.LastBatchAt != nil {
+		info.LastBatchAt = timestamppb.New(*migration.LastBatchAt)
+	}
+	if migration.CompletedAt != nil {
+		info.CompletedAt = timestamppb.New(*migration.CompletedAt)
+	}
+
+	repo := s.dbService.GetRepository()
+	if migration.Kind == models.UserMigrationKindPlan {
+		if plan, err := repo.Plan.GetByID(migration.SourceID); err == nil {
+			info.SourceName = plan.Name
+		}
+		if plan, err := repo.Plan.GetByID(migration.TargetID); err == nil {
+			info.TargetName = plan.Name
+		}
+	} else {
+		if node, err := repo.Node.GetByID(migration.SourceID); err == nil {
+			info.SourceName = node.Name
+		}
+		if node, err := repo.Node.GetByID(migration.TargetID); err == nil {
+			info.TargetName = node.Name
+		}
+	}
+	return info
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// User balance methods
+
+func (s *ManagementService) GetUserBalance(ctx context.Context, req *pbv1.GetUserBalanceRequest) (*pbv1.GetUserBalanceResponse, error) {
+	s.logger.Debug("GetUserBalance called", zap.String("user_id", req.UserId))
+
+	userID, err := parseWalletUserID(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	reconciliation, err := s.dbService.GetRepository().Wallet.Reconcile(userID)
+	if err != nil {
+		return nil, s.walletError(err, req.UserId, "failed to get user balance")
+	}
+	if !reconciliation.Consistent() {
+		s.logger.Error("User balance does not match the ledger",
+			zap.Uint("user_id", userID),
+			zap.Int64("balance", reconciliation.Balance),
+			zap.Int64("ledger_balance", reconciliation.LedgerBalance),
+			zap.Int64("unbalanced_transactions", reconciliation.UnbalancedTransactions),
+		)
+	}
+
+	return &pbv1.GetUserBalanceResponse{
+		UserId:        req.UserId,
+		Balance:       reconciliation.Balance,
+		Currency:      reconciliation.Currency,
+		LedgerBalance: reconciliation.LedgerBalance,
+		Consistent:    reconciliation.Consistent(),
+	}, nil
+}
+
+func (s *ManagementService) TopUpUserBalance(ctx context.Context, req *pbv1.TopUpUserBalanceRequest) (*pbv1.TopUpUserBalanceResponse, error) {
+	s.logger.Debug("TopUpUserBalance called",
+		zap.String("user_id", req.UserId),
+		zap.Int64("amount", req.Amount),
+		zap.String("currency", req.Currency),
+	)
+
+	transaction, err := s.postAdminBalanceTransaction(models.BalanceTransactionTopUp,
+		req.UserId, req.Amount, req.Currency, req.Note, req.Operator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.TopUpUserBalanceResponse{
+		Success:     true,
+		Message:     "balance topped up successfully",
+		Transaction: convertBalanceTransactionToProto(transaction),
+	}, nil
+}
+
+func (s *ManagementService) DeductUserBalance(ctx context.Context, req *pbv1.DeductUserBalanceRequest) (*pbv1.DeductUserBalanceResponse, error) {
+	s.logger.Debug("DeductUserBalance called",
+		zap.String("user_id", req.UserId),
+		zap.Int64("amount", req.Amount),
+		zap.String("currency", req.Currency),
+	)
+
+	transaction, err := s.postAdminBalanceTransaction(models.BalanceTransactionDeduction,
+		req.UserId, req.Amount, req.Currency, req.Note, req.Operator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbv1.DeductUserBalanceResponse{
+		Success:     true,
+		Message:     "balance deducted successfully",
+		Transaction: convertBalanceTransactionToProto(transaction),
+	}, nil
+}
+
+func (s *ManagementService) ListBalanceTransactions(ctx context.Context, req *pbv1.ListBalanceTransactionsRequest) (*pbv1.ListBalanceTransactionsResponse, error) {
+	s.logger.Debug("ListBalanceTransactions called",
+		zap.String("user_id", req.UserId),
+		zap.String("type_filter", req.TypeFilter),
+		zap.Int32("page", req.Page),
+		zap.Int32("page_size", req.PageSize),
+	)
+
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	offset := (page - 1) * pageSize
+
+	var filter repository.BalanceTransactionFilter
+	if req.UserId != "" {
+		userID, err := parseWalletUserID(req.UserId)
+		if err != nil {
+			return nil, err
+		}
+		filter.UserID = userID
+	}
+	if req.TypeFilter != "" {
+		filter.Type = models.BalanceTransactionType(req.TypeFilter)
+		if !filter.Type.IsValid() {
+			return nil, apierror.InvalidField("type_filter", "type_filter must be one of topup, deduction, purchase, refund")
+		}
+	}
+
+	transactions, total, err := s.dbService.GetRepository().Wallet.ListTransactions(filter, int(offset), int(pageSize))
+	if err != nil {
+		s.logger.Error("Failed to list balance transactions", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list balance transactions")
+	}
+
+	pbTransactions := make([]*pbv1.BalanceTransactionInfo, len(transactions))
+	for i, transaction := range transactions {
+		pbTransactions[i] = convertBalanceTransactionToProto(transaction)
+	}
+
+	return &pbv1.ListBalanceTransactionsResponse{
+		Transactions: pbTransactions,
+		Total:        int32(total),
+		Page:         page,
+		PageSize:     pageSize,
+	}, nil
+}
+
+// postAdminBalanceTransaction validates and books a top-up or deduction
+func (s *ManagementService) postAdminBalanceTransaction(txType models.BalanceTransactionType, id string, amount int64, currency, note, operator string) (*models.BalanceTransaction, error) {
+	userID, err := parseWalletUserID(id)
+	if err != nil {
+		return nil, err
+	}
+	if operator == "" {
+		return nil, apierror.MissingField("operator")
+	}
+
+	transaction := &models.BalanceTransaction{
+		UserID:   userID,
+		Type:     txType,
+		Amount:   amount,
+		Currency: currency,
+		Note:     note,
+		Operator: operator,
+	}
+	if err := transaction.Validate(); err != nil {
+		return nil, validationError(err, "")
+	}
+
+	if err := s.dbService.GetRepository().Wallet.Post(transaction); err != nil {
+		return nil, s.walletError(err, id, fmt.Sprintf("failed to post %s", txType))
+	}
+
+	s.logger.Info("User balance changed",
+		zap.Uint("user_id", userID),
+		zap.String("type", string(txType)),
+		zap.Int64("amount", transaction.Amount),
+		zap.String("currency", currency),
+		zap.Int64("balance", transaction.BalanceAfter),
+		zap.String("operator", operator),
+	)
+	return transaction, nil
+}
+
+// parseWalletUserID parses the required ID of a wallet's user
+func parseWalletUserID(id string) (uint, error) {
+	if id == "" {
+		return 0, apierror.MissingField("user_id")
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, apierror.InvalidField("user_id", "invalid user_id format")
+	}
+	return uint(userID), nil
+}
+
+// walletError maps repository errors of balance operations to API errors
+func (s *ManagementService) walletError(err error, userID, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return apierror.NotFound(apierror.ResourceUser, userID)
+	case errors.Is(err, models.ErrInsufficientBalance):
+		return apierror.FailedPrecondition(apierror.ReasonInsufficientBalance, "user/"+userID, "insufficient balance")
+	case errors.Is(err, models.ErrBalanceCurrency):
+		return apierror.FailedPrecondition(apierror.ReasonBalanceCurrency, "user/"+userID, "balance is held in another currency")
+	}
+	s.logger.Error(message, zap.Error(err), zap.String("user_id", userID))
+	return status.Error(codes.Internal, message)
+}
+
+// convertBalanceTransactionToProto converts a balance transaction to protobuf format
+func convertBalanceTransactionToProto(transaction *models.BalanceTransaction) *pbv1.BalanceTransactionInfo {
+	info := &pbv1.BalanceTransactionInfo{
+		TransactionId: strconv.FormatUint(uint64(transaction.ID), 10),
+		UserId:        strconv.FormatUint(uint64(transaction.UserID), 10),
+		Type:          string(transaction.Type),
+		Amount:        transaction.Amount,
+		Currency:      transaction.Currency,
+		BalanceAfter:  transaction.BalanceAfter,
+		Note:          transaction.Note,
+		Operator:      transaction.Operator,
+		CreatedAt:     timestamppb.New(transaction.CreatedAt),
+	}
+	if transaction.OrderID != nil {
+		info.OrderId = strconv.FormatUint(uint64(*transaction.OrderID), 10)
+	}
+	return info
+}
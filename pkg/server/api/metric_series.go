@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/metrics"
+)
+
+// syncMetricSeries periodically deletes the Prometheus series of users that
+// were removed or are no longer active, and of nodes that were removed, so
+// that per-user and per-node labels do not pile up as they churn. Offline
+// nodes keep their series, reporting a status of 0. Every instance runs it,
+// each exposes its own series.
+func (s *AgentService) syncMetricSeries(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Metrics.SeriesSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.performSeriesSync()
+		}
+	}
+}
+
+// performSeriesSync deletes the series of departed nodes and users
+func (s *AgentService) performSeriesSync() {
+	repos := s.dbService.GetRepository()
+	existing, _, err := repos.Node.List(0, -1)
+	if err != nil {
+		s.logger.Error("Failed to check nodes of metric series", zap.Error(err))
+		return
+	}
+	present := make(map[string]bool, len(existing))
+	for _, node := range existing {
+		present[strconv.FormatUint(uint64(node.ID), 10)] = true
+	}
+
+	nodes := 0
+	for _, nodeID := range metrics.TrackedNodes() {
+		if !present[nodeID] {
+			metrics.DeleteNodeSeries(nodeID)
+			nodes++
+		}
+	}
+
+	tracked := metrics.TrackedUsers()
+	userIDs := make([]uint, 0, len(tracked))
+	for _, userID := range tracked {
+		if id, err := strconv.ParseUint(userID, 10, 32); err == nil {
+			userIDs = append(userIDs, uint(id))
+		}
+	}
+	active, err := repos.User.FilterActiveIDs(userIDs)
+	if err != nil {
+		s.logger.Error("Failed to check users of metric series", zap.Error(err))
+		return
+	}
+	keep := make(map[string]bool, len(active))
+	for _, id := range active {
+		keep[strconv.FormatUint(uint64(id), 10)] = true
+	}
+
+	users := 0
+	for _, userID := range tracked {
+		if !keep[userID] {
+			metrics.DeleteUserSeries(userID)
+			users++
+		}
+	}
+
+	if nodes > 0 || users > 0 {
+		s.logger.Debug("Deleted stale metric series", zap.Int("nodes", nodes), zap.Int("users", users))
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// recordMetricsSample stores a reported metrics snapshot in the node's minute bucket.
+// Network values are the rates derived for the node, not the raw agent counters.
+func (s *AgentService) recordMetricsSample(node *models.Node, metrics *pbv1.NodeMetrics, reportedAt time.Time) {
+	if !s.business().Metrics.Enabled {
+		return
+	}
+
+	sample := &models.NodeMetricsHistory{
+		NodeID:                node.ID,
+		Resolution:            models.MetricsResolutionMinute,
+		Timestamp:             reportedAt,
+		CPUUsage:              metrics.CpuUsagePercent,
+		MemoryUsage:           metrics.MemoryUsagePercent,
+		DiskUsage:             metrics.DiskUsagePercent,
+		LoadAverage:           metrics.LoadAverage,
+		NetworkInBytesPerSec:  node.NetworkInRate,
+		NetworkOutBytesPerSec: node.NetworkOutRate,
+		Connections:           metrics.ActiveConnections,
+		MaxCPUUsage:           metrics.CpuUsagePercent,
+		MaxConnections:        metrics.ActiveConnections,
+	}
+
+	// History is best effort, a failed sample must not fail the report
+	if err := s.dbService.GetRepository().Metrics.RecordSample(sample); err != nil {
+		s.logger.Warn("Failed to record metrics sample", zap.Error(err), zap.Uint("node_id", node.ID))
+	}
+}
+
+// downsampleMetrics periodically folds aged metrics samples into coarser buckets
+func (s *AgentService) downsampleMetrics(ctx context.Context) {
+	s.runActiveJob(ctx, s.business().Metrics.DownsampleInterval, s.performDownsample)
+}
+
+// performDownsample runs one minute -> hour -> day downsampling pass and expires daily buckets
+func (s *AgentService) performDownsample() {
+	cfg := s.business().Metrics
+	repo := s.dbService.GetRepository().Metrics
+	now := time.Now()
+
+	folded, err := repo.Downsample(models.MetricsResolutionMinute, models.MetricsResolutionHour, now.Add(-cfg.MinuteRetention))
+	if err != nil {
+		s.logger.Error("Failed to downsample minute metrics", zap.Error(err))
+		return
+	}
+
+	foldedHourly, err := repo.Downsample(models.MetricsResolutionHour, models.MetricsResolutionDay, now.Add(-cfg.HourlyRetention))
+	if err != nil {
+		s.logger.Error("Failed to downsample hourly metrics", zap.Error(err))
+		return
+	}
+
+	expired, err := repo.DeleteOlderThan(models.MetricsResolutionDay, now.Add(-cfg.DailyRetention))
+	if err != nil {
+		s.logger.Error("Failed to delete expired daily metrics", zap.Error(err))
+		return
+	}
+
+	s.logger.Debug("metrics history downsampled",
+		zap.Int64("minute_samples", folded),
+		zap.Int64("hourly_samples", foldedHourly),
+		zap.Int64("expired_daily_samples", expired),
+	)
+}
+
+// metricsResolution maps the requested granularity to a stored resolution.
+// Without a granularity the finest resolution still retained at start is used.
+func (s *ManagementService) metricsResolution(granularity string, start time.Time) (models.MetricsResolution, error) {
+	switch granularity {
+	case "minute", "1m":
+		return models.MetricsResolutionMinute, nil
+	case "hour", "hourly", "1h":
+		return models.MetricsResolutionHour, nil
+	case "day", "daily", "1d":
+		return models.MetricsResolutionDay, nil
+	case "":
+	default:
+		return "", apierror.InvalidField("granularity", fmt.Sprintf("invalid granularity %q, use minute, hour or day", granularity))
+	}
+
+	cfg := s.business().Metrics
+	age := time.Since(start)
+	switch {
+	case age <= cfg.MinuteRetention:
+		return models.MetricsResolutionMinute, nil
+	case age <= cfg.HourlyRetention:
+		return models.MetricsResolutionHour, nil
+	default:
+		return models.MetricsResolutionDay, nil
+	}
+}
+
+// convertMetricsSampleToProto converts a metrics history sample to its protobuf form
+func convertMetricsSampleToProto(sample *models.NodeMetricsHistory) *pbv1.MetricsData {
+	return &pbv1.MetricsData{
+		Timestamp:   timestamppb.New(sample.Timestamp),
+		CpuUsage:    sample.CPUUsage,
+		MemoryUsage: sample.MemoryUsage,
+		DiskUsage:   sample.DiskUsage,
+		NetworkIn:   sample.NetworkInBytesPerSec,
+		NetworkOut:  sample.NetworkOutBytesPerSec,
+		Connections: sample.Connections,
+	}
+}
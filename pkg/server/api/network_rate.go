@@ -0,0 +1,48 @@
+package api
+
+import (
+	"time"
+)
+
+// networkCounter is a cumulative network counter reading reported by an agent
+type networkCounter struct {
+	in  int64
+	out int64
+	at  time.Time
+}
+
+// networkRate computes bytes per second between two counter readings.
+// It returns false when no rate can be derived: the readings are out of order,
+// too far apart, or a counter went backwards because the agent restarted.
+func networkRate(prev, cur networkCounter, maxGap time.Duration) (in, out int64, ok bool) {
+	elapsed := cur.at.Sub(prev.at)
+	if elapsed <= 0 || (maxGap > 0 && elapsed > maxGap) {
+		return 0, 0, false
+	}
+	if cur.in < prev.in || cur.out < prev.out {
+		return 0, 0, false
+	}
+
+	seconds := elapsed.Seconds()
+	in = int64(float64(cur.in-prev.in) / seconds)
+	out = int64(float64(cur.out-prev.out) / seconds)
+	return in, out, true
+}
+
+// updateNetworkRate stores the node's latest counter reading and returns the
+// rate since the previous one. The bytes in between are witnessed.
+func (s *AgentService) updateNetworkRate(nodeID uint, cur networkCounter) (in, out int64, ok bool) {
+	s.countersMux.Lock()
+	defer s.countersMux.Unlock()
+
+	prev, exists := s.counters[nodeID]
+	s.counters[nodeID] = cur
+	if !exists {
+		return 0, 0, false
+	}
+	in, out, ok = networkRate(prev, cur, s.business().Node.MaxOfflineTime)
+	if ok {
+		s.witness.addInterface(nodeID, (cur.in-prev.in)+(cur.out-prev.out), cur.at.Sub(prev.at))
+	}
+	return in, out, ok
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestNetworkRate(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	reading := func(in, out int64, after time.Duration) networkCounter {
+		return networkCounter{in: in, out: out, at: start.Add(after)}
+	}
+
+	tests := []struct {
+		name    string
+		prev    networkCounter
+		cur     networkCounter
+		maxGap  time.Duration
+		wantIn  int64
+		wantOut int64
+		wantOK  bool
+	}{
+		{
+			name:    "rate over the interval",
+			prev:    reading(1000, 2000, 0),
+			cur:     reading(11000, 4000, 10*time.Second),
+			maxGap:  time.Minute,
+			wantIn:  1000,
+			wantOut: 200,
+			wantOK:  true,
+		},
+		{
+			name:   "idle node",
+			prev:   reading(1000, 2000, 0),
+			cur:    reading(1000, 2000, 10*time.Second),
+			maxGap: time.Minute,
+			wantOK: true,
+		},
+		{
+			name:    "no gap limit",
+			prev:    reading(0, 0, 0),
+			cur:     reading(3600, 7200, time.Hour),
+			wantIn:  1,
+			wantOut: 2,
+			wantOK:  true,
+		},
+		{
+			name:   "counter reset by an agent restart",
+			prev:   reading(50000, 2000, 0),
+			cur:    reading(100, 2500, 10*time.Second),
+			maxGap: time.Minute,
+		},
+		{
+			name:   "outgoing counter reset",
+			prev:   reading(1000, 50000, 0),
+			cur:    reading(2000, 100, 10*time.Second),
+			maxGap: time.Minute,
+		},
+		{
+			name:   "zero interval",
+			prev:   reading(1000, 2000, 0),
+			cur:    reading(5000, 6000, 0),
+			maxGap: time.Minute,
+		},
+		{
+			name:   "readings out of order",
+			prev:   reading(1000, 2000, 10*time.Second),
+			cur:    reading(5000, 6000, 0),
+			maxGap: time.Minute,
+		},
+		{
+			name:   "readings too far apart",
+			prev:   reading(1000, 2000, 0),
+			cur:    reading(5000, 6000, 2*time.Minute),
+			maxGap: time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out, ok := networkRate(tt.prev, tt.cur, tt.maxGap)
+			if in != tt.wantIn || out != tt.wantOut || ok != tt.wantOK {
+				t.Errorf("networkRate() = %d, %d, %v; want %d, %d, %v", in, out, ok, tt.wantIn, tt.wantOut, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestUpdateNetworkRateFirstSample(t *testing.T) {
+	s := &AgentService{counters: make(map[uint]networkCounter)}
+	business := configv1.BusinessConfig{}
+	business.Node.MaxOfflineTime = time.Minute
+	s.businessConfig.Store(&business)
+
+	start := time.Unix(1700000000, 0)
+	// The first reading of a node only starts its rate
+	if _, _, ok := s.updateNetworkRate(1, networkCounter{in: 1000, out: 2000, at: start}); ok {
+		t.Fatal("updateNetworkRate() of the first reading derived a rate")
+	}
+	in, out, ok := s.updateNetworkRate(1, networkCounter{in: 3000, out: 2500, at: start.Add(10 * time.Second)})
+	if !ok || in != 200 || out != 50 {
+		t.Errorf("updateNetworkRate() = %d, %d, %v; want 200, 50, true", in, out, ok)
+	}
+
+	// Readings are kept per node
+	if _, _, ok := s.updateNetworkRate(2, networkCounter{in: 5000, out: 5000, at: start.Add(10 * time.Second)}); ok {
+		t.Error("updateNetworkRate() of another node's first reading derived a rate")
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+const (
+	// commandTimeoutCheckInterval is how often commands without a result
+	// are checked against the command timeout
+	commandTimeoutCheckInterval = time.Minute
+	// maxCommandOutput is the length of the command output kept
+	maxCommandOutput = 4096
+	// maxCommandWait bounds how long ExecuteUserCommand waits for a result
+	maxCommandWait = time.Minute
+)
+
+// commandResults hands the command results nodes report to the callers
+// waiting for them. The zero value is ready to use.
+type commandResults struct {
+	mu      sync.Mutex
+	waiters map[string]chan *models.NodeCommand
+}
+
+// wait returns a channel receiving the result of a command and a function
+// to call once the caller stops waiting
+func (r *commandResults) wait(commandID string) (<-chan *models.NodeCommand, func()) {
+	result := make(chan *models.NodeCommand, 1)
+	r.mu.Lock()
+	if r.waiters == nil {
+		r.waiters = make(map[string]chan *models.NodeCommand)
+	}
+	r.waiters[commandID] = result
+	r.mu.Unlock()
+
+	return result, func() {
+		r.mu.Lock()
+		delete(r.waiters, commandID)
+		r.mu.Unlock()
+	}
+}
+
+// deliver hands the result of a command to its waiting caller, if any
+func (r *commandResults) deliver(command *models.NodeCommand) {
+	r.mu.Lock()
+	result, ok := r.waiters[command.CommandID]
+	delete(r.waiters, command.CommandID)
+	r.mu.Unlock()
+
+	if ok {
+		result <- command
+	}
+}
+
+// recordCommand records a command queued to a node, whose result the node
+// reports with ReportCommandResult. The command is queued either way.
+func (s *AgentService) recordCommand(nodeID string, command *pbv1.PendingCommand) {
+	id, err := strconv.ParseUint(nodeID, 10, 32)
+	if err != nil {
+		return
+	}
+	record := &models.NodeCommand{
+		CommandID: command.CommandId,
+		NodeID:    uint(id),
+		Type:      command.Command.GetType().String(),
+		UserID:    command.Command.GetUserId(),
+		RequestID: command.RequestId,
+		Status:    models.NodeCommandPending,
+	}
+	// Speed limits are kept to tell whether nodes enforce the current ones
+	if limit, err := strconv.ParseInt(command.Command.GetParameters()["speed_limit"], 10, 64); err == nil {
+		record.SpeedLimit = &limit
+	}
+	err = s.dbService.GetRepository().NodeCommand.Create(record)
+	if err != nil {
+		s.logger.Error("Failed to record node command", zap.Error(err),
+			zap.String("node_id", nodeID), zap.String("command_id", command.CommandId))
+	}
+}
+
+// failCommand records that a command could not be queued
+func (s *AgentService) failCommand(nodeID, commandID, reason string) {
+	id, err := strconv.ParseUint(nodeID, 10, 32)
+	if err != nil {
+		return
+	}
+	command, err := s.dbService.GetRepository().NodeCommand.Complete(uint(id), commandID, models.NodeCommandFailed, reason, time.Now())
+	if err != nil {
+		s.logger.Error("Failed to record node command failure", zap.Error(err), zap.String("command_id", commandID))
+		return
+	}
+	s.commandResults.deliver(command)
+}
+
+// markCommandsDelivered records that commands were handed to their node
+func (s *AgentService) markCommandsDelivered(commands []*pbv1.PendingCommand) {
+	ids := make([]string, len(commands))
+	for i, command := range commands {
+		ids[i] = command.CommandId
+	}
+	if err := s.dbService.GetRepository().NodeCommand.MarkDelivered(ids, time.Now()); err != nil {
+		s.logger.Error("Failed to mark node commands delivered", zap.Error(err))
+	}
+}
+
+// ReportCommandResult records the result of a command a node executed
+func (s *AgentService) ReportCommandResult(ctx context.Context, req *pbv1.ReportCommandResultRequest) (*pbv1.ReportCommandResultResponse, error) {
+	s.logger.Debug("ReportCommandResult called",
+		zap.String("node_id", req.NodeId),
+		zap.String("command_id", req.CommandId),
+		zap.Bool("success", req.Success),
+	)
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	if req.CommandId == "" {
+		return nil, apierror.MissingField("command_id")
+	}
+
+	// Parse node ID
+	nodeID, err := strconv.ParseUint(req.NodeId, 10, 32)
+	if err != nil {
+		return nil, apierror.InvalidField("node_id", "invalid node_id format")
+	}
+
+	result := models.NodeCommandSucceeded
+	if !req.Success {
+		result = models.NodeCommandFailed
+	}
+	completedAt := time.Now()
+	if req.CompletedAt != nil {
+		completedAt = req.CompletedAt.AsTime()
+	}
+	output := req.Output
+	if len(output) > maxCommandOutput {
+		output = output[:maxCommandOutput]
+	}
+
+	command, err := s.dbService.GetRepository().NodeCommand.Complete(uint(nodeID), req.CommandId, result, output, completedAt)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, apierror.NotFound(apierror.ResourceNodeCommand, req.CommandId)
+	case errors.Is(err, repository.ErrNodeCommandFinished):
+		// The command timed out before the node reported, the result is late
+		return &pbv1.ReportCommandResultResponse{Success: true, Message: "command already finished"}, nil
+	case err != nil:
+		s.logger.Error("Failed to record command result", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to record command result")
+	}
+
+	s.commandResults.deliver(command)
+
+	if !req.Success {
+		s.logger.Warn("Node command failed",
+			zap.String("node_id", req.NodeId),
+			zap.String("command_id", req.CommandId),
+			zap.String("output", output),
+		)
+	}
+
+	return &pbv1.ReportCommandResultResponse{
+		Success: true,
+		Message: "command result recorded",
+	}, nil
+}
+
+// timeOutCommands periodically marks the commands without a result within
+// the command timeout timed out
+func (s *AgentService) timeOutCommands(ctx context.Context) {
+	ticker := time.NewTicker(commandTimeoutCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.active() {
+				continue
+			}
+			now := time.Now()
+			timedOut, err := s.dbService.GetRepository().NodeCommand.TimeOut(now.Add(-s.business().Node.CommandTimeout), now)
+			if err != nil {
+				s.logger.Error("Failed to time out node commands", zap.Error(err))
+				continue
+			}
+			if timedOut > 0 {
+				s.logger.Warn("Node commands timed out without a result", zap.Int64("commands", timedOut))
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// pendingDiagnostic is a RUN_DIAGNOSTIC command a caller waits for
+type pendingDiagnostic struct {
+	nodeID string
+	result chan *pbv1.DiagnosticResult
+}
+
+// diagnosticResults hands the diagnostic results nodes report to the callers
+// waiting for them. The zero value is ready to use.
+type diagnosticResults struct {
+	mu      sync.Mutex
+	pending map[string]*pendingDiagnostic
+}
+
+// expect returns a channel receiving the result of a command and a function
+// to call once the caller stops waiting
+func (r *diagnosticResults) expect(commandID, nodeID string) (<-chan *pbv1.DiagnosticResult, func()) {
+	diagnostic := &pendingDiagnostic{nodeID: nodeID, result: make(chan *pbv1.DiagnosticResult, 1)}
+	r.mu.Lock()
+	if r.pending == nil {
+		r.pending = make(map[string]*pendingDiagnostic)
+	}
+	r.pending[commandID] = diagnostic
+	r.mu.Unlock()
+
+	return diagnostic.result, func() {
+		r.mu.Lock()
+		delete(r.pending, commandID)
+		r.mu.Unlock()
+	}
+}
+
+// deliver hands a result to the caller waiting for it, false when none does
+func (r *diagnosticResults) deliver(nodeID, commandID string, result *pbv1.DiagnosticResult) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	diagnostic, ok := r.pending[commandID]
+	if !ok || diagnostic.nodeID != nodeID {
+		return false
+	}
+	delete(r.pending, commandID)
+	diagnostic.result <- result
+	return true
+}
+
+// runNodeDiagnostic asks a connected node to run a diagnostic and waits for
+// its result
+func (s *AgentService) runNodeDiagnostic(ctx context.Context, nodeID string, parameters map[string]string, timeout time.Duration) (*pbv1.DiagnosticResult, error) {
+	command := &pbv1.PendingCommand{
+		CommandId: generateCommandID(),
+		Command: &pbv1.UserCommand{
+			Type:       pbv1.UserCommand_RUN_DIAGNOSTIC,
+			UserId:     "system",
+			Parameters: parameters,
+		},
+		CreatedAt: timestamppb.Now(),
+	}
+
+	// Expect the result before queuing, the node may report it at once
+	result, stop := s.diagnosticResults.expect(command.CommandId, nodeID)
+	defer stop()
+
+	if err := s.sendCommandToNode(ctx, nodeID, command); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case diagnostic := <-result:
+		return diagnostic, nil
+	case <-timer.C:
+		return nil, apierror.New(codes.DeadlineExceeded, apierror.ReasonNodeDiagnosticTimeout,
+			fmt.Sprintf("node did not report the diagnostic within %s", timeout), map[string]string{"node_id": nodeID})
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// ReportDiagnosticResult receives the result of a RUN_DIAGNOSTIC command
+func (s *AgentService) ReportDiagnosticResult(ctx context.Context, req *pbv1.ReportDiagnosticResultRequest) (*pbv1.ReportDiagnosticResultResponse, error) {
+	s.logger.Debug("ReportDiagnosticResult called",
+		zap.String("node_id", req.NodeId),
+		zap.String("command_id", req.CommandId),
+	)
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	if req.CommandId == "" {
+		return nil, apierror.MissingField("command_id")
+	}
+	if req.Result == nil {
+		return nil, apierror.MissingField("result")
+	}
+
+	// The admin may have stopped waiting
+	if !s.diagnosticResults.deliver(req.NodeId, req.CommandId, req.Result) {
+		return nil, apierror.NotFound(apierror.ResourceNodeCommand, req.CommandId)
+	}
+
+	return &pbv1.ReportDiagnosticResultResponse{
+		Success: true,
+		Message: "diagnostic result received",
+	}, nil
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// enrollNodeMethod is the full method of EnrollNode, the only AgentService
+// RPC called without a node token
+const enrollNodeMethod = agentServicePrefix + "EnrollNode"
+
+// EnrollNode exchanges a one-time enrollment token for a new node and its node
+// token. Enrollment tokens are issued by admins, so nodes enroll even while
+// node.registration_enabled is off.
+func (s *AgentService) EnrollNode(ctx context.Context, req *pbv1.EnrollNodeRequest) (*pbv1.EnrollNodeResponse, error) {
+	s.logger.Info("EnrollNode called", zap.String("node_name", req.NodeName), zap.String("node_ip", req.NodeIp))
+
+	if req.EnrollmentToken == "" {
+		return nil, apierror.MissingField("enrollment_token")
+	}
+
+	repo := s.dbService.GetRepository()
+	enrollment, err := repo.NodeEnrollment.GetByHash(auth.HashNodeToken(req.EnrollmentToken))
+	if err != nil || enrollment.State(time.Now()) != models.NodeEnrollmentPending {
+		s.logger.Warn("Rejected invalid enrollment token", zap.String("node_ip", req.NodeIp))
+		return nil, invalidEnrollmentError()
+	}
+
+	name := enrollment.NodeName
+	if name == "" {
+		name = req.NodeName
+	}
+	if name == "" {
+		return nil, apierror.MissingField("node_name")
+	}
+	name, err = s.resolveNodeName(name, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// The node stays offline until the agent registers it with its new token
+	node := &models.Node{
+		Name:           name,
+		Description:    enrollment.Description,
+		Region:         enrollment.Region,
+		Host:           req.NodeIp,
+		Port:           8080, // Default port since not in protobuf
+		Status:         models.NodeStatusOffline,
+		SingBoxVersion: req.Version,
+		ConfigVersion:  1,
+		MaxUsers:       1000, // Default since not in protobuf
+	}
+	if err := node.Validate(); err != nil {
+		return nil, validationError(err, "node.")
+	}
+
+	token, err := auth.GenerateNodeToken()
+	if err != nil {
+		s.logger.Error("Failed to generate node token", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to generate node token")
+	}
+	nodeToken := &models.NodeToken{
+		TokenHash:   auth.HashNodeToken(token),
+		Description: "issued by enrollment " + strconv.FormatUint(uint64(enrollment.ID), 10),
+	}
+
+	if err := repo.NodeEnrollment.Enroll(enrollment.ID, node, nodeToken); err != nil {
+		if errors.Is(err, repository.ErrNodeEnrollmentUnavailable) {
+			return nil, invalidEnrollmentError()
+		}
+		s.logger.Error("Failed to enroll node", zap.Error(err), zap.Uint("enrollment_id", enrollment.ID))
+		return nil, status.Error(codes.Internal, "failed to enroll node")
+	}
+
+	nodeID := strconv.FormatUint(uint64(node.ID), 10)
+	s.logger.Info("Node enrolled",
+		zap.String("node_id", nodeID),
+		zap.String("node_name", node.Name),
+		zap.Uint("enrollment_id", enrollment.ID),
+	)
+
+	return &pbv1.EnrollNodeResponse{
+		NodeId:    nodeID,
+		NodeName:  node.Name,
+		NodeToken: token,
+	}, nil
+}
+
+// invalidEnrollmentError rejects an enrollment token without telling whether
+// it is unknown, used, revoked or expired
+func invalidEnrollmentError() error {
+	return apierror.New(codes.Unauthenticated, apierror.ReasonNodeEnrollmentInvalid,
+		"invalid, used or expired enrollment token", nil)
+}
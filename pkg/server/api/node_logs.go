@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/apierror"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// maxNodeLogBytes bounds the log lines kept of an upload, agents send at
+// most 256 KiB
+const maxNodeLogBytes = 512 << 10
+
+// nodeLogs are the log lines a node uploaded for a FETCH_LOGS command
+type nodeLogs struct {
+	nodeID    string
+	lines     []string
+	size      int
+	truncated bool
+	// err is why the node could not read the logs
+	err  string
+	done chan struct{}
+}
+
+// logUploads collects the log chunks nodes upload for the callers waiting
+// for them. The zero value is ready to use.
+type logUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*nodeLogs
+}
+
+// expect returns the logs of a command, complete once done is closed, and a
+// function to call once the caller stops waiting
+func (u *logUploads) expect(commandID, nodeID string) (*nodeLogs, func()) {
+	logs := &nodeLogs{nodeID: nodeID, done: make(chan struct{})}
+	u.mu.Lock()
+	if u.uploads == nil {
+		u.uploads = make(map[string]*nodeLogs)
+	}
+	u.uploads[commandID] = logs
+	u.mu.Unlock()
+
+	return logs, func() {
+		u.mu.Lock()
+		delete(u.uploads, commandID)
+		u.mu.Unlock()
+	}
+}
+
+// add appends a chunk to the logs of its command, false when no caller
+// waits for them
+func (u *logUploads) add(chunk *pbv1.UploadNodeLogsRequest) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	logs, ok := u.uploads[chunk.CommandId]
+	if !ok || logs.nodeID != chunk.NodeId {
+		return false
+	}
+	logs.lines = append(logs.lines, chunk.Lines...)
+	for _, line := range chunk.Lines {
+		logs.size += len(line)
+	}
+	for logs.size > maxNodeLogBytes && len(logs.lines) > 0 {
+		logs.size -= len(logs.lines[0])
+		logs.lines = logs.lines[1:]
+		logs.truncated = true
+	}
+	logs.truncated = logs.truncated || chunk.Truncated
+	logs.err = chunk.Error
+	if chunk.Last {
+		delete(u.uploads, chunk.CommandId)
+		close(logs.done)
+	}
+	return true
+}
+
+// fetchNodeLogs asks a connected node for its logs and waits for the upload
+func (s *AgentService) fetchNodeLogs(ctx context.Context, nodeID string, parameters map[string]string, timeout time.Duration) (*nodeLogs, error) {
+	command := &pbv1.PendingCommand{
+		CommandId: generateCommandID(),
+		Command: &pbv1.UserCommand{
+			Type:       pbv1.UserCommand_FETCH_LOGS,
+			UserId:     "system",
+			Parameters: parameters,
+		},
+		CreatedAt: timestamppb.Now(),
+	}
+
+	// Expect the upload before queuing, the node may send it at once
+	logs, stop := s.logUploads.expect(command.CommandId, nodeID)
+	defer stop()
+
+	if err := s.sendCommandToNode(ctx, nodeID, command); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-logs.done:
+	case <-timer.C:
+		return nil, apierror.New(codes.DeadlineExceeded, apierror.ReasonNodeLogsTimeout,
+			fmt.Sprintf("node did not upload its logs within %s", timeout), map[string]string{"node_id": nodeID})
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+
+	if logs.err != "" {
+		return nil, apierror.FailedPrecondition(apierror.ReasonNodeLogsUnavailable, nodeID, logs.err)
+	}
+	return logs, nil
+}
+
+// UploadNodeLogs receives a chunk of the logs a node read for a FETCH_LOGS command
+func (s *AgentService) UploadNodeLogs(ctx context.Context, req *pbv1.UploadNodeLogsRequest) (*pbv1.UploadNodeLogsResponse, error) {
+	s.logger.Debug("UploadNodeLogs called",
+		zap.String("node_id", req.NodeId),
+		zap.String("command_id", req.CommandId),
+		zap.Int("lines", len(req.Lines)),
+		zap.Bool("last", req.Last),
+	)
+
+	if req.NodeId == "" {
+		return nil, apierror.MissingField("node_id")
+	}
+	if req.CommandId == "" {
+		return nil, apierror.MissingField("command_id")
+	}
+
+	// The admin may have stopped waiting
+	if !s.logUploads.add(req) {
+		return nil, apierror.NotFound(apierror.ResourceNodeCommand, req.CommandId)
+	}
+
+	return &pbv1.UploadNodeLogsResponse{
+		Success: true,
+		Message: "logs received",
+	}, nil
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// overviewRefreshInterval is how often the system overview is rebuilt,
+	// the most the overview served is behind
+	overviewRefreshInterval = 15 * time.Second
+	// maxOverviewNodes bounds the node summaries of the overview
+	maxOverviewNodes = 100
+)
+
+// aggregateOverview periodically rebuilds the system overview that
+// GetSystemOverview serves, so that dashboards polling it cost no queries
+func (s *ManagementService) aggregateOverview(ctx context.Context) {
+	s.refreshOverview()
+
+	ticker := time.NewTicker(overviewRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refreshOverview()
+		}
+	}
+}
+
+// refreshOverview rebuilds the system overview, keeping the previous one
+// when it cannot
+func (s *ManagementService) refreshOverview() (*pbv1.GetSystemOverviewResponse, error) {
+	overview, err := s.buildOverview(time.Now())
+	if err != nil {
+		s.logger.Error("Failed to build system overview", zap.Error(err))
+		return nil, err
+	}
+	s.overview.Store(overview)
+	return overview, nil
+}
+
+// buildOverview gathers the system overview from the database
+func (s *ManagementService) buildOverview(now time.Time) (*pbv1.GetSystemOverviewResponse, error) {
+	repo := s.dbService.GetRepository()
+
+	stats, err := repo.User.GetSystemStats()
+	if err != nil {
+		return nil, err
+	}
+	nodeStats, err := repo.Node.GetNodeStats()
+	if err != nil {
+		return nil, err
+	}
+
+	today := now.Truncate(24 * time.Hour)
+	todayTraffic, err := repo.Traffic.GetTotalTrafficInRange(today, today.AddDate(0, 0, 1))
+	if err != nil {
+		s.logger.Error("Failed to get today's traffic", zap.Error(err))
+		todayTraffic = 0
+	}
+
+	nodes, _, err := repo.Node.List(0, maxOverviewNodes)
+	if err != nil {
+		return nil, err
+	}
+	nodeSummaries := make([]*pbv1.NodeSummary, len(nodes))
+	for i, node := range nodes {
+		nodeSummaries[i] = &pbv1.NodeSummary{
+			NodeId:          strconv.FormatUint(uint64(node.ID), 10),
+			NodeName:        node.Name,
+			Status:          string(node.Status),
+			UserCount:       int32(node.CurrentUsers),
+			ConnectionCount: int32(node.ActiveConnections),
+			CpuUsage:        node.CPUUsage,
+		}
+	}
+
+	// Infrastructure alerts from monitoring systems show next to the panel's own
+	recentAlerts := append([]*pbv1.AlertInfo{}, s.geoDataAlerts(now)...)
+	recentAlerts = append(recentAlerts, s.externalAlerts(now)...)
+
+	return &pbv1.GetSystemOverviewResponse{
+		Stats: &pbv1.SystemStats{
+			TotalNodes:        int32(nodeStats.TotalNodes),
+			OnlineNodes:       int32(nodeStats.OnlineNodes),
+			TotalUsers:        int32(stats.TotalUsers),
+			ActiveUsers:       int32(stats.ActiveUsers),
+			TotalTrafficToday: todayTraffic,
+			TotalConnections:  int32(nodeStats.TotalConnections),
+			AvgCpuUsage:       nodeStats.AvgCPUUsage,
+			AvgMemoryUsage:    nodeStats.AvgMemoryUsage,
+		},
+		NodeSummaries: nodeSummaries,
+		RecentAlerts:  recentAlerts,
+		GeneratedAt:   timestamppb.New(now),
+	}, nil
+}
+
+// cachedOverview returns a copy of the latest system overview, building it
+// when the aggregator has not yet
+func (s *ManagementService) cachedOverview() (*pbv1.GetSystemOverviewResponse, error) {
+	overview := s.overview.Load()
+	if overview == nil {
+		var err error
+		if overview, err = s.refreshOverview(); err != nil {
+			return nil, err
+		}
+	}
+	return proto.Clone(overview).(*pbv1.GetSystemOverviewResponse), nil
+}
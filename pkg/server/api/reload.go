@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/config"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/models"
+)
+
+// reloadableSections are the sections of the API config applied by Reload,
+// changes to the others only apply after a restart
+var reloadableSections = []string{"log", "business"}
+
+// business returns the current business settings
+func (s *AgentService) business() *configv1.BusinessConfig {
+	return s.businessConfig.Load()
+}
+
+// business returns the current business settings
+func (s *ManagementService) business() *configv1.BusinessConfig {
+	return s.businessConfig.Load()
+}
+
+// Reload replaces the business settings, restarting the background jobs so
+// that they pick up new intervals and policies
+func (s *AgentService) Reload(business configv1.BusinessConfig) {
+	s.jobsMux.Lock()
+	defer s.jobsMux.Unlock()
+
+	if s.stopJobs == nil {
+		s.businessConfig.Store(&business)
+		return
+	}
+	s.stopJobs()
+	s.jobs.Wait()
+	s.businessConfig.Store(&business)
+	s.startJobs()
+}
+
+// Reload applies the log level, business intervals and alert settings of a
+// configuration reloaded from path, which must be valid. Other changes are
+// logged as needing a restart. The reload is recorded in the admin audit log.
+func (s *Server) Reload(path string, reloaded *configv1.APIConfig) {
+	if err := logger.SetLevel(reloaded.Log.Level); err != nil {
+		s.logger.Error("Failed to change the log level", zap.Error(err))
+	}
+
+	s.managementService.businessConfig.Store(&reloaded.Business)
+	s.agentService.Reload(reloaded.Business)
+
+	alerts := reloaded.Business.Alert
+	channels := alertChannels(alerts, s.dbService.GetRepository(), s.mailer, s.events)
+	if engine := s.agentService.alerts; engine != nil {
+		engine.SetChannels(channels...)
+		s.agentService.ingester.SetQuotaThresholds(alerts.QuotaWarningThresholds)
+	} else if len(channels) > 0 {
+		s.logger.Warn("User alerts were enabled by the reloaded configuration, restart to apply")
+	}
+
+	s.logger.Info("Configuration reloaded", zap.String("path", path), zap.String("log_level", reloaded.Log.Level))
+	if changed := config.ChangedSections(&s.config, reloaded, reloadableSections...); len(changed) > 0 {
+		s.logger.Warn("Configuration changes need a restart to apply", zap.Strings("sections", changed))
+	}
+	// The other sections stay those the server runs with until a restart
+	s.config.Log = reloaded.Log
+	s.config.Business = reloaded.Business
+	s.auditReload(path, nil)
+}
+
+// RejectReload records a configuration from path that failed to load or
+// validate, and alerts the admins managing nodes. The current configuration
+// stays in effect.
+func (s *Server) RejectReload(path string, err error) {
+	s.logger.Error("Configuration reload rejected, keeping the current configuration",
+		zap.String("path", path), zap.Error(err))
+	s.auditReload(path, err)
+	s.agentService.alertNodeManagers(alert.Alert{
+		Type:     models.NotificationTypeSystem,
+		Severity: models.SeverityCritical,
+		Title:    "Configuration reload failed",
+		Message:  fmt.Sprintf("The API server kept its current configuration, %s is invalid: %v", path, err),
+	})
+}
+
+// auditReload writes a configuration reload to the admin audit log
+func (s *Server) auditReload(path string, err error) {
+	if err := s.dbService.GetRepository().AdminAudit.Create(models.NewConfigReloadAudit("api", path, err)); err != nil {
+		s.logger.Error("Failed to record configuration reload", zap.Error(err))
+	}
+}
@@ -4,17 +4,31 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/blocklist"
 	configv1 "sing-box-web/pkg/config/v1"
 	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/geodata"
+	"sing-box-web/pkg/ha"
+	healthcheck "sing-box-web/pkg/health"
 	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
 	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+	"sing-box-web/pkg/util"
 )
 
 // Server represents the gRPC API server
@@ -24,6 +38,15 @@ type Server struct {
 	listener   net.Listener
 	logger     *zap.Logger
 	dbService  *database.Service
+	elector    *ha.Elector
+	mailer     *mail.Mailer
+	events     *events.Bus
+	// health answers the standard gRPC health checks, serving while ready
+	// and the checks of checker pass
+	health       *health.Server
+	checker      *healthcheck.Checker
+	healthServer *healthcheck.Server
+	stopHealth   context.CancelFunc
 
 	// Services
 	managementService *ManagementService
@@ -36,6 +59,7 @@ func NewServer(config configv1.APIConfig, dbService *database.Service) (*Server,
 
 	// Create gRPC server with options
 	opts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
 		grpc.MaxRecvMsgSize(config.GRPC.MaxRecvMsgSize),
 		grpc.MaxSendMsgSize(config.GRPC.MaxSendMsgSize),
 		grpc.ConnectionTimeout(config.GRPC.ConnectionTimeout),
@@ -49,34 +73,153 @@ func NewServer(config configv1.APIConfig, dbService *database.Service) (*Server,
 		}),
 	}
 
-	// Add TLS if enabled
+	// Add TLS if enabled; mTLS when client CAs are configured
 	if config.GRPC.TLSEnabled {
-		// TODO: Add TLS configuration
+		tlsConfig, err := util.NewServerTLSConfig(config.GRPC.CertFile, config.GRPC.KeyFile, config.GRPC.ClientCAs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS configuration: %w", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	// Recover panics first so that every later interceptor is covered, then
+	// tag, log and count every call, rejected ones included
+	interceptors := []grpc.UnaryServerInterceptor{
+		newRecoveryInterceptor(logger),
+		newRequestInterceptor(config.GRPC.Interceptors, logger),
+	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		newStreamRecoveryInterceptor(logger),
+		newStreamRequestInterceptor(config.GRPC.Interceptors, logger),
+	}
+
+	if config.GRPC.Interceptors.RateLimit.Enabled {
+		limiter := newRateLimiter(config.GRPC.Interceptors.RateLimit)
+		interceptors = append(interceptors, newRateLimitInterceptor(limiter))
+		streamInterceptors = append(streamInterceptors, newStreamRateLimitInterceptor(limiter))
+	}
+
+	// Only the lease holder serves agents when running with a warm standby
+	var elector *ha.Elector
+	if config.HA.Enabled {
+		elector = ha.NewElector(config.HA, dbService.GetRepository().Lease, logger)
+		interceptors = append(interceptors, newStandbyInterceptor(elector))
+	}
+
+	// Authenticate agents with node registration tokens
+	if config.GRPC.RequireNodeToken {
+		interceptors = append(interceptors, newNodeAuthInterceptor(dbService.GetRepository(), logger))
+	} else {
+		logger.Warn("node token authentication is disabled, any caller can act as any node")
+	}
+
+	// Authenticate management clients with the shared management token
+	if config.GRPC.ManagementToken != "" {
+		interceptors = append(interceptors, newManagementAuthInterceptor(config.GRPC.ManagementToken, logger))
+		streamInterceptors = append(streamInterceptors, newStreamManagementAuthInterceptor(config.GRPC.ManagementToken, logger))
+	} else {
+		logger.Warn("management token authentication is disabled, any caller can use the management service")
 	}
 
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+
 	grpcServer := grpc.NewServer(opts...)
 
 	// Create services
-	managementService := NewManagementService(dbService, logger)
-	agentService := NewAgentService(config, dbService, logger)
+	bus, err := events.New(config.Events, logger.Named("events"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create event bus: %w", err)
+	}
+	managementService := NewManagementService(config, dbService, logger)
+	managementService.events = bus
+	agentService := NewAgentService(config, dbService, bus, logger)
+	agentService.elector = elector
+	managementService.agents = agentService
+	managementService.SetSettings(agentService.settings)
+	if config.Business.GeoData.Enabled {
+		cache := geodata.NewCache(config.Business.GeoData, dbService.GetRepository().GeoData, logger)
+		agentService.geoData = cache
+		managementService.geoData = cache
+	}
+	if len(config.Business.Blocklist.DisposableDomainLists) > 0 {
+		updater := blocklist.NewUpdater(config.Business.Blocklist, dbService.GetRepository().Blocklist, logger)
+		agentService.blocklists = updater
+		managementService.blocklists = updater
+	}
+	var mailer *mail.Mailer
+	if config.Mail.Enabled {
+		var err error
+		mailer, err = mail.NewMailer(config.Mail, models.Branding{}, dbService.GetRepository().Tenant, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mailer: %w", err)
+		}
+		managementService.mailer = mailer
+		agentService.mailer = mailer
+		managementService.accountTokens = auth.NewAccountTokens(config.Mail)
+	}
+
+	if channels := alertChannels(config.Business.Alert, dbService.GetRepository(), mailer, bus); len(channels) > 0 {
+		engine := alert.NewEngine(logger, channels...)
+		agentService.alerts = engine
+		agentService.ingester.SetAlerts(engine, config.Business.Alert.QuotaWarningThresholds)
+	}
 
 	// Register services
 	pbv1.RegisterManagementServiceServer(grpcServer, managementService)
 	pbv1.RegisterAgentServiceServer(grpcServer, agentService)
 
+	// Not serving until the process reports ready
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
 	// Register reflection service for development
 	reflection.Register(grpcServer)
 
+	checker := healthcheck.NewChecker(config.HealthEndpoints.CheckTimeout)
+	checker.Add("database", func(context.Context) error {
+		return dbService.Health()
+	})
+
 	return &Server{
 		config:            config,
 		grpcServer:        grpcServer,
 		logger:            logger,
 		dbService:         dbService,
+		elector:           elector,
+		mailer:            mailer,
+		events:            bus,
+		health:            healthServer,
+		checker:           checker,
+		healthServer:      healthcheck.NewServer(config.HealthEndpoints, checker, logger),
 		managementService: managementService,
 		agentService:      agentService,
 	}, nil
 }
 
+// alertChannels returns the channels delivering user alerts enabled by
+// config, none when user alerts are disabled
+func alertChannels(config configv1.AlertConfig, repo *repository.Manager, mailer *mail.Mailer, bus *events.Bus) []alert.Channel {
+	var channels []alert.Channel
+	if config.InAppNotifications {
+		channels = append(channels, alert.NewInAppChannel(repo.Notification))
+	}
+	if config.EmailNotifications && mailer != nil {
+		channels = append(channels, alert.NewMailChannel(mailer, repo.User, repo.AlertDelivery))
+	}
+	if config.TelegramNotifications {
+		channels = append(channels, alert.NewTelegramChannel(config.Telegram, repo.User, repo.AlertDelivery))
+	}
+	if len(channels) > 0 {
+		// Dashboards watching the events see the alerts users are sent
+		channels = append(channels, alert.NewEventChannel(bus, repo.AlertDelivery))
+	}
+	return channels
+}
+
 // Start starts the gRPC server
 func (s *Server) Start(ctx context.Context) error {
 	// Create listener
@@ -99,6 +242,31 @@ func (s *Server) Start(ctx context.Context) error {
 		}
 	}()
 
+	// Readiness follows the database once the process reports ready
+	if err := s.healthServer.Start(); err != nil {
+		return fmt.Errorf("failed to start health endpoints: %w", err)
+	}
+	var healthCtx context.Context
+	healthCtx, s.stopHealth = context.WithCancel(ctx)
+	go s.checker.WatchGRPC(healthCtx, s.health, s.config.HealthEndpoints.CheckInterval)
+
+	// Compete for the lease; a standby keeps serving management calls
+	if s.elector != nil {
+		s.elector.OnChange(func(active bool) {
+			if !active {
+				s.agentService.forgetNodes()
+			}
+		})
+		s.elector.Start(ctx)
+	}
+
+	if s.mailer != nil {
+		s.mailer.Start(ctx)
+	}
+
+	// Connect the event bus to its backend
+	go s.events.Run(ctx)
+
 	// Start services
 	if err := s.managementService.Start(ctx); err != nil {
 		return fmt.Errorf("failed to start management service: %w", err)
@@ -125,7 +293,16 @@ func (s *Server) Stop(ctx context.Context) error {
 		s.logger.Error("failed to stop agent service", zap.Error(err))
 	}
 
-	// Graceful shutdown with timeout
+	if s.mailer != nil {
+		s.mailer.Stop()
+	}
+
+	// Hand the lease over to a standby right away
+	if s.elector != nil {
+		s.elector.Stop()
+	}
+
+	// Wait for running calls until ctx ends
 	done := make(chan struct{})
 	go func() {
 		s.grpcServer.GracefulStop()
@@ -135,7 +312,7 @@ func (s *Server) Stop(ctx context.Context) error {
 	select {
 	case <-done:
 		s.logger.Info("gRPC server stopped gracefully")
-	case <-time.After(30 * time.Second):
+	case <-ctx.Done():
 		s.logger.Warn("gRPC server force stopped due to timeout")
 		s.grpcServer.Stop()
 	}
@@ -144,9 +321,34 @@ func (s *Server) Stop(ctx context.Context) error {
 		s.listener.Close()
 	}
 
+	if s.stopHealth != nil {
+		s.stopHealth()
+	}
+	if err := s.healthServer.Stop(ctx); err != nil {
+		s.logger.Warn("failed to stop health endpoints", zap.Error(err))
+	}
+
 	return nil
 }
 
+// SetReady sets the readiness reported to gRPC health checks and on
+// /readyz. Agents and load balancers checking it move away before the
+// server stops.
+func (s *Server) SetReady(ready bool) {
+	s.checker.SetReady(ready)
+	if ready {
+		s.health.Resume()
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if s.checker.Check(context.Background()).Ready() {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		s.health.SetServingStatus("", status)
+		return
+	}
+	// Shutdown sets every service not serving and ignores later updates
+	s.health.Shutdown()
+}
+
 // GetAddress returns the server listen address
 func (s *Server) GetAddress() string {
 	if s.listener != nil {
@@ -163,8 +365,9 @@ func (s *Server) IsHealthy() bool {
 // GetMetrics returns server metrics
 func (s *Server) GetMetrics() map[string]interface{} {
 	return map[string]interface{}{
-		"address":     s.GetAddress(),
-		"healthy":     s.IsHealthy(),
-		"tls_enabled": s.config.GRPC.TLSEnabled,
+		"address":      s.GetAddress(),
+		"healthy":      s.IsHealthy(),
+		"tls_enabled":  s.config.GRPC.TLSEnabled,
+		"mtls_enabled": s.config.GRPC.TLSEnabled && s.config.GRPC.ClientCAs != "",
 	}
 }
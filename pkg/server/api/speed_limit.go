@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+const (
+	// speedLimitSyncInterval is how often the speed limits of the users of
+	// connected nodes are compared with the ones pushed to them
+	speedLimitSyncInterval = time.Minute
+	// maxSpeedLimitPushes bounds the speed limit updates queued to a node per
+	// sync, leaving room in its command queue
+	maxSpeedLimitPushes = 50
+)
+
+// userSpeedLimit returns the speed limit of a user on a node, see
+// models.User.SpeedLimitOn
+func userSpeedLimit(repo *repository.Manager, user *models.User, node *models.Node) (int64, error) {
+	var override int64
+	access, err := repo.Plan.GetNodeAccess(user.PlanID, node.ID)
+	switch {
+	case err == nil:
+		if access.IsEnabled {
+			override = access.SpeedLimitOverride
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+	return user.SpeedLimitOn(node, override), nil
+}
+
+// pushedSpeedLimits returns the speed limits last pushed to the users of a
+// node, by user ID
+func pushedSpeedLimits(repo *repository.Manager, filter repository.NodeCommandFilter) (map[string]*models.NodeCommand, error) {
+	commands, err := repo.NodeCommand.LatestSpeedLimits(filter)
+	if err != nil {
+		return nil, err
+	}
+	pushed := make(map[string]*models.NodeCommand, len(commands))
+	for _, command := range commands {
+		pushed[command.UserID] = command
+	}
+	return pushed, nil
+}
+
+// syncSpeedLimits periodically pushes the speed limits of users that changed
+// since they were last pushed to the connected nodes, such as after a plan
+// or node limit changed
+func (s *AgentService) syncSpeedLimits(ctx context.Context) {
+	ticker := time.NewTicker(speedLimitSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.active() {
+				continue
+			}
+			for nodeID := range s.GetNodeStates() {
+				if err := s.syncNodeSpeedLimits(nodeID); err != nil {
+					s.logger.Error("Failed to sync node speed limits", zap.Error(err), zap.String("node_id", nodeID))
+				}
+			}
+		}
+	}
+}
+
+// syncNodeSpeedLimits pushes the speed limits of the users of a node that
+// differ from the ones last pushed. Users never pushed a limit only get one
+// when they are limited.
+func (s *AgentService) syncNodeSpeedLimits(nodeID string) error {
+	id, err := strconv.ParseUint(nodeID, 10, 32)
+	if err != nil {
+		return nil
+	}
+	repo := s.dbService.GetRepository()
+	node, err := repo.Node.GetByID(uint(id))
+	if err != nil {
+		return err
+	}
+	users, err := repo.Node.GetNodeUsers(node.ID)
+	if err != nil {
+		return err
+	}
+	pushed, err := pushedSpeedLimits(repo, repository.NodeCommandFilter{NodeID: node.ID})
+	if err != nil {
+		return err
+	}
+
+	pushes := 0
+	for _, user := range users {
+		limit, err := userSpeedLimit(repo, user, node)
+		if err != nil {
+			return err
+		}
+		last, ok := pushed[strconv.FormatUint(uint64(user.ID), 10)]
+		if ok && *last.SpeedLimit == limit || !ok && limit == 0 {
+			continue
+		}
+		if pushes == maxSpeedLimitPushes {
+			break
+		}
+		s.pushUser(node.ID, user, pbv1.UserCommand_UPDATE_USER)
+		pushes++
+	}
+	if pushes > 0 {
+		s.logger.Info("Pushed changed speed limits to node", zap.String("node_id", nodeID), zap.Int("users", pushes))
+	}
+	return nil
+}
+
+// userSpeedLimits gets the speed limit of a user on each node the user may
+// use and whether the node enforces it
+func (s *ManagementService) userSpeedLimits(user *models.User) ([]*pbv1.UserSpeedLimitStatus, error) {
+	repo := s.dbService.GetRepository()
+	nodes, err := repo.Node.GetUserNodes(user.ID)
+	if err != nil {
+		s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint("user_id", user.ID))
+		return nil, status.Error(codes.Internal, "failed to get user nodes")
+	}
+	userID := strconv.FormatUint(uint64(user.ID), 10)
+	commands, err := repo.NodeCommand.LatestSpeedLimits(repository.NodeCommandFilter{UserID: userID})
+	if err != nil {
+		s.logger.Error("Failed to get speed limit commands", zap.Error(err), zap.Uint("user_id", user.ID))
+		return nil, status.Error(codes.Internal, "failed to get speed limits")
+	}
+	pushed := make(map[uint]*models.NodeCommand, len(commands))
+	for _, command := range commands {
+		pushed[command.NodeID] = command
+	}
+
+	limits := make([]*pbv1.UserSpeedLimitStatus, 0, len(nodes))
+	for _, node := range nodes {
+		limit, err := userSpeedLimit(repo, user, node)
+		if err != nil {
+			s.logger.Error("Failed to get speed limit", zap.Error(err), zap.Uint("user_id", user.ID), zap.Uint("node_id", node.ID))
+			return nil, status.Error(codes.Internal, "failed to get speed limits")
+		}
+		info := &pbv1.UserSpeedLimitStatus{
+			NodeId:   strconv.FormatUint(uint64(node.ID), 10),
+			NodeName: node.Name,
+			Limit:    limit,
+			// Unlimited users need nothing enforced
+			Enforced: limit == 0,
+		}
+		if command, ok := pushed[node.ID]; ok {
+			info.AppliedLimit = *command.SpeedLimit
+			info.CommandStatus = string(command.Status)
+			info.UpdatedAt = timestamppb.New(command.UpdatedAt)
+			if command.Status == models.NodeCommandFailed {
+				info.Error = command.Output
+			}
+			info.Enforced = command.Status == models.NodeCommandSucceeded && *command.SpeedLimit == limit
+		}
+		limits = append(limits, info)
+	}
+	return limits, nil
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/models"
+)
+
+// checkSubscriptionSharing periodically looks for subscription links fetched
+// from more addresses than one user has
+func (s *AgentService) checkSubscriptionSharing(ctx context.Context) {
+	s.runActiveJob(ctx, s.business().SubscriptionSharing.CheckInterval, func() {
+		s.performSharingCheck(time.Now())
+	})
+}
+
+// performSharingCheck alerts the admins of users about each user whose
+// subscription was fetched from more than the allowed addresses over the
+// window, once per user and window
+func (s *AgentService) performSharingCheck(now time.Time) {
+	policy := s.business().SubscriptionSharing
+	repo := s.dbService.GetRepository()
+
+	sharing, err := repo.SubscriptionLog.ListSharing(now.Add(-policy.Window), policy.MaxDistinctIPs+1)
+	if err != nil {
+		s.logger.Error("Failed to list shared subscriptions", zap.Error(err))
+		return
+	}
+
+	for _, shared := range sharing {
+		user, err := repo.User.GetByID(shared.UserID)
+		if err != nil {
+			continue
+		}
+		s.logger.Warn("Subscription fetched from many addresses",
+			zap.Uint("user_id", user.ID),
+			zap.String("username", user.Username),
+			zap.Int64("distinct_ips", shared.DistinctIPs),
+			zap.Int64("fetches", shared.Fetches),
+			zap.Duration("window", policy.Window),
+		)
+		s.alertAdmins(models.AdminPermissionUsers, alert.Alert{
+			Type:     models.NotificationTypeSubscriptionSharing,
+			Severity: models.SeverityWarning,
+			Title:    "Subscription link may be shared",
+			Message: fmt.Sprintf("The subscription of user %s was fetched from %d addresses (%d fetches) over the last %s. Check its access history and rotate the link if it was shared.",
+				user.Username, shared.DistinctIPs, shared.Fetches, policy.Window),
+			Key: fmt.Sprintf("subscription_sharing:%d:%d", user.ID, now.Truncate(policy.Window).Unix()),
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/telemetry"
+)
+
+// reportTelemetry periodically posts the anonymous deployment statistics
+// to the telemetry endpoint
+func (s *AgentService) reportTelemetry(ctx context.Context) {
+	reporter := telemetry.NewReporter(s.config.Telemetry, s.logger.Named("telemetry"))
+	s.logger.Info("Anonymous telemetry enabled", zap.String("endpoint", s.config.Telemetry.Endpoint))
+
+	s.runActiveJob(ctx, s.config.Telemetry.Interval, func() {
+		nodes, err := s.dbService.GetRepository().Node.GetNodeCount()
+		if err != nil {
+			s.logger.Error("Failed to count nodes for telemetry", zap.Error(err))
+			return
+		}
+		if err := reporter.Report(ctx, telemetry.Collect(s.config.Database.Driver, nodes)); err != nil {
+			s.logger.Warn("Failed to report telemetry", zap.Error(err))
+		}
+	})
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/format"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/report"
+	"sing-box-web/pkg/repository"
+)
+
+const (
+	// trafficReportCheckInterval is how often the monthly report job checks
+	// whether the report of the previous month is due
+	trafficReportCheckInterval = time.Hour
+	// trafficReportDelay leaves the aggregation time to add up the last day
+	// of the month before its report is mailed
+	trafficReportDelay = time.Hour
+	// trafficReportChannel names the delivery records of the monthly reports
+	trafficReportChannel = "traffic_report"
+	// reportDateLayout is the layout of the dates of traffic reports
+	reportDateLayout = "2006-01-02"
+)
+
+// trafficReportHeaders are the header rows of the traffic reports by subject
+var trafficReportHeaders = map[string][]string{
+	models.TrafficTotalsByUser: {"User ID", "Username", "Email", "Upload (bytes)", "Download (bytes)", "Total (bytes)", "Total (GiB)", "Connections"},
+	models.TrafficTotalsByNode: {"Node ID", "Name", "Host", "Upload (bytes)", "Download (bytes)", "Total (bytes)", "Total (GiB)", "Connections"},
+}
+
+// trafficReport is a traffic report rendered as a file
+type trafficReport struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+	Totals      []*models.TrafficTotal
+}
+
+// buildTrafficReport adds up the daily summaries of the days from start to
+// end per user or per node and renders them in a format
+func buildTrafficReport(traffic repository.TrafficRepository, by, fileFormat string, start, end time.Time) (*trafficReport, error) {
+	totals, err := traffic.TotalDailySummaries(by, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	table := &report.Table{
+		Name:   fmt.Sprintf("%ss %s to %s", strings.ToUpper(by[:1])+by[1:], start.Format(reportDateLayout), end.Format(reportDateLayout)),
+		Header: trafficReportHeaders[by],
+	}
+	for _, total := range totals {
+		table.Rows = append(table.Rows, []any{
+			int64(total.ID), total.Name, total.Address, total.Upload, total.Download, total.Total,
+			math.Round(float64(total.Total)/(1<<30)*100) / 100, total.Connections,
+		})
+	}
+	content, err := report.Render(fileFormat, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return &trafficReport{
+		Filename:    fmt.Sprintf("traffic-%ss-%s-%s.%s", by, start.Format(reportDateLayout), end.Format(reportDateLayout), fileFormat),
+		ContentType: report.ContentType(fileFormat),
+		Content:     content,
+		Totals:      totals,
+	}, nil
+}
+
+// previousMonth returns the first and the last day of the month before now, in UTC
+func previousMonth(now time.Time) (time.Time, time.Time) {
+	year, month, _ := now.UTC().Date()
+	thisMonth := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return thisMonth.AddDate(0, -1, 0), thisMonth.AddDate(0, 0, -1)
+}
+
+// Traffic report methods
+
+func (s *ManagementService) ExportTrafficReport(ctx context.Context, req *pbv1.ExportTrafficReportRequest) (*pbv1.ExportTrafficReportResponse, error) {
+	s.logger.Debug("ExportTrafficReport called",
+		zap.String("group_by", req.GroupBy),
+		zap.String("start_date", req.StartDate),
+		zap.String("end_date", req.EndDate),
+		zap.String("format", req.Format),
+	)
+
+	by := req.GroupBy
+	if by == "" {
+		by = models.TrafficTotalsByUser
+	}
+	if _, ok := trafficReportHeaders[by]; !ok {
+		return nil, apierror.InvalidField("group_by", "group_by must be user or node")
+	}
+	fileFormat := req.Format
+	if fileFormat == "" {
+		fileFormat = report.FormatCSV
+	}
+	if !slices.Contains(report.Formats, fileFormat) {
+		return nil, apierror.InvalidField("format", "format must be one of "+strings.Join(report.Formats, ", "))
+	}
+
+	start, end := previousMonth(time.Now())
+	if req.StartDate != "" {
+		date, err := time.Parse(reportDateLayout, req.StartDate)
+		if err != nil {
+			return nil, apierror.InvalidField("start_date", "invalid start_date, expected YYYY-MM-DD")
+		}
+		start = date
+	}
+	if req.EndDate != "" {
+		date, err := time.Parse(reportDateLayout, req.EndDate)
+		if err != nil {
+			return nil, apierror.InvalidField("end_date", "invalid end_date, expected YYYY-MM-DD")
+		}
+		end = date
+	}
+	if end.Before(start) {
+		return nil, apierror.InvalidField("end_date", "end_date must not be before start_date")
+	}
+
+	built, err := buildTrafficReport(s.dbService.GetRepository().Traffic, by, fileFormat, start, end)
+	if err != nil {
+		s.logger.Error("Failed to build traffic report", zap.Error(err))
+		return nil, apierror.Internal("failed to export traffic report")
+	}
+
+	return &pbv1.ExportTrafficReportResponse{
+		Filename:    built.Filename,
+		ContentType: built.ContentType,
+		Content:     built.Content,
+		Rows:        int32(len(built.Totals)),
+	}, nil
+}
+
+// sendTrafficReports periodically mails the traffic report of the previous
+// month once it is over
+func (s *AgentService) sendTrafficReports(ctx context.Context) {
+	s.runActiveJob(ctx, trafficReportCheckInterval, func() {
+		s.performTrafficReport(time.Now())
+	})
+}
+
+// performTrafficReport mails the per user and per node traffic of the month
+// before now to the report recipients, once per month. A report that could
+// not be built or queued to anyone is tried again on the next check.
+func (s *AgentService) performTrafficReport(now time.Time) {
+	if s.mailer == nil || !s.mailer.Enabled(mail.TemplateTrafficReport) {
+		return
+	}
+	start, end := previousMonth(now)
+	if now.Before(end.AddDate(0, 0, 1).Add(trafficReportDelay)) {
+		return
+	}
+
+	repos := s.dbService.GetRepository()
+	period := start.Format("2006-01")
+	key := "traffic_report:" + period
+	recorded, err := repos.AlertDelivery.Record(trafficReportChannel, 0, key)
+	if err != nil {
+		s.logger.Error("Failed to record traffic report", zap.Error(err), zap.String("period", period))
+		return
+	}
+	if !recorded {
+		return
+	}
+
+	queued, err := s.mailTrafficReport(start, end)
+	if err != nil || queued == 0 {
+		if err != nil {
+			s.logger.Error("Failed to send traffic report", zap.Error(err), zap.String("period", period))
+		}
+		if err := repos.AlertDelivery.Forget(trafficReportChannel, 0, key); err != nil {
+			s.logger.Error("Failed to forget traffic report", zap.Error(err), zap.String("period", period))
+		}
+		return
+	}
+	s.logger.Info("Traffic report sent", zap.String("period", period), zap.Int("recipients", queued))
+}
+
+// mailTrafficReport queues the traffic report of the days from start to end
+// to each recipient and returns how many it was queued to
+func (s *AgentService) mailTrafficReport(start, end time.Time) (int, error) {
+	policy := s.business().TrafficReport
+	repos := s.dbService.GetRepository()
+
+	// Configured recipients are greeted by their address, admins by name
+	recipients := make(map[string]string)
+	var addresses []string
+	for _, address := range policy.Recipients {
+		recipients[address] = address
+		addresses = append(addresses, address)
+	}
+	if len(addresses) == 0 {
+		admins, _, err := repos.User.ListFiltered(repository.UserListFilter{Roles: adminRoles}, 0, -1)
+		if err != nil {
+			return 0, err
+		}
+		for _, admin := range admins {
+			if admin.Status == models.UserStatusActive && admin.Email != "" && admin.HasAdminPermission(models.AdminPermissionNodes) {
+				recipients[admin.Email] = admin.Username
+				addresses = append(addresses, admin.Email)
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		s.logger.Warn("No recipients for the traffic report")
+		return 0, nil
+	}
+
+	users, err := buildTrafficReport(repos.Traffic, models.TrafficTotalsByUser, policy.Format, start, end)
+	if err != nil {
+		return 0, err
+	}
+	nodes, err := buildTrafficReport(repos.Traffic, models.TrafficTotalsByNode, policy.Format, start, end)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, node := range nodes.Totals {
+		total += node.Total
+	}
+
+	data := map[string]string{
+		"period": start.Format("2006-01"),
+		"total":  format.New(format.DefaultLocale, time.UTC).Bytes(total),
+		"users":  strconv.Itoa(len(users.Totals)),
+		"nodes":  strconv.Itoa(len(nodes.Totals)),
+	}
+	attachments := []mail.Attachment{
+		{Filename: users.Filename, ContentType: users.ContentType, Content: users.Content},
+		{Filename: nodes.Filename, ContentType: nodes.ContentType, Content: nodes.Content},
+	}
+	queued := 0
+	for _, address := range addresses {
+		err := s.mailer.Send(&mail.Message{
+			Template:    mail.TemplateTrafficReport,
+			To:          address,
+			Username:    recipients[address],
+			Data:        data,
+			Attachments: attachments,
+		})
+		if err != nil {
+			s.logger.Warn("Failed to queue traffic report", zap.String("to", address), zap.Error(err))
+			continue
+		}
+		queued++
+	}
+	return queued, nil
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/repository"
+)
+
+const (
+	// summaryBatchSize bounds the records added to the summaries per transaction
+	summaryBatchSize = 5000
+	// summaryCommitLag leaves the newest records to the next pass, so that
+	// ingestion batches still being committed are not skipped
+	summaryCommitLag = 30 * time.Second
+)
+
+// aggregateTraffic periodically adds new traffic records to the summaries and
+// checks the previous day's summaries once a day
+func (s *AgentService) aggregateTraffic(ctx context.Context) {
+	var checked time.Time
+	s.runActiveJob(ctx, s.business().Traffic.AggregationWindow, func() {
+		s.performAggregation()
+
+		yesterday := time.Now().AddDate(0, 0, -1).Truncate(24 * time.Hour)
+		if yesterday.After(checked) && s.checkTrafficSummaries(yesterday) {
+			checked = yesterday
+		}
+	})
+}
+
+// performAggregation adds the records reported since the last pass to the summaries
+func (s *AgentService) performAggregation() {
+	repo := s.dbService.GetRepository().Traffic
+	before := time.Now().Add(-summaryCommitLag)
+
+	total := 0
+	for {
+		processed, err := repo.AggregateNewRecords(before, summaryBatchSize)
+		if errors.Is(err, repository.ErrWatermarkMoved) {
+			s.logger.Debug("Traffic summaries were updated concurrently, retrying next pass")
+			return
+		}
+		if err != nil {
+			s.logger.Error("Failed to aggregate traffic records", zap.Error(err))
+			return
+		}
+		total += processed
+		if processed < summaryBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.logger.Debug("traffic records aggregated", zap.Int("records", total))
+	}
+}
+
+// checkTrafficSummaries repairs the summaries of a day and its month that
+// drifted from the records and reports whether the check completed
+func (s *AgentService) checkTrafficSummaries(day time.Time) bool {
+	result, err := s.dbService.GetRepository().Traffic.CheckSummaries(day)
+	if err != nil {
+		s.logger.Error("Failed to check traffic summaries", zap.Error(err), zap.Time("date", day))
+		return false
+	}
+
+	if result.Repaired > 0 {
+		s.logger.Warn("Repaired inconsistent traffic summaries",
+			zap.Time("date", result.Date),
+			zap.Int("checked", result.Checked),
+			zap.Int("repaired", result.Repaired),
+		)
+	} else {
+		s.logger.Debug("traffic summaries consistent", zap.Time("date", result.Date), zap.Int("checked", result.Checked))
+	}
+	return true
+}
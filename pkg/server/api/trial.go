@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// signupTrialPlan returns the trial plan new self-registered users start on,
+// nil when there is none. A misconfigured trial plan is skipped rather than
+// failing signups.
+func (s *ManagementService) signupTrialPlan() (*models.Plan, error) {
+	trial := s.business().Trial
+	if !trial.Enabled || trial.PlanID == 0 {
+		return nil, nil
+	}
+
+	plan, err := s.dbService.GetRepository().Plan.GetByID(trial.PlanID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Warn("Trial plan not found", zap.Uint("plan_id", trial.PlanID))
+		return nil, nil
+	}
+	if err != nil {
+		s.logger.Error("Failed to get trial plan", zap.Error(err), zap.Uint("plan_id", trial.PlanID))
+		return nil, status.Error(codes.Internal, "failed to get trial plan")
+	}
+	if !plan.StartsTrial() || !plan.IsActive() {
+		s.logger.Warn("Trial plan is not an active trial plan", zap.Uint("plan_id", plan.ID))
+		return nil, nil
+	}
+	return plan, nil
+}
+
+// checkTrials periodically warns users whose trial ends soon and ends the
+// trials that are due
+func (s *AgentService) checkTrials(ctx context.Context) {
+	s.runActiveJob(ctx, s.business().Trial.CheckInterval, func() {
+		s.performTrialCheck(time.Now())
+	})
+}
+
+// performTrialCheck warns the users whose trial ends within the warning,
+// once per trial, and ends the trials that are due. Users move to the
+// downgrade plan or expire and are removed from their nodes.
+func (s *AgentService) performTrialCheck(now time.Time) {
+	policy := s.business().Trial
+	repo := s.dbService.GetRepository()
+
+	users, err := repo.User.ListTrialsEndingBefore(now.Add(policy.WarningBefore))
+	if err != nil {
+		s.logger.Error("Failed to list ending trials", zap.Error(err))
+		return
+	}
+	if len(users) == 0 {
+		return
+	}
+
+	downgrade, err := s.trialDowngradePlan()
+	if err != nil {
+		s.logger.Error("Failed to get trial downgrade plan", zap.Error(err), zap.Uint("plan_id", policy.DowngradePlanID))
+		return
+	}
+
+	var ended int
+	for _, user := range users {
+		if user.TrialEndsAt.After(now) {
+			s.raiseUserAlert(&alert.Alert{
+				UserID:   user.ID,
+				Type:     models.NotificationTypePlanExpiring,
+				Severity: models.SeverityWarning,
+				Title:    "Trial ending soon",
+				Message:  fmt.Sprintf("Your trial ends on %s. Purchase a plan to keep your service.", user.TrialEndsAt.UTC().Format("2006-01-02 15:04 MST")),
+				Key:      fmt.Sprintf("trial_ending:%d", user.TrialEndsAt.Unix()),
+			})
+			continue
+		}
+
+		if err := s.endTrial(user, downgrade, now); err != nil {
+			s.logger.Error("Failed to end trial", zap.Error(err), zap.Uint("user_id", user.ID))
+			continue
+		}
+		ended++
+	}
+
+	if ended > 0 {
+		s.logger.Info("Trials ended", zap.Int("users", ended))
+	}
+}
+
+// trialDowngradePlan returns the plan users move to at the end of their
+// trial, nil when they expire instead
+func (s *AgentService) trialDowngradePlan() (*models.Plan, error) {
+	planID := s.business().Trial.DowngradePlanID
+	if planID == 0 {
+		return nil, nil
+	}
+	plan, err := s.dbService.GetRepository().Plan.GetByID(planID)
+	if err != nil {
+		return nil, err
+	}
+	// Downgrading to a trial would start another one
+	if plan.StartsTrial() {
+		return nil, fmt.Errorf("downgrade plan %d is a trial plan", planID)
+	}
+	return plan, nil
+}
+
+// endTrial ends the trial of a user, provisioning the nodes of the
+// downgrade plan or removing the user from its nodes when it expires
+func (s *AgentService) endTrial(user *models.User, downgrade *models.Plan, now time.Time) error {
+	repo := s.dbService.GetRepository()
+
+	// The nodes are those of the trial plan until it ended
+	granted, err := repo.NodeFailover.GrantedNodeIDs(user.PlanID)
+	if err != nil {
+		return err
+	}
+	nodes, err := repo.Node.GetUserNodes(user.ID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := repo.User.EndTrial(user.ID, downgrade, now)
+	if err != nil || !ok {
+		return err
+	}
+
+	message := "Your trial has ended. Purchase a plan to continue using the service."
+	if downgrade == nil {
+		for _, node := range nodes {
+			s.pushUser(node.ID, user, pbv1.UserCommand_REMOVE_USER)
+		}
+	} else {
+		downgraded, err := repo.User.GetByID(user.ID)
+		if err != nil {
+			return fmt.Errorf("downgraded, but the nodes were not provisioned: %w", err)
+		}
+		to, err := repo.NodeFailover.GrantedNodeIDs(downgrade.ID)
+		if err != nil {
+			return fmt.Errorf("downgraded, but the nodes were not provisioned: %w", err)
+		}
+		if err := s.pushPlanNodes(downgraded, granted, to, true); err != nil {
+			return fmt.Errorf("downgraded, but the nodes were not provisioned: %w", err)
+		}
+		message = fmt.Sprintf("Your trial has ended and your account moved to the %s plan. Purchase a plan to restore the trial features.", downgrade.Name)
+	}
+
+	s.raiseUserAlert(&alert.Alert{
+		UserID:   user.ID,
+		Type:     models.NotificationTypePlanExpiring,
+		Severity: models.SeverityCritical,
+		Title:    "Trial ended",
+		Message:  message,
+		Key:      fmt.Sprintf("trial_ended:%d", user.TrialEndsAt.Unix()),
+	})
+	s.logger.Info("Trial ended",
+		zap.Uint("user_id", user.ID),
+		zap.String("username", user.Username),
+		zap.Bool("downgraded", downgrade != nil),
+	)
+	return nil
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+const (
+	// credentialSyncInterval is how often regenerated credentials are pushed
+	// to the nodes that connected since
+	credentialSyncInterval = 30 * time.Second
+	// maxCredentialSyncRotations bounds the rotations pushed per sync
+	maxCredentialSyncRotations = 500
+)
+
+// syncCredentials periodically pushes the regenerated credentials of users
+// to their nodes that did not take them yet, such as nodes that were offline
+// or regenerations made by the web server
+func (s *AgentService) syncCredentials(ctx context.Context) {
+	ticker := time.NewTicker(credentialSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.active() {
+				continue
+			}
+			rotations, err := s.dbService.GetRepository().UserCredential.ListUnpropagated(maxCredentialSyncRotations)
+			if err != nil {
+				s.logger.Error("Failed to list credential rotations to push", zap.Error(err))
+				continue
+			}
+			for _, rotation := range rotations {
+				if err := s.pushCredentialRotation(rotation); err != nil {
+					s.logger.Error("Failed to push credential rotation", zap.Error(err), zap.Uint("rotation_id", rotation.ID))
+				}
+			}
+		}
+	}
+}
+
+// pushCredentialRotation pushes the user of a rotation with its new UUID to
+// the connected nodes of the user that do not have it yet. The rotation is
+// propagated once no node of the user misses it.
+func (s *AgentService) pushCredentialRotation(rotation *models.UserCredentialRotation) error {
+	repo := s.dbService.GetRepository()
+	user, err := repo.User.GetByID(rotation.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Deleted users are on no node any more
+		return repo.UserCredential.MarkPushed(rotation, true)
+	}
+	if err != nil {
+		return err
+	}
+	nodes, err := repo.Node.GetUserNodes(user.ID)
+	if err != nil {
+		return err
+	}
+
+	connected := s.GetNodeStates()
+	pushed, missing := 0, 0
+	for _, node := range nodes {
+		if rotation.Pushed(node.ID) {
+			continue
+		}
+		if _, ok := connected[strconv.FormatUint(uint64(node.ID), 10)]; !ok {
+			missing++
+			continue
+		}
+		if err := s.pushUser(node.ID, user, pbv1.UserCommand_UPDATE_USER); err != nil {
+			missing++
+			continue
+		}
+		rotation.PushedNodeIDs = append(rotation.PushedNodeIDs, node.ID)
+		pushed++
+	}
+	if pushed == 0 && missing > 0 {
+		return nil
+	}
+	return repo.UserCredential.MarkPushed(rotation, missing == 0)
+}
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+// migrateUsers periodically moves the users due of the running user
+// migrations
+func (s *AgentService) migrateUsers(ctx context.Context) {
+	ticker := time.NewTicker(s.business().UserMigration.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Only the active instance holds the command queues of the nodes
+			if !s.active() {
+				continue
+			}
+			s.performUserMigrations(time.Now())
+		}
+	}
+}
+
+// performUserMigrations moves a batch of users of each running migration
+func (s *AgentService) performUserMigrations(now time.Time) {
+	migrations, err := s.dbService.GetRepository().UserMigration.ListRunning()
+	if err != nil {
+		s.logger.Error("Failed to list running user migrations", zap.Error(err))
+		return
+	}
+	for _, migration := range migrations {
+		s.migrateBatch(migration, now)
+	}
+}
+
+// userMover moves a user of a migration's source to its target and queues
+// the provisioning commands of the move
+type userMover func(user *models.User) error
+
+// migrateBatch moves the users of a migration due at now. A migration whose
+// target is gone is paused with the error; users failing to move are counted
+// and passed over.
+func (s *AgentService) migrateBatch(migration *models.UserMigration, now time.Time) {
+	repo := s.dbService.GetRepository().UserMigration
+
+	due := migration.Due(now)
+	if due < 1 {
+		return
+	}
+
+	var move userMover
+	var err error
+	switch migration.Kind {
+	case models.UserMigrationKindPlan:
+		move, err = s.planMover(migration)
+	case models.UserMigrationKindNode:
+		move, err = s.nodeMover(migration)
+	default:
+		err = fmt.Errorf("unknown migration kind %q", migration.Kind)
+	}
+	if err != nil {
+		s.pauseUserMigration(migration, err)
+		return
+	}
+
+	users, err := repo.NextUsers(migration, due)
+	if err != nil {
+		s.logger.Error("Failed to list users to migrate", zap.Error(err), zap.Uint("migration_id", migration.ID))
+		return
+	}
+
+	progress := repository.UserMigrationProgress{
+		LastUserID:  migration.LastUserID,
+		LastBatchAt: migration.BatchDone(len(users), now),
+		Completed:   len(users) < due,
+	}
+	for _, user := range users {
+		progress.LastUserID = user.ID
+		err := move(user)
+		switch {
+		case errors.Is(err, repository.ErrMigrationStateChanged):
+			// Moved off the source in the meantime, nothing left to do
+		case err != nil:
+			progress.Failed++
+			progress.LastError = fmt.Sprintf("user %d: %v", user.ID, err)
+			s.logger.Warn("Failed to migrate user",
+				zap.Error(err),
+				zap.Uint("migration_id", migration.ID),
+				zap.Uint("user_id", user.ID),
+			)
+		default:
+			progress.Moved++
+		}
+	}
+
+	if err := repo.RecordProgress(migration.ID, progress); err != nil {
+		s.logger.Error("Failed to record user migration progress", zap.Error(err), zap.Uint("migration_id", migration.ID))
+		return
+	}
+	if progress.Completed {
+		s.logger.Info("User migration completed",
+			zap.Uint("migration_id", migration.ID),
+			zap.String("kind", string(migration.Kind)),
+			zap.Int64("moved", migration.Moved+progress.Moved),
+			zap.Int64("failed", migration.Failed+progress.Failed),
+		)
+	}
+}
+
+// pauseUserMigration pauses a migration that cannot go on
+func (s *AgentService) pauseUserMigration(migration *models.UserMigration, cause error) {
+	s.logger.Error("Pausing user migration", zap.Error(cause), zap.Uint("migration_id", migration.ID))
+
+	repo := s.dbService.GetRepository().UserMigration
+	err := repo.SetStatus(migration, []models.UserMigrationStatus{models.UserMigrationStatusRunning}, models.UserMigrationStatusPaused, 0)
+	if err != nil && !errors.Is(err, repository.ErrMigrationStateChanged) {
+		s.logger.Error("Failed to pause user migration", zap.Error(err), zap.Uint("migration_id", migration.ID))
+		return
+	}
+	if err := repo.RecordProgress(migration.ID, repository.UserMigrationProgress{
+		LastUserID:  migration.LastUserID,
+		LastError:   cause.Error(),
+		LastBatchAt: time.Now(),
+	}); err != nil {
+		s.logger.Error("Failed to record user migration error", zap.Error(err), zap.Uint("migration_id", migration.ID))
+	}
+}
+
+// planMover returns the mover switching users to the target plan and
+// provisioning their nodes, see pushPlanNodes. The nodes of both plans have
+// the speed limit of the user updated when it changed.
+func (s *AgentService) planMover(migration *models.UserMigration) (userMover, error) {
+	repo := s.dbService.GetRepository()
+
+	target, err := repo.Plan.GetByID(migration.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("target plan %d: %w", migration.TargetID, err)
+	}
+	from, err := repo.NodeFailover.GrantedNodeIDs(migration.SourceID)
+	if err != nil {
+		return nil, fmt.Errorf("nodes of source plan %d: %w", migration.SourceID, err)
+	}
+	to, err := repo.NodeFailover.GrantedNodeIDs(target.ID)
+	if err != nil {
+		return nil, fmt.Errorf("nodes of target plan %d: %w", target.ID, err)
+	}
+
+	return func(user *models.User) error {
+		speedLimit := user.SpeedLimit
+		if err := repo.UserMigration.MovePlan(user, migration.SourceID, target); err != nil {
+			return err
+		}
+		if err := s.pushPlanNodes(user, from, to, user.SpeedLimit != speedLimit); err != nil {
+			return fmt.Errorf("moved, but the nodes were not provisioned: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// pushPlanNodes provisions the nodes of a user that moved from a plan
+// granting the nodes from to one granting the nodes to. Nodes only the old
+// plan grants have the user removed, nodes only the new plan grants have it
+// added, and with update set the nodes of both have it updated. Nodes the
+// user is assigned to directly are left alone.
+func (s *AgentService) pushPlanNodes(user *models.User, from, to map[uint]bool, update bool) error {
+	assigned, err := s.dbService.GetRepository().NodeFailover.AssignedNodeIDs(user.ID)
+	if err != nil {
+		return err
+	}
+
+	for nodeID := range from {
+		if !to[nodeID] && !assigned[nodeID] {
+			s.pushUser(nodeID, user, pbv1.UserCommand_REMOVE_USER)
+		}
+	}
+	for nodeID := range to {
+		switch {
+		case assigned[nodeID]:
+		case !from[nodeID]:
+			s.pushUser(nodeID, user, pbv1.UserCommand_ADD_USER)
+		case update:
+			s.pushUser(nodeID, user, pbv1.UserCommand_UPDATE_USER)
+		}
+	}
+	return nil
+}
+
+// nodeMover returns the mover reassigning users to the target node. Users
+// the source node remains granted to by their plan keep it.
+func (s *AgentService) nodeMover(migration *models.UserMigration) (userMover, error) {
+	repo := s.dbService.GetRepository()
+
+	target, err := repo.Node.GetByID(migration.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("target node %d: %w", migration.TargetID, err)
+	}
+
+	return func(user *models.User) error {
+		if err := repo.UserMigration.MoveNode(user.ID, migration.SourceID, target.ID); err != nil {
+			return err
+		}
+		s.pushUser(target.ID, user, pbv1.UserCommand_ADD_USER)
+		if !s.userHasNode(user.ID, migration.SourceID) {
+			s.pushUser(migration.SourceID, user, pbv1.UserCommand_REMOVE_USER)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"errors"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/models"
+)
+
+// validationError converts a model validation failure into an InvalidArgument
+// error listing every invalid field, named with the prefix of the request
+// message. Other errors are returned unchanged.
+func validationError(err error, prefix string) error {
+	var validationErr *models.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	violations := make([]apierror.FieldViolation, len(validationErr.Fields))
+	for i, field := range validationErr.Fields {
+		violations[i] = apierror.FieldViolation{
+			Field:       prefix + field.Field,
+			Description: field.Message,
+		}
+	}
+	return apierror.InvalidFields(violations)
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/alert"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// witnessMinCoverage is the fraction of a window the counter readings of a
+// node must span for the window to be checked. Nodes that joined late or
+// stopped reporting metrics are not judged on a partial window.
+const witnessMinCoverage = 0.8
+
+// nodeWitness accumulates what each node reported over the current window
+type nodeWitness struct {
+	mu      sync.Mutex
+	start   time.Time
+	windows map[uint]*witnessWindow
+}
+
+// witnessWindow is what a node reported over a window
+type witnessWindow struct {
+	interfaceBytes int64
+	// covered is the time spanned by the counter readings
+	covered      time.Duration
+	trafficBytes int64
+}
+
+func newNodeWitness(now time.Time) *nodeWitness {
+	return &nodeWitness{start: now, windows: make(map[uint]*witnessWindow)}
+}
+
+// window returns the node's window, the caller holds mu
+func (w *nodeWitness) window(nodeID uint) *witnessWindow {
+	window, ok := w.windows[nodeID]
+	if !ok {
+		window = &witnessWindow{}
+		w.windows[nodeID] = window
+	}
+	return window
+}
+
+// addInterface records the interface bytes between two counter readings
+func (w *nodeWitness) addInterface(nodeID uint, bytes int64, elapsed time.Duration) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	window := w.window(nodeID)
+	window.interfaceBytes += bytes
+	window.covered += elapsed
+}
+
+// addTraffic records reported user traffic
+func (w *nodeWitness) addTraffic(nodeID uint, bytes int64) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.window(nodeID).trafficBytes += bytes
+}
+
+// take returns the windows accumulated since start and starts new ones at now
+func (w *nodeWitness) take(now time.Time) (time.Time, map[uint]*witnessWindow) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	start, windows := w.start, w.windows
+	w.start, w.windows = now, make(map[uint]*witnessWindow)
+	return start, windows
+}
+
+// crossCheckNodes checks the reports of the nodes at the end of every window
+func (s *AgentService) crossCheckNodes(ctx context.Context) {
+	ticker := time.NewTicker(s.business().Witness.Window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Standbys receive no reports, their windows are empty
+			if !s.active() {
+				s.witness.take(time.Now())
+				continue
+			}
+			s.performWitnessCheck(time.Now())
+		}
+	}
+}
+
+// performWitnessCheck compares what each node reported over the window with
+// the panel's observations, flagging the nodes whose reports diverge and
+// clearing the flags of those whose reports agree again
+func (s *AgentService) performWitnessCheck(now time.Time) {
+	policy := s.business().Witness
+	repo := s.dbService.GetRepository()
+	start, windows := s.witness.take(now)
+	if len(windows) == 0 {
+		return
+	}
+
+	nodeIDs := make([]uint, 0, len(windows))
+	for nodeID := range windows {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	// Without probe data only the traffic is compared
+	probes, err := repo.Probe.GetNodeStats(nodeIDs, start)
+	if err != nil {
+		s.logger.Warn("Failed to get probe stats for the witness check", zap.Error(err))
+	}
+
+	thresholds := models.WitnessThresholds{
+		MinBytes:             policy.MinBytes,
+		MinInterfaceRatio:    policy.MinInterfaceRatio,
+		MaxInterfaceRatio:    policy.MaxInterfaceRatio,
+		MinProbeSamples:      int64(policy.MinProbeSamples),
+		MinProbeAvailability: policy.MinProbeAvailability,
+	}
+	minCovered := time.Duration(float64(now.Sub(start)) * witnessMinCoverage)
+
+	for nodeID, window := range windows {
+		if window.covered < minCovered {
+			continue
+		}
+		node, err := repo.Node.GetByID(nodeID)
+		if err != nil {
+			continue
+		}
+
+		witness := models.NodeWitness{
+			InterfaceBytes: window.interfaceBytes,
+			TrafficBytes:   window.trafficBytes,
+		}
+		if stats := probes[nodeID]; stats != nil {
+			witness.ProbeSamples = stats.Samples
+			witness.ProbeSuccesses = stats.Successes
+		}
+		s.applyWitnessFindings(node, witness, witness.Check(thresholds), now)
+	}
+}
+
+// applyWitnessFindings stores the findings of a node, and on new findings
+// publishes a node.flagged event and alerts the admins of nodes
+func (s *AgentService) applyWitnessFindings(node *models.Node, witness models.NodeWitness, findings []models.WitnessFinding, now time.Time) {
+	flags := models.JoinWitnessFindings(findings)
+	if flags == node.WitnessFlags {
+		return
+	}
+
+	var flaggedAt *time.Time
+	if len(findings) > 0 {
+		flaggedAt = node.WitnessFlaggedAt
+		if flaggedAt == nil {
+			flaggedAt = &now
+		}
+	}
+	if err := s.dbService.GetRepository().Node.SetWitnessFlags(node.ID, flags, flaggedAt); err != nil {
+		s.logger.Error("Failed to store witness findings", zap.Error(err), zap.Uint("node_id", node.ID))
+		return
+	}
+
+	if len(findings) == 0 {
+		s.logger.Info("Node reports agree with observations again", zap.Uint("node_id", node.ID), zap.String("node_name", node.Name))
+		return
+	}
+	s.logger.Warn("Node reports diverge from observations",
+		zap.Uint("node_id", node.ID),
+		zap.String("node_name", node.Name),
+		zap.String("findings", flags),
+		zap.Int64("interface_bytes", witness.InterfaceBytes),
+		zap.Int64("traffic_bytes", witness.TrafficBytes),
+		zap.Float64("probe_availability", witness.ProbeAvailability()),
+	)
+
+	s.events.Publish(&pbv1.Event{
+		Type: events.TypeNodeFlagged,
+		NodeWitness: &pbv1.NodeWitnessEvent{
+			NodeId:            strconv.FormatUint(uint64(node.ID), 10),
+			NodeName:          node.Name,
+			Findings:          strings.Split(flags, ","),
+			InterfaceBytes:    witness.InterfaceBytes,
+			TrafficBytes:      witness.TrafficBytes,
+			ProbeAvailability: witness.ProbeAvailability(),
+		},
+	})
+	s.alertNodeAdmins(node, witness, flags, *flaggedAt)
+}
+
+// alertNodeAdmins raises a node witness alert for each active admin allowed
+// to manage nodes, once per flagged stretch and set of findings
+func (s *AgentService) alertNodeAdmins(node *models.Node, witness models.NodeWitness, flags string, flaggedAt time.Time) {
+	message := fmt.Sprintf("The reports of node %s diverge from observations over the last %s (%s): %s through its interfaces, %s of user traffic reported",
+		node.Name, s.business().Witness.Window, flags,
+		models.FormatBytes(witness.InterfaceBytes), models.FormatBytes(witness.TrafficBytes))
+	if witness.ProbeSamples > 0 {
+		message += fmt.Sprintf(", %.0f%% of probes succeeded", witness.ProbeAvailability()*100)
+	}
+	message += ". Check the node's agent."
+
+	s.alertNodeManagers(alert.Alert{
+		Type:     models.NotificationTypeNodeWitness,
+		Severity: models.SeverityWarning,
+		Title:    "Node reports diverge",
+		Message:  message,
+		Key:      fmt.Sprintf("node_witness:%d:%d:%s", node.ID, flaggedAt.Unix(), flags),
+	})
+}
@@ -0,0 +1,212 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/blocklist"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/geoip"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// Access rules refused requests are recorded under
+const (
+	accessRuleAdminNetwork   = "admin_network"
+	accessRuleCountry        = "country/"
+	accessRuleUnknownCountry = "country/unknown"
+)
+
+// accessGuard refuses requests by the network and country they come from.
+// Refused requests are recorded in the admin audit log, once per client IP
+// and rule within the audit interval.
+type accessGuard struct {
+	config configv1.AccessConfig
+	// adminAllowed and adminDenied are the canonical CIDRs of the admin lists
+	adminAllowed []string
+	adminDenied  []string
+	// geo is the country database, nil without country blocking
+	geo              *geoip.Database
+	blockedCountries map[string]bool
+	allowedCountries map[string]bool
+
+	audit  repository.AdminAuditRepository
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	audited   map[string]time.Time
+	lastSweep time.Time
+}
+
+// newAccessGuard creates the guard of an access configuration
+func newAccessGuard(config configv1.AccessConfig, audit repository.AdminAuditRepository, logger *zap.Logger) (*accessGuard, error) {
+	g := &accessGuard{
+		config:  config,
+		audit:   audit,
+		logger:  logger,
+		audited: make(map[string]time.Time),
+	}
+	var err error
+	if g.adminAllowed, err = normalizeNetworks(config.AdminAllowedCIDRs); err != nil {
+		return nil, err
+	}
+	if g.adminDenied, err = normalizeNetworks(config.AdminDeniedCIDRs); err != nil {
+		return nil, err
+	}
+	if config.GeoIP.Enabled {
+		g.geo = geoip.NewDatabase(config.GeoIP, logger.Named("geoip"))
+		g.blockedCountries = countrySet(config.GeoIP.BlockedCountries)
+		g.allowedCountries = countrySet(config.GeoIP.AllowedCountries)
+	}
+	return g, nil
+}
+
+// normalizeNetworks returns the canonical CIDRs of networks and addresses
+func normalizeNetworks(values []string) ([]string, error) {
+	cidrs := make([]string, 0, len(values))
+	for _, value := range values {
+		cidr, err := blocklist.Normalize(models.BlocklistTypeCIDR, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access network: %w", err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// countrySet returns the uppercase country codes as a set
+func countrySet(codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(code)] = true
+	}
+	return set
+}
+
+// containsIP reports whether one of the CIDRs contains ip
+func containsIP(cidrs []string, ip net.IP) bool {
+	for _, cidr := range cidrs {
+		if blocklist.ContainsIP(cidr, ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// adminRule returns the rule refusing a client IP the admin endpoints, empty
+// when it is allowed
+func (g *accessGuard) adminRule(ip net.IP) string {
+	if len(g.adminAllowed) == 0 && len(g.adminDenied) == 0 {
+		return ""
+	}
+	if ip == nil || containsIP(g.adminDenied, ip) {
+		return accessRuleAdminNetwork
+	}
+	if len(g.adminAllowed) > 0 && !containsIP(g.adminAllowed, ip) {
+		return accessRuleAdminNetwork
+	}
+	return ""
+}
+
+// countryRule returns the rule refusing a client IP by its country, empty
+// when it is allowed. Requests are allowed while the database is not loaded.
+func (g *accessGuard) countryRule(ip net.IP) string {
+	if g.geo == nil || ip == nil {
+		return ""
+	}
+	country, err := g.geo.Country(ip)
+	if err != nil {
+		if !errors.Is(err, geoip.ErrNotLoaded) {
+			g.logger.Warn("GeoIP lookup failed", zap.String("ip", ip.String()), zap.Error(err))
+		}
+		return ""
+	}
+	switch {
+	case country == "":
+		if g.config.GeoIP.BlockUnknown {
+			return accessRuleUnknownCountry
+		}
+	case g.blockedCountries[country]:
+		return accessRuleCountry + country
+	case len(g.allowedCountries) > 0 && !g.allowedCountries[country]:
+		return accessRuleCountry + country
+	}
+	return ""
+}
+
+// refuse answers a request refused by rule with 403 and audits it
+func (g *accessGuard) refuse(c *gin.Context, rule string) {
+	g.record(c, rule)
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access denied from your network"})
+}
+
+// record writes a refused request to the audit log, unless the client IP
+// was recorded for the rule within the audit interval
+func (g *accessGuard) record(c *gin.Context, rule string) {
+	clientIP := c.ClientIP()
+	key := rule + "|" + clientIP
+	now := time.Now()
+
+	g.mu.Lock()
+	if now.Sub(g.lastSweep) >= g.config.AuditInterval {
+		for k, at := range g.audited {
+			if now.Sub(at) >= g.config.AuditInterval {
+				delete(g.audited, k)
+			}
+		}
+		g.lastSweep = now
+	}
+	at, seen := g.audited[key]
+	recent := seen && now.Sub(at) < g.config.AuditInterval
+	if !recent {
+		g.audited[key] = now
+	}
+	g.mu.Unlock()
+	if recent {
+		return
+	}
+
+	log := logger.FromContext(c.Request.Context(), g.logger)
+	log.Info("Request refused by access rule",
+		zap.String("rule", rule),
+		zap.String("client_ip", clientIP),
+		zap.String("path", c.Request.URL.Path),
+	)
+	entry := models.NewAccessBlockedAudit(c.Request.Method, c.Request.URL.Path, clientIP, c.GetString(contextKeyRequestID), rule)
+	if err := g.audit.Create(entry); err != nil {
+		log.Error("Failed to record refused request", zap.Error(err), zap.String("rule", rule))
+	}
+}
+
+// adminAccessMiddleware refuses the admin endpoints to client IPs outside
+// the allowed networks or in the denied ones
+func (s *Server) adminAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rule := s.access.adminRule(net.ParseIP(c.ClientIP())); rule != "" {
+			s.access.refuse(c, rule)
+			return
+		}
+		c.Next()
+	}
+}
+
+// countryAccessMiddleware refuses client IPs of blocked countries
+func (s *Server) countryAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rule := s.access.countryRule(net.ParseIP(c.ClientIP())); rule != "" {
+			s.access.refuse(c, rule)
+			return
+		}
+		c.Next()
+	}
+}
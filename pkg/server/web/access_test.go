@@ -0,0 +1,103 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := (&models.Database{DB: db}).AutoMigrate(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newAccessTestServer returns a server serving GET /admin behind the admin
+// access rules of config
+func newAccessTestServer(t *testing.T, config configv1.WebConfig) (*Server, *gorm.DB) {
+	t.Helper()
+	db := newTestDB(t)
+	engine, err := newEngine(config)
+	if err != nil {
+		t.Fatalf("newEngine: %v", err)
+	}
+	access, err := newAccessGuard(config.Access, repository.NewAdminAuditRepository(db), zap.NewNop())
+	if err != nil {
+		t.Fatalf("newAccessGuard: %v", err)
+	}
+	s := &Server{config: config, engine: engine, logger: zap.NewNop(), access: access}
+	engine.GET("/admin", s.adminAccessMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.ClientIP())
+	})
+	return s, db
+}
+
+// serve answers a GET request from remoteAddr with an X-Forwarded-For header
+func serve(engine http.Handler, path, remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAccessForgedForwardedFor(t *testing.T) {
+	config := *configv1.DefaultWebConfig()
+	config.Access.AdminAllowedCIDRs = []string{"10.0.0.0/8"}
+	s, db := newAccessTestServer(t, config)
+
+	// Without trusted proxies the header is ignored, the client is the peer
+	w := serve(s.engine, "/admin", "203.0.113.9:40000", "10.0.0.1")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("forged X-Forwarded-For = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	var entry models.AdminAuditLog
+	if err := db.First(&entry).Error; err != nil {
+		t.Fatalf("refused request not audited: %v", err)
+	}
+	if entry.ClientIP != "203.0.113.9" {
+		t.Errorf("audited IP = %q, want the peer address 203.0.113.9", entry.ClientIP)
+	}
+
+	if w := serve(s.engine, "/admin", "10.0.0.1:40000", ""); w.Code != http.StatusOK {
+		t.Errorf("request from an allowed network = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAdminAccessTrustedProxy(t *testing.T) {
+	config := *configv1.DefaultWebConfig()
+	config.Access.AdminAllowedCIDRs = []string{"10.0.0.0/8"}
+	config.Access.TrustedProxies = []string{"192.0.2.1"}
+	s, _ := newAccessTestServer(t, config)
+
+	// The header of a trusted proxy names the client
+	w := serve(s.engine, "/admin", "192.0.2.1:40000", "10.0.0.1")
+	if w.Code != http.StatusOK || w.Body.String() != "10.0.0.1" {
+		t.Errorf("request through a trusted proxy = %d %q, want 200 from 10.0.0.1", w.Code, w.Body.String())
+	}
+	w = serve(s.engine, "/admin", "192.0.2.1:40000", "203.0.113.9")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("outside client through a trusted proxy = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
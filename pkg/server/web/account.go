@@ -0,0 +1,104 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+)
+
+// accountEmailRequest is the body of password reset and verification mail requests
+type accountEmailRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// resetPasswordRequest is the body of a password reset
+type resetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// verifyEmailRequest is the body of an email verification
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// handleForgotPassword mails a password reset link. The response is the same
+// whether or not the address has an account.
+func (s *Server) handleForgotPassword(c *gin.Context) {
+	var req accountEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := s.accounts.RequestPasswordReset(req.Email, c.ClientIP()); err != nil {
+		s.writeAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the address belongs to an account, a password reset link was sent"})
+}
+
+// handleResetPassword sets a new password with the token of a reset link
+func (s *Server) handleResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := s.accounts.ResetPassword(req.Token, req.Password); err != nil {
+		s.writeAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "password reset"})
+}
+
+// handleRequestVerification mails a new verification link. The response is
+// the same whether or not the address has an account.
+func (s *Server) handleRequestVerification(c *gin.Context) {
+	var req accountEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := s.accounts.RequestVerification(req.Email, c.ClientIP()); err != nil {
+		s.writeAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the address belongs to an unverified account, a verification link was sent"})
+}
+
+// handleVerifyEmail verifies the email address with the token of a verification link
+func (s *Server) handleVerifyEmail(c *gin.Context) {
+	var req verifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := s.accounts.VerifyEmail(req.Token); err != nil {
+		s.writeAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "email verified"})
+}
+
+// writeAccountError maps the errors of the account flows to responses
+func (s *Server) writeAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidAccountToken),
+		errors.Is(err, auth.ErrPasswordTooShort),
+		errors.Is(err, auth.ErrPasswordTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrTooManyRequests):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		s.logger.Error("Account request failed", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Admin management endpoints, reserved for super admins
+
+// handleListAdmins lists the admins and super admins
+func (s *Server) handleListAdmins(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListAdmins(c.Request.Context(), &pbv1.ListAdminsRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateAdmin creates an admin from a CreateAdminRequest body
+func (s *Server) handleCreateAdmin(c *gin.Context) {
+	req := &pbv1.CreateAdminRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+
+	resp, err := s.management.CreateAdmin(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateAdmin replaces the role and permissions of an admin with an
+// UpdateAdminRequest body
+func (s *Server) handleUpdateAdmin(c *gin.Context) {
+	req := &pbv1.UpdateAdminRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.AdminId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+
+	resp, err := s.management.UpdateAdmin(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDisableAdmin disables an admin, which locks it out immediately
+func (s *Server) handleDisableAdmin(c *gin.Context) {
+	s.setAdminStatus(c, true)
+}
+
+// handleEnableAdmin enables a disabled admin again
+func (s *Server) handleEnableAdmin(c *gin.Context) {
+	s.setAdminStatus(c, false)
+}
+
+// setAdminStatus disables or enables the admin in the path
+func (s *Server) setAdminStatus(c *gin.Context, disabled bool) {
+	resp, err := s.management.SetAdminStatus(c.Request.Context(), &pbv1.SetAdminStatusRequest{
+		AdminId:  c.Param("id"),
+		Disabled: disabled,
+		Operator: c.MustGet(contextKeyClaims).(*auth.Claims).Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListAdminAuditLogs lists the changes admins made, optionally of one
+// ?admin_id between the RFC 3339 ?start and ?end
+func (s *Server) handleListAdminAuditLogs(c *gin.Context) {
+	start, ok := timeQuery(c, "start")
+	if !ok {
+		return
+	}
+	end, ok := timeQuery(c, "end")
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListAdminAuditLogs(c.Request.Context(), &pbv1.ListAdminAuditLogsRequest{
+		AdminId:   c.Query("admin_id"),
+		StartTime: start,
+		EndTime:   end,
+		Page:      int32(page),
+		PageSize:  int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// alertmanagerPayload is the body of an Alertmanager webhook notification
+// (version 4). Only the fields the panel uses are decoded.
+type alertmanagerPayload struct {
+	Alerts []alertmanagerAlert `json:"alerts"`
+}
+
+// alertmanagerAlert is an alert of an Alertmanager notification
+type alertmanagerAlert struct {
+	Status      string            `json:"status"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	// EndsAt is the zero time while the end of a firing alert is unknown
+	EndsAt      time.Time `json:"endsAt"`
+	Fingerprint string    `json:"fingerprint"`
+}
+
+// handleAlertmanagerWebhook receives the notifications of an Alertmanager
+// webhook receiver, authenticated by the configured bearer token, and stores
+// their alerts next to the panel's own
+func (s *Server) handleAlertmanagerWebhook(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Alertmanager.Token)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	var payload alertmanagerPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	req := &pbv1.IngestExternalAlertsRequest{
+		Source:     models.ExternalAlertSourceAlertmanager,
+		Alerts:     make([]*pbv1.ExternalAlert, len(payload.Alerts)),
+		NodeLabels: s.config.Alertmanager.NodeLabels,
+	}
+	for i, alert := range payload.Alerts {
+		req.Alerts[i] = &pbv1.ExternalAlert{
+			Fingerprint: alert.Fingerprint,
+			Status:      alert.Status,
+			Labels:      alert.Labels,
+			Annotations: alert.Annotations,
+			StartsAt:    optionalTimestamp(alert.StartsAt),
+			EndsAt:      optionalTimestamp(alert.EndsAt),
+		}
+	}
+	resp, err := s.management.IngestExternalAlerts(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// optionalTimestamp converts a time to protobuf format, nil for the zero time
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleListUserAnnouncements returns the caller's unread announcements, or
+// all visible ones with ?all=true
+func (s *Server) handleListUserAnnouncements(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	includeRead, _ := strconv.ParseBool(c.Query("all"))
+
+	resp, err := s.management.ListUserAnnouncements(c.Request.Context(), &pbv1.ListUserAnnouncementsRequest{
+		UserId:      claims.UserID,
+		IncludeRead: includeRead,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleMarkUserAnnouncementsRead marks the announcement_ids of the body as
+// read by the caller, or all visible announcements when the list is empty
+func (s *Server) handleMarkUserAnnouncementsRead(c *gin.Context) {
+	req := &pbv1.MarkAnnouncementsReadRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.MustGet(contextKeyClaims).(*auth.Claims).UserID
+	resp, err := s.management.MarkAnnouncementsRead(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// Announcement administration endpoints. Request and response bodies are the
+// JSON form of the matching ManagementService messages.
+
+// handleListAnnouncements lists announcements, pinned first
+func (s *Server) handleListAnnouncements(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListAnnouncements(c.Request.Context(), &pbv1.ListAnnouncementsRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateAnnouncement creates an announcement from an AnnouncementSpec body
+func (s *Server) handleCreateAnnouncement(c *gin.Context) {
+	spec := &pbv1.AnnouncementSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.CreateAnnouncement(c.Request.Context(), &pbv1.CreateAnnouncementRequest{
+		Announcement: spec,
+		Operator:     c.MustGet(contextKeyClaims).(*auth.Claims).Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetAnnouncement returns an announcement
+func (s *Server) handleGetAnnouncement(c *gin.Context) {
+	resp, err := s.management.GetAnnouncement(c.Request.Context(), &pbv1.GetAnnouncementRequest{AnnouncementId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateAnnouncement replaces the editable fields of an announcement with an AnnouncementSpec body
+func (s *Server) handleUpdateAnnouncement(c *gin.Context) {
+	spec := &pbv1.AnnouncementSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.UpdateAnnouncement(c.Request.Context(), &pbv1.UpdateAnnouncementRequest{
+		AnnouncementId: c.Param("id"),
+		Announcement:   spec,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeleteAnnouncement deletes an announcement
+func (s *Server) handleDeleteAnnouncement(c *gin.Context) {
+	resp, err := s.management.DeleteAnnouncement(c.Request.Context(), &pbv1.DeleteAnnouncementRequest{AnnouncementId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,159 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// contextKeyAPIKey is the gin context key holding the API key a request was made with
+const contextKeyAPIKey = "api_key"
+
+// apiKeyAuth authenticates a request by an API key, which acts as its owner,
+// and meters it against the key's limits
+func (s *Server) apiKeyAuth(c *gin.Context, token string) {
+	repo := s.dbService.GetRepository()
+	key, err := repo.APIKey.GetByHash(auth.HashAPIKey(token))
+	if err != nil || !key.IsActive() {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+		return
+	}
+	// Keys stop working with their owner's account
+	user, err := repo.User.GetByID(key.UserID)
+	if err != nil || !user.IsActive() {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+		return
+	}
+
+	if wait, err := s.apiKeys.Allow(key); err != nil {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Set(contextKeyClaims, &auth.Claims{
+		UserID:   strconv.FormatUint(uint64(user.ID), 10),
+		Username: user.Username,
+		Role:     string(user.Role),
+	})
+	c.Set(contextKeyAPIKey, key)
+	c.Next()
+
+	s.apiKeys.Record(key.ID, c.Writer.Status(), max(c.Request.ContentLength, 0), int64(max(c.Writer.Size(), 0)))
+}
+
+// sessionOnlyMiddleware rejects requests made with an API key, so that a
+// leaked key cannot be used to create or revoke keys or to change the second
+// factor. It must run after authMiddleware.
+func sessionOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKeyOf(c) != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed with an API key, log in instead"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// API key endpoints of the key owners
+
+// apiKeyRequest is the body of an API key creation
+type apiKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// handleListUserAPIKeys lists the caller's API keys
+func (s *Server) handleListUserAPIKeys(c *gin.Context) {
+	resp, err := s.management.ListAPIKeys(c.Request.Context(), &pbv1.ListAPIKeysRequest{
+		UserId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateUserAPIKey creates an API key for the caller, returning the key once
+func (s *Server) handleCreateUserAPIKey(c *gin.Context) {
+	var req apiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	resp, err := s.management.CreateAPIKey(c.Request.Context(), &pbv1.CreateAPIKeyRequest{
+		UserId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+		Name:   req.Name,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleRevokeUserAPIKey revokes an API key of the caller
+func (s *Server) handleRevokeUserAPIKey(c *gin.Context) {
+	resp, err := s.management.RevokeAPIKey(c.Request.Context(), &pbv1.RevokeAPIKeyRequest{
+		KeyId:  c.Param("id"),
+		UserId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetUserAPIKeyUsage returns the daily usage of an API key of the caller
+// over the last ?days. The usage of the last flush interval may be missing.
+func (s *Server) handleGetUserAPIKeyUsage(c *gin.Context) {
+	days, _ := strconv.Atoi(c.Query("days"))
+	resp, err := s.management.GetAPIKeyUsage(c.Request.Context(), &pbv1.GetAPIKeyUsageRequest{
+		KeyId:  c.Param("id"),
+		UserId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+		Days:   int32(days),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// API key endpoints of admins
+
+// handleListAPIKeys lists the API keys of a user
+func (s *Server) handleListAPIKeys(c *gin.Context) {
+	resp, err := s.management.ListAPIKeys(c.Request.Context(), &pbv1.ListAPIKeysRequest{
+		UserId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateAPIKeyLimits sets the limits of an API key from an UpdateAPIKeyLimitsRequest body
+func (s *Server) handleUpdateAPIKeyLimits(c *gin.Context) {
+	req := &pbv1.UpdateAPIKeyLimitsRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.KeyId = c.Param("id")
+	resp, err := s.management.UpdateAPIKeyLimits(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleRevokeAPIKey revokes an API key of any user
+func (s *Server) handleRevokeAPIKey(c *gin.Context) {
+	resp, err := s.management.RevokeAPIKey(c.Request.Context(), &pbv1.RevokeAPIKeyRequest{
+		KeyId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetAPIKeyUsage returns the daily usage of an API key of any user over the last ?days
+func (s *Server) handleGetAPIKeyUsage(c *gin.Context) {
+	days, _ := strconv.Atoi(c.Query("days"))
+	resp, err := s.management.GetAPIKeyUsage(c.Request.Context(), &pbv1.GetAPIKeyUsageRequest{
+		KeyId: c.Param("id"),
+		Days:  int32(days),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// apiKeyOf returns the API key a request was made with, nil for sessions
+func apiKeyOf(c *gin.Context) *models.APIKey {
+	if key, ok := c.Get(contextKeyAPIKey); ok {
+		return key.(*models.APIKey)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/metrics"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+var (
+	// errAPIKeyRateLimited is returned for requests above the rate of a key
+	errAPIKeyRateLimited = errors.New("API key rate limit exceeded")
+	// errAPIKeyQuotaExceeded is returned once a key used up its daily quota
+	errAPIKeyQuotaExceeded = errors.New("API key daily quota exceeded")
+)
+
+// apiKeyMeter meters the requests made with API keys and enforces their
+// limits. The usage is kept in memory and periodically added to the daily
+// usage in the database. Each web instance enforces the limits on its own:
+// the daily quota counts the requests stored at the start of the day and
+// those the instance served since.
+type apiKeyMeter struct {
+	config configv1.APIKeyConfig
+	keys   repository.APIKeyRepository
+	logger *zap.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	limiters map[uint]*apiKeyLimiter
+	// pending is the usage not written to the database yet, by key and day
+	pending  map[apiKeyDay]*models.APIKeyUsage
+	lastUsed map[uint]time.Time
+
+	done chan struct{}
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// apiKeyDay identifies the usage of a key on a UTC day
+type apiKeyDay struct {
+	keyID uint
+	day   time.Time
+}
+
+// apiKeyLimiter is the limit state of a key
+type apiKeyLimiter struct {
+	// tokens of the rate limit bucket as of updated
+	tokens  float64
+	updated time.Time
+
+	// requests counts the requests of the key on day
+	day      time.Time
+	requests int64
+}
+
+func newAPIKeyMeter(config configv1.APIKeyConfig, keys repository.APIKeyRepository, logger *zap.Logger) *apiKeyMeter {
+	return &apiKeyMeter{
+		config:   config,
+		keys:     keys,
+		logger:   logger,
+		now:      time.Now,
+		limiters: make(map[uint]*apiKeyLimiter),
+		pending:  make(map[apiKeyDay]*models.APIKeyUsage),
+		lastUsed: make(map[uint]time.Time),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start writes the metered usage to the database every flush interval
+func (m *apiKeyMeter) Start(ctx context.Context) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.config.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-m.done:
+				return
+			case <-ticker.C:
+				m.flush()
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic writes and writes the usage metered since the last
+func (m *apiKeyMeter) Stop() {
+	m.once.Do(func() { close(m.done) })
+	m.wg.Wait()
+	m.flush()
+}
+
+// limits returns the daily quota, rate and burst of a key, falling back to
+// the configured defaults
+func (m *apiKeyMeter) limits(key *models.APIKey) (quota int64, rate float64, burst int) {
+	quota, rate, burst = key.DailyQuota, key.RateLimit, key.Burst
+	if quota == 0 {
+		quota = m.config.DailyQuota
+	}
+	if rate == 0 {
+		rate = m.config.RateLimit
+	}
+	if burst == 0 {
+		burst = m.config.Burst
+	}
+	return quota, rate, max(burst, 1)
+}
+
+// Allow admits a request made with a key, counting it against the quota.
+// Refused requests return how long to wait before retrying.
+func (m *apiKeyMeter) Allow(key *models.APIKey) (time.Duration, error) {
+	quota, rate, burst := m.limits(key)
+	now := m.now()
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	// The requests stored for the day are loaded once per key and day
+	var stored int64
+	if quota > 0 && m.needsDay(key.ID, today) {
+		var err error
+		if stored, err = m.keys.GetDailyRequests(key.ID, today); err != nil {
+			m.logger.Warn("Failed to load API key usage, quota counted from now",
+				zap.Uint("key_id", key.ID), zap.Error(err))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limiter, ok := m.limiters[key.ID]
+	if !ok {
+		limiter = &apiKeyLimiter{tokens: float64(burst), updated: now}
+		m.limiters[key.ID] = limiter
+	}
+	if !limiter.day.Equal(today) {
+		limiter.day = today
+		limiter.requests = stored
+	}
+
+	if quota > 0 && limiter.requests >= quota {
+		m.reject(key.ID, today, "quota")
+		return today.AddDate(0, 0, 1).Sub(now), errAPIKeyQuotaExceeded
+	}
+
+	if rate > 0 {
+		elapsed := now.Sub(limiter.updated).Seconds()
+		limiter.tokens = math.Min(float64(burst), limiter.tokens+elapsed*rate)
+		limiter.updated = now
+		if limiter.tokens < 1 {
+			m.reject(key.ID, today, "rate_limit")
+			wait := time.Duration((1 - limiter.tokens) / rate * float64(time.Second))
+			return wait, errAPIKeyRateLimited
+		}
+		limiter.tokens--
+	}
+
+	limiter.requests++
+	return 0, nil
+}
+
+// needsDay reports whether the requests of a key on a day were not loaded yet
+func (m *apiKeyMeter) needsDay(keyID uint, day time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limiter, ok := m.limiters[keyID]
+	return !ok || !limiter.day.Equal(day)
+}
+
+// reject counts a refused request, m.mu must be held
+func (m *apiKeyMeter) reject(keyID uint, day time.Time, reason string) {
+	m.usage(keyID, day).Rejected++
+	metrics.RecordAPIKeyRejected(strconv.FormatUint(uint64(keyID), 10), reason)
+}
+
+// usage returns the pending usage of a key on a day, m.mu must be held
+func (m *apiKeyMeter) usage(keyID uint, day time.Time) *models.APIKeyUsage {
+	id := apiKeyDay{keyID: keyID, day: day}
+	usage, ok := m.pending[id]
+	if !ok {
+		usage = &models.APIKeyUsage{}
+		m.pending[id] = usage
+	}
+	return usage
+}
+
+// Record meters a served request made with a key
+func (m *apiKeyMeter) Record(keyID uint, statusCode int, bytesIn, bytesOut int64) {
+	now := m.now()
+
+	m.mu.Lock()
+	usage := m.usage(keyID, now.UTC().Truncate(24*time.Hour))
+	usage.Requests++
+	if statusCode >= 500 {
+		usage.Errors++
+	}
+	usage.BytesIn += bytesIn
+	usage.BytesOut += bytesOut
+	m.lastUsed[keyID] = now
+	m.mu.Unlock()
+
+	metrics.RecordAPIKeyRequest(strconv.FormatUint(uint64(keyID), 10), statusCode, bytesIn, bytesOut)
+}
+
+// flush adds the pending usage to the database. Usage that could not be
+// written is kept for the next flush.
+func (m *apiKeyMeter) flush() {
+	m.mu.Lock()
+	pending, lastUsed := m.pending, m.lastUsed
+	m.pending = make(map[apiKeyDay]*models.APIKeyUsage)
+	m.lastUsed = make(map[uint]time.Time)
+	m.mu.Unlock()
+
+	for id, usage := range pending {
+		if err := m.keys.AddUsage(id.keyID, id.day, *usage); err != nil {
+			m.logger.Error("Failed to store API key usage", zap.Uint("key_id", id.keyID), zap.Error(err))
+			m.mu.Lock()
+			m.usage(id.keyID, id.day).Add(*usage)
+			m.mu.Unlock()
+		}
+	}
+	for keyID, usedAt := range lastUsed {
+		if err := m.keys.UpdateLastUsed(keyID, usedAt); err != nil {
+			m.logger.Warn("Failed to update API key last use", zap.Uint("key_id", keyID), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/repository"
+)
+
+// jwtUserRepository adapts the user repository to the auth package
+type jwtUserRepository struct {
+	users repository.UserRepository
+}
+
+// GetByID gets basic user info for token refresh
+func (r *jwtUserRepository) GetByID(id uint) (*auth.User, error) {
+	user, err := r.users.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return &auth.User{
+		ID:       user.ID,
+		Username: user.Username,
+		Role:     string(user.Role),
+		Active:   user.IsActive(),
+	}, nil
+}
+
+// loginRequest is the body of a login request. Password providers use
+// username and password, OIDC providers use code and redirect_uri.
+type loginRequest struct {
+	Provider      string `json:"provider"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	Code          string `json:"code"`
+	RedirectURI   string `json:"redirect_uri"`
+	TwoFactorCode string `json:"two_factor_code"`
+}
+
+// handleLogin verifies the credentials and issues an access and refresh token
+func (s *Server) handleLogin(c *gin.Context) {
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	result, err := s.authn.Login(c.Request.Context(), auth.LoginRequest{
+		Provider:      req.Provider,
+		Username:      req.Username,
+		Password:      req.Password,
+		Code:          req.Code,
+		RedirectURI:   req.RedirectURI,
+		TwoFactorCode: req.TwoFactorCode,
+		ClientIP:      c.ClientIP(),
+	})
+	if err != nil {
+		var setup *auth.TwoFactorSetupError
+		switch {
+		case errors.As(err, &setup):
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                     err.Error(),
+				"two_factor_setup_required": true,
+				"setup_token":               setup.Token,
+				"setup_token_expires_at":    setup.ExpiresAt,
+			})
+		case errors.Is(err, auth.ErrUnknownProvider):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, auth.ErrTwoFactorRequired):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "two_factor_required": true})
+		case errors.Is(err, auth.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "email_verification_required": true})
+		case errors.Is(err, auth.ErrInvalidCredentials),
+			errors.Is(err, auth.ErrInvalidTOTPCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, auth.ErrAccountInactive),
+			errors.Is(err, auth.ErrProviderNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			s.logger.Error("Login failed", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  result.AccessToken,
+		"refresh_token": result.RefreshToken,
+		"expires_at":    result.ExpiresAt,
+		"user": gin.H{
+			"id":       result.User.ID,
+			"username": result.User.Username,
+			"role":     result.User.Role,
+		},
+	})
+}
+
+// logoutRequest is the optional body of a logout
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// handleLogout revokes the access token used for the request and the refresh
+// token issued with it
+func (s *Server) handleLogout(c *gin.Context) {
+	if apiKeyOf(c) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API keys are revoked, not logged out"})
+		return
+	}
+	token := c.GetString(contextKeyToken)
+
+	var req logoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+	// Revoked first, an invalid refresh token leaves the session usable
+	if req.RefreshToken != "" {
+		if err := s.jwtManager.RevokeRefreshToken(req.RefreshToken, c.MustGet(contextKeyClaims).(*auth.Claims).UserID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid refresh token"})
+			return
+		}
+	}
+
+	if err := s.jwtManager.RevokeToken(token); err != nil {
+		s.logger.Error("Failed to revoke token on logout", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// refreshRequest is the body of an access token refresh
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// handleRefresh issues a new access token for a refresh token. A 401 means
+// the refresh token is no longer valid and the user has to log in again.
+func (s *Server) handleRefresh(c *gin.Context) {
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	token, err := s.jwtManager.RefreshToken(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token", "login_required": true})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"expires_at":   time.Now().Add(s.config.Auth.JWTExpiration),
+	})
+}
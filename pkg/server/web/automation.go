@@ -0,0 +1,99 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Automation rule endpoints. Rules run actions on the domain events matching
+// their conditions, the API server evaluating them as events are published.
+
+// handleListAutomationRules lists the rules by name
+func (s *Server) handleListAutomationRules(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListAutomationRules(c.Request.Context(), &pbv1.ListAutomationRulesRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateAutomationRule creates a rule from an AutomationRuleSpec body
+// with the caller as operator
+func (s *Server) handleCreateAutomationRule(c *gin.Context) {
+	spec := &pbv1.AutomationRuleSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.CreateAutomationRule(c.Request.Context(), &pbv1.CreateAutomationRuleRequest{
+		Rule:     spec,
+		Operator: c.MustGet(contextKeyClaims).(*auth.Claims).Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateAutomationRule replaces a rule with an AutomationRuleSpec body
+func (s *Server) handleUpdateAutomationRule(c *gin.Context) {
+	spec := &pbv1.AutomationRuleSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.UpdateAutomationRule(c.Request.Context(), &pbv1.UpdateAutomationRuleRequest{
+		RuleId:   c.Param("id"),
+		Rule:     spec,
+		Operator: c.MustGet(contextKeyClaims).(*auth.Claims).Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeleteAutomationRule deletes a rule with its executions
+func (s *Server) handleDeleteAutomationRule(c *gin.Context) {
+	resp, err := s.management.DeleteAutomationRule(c.Request.Context(), &pbv1.DeleteAutomationRuleRequest{
+		RuleId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleTestAutomationRule evaluates the rule of a TestAutomationRuleRequest
+// body on its sample event without running the actions
+func (s *Server) handleTestAutomationRule(c *gin.Context) {
+	req := &pbv1.TestAutomationRuleRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	resp, err := s.management.TestAutomationRule(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleTestSavedAutomationRule evaluates the rule in the path on the sample
+// event of an {"event": {...}} body
+func (s *Server) handleTestSavedAutomationRule(c *gin.Context) {
+	req := &pbv1.TestAutomationRuleRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.RuleId = c.Param("id")
+
+	resp, err := s.management.TestAutomationRule(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListAutomationExecutions lists the executions of the rule in the
+// path, newest first
+func (s *Server) handleListAutomationExecutions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListAutomationExecutions(c.Request.Context(), &pbv1.ListAutomationExecutionsRequest{
+		RuleId:   c.Param("id"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Registration blocklist endpoints. Imported disposable domain lists are
+// refreshed by the API server, which is where RefreshBlocklists is served.
+
+// handleListBlocklistEntries lists entries, optionally of one ?type and ?source
+// or matching ?search
+func (s *Server) handleListBlocklistEntries(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListBlocklistEntries(c.Request.Context(), &pbv1.ListBlocklistEntriesRequest{
+		Type:     c.Query("type"),
+		Source:   c.Query("source"),
+		Search:   c.Query("search"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateBlocklistEntry blocklists an email, domain or network with the
+// caller as operator
+func (s *Server) handleCreateBlocklistEntry(c *gin.Context) {
+	req := &pbv1.CreateBlocklistEntryRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+
+	resp, err := s.management.CreateBlocklistEntry(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeleteBlocklistEntry removes a blocklist entry
+func (s *Server) handleDeleteBlocklistEntry(c *gin.Context) {
+	resp, err := s.management.DeleteBlocklistEntry(c.Request.Context(), &pbv1.DeleteBlocklistEntryRequest{
+		EntryId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+)
+
+// brandingFor returns the effective branding of a user: the tenant's branding
+// with empty fields taken from the configured default. A tenant that cannot be
+// loaded falls back to the default so that users are never locked out by it.
+func (s *Server) brandingFor(user *models.User) models.Branding {
+	defaults := models.DefaultBranding(s.config.Branding)
+	if user.TenantID == nil {
+		return defaults
+	}
+
+	tenant, err := s.dbService.GetRepository().Tenant.GetByID(*user.TenantID)
+	if err != nil {
+		s.logger.Warn("Failed to get tenant, using default branding", zap.Error(err),
+			zap.Uint("user_id", user.ID), zap.Uint("tenant_id", *user.TenantID))
+		return defaults
+	}
+	return tenant.Branding.Merge(defaults)
+}
+
+// handleUserBranding returns the branding the caller's portal should display
+func (s *Server) handleUserBranding(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	userID, err := strconv.ParseUint(claims.UserID, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	user, err := s.dbService.GetRepository().User.GetByID(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		return
+	}
+
+	branding := s.brandingFor(user)
+	c.JSON(http.StatusOK, gin.H{
+		"branding":      branding,
+		"subscribe_url": branding.SubscriptionURL(user.SubscriptionToken),
+	})
+}
@@ -0,0 +1,46 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleListActiveConnections lists the open connections nodes reported,
+// with ?user_id and ?node_id filters
+func (s *Server) handleListActiveConnections(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListActiveConnections(c.Request.Context(), &pbv1.ListActiveConnectionsRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+		UserId:   c.Query("user_id"),
+		NodeId:   c.Query("node_id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleKillConnection has the node of the connection of the path close it
+func (s *Server) handleKillConnection(c *gin.Context) {
+	req := &pbv1.KillConnectionRequest{SessionId: c.Param("session_id")}
+	var resp *pbv1.KillConnectionResponse
+	err := s.callActiveAPIServer(c.Request.Context(), func(client pbv1.ManagementServiceClient) (err error) {
+		resp, err = client.KillConnection(c.Request.Context(), req)
+		return err
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleKickUser has the nodes of the user of the path close its connections
+func (s *Server) handleKickUser(c *gin.Context) {
+	req := &pbv1.KickUserRequest{UserId: c.Param("id")}
+	var resp *pbv1.KickUserResponse
+	err := s.callActiveAPIServer(c.Request.Context(), func(client pbv1.ManagementServiceClient) (err error) {
+		resp, err = client.KickUser(c.Request.Context(), req)
+		return err
+	})
+	s.writeManagementResponse(c, resp, err)
+}
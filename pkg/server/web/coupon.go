@@ -0,0 +1,102 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// validateCouponRequest is the body of a user's coupon check at checkout
+type validateCouponRequest struct {
+	Code   string `json:"code"`
+	PlanID string `json:"plan_id"`
+}
+
+// handleValidateUserCoupon prices the caller's order of a public plan with a
+// coupon, without using it up
+func (s *Server) handleValidateUserCoupon(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+
+	var req validateCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" || req.PlanID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code and plan_id are required"})
+		return
+	}
+	if !s.checkPublicPlan(c, req.PlanID) {
+		return
+	}
+
+	resp, err := s.management.ValidateCoupon(c.Request.Context(), &pbv1.ValidateCouponRequest{
+		Code:   req.Code,
+		UserId: claims.UserID,
+		PlanId: req.PlanID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// Coupon administration endpoints. Request and response bodies are the JSON
+// form of the matching ManagementService messages.
+
+// handleListCoupons lists coupons, filtered by the search query parameter
+func (s *Server) handleListCoupons(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListCoupons(c.Request.Context(), &pbv1.ListCouponsRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+		Search:   c.Query("search"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateCoupon creates a coupon from a CouponSpec body
+func (s *Server) handleCreateCoupon(c *gin.Context) {
+	spec := &pbv1.CouponSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.CreateCoupon(c.Request.Context(), &pbv1.CreateCouponRequest{Coupon: spec})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetCoupon returns a coupon
+func (s *Server) handleGetCoupon(c *gin.Context) {
+	resp, err := s.management.GetCoupon(c.Request.Context(), &pbv1.GetCouponRequest{CouponId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateCoupon replaces the editable fields of a coupon with a CouponSpec body
+func (s *Server) handleUpdateCoupon(c *gin.Context) {
+	spec := &pbv1.CouponSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.UpdateCoupon(c.Request.Context(), &pbv1.UpdateCouponRequest{
+		CouponId: c.Param("id"),
+		Coupon:   spec,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeleteCoupon deletes a coupon
+func (s *Server) handleDeleteCoupon(c *gin.Context) {
+	resp, err := s.management.DeleteCoupon(c.Request.Context(), &pbv1.DeleteCouponRequest{CouponId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleAllCouponStatistics returns redemption statistics across all coupons
+func (s *Server) handleAllCouponStatistics(c *gin.Context) {
+	resp, err := s.management.GetCouponStatistics(c.Request.Context(), &pbv1.GetCouponStatisticsRequest{})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCouponStatistics returns redemption statistics of one coupon
+func (s *Server) handleCouponStatistics(c *gin.Context) {
+	resp, err := s.management.GetCouponStatistics(c.Request.Context(), &pbv1.GetCouponStatisticsRequest{CouponId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
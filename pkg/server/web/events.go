@@ -0,0 +1,312 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/util"
+)
+
+// Real-time dashboard events. Every WebSocket client subscribes to the web
+// server's bus. With the in-memory bus, eventRelay republishes there the
+// events the API server streams; with Redis, the bus reads the stream itself.
+
+// eventTopicPermissions are the admin permissions required per topic
+var eventTopicPermissions = map[string]models.AdminPermission{
+	events.TopicNodes:   models.AdminPermissionNodes,
+	events.TopicTraffic: models.AdminPermissionNodes,
+	events.TopicAlerts:  models.AdminPermissionUsers,
+	events.TopicUsers:   models.AdminPermissionUsers,
+}
+
+// eventRelay republishes the events of the API server on a local bus
+type eventRelay struct {
+	config    configv1.EventsConfig
+	apiServer configv1.APIServerConnection
+	bus       *events.Bus
+	logger    *zap.Logger
+}
+
+// run watches the API server until ctx is done. A broken stream is watched
+// again after the reconnect interval, on the next failover address, since
+// only the active API instance streams events.
+func (r *eventRelay) run(ctx context.Context) {
+	addresses := append([]string{
+		net.JoinHostPort(r.apiServer.Address, strconv.Itoa(r.apiServer.Port)),
+	}, r.apiServer.FailoverAddresses...)
+
+	for i := 0; ; i = (i + 1) % len(addresses) {
+		err := r.watch(ctx, addresses[i])
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Warn("Event stream from API server broke", zap.String("address", addresses[i]), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.config.ReconnectInterval):
+		}
+	}
+}
+
+// watch relays the events of the API server at address until the stream breaks
+func (r *eventRelay) watch(ctx context.Context, address string) error {
+	conn, err := dialAPIServer(r.apiServer, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	stream, err := pbv1.NewManagementServiceClient(conn).WatchEvents(ctx, &pbv1.WatchEventsRequest{})
+	if err != nil {
+		return err
+	}
+	r.logger.Info("Watching API server events", zap.String("address", address))
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		r.bus.Publish(event)
+	}
+}
+
+// dialAPIServer creates a client connection to the API server at address
+// ("host:port"). The connection is established on first use.
+func dialAPIServer(apiServer configv1.APIServerConnection, address string) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if !apiServer.Insecure {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig, err := util.NewClientTLSConfig(apiServer.CertFile, apiServer.KeyFile, apiServer.CAFile, host, apiServer.PinnedPublicKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS configuration: %w", err)
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithUnaryInterceptor(requestIDUnaryClientInterceptor),
+		grpc.WithStreamInterceptor(requestIDStreamClientInterceptor),
+	}
+	if apiServer.AuthToken != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&managementTokenCredentials{
+			token:      apiServer.AuthToken,
+			requireTLS: !apiServer.Insecure,
+		}))
+	}
+	return grpc.NewClient(address, opts...)
+}
+
+// callActiveAPIServer calls the management service of the API server for
+// the methods the in-process one cannot serve, such as those talking to
+// agents. Only the active API instance holds the command queues of the
+// agents, so the failover servers are tried while the ones before them are
+// unavailable.
+func (s *Server) callActiveAPIServer(ctx context.Context, call func(pbv1.ManagementServiceClient) error) error {
+	apiServer := s.config.APIServer
+	addresses := append([]string{
+		net.JoinHostPort(apiServer.Address, strconv.Itoa(apiServer.Port)),
+	}, apiServer.FailoverAddresses...)
+
+	var err error
+	for _, address := range addresses {
+		var conn *grpc.ClientConn
+		conn, err = dialAPIServer(apiServer, address)
+		if err != nil {
+			err = status.Errorf(codes.Unavailable, "failed to connect to the API server: %v", err)
+			continue
+		}
+		err = call(pbv1.NewManagementServiceClient(conn))
+		conn.Close()
+		if status.Code(err) != codes.Unavailable || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// requestIDUnaryClientInterceptor passes the request ID of a call's context
+// on to the API server in the x-request-id metadata
+func requestIDUnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(outgoingRequestID(ctx), method, req, reply, cc, opts...)
+}
+
+// requestIDStreamClientInterceptor is requestIDUnaryClientInterceptor for streams
+func requestIDStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(outgoingRequestID(ctx), desc, cc, method, opts...)
+}
+
+// outgoingRequestID adds the request ID of ctx to its outgoing metadata
+func outgoingRequestID(ctx context.Context) context.Context {
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		return metadata.AppendToOutgoingContext(ctx, logger.RequestIDHeader, requestID)
+	}
+	return ctx
+}
+
+// managementTokenCredentials attaches the management token to every RPC
+type managementTokenCredentials struct {
+	token      string
+	requireTLS bool
+}
+
+// GetRequestMetadata returns the authorization metadata for a call
+func (c *managementTokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{
+		"authorization": "Bearer " + c.token,
+	}, nil
+}
+
+// RequireTransportSecurity reports whether the token may only be sent over TLS
+func (c *managementTokenCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// eventStreamMessage is sent by clients to change their topics
+type eventStreamMessage struct {
+	Topics []string `json:"topics"`
+}
+
+// allowedEventTopics parses a comma-separated topic list and checks that the
+// admin may receive each topic. An empty list is every topic the admin may
+// receive.
+func allowedEventTopics(admin *models.User, list string) ([]string, error) {
+	requested, unknown := events.ParseTopics(list)
+	if unknown != "" {
+		return nil, fmt.Errorf("unknown topic %q", unknown)
+	}
+
+	var topics []string
+	for _, topic := range requested {
+		if admin.HasAdminPermission(eventTopicPermissions[topic]) {
+			topics = append(topics, topic)
+		} else if list != "" {
+			return nil, fmt.Errorf("admin permission %s required for topic %s", eventTopicPermissions[topic], topic)
+		}
+	}
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topic is allowed")
+	}
+	return topics, nil
+}
+
+// eventTokenFromQuery moves an ?access_token to the Authorization header, as
+// browsers cannot set headers on WebSocket requests. It must run before
+// authMiddleware.
+func eventTokenFromQuery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
+// handleEventStream upgrades to a WebSocket streaming the events of ?topics
+// as JSON. Clients change their topics by sending {"topics": [...]}.
+func (s *Server) handleEventStream(c *gin.Context) {
+	admin := c.MustGet(contextKeyAdmin).(*models.User)
+	topics, err := allowedEventTopics(admin, c.Query("topics"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Clients authenticate with a bearer token rather than cookies, so the
+	// origin is not checked
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		s.streamEvents(ws, admin, topics)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// streamEvents sends the events of the subscription to the client until it
+// disconnects, falls behind the write timeout or the server stops
+func (s *Server) streamEvents(ws *websocket.Conn, admin *models.User, topics []string) {
+	defer ws.Close()
+
+	sub := s.events.Subscribe(topics, s.config.Events.ClientBuffer)
+	defer sub.Close()
+
+	var writeMu sync.Mutex
+	send := func(data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := ws.SetWriteDeadline(time.Now().Add(s.config.Events.WriteTimeout)); err != nil {
+			return err
+		}
+		return websocket.Message.Send(ws, string(data))
+	}
+
+	// Reads topic changes until the client disconnects
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var msg eventStreamMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			topics, err := allowedEventTopics(admin, strings.Join(msg.Topics, ","))
+			if err != nil {
+				reply, _ := json.Marshal(gin.H{"error": err.Error()})
+				if send(reply) != nil {
+					return
+				}
+				continue
+			}
+			sub.SetTopics(topics)
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-s.stopped:
+			return
+		case event := <-sub.Events():
+			data, err := managementJSON.Marshal(event)
+			if err != nil {
+				s.logger.Error("Failed to encode event", zap.Error(err))
+				continue
+			}
+			if err := send(data); err != nil {
+				s.logger.Debug("Event client disconnected",
+					zap.Uint("admin_id", admin.ID),
+					zap.Uint64("dropped", sub.Dropped()),
+					zap.Error(err),
+				)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleGeoDataStatus returns the cached geo databases and the versions each
+// node reported. Syncing is only available through the API server.
+func (s *Server) handleGeoDataStatus(c *gin.Context) {
+	resp, err := s.management.GetGeoDataStatus(c.Request.Context(), &pbv1.GetGeoDataStatusRequest{})
+	s.writeManagementResponse(c, resp, err)
+}
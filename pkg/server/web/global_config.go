@@ -0,0 +1,48 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// headerConfirmation carries the confirmation text the safety policy asks for
+// on requests without a body
+const headerConfirmation = "X-Confirmation"
+
+// handleGetGlobalConfig returns the global config, including the panel
+// environment and safety rules
+func (s *Server) handleGetGlobalConfig(c *gin.Context) {
+	resp, err := s.management.GetGlobalConfig(c.Request.Context(), &emptypb.Empty{})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateGlobalConfig updates the global config with an
+// UpdateGlobalConfigRequest body
+func (s *Server) handleUpdateGlobalConfig(c *gin.Context) {
+	req := &pbv1.UpdateGlobalConfigRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.UpdateGlobalConfig(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListGlobalConfigHistory lists the changes of the system settings,
+// of the one in the key query parameter when set, newest first
+func (s *Server) handleListGlobalConfigHistory(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListGlobalConfigHistory(c.Request.Context(), &pbv1.ListGlobalConfigHistoryRequest{
+		Key:      c.Query("key"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// checkAPIServer asks the API server, or one of its failover servers, for
+// its gRPC health. Standbys report serving too, any of them will do.
+func (s *Server) checkAPIServer(ctx context.Context) error {
+	apiServer := s.config.APIServer
+	addresses := append([]string{
+		net.JoinHostPort(apiServer.Address, strconv.Itoa(apiServer.Port)),
+	}, apiServer.FailoverAddresses...)
+
+	var errs []error
+	for _, address := range addresses {
+		err := checkGRPCHealth(ctx, apiServer, address)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", address, err))
+	}
+	return errors.Join(errs...)
+}
+
+// checkGRPCHealth checks the overall status of the gRPC health service at address
+func checkGRPCHealth(ctx context.Context, apiServer configv1.APIServerConnection, address string) error {
+	conn, err := dialAPIServer(apiServer, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleSendTestMail sends a template filled with sample data right away,
+// with the body in the JSON form of SendTestMailRequest
+func (s *Server) handleSendTestMail(c *gin.Context) {
+	req := &pbv1.SendTestMailRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	resp, err := s.management.SendTestMail(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
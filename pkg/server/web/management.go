@@ -0,0 +1,103 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"sing-box-web/pkg/apierror"
+	"sing-box-web/pkg/logger"
+)
+
+// managementJSON renders management responses with the proto field names
+var managementJSON = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// bindManagementRequest decodes a JSON body into a management request. Path
+// parameters are set by the caller afterwards, so they win over the body.
+func bindManagementRequest(c *gin.Context, req proto.Message) bool {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return false
+	}
+	if len(body) == 0 {
+		return true
+	}
+	if err := protojson.Unmarshal(body, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return false
+	}
+	return true
+}
+
+// queryFieldMask reads a field mask from a comma-separated query parameter,
+// nil when it is absent
+func queryFieldMask(c *gin.Context, key string) *fieldmaskpb.FieldMask {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+	mask := &fieldmaskpb.FieldMask{}
+	for _, path := range strings.Split(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			mask.Paths = append(mask.Paths, path)
+		}
+	}
+	return mask
+}
+
+// writeManagementResponse writes the result of a management service call,
+// translating its gRPC status error to the matching HTTP status. Error bodies
+// carry the request ID, internal errors are only detailed in the logs.
+func (s *Server) writeManagementResponse(c *gin.Context, resp proto.Message, err error) {
+	requestID := c.GetString(contextKeyRequestID)
+	if err != nil {
+		code := apierror.HTTPStatus(err)
+		if code == http.StatusInternalServerError {
+			logger.FromContext(c.Request.Context(), s.logger).Error("Management call failed",
+				zap.Error(err),
+				zap.String("path", c.Request.URL.Path),
+			)
+			c.JSON(code, gin.H{"error": "Internal server error", "request_id": requestID})
+			return
+		}
+
+		body := gin.H{"error": status.Convert(err).Message(), "request_id": requestID}
+		if reason := apierror.Reason(err); reason != "" {
+			body["reason"] = reason
+		}
+		if fields := apierror.FieldViolations(err); len(fields) > 0 {
+			body["fields"] = fields
+		}
+		c.JSON(code, body)
+		return
+	}
+
+	data, err := managementJSON.Marshal(resp)
+	if err != nil {
+		logger.FromContext(c.Request.Context(), s.logger).Error("Failed to encode management response", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "request_id": requestID})
+		return
+	}
+
+	// Admins get display values next to the raw ones, so that frontends do
+	// not convert sizes, durations and times each their own way
+	if formatter := s.displayFormatter(c); formatter != nil {
+		annotated, err := formatter.Annotate(data)
+		if err != nil {
+			logger.FromContext(c.Request.Context(), s.logger).Error("Failed to annotate management response", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error", "request_id": requestID})
+			return
+		}
+		data = annotated
+		c.Header("Content-Language", string(formatter.Locale()))
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/models"
+)
+
+const (
+	// contextKeyClaims is the gin context key holding validated JWT claims
+	contextKeyClaims = "claims"
+	// contextKeyToken is the gin context key holding the raw bearer token
+	contextKeyToken = "token"
+	// contextKeyAdmin is the gin context key holding the calling admin
+	contextKeyAdmin = "admin"
+	// contextKeyRequestID is the gin context key holding the request ID
+	contextKeyRequestID = "request_id"
+
+	// headerTokenRefresh is set to "required" on responses to an access
+	// token accepted after it expired
+	headerTokenRefresh = "X-Token-Refresh"
+)
+
+// requestIDMiddleware tags each request with the caller's X-Request-ID, or a
+// new one when it sent none or an invalid one. The ID is returned in the
+// response header and carried by the request context, which passes it on to
+// management calls, the API server and the commands they queue for agents.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := logger.RequestIDOrNew(c.GetHeader(logger.RequestIDHeader))
+		c.Header(logger.RequestIDHeader, requestID)
+		c.Set(contextKeyRequestID, requestID)
+		c.Request = c.Request.WithContext(logger.ContextWithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// authMiddleware validates the bearer token, including the revocation list
+func (s *Server) authMiddleware() gin.HandlerFunc {
+	return s.bearerAuth(0)
+}
+
+// staleAuthMiddleware validates the bearer token like authMiddleware, but
+// also accepts access tokens that expired within the portal's stale token
+// grace, so that installed portals keep showing a status while offline for
+// a while. Responses to stale tokens carry the refresh header.
+func (s *Server) staleAuthMiddleware() gin.HandlerFunc {
+	return s.bearerAuth(s.config.Portal.StaleTokenGrace)
+}
+
+// bearerAuth validates the bearer token, accepting it until grace after
+// expiry. API keys are accepted as well when enabled.
+func (s *Server) bearerAuth(grace time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing bearer token"})
+			return
+		}
+		if s.apiKeys != nil && auth.IsAPIKey(token) {
+			s.apiKeyAuth(c, token)
+			return
+		}
+
+		claims, stale, err := s.jwtManager.ValidateStaleToken(token, grace)
+		if err != nil {
+			abortInvalidToken(c, err)
+			return
+		}
+		if stale {
+			c.Header(headerTokenRefresh, "required")
+		}
+
+		c.Set(contextKeyClaims, claims)
+		c.Set(contextKeyToken, token)
+		c.Next()
+	}
+}
+
+// abortInvalidToken rejects a request with an invalid bearer token. Expired
+// tokens are told apart, clients then refresh instead of logging in again.
+func abortInvalidToken(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrTokenExpired):
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token", error_description="token expired"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token expired", "refresh_required": true})
+	case errors.Is(err, auth.ErrTokenRevoked):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+	default:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+	}
+}
+
+// adminMiddleware rejects callers that are not active admins and records
+// the changes they make in the admin audit log. The admin is loaded on every
+// request, so that disabling an admin or changing its permissions applies to
+// tokens already issued. It must run after authMiddleware.
+func (s *Server) adminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+		if !models.UserRole(claims.Role).IsAdmin() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+			return
+		}
+
+		id, err := strconv.ParseUint(claims.UserID, 10, 32)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		admin, err := s.dbService.GetRepository().User.GetByID(uint(id))
+		if err != nil || !admin.Role.IsAdmin() || !admin.IsActive() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+			return
+		}
+
+		c.Set(contextKeyAdmin, admin)
+		c.Next()
+
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			s.recordAdminAction(c, admin)
+		}
+	}
+}
+
+// requirePermission rejects admins without a permission. It must run after adminMiddleware.
+func (s *Server) requirePermission(permission models.AdminPermission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin := c.MustGet(contextKeyAdmin).(*models.User)
+		if !admin.HasAdminPermission(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin permission " + string(permission) + " required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// superAdminMiddleware rejects admins that are not super admins. It must run after adminMiddleware.
+func (s *Server) superAdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin := c.MustGet(contextKeyAdmin).(*models.User)
+		if admin.Role != models.UserRoleSuperAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "super admin role required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// recordAdminAction writes a change made by an admin, including rejected
+// ones, to the admin audit log
+func (s *Server) recordAdminAction(c *gin.Context, admin *models.User) {
+	entry := &models.AdminAuditLog{
+		AdminID:       admin.ID,
+		AdminUsername: admin.Username,
+		Method:        c.Request.Method,
+		Route:         c.FullPath(),
+		Path:          c.Request.URL.Path,
+		Status:        c.Writer.Status(),
+		ClientIP:      c.ClientIP(),
+		RequestID:     c.GetString(contextKeyRequestID),
+	}
+	if err := s.dbService.GetRepository().AdminAudit.Create(entry); err != nil {
+		logger.FromContext(c.Request.Context(), s.logger).Error("Failed to record admin action",
+			zap.Error(err),
+			zap.Uint("admin_id", admin.ID),
+			zap.String("method", entry.Method),
+			zap.String("path", entry.Path),
+		)
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleListNodes lists nodes by ?status, ?region, ?search and ?online,
+// sorted by ?sort_by and ?sort_order. ?fields limits the node fields
+// returned. With ?saved_filter the parameters left out are taken from that
+// saved filter.
+func (s *Server) handleListNodes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+	onlineOnly, _ := strconv.ParseBool(c.Query("online"))
+
+	resp, err := s.management.ListNodes(c.Request.Context(), &pbv1.ListNodesRequest{
+		Page:          int32(page),
+		PageSize:      int32(pageSize),
+		StatusFilter:  c.Query("status"),
+		Region:        c.Query("region"),
+		SearchKeyword: c.Query("search"),
+		OnlineOnly:    onlineOnly,
+		SortBy:        c.Query("sort_by"),
+		SortOrder:     c.Query("sort_order"),
+		FieldMask:     queryFieldMask(c, "fields"),
+		SavedFilterId: c.Query("saved_filter"),
+		AdminId:       c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListNodeStatusTransitions lists the status changes of a node and
+// their reasons, newest first
+func (s *Server) handleListNodeStatusTransitions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListNodeStatusTransitions(c.Request.Context(), &pbv1.ListNodeStatusTransitionsRequest{
+		NodeId:   c.Param("id"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListNodeFailovers lists the users moved off or onto a node while
+// nodes were offline, newest first
+func (s *Server) handleListNodeFailovers(c *gin.Context) {
+	activeOnly, _ := strconv.ParseBool(c.Query("active"))
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListNodeFailovers(c.Request.Context(), &pbv1.ListNodeFailoversRequest{
+		NodeId:     c.Param("id"),
+		ActiveOnly: activeOnly,
+		Page:       int32(page),
+		PageSize:   int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetNodeGroupStats aggregates the nodes per group, or per region
+// with group_by=region
+func (s *Server) handleGetNodeGroupStats(c *gin.Context) {
+	start, ok := timeQuery(c, "start")
+	if !ok {
+		return
+	}
+	end, ok := timeQuery(c, "end")
+	if !ok {
+		return
+	}
+
+	resp, err := s.management.GetNodeGroupStats(c.Request.Context(), &pbv1.GetNodeGroupStatsRequest{
+		GroupBy:   c.Query("group_by"),
+		StartTime: start,
+		EndTime:   end,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleRemoveNode removes a node and revokes its tokens. The safety policy
+// confirmation goes in the X-Confirmation header.
+func (s *Server) handleRemoveNode(c *gin.Context) {
+	resp, err := s.management.RemoveNode(c.Request.Context(), &pbv1.RemoveNodeRequest{
+		NodeId:       c.Param("id"),
+		Confirmation: c.GetHeader(headerConfirmation),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,24 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleListNodeCommands lists the commands queued to nodes and their
+// results, newest first, optionally of one node and in one status
+func (s *Server) handleListNodeCommands(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListCommands(c.Request.Context(), &pbv1.ListCommandsRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+		NodeId:   c.Query("node_id"),
+		Status:   c.Query("status"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
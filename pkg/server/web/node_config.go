@@ -0,0 +1,97 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Node config history endpoints. Response bodies are the JSON form of the
+// matching ManagementService messages.
+
+// handleListNodeConfigVersions lists the config versions of a node, newest first
+func (s *Server) handleListNodeConfigVersions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListNodeConfigVersions(c.Request.Context(), &pbv1.ListNodeConfigVersionsRequest{
+		NodeId:   c.Param("id"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetNodeConfigVersion returns a config version with its content
+func (s *Server) handleGetNodeConfigVersion(c *gin.Context) {
+	version, _ := strconv.Atoi(c.Param("version"))
+
+	resp, err := s.management.GetNodeConfigVersion(c.Request.Context(), &pbv1.GetNodeConfigVersionRequest{
+		NodeId:  c.Param("id"),
+		Version: int32(version),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDiffNodeConfigVersions diffs the versions given by the from and to query parameters
+func (s *Server) handleDiffNodeConfigVersions(c *gin.Context) {
+	from, _ := strconv.Atoi(c.Query("from"))
+	to, _ := strconv.Atoi(c.Query("to"))
+
+	resp, err := s.management.DiffNodeConfigVersions(c.Request.Context(), &pbv1.DiffNodeConfigVersionsRequest{
+		NodeId:      c.Param("id"),
+		FromVersion: int32(from),
+		ToVersion:   int32(to),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleRestoreNodeConfigVersion applies a config version again as the
+// node's newest version, authored by the caller
+func (s *Server) handleRestoreNodeConfigVersion(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	version, _ := strconv.Atoi(c.Param("version"))
+
+	resp, err := s.management.RestoreNodeConfigVersion(c.Request.Context(), &pbv1.RestoreNodeConfigVersionRequest{
+		NodeId:   c.Param("id"),
+		Version:  int32(version),
+		Operator: claims.Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListNodeConfigOverrides lists the config overrides of a node
+func (s *Server) handleListNodeConfigOverrides(c *gin.Context) {
+	resp, err := s.management.ListNodeConfigOverrides(c.Request.Context(), &pbv1.ListNodeConfigOverridesRequest{
+		NodeId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleSetNodeConfigOverride sets a config override of a node, authored by
+// the caller
+func (s *Server) handleSetNodeConfigOverride(c *gin.Context) {
+	req := &pbv1.SetNodeConfigOverrideRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.NodeId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+
+	resp, err := s.management.SetNodeConfigOverride(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleClearNodeConfigOverrides clears the config overrides of a node given
+// by the path query parameters, or all of them
+func (s *Server) handleClearNodeConfigOverrides(c *gin.Context) {
+	resp, err := s.management.ClearNodeConfigOverrides(c.Request.Context(), &pbv1.ClearNodeConfigOverridesRequest{
+		NodeId:   c.Param("id"),
+		Paths:    c.QueryArray("path"),
+		Operator: c.MustGet(contextKeyClaims).(*auth.Claims).Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
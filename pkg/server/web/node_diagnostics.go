@@ -0,0 +1,25 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleRunNodeDiagnostic runs a diagnostic on the node of the path, with a
+// body of {"type", "target", "port", "count", "timeout_seconds"}. The node
+// runs it after its next heartbeat, so the call blocks until the result.
+func (s *Server) handleRunNodeDiagnostic(c *gin.Context) {
+	req := &pbv1.RunNodeDiagnosticRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.NodeId = c.Param("id")
+
+	var resp *pbv1.RunNodeDiagnosticResponse
+	err := s.callActiveAPIServer(c.Request.Context(), func(client pbv1.ManagementServiceClient) (err error) {
+		resp, err = client.RunNodeDiagnostic(c.Request.Context(), req)
+		return err
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,47 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Node enrollment endpoints. An enrollment is a one-time token the agent of a
+// new node exchanges on its first start for its node ID and node token.
+
+// handleListNodeEnrollments lists the enrollments, newest first
+func (s *Server) handleListNodeEnrollments(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListNodeEnrollments(c.Request.Context(), &pbv1.ListNodeEnrollmentsRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateNodeEnrollment issues an enrollment token from a
+// CreateNodeEnrollmentRequest body with the caller as operator. The token and
+// the install command embedding it are only returned once.
+func (s *Server) handleCreateNodeEnrollment(c *gin.Context) {
+	req := &pbv1.CreateNodeEnrollmentRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+
+	resp, err := s.management.CreateNodeEnrollment(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleRevokeNodeEnrollment revokes an enrollment that was not used
+func (s *Server) handleRevokeNodeEnrollment(c *gin.Context) {
+	resp, err := s.management.RevokeNodeEnrollment(c.Request.Context(), &pbv1.RevokeNodeEnrollmentRequest{
+		EnrollmentId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/probe"
+)
+
+// handleUserNodeLatency returns sanitized latency and availability of the caller's nodes
+func (s *Server) handleUserNodeLatency(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	userID, err := strconv.ParseUint(claims.UserID, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	nodes, err := s.dbService.GetRepository().Node.GetUserNodes(uint(userID))
+	if err != nil {
+		s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint64("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	latencies, err := s.nodeLatencies(nodes)
+	if err != nil {
+		s.logger.Error("Failed to get node probe stats", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"nodes":  latencies,
+		"window": s.config.Probe.Window.String(),
+	})
+}
+
+// nodeQualityLabels returns quality labels for subscription entries.
+// Failures are logged and result in an unlabeled subscription.
+func (s *Server) nodeQualityLabels(nodes []*models.Node) map[uint]string {
+	latencies, err := s.nodeLatencies(nodes)
+	if err != nil {
+		s.logger.Warn("Failed to get node probe stats for subscription", zap.Error(err))
+		return nil
+	}
+	return probe.Labels(latencies)
+}
+
+// nodeLatencies aggregates probe data of the configured window for the given nodes
+func (s *Server) nodeLatencies(nodes []*models.Node) ([]*probe.NodeLatency, error) {
+	nodeIDs := make([]uint, len(nodes))
+	for i, node := range nodes {
+		nodeIDs[i] = node.ID
+	}
+
+	since := time.Now().Add(-s.config.Probe.Window)
+	stats, err := s.dbService.GetRepository().Probe.GetNodeStats(nodeIDs, since)
+	if err != nil {
+		return nil, err
+	}
+	return probe.Summarize(nodes, stats), nil
+}
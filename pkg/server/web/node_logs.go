@@ -0,0 +1,41 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleFetchNodeLogs returns recent log lines of the node of the path, with
+// ?source (sing-box or agent), ?lines, the RFC 3339 ?since and ?until and
+// ?timeout_seconds. The node uploads them on its next heartbeat, so the call
+// blocks until then.
+func (s *Server) handleFetchNodeLogs(c *gin.Context) {
+	since, ok := timeQuery(c, "since")
+	if !ok {
+		return
+	}
+	until, ok := timeQuery(c, "until")
+	if !ok {
+		return
+	}
+	lines, _ := strconv.Atoi(c.Query("lines"))
+	timeout, _ := strconv.Atoi(c.Query("timeout_seconds"))
+
+	req := &pbv1.FetchNodeLogsRequest{
+		NodeId:         c.Param("id"),
+		Source:         c.Query("source"),
+		Lines:          int32(lines),
+		Since:          since,
+		Until:          until,
+		TimeoutSeconds: int32(timeout),
+	}
+	var resp *pbv1.FetchNodeLogsResponse
+	err := s.callActiveAPIServer(c.Request.Context(), func(client pbv1.ManagementServiceClient) (err error) {
+		resp, err = client.FetchNodeLogs(c.Request.Context(), req)
+		return err
+	})
+	s.writeManagementResponse(c, resp, err)
+}
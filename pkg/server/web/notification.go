@@ -0,0 +1,80 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleListUserNotifications returns the caller's notifications, newest
+// first, only the unread ones with ?unread=true
+func (s *Server) handleListUserNotifications(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	unreadOnly, _ := strconv.ParseBool(c.Query("unread"))
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListUserNotifications(c.Request.Context(), &pbv1.ListUserNotificationsRequest{
+		UserId:     claims.UserID,
+		UnreadOnly: unreadOnly,
+		Page:       int32(page),
+		PageSize:   int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetUnreadNotificationCount returns how many notifications the caller has not read
+func (s *Server) handleGetUnreadNotificationCount(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	resp, err := s.management.GetUnreadNotificationCount(c.Request.Context(), &pbv1.GetUnreadNotificationCountRequest{
+		UserId: claims.UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleMarkUserNotificationsRead marks the notification_ids of the body as
+// read, or all of the caller's notifications when the list is empty
+func (s *Server) handleMarkUserNotificationsRead(c *gin.Context) {
+	req := &pbv1.MarkNotificationsReadRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.MustGet(contextKeyClaims).(*auth.Claims).UserID
+	resp, err := s.management.MarkNotificationsRead(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleLinkUserTelegram links the chat_id of the body to receive the
+// caller's alerts from the Telegram bot
+func (s *Server) handleLinkUserTelegram(c *gin.Context) {
+	req := &pbv1.SetUserTelegramChatRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.MustGet(contextKeyClaims).(*auth.Claims).UserID
+	resp, err := s.management.SetUserTelegramChat(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUnlinkUserTelegram stops sending the caller's alerts to Telegram
+func (s *Server) handleUnlinkUserTelegram(c *gin.Context) {
+	resp, err := s.management.SetUserTelegramChat(c.Request.Context(), &pbv1.SetUserTelegramChatRequest{
+		UserId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleSendNotification sends a notification to the user of the path, with
+// the body in the JSON form of SendNotificationRequest
+func (s *Server) handleSendNotification(c *gin.Context) {
+	req := &pbv1.SendNotificationRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.Param("id")
+	resp, err := s.management.SendNotification(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,178 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// createOrderRequest is the body of a user's plan purchase
+type createOrderRequest struct {
+	PlanID     string `json:"plan_id"`
+	CouponCode string `json:"coupon_code"`
+}
+
+// handleCreateUserOrder opens a pending order of a publicly available plan for the caller
+func (s *Server) handleCreateUserOrder(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+
+	var req createOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.PlanID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "plan_id is required"})
+		return
+	}
+	if !s.checkPublicPlan(c, req.PlanID) {
+		return
+	}
+
+	resp, err := s.management.CreateOrder(c.Request.Context(), &pbv1.CreateOrderRequest{
+		UserId:     claims.UserID,
+		PlanId:     req.PlanID,
+		CouponCode: req.CouponCode,
+		// Checked against the trial blocklist, so only taken from headers
+		// set by a trusted proxy
+		ClientIp: c.ClientIP(),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// checkPublicPlan writes an error response and returns false unless the plan
+// is offered publicly. Users may only order such plans, admins order others
+// through the admin API.
+func (s *Server) checkPublicPlan(c *gin.Context, id string) bool {
+	planID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return false
+	}
+
+	plan, err := s.dbService.GetRepository().Plan.GetByID(uint(planID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "plan not found"})
+			return false
+		}
+		s.logger.Error("Failed to get plan", zap.Error(err), zap.Uint64("plan_id", planID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return false
+	}
+	if !plan.IsAvailable() {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "plan is not available for purchase"})
+		return false
+	}
+	return true
+}
+
+// handleListUserOrders lists the caller's orders
+func (s *Server) handleListUserOrders(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListOrders(c.Request.Context(), &pbv1.ListOrdersRequest{
+		Page:         int32(page),
+		PageSize:     int32(pageSize),
+		UserId:       claims.UserID,
+		StatusFilter: c.Query("status"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCancelUserOrder cancels one of the caller's pending orders
+func (s *Server) handleCancelUserOrder(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+
+	order, err := s.management.GetOrder(c.Request.Context(), &pbv1.GetOrderRequest{OrderId: c.Param("id")})
+	if err != nil {
+		s.writeManagementResponse(c, nil, err)
+		return
+	}
+	// Other users' orders are reported as missing rather than forbidden
+	if order.Order.UserId != claims.UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	resp, err := s.management.CancelOrder(c.Request.Context(), &pbv1.CancelOrderRequest{
+		OrderId:  c.Param("id"),
+		Reason:   "cancelled by user",
+		Operator: claims.Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// Order administration endpoints. Request and response bodies are the JSON
+// form of the matching ManagementService messages; the operator is the caller.
+
+// handleListOrders lists orders, filtered by the user_id and status query parameters
+func (s *Server) handleListOrders(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListOrders(c.Request.Context(), &pbv1.ListOrdersRequest{
+		Page:         int32(page),
+		PageSize:     int32(pageSize),
+		UserId:       c.Query("user_id"),
+		StatusFilter: c.Query("status"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateOrder opens an order of any active plan for a user
+func (s *Server) handleCreateOrder(c *gin.Context) {
+	req := &pbv1.CreateOrderRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	resp, err := s.management.CreateOrder(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetOrder returns an order with its payments
+func (s *Server) handleGetOrder(c *gin.Context) {
+	resp, err := s.management.GetOrder(c.Request.Context(), &pbv1.GetOrderRequest{OrderId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleConfirmOrderPayment records a payment and activates the ordered plan
+func (s *Server) handleConfirmOrderPayment(c *gin.Context) {
+	req := &pbv1.ConfirmOrderPaymentRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.OrderId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.ConfirmOrderPayment(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCancelOrder cancels a pending order
+func (s *Server) handleCancelOrder(c *gin.Context) {
+	req := &pbv1.CancelOrderRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.OrderId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.CancelOrder(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleRefundOrder marks a paid order as refunded
+func (s *Server) handleRefundOrder(c *gin.Context) {
+	req := &pbv1.RefundOrderRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.OrderId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.RefundOrder(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,148 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Plan administration endpoints. Request and response bodies are the JSON
+// form of the matching ManagementService messages.
+
+// handleListPlans lists plans, filtered by the status and search query parameters
+func (s *Server) handleListPlans(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListPlans(c.Request.Context(), &pbv1.ListPlansRequest{
+		Page:         int32(page),
+		PageSize:     int32(pageSize),
+		StatusFilter: c.Query("status"),
+		Search:       c.Query("search"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreatePlan creates a plan from a PlanSpec body
+func (s *Server) handleCreatePlan(c *gin.Context) {
+	spec := &pbv1.PlanSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.CreatePlan(c.Request.Context(), &pbv1.CreatePlanRequest{Plan: spec})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetPlan returns a plan with its features and node access
+func (s *Server) handleGetPlan(c *gin.Context) {
+	resp, err := s.management.GetPlan(c.Request.Context(), &pbv1.GetPlanRequest{PlanId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdatePlan replaces the editable fields of a plan with a PlanSpec body
+func (s *Server) handleUpdatePlan(c *gin.Context) {
+	spec := &pbv1.PlanSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.UpdatePlan(c.Request.Context(), &pbv1.UpdatePlanRequest{
+		PlanId: c.Param("id"),
+		Plan:   spec,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeletePlan deletes a plan that no user is assigned to
+func (s *Server) handleDeletePlan(c *gin.Context) {
+	resp, err := s.management.DeletePlan(c.Request.Context(), &pbv1.DeletePlanRequest{PlanId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleAllPlanStatistics returns usage statistics of every plan
+func (s *Server) handleAllPlanStatistics(c *gin.Context) {
+	resp, err := s.management.GetPlanStatistics(c.Request.Context(), &pbv1.GetPlanStatisticsRequest{})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handlePlanStatistics returns usage statistics of one plan
+func (s *Server) handlePlanStatistics(c *gin.Context) {
+	resp, err := s.management.GetPlanStatistics(c.Request.Context(), &pbv1.GetPlanStatisticsRequest{PlanId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleAddPlanFeature adds a feature to a plan
+func (s *Server) handleAddPlanFeature(c *gin.Context) {
+	req := &pbv1.AddPlanFeatureRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.PlanId = c.Param("id")
+	resp, err := s.management.AddPlanFeature(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdatePlanFeature updates a feature of a plan
+func (s *Server) handleUpdatePlanFeature(c *gin.Context) {
+	if !s.featureBelongsToPlan(c) {
+		return
+	}
+	req := &pbv1.UpdatePlanFeatureRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.FeatureId = c.Param("feature_id")
+	resp, err := s.management.UpdatePlanFeature(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeletePlanFeature removes a feature from a plan
+func (s *Server) handleDeletePlanFeature(c *gin.Context) {
+	if !s.featureBelongsToPlan(c) {
+		return
+	}
+	resp, err := s.management.DeletePlanFeature(c.Request.Context(), &pbv1.DeletePlanFeatureRequest{
+		FeatureId: c.Param("feature_id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleSetPlanNodeAccess grants or updates a plan's access to a node
+func (s *Server) handleSetPlanNodeAccess(c *gin.Context) {
+	req := &pbv1.SetPlanNodeAccessRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.PlanId = c.Param("id")
+	req.NodeId = c.Param("node_id")
+	resp, err := s.management.SetPlanNodeAccess(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleRemovePlanNodeAccess revokes a plan's direct access to a node
+func (s *Server) handleRemovePlanNodeAccess(c *gin.Context) {
+	resp, err := s.management.RemovePlanNodeAccess(c.Request.Context(), &pbv1.RemovePlanNodeAccessRequest{
+		PlanId: c.Param("id"),
+		NodeId: c.Param("node_id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// featureBelongsToPlan checks that the feature in the path belongs to the plan
+// in the path, so that a feature cannot be edited through another plan's URL
+func (s *Server) featureBelongsToPlan(c *gin.Context) bool {
+	resp, err := s.management.GetPlan(c.Request.Context(), &pbv1.GetPlanRequest{PlanId: c.Param("id")})
+	if err != nil {
+		s.writeManagementResponse(c, nil, err)
+		return false
+	}
+	for _, feature := range resp.Plan.Features {
+		if feature.Id == c.Param("feature_id") {
+			return true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "plan_feature not found"})
+	return false
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/subscription"
+)
+
+// setupPortalRoutes registers the installable assets of the user portal at
+// the root, where browsers look for them and the service worker scope covers
+// the whole portal
+func (s *Server) setupPortalRoutes() {
+	s.engine.GET("/manifest.webmanifest", s.handleWebAppManifest)
+
+	dir := s.config.Portal.AssetsDir
+	if dir == "" {
+		return
+	}
+	s.engine.GET("/sw.js", s.handleServiceWorker)
+	s.engine.Static("/icons", filepath.Join(dir, "icons"))
+}
+
+// handleWebAppManifest serves the web app manifest built from the default branding
+func (s *Server) handleWebAppManifest(c *gin.Context) {
+	cfg := s.config.Portal
+	manifest := models.NewWebAppManifest(models.DefaultBranding(s.config.Branding), cfg.ThemeColor, cfg.BackgroundColor)
+
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Header("Content-Type", "application/manifest+json; charset=utf-8")
+	c.JSON(http.StatusOK, manifest)
+}
+
+// handleServiceWorker serves sw.js of the assets directory. It is never
+// cached, so that browsers pick up a new portal version on their next visit.
+func (s *Server) handleServiceWorker(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Service-Worker-Allowed", "/")
+	c.Header("Content-Type", "text/javascript; charset=utf-8")
+	c.File(filepath.Join(s.config.Portal.AssetsDir, "sw.js"))
+}
+
+// handleUserStatus returns the caller's compact status for low-bandwidth
+// polling. Clients sending the ETag back in If-None-Match get 304 Not
+// Modified while nothing changed. Access tokens that expired within the
+// stale token grace are accepted, see staleAuthMiddleware.
+func (s *Server) handleUserStatus(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	userID, err := strconv.ParseUint(claims.UserID, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	repo := s.dbService.GetRepository()
+	user, err := repo.User.GetByID(uint(userID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		return
+	}
+	unread, err := repo.Notification.CountUnread(user.ID)
+	if err != nil {
+		// The count is a hint, the status is still worth returning
+		s.logger.Warn("Failed to count unread notifications", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	status := models.NewPortalStatus(user, unread)
+	etag := status.ETag()
+
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "private, max-age="+strconv.Itoa(int(s.config.Portal.StatusMaxAge.Seconds())))
+	header.Add("Vary", "Authorization")
+
+	if subscription.MatchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/format"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleGetAdminPreferences returns the caller's display preferences
+func (s *Server) handleGetAdminPreferences(c *gin.Context) {
+	resp, err := s.management.GetAdminPreferences(c.Request.Context(), &pbv1.GetAdminPreferencesRequest{
+		AdminId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateAdminPreferences sets the caller's display preferences from an
+// UpdateAdminPreferencesRequest body
+func (s *Server) handleUpdateAdminPreferences(c *gin.Context) {
+	req := &pbv1.UpdateAdminPreferencesRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.AdminId = c.MustGet(contextKeyClaims).(*auth.Claims).UserID
+	resp, err := s.management.UpdateAdminPreferences(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// displayFormatter returns the formatter rendering the display values of a
+// response to an admin, nil for other callers and when ?display=false. The
+// admin's preferences win over the Accept-Language header.
+func (s *Server) displayFormatter(c *gin.Context) *format.Formatter {
+	value, ok := c.Get(contextKeyAdmin)
+	if !ok || c.Query("display") == "false" {
+		return nil
+	}
+	admin := value.(*models.User)
+
+	locale, ok := format.ParseLocale(admin.Locale)
+	if !ok {
+		locale = format.NegotiateLocale(c.GetHeader("Accept-Language"))
+	}
+	location, err := format.LoadLocation(admin.TimeZone)
+	if err != nil {
+		logger.FromContext(c.Request.Context(), s.logger).Warn("Invalid admin time zone, using the server one",
+			zap.Uint("admin_id", admin.ID), zap.String("time_zone", admin.TimeZone))
+	}
+	return format.New(locale, location)
+}
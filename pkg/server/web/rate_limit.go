@@ -0,0 +1,97 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/metrics"
+	"sing-box-web/pkg/ratelimit"
+)
+
+// Rate limit scopes, the prefixes of the bucket keys and the labels of the
+// rejected requests metric
+const (
+	rateLimitScopeIP                = "ip"
+	rateLimitScopeUser              = "user"
+	rateLimitScopeSubscriptionIP    = "subscription_ip"
+	rateLimitScopeSubscriptionToken = "subscription_token"
+)
+
+// apiRateLimit is the limit of each client IP and user on the web API
+func (s *Server) apiRateLimit() ratelimit.Limit {
+	return ratelimit.Limit{Requests: s.config.Auth.RateLimitRequests, Period: s.config.Auth.RateLimitDuration}
+}
+
+// subscriptionRateLimit is the limit of each client IP and token on the
+// subscription endpoint
+func (s *Server) subscriptionRateLimit() ratelimit.Limit {
+	return ratelimit.Limit{Requests: s.config.Auth.SubscriptionRateLimitRequests, Period: s.config.Auth.RateLimitDuration}
+}
+
+// ipRateLimitMiddleware limits the requests of each client IP to the web API.
+// The client IP is the peer address, or the one named by a trusted proxy, so
+// that forged X-Forwarded-For headers do not get fresh buckets.
+func (s *Server) ipRateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.limiter != nil && !s.allowRequest(c, rateLimitScopeIP, c.ClientIP(), s.apiRateLimit()) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// userRateLimitMiddleware limits the requests of each user to the web API,
+// whichever their client IP. It must run after authMiddleware.
+func (s *Server) userRateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+		if s.limiter != nil && !s.allowRequest(c, rateLimitScopeUser, claims.UserID, s.apiRateLimit()) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// subscriptionRateLimitMiddleware limits the fetches of the subscription
+// endpoint per client IP, resolved as by ipRateLimitMiddleware, and per
+// token. Subscription clients are not limited with the web API, their polls
+// then do not use up the limit of the portal.
+func (s *Server) subscriptionRateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.limiter != nil {
+			limit := s.subscriptionRateLimit()
+			if !s.allowRequest(c, rateLimitScopeSubscriptionIP, c.ClientIP(), limit) ||
+				!s.allowRequest(c, rateLimitScopeSubscriptionToken, tokenKey(c.Param("token")), limit) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// tokenKey is the bucket key of a subscription token, which is not stored
+// in the clear, e.g. in Redis
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+// allowRequest takes a token of the bucket of key in a scope, refusing the
+// request with 429 and Retry-After when there is none
+func (s *Server) allowRequest(c *gin.Context, scope, key string, limit ratelimit.Limit) bool {
+	ok, wait := s.limiter.Allow(c.Request.Context(), scope+":"+key, limit)
+	if ok {
+		return true
+	}
+	metrics.RecordRateLimitRejected(scope)
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(max(wait, time.Second).Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded, retry later"})
+	return false
+}
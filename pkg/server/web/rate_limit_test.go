@@ -0,0 +1,60 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/ratelimit"
+)
+
+// newRateLimitTestServer returns a server allowing one request per client IP
+// on GET /api and GET /sub/:token
+func newRateLimitTestServer(t *testing.T) *Server {
+	t.Helper()
+	config := *configv1.DefaultWebConfig()
+	config.Auth.RateLimitRequests = 1
+	config.Auth.SubscriptionRateLimitRequests = 1
+	config.Auth.RateLimitDuration = time.Hour
+	engine, err := newEngine(config)
+	if err != nil {
+		t.Fatalf("newEngine: %v", err)
+	}
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), zap.NewNop())
+	t.Cleanup(func() { limiter.Close() })
+	s := &Server{config: config, engine: engine, logger: zap.NewNop(), limiter: limiter}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	engine.GET("/api", s.ipRateLimitMiddleware(), ok)
+	engine.GET("/sub/:token", s.subscriptionRateLimitMiddleware(), ok)
+	return s
+}
+
+func TestRateLimitIgnoresForgedForwardedFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		first  string
+		second string
+	}{
+		{"api", "/api", "/api"},
+		{"subscription", "/sub/first", "/sub/second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRateLimitTestServer(t)
+			if w := serve(s.engine, tt.first, "203.0.113.9:40000", "10.0.0.1"); w.Code != http.StatusOK {
+				t.Fatalf("first request = %d, want %d", w.Code, http.StatusOK)
+			}
+			// Another X-Forwarded-For from the same peer uses the same bucket
+			if w := serve(s.engine, tt.second, "203.0.113.9:40001", "10.0.0.2"); w.Code != http.StatusTooManyRequests {
+				t.Errorf("second request with another X-Forwarded-For = %d, want %d", w.Code, http.StatusTooManyRequests)
+			}
+			if w := serve(s.engine, tt.second, "198.51.100.4:40000", ""); w.Code != http.StatusOK {
+				t.Errorf("request from another peer = %d, want %d", w.Code, http.StatusOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleUserReferralStats returns the caller's referral code and earnings
+func (s *Server) handleUserReferralStats(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+
+	resp, err := s.management.GetReferralStats(c.Request.Context(), &pbv1.GetReferralStatsRequest{UserId: claims.UserID})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListUserReferrals lists the users the caller referred
+func (s *Server) handleListUserReferrals(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListReferrals(c.Request.Context(), &pbv1.ListReferralsRequest{
+		UserId:   claims.UserID,
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// Referral program administration endpoints. Request and response bodies are
+// the JSON form of the matching ManagementService messages.
+
+// handleGetReferralSettings returns the referral program settings
+func (s *Server) handleGetReferralSettings(c *gin.Context) {
+	resp, err := s.management.GetReferralSettings(c.Request.Context(), &pbv1.GetReferralSettingsRequest{})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateReferralSettings replaces the referral program settings with a ReferralSettings body
+func (s *Server) handleUpdateReferralSettings(c *gin.Context) {
+	settings := &pbv1.ReferralSettings{}
+	if !bindManagementRequest(c, settings) {
+		return
+	}
+	resp, err := s.management.UpdateReferralSettings(c.Request.Context(), &pbv1.UpdateReferralSettingsRequest{
+		Settings: settings,
+		Operator: c.MustGet(contextKeyClaims).(*auth.Claims).Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListReferralCommissions lists commissions, filtered by the user_id
+// and status query parameters
+func (s *Server) handleListReferralCommissions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListReferralCommissions(c.Request.Context(), &pbv1.ListReferralCommissionsRequest{
+		UserId:       c.Query("user_id"),
+		StatusFilter: c.Query("status"),
+		Page:         int32(page),
+		PageSize:     int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetReferralStats returns the referral stats of a user
+func (s *Server) handleGetReferralStats(c *gin.Context) {
+	resp, err := s.management.GetReferralStats(c.Request.Context(), &pbv1.GetReferralStatsRequest{UserId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handlePayoutReferralCommissions pays out a user's pending commissions in one currency
+func (s *Server) handlePayoutReferralCommissions(c *gin.Context) {
+	req := &pbv1.PayoutReferralCommissionsRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.PayoutReferralCommissions(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateReferralAdjustment credits or takes back a commission of a user
+func (s *Server) handleCreateReferralAdjustment(c *gin.Context) {
+	req := &pbv1.CreateReferralAdjustmentRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.CreateReferralAdjustment(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/config"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/models"
+)
+
+// Reload applies the log level of a configuration reloaded from path, which
+// must be valid. Other changes are logged as needing a restart. The reload is
+// recorded in the admin audit log.
+func (s *Server) Reload(path string, reloaded *configv1.WebConfig) {
+	if err := logger.SetLevel(reloaded.Log.Level); err != nil {
+		s.logger.Error("Failed to change the log level", zap.Error(err))
+	}
+
+	s.logger.Info("Configuration reloaded", zap.String("path", path), zap.String("log_level", reloaded.Log.Level))
+	if changed := config.ChangedSections(&s.config, reloaded, "log"); len(changed) > 0 {
+		s.logger.Warn("Configuration changes need a restart to apply", zap.Strings("sections", changed))
+	}
+	// The other sections stay those the server runs with until a restart
+	s.config.Log = reloaded.Log
+	s.auditReload(path, nil)
+}
+
+// RejectReload records a configuration from path that failed to load or
+// validate. The current configuration stays in effect.
+func (s *Server) RejectReload(path string, err error) {
+	s.logger.Error("Configuration reload rejected, keeping the current configuration",
+		zap.String("path", path), zap.Error(err))
+	s.auditReload(path, err)
+}
+
+// auditReload writes a configuration reload to the admin audit log
+func (s *Server) auditReload(path string, err error) {
+	if err := s.dbService.GetRepository().AdminAudit.Create(models.NewConfigReloadAudit("web", path, err)); err != nil {
+		s.logger.Error("Failed to record configuration reload", zap.Error(err))
+	}
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/gateway"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// managementRPCPrefix is where every ManagementService method is served as
+// POST {prefix}/{MethodName}, for tooling without a gRPC client
+const managementRPCPrefix = "/api/v1/admin/rpc"
+
+// newManagementGateway maps the ManagementService methods of srv to HTTP/JSON
+func newManagementGateway(srv pbv1.ManagementServiceServer) (*gateway.Gateway, error) {
+	service := pbv1.File_v1_management_proto.Services().ByName("ManagementService")
+	return gateway.New(service, srv)
+}
+
+// ManagementOpenAPI returns the OpenAPI document of the ManagementService
+// methods served under /api/v1/admin/rpc
+func ManagementOpenAPI() ([]byte, error) {
+	g, err := newManagementGateway(pbv1.UnimplementedManagementServiceServer{})
+	if err != nil {
+		return nil, err
+	}
+	return managementOpenAPI(g)
+}
+
+// managementOpenAPI describes the routes of a management gateway
+func managementOpenAPI(g *gateway.Gateway) ([]byte, error) {
+	return g.OpenAPI(managementRPCPrefix, "sing-box-web management API", "v1")
+}
+
+// handleManagementRPC calls the ManagementService method of the path with the
+// JSON body as its request. The call is made in-process, like the other
+// admin endpoints, but bypasses their per-area permissions, so the route is
+// left to super admins.
+func (s *Server) handleManagementRPC(c *gin.Context) {
+	method := c.Param("method")
+	if !s.gateway.Has(method) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown method"})
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	resp, err := s.gateway.Invoke(c.Request.Context(), method, body)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleManagementOpenAPI serves the OpenAPI document of the RPC routes
+func (s *Server) handleManagementOpenAPI(c *gin.Context) {
+	data, err := managementOpenAPI(s.gateway)
+	if err != nil {
+		s.logger.Error("Failed to generate OpenAPI document", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
@@ -0,0 +1,56 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Saved filter endpoints. Filters belong to the calling admin; shared
+// filters of other admins are listed and usable but read-only.
+
+// handleListSavedFilters lists the caller's and shared filters, optionally of one ?entity
+func (s *Server) handleListSavedFilters(c *gin.Context) {
+	resp, err := s.management.ListSavedFilters(c.Request.Context(), &pbv1.ListSavedFiltersRequest{
+		AdminId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+		Entity:  c.Query("entity"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateSavedFilter saves a filter from a SavedFilterSpec body
+func (s *Server) handleCreateSavedFilter(c *gin.Context) {
+	spec := &pbv1.SavedFilterSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.CreateSavedFilter(c.Request.Context(), &pbv1.CreateSavedFilterRequest{
+		AdminId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+		Filter:  spec,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateSavedFilter replaces the editable fields of a filter with a SavedFilterSpec body
+func (s *Server) handleUpdateSavedFilter(c *gin.Context) {
+	spec := &pbv1.SavedFilterSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.UpdateSavedFilter(c.Request.Context(), &pbv1.UpdateSavedFilterRequest{
+		AdminId:  c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+		FilterId: c.Param("id"),
+		Filter:   spec,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeleteSavedFilter deletes a filter of the caller
+func (s *Server) handleDeleteSavedFilter(c *gin.Context) {
+	resp, err := s.management.DeleteSavedFilter(c.Request.Context(), &pbv1.DeleteSavedFilterRequest{
+		AdminId:  c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+		FilterId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,24 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleSearch searches users, nodes and plans for ?q at once, for the admin
+// omnibox. Repeat ?type to narrow the types; ?limit caps the results per type.
+// Only the types the caller's permissions cover are searched.
+func (s *Server) handleSearch(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	resp, err := s.management.Search(c.Request.Context(), &pbv1.SearchRequest{
+		AdminId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+		Query:   c.Query("q"),
+		Types:   c.QueryArray("type"),
+		Limit:   int32(limit),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
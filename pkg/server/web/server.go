@@ -0,0 +1,525 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/database"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/gateway"
+	"sing-box-web/pkg/health"
+	"sing-box-web/pkg/logger"
+	"sing-box-web/pkg/mail"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/probe"
+	"sing-box-web/pkg/ratelimit"
+	"sing-box-web/pkg/server/api"
+	"sing-box-web/pkg/settings"
+)
+
+// Server represents the HTTP web server
+type Server struct {
+	config     configv1.WebConfig
+	engine     *gin.Engine
+	httpServer *http.Server
+	listener   net.Listener
+	logger     *zap.Logger
+	dbService  *database.Service
+	jwtManager *auth.JWTManager
+	authn      *auth.Authenticator
+	prober     *probe.Prober
+	mailer     *mail.Mailer
+	// accounts runs password reset and email verification, nil without mail
+	accounts *auth.Accounts
+	// management serves the admin endpoints in-process
+	management *api.ManagementService
+	// gateway serves all of management's methods as HTTP/JSON
+	gateway *gateway.Gateway
+	// events feeds the WebSocket event streams, nil when disabled
+	events     *events.Bus
+	relay      *eventRelay
+	stopEvents context.CancelFunc
+	// stopped is closed by Stop to end the event streams
+	stopped chan struct{}
+	// health answers /healthz and /readyz, not ready until started and
+	// while stopping
+	health *health.Checker
+	// apiKeys meters the requests made with API keys, nil when disabled
+	apiKeys *apiKeyMeter
+	// limiter rate limits the requests per client IP and user, nil when disabled
+	limiter *ratelimit.Limiter
+	// access refuses admin endpoints by network, login and subscriptions
+	// by country
+	access *accessGuard
+	// settings are the system settings admins change at runtime, shared
+	// with management so that changes apply at once
+	settings *settings.Store
+}
+
+// NewServer creates a new HTTP web server
+func NewServer(config configv1.WebConfig, dbService *database.Service) (*Server, error) {
+	logger := logger.GetLogger().Named("web-server")
+
+	engine, err := newEngine(config)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := dbService.GetRepository()
+	jwtManager := auth.NewJWTManager(config.Auth, logger.Named("jwt"))
+	jwtManager.SetUserRepository(&jwtUserRepository{users: repo.User})
+	jwtManager.SetRevocationStore(repo.Token)
+
+	authn, err := auth.NewAuthenticator(config.Auth, repo.User, jwtManager, logger.Named("auth"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		config:     config,
+		engine:     engine,
+		logger:     logger,
+		dbService:  dbService,
+		jwtManager: jwtManager,
+		authn:      authn,
+		management: api.NewManagementService(managementConfig(config), dbService, logger),
+		stopped:    make(chan struct{}),
+		health:     health.NewChecker(config.HealthEndpoints.CheckTimeout),
+		settings:   settings.NewStore(repo.SystemSetting, logger),
+	}
+	s.management.SetSettings(s.settings)
+	s.management.SetTwoFactorIssuer(config.Auth.TwoFactorIssuer)
+	s.health.Add("database", func(context.Context) error {
+		return dbService.Health()
+	})
+	// Management calls are served in-process, the portal works on without
+	// the API server but nodes receive no changes
+	s.health.AddOptional("api server", s.checkAPIServer)
+	if config.Probe.Enabled {
+		s.prober = probe.NewProber(config.Probe, repo, logger)
+	}
+	if config.APIKeys.Enabled {
+		s.apiKeys = newAPIKeyMeter(config.APIKeys, repo.APIKey, logger.Named("api-keys"))
+	}
+	if config.Auth.EnableRateLimit {
+		if s.limiter, err = ratelimit.New(config.Auth.RateLimitStore, logger.Named("rate-limit")); err != nil {
+			return nil, fmt.Errorf("failed to create rate limiter: %w", err)
+		}
+	}
+	if s.access, err = newAccessGuard(config.Access, repo.AdminAudit, logger.Named("access")); err != nil {
+		return nil, err
+	}
+	if config.Mail.Enabled {
+		s.mailer, err = mail.NewMailer(config.Mail, models.DefaultBranding(config.Branding), repo.Tenant, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mailer: %w", err)
+		}
+		s.management.SetMailer(s.mailer)
+
+		tokens := auth.NewAccountTokens(config.Mail)
+		s.accounts = auth.NewAccounts(config.Auth, repo.User, tokens, s.mailer, logger.Named("accounts"))
+		s.management.SetAccountTokens(tokens)
+	}
+	if config.Events.Enabled {
+		if s.events, err = events.New(config.Events.Bus, logger.Named("events")); err != nil {
+			return nil, fmt.Errorf("failed to create event bus: %w", err)
+		}
+		s.management.SetEvents(s.events)
+		// The in-memory bus only gets the API server's events through WatchEvents
+		if config.Events.Bus.Backend == "memory" {
+			s.relay = &eventRelay{
+				config:    config.Events,
+				apiServer: config.APIServer,
+				bus:       s.events,
+				logger:    logger.Named("events"),
+			}
+		}
+	}
+	if s.gateway, err = newManagementGateway(s.management); err != nil {
+		return nil, fmt.Errorf("failed to create management gateway: %w", err)
+	}
+	s.setupRoutes()
+
+	return s, nil
+}
+
+// newEngine creates the gin engine of the server. Clients are identified by
+// the address requests come from, or by the X-Forwarded-For header set by
+// one of the trusted proxies.
+func newEngine(config configv1.WebConfig) (*gin.Engine, error) {
+	gin.SetMode(gin.ReleaseMode)
+	engine := gin.New()
+	if err := engine.SetTrustedProxies(config.Access.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	engine.Use(gin.Recovery(), requestIDMiddleware())
+	if config.SkyWalking.Enabled {
+		engine.Use(otelgin.Middleware(config.SkyWalking.ServiceName))
+	}
+	return engine, nil
+}
+
+// managementConfig is the configuration of the in-process management service,
+// which only reads the business settings of the web configuration
+func managementConfig(config configv1.WebConfig) configv1.APIConfig {
+	return configv1.APIConfig{
+		Business: configv1.BusinessConfig{
+			Node: configv1.NodeConfig{Enrollment: config.NodeEnrollment},
+			Trial: configv1.TrialConfig{
+				Enabled: config.Auth.SignupTrialPlanID != 0,
+				PlanID:  config.Auth.SignupTrialPlanID,
+			},
+		},
+	}
+}
+
+// setupRoutes registers all HTTP routes
+func (s *Server) setupRoutes() {
+	// Liveness and readiness for Kubernetes and load balancers, unauthenticated
+	s.engine.GET("/healthz", gin.WrapF(s.health.ServeLiveness))
+	s.engine.GET("/readyz", gin.WrapF(s.health.ServeReadiness))
+
+	// Web app manifest and service worker of the user portal
+	s.setupPortalRoutes()
+
+	// Public subscription endpoint, authenticated by the subscription token
+	subscribe := s.engine.Group("/api/v1/subscribe", s.countryAccessMiddleware(), s.subscriptionRateLimitMiddleware())
+	subscribe.GET("/:token", s.handleSubscription)
+	subscribe.HEAD("/:token", s.handleSubscription)
+
+	v1 := s.engine.Group("/api/v1", s.ipRateLimitMiddleware())
+
+	// Login through the configured credential providers
+	v1.POST("/auth/login", s.countryAccessMiddleware(), s.handleLogin)
+	v1.POST("/auth/refresh", s.handleRefresh)
+
+	// Polled by installed portals, which may come back online with an
+	// expired access token; the status is still served and a refresh asked for
+	v1.GET("/user/status", s.staleAuthMiddleware(), s.handleUserStatus)
+
+	// Self-registration, checked by the address of the client
+	if s.config.Auth.AllowSignup {
+		v1.POST("/auth/signup", s.countryAccessMiddleware(), s.handleSignup)
+	}
+
+	// Password reset and email verification links are sent by mail
+	if s.accounts != nil {
+		v1.POST("/auth/password/forgot", s.handleForgotPassword)
+		v1.POST("/auth/password/reset", s.handleResetPassword)
+		v1.POST("/auth/email/verification", s.handleRequestVerification)
+		v1.POST("/auth/email/verify", s.handleVerifyEmail)
+	}
+
+	// Infrastructure alerts posted by Alertmanager, authenticated by its token
+	if s.config.Alertmanager.Enabled {
+		v1.POST("/webhooks/alertmanager", s.handleAlertmanagerWebhook)
+	}
+
+	// Real-time events over WebSocket. Browsers cannot set headers on
+	// WebSocket requests, the token may be passed as ?access_token instead.
+	if s.events != nil {
+		v1.GET("/admin/events", s.adminAccessMiddleware(), eventTokenFromQuery(), s.authMiddleware(), s.adminMiddleware(), s.handleEventStream)
+	}
+
+	// Authenticated endpoints
+	authorized := v1.Group("", s.authMiddleware(), s.userRateLimitMiddleware())
+	authorized.POST("/auth/logout", s.handleLogout)
+	authorized.GET("/user/branding", s.handleUserBranding)
+	authorized.GET("/user/nodes/latency", s.handleUserNodeLatency)
+	authorized.POST("/user/subscription/rotate", s.handleRotateSubscriptionToken)
+	authorized.GET("/user/orders", s.handleListUserOrders)
+	authorized.POST("/user/orders", s.handleCreateUserOrder)
+	authorized.POST("/user/orders/:id/cancel", s.handleCancelUserOrder)
+	authorized.POST("/user/orders/:id/pay", s.handlePayUserOrder)
+	authorized.GET("/user/balance", s.handleUserBalance)
+	authorized.GET("/user/balance/transactions", s.handleListUserBalanceTransactions)
+	authorized.POST("/user/coupons/validate", s.handleValidateUserCoupon)
+	authorized.GET("/user/referrals", s.handleUserReferralStats)
+	authorized.GET("/user/referrals/users", s.handleListUserReferrals)
+	authorized.GET("/user/announcements", s.handleListUserAnnouncements)
+	authorized.POST("/user/announcements/read", s.handleMarkUserAnnouncementsRead)
+	authorized.GET("/user/notifications", s.handleListUserNotifications)
+	authorized.GET("/user/notifications/unread-count", s.handleGetUnreadNotificationCount)
+	authorized.POST("/user/notifications/read", s.handleMarkUserNotificationsRead)
+	authorized.PUT("/user/telegram", s.handleLinkUserTelegram)
+	authorized.DELETE("/user/telegram", s.handleUnlinkUserTelegram)
+
+	// 2FA of the caller. Admins refused at login until 2FA is enabled set it
+	// up with the setup token they were given instead of an access token.
+	twoFactor := v1.Group("/user/two-factor", s.twoFactorEnrollmentMiddleware(), s.userRateLimitMiddleware(), sessionOnlyMiddleware())
+	twoFactor.POST("/setup", s.handleSetupUserTwoFactor)
+	twoFactor.POST("/enable", s.handleEnableUserTwoFactor)
+	authorized.POST("/user/two-factor/disable", sessionOnlyMiddleware(), s.handleDisableUserTwoFactor)
+
+	// API keys are managed from a login session only
+	if s.apiKeys != nil {
+		apiKeys := authorized.Group("/user/api-keys", sessionOnlyMiddleware())
+		apiKeys.GET("", s.handleListUserAPIKeys)
+		apiKeys.POST("", s.handleCreateUserAPIKey)
+		apiKeys.DELETE("/:id", s.handleRevokeUserAPIKey)
+		apiKeys.GET("/:id/usage", s.handleGetUserAPIKeyUsage)
+	}
+
+	// Administration endpoints. Admins reach the areas their permissions
+	// grant, super admins everything; the changes they make are audited.
+	// Clients outside the admin networks are refused before authentication.
+	admin := v1.Group("/admin", s.adminAccessMiddleware(), s.authMiddleware(), s.userRateLimitMiddleware(), s.adminMiddleware())
+	admin.GET("/search", s.handleSearch)
+	admin.GET("/preferences", s.handleGetAdminPreferences)
+	admin.PUT("/preferences", s.handleUpdateAdminPreferences)
+	admin.GET("/saved-filters", s.handleListSavedFilters)
+	admin.POST("/saved-filters", s.handleCreateSavedFilter)
+	admin.PUT("/saved-filters/:id", s.handleUpdateSavedFilter)
+	admin.DELETE("/saved-filters/:id", s.handleDeleteSavedFilter)
+
+	billing := admin.Group("", s.requirePermission(models.AdminPermissionBilling))
+	billing.GET("/plans", s.handleListPlans)
+	billing.POST("/plans", s.handleCreatePlan)
+	billing.GET("/plans/statistics", s.handleAllPlanStatistics)
+	billing.GET("/plans/:id", s.handleGetPlan)
+	billing.PUT("/plans/:id", s.handleUpdatePlan)
+	billing.DELETE("/plans/:id", s.handleDeletePlan)
+	billing.GET("/plans/:id/statistics", s.handlePlanStatistics)
+	billing.POST("/plans/:id/features", s.handleAddPlanFeature)
+	billing.PUT("/plans/:id/features/:feature_id", s.handleUpdatePlanFeature)
+	billing.DELETE("/plans/:id/features/:feature_id", s.handleDeletePlanFeature)
+	billing.PUT("/plans/:id/nodes/:node_id", s.handleSetPlanNodeAccess)
+	billing.DELETE("/plans/:id/nodes/:node_id", s.handleRemovePlanNodeAccess)
+	billing.GET("/orders", s.handleListOrders)
+	billing.POST("/orders", s.handleCreateOrder)
+	billing.GET("/orders/:id", s.handleGetOrder)
+	billing.POST("/orders/:id/confirm", s.handleConfirmOrderPayment)
+	billing.POST("/orders/:id/cancel", s.handleCancelOrder)
+	billing.POST("/orders/:id/refund", s.handleRefundOrder)
+	billing.GET("/coupons", s.handleListCoupons)
+	billing.POST("/coupons", s.handleCreateCoupon)
+	billing.GET("/coupons/statistics", s.handleAllCouponStatistics)
+	billing.GET("/coupons/:id", s.handleGetCoupon)
+	billing.PUT("/coupons/:id", s.handleUpdateCoupon)
+	billing.DELETE("/coupons/:id", s.handleDeleteCoupon)
+	billing.GET("/coupons/:id/statistics", s.handleCouponStatistics)
+	billing.GET("/referrals/settings", s.handleGetReferralSettings)
+	billing.PUT("/referrals/settings", s.handleUpdateReferralSettings)
+	billing.GET("/referrals/commissions", s.handleListReferralCommissions)
+	billing.GET("/users/:id/referrals", s.handleGetReferralStats)
+	billing.POST("/users/:id/referrals/payout", s.handlePayoutReferralCommissions)
+	billing.POST("/users/:id/referrals/adjustments", s.handleCreateReferralAdjustment)
+	billing.GET("/balance/transactions", s.handleListBalanceTransactions)
+
+	users := admin.Group("", s.requirePermission(models.AdminPermissionUsers))
+	users.GET("/users", s.handleListUsers)
+	users.GET("/users/:id/detail", s.handleGetUserDetail)
+	users.GET("/users/:id/traffic", s.handleGetUserTraffic)
+	users.PUT("/users/:id/inactivity-exemption", s.handleSetUserInactivityExempt)
+	users.GET("/users/:id/credentials", s.handleListUserCredentialRotations)
+	users.GET("/users/:id/subscription-access", s.handleListSubscriptionAccessLogs)
+	users.POST("/users/:id/kick", s.handleKickUser)
+	users.GET("/connections", s.handleListActiveConnections)
+	users.DELETE("/connections/:session_id", s.handleKillConnection)
+	users.POST("/users/:id/credentials/regenerate", s.handleRegenerateUserCredentials)
+	users.POST("/users/credentials/regenerate", s.handleBulkRegenerateUserCredentials)
+	users.GET("/blocklist", s.handleListBlocklistEntries)
+	users.POST("/blocklist", s.handleCreateBlocklistEntry)
+	users.DELETE("/blocklist/:id", s.handleDeleteBlocklistEntry)
+	users.GET("/users/:id/balance", s.handleGetUserBalance)
+	users.POST("/users/:id/balance/top-up", s.handleTopUpUserBalance)
+	users.POST("/users/:id/balance/deduct", s.handleDeductUserBalance)
+	users.GET("/users/:id/shaping", s.handleGetUserShapingStats)
+	users.GET("/users/:id/api-keys", s.handleListAPIKeys)
+	users.PUT("/api-keys/:id/limits", s.handleUpdateAPIKeyLimits)
+	users.DELETE("/api-keys/:id", s.handleRevokeAPIKey)
+	users.GET("/api-keys/:id/usage", s.handleGetAPIKeyUsage)
+	users.GET("/user-migrations", s.handleListUserMigrations)
+	users.POST("/user-migrations", s.handleCreateUserMigration)
+	users.GET("/user-migrations/:id", s.handleGetUserMigration)
+	users.POST("/user-migrations/:id/pause", s.handlePauseUserMigration)
+	users.POST("/user-migrations/:id/resume", s.handleResumeUserMigration)
+	users.POST("/user-migrations/:id/cancel", s.handleCancelUserMigration)
+
+	nodes := admin.Group("", s.requirePermission(models.AdminPermissionNodes))
+	nodes.GET("/nodes", s.handleListNodes)
+	nodes.GET("/node-groups/stats", s.handleGetNodeGroupStats)
+	nodes.GET("/traffic/report", s.handleExportTrafficReport)
+	nodes.GET("/geodata", s.handleGeoDataStatus)
+	nodes.GET("/nodes/:id/status-transitions", s.handleListNodeStatusTransitions)
+	nodes.GET("/nodes/:id/failovers", s.handleListNodeFailovers)
+	nodes.GET("/nodes/:id/config-versions", s.handleListNodeConfigVersions)
+	nodes.GET("/nodes/:id/config-versions/diff", s.handleDiffNodeConfigVersions)
+	nodes.GET("/nodes/:id/config-versions/:version", s.handleGetNodeConfigVersion)
+	nodes.POST("/nodes/:id/config-versions/:version/restore", s.handleRestoreNodeConfigVersion)
+	nodes.GET("/nodes/:id/config-overrides", s.handleListNodeConfigOverrides)
+	nodes.PUT("/nodes/:id/config-overrides", s.handleSetNodeConfigOverride)
+	nodes.DELETE("/nodes/:id/config-overrides", s.handleClearNodeConfigOverrides)
+	nodes.GET("/node-enrollments", s.handleListNodeEnrollments)
+	nodes.POST("/node-enrollments", s.handleCreateNodeEnrollment)
+	nodes.DELETE("/node-enrollments/:id", s.handleRevokeNodeEnrollment)
+	nodes.GET("/node-commands", s.handleListNodeCommands)
+	nodes.GET("/nodes/:id/logs", s.handleFetchNodeLogs)
+	nodes.POST("/nodes/:id/diagnostics", s.handleRunNodeDiagnostic)
+
+	content := admin.Group("", s.requirePermission(models.AdminPermissionContent))
+	content.GET("/announcements", s.handleListAnnouncements)
+	content.POST("/announcements", s.handleCreateAnnouncement)
+	content.GET("/announcements/:id", s.handleGetAnnouncement)
+	content.PUT("/announcements/:id", s.handleUpdateAnnouncement)
+	content.DELETE("/announcements/:id", s.handleDeleteAnnouncement)
+	content.POST("/users/:id/notifications", s.handleSendNotification)
+	content.POST("/mail/test", s.handleSendTestMail)
+
+	tenants := admin.Group("", s.requirePermission(models.AdminPermissionTenants))
+	tenants.GET("/tenants", s.handleListTenants)
+	tenants.POST("/tenants", s.handleCreateTenant)
+	tenants.PUT("/tenants/users", s.handleAssignTenantUsers)
+	tenants.GET("/tenants/:id", s.handleGetTenant)
+	tenants.PUT("/tenants/:id", s.handleUpdateTenant)
+	tenants.DELETE("/tenants/:id", s.handleDeleteTenant)
+
+	// Admins, the global config, node removal and automation rules are left to
+	// super admins
+	super := admin.Group("", s.superAdminMiddleware())
+	super.GET("/config", s.handleGetGlobalConfig)
+	super.PUT("/config", s.handleUpdateGlobalConfig)
+	super.GET("/config/history", s.handleListGlobalConfigHistory)
+	super.DELETE("/nodes/:id", s.handleRemoveNode)
+	super.GET("/admins", s.handleListAdmins)
+	super.POST("/admins", s.handleCreateAdmin)
+	super.PUT("/admins/:id", s.handleUpdateAdmin)
+	super.POST("/admins/:id/disable", s.handleDisableAdmin)
+	super.POST("/admins/:id/enable", s.handleEnableAdmin)
+	super.GET("/audit-logs", s.handleListAdminAuditLogs)
+	super.GET("/automation/rules", s.handleListAutomationRules)
+	super.POST("/automation/rules", s.handleCreateAutomationRule)
+	super.POST("/automation/rules/test", s.handleTestAutomationRule)
+	super.PUT("/automation/rules/:id", s.handleUpdateAutomationRule)
+	super.DELETE("/automation/rules/:id", s.handleDeleteAutomationRule)
+	super.POST("/automation/rules/:id/test", s.handleTestSavedAutomationRule)
+	super.GET("/automation/executions", s.handleListAutomationExecutions)
+	super.GET("/automation/rules/:id/executions", s.handleListAutomationExecutions)
+	super.GET("/rpc/openapi.json", s.handleManagementOpenAPI)
+	super.POST("/rpc/:method", s.handleManagementRPC)
+}
+
+// Start starts the HTTP server
+func (s *Server) Start(ctx context.Context) error {
+	address := fmt.Sprintf("%s:%d", s.config.Server.Address, s.config.Server.Port)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	s.listener = listener
+	s.httpServer = &http.Server{
+		Handler:      s.engine,
+		ReadTimeout:  s.config.Server.ReadTimeout,
+		WriteTimeout: s.config.Server.WriteTimeout,
+		IdleTimeout:  s.config.Server.IdleTimeout,
+	}
+
+	s.logger.Info("HTTP server starting",
+		zap.String("address", address),
+		zap.Bool("tls", s.config.Server.TLSEnabled),
+	)
+
+	go func() {
+		var err error
+		if s.config.Server.TLSEnabled {
+			err = s.httpServer.ServeTLS(listener, s.config.Server.CertFile, s.config.Server.KeyFile)
+		} else {
+			err = s.httpServer.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("HTTP server failed", zap.Error(err))
+		}
+	}()
+
+	if err := s.management.Start(ctx); err != nil {
+		return fmt.Errorf("failed to start management service: %w", err)
+	}
+	if s.prober != nil {
+		s.prober.Start(ctx)
+	}
+	if s.mailer != nil {
+		s.mailer.Start(ctx)
+	}
+	if s.apiKeys != nil {
+		s.apiKeys.Start(ctx)
+	}
+	if s.access.geo != nil {
+		s.access.geo.Start(ctx)
+	}
+	if s.events != nil {
+		var eventsCtx context.Context
+		eventsCtx, s.stopEvents = context.WithCancel(ctx)
+		go s.events.Run(eventsCtx)
+		if s.relay != nil {
+			go s.relay.run(eventsCtx)
+		}
+	}
+
+	s.logger.Info("HTTP server started successfully")
+	return nil
+}
+
+// Stop stops the HTTP server
+func (s *Server) Stop(ctx context.Context) error {
+	s.logger.Info("HTTP server stopping")
+
+	if s.httpServer == nil {
+		return nil
+	}
+	if s.mailer != nil {
+		defer s.mailer.Stop()
+	}
+	if s.limiter != nil {
+		defer s.limiter.Close()
+	}
+	if s.access.geo != nil {
+		defer s.access.geo.Stop()
+	}
+	// Deferred calls run last first, the usage of the requests Shutdown
+	// waits for is written before the database closes
+	if s.apiKeys != nil {
+		defer s.apiKeys.Stop()
+	}
+	if s.stopEvents != nil {
+		s.stopEvents()
+	}
+	// Hijacked WebSocket connections are not closed by Shutdown
+	close(s.stopped)
+
+	// Wait for running requests until ctx ends
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		s.logger.Warn("HTTP server force stopped due to timeout", zap.Error(err))
+		return s.httpServer.Close()
+	}
+
+	s.logger.Info("HTTP server stopped gracefully")
+	return nil
+}
+
+// SetReady sets whether /readyz reports the server ready
+func (s *Server) SetReady(ready bool) {
+	s.health.SetReady(ready)
+}
+
+// GetAddress returns the server listen address
+func (s *Server) GetAddress() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return fmt.Sprintf("%s:%d", s.config.Server.Address, s.config.Server.Port)
+}
+
+// IsHealthy returns true if the server is healthy
+func (s *Server) IsHealthy() bool {
+	return s.listener != nil && s.httpServer != nil
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/database"
+)
+
+// newTestServer returns a server on a new SQLite database, with the default
+// configuration changed by configure
+func newTestServer(t *testing.T, configure func(*configv1.WebConfig)) *Server {
+	t.Helper()
+	config := *configv1.DefaultWebConfig()
+	config.Database.Database = filepath.Join(t.TempDir(), "web.db")
+	config.Database.StatsInterval = 0
+	config.Auth.EnableRateLimit = false
+	if configure != nil {
+		configure(&config)
+	}
+
+	dbService, err := database.New(config.Database, zap.NewNop())
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { dbService.Close() })
+	if err := dbService.Migrate(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+
+	s, err := NewServer(config, dbService)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s
+}
+
+// postJSON answers a POST of body from remoteAddr, with a bearer token when
+// one is given, and decodes the JSON response
+func postJSON(t *testing.T, s *Server, path, remoteAddr, token string, body any) (int, map[string]any) {
+	t.Helper()
+	return serveJSON(t, s, postRequest(t, path, remoteAddr, token, body))
+}
+
+// postRequest returns a POST of body in JSON from remoteAddr, with a bearer
+// token when one is given
+func postRequest(t *testing.T, path, remoteAddr, token string, body any) *http.Request {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("encode request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// serveJSON answers a request and decodes the JSON response
+func serveJSON(t *testing.T, s *Server, req *http.Request) (int, map[string]any) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, req)
+
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: decode response %q: %v", req.Method, req.URL.Path, w.Body.String(), err)
+	}
+	return w.Code, resp
+}
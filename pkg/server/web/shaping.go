@@ -0,0 +1,20 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleGetUserShapingStats returns how much a user's speed limit throttled them
+func (s *Server) handleGetUserShapingStats(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.Query("hours"))
+
+	resp, err := s.management.GetUserShapingStats(c.Request.Context(), &pbv1.GetUserShapingStatsRequest{
+		UserId: c.Param("id"),
+		Hours:  int32(hours),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
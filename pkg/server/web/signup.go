@@ -0,0 +1,48 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// signupRequest is the body of a self-registration
+type signupRequest struct {
+	Username     string `json:"username" binding:"required"`
+	Email        string `json:"email" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	ReferralCode string `json:"referral_code"`
+}
+
+// handleSignup registers a user. The email address and the client address,
+// resolved through the trusted proxies, are checked against the registration
+// blocklist; the plan is not the caller's to choose.
+func (s *Server) handleSignup(c *gin.Context) {
+	var req signupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if len(req.Password) < s.config.Auth.PasswordMinLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password is too short"})
+		return
+	}
+
+	// Without a client address the blocklist would not be checked
+	clientIP := c.ClientIP()
+	if clientIP == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "client address unknown"})
+		return
+	}
+
+	resp, err := s.management.CreateUser(c.Request.Context(), &pbv1.CreateUserRequest{
+		Username:     req.Username,
+		Email:        req.Email,
+		Password:     req.Password,
+		ReferralCode: req.ReferralCode,
+		ClientIp:     clientIP,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+func TestSignupBlocklist(t *testing.T) {
+	s := newTestServer(t, func(config *configv1.WebConfig) {
+		config.Auth.AllowSignup = true
+	})
+	blocklist := s.dbService.GetRepository().Blocklist
+	for _, entry := range []*models.BlocklistEntry{
+		{Type: models.BlocklistTypeCIDR, Value: "203.0.113.0/24", Source: "manual"},
+		{Type: models.BlocklistTypeDomain, Value: "spam.example", Source: "manual"},
+	} {
+		if err := blocklist.Create(entry); err != nil {
+			t.Fatalf("create blocklist entry: %v", err)
+		}
+	}
+	signup := func(username, email string) map[string]string {
+		return map[string]string{"username": username, "email": email, "password": "signup-password"}
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		body         map[string]string
+		want         int
+	}{
+		{"blocked network", "203.0.113.9:40000", "", signup("alice", "alice@example.com"), http.StatusForbidden},
+		// A forged header does not move the client out of a blocked network
+		{"blocked network with forged header", "203.0.113.9:40000", "198.51.100.4", signup("bob", "bob@example.com"), http.StatusForbidden},
+		{"blocked email domain", "198.51.100.4:40000", "", signup("carol", "carol@spam.example"), http.StatusForbidden},
+		{"allowed", "198.51.100.4:40000", "", signup("dave", "dave@example.com"), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := postRequest(t, "/api/v1/auth/signup", tt.remoteAddr, "", tt.body)
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			code, resp := serveJSON(t, s, req)
+			if code != tt.want {
+				t.Fatalf("signup = %d %v, want %d", code, resp, tt.want)
+			}
+			if tt.want == http.StatusForbidden && resp["reason"] != "BLOCKLISTED" {
+				t.Errorf("signup refused with reason %v, want BLOCKLISTED", resp["reason"])
+			}
+		})
+	}
+
+	if _, err := s.dbService.GetRepository().User.GetByUsername("alice"); err == nil {
+		t.Error("user of a blocked network created")
+	}
+}
+
+func TestSignupStartsTrial(t *testing.T) {
+	const trialPlanID = 7
+	s := newTestServer(t, func(config *configv1.WebConfig) {
+		config.Auth.AllowSignup = true
+		config.Auth.SignupTrialPlanID = trialPlanID
+	})
+	repo := s.dbService.GetRepository()
+	trial := &models.Plan{ID: trialPlanID, Name: "Trial", IsTrialPlan: true, TrialDays: 7, Period: models.PlanPeriodMonthly, IsEnabled: true, Status: models.PlanStatusActive}
+	if err := repo.Plan.Create(trial); err != nil {
+		t.Fatalf("create trial plan: %v", err)
+	}
+
+	body := map[string]string{"username": "alice", "email": "alice@example.com", "password": "signup-password"}
+	if code, resp := postJSON(t, s, "/api/v1/auth/signup", testClient, "", body); code != http.StatusOK {
+		t.Fatalf("signup = %d %v, want 200", code, resp)
+	}
+
+	user, err := repo.User.GetByUsername("alice")
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if user.PlanID != trialPlanID || user.TrialEndsAt == nil {
+		t.Errorf("user on plan %d with trial ending %v, want a trial of plan %d", user.PlanID, user.TrialEndsAt, trialPlanID)
+	}
+	if user.Password == body["password"] {
+		t.Error("password stored in clear")
+	}
+
+	// The stored hash is the one logins check
+	login := map[string]string{"username": "alice", "password": "signup-password"}
+	if code, resp := postJSON(t, s, "/api/v1/auth/login", testClient, "", login); code != http.StatusOK {
+		t.Errorf("login after signup = %d %v, want 200", code, resp)
+	}
+}
@@ -0,0 +1,173 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/subscription"
+)
+
+// handleSubscription serves a user's subscription profile.
+// Responses carry an ETag derived from the content hash so that clients sending
+// If-None-Match receive 304 Not Modified when nothing changed.
+func (s *Server) handleSubscription(c *gin.Context) {
+	token := c.Param("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subscription token is required"})
+		return
+	}
+
+	repo := s.dbService.GetRepository()
+	previousToken := false
+	user, err := repo.User.GetBySubscriptionToken(token)
+	if err != nil {
+		// Links replaced by a rotation keep working during their grace period
+		user, err = repo.SubscriptionToken.GetUserByPreviousToken(token)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+			return
+		}
+		previousToken = true
+		s.logger.Debug("Subscription served for a rotated token", zap.Uint("user_id", user.ID))
+	}
+
+	if !user.IsActive() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return
+	}
+
+	// Fetches are recorded for the access history and the detection of
+	// shared links, also when the client's copy is current
+	access := models.NewSubscriptionAccessLog(user.ID, c.ClientIP(), c.Request.UserAgent(), previousToken)
+	if err := repo.SubscriptionLog.Create(access); err != nil {
+		s.logger.Warn("Failed to record subscription fetch", zap.Error(err), zap.Uint("user_id", user.ID))
+	}
+
+	nodes, err := repo.Node.GetUserNodes(user.ID)
+	if err != nil {
+		s.logger.Error("Failed to get user nodes", zap.Error(err), zap.Uint("user_id", user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var labels map[uint]string
+	if s.settings.Bool(models.SettingSubscriptionShowNodeQuality, s.config.Subscription.ShowNodeQuality) {
+		labels = s.nodeQualityLabels(nodes)
+	}
+
+	profile, err := subscription.Build(user, nodes, labels)
+	if err != nil {
+		s.logger.Error("Failed to build subscription", zap.Error(err), zap.Uint("user_id", user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Record content changes so the panel can tell when a subscription actually changed
+	if profile.Hash != user.SubscriptionHash {
+		if err := repo.User.UpdateSubscriptionHash(user.ID, profile.Hash); err != nil {
+			s.logger.Warn("Failed to update subscription hash", zap.Error(err), zap.Uint("user_id", user.ID))
+		}
+	}
+
+	cfg := s.config.Subscription
+	etag := profile.ETag()
+
+	header := c.Writer.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "private, max-age="+strconv.Itoa(int(cfg.CacheMaxAge.Seconds()))+", must-revalidate")
+	header.Set("Vary", "Accept-Encoding")
+	header.Set("Profile-Update-Interval", subscription.UpdateIntervalHeader(cfg.UpdateInterval))
+	header.Set("Subscription-Userinfo", subscription.UserInfoHeader(user))
+	header.Set("X-Subscription-Hash", profile.Hash)
+	subscription.SetBrandingHeaders(header, s.brandingFor(user))
+
+	if subscription.MatchesETag(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=utf-8", profile.Content)
+}
+
+// rotateTokenRequest is the optional body of a self-service token rotation
+type rotateTokenRequest struct {
+	// RevokeOld invalidates the old link immediately instead of after the grace period
+	RevokeOld bool `json:"revoke_old"`
+}
+
+// handleRotateSubscriptionToken replaces the caller's subscription token
+func (s *Server) handleRotateSubscriptionToken(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	userID, err := strconv.ParseUint(claims.UserID, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var req rotateTokenRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	graceUntil := time.Now().Add(s.settings.Duration(models.SettingSubscriptionTokenGracePeriod, s.config.Subscription.TokenGracePeriod))
+	reason := "self-service rotation"
+	if req.RevokeOld {
+		graceUntil = time.Now()
+		reason = "self-service rotation, old links revoked"
+	}
+
+	token := models.NewSubscriptionToken()
+	rotation := &models.SubscriptionTokenRotation{
+		UserID:     uint(userID),
+		GraceUntil: graceUntil,
+		Operator:   claims.Username,
+		Reason:     reason,
+	}
+	if err := s.dbService.GetRepository().SubscriptionToken.Rotate(rotation, token); err != nil {
+		s.logger.Error("Failed to rotate subscription token", zap.Error(err), zap.Uint64("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	s.logger.Info("subscription token rotated",
+		zap.Uint("rotation_id", rotation.ID),
+		zap.Uint64("user_id", userID),
+		zap.Time("grace_until", rotation.GraceUntil),
+		zap.String("operator", rotation.Operator),
+		zap.String("reason", rotation.Reason),
+	)
+
+	response := gin.H{
+		"subscription_token":    token,
+		"subscribe_path":        "/api/v1/subscribe/" + token,
+		"old_token_valid_until": rotation.GraceUntil,
+	}
+	// Tenants with their own subscription host hand out absolute links on it
+	if user, err := s.dbService.GetRepository().User.GetByID(uint(userID)); err == nil {
+		response["subscribe_url"] = s.brandingFor(user).SubscriptionURL(token)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// handleListSubscriptionAccessLogs lists the fetches of a user's subscription
+func (s *Server) handleListSubscriptionAccessLogs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListSubscriptionAccessLogs(c.Request.Context(), &pbv1.ListSubscriptionAccessLogsRequest{
+		UserId:   c.Param("id"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// Tenant administration endpoints. Request and response bodies are the JSON
+// form of the matching ManagementService messages.
+
+// handleListTenants lists tenants
+func (s *Server) handleListTenants(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListTenants(c.Request.Context(), &pbv1.ListTenantsRequest{
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateTenant creates a tenant from a TenantSpec body
+func (s *Server) handleCreateTenant(c *gin.Context) {
+	spec := &pbv1.TenantSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.CreateTenant(c.Request.Context(), &pbv1.CreateTenantRequest{Tenant: spec})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetTenant returns a tenant with its branding
+func (s *Server) handleGetTenant(c *gin.Context) {
+	resp, err := s.management.GetTenant(c.Request.Context(), &pbv1.GetTenantRequest{TenantId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleUpdateTenant replaces the editable fields of a tenant with a TenantSpec body
+func (s *Server) handleUpdateTenant(c *gin.Context) {
+	spec := &pbv1.TenantSpec{}
+	if !bindManagementRequest(c, spec) {
+		return
+	}
+	resp, err := s.management.UpdateTenant(c.Request.Context(), &pbv1.UpdateTenantRequest{
+		TenantId: c.Param("id"),
+		Tenant:   spec,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeleteTenant deletes a tenant, its users fall back to the default branding
+func (s *Server) handleDeleteTenant(c *gin.Context) {
+	resp, err := s.management.DeleteTenant(c.Request.Context(), &pbv1.DeleteTenantRequest{
+		TenantId:     c.Param("id"),
+		Confirmation: c.GetHeader(headerConfirmation),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleAssignTenantUsers moves users to the tenant in the body, or back to
+// the default branding when tenant_id is empty
+func (s *Server) handleAssignTenantUsers(c *gin.Context) {
+	req := &pbv1.AssignUsersToTenantRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	resp, err := s.management.AssignUsersToTenant(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleExportTrafficReport downloads the traffic of each user or each node
+// over a range of days as a CSV or XLSX file
+func (s *Server) handleExportTrafficReport(c *gin.Context) {
+	resp, err := s.management.ExportTrafficReport(c.Request.Context(), &pbv1.ExportTrafficReportRequest{
+		GroupBy:   c.Query("group_by"),
+		StartDate: c.Query("start"),
+		EndDate:   c.Query("end"),
+		Format:    c.Query("format"),
+	})
+	if err != nil {
+		s.writeManagementResponse(c, nil, err)
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": resp.Filename}))
+	c.Data(http.StatusOK, resp.ContentType, resp.Content)
+}
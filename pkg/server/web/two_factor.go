@@ -0,0 +1,72 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// twoFactorEnrollmentMiddleware authenticates like authMiddleware, but also
+// accepts the setup token of a login refused until 2FA is enabled
+func (s *Server) twoFactorEnrollmentMiddleware() gin.HandlerFunc {
+	authenticate := s.authMiddleware()
+	return func(c *gin.Context) {
+		token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token != "" && !auth.IsAPIKey(token) {
+			if claims, err := s.jwtManager.ValidateTwoFactorSetupToken(token); err == nil {
+				c.Set(contextKeyClaims, claims)
+				c.Next()
+				return
+			}
+		}
+		authenticate(c)
+	}
+}
+
+// 2FA endpoints of the caller, who is taken from the token and never from
+// the body
+
+// handleSetupUserTwoFactor starts the 2FA enrollment of the caller with a
+// new secret
+func (s *Server) handleSetupUserTwoFactor(c *gin.Context) {
+	resp, err := s.management.SetupTwoFactor(c.Request.Context(), &pbv1.SetupTwoFactorRequest{
+		UserId: c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleEnableUserTwoFactor enables 2FA of the caller with a code of the
+// secret of the setup, returning the backup codes
+func (s *Server) handleEnableUserTwoFactor(c *gin.Context) {
+	req := &pbv1.EnableTwoFactorRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.MustGet(contextKeyClaims).(*auth.Claims).UserID
+	resp, err := s.management.EnableTwoFactor(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDisableUserTwoFactor disables 2FA of the caller with a TOTP or
+// backup code. Admins cannot while 2FA is mandatory for them.
+func (s *Server) handleDisableUserTwoFactor(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	if s.config.Auth.RequireAdminTwoFactor && models.UserRole(claims.Role).IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is mandatory for admin accounts"})
+		return
+	}
+
+	req := &pbv1.DisableTwoFactorRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = claims.UserID
+	req.Force = false
+	resp, err := s.management.DisableTwoFactor(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"sing-box-web/pkg/auth"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+)
+
+const testClient = "203.0.113.9:40000"
+
+// createTestUser stores an active user with a password
+func createTestUser(t *testing.T, s *Server, username string, role models.UserRole, password string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := &models.User{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: string(hash),
+		Role:     role,
+		Status:   models.UserStatusActive,
+	}
+	if err := s.dbService.GetRepository().User.Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestAdminTwoFactorEnrollment(t *testing.T) {
+	s := newTestServer(t, func(config *configv1.WebConfig) {
+		config.Auth.RequireAdminTwoFactor = true
+	})
+	createTestUser(t, s, "root", models.UserRoleAdmin, "secret-password")
+	login := map[string]string{"username": "root", "password": "secret-password"}
+
+	// The login is refused with a token that only allows enrolling
+	code, resp := postJSON(t, s, "/api/v1/auth/login", testClient, "", login)
+	setupToken, _ := resp["setup_token"].(string)
+	if code != http.StatusForbidden || resp["two_factor_setup_required"] != true || setupToken == "" {
+		t.Fatalf("login without 2FA = %d %v, want 403 with a setup token", code, resp)
+	}
+	if code, _ := postJSON(t, s, "/api/v1/auth/logout", testClient, setupToken, nil); code != http.StatusUnauthorized {
+		t.Errorf("setup token used as access token = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code, _ := postJSON(t, s, "/api/v1/user/two-factor/disable", testClient, setupToken, nil); code != http.StatusUnauthorized {
+		t.Errorf("setup token used to disable 2FA = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	code, resp = postJSON(t, s, "/api/v1/user/two-factor/setup", testClient, setupToken, nil)
+	secret, _ := resp["secret"].(string)
+	if code != http.StatusOK || secret == "" {
+		t.Fatalf("setup = %d %v, want a secret", code, resp)
+	}
+	now := time.Now()
+	totp, err := auth.GenerateTOTPCode(secret, now)
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode: %v", err)
+	}
+	code, resp = postJSON(t, s, "/api/v1/user/two-factor/enable", testClient, setupToken, map[string]string{"code": totp})
+	if code != http.StatusOK || resp["success"] != true {
+		t.Fatalf("enable = %d %v", code, resp)
+	}
+
+	// The login then goes through with the next code
+	next, err := auth.GenerateTOTPCode(secret, now.Add(auth.TOTPPeriod))
+	if err != nil {
+		t.Fatalf("GenerateTOTPCode: %v", err)
+	}
+	login["two_factor_code"] = next
+	code, resp = postJSON(t, s, "/api/v1/auth/login", testClient, "", login)
+	if code != http.StatusOK || resp["access_token"] == nil {
+		t.Fatalf("login with 2FA = %d %v", code, resp)
+	}
+}
+
+func TestUserTwoFactorActsOnCaller(t *testing.T) {
+	s := newTestServer(t, nil)
+	alice := createTestUser(t, s, "alice", models.UserRoleUser, "alice-password")
+	bob := createTestUser(t, s, "bob", models.UserRoleUser, "bob-password")
+
+	aliceToken, err := s.jwtManager.GenerateToken(strconv.FormatUint(uint64(alice.ID), 10), alice.Username, string(alice.Role))
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	// A user_id in the body does not name another user
+	body := map[string]string{"user_id": strconv.FormatUint(uint64(bob.ID), 10)}
+	if code, resp := postJSON(t, s, "/api/v1/user/two-factor/setup", testClient, aliceToken, body); code != http.StatusOK {
+		t.Fatalf("setup = %d %v", code, resp)
+	}
+	users := s.dbService.GetRepository().User
+	if stored, _ := users.GetByID(alice.ID); stored.TwoFactorSecret == "" {
+		t.Error("setup did not store a secret for the caller")
+	}
+	if stored, _ := users.GetByID(bob.ID); stored.TwoFactorSecret != "" {
+		t.Error("setup stored a secret for the user of the body")
+	}
+}
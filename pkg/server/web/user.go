@@ -0,0 +1,94 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleListUsers lists users by ?status, ?search and ?plan_id, sorted by
+// ?sort_by and ?sort_order. ?fields limits the user fields returned. With
+// ?saved_filter the parameters left out are taken from that saved filter.
+func (s *Server) handleListUsers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListUsers(c.Request.Context(), &pbv1.ListUsersRequest{
+		Page:          int32(page),
+		PageSize:      int32(pageSize),
+		StatusFilter:  c.Query("status"),
+		SearchKeyword: c.Query("search"),
+		PlanId:        c.Query("plan_id"),
+		SortBy:        c.Query("sort_by"),
+		SortOrder:     c.Query("sort_order"),
+		FieldMask:     queryFieldMask(c, "fields"),
+		SavedFilterId: c.Query("saved_filter"),
+		AdminId:       c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetUserDetail returns everything the user detail page shows in one call
+func (s *Server) handleGetUserDetail(c *gin.Context) {
+	resp, err := s.management.GetUserDetail(c.Request.Context(), &pbv1.GetUserDetailRequest{
+		UserId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleSetUserInactivityExempt exempts the user of the path from the
+// inactive account policy, or subjects it again, with a body of {"exempt": bool}
+func (s *Server) handleSetUserInactivityExempt(c *gin.Context) {
+	req := &pbv1.SetUserInactivityExemptRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.Param("id")
+	resp, err := s.management.SetUserInactivityExempt(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetUserTraffic returns the traffic of a user between the RFC 3339
+// ?start and ?end. With ?saved_filter the parameters left out are taken from
+// that saved filter.
+func (s *Server) handleGetUserTraffic(c *gin.Context) {
+	start, ok := timeQuery(c, "start")
+	if !ok {
+		return
+	}
+	end, ok := timeQuery(c, "end")
+	if !ok {
+		return
+	}
+
+	resp, err := s.management.GetUserTraffic(c.Request.Context(), &pbv1.GetUserTrafficRequest{
+		UserId:        c.Param("id"),
+		StartTime:     start,
+		EndTime:       end,
+		Granularity:   c.Query("granularity"),
+		SavedFilterId: c.Query("saved_filter"),
+		AdminId:       c.MustGet(contextKeyClaims).(*auth.Claims).UserID,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// timeQuery parses an optional RFC 3339 query parameter, answering 400 when
+// it is malformed
+func timeQuery(c *gin.Context, param string) (*timestamppb.Timestamp, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param + ", expected an RFC 3339 time"})
+		return nil, false
+	}
+	return timestamppb.New(t), true
+}
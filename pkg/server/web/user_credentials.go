@@ -0,0 +1,48 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleRegenerateUserCredentials replaces a user's proxy UUID and pushes it
+// to the user's nodes
+func (s *Server) handleRegenerateUserCredentials(c *gin.Context) {
+	req := &pbv1.RegenerateUserCredentialsRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.RegenerateUserCredentials(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleBulkRegenerateUserCredentials regenerates the credentials of the
+// users selected by ID, by plan or all of them
+func (s *Server) handleBulkRegenerateUserCredentials(c *gin.Context) {
+	req := &pbv1.BulkRegenerateUserCredentialsRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.BulkRegenerateUserCredentials(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListUserCredentialRotations lists the credential history of a user
+func (s *Server) handleListUserCredentialRotations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListUserCredentialRotations(c.Request.Context(), &pbv1.ListUserCredentialRotationsRequest{
+		UserId:   c.Param("id"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
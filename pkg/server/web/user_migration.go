@@ -0,0 +1,79 @@
+package web
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// User migration endpoints. Migrations move the users of a plan or node to
+// another at a set rate, the API server moving them in the background.
+
+// handleListUserMigrations lists the migrations, optionally of one ?status,
+// newest first
+func (s *Server) handleListUserMigrations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListUserMigrations(c.Request.Context(), &pbv1.ListUserMigrationsRequest{
+		Status:   c.Query("status"),
+		Page:     int32(page),
+		PageSize: int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleCreateUserMigration starts a migration from a CreateUserMigrationRequest
+// body with the caller as operator
+func (s *Server) handleCreateUserMigration(c *gin.Context) {
+	req := &pbv1.CreateUserMigrationRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+
+	resp, err := s.management.CreateUserMigration(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleGetUserMigration returns the progress of a migration
+func (s *Server) handleGetUserMigration(c *gin.Context) {
+	resp, err := s.management.GetUserMigration(c.Request.Context(), &pbv1.GetUserMigrationRequest{
+		MigrationId: c.Param("id"),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handlePauseUserMigration stops a migration after its current batch
+func (s *Server) handlePauseUserMigration(c *gin.Context) {
+	s.setUserMigrationState(c, "pause", 0)
+}
+
+// handleResumeUserMigration resumes a paused migration, at the rate of an
+// optional {"users_per_minute": n} body
+func (s *Server) handleResumeUserMigration(c *gin.Context) {
+	req := &pbv1.SetUserMigrationStateRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	s.setUserMigrationState(c, "resume", req.UsersPerMinute)
+}
+
+// handleCancelUserMigration stops a migration for good, the users moved
+// already stay moved
+func (s *Server) handleCancelUserMigration(c *gin.Context) {
+	s.setUserMigrationState(c, "cancel", 0)
+}
+
+// setUserMigrationState applies an action to the migration in the path
+func (s *Server) setUserMigrationState(c *gin.Context, action string, usersPerMinute int32) {
+	resp, err := s.management.SetUserMigrationState(c.Request.Context(), &pbv1.SetUserMigrationStateRequest{
+		MigrationId:    c.Param("id"),
+		Action:         action,
+		UsersPerMinute: usersPerMinute,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sing-box-web/pkg/auth"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+)
+
+// handleUserBalance returns the caller's balance
+func (s *Server) handleUserBalance(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+
+	resp, err := s.management.GetUserBalance(c.Request.Context(), &pbv1.GetUserBalanceRequest{UserId: claims.UserID})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListUserBalanceTransactions lists the caller's balance transactions
+func (s *Server) handleListUserBalanceTransactions(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListBalanceTransactions(c.Request.Context(), &pbv1.ListBalanceTransactionsRequest{
+		UserId:     claims.UserID,
+		TypeFilter: c.Query("type"),
+		Page:       int32(page),
+		PageSize:   int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handlePayUserOrder pays one of the caller's pending orders from their balance
+func (s *Server) handlePayUserOrder(c *gin.Context) {
+	claims := c.MustGet(contextKeyClaims).(*auth.Claims)
+
+	order, err := s.management.GetOrder(c.Request.Context(), &pbv1.GetOrderRequest{OrderId: c.Param("id")})
+	if err != nil {
+		s.writeManagementResponse(c, nil, err)
+		return
+	}
+	// Other users' orders are reported as missing rather than forbidden
+	if order.Order.UserId != claims.UserID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+		return
+	}
+
+	resp, err := s.management.ConfirmOrderPayment(c.Request.Context(), &pbv1.ConfirmOrderPaymentRequest{
+		OrderId:  c.Param("id"),
+		Method:   models.PaymentMethodBalance,
+		Operator: claims.Username,
+	})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// User balance administration endpoints. Request and response bodies are the
+// JSON form of the matching ManagementService messages; the operator is the caller.
+
+// handleGetUserBalance returns a user's balance checked against the ledger
+func (s *Server) handleGetUserBalance(c *gin.Context) {
+	resp, err := s.management.GetUserBalance(c.Request.Context(), &pbv1.GetUserBalanceRequest{UserId: c.Param("id")})
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleTopUpUserBalance adds money to a user's balance
+func (s *Server) handleTopUpUserBalance(c *gin.Context) {
+	req := &pbv1.TopUpUserBalanceRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.TopUpUserBalance(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleDeductUserBalance takes money from a user's balance
+func (s *Server) handleDeductUserBalance(c *gin.Context) {
+	req := &pbv1.DeductUserBalanceRequest{}
+	if !bindManagementRequest(c, req) {
+		return
+	}
+	req.UserId = c.Param("id")
+	req.Operator = c.MustGet(contextKeyClaims).(*auth.Claims).Username
+	resp, err := s.management.DeductUserBalance(c.Request.Context(), req)
+	s.writeManagementResponse(c, resp, err)
+}
+
+// handleListBalanceTransactions lists balance transactions, filtered by the
+// user_id and type query parameters
+func (s *Server) handleListBalanceTransactions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.Query("page"))
+	pageSize, _ := strconv.Atoi(c.Query("page_size"))
+
+	resp, err := s.management.ListBalanceTransactions(c.Request.Context(), &pbv1.ListBalanceTransactionsRequest{
+		UserId:     c.Query("user_id"),
+		TypeFilter: c.Query("type"),
+		Page:       int32(page),
+		PageSize:   int32(pageSize),
+	})
+	s.writeManagementResponse(c, resp, err)
+}
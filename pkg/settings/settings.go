@@ -0,0 +1,163 @@
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+// cacheTTL is how long the settings are cached. Changes made through a Store
+// apply at once; other instances sharing the database see them within it.
+const cacheTTL = 30 * time.Second
+
+// Store reads the system settings admins change at runtime, caching them.
+// Getters take the value of the configuration file, used while the setting
+// is unset or cannot be read.
+type Store struct {
+	repo   repository.SystemSettingRepository
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	values   map[string]string
+	loadedAt time.Time
+	now      func() time.Time
+}
+
+// NewStore creates a system settings store
+func NewStore(repo repository.SystemSettingRepository, logger *zap.Logger) *Store {
+	return &Store{
+		repo:   repo,
+		logger: logger.Named("settings"),
+		now:    time.Now,
+	}
+}
+
+// Bool returns a bool setting, fallback when unset
+func (s *Store) Bool(key string, fallback bool) bool {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// Duration returns a duration setting, fallback when unset
+func (s *Store) Duration(key string, fallback time.Duration) time.Duration {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// Values returns the settings that are set, by key
+func (s *Store) Values() (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// Validate checks settings before they are set. Unknown keys and invalid
+// values are reported in a *models.ValidationError; empty values unset.
+func Validate(values map[string]string) error {
+	var fields []models.FieldError
+	for key, value := range values {
+		definition, ok := models.LookupSetting(key)
+		if !ok {
+			fields = append(fields, models.FieldError{Field: key, Value: value, Message: fmt.Sprintf("unknown setting %q", key)})
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		var validation *models.ValidationError
+		if errors.As(definition.Validate(value), &validation) {
+			fields = append(fields, validation.Fields...)
+		}
+	}
+	if len(fields) > 0 {
+		return &models.ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// Set validates and saves settings, unsetting those with an empty value, and
+// returns the recorded changes. Nothing is saved when a setting is invalid.
+func (s *Store) Set(values map[string]string, operator string) ([]*models.SystemSettingChange, error) {
+	if err := Validate(values); err != nil {
+		return nil, err
+	}
+	changes, err := s.repo.Apply(values, operator)
+	s.Invalidate()
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Invalidate drops the cached settings, read again on the next lookup
+func (s *Store) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = nil
+}
+
+// lookup returns a setting and whether it is set
+func (s *Store) lookup(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		s.logger.Error("Failed to load system settings", zap.Error(err))
+		if s.values == nil {
+			return "", false
+		}
+	}
+	value, ok := s.values[key]
+	return value, ok
+}
+
+// load reads the settings when the cache is empty or expired. A failed read
+// keeps the expired values for another TTL. The caller holds mu.
+func (s *Store) load() error {
+	now := s.now()
+	if s.values != nil && now.Sub(s.loadedAt) < cacheTTL {
+		return nil
+	}
+	settings, err := s.repo.List()
+	if err != nil {
+		if s.values != nil {
+			s.loadedAt = now
+		}
+		return err
+	}
+	s.values = make(map[string]string, len(settings))
+	for _, setting := range settings {
+		s.values[setting.Key] = setting.Value
+	}
+	s.loadedAt = now
+	return nil
+}
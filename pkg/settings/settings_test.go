@@ -0,0 +1,118 @@
+package settings
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"sing-box-web/pkg/models"
+)
+
+// fakeRepository keeps the settings in memory and counts the reads
+type fakeRepository struct {
+	values map[string]string
+	reads  int
+	err    error
+}
+
+func (r *fakeRepository) List() ([]*models.SystemSetting, error) {
+	r.reads++
+	if r.err != nil {
+		return nil, r.err
+	}
+	var settings []*models.SystemSetting
+	for key, value := range r.values {
+		settings = append(settings, &models.SystemSetting{Key: key, Value: value})
+	}
+	return settings, nil
+}
+
+func (r *fakeRepository) Apply(values map[string]string, operator string) ([]*models.SystemSettingChange, error) {
+	var changes []*models.SystemSettingChange
+	for key, value := range values {
+		if value == "" {
+			delete(r.values, key)
+		} else {
+			r.values[key] = value
+		}
+		changes = append(changes, &models.SystemSettingChange{Key: key, NewValue: value, Operator: operator})
+	}
+	return changes, nil
+}
+
+func (r *fakeRepository) ListChanges(string, int, int) ([]*models.SystemSettingChange, int64, error) {
+	return nil, 0, nil
+}
+
+func (r *fakeRepository) LatestChangeID() (uint, error) {
+	return 0, nil
+}
+
+func TestStoreFallbackAndCache(t *testing.T) {
+	repo := &fakeRepository{values: map[string]string{models.SettingNodeRegistrationEnabled: "false"}}
+	store := NewStore(repo, zap.NewNop())
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if store.Bool(models.SettingNodeRegistrationEnabled, true) {
+		t.Error("set bool setting ignored")
+	}
+	if got := store.Duration(models.SettingNodeCleanupInterval, time.Minute); got != time.Minute {
+		t.Errorf("unset duration = %v, want the fallback", got)
+	}
+	if repo.reads != 1 {
+		t.Errorf("reads = %d, want the settings cached", repo.reads)
+	}
+
+	// Changes of other instances are seen once the cache expires
+	repo.values[models.SettingNodeCleanupInterval] = "5m"
+	now = now.Add(cacheTTL)
+	if got := store.Duration(models.SettingNodeCleanupInterval, time.Minute); got != 5*time.Minute {
+		t.Errorf("duration after expiry = %v, want 5m", got)
+	}
+
+	// A failed read keeps the last values
+	repo.err = errors.New("database down")
+	now = now.Add(cacheTTL)
+	if store.Bool(models.SettingNodeRegistrationEnabled, true) {
+		t.Error("failed read dropped the cached settings")
+	}
+}
+
+func TestStoreSet(t *testing.T) {
+	repo := &fakeRepository{values: map[string]string{models.SettingSubscriptionShowNodeQuality: "true"}}
+	store := NewStore(repo, zap.NewNop())
+	if !store.Bool(models.SettingSubscriptionShowNodeQuality, false) {
+		t.Fatal("set bool setting ignored")
+	}
+
+	_, err := store.Set(map[string]string{
+		"unknown.key":                     "1",
+		models.SettingNodeCleanupInterval: "1s",
+		models.SettingNodeMaxOfflineTime:  "soon",
+	}, "admin")
+	var validation *models.ValidationError
+	if !errors.As(err, &validation) || len(validation.Fields) != 3 {
+		t.Fatalf("Set = %v, want 3 invalid fields", err)
+	}
+	if len(repo.values) != 1 {
+		t.Errorf("invalid settings saved: %v", repo.values)
+	}
+
+	// Saving invalidates the cache, unsetting falls back again
+	changes, err := store.Set(map[string]string{
+		models.SettingSubscriptionShowNodeQuality:  "",
+		models.SettingSubscriptionTokenGracePeriod: "24h",
+	}, "admin")
+	if err != nil || len(changes) != 2 {
+		t.Fatalf("Set = %v, %v, want 2 changes", changes, err)
+	}
+	if store.Bool(models.SettingSubscriptionShowNodeQuality, false) {
+		t.Error("unset setting did not fall back")
+	}
+	if got := store.Duration(models.SettingSubscriptionTokenGracePeriod, 0); got != 24*time.Hour {
+		t.Errorf("grace period = %v, want 24h", got)
+	}
+}
@@ -0,0 +1,214 @@
+package subscription
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sing-box-web/pkg/models"
+)
+
+// Profile is a rendered subscription for a single user
+type Profile struct {
+	Content []byte
+	Hash    string
+}
+
+// ETag returns the strong entity tag for the profile content
+func (p *Profile) ETag() string {
+	return `"` + p.Hash[:32] + `"`
+}
+
+// Build renders the sing-box outbound profile of a user for the given nodes.
+// Optional labels are appended to the outbound tag of the matching node.
+// The output is deterministic so that identical inputs always yield the same hash.
+func Build(user *models.User, nodes []*models.Node, labels map[uint]string) (*Profile, error) {
+	enabled := make([]*models.Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.IsEnabled {
+			enabled = append(enabled, node)
+		}
+	}
+	sort.SliceStable(enabled, func(i, j int) bool {
+		if enabled[i].Sort != enabled[j].Sort {
+			return enabled[i].Sort < enabled[j].Sort
+		}
+		return enabled[i].ID < enabled[j].ID
+	})
+
+	outbounds := make([]map[string]interface{}, 0, len(enabled)+1)
+	tags := make([]string, 0, len(enabled))
+	for _, node := range enabled {
+		outbound, err := buildOutbound(user, node)
+		if err != nil {
+			return nil, err
+		}
+		if label, ok := labels[node.ID]; ok && label != "" {
+			outbound["tag"] = fmt.Sprintf("%s [%s]", outbound["tag"], label)
+		}
+		outbounds = append(outbounds, outbound)
+		tags = append(tags, outbound["tag"].(string))
+	}
+
+	if len(tags) > 0 {
+		selector := map[string]interface{}{
+			"type":      "selector",
+			"tag":       "proxy",
+			"outbounds": tags,
+			"default":   tags[0],
+		}
+		outbounds = append([]map[string]interface{}{selector}, outbounds...)
+	}
+
+	content, err := json.MarshalIndent(map[string]interface{}{
+		"outbounds": outbounds,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode subscription: %w", err)
+	}
+
+	return &Profile{
+		Content: content,
+		Hash:    ContentHash(content),
+	}, nil
+}
+
+// ContentHash returns the hex encoded SHA-256 hash of subscription content
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchesETag reports whether an If-None-Match header value matches the etag
+func MatchesETag(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		// Weak comparison as required for If-None-Match (RFC 9110 13.1.2)
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// UserInfoHeader builds the subscription-userinfo header understood by most clients
+func UserInfoHeader(user *models.User) string {
+	parts := []string{
+		"upload=0",
+		"download=" + strconv.FormatInt(user.TrafficUsed, 10),
+		"total=" + strconv.FormatInt(user.TrafficQuota, 10),
+	}
+	if user.ExpiresAt != nil {
+		parts = append(parts, "expire="+strconv.FormatInt(user.ExpiresAt.Unix(), 10))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// UpdateIntervalHeader builds the profile-update-interval header, in whole
+// hours as clients expect. Intervals are rounded up, to at least an hour, so
+// that clients never refresh more often than configured or not at all.
+func UpdateIntervalHeader(interval time.Duration) string {
+	hours := int(math.Ceil(interval.Hours()))
+	return strconv.Itoa(max(hours, 1))
+}
+
+// SetBrandingHeaders sets the headers clients use to name and link a profile.
+// The title is base64 encoded so that non-ASCII panel names survive intact.
+func SetBrandingHeaders(header http.Header, branding models.Branding) {
+	if branding.PanelName != "" {
+		header.Set("Profile-Title", "base64:"+base64.StdEncoding.EncodeToString([]byte(branding.PanelName)))
+		header.Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(branding.PanelName))
+	}
+	if branding.SupportURL != "" {
+		header.Set("Support-Url", branding.SupportURL)
+	}
+	if branding.SubscriptionHost != "" {
+		header.Set("Profile-Web-Page-Url", "https://"+branding.SubscriptionHost)
+	}
+}
+
+// buildOutbound converts a node into a sing-box outbound for the user
+func buildOutbound(user *models.User, node *models.Node) (map[string]interface{}, error) {
+	outbound := map[string]interface{}{
+		"type":        string(node.Type),
+		"tag":         fmt.Sprintf("%s-%d", node.Name, node.ID),
+		"server":      node.Host,
+		"server_port": node.Port,
+	}
+
+	switch node.Type {
+	case models.NodeTypeVMess:
+		outbound["uuid"] = user.UUID
+		outbound["security"] = "auto"
+		outbound["alter_id"] = 0
+	case models.NodeTypeVLESS:
+		outbound["uuid"] = user.UUID
+	case models.NodeTypeTrojan, models.NodeTypeHysteria2:
+		outbound["password"] = user.UUID
+	case models.NodeTypeShadowsocks:
+		outbound["method"] = node.Method
+		outbound["password"] = user.UUID
+	case models.NodeTypeHysteria:
+		outbound["auth_str"] = user.UUID
+	case models.NodeTypeTUIC:
+		outbound["uuid"] = user.UUID
+		outbound["password"] = user.UUID
+	default:
+		return nil, fmt.Errorf("unsupported node type: %s", node.Type)
+	}
+
+	if node.TLS {
+		tls := map[string]interface{}{
+			"enabled":  true,
+			"insecure": node.AllowInsecure,
+		}
+		if node.ServerName != "" {
+			tls["server_name"] = node.ServerName
+		}
+		if node.ALPN != "" {
+			tls["alpn"] = strings.Split(node.ALPN, ",")
+		}
+		if node.Fingerprint != "" {
+			tls["utls"] = map[string]interface{}{
+				"enabled":     true,
+				"fingerprint": node.Fingerprint,
+			}
+		}
+		outbound["tls"] = tls
+	}
+
+	switch node.Network {
+	case "ws":
+		transport := map[string]interface{}{
+			"type": "ws",
+			"path": node.Path,
+		}
+		if node.Host_header != "" {
+			transport["headers"] = map[string]string{"Host": node.Host_header}
+		}
+		outbound["transport"] = transport
+	case "grpc":
+		outbound["transport"] = map[string]interface{}{
+			"type":         "grpc",
+			"service_name": node.Path,
+		}
+	}
+
+	return outbound, nil
+}
@@ -0,0 +1,82 @@
+package subscription
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileETag(t *testing.T) {
+	profile := &Profile{Content: []byte(`{"outbounds":[]}`)}
+	profile.Hash = ContentHash(profile.Content)
+
+	etag := profile.ETag()
+	if strings.HasPrefix(etag, "W/") {
+		t.Errorf("ETag() = %s, want a strong tag", etag)
+	}
+	if want := `"` + profile.Hash[:32] + `"`; etag != want {
+		t.Errorf("ETag() = %s, want %s", etag, want)
+	}
+
+	// The tag follows the content only
+	same := &Profile{Hash: ContentHash([]byte(`{"outbounds":[]}`))}
+	if same.ETag() != etag {
+		t.Errorf("ETag() of the same content = %s, want %s", same.ETag(), etag)
+	}
+	changed := &Profile{Hash: ContentHash([]byte(`{"outbounds":[{}]}`))}
+	if changed.ETag() == etag {
+		t.Errorf("ETag() of changed content = %s, want another tag", changed.ETag())
+	}
+}
+
+func TestMatchesETag(t *testing.T) {
+	const etag = `"0123456789abcdef"`
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{name: "no header", ifNoneMatch: "", etag: etag, want: false},
+		{name: "same strong tag", ifNoneMatch: etag, etag: etag, want: true},
+		{name: "other tag", ifNoneMatch: `"fedcba9876543210"`, etag: etag, want: false},
+		{name: "weak candidate", ifNoneMatch: `W/"0123456789abcdef"`, etag: etag, want: true},
+		{name: "weak etag", ifNoneMatch: etag, etag: `W/"0123456789abcdef"`, want: true},
+		{name: "both weak", ifNoneMatch: `W/"0123456789abcdef"`, etag: `W/"0123456789abcdef"`, want: true},
+		{name: "other weak tag", ifNoneMatch: `W/"fedcba9876543210"`, etag: etag, want: false},
+		{name: "unquoted tag", ifNoneMatch: "0123456789abcdef", etag: etag, want: false},
+		{name: "match in a list", ifNoneMatch: `"aaaa", "0123456789abcdef", "bbbb"`, etag: etag, want: true},
+		{name: "weak match in a list", ifNoneMatch: `"aaaa",W/"0123456789abcdef"`, etag: etag, want: true},
+		{name: "no match in a list", ifNoneMatch: `"aaaa", W/"bbbb"`, etag: etag, want: false},
+		{name: "any", ifNoneMatch: "*", etag: etag, want: true},
+		{name: "any in a list", ifNoneMatch: `"aaaa", *`, etag: etag, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchesETag(tt.ifNoneMatch, tt.etag); got != tt.want {
+				t.Errorf("MatchesETag(%q, %q) = %v, want %v", tt.ifNoneMatch, tt.etag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateIntervalHeader(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		want     string
+	}{
+		{0, "1"},
+		{30 * time.Minute, "1"},
+		{time.Hour, "1"},
+		{90 * time.Minute, "2"},
+		{12 * time.Hour, "12"},
+		{24*time.Hour + time.Second, "25"},
+	}
+	for _, tt := range tests {
+		if got := UpdateIntervalHeader(tt.interval); got != tt.want {
+			t.Errorf("UpdateIntervalHeader(%v) = %q, want %q", tt.interval, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package telemetry reports anonymous deployment statistics to help the
+// maintainers prioritize work. Reporting is opt-in: nothing is sent unless
+// telemetry.enabled is set. The payload is limited to the fields of Payload;
+// it never includes users, nodes, addresses, credentials or traffic.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/version"
+)
+
+// SchemaVersion is the version of the payload format
+const SchemaVersion = 1
+
+// Payload is the complete report. Counts are reported as buckets so that a
+// deployment cannot be recognized by its exact size.
+type Payload struct {
+	SchemaVersion  int    `json:"schema_version"`
+	Version        string `json:"version"`
+	GoVersion      string `json:"go_version"`
+	OS             string `json:"os"`
+	Arch           string `json:"arch"`
+	DatabaseDriver string `json:"database_driver"`
+	NodeCount      string `json:"node_count"`
+}
+
+// Collect builds the payload of a deployment with the database driver and
+// the number of nodes
+func Collect(databaseDriver string, nodeCount int64) Payload {
+	return Payload{
+		SchemaVersion:  SchemaVersion,
+		Version:        version.Version,
+		GoVersion:      version.GoVersion,
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		DatabaseDriver: databaseDriver,
+		NodeCount:      NodeCountBucket(nodeCount),
+	}
+}
+
+// nodeCountBuckets are the upper bounds of the node count buckets
+var nodeCountBuckets = []struct {
+	max   int64
+	label string
+}{
+	{0, "0"},
+	{1, "1"},
+	{5, "2-5"},
+	{20, "6-20"},
+	{100, "21-100"},
+}
+
+// NodeCountBucket returns the bucket reported for a number of nodes
+func NodeCountBucket(count int64) string {
+	for _, bucket := range nodeCountBuckets {
+		if count <= bucket.max {
+			return bucket.label
+		}
+	}
+	return "100+"
+}
+
+// Reporter posts payloads to the telemetry endpoint
+type Reporter struct {
+	endpoint string
+	client   *http.Client
+	logger   *zap.Logger
+}
+
+// NewReporter creates a reporter posting to the configured endpoint
+func NewReporter(config configv1.TelemetryConfig, logger *zap.Logger) *Reporter {
+	return &Reporter{
+		endpoint: config.Endpoint,
+		client:   &http.Client{Timeout: config.Timeout},
+		logger:   logger,
+	}
+}
+
+// Report posts one payload
+func (r *Reporter) Report(ctx context.Context, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned status %d", resp.StatusCode)
+	}
+	r.logger.Debug("Telemetry reported", zap.ByteString("payload", body))
+	return nil
+}
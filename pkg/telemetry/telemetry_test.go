@@ -0,0 +1,89 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+func TestNodeCountBucket(t *testing.T) {
+	tests := []struct {
+		count int64
+		want  string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{2, "2-5"},
+		{5, "2-5"},
+		{6, "6-20"},
+		{20, "6-20"},
+		{21, "21-100"},
+		{100, "21-100"},
+		{101, "100+"},
+		{5000, "100+"},
+	}
+	for _, tt := range tests {
+		if got := NodeCountBucket(tt.count); got != tt.want {
+			t.Errorf("NodeCountBucket(%d) = %q, want %q", tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestReporterSendsOnlyPayloadFields(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("method = %s, want POST", r.Method)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	reporter := NewReporter(configv1.TelemetryConfig{Endpoint: server.URL, Timeout: time.Second}, zap.NewNop())
+	if err := reporter.Report(context.Background(), Collect("sqlite", 12)); err != nil {
+		t.Fatalf("Report failed: %v", err)
+	}
+
+	keys := make([]string, 0, len(received))
+	for key := range received {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	want := []string{"arch", "database_driver", "go_version", "node_count", "os", "schema_version", "version"}
+	if len(keys) != len(want) {
+		t.Fatalf("payload fields = %v, want %v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("payload fields = %v, want %v", keys, want)
+		}
+	}
+	if received["node_count"] != "6-20" || received["database_driver"] != "sqlite" {
+		t.Errorf("payload = %v", received)
+	}
+}
+
+func TestReporterRejectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	reporter := NewReporter(configv1.TelemetryConfig{Endpoint: server.URL, Timeout: time.Second}, zap.NewNop())
+	if err := reporter.Report(context.Background(), Collect("mysql", 0)); err == nil {
+		t.Error("Report succeeded on a 500 response")
+	}
+}
@@ -0,0 +1,116 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// gormSpanKey is the instance setting holding the span of a statement
+const gormSpanKey = "tracing:span"
+
+// gormPlugin records a span for each database operation run within a traced
+// operation, i.e. with a context carrying a span (db.WithContext). Operations
+// without one are not traced, so that background queries do not start
+// traces of their own.
+type gormPlugin struct{}
+
+// GORMPlugin returns the GORM plugin tracing database operations
+func GORMPlugin() gorm.Plugin {
+	return gormPlugin{}
+}
+
+// Name returns the name of the plugin
+func (gormPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize registers the callbacks around each kind of operation
+func (p gormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	hooks := []struct {
+		operation string
+		register  func(before, after func(*gorm.DB)) error
+	}{
+		{"create", func(before, after func(*gorm.DB)) error {
+			if err := callbacks.Create().Before("gorm:create").Register("tracing:before_create", before); err != nil {
+				return err
+			}
+			return callbacks.Create().After("gorm:create").Register("tracing:after_create", after)
+		}},
+		{"query", func(before, after func(*gorm.DB)) error {
+			if err := callbacks.Query().Before("gorm:query").Register("tracing:before_query", before); err != nil {
+				return err
+			}
+			return callbacks.Query().After("gorm:query").Register("tracing:after_query", after)
+		}},
+		{"update", func(before, after func(*gorm.DB)) error {
+			if err := callbacks.Update().Before("gorm:update").Register("tracing:before_update", before); err != nil {
+				return err
+			}
+			return callbacks.Update().After("gorm:update").Register("tracing:after_update", after)
+		}},
+		{"delete", func(before, after func(*gorm.DB)) error {
+			if err := callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", before); err != nil {
+				return err
+			}
+			return callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", after)
+		}},
+		{"row", func(before, after func(*gorm.DB)) error {
+			if err := callbacks.Row().Before("gorm:row").Register("tracing:before_row", before); err != nil {
+				return err
+			}
+			return callbacks.Row().After("gorm:row").Register("tracing:after_row", after)
+		}},
+		{"raw", func(before, after func(*gorm.DB)) error {
+			if err := callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", before); err != nil {
+				return err
+			}
+			return callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", after)
+		}},
+	}
+	for _, hook := range hooks {
+		if err := hook.register(startSpan(hook.operation), endSpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSpan starts the span of an operation when its context is traced
+func startSpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil || !trace.SpanFromContext(ctx).IsRecording() {
+			return
+		}
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// endSpan ends the span of an operation with its statement and outcome
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("db.system", db.Dialector.Name()),
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sampleWindow is the window of the rate sampler, as SkyWalking's
+// sample_n_per_3_secs
+const sampleWindow = 3 * time.Second
+
+// rateSampler samples at most limit new traces per window; a limit of 0 or
+// less samples every trace. It decides for root spans only, see
+// sdktrace.ParentBased.
+type rateSampler struct {
+	limit int
+	now   func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+func newRateSampler(limit int) *rateSampler {
+	return &rateSampler{limit: limit, now: time.Now}
+}
+
+// ShouldSample samples a new trace while the window has room
+func (s *rateSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	result := sdktrace.SamplingResult{
+		Decision:   sdktrace.Drop,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+	if s.allow() {
+		result.Decision = sdktrace.RecordAndSample
+	}
+	return result
+}
+
+// allow counts a new trace and reports whether it is within the limit
+func (s *rateSampler) allow() bool {
+	if s.limit <= 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.windowStart) >= sampleWindow {
+		s.windowStart = now
+		s.count = 0
+	}
+	if s.count >= s.limit {
+		return false
+	}
+	s.count++
+	return true
+}
+
+// Description describes the sampler
+func (s *rateSampler) Description() string {
+	return fmt.Sprintf("RateSampler{%d per %s}", s.limit, sampleWindow)
+}
@@ -0,0 +1,42 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestRateSampler(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sampler := newRateSampler(2)
+	sampler.now = func() time.Time { return now }
+
+	sample := func() bool {
+		result := sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background()})
+		return result.Decision == sdktrace.RecordAndSample
+	}
+
+	for i, want := range []bool{true, true, false, false} {
+		if got := sample(); got != want {
+			t.Errorf("trace %d sampled = %v, want %v", i, got, want)
+		}
+	}
+
+	// The next window has room again
+	now = now.Add(sampleWindow)
+	if !sample() {
+		t.Error("trace of the next window not sampled")
+	}
+}
+
+func TestRateSamplerUnlimited(t *testing.T) {
+	sampler := newRateSampler(0)
+	for i := 0; i < 100; i++ {
+		result := sampler.ShouldSample(sdktrace.SamplingParameters{ParentContext: context.Background()})
+		if result.Decision != sdktrace.RecordAndSample {
+			t.Fatalf("trace %d not sampled without a limit", i)
+		}
+	}
+}
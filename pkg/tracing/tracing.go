@@ -0,0 +1,82 @@
+// Package tracing sets up distributed tracing with OpenTelemetry. Setup
+// installs the global tracer provider; the HTTP, gRPC and GORM
+// instrumentation of the servers uses it and records nothing until then.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+
+	configv1 "sing-box-web/pkg/config/v1"
+)
+
+// Exporters of SkyWalkingConfig
+const (
+	// ExporterSkyWalking exports to a SkyWalking OAP, authenticated by its token
+	ExporterSkyWalking = "skywalking"
+	// ExporterOTLP exports to an OTLP collector
+	ExporterOTLP = "otlp"
+)
+
+// skyWalkingAuthHeader is the metadata a SkyWalking OAP reads its token from
+const skyWalkingAuthHeader = "authentication"
+
+// instrumentationName names the tracer of the instrumentation of this package
+const instrumentationName = "sing-box-web/pkg/tracing"
+
+// Setup installs the tracer provider and the W3C trace context propagation
+// described by config, and returns the function flushing the spans still
+// buffered and stopping the export. Nothing is installed when tracing is
+// disabled.
+func Setup(ctx context.Context, config configv1.SkyWalkingConfig, logger *zap.Logger) (func(context.Context) error, error) {
+	if !config.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	headers := make(map[string]string, len(config.Headers)+1)
+	for key, value := range config.Headers {
+		headers[key] = value
+	}
+	if config.Exporter == ExporterSkyWalking && config.Authentication != "" {
+		headers[skyWalkingAuthHeader] = config.Authentication
+	}
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(config.Collector),
+		otlptracegrpc.WithHeaders(headers),
+	}
+	if config.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	// The exporter connects lazily, an unreachable collector does not block startup
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(newRateSampler(config.SampleRate))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", config.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("Tracing error", zap.Error(err))
+	}))
+
+	logger.Info("Tracing enabled",
+		zap.String("exporter", config.Exporter),
+		zap.String("collector", config.Collector),
+		zap.String("service", config.ServiceName),
+		zap.Int("sample_rate", config.SampleRate),
+	)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,575 @@
+package traffic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"sing-box-web/pkg/alert"
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/events"
+	"sing-box-web/pkg/metrics"
+	"sing-box-web/pkg/models"
+	pbv1 "sing-box-web/pkg/pb/v1"
+	"sing-box-web/pkg/repository"
+)
+
+var (
+	// ErrBufferFull is returned by Add while the ingestion buffer is full
+	ErrBufferFull = errors.New("traffic ingestion buffer is full")
+	// ErrDuplicateReport is returned by Add for a report received before
+	ErrDuplicateReport = errors.New("traffic report received before")
+)
+
+// reportKey identifies a report of a node
+type reportKey struct {
+	nodeID   uint
+	reportID string
+}
+
+// pendingReport is a report with buffered records
+type pendingReport struct {
+	key     reportKey
+	records int
+}
+
+// Ingester buffers traffic records reported by agents and writes them in
+// batches. Each flush inserts the buffered records with BatchCreateRecords and
+// applies the per-user totals with a single aggregated UPDATE.
+type Ingester struct {
+	config configv1.TrafficConfig
+	repo   *repository.Manager
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	pending []*models.TrafficRecord
+	// unapplied is the usage of records written to tenant databases whose
+	// shared transaction failed, applied with the next flush
+	unapplied map[uint]int64
+	// Reports with buffered records and the report of each of the records.
+	// The receipt of a report is written with its last records, so that a
+	// report lost before it is stored is accepted again.
+	reports map[reportKey]*pendingReport
+	owners  map[*models.TrafficRecord]*pendingReport
+
+	// Quota alerts, nil when user alerts are disabled
+	alerts          *alert.Engine
+	quotaThresholds []int
+
+	// Live traffic counters, nil when not published
+	events *events.Bus
+
+	flushCh chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewIngester creates a new traffic ingester
+func NewIngester(config configv1.TrafficConfig, repo *repository.Manager, logger *zap.Logger) *Ingester {
+	return &Ingester{
+		config:  config,
+		repo:    repo,
+		logger:  logger.Named("traffic-ingester"),
+		pending: make([]*models.TrafficRecord, 0, config.BatchSize),
+		reports: make(map[reportKey]*pendingReport),
+		owners:  make(map[*models.TrafficRecord]*pendingReport),
+		flushCh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// SetAlerts raises quota alerts with engine for users that reached one of
+// the quotaThresholds percentages of their quota, or their plan's own
+// thresholds, and for users that used all of it
+func (i *Ingester) SetAlerts(engine *alert.Engine, quotaThresholds []int) {
+	i.alerts = engine
+	i.quotaThresholds = quotaThresholds
+}
+
+// SetQuotaThresholds changes the default quota warning thresholds, e.g. when
+// the configuration is reloaded
+func (i *Ingester) SetQuotaThresholds(quotaThresholds []int) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.quotaThresholds = quotaThresholds
+}
+
+// SetEvents publishes the traffic of each flush to bus
+func (i *Ingester) SetEvents(bus *events.Bus) {
+	i.events = bus
+}
+
+// Start starts flushing the buffer every report interval or when a batch is full
+func (i *Ingester) Start(ctx context.Context) {
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+
+		ticker := time.NewTicker(i.config.ReportInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				i.Flush()
+				return
+			case <-i.done:
+				i.Flush()
+				return
+			case <-ticker.C:
+				i.Flush()
+			case <-i.flushCh:
+				i.Flush()
+			}
+		}
+	}()
+
+	i.logger.Info("traffic ingester started",
+		zap.Duration("flush_interval", i.config.ReportInterval),
+		zap.Int("batch_size", i.config.BatchSize),
+		zap.Int("max_buffered_records", i.config.MaxBufferedRecords),
+	)
+}
+
+// Stop flushes the remaining records and stops the ingester
+func (i *Ingester) Stop() {
+	select {
+	case <-i.done:
+	default:
+		close(i.done)
+	}
+	i.wg.Wait()
+}
+
+// Add queues the records of a report of a node for the next flush. A report
+// received before is rejected with ErrDuplicateReport, and the whole report
+// with ErrBufferFull when it does not fit, so that agents retry it later.
+// Reports without an ID, from older agents, are always accepted.
+func (i *Ingester) Add(nodeID uint, reportID string, records []*models.TrafficRecord) error {
+	if len(records) == 0 {
+		// Nothing is lost with a report without traffic
+		if reportID == "" {
+			return nil
+		}
+		fresh, err := i.repo.AgentReport.Record(nodeID, reportID)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrDuplicateReport
+		}
+		return nil
+	}
+
+	key := reportKey{nodeID: nodeID, reportID: reportID}
+	i.mu.Lock()
+	if reportID != "" {
+		// Looked up under the lock, as a flush forgets a report once its
+		// receipt is committed
+		received, err := i.repo.AgentReport.Exists(nodeID, reportID)
+		if err != nil {
+			i.mu.Unlock()
+			return err
+		}
+		if _, buffered := i.reports[key]; buffered || received {
+			i.mu.Unlock()
+			return ErrDuplicateReport
+		}
+	}
+	if len(i.pending)+len(records) > i.config.MaxBufferedRecords {
+		i.mu.Unlock()
+		metrics.RecordTrafficIngestRejected(len(records))
+		return ErrBufferFull
+	}
+	i.pending = append(i.pending, records...)
+	if reportID != "" {
+		report := &pendingReport{key: key, records: len(records)}
+		i.reports[key] = report
+		for _, record := range records {
+			i.owners[record] = report
+		}
+	}
+	buffered := len(i.pending)
+	i.mu.Unlock()
+
+	metrics.SetTrafficIngestBuffered(buffered)
+
+	if buffered >= i.config.BatchSize {
+		select {
+		case i.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Buffered returns the number of records waiting for the next flush
+func (i *Ingester) Buffered() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return len(i.pending)
+}
+
+// Flush writes all buffered records. Records of a failed flush are put back
+// in front of the buffer and retried on the next flush.
+func (i *Ingester) Flush() {
+	i.mu.Lock()
+	batch := i.pending
+	i.pending = make([]*models.TrafficRecord, 0, i.config.BatchSize)
+	i.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	start := time.Now()
+	result, err := i.write(batch)
+	metrics.RecordTrafficIngestFlush(len(batch), err == nil, time.Since(start))
+	i.stored(result.written)
+
+	if err != nil {
+		i.logger.Error("Failed to flush traffic records", zap.Error(err), zap.Int("records", len(result.failed)))
+		i.requeue(result.failed)
+		if len(result.written) == 0 {
+			return
+		}
+	}
+
+	metrics.SetTrafficIngestBuffered(i.Buffered())
+	i.logger.Debug("traffic records flushed",
+		zap.Int("records", len(result.written)),
+		zap.Int("users", len(result.usage)),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	recordUserTraffic(result.written)
+	i.publishTraffic(result.written, len(result.usage))
+	if result.usage != nil {
+		i.checkQuotas(result.usage)
+	}
+}
+
+// recordUserTraffic adds a written batch to the per-user traffic counters
+func recordUserTraffic(batch []*models.TrafficRecord) {
+	for _, record := range batch {
+		userID := strconv.FormatUint(uint64(record.UserID), 10)
+		nodeID := strconv.FormatUint(uint64(record.NodeID), 10)
+		if record.Upload > 0 {
+			metrics.RecordUserTraffic(userID, "upload", nodeID, record.Upload)
+		}
+		if record.Download > 0 {
+			metrics.RecordUserTraffic(userID, "download", nodeID, record.Download)
+		}
+	}
+}
+
+// publishTraffic publishes the totals of a written batch, per node
+func (i *Ingester) publishTraffic(batch []*models.TrafficRecord, users int) {
+	if i.events == nil {
+		return
+	}
+
+	event := &pbv1.TrafficEvent{Users: int32(users)}
+	nodes := make(map[uint]*pbv1.NodeTrafficCounter)
+	for _, record := range batch {
+		event.UploadBytes += record.Upload
+		event.DownloadBytes += record.Download
+		counter, ok := nodes[record.NodeID]
+		if !ok {
+			counter = &pbv1.NodeTrafficCounter{NodeId: strconv.FormatUint(uint64(record.NodeID), 10)}
+			nodes[record.NodeID] = counter
+			event.Nodes = append(event.Nodes, counter)
+		}
+		counter.UploadBytes += record.Upload
+		counter.DownloadBytes += record.Download
+	}
+	i.events.Publish(&pbv1.Event{Type: events.TypeTrafficReported, Traffic: event})
+}
+
+// flushResult is the outcome of writing a batch
+type flushResult struct {
+	// written are the stored records, failed those to retry
+	written []*models.TrafficRecord
+	failed  []*models.TrafficRecord
+	// usage is the user usage applied, nil when none was
+	usage map[uint]int64
+}
+
+// write stores a batch, the aggregated user usage and the receipts of the
+// reports stored in full in one transaction. Records of tenants with a
+// dedicated database are written to it first, each database in its own
+// transaction, and their usage is applied with the shared transaction. A
+// failed tenant database only fails its own records.
+func (i *Ingester) write(batch []*models.TrafficRecord) (flushResult, error) {
+	shared := batch
+	var written []*models.TrafficRecord
+	var result flushResult
+	var tenantErr error
+
+	if tenants := i.repo.TenantDatabases(); tenants != nil {
+		groups, err := tenants.SplitRecords(batch)
+		if err != nil {
+			return flushResult{failed: batch}, err
+		}
+		shared = groups[0]
+		for _, tenantID := range tenants.TenantIDs() {
+			records := groups[tenantID]
+			if len(records) == 0 {
+				continue
+			}
+			err := tenants.DB(&tenantID).Transaction(func(tx *gorm.DB) error {
+				return repository.NewTrafficRepository(tx).BatchCreateRecords(records)
+			})
+			if err != nil {
+				result.failed = append(result.failed, records...)
+				tenantErr = errors.Join(tenantErr, fmt.Errorf("tenant %d database: %w", tenantID, err))
+				continue
+			}
+			written = append(written, records...)
+		}
+	}
+
+	usage := make(map[uint]int64)
+	for _, record := range shared {
+		usage[record.UserID] += record.Total
+	}
+	for _, record := range written {
+		usage[record.UserID] += record.Total
+	}
+	i.mu.Lock()
+	carried := i.unapplied
+	i.unapplied = nil
+	i.mu.Unlock()
+	for userID, total := range carried {
+		usage[userID] += total
+	}
+
+	receipts := i.completeReports(batch, result.failed)
+	err := i.repo.Transaction(func(tx *gorm.DB) error {
+		if err := repository.NewTrafficRepository(tx).BatchCreateRecords(shared); err != nil {
+			return err
+		}
+		if err := repository.NewAgentReportRepository(tx).RecordBatch(receipts); err != nil {
+			return err
+		}
+		return repository.NewUserRepository(tx).BatchAddTrafficUsage(usage)
+	})
+	if err != nil {
+		// Records committed to tenant databases must not be written again,
+		// their usage is kept for the next flush instead
+		i.mu.Lock()
+		if i.unapplied == nil {
+			i.unapplied = make(map[uint]int64)
+		}
+		for userID, total := range carried {
+			i.unapplied[userID] += total
+		}
+		for _, record := range written {
+			i.unapplied[record.UserID] += record.Total
+		}
+		i.mu.Unlock()
+
+		result.written = written
+		result.failed = append(result.failed, shared...)
+		err = errors.Join(err, tenantErr)
+	} else {
+		result.written = append(written, shared...)
+		result.usage = usage
+		err = tenantErr
+	}
+
+	// The analytics copy is best effort, retrying would duplicate the committed records
+	if store := i.repo.Analytics(); store != nil && len(result.written) > 0 {
+		if err := store.InsertRecords(result.written); err != nil {
+			i.logger.Error("Failed to copy traffic records to analytics store", zap.Error(err), zap.Int("records", len(result.written)))
+		}
+	}
+	return result, err
+}
+
+// completeReports returns the receipts of the reports of a batch whose
+// records are all stored when the batch is, none of them failing in a tenant
+// database
+func (i *Ingester) completeReports(batch, failed []*models.TrafficRecord) []*models.AgentReportReceipt {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	incomplete := make(map[*pendingReport]bool)
+	for _, record := range failed {
+		if report := i.owners[record]; report != nil {
+			incomplete[report] = true
+		}
+	}
+	var receipts []*models.AgentReportReceipt
+	for _, record := range batch {
+		report := i.owners[record]
+		if report == nil || incomplete[report] {
+			continue
+		}
+		// Each report is listed once
+		incomplete[report] = true
+		receipts = append(receipts, &models.AgentReportReceipt{NodeID: report.key.nodeID, ReportID: report.key.reportID})
+	}
+	return receipts
+}
+
+// stored forgets the reports of written records once all their records are
+// written, their receipts being committed with them
+func (i *Ingester) stored(written []*models.TrafficRecord) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, record := range written {
+		report := i.owners[record]
+		if report == nil {
+			continue
+		}
+		delete(i.owners, record)
+		if report.records--; report.records == 0 {
+			delete(i.reports, report.key)
+		}
+	}
+}
+
+// requeue puts a failed batch back in front of the buffer, dropping what no
+// longer fits. The oldest records are dropped, along with the other records
+// of their reports, so that agents may send those reports again in full.
+func (i *Ingester) requeue(batch []*models.TrafficRecord) {
+	i.mu.Lock()
+	room := i.config.MaxBufferedRecords - len(i.pending)
+	dropped := 0
+	if room < len(batch) {
+		lost := make(map[*pendingReport]bool)
+		for _, record := range batch[:len(batch)-max(room, 0)] {
+			if report := i.owners[record]; report != nil {
+				lost[report] = true
+			}
+		}
+		kept := make([]*models.TrafficRecord, 0, max(room, 0))
+		for n, record := range batch {
+			report := i.owners[record]
+			if n >= len(batch)-max(room, 0) && !lost[report] {
+				kept = append(kept, record)
+				continue
+			}
+			if report != nil {
+				delete(i.owners, record)
+				delete(i.reports, report.key)
+			}
+		}
+		dropped = len(batch) - len(kept)
+		batch = kept
+	}
+	i.pending = append(batch, i.pending...)
+	buffered := len(i.pending)
+	i.mu.Unlock()
+
+	metrics.SetTrafficIngestBuffered(buffered)
+	if dropped > 0 {
+		metrics.RecordTrafficIngestDropped(dropped)
+		i.logger.Error("Dropped traffic records after failed flush", zap.Int("records", dropped))
+	}
+}
+
+// checkQuotas warns about users that used up their quota with this flush,
+// and alerts those past a warning threshold or over it when alerts are enabled
+func (i *Ingester) checkQuotas(usage map[uint]int64) {
+	userIDs := make([]uint, 0, len(usage))
+	for userID := range usage {
+		userIDs = append(userIDs, userID)
+	}
+
+	i.mu.Lock()
+	defaults := i.quotaThresholds
+	i.mu.Unlock()
+
+	percent := 100
+	var planThresholds map[uint][]int
+	if i.alerts != nil {
+		var err error
+		if planThresholds, err = i.repo.Plan.ListQuotaWarningThresholds(); err != nil {
+			i.logger.Error("Failed to get plan quota thresholds", zap.Error(err))
+			return
+		}
+		percent = lowestThreshold(defaults, planThresholds)
+	}
+	users, err := i.repo.User.GetNearQuota(userIDs, percent)
+	if err != nil {
+		i.logger.Error("Failed to check traffic quotas", zap.Error(err))
+		return
+	}
+
+	for _, user := range users {
+		exceeded := user.TrafficUsed >= user.TrafficQuota
+		if exceeded {
+			i.logger.Warn("User used up traffic quota",
+				zap.Uint("user_id", user.ID),
+				zap.Int64("used", user.TrafficUsed),
+				zap.Int64("quota", user.TrafficQuota),
+			)
+		}
+		if i.alerts == nil {
+			continue
+		}
+		if exceeded {
+			i.alerts.Raise(quotaAlert(user, 100))
+			continue
+		}
+		thresholds, ok := planThresholds[user.PlanID]
+		if !ok {
+			thresholds = defaults
+		}
+		// Only the highest threshold reached is raised; lower ones skipped by
+		// a large flush stay silent for the rest of the period
+		if threshold := models.CrossedQuotaThreshold(user.TrafficUsed, user.TrafficQuota, thresholds); threshold > 0 {
+			i.alerts.Raise(quotaAlert(user, threshold))
+		}
+	}
+}
+
+// lowestThreshold returns the lowest quota warning threshold of the defaults
+// and the plans, 100 when there is none
+func lowestThreshold(defaults []int, plans map[uint][]int) int {
+	lowest := 100
+	if len(defaults) > 0 {
+		lowest = min(lowest, defaults[0])
+	}
+	for _, thresholds := range plans {
+		lowest = min(lowest, thresholds[0])
+	}
+	return lowest
+}
+
+// quotaAlert builds the quota alert of a user for a threshold, 100 when the
+// quota is used up. Each threshold is raised once per quota period.
+func quotaAlert(user *models.User, threshold int) *alert.Alert {
+	period := user.TrafficResetDate.Format("2006-01-02")
+	used := fmt.Sprintf("%s of %s", models.FormatBytes(user.TrafficUsed), models.FormatBytes(user.TrafficQuota))
+	if threshold >= 100 {
+		return &alert.Alert{
+			UserID:   user.ID,
+			Type:     models.NotificationTypeQuotaExceeded,
+			Severity: models.SeverityCritical,
+			Title:    "Traffic quota used up",
+			Message:  fmt.Sprintf("You have used %s traffic for this period.", used),
+			Key:      "quota_exceeded:" + period,
+		}
+	}
+	severity := models.SeverityInfo
+	if threshold >= 80 {
+		severity = models.SeverityWarning
+	}
+	return &alert.Alert{
+		UserID:   user.ID,
+		Type:     models.NotificationTypeQuotaWarning,
+		Severity: severity,
+		Title:    fmt.Sprintf("%d%% of traffic quota used", threshold),
+		Message:  fmt.Sprintf("You have used %s traffic for this period.", used),
+		Key:      fmt.Sprintf("quota_warning:%d:%s", threshold, period),
+	}
+}
@@ -0,0 +1,244 @@
+package traffic
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	configv1 "sing-box-web/pkg/config/v1"
+	"sing-box-web/pkg/models"
+	"sing-box-web/pkg/repository"
+)
+
+func newIngesterTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=10000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := (&models.Database{DB: db}).AutoMigrate(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newTestIngester returns an ingester of a fresh database, never flushed but
+// by the test
+func newTestIngester(t *testing.T, maxBuffered int) (*Ingester, *gorm.DB) {
+	t.Helper()
+	db := newIngesterTestDB(t)
+	config := configv1.TrafficConfig{ReportInterval: time.Hour, BatchSize: 100, MaxBufferedRecords: maxBuffered}
+	return NewIngester(config, repository.NewManager(db), zap.NewNop()), db
+}
+
+func testRecords(userID uint, totals ...int64) []*models.TrafficRecord {
+	now := time.Now()
+	records := make([]*models.TrafficRecord, len(totals))
+	for n, total := range totals {
+		records[n] = &models.TrafficRecord{UserID: userID, NodeID: 1, Download: total, Total: total,
+			ConnectTime: now, RecordDate: now.Truncate(24 * time.Hour), RecordHour: now.Hour()}
+	}
+	return records
+}
+
+// failInserts makes inserts of traffic records fail until the returned
+// function is called
+func failInserts(t *testing.T, db *gorm.DB) func() {
+	t.Helper()
+	err := db.Exec("CREATE TRIGGER fail_traffic_records BEFORE INSERT ON traffic_records BEGIN SELECT RAISE(ABORT, 'injected failure'); END").Error
+	if err != nil {
+		t.Fatalf("create trigger: %v", err)
+	}
+	return func() {
+		if err := db.Exec("DROP TRIGGER fail_traffic_records").Error; err != nil {
+			t.Fatalf("drop trigger: %v", err)
+		}
+	}
+}
+
+func countRecords(t *testing.T, db *gorm.DB) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.TrafficRecord{}).Count(&count).Error; err != nil {
+		t.Fatalf("count records: %v", err)
+	}
+	return count
+}
+
+func TestIngesterReportReceipts(t *testing.T) {
+	ingester, db := newTestIngester(t, 100)
+	receipts := repository.NewAgentReportRepository(db)
+
+	if err := ingester.Add(1, "report-1", testRecords(1, 10, 20)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// A buffered report is a duplicate before its receipt is written
+	if err := ingester.Add(1, "report-1", testRecords(1, 10, 20)); !errors.Is(err, ErrDuplicateReport) {
+		t.Fatalf("Add of a buffered report = %v, want ErrDuplicateReport", err)
+	}
+	if exists, _ := receipts.Exists(1, "report-1"); exists {
+		t.Fatal("receipt written before the records")
+	}
+
+	// A failed flush writes neither the records nor the receipt
+	restore := failInserts(t, db)
+	ingester.Flush()
+	if exists, _ := receipts.Exists(1, "report-1"); exists {
+		t.Fatal("receipt written by a failed flush")
+	}
+	if err := ingester.Add(1, "report-1", testRecords(1, 10, 20)); !errors.Is(err, ErrDuplicateReport) {
+		t.Fatalf("Add of a requeued report = %v, want ErrDuplicateReport", err)
+	}
+	restore()
+
+	ingester.Flush()
+	if count := countRecords(t, db); count != 2 {
+		t.Fatalf("records = %d, want 2", count)
+	}
+	if exists, _ := receipts.Exists(1, "report-1"); !exists {
+		t.Fatal("receipt not written with the records")
+	}
+	if err := ingester.Add(1, "report-1", testRecords(1, 10, 20)); !errors.Is(err, ErrDuplicateReport) {
+		t.Fatalf("Add of a stored report = %v, want ErrDuplicateReport", err)
+	}
+	if len(ingester.reports) != 0 || len(ingester.owners) != 0 {
+		t.Errorf("stored report still tracked: %d reports, %d records", len(ingester.reports), len(ingester.owners))
+	}
+
+	// A report without traffic is recorded right away
+	if err := ingester.Add(1, "report-2", nil); err != nil {
+		t.Fatalf("Add of an empty report: %v", err)
+	}
+	if err := ingester.Add(1, "report-2", nil); !errors.Is(err, ErrDuplicateReport) {
+		t.Fatalf("second Add of an empty report = %v, want ErrDuplicateReport", err)
+	}
+}
+
+func TestIngesterDroppedReportAcceptedAgain(t *testing.T) {
+	ingester, _ := newTestIngester(t, 3)
+
+	if err := ingester.Add(1, "old", testRecords(1, 10, 20)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	batch := ingester.pending
+	ingester.pending = nil
+	if err := ingester.Add(1, "new", testRecords(2, 30, 40)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// One record fits back, the whole report is dropped instead
+	ingester.requeue(batch)
+	if buffered := ingester.Buffered(); buffered != 2 {
+		t.Fatalf("buffered = %d, want the 2 records of the newer report", buffered)
+	}
+	if len(ingester.owners) != 2 {
+		t.Errorf("records of the dropped report still tracked: %d tracked", len(ingester.owners))
+	}
+	if err := ingester.Add(1, "old", testRecords(1, 10)); err != nil {
+		t.Fatalf("Add of a dropped report = %v, want it accepted", err)
+	}
+}
+
+func bufferedTotals(ingester *Ingester) []int64 {
+	ingester.mu.Lock()
+	defer ingester.mu.Unlock()
+	totals := make([]int64, len(ingester.pending))
+	for n, record := range ingester.pending {
+		totals[n] = record.Total
+	}
+	return totals
+}
+
+func TestIngesterFailedFlush(t *testing.T) {
+	ingester, db := newTestIngester(t, 100)
+	user := &models.User{Username: "alice", Email: "alice@example.com", Password: "x", Status: models.UserStatusActive}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	if err := ingester.Add(1, "report-1", testRecords(user.ID, 10, 20)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	restore := failInserts(t, db)
+	ingester.Flush()
+
+	// Neither the records nor the usage are stored, the records wait for the
+	// next flush
+	if count := countRecords(t, db); count != 0 {
+		t.Fatalf("records = %d after a failed flush, want 0", count)
+	}
+	var stored models.User
+	db.First(&stored, user.ID)
+	if stored.TrafficUsed != 0 {
+		t.Fatalf("traffic used = %d after a failed flush, want 0", stored.TrafficUsed)
+	}
+	if buffered := ingester.Buffered(); buffered != 2 {
+		t.Fatalf("buffered = %d, want the 2 records of the failed flush", buffered)
+	}
+
+	restore()
+	ingester.Flush()
+	if count := countRecords(t, db); count != 2 {
+		t.Fatalf("records = %d after the retry, want 2", count)
+	}
+	db.First(&stored, user.ID)
+	if stored.TrafficUsed != 30 {
+		t.Errorf("traffic used = %d after the retry, want 30", stored.TrafficUsed)
+	}
+	if buffered := ingester.Buffered(); buffered != 0 {
+		t.Errorf("buffered = %d after the retry, want 0", buffered)
+	}
+}
+
+func TestIngesterRequeueOrder(t *testing.T) {
+	ingester, _ := newTestIngester(t, 100)
+
+	if err := ingester.Add(1, "old", testRecords(1, 10, 20)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// Records reported while the flush runs are buffered behind the failed
+	// ones, which are older
+	batch := ingester.pending
+	ingester.pending = nil
+	if err := ingester.Add(1, "new", testRecords(2, 30, 40)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	ingester.requeue(batch)
+
+	want := []int64{10, 20, 30, 40}
+	if got := bufferedTotals(ingester); !slices.Equal(got, want) {
+		t.Errorf("buffered totals = %v, want %v", got, want)
+	}
+}
+
+func TestIngesterBufferFull(t *testing.T) {
+	ingester, _ := newTestIngester(t, 3)
+
+	if err := ingester.Add(1, "first", testRecords(1, 10, 20)); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	// A report is rejected as a whole, none of its records are buffered
+	if err := ingester.Add(1, "second", testRecords(1, 30, 40)); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("Add to a full buffer = %v, want ErrBufferFull", err)
+	}
+	if got := bufferedTotals(ingester); !slices.Equal(got, []int64{10, 20}) {
+		t.Fatalf("buffered totals = %v, want [10 20]", got)
+	}
+	if _, tracked := ingester.reports[reportKey{nodeID: 1, reportID: "second"}]; tracked {
+		t.Fatal("rejected report tracked")
+	}
+
+	// The agent retries it once the buffer was flushed
+	ingester.Flush()
+	if err := ingester.Add(1, "second", testRecords(1, 30, 40)); err != nil {
+		t.Errorf("Add of a rejected report after a flush = %v, want it accepted", err)
+	}
+}
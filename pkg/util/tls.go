@@ -0,0 +1,136 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// pinPrefix starts every public key pin, followed by the base64 encoded
+// SHA-256 hash of a certificate's SubjectPublicKeyInfo
+const pinPrefix = "sha256/"
+
+// NewServerTLSConfig builds a server TLS configuration.
+// When clientCAFile is set, clients must present a certificate signed by it (mTLS).
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// NewClientTLSConfig builds a client TLS configuration.
+// caFile overrides the system roots; certFile/keyFile enable a client certificate for mTLS.
+// When pins are given, the server's verified chain must also contain a
+// certificate whose public key matches one of them.
+func NewClientTLSConfig(certFile, keyFile, caFile, serverName string, pins []string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if len(pins) > 0 {
+		hashes, err := ParsePublicKeyPins(pins)
+		if err != nil {
+			return nil, err
+		}
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(state.VerifiedChains, hashes)
+		}
+	}
+
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// PublicKeyPin returns the pin of a certificate's public key, in the
+// "sha256/<base64>" form accepted by NewClientTLSConfig
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// ParsePublicKeyPins decodes "sha256/<base64>" public key pins into SPKI hashes
+func ParsePublicKeyPins(pins []string) ([][]byte, error) {
+	hashes := make([][]byte, 0, len(pins))
+	for _, pin := range pins {
+		encoded, ok := strings.CutPrefix(pin, pinPrefix)
+		if !ok {
+			return nil, fmt.Errorf("public key pin %q must start with %q", pin, pinPrefix)
+		}
+		hash, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("public key pin %q is not a base64 encoded SHA-256 hash", pin)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
+// verifyPins checks that a verified chain contains a certificate whose public
+// key hash is one of hashes. Any pin matching is enough, so that a new key can
+// be pinned next to the current one while it is rolled out.
+func verifyPins(chains [][]*x509.Certificate, hashes [][]byte) error {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, hash := range hashes {
+				if bytes.Equal(sum[:], hash) {
+					return nil
+				}
+			}
+		}
+	}
+	return errors.New("server certificate does not match any pinned public key")
+}
+
+// loadCertPool reads PEM encoded certificates into a pool
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file %s: %w", path, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificates found in %s", path)
+	}
+	return pool, nil
+}
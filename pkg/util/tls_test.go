@@ -0,0 +1,199 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestVerifyPins(t *testing.T) {
+	leaf := newTestCertificate(t, "api.example.com")
+	ca := newTestCertificate(t, "Example CA")
+	other := newTestCertificate(t, "other")
+	chains := [][]*x509.Certificate{{leaf, ca}}
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr bool
+	}{
+		{"leaf pinned", []string{PublicKeyPin(leaf)}, false},
+		{"CA pinned", []string{PublicKeyPin(ca)}, false},
+		{"rotation keeps old pin", []string{PublicKeyPin(other), PublicKeyPin(leaf)}, false},
+		{"no match", []string{PublicKeyPin(other)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes, err := ParsePublicKeyPins(tt.pins)
+			if err != nil {
+				t.Fatalf("ParsePublicKeyPins() error = %v", err)
+			}
+			if err := verifyPins(chains, hashes); (err != nil) != tt.wantErr {
+				t.Errorf("verifyPins() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePublicKeyPinsRejectsMalformed(t *testing.T) {
+	for _, pin := range []string{
+		"",
+		"sha1/AAAA",
+		"sha256/not-base64!",
+		"sha256/AAAA", // too short for SHA-256
+	} {
+		if _, err := ParsePublicKeyPins([]string{pin}); err == nil {
+			t.Errorf("ParsePublicKeyPins(%q) succeeded, want error", pin)
+		}
+	}
+}
+
+// testIssuer is a certificate and its key, signing other test certificates
+type testIssuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// writeTestCertificate writes a certificate for name signed by issuer,
+// self-signed when issuer is nil, and its key as PEM files in dir
+func writeTestCertificate(t *testing.T, dir, name string, issuer *testIssuer, isCA bool) (*testIssuer, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	parent, parentKey := template, key
+	if issuer != nil {
+		parent, parentKey = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &testIssuer{cert: cert, key: key}, certFile, keyFile
+}
+
+func TestServerTLSConfigRequiresClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca, caFile, _ := writeTestCertificate(t, dir, "ca.example", nil, true)
+	_, serverCert, serverKey := writeTestCertificate(t, dir, "localhost", ca, false)
+	_, clientCert, clientKey := writeTestCertificate(t, dir, "agent.example", ca, false)
+	_, strangerCert, strangerKey := writeTestCertificate(t, dir, "stranger.example", nil, false)
+
+	serverConfig, err := NewServerTLSConfig(serverCert, serverKey, caFile)
+	if err != nil {
+		t.Fatalf("NewServerTLSConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  bool
+	}{
+		{"certificate signed by the CA", clientCert, clientKey, false},
+		{"no certificate", "", "", true},
+		{"certificate of another CA", strangerCert, strangerKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig, err := NewClientTLSConfig(tt.certFile, tt.keyFile, caFile, "localhost", nil)
+			if err != nil {
+				t.Fatalf("NewClientTLSConfig() error = %v", err)
+			}
+
+			listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			handshake := make(chan error, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					handshake <- err
+					return
+				}
+				defer conn.Close()
+				handshake <- conn.(*tls.Conn).Handshake()
+			}()
+
+			// With TLS 1.3 the client may finish its handshake before the
+			// server checks its certificate, the server side decides
+			conn, err := tls.Dial("tcp", listener.Addr().String(), clientConfig)
+			if err == nil {
+				defer conn.Close()
+			}
+			if err := <-handshake; (err != nil) != tt.wantErr {
+				t.Errorf("server handshake error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
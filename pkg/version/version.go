@@ -0,0 +1,14 @@
+// Package version holds the build information of the binaries, set by the
+// Makefile through -ldflags.
+package version
+
+import "runtime"
+
+var (
+	// Version is the release of the build, from git describe
+	Version = "dev"
+	// BuildDate is when the binary was built
+	BuildDate = ""
+	// GoVersion is the toolchain the binary was built with
+	GoVersion = runtime.Version()
+)
@@ -0,0 +1,163 @@
+#!/bin/sh
+# Installs the sing-box-agent on a new node and enrolls it with a one-time
+# enrollment token issued by the management API (POST /admin/node-enrollments).
+#
+#   curl -fsSL <url>/install-agent.sh | sudo sh -s -- --api api.example.com:8081 --token sbe_...
+#
+# Options:
+#   --api HOST:PORT   API server the agent connects to (required)
+#   --token TOKEN     One-time enrollment token (required)
+#   --name NAME       Node name, unless the enrollment sets one (default: hostname)
+#   --ca-file FILE    CA certificate of the API server (default: system CAs)
+#   --insecure        Connect without TLS
+#   --version VERSION Agent release to install (default: AGENT_VERSION below)
+#
+# The agent binary is checked against the SHA-256 published in the
+# sha256sums.txt of its release before it is installed.
+#
+# Environment:
+#   SING_BOX_AGENT_URL     Agent binary to install instead of the release one
+#   SING_BOX_AGENT_SHA256  Expected SHA-256 of the agent binary, required with
+#                          SING_BOX_AGENT_URL (default: from the release)
+#   SING_BOX_BINARY        sing-box binary used by the agent (default: found in PATH)
+set -eu
+
+# AGENT_VERSION is the agent release installed by default, bumped with
+# each release
+AGENT_VERSION="v0.1.0"
+RELEASES="https://github.com/HappyLadySauce/sing-box-web/releases/download"
+
+API=""
+TOKEN=""
+NAME="$(hostname)"
+CA_FILE=""
+INSECURE="false"
+VERSION="$AGENT_VERSION"
+
+CONFIG_DIR="/etc/sing-box-agent"
+STATE_DIR="/var/lib/sing-box-agent"
+BIN="/usr/local/bin/sing-box-agent"
+
+fail() {
+	echo "install-agent: $*" >&2
+	exit 1
+}
+
+# sha256 prints the SHA-256 of a file
+sha256() {
+	if command -v sha256sum >/dev/null 2>&1; then
+		sha256sum "$1" | awk '{print $1}'
+	elif command -v shasum >/dev/null 2>&1; then
+		shasum -a 256 "$1" | awk '{print $1}'
+	else
+		fail "neither sha256sum nor shasum is installed"
+	fi
+}
+
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--api) API="$2"; shift 2 ;;
+	--token) TOKEN="$2"; shift 2 ;;
+	--name) NAME="$2"; shift 2 ;;
+	--ca-file) CA_FILE="$2"; shift 2 ;;
+	--insecure) INSECURE="true"; shift ;;
+	--version) VERSION="$2"; shift 2 ;;
+	*) fail "unknown option $1" ;;
+	esac
+done
+
+[ -n "$API" ] || fail "--api is required"
+[ -n "$TOKEN" ] || fail "--token is required"
+[ "$(id -u)" -eq 0 ] || fail "must run as root"
+
+HOST="${API%:*}"
+PORT="${API##*:}"
+[ "$HOST" != "$API" ] && [ -n "$PORT" ] || fail "--api must be host:port"
+
+# A node enrolls once, a second run would waste the token
+if [ -f "$STATE_DIR/credentials.json" ]; then
+	fail "$STATE_DIR/credentials.json exists, this node is already enrolled"
+fi
+
+case "$(uname -m)" in
+x86_64 | amd64) ARCH="amd64" ;;
+aarch64 | arm64) ARCH="arm64" ;;
+*) fail "unsupported architecture $(uname -m)" ;;
+esac
+
+SING_BOX_BINARY="${SING_BOX_BINARY:-$(command -v sing-box || true)}"
+[ -n "$SING_BOX_BINARY" ] || fail "sing-box is not installed, set SING_BOX_BINARY to its path"
+
+ASSET="sing-box-agent-linux-$ARCH"
+URL="${SING_BOX_AGENT_URL:-$RELEASES/$VERSION/$ASSET}"
+SHA256="${SING_BOX_AGENT_SHA256:-}"
+if [ -z "$SHA256" ]; then
+	# A binary from elsewhere has no published checksum to trust
+	[ -z "${SING_BOX_AGENT_URL:-}" ] || fail "SING_BOX_AGENT_SHA256 is required with SING_BOX_AGENT_URL"
+	SHA256="$(curl -fsSL "$RELEASES/$VERSION/sha256sums.txt" | awk -v asset="$ASSET" '{sub(/^\*/, "", $2)} $2 == asset {print $1}')"
+	[ -n "$SHA256" ] || fail "no published checksum of $ASSET in release $VERSION"
+fi
+
+echo "Downloading sing-box-agent $VERSION from $URL"
+curl -fsSL -o "$BIN.tmp" "$URL" || fail "failed to download the agent"
+ACTUAL="$(sha256 "$BIN.tmp")"
+if [ "$ACTUAL" != "$(echo "$SHA256" | tr 'A-F' 'a-f')" ]; then
+	rm -f "$BIN.tmp"
+	fail "checksum mismatch for $URL: got $ACTUAL, want $SHA256"
+fi
+chmod 0755 "$BIN.tmp"
+mv "$BIN.tmp" "$BIN"
+
+mkdir -p "$CONFIG_DIR" "$STATE_DIR" /etc/sing-box
+chmod 0700 "$STATE_DIR"
+
+# The agent replaces the sing-box config with the one assigned to the node
+[ -f /etc/sing-box/config.json ] || echo '{}' >/etc/sing-box/config.json
+
+# The config holds the enrollment token until the agent has used it
+umask 077
+cat >"$CONFIG_DIR/agent.yaml" <<EOF
+apiVersion: v1
+kind: AgentConfig
+
+node:
+  nodeId: ""
+  nodeName: "$NAME"
+  maxUsers: 1000
+  enrollmentToken: "$TOKEN"
+  credentialsFile: "$STATE_DIR/credentials.json"
+
+apiServer:
+  address: "$HOST"
+  port: $PORT
+  timeout: 10s
+  insecure: $INSECURE
+  caFile: "$CA_FILE"
+
+singBox:
+  binaryPath: "$SING_BOX_BINARY"
+  configPath: "/etc/sing-box/config.json"
+  restartDelay: 5s
+EOF
+
+if command -v systemctl >/dev/null 2>&1; then
+	cat >/etc/systemd/system/sing-box-agent.service <<EOF
+[Unit]
+Description=sing-box-web node agent
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=$BIN --config=$CONFIG_DIR/agent.yaml
+Restart=on-failure
+RestartSec=5s
+
+[Install]
+WantedBy=multi-user.target
+EOF
+	systemctl daemon-reload
+	systemctl enable --now sing-box-agent
+	echo "sing-box-agent installed and started, follow it with: journalctl -u sing-box-agent -f"
+else
+	echo "sing-box-agent installed, start it with: $BIN --config=$CONFIG_DIR/agent.yaml"
+fi